@@ -0,0 +1,92 @@
+package v1alpha1
+
+import "time"
+
+// Sources of configuration values
+const (
+	// ConfigSourceEnv marks a value read from an environment variable
+	ConfigSourceEnv = "env"
+	// ConfigSourceDefault marks a built-in default
+	ConfigSourceDefault = "default"
+)
+
+// EffectiveConfig is the configuration a server is running with, with
+// secrets redacted. It is meant for support: it shows what a deployment
+// actually uses without anyone having to read its environment.
+type EffectiveConfig struct {
+	// TypeMeta describes the versioning of this object
+	TypeMeta `json:",inline"`
+	// ServerVersion is the version of the server binary
+	ServerVersion string `json:"serverVersion"`
+	// Settings lists every configuration variable, sorted by name
+	Settings []ConfigSetting `json:"settings"`
+}
+
+// ConfigSetting is one configuration value
+type ConfigSetting struct {
+	// Name is the environment variable, e.g. WSIGN_DB_HOST
+	Name string `json:"name"`
+	// Value is the value in effect; secrets read "<redacted>"
+	Value string `json:"value"`
+	// Source is env or default. A variable that is set but could not be
+	// parsed reports default, the value used instead.
+	Source string `json:"source"`
+	// Secret is true for variables that hold secrets
+	Secret bool `json:"secret,omitempty"`
+}
+
+// EventRetentionStatus describes how much space stored content events take
+// and how they are pruned
+type EventRetentionStatus struct {
+	// TypeMeta describes the versioning of this object
+	TypeMeta `json:",inline"`
+	// Retention is how long events are kept, such as 2160h0m0s; empty
+	// when they are kept forever
+	Retention string `json:"retention,omitempty"`
+	// Archive is set when pruned events are written to NDJSON files first
+	Archive bool `json:"archive"`
+	// Partitioned is set when events are stored in monthly partitions
+	Partitioned bool `json:"partitioned"`
+	// OldestEvent is the timestamp of the oldest event kept
+	OldestEvent *time.Time `json:"oldestEvent,omitempty"`
+	// TotalBytes is the space all the tables take, including indexes
+	TotalBytes int64 `json:"totalBytes"`
+	// Tables lists the partitions oldest first, or the one table events
+	// are stored in when they are not partitioned
+	Tables []EventTable `json:"tables"`
+	// LastRun describes the last pruning run made by the server answering,
+	// absent before its first
+	LastRun *EventPruneRun `json:"lastRun,omitempty"`
+}
+
+// EventTable is a table content events are stored in
+type EventTable struct {
+	// Name is the table's name
+	Name string `json:"name"`
+	// From and To bound the timestamps of the events the table holds, From
+	// inclusive and To exclusive; absent means unbounded
+	From *time.Time `json:"from,omitempty"`
+	To   *time.Time `json:"to,omitempty"`
+	// Default is set for the partition holding events no other covers
+	Default bool `json:"default,omitempty"`
+	// Bytes is the space the table takes, including its indexes
+	Bytes int64 `json:"bytes"`
+	// EstimatedRows is the database's estimate of the events held
+	EstimatedRows int64 `json:"estimatedRows"`
+}
+
+// EventPruneRun describes one run of content event pruning
+type EventPruneRun struct {
+	// StartedAt is when the run started
+	StartedAt time.Time `json:"startedAt"`
+	// Cutoff is the timestamp events older than were pruned
+	Cutoff time.Time `json:"cutoff"`
+	// Deleted counts the events deleted one batch at a time
+	Deleted int `json:"deleted"`
+	// DroppedPartitions names the partitions dropped whole
+	DroppedPartitions []string `json:"droppedPartitions,omitempty"`
+	// Archived is set when the deleted events were archived first
+	Archived bool `json:"archived"`
+	// Duration is how long the run took
+	Duration Milliseconds `json:"duration"`
+}
@@ -3,6 +3,8 @@ package v1alpha1
 
 import (
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // ContentSource represents a source of content for displays
@@ -26,16 +28,51 @@ type ContentSourceSpec struct {
 	Type string `json:"type"`
 	// Properties contains additional metadata about the content
 	Properties map[string]string `json:"properties,omitempty"`
+	// AllowedPaths lists the paths below URL that assignments may point at.
+	// An assignment may use an allowed path or anything beneath it; when the
+	// list is empty only URL itself can be assigned.
+	AllowedPaths []string `json:"allowedPaths,omitempty"`
+	// Tags group sources for organization and bulk operations (e.g.,
+	// "seasonal", "emergency"). They are kept sorted and free of duplicates.
+	Tags []string `json:"tags,omitempty"`
+	// Fallbacks names other content sources to show, in order of
+	// preference, while this one is unhealthy. A fallback's own fallbacks
+	// are tried after it; the chain may not lead back to a source already
+	// in it.
+	Fallbacks []string `json:"fallbacks,omitempty"`
 }
 
 // ContentSourceStatus defines the observed state of a ContentSource
 type ContentSourceStatus struct {
 	// LastValidated indicates when the content was last validated
 	LastValidated time.Time `json:"lastValidated"`
+	// IsHealthy indicates whether the most recent validation passed
+	IsHealthy bool `json:"isHealthy"`
 	// Hash is a content-based identifier for caching
 	Hash string `json:"hash"`
 	// Version tracks updates to this content source
 	Version int `json:"version"`
+	// Validation holds the report from the most recent validation, if any
+	Validation *ContentValidationReport `json:"validation,omitempty"`
+}
+
+// ContentValidationReport describes what the server observed when it last
+// fetched a content source's URL
+type ContentValidationReport struct {
+	// ValidatedAt is when the validation ran
+	ValidatedAt time.Time `json:"validatedAt"`
+	// Passed indicates whether the content was reachable and usable
+	Passed bool `json:"passed"`
+	// HTTPStatus is the status code returned by the content origin
+	HTTPStatus int `json:"httpStatus,omitempty"`
+	// LatencyMs is how long fetching the content took in milliseconds
+	LatencyMs int64 `json:"latencyMs"`
+	// ContentType is the Content-Type reported by the content origin
+	ContentType string `json:"contentType,omitempty"`
+	// Size is the size of the content in bytes
+	Size int64 `json:"size"`
+	// Reason explains why validation failed
+	Reason string `json:"reason,omitempty"`
 }
 
 // ContentSourceUpdate represents a partial update to a content source
@@ -44,6 +81,113 @@ type ContentSourceUpdate struct {
 	URL *string `json:"url,omitempty"`
 	// Properties updates the content metadata
 	Properties map[string]string `json:"properties,omitempty"`
+	// AllowedPaths replaces the allowed paths when set; an empty list
+	// clears them
+	AllowedPaths *[]string `json:"allowedPaths,omitempty"`
+	// Tags replaces the tags when set; an empty list clears them. AddTags and
+	// RemoveTags are applied afterwards.
+	Tags *[]string `json:"tags,omitempty"`
+	// AddTags adds tags, keeping the ones already present
+	AddTags []string `json:"addTags,omitempty"`
+	// RemoveTags removes tags, ignoring ones that are not present
+	RemoveTags []string `json:"removeTags,omitempty"`
+	// Fallbacks replaces the fallback sources when set; an empty list
+	// clears them
+	Fallbacks *[]string `json:"fallbacks,omitempty"`
+	// Notify tells the connected displays assigned content from this source
+	// to reload once the update is saved
+	Notify bool `json:"notify,omitempty"`
+	// Version, when set, makes the update conditional on the content source
+	// being at this version
+	Version int `json:"version,omitempty"`
+}
+
+// ContentSourceUpdateResult is the content source as updated, along with
+// the outcome of any display notification
+type ContentSourceUpdateResult struct {
+	ContentSource `json:",inline"`
+
+	// NotifiedDisplays is how many displays were told to reload. It is only
+	// set when the update asked for notification.
+	NotifiedDisplays *int `json:"notifiedDisplays,omitempty"`
+}
+
+// ResourceReference names a resource that refers to another, such as a
+// content assignment showing a content source
+type ResourceReference struct {
+	// Kind is the kind of the referring resource
+	Kind string `json:"kind"`
+	// Name is the referring resource's name
+	Name string `json:"name"`
+}
+
+// ContentSourceDeletion reports a removed content source, along with the
+// resources that referred to it and were removed by force
+type ContentSourceDeletion struct {
+	// TypeMeta describes the versioning of this object
+	TypeMeta `json:",inline"`
+
+	// Name is the name of the removed source
+	Name string `json:"name"`
+	// Removed lists the resources removed along with the source
+	Removed []ResourceReference `json:"removed"`
+}
+
+// ContentPreviewRequest asks what a display would be sent for a content
+// source. The display is the registered one Display names, or else a
+// hypothetical one at Location with Properties; with neither only the
+// URL is resolved.
+type ContentPreviewRequest struct {
+	// Path is a path beneath the source URL, held to the source's allowed
+	// paths; empty previews the source URL itself
+	Path string `json:"path,omitempty"`
+	// At is when schedules are evaluated; unset means now
+	At *time.Time `json:"at,omitempty"`
+	// Display names the registered display to preview as
+	Display string `json:"display,omitempty"`
+	// Location places a hypothetical display
+	Location *DisplayLocation `json:"location,omitempty"`
+	// Properties describe a hypothetical display
+	Properties map[string]string `json:"properties,omitempty"`
+	// Probe has the server fetch the resolved URL and report what it saw
+	Probe bool `json:"probe,omitempty"`
+}
+
+// ContentPreview shows what a display would be sent for a content source
+type ContentPreview struct {
+	// TypeMeta describes the versioning of this object
+	TypeMeta `json:",inline"`
+
+	// Source is the name of the content source
+	Source string `json:"source"`
+	// URL is the content URL once the path has passed the source's
+	// allowed paths
+	URL string `json:"url"`
+	// At is when schedules were evaluated
+	At time.Time `json:"at"`
+	// SourceHealthy reports whether the source passed its last
+	// validation; displays are sent its fallbacks while it has not
+	SourceHealthy bool `json:"sourceHealthy"`
+	// Display is the display previewed as, when there is one
+	Display *ContentPreviewDisplay `json:"display,omitempty"`
+	// Resolution describes each assignment considered for the display at
+	// At, with the schedule window of those in effect
+	Resolution []ResolutionStep `json:"resolution,omitempty"`
+	// Assigned reports whether the assignment selected for the display at
+	// At points at URL
+	Assigned bool `json:"assigned"`
+	// Probe is what fetching URL observed, when a probe was asked for
+	Probe *ContentValidationReport `json:"probe,omitempty"`
+}
+
+// ContentPreviewDisplay describes the display a preview is made as
+type ContentPreviewDisplay struct {
+	// Name is the registered display's name, empty for a hypothetical one
+	Name string `json:"name,omitempty"`
+	// Location is where the display is
+	Location DisplayLocation `json:"location"`
+	// Properties are the display's properties
+	Properties map[string]string `json:"properties,omitempty"`
 }
 
 // ContentSourceList is a list of content sources
@@ -54,3 +198,67 @@ type ContentSourceList struct {
 	// Items is the list of ContentSource objects
 	Items []ContentSource `json:"items"`
 }
+
+// ContentHealth reports how a content source's URL is doing on displays,
+// judged from the events they report
+type ContentHealth struct {
+	// URL is the content URL the report is about
+	URL string `json:"url"`
+	// Healthy is false when displays stopped loading the content or fail
+	// to load it too often
+	Healthy bool `json:"healthy"`
+	// Issues explains why the content is unhealthy
+	Issues []string `json:"issues,omitempty"`
+	// CheckedAt is when the report was produced
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// ContentMetrics aggregates the events displays reported for a content URL
+type ContentMetrics struct {
+	// URL is the content URL the metrics are about
+	URL string `json:"url"`
+	// LastSeen is when a display last reported the content
+	LastSeen time.Time `json:"lastSeen"`
+	// LoadCount is how many times displays loaded the content
+	LoadCount int64 `json:"loadCount"`
+	// ErrorCount is how many loads failed
+	ErrorCount int64 `json:"errorCount"`
+	// AvgLoadTime is the mean load time in whole milliseconds
+	AvgLoadTime int64 `json:"avgLoadTime"`
+	// AvgRenderTime is the mean render time in whole milliseconds
+	AvgRenderTime int64 `json:"avgRenderTime"`
+	// ErrorRates maps error codes to the share of loads failing with them
+	ErrorRates map[string]float64 `json:"errorRates,omitempty"`
+}
+
+// PrefetchStatus reports how far the displays assigned a content URL have
+// got downloading it ahead of an assignment going live
+type PrefetchStatus struct {
+	// URL is the content URL the status is about
+	URL string `json:"url"`
+	// Complete counts the targeted displays that have downloaded all of it
+	Complete int `json:"complete"`
+	// Targeted counts the displays selected by an assignment of the URL
+	// that has not expired
+	Targeted int `json:"targeted"`
+	// Displays lists the progress of each targeted display, including
+	// those that have not reported any
+	Displays []DisplayPrefetch `json:"displays"`
+}
+
+// DisplayPrefetch is one display's latest reported prefetch progress
+type DisplayPrefetch struct {
+	// DisplayID identifies the display
+	DisplayID uuid.UUID `json:"displayId"`
+	// BytesDownloaded is how much of the content the display has fetched
+	BytesDownloaded int64 `json:"bytesDownloaded"`
+	// BytesTotal is the size of the content, or zero while unknown
+	BytesTotal int64 `json:"bytesTotal"`
+	// Percent is how much of the content has been fetched, from 0 to 100
+	Percent float64 `json:"percent"`
+	// Complete is set once the whole content has been fetched
+	Complete bool `json:"complete"`
+	// ReportedAt is when the display reported the progress; unset for
+	// displays that have not reported any
+	ReportedAt *time.Time `json:"reportedAt,omitempty"`
+}
@@ -1,6 +1,9 @@
 package v1alpha1
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // ControlMessageType defines types of control messages
 type ControlMessageType string
@@ -12,9 +15,71 @@ const (
 	ControlMessageReload ControlMessageType = "RELOAD"
 	// ControlMessageStatus indicates display status report
 	ControlMessageStatus ControlMessageType = "STATUS"
+	// ControlMessageSettings carries device settings for the display to
+	// apply
+	ControlMessageSettings ControlMessageType = "SETTINGS"
+	// ControlMessageRateLimited tells a display that messages to or from
+	// it hit its rate limit
+	ControlMessageRateLimited ControlMessageType = "RATE_LIMITED"
+	// ControlMessageError tells a display that a message it sent was
+	// rejected and why
+	ControlMessageError ControlMessageType = "ERROR"
+	// ControlMessageReconnect tells a display that the server is about to
+	// close its connection and when to connect again
+	ControlMessageReconnect ControlMessageType = "RECONNECT"
 )
 
-// ControlMessage represents a message sent over display control WebSocket
+// ControlAPIVersion is the control protocol version spoken by this package
+const ControlAPIVersion = "v1alpha1"
+
+// KnownControlMessageTypes lists every message type defined by this version
+// of the protocol
+func KnownControlMessageTypes() []ControlMessageType {
+	return []ControlMessageType{
+		ControlMessageSequenceUpdate,
+		ControlMessageReload,
+		ControlMessageStatus,
+		ControlMessageSettings,
+		ControlMessageRateLimited,
+		ControlMessageError,
+		ControlMessageReconnect,
+	}
+}
+
+// BaselineCapabilities are the message types every display understands. A
+// display that reports no capabilities is assumed to support exactly these,
+// so message types added later are never sent to firmware that predates
+// them.
+func BaselineCapabilities() []ControlMessageType {
+	return []ControlMessageType{
+		ControlMessageSequenceUpdate,
+		ControlMessageReload,
+		ControlMessageStatus,
+	}
+}
+
+// Known reports whether t is defined by this version of the protocol
+func (t ControlMessageType) Known() bool {
+	for _, k := range KnownControlMessageTypes() {
+		if t == k {
+			return true
+		}
+	}
+	return false
+}
+
+// ControlMessage represents a message sent over display control WebSocket.
+//
+// The protocol grows without breaking older peers by these rules:
+//   - Receivers ignore fields they do not know.
+//   - Receivers ignore, and count, messages whose type they do not know or
+//     whose APIVersion is neither empty nor ControlAPIVersion. An empty
+//     APIVersion is read as ControlAPIVersion for peers that never set it.
+//   - New fields are optional, so a message stays valid for a receiver that
+//     drops them.
+//   - The server only sends a display message types listed in the
+//     capabilities of its status reports, or BaselineCapabilities if it
+//     lists none.
 type ControlMessage struct {
 	// TypeMeta describes API version details
 	TypeMeta `json:",inline"`
@@ -28,6 +93,27 @@ type ControlMessage struct {
 	Error *ControlError `json:"error,omitempty"`
 	// Status contains display status if applicable
 	Status *ControlStatus `json:"status,omitempty"`
+	// Settings contains the complete device settings for a SETTINGS
+	// message; settings it leaves out keep the device's own value. It is
+	// omitted from a SETTINGS message that only carries polling hints.
+	Settings *DisplaySettings `json:"settings,omitempty"`
+	// Polling carries the display's polling hints in a SETTINGS message
+	Polling *PollingHints `json:"polling,omitempty"`
+	// RateLimit describes the limit hit for a RATE_LIMITED message
+	RateLimit *ControlRateLimit `json:"rateLimit,omitempty"`
+	// Reconnect tells a display when to connect again for a RECONNECT
+	// message
+	Reconnect *ControlReconnect `json:"reconnect,omitempty"`
+}
+
+// Understood reports whether a receiver built against this package can act
+// on m: its version matches and its type is known. Other messages should be
+// ignored rather than treated as errors.
+func (m *ControlMessage) Understood() bool {
+	if m.APIVersion != "" && m.APIVersion != ControlAPIVersion {
+		return false
+	}
+	return m.Type.Known()
 }
 
 // ContentSequence defines ordered content items to display
@@ -36,6 +122,32 @@ type ContentSequence struct {
 	Items []ContentItem `json:"items"`
 }
 
+// DefaultContentWeight is the weight of a sequence item that sets none
+const DefaultContentWeight = 1
+
+// Validate checks that every item's weight is positive or unset
+func (s *ContentSequence) Validate() error {
+	for i, item := range s.Items {
+		if item.Weight < 0 {
+			return fmt.Errorf("items[%d]: weight %d must be positive", i, item.Weight)
+		}
+	}
+	return nil
+}
+
+// WithDefaults returns a copy of the sequence with every unset weight
+// filled in, so that displays need not know the default
+func (s *ContentSequence) WithDefaults() *ContentSequence {
+	c := &ContentSequence{Items: make([]ContentItem, len(s.Items))}
+	for i, item := range s.Items {
+		if item.Weight == 0 {
+			item.Weight = DefaultContentWeight
+		}
+		c.Items[i] = item
+	}
+	return c
+}
+
 // ContentItem represents a single item in display sequence
 type ContentItem struct {
 	// URL points to cacheable content location
@@ -44,6 +156,11 @@ type ContentItem struct {
 	Duration ContentDuration `json:"duration"`
 	// Transition defines how to switch to next content
 	Transition ContentTransition `json:"transition"`
+	// Weight is how often the item is shown relative to the others in the
+	// sequence: an item of weight 2 comes up twice as often as one of
+	// weight 1. Zero means DefaultContentWeight. Displays that predate
+	// weights ignore it and show every item in turn.
+	Weight int `json:"weight,omitempty"`
 }
 
 // ContentDuration specifies content display timing
@@ -62,12 +179,54 @@ type ContentTransition struct {
 	Duration int `json:"duration"`
 }
 
+// ControlErrorMessageTooLarge is the code of an ERROR sent for a message
+// over the server's size limit. The message was dropped; Limit is the
+// largest size in bytes the server accepts.
+const ControlErrorMessageTooLarge = "MESSAGE_TOO_LARGE"
+
 // ControlError represents control message errors
 type ControlError struct {
 	// Code provides error classification
 	Code string `json:"code"`
 	// Message provides error details
 	Message string `json:"message"`
+	// Limit is the limit a rejected message exceeded, when there is one
+	Limit int `json:"limit,omitempty"`
+}
+
+// RateLimitDirection tells which way messages hit a display's rate limit
+type RateLimitDirection string
+
+const (
+	// RateLimitOutbound means messages to the display are held back and
+	// will be delivered once the limit allows, with consecutive sequence
+	// updates merged into the latest
+	RateLimitOutbound RateLimitDirection = "outbound"
+	// RateLimitInbound means messages from the display were dropped; it
+	// should send its status again after RetryAfterSeconds
+	RateLimitInbound RateLimitDirection = "inbound"
+)
+
+// ControlRateLimit describes a rate limit a display's messages hit
+type ControlRateLimit struct {
+	// Direction tells whether messages to or from the display were limited
+	Direction RateLimitDirection `json:"direction"`
+	// RetryAfterSeconds is how long until the limit allows messages again
+	RetryAfterSeconds int `json:"retryAfterSeconds"`
+}
+
+// ControlReconnect asks a display to wait before connecting again once the
+// server closes its connection. Waiting a random part of Spread keeps a
+// fleet from reconnecting at the same moment, all to whichever server comes
+// up first.
+type ControlReconnect struct {
+	// Delay is the least time to wait before reconnecting
+	Delay Milliseconds `json:"delayMs"`
+	// Spread is the window after Delay over which to pick the reconnect
+	// time at random
+	Spread Milliseconds `json:"spreadMs"`
+	// Reason tells why the connection is being closed
+	Reason string `json:"reason,omitempty"`
 }
 
 // ControlStatus represents current display state for control messages
@@ -80,4 +239,8 @@ type ControlStatus struct {
 	LastError *string `json:"lastError,omitempty"`
 	// UpdatedAt indicates when status was generated
 	UpdatedAt time.Time `json:"updatedAt"`
+	// Capabilities lists the message types the display can handle. It is
+	// sent with the first status report of a connection; later reports may
+	// omit it.
+	Capabilities []ControlMessageType `json:"capabilities,omitempty"`
 }
@@ -0,0 +1,146 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// legacyControlMessage is the message as the first display firmware knew
+// it, before versioning and capabilities
+type legacyControlMessage struct {
+	Type     string `json:"type"`
+	Sequence *struct {
+		Items []struct {
+			URL string `json:"url"`
+		} `json:"items"`
+	} `json:"sequence,omitempty"`
+	Status *struct {
+		CurrentURL string `json:"currentUrl"`
+	} `json:"status,omitempty"`
+}
+
+// sampleControlMessages returns a fully populated message of every known type
+func sampleControlMessages() []ControlMessage {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	lastError := "fetch failed"
+	brightness := 80
+	var msgs []ControlMessage
+	for _, typ := range KnownControlMessageTypes() {
+		msgs = append(msgs, ControlMessage{
+			TypeMeta:  TypeMeta{Kind: "ControlMessage", APIVersion: ControlAPIVersion},
+			Type:      typ,
+			Timestamp: now,
+			Sequence: &ContentSequence{Items: []ContentItem{{
+				URL:        "https://example.com/welcome",
+				Duration:   ContentDuration{Type: "fixed", Value: 10},
+				Transition: ContentTransition{Type: "fade", Duration: 500},
+			}}},
+			Error: &ControlError{Code: "E1", Message: "boom", Limit: 512},
+			Status: &ControlStatus{
+				CurrentURL:   "https://example.com/welcome",
+				State:        DisplayStateActive,
+				LastError:    &lastError,
+				UpdatedAt:    now,
+				Capabilities: BaselineCapabilities(),
+			},
+			Settings: &DisplaySettings{
+				Orientation:    "portrait",
+				Brightness:     &brightness,
+				ScreenSchedule: []ScreenWindow{{Start: "07:00", End: "19:00"}},
+			},
+			Polling: &PollingHints{HeartbeatInterval: 60, ContentPollInterval: 300},
+		})
+	}
+	return msgs
+}
+
+func TestControlMessageRoundTrip(t *testing.T) {
+	for _, msg := range sampleControlMessages() {
+		t.Run(string(msg.Type), func(t *testing.T) {
+			data, err := json.Marshal(msg)
+			require.NoError(t, err)
+
+			var decoded ControlMessage
+			require.NoError(t, json.Unmarshal(data, &decoded))
+			assert.Equal(t, msg, decoded)
+			assert.True(t, decoded.Understood())
+
+			var legacy legacyControlMessage
+			require.NoError(t, json.Unmarshal(data, &legacy), "older receivers must still decode")
+			assert.Equal(t, string(msg.Type), legacy.Type)
+			assert.Equal(t, msg.Sequence.Items[0].URL, legacy.Sequence.Items[0].URL)
+			assert.Equal(t, msg.Status.CurrentURL, legacy.Status.CurrentURL)
+		})
+	}
+}
+
+func TestControlMessageFromNewerPeer(t *testing.T) {
+	// A message from a later protocol revision with a type and fields this
+	// package does not know
+	data := []byte(`{"apiVersion":"v1alpha1","type":"EVENT_BATCH","timestamp":"2024-05-01T12:00:00Z","events":[{"id":1}]}`)
+
+	var msg ControlMessage
+	require.NoError(t, json.Unmarshal(data, &msg))
+	assert.False(t, msg.Understood())
+}
+
+func TestControlMessageUnderstood(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiVersion string
+		typ        ControlMessageType
+		want       bool
+	}{
+		{"current version", ControlAPIVersion, ControlMessageStatus, true},
+		{"unversioned legacy peer", "", ControlMessageReload, true},
+		{"other version", "v1beta1", ControlMessageStatus, false},
+		{"unknown type", ControlAPIVersion, "SHUTDOWN", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := ControlMessage{TypeMeta: TypeMeta{APIVersion: tt.apiVersion}, Type: tt.typ}
+			assert.Equal(t, tt.want, msg.Understood())
+		})
+	}
+}
+
+func TestPollingHintsClamp(t *testing.T) {
+	tests := []struct {
+		name string
+		in   PollingHints
+		want PollingHints
+	}{
+		{"within bounds", PollingHints{60, 300}, PollingHints{60, 300}},
+		{"unset stays unset", PollingHints{}, PollingHints{}},
+		{"too fast", PollingHints{1, -5}, PollingHints{MinHeartbeatInterval, MinContentPollInterval}},
+		{"too slow", PollingHints{1 << 30, 365 * 24 * 60 * 60}, PollingHints{MaxHeartbeatInterval, MaxContentPollInterval}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.in.Clamp())
+		})
+	}
+}
+
+func TestContentSequenceWeights(t *testing.T) {
+	seq := &ContentSequence{Items: []ContentItem{
+		{URL: "https://example.com/promo", Weight: 2},
+		{URL: "https://example.com/filler"},
+	}}
+	require.NoError(t, seq.Validate())
+
+	data, err := json.Marshal(seq.WithDefaults())
+	require.NoError(t, err)
+	var decoded ContentSequence
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, 2, decoded.Items[0].Weight, "set weights are kept")
+	assert.Equal(t, DefaultContentWeight, decoded.Items[1].Weight, "missing weights get the default")
+	assert.Zero(t, seq.Items[1].Weight, "the original sequence is left alone")
+
+	seq.Items[1].Weight = -1
+	assert.Error(t, seq.Validate())
+}
@@ -0,0 +1,167 @@
+package v1alpha1
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Outcomes of an assignment considered while resolving a display's content
+const (
+	// ResolutionSelected marks the assignment that decides the content
+	ResolutionSelected = "selected"
+	// ResolutionSkipped marks an assignment that does not apply to the
+	// display now
+	ResolutionSkipped = "skipped"
+	// ResolutionOutranked marks an assignment that applies but loses to
+	// the selected one
+	ResolutionOutranked = "outranked"
+	// ResolutionOverridden marks content served in place of the selected
+	// assignment, such as a maintenance notice or a fallback source
+	ResolutionOverridden = "overridden"
+)
+
+// ResolutionStep describes one assignment considered while resolving a
+// display's content and what became of it
+type ResolutionStep struct {
+	// Assignment is the name of the assignment
+	Assignment string `json:"assignment"`
+	// ContentURL is the content the assignment points at
+	ContentURL string `json:"contentUrl"`
+	// Outcome is selected, skipped, outranked or overridden
+	Outcome string `json:"outcome"`
+	// Reason explains the outcome
+	Reason string `json:"reason,omitempty"`
+	// Window is the window of the assignment's schedule the resolution
+	// time falls in, for scheduled assignments in effect then
+	Window *ScheduleWindow `json:"window,omitempty"`
+	// Timezone is the zone Window is read in
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// DisplayDebugSnapshot gathers everything known about a display into one
+// document for support tickets. Each section is read independently; a
+// section that could not be read within the snapshot's time limit carries
+// an error note and the others are still filled in. Credentials are never
+// included: sessions are described by their metadata only.
+type DisplayDebugSnapshot struct {
+	// TypeMeta describes the versioning of this object
+	TypeMeta `json:",inline"`
+	// DisplayID identifies the display
+	DisplayID uuid.UUID `json:"displayId"`
+	// GeneratedAt is when the snapshot was taken
+	GeneratedAt time.Time `json:"generatedAt"`
+	// Display is the display's record, including its properties
+	Display *Display `json:"display"`
+	// Connection describes the display's control connections to the
+	// server that took the snapshot
+	Connection DebugConnection `json:"connection"`
+	// Events are the display's recent lifecycle events, newest first
+	Events DebugEvents `json:"events"`
+	// ContentHistory is the display's recent content transitions, newest
+	// first
+	ContentHistory DebugContentHistory `json:"contentHistory"`
+	// Content is the content the display resolves to now and how it was
+	// chosen
+	Content DebugContent `json:"content"`
+	// PendingMessages are the control messages queued for the display
+	PendingMessages DebugPendingMessages `json:"pendingMessages"`
+	// Sessions describe the display's token sessions
+	Sessions DebugSessions `json:"sessions"`
+	// RateLimits are the current state of the display's rate limit buckets
+	RateLimits DebugRateLimits `json:"rateLimits"`
+}
+
+// DebugConnection is the connection section of a debug snapshot
+type DebugConnection struct {
+	// Connected reports whether the display holds a connection
+	Connected bool `json:"connected"`
+	// Since is when the oldest open connection was established
+	Since *time.Time `json:"since,omitempty"`
+	// Items are the open connections, oldest first
+	Items []ConnectionInfo `json:"items"`
+	// Error describes why the section could not be read
+	Error string `json:"error,omitempty"`
+}
+
+// DebugEvent is a lifecycle event in a debug snapshot
+type DebugEvent struct {
+	// Type is what happened, such as ACTIVATED or OFFLINE
+	Type string `json:"type"`
+	// Timestamp is when it happened
+	Timestamp time.Time `json:"timestamp"`
+	// Data holds the event's details
+	Data map[string]string `json:"data,omitempty"`
+}
+
+// DebugEvents is the lifecycle event section of a debug snapshot
+type DebugEvents struct {
+	// Items are the events, newest first
+	Items []DebugEvent `json:"items"`
+	// Note qualifies the items, such as events being kept per server
+	Note string `json:"note,omitempty"`
+	// Error describes why the section could not be read
+	Error string `json:"error,omitempty"`
+}
+
+// DebugContentHistory is the content history section of a debug snapshot
+type DebugContentHistory struct {
+	// Items are the transitions, newest first
+	Items []ContentHistoryEntry `json:"items"`
+	// Error describes why the section could not be read
+	Error string `json:"error,omitempty"`
+}
+
+// DebugContent is the resolved content section of a debug snapshot
+type DebugContent struct {
+	// Assignment is what the display is served now, nil when nothing is
+	// assigned to it
+	Assignment *ContentAssignment `json:"assignment,omitempty"`
+	// Trace lists the assignments considered and what became of each
+	Trace []ResolutionStep `json:"trace"`
+	// Error describes why the section could not be read
+	Error string `json:"error,omitempty"`
+}
+
+// DebugPendingMessages is the outbox section of a debug snapshot
+type DebugPendingMessages struct {
+	// Items are the queued messages in queue order
+	Items []PendingMessage `json:"items"`
+	// Error describes why the section could not be read
+	Error string `json:"error,omitempty"`
+}
+
+// DebugSessions is the token session section of a debug snapshot
+type DebugSessions struct {
+	// Items are the sessions, oldest first
+	Items []DisplaySession `json:"items"`
+	// Error describes why the section could not be read
+	Error string `json:"error,omitempty"`
+}
+
+// DebugRateLimit is the state of one of a display's rate limit buckets
+type DebugRateLimit struct {
+	// Type is the limit type, such as ws_message_in
+	Type string `json:"type"`
+	// Key is what the bucket counts, the display ID or a remote address
+	Key string `json:"key"`
+	// Rate is how many requests are allowed per period
+	Rate int `json:"rate"`
+	// PeriodSeconds is the window over which Rate applies
+	PeriodSeconds int `json:"periodSeconds"`
+	// Burst is how many requests are allowed at once
+	Burst int `json:"burst"`
+	// Remaining is how many requests are allowed right now
+	Remaining int `json:"remaining"`
+	// RetryAfterSeconds is how long until the next request is allowed,
+	// rounded up, and zero when one is allowed now
+	RetryAfterSeconds int `json:"retryAfterSeconds"`
+}
+
+// DebugRateLimits is the rate limit section of a debug snapshot
+type DebugRateLimits struct {
+	// Items are the display's buckets
+	Items []DebugRateLimit `json:"items"`
+	// Error describes why the section could not be read
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,55 @@
+package v1alpha1
+
+// Optional API features a server may advertise in its discovery document
+const (
+	// FeaturePagination means list endpoints accept continuation tokens
+	FeaturePagination = "pagination"
+	// FeatureWatch means resources can be watched for changes
+	FeatureWatch = "watch"
+	// FeatureBulkUpdate means several displays can be updated in one request
+	FeatureBulkUpdate = "bulkUpdate"
+	// FeatureContentFilters means content sources can be listed by type and
+	// fetched by name in one request
+	FeatureContentFilters = "contentFilters"
+	// FeatureDisplayPatch means displays accept partial updates with
+	// optimistic locking
+	FeatureDisplayPatch = "displayPatch"
+	// FeatureDisplaySelf means displays can read their own record and
+	// assigned content from /displays/me with their token
+	FeatureDisplaySelf = "displaySelf"
+	// FeatureDisplaySelfHead means /displays/me answers HEAD with the ETag
+	// of its GET response, so a display can poll for changes without
+	// downloading its record
+	FeatureDisplaySelfHead = "displaySelfHead"
+)
+
+// APIDiscovery describes what a server supports so that clients built for a
+// different version can adapt
+type APIDiscovery struct {
+	// TypeMeta describes the versioning of this object
+	TypeMeta `json:",inline"`
+	// ServerVersion is the version of the server binary
+	ServerVersion string `json:"serverVersion"`
+	// Resources lists the resource kinds served and where to find them
+	Resources []APIResource `json:"resources"`
+	// Features lists the optional features the server implements
+	Features []string `json:"features"`
+}
+
+// APIResource describes one resource kind served by the API
+type APIResource struct {
+	// Kind is the resource kind, e.g. "Display"
+	Kind string `json:"kind"`
+	// Path is the collection endpoint for the kind
+	Path string `json:"path"`
+}
+
+// HasFeature reports whether the server advertises feature
+func (d *APIDiscovery) HasFeature(feature string) bool {
+	for _, f := range d.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,8 @@
 package v1alpha1
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,6 +15,9 @@ type DisplayState string
 const (
 	// DisplayStateUnregistered indicates a display that hasn't completed registration
 	DisplayStateUnregistered DisplayState = "UNREGISTERED"
+	// DisplayStatePendingApproval indicates an activated display waiting for
+	// an operator's approval
+	DisplayStatePendingApproval DisplayState = "PENDING_APPROVAL"
 	// DisplayStateActive indicates a properly registered and active display
 	DisplayStateActive DisplayState = "ACTIVE"
 	// DisplayStateOffline indicates a display that hasn't communicated recently
@@ -50,16 +55,130 @@ type DisplaySpec struct {
 	Location DisplayLocation `json:"location"`
 	// Properties contains arbitrary key-value pairs for display metadata
 	Properties map[string]string `json:"properties,omitempty"`
+	// Settings are the device settings pushed to the display, absent if
+	// none have been set
+	Settings *DisplaySettings `json:"settings,omitempty"`
+}
+
+// DisplaySettings are device settings a display applies itself. Fields that
+// are absent leave the device's own setting alone.
+type DisplaySettings struct {
+	// Orientation is landscape, portrait, landscape-flipped or
+	// portrait-flipped
+	Orientation string `json:"orientation,omitempty"`
+	// Brightness is the backlight level, 0 to 100
+	Brightness *int `json:"brightness,omitempty"`
+	// Volume is the audio level, 0 to 100
+	Volume *int `json:"volume,omitempty"`
+	// ScreenSchedule lists the times of day the screen is on, in the
+	// display's local time. An empty schedule keeps the screen on.
+	ScreenSchedule []ScreenWindow `json:"screenSchedule,omitempty"`
+}
+
+// ScreenWindow is a daily period during which a display's screen is on. A
+// window whose end is earlier than its start runs past midnight.
+type ScreenWindow struct {
+	// Start is when the screen turns on, as HH:MM
+	Start string `json:"start"`
+	// End is when the screen turns off, as HH:MM
+	End string `json:"end"`
 }
 
 // DisplayStatus defines the observed state of a Display
 type DisplayStatus struct {
 	// State indicates the display's current operational state
 	State DisplayState `json:"state"`
-	// LastSeen is when the display last contacted the server
+	// LastSeen is when the display last contacted the server, or the zero
+	// time if it never has
 	LastSeen time.Time `json:"lastSeen"`
 	// Version tracks optimistic concurrency control
 	Version int `json:"version"`
+	// LastError is the content failure the display most recently reported,
+	// absent once it has loaded content successfully since
+	LastError *DisplayContentError `json:"lastError,omitempty"`
+	// Timezone is the IANA time zone the display's timestamps are given
+	// in, set only when the request asked for one with ?tz=
+	Timezone string `json:"timezone,omitempty"`
+	// TimezoneSource tells where Timezone came from: request, display,
+	// site, or default when neither the display nor its site names a zone
+	TimezoneSource string `json:"timezoneSource,omitempty"`
+	// Runtime is the display's live state, set only when a list asked for
+	// it with ?include=runtime
+	Runtime *DisplayRuntime `json:"runtime,omitempty"`
+}
+
+// DisplayRuntime is what the server knows about a display at the moment
+// beyond its stored record
+type DisplayRuntime struct {
+	// Connected is set while the display holds a control connection to the
+	// server that answered
+	Connected bool `json:"connected"`
+	// PendingMessages counts the control messages queued until the display
+	// connects
+	PendingMessages int `json:"pendingMessages"`
+	// LastContentURL is the content the display last switched to, empty
+	// when it has no history
+	LastContentURL string `json:"lastContentUrl,omitempty"`
+	// LastContentAt is when it switched to LastContentURL
+	LastContentAt *time.Time `json:"lastContentAt,omitempty"`
+}
+
+// DisplayIncludeRuntime asks a display list for each display's runtime
+// state, as the value of the include query parameter
+const DisplayIncludeRuntime = "runtime"
+
+// DisplaySelf is what a display reads about itself when it starts: its own
+// record and the content it should show
+type DisplaySelf struct {
+	Display `json:",inline"`
+
+	// Content is the content assigned to the display, absent when no
+	// current assignment selects it
+	Content *AssignedContent `json:"content,omitempty"`
+	// Polling are the intervals the display should check in and look for
+	// new content at
+	Polling *PollingHints `json:"polling,omitempty"`
+	// Maintenance is set while the server is in maintenance mode, when
+	// Content is the maintenance notice rather than the assigned content
+	Maintenance *MaintenanceNotice `json:"maintenance,omitempty"`
+}
+
+// AssignedContent is the content an assignment points a display at
+type AssignedContent struct {
+	// URL is where the display fetches the content
+	URL string `json:"url"`
+	// Assignment names the assignment that selected the display
+	Assignment string `json:"assignment"`
+	// Source names the content source the assignment was created from, if
+	// any
+	Source string `json:"source,omitempty"`
+	// ValidUntil is when the assignment expires, if it does
+	ValidUntil *time.Time `json:"validUntil,omitempty"`
+}
+
+// DisplayResync reports the content pushed to a display to bring it back in
+// step with its assignment
+type DisplayResync struct {
+	TypeMeta `json:",inline"`
+
+	// DisplayID identifies the display
+	DisplayID uuid.UUID `json:"displayId"`
+	// Content is the content the display was sent
+	Content AssignedContent `json:"content"`
+	// SentAt is when the content was sent
+	SentAt time.Time `json:"sentAt"`
+}
+
+// DisplayContentError describes a content failure reported by a display
+type DisplayContentError struct {
+	// Code classifies the failure
+	Code string `json:"code"`
+	// Message describes the failure
+	Message string `json:"message"`
+	// URL is the content that failed
+	URL string `json:"url"`
+	// Timestamp is when the display reported the failure
+	Timestamp time.Time `json:"timestamp"`
 }
 
 // TypeMeta describes an individual object's type and API version
@@ -90,6 +209,8 @@ type DisplayRegistrationRequest struct {
 	Location DisplayLocation `json:"location"`
 	// ActivationCode is the code shown on the display
 	ActivationCode string `json:"activationCode"`
+	// Properties are stored with the display when it is registered
+	Properties map[string]string `json:"properties,omitempty"`
 }
 
 // DisplayRegistrationResponse contains the result of a registration request
@@ -98,6 +219,376 @@ type DisplayRegistrationResponse struct {
 	Display *Display `json:"display"`
 }
 
+// DeviceCodeRequest is sent by a display starting device activation. The
+// body is optional.
+type DeviceCodeRequest struct {
+	// SiteID, when set, is the only site the display may be activated
+	// into. Displays provisioned for one site send it so that operators of
+	// other sites cannot claim them.
+	SiteID string `json:"siteId,omitempty"`
+	// LocationHint is where a pre-staged display expects to be installed.
+	// It only pre-fills activation; any field may be left empty.
+	LocationHint *DisplayLocation `json:"locationHint,omitempty"`
+	// NameHint is the name a pre-staged display expects to be given
+	NameHint string `json:"nameHint,omitempty"`
+}
+
+// DeviceCodeResponse is returned to a display starting device activation
+type DeviceCodeResponse struct {
+	// DeviceCode is the secret the display polls with
+	DeviceCode string `json:"deviceCode"`
+	// UserCode is the short code the display shows on screen
+	UserCode string `json:"userCode"`
+	// VerificationURI is the page where an operator enters the user code
+	VerificationURI string `json:"verificationURI"`
+	// VerificationURIComplete is VerificationURI with the user code filled in
+	VerificationURIComplete string `json:"verificationURIComplete,omitempty"`
+	// ExpiresIn is the number of seconds until the codes expire
+	ExpiresIn int `json:"expiresIn"`
+	// Interval is the minimum number of seconds between polls
+	Interval int `json:"interval"`
+	// SiteID is the site the display may be activated into, if it is
+	// bound to one
+	SiteID string `json:"siteId,omitempty"`
+}
+
+// ActivationCode describes a user code that can still be activated, for
+// operators about to activate the display showing it
+type ActivationCode struct {
+	// UserCode is the code shown on the display
+	UserCode string `json:"userCode"`
+	// ExpiresAt is when the code stops being accepted
+	ExpiresAt time.Time `json:"expiresAt"`
+	// SiteID is the site the display may be activated into, if it is
+	// bound to one
+	SiteID string `json:"siteId,omitempty"`
+	// LocationHint is where the display expects to be installed, if it
+	// said. Activation still needs the location to be given.
+	LocationHint *DisplayLocation `json:"locationHint,omitempty"`
+	// NameHint is the name the display expects to be given, if it said
+	NameHint string `json:"nameHint,omitempty"`
+}
+
+// DeviceTokenRequest is sent by a display polling for activation
+type DeviceTokenRequest struct {
+	// DeviceCode is the secret issued with the user code
+	DeviceCode string `json:"deviceCode"`
+}
+
+// DeviceTokenResponse is returned once a display has been activated
+type DeviceTokenResponse struct {
+	// Display is the activated display
+	Display *Display `json:"display"`
+	// AccessToken authenticates the display to the server
+	AccessToken string `json:"accessToken,omitempty"`
+	// TokenType is the kind of access token, always "Bearer"
+	TokenType string `json:"tokenType,omitempty"`
+	// ExpiresIn is the number of seconds until the access token expires
+	ExpiresIn int `json:"expiresIn,omitempty"`
+}
+
+// DisplayProvisionRequest asks the server to register a display ahead of
+// installation and mint the provisioning file it is set up from offline
+type DisplayProvisionRequest struct {
+	// Name is the name of the display
+	Name string `json:"name"`
+	// Location specifies where the display will be installed
+	Location DisplayLocation `json:"location"`
+	// Properties are stored with the display when it is registered
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+// ProvisioningBundle is what a provisioned display needs to join the
+// server: its identity, initial configuration and a single use enrollment
+// token
+type ProvisioningBundle struct {
+	// DisplayID is the ID of the registered display
+	DisplayID uuid.UUID `json:"displayId"`
+	// Name is the name of the display
+	Name string `json:"name"`
+	// Location is where the display is installed
+	Location DisplayLocation `json:"location"`
+	// Properties are the display's initial properties
+	Properties map[string]string `json:"properties,omitempty"`
+	// ServerURL is the base URL of the server the display enrolls with
+	ServerURL string `json:"serverURL"`
+	// EnrollmentToken is exchanged once for an access token at the
+	// enrollment endpoint
+	EnrollmentToken string `json:"enrollmentToken"`
+	// IssuedAt is when the bundle was minted
+	IssuedAt time.Time `json:"issuedAt"`
+	// ExpiresAt is when the enrollment token stops being accepted
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// ProvisioningFile is a provisioning bundle with a detached signature made
+// with the server's signing key, as written to a .wsp file. Bundle holds
+// the exact bytes signed, so firmware verifies the signature before
+// decoding them.
+type ProvisioningFile struct {
+	TypeMeta `json:",inline"`
+	// Bundle is the JSON encoded ProvisioningBundle
+	Bundle []byte `json:"bundle"`
+	// Signature is the signature over Bundle
+	Signature ProvisioningSignature `json:"signature"`
+}
+
+// DecodeBundle decodes the file's bundle. It does not check the signature.
+func (f *ProvisioningFile) DecodeBundle() (*ProvisioningBundle, error) {
+	var bundle ProvisioningBundle
+	if err := json.Unmarshal(f.Bundle, &bundle); err != nil {
+		return nil, fmt.Errorf("invalid provisioning bundle: %w", err)
+	}
+	return &bundle, nil
+}
+
+// ProvisioningSignature is the detached signature of a provisioning file
+type ProvisioningSignature struct {
+	// KeyID identifies the server key that made the signature
+	KeyID string `json:"keyId"`
+	// Algorithm is the signing algorithm, "EdDSA" or "HS256"
+	Algorithm string `json:"algorithm"`
+	// Value is the raw signature
+	Value []byte `json:"value"`
+}
+
+// EnrollmentRequest is sent by a provisioned display exchanging its
+// enrollment token for an access token. The response is a
+// DeviceTokenResponse.
+type EnrollmentRequest struct {
+	// EnrollmentToken is the token from the display's provisioning file
+	EnrollmentToken string `json:"enrollmentToken"`
+}
+
+// OAuthError is the error body used by the device activation endpoints,
+// following the OAuth 2.0 device authorization grant (RFC 8628)
+type OAuthError struct {
+	// Error is the error code, such as "authorization_pending"
+	Error string `json:"error"`
+	// ErrorDescription is a human-readable description of the error
+	ErrorDescription string `json:"error_description,omitempty"`
+	// Errors lists the invalid fields of an invalid_request
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// ContentHistoryEntry records a display switching content
+type ContentHistoryEntry struct {
+	// Timestamp is when the change happened
+	Timestamp time.Time `json:"timestamp"`
+	// FromURL is the content shown before the change
+	FromURL string `json:"fromUrl,omitempty"`
+	// ToURL is the content shown after the change
+	ToURL string `json:"toUrl"`
+	// Trigger is what caused the change: sequence, reload, assignment-change,
+	// resync or maintenance
+	Trigger string `json:"trigger"`
+}
+
+// ContentHistory lists a display's most recent content transitions,
+// newest first
+type ContentHistory struct {
+	// TypeMeta describes the versioning of this object
+	TypeMeta `json:",inline"`
+	// DisplayID identifies the display
+	DisplayID uuid.UUID `json:"displayId"`
+	// Items are the transitions, newest first
+	Items []ContentHistoryEntry `json:"items"`
+}
+
+// DisplayStateSnapshot counts a site's displays by state at one point in time
+type DisplayStateSnapshot struct {
+	// Timestamp is when the counts were taken
+	Timestamp time.Time `json:"timestamp"`
+	// SiteID identifies the site
+	SiteID string `json:"siteId"`
+	// Counts maps each state that had displays to how many it had
+	Counts map[DisplayState]int `json:"counts"`
+}
+
+// DisplayStateCounts counts displays by state at each site as they are now
+type DisplayStateCounts struct {
+	// TypeMeta describes the versioning of this object
+	TypeMeta `json:",inline"`
+	// Items holds one entry per site with matching displays, ordered by site
+	Items []DisplayStateSnapshot `json:"items"`
+}
+
+// DisplayStateHistory lists periodic display state snapshots, oldest first
+type DisplayStateHistory struct {
+	// TypeMeta describes the versioning of this object
+	TypeMeta `json:",inline"`
+	// SiteID is the site the history covers, empty for every site
+	SiteID string `json:"siteId,omitempty"`
+	// Since is the start of the period covered
+	Since time.Time `json:"since"`
+	// Items are the snapshots, oldest first
+	Items []DisplayStateSnapshot `json:"items"`
+}
+
+// LocationProposalState is where a location proposal is in its lifecycle
+type LocationProposalState string
+
+const (
+	// LocationProposalPending indicates a proposal waiting for an operator
+	LocationProposalPending LocationProposalState = "pending"
+	// LocationProposalApproved indicates the proposed location was applied
+	LocationProposalApproved LocationProposalState = "approved"
+	// LocationProposalRejected indicates an operator turned the proposal down
+	LocationProposalRejected LocationProposalState = "rejected"
+	// LocationProposalExpired indicates nobody decided in time
+	LocationProposalExpired LocationProposalState = "expired"
+)
+
+// LocationProposalRequest is sent by a display to propose a correction to
+// its own location
+type LocationProposalRequest struct {
+	// Location is where the display is actually mounted
+	Location DisplayLocation `json:"location"`
+	// Note optionally explains the change to the approving operator
+	Note string `json:"note,omitempty"`
+}
+
+// LocationProposal is a location change suggested by a display
+type LocationProposal struct {
+	// ID uniquely identifies the proposal
+	ID uuid.UUID `json:"id"`
+	// DisplayID is the display that proposed the change
+	DisplayID uuid.UUID `json:"displayId"`
+	// Location is the proposed location
+	Location DisplayLocation `json:"location"`
+	// PreviousLocation is where the display was recorded when it proposed
+	// the change
+	PreviousLocation DisplayLocation `json:"previousLocation"`
+	// Note is an optional explanation from the installer
+	Note string `json:"note,omitempty"`
+	// State is where the proposal is in its lifecycle
+	State LocationProposalState `json:"state"`
+	// CreatedAt is when the display made the proposal
+	CreatedAt time.Time `json:"createdAt"`
+	// DecidedAt is when the proposal was approved, rejected or expired
+	DecidedAt *time.Time `json:"decidedAt,omitempty"`
+	// DecidedBy names the operator who approved or rejected the proposal
+	DecidedBy string `json:"decidedBy,omitempty"`
+}
+
+// LocationProposalList is a list of location proposals, oldest first
+type LocationProposalList struct {
+	// TypeMeta describes the versioning of this object
+	TypeMeta `json:",inline"`
+	// Items are the proposals
+	Items []LocationProposal `json:"items"`
+}
+
+// PendingMessageState is where a queued control message is in its
+// lifecycle
+type PendingMessageState string
+
+const (
+	// PendingMessagePending indicates a message waiting for its display to
+	// connect
+	PendingMessagePending PendingMessageState = "pending"
+	// PendingMessageDelivered indicates a message handed to the display
+	PendingMessageDelivered PendingMessageState = "delivered"
+	// PendingMessageDead indicates a message that expired undelivered and
+	// can be replayed
+	PendingMessageDead PendingMessageState = "dead"
+)
+
+// PendingMessage is a control message queued for a display that was not
+// connected when it was sent
+type PendingMessage struct {
+	// ID uniquely identifies the queued message
+	ID uuid.UUID `json:"id"`
+	// DisplayID is the display the message is for
+	DisplayID uuid.UUID `json:"displayId"`
+	// Message is the queued control message
+	Message ControlMessage `json:"message"`
+	// State is where the message is in its lifecycle
+	State PendingMessageState `json:"state"`
+	// Attempts counts how often delivery was tried
+	Attempts int `json:"attempts"`
+	// LastError describes why the last delivery attempt failed
+	LastError string `json:"lastError,omitempty"`
+	// CreatedAt is when the message was queued
+	CreatedAt time.Time `json:"createdAt"`
+	// ExpiresAt is when an undelivered message becomes a dead letter
+	ExpiresAt time.Time `json:"expiresAt"`
+	// DeliveredAt is when the message was delivered
+	DeliveredAt *time.Time `json:"deliveredAt,omitempty"`
+}
+
+// PendingMessageList is a display's queued control messages in queue order
+type PendingMessageList struct {
+	// TypeMeta describes the versioning of this object
+	TypeMeta `json:",inline"`
+	// DisplayID is the display the messages are for
+	DisplayID uuid.UUID `json:"displayId"`
+	// Items are the messages
+	Items []PendingMessage `json:"items"`
+}
+
+// ConnectionInfo describes one open control connection held by a display
+type ConnectionInfo struct {
+	// ConnectedAt is when the connection was established
+	ConnectedAt time.Time `json:"connectedAt"`
+	// RemoteAddr is the peer address of the connection
+	RemoteAddr string `json:"remoteAddr,omitempty"`
+	// QueueDepth is the number of messages waiting to be written
+	QueueDepth int `json:"queueDepth"`
+	// QueueCapacity is the maximum number of queued messages
+	QueueCapacity int `json:"queueCapacity"`
+	// DroppedFrames counts messages evicted because the queue was full
+	DroppedFrames uint64 `json:"droppedFrames"`
+	// ConsecutiveDrops counts drops since the last message queued cleanly
+	ConsecutiveDrops uint64 `json:"consecutiveDrops"`
+	// SessionID is the token session the connection authenticated with,
+	// when the display presented a token
+	SessionID *uuid.UUID `json:"sessionId,omitempty"`
+	// Capabilities are the message types the display declared it handles,
+	// empty if it has not declared any
+	Capabilities []ControlMessageType `json:"capabilities,omitempty"`
+	// UnknownMessages counts messages from the display that were ignored
+	// because their type or version was not understood
+	UnknownMessages uint64 `json:"unknownMessages"`
+}
+
+// DisplayConnections lists a display's open control connections
+type DisplayConnections struct {
+	// TypeMeta describes the versioning of this object
+	TypeMeta `json:",inline"`
+	// DisplayID identifies the display
+	DisplayID uuid.UUID `json:"displayId"`
+	// Items are the open connections, oldest first
+	Items []ConnectionInfo `json:"items"`
+}
+
+// DisplaySession describes one family of access tokens issued to a
+// display: the token from its activation and any refreshed from it
+type DisplaySession struct {
+	// ID identifies the session
+	ID uuid.UUID `json:"id"`
+	// KeyID identifies the key that signed the newest token
+	KeyID string `json:"keyId"`
+	// IssuedAt is when the session's first token was issued
+	IssuedAt time.Time `json:"issuedAt"`
+	// LastRefreshedAt is when the session's newest token was issued
+	LastRefreshedAt time.Time `json:"lastRefreshedAt"`
+	// ExpiresAt is when the session's last token stops being valid
+	ExpiresAt time.Time `json:"expiresAt"`
+	// Connections counts the open control connections using the session
+	Connections int `json:"connections"`
+}
+
+// DisplaySessionList lists a display's active sessions
+type DisplaySessionList struct {
+	// TypeMeta describes the versioning of this object
+	TypeMeta `json:",inline"`
+	// DisplayID identifies the display
+	DisplayID uuid.UUID `json:"displayId"`
+	// Items are the sessions, oldest first
+	Items []DisplaySession `json:"items"`
+}
+
 // DisplayFilter defines criteria for listing displays
 type DisplayFilter struct {
 	// SiteID filters by location site ID
@@ -115,6 +606,9 @@ type DisplayList struct {
 
 	// Items is the list of Display objects
 	Items []Display `json:"items"`
+	// NextCursor fetches the following page when passed back as the cursor
+	// query parameter. It is empty on the last page.
+	NextCursor string `json:"nextCursor,omitempty"`
 }
 
 // DisplayUpdateRequest represents a request to update a display
@@ -125,6 +619,24 @@ type DisplayUpdateRequest struct {
 	Properties map[string]string `json:"properties,omitempty"`
 }
 
+// DisplayPatchRequest represents a partial update to a display. Properties
+// not named in the request are left unchanged.
+type DisplayPatchRequest struct {
+	// Name renames the display when set. Its ID is kept, so its tokens,
+	// connections and history are unaffected.
+	Name string `json:"name,omitempty"`
+	// Location updates the display's location when set. Empty fields keep
+	// their current value.
+	Location *DisplayLocation `json:"location,omitempty"`
+	// AddProperties adds or overwrites the given properties
+	AddProperties map[string]string `json:"addProperties,omitempty"`
+	// RemoveProperties deletes the given property keys
+	RemoveProperties []string `json:"removeProperties,omitempty"`
+	// Version, when set, makes the update conditional on the display being
+	// at this version
+	Version int `json:"version,omitempty"`
+}
+
 // ContentAssignment represents content assigned to displays
 type ContentAssignment struct {
 	// TypeMeta describes the versioning of this object
@@ -132,6 +644,8 @@ type ContentAssignment struct {
 	// ObjectMeta provides metadata about the assignment
 	ObjectMeta `json:"metadata,omitempty"`
 
+	// Source names the content source the assignment was created from, if any
+	Source string `json:"source,omitempty"`
 	// DisplaySelector specifies which displays this content targets
 	DisplaySelector DisplaySelector `json:"displaySelector"`
 	// ContentURL is the URL where displays can fetch the content
@@ -140,6 +654,30 @@ type ContentAssignment struct {
 	ValidFrom *time.Time `json:"validFrom,omitempty"`
 	// ValidUntil indicates when this content expires
 	ValidUntil *time.Time `json:"validUntil,omitempty"`
+	// Schedule, when set, limits the assignment to recurring windows of
+	// local time within its validity period
+	Schedule *RecurringSchedule `json:"schedule,omitempty"`
+	// AutoCreated is set by the server on assignments it created for a
+	// display matching an auto-assignment rule when the display was
+	// activated. Any other assignment selecting the display outranks it.
+	AutoCreated bool `json:"autoCreated,omitempty"`
+
+	// Status describes the display an assignment targets by reference. It
+	// is set by the server and unset for assignments selecting displays by
+	// location.
+	Status *ContentAssignmentStatus `json:"status,omitempty"`
+}
+
+// ContentAssignmentStatus describes the display a content assignment names
+type ContentAssignmentStatus struct {
+	// DisplayName is the current name of the display, empty once it has
+	// been deleted
+	DisplayName string `json:"displayName,omitempty"`
+	// Orphaned is set once the display has been deleted; the assignment
+	// then reaches no display
+	Orphaned bool `json:"orphaned,omitempty"`
+	// Warnings explain why the assignment may not show on its display
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 // ContentAssignmentList is a list of content assignments
@@ -150,3 +688,44 @@ type ContentAssignmentList struct {
 	// Items is the list of ContentAssignment objects
 	Items []ContentAssignment `json:"items"`
 }
+
+// DisplayPruneRequest asks for offline displays to be removed in bulk
+type DisplayPruneRequest struct {
+	// OfflineFor is how long a display must have been offline, as a number
+	// of days such as 30d or a Go duration
+	OfflineFor string `json:"offlineFor"`
+	// SiteID limits pruning to one site when set
+	SiteID string `json:"siteId,omitempty"`
+	// DryRun lists the displays that would be removed without removing them
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// DisplayPruneResult reports the outcome of a prune
+type DisplayPruneResult struct {
+	// TypeMeta describes the versioning of this object
+	TypeMeta `json:",inline"`
+	// DryRun is set when nothing was removed
+	DryRun bool `json:"dryRun,omitempty"`
+	// Matched counts the displays offline for longer than requested
+	Matched int `json:"matched"`
+	// Deleted counts the displays removed
+	Deleted int `json:"deleted"`
+	// Skipped counts matched displays kept because they came back or
+	// changed state while the prune ran
+	Skipped int `json:"skipped"`
+	// Items are the displays removed, or on a dry run those that would be,
+	// longest silent first
+	Items []PrunedDisplay `json:"items"`
+}
+
+// PrunedDisplay identifies a display removed by a prune
+type PrunedDisplay struct {
+	// ID uniquely identifies the display
+	ID uuid.UUID `json:"id"`
+	// Name is the display's name
+	Name string `json:"name"`
+	// SiteID is the site the display was at
+	SiteID string `json:"siteId"`
+	// LastSeen is when the display last checked in
+	LastSeen time.Time `json:"lastSeen"`
+}
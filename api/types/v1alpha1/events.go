@@ -1,6 +1,10 @@
 package v1alpha1
 
 import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,6 +22,8 @@ const (
 	DisplayEventDisabled DisplayEventType = "DISABLED"
 	// DisplayEventLocationChanged indicates display location change
 	DisplayEventLocationChanged DisplayEventType = "LOCATION_CHANGED"
+	// DisplayEventRenamed indicates display name change
+	DisplayEventRenamed DisplayEventType = "RENAMED"
 )
 
 // ContentEventType represents types of content-related events
@@ -34,6 +40,9 @@ const (
 	ContentEventHidden ContentEventType = "CONTENT_HIDDEN"
 	// ContentEventInteractive indicates content became interactive
 	ContentEventInteractive ContentEventType = "CONTENT_INTERACTIVE"
+	// ContentEventPrefetchProgress reports how much of content a display
+	// has downloaded ahead of showing it
+	ContentEventPrefetchProgress ContentEventType = "CONTENT_PREFETCH_PROGRESS"
 )
 
 // DisplayEvent represents a display lifecycle event
@@ -85,13 +94,57 @@ type EventError struct {
 // EventMetrics contains content performance measurements
 type EventMetrics struct {
 	// LoadTime measures content loading duration
-	LoadTime int64 `json:"loadTime"`
+	LoadTime Milliseconds `json:"loadTime"`
 	// RenderTime measures initial render duration
-	RenderTime int64 `json:"renderTime"`
+	RenderTime Milliseconds `json:"renderTime"`
 	// InteractiveTime measures time until interactive
-	InteractiveTime int64 `json:"interactiveTime"`
+	InteractiveTime Milliseconds `json:"interactiveTime"`
 	// ResourceStats contains resource usage details
 	ResourceStats *ResourceStats `json:"resourceStats,omitempty"`
+	// BytesDownloaded is how much of the content a prefetch has fetched
+	BytesDownloaded int64 `json:"bytesDownloaded,omitempty"`
+	// BytesTotal is the size of the content being prefetched, or zero
+	// while it is not yet known
+	BytesTotal int64 `json:"bytesTotal,omitempty"`
+}
+
+// Milliseconds is a whole number of milliseconds. It is written to JSON as
+// an integer. It reads any JSON number, rounding a fraction half away from
+// zero, because browsers time page loads in fractional milliseconds and
+// older servers stored what they were sent. Numbers are parsed exactly, so
+// values beyond the 53 bits a float64 holds do not drift.
+type Milliseconds int64
+
+// UnmarshalJSON reads a JSON number, rounding it to whole milliseconds.
+// null leaves the value unchanged.
+func (m *Milliseconds) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		*m = Milliseconds(n)
+		return nil
+	}
+
+	// Valid JSON rules out the fractions and hex SetString also accepts
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || !json.Valid(data) {
+		return fmt.Errorf("milliseconds must be a number, got %s", s)
+	}
+	// Round half away from zero: truncate |r| + 1/2
+	half := big.NewRat(1, 2)
+	abs := new(big.Rat).Abs(r)
+	abs.Add(abs, half)
+	rounded := new(big.Int).Quo(abs.Num(), abs.Denom())
+	if r.Sign() < 0 {
+		rounded.Neg(rounded)
+	}
+	if !rounded.IsInt64() {
+		return fmt.Errorf("milliseconds %s out of range", s)
+	}
+	*m = Milliseconds(rounded.Int64())
+	return nil
 }
 
 // ResourceStats contains content resource usage details
@@ -113,3 +166,14 @@ type ContentEventBatch struct {
 	// Events contains the batch of events
 	Events []ContentEvent `json:"events"`
 }
+
+// ContentEventList is one page of stored content events, oldest first
+type ContentEventList struct {
+	// TypeMeta describes API version details
+	TypeMeta `json:",inline"`
+	// Items contains the events on this page
+	Items []ContentEvent `json:"items"`
+	// NextPageToken fetches the following page when passed back as the
+	// pageToken query parameter. It is empty on the last page.
+	NextPageToken string `json:"nextPageToken,omitempty"`
+}
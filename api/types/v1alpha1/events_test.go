@@ -0,0 +1,51 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMillisecondsUnmarshal(t *testing.T) {
+	tests := []struct {
+		json string
+		want Milliseconds
+	}{
+		{json: `1500`, want: 1500},
+		// 2^53 + 1 and the int64 bounds survive, where a float64 would not
+		{json: `9007199254740993`, want: 1<<53 + 1},
+		{json: `9223372036854775807`, want: math.MaxInt64},
+		{json: `-9223372036854775808`, want: math.MinInt64},
+		{json: `1500.4`, want: 1500},
+		{json: `1500.5`, want: 1501},
+		{json: `-1500.5`, want: -1501},
+		{json: `9007199254740993.4`, want: 1<<53 + 1},
+		{json: `1.5e3`, want: 1500},
+		{json: `2E-1`, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.json, func(t *testing.T) {
+			var m Milliseconds
+			require.NoError(t, json.Unmarshal([]byte(tt.json), &m))
+			assert.Equal(t, tt.want, m)
+		})
+	}
+
+	for _, invalid := range []string{`"1500"`, `true`, `9223372036854775807.5`, `1e19`} {
+		var m Milliseconds
+		assert.Error(t, json.Unmarshal([]byte(invalid), &m), invalid)
+	}
+}
+
+func TestEventMetricsRoundTrip(t *testing.T) {
+	var m EventMetrics
+	require.NoError(t, json.Unmarshal([]byte(`{"loadTime": 812.6, "renderTime": 9007199254740993, "interactiveTime": null}`), &m))
+	assert.Equal(t, EventMetrics{LoadTime: 813, RenderTime: 1<<53 + 1}, m)
+
+	data, err := json.Marshal(m)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"loadTime": 813, "renderTime": 9007199254740993, "interactiveTime": 0}`, string(data))
+}
@@ -0,0 +1,46 @@
+package v1alpha1
+
+import "time"
+
+// MaintenanceRequest starts or ends maintenance mode, during which every
+// display shows one notice instead of its assigned content
+type MaintenanceRequest struct {
+	// Enabled starts maintenance when true and ends it when false
+	Enabled bool `json:"enabled"`
+	// ContentURL is the notice displays show, required to start maintenance
+	ContentURL string `json:"contentUrl,omitempty"`
+	// Message describes the maintenance to displays and operators
+	Message string `json:"message,omitempty"`
+	// Until, when set, is when maintenance ends by itself
+	Until *time.Time `json:"until,omitempty"`
+}
+
+// MaintenanceStatus reports whether the server is in maintenance mode
+type MaintenanceStatus struct {
+	// TypeMeta describes the versioning of this object
+	TypeMeta `json:",inline"`
+	// Enabled is true while displays are shown the maintenance notice
+	Enabled bool `json:"enabled"`
+	// ContentURL is the notice displays are shown
+	ContentURL string `json:"contentUrl,omitempty"`
+	// Message describes the maintenance
+	Message string `json:"message,omitempty"`
+	// Until is when maintenance ends by itself, if it does
+	Until *time.Time `json:"until,omitempty"`
+	// StartedAt is when maintenance started
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+	// StartedBy names the operator who started maintenance, if known
+	StartedBy string `json:"startedBy,omitempty"`
+	// DisplaysNotified counts the connected displays sent new content by
+	// the request that returned this status
+	DisplaysNotified int `json:"displaysNotified,omitempty"`
+}
+
+// MaintenanceNotice tells a display that its content was replaced by the
+// maintenance notice
+type MaintenanceNotice struct {
+	// Message describes the maintenance
+	Message string `json:"message,omitempty"`
+	// Until is when maintenance ends by itself, if it does
+	Until *time.Time `json:"until,omitempty"`
+}
@@ -0,0 +1,63 @@
+package v1alpha1
+
+// Bounds displays apply to polling hints, in seconds. The server never
+// hands out hints outside them, but displays clamp what they receive so that
+// a bad hint cannot make them flood the server or go quiet for days.
+const (
+	MinHeartbeatInterval   = 15
+	MaxHeartbeatInterval   = 60 * 60
+	MinContentPollInterval = 30
+	MaxContentPollInterval = 24 * 60 * 60
+)
+
+// PollingHints tell a display how often to check in and to look for new
+// content. The server assigns them, so load can be reduced across a fleet
+// without updating firmware. Intervals are in seconds; zero means no hint,
+// and the display keeps its own interval.
+type PollingHints struct {
+	// HeartbeatInterval is how often the display should report that it is
+	// alive
+	HeartbeatInterval int `json:"heartbeatInterval,omitempty"`
+	// ContentPollInterval is how often the display should check for new
+	// content
+	ContentPollInterval int `json:"contentPollInterval,omitempty"`
+}
+
+// Clamp returns the hints limited to the bounds above. Unset hints stay
+// unset.
+func (h PollingHints) Clamp() PollingHints {
+	return PollingHints{
+		HeartbeatInterval:   clampHint(h.HeartbeatInterval, MinHeartbeatInterval, MaxHeartbeatInterval),
+		ContentPollInterval: clampHint(h.ContentPollInterval, MinContentPollInterval, MaxContentPollInterval),
+	}
+}
+
+func clampHint(v, lo, hi int) int {
+	switch {
+	case v == 0:
+		return 0
+	case v < lo:
+		return lo
+	case v > hi:
+		return hi
+	}
+	return v
+}
+
+// PollingPolicy is the set of polling hints a server hands out: one default
+// and overrides by site ID. Hints an override leaves unset are taken from
+// the default.
+type PollingPolicy struct {
+	// TypeMeta describes the versioning of this object
+	TypeMeta `json:",inline"`
+	// Default applies to displays at sites without an override
+	Default PollingHints `json:"default"`
+	// Sites overrides the default by site ID
+	Sites map[string]PollingHints `json:"sites,omitempty"`
+}
+
+// HeartbeatResponse is returned when a display's check-in is recorded
+type HeartbeatResponse struct {
+	// PollingHints are the intervals the display should use from now on
+	PollingHints `json:",inline"`
+}
@@ -0,0 +1,97 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RecurringSchedule limits an assignment to windows of local time that
+// repeat every week, such as breakfast menus until 10:30 on weekdays.
+// Windows follow the wall clock of their time zone, so across a daylight
+// saving change a window still opens and closes at the same local times. A
+// window in the hour skipped when clocks go forward does not open that day.
+type RecurringSchedule struct {
+	// Timezone is the IANA time zone the windows are in, such as
+	// America/New_York. When empty the time zone configured for the
+	// assignment's site is used.
+	Timezone string `json:"timezone,omitempty"`
+	// Windows are when the assignment is in effect. Windows of one schedule
+	// may not overlap.
+	Windows []ScheduleWindow `json:"windows"`
+}
+
+// ScheduleWindow is a range of local time on some days of the week
+type ScheduleWindow struct {
+	// DaysOfWeek are the days the window opens on; empty means every day
+	DaysOfWeek []time.Weekday `json:"daysOfWeek,omitempty"`
+	// TimeOfDay is the range of local time, from Start up to but not
+	// including End, both written HH:MM. End may be 24:00 for the end of
+	// the day; a window past midnight is written as two windows.
+	TimeOfDay TimeRange `json:"timeOfDay"`
+}
+
+// ParseClock reads a time of day written HH:MM, returning minutes since
+// midnight. 24:00 is accepted for the end of the day.
+func ParseClock(v string) (int, error) {
+	hh, mm, ok := strings.Cut(v, ":")
+	h, herr := strconv.Atoi(hh)
+	m, merr := strconv.Atoi(mm)
+	if !ok || len(hh) != 2 || len(mm) != 2 || herr != nil || merr != nil || h < 0 || m < 0 {
+		return 0, fmt.Errorf("invalid time of day %q: use HH:MM", v)
+	}
+	if h == 24 && m == 0 {
+		return 24 * 60, nil
+	}
+	if h > 23 || m > 59 {
+		return 0, fmt.Errorf("invalid time of day %q: use HH:MM between 00:00 and 24:00", v)
+	}
+	return h*60 + m, nil
+}
+
+// weekdayNames maps the names days may be written with to their weekday
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// ParseWeekdays reads a comma separated list of days and day ranges, such
+// as "mon-fri" or "mon,wed,sat-sun". A range runs forward through the week,
+// so "fri-mon" covers the weekend. Days are returned in week order,
+// starting on Sunday, without repeats.
+func ParseWeekdays(v string) ([]time.Weekday, error) {
+	var set [7]bool
+	for _, part := range strings.Split(v, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		first, last, isRange := strings.Cut(part, "-")
+		from, ok := weekdayNames[first]
+		if !ok {
+			return nil, fmt.Errorf("invalid day of week %q", first)
+		}
+		to := from
+		if isRange {
+			if to, ok = weekdayNames[last]; !ok {
+				return nil, fmt.Errorf("invalid day of week %q", last)
+			}
+		}
+		for d := from; ; d = (d + 1) % 7 {
+			set[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+	var days []time.Weekday
+	for d, on := range set {
+		if on {
+			days = append(days, time.Weekday(d))
+		}
+	}
+	return days, nil
+}
@@ -2,7 +2,13 @@
 package v1alpha1
 
 import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // RedirectRule defines how to map display requests to content URLs
@@ -53,6 +59,9 @@ type ContentRedirect struct {
 	Version string `json:"version"`
 	// Hash identifies the specific content revision
 	Hash string `json:"hash"`
+	// Tags, when set, redirect to content carrying all of these tags
+	// instead of naming a content type
+	Tags []string `json:"tags,omitempty"`
 }
 
 // Schedule defines when a rule is active
@@ -75,14 +84,148 @@ type TimeRange struct {
 	End string `json:"end"`
 }
 
-// DisplaySelector identifies displays by their location attributes
+// DisplaySelector identifies displays by their location attributes and
+// properties, or names exactly one display. Empty fields match every
+// display.
 type DisplaySelector struct {
+	// DisplayRef targets exactly one display by name or ID, in place of
+	// the other fields. The server stores and returns the display's ID, so
+	// the selector keeps its display when it is renamed.
+	DisplayRef string `json:"displayRef,omitempty"`
 	// SiteID identifies a physical location
 	SiteID string `json:"siteId,omitempty"`
 	// Zone identifies an area within a site
 	Zone string `json:"zone,omitempty"`
 	// Position identifies a specific spot within a zone
 	Position string `json:"position,omitempty"`
+	// MatchProperties lists display properties that must all be present
+	// with exactly these values, e.g. {"orientation": "portrait"}
+	MatchProperties map[string]string `json:"matchProperties,omitempty"`
+}
+
+// Matches reports whether a display at location with properties is
+// selected. A selector naming a display matches no location; Selects
+// checks it against a display's ID.
+func (s DisplaySelector) Matches(location DisplayLocation, properties map[string]string) bool {
+	if s.DisplayRef != "" {
+		return false
+	}
+	if s.SiteID != "" && s.SiteID != location.SiteID {
+		return false
+	}
+	if s.Zone != "" && s.Zone != location.Zone {
+		return false
+	}
+	if s.Position != "" && s.Position != location.Position {
+		return false
+	}
+	for key, value := range s.MatchProperties {
+		if got, ok := properties[key]; !ok || got != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Selects reports whether the display with id at location with
+// properties is selected, either by name or ID or by its location and
+// properties
+func (s DisplaySelector) Selects(id uuid.UUID, location DisplayLocation, properties map[string]string) bool {
+	if s.DisplayRef != "" {
+		return s.DisplayRef == id.String()
+	}
+	return s.Matches(location, properties)
+}
+
+// displayRefSpecificity ranks a selector naming a display above any
+// combination of location levels and properties
+const displayRefSpecificity = 1 << 16
+
+// Specificity counts the dimensions a selector constrains: each location
+// level set plus each property matched. A selector naming a display
+// outranks all others. When several selectors match a display, the one
+// with the higher specificity takes precedence.
+func (s DisplaySelector) Specificity() int {
+	if s.DisplayRef != "" {
+		return displayRefSpecificity
+	}
+	n := len(s.MatchProperties)
+	for _, level := range []string{s.SiteID, s.Zone, s.Position} {
+		if level != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// MatchPropertyParam is the query parameter that carries a selector's
+// matchProperties, repeated once per key=value pair
+const MatchPropertyParam = "matchProperty"
+
+// FormatMatchProperties renders properties as key=value pairs sorted by
+// key, the form used by MatchPropertyParam
+func FormatMatchProperties(properties map[string]string) []string {
+	pairs := make([]string, 0, len(properties))
+	for key, value := range properties {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+// ParseMatchProperties parses key=value pairs into a property map. It
+// returns nil when pairs is empty.
+func ParseMatchProperties(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	properties := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid property match %q: want key=value", pair)
+		}
+		properties[key] = value
+	}
+	return properties, nil
+}
+
+// ParseSince reads a time given relative to now, as the since and until
+// query parameters are. It accepts a period as ParsePeriod does, meaning
+// that long ago, or an RFC 3339 time.
+func ParseSince(v string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+
+	period, err := ParsePeriod(v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return now.Add(-period), nil
+}
+
+// ParsePeriod reads a length of time given as a number of days such as 7d
+// or as any positive Go duration
+func ParsePeriod(v string) (time.Duration, error) {
+	var period time.Duration
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number of days", v)
+		}
+		period = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("%q is neither a number of days nor a duration", v)
+		}
+		period = d
+	}
+	if period <= 0 {
+		return 0, fmt.Errorf("%q must be positive", v)
+	}
+	return period, nil
 }
 
 // ListResponse wraps lists of items with metadata
@@ -91,6 +234,8 @@ type ListResponse struct {
 	Items []interface{} `json:"items"`
 	// TotalCount is the total number of matching items
 	TotalCount int `json:"totalCount,omitempty"`
+	// NextCursor fetches the following page; empty on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
 }
 
 // Error represents an API error response
@@ -101,4 +246,28 @@ type Error struct {
 	Message string `json:"message"`
 	// Details contains additional error context
 	Details interface{} `json:"details,omitempty"`
+	// Errors lists every invalid field of a request that failed
+	// validation
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// Field error codes
+const (
+	// FieldRequired marks a missing field
+	FieldRequired = "required"
+	// FieldInvalid marks a field whose value is not acceptable
+	FieldInvalid = "invalid"
+	// FieldUnknown marks a field the request type does not have
+	FieldUnknown = "unknown"
+)
+
+// FieldError describes one invalid field of a request
+type FieldError struct {
+	// Field is the path of the field in the request body, such as
+	// "location.zone" or "events[2].url"
+	Field string `json:"field"`
+	// Code is a machine-readable reason, one of the Field* codes
+	Code string `json:"code"`
+	// Message is a human-readable description of the problem
+	Message string `json:"message"`
 }
@@ -0,0 +1,89 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisplaySelectorMatches(t *testing.T) {
+	lobby := DisplayLocation{SiteID: "hq", Zone: "lobby", Position: "north"}
+	portrait := map[string]string{"orientation": "portrait", "resolution": "4k"}
+
+	tests := []struct {
+		name     string
+		selector DisplaySelector
+		want     bool
+	}{
+		{"empty selector matches everything", DisplaySelector{}, true},
+		{"location levels", DisplaySelector{SiteID: "hq", Zone: "lobby"}, true},
+		{"other zone", DisplaySelector{SiteID: "hq", Zone: "cafeteria"}, false},
+		{"one property", DisplaySelector{SiteID: "hq", MatchProperties: map[string]string{"orientation": "portrait"}}, true},
+		{"every property must match", DisplaySelector{MatchProperties: map[string]string{"orientation": "portrait", "resolution": "8k"}}, false},
+		{"missing property", DisplaySelector{MatchProperties: map[string]string{"touch": "yes"}}, false},
+		{"empty value still requires the key", DisplaySelector{MatchProperties: map[string]string{"touch": ""}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.selector.Matches(lobby, portrait))
+		})
+	}
+}
+
+func TestDisplaySelectorSpecificity(t *testing.T) {
+	site := DisplaySelector{SiteID: "hq"}
+	zone := DisplaySelector{SiteID: "hq", Zone: "lobby"}
+	portraitAtSite := DisplaySelector{SiteID: "hq", MatchProperties: map[string]string{"orientation": "portrait"}}
+	portraitInZone := DisplaySelector{SiteID: "hq", Zone: "lobby", MatchProperties: map[string]string{"orientation": "portrait"}}
+
+	assert.Equal(t, 0, DisplaySelector{}.Specificity())
+	assert.Equal(t, 1, site.Specificity())
+	assert.Equal(t, 2, zone.Specificity())
+	assert.Equal(t, zone.Specificity(), portraitAtSite.Specificity())
+	assert.Greater(t, portraitInZone.Specificity(), zone.Specificity())
+}
+
+func TestParseMatchProperties(t *testing.T) {
+	properties, err := ParseMatchProperties([]string{"orientation=portrait", "note=a=b"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"orientation": "portrait", "note": "a=b"}, properties)
+	assert.Equal(t, []string{"note=a=b", "orientation=portrait"}, FormatMatchProperties(properties))
+
+	none, err := ParseMatchProperties(nil)
+	require.NoError(t, err)
+	assert.Nil(t, none)
+
+	for _, bad := range []string{"orientation", "=portrait"} {
+		_, err := ParseMatchProperties([]string{bad})
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "7d", want: now.Add(-7 * 24 * time.Hour)},
+		{value: "90m", want: now.Add(-90 * time.Minute)},
+		{value: "2024-03-01T00:00:00Z", want: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{value: "0d", wantErr: true},
+		{value: "-1h", wantErr: true},
+		{value: "xd", wantErr: true},
+		{value: "last week", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSince(tt.value, now)
+		if tt.wantErr {
+			assert.Error(t, err, tt.value)
+			continue
+		}
+		require.NoError(t, err, tt.value)
+		assert.True(t, tt.want.Equal(got), "%s: got %s", tt.value, got)
+	}
+}
@@ -0,0 +1,13 @@
+package v1alpha1
+
+// VersionInfo describes the build of a server binary
+type VersionInfo struct {
+	// TypeMeta describes the versioning of this object
+	TypeMeta `json:",inline"`
+	// Version is the release version
+	Version string `json:"version"`
+	// Commit is the source revision the binary was built from
+	Commit string `json:"commit"`
+	// BuildDate is when the binary was built
+	BuildDate string `json:"buildDate"`
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/properties"
+	"github.com/wrale/wrale-signage/internal/wsignd/server"
+)
+
+// checkUsage describes the "check" subcommand
+const checkUsage = "usage: wsignd check [properties]"
+
+// runCheck implements "wsignd check properties", which reports stored data
+// the server would no longer accept. Without arguments, "wsignd check" runs
+// runSelfCheck instead. It never changes anything; the records it
+// lists are left for an operator to fix.
+func runCheck(ctx context.Context, args []string, cfg *config.Config, db *sql.DB, out io.Writer) error {
+	if len(args) != 1 || args[0] != "properties" {
+		return fmt.Errorf(checkUsage)
+	}
+
+	stores := server.PostgresStores(db, nil, cfg)
+	found, err := checkProperties(ctx, cfg.Properties, stores.Displays, stores.Content, out)
+	if err != nil {
+		return err
+	}
+	if found > 0 {
+		return fmt.Errorf("found %d property limit violation(s)", found)
+	}
+	return nil
+}
+
+// checkProperties lists the displays and content sources whose properties
+// exceed limits and returns how many violations it found
+func checkProperties(ctx context.Context, limits config.PropertyLimits, displays display.Repository, sources content.Repository, out io.Writer) (int, error) {
+	fmt.Fprintf(out, "Property limits: %d properties, %d byte keys, %d byte values, %d bytes in total (0 is unlimited)\n",
+		limits.MaxCount, limits.MaxKeyLength, limits.MaxValueLength, limits.MaxTotalSize)
+
+	found := 0
+	report := func(owner string, props map[string]string) {
+		for _, v := range properties.Violations(limits, props) {
+			field := "properties"
+			if v.Key != "" {
+				field = fmt.Sprintf("properties[%s]", properties.QuoteKey(v.Key))
+			}
+			fmt.Fprintf(out, "%s: %s: %s\n", owner, field, v.Message)
+			found++
+		}
+	}
+
+	all, err := displays.List(ctx, display.DisplayFilter{})
+	if err != nil {
+		return 0, fmt.Errorf("error listing displays: %w", err)
+	}
+	for _, d := range all {
+		report(fmt.Sprintf("display %s (%s)", d.Name, d.ID), d.Properties)
+	}
+
+	list, err := sources.ListContent(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error listing content sources: %w", err)
+	}
+	for _, s := range list {
+		report(fmt.Sprintf("content source %s", s.Name), s.Spec.Properties)
+	}
+
+	if found == 0 {
+		fmt.Fprintf(out, "No violations in %d display(s) and %d content source(s)\n", len(all), len(list))
+	}
+	return found, nil
+}
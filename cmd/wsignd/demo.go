@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+	"github.com/wrale/wrale-signage/internal/wsignd/server"
+)
+
+// demoKeyID names the signing key generated for a demo run
+const demoKeyID = "demo"
+
+// setupDemoKeyRing uses the configured signing keys if there are any and
+// otherwise generates a key that lives as long as the process
+func setupDemoKeyRing(cfg config.AuthConfig) (*auth.KeyRing, error) {
+	if cfg.TokenSigningKey != "" || len(cfg.SigningKeys) > 0 {
+		return setupKeyRing(cfg)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("error generating demo signing key: %w", err)
+	}
+	key, err := auth.NewHMACKey(demoKeyID, secret)
+	if err != nil {
+		return nil, err
+	}
+	return auth.NewKeyRing(key)
+}
+
+// seedDemo fills the stores with example displays and content and
+// returns an admin API token for trying out the API
+func seedDemo(ctx context.Context, stores *server.Stores) (string, error) {
+	displays := []struct {
+		name     string
+		location display.Location
+		active   bool
+	}{
+		{"lobby-north", display.Location{SiteID: "hq", Zone: "lobby", Position: "north"}, true},
+		{"lobby-south", display.Location{SiteID: "hq", Zone: "lobby", Position: "south"}, true},
+		{"cafeteria-main", display.Location{SiteID: "hq", Zone: "cafeteria", Position: "main"}, false},
+	}
+	for _, spec := range displays {
+		d, err := display.NewDisplay(spec.name, spec.location)
+		if err != nil {
+			return "", err
+		}
+		if spec.active {
+			if err := d.Activate(); err != nil {
+				return "", err
+			}
+		}
+		if err := stores.Displays.Save(ctx, d); err != nil {
+			return "", fmt.Errorf("error seeding display %s: %w", spec.name, err)
+		}
+	}
+
+	sources := []v1alpha1.ContentSource{
+		{
+			ObjectMeta: v1alpha1.ObjectMeta{Name: "welcome"},
+			Spec:       v1alpha1.ContentSourceSpec{URL: "https://example.com/welcome.html", Type: "welcome"},
+		},
+		{
+			ObjectMeta: v1alpha1.ObjectMeta{Name: "lunch-menu"},
+			Spec:       v1alpha1.ContentSourceSpec{URL: "https://example.com/menu.html", Type: "menu"},
+		},
+	}
+	for i := range sources {
+		source := &sources[i]
+		source.Status = v1alpha1.ContentSourceStatus{Version: 1}
+		if err := stores.Content.CreateContent(ctx, source); err != nil {
+			return "", fmt.Errorf("error seeding content source %s: %w", source.Name, err)
+		}
+	}
+
+	// Point the cafeteria at the lunch menu
+	err := stores.Assignments.Create(ctx, &v1alpha1.ContentAssignment{
+		ObjectMeta:      v1alpha1.ObjectMeta{Name: "cafeteria-lunch"},
+		Source:          "lunch-menu",
+		DisplaySelector: v1alpha1.DisplaySelector{SiteID: "hq", Zone: "cafeteria"},
+		ContentURL:      "https://example.com/menu.html",
+	})
+	if err != nil {
+		return "", fmt.Errorf("error seeding assignment: %w", err)
+	}
+
+	// Demo data is thrown away on exit, so one all-powerful token will do
+	raw, _, err := operator.NewService(stores.Operators).CreateToken(ctx, "demo", "demo admin", []operator.Scope{operator.ScopeAdmin}, 0)
+	if err != nil {
+		return "", fmt.Errorf("error seeding operator token: %w", err)
+	}
+
+	return raw, nil
+}
+
+// logDemoBanner makes it obvious in the logs that nothing will be kept
+func logDemoBanner(logger *slog.Logger, adminToken string) {
+	logger.Warn("running in DEMO mode: all data is held in memory and lost on exit",
+		"displays", "lobby-north, lobby-south, cafeteria-main",
+		"content", "welcome, lunch-menu",
+		"assignments", "cafeteria-lunch",
+		"adminToken", adminToken,
+	)
+}
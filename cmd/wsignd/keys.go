@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
+	authpostgres "github.com/wrale/wrale-signage/internal/wsignd/auth/postgres"
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+)
+
+// legacyKeyID names the key built from the single WSIGN_AUTH_TOKEN_KEY secret
+const legacyKeyID = "default"
+
+// setupKeyRing builds the token signing key ring from configuration. The
+// first configured key is the primary key.
+func setupKeyRing(cfg config.AuthConfig) (*auth.KeyRing, error) {
+	if len(cfg.SigningKeys) == 0 {
+		key, err := auth.NewHMACKey(legacyKeyID, []byte(cfg.TokenSigningKey))
+		if err != nil {
+			return nil, err
+		}
+		return auth.NewKeyRing(key)
+	}
+
+	keys := make([]*auth.Key, 0, len(cfg.SigningKeys))
+	for _, k := range cfg.SigningKeys {
+		key, err := auth.ParseKey(k.ID, k.Algorithm, k.Key)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return auth.NewKeyRing(keys...)
+}
+
+// runKeys implements the "keys" subcommand. Along with each key's usage it
+// prints the public key of EdDSA keys, base64 encoded, which is what
+// displays verify provisioning files with.
+func runKeys(ctx context.Context, args []string, cfg *config.Config, db *sql.DB, out io.Writer) error {
+	if len(args) == 0 || args[0] != "list" {
+		return fmt.Errorf("usage: wsignd keys list")
+	}
+
+	keys, err := setupKeyRing(cfg.Auth)
+	if err != nil {
+		return fmt.Errorf("error loading signing keys: %w", err)
+	}
+	usage, err := auth.NewService(keys, authpostgres.NewRepository(db), cfg.Auth.TokenExpiry).KeyUsage(ctx)
+	if err != nil {
+		return fmt.Errorf("error reading key usage: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "KID\tALGORITHM\tSTATUS\tACTIVE TOKENS\tPUBLIC KEY\n")
+	for _, u := range usage {
+		status := "active"
+		switch {
+		case u.Primary:
+			status = "primary"
+		case u.Algorithm == "":
+			// Tokens remain from a key that is no longer configured
+			status = "removed"
+		}
+		algorithm := string(u.Algorithm)
+		if algorithm == "" {
+			algorithm = "-"
+		}
+		// HS256 secrets are never printed
+		public := "-"
+		if len(u.PublicKey) > 0 {
+			public = base64.StdEncoding.EncodeToString(u.PublicKey)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", u.KeyID, algorithm, status, u.ActiveTokens, public)
+	}
+	return tw.Flush()
+}
@@ -4,21 +4,26 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
-
-	"github.com/go-chi/chi/v5"
-	_ "github.com/lib/pq"
+	// Assignment schedules name IANA time zones, which minimal container
+	// images do not install
+	_ "time/tzdata"
 
+	"github.com/wrale/wrale-signage/internal/version"
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
 	"github.com/wrale/wrale-signage/internal/wsignd/config"
-	"github.com/wrale/wrale-signage/internal/wsignd/display"
-	displayhttp "github.com/wrale/wrale-signage/internal/wsignd/display/http"
-	"github.com/wrale/wrale-signage/internal/wsignd/display/postgres"
+	"github.com/wrale/wrale-signage/internal/wsignd/database"
+	"github.com/wrale/wrale-signage/internal/wsignd/listen"
+	"github.com/wrale/wrale-signage/internal/wsignd/logging"
+	"github.com/wrale/wrale-signage/internal/wsignd/scheduler"
+	"github.com/wrale/wrale-signage/internal/wsignd/server"
 )
 
 func main() {
@@ -26,43 +31,168 @@ func main() {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 	slog.SetDefault(logger)
 
+	// --demo is shorthand for WSIGN_MODE=demo
+	if len(os.Args) > 1 && os.Args[1] == "--demo" {
+		os.Setenv("WSIGN_MODE", config.ModeDemo)
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+
+	// The environment check reports an invalid configuration itself, so
+	// it runs before the configuration is loaded
+	if len(os.Args) == 2 && os.Args[1] == "check" {
+		if err := runSelfCheck(context.Background(), os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Load configuration from environment variables, with validation
 	cfg, err := config.Load()
 	if err != nil {
 		logger.Error("failed to load configuration", "error", err)
 		os.Exit(1)
 	}
+	logger = slog.New(logging.NewSamplingHandler(
+		slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: cfg.Log.Level}),
+		cfg.Log.SampleEvery,
+	))
+	slog.SetDefault(logger)
+	for _, warning := range cfg.Warnings() {
+		logger.Warn("configuration warning", "warning", warning)
+	}
+
+	// Support bundles are written before migrating so that they can be
+	// collected from a server that fails to start
+	if len(os.Args) > 1 && os.Args[1] == "support-bundle" {
+		if err := runSupportBundle(context.Background(), os.Args[2:], cfg, os.Stdout); err != nil {
+			logger.Error("support-bundle command failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var (
+		stores *server.Stores
+		keys   *auth.KeyRing
+		db     *sql.DB
+		reader *sql.DB
+	)
+	if cfg.Demo() {
+		// Demo mode needs no external services
+		stores = server.MemoryStores(cfg)
+		var adminToken string
+		adminToken, err = seedDemo(context.Background(), stores)
+		if err != nil {
+			logger.Error("failed to seed demo data", "error", err)
+			os.Exit(1)
+		}
+		keys, err = setupDemoKeyRing(cfg.Auth)
+		logDemoBanner(logger, adminToken)
+	} else {
+		// A database started alongside the server may take a while to
+		// accept connections
+		// The primary and the replica share one set of query timings
+		queries := database.NewQueryTimer(cfg.Database.SlowQueryThreshold, logger)
+		db, err = database.SetupDatabase(context.Background(), cfg.Database, logger, queries)
+		if err != nil {
+			var setupErr *database.SetupError
+			if errors.As(err, &setupErr) && setupErr.Stage == database.StageMigrate {
+				logger.Error("failed to migrate database", "error", setupErr.Err)
+			} else {
+				logger.Error("failed to connect to database", "error", err)
+			}
+			os.Exit(1)
+		}
+		defer db.Close()
+
+		// Administrative subcommands run against the same configuration
+		if len(os.Args) > 1 && os.Args[1] == "keys" {
+			if err := runKeys(context.Background(), os.Args[2:], cfg, db, os.Stdout); err != nil {
+				logger.Error("keys command failed", "error", err)
+				os.Exit(1)
+			}
+			return
+		}
+		if len(os.Args) > 1 && os.Args[1] == "check" {
+			if err := runCheck(context.Background(), os.Args[2:], cfg, db, os.Stdout); err != nil {
+				logger.Error("check command failed", "error", err)
+				os.Exit(1)
+			}
+			return
+		}
+		if len(os.Args) > 1 && os.Args[1] == "token" {
+			if err := runToken(context.Background(), os.Args[2:], db, os.Stdout); err != nil {
+				logger.Error("token command failed", "error", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		// Listings and metrics can be read from a replica; it is not
+		// migrated, as it follows the primary's schema
+		if replica, ok := cfg.Database.Replica(); ok {
+			reader, err = database.Connect(context.Background(), replica, logger, queries)
+			if err != nil {
+				logger.Error("failed to connect to read replica", "error", err)
+				os.Exit(1)
+			}
+		}
+
+		stores = server.PostgresStores(db, reader, cfg)
+		stores.Queries = queries
+		keys, err = setupKeyRing(cfg.Auth)
+	}
+	if err != nil {
+		logger.Error("failed to load signing keys", "error", err)
+		os.Exit(1)
+	}
 
-	// Establish database connection with proper connection pooling
-	db, err := setupDatabase(cfg.Database)
+	// Background jobs share a single scheduler
+	sched := scheduler.New(logger, scheduler.WithJobTimeout(cfg.Database.JobTimeout))
+
+	// Cancelled as soon as shutdown begins so that requests waiting for
+	// capacity give up instead of holding the server open
+	shutdownCtx, beginShutdown := context.WithCancel(context.Background())
+	defer beginShutdown()
+
+	wsignd, err := server.New(shutdownCtx, cfg, stores, keys, logger, sched)
 	if err != nil {
-		logger.Error("failed to connect to database", "error", err)
+		logger.Error("failed to set up routes", "error", err)
 		os.Exit(1)
 	}
-	defer db.Close()
 
-	// Create HTTP server with timeouts and configuration
-	server := &http.Server{
-		Addr:         fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
-		Handler:      setupRouter(cfg, db, logger),
+	// Create HTTP server with timeouts and configuration; it serves every
+	// listen address
+	httpServer := &http.Server{
+		Handler:      wsignd.Handler,
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
 		IdleTimeout:  cfg.Server.IdleTimeout,
 	}
 
+	// Every address is bound before anything is served, so a server that
+	// cannot listen everywhere it was asked to does not start at all
+	listeners, err := listen.Listen(context.Background(), listen.Addresses(cfg.Server))
+	if err != nil {
+		logger.Error("failed to listen", "error", err)
+		os.Exit(1)
+	}
+	addresses := make([]string, 0, len(listeners))
+	for _, ln := range listeners {
+		addresses = append(addresses, ln.Addr().String())
+	}
+
+	sched.Start(context.Background())
+
 	// Start the server in a goroutine to allow for graceful shutdown
 	go func() {
 		logger.Info("starting server",
-			"host", cfg.Server.Host,
-			"port", cfg.Server.Port,
+			"version", version.Version,
+			"addresses", addresses,
 		)
 
-		var err error
-		if cfg.Server.TLSCert != "" && cfg.Server.TLSKey != "" {
-			err = server.ListenAndServeTLS(cfg.Server.TLSCert, cfg.Server.TLSKey)
-		} else {
-			err = server.ListenAndServe()
-		}
+		err := listen.Serve(httpServer, listeners, cfg.Server.TLSCert, cfg.Server.TLSKey)
 		if err != nil && err != http.ErrServerClosed {
 			logger.Error("server error", "error", err)
 			os.Exit(1)
@@ -76,73 +206,14 @@ func main() {
 	// Wait for interrupt signal
 	<-shutdown
 	logger.Info("shutting down server...")
+	beginShutdown()
 
-	// Create shutdown context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	// Attempt graceful shutdown
-	if err := server.Shutdown(ctx); err != nil {
-		logger.Error("server shutdown error", "error", err)
+	var closers []io.Closer
+	if reader != nil {
+		closers = append(closers, reader)
 	}
-
-	logger.Info("server stopped")
-}
-
-// setupDatabase creates a database connection pool with proper configuration
-func setupDatabase(cfg config.DatabaseConfig) (*sql.DB, error) {
-	// Build Postgres connection string with all parameters
-	connStr := fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Host,
-		cfg.Port,
-		cfg.User,
-		cfg.Password,
-		cfg.Name,
-		cfg.SSLMode,
-	)
-
-	// Open database connection
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		return nil, fmt.Errorf("error opening database: %w", err)
+	if db != nil {
+		closers = append(closers, db)
 	}
-
-	// Configure connection pool
-	db.SetMaxOpenConns(cfg.MaxOpenConns)
-	db.SetMaxIdleConns(cfg.MaxIdleConns)
-	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
-
-	// Verify connection is working
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := db.PingContext(ctx); err != nil {
-		return nil, fmt.Errorf("error connecting to database: %w", err)
-	}
-
-	return db, nil
-}
-
-// setupRouter creates and configures the HTTP router with all application routes
-func setupRouter(cfg *config.Config, db *sql.DB, logger *slog.Logger) http.Handler {
-	r := chi.NewRouter()
-
-	// Set up display service dependencies
-	repo := postgres.NewRepository(db)
-	publisher := &noopEventPublisher{} // TODO: Implement real event publisher
-	service := display.NewService(repo, publisher)
-
-	// Create and mount display handlers
-	displayHandler := displayhttp.NewHandler(service, logger)
-	r.Mount("/", displayhttp.NewRouter(displayHandler))
-
-	return r
-}
-
-// noopEventPublisher is a temporary implementation of display.EventPublisher
-type noopEventPublisher struct{}
-
-func (p *noopEventPublisher) Publish(ctx context.Context, event display.Event) error {
-	return nil
+	shutdownServer(cfg.Server.Shutdown, logger, httpServer, wsignd, sched, closers)
 }
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+	"github.com/wrale/wrale-signage/internal/wsignd/database"
+	"github.com/wrale/wrale-signage/internal/wsignd/migrations"
+)
+
+// checkState is the outcome of one environment check
+type checkState string
+
+const (
+	checkOK   checkState = "ok"
+	checkFail checkState = "FAIL"
+	checkSkip checkState = "skip"
+)
+
+// checkResult is one line of the environment check report
+type checkResult struct {
+	name   string
+	state  checkState
+	detail string
+	// hint tells an operator how to fix a failed check
+	hint string
+}
+
+// runSelfCheck implements "wsignd check" without arguments. It loads the
+// configuration and reaches every service the server needs, in the order
+// the server would, without migrating or listening. Checks that depend on
+// one that failed are skipped. It fails when any check does.
+func runSelfCheck(ctx context.Context, out io.Writer) error {
+	var results []checkResult
+	report := func(r checkResult) {
+		results = append(results, r)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		report(checkResult{name: "config", state: checkFail, detail: err.Error(),
+			hint: "fix the WSIGN_* environment variables; the error names the setting at fault"})
+		for _, name := range []string{"signing keys", "database", "read replica", "migrations"} {
+			report(checkResult{name: name, state: checkSkip, detail: "needs a valid configuration"})
+		}
+		return writeCheckReport(out, results)
+	}
+	report(checkResult{name: "config", state: checkOK, detail: "mode " + cfg.Mode})
+
+	if cfg.Demo() {
+		report(checkResult{name: "signing keys", state: checkSkip, detail: "demo mode generates an ephemeral key"})
+		for _, name := range []string{"database", "read replica", "migrations"} {
+			report(checkResult{name: name, state: checkSkip, detail: "demo mode has no database"})
+		}
+		return writeCheckReport(out, results)
+	}
+
+	report(checkSigningKeys(cfg.Auth))
+
+	// Unlike startup, an unreachable database is reported rather than
+	// waited for
+	quiet := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dbCfg := cfg.Database
+	dbCfg.ConnectAttempts = 1
+	db, err := database.Connect(ctx, dbCfg, quiet, nil)
+	if err != nil {
+		report(checkResult{name: "database", state: checkFail, detail: err.Error(),
+			hint: "check WSIGN_DB_URL or WSIGN_DB_HOST, WSIGN_DB_PORT, WSIGN_DB_USER and WSIGN_DB_PASSWORD, and that the database accepts connections from this host"})
+	} else {
+		defer db.Close()
+		report(checkResult{name: "database", state: checkOK, detail: "connected"})
+	}
+
+	if replica, ok := cfg.Database.Replica(); !ok {
+		report(checkResult{name: "read replica", state: checkSkip, detail: "not configured"})
+	} else {
+		replica.ConnectAttempts = 1
+		reader, err := database.Connect(ctx, replica, quiet, nil)
+		if err != nil {
+			report(checkResult{name: "read replica", state: checkFail, detail: err.Error(),
+				hint: "check WSIGN_DB_REPLICA_URL, or unset it to read from the primary"})
+		} else {
+			reader.Close()
+			report(checkResult{name: "read replica", state: checkOK, detail: "connected"})
+		}
+	}
+
+	if db == nil {
+		report(checkResult{name: "migrations", state: checkSkip, detail: "needs the database"})
+	} else {
+		report(checkMigrations(ctx, db, cfg.Database.MigrationLockTimeout))
+	}
+	return writeCheckReport(out, results)
+}
+
+// checkSigningKeys builds the key ring the server would sign tokens with
+func checkSigningKeys(cfg config.AuthConfig) checkResult {
+	keys, err := setupKeyRing(cfg)
+	if err != nil {
+		return checkResult{name: "signing keys", state: checkFail, detail: err.Error(),
+			hint: "set WSIGN_AUTH_SIGNING_KEYS to id:algorithm:key entries with base64 key material, or WSIGN_AUTH_TOKEN_KEY to a shared secret"}
+	}
+	primary := keys.Primary()
+	return checkResult{name: "signing keys", state: checkOK,
+		detail: fmt.Sprintf("%d key(s), primary %s (%s)", len(keys.Keys()), primary.ID, primary.Algorithm)}
+}
+
+// checkMigrations compares the migrations applied to db with those shipped
+// with this binary
+func checkMigrations(ctx context.Context, db *sql.DB, lockTimeout time.Duration) checkResult {
+	statuses, err := migrations.NewManager(db, lockTimeout).Status(ctx)
+	if err != nil {
+		return checkResult{name: "migrations", state: checkFail, detail: err.Error(),
+			hint: "check that the database user can read the schema_migrations table"}
+	}
+
+	var pending, unknown []string
+	for _, s := range statuses {
+		switch {
+		case s.Unknown:
+			unknown = append(unknown, fmt.Sprintf("%03d", s.Version))
+		case s.AppliedAt == nil:
+			pending = append(pending, fmt.Sprintf("%03d", s.Version))
+		}
+	}
+	switch {
+	case len(unknown) > 0:
+		return checkResult{name: "migrations", state: checkFail,
+			detail: fmt.Sprintf("database has migrations this binary does not know: %s", strings.Join(unknown, ", ")),
+			hint:   "the database was migrated by a newer wsignd; deploy that version or later"}
+	case len(pending) > 0:
+		return checkResult{name: "migrations", state: checkFail,
+			detail: fmt.Sprintf("%d pending: %s", len(pending), strings.Join(pending, ", ")),
+			hint:   "migrations are applied when wsignd starts; start one instance to apply them before rolling out the rest"}
+	}
+	return checkResult{name: "migrations", state: checkOK, detail: fmt.Sprintf("%d applied, none pending", len(statuses))}
+}
+
+// writeCheckReport prints results with the hint under each failure, and
+// returns an error naming how many checks failed
+func writeCheckReport(out io.Writer, results []checkResult) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	failed := 0
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.state, r.name, r.detail)
+		if r.state == checkFail {
+			failed++
+			if r.hint != "" {
+				fmt.Fprintf(tw, "\t\thint: %s\n", r.hint)
+			}
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(results))
+	}
+	return nil
+}
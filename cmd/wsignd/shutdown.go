@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+	"github.com/wrale/wrale-signage/internal/wsignd/scheduler"
+	"github.com/wrale/wrale-signage/internal/wsignd/server"
+)
+
+// shutdownServer stops the server in order, logging what was in flight at
+// each stage:
+//
+//  1. the listener is closed so no new connections are accepted
+//  2. control sockets are told to spread their reconnects over
+//     ReconnectSpread, closed with 1001 and given HubTimeout to go
+//  3. in-flight requests are given RequestTimeout to finish; any still
+//     running are counted as ungraceful terminations and cut off
+//  4. background jobs are stopped and stores are closed
+//
+// Stores are closed last because every earlier stage may still use them.
+func shutdownServer(cfg config.ShutdownConfig, logger *slog.Logger, httpServer *http.Server, wsignd *server.App, sched *scheduler.Scheduler, stores []io.Closer) {
+	start := time.Now()
+
+	// Shutdown closes the listeners at once and then waits for idle
+	// connections. Hijacked control sockets are not waited for, which is
+	// why the hub is drained separately.
+	serverCtx, stopWaiting := context.WithCancel(context.Background())
+	defer stopWaiting()
+	serverDone := make(chan error, 1)
+	go func() { serverDone <- httpServer.Shutdown(serverCtx) }()
+	logger.Info("shutdown: stopped accepting connections",
+		"requestsInFlight", wsignd.Requests.Begin(),
+	)
+
+	stage := time.Now()
+	hubCtx, cancel := context.WithTimeout(context.Background(), cfg.HubTimeout)
+	closed, remaining := wsignd.Displays.DrainConnections(hubCtx)
+	cancel()
+	logger.Info("shutdown: drained control sockets",
+		"closed", closed,
+		"remaining", remaining,
+		"duration", time.Since(stage),
+	)
+
+	stage = time.Now()
+	requestCtx, cancel := context.WithTimeout(context.Background(), cfg.RequestTimeout)
+	defer cancel()
+	abandoned := wsignd.Requests.Wait(requestCtx)
+	select {
+	case err := <-serverDone:
+		if err != nil {
+			logger.Error("server shutdown error", "error", err)
+		}
+	case <-requestCtx.Done():
+		stopWaiting()
+		<-serverDone
+	}
+	if abandoned > 0 {
+		logger.Warn("shutdown: requests still running at timeout were terminated",
+			"requests", abandoned,
+			"timeout", cfg.RequestTimeout,
+		)
+		if err := httpServer.Close(); err != nil {
+			logger.Error("server close error", "error", err)
+		}
+	}
+	logger.Info("shutdown: drained requests",
+		"ungracefulTerminations", abandoned,
+		"duration", time.Since(stage),
+	)
+
+	stage = time.Now()
+	sched.Stop()
+	for _, store := range stores {
+		if err := store.Close(); err != nil {
+			logger.Error("failed to close store", "error", err)
+		}
+	}
+	logger.Info("shutdown: closed stores",
+		"stores", len(stores),
+		"duration", time.Since(stage),
+	)
+
+	logger.Info("server stopped", "drainDuration", time.Since(start))
+}
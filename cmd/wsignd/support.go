@@ -0,0 +1,136 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/wrale/wrale-signage/internal/version"
+	"github.com/wrale/wrale-signage/internal/wsignd/admin"
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+	"github.com/wrale/wrale-signage/internal/wsignd/database"
+	"github.com/wrale/wrale-signage/internal/wsignd/migrations"
+)
+
+// supportBundleUsage describes the "support-bundle" subcommand
+const supportBundleUsage = "usage: wsignd support-bundle [--output FILE]"
+
+// supportVersion is the build information written to a support bundle
+type supportVersion struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+	Platform  string `json:"platform"`
+}
+
+// supportMigrations is the migration status written to a support bundle.
+// Error is set instead when the status could not be read, which is itself
+// worth reporting.
+type supportMigrations struct {
+	Migrations []migrations.Status `json:"migrations,omitempty"`
+	Error      string              `json:"error,omitempty"`
+}
+
+// runSupportBundle implements the "support-bundle" subcommand. It writes a
+// gzipped tarball holding the redacted effective configuration, the
+// migration status and version information. Nothing is migrated, so it can
+// be run against a database the server refuses to start on.
+func runSupportBundle(ctx context.Context, args []string, cfg *config.Config, out io.Writer) error {
+	fs := flag.NewFlagSet("support-bundle", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	output := fs.String("output", "", "file to write, default wsignd-support-TIMESTAMP.tar.gz")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w\n%s", err, supportBundleUsage)
+	}
+	now := time.Now().UTC()
+	if *output == "" {
+		*output = fmt.Sprintf("wsignd-support-%s.tar.gz", now.Format("20060102T150405Z"))
+	}
+
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{"version.json", supportVersion{
+			Version:   version.Version,
+			Commit:    version.Commit,
+			BuildDate: version.BuildDate,
+			GoVersion: runtime.Version(),
+			Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		}},
+		{"config.json", admin.EffectiveConfig(cfg, version.Version)},
+		{"migrations.json", migrationStatus(ctx, cfg)},
+	}
+
+	f, err := os.OpenFile(*output, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("error creating support bundle: %w", err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, file := range files {
+		data, err := json.MarshalIndent(file.v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding %s: %w", file.name, err)
+		}
+		data = append(data, '\n')
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    file.name,
+			Mode:    0o600,
+			Size:    int64(len(data)),
+			ModTime: now,
+		}); err != nil {
+			return fmt.Errorf("error writing support bundle: %w", err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("error writing support bundle: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("error writing support bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("error writing support bundle: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error writing support bundle: %w", err)
+	}
+
+	fmt.Fprintf(out, "Wrote %s\n", *output)
+	return nil
+}
+
+// migrationStatus reads the migration status, recording why it could not
+// be read rather than failing the bundle
+func migrationStatus(ctx context.Context, cfg *config.Config) supportMigrations {
+	if cfg.Demo() {
+		return supportMigrations{Error: "demo mode has no database"}
+	}
+
+	// A support bundle reports an unreachable database rather than waiting
+	// for it
+	dbCfg := cfg.Database
+	dbCfg.ConnectAttempts = 1
+	db, err := database.Connect(ctx, dbCfg, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	if err != nil {
+		return supportMigrations{Error: err.Error()}
+	}
+	defer db.Close()
+
+	statuses, err := migrations.NewManager(db, cfg.Database.MigrationLockTimeout).Status(ctx)
+	if err != nil {
+		return supportMigrations{Error: err.Error()}
+	}
+	return supportMigrations{Migrations: statuses}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+	operatorpostgres "github.com/wrale/wrale-signage/internal/wsignd/operator/postgres"
+)
+
+// tokenUsage describes the "token" subcommand
+const tokenUsage = "usage: wsignd token create --operator NAME --scopes SCOPE[,SCOPE...] [--name NAME] [--expires DURATION]"
+
+// runToken implements the "token" subcommand. It writes straight to the
+// database so that the first operator token can be created before anyone
+// is able to call the API.
+func runToken(ctx context.Context, args []string, db *sql.DB, out io.Writer) error {
+	if len(args) == 0 || args[0] != "create" {
+		return fmt.Errorf(tokenUsage)
+	}
+
+	fs := flag.NewFlagSet("token create", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	operatorName := fs.String("operator", "", "operator the token acts for, created if missing")
+	scopeList := fs.String("scopes", "", "comma separated scopes: "+scopeNames())
+	name := fs.String("name", "", "description of what the token is for")
+	expires := fs.Duration("expires", 0, "how long the token is valid; 0 never expires")
+	if err := fs.Parse(args[1:]); err != nil {
+		return fmt.Errorf("%w\n%s", err, tokenUsage)
+	}
+	if *operatorName == "" {
+		return fmt.Errorf("--operator is required\n%s", tokenUsage)
+	}
+	scopes, err := operator.ParseScopes(*scopeList)
+	if err != nil {
+		return fmt.Errorf("%w\n%s", err, tokenUsage)
+	}
+
+	return createOperatorToken(ctx, operator.NewService(operatorpostgres.NewRepository(db)), *operatorName, *name, scopes, *expires, out)
+}
+
+// createOperatorToken issues a token and prints it once; only its hash is
+// kept by the server
+func createOperatorToken(ctx context.Context, svc operator.Service, operatorName, name string, scopes []operator.Scope, ttl time.Duration, out io.Writer) error {
+	raw, token, err := svc.CreateToken(ctx, operatorName, name, scopes, ttl)
+	if err != nil {
+		return fmt.Errorf("error creating token: %w", err)
+	}
+
+	expires := "never"
+	if token.ExpiresAt != nil {
+		expires = token.ExpiresAt.Format(time.RFC3339)
+	}
+	fmt.Fprintf(out, "Created token %s for operator %q\n", token.ID, operatorName)
+	fmt.Fprintf(out, "Scopes:  %s\n", strings.Join(scopeStrings(scopes), ","))
+	fmt.Fprintf(out, "Expires: %s\n\n", expires)
+	fmt.Fprintf(out, "%s\n\n", raw)
+	fmt.Fprintf(out, "The token is not shown again. Use it with:\n")
+	fmt.Fprintf(out, "  wsignctl config set-context CONTEXT --token %s\n", raw)
+	return nil
+}
+
+func scopeNames() string {
+	return strings.Join(scopeStrings(operator.AllScopes), ", ")
+}
+
+func scopeStrings(scopes []operator.Scope) []string {
+	out := make([]string, len(scopes))
+	for i, s := range scopes {
+		out[i] = string(s)
+	}
+	return out
+}
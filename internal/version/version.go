@@ -0,0 +1,14 @@
+// Package version holds build information shared by wsignd and wsignctl. The
+// values are replaced at build time with
+//
+//	-ldflags "-X github.com/wrale/wrale-signage/internal/version.Version=1.2.3"
+package version
+
+var (
+	// Version is the release version
+	Version = "dev"
+	// Commit is the source revision
+	Commit = "none"
+	// BuildDate is when the binary was built
+	BuildDate = "unknown"
+)
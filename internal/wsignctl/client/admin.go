@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+)
+
+// GetEffectiveConfig retrieves the configuration the server runs with,
+// secrets redacted. It requires an admin token.
+func (c *Client) GetEffectiveConfig(ctx context.Context) (*v1alpha1.EffectiveConfig, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, "/api/v1alpha1/admin/config", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server configuration: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var cfg v1alpha1.EffectiveConfig
+	if err := decodeResponse(resp, &cfg); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return &cfg, closeBody(resp.Body, nil)
+}
+
+// GetPollingPolicy retrieves the polling hints the server hands out to
+// displays. It requires an admin token.
+func (c *Client) GetPollingPolicy(ctx context.Context) (*v1alpha1.PollingPolicy, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, "/api/v1alpha1/admin/polling", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get polling policy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var policy v1alpha1.PollingPolicy
+	if err := decodeResponse(resp, &policy); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return &policy, closeBody(resp.Body, nil)
+}
+
+// UpdatePollingPolicy replaces the polling policy of the server the client
+// talks to and returns the policy now in effect. It requires an admin token.
+func (c *Client) UpdatePollingPolicy(ctx context.Context, policy *v1alpha1.PollingPolicy) (*v1alpha1.PollingPolicy, error) {
+	resp, err := c.doRequest(ctx, http.MethodPut, "/api/v1alpha1/admin/polling", policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update polling policy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var updated v1alpha1.PollingPolicy
+	if err := decodeResponse(resp, &updated); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return &updated, closeBody(resp.Body, nil)
+}
+
+// GetMaintenance reports whether the server is in maintenance mode. It
+// requires an admin token.
+func (c *Client) GetMaintenance(ctx context.Context) (*v1alpha1.MaintenanceStatus, error) {
+	return c.maintenanceRequest(ctx, http.MethodGet, nil, "failed to get maintenance mode")
+}
+
+// StartMaintenance shows every display the notice described by req until
+// maintenance is ended or req.Until passes. It requires an admin token.
+func (c *Client) StartMaintenance(ctx context.Context, req *v1alpha1.MaintenanceRequest) (*v1alpha1.MaintenanceStatus, error) {
+	req.Enabled = true
+	return c.maintenanceRequest(ctx, http.MethodPost, req, "failed to start maintenance mode")
+}
+
+// EndMaintenance sends every display its assigned content again. It
+// requires an admin token.
+func (c *Client) EndMaintenance(ctx context.Context) (*v1alpha1.MaintenanceStatus, error) {
+	return c.maintenanceRequest(ctx, http.MethodDelete, nil, "failed to end maintenance mode")
+}
+
+// maintenanceRequest sends a request to the maintenance endpoint and
+// decodes the status it returns
+func (c *Client) maintenanceRequest(ctx context.Context, method string, body interface{}, failure string) (*v1alpha1.MaintenanceStatus, error) {
+	resp, err := c.doRequest(ctx, method, "/api/v1alpha1/admin/maintenance", body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", failure, err)
+	}
+	defer resp.Body.Close()
+
+	var status v1alpha1.MaintenanceStatus
+	if err := decodeResponse(resp, &status); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return &status, closeBody(resp.Body, nil)
+}
+
+// GetEventRetention retrieves how content events are stored and pruned. It
+// requires an admin token.
+func (c *Client) GetEventRetention(ctx context.Context) (*v1alpha1.EventRetentionStatus, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, "/api/v1alpha1/admin/retention", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event retention: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var status v1alpha1.EventRetentionStatus
+	if err := decodeResponse(resp, &status); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return &status, closeBody(resp.Body, nil)
+}
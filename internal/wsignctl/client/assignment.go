@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+)
+
+// CreateContentAssignment creates an assignment and returns it as stored,
+// including the ID the server gave it
+func (c *Client) CreateContentAssignment(ctx context.Context, a *v1alpha1.ContentAssignment) (*v1alpha1.ContentAssignment, error) {
+	resp, err := c.doRequest(ctx, http.MethodPost, "/api/v1alpha1/assignments", a)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create assignment: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var created v1alpha1.ContentAssignment
+	if err := decodeResponse(resp, &created); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return &created, closeBody(resp.Body, nil)
+}
+
+// ListContentAssignments retrieves the assignments created from source whose
+// selectors have the values set in selector. Empty arguments match anything.
+func (c *Client) ListContentAssignments(ctx context.Context, source string, selector v1alpha1.DisplaySelector) ([]v1alpha1.ContentAssignment, error) {
+	u := url.Values{}
+	if source != "" {
+		u.Set("source", source)
+	}
+	if selector.SiteID != "" {
+		u.Set("siteId", selector.SiteID)
+	}
+	if selector.Zone != "" {
+		u.Set("zone", selector.Zone)
+	}
+	if selector.Position != "" {
+		u.Set("position", selector.Position)
+	}
+	if selector.DisplayRef != "" {
+		u.Set("displayRef", selector.DisplayRef)
+	}
+	for _, pair := range v1alpha1.FormatMatchProperties(selector.MatchProperties) {
+		u.Add(v1alpha1.MatchPropertyParam, pair)
+	}
+
+	path := "/api/v1alpha1/assignments"
+	if len(u) > 0 {
+		path += "?" + u.Encode()
+	}
+
+	resp, err := c.doRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list assignments: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var assignments []v1alpha1.ContentAssignment
+	if err := decodeResponse(resp, &assignments); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return assignments, closeBody(resp.Body, nil)
+}
+
+// DeleteContentAssignment removes an assignment by ID
+func (c *Client) DeleteContentAssignment(ctx context.Context, id string) error {
+	resp, err := c.doRequest(ctx, http.MethodDelete, "/api/v1alpha1/assignments/"+url.PathEscape(id), nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete assignment: %w", err)
+	}
+	return closeBody(resp.Body, handleResponse(resp))
+}
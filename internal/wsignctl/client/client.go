@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/wrale/wrale-signage/api/types/v1alpha1"
@@ -24,6 +25,13 @@ type Client struct {
 	httpClient *http.Client
 	// token is the authentication token
 	token string
+
+	// discovery caches the server's discovery document once fetched
+	discovery *v1alpha1.APIDiscovery
+	// discoveryCache is a file the discovery document is kept in between runs
+	discoveryCache string
+	// discoveryTTL is how long a cached discovery document is trusted
+	discoveryTTL time.Duration
 }
 
 // ClientOption configures a Client
@@ -78,12 +86,28 @@ func NewClient(baseURL string, options ...ClientOption) (*Client, error) {
 
 // doRequest performs an HTTP request with automatic error handling
 func (c *Client) doRequest(ctx context.Context, method, pathStr string, body interface{}) (*http.Response, error) {
+	return c.doRequestWith(ctx, c.httpClient, method, pathStr, body)
+}
+
+// doStreamRequest performs a request like doRequest but without the
+// client's overall timeout, for responses that are read for as long as the
+// server keeps sending. ctx still bounds it.
+func (c *Client) doStreamRequest(ctx context.Context, method, pathStr string) (*http.Response, error) {
+	streaming := *c.httpClient
+	streaming.Timeout = 0
+	return c.doRequestWith(ctx, &streaming, method, pathStr, nil)
+}
+
+// doRequestWith performs a request using httpClient
+func (c *Client) doRequestWith(ctx context.Context, httpClient *http.Client, method, pathStr string, body interface{}) (*http.Response, error) {
 	// Build full URL
 	u, err := url.Parse(c.baseURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid base URL: %w", err)
 	}
+	pathStr, rawQuery, _ := strings.Cut(pathStr, "?")
 	u.Path = path.Join(u.Path, pathStr)
+	u.RawQuery = rawQuery
 
 	// Create request body if needed
 	var bodyReader io.Reader
@@ -108,7 +132,7 @@ func (c *Client) doRequest(ctx context.Context, method, pathStr string, body int
 	}
 
 	// Perform request
-	resp, err := c.httpClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("error performing request: %w", err)
 	}
@@ -116,11 +140,14 @@ func (c *Client) doRequest(ctx context.Context, method, pathStr string, body int
 	// Check for API errors
 	if resp.StatusCode >= 400 {
 		defer resp.Body.Close()
-		var apiErr v1alpha1.Error
+		var apiErr struct {
+			v1alpha1.Error
+			Details json.RawMessage `json:"details"`
+		}
 		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil {
-			return nil, fmt.Errorf("HTTP %d: unable to decode error response", resp.StatusCode)
+			return nil, &APIError{StatusCode: resp.StatusCode, Message: "unable to decode error response"}
 		}
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, apiErr.Message)
+		return nil, &APIError{StatusCode: resp.StatusCode, Code: apiErr.Code, Message: apiErr.Message, Details: apiErr.Details}
 	}
 
 	return resp, nil
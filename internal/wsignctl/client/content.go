@@ -3,7 +3,11 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/wrale/wrale-signage/api/types/v1alpha1"
 )
@@ -11,50 +15,236 @@ import (
 // AddContentSource creates a new content source in the system. It takes a complete ContentSource
 // object that specifies all required fields including name, URL, and content type.
 // The server will validate the input and ensure the name is unique before creating
-// the content source.
-func (c *Client) AddContentSource(ctx context.Context, source *v1alpha1.ContentSource) error {
-	resp, err := c.doRequest(ctx, "POST", "/api/v1alpha1/content", source)
+// the content source. The server also fetches the content; when strict is true a
+// failed fetch rejects the source, otherwise the failure is recorded on its status.
+// Names are case-insensitive; when the name is taken the error is a
+// *ContentSourceExistsError carrying the existing source.
+func (c *Client) AddContentSource(ctx context.Context, source *v1alpha1.ContentSource, strict bool) (*v1alpha1.ContentSource, error) {
+	path := "/api/v1alpha1/content"
+	if strict {
+		path += "?strict=true"
+	}
+	resp, err := c.doRequest(ctx, "POST", path, source)
 	if err != nil {
-		return err
+		return nil, existingSourceError(err)
 	}
 	defer resp.Body.Close()
-	return nil
+
+	var created v1alpha1.ContentSource
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &created, nil
+}
+
+// ContentSourceExistsError is returned by AddContentSource when a source
+// with the same name, ignoring case, already exists
+type ContentSourceExistsError struct {
+	// Existing is the source the server already holds
+	Existing *v1alpha1.ContentSource
+	// Err is the API error the server answered with
+	Err error
+}
+
+func (e *ContentSourceExistsError) Error() string {
+	return fmt.Sprintf("content source %q already exists", e.Existing.Name)
+}
+
+func (e *ContentSourceExistsError) Unwrap() error {
+	return e.Err
+}
+
+// existingSourceError turns a conflict carrying the existing source into a
+// *ContentSourceExistsError, returning other errors unchanged
+func existingSourceError(err error) error {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict || len(apiErr.Details) == 0 {
+		return err
+	}
+	var existing v1alpha1.ContentSource
+	if json.Unmarshal(apiErr.Details, &existing) != nil || existing.Name == "" {
+		return err
+	}
+	return &ContentSourceExistsError{Existing: &existing, Err: err}
 }
 
 // UpdateContentSource updates an existing content source identified by name. The update
 // parameter specifies which fields to modify - only non-nil fields will be updated.
-// This allows for partial updates without affecting other fields.
-func (c *Client) UpdateContentSource(ctx context.Context, name string, update *v1alpha1.ContentSourceUpdate) error {
-	resp, err := c.doRequest(ctx, "PATCH", fmt.Sprintf("/api/v1alpha1/content/%s", name), update)
+// This allows for partial updates without affecting other fields. The content is
+// revalidated and strict has the same meaning as for AddContentSource. When
+// update.Notify is set the result reports how many displays were told to reload.
+// When update.Version is set the update fails with an error matching
+// IsVersionMismatch if the source has changed since that version.
+func (c *Client) UpdateContentSource(ctx context.Context, name string, update *v1alpha1.ContentSourceUpdate, strict bool) (*v1alpha1.ContentSourceUpdateResult, error) {
+	path := fmt.Sprintf("/api/v1alpha1/content/%s", name)
+	if strict {
+		path += "?strict=true"
+	}
+	resp, err := c.doRequest(ctx, "PATCH", path, update)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result v1alpha1.ContentSourceUpdateResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// ValidateContentSource asks the server to validate a content source now and
+// returns the source with its refreshed validation status.
+func (c *Client) ValidateContentSource(ctx context.Context, name string) (*v1alpha1.ContentSource, error) {
+	resp, err := c.doRequest(ctx, "POST", fmt.Sprintf("/api/v1alpha1/content/%s/validate", name), nil)
+	if err != nil {
+		return nil, err
 	}
 	defer resp.Body.Close()
-	return nil
+
+	var source v1alpha1.ContentSource
+	if err := json.NewDecoder(resp.Body).Decode(&source); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &source, nil
 }
 
-// RemoveContentSource deletes a content source from the system. If force is false,
-// the operation will fail if any redirect rules reference this content source.
-// Setting force to true will delete the content source and invalidate any
-// referring rules.
-func (c *Client) RemoveContentSource(ctx context.Context, name string, force bool) error {
+// PreviewContentSource asks the server what a display would be sent for a
+// content source: the URL after allowed-path checks, how assignments
+// resolve at the requested time and, when req.Probe is set, what fetching
+// the URL observes. Probes are rate limited per source by the server.
+func (c *Client) PreviewContentSource(ctx context.Context, name string, req *v1alpha1.ContentPreviewRequest) (*v1alpha1.ContentPreview, error) {
+	resp, err := c.doRequest(ctx, http.MethodPost, "/api/v1alpha1/content/"+url.PathEscape(name)+"/preview", req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var preview v1alpha1.ContentPreview
+	if err := json.NewDecoder(resp.Body).Decode(&preview); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &preview, nil
+}
+
+// RemoveContentSource deletes a content source from the system. If force is
+// false the removal fails with a *ContentSourceInUseError while content
+// assignments are made from the source. Setting force to true removes those
+// assignments along with it; the result lists them.
+func (c *Client) RemoveContentSource(ctx context.Context, name string, force bool) (*v1alpha1.ContentSourceDeletion, error) {
 	path := fmt.Sprintf("/api/v1alpha1/content/%s", name)
 	if force {
 		path += "?force=true"
 	}
 	resp, err := c.doRequest(ctx, "DELETE", path, nil)
 	if err != nil {
-		return err
+		return nil, sourceInUseError(name, err)
 	}
 	defer resp.Body.Close()
-	return nil
+
+	var deletion v1alpha1.ContentSourceDeletion
+	if err := json.NewDecoder(resp.Body).Decode(&deletion); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &deletion, nil
+}
+
+// ContentSourceInUseError is returned by RemoveContentSource when resources
+// still refer to the source
+type ContentSourceInUseError struct {
+	// Name is the source that was not removed
+	Name string
+	// References are the resources made from the source
+	References []v1alpha1.ResourceReference
+	// Err is the API error the server answered with
+	Err error
+}
+
+func (e *ContentSourceInUseError) Error() string {
+	return fmt.Sprintf("content source %q is used by %d resource(s)", e.Name, len(e.References))
 }
 
-// ListContentSources retrieves all content sources in the system. The results can be
-// filtered server-side by passing query parameters, though this basic implementation
-// returns all sources.
+func (e *ContentSourceInUseError) Unwrap() error {
+	return e.Err
+}
+
+// sourceInUseError turns a conflict listing the resources made from a
+// source into a *ContentSourceInUseError, returning other errors unchanged
+func sourceInUseError(name string, err error) error {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "SOURCE_IN_USE" {
+		return err
+	}
+	var refs []v1alpha1.ResourceReference
+	if json.Unmarshal(apiErr.Details, &refs) != nil {
+		return err
+	}
+	return &ContentSourceInUseError{Name: name, References: refs, Err: err}
+}
+
+// ListContentSources retrieves all content sources in the system.
 func (c *Client) ListContentSources(ctx context.Context) ([]v1alpha1.ContentSource, error) {
-	resp, err := c.doRequest(ctx, "GET", "/api/v1alpha1/content", nil)
+	return c.listContentSources(ctx, nil)
+}
+
+// ListContentSourcesByType retrieves the content sources of one type. The
+// filtering happens on the server.
+func (c *Client) ListContentSourcesByType(ctx context.Context, contentType string) ([]v1alpha1.ContentSource, error) {
+	return c.listContentSources(ctx, url.Values{"type": {contentType}})
+}
+
+// ListContentSourcesByTags retrieves the content sources carrying every one
+// of tags. The filtering happens on the server.
+func (c *Client) ListContentSourcesByTags(ctx context.Context, tags []string) ([]v1alpha1.ContentSource, error) {
+	return c.listContentSources(ctx, url.Values{"tag": tags})
+}
+
+// GetContentSources retrieves several content sources by name in a single
+// request. The result follows the order of names; if any name does not
+// exist an error listing the missing names is returned.
+func (c *Client) GetContentSources(ctx context.Context, names []string) ([]v1alpha1.ContentSource, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	found, err := c.listContentSources(ctx, url.Values{"name": names})
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]v1alpha1.ContentSource, len(found))
+	for _, source := range found {
+		byName[source.Name] = source
+	}
+	sources := make([]v1alpha1.ContentSource, 0, len(names))
+	var missing []string
+	for _, name := range names {
+		source, ok := byName[name]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		sources = append(sources, source)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("content sources not found: %s", strings.Join(missing, ", "))
+	}
+
+	return sources, nil
+}
+
+// listContentSources fetches the content source list with optional filters
+func (c *Client) listContentSources(ctx context.Context, query url.Values) ([]v1alpha1.ContentSource, error) {
+	path := "/api/v1alpha1/content"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	resp, err := c.doRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -84,3 +274,54 @@ func (c *Client) GetContentSource(ctx context.Context, name string) (*v1alpha1.C
 
 	return &source, nil
 }
+
+// GetContentHealth reports how the content at contentURL is doing on
+// displays. The URL must belong to a content source.
+func (c *Client) GetContentHealth(ctx context.Context, contentURL string) (*v1alpha1.ContentHealth, error) {
+	resp, err := c.doRequest(ctx, "GET", "/api/v1alpha1/content/health?"+url.Values{"url": {contentURL}}.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var health v1alpha1.ContentHealth
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &health, nil
+}
+
+// GetContentMetrics retrieves the load and error counts displays reported
+// for the content at contentURL. The URL must belong to a content source.
+func (c *Client) GetContentMetrics(ctx context.Context, contentURL string) (*v1alpha1.ContentMetrics, error) {
+	resp, err := c.doRequest(ctx, "GET", "/api/v1alpha1/content/metrics?"+url.Values{"url": {contentURL}}.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var metrics v1alpha1.ContentMetrics
+	if err := json.NewDecoder(resp.Body).Decode(&metrics); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &metrics, nil
+}
+
+// GetPrefetchStatus retrieves how far the displays assigned contentURL have
+// got downloading it
+func (c *Client) GetPrefetchStatus(ctx context.Context, contentURL string) (*v1alpha1.PrefetchStatus, error) {
+	resp, err := c.doRequest(ctx, "GET", "/api/v1alpha1/content/prefetch-status?"+url.Values{"url": {contentURL}}.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var status v1alpha1.PrefetchStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &status, nil
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+)
+
+// WithDiscoveryCache keeps the server's discovery document in path and
+// reuses it for ttl, so that capability checks do not cost a request on
+// every command
+func WithDiscoveryCache(path string, ttl time.Duration) ClientOption {
+	return func(c *Client) {
+		c.discoveryCache = path
+		c.discoveryTTL = ttl
+	}
+}
+
+// Discover returns the server's discovery document, describing its version
+// and the optional features it supports. Servers that predate discovery
+// return an error.
+func (c *Client) Discover(ctx context.Context) (*v1alpha1.APIDiscovery, error) {
+	if c.discovery != nil {
+		return c.discovery, nil
+	}
+	if doc := c.readDiscoveryCache(); doc != nil {
+		c.discovery = doc
+		return doc, nil
+	}
+
+	resp, err := c.doRequest(ctx, "GET", "/api/v1alpha1", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var doc v1alpha1.APIDiscovery
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	c.discovery = &doc
+	c.writeDiscoveryCache(&doc)
+	return &doc, nil
+}
+
+// ServerVersion returns the build information of the server. Servers that
+// predate the version endpoint only report their version, taken from the
+// discovery document.
+func (c *Client) ServerVersion(ctx context.Context) (*v1alpha1.VersionInfo, error) {
+	resp, err := c.doRequest(ctx, "GET", "/api/v1alpha1/version", nil)
+	if IsNotFound(err) {
+		doc, err := c.Discover(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &v1alpha1.VersionInfo{Version: doc.ServerVersion}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var info v1alpha1.VersionInfo
+	if err := decodeResponse(resp, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// SupportsFeature reports whether the server advertises feature. When the
+// server cannot describe itself the error explains why.
+func (c *Client) SupportsFeature(ctx context.Context, feature string) (bool, error) {
+	doc, err := c.Discover(ctx)
+	if err != nil {
+		return false, err
+	}
+	return doc.HasFeature(feature), nil
+}
+
+// readDiscoveryCache returns the cached document if it is still fresh
+func (c *Client) readDiscoveryCache() *v1alpha1.APIDiscovery {
+	if c.discoveryCache == "" {
+		return nil
+	}
+	info, err := os.Stat(c.discoveryCache)
+	if err != nil || time.Since(info.ModTime()) > c.discoveryTTL {
+		return nil
+	}
+	data, err := os.ReadFile(c.discoveryCache)
+	if err != nil {
+		return nil
+	}
+	var doc v1alpha1.APIDiscovery
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil
+	}
+	return &doc
+}
+
+// writeDiscoveryCache stores the document; a cache that cannot be written
+// only costs a request next time
+func (c *Client) writeDiscoveryCache(doc *v1alpha1.APIDiscovery) {
+	if c.discoveryCache == "" {
+		return
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.discoveryCache), 0750); err != nil {
+		return
+	}
+	_ = os.WriteFile(c.discoveryCache, data, 0600)
+}
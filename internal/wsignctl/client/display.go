@@ -1,11 +1,15 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 
 	"github.com/wrale/wrale-signage/api/types/v1alpha1"
 )
@@ -23,7 +27,18 @@ func closeBody(body io.ReadCloser, err error) error {
 
 // GetDisplay retrieves a display by ID
 func (c *Client) GetDisplay(ctx context.Context, id string) (*v1alpha1.Display, error) {
-	resp, err := c.doRequest(ctx, http.MethodGet, "/api/v1alpha1/displays/"+id, nil)
+	return c.GetDisplayIn(ctx, id, "")
+}
+
+// GetDisplayIn retrieves a display by ID with its times in the zone tz: an
+// IANA name, or "local" for the display's own zone. An empty tz leaves
+// them in UTC.
+func (c *Client) GetDisplayIn(ctx context.Context, id, tz string) (*v1alpha1.Display, error) {
+	path := "/api/v1alpha1/displays/" + id
+	if tz != "" {
+		path += "?" + url.Values{"tz": {tz}}.Encode()
+	}
+	resp, err := c.doRequest(ctx, http.MethodGet, path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get display: %w", err)
 	}
@@ -37,8 +52,116 @@ func (c *Client) GetDisplay(ctx context.Context, id string) (*v1alpha1.Display,
 	return &display, closeBody(resp.Body, nil)
 }
 
-// ListDisplays retrieves displays matching the given selector
+// GetDisplayContentHistory retrieves up to limit of a display's most recent
+// content transitions, newest first. A limit of zero uses the server default.
+func (c *Client) GetDisplayContentHistory(ctx context.Context, name string, limit int) (*v1alpha1.ContentHistory, error) {
+	path := "/api/v1alpha1/displays/" + name + "/content-history"
+	if limit > 0 {
+		path += fmt.Sprintf("?limit=%d", limit)
+	}
+
+	resp, err := c.doRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get content history: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var history v1alpha1.ContentHistory
+	if err := decodeResponse(resp, &history); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return &history, closeBody(resp.Body, nil)
+}
+
+// GetDisplayStateCounts counts the displays at each site by state. An empty
+// siteID covers every site.
+func (c *Client) GetDisplayStateCounts(ctx context.Context, siteID string) (*v1alpha1.DisplayStateCounts, error) {
+	u := url.Values{}
+	if siteID != "" {
+		u.Set("site", siteID)
+	}
+
+	resp, err := c.doRequest(ctx, http.MethodGet, "/api/v1alpha1/displays/stats?"+u.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state counts: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var counts v1alpha1.DisplayStateCounts
+	if err := decodeResponse(resp, &counts); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return &counts, closeBody(resp.Body, nil)
+}
+
+// GetDisplayStateHistory retrieves the periodic display state snapshots,
+// oldest first. An empty siteID covers every site; since is a duration such
+// as 7d or an RFC 3339 time, and empty uses the server default.
+func (c *Client) GetDisplayStateHistory(ctx context.Context, siteID, since string) (*v1alpha1.DisplayStateHistory, error) {
+	u := url.Values{}
+	if siteID != "" {
+		u.Set("site", siteID)
+	}
+	if since != "" {
+		u.Set("since", since)
+	}
+
+	resp, err := c.doRequest(ctx, http.MethodGet, "/api/v1alpha1/displays/stats/history?"+u.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state history: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var history v1alpha1.DisplayStateHistory
+	if err := decodeResponse(resp, &history); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return &history, closeBody(resp.Body, nil)
+}
+
+// ListDisplays retrieves every display matching the given selector
 func (c *Client) ListDisplays(ctx context.Context, selector v1alpha1.DisplaySelector) ([]v1alpha1.Display, error) {
+	var displays []v1alpha1.Display
+	err := c.ForEachDisplay(ctx, selector, DisplayListOptions{}, 0, func(d v1alpha1.Display) error {
+		displays = append(displays, d)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return displays, nil
+}
+
+// DisplayListOptions narrows a display listing beyond its selector
+type DisplayListOptions struct {
+	// NeverSeen, when set, keeps only displays that have never contacted
+	// the server (true) or only those that have (false)
+	NeverSeen *bool
+	// Timezone, when set, gives times in that IANA zone, or in each
+	// display's own zone when "local"
+	Timezone string
+	// Runtime asks for each display's runtime state: whether it is
+	// connected, its queued messages and the content it last loaded
+	Runtime bool
+}
+
+// ForEachDisplay calls fn for each display matching the selector and
+// options, following page cursors until the list is exhausted or maxItems
+// displays have been visited. A maxItems of zero or less visits every
+// display.
+func (c *Client) ForEachDisplay(ctx context.Context, selector v1alpha1.DisplaySelector, opts DisplayListOptions, maxItems int, fn func(v1alpha1.Display) error) error {
+	fetch := func(ctx context.Context, cursor string) (Page[v1alpha1.Display], error) {
+		return c.listDisplayPage(ctx, selector, opts, cursor)
+	}
+	return Paginate(ctx, fetch, maxItems, fn)
+}
+
+// listDisplayPage fetches one page of displays. Servers without pagination
+// answer with a bare array, which is treated as the only page.
+func (c *Client) listDisplayPage(ctx context.Context, selector v1alpha1.DisplaySelector, opts DisplayListOptions, cursor string) (Page[v1alpha1.Display], error) {
 	// Build query parameters
 	u := url.Values{}
 	if selector.SiteID != "" {
@@ -50,24 +173,47 @@ func (c *Client) ListDisplays(ctx context.Context, selector v1alpha1.DisplaySele
 	if selector.Position != "" {
 		u.Set("position", selector.Position)
 	}
-
-	path := "/api/v1alpha1/displays"
-	if len(u) > 0 {
-		path += "?" + u.Encode()
+	for _, pair := range v1alpha1.FormatMatchProperties(selector.MatchProperties) {
+		u.Add(v1alpha1.MatchPropertyParam, pair)
+	}
+	if opts.NeverSeen != nil {
+		u.Set("neverSeen", strconv.FormatBool(*opts.NeverSeen))
+	}
+	if opts.Timezone != "" {
+		u.Set("tz", opts.Timezone)
+	}
+	if opts.Runtime {
+		u.Set("include", v1alpha1.DisplayIncludeRuntime)
+	}
+	u.Set("limit", strconv.Itoa(listPageSize))
+	if cursor != "" {
+		u.Set("cursor", cursor)
 	}
 
-	resp, err := c.doRequest(ctx, http.MethodGet, path, nil)
+	resp, err := c.doRequest(ctx, http.MethodGet, "/api/v1alpha1/displays?"+u.Encode(), nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list displays: %w", err)
+		return Page[v1alpha1.Display]{}, fmt.Errorf("failed to list displays: %w", err)
 	}
 	defer resp.Body.Close()
 
-	var displays []v1alpha1.Display
-	if err := decodeResponse(resp, &displays); err != nil {
-		return nil, closeBody(resp.Body, err)
+	var raw json.RawMessage
+	if err := decodeResponse(resp, &raw); err != nil {
+		return Page[v1alpha1.Display]{}, closeBody(resp.Body, err)
 	}
 
-	return displays, closeBody(resp.Body, nil)
+	var page Page[v1alpha1.Display]
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &page.Items)
+	} else {
+		var list v1alpha1.DisplayList
+		err = json.Unmarshal(trimmed, &list)
+		page = Page[v1alpha1.Display]{Items: list.Items, NextCursor: list.NextCursor}
+	}
+	if err != nil {
+		return Page[v1alpha1.Display]{}, closeBody(resp.Body, fmt.Errorf("error decoding response: %w", err))
+	}
+
+	return page, closeBody(resp.Body, nil)
 }
 
 // CreateDisplay creates a new display
@@ -80,20 +226,91 @@ func (c *Client) CreateDisplay(ctx context.Context, name string, display *v1alph
 	return closeBody(resp.Body, nil)
 }
 
-// UpdateDisplay updates an existing display's location and properties
+// RegisterDisplay pre-registers a display with a known name, location and
+// properties, ahead of it being installed and activated
+func (c *Client) RegisterDisplay(ctx context.Context, req *v1alpha1.DisplayRegistrationRequest) (*v1alpha1.Display, error) {
+	resp, err := c.doRequest(ctx, http.MethodPost, "/api/v1alpha1/displays", req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register display: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result v1alpha1.DisplayRegistrationResponse
+	if err := decodeResponse(resp, &result); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return result.Display, closeBody(resp.Body, nil)
+}
+
+// UpdateDisplay applies a partial update to a display. Properties in
+// addProps are added or overwritten and those in removeProps are deleted; the
+// server merges the changes so other properties are left untouched.
 func (c *Client) UpdateDisplay(ctx context.Context, name string, location *v1alpha1.DisplayLocation, addProps map[string]string, removeProps []string) error {
-	update := &v1alpha1.DisplayUpdateRequest{
-		Location:   location,
-		Properties: addProps,
+	patch := &v1alpha1.DisplayPatchRequest{
+		Location:         location,
+		AddProperties:    addProps,
+		RemoveProperties: removeProps,
 	}
 
-	resp, err := c.doRequest(ctx, http.MethodPut, "/api/v1alpha1/displays/"+name, update)
+	resp, err := c.doRequest(ctx, http.MethodPatch, "/api/v1alpha1/displays/"+name, patch)
 	if err != nil {
 		return fmt.Errorf("failed to update display: %w", err)
 	}
 	return closeBody(resp.Body, nil)
 }
 
+// RenameDisplay changes a display's name, leaving its ID and everything tied
+// to it in place. When another display already has the name, ignoring case,
+// the error is a *DisplayNameTakenError.
+func (c *Client) RenameDisplay(ctx context.Context, name, newName string) (*v1alpha1.Display, error) {
+	patch := &v1alpha1.DisplayPatchRequest{Name: newName}
+
+	resp, err := c.doRequest(ctx, http.MethodPatch, "/api/v1alpha1/displays/"+url.PathEscape(name), patch)
+	if err != nil {
+		return nil, displayNameTakenError(fmt.Errorf("failed to rename display: %w", err))
+	}
+	defer resp.Body.Close()
+
+	var renamed v1alpha1.Display
+	if err := decodeResponse(resp, &renamed); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return &renamed, closeBody(resp.Body, nil)
+}
+
+// DisplayNameTakenError is returned by RenameDisplay when another display
+// already has the new name
+type DisplayNameTakenError struct {
+	// Existing is the display holding the name
+	Existing *v1alpha1.Display
+	// Err is the API error the server answered with
+	Err error
+}
+
+func (e *DisplayNameTakenError) Error() string {
+	return fmt.Sprintf("display name %q is taken by display %s", e.Existing.Name, e.Existing.ID)
+}
+
+func (e *DisplayNameTakenError) Unwrap() error {
+	return e.Err
+}
+
+// displayNameTakenError turns a conflict carrying the display holding a name
+// into a *DisplayNameTakenError, returning other errors unchanged
+func displayNameTakenError(err error) error {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "DISPLAY_EXISTS" || len(apiErr.Details) == 0 {
+		return err
+	}
+	var existing v1alpha1.Display
+	if json.Unmarshal(apiErr.Details, &existing) != nil || existing.Name == "" {
+		return err
+	}
+	return &DisplayNameTakenError{Existing: &existing, Err: err}
+}
+
 // DeleteDisplay deletes a display
 func (c *Client) DeleteDisplay(ctx context.Context, name string) error {
 	resp, err := c.doRequest(ctx, http.MethodDelete, "/api/v1alpha1/displays/"+name, nil)
@@ -118,3 +335,205 @@ func (c *Client) ActivateDisplay(ctx context.Context, req *v1alpha1.DisplayRegis
 
 	return result.Display, closeBody(resp.Body, nil)
 }
+
+// CheckActivationCode describes a user code that can still be activated,
+// with the location and name its display suggested
+func (c *Client) CheckActivationCode(ctx context.Context, userCode string) (*v1alpha1.ActivationCode, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, "/api/v1alpha1/displays/activate/"+url.PathEscape(userCode), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check activation code: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var code v1alpha1.ActivationCode
+	if err := decodeResponse(resp, &code); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return &code, closeBody(resp.Body, nil)
+}
+
+// ProvisionDisplay registers a display ahead of installation and returns
+// its signed provisioning file
+func (c *Client) ProvisionDisplay(ctx context.Context, req *v1alpha1.DisplayProvisionRequest) (*v1alpha1.ProvisioningFile, error) {
+	resp, err := c.doRequest(ctx, http.MethodPost, "/api/v1alpha1/displays/provision", req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to provision display: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var file v1alpha1.ProvisioningFile
+	if err := decodeResponse(resp, &file); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return &file, closeBody(resp.Body, nil)
+}
+
+// ApproveDisplay approves a display that is awaiting approval, returning
+// the now active display
+func (c *Client) ApproveDisplay(ctx context.Context, name string) (*v1alpha1.Display, error) {
+	resp, err := c.doRequest(ctx, http.MethodPost, "/api/v1alpha1/displays/"+url.PathEscape(name)+"/approve", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to approve display: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var display v1alpha1.Display
+	if err := decodeResponse(resp, &display); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return &display, closeBody(resp.Body, nil)
+}
+
+// UpdateDisplaySettings replaces a display's device settings, returning the
+// updated display. A connected display is sent the settings at once; others
+// receive them when they next connect.
+func (c *Client) UpdateDisplaySettings(ctx context.Context, name string, settings *v1alpha1.DisplaySettings) (*v1alpha1.Display, error) {
+	resp, err := c.doRequest(ctx, http.MethodPut, "/api/v1alpha1/displays/"+url.PathEscape(name)+"/settings", settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update display settings: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var display v1alpha1.Display
+	if err := decodeResponse(resp, &display); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return &display, closeBody(resp.Body, nil)
+}
+
+// DisconnectDisplay closes a display's control connection, forcing it to
+// reconnect. When reload is true the display is told to reload first.
+func (c *Client) DisconnectDisplay(ctx context.Context, name string, reload bool) error {
+	path := "/api/v1alpha1/displays/" + name + "/disconnect"
+	if reload {
+		path += "?reload=true"
+	}
+	resp, err := c.doRequest(ctx, http.MethodPost, path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to disconnect display: %w", err)
+	}
+	return closeBody(resp.Body, nil)
+}
+
+// ResyncDisplay has the server push a display the content currently
+// assigned to it
+func (c *Client) ResyncDisplay(ctx context.Context, name string) (*v1alpha1.DisplayResync, error) {
+	resp, err := c.doRequest(ctx, http.MethodPost, "/api/v1alpha1/displays/"+url.PathEscape(name)+"/resync", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resync display: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var resync v1alpha1.DisplayResync
+	if err := decodeResponse(resp, &resync); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return &resync, closeBody(resp.Body, nil)
+}
+
+// ListDisplaySessions retrieves a display's active token sessions, oldest
+// first
+func (c *Client) ListDisplaySessions(ctx context.Context, name string) (*v1alpha1.DisplaySessionList, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, "/api/v1alpha1/displays/"+url.PathEscape(name)+"/sessions", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var sessions v1alpha1.DisplaySessionList
+	if err := decodeResponse(resp, &sessions); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return &sessions, closeBody(resp.Body, nil)
+}
+
+// GetDisplayDebug returns a debug snapshot of a display gathering its
+// record, connections, recent events, content and queued messages
+func (c *Client) GetDisplayDebug(ctx context.Context, name string) (*v1alpha1.DisplayDebugSnapshot, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, "/api/v1alpha1/displays/"+url.PathEscape(name)+"/debug", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get debug snapshot: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var snapshot v1alpha1.DisplayDebugSnapshot
+	if err := decodeResponse(resp, &snapshot); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return &snapshot, closeBody(resp.Body, nil)
+}
+
+// TerminateDisplaySession revokes one of a display's sessions. The server
+// also closes any control connection that authenticated with it.
+func (c *Client) TerminateDisplaySession(ctx context.Context, name, sessionID string) error {
+	path := "/api/v1alpha1/displays/" + url.PathEscape(name) + "/sessions/" + url.PathEscape(sessionID)
+	resp, err := c.doRequest(ctx, http.MethodDelete, path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to terminate session: %w", err)
+	}
+	return closeBody(resp.Body, handleResponse(resp))
+}
+
+// ListPendingMessages retrieves the control messages queued for a display
+// in queue order. An empty state lists messages in every state.
+func (c *Client) ListPendingMessages(ctx context.Context, name string, state v1alpha1.PendingMessageState) (*v1alpha1.PendingMessageList, error) {
+	path := "/api/v1alpha1/displays/" + url.PathEscape(name) + "/pending-messages"
+	if state != "" {
+		path += "?state=" + url.QueryEscape(string(state))
+	}
+
+	resp, err := c.doRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending messages: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var list v1alpha1.PendingMessageList
+	if err := decodeResponse(resp, &list); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return &list, closeBody(resp.Body, nil)
+}
+
+// ReplayPendingMessage queues a dead letter for a display again. The server
+// delivers it at once if the display is connected.
+func (c *Client) ReplayPendingMessage(ctx context.Context, name, messageID string) (*v1alpha1.PendingMessage, error) {
+	path := "/api/v1alpha1/displays/" + url.PathEscape(name) + "/pending-messages/" + url.PathEscape(messageID) + "/replay"
+	resp, err := c.doRequest(ctx, http.MethodPost, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var m v1alpha1.PendingMessage
+	if err := decodeResponse(resp, &m); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return &m, closeBody(resp.Body, nil)
+}
+
+// PruneDisplays removes displays offline for longer than req.OfflineFor, or
+// on a dry run lists them. It requires an admin token.
+func (c *Client) PruneDisplays(ctx context.Context, req *v1alpha1.DisplayPruneRequest) (*v1alpha1.DisplayPruneResult, error) {
+	resp, err := c.doRequest(ctx, http.MethodPost, "/api/v1alpha1/displays/prune", req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prune displays: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result v1alpha1.DisplayPruneResult
+	if err := decodeResponse(resp, &result); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return &result, closeBody(resp.Body, nil)
+}
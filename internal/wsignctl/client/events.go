@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+)
+
+// ContentEventQuery selects the content events to read. Empty fields match
+// every event; Since and Until take a duration before now such as 24h or
+// 7d, or an RFC 3339 time, and default to the last day.
+type ContentEventQuery struct {
+	DisplayID string
+	URL       string
+	Type      string
+	Since     string
+	Until     string
+	// Timezone gives timestamps in that IANA zone, or in the zone of the
+	// display reporting each event when "local"
+	Timezone string
+}
+
+// values encodes the query as request parameters
+func (q ContentEventQuery) values() url.Values {
+	u := url.Values{}
+	for key, value := range map[string]string{
+		"displayId": q.DisplayID,
+		"url":       q.URL,
+		"type":      q.Type,
+		"since":     q.Since,
+		"until":     q.Until,
+		"tz":        q.Timezone,
+	} {
+		if value != "" {
+			u.Set(key, value)
+		}
+	}
+	return u
+}
+
+// ForEachContentEvent calls fn for each stored content event the query
+// selects, oldest first, following page tokens until the events run out or
+// maxItems have been visited. A maxItems of zero or less visits every event.
+func (c *Client) ForEachContentEvent(ctx context.Context, query ContentEventQuery, maxItems int, fn func(v1alpha1.ContentEvent) error) error {
+	fetch := func(ctx context.Context, token string) (Page[v1alpha1.ContentEvent], error) {
+		u := query.values()
+		u.Set("limit", strconv.Itoa(listPageSize))
+		if token != "" {
+			u.Set("pageToken", token)
+		}
+
+		resp, err := c.doRequest(ctx, http.MethodGet, "/api/v1alpha1/content/events?"+u.Encode(), nil)
+		if err != nil {
+			return Page[v1alpha1.ContentEvent]{}, fmt.Errorf("failed to query content events: %w", err)
+		}
+		defer resp.Body.Close()
+
+		var list v1alpha1.ContentEventList
+		if err := decodeResponse(resp, &list); err != nil {
+			return Page[v1alpha1.ContentEvent]{}, err
+		}
+		return Page[v1alpha1.ContentEvent]{Items: list.Items, NextCursor: list.NextPageToken}, nil
+	}
+	return Paginate(ctx, fetch, maxItems, fn)
+}
+
+// ExportContentEvents streams the content events the query selects to w as
+// newline-delimited JSON, one event per line, as the server produces them.
+// A limit of zero or less exports every event. The export is not subject to
+// the client's request timeout, so only ctx bounds it.
+func (c *Client) ExportContentEvents(ctx context.Context, query ContentEventQuery, limit int, w io.Writer) error {
+	u := query.values()
+	u.Set("format", "ndjson")
+	if limit > 0 {
+		u.Set("limit", strconv.Itoa(limit))
+	}
+
+	resp, err := c.doStreamRequest(ctx, http.MethodGet, "/api/v1alpha1/content/events?"+u.Encode())
+	if err != nil {
+		return fmt.Errorf("failed to export content events: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// The server aborts the connection when it fails mid-export, which
+	// surfaces here as an unexpected EOF rather than a clean end
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("content event export interrupted: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"context"
+	"fmt"
+)
+
+// listPageSize is the number of items requested per page
+const listPageSize = 100
+
+// Page is one page of a list response
+type Page[T any] struct {
+	Items      []T
+	NextCursor string
+}
+
+// PageFetcher fetches the page that starts at cursor. The first page is
+// requested with an empty cursor.
+type PageFetcher[T any] func(ctx context.Context, cursor string) (Page[T], error)
+
+// Paginate fetches pages until the server stops returning a cursor, passing
+// each item to fn in order. Iteration stops early when fn returns an error,
+// ctx is cancelled, or maxItems items have been visited; a maxItems of zero
+// or less visits everything.
+func Paginate[T any](ctx context.Context, fetch PageFetcher[T], maxItems int, fn func(T) error) error {
+	visited := 0
+	cursor := ""
+	seen := map[string]bool{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		page, err := fetch(ctx, cursor)
+		if err != nil {
+			return err
+		}
+		for _, item := range page.Items {
+			if maxItems > 0 && visited >= maxItems {
+				return nil
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(item); err != nil {
+				return err
+			}
+			visited++
+		}
+
+		if page.NextCursor == "" || (maxItems > 0 && visited >= maxItems) {
+			return nil
+		}
+		// A server handing back a cursor it already gave us would keep us
+		// here forever
+		if seen[page.NextCursor] {
+			return fmt.Errorf("server repeated page cursor %q", page.NextCursor)
+		}
+		seen[page.NextCursor] = true
+		cursor = page.NextCursor
+	}
+}
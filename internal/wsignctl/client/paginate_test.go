@@ -0,0 +1,184 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+)
+
+// pagedDisplayServer serves total displays in pages of pageSize, using the
+// index of the next display as the cursor
+func pagedDisplayServer(t *testing.T, total, pageSize int, requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		require.Equal(t, "/api/v1alpha1/displays", r.URL.Path)
+		assert.Equal(t, "hq", r.URL.Query().Get("siteId"))
+
+		start := 0
+		if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+			var err error
+			start, err = strconv.Atoi(cursor)
+			require.NoError(t, err)
+		}
+		end := min(start+pageSize, total)
+
+		list := v1alpha1.DisplayList{Items: []v1alpha1.Display{}}
+		for i := start; i < end; i++ {
+			list.Items = append(list.Items, v1alpha1.Display{
+				ObjectMeta: v1alpha1.ObjectMeta{Name: fmt.Sprintf("display-%02d", i)},
+			})
+		}
+		if end < total {
+			list.NextCursor = strconv.Itoa(end)
+		}
+		_ = json.NewEncoder(w).Encode(list)
+	}))
+}
+
+func TestForEachDisplay(t *testing.T) {
+	selector := v1alpha1.DisplaySelector{SiteID: "hq"}
+
+	t.Run("visits every display once", func(t *testing.T) {
+		var requests int
+		server := pagedDisplayServer(t, 25, 10, &requests)
+		defer server.Close()
+		c, err := NewClient(server.URL)
+		require.NoError(t, err)
+
+		visits := map[string]int{}
+		var order []string
+		err = c.ForEachDisplay(context.Background(), selector, DisplayListOptions{}, 0, func(d v1alpha1.Display) error {
+			visits[d.Name]++
+			order = append(order, d.Name)
+			return nil
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, 3, requests)
+		assert.Len(t, visits, 25)
+		for name, n := range visits {
+			assert.Equal(t, 1, n, name)
+		}
+		assert.Equal(t, "display-00", order[0])
+		assert.Equal(t, "display-24", order[24])
+	})
+
+	t.Run("stops at max items", func(t *testing.T) {
+		var requests int
+		server := pagedDisplayServer(t, 25, 10, &requests)
+		defer server.Close()
+		c, err := NewClient(server.URL)
+		require.NoError(t, err)
+
+		var names []string
+		err = c.ForEachDisplay(context.Background(), selector, DisplayListOptions{}, 10, func(d v1alpha1.Display) error {
+			names = append(names, d.Name)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Len(t, names, 10)
+		assert.Equal(t, 1, requests, "a full first page should not fetch the next")
+	})
+
+	t.Run("stops when cancelled", func(t *testing.T) {
+		var requests int
+		server := pagedDisplayServer(t, 25, 10, &requests)
+		defer server.Close()
+		c, err := NewClient(server.URL)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		visited := 0
+		err = c.ForEachDisplay(ctx, selector, DisplayListOptions{}, 0, func(d v1alpha1.Display) error {
+			visited++
+			if visited == 3 {
+				cancel()
+			}
+			return nil
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 3, visited)
+		assert.Equal(t, 1, requests)
+	})
+
+	t.Run("accepts unpaginated servers", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(w).Encode([]v1alpha1.Display{
+				{ObjectMeta: v1alpha1.ObjectMeta{Name: "lobby"}},
+				{ObjectMeta: v1alpha1.ObjectMeta{Name: "cafe"}},
+			})
+		}))
+		defer server.Close()
+		c, err := NewClient(server.URL)
+		require.NoError(t, err)
+
+		displays, err := c.ListDisplays(context.Background(), selector)
+		require.NoError(t, err)
+		require.Len(t, displays, 2)
+		assert.Equal(t, "lobby", displays[0].Name)
+	})
+
+	t.Run("sends never seen only when set", func(t *testing.T) {
+		var got []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = append(got, r.URL.Query().Get("neverSeen"))
+			_ = json.NewEncoder(w).Encode([]v1alpha1.Display{})
+		}))
+		defer server.Close()
+		c, err := NewClient(server.URL)
+		require.NoError(t, err)
+
+		never, seen := true, false
+		for _, opts := range []DisplayListOptions{{}, {NeverSeen: &never}, {NeverSeen: &seen}} {
+			err := c.ForEachDisplay(context.Background(), selector, opts, 0, func(v1alpha1.Display) error { return nil })
+			require.NoError(t, err)
+		}
+		assert.Equal(t, []string{"", "true", "false"}, got)
+	})
+}
+
+func TestPaginate_RejectsRepeatedCursor(t *testing.T) {
+	fetch := func(ctx context.Context, cursor string) (Page[int], error) {
+		return Page[int]{Items: []int{1}, NextCursor: "again"}, nil
+	}
+	err := Paginate(context.Background(), fetch, 0, func(int) error { return nil })
+	assert.ErrorContains(t, err, "repeated page cursor")
+}
+
+func TestForEachContentEvent(t *testing.T) {
+	var tokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v1alpha1/content/events", r.URL.Path)
+		assert.Equal(t, "https://example.com/menu", r.URL.Query().Get("url"))
+		token := r.URL.Query().Get("pageToken")
+		tokens = append(tokens, token)
+
+		list := v1alpha1.ContentEventList{Items: []v1alpha1.ContentEvent{{URL: "https://example.com/menu"}}}
+		if token == "" {
+			list.NextPageToken = "second"
+		}
+		_ = json.NewEncoder(w).Encode(list)
+	}))
+	defer server.Close()
+	c, err := NewClient(server.URL)
+	require.NoError(t, err)
+
+	visited := 0
+	err = c.ForEachContentEvent(context.Background(), ContentEventQuery{URL: "https://example.com/menu"}, 0, func(v1alpha1.ContentEvent) error {
+		visited++
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, visited)
+	assert.Equal(t, []string{"", "second"}, tokens)
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+)
+
+// ListLocationProposals retrieves location proposals, oldest first. An
+// empty state lists proposals in every state.
+func (c *Client) ListLocationProposals(ctx context.Context, state v1alpha1.LocationProposalState) ([]v1alpha1.LocationProposal, error) {
+	path := "/api/v1alpha1/location-proposals"
+	if state != "" {
+		path += "?state=" + url.QueryEscape(string(state))
+	}
+
+	resp, err := c.doRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list location proposals: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var list v1alpha1.LocationProposalList
+	if err := decodeResponse(resp, &list); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return list.Items, closeBody(resp.Body, nil)
+}
+
+// ApproveLocationProposal applies a pending proposal's location to its
+// display
+func (c *Client) ApproveLocationProposal(ctx context.Context, id string) (*v1alpha1.LocationProposal, error) {
+	return c.decideLocationProposal(ctx, id, "approve")
+}
+
+// RejectLocationProposal turns a pending proposal down
+func (c *Client) RejectLocationProposal(ctx context.Context, id string) (*v1alpha1.LocationProposal, error) {
+	return c.decideLocationProposal(ctx, id, "reject")
+}
+
+// decideLocationProposal posts an approve or reject action for a proposal
+func (c *Client) decideLocationProposal(ctx context.Context, id, action string) (*v1alpha1.LocationProposal, error) {
+	resp, err := c.doRequest(ctx, http.MethodPost, "/api/v1alpha1/location-proposals/"+url.PathEscape(id)+"/"+action, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s location proposal: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	var p v1alpha1.LocationProposal
+	if err := decodeResponse(resp, &p); err != nil {
+		return nil, closeBody(resp.Body, err)
+	}
+
+	return &p, closeBody(resp.Body, nil)
+}
@@ -1,9 +1,16 @@
 package client
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
 )
 
 // decodeResponse decodes a JSON response into the provided target
@@ -26,18 +33,80 @@ func handleResponse(resp *http.Response) error {
 	}
 
 	defer resp.Body.Close()
-	var apiErr struct {
-		Code    string `json:"code"`
-		Message string `json:"message"`
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &APIError{StatusCode: resp.StatusCode, Message: "unable to read error response"}
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil {
-		return fmt.Errorf("HTTP %d: unable to decode error response", resp.StatusCode)
+	var apiErr struct {
+		Code    string                `json:"code"`
+		Message string                `json:"message"`
+		Details json.RawMessage       `json:"details"`
+		Errors  []v1alpha1.FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &apiErr); err != nil {
+		return &APIError{StatusCode: resp.StatusCode, Message: "unable to decode error response"}
 	}
 
 	if apiErr.Message == "" {
-		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Code:       apiErr.Code,
+		Message:    apiErr.Message,
+		Details:    apiErr.Details,
+		Errors:     apiErr.Errors,
+		Body:       body,
+	}
+}
+
+// APIError is returned when the server answers with an error status
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	// Details is the error's details as sent, if any
+	Details json.RawMessage
+	// Errors lists the request fields the server found invalid
+	Errors []v1alpha1.FieldError
+	// Body is the error response as sent, for passing through unchanged
+	Body json.RawMessage
+}
+
+// Error renders the status and message, followed by one aligned line per
+// invalid field
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Message)
+	if len(e.Errors) == 0 {
+		return msg
 	}
 
-	return fmt.Errorf("HTTP %d: %s", resp.StatusCode, apiErr.Message)
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	for _, f := range e.Errors {
+		fmt.Fprintf(tw, "  - %s\t%s\t%s\n", f.Field, f.Code, f.Message)
+	}
+	tw.Flush()
+	return msg + "\n" + strings.TrimRight(buf.String(), "\n")
+}
+
+// IsNotFound reports whether err is an API error with status 404
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// IsConflict reports whether err is an API error with status 409
+func IsConflict(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict
+}
+
+// IsVersionMismatch reports whether err is an API error raised because the
+// resource changed since the version named in the request
+func IsVersionMismatch(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == "VERSION_MISMATCH"
 }
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+)
+
+func TestHandleResponseFieldErrors(t *testing.T) {
+	body := `{"code":"INVALID_INPUT","message":"invalid fields","errors":[` +
+		`{"field":"location.siteId","code":"required","message":"is required"},` +
+		`{"field":"location.zone","code":"required","message":"is required"},` +
+		`{"field":"activationCode","code":"invalid","message":"must be a code"}]}`
+	resp := &http.Response{StatusCode: http.StatusBadRequest, Body: io.NopCloser(strings.NewReader(body))}
+
+	err := handleResponse(resp)
+	require.Error(t, err)
+	apiErr, ok := err.(*APIError)
+	require.True(t, ok)
+	assert.Equal(t, "INVALID_INPUT", apiErr.Code)
+	assert.Len(t, apiErr.Errors, 3)
+	assert.Equal(t, v1alpha1.FieldRequired, apiErr.Errors[0].Code)
+	assert.JSONEq(t, body, string(apiErr.Body))
+
+	assert.Equal(t, "HTTP 400: invalid fields\n"+
+		"  - location.siteId  required  is required\n"+
+		"  - location.zone    required  is required\n"+
+		"  - activationCode   invalid   must be a code", err.Error())
+}
+
+func TestHandleResponseWithoutFieldErrors(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(`{"code":"NOT_FOUND"}`))}
+
+	err := handleResponse(resp)
+	assert.EqualError(t, err, "HTTP 404: Not Found")
+	assert.True(t, IsNotFound(err))
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/wrale/wrale-signage/api/types/v1alpha1"
 )
@@ -25,30 +26,24 @@ func (c *Client) AddRedirectRule(ctx context.Context, rule *v1alpha1.RedirectRul
 
 // ListRedirectRules retrieves redirect rules matching the filter
 func (c *Client) ListRedirectRules(ctx context.Context, filter *v1alpha1.RuleFilter) ([]v1alpha1.RedirectRule, error) {
-	// Build query parameters for filtering
-	query := make(map[string]string)
+	// Build query parameters for filtering; Encode sorts them, so the
+	// request is the same on every run
+	query := url.Values{}
 	if filter != nil {
 		if filter.SiteID != "" {
-			query["siteId"] = filter.SiteID
+			query.Set("siteId", filter.SiteID)
 		}
 		if filter.Zone != "" {
-			query["zone"] = filter.Zone
+			query.Set("zone", filter.Zone)
 		}
 		if filter.Position != "" {
-			query["position"] = filter.Position
+			query.Set("position", filter.Position)
 		}
 	}
 
 	path := "/api/v1alpha1/rules"
 	if len(query) > 0 {
-		values := make([]string, 0, len(query))
-		for k, v := range query {
-			values = append(values, fmt.Sprintf("%s=%s", k, v))
-		}
-		path += "?" + values[0]
-		for _, v := range values[1:] {
-			path += "&" + v
-		}
+		path += "?" + query.Encode()
 	}
 
 	resp, err := c.doRequest(ctx, http.MethodGet, path, nil)
@@ -0,0 +1,33 @@
+// Package admin implements the server administration commands
+package admin
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/internal/wsignctl/client"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+// NewCommand creates the admin command and its subcommands
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "admin",
+		Short: "Inspect and tune the server",
+		Long: `The admin command provides subcommands for inspecting and tuning a running
+server.
+They require a token with the admin scope.`,
+	}
+
+	cmd.AddCommand(
+		newConfigCommand(),
+		newPollingCommand(),
+		newRetentionCommand(),
+	)
+
+	return cmd
+}
+
+// getClient returns an API client honouring the global connection flags
+func getClient(cmd *cobra.Command) (*client.Client, error) {
+	return util.GetClientFromCommand(cmd)
+}
@@ -0,0 +1,77 @@
+package admin
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+func newConfigCommand() *cobra.Command {
+	var (
+		output  string
+		envOnly bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Show the server's effective configuration",
+		Long: `Show the configuration the server is running with: every setting, its
+value and whether it was set in the environment or left at its default.
+Secrets such as the database password and signing keys are redacted by the
+server, so the output can be shared when asking for support.
+
+A variable that is set but could not be parsed is listed as default, with
+the value that was used instead.`,
+		Example: `  # Show every setting
+  wsignctl admin config
+
+  # Show only what the deployment changed
+  wsignctl admin config --env-only
+
+  # Save the configuration for a support request
+  wsignctl admin config -o json > wsignd-config.json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := getClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			cfg, err := client.GetEffectiveConfig(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("error getting server configuration: %w", err)
+			}
+
+			if envOnly {
+				var settings []v1alpha1.ConfigSetting
+				for _, s := range cfg.Settings {
+					if s.Source == v1alpha1.ConfigSourceEnv {
+						settings = append(settings, s)
+					}
+				}
+				cfg.Settings = settings
+			}
+
+			if output == "json" {
+				return util.PrintJSON(cmd.OutOrStdout(), cfg)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Server version: %s\n\n", cfg.ServerVersion)
+			tw := util.NewTabWriter(cmd.OutOrStdout())
+			defer tw.Flush()
+			fmt.Fprintf(tw, "NAME\tVALUE\tSOURCE\n")
+			for _, s := range cfg.Settings {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Name, s.Value, s.Source)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format (table, json)")
+	cmd.Flags().BoolVar(&envOnly, "env-only", false, "Show only settings taken from the environment")
+
+	return cmd
+}
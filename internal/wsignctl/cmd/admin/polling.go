@@ -0,0 +1,166 @@
+package admin
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+func newPollingCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "polling",
+		Short: "Show the polling hints handed out to displays",
+		Long: `Show how often displays are asked to check in and to look for new content,
+by default and for each site with an override.`,
+		Example: `  # Show the polling policy
+  wsignctl admin polling`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := getClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			policy, err := client.GetPollingPolicy(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("error getting polling policy: %w", err)
+			}
+			return printPollingPolicy(cmd, policy, output)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format (table, json)")
+	cmd.AddCommand(newPollingSetCommand())
+
+	return cmd
+}
+
+func newPollingSetCommand() *cobra.Command {
+	var (
+		site        string
+		heartbeat   time.Duration
+		contentPoll time.Duration
+		clearSite   bool
+		output      string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "set",
+		Short: "Change the polling hints handed out to displays",
+		Long: `Change how often displays are asked to check in and to look for new content.
+Connected displays are sent their new hints straight away; others receive
+them when they next connect or check in.
+
+The change is held by the server the command talks to until it restarts.
+Set WSIGN_DISPLAY_HEARTBEAT_INTERVAL, WSIGN_DISPLAY_CONTENT_POLL_INTERVAL
+and WSIGN_DISPLAY_POLLING_SITES to keep it, and on every replica.`,
+		Example: `  # Slow every display down during an incident
+  wsignctl admin polling set --heartbeat 5m --content-poll 30m
+
+  # Let one site check in more often
+  wsignctl admin polling set --site hq --heartbeat 30s
+
+  # Remove a site's override
+  wsignctl admin polling set --site hq --clear`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			changed := cmd.Flags().Changed("heartbeat") || cmd.Flags().Changed("content-poll")
+			switch {
+			case clearSite && site == "":
+				return fmt.Errorf("--clear requires --site")
+			case clearSite && changed:
+				return fmt.Errorf("--clear cannot be combined with --heartbeat or --content-poll")
+			case !clearSite && !changed:
+				return fmt.Errorf("nothing to change: give --heartbeat, --content-poll or --clear")
+			}
+
+			client, err := getClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			policy, err := client.GetPollingPolicy(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("error getting polling policy: %w", err)
+			}
+
+			if site == "" {
+				applyPollingFlags(cmd, &policy.Default, heartbeat, contentPoll)
+			} else if clearSite {
+				delete(policy.Sites, site)
+			} else {
+				hints := policy.Sites[site]
+				applyPollingFlags(cmd, &hints, heartbeat, contentPoll)
+				if policy.Sites == nil {
+					policy.Sites = make(map[string]v1alpha1.PollingHints)
+				}
+				policy.Sites[site] = hints
+			}
+
+			updated, err := client.UpdatePollingPolicy(cmd.Context(), policy)
+			if err != nil {
+				return fmt.Errorf("error updating polling policy: %w", err)
+			}
+			return printPollingPolicy(cmd, updated, output)
+		},
+	}
+
+	cmd.Flags().StringVar(&site, "site", "", "Change the override for this site instead of the default")
+	cmd.Flags().DurationVar(&heartbeat, "heartbeat", 0, "How often displays should check in")
+	cmd.Flags().DurationVar(&contentPoll, "content-poll", 0, "How often displays should look for new content")
+	cmd.Flags().BoolVar(&clearSite, "clear", false, "Remove the site's override")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format (table, json)")
+
+	return cmd
+}
+
+// applyPollingFlags sets the intervals given on the command line. A site
+// override given zero inherits the default again.
+func applyPollingFlags(cmd *cobra.Command, hints *v1alpha1.PollingHints, heartbeat, contentPoll time.Duration) {
+	if cmd.Flags().Changed("heartbeat") {
+		hints.HeartbeatInterval = int(heartbeat / time.Second)
+	}
+	if cmd.Flags().Changed("content-poll") {
+		hints.ContentPollInterval = int(contentPoll / time.Second)
+	}
+}
+
+func printPollingPolicy(cmd *cobra.Command, policy *v1alpha1.PollingPolicy, output string) error {
+	if output == "json" {
+		return util.PrintJSON(cmd.OutOrStdout(), policy)
+	}
+
+	sites := make([]string, 0, len(policy.Sites))
+	for site := range policy.Sites {
+		sites = append(sites, site)
+	}
+	sort.Strings(sites)
+
+	tw := util.NewTabWriter(cmd.OutOrStdout())
+	defer tw.Flush()
+	fmt.Fprintf(tw, "SITE\tHEARTBEAT\tCONTENT POLL\n")
+	fmt.Fprintf(tw, "(default)\t%s\t%s\n",
+		formatHint(policy.Default.HeartbeatInterval), formatHint(policy.Default.ContentPollInterval))
+	for _, site := range sites {
+		hints := policy.Sites[site]
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", site,
+			formatHint(hints.HeartbeatInterval), formatHint(hints.ContentPollInterval))
+	}
+	return nil
+}
+
+// formatHint formats an interval in seconds, showing an unset one as
+// inherited from the default
+func formatHint(seconds int) string {
+	if seconds == 0 {
+		return "(default)"
+	}
+	return (time.Duration(seconds) * time.Second).String()
+}
@@ -0,0 +1,130 @@
+package admin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+func newRetentionCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "retention",
+		Short: "Inspect how content events are kept",
+		Long: `The retention command provides subcommands for inspecting how the server
+stores content events and prunes old ones.`,
+	}
+
+	cmd.AddCommand(newRetentionStatusCommand())
+
+	return cmd
+}
+
+func newRetentionStatusCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show event storage and the last pruning run",
+		Long: `Show how long the server keeps content events, the tables they are stored
+in with their size and estimated row count, the oldest event kept and what
+the last pruning run on the server answering removed.
+
+Events are stored in monthly partitions; the partition without a lower bound
+holds the events stored before partitioning was introduced, and the default
+partition holds events no month covers yet.`,
+		Example: `  # Show event retention
+  wsignctl admin retention status
+
+  # Show it as JSON
+  wsignctl admin retention status -o json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := getClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			status, err := client.GetEventRetention(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("error getting event retention: %w", err)
+			}
+			if output == "json" {
+				return util.PrintJSON(cmd.OutOrStdout(), status)
+			}
+			printRetentionStatus(cmd, status)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format (table, json)")
+
+	return cmd
+}
+
+func printRetentionStatus(cmd *cobra.Command, status *v1alpha1.EventRetentionStatus) {
+	out := cmd.OutOrStdout()
+	retention := "forever"
+	if status.Retention != "" {
+		retention = status.Retention
+	}
+	fmt.Fprintf(out, "Retention:    %s\n", retention)
+	fmt.Fprintf(out, "Archive:      %t\n", status.Archive)
+	oldest := "<none>"
+	if status.OldestEvent != nil {
+		oldest = status.OldestEvent.Local().Format(time.RFC3339)
+	}
+	fmt.Fprintf(out, "Oldest event: %s\n", oldest)
+	fmt.Fprintf(out, "Total size:   %s\n", formatBytes(status.TotalBytes))
+	if run := status.LastRun; run != nil {
+		fmt.Fprintf(out, "Last run:     %s, %d deleted before %s",
+			run.StartedAt.Local().Format(time.RFC3339), run.Deleted, run.Cutoff.Local().Format(time.RFC3339))
+		if len(run.DroppedPartitions) > 0 {
+			fmt.Fprintf(out, ", %d partitions dropped", len(run.DroppedPartitions))
+		}
+		fmt.Fprintln(out)
+	}
+	fmt.Fprintln(out)
+
+	tw := util.NewTabWriter(out)
+	defer tw.Flush()
+	fmt.Fprintf(tw, "TABLE\tRANGE\tSIZE\tROWS\n")
+	for _, t := range status.Tables {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t~%d\n", t.Name, formatRange(status.Partitioned, t), formatBytes(t.Bytes), t.EstimatedRows)
+	}
+}
+
+// formatRange describes the events a table holds
+func formatRange(partitioned bool, t v1alpha1.EventTable) string {
+	switch {
+	case !partitioned:
+		return "all"
+	case t.Default:
+		return "default"
+	}
+	from, to := "", ""
+	if t.From != nil {
+		from = t.From.UTC().Format("2006-01-02")
+	}
+	if t.To != nil {
+		to = t.To.UTC().Format("2006-01-02")
+	}
+	return from + "…" + to
+}
+
+// formatBytes gives a size in the largest binary unit it fills
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,23 @@
+// Package assignment implements the content assignment commands
+package assignment
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// NewCommand creates the assignment command and its subcommands
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "assignment",
+		Short: "Inspect content assignments",
+		Long: `The assignment command lists the content assignments that decide what each
+display shows. Assignments are created and removed with 'wsignctl content
+assign' and 'wsignctl content unassign'.`,
+	}
+
+	cmd.AddCommand(
+		newListCommand(),
+	)
+
+	return cmd
+}
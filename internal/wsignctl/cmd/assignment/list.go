@@ -0,0 +1,106 @@
+package assignment
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+func newListCommand() *cobra.Command {
+	var (
+		source   string
+		selector v1alpha1.DisplaySelector
+		output   string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List content assignments",
+		Long: `List content assignments, optionally only those created from one source or
+with the given location or display.
+
+Assignments to a single display show the display's current name. When that
+display has been deleted the assignment reaches no display; it is marked
+orphaned and a warning is written to stderr so it can be removed.
+
+Assignments the server's auto-assignment rules created when a display was
+activated are marked (auto). They can be deleted like any other, but come
+back when the display is next activated unless its suppressAutoAssign
+property is set to true.`,
+		Example: `  # List every assignment
+  wsignctl assignment list
+
+  # List the assignments in the cafeteria at HQ
+  wsignctl assignment list --site-id=hq --zone=cafeteria
+
+  # List the assignments to the reception iPad
+  wsignctl assignment list --display=reception-ipad`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := util.GetClientFromCommand(cmd)
+			if err != nil {
+				return err
+			}
+
+			assignments, err := client.ListContentAssignments(cmd.Context(), source, selector)
+			if err != nil {
+				return fmt.Errorf("error listing assignments: %w", err)
+			}
+
+			for _, a := range assignments {
+				if a.Status == nil {
+					continue
+				}
+				for _, warning := range a.Status.Warnings {
+					fmt.Fprintf(cmd.ErrOrStderr(), "Warning: assignment %s: %s\n", a.ID, warning)
+				}
+			}
+
+			if output == "json" {
+				return util.PrintJSON(cmd.OutOrStdout(), assignments)
+			}
+
+			tw := util.NewTabWriter(cmd.OutOrStdout())
+			defer tw.Flush()
+			fmt.Fprintf(tw, "ID\tSOURCE\tTARGET\tCONTENT\tCREATED\n")
+			for _, a := range assignments {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+					a.ID,
+					a.Source,
+					formatTarget(a),
+					a.ContentURL,
+					util.FormatDuration(time.Since(a.CreatedAt)))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&source, "source", "", "Only list assignments created from this content source")
+	cmd.Flags().StringVar(&selector.SiteID, "site-id", "", "Filter by site ID")
+	cmd.Flags().StringVar(&selector.Zone, "zone", "", "Filter by zone")
+	cmd.Flags().StringVar(&selector.Position, "position", "", "Filter by position")
+	cmd.Flags().StringVar(&selector.DisplayRef, "display", "", "Only list assignments to this display, by name or ID")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format (table, json)")
+
+	return cmd
+}
+
+// formatTarget describes the displays an assignment targets, naming its
+// display when it targets one and marking auto-created assignments
+func formatTarget(a v1alpha1.ContentAssignment) string {
+	if a.DisplaySelector.DisplayRef == "" || a.Status == nil {
+		return util.FormatSelectors(a.DisplaySelector)
+	}
+	target := "display=" + a.Status.DisplayName
+	if a.Status.Orphaned {
+		target = fmt.Sprintf("display=%s (orphaned)", a.DisplaySelector.DisplayRef)
+	}
+	if a.AutoCreated {
+		target += " (auto)"
+	}
+	return target
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/wrale/wrale-signage/internal/wsignctl/config"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
 )
 
 // newConfigCmd creates the config command that manages CLI contexts and settings.
@@ -37,13 +38,15 @@ func newConfigGetContextCmd() *cobra.Command {
 		Long:  `Display information about one or many configuration contexts.`,
 		Run: func(cmd *cobra.Command, args []string) {
 			if len(args) == 0 {
-				fmt.Printf("CURRENT   NAME           SERVER\n")
-				for name, ctx := range cfg.Contexts {
+				tw := util.NewTabWriter(cmd.OutOrStdout())
+				defer tw.Flush()
+				fmt.Fprintf(tw, "CURRENT\tNAME\tSERVER\n")
+				for _, name := range util.SortedKeys(cfg.Contexts) {
 					current := " "
 					if name == cfg.CurrentContext {
 						current = "*"
 					}
-					fmt.Printf("%-8s  %-13s  %s\n", current, name, ctx.Server)
+					fmt.Fprintf(tw, "%s\t%s\t%s\n", current, name, cfg.Contexts[name].Server)
 				}
 				return
 			}
@@ -58,6 +61,9 @@ func newConfigGetContextCmd() *cobra.Command {
 			fmt.Printf("Name: %s\n", name)
 			fmt.Printf("Server: %s\n", ctx.Server)
 			fmt.Printf("Insecure Skip Verify: %v\n", ctx.InsecureSkipVerify)
+			if ctx.Timezone != "" {
+				fmt.Printf("Timezone: %s\n", ctx.Timezone)
+			}
 			if ctx.Token != "" {
 				fmt.Printf("Token: %s...\n", ctx.Token[:10])
 			}
@@ -71,6 +77,7 @@ func newConfigSetContextCmd() *cobra.Command {
 		server          string
 		token           string
 		insecureSkipTLS bool
+		timezone        string
 	)
 
 	cmd := &cobra.Command{
@@ -83,12 +90,18 @@ func newConfigSetContextCmd() *cobra.Command {
 			if server == "" {
 				return fmt.Errorf("server URL is required")
 			}
+			if cmd.Flags().Changed("timezone") {
+				if err := config.ValidateTimezone(timezone); err != nil {
+					return err
+				}
+			}
 
 			context := &config.Context{
 				Name:               name,
 				Server:             server,
 				Token:              token,
 				InsecureSkipVerify: insecureSkipTLS,
+				Timezone:           timezone,
 			}
 
 			cfg.AddContext(name, context)
@@ -109,6 +122,7 @@ func newConfigSetContextCmd() *cobra.Command {
 	cmd.Flags().StringVar(&server, "server", "", "Server URL (required)")
 	cmd.Flags().StringVar(&token, "token", "", "Authentication token")
 	cmd.Flags().BoolVar(&insecureSkipTLS, "insecure-skip-tls", false, "Skip TLS certificate verification")
+	cmd.Flags().StringVar(&timezone, "timezone", "", "Time zone to show times in: an IANA name, or local for each display's own zone (default UTC)")
 
 	markFlagRequired(cmd, "server")
 
@@ -175,10 +189,14 @@ func newConfigViewCmd() *cobra.Command {
 			default:
 				fmt.Printf("Current Context: %s\n\n", cfg.CurrentContext)
 				fmt.Printf("Contexts:\n")
-				for name, ctx := range cfg.Contexts {
+				for _, name := range util.SortedKeys(cfg.Contexts) {
+					ctx := cfg.Contexts[name]
 					fmt.Printf("- %s:\n", name)
 					fmt.Printf("    Server: %s\n", ctx.Server)
 					fmt.Printf("    InsecureSkipVerify: %v\n", ctx.InsecureSkipVerify)
+					if ctx.Timezone != "" {
+						fmt.Printf("    Timezone: %s\n", ctx.Timezone)
+					}
 					if ctx.Token != "" {
 						fmt.Printf("    Token: %s...\n", ctx.Token[:10])
 					}
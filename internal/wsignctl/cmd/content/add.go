@@ -1,11 +1,12 @@
 package content
 
 import (
+	"errors"
 	"fmt"
-	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/client"
 	"github.com/wrale/wrale-signage/internal/wsignctl/util"
 )
 
@@ -14,6 +15,9 @@ func newAddCmd() *cobra.Command {
 		url         string
 		contentType string
 		properties  []string
+		allowed     []string
+		tags        []string
+		strict      bool
 	)
 
 	cmd := &cobra.Command{
@@ -22,10 +26,16 @@ func newAddCmd() *cobra.Command {
 		Long: `Add a new content source that displays can be redirected to.
 
 A content source needs:
-- A unique name for referring to it in redirect rules
+- A unique name for referring to it in redirect rules. Names are not
+  case-sensitive and are stored in lower case
 - A URL where content can be found
 - A content type that identifies what kind of content this is
-- Optional properties for additional metadata`,
+- Optional properties for additional metadata
+- Optional allowed paths that assignments may point at below the URL
+- Optional tags for grouping sources, such as all emergency content
+
+The server fetches the URL when the source is added and records the result on
+the source's status. Use --strict to reject the source if the fetch fails.`,
 		Example: `  # Add a basic content source
   wsignctl content add menus --url=https://menu.example.com --type=menu
   
@@ -34,19 +44,25 @@ A content source needs:
     --url=https://intranet.example.com/signage \
     --type=internal \
     --property=department=hr \
-    --property=audience=employees`,
+    --property=audience=employees
+
+  # Let assignments point at individual menus below the source URL
+  wsignctl content add menus --url=https://menu.example.com --type=menu \
+    --allowed-path=/breakfast --allowed-path=/lunch
+
+  # Tag a source so it can be found with 'content list --tag'
+  wsignctl content add fire-alert --url=https://alerts.example.com/fire --type=alert \
+    --tag=emergency --tag=lobby
+
+  # Refuse to add a source whose content cannot be fetched
+  wsignctl content add alerts --url=https://alerts.example.com --type=alert --strict`,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			name := args[0]
 
-			// Parse properties into map
-			props := make(map[string]string)
-			for _, prop := range properties {
-				parts := strings.SplitN(prop, "=", 2)
-				if len(parts) != 2 {
-					return fmt.Errorf("invalid property format %q - use Key=Value", prop)
-				}
-				props[parts[0]] = parts[1]
+			props, err := util.ParseLabels("property", properties)
+			if err != nil {
+				return err
 			}
 
 			// Create the content source
@@ -59,9 +75,11 @@ A content source needs:
 					Name: name,
 				},
 				Spec: v1alpha1.ContentSourceSpec{
-					URL:        url,
-					Type:       contentType,
-					Properties: props,
+					URL:          url,
+					Type:         contentType,
+					Properties:   props,
+					AllowedPaths: allowed,
+					Tags:         tags,
 				},
 			}
 
@@ -71,11 +89,18 @@ A content source needs:
 				return fmt.Errorf("failed to create API client: %w", err)
 			}
 
-			if err := c.AddContentSource(cmd.Context(), source); err != nil {
+			created, err := c.AddContentSource(cmd.Context(), source, strict)
+			var exists *client.ContentSourceExistsError
+			if errors.As(err, &exists) {
+				return fmt.Errorf("content source %q already exists with URL %s; use 'wsignctl content update %s' to change it",
+					exists.Existing.Name, exists.Existing.Spec.URL, exists.Existing.Name)
+			}
+			if err != nil {
 				return fmt.Errorf("error adding content source: %w", err)
 			}
 
-			fmt.Printf("Content source %q added\n", name)
+			fmt.Printf("Content source %q added\n", created.Name)
+			printValidationWarning(cmd, created)
 			return nil
 		},
 	}
@@ -83,6 +108,9 @@ A content source needs:
 	cmd.Flags().StringVar(&url, "url", "", "URL where content can be found (required)")
 	cmd.Flags().StringVar(&contentType, "type", "", "Type of content (required)")
 	cmd.Flags().StringArrayVar(&properties, "property", nil, "Additional properties in Key=Value format")
+	cmd.Flags().StringArrayVar(&allowed, "allowed-path", nil, "Path below the URL that assignments may use (repeatable)")
+	cmd.Flags().StringArrayVar(&tags, "tag", nil, "Tag for grouping the source (repeatable)")
+	cmd.Flags().BoolVar(&strict, "strict", false, "Reject the source if content validation fails")
 
 	if err := cmd.MarkFlagRequired("url"); err != nil {
 		// This should only happen during development
@@ -0,0 +1,285 @@
+package content
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/client"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+func newAssignCmd() *cobra.Command {
+	var (
+		selector v1alpha1.DisplaySelector
+		path     string
+		from     string
+		until    string
+		days     string
+		tz       string
+
+		waitPrefetch    float64
+		prefetchTimeout time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "assign SOURCE",
+		Short: "Show a content source on the displays at a location",
+		Long: `Create a content assignment from a content source to a display location.
+
+The assignment points at the source's URL. Use --path to point at a page below
+it instead; the path must be one of the source's allowed paths or lie beneath
+one. Once created, the displays currently registered at the location are
+listed so you can check the assignment reaches the screens you meant.
+
+Use --display instead of a location to show the content on one display,
+named or by ID. Such an assignment takes precedence over every assignment to
+the display's location and follows the display when it is renamed.
+
+--from and --until take either RFC3339 times, bounding when the assignment is
+valid, or HH:MM times of day, showing it in the same window every day. Use
+--days to limit the window to some days of the week and --tz to read it in a
+time zone other than the one configured for the site.
+
+With --wait-for-prefetch the command then waits until the given percentage of
+the displays the content is assigned to, 100 when no value is given, report
+having downloaded all of it. This is most useful with an assignment that
+starts later, so that large media is on the screens before it goes live.`,
+		Example: `  # Show the menus in the cafeteria at HQ
+  wsignctl content assign menus --site-id=hq --zone=cafeteria
+
+  # Show the events calendar on the portrait displays at HQ
+  wsignctl content assign events --site-id=hq --match-label orientation=portrait
+
+  # Show the breakfast menu on one board until 10:30 on weekdays
+  wsignctl content assign menus --site-id=hq --zone=cafeteria --position=menu-1 \
+    --path=/breakfast --days mon-fri --from 06:00 --until 10:30 --tz America/New_York
+
+  # Show the visitor sign-in page on the reception iPad only
+  wsignctl content assign visitors --display=reception-ipad
+
+  # Show the summer menu until the end of August
+  wsignctl content assign menus --site-id=hq --zone=cafeteria --path=/lunch \
+    --until=2024-09-01T00:00:00Z
+
+  # Roll out a new video tonight once most lobby displays have fetched it
+  wsignctl content assign promo --site-id=hq --zone=lobby \
+    --from=2024-06-01T00:00:00Z --wait-for-prefetch=90 --prefetch-timeout=2h`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			waiting := cmd.Flags().Changed("wait-for-prefetch")
+			if waiting && (waitPrefetch <= 0 || waitPrefetch > 100) {
+				return fmt.Errorf("--wait-for-prefetch takes a percentage of displays above 0 and up to 100")
+			}
+			schedule, err := dailySchedule(from, until, days, tz)
+			if err != nil {
+				return err
+			}
+			var window *v1alpha1.Schedule
+			if schedule == nil {
+				if window, err = util.ParseSchedule(from, until, nil, ""); err != nil {
+					return err
+				}
+			}
+
+			c, err := util.GetClientFromCommand(cmd)
+			if err != nil {
+				return err
+			}
+
+			source, err := lookupSource(cmd.Context(), c, args[0])
+			if err != nil {
+				return err
+			}
+			contentURL, err := resolveContentURL(source, path)
+			if err != nil {
+				return err
+			}
+
+			a := &v1alpha1.ContentAssignment{
+				TypeMeta:        v1alpha1.TypeMeta{Kind: "ContentAssignment", APIVersion: "v1alpha1"},
+				Source:          source.Name,
+				DisplaySelector: selector,
+				ContentURL:      contentURL,
+				Schedule:        schedule,
+			}
+			if window != nil {
+				a.ValidFrom = window.ActiveFrom
+				a.ValidUntil = window.ActiveUntil
+			}
+
+			created, err := c.CreateContentAssignment(cmd.Context(), a)
+			if err != nil {
+				return fmt.Errorf("error creating assignment: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Assignment %s created: %s -> %s (%s)\n",
+				created.ID, source.Name, util.FormatSelectors(selector), contentURL)
+			printAssignmentWarnings(cmd, created)
+
+			if selector.DisplayRef == "" {
+				matched, err := c.ListDisplays(cmd.Context(), selector)
+				if err != nil {
+					return fmt.Errorf("assignment created, but listing matching displays failed: %w", err)
+				}
+				printMatchedDisplays(cmd, matched)
+			} else if created.Status != nil && created.Status.DisplayName != "" {
+				fmt.Fprintf(cmd.OutOrStdout(), "Targets display %s (%s)\n",
+					created.Status.DisplayName, created.DisplaySelector.DisplayRef)
+			}
+			if waiting {
+				return waitForPrefetch(cmd, c, contentURL, waitPrefetch, prefetchTimeout)
+			}
+			return nil
+		},
+	}
+
+	addSelectorFlags(cmd, &selector)
+	cmd.Flags().StringVar(&path, "path", "", "Path below the source URL to show, from the source's allowed paths")
+	cmd.Flags().StringVar(&from, "from", "", "When the assignment starts (RFC3339), or when its daily window opens (HH:MM)")
+	cmd.Flags().StringVar(&until, "until", "", "When the assignment ends (RFC3339), or when its daily window closes (HH:MM)")
+	cmd.Flags().StringVar(&days, "days", "", "Days of the week the window opens on, such as mon-fri or sat,sun")
+	cmd.Flags().StringVar(&tz, "tz", "", "IANA time zone of the window, such as America/New_York (default the site's)")
+	cmd.Flags().Float64Var(&waitPrefetch, "wait-for-prefetch", 0, "Wait until this percentage of the target displays have downloaded the content")
+	cmd.Flags().Lookup("wait-for-prefetch").NoOptDefVal = "100"
+	cmd.Flags().DurationVar(&prefetchTimeout, "prefetch-timeout", 30*time.Minute, "How long --wait-for-prefetch waits before giving up")
+
+	return cmd
+}
+
+// dailySchedule builds the recurring schedule given by times of day, days
+// of the week and a time zone. It returns nil when from and until are not
+// times of day and no days are given, leaving them to be read as RFC3339
+// validity bounds. Days without times cover the whole of each day.
+func dailySchedule(from, until, days, tz string) (*v1alpha1.RecurringSchedule, error) {
+	_, fromErr := v1alpha1.ParseClock(from)
+	_, untilErr := v1alpha1.ParseClock(until)
+	clockFrom, clockUntil := from != "" && fromErr == nil, until != "" && untilErr == nil
+
+	switch {
+	case clockFrom != clockUntil:
+		return nil, fmt.Errorf("give both --from and --until as HH:MM for a daily window")
+	case !clockFrom && days == "":
+		if tz != "" {
+			return nil, fmt.Errorf("--tz applies to daily windows; give --from and --until as HH:MM or --days")
+		}
+		return nil, nil
+	}
+
+	window := v1alpha1.ScheduleWindow{TimeOfDay: v1alpha1.TimeRange{Start: "00:00", End: "24:00"}}
+	if clockFrom {
+		window.TimeOfDay = v1alpha1.TimeRange{Start: from, End: until}
+	} else if from != "" || until != "" {
+		return nil, fmt.Errorf("--days takes --from and --until as HH:MM, not dates")
+	}
+	if days != "" {
+		var err error
+		if window.DaysOfWeek, err = v1alpha1.ParseWeekdays(days); err != nil {
+			return nil, fmt.Errorf("invalid --days: %w", err)
+		}
+	}
+	return &v1alpha1.RecurringSchedule{Timezone: tz, Windows: []v1alpha1.ScheduleWindow{window}}, nil
+}
+
+// addSelectorFlags registers the location, label and display flags shared
+// by assign and unassign; either a site or a single display is required
+func addSelectorFlags(cmd *cobra.Command, selector *v1alpha1.DisplaySelector) {
+	cmd.Flags().StringVar(&selector.SiteID, "site-id", "", "Site of the target displays")
+	cmd.Flags().StringVar(&selector.Zone, "zone", "", "Zone of the target displays")
+	cmd.Flags().StringVar(&selector.Position, "position", "", "Position of the target display within the zone")
+	cmd.Flags().StringToStringVar(&selector.MatchProperties, "match-label", nil, "Display property the targets must have, as key=value (repeatable)")
+	cmd.Flags().StringVar(&selector.DisplayRef, "display", "", "Name or ID of the one display to target, instead of a location")
+
+	cmd.MarkFlagsOneRequired("site-id", "display")
+	for _, flag := range []string{"site-id", "zone", "position", "match-label"} {
+		cmd.MarkFlagsMutuallyExclusive("display", flag)
+	}
+}
+
+// lookupSource fetches a content source, explaining what to do when it
+// does not exist
+func lookupSource(ctx context.Context, c *client.Client, name string) (*v1alpha1.ContentSource, error) {
+	source, err := c.GetContentSource(ctx, name)
+	if client.IsNotFound(err) {
+		return nil, fmt.Errorf("content source %q not found; run 'wsignctl content list' to see the available sources", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error looking up content source %q: %w", name, err)
+	}
+	return source, nil
+}
+
+// resolveContentURL returns the URL an assignment of source should point at.
+// An empty path selects the source URL itself; anything else must be an
+// allowed path of the source or lie beneath one.
+func resolveContentURL(source *v1alpha1.ContentSource, path string) (string, error) {
+	if path == "" {
+		return source.Spec.URL, nil
+	}
+	if !strings.HasPrefix(path, "/") || strings.Contains(path, "..") {
+		return "", fmt.Errorf("invalid path %q: it must start with / and may not contain ..", path)
+	}
+
+	if !pathAllowed(source.Spec.AllowedPaths, path) {
+		if len(source.Spec.AllowedPaths) == 0 {
+			return "", fmt.Errorf("content source %q does not allow paths; assign it without --path", source.Name)
+		}
+		return "", fmt.Errorf("path %q is not allowed for content source %q; allowed paths: %s",
+			path, source.Name, strings.Join(source.Spec.AllowedPaths, ", "))
+	}
+
+	u, err := url.Parse(source.Spec.URL)
+	if err != nil {
+		return "", fmt.Errorf("content source %q has an invalid URL: %w", source.Name, err)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	return u.String(), nil
+}
+
+// pathAllowed reports whether path equals or lies beneath an allowed path
+func pathAllowed(allowed []string, path string) bool {
+	for _, p := range allowed {
+		p = strings.TrimSuffix(p, "/")
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// printAssignmentWarnings writes the server's warnings about an assignment,
+// such as a target display that is disabled, to stderr
+func printAssignmentWarnings(cmd *cobra.Command, a *v1alpha1.ContentAssignment) {
+	if a.Status == nil {
+		return
+	}
+	for _, warning := range a.Status.Warnings {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s\n", warning)
+	}
+}
+
+// printMatchedDisplays lists the displays an assignment currently reaches
+func printMatchedDisplays(cmd *cobra.Command, displays []v1alpha1.Display) {
+	out := cmd.OutOrStdout()
+	if len(displays) == 0 {
+		fmt.Fprintln(out, "No registered displays match yet")
+		return
+	}
+
+	fmt.Fprintf(out, "Matches %d registered display(s):\n", len(displays))
+	tw := util.NewTabWriter(out)
+	defer tw.Flush()
+	fmt.Fprintf(tw, "  NAME\tSITE\tZONE\tPOSITION\tSTATE\n")
+	for _, d := range displays {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n",
+			d.Name,
+			d.Spec.Location.SiteID,
+			d.Spec.Location.Zone,
+			d.Spec.Location.Position,
+			d.Status.State)
+	}
+}
@@ -0,0 +1,424 @@
+package content
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+)
+
+// fakeServer serves one content source, the assignments API and a display
+// list filtered by site, zone and properties
+type fakeServer struct {
+	source      v1alpha1.ContentSource
+	displays    []v1alpha1.Display
+	assignments []v1alpha1.ContentAssignment
+	// prefetch are the prefetch statuses served in turn, the last one
+	// repeating
+	prefetch []v1alpha1.PrefetchStatus
+	// updates are the content source updates received
+	updates []v1alpha1.ContentSourceUpdate
+	// previews are the preview requests received
+	previews []v1alpha1.ContentPreviewRequest
+	// preview is the preview served
+	preview v1alpha1.ContentPreview
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1alpha1/content/"+f.source.Name:
+		_ = json.NewEncoder(w).Encode(f.source)
+	case r.Method == http.MethodPatch && r.URL.Path == "/api/v1alpha1/content/"+f.source.Name:
+		var update v1alpha1.ContentSourceUpdate
+		_ = json.NewDecoder(r.Body).Decode(&update)
+		f.updates = append(f.updates, update)
+		if update.Fallbacks != nil {
+			f.source.Spec.Fallbacks = *update.Fallbacks
+		}
+		f.source.Status.Version++
+		_ = json.NewEncoder(w).Encode(v1alpha1.ContentSourceUpdateResult{ContentSource: f.source})
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1alpha1/content/"+f.source.Name+"/preview":
+		var req v1alpha1.ContentPreviewRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		f.previews = append(f.previews, req)
+		_ = json.NewEncoder(w).Encode(f.preview)
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1alpha1/displays":
+		query := r.URL.Query()
+		properties, _ := v1alpha1.ParseMatchProperties(query[v1alpha1.MatchPropertyParam])
+		selector := v1alpha1.DisplaySelector{
+			SiteID:          query.Get("siteId"),
+			Zone:            query.Get("zone"),
+			MatchProperties: properties,
+		}
+		var matched []v1alpha1.Display
+		for _, d := range f.displays {
+			if selector.SiteID != "" && selector.Matches(d.Spec.Location, d.Spec.Properties) {
+				matched = append(matched, d)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(matched)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1alpha1/assignments":
+		var a v1alpha1.ContentAssignment
+		_ = json.NewDecoder(r.Body).Decode(&a)
+		a.ID = uuid.New()
+		f.assignments = append(f.assignments, a)
+		if a.DisplaySelector.DisplayRef != "" {
+			a.Status = &v1alpha1.ContentAssignmentStatus{
+				DisplayName: a.DisplaySelector.DisplayRef,
+				Warnings:    []string{"display " + a.DisplaySelector.DisplayRef + " is disabled"},
+			}
+		}
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(a)
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1alpha1/assignments":
+		var matched []v1alpha1.ContentAssignment
+		for _, a := range f.assignments {
+			if a.Source == r.URL.Query().Get("source") {
+				matched = append(matched, a)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(matched)
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1alpha1/content/prefetch-status":
+		status := f.prefetch[0]
+		if len(f.prefetch) > 1 {
+			f.prefetch = f.prefetch[1:]
+		}
+		status.URL = r.URL.Query().Get("url")
+		_ = json.NewEncoder(w).Encode(status)
+	case r.Method == http.MethodDelete:
+		for i, a := range f.assignments {
+			if r.URL.Path == "/api/v1alpha1/assignments/"+a.ID.String() {
+				f.assignments = append(f.assignments[:i], f.assignments[i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(v1alpha1.Error{Code: "NOT_FOUND", Message: "not found"})
+	}
+}
+
+func newFakeServer(t *testing.T) (*fakeServer, *httptest.Server) {
+	t.Setenv("WSIGN_INSECURE_SKIP_TLS", "false")
+	f := &fakeServer{
+		source: v1alpha1.ContentSource{
+			ObjectMeta: v1alpha1.ObjectMeta{Name: "menus"},
+			Spec: v1alpha1.ContentSourceSpec{
+				URL:          "https://menu.example.com/boards",
+				Type:         "menu",
+				AllowedPaths: []string{"/breakfast", "/lunch"},
+			},
+		},
+		displays: []v1alpha1.Display{
+			{
+				ObjectMeta: v1alpha1.ObjectMeta{Name: "cafeteria-main"},
+				Spec:       v1alpha1.DisplaySpec{Location: v1alpha1.DisplayLocation{SiteID: "hq", Zone: "cafeteria", Position: "main"}},
+			},
+			{
+				ObjectMeta: v1alpha1.ObjectMeta{Name: "lobby-north"},
+				Spec: v1alpha1.DisplaySpec{
+					Location:   v1alpha1.DisplayLocation{SiteID: "hq", Zone: "lobby", Position: "north"},
+					Properties: map[string]string{"orientation": "portrait"},
+				},
+			},
+		},
+	}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+// run executes cmd against server and returns its output
+func run(t *testing.T, cmd *cobra.Command, server *httptest.Server, args ...string) (string, error) {
+	cmd.Flags().String("server", server.URL, "")
+	cmd.Flags().String("token", "test-token", "")
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestResolveContentURL(t *testing.T) {
+	source := &v1alpha1.ContentSource{
+		ObjectMeta: v1alpha1.ObjectMeta{Name: "menus"},
+		Spec: v1alpha1.ContentSourceSpec{
+			URL:          "https://menu.example.com/boards/?theme=dark",
+			AllowedPaths: []string{"/breakfast", "/lunch/"},
+		},
+	}
+
+	tests := []struct {
+		path    string
+		want    string
+		wantErr string
+	}{
+		{path: "", want: "https://menu.example.com/boards/?theme=dark"},
+		{path: "/breakfast", want: "https://menu.example.com/boards/breakfast?theme=dark"},
+		{path: "/lunch/specials", want: "https://menu.example.com/boards/lunch/specials?theme=dark"},
+		{path: "/breakfast-club", wantErr: "allowed paths: /breakfast, /lunch/"},
+		{path: "/dinner", wantErr: "not allowed"},
+		{path: "breakfast", wantErr: "must start with /"},
+		{path: "/breakfast/../admin", wantErr: "may not contain .."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := resolveContentURL(source, tt.path)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("source without allowed paths", func(t *testing.T) {
+		bare := &v1alpha1.ContentSource{
+			ObjectMeta: v1alpha1.ObjectMeta{Name: "welcome"},
+			Spec:       v1alpha1.ContentSourceSpec{URL: "https://example.com/welcome"},
+		}
+		_, err := resolveContentURL(bare, "/today")
+		assert.ErrorContains(t, err, "does not allow paths")
+	})
+}
+
+func TestAssignCommand(t *testing.T) {
+	t.Run("creates the assignment and previews matching displays", func(t *testing.T) {
+		f, server := newFakeServer(t)
+
+		out, err := run(t, newAssignCmd(), server,
+			"menus", "--site-id=hq", "--zone=cafeteria", "--path=/lunch", "--until=2024-06-01T14:00:00Z")
+		require.NoError(t, err)
+
+		require.Len(t, f.assignments, 1)
+		a := f.assignments[0]
+		assert.Equal(t, "menus", a.Source)
+		assert.Equal(t, "https://menu.example.com/boards/lunch", a.ContentURL)
+		assert.Equal(t, v1alpha1.DisplaySelector{SiteID: "hq", Zone: "cafeteria"}, a.DisplaySelector)
+		require.NotNil(t, a.ValidUntil)
+		assert.Nil(t, a.ValidFrom)
+
+		assert.Contains(t, out, "Assignment "+a.ID.String()+" created")
+		assert.Contains(t, out, "Matches 1 registered display(s)")
+		assert.Contains(t, out, "cafeteria-main")
+		assert.NotContains(t, out, "lobby-north")
+	})
+
+	t.Run("match labels narrow the target displays", func(t *testing.T) {
+		f, server := newFakeServer(t)
+
+		out, err := run(t, newAssignCmd(), server,
+			"menus", "--site-id=hq", "--match-label", "orientation=portrait")
+		require.NoError(t, err)
+
+		require.Len(t, f.assignments, 1)
+		assert.Equal(t, map[string]string{"orientation": "portrait"}, f.assignments[0].DisplaySelector.MatchProperties)
+		assert.Contains(t, out, "orientation=portrait")
+		assert.Contains(t, out, "lobby-north")
+		assert.NotContains(t, out, "cafeteria-main")
+	})
+
+	t.Run("reports when no displays match", func(t *testing.T) {
+		_, server := newFakeServer(t)
+
+		out, err := run(t, newAssignCmd(), server, "menus", "--site-id=hq", "--zone=rooftop")
+		require.NoError(t, err)
+		assert.Contains(t, out, "No registered displays match yet")
+	})
+
+	t.Run("a single display", func(t *testing.T) {
+		f, server := newFakeServer(t)
+
+		out, err := run(t, newAssignCmd(), server, "menus", "--display=reception-ipad")
+		require.NoError(t, err)
+
+		require.Len(t, f.assignments, 1)
+		assert.Equal(t, v1alpha1.DisplaySelector{DisplayRef: "reception-ipad"}, f.assignments[0].DisplaySelector)
+		assert.Contains(t, out, "Targets display reception-ipad")
+		assert.Contains(t, out, "Warning: display reception-ipad is disabled")
+		assert.NotContains(t, out, "Matches", "no location to preview")
+	})
+
+	t.Run("a display or a site is required, not both", func(t *testing.T) {
+		f, server := newFakeServer(t)
+
+		_, err := run(t, newAssignCmd(), server, "menus")
+		assert.ErrorContains(t, err, "at least one of the flags")
+		_, err = run(t, newAssignCmd(), server, "menus", "--display=reception-ipad", "--zone=lobby")
+		assert.ErrorContains(t, err, "none of the others can be")
+		assert.Empty(t, f.assignments)
+	})
+
+	t.Run("unknown source", func(t *testing.T) {
+		f, server := newFakeServer(t)
+
+		_, err := run(t, newAssignCmd(), server, "specials", "--site-id=hq")
+		assert.ErrorContains(t, err, `content source "specials" not found`)
+		assert.ErrorContains(t, err, "wsignctl content list")
+		assert.Empty(t, f.assignments)
+	})
+
+	t.Run("disallowed path", func(t *testing.T) {
+		f, server := newFakeServer(t)
+
+		_, err := run(t, newAssignCmd(), server, "menus", "--site-id=hq", "--path=/admin")
+		assert.ErrorContains(t, err, "allowed paths: /breakfast, /lunch")
+		assert.Empty(t, f.assignments)
+	})
+
+	t.Run("daily window", func(t *testing.T) {
+		f, server := newFakeServer(t)
+
+		_, err := run(t, newAssignCmd(), server, "menus", "--site-id=hq", "--path=/breakfast",
+			"--days", "mon-fri", "--from", "06:00", "--until", "10:30", "--tz", "America/New_York")
+		require.NoError(t, err)
+
+		require.Len(t, f.assignments, 1)
+		a := f.assignments[0]
+		assert.Nil(t, a.ValidFrom)
+		assert.Nil(t, a.ValidUntil)
+		assert.Equal(t, &v1alpha1.RecurringSchedule{
+			Timezone: "America/New_York",
+			Windows: []v1alpha1.ScheduleWindow{{
+				DaysOfWeek: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+				TimeOfDay:  v1alpha1.TimeRange{Start: "06:00", End: "10:30"},
+			}},
+		}, a.Schedule)
+	})
+}
+
+func TestAssignWaitForPrefetch(t *testing.T) {
+	interval := prefetchPollInterval
+	prefetchPollInterval = time.Millisecond
+	t.Cleanup(func() { prefetchPollInterval = interval })
+
+	t.Run("waits until enough displays have prefetched", func(t *testing.T) {
+		f, server := newFakeServer(t)
+		f.prefetch = []v1alpha1.PrefetchStatus{
+			{Complete: 0, Targeted: 4},
+			{Complete: 2, Targeted: 4},
+			{Complete: 3, Targeted: 4},
+			{Complete: 4, Targeted: 4},
+		}
+
+		out, err := run(t, newAssignCmd(), server, "menus", "--site-id=hq", "--wait-for-prefetch=75")
+		require.NoError(t, err)
+		assert.Contains(t, out, "Prefetched by 2 of 4 display(s)")
+		assert.Contains(t, out, "Prefetched by 3 of 4 display(s)")
+		assert.NotContains(t, out, "Prefetched by 4 of 4", "stops polling once the threshold is met")
+	})
+
+	t.Run("gives up after the timeout", func(t *testing.T) {
+		f, server := newFakeServer(t)
+		f.prefetch = []v1alpha1.PrefetchStatus{{Complete: 1, Targeted: 2}}
+
+		_, err := run(t, newAssignCmd(), server, "menus", "--site-id=hq", "--wait-for-prefetch", "--prefetch-timeout=20ms")
+		assert.ErrorContains(t, err, "timed out after 20ms waiting for 100% of displays")
+		assert.Len(t, f.assignments, 1, "the assignment is kept")
+	})
+
+	t.Run("does not wait when no displays are assigned", func(t *testing.T) {
+		f, server := newFakeServer(t)
+		f.prefetch = []v1alpha1.PrefetchStatus{{}}
+
+		out, err := run(t, newAssignCmd(), server, "menus", "--site-id=hq", "--wait-for-prefetch")
+		require.NoError(t, err)
+		assert.Contains(t, out, "not waiting for prefetch")
+	})
+
+	t.Run("rejects percentages out of range", func(t *testing.T) {
+		f, server := newFakeServer(t)
+
+		_, err := run(t, newAssignCmd(), server, "menus", "--site-id=hq", "--wait-for-prefetch=150")
+		assert.ErrorContains(t, err, "--wait-for-prefetch")
+		assert.Empty(t, f.assignments)
+	})
+}
+
+func TestDailySchedule(t *testing.T) {
+	weekend := []time.Weekday{time.Sunday, time.Saturday}
+	tests := []struct {
+		name                  string
+		from, until, days, tz string
+		want                  *v1alpha1.RecurringSchedule
+		wantErr               string
+	}{
+		{name: "none"},
+		{name: "validity dates", from: "2024-06-01T00:00:00Z", until: "2024-09-01T00:00:00Z"},
+		{
+			name: "every day", from: "11:00", until: "14:00",
+			want: &v1alpha1.RecurringSchedule{Windows: []v1alpha1.ScheduleWindow{{TimeOfDay: v1alpha1.TimeRange{Start: "11:00", End: "14:00"}}}},
+		},
+		{
+			name: "whole days", days: "sat,sun",
+			want: &v1alpha1.RecurringSchedule{Windows: []v1alpha1.ScheduleWindow{{DaysOfWeek: weekend, TimeOfDay: v1alpha1.TimeRange{Start: "00:00", End: "24:00"}}}},
+		},
+		{name: "one time of day", from: "06:00", wantErr: "give both --from and --until"},
+		{name: "time of day and date", from: "06:00", until: "2024-09-01T00:00:00Z", wantErr: "give both --from and --until"},
+		{name: "days with dates", days: "mon", until: "2024-09-01T00:00:00Z", wantErr: "not dates"},
+		{name: "time zone alone", tz: "Europe/London", wantErr: "--tz applies to daily windows"},
+		{name: "bad days", days: "mon-funday", from: "06:00", until: "10:30", wantErr: "invalid --days"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dailySchedule(tt.from, tt.until, tt.days, tt.tz)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUnassignCommand(t *testing.T) {
+	f, server := newFakeServer(t)
+	zone := v1alpha1.DisplaySelector{SiteID: "hq", Zone: "cafeteria"}
+	board := v1alpha1.DisplaySelector{SiteID: "hq", Zone: "cafeteria", Position: "menu-1"}
+	portrait := v1alpha1.DisplaySelector{SiteID: "hq", Zone: "cafeteria", MatchProperties: map[string]string{"orientation": "portrait"}}
+	f.assignments = []v1alpha1.ContentAssignment{
+		{ObjectMeta: v1alpha1.ObjectMeta{ID: uuid.New()}, Source: "menus", DisplaySelector: zone},
+		{ObjectMeta: v1alpha1.ObjectMeta{ID: uuid.New()}, Source: "menus", DisplaySelector: board},
+		{ObjectMeta: v1alpha1.ObjectMeta{ID: uuid.New()}, Source: "menus", DisplaySelector: portrait},
+	}
+
+	out, err := run(t, newUnassignCmd(), server, "menus", "--site-id=hq", "--zone=cafeteria")
+	require.NoError(t, err)
+	assert.Contains(t, out, "removed")
+
+	// The single-board and labelled assignments have different selectors
+	// and stay
+	require.Len(t, f.assignments, 2)
+	assert.Equal(t, board, f.assignments[0].DisplaySelector)
+	assert.Equal(t, portrait, f.assignments[1].DisplaySelector)
+
+	_, err = run(t, newUnassignCmd(), server, "menus", "--site-id=hq", "--zone=cafeteria", "--match-label", "orientation=portrait")
+	require.NoError(t, err)
+	require.Len(t, f.assignments, 1)
+
+	_, err = run(t, newUnassignCmd(), server, "menus", "--site-id=hq", "--zone=cafeteria")
+	assert.ErrorContains(t, err, "no assignments")
+
+	// The server lists only the assignments naming the display, by its ID
+	ipad := v1alpha1.DisplaySelector{DisplayRef: uuid.NewString()}
+	f.assignments = append(f.assignments,
+		v1alpha1.ContentAssignment{ObjectMeta: v1alpha1.ObjectMeta{ID: uuid.New()}, Source: "menus", DisplaySelector: ipad})
+	_, err = run(t, newUnassignCmd(), server, "menus", "--display=reception-ipad")
+	require.NoError(t, err)
+	require.Len(t, f.assignments, 1)
+	assert.Equal(t, board, f.assignments[0].DisplaySelector)
+}
@@ -25,7 +25,14 @@ For example:
 		newAddCmd(),
 		newListCmd(),
 		newUpdateCmd(),
+		newSetFallbacksCmd(),
 		newRemoveCmd(),
+		newStatusCmd(),
+		newHealthCmd(),
+		newPreviewCmd(),
+		newEventsCmd(),
+		newAssignCmd(),
+		newUnassignCmd(),
 	)
 
 	return cmd
@@ -0,0 +1,128 @@
+package content
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/client"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+func newEventsCmd() *cobra.Command {
+	var (
+		query  client.ContentEventQuery
+		limit  int
+		format string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "events",
+		Short: "Query the raw events displays reported",
+		Long: `Query the content events displays reported, oldest first, for analysis
+outside wsignd.
+
+Events can be narrowed to one URL, display or event type. --since and --until
+take a duration before now such as 24h or 7d, or an RFC 3339 time; a single
+query covers at most 31 days.
+
+The ndjson format writes one JSON event per line as the server streams them,
+which suits exporting large windows to a file.
+
+Times are shown in this machine's zone unless --tz or the context names
+one; --tz local shows each event in the zone of the display reporting it.`,
+		Example: `  # Show the last day of events for a URL
+  wsignctl content events --url https://menu.example.com/
+
+  # Show the errors one display reported this week
+  wsignctl content events --display 7d3c... --type CONTENT_ERROR --since 7d
+
+  # Show when each display saw a URL, in the display's own time
+  wsignctl content events --url https://menu.example.com/ --tz local
+
+  # Export a day of events for offline analysis
+  wsignctl content events --url https://menu.example.com/ --since 24h --format ndjson > events.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tz, err := util.Timezone(cmd)
+			if err != nil {
+				return err
+			}
+			query.Timezone = tz
+
+			c, err := util.GetClientFromCommand(cmd)
+			if err != nil {
+				return err
+			}
+
+			if format == "ndjson" {
+				if err := c.ExportContentEvents(cmd.Context(), query, limit, cmd.OutOrStdout()); err != nil {
+					return fmt.Errorf("error exporting content events: %w", err)
+				}
+				return nil
+			}
+			if format != "table" && format != "json" {
+				return fmt.Errorf("--format must be table, json or ndjson")
+			}
+
+			var events []v1alpha1.ContentEvent
+			err = c.ForEachContentEvent(cmd.Context(), query, limit, func(e v1alpha1.ContentEvent) error {
+				events = append(events, e)
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("error querying content events: %w", err)
+			}
+			if format == "json" {
+				return util.PrintJSON(cmd.OutOrStdout(), events)
+			}
+
+			tw := util.NewTabWriter(cmd.OutOrStdout())
+			defer tw.Flush()
+			fmt.Fprintln(tw, "TIME\tDISPLAY\tTYPE\tURL\tDETAIL")
+			for _, e := range events {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+					formatEventTime(e.Timestamp, query.Timezone),
+					e.DisplayID,
+					e.Type,
+					e.URL,
+					formatEventDetail(e),
+				)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&query.URL, "url", "", "Only events for this content URL")
+	cmd.Flags().StringVar(&query.DisplayID, "display", "", "Only events reported by this display ID")
+	cmd.Flags().StringVar(&query.Type, "type", "", "Only events of this type, such as CONTENT_ERROR")
+	cmd.Flags().StringVar(&query.Since, "since", "24h", "Start of the period, as a duration such as 24h or 7d or an RFC 3339 time")
+	cmd.Flags().StringVar(&query.Until, "until", "", "End of the period, in the same forms as --since (default now)")
+	cmd.Flags().IntVar(&limit, "limit", 0, "Maximum number of events (0 for all)")
+	cmd.Flags().StringVar(&format, "format", "table", "Output format (table, json, ndjson)")
+	util.AddTimezoneFlag(cmd)
+
+	return cmd
+}
+
+// formatEventTime formats an event's time for a table cell. Times the
+// server was asked to give in a zone keep its offset; others are shown in
+// this machine's zone.
+func formatEventTime(t time.Time, tz string) string {
+	if tz == "" {
+		t = t.Local()
+	}
+	return t.Format(time.RFC3339)
+}
+
+// formatEventDetail summarises what an event reported for a table cell
+func formatEventDetail(e v1alpha1.ContentEvent) string {
+	switch {
+	case e.Error != nil:
+		return e.Error.Code + ": " + e.Error.Message
+	case e.Metrics != nil:
+		return fmt.Sprintf("load %dms, render %dms", e.Metrics.LoadTime, e.Metrics.RenderTime)
+	}
+	return ""
+}
@@ -0,0 +1,76 @@
+package content
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/client"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+func newSetFallbacksCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set-fallbacks NAME FALLBACKS",
+		Short: "Set the sources shown while a content source is unhealthy",
+		Long: `Set the ordered list of content sources that displays are moved to while
+a content source is unhealthy.
+
+FALLBACKS is a comma-separated list of source names, tried in order; each
+fallback's own fallbacks are tried after it. The first one that is healthy is
+shown. A source is unhealthy when its last validation failed or displays
+report failing to load it too often. Give an empty list to remove the
+fallbacks.
+
+The fallbacks must exist and may not lead back to a source already in the
+chain.`,
+		Example: `  # Fall back to the static notice, then the building map
+  wsignctl content set-fallbacks emergency-feed emergency-static,building-map
+
+  # Remove the fallbacks
+  wsignctl content set-fallbacks emergency-feed ""`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			fallbacks := []string{}
+			for _, fallback := range strings.Split(args[1], ",") {
+				if fallback = strings.TrimSpace(fallback); fallback != "" {
+					fallbacks = append(fallbacks, fallback)
+				}
+			}
+
+			c, err := util.GetClientFromCommand(cmd)
+			if err != nil {
+				return err
+			}
+
+			source, err := lookupSource(cmd.Context(), c, name)
+			if err != nil {
+				return err
+			}
+			update := &v1alpha1.ContentSourceUpdate{
+				Fallbacks: &fallbacks,
+				Version:   source.Status.Version,
+			}
+			result, err := c.UpdateContentSource(cmd.Context(), source.Name, update, false)
+			if client.IsVersionMismatch(err) {
+				return fmt.Errorf("content source %q was changed by someone else; check it and retry: %w", name, err)
+			}
+			if err != nil {
+				return fmt.Errorf("error setting fallbacks: %w", err)
+			}
+
+			out := cmd.OutOrStdout()
+			if len(result.Spec.Fallbacks) == 0 {
+				fmt.Fprintf(out, "Content source %q has no fallbacks\n", result.Name)
+				return nil
+			}
+			fmt.Fprintf(out, "Content source %q falls back to: %s\n", result.Name, strings.Join(result.Spec.Fallbacks, ", "))
+			return nil
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,28 @@
+package content
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetFallbacksCommand(t *testing.T) {
+	f, server := newFakeServer(t)
+	f.source.Status.Version = 3
+
+	out, err := run(t, newSetFallbacksCmd(), server, "menus", "static-menu, menu-photo")
+	require.NoError(t, err)
+	require.Len(t, f.updates, 1)
+	require.NotNil(t, f.updates[0].Fallbacks)
+	assert.Equal(t, []string{"static-menu", "menu-photo"}, *f.updates[0].Fallbacks)
+	assert.Equal(t, 3, f.updates[0].Version, "the update is conditional on the version read")
+	assert.Contains(t, out, `"menus" falls back to: static-menu, menu-photo`)
+
+	out, err = run(t, newSetFallbacksCmd(), server, "menus", "")
+	require.NoError(t, err)
+	require.Len(t, f.updates, 2)
+	require.NotNil(t, f.updates[1].Fallbacks, "an empty list is sent to clear them")
+	assert.Empty(t, *f.updates[1].Fallbacks)
+	assert.Contains(t, out, "has no fallbacks")
+}
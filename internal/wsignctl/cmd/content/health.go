@@ -0,0 +1,94 @@
+package content
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+func newHealthCmd() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "health NAME",
+		Short: "Show how content is doing on displays",
+		Long: `Show the health and load metrics of a content source as reported by the
+displays showing it.
+
+Unlike status, which reports what the server saw when it fetched the content,
+health is judged from the load and error events displays send.`,
+		Example: `  # Show display-reported health for the menus source
+  wsignctl content health menus`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := util.GetClientFromCommand(cmd)
+			if err != nil {
+				return err
+			}
+
+			source, err := c.GetContentSource(cmd.Context(), args[0])
+			if err != nil {
+				return fmt.Errorf("error getting content source: %w", err)
+			}
+			health, err := c.GetContentHealth(cmd.Context(), source.Spec.URL)
+			if err != nil {
+				return fmt.Errorf("error getting content health: %w", err)
+			}
+			metrics, err := c.GetContentMetrics(cmd.Context(), source.Spec.URL)
+			if err != nil {
+				return fmt.Errorf("error getting content metrics: %w", err)
+			}
+
+			if output == "json" {
+				return util.PrintJSON(cmd.OutOrStdout(), struct {
+					Health  *v1alpha1.ContentHealth  `json:"health"`
+					Metrics *v1alpha1.ContentMetrics `json:"metrics"`
+				}{health, metrics})
+			}
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "Name:            %s\n", source.Name)
+			fmt.Fprintf(out, "URL:             %s\n", source.Spec.URL)
+			fmt.Fprintf(out, "Healthy:         %s\n", formatHealthy(health))
+			if len(health.Issues) > 0 {
+				fmt.Fprintf(out, "Issues:          %s\n", strings.Join(health.Issues, "; "))
+			}
+			fmt.Fprintf(out, "Last Seen:       %s\n", formatValidated(metrics.LastSeen))
+			fmt.Fprintf(out, "Loads:           %d\n", metrics.LoadCount)
+			fmt.Fprintf(out, "Errors:          %d\n", metrics.ErrorCount)
+			fmt.Fprintf(out, "Avg Load Time:   %s\n", time.Duration(metrics.AvgLoadTime)*time.Millisecond)
+			fmt.Fprintf(out, "Avg Render Time: %s\n", time.Duration(metrics.AvgRenderTime)*time.Millisecond)
+
+			codes := make([]string, 0, len(metrics.ErrorRates))
+			for code := range metrics.ErrorRates {
+				codes = append(codes, code)
+			}
+			sort.Strings(codes)
+			if len(codes) > 0 {
+				fmt.Fprintln(out, "Error Rates:")
+			}
+			for _, code := range codes {
+				fmt.Fprintf(out, "  %-20s %.1f%%\n", code, metrics.ErrorRates[code]*100)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format (text, json)")
+
+	return cmd
+}
+
+// formatHealthy summarises display-reported health
+func formatHealthy(h *v1alpha1.ContentHealth) string {
+	if h.Healthy {
+		return "yes"
+	}
+	return "no"
+}
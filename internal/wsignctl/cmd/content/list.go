@@ -2,13 +2,19 @@ package content
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
 	"github.com/wrale/wrale-signage/internal/wsignctl/util"
 )
 
 func newListCmd() *cobra.Command {
-	var output string
+	var (
+		output      string
+		contentType string
+		tags        []string
+	)
 
 	cmd := &cobra.Command{
 		Use:   "list",
@@ -19,6 +25,12 @@ This shows where displays can be redirected to fetch content from.`,
 		Example: `  # List all content sources
   wsignctl content list
   
+  # List only static pages
+  wsignctl content list --type static-page
+
+  # List the emergency content
+  wsignctl content list --tag emergency
+
   # Show detailed JSON output
   wsignctl content list -o json`,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -27,7 +39,17 @@ This shows where displays can be redirected to fetch content from.`,
 				return err
 			}
 
-			sources, err := c.ListContentSources(cmd.Context())
+			var sources []v1alpha1.ContentSource
+			switch {
+			case contentType != "" && len(tags) > 0:
+				return fmt.Errorf("--type and --tag cannot be combined")
+			case contentType != "":
+				sources, err = c.ListContentSourcesByType(cmd.Context(), contentType)
+			case len(tags) > 0:
+				sources, err = c.ListContentSourcesByTags(cmd.Context(), tags)
+			default:
+				sources, err = c.ListContentSources(cmd.Context())
+			}
 			if err != nil {
 				return fmt.Errorf("error listing content sources: %w", err)
 			}
@@ -41,16 +63,18 @@ This shows where displays can be redirected to fetch content from.`,
 				defer tw.Flush()
 
 				// Print header
-				fmt.Fprintf(tw, "NAME\tURL\tTYPE\tPROPERTIES\tLAST VALIDATED\tHASH\n")
+				fmt.Fprintf(tw, "NAME\tURL\tTYPE\tTAGS\tPROPERTIES\tVALID\tLAST VALIDATED\tHASH\n")
 
 				// Print each source
 				for _, s := range sources {
-					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
 						s.Name,
 						s.Spec.URL,
 						s.Spec.Type,
+						strings.Join(s.Spec.Tags, ","),
 						util.FormatProperties(s.Spec.Properties),
-						s.Status.LastValidated.Format("2006-01-02 15:04:05"),
+						formatValid(&s),
+						formatValidated(s.Status.LastValidated),
 						s.Status.Hash,
 					)
 				}
@@ -60,6 +84,8 @@ This shows where displays can be redirected to fetch content from.`,
 		},
 	}
 
+	cmd.Flags().StringVar(&contentType, "type", "", "Only list content sources of this type")
+	cmd.Flags().StringArrayVar(&tags, "tag", nil, "Only list content sources carrying this tag (repeatable; all must match)")
 	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format (table, json)")
 
 	return cmd
@@ -0,0 +1,64 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/client"
+)
+
+// prefetchPollInterval is how often --wait-for-prefetch checks progress
+var prefetchPollInterval = 5 * time.Second
+
+// waitForPrefetch polls the prefetch status of contentURL until at least
+// threshold percent of the displays assigned it have downloaded all of it,
+// printing progress as it changes. It fails when timeout passes first.
+func waitForPrefetch(cmd *cobra.Command, c *client.Client, contentURL string, threshold float64, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+	defer cancel()
+	out := cmd.OutOrStdout()
+
+	ticker := time.NewTicker(prefetchPollInterval)
+	defer ticker.Stop()
+	last := -1
+	for {
+		status, err := c.GetPrefetchStatus(ctx, contentURL)
+		switch {
+		case err == nil:
+			if status.Targeted == 0 {
+				fmt.Fprintln(out, "No displays are assigned the content; not waiting for prefetch")
+				return nil
+			}
+			if status.Complete != last {
+				fmt.Fprintf(out, "Prefetched by %d of %d display(s)\n", status.Complete, status.Targeted)
+				last = status.Complete
+			}
+			if prefetched(status, threshold) {
+				return nil
+			}
+		case ctx.Err() == nil:
+			return fmt.Errorf("error checking prefetch progress: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) && cmd.Context().Err() == nil {
+				return fmt.Errorf("timed out after %s waiting for %g%% of displays to prefetch %s; %d of them have",
+					timeout, threshold, contentURL, max(last, 0))
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// prefetched reports whether at least threshold percent of the targeted
+// displays have downloaded the content
+func prefetched(status *v1alpha1.PrefetchStatus, threshold float64) bool {
+	return float64(status.Complete)*100 >= threshold*float64(status.Targeted)
+}
@@ -0,0 +1,195 @@
+package content
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+func newPreviewCmd() *cobra.Command {
+	var (
+		req      v1alpha1.ContentPreviewRequest
+		at       string
+		location v1alpha1.DisplayLocation
+		labels   []string
+		output   string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "preview SOURCE",
+		Short: "Show what a display would be sent for a content source",
+		Long: `Check content before assigning it, as the server would resolve it for a display.
+
+The preview shows the URL a display would be sent once --path has passed the
+source's allowed paths, and whether the source is healthy. Given a display,
+either a registered one with --as-display or a hypothetical one described
+with --site-id, --zone, --position and --label, it also shows how that
+display's assignments resolve at --at (default now), along with the schedule
+window of each assignment in effect then.
+
+With --probe the server fetches the URL and reports its status, latency,
+content type and size. The server only ever fetches URLs beneath a
+registered content source, and limits how often each source is probed.`,
+		Example: `  # Check the specials page of the menus source
+  wsignctl content preview menus --path /specials --probe
+
+  # See what a cafeteria board shows at lunch on Monday
+  wsignctl content preview menus --path /specials --as-display cafeteria-main \
+    --at 2024-06-03T12:30:00-04:00
+
+  # Preview for a display that is not installed yet
+  wsignctl content preview menus --site-id hq --zone cafeteria --label orientation=portrait -o json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "text" && output != "json" {
+				return fmt.Errorf("unknown output format %q, expected text or json", output)
+			}
+			if at != "" {
+				t, err := time.Parse(time.RFC3339, at)
+				if err != nil {
+					return fmt.Errorf("invalid --at %q: use an RFC3339 time such as 2024-06-03T12:30:00Z", at)
+				}
+				req.At = &t
+			}
+			properties, err := util.ParseLabels("label", labels)
+			if err != nil {
+				return err
+			}
+			if location != (v1alpha1.DisplayLocation{}) {
+				req.Location = &location
+			}
+			if len(properties) > 0 {
+				req.Properties = properties
+			}
+			if req.Display != "" && (req.Location != nil || req.Properties != nil) {
+				return fmt.Errorf("--as-display cannot be combined with --site-id, --zone, --position or --label")
+			}
+
+			c, err := util.GetClientFromCommand(cmd)
+			if err != nil {
+				return err
+			}
+
+			preview, err := c.PreviewContentSource(cmd.Context(), args[0], &req)
+			if err != nil {
+				return fmt.Errorf("error previewing content: %w", err)
+			}
+
+			if output == "json" {
+				return util.PrintJSON(cmd.OutOrStdout(), preview)
+			}
+			printPreview(cmd.OutOrStdout(), preview)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&req.Path, "path", "", "Path below the source URL, from the source's allowed paths")
+	cmd.Flags().StringVar(&at, "at", "", "When to evaluate schedules, as an RFC3339 time (default now)")
+	cmd.Flags().StringVar(&req.Display, "as-display", "", "Name of the registered display to preview as")
+	cmd.Flags().StringVar(&location.SiteID, "site-id", "", "Site of a hypothetical display to preview as")
+	cmd.Flags().StringVar(&location.Zone, "zone", "", "Zone of a hypothetical display")
+	cmd.Flags().StringVar(&location.Position, "position", "", "Position of a hypothetical display")
+	cmd.Flags().StringArrayVar(&labels, "label", nil, "Label of a hypothetical display in key=value format (repeatable)")
+	cmd.Flags().BoolVar(&req.Probe, "probe", false, "Have the server fetch the URL and report what it sees")
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format (text, json)")
+
+	return cmd
+}
+
+// printPreview writes a readable summary of a preview
+func printPreview(out io.Writer, p *v1alpha1.ContentPreview) {
+	fmt.Fprintf(out, "Source:      %s\n", p.Source)
+	fmt.Fprintf(out, "URL:         %s\n", p.URL)
+	healthy := "yes"
+	if !p.SourceHealthy {
+		healthy = "no, displays are sent its fallbacks"
+	}
+	fmt.Fprintf(out, "Healthy:     %s\n", healthy)
+	fmt.Fprintf(out, "At:          %s\n", p.At.Format(time.RFC3339))
+
+	if d := p.Display; d != nil {
+		name := d.Name
+		if name == "" {
+			name = "hypothetical display"
+		}
+		fmt.Fprintf(out, "Display:     %s at %s\n", name, formatPreviewLocation(d.Location))
+		if len(d.Properties) > 0 {
+			fmt.Fprintf(out, "Labels:      %s\n", util.FormatProperties(d.Properties))
+		}
+
+		var selected *v1alpha1.ResolutionStep
+		for i := range p.Resolution {
+			if p.Resolution[i].Outcome == v1alpha1.ResolutionSelected {
+				selected = &p.Resolution[i]
+			}
+		}
+		switch {
+		case selected == nil:
+			fmt.Fprintf(out, "Assigned:    no, no assignment selects the display\n")
+		case p.Assigned:
+			fmt.Fprintf(out, "Assigned:    yes, by %s\n", selected.Assignment)
+		default:
+			fmt.Fprintf(out, "Assigned:    no, %s shows %s\n", selected.Assignment, selected.ContentURL)
+		}
+		for _, step := range p.Resolution {
+			line := fmt.Sprintf("             %s %s", step.Outcome, step.Assignment)
+			if step.Reason != "" {
+				line += ": " + step.Reason
+			}
+			if step.Window != nil {
+				line += ", window " + formatWindow(step.Window, step.Timezone)
+			}
+			fmt.Fprintln(out, line)
+		}
+	}
+
+	if r := p.Probe; r != nil {
+		result := fmt.Sprintf("%d", r.HTTPStatus)
+		if r.ContentType != "" {
+			result += " " + r.ContentType
+		}
+		result += fmt.Sprintf(", %d bytes in %s", r.Size, time.Duration(r.LatencyMs)*time.Millisecond)
+		if !r.Passed {
+			result = "failed, " + r.Reason
+		}
+		fmt.Fprintf(out, "Probe:       %s\n", result)
+	}
+}
+
+// formatPreviewLocation writes a location as site/zone/position, leaving
+// out the parts not given
+func formatPreviewLocation(l v1alpha1.DisplayLocation) string {
+	var parts []string
+	for _, part := range []string{l.SiteID, l.Zone, l.Position} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return "no location"
+	}
+	return strings.Join(parts, "/")
+}
+
+// formatWindow writes a schedule window such as "11:00-14:00 Mon,Fri
+// America/New_York"
+func formatWindow(w *v1alpha1.ScheduleWindow, tz string) string {
+	s := w.TimeOfDay.Start + "-" + w.TimeOfDay.End
+	if len(w.DaysOfWeek) > 0 {
+		days := make([]string, len(w.DaysOfWeek))
+		for i, d := range w.DaysOfWeek {
+			days[i] = d.String()[:3]
+		}
+		s += " " + strings.Join(days, ",")
+	}
+	if tz != "" {
+		s += " " + tz
+	}
+	return s
+}
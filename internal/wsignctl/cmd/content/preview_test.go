@@ -0,0 +1,89 @@
+package content
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+)
+
+func TestPreviewCommand(t *testing.T) {
+	f, server := newFakeServer(t)
+	at := time.Date(2024, 6, 3, 16, 30, 0, 0, time.UTC)
+	f.preview = v1alpha1.ContentPreview{
+		Source:        "menus",
+		URL:           "https://menu.example.com/boards/lunch",
+		At:            at,
+		SourceHealthy: true,
+		Display: &v1alpha1.ContentPreviewDisplay{
+			Name:     "cafeteria-main",
+			Location: v1alpha1.DisplayLocation{SiteID: "hq", Zone: "cafeteria", Position: "main"},
+		},
+		Resolution: []v1alpha1.ResolutionStep{
+			{Assignment: "all-day", ContentURL: "https://menu.example.com/boards", Outcome: v1alpha1.ResolutionOutranked, Reason: "a newer assignment is as specific"},
+			{
+				Assignment: "lunch",
+				ContentURL: "https://menu.example.com/boards/lunch",
+				Outcome:    v1alpha1.ResolutionSelected,
+				Window: &v1alpha1.ScheduleWindow{
+					DaysOfWeek: []time.Weekday{time.Monday, time.Friday},
+					TimeOfDay:  v1alpha1.TimeRange{Start: "11:00", End: "14:00"},
+				},
+				Timezone: "America/New_York",
+			},
+		},
+		Assigned: true,
+		Probe:    &v1alpha1.ContentValidationReport{Passed: true, HTTPStatus: 200, ContentType: "text/html", Size: 2048, LatencyMs: 35},
+	}
+
+	out, err := run(t, newPreviewCmd(), server, "menus", "--path", "/lunch", "--as-display", "cafeteria-main",
+		"--at", "2024-06-03T12:30:00-04:00", "--probe")
+	require.NoError(t, err)
+	require.Len(t, f.previews, 1)
+	req := f.previews[0]
+	assert.Equal(t, "/lunch", req.Path)
+	assert.Equal(t, "cafeteria-main", req.Display)
+	require.NotNil(t, req.At)
+	assert.True(t, at.Equal(*req.At))
+	assert.True(t, req.Probe)
+	assert.Nil(t, req.Location)
+
+	assert.Contains(t, out, "URL:         https://menu.example.com/boards/lunch")
+	assert.Contains(t, out, "Display:     cafeteria-main at hq/cafeteria/main")
+	assert.Contains(t, out, "Assigned:    yes, by lunch")
+	assert.Contains(t, out, "outranked all-day: a newer assignment is as specific")
+	assert.Contains(t, out, "selected lunch, window 11:00-14:00 Mon,Fri America/New_York")
+	assert.Contains(t, out, "Probe:       200 text/html, 2048 bytes in 35ms")
+
+	t.Run("hypothetical display as json", func(t *testing.T) {
+		out, err := run(t, newPreviewCmd(), server, "menus", "--site-id", "hq", "--zone", "cafeteria",
+			"--label", "orientation=portrait", "-o", "json")
+		require.NoError(t, err)
+		req := f.previews[len(f.previews)-1]
+		assert.Equal(t, &v1alpha1.DisplayLocation{SiteID: "hq", Zone: "cafeteria"}, req.Location)
+		assert.Equal(t, map[string]string{"orientation": "portrait"}, req.Properties)
+		assert.Nil(t, req.At, "schedules are evaluated now by default")
+		assert.False(t, req.Probe)
+
+		var p v1alpha1.ContentPreview
+		require.NoError(t, json.Unmarshal([]byte(out), &p))
+		assert.Equal(t, "https://menu.example.com/boards/lunch", p.URL)
+	})
+
+	t.Run("bad flags are refused before calling the server", func(t *testing.T) {
+		sent := len(f.previews)
+		for _, args := range [][]string{
+			{"menus", "--at", "tomorrow"},
+			{"menus", "--as-display", "cafeteria-main", "--zone", "cafeteria"},
+			{"menus", "-o", "yaml"},
+		} {
+			_, err := run(t, newPreviewCmd(), server, args...)
+			assert.Error(t, err, args)
+		}
+		assert.Len(t, f.previews, sent)
+	})
+}
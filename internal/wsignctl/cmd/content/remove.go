@@ -1,9 +1,12 @@
 package content
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/internal/wsignctl/client"
 	"github.com/wrale/wrale-signage/internal/wsignctl/util"
 )
 
@@ -15,12 +18,13 @@ func newRemoveCmd() *cobra.Command {
 		Short: "Remove a content source",
 		Long: `Remove a content source from the system.
 
-By default, this will fail if any redirect rules reference the source.
-Use --force to remove it anyway and invalidate those rules.`,
+By default, this fails while any content assignments are made from the source,
+and lists them. Use --force to remove the source together with those
+assignments; the displays they targeted stop showing the content.`,
 		Example: `  # Remove an unused content source
   wsignctl content remove old-menus
-  
-  # Force remove even if referenced
+
+  # Remove a source and the assignments made from it
   wsignctl content remove old-menus --force`,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -31,16 +35,30 @@ Use --force to remove it anyway and invalidate those rules.`,
 				return err
 			}
 
-			if err := c.RemoveContentSource(cmd.Context(), name, force); err != nil {
+			deletion, err := c.RemoveContentSource(cmd.Context(), name, force)
+			var inUse *client.ContentSourceInUseError
+			if errors.As(err, &inUse) {
+				out := cmd.ErrOrStderr()
+				fmt.Fprintf(out, "Content source %q is still used by:\n", name)
+				for _, ref := range inUse.References {
+					fmt.Fprintf(out, "  %s %s\n", ref.Kind, ref.Name)
+				}
+				return fmt.Errorf("content source %q not removed; use --force to remove it with its assignments", name)
+			}
+			if err != nil {
 				return fmt.Errorf("error removing content source: %w", err)
 			}
 
-			fmt.Printf("Content source %q removed\n", name)
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "Content source %q removed\n", deletion.Name)
+			for _, ref := range deletion.Removed {
+				fmt.Fprintf(out, "  removed %s %s\n", ref.Kind, ref.Name)
+			}
 			return nil
 		},
 	}
 
-	cmd.Flags().BoolVar(&force, "force", false, "Remove even if referenced by rules")
+	cmd.Flags().BoolVar(&force, "force", false, "Remove the assignments made from the source as well")
 
 	return cmd
 }
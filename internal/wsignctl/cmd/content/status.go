@@ -0,0 +1,119 @@
+package content
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+func newStatusCmd() *cobra.Command {
+	var (
+		refresh bool
+		output  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "status NAME",
+		Short: "Show content validation status",
+		Long: `Show the most recent validation report for a content source.
+
+The server validates every content source periodically. Use --refresh to
+validate the source now instead of showing the last stored report.`,
+		Example: `  # Show the last validation report
+  wsignctl content status menus
+
+  # Validate now and show the result
+  wsignctl content status menus --refresh`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			c, err := util.GetClientFromCommand(cmd)
+			if err != nil {
+				return err
+			}
+
+			var source *v1alpha1.ContentSource
+			if refresh {
+				source, err = c.ValidateContentSource(cmd.Context(), name)
+			} else {
+				source, err = c.GetContentSource(cmd.Context(), name)
+			}
+			if err != nil {
+				return fmt.Errorf("error getting content status: %w", err)
+			}
+
+			if output == "json" {
+				return util.PrintJSON(cmd.OutOrStdout(), source.Status)
+			}
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "Name:            %s\n", source.Name)
+			fmt.Fprintf(out, "URL:             %s\n", source.Spec.URL)
+			if len(source.Spec.Fallbacks) > 0 {
+				fmt.Fprintf(out, "Fallbacks:       %s\n", strings.Join(source.Spec.Fallbacks, ", "))
+			}
+			fmt.Fprintf(out, "Valid:           %s\n", formatValid(source))
+			fmt.Fprintf(out, "Last Validated:  %s\n", formatValidated(source.Status.LastValidated))
+
+			report := source.Status.Validation
+			if report == nil {
+				return nil
+			}
+			if report.HTTPStatus != 0 {
+				fmt.Fprintf(out, "HTTP Status:     %d\n", report.HTTPStatus)
+			}
+			fmt.Fprintf(out, "Latency:         %s\n", time.Duration(report.LatencyMs)*time.Millisecond)
+			if report.ContentType != "" {
+				fmt.Fprintf(out, "Content Type:    %s\n", report.ContentType)
+			}
+			fmt.Fprintf(out, "Size:            %d bytes\n", report.Size)
+			if report.Reason != "" {
+				fmt.Fprintf(out, "Reason:          %s\n", report.Reason)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&refresh, "refresh", false, "Validate the content now")
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format (text, json)")
+
+	return cmd
+}
+
+// formatValid summarises the validation state of a content source
+func formatValid(s *v1alpha1.ContentSource) string {
+	switch {
+	case s.Status.LastValidated.IsZero():
+		return "-"
+	case s.Status.IsHealthy:
+		return "yes"
+	default:
+		return "no"
+	}
+}
+
+// formatValidated renders a validation time, or "Never" if unset
+func formatValidated(t time.Time) string {
+	if t.IsZero() {
+		return "Never"
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// printValidationWarning tells the user when a saved source failed validation
+func printValidationWarning(cmd *cobra.Command, s *v1alpha1.ContentSource) {
+	if s == nil || s.Status.IsHealthy {
+		return
+	}
+	reason := "content could not be validated"
+	if s.Status.Validation != nil && s.Status.Validation.Reason != "" {
+		reason = s.Status.Validation.Reason
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s; the source was saved but is marked unhealthy\n", reason)
+}
@@ -0,0 +1,77 @@
+package content
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+func newUnassignCmd() *cobra.Command {
+	var selector v1alpha1.DisplaySelector
+
+	cmd := &cobra.Command{
+		Use:   "unassign SOURCE",
+		Short: "Stop showing a content source at a location",
+		Long: `Remove the content assignments created from a source for a display location.
+
+Only assignments whose location and matched labels are exactly the ones
+given are removed, so unassigning a source from a zone leaves assignments to
+single positions in that zone, or to labelled displays in it, in place.
+Likewise --display removes only the assignments naming that display.`,
+		Example: `  # Stop showing the menus in the cafeteria
+  wsignctl content unassign menus --site-id=hq --zone=cafeteria
+
+  # Stop showing the visitor sign-in page on the reception iPad
+  wsignctl content unassign visitors --display=reception-ipad`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			c, err := util.GetClientFromCommand(cmd)
+			if err != nil {
+				return err
+			}
+
+			candidates, err := c.ListContentAssignments(cmd.Context(), name, selector)
+			if err != nil {
+				return fmt.Errorf("error listing assignments: %w", err)
+			}
+
+			removed := 0
+			for _, a := range candidates {
+				if !sameSelector(a.DisplaySelector, selector) {
+					continue
+				}
+				if err := c.DeleteContentAssignment(cmd.Context(), a.ID.String()); err != nil {
+					return fmt.Errorf("error removing assignment %s: %w", a.ID, err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Assignment %s removed\n", a.ID)
+				removed++
+			}
+			if removed == 0 {
+				return fmt.Errorf("no assignments of %q target %s", name, util.FormatSelectors(selector))
+			}
+			return nil
+		},
+	}
+
+	addSelectorFlags(cmd, &selector)
+
+	return cmd
+}
+
+// sameSelector reports whether two selectors target exactly the same
+// displays by the same criteria. A display given by name is stored as its
+// ID, and the server only lists assignments naming the display when one is
+// given, so display references only need to be set on both.
+func sameSelector(a, b v1alpha1.DisplaySelector) bool {
+	return (a.DisplayRef != "") == (b.DisplayRef != "") &&
+		a.SiteID == b.SiteID &&
+		a.Zone == b.Zone &&
+		a.Position == b.Position &&
+		maps.Equal(a.MatchProperties, b.MatchProperties)
+}
@@ -2,10 +2,10 @@ package content
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/client"
 	"github.com/wrale/wrale-signage/internal/wsignctl/util"
 )
 
@@ -14,6 +14,12 @@ func newUpdateCmd() *cobra.Command {
 		url         string
 		addProps    []string
 		removeProps []string
+		tags        []string
+		removeTags  []string
+		replaceTags bool
+		strict      bool
+		notify      bool
+		force       bool
 	)
 
 	cmd := &cobra.Command{
@@ -23,34 +29,63 @@ func newUpdateCmd() *cobra.Command {
 
 You can modify:
 - The URL where content is found
-- Properties (add or remove)`,
+- Properties (add or remove)
+- Tags (add or remove)
+
+Tags given with --tag are added to the source's existing tags. Use
+--replace-tags to make them the source's only tags instead.
+
+The content is revalidated after the update. Use --strict to reject the
+update if validation fails.
+
+Displays keep showing what they loaded until they next reload. Use --notify
+to tell the connected displays assigned content from the source to reload
+as soon as the update is saved.
+
+The update only applies if nobody else changed the source since it was
+read, so concurrent edits are not lost. Use --force to apply it to whatever
+the source holds by then.`,
 		Example: `  # Update URL
   wsignctl content update menus --url=https://newmenu.example.com
   
   # Modify properties
   wsignctl content update weather \
     --add-property=refresh=5m \
-    --remove-property=old-key`,
+    --remove-property=old-key
+
+  # Tag a source as emergency content, dropping an old tag
+  wsignctl content update fire-alert --tag=emergency --remove-tag=drill
+
+  # Make lobby the source's only tag
+  wsignctl content update welcome --tag=lobby --replace-tags
+
+  # Point menus at a new URL and reload the displays showing them
+  wsignctl content update menus --url=https://menu.example.com/v2 --notify`,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			name := args[0]
 
-			// Parse properties to add/update
-			properties := make(map[string]string)
-			for _, prop := range addProps {
-				parts := strings.SplitN(prop, "=", 2)
-				if len(parts) != 2 {
-					return fmt.Errorf("invalid property format %q - use Key=Value", prop)
+			properties, err := util.ParseLabels("property", addProps)
+			if err != nil {
+				return err
+			}
+			// An empty value removes a property, so it cannot be set to one
+			for key, value := range properties {
+				if value == "" {
+					return fmt.Errorf("property %q cannot be set to an empty value; use --remove-property=%s to remove it", key, key)
 				}
-				properties[parts[0]] = parts[1]
 			}
 
 			// Mark properties to remove with empty values
-			for _, prop := range removeProps {
-				if strings.Contains(prop, "=") {
-					return fmt.Errorf("use just the property name to remove, not %q", prop)
+			removed, err := util.ParseLabelKeys("remove-property", removeProps)
+			if err != nil {
+				return err
+			}
+			for _, key := range removed {
+				if _, ok := properties[key]; ok {
+					return fmt.Errorf("property %q is both set and removed", key)
 				}
-				properties[prop] = ""
+				properties[key] = ""
 			}
 
 			// Build update
@@ -60,17 +95,43 @@ You can modify:
 			if url != "" {
 				update.URL = &url
 			}
+			if replaceTags {
+				if tags == nil {
+					tags = []string{}
+				}
+				update.Tags = &tags
+			} else {
+				update.AddTags = tags
+			}
+			update.RemoveTags = removeTags
+			update.Notify = notify
 
 			c, err := util.GetClientFromCommand(cmd)
 			if err != nil {
 				return err
 			}
 
-			if err := c.UpdateContentSource(cmd.Context(), name, update); err != nil {
+			if !force {
+				source, err := c.GetContentSource(cmd.Context(), name)
+				if err != nil {
+					return fmt.Errorf("error getting content source: %w", err)
+				}
+				update.Version = source.Status.Version
+			}
+
+			result, err := c.UpdateContentSource(cmd.Context(), name, update, strict)
+			if client.IsVersionMismatch(err) {
+				return fmt.Errorf("content source %q was changed by someone else; check it and retry, or use --force: %w", name, err)
+			}
+			if err != nil {
 				return fmt.Errorf("error updating content source: %w", err)
 			}
 
 			fmt.Printf("Content source %q updated\n", name)
+			if result.NotifiedDisplays != nil {
+				fmt.Printf("%d display(s) told to reload\n", *result.NotifiedDisplays)
+			}
+			printValidationWarning(cmd, &result.ContentSource)
 			return nil
 		},
 	}
@@ -78,6 +139,12 @@ You can modify:
 	cmd.Flags().StringVar(&url, "url", "", "New URL for content")
 	cmd.Flags().StringArrayVar(&addProps, "add-property", nil, "Add properties in Key=Value format")
 	cmd.Flags().StringArrayVar(&removeProps, "remove-property", nil, "Remove properties by name")
+	cmd.Flags().StringArrayVar(&tags, "tag", nil, "Add a tag (repeatable)")
+	cmd.Flags().StringArrayVar(&removeTags, "remove-tag", nil, "Remove a tag (repeatable)")
+	cmd.Flags().BoolVar(&replaceTags, "replace-tags", false, "Replace the existing tags with those given by --tag")
+	cmd.Flags().BoolVar(&strict, "strict", false, "Reject the update if content validation fails")
+	cmd.Flags().BoolVar(&notify, "notify", false, "Reload the connected displays assigned this content")
+	cmd.Flags().BoolVar(&force, "force", false, "Update even if the source changed since it was read")
 
 	return cmd
 }
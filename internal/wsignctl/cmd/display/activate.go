@@ -1,7 +1,10 @@
 package display
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -16,8 +19,10 @@ func newActivateCommand() *cobra.Command {
 		siteID   string
 		zone     string
 		position string
+		name     string
 		labels   []string
 		output   string
+		accept   bool
 	)
 
 	cmd := &cobra.Command{
@@ -27,25 +32,28 @@ func newActivateCommand() *cobra.Command {
 location information and any additional properties.
 
 The activation code should be visible on the display's screen after it has
-connected to the displays.{domain} endpoint.`,
+connected to the displays.{domain} endpoint.
+
+A pre-staged display may suggest where it is installed and what it is
+called. When --site-id, --zone or --position is left out, the suggestion
+fills the missing values once it is confirmed at the prompt, or without
+asking with --accept-hints. Values given on the command line always win.`,
 		Example: `  # Activate a display showing code BLUE-FISH
   wsignctl display activate BLUE-FISH --site-id=hq --zone=lobby --position=north
   
   # Activate with additional metadata
   wsignctl display activate CAKE-MOON --site-id=hq --zone=cafeteria --position=menu-1 \
-    --label=orientation=portrait`,
+    --label=orientation=portrait
+
+  # Activate a pre-staged display where it says it belongs
+  wsignctl display activate PINE-OTTER --accept-hints`,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			code := args[0]
 
-			// Parse labels into properties map
-			properties := make(map[string]string)
-			for _, label := range labels {
-				parts := strings.SplitN(label, "=", 2)
-				if len(parts) != 2 {
-					return fmt.Errorf("invalid label format %q - use key=value", label)
-				}
-				properties[parts[0]] = parts[1]
+			properties, err := util.ParseLabels("label", labels)
+			if err != nil {
+				return err
 			}
 
 			client, err := util.GetClientFromCommand(cmd)
@@ -53,15 +61,26 @@ connected to the displays.{domain} endpoint.`,
 				return err
 			}
 
-			// Build registration request
+			// Build registration request. The server generates a name if
+			// none is given.
 			reg := &v1alpha1.DisplayRegistrationRequest{
-				Name: "", // Server will generate name if not provided
+				Name: name,
 				Location: v1alpha1.DisplayLocation{
 					SiteID:   siteID,
 					Zone:     zone,
 					Position: position,
 				},
 				ActivationCode: code,
+				Properties:     properties,
+			}
+
+			if !locationComplete(reg.Location) || accept {
+				if err := applyHints(cmd, client, reg, accept); err != nil {
+					return err
+				}
+			}
+			if missing := missingLocationFlags(reg.Location); len(missing) > 0 {
+				return fmt.Errorf("required flag(s) %s not set", strings.Join(missing, ", "))
 			}
 
 			// Attempt to activate the display
@@ -75,7 +94,12 @@ connected to the displays.{domain} endpoint.`,
 				return util.PrintJSON(cmd.OutOrStdout(), display)
 			}
 
-			fmt.Fprintf(cmd.OutOrStdout(), "Display activated successfully!\n\n")
+			if display.Status.State == v1alpha1.DisplayStatePendingApproval {
+				fmt.Fprintf(cmd.OutOrStdout(), "Display activated and awaiting approval.\n")
+				fmt.Fprintf(cmd.OutOrStdout(), "Run 'wsignctl display approve %s' to let it show content.\n\n", display.Name)
+			} else {
+				fmt.Fprintf(cmd.OutOrStdout(), "Display activated successfully!\n\n")
+			}
 			fmt.Fprintf(cmd.OutOrStdout(), "Details:\n")
 			fmt.Fprintf(cmd.OutOrStdout(), "  Name:     %s\n", display.Name)
 			fmt.Fprintf(cmd.OutOrStdout(), "  ID:       %s\n", display.ObjectMeta.ID)
@@ -87,8 +111,8 @@ connected to the displays.{domain} endpoint.`,
 
 			if len(display.Spec.Properties) > 0 {
 				fmt.Fprintf(cmd.OutOrStdout(), "\nProperties:\n")
-				for k, v := range display.Spec.Properties {
-					fmt.Fprintf(cmd.OutOrStdout(), "  %s: %s\n", k, v)
+				for _, k := range util.SortedKeys(display.Spec.Properties) {
+					fmt.Fprintf(cmd.OutOrStdout(), "  %s: %s\n", k, display.Spec.Properties[k])
 				}
 			}
 
@@ -97,21 +121,106 @@ connected to the displays.{domain} endpoint.`,
 	}
 
 	// Add location flags
-	cmd.Flags().StringVar(&siteID, "site-id", "", "Site identifier (required)")
-	cmd.Flags().StringVar(&zone, "zone", "", "Zone within site (required)")
-	cmd.Flags().StringVar(&position, "position", "", "Position within zone (required)")
+	cmd.Flags().StringVar(&siteID, "site-id", "", "Site identifier (required unless the display suggests one)")
+	cmd.Flags().StringVar(&zone, "zone", "", "Zone within site (required unless the display suggests one)")
+	cmd.Flags().StringVar(&position, "position", "", "Position within zone (required unless the display suggests one)")
+	cmd.Flags().StringVar(&name, "name", "", "Display name (default the display's suggestion, or one generated from its location)")
 	cmd.Flags().StringArrayVar(&labels, "label", nil, "Additional labels in key=value format")
 	cmd.Flags().StringVarP(&output, "output", "o", "", "Output format (json)")
+	cmd.Flags().BoolVar(&accept, "accept-hints", false, "Use the location and name the display suggests without asking")
+
+	return cmd
+}
+
+// hintsClient is the part of the API client reading a code's hints
+type hintsClient interface {
+	CheckActivationCode(ctx context.Context, userCode string) (*v1alpha1.ActivationCode, error)
+}
+
+// applyHints fills the location and name reg leaves empty from what the
+// display suggested, once the suggestion is confirmed. With accept set it
+// is used without asking.
+func applyHints(cmd *cobra.Command, c hintsClient, reg *v1alpha1.DisplayRegistrationRequest, accept bool) error {
+	code, err := c.CheckActivationCode(cmd.Context(), reg.ActivationCode)
+	if err != nil {
+		return fmt.Errorf("error checking activation code: %w", err)
+	}
+
+	var hint v1alpha1.DisplayLocation
+	if code.LocationHint != nil {
+		hint = *code.LocationHint
+	}
+	if hint.SiteID == "" {
+		hint.SiteID = code.SiteID
+	}
 
-	// Mark required flags and handle potential errors
-	requiredFlags := []string{"site-id", "zone", "position"}
-	for _, flag := range requiredFlags {
-		if err := cmd.MarkFlagRequired(flag); err != nil {
-			// Since this is during command construction, we should panic
-			// This follows cobra's pattern for command setup errors
-			panic(fmt.Sprintf("failed to mark %q flag as required: %v", flag, err))
+	suggested := *reg
+	for _, field := range []struct {
+		value *string
+		hint  string
+	}{
+		{&suggested.Location.SiteID, hint.SiteID},
+		{&suggested.Location.Zone, hint.Zone},
+		{&suggested.Location.Position, hint.Position},
+		{&suggested.Name, code.NameHint},
+	} {
+		if *field.value == "" {
+			*field.value = field.hint
 		}
 	}
+	if suggested.Location == reg.Location && suggested.Name == reg.Name {
+		return nil
+	}
 
-	return cmd
+	if !accept {
+		question := "Suggested: " + formatLocation(suggested.Location)
+		if suggested.Name != reg.Name {
+			question += " named " + suggested.Name
+		}
+		ok, err := confirmDefaultYes(cmd.InOrStdin(), cmd.OutOrStdout(), question+", accept?")
+		if err != nil || !ok {
+			return err
+		}
+	}
+	*reg = suggested
+	return nil
+}
+
+// confirmDefaultYes asks question and reports whether it was accepted. An
+// empty answer accepts; no answer at all, as from a closed input, does not.
+func confirmDefaultYes(in io.Reader, out io.Writer, question string) (bool, error) {
+	fmt.Fprintf(out, "%s [Y/n] ", question)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("error reading confirmation: %w", err)
+	}
+	if err == io.EOF && answer == "" {
+		fmt.Fprintln(out)
+		return false, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "", "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// locationComplete reports whether every part of location is set
+func locationComplete(location v1alpha1.DisplayLocation) bool {
+	return len(missingLocationFlags(location)) == 0
+}
+
+// missingLocationFlags names the flags of the location parts still unset
+func missingLocationFlags(location v1alpha1.DisplayLocation) []string {
+	var missing []string
+	for _, field := range []struct{ flag, value string }{
+		{"site-id", location.SiteID},
+		{"zone", location.Zone},
+		{"position", location.Position},
+	} {
+		if field.value == "" {
+			missing = append(missing, `"`+field.flag+`"`)
+		}
+	}
+	return missing
 }
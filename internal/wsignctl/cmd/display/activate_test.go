@@ -0,0 +1,95 @@
+package display
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/activation"
+	displayhttp "github.com/wrale/wrale-signage/internal/wsignd/display/http"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+func TestActivateCommandHints(t *testing.T) {
+	t.Setenv("WSIGN_INSECURE_SKIP_TLS", "false")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	service := display.NewService(memory.NewRepository(), noopPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, logger)
+	activations := activation.NewService(memory.NewActivationRepository(), time.Minute, activation.SitePolicy{})
+	router := displayhttp.NewRouter(displayhttp.NewHandler(service, activations, nil, logger), ratelimit.NewMemoryService(nil), nil)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	ctx := context.Background()
+
+	// newCode issues a code as a display pre-staged for hq/lobby/<position>
+	// would request it
+	newCode := func(position string) string {
+		code, err := activations.GenerateCode(ctx, "", activation.Hints{
+			Location: display.Location{SiteID: "hq", Zone: "lobby", Position: position},
+			Name:     "lobby-" + position,
+		})
+		require.NoError(t, err)
+		return code.UserCode
+	}
+	run := func(input string, args ...string) (string, error) {
+		cmd := newActivateCommand()
+		cmd.Flags().String("server", server.URL, "")
+		cmd.Flags().String("token", "test-token", "")
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&out)
+		cmd.SetIn(strings.NewReader(input))
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	t.Run("confirmed hints fill the missing flags", func(t *testing.T) {
+		out, err := run("\n", newCode("north"))
+		require.NoError(t, err)
+		assert.Contains(t, out, "Suggested: hq/lobby/north named lobby-north, accept? [Y/n]")
+
+		d, err := service.GetByName(ctx, "lobby-north")
+		require.NoError(t, err)
+		assert.Equal(t, display.Location{SiteID: "hq", Zone: "lobby", Position: "north"}, d.Location)
+	})
+
+	t.Run("flags override hints", func(t *testing.T) {
+		_, err := run("", newCode("south"), "--accept-hints", "--zone", "cafeteria", "--name", "cafe-south")
+		require.NoError(t, err)
+
+		d, err := service.GetByName(ctx, "cafe-south")
+		require.NoError(t, err)
+		assert.Equal(t, display.Location{SiteID: "hq", Zone: "cafeteria", Position: "south"}, d.Location)
+		_, err = service.GetByName(ctx, "lobby-south")
+		assert.Error(t, err)
+	})
+
+	t.Run("declined or unanswered hints are not used", func(t *testing.T) {
+		code := newCode("east")
+		for _, input := range []string{"n\n", ""} {
+			out, err := run(input, code, "--site-id", "hq")
+			require.EqualError(t, err, `required flag(s) "zone", "position" not set`)
+			assert.Contains(t, out, "Suggested: hq/lobby/east named lobby-east")
+		}
+
+		_, err := service.GetByName(ctx, "lobby-east")
+		assert.Error(t, err)
+	})
+
+	t.Run("complete flags skip the hints", func(t *testing.T) {
+		out, err := run("", newCode("west"), "--site-id", "annex", "--zone", "hall", "--position", "west")
+		require.NoError(t, err)
+		assert.NotContains(t, out, "Suggested")
+		assert.Contains(t, out, "Location: annex/hall/west")
+	})
+}
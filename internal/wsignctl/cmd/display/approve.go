@@ -0,0 +1,56 @@
+package display
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+func newApproveCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "approve NAME",
+		Short: "Approve a display awaiting approval",
+		Long: `Approve a display that was activated at a site where the server requires
+approval (WSIGN_DISPLAY_APPROVAL_SITES). Until then the display is listed as
+PENDING_APPROVAL and is not issued tokens; once approved it is activated the
+next time it polls.
+
+Approving requires a token with the displays:approve scope, and every
+approval is recorded in the server's audit log. Approve the display before
+its activation code expires, or it has to be activated again.`,
+		Example: `  # Find displays awaiting approval
+  wsignctl display list | grep PENDING_APPROVAL
+
+  # Approve one
+  wsignctl display approve hq-lobby-north-3fa2c1`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			client, err := getClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			display, err := client.ApproveDisplay(cmd.Context(), name)
+			if err != nil {
+				return fmt.Errorf("error approving display: %w", err)
+			}
+
+			if output == "json" {
+				return util.PrintJSON(cmd.OutOrStdout(), display)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Display %q approved, state %s\n", display.Name, display.Status.State)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format (table, json)")
+
+	return cmd
+}
@@ -21,16 +21,30 @@ codes, managing display locations, and viewing display status information.`,
 	// Add all display-related subcommands
 	cmd.AddCommand(
 		newCreateCommand(),
+		newProvisionCommand(),
 		newActivateCommand(),
+		newApproveCommand(),
+		newGetCommand(),
 		newListCommand(),
 		newUpdateCommand(),
+		newRenameCommand(),
 		newDeleteCommand(),
+		newPruneCommand(),
+		newDisconnectCommand(),
+		newResyncCommand(),
+		newSessionsCommand(),
+		newDebugCommand(),
+		newStatsCommand(),
+		newProposalsCommand(),
+		newMessagesCommand(),
+		newImportCommand(),
+		newSettingsCommand(),
 	)
 
 	return cmd
 }
 
-// getClient returns a configured API client
-func getClient() (*client.Client, error) {
-	return util.GetClient()
+// getClient returns an API client honouring the global connection flags
+func getClient(cmd *cobra.Command) (*client.Client, error) {
+	return util.GetClientFromCommand(cmd)
 }
@@ -2,13 +2,13 @@ package display
 
 import (
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 
 	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
 )
 
 // newCreateCommand creates a command for pre-configuring displays
@@ -38,17 +38,12 @@ the display, like "lobby-north" or "cafeteria-menu-1".`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			name := args[0]
 
-			// Parse label key-value pairs into properties map
-			properties := make(map[string]string)
-			for _, label := range labels {
-				parts := strings.SplitN(label, "=", 2)
-				if len(parts) != 2 {
-					return fmt.Errorf("invalid label format %q - use key=value", label)
-				}
-				properties[parts[0]] = parts[1]
+			properties, err := util.ParseLabels("label", labels)
+			if err != nil {
+				return err
 			}
 
-			client, err := getClient()
+			client, err := getClient(cmd)
 			if err != nil {
 				return err
 			}
@@ -74,9 +69,8 @@ the display, like "lobby-north" or "cafeteria-menu-1".`,
 					Properties: properties,
 				},
 				Status: v1alpha1.DisplayStatus{
-					State:    v1alpha1.DisplayStateUnregistered,
-					LastSeen: time.Now(),
-					Version:  1,
+					State:   v1alpha1.DisplayStateUnregistered,
+					Version: 1,
 				},
 			}
 
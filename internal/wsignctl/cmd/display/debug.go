@@ -0,0 +1,126 @@
+package display
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+func newDebugCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "debug NAME",
+		Short: "Gather a display's debug snapshot",
+		Long: `Gather everything the server knows about a display in one request: its
+record and properties, control connections, recent lifecycle events and
+content changes, the content it resolves to and how that was chosen, queued
+messages, session metadata and rate limit state.
+
+Sections the server could not read in time are reported with a note rather
+than failing the snapshot. Access tokens and keys are never included, so the
+JSON output can be attached to a support ticket as is. Requires an admin
+token.`,
+		Example: `  # Summarize a display's state
+  wsignctl display debug lobby-north
+
+  # Save the full snapshot for a ticket
+  wsignctl display debug lobby-north -o json > snapshot.json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "table" && output != "json" {
+				return fmt.Errorf("unknown output format %q, expected table or json", output)
+			}
+
+			client, err := getClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			snapshot, err := client.GetDisplayDebug(cmd.Context(), args[0])
+			if err != nil {
+				return fmt.Errorf("error getting debug snapshot: %w", err)
+			}
+
+			if output == "json" {
+				return util.PrintJSON(cmd.OutOrStdout(), snapshot)
+			}
+			printDebugSummary(cmd.OutOrStdout(), snapshot)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format (table, json)")
+
+	return cmd
+}
+
+// printDebugSummary writes the headline of each section of a snapshot
+// followed by the notes of sections that could not be read
+func printDebugSummary(out io.Writer, s *v1alpha1.DisplayDebugSnapshot) {
+	if d := s.Display; d != nil {
+		fmt.Fprintf(out, "Display:     %s (%s)\n", d.Name, d.Status.State)
+		fmt.Fprintf(out, "Location:    %s\n", formatLocation(d.Spec.Location))
+	}
+	fmt.Fprintf(out, "ID:          %s\n", s.DisplayID)
+
+	connected := "no"
+	if s.Connection.Connected && s.Connection.Since != nil {
+		connected = fmt.Sprintf("yes, for %s (%d connection(s))",
+			util.FormatDuration(time.Since(*s.Connection.Since)), len(s.Connection.Items))
+	}
+	fmt.Fprintf(out, "Connected:   %s\n", connected)
+	for _, c := range s.Connection.Items {
+		fmt.Fprintf(out, "             %s queue %d/%d, %d dropped\n",
+			c.RemoteAddr, c.QueueDepth, c.QueueCapacity, c.DroppedFrames)
+	}
+
+	content := "none assigned"
+	if a := s.Content.Assignment; a != nil {
+		content = fmt.Sprintf("%s (%s)", a.ContentURL, a.Name)
+	}
+	fmt.Fprintf(out, "Content:     %s\n", content)
+	for _, step := range s.Content.Trace {
+		line := fmt.Sprintf("             %s %s", step.Outcome, step.Assignment)
+		if step.Reason != "" {
+			line += ": " + step.Reason
+		}
+		fmt.Fprintln(out, line)
+	}
+
+	fmt.Fprintf(out, "Events:      %d\n", len(s.Events.Items))
+	fmt.Fprintf(out, "Changes:     %d\n", len(s.ContentHistory.Items))
+	fmt.Fprintf(out, "Pending:     %d message(s)\n", len(s.PendingMessages.Items))
+	fmt.Fprintf(out, "Sessions:    %d\n", len(s.Sessions.Items))
+	for _, l := range s.RateLimits.Items {
+		if l.Remaining == 0 {
+			fmt.Fprintf(out, "Limited:     %s for %s, retry in %ds\n", l.Type, l.Key, l.RetryAfterSeconds)
+		}
+	}
+
+	notes := []struct{ section, note string }{
+		{"connection", s.Connection.Error},
+		{"events", s.Events.Error},
+		{"contentHistory", s.ContentHistory.Error},
+		{"content", s.Content.Error},
+		{"pendingMessages", s.PendingMessages.Error},
+		{"sessions", s.Sessions.Error},
+		{"rateLimits", s.RateLimits.Error},
+	}
+	header := false
+	for _, n := range notes {
+		if n.note == "" {
+			continue
+		}
+		if !header {
+			fmt.Fprintf(out, "\nSections not read:\n")
+			header = true
+		}
+		fmt.Fprintf(out, "  %s: %s\n", n.section, n.note)
+	}
+}
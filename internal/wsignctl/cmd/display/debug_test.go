@@ -0,0 +1,58 @@
+package display
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	displayhttp "github.com/wrale/wrale-signage/internal/wsignd/display/http"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+func TestDebugCommand(t *testing.T) {
+	t.Setenv("WSIGN_INSECURE_SKIP_TLS", "false")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	events := display.NewEventLog(0, noopPublisher{})
+	service := display.NewService(memory.NewRepository(), events, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, logger)
+	handler := displayhttp.NewHandler(service, nil, nil, logger)
+	handler.SetDebugService(displayhttp.NewDebugService(handler, events, nil, 0))
+	server := httptest.NewServer(displayhttp.NewRouter(handler, ratelimit.NewMemoryService(nil), nil))
+	t.Cleanup(server.Close)
+
+	d, err := service.Register(context.Background(), "lobby-north", display.Location{SiteID: "hq", Zone: "lobby", Position: "north"}, nil)
+	require.NoError(t, err)
+
+	run := func(args ...string) string {
+		cmd := newDebugCommand()
+		cmd.Flags().String("server", server.URL, "")
+		cmd.Flags().String("token", "test-token", "")
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs(args)
+		require.NoError(t, cmd.Execute())
+		return out.String()
+	}
+
+	var snapshot v1alpha1.DisplayDebugSnapshot
+	require.NoError(t, json.Unmarshal([]byte(run("lobby-north", "-o", "json")), &snapshot))
+	assert.Equal(t, d.ID, snapshot.DisplayID)
+	require.Len(t, snapshot.Events.Items, 1)
+	assert.Equal(t, string(display.EventRegistered), snapshot.Events.Items[0].Type)
+
+	out := run("lobby-north")
+	assert.Contains(t, out, "Display:     lobby-north")
+	assert.Contains(t, out, "Connected:   no")
+	assert.Contains(t, out, "Sections not read:")
+	assert.Contains(t, out, "  sessions: sessions are not available")
+	assert.Contains(t, out, "  content: content resolution is not enabled")
+}
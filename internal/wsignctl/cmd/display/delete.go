@@ -29,7 +29,7 @@ which will continue trying to connect until reactivated or reconfigured.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			name := args[0]
 
-			client, err := getClient()
+			client, err := getClient(cmd)
 			if err != nil {
 				return err
 			}
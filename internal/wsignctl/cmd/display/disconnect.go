@@ -0,0 +1,50 @@
+package display
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func newDisconnectCommand() *cobra.Command {
+	var reload bool
+
+	cmd := &cobra.Command{
+		Use:   "disconnect NAME",
+		Short: "Force a display to reconnect",
+		Long: `Close a display's control connection so that it reconnects to the server.
+
+This is useful when a display is misbehaving, for example stuck rendering or
+flooding the server with events, and avoids power-cycling the hardware
+on-site. Use --reload to have the display reload its page before the
+connection is closed.
+
+The command fails if the display is not currently connected. It requires a
+token with the admin scope.`,
+		Example: `  # Force a display to reconnect
+  wsignctl display disconnect lobby-north
+
+  # Reload the display's page, then reconnect
+  wsignctl display disconnect lobby-north --reload`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			client, err := getClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			if err := client.DisconnectDisplay(cmd.Context(), name, reload); err != nil {
+				return fmt.Errorf("error disconnecting display: %w", err)
+			}
+
+			fmt.Printf("Display %q disconnected\n", name)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&reload, "reload", false, "Tell the display to reload before disconnecting")
+
+	return cmd
+}
@@ -0,0 +1,120 @@
+package display
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+// recentContentCount is how many content transitions display get shows
+const recentContentCount = 3
+
+// newGetCommand creates a command for showing a single display
+func newGetCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "get NAME",
+		Short: "Show display details",
+		Long: `Show a display's location, state and properties together with its
+most recent content changes.
+
+Content changes are recorded when the display reports new content and when
+the server pushes a reload or new content to it.
+
+With --tz, or a time zone configured on the context, times are also given
+in that zone; --tz local uses the display's own zone, falling back to its
+site's and then the server's default.`,
+		Example: `  # Show a display
+  wsignctl display get lobby-north
+
+  # Show a display as JSON
+  wsignctl display get lobby-north -o json
+
+  # Show a display with times in its own zone
+  wsignctl display get lobby-north --tz local`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			tz, err := util.Timezone(cmd)
+			if err != nil {
+				return err
+			}
+
+			client, err := getClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			// The history endpoint accepts names and resolves the display ID
+			history, err := client.GetDisplayContentHistory(cmd.Context(), name, recentContentCount)
+			if err != nil {
+				return fmt.Errorf("error getting content history: %w", err)
+			}
+
+			d, err := client.GetDisplayIn(cmd.Context(), history.DisplayID.String(), tz)
+			if err != nil {
+				return fmt.Errorf("error getting display: %w", err)
+			}
+
+			if output == "json" {
+				return util.PrintJSON(cmd.OutOrStdout(), struct {
+					*v1alpha1.Display
+					RecentContent []v1alpha1.ContentHistoryEntry `json:"recentContent"`
+				}{d, history.Items})
+			}
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "Name:        %s\n", d.Name)
+			fmt.Fprintf(out, "ID:          %s\n", d.ID)
+			fmt.Fprintf(out, "Site:        %s\n", d.Spec.Location.SiteID)
+			fmt.Fprintf(out, "Zone:        %s\n", d.Spec.Location.Zone)
+			fmt.Fprintf(out, "Position:    %s\n", d.Spec.Location.Position)
+			fmt.Fprintf(out, "State:       %s\n", d.Status.State)
+			fmt.Fprintf(out, "Last Seen:   %s\n", formatLastSeen(d.Status.LastSeen))
+			if tz != "" && !d.Status.LastSeen.IsZero() {
+				fmt.Fprintf(out, "Seen At:     %s\n", d.Status.LastSeen.Format(time.RFC3339))
+			}
+			if d.Status.Timezone != "" {
+				fmt.Fprintf(out, "Timezone:    %s (%s)\n", d.Status.Timezone, d.Status.TimezoneSource)
+			}
+			if e := d.Status.LastError; e != nil {
+				fmt.Fprintf(out, "Last Error:  %s: %s (%s, %s)\n",
+					e.Code, e.Message, e.URL, util.FormatDuration(time.Since(e.Timestamp)))
+			}
+			if len(d.Spec.Properties) > 0 {
+				fmt.Fprintf(out, "Properties:  %s\n", util.FormatProperties(d.Spec.Properties))
+			}
+			if d.Spec.Settings != nil {
+				fmt.Fprintf(out, "Settings:    %s\n", formatSettings(d.Spec.Settings))
+			}
+
+			fmt.Fprintln(out)
+			if len(history.Items) == 0 {
+				fmt.Fprintln(out, "Recent Content: none recorded")
+				return nil
+			}
+			fmt.Fprintln(out, "Recent Content:")
+			tw := util.NewTabWriter(out)
+			defer tw.Flush()
+			fmt.Fprintf(tw, "  WHEN\tTRIGGER\tURL\n")
+			for _, t := range history.Items {
+				fmt.Fprintf(tw, "  %s\t%s\t%s\n",
+					util.FormatDuration(time.Since(t.Timestamp)),
+					t.Trigger,
+					t.ToURL)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format (text, json)")
+	util.AddTimezoneFlag(cmd)
+
+	return cmd
+}
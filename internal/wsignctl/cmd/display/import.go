@@ -0,0 +1,318 @@
+package display
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/client"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+// Columns of a display import file. Any other column must be a label
+// column named label.<key>.
+const (
+	columnName     = "name"
+	columnSiteID   = "site_id"
+	columnZone     = "zone"
+	columnPosition = "position"
+	labelPrefix    = "label."
+)
+
+// importRow is a display read from an import file
+type importRow struct {
+	// line is the line of the file the row starts on
+	line     int
+	name     string
+	location v1alpha1.DisplayLocation
+	labels   map[string]string
+}
+
+// Outcomes of importing a row that succeeded
+const (
+	outcomeCreated = "created"
+	outcomeUpdated = "updated"
+	outcomeSkipped = "skipped"
+)
+
+// codeDisplayExists is the error code the server reports when a display
+// name is already taken
+const codeDisplayExists = "DISPLAY_EXISTS"
+
+// displayExists reports whether err is the server refusing a name that is
+// already taken. Other conflicts, such as a full zone, are failures.
+func displayExists(err error) bool {
+	var apiErr *client.APIError
+	return errors.As(err, &apiErr) && apiErr.Code == codeDisplayExists
+}
+
+// importClient is the part of the API client an import uses
+type importClient interface {
+	RegisterDisplay(ctx context.Context, req *v1alpha1.DisplayRegistrationRequest) (*v1alpha1.Display, error)
+	UpdateDisplay(ctx context.Context, name string, location *v1alpha1.DisplayLocation, addProps map[string]string, removeProps []string) error
+}
+
+func newImportCommand() *cobra.Command {
+	var (
+		dryRun         bool
+		updateExisting bool
+		bulk           util.BulkOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import FILE",
+		Short: "Pre-configure displays from a CSV file",
+		Long: `Create display entries for every row of a CSV file, as with "display create".
+
+The first row names the columns. name, site_id, zone and position are
+required; each label.<key> column sets the label <key>, and an empty cell
+leaves the label unset. Every row is checked before anything is sent, and
+all problems are reported with their line numbers.
+
+Displays whose name is already taken are skipped, or with --update-existing
+moved and relabelled to match the file. Rows are sent --concurrency at a
+time. A failed row does not stop the others unless --fail-fast is set, and
+Ctrl-C stops sending new rows once those in flight have finished. Either
+way the rows that failed or were never sent are listed at the end, and the
+command exits with an error if there were any.`,
+		Example: `  # Check a file without creating anything
+  wsignctl display import displays.csv --dry-run
+
+  # Create the displays, updating those that already exist
+  wsignctl display import displays.csv --update-existing
+
+  # displays.csv
+  name,site_id,zone,position,label.orientation
+  lobby-north,hq,lobby,north,landscape
+  cafe-menu-1,hq,cafeteria,menu-1,portrait`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := bulk.Validate(); err != nil {
+				return err
+			}
+
+			f, err := os.Open(args[0])
+			if err != nil {
+				return fmt.Errorf("error opening import file: %w", err)
+			}
+			defer f.Close()
+
+			rows, err := parseImportFile(f)
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			if dryRun {
+				fmt.Fprintf(out, "%d display(s) ready to import; nothing was sent (dry run)\n", len(rows))
+				return nil
+			}
+
+			client, err := getClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := util.NotifyInterrupt(cmd.Context())
+			defer stop()
+			progress := util.NewProgress(cmd.ErrOrStderr(), "Importing displays", len(rows))
+			summary := importDisplays(ctx, client, rows, updateExisting, bulk, progress)
+			util.PrintBulkSummary(out, summary, outcomeCreated, outcomeUpdated, outcomeSkipped)
+			return summary.Err("display(s)")
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Check the file without creating any displays")
+	cmd.Flags().BoolVar(&updateExisting, "update-existing", false, "Update the location and labels of displays that already exist")
+	util.AddBulkFlags(cmd, &bulk)
+
+	return cmd
+}
+
+// parseImportFile reads and checks every row of an import file. All problems
+// found are returned together, each with its line number.
+func parseImportFile(r io.Reader) ([]importRow, error) {
+	// Spreadsheet exports often start with a byte order mark
+	buffered := bufio.NewReader(r)
+	if bom, err := buffered.Peek(3); err == nil && bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		_, _ = buffered.Discard(3)
+	}
+
+	reader := csv.NewReader(buffered)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("import file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading import file: %w", err)
+	}
+
+	columns, err := parseImportHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		rows     []importRow
+		problems []string
+		seen     = make(map[string]int)
+	)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// The reader cannot resynchronise after a malformed quote
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				problems = append(problems, fmt.Sprintf("line %d: %v", parseErr.StartLine, parseErr.Err))
+				break
+			}
+			return nil, fmt.Errorf("error reading import file: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if len(record) != len(header) {
+			problems = append(problems, fmt.Sprintf("line %d: has %d fields, the header has %d", line, len(record), len(header)))
+			continue
+		}
+
+		row := importRow{line: line, labels: make(map[string]string)}
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			switch column := columns[i]; column {
+			case columnName:
+				row.name = value
+			case columnSiteID:
+				row.location.SiteID = value
+			case columnZone:
+				row.location.Zone = value
+			case columnPosition:
+				row.location.Position = value
+			default:
+				if value != "" {
+					row.labels[strings.TrimPrefix(column, labelPrefix)] = value
+				}
+			}
+		}
+
+		var missing []string
+		for _, field := range []struct{ column, value string }{
+			{columnName, row.name},
+			{columnSiteID, row.location.SiteID},
+			{columnZone, row.location.Zone},
+			{columnPosition, row.location.Position},
+		} {
+			if field.value == "" {
+				missing = append(missing, field.column)
+			}
+		}
+		if len(missing) > 0 {
+			problems = append(problems, fmt.Sprintf("line %d: %s cannot be empty", line, strings.Join(missing, ", ")))
+			continue
+		}
+		if first, ok := seen[row.name]; ok {
+			problems = append(problems, fmt.Sprintf("line %d: display %q is already listed on line %d", line, row.name, first))
+			continue
+		}
+		seen[row.name] = line
+		rows = append(rows, row)
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("import file has %d problem(s):\n  %s", len(problems), strings.Join(problems, "\n  "))
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("import file has no displays")
+	}
+	return rows, nil
+}
+
+// parseImportHeader checks the header row and returns the column names.
+// Column names are not case sensitive, but label keys keep their case.
+func parseImportHeader(header []string) ([]string, error) {
+	columns := make([]string, len(header))
+	found := make(map[string]bool)
+	for i, column := range header {
+		column = strings.TrimSpace(column)
+		switch lower := strings.ToLower(column); {
+		case lower == columnName, lower == columnSiteID, lower == columnZone, lower == columnPosition:
+			column = lower
+		case strings.HasPrefix(lower, labelPrefix) && len(column) > len(labelPrefix):
+			column = labelPrefix + column[len(labelPrefix):]
+			if err := util.ValidateLabelKey(column[len(labelPrefix):]); err != nil {
+				return nil, fmt.Errorf("line 1: column %q: %w", column, err)
+			}
+		default:
+			return nil, fmt.Errorf("line 1: unknown column %q; use name, site_id, zone, position or label.<key>", column)
+		}
+		if found[column] {
+			return nil, fmt.Errorf("line 1: column %q appears more than once", column)
+		}
+		found[column] = true
+		columns[i] = column
+	}
+
+	var missing []string
+	for _, required := range []string{columnName, columnSiteID, columnZone, columnPosition} {
+		if !found[required] {
+			missing = append(missing, required)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("line 1: missing column(s) %s", strings.Join(missing, ", "))
+	}
+	return columns, nil
+}
+
+// importDisplays registers every row, as many at once as opts allows, and
+// returns the result of each in the order of the rows
+func importDisplays(ctx context.Context, c importClient, rows []importRow, updateExisting bool, opts util.BulkOptions, progress *util.Progress) *util.BulkSummary {
+	items := make([]string, len(rows))
+	for i, row := range rows {
+		items[i] = fmt.Sprintf("line %d: %s", row.line, row.name)
+	}
+	return util.RunBulk(ctx, items, opts, progress, func(ctx context.Context, i int) (string, error) {
+		return importDisplay(ctx, c, rows[i], updateExisting)
+	})
+}
+
+// importDisplay registers one row, falling back to an update when the
+// display exists and updateExisting is set
+func importDisplay(ctx context.Context, c importClient, row importRow, updateExisting bool) (string, error) {
+	_, err := c.RegisterDisplay(ctx, &v1alpha1.DisplayRegistrationRequest{
+		Name:       row.name,
+		Location:   row.location,
+		Properties: row.labels,
+	})
+	switch {
+	case err == nil:
+		return outcomeCreated, nil
+	case !displayExists(err):
+		return "", err
+	case !updateExisting:
+		return outcomeSkipped, nil
+	}
+
+	location := row.location
+	if err := c.UpdateDisplay(ctx, row.name, &location, row.labels, nil); err != nil {
+		return "", err
+	}
+	return outcomeUpdated, nil
+}
@@ -0,0 +1,211 @@
+package display
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/activation"
+	displayhttp "github.com/wrale/wrale-signage/internal/wsignd/display/http"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+func TestParseImportFile(t *testing.T) {
+	t.Run("rows and labels", func(t *testing.T) {
+		rows, err := parseImportFile(strings.NewReader("\xef\xbb\xbf" +
+			"Name,site_id,zone,position,label.Orientation,label.note\n" +
+			"lobby-north,hq,lobby,north,landscape,\"by the doors, left\"\n" +
+			"\n" +
+			"cafe-menu-1, hq ,cafeteria,menu-1,portrait,\"two\nlines\"\n" +
+			"cafe-menu-2,hq,cafeteria,menu-2,,\n"))
+		require.NoError(t, err)
+		require.Len(t, rows, 3)
+
+		assert.Equal(t, importRow{
+			line:     2,
+			name:     "lobby-north",
+			location: v1alpha1.DisplayLocation{SiteID: "hq", Zone: "lobby", Position: "north"},
+			labels:   map[string]string{"Orientation": "landscape", "note": "by the doors, left"},
+		}, rows[0])
+		assert.Equal(t, 4, rows[1].line)
+		assert.Equal(t, "hq", rows[1].location.SiteID)
+		assert.Equal(t, "two\nlines", rows[1].labels["note"])
+		assert.Equal(t, 6, rows[2].line, "line numbers count the lines of quoted fields")
+		assert.Empty(t, rows[2].labels, "empty cells leave labels unset")
+	})
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr []string
+	}{
+		{
+			name:    "empty file",
+			input:   "",
+			wantErr: []string{"import file is empty"},
+		},
+		{
+			name:    "header only",
+			input:   "name,site_id,zone,position\n",
+			wantErr: []string{"no displays"},
+		},
+		{
+			name:    "missing columns",
+			input:   "name,zone\nlobby-north,lobby\n",
+			wantErr: []string{"line 1: missing column(s) site_id, position"},
+		},
+		{
+			name:    "unknown column",
+			input:   "name,site_id,zone,position,colour\n",
+			wantErr: []string{`line 1: unknown column "colour"`},
+		},
+		{
+			name:    "label without a key",
+			input:   "name,site_id,zone,position,label.\n",
+			wantErr: []string{`unknown column "label."`},
+		},
+		{
+			name:    "reserved label",
+			input:   "name,site_id,zone,position,label.sys.agentVersion\n",
+			wantErr: []string{`column "label.sys.agentVersion"`, "reserved"},
+		},
+		{
+			name:    "repeated column",
+			input:   "name,site_id,zone,position,Zone\n",
+			wantErr: []string{`column "zone" appears more than once`},
+		},
+		{
+			name: "every row problem is reported",
+			input: "name,site_id,zone,position\n" +
+				"lobby-north,hq,lobby,north\n" +
+				",hq,lobby,\n" +
+				"lobby-south,hq,lobby\n" +
+				"lobby-north,hq,lobby,east\n",
+			wantErr: []string{
+				"3 problem(s)",
+				"line 3: name, position cannot be empty",
+				"line 4: has 3 fields, the header has 4",
+				`line 5: display "lobby-north" is already listed on line 2`,
+			},
+		},
+		{
+			name: "malformed quote",
+			input: "name,site_id,zone,position\n" +
+				"lobby-north,hq,lobby,north\n" +
+				"lobby-south,hq,\"lobby,south\n",
+			wantErr: []string{"line 3:"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := parseImportFile(strings.NewReader(tt.input))
+			require.Error(t, err)
+			assert.Nil(t, rows)
+			for _, want := range tt.wantErr {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
+
+func TestImportCommand(t *testing.T) {
+	t.Setenv("WSIGN_INSECURE_SKIP_TLS", "false")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	service := display.NewService(memory.NewRepository(), noopPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{},
+		display.Capacity{Zones: map[string]int{display.ZoneKey("hq", "rooftop"): 1}}, logger)
+	activations := activation.NewService(memory.NewActivationRepository(), time.Minute, activation.SitePolicy{})
+	router := displayhttp.NewRouter(displayhttp.NewHandler(service, activations, nil, logger), ratelimit.NewMemoryService(nil), nil)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	ctx := context.Background()
+
+	_, err := service.Register(ctx, "lobby-north", display.Location{SiteID: "hq", Zone: "lobby", Position: "north"},
+		map[string]string{"orientation": "landscape"})
+	require.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), "displays.csv")
+	require.NoError(t, os.WriteFile(file, []byte(
+		"name,site_id,zone,position,label.orientation\n"+
+			"lobby-north,hq,lobby,east,portrait\n"+
+			"cafe-menu-1,hq,cafeteria,menu-1,portrait\n"+
+			"cafe-menu-2,hq,cafeteria,menu-2,\n"+
+			"roof-1,hq,rooftop,west,\n"+
+			"roof-2,hq,rooftop,east,\n"), 0o600))
+
+	run := func(args ...string) (string, error) {
+		cmd := newImportCommand()
+		cmd.Flags().String("server", server.URL, "")
+		cmd.Flags().String("token", "test-token", "")
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&out)
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	t.Run("dry run sends nothing", func(t *testing.T) {
+		out, err := run(file, "--dry-run")
+		require.NoError(t, err)
+		assert.Contains(t, out, "5 display(s) ready to import")
+
+		_, err = service.GetByName(ctx, "cafe-menu-1")
+		assert.Error(t, err)
+	})
+
+	t.Run("existing displays are skipped and failures reported", func(t *testing.T) {
+		out, err := run(file, "--concurrency=2")
+		require.EqualError(t, err, "1 of 5 display(s) failed")
+		assert.Contains(t, out, "Importing displays: 5/5 (1 failed)")
+		assert.Contains(t, out, "Created 3, updated 0, skipped 1, failed 1\n")
+		assert.Regexp(t, `line [56]: roof-[12] +failed +.*HTTP 409`, out)
+
+		created, err := service.GetByName(ctx, "cafe-menu-1")
+		require.NoError(t, err)
+		assert.Equal(t, display.Location{SiteID: "hq", Zone: "cafeteria", Position: "menu-1"}, created.Location)
+		assert.Equal(t, map[string]string{"orientation": "portrait"}, created.Properties)
+
+		existing, err := service.GetByName(ctx, "lobby-north")
+		require.NoError(t, err)
+		assert.Equal(t, "north", existing.Location.Position, "skipped displays are left alone")
+	})
+
+	t.Run("existing displays can be updated", func(t *testing.T) {
+		out, err := run(file, "--update-existing")
+		require.Error(t, err, "the rooftop zone is still full")
+		assert.Contains(t, out, "Created 0, updated 4, skipped 0, failed 1")
+
+		existing, err := service.GetByName(ctx, "lobby-north")
+		require.NoError(t, err)
+		assert.Equal(t, "east", existing.Location.Position)
+		assert.Equal(t, map[string]string{"orientation": "portrait"}, existing.Properties)
+	})
+
+	t.Run("invalid files are rejected before anything is sent", func(t *testing.T) {
+		bad := filepath.Join(t.TempDir(), "bad.csv")
+		require.NoError(t, os.WriteFile(bad, []byte("name,site_id,zone,position\nlobby-west,hq,,west\n"), 0o600))
+
+		_, err := run(bad)
+		require.ErrorContains(t, err, "line 2: zone cannot be empty")
+		_, err = service.GetByName(ctx, "lobby-west")
+		assert.Error(t, err)
+	})
+}
+
+// noopPublisher discards display events
+type noopPublisher struct{}
+
+func (noopPublisher) Publish(ctx context.Context, event display.Event) error { return nil }
@@ -2,11 +2,13 @@ package display
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/client"
 	"github.com/wrale/wrale-signage/internal/wsignctl/util"
 )
 
@@ -16,71 +18,119 @@ func newListCommand() *cobra.Command {
 		siteID   string
 		zone     string
 		position string
+		labels   map[string]string
 		output   string
 		showLast bool
+		showErrs bool
+		never    bool
+		seen     bool
+		limit    int
+		watch    bool
+		interval time.Duration
 	)
 
 	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List displays",
-		Long: `List displays in the system, optionally filtered by location.
+		Long: `List displays in the system, optionally filtered by location and by
+display properties given with --match-label.
 		
 The output can be formatted as a table (default) or as JSON for scripting.
-Use --show-last to include the last content URL each display loaded.`,
+-o wide adds whether each display is connected, the control messages queued
+for it and the content it last loaded; JSON output includes the same under
+status.runtime when --show-last is given. Connection state is as seen by the
+server that answered.
+Use --show-last to include the last content URL each display loaded.
+Use --show-errors to include the content error each display last reported,
+cleared once it loads content successfully.
+Use --never-seen to find displays that were created but have never
+connected, such as ones not yet installed, and --seen for the rest.
+Use --watch to keep the list up to date until interrupted.
+Use --tz, or configure a time zone on the context, to have JSON output give
+times in that zone; --tz local uses each display's own zone.`,
 		Example: `  # List all displays
   wsignctl display list
   
   # List displays at a specific site
   wsignctl display list --site-id=hq
   
+  # List the portrait displays at a site
+  wsignctl display list --site-id=hq --match-label orientation=portrait
+  
   # Show display status with content information
-  wsignctl display list --show-last -o json`,
+  wsignctl display list --show-last -o json
+
+  # See which displays are connected and what they are showing
+  wsignctl display list -o wide
+
+  # Spot displays that are currently failing to load content
+  wsignctl display list --show-errors
+
+  # Find displays at a site that were never installed
+  wsignctl display list --site-id=hq --never-seen
+
+  # Keep watching the displays at a site
+  wsignctl display list --site-id=hq --watch`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			client, err := getClient()
+			var opts client.DisplayListOptions
+			if never || seen {
+				opts.NeverSeen = &never
+			}
+			tz, err := util.Timezone(cmd)
+			if err != nil {
+				return err
+			}
+			opts.Timezone = tz
+			opts.Runtime = showLast || output == "wide"
+
+			client, err := getClient(cmd)
 			if err != nil {
 				return err
 			}
 
 			// Build location filter
 			filter := v1alpha1.DisplaySelector{
-				SiteID:   siteID,
-				Zone:     zone,
-				Position: position,
+				SiteID:          siteID,
+				Zone:            zone,
+				Position:        position,
+				MatchProperties: labels,
 			}
 
-			displays, err := client.ListDisplays(cmd.Context(), filter)
-			if err != nil {
-				return fmt.Errorf("error listing displays: %w", err)
+			list := func() error {
+				var displays []v1alpha1.Display
+				err := client.ForEachDisplay(cmd.Context(), filter, opts, limit, func(d v1alpha1.Display) error {
+					displays = append(displays, d)
+					return nil
+				})
+				if err != nil {
+					return fmt.Errorf("error listing displays: %w", err)
+				}
+				return printDisplays(cmd, displays, output, displayColumns{
+					lastContent: showLast,
+					errors:      showErrs,
+				})
+			}
+			if !watch {
+				return list()
 			}
 
-			// Format output based on requested format
-			switch output {
-			case "json":
-				return util.PrintJSON(cmd.OutOrStdout(), displays)
-			default:
-				tw := util.NewTabWriter(cmd.OutOrStdout())
-				defer tw.Flush()
-
-				// Print header row
-				fmt.Fprintf(tw, "NAME\tSITE\tZONE\tPOSITION\tSTATE\tLAST SEEN\tPROPERTIES\n")
-
-				// Print each display as a row
-				for _, d := range displays {
-					lastSeen := util.FormatDuration(time.Since(d.Status.LastSeen))
-					props := util.FormatProperties(d.Spec.Properties)
-
-					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
-						d.Name,
-						d.Spec.Location.SiteID,
-						d.Spec.Location.Zone,
-						d.Spec.Location.Position,
-						d.Status.State,
-						lastSeen,
-						props)
+			// No watch stream is consumed yet, so the list is polled; warn
+			// when the server could not have offered anything better
+			util.CheckFeature(cmd, client, v1alpha1.FeatureWatch,
+				fmt.Sprintf("refreshing every %s", interval))
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				if err := list(); err != nil {
+					return err
+				}
+				select {
+				case <-cmd.Context().Done():
+					return nil
+				case <-ticker.C:
+					fmt.Fprintln(cmd.OutOrStdout())
 				}
 			}
-
-			return nil
 		},
 	}
 
@@ -88,8 +138,126 @@ Use --show-last to include the last content URL each display loaded.`,
 	cmd.Flags().StringVar(&siteID, "site-id", "", "Filter by site")
 	cmd.Flags().StringVar(&zone, "zone", "", "Filter by zone")
 	cmd.Flags().StringVar(&position, "position", "", "Filter by position")
-	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format (table, json)")
+	cmd.Flags().StringToStringVar(&labels, "match-label", nil, "Filter by display property, as key=value (repeatable)")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format (table, wide, json)")
 	cmd.Flags().BoolVar(&showLast, "show-last", false, "Show last content loaded")
+	cmd.Flags().BoolVar(&showErrs, "show-errors", false, "Show the last content error of each display")
+	cmd.Flags().BoolVar(&never, "never-seen", false, "Only list displays that have never connected")
+	cmd.Flags().BoolVar(&seen, "seen", false, "Only list displays that have connected at least once")
+	cmd.MarkFlagsMutuallyExclusive("never-seen", "seen")
+	cmd.Flags().IntVar(&limit, "limit", 0, "Maximum number of displays to list (0 for all)")
+	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "Keep listing displays until interrupted")
+	cmd.Flags().DurationVar(&interval, "watch-interval", 5*time.Second, "How often to refresh when watching")
+	util.AddTimezoneFlag(cmd)
 
 	return cmd
 }
+
+// displayColumns are the optional columns of the display table
+type displayColumns struct {
+	lastContent bool
+	errors      bool
+}
+
+// printDisplays writes displays in the requested output format. Wide
+// output adds the runtime columns, including the last content.
+func printDisplays(cmd *cobra.Command, displays []v1alpha1.Display, output string, columns displayColumns) error {
+	wide := output == "wide"
+	switch output {
+	case "json":
+		return util.PrintJSON(cmd.OutOrStdout(), displays)
+	default:
+		tw := util.NewTabWriter(cmd.OutOrStdout())
+		defer tw.Flush()
+
+		// Print header row
+		header := "NAME\tSITE\tZONE\tPOSITION\tSTATE\tLAST SEEN\tPROPERTIES"
+		if wide {
+			header += "\tCONNECTED\tPENDING"
+		}
+		if wide || columns.lastContent {
+			header += "\tLAST CONTENT"
+		}
+		if columns.errors {
+			header += "\tLAST ERROR"
+		}
+		fmt.Fprintln(tw, header)
+
+		// Print each display as a row
+		for _, d := range displays {
+			lastSeen := formatLastSeen(d.Status.LastSeen)
+			props := util.FormatProperties(d.Spec.Properties)
+
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s",
+				d.Name,
+				d.Spec.Location.SiteID,
+				d.Spec.Location.Zone,
+				d.Spec.Location.Position,
+				d.Status.State,
+				lastSeen,
+				props)
+			if wide {
+				fmt.Fprintf(tw, "\t%s\t%s", formatConnected(d.Status.Runtime), formatPending(d.Status.Runtime))
+			}
+			if wide || columns.lastContent {
+				fmt.Fprintf(tw, "\t%s", formatLastContent(d.Status.Runtime))
+			}
+			if columns.errors {
+				fmt.Fprintf(tw, "\t%s", formatLastError(d.Status.LastError))
+			}
+			fmt.Fprintln(tw)
+		}
+	}
+
+	return nil
+}
+
+// formatLastSeen describes when a display last connected, or that it never
+// has
+func formatLastSeen(t time.Time) string {
+	if t.IsZero() {
+		return "Never"
+	}
+	return util.FormatDuration(time.Since(t))
+}
+
+// formatLastError summarises a display's last content error for a table cell
+func formatLastError(e *v1alpha1.DisplayContentError) string {
+	if e == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%s %s (%s)", e.Code, e.URL, util.FormatDuration(time.Since(e.Timestamp)))
+}
+
+// formatConnected tells whether a display is connected, or "-" when the
+// server did not say
+func formatConnected(rt *v1alpha1.DisplayRuntime) string {
+	switch {
+	case rt == nil:
+		return "-"
+	case rt.Connected:
+		return "yes"
+	default:
+		return "no"
+	}
+}
+
+// formatPending gives how many messages are queued for a display
+func formatPending(rt *v1alpha1.DisplayRuntime) string {
+	if rt == nil {
+		return "-"
+	}
+	return strconv.Itoa(rt.PendingMessages)
+}
+
+// formatLastContent summarises the content a display last loaded for a
+// table cell
+func formatLastContent(rt *v1alpha1.DisplayRuntime) string {
+	if rt == nil || rt.LastContentURL == "" {
+		return "-"
+	}
+	if rt.LastContentAt == nil {
+		return rt.LastContentURL
+	}
+	return fmt.Sprintf("%s (%s)", rt.LastContentURL, util.FormatDuration(time.Since(*rt.LastContentAt)))
+}
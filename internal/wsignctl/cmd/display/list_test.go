@@ -0,0 +1,146 @@
+package display
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	displayhttp "github.com/wrale/wrale-signage/internal/wsignd/display/http"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+// update rewrites the golden files from the current output
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// goldenDisplays is fixed list output with enough properties that map
+// order would show through
+var goldenDisplays = []v1alpha1.Display{
+	{
+		ObjectMeta: v1alpha1.ObjectMeta{Name: "lobby-north"},
+		Spec: v1alpha1.DisplaySpec{
+			Location: v1alpha1.DisplayLocation{SiteID: "hq", Zone: "lobby", Position: "north"},
+			Properties: map[string]string{
+				"orientation": "portrait", "screen-size": "55", "floor": "1",
+				"vendor": "acme", "model": "x200", "audio": "off",
+			},
+		},
+		Status: v1alpha1.DisplayStatus{State: v1alpha1.DisplayStateActive},
+	},
+	{
+		ObjectMeta: v1alpha1.ObjectMeta{Name: "cafeteria-menu-board"},
+		Spec: v1alpha1.DisplaySpec{
+			Location:   v1alpha1.DisplayLocation{SiteID: "annex", Zone: "cafeteria", Position: "wall"},
+			Properties: map[string]string{"touch": "yes", "orientation": "landscape", "brightness": "high"},
+		},
+		Status: v1alpha1.DisplayStatus{State: v1alpha1.DisplayStateOffline},
+	},
+}
+
+// TestListCommandGolden runs the same list twice against fixed data and
+// checks both runs match the golden output byte for byte
+func TestListCommandGolden(t *testing.T) {
+	t.Setenv("WSIGN_INSECURE_SKIP_TLS", "false")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, util.PrintJSON(w, goldenDisplays))
+	}))
+	t.Cleanup(server.Close)
+
+	run := func(args ...string) []byte {
+		cmd := newListCommand()
+		cmd.Flags().String("server", server.URL, "")
+		cmd.Flags().String("token", "test-token", "")
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs(args)
+		require.NoError(t, cmd.Execute())
+		return out.Bytes()
+	}
+
+	for _, tc := range []struct {
+		golden string
+		args   []string
+	}{
+		{"list.golden", []string{}},
+		{"list-json.golden", []string{"-o", "json"}},
+	} {
+		t.Run(tc.golden, func(t *testing.T) {
+			first := run(tc.args...)
+			assert.Equal(t, string(first), string(run(tc.args...)), "consecutive runs differ")
+
+			path := filepath.Join("testdata", tc.golden)
+			if *update {
+				require.NoError(t, os.WriteFile(path, first, 0o644))
+			}
+			want, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), string(first))
+		})
+	}
+}
+
+func TestListCommandRuntime(t *testing.T) {
+	t.Setenv("WSIGN_INSECURE_SKIP_TLS", "false")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := memory.NewRepository()
+	service := display.NewService(repo, noopPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, logger)
+	handler := displayhttp.NewHandler(service, nil, nil, logger)
+	handler.SetEnricher(display.NewEnricher(repo, handler, nil))
+	server := httptest.NewServer(displayhttp.NewRouter(handler, ratelimit.NewMemoryService(nil), nil))
+	t.Cleanup(server.Close)
+	ctx := context.Background()
+
+	d, err := service.Register(ctx, "lobby-north", display.Location{SiteID: "hq", Zone: "lobby", Position: "north"}, nil)
+	require.NoError(t, err)
+	require.NoError(t, service.RecordContentChange(ctx, d.ID, "https://example.com/menu", display.TriggerAssignmentChange))
+
+	run := func(args ...string) string {
+		cmd := newListCommand()
+		cmd.Flags().String("server", server.URL, "")
+		cmd.Flags().String("token", "test-token", "")
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&out)
+		cmd.SetArgs(args)
+		require.NoError(t, cmd.Execute())
+		return out.String()
+	}
+	row := func(out string) []string {
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		require.Len(t, lines, 2)
+		return strings.Fields(lines[1])
+	}
+
+	out := run()
+	assert.NotContains(t, out, "CONNECTED")
+	assert.NotContains(t, out, "example.com")
+
+	out = run("-o", "wide")
+	assert.Contains(t, out, "CONNECTED")
+	assert.Contains(t, out, "LAST CONTENT")
+	fields := row(out)
+	assert.Contains(t, fields, "no")
+	assert.Contains(t, fields, "https://example.com/menu")
+
+	out = run("--show-last")
+	assert.NotContains(t, out, "CONNECTED")
+	assert.Contains(t, row(out), "https://example.com/menu")
+
+	out = run("--show-last", "-o", "json")
+	assert.Contains(t, out, `"lastContentUrl": "https://example.com/menu"`)
+}
@@ -0,0 +1,93 @@
+package display
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+func newMessagesCommand() *cobra.Command {
+	var (
+		output string
+		state  string
+		replay string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "messages NAME",
+		Short: "List or replay a display's queued control messages",
+		Long: `List the control messages queued for a display while it was not connected.
+Queued messages are delivered in order once the display connects. Messages
+that expire first become dead letters and stay until they are replayed.
+
+Use --replay with a message ID to queue a dead letter again. It is delivered
+at once if the display is connected. The server only queues messages when
+its outbox is enabled.`,
+		Example: `  # Show everything queued for a display
+  wsignctl display messages lobby-north
+
+  # Show only dead letters
+  wsignctl display messages lobby-north --state dead
+
+  # Replay a dead letter
+  wsignctl display messages lobby-north --replay 5f0c6c1e-2b4a-4d6e-9c1f-0a7d3e8b9c21`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			client, err := getClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			if replay != "" {
+				m, err := client.ReplayPendingMessage(cmd.Context(), name, replay)
+				if err != nil {
+					return fmt.Errorf("error replaying message: %w", err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Message %s for display %q is %s\n", m.ID, name, m.State)
+				return nil
+			}
+
+			messages, err := client.ListPendingMessages(cmd.Context(), name, v1alpha1.PendingMessageState(state))
+			if err != nil {
+				return fmt.Errorf("error listing messages: %w", err)
+			}
+
+			if output == "json" {
+				return util.PrintJSON(cmd.OutOrStdout(), messages)
+			}
+
+			if len(messages.Items) == 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "Display %q has no queued messages\n", name)
+				return nil
+			}
+
+			tw := util.NewTabWriter(cmd.OutOrStdout())
+			defer tw.Flush()
+			fmt.Fprintf(tw, "MESSAGE\tTYPE\tSTATE\tATTEMPTS\tQUEUED\tEXPIRES\tLAST ERROR\n")
+			for _, m := range messages.Items {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+					m.ID,
+					m.Message.Type,
+					m.State,
+					m.Attempts,
+					util.FormatDuration(time.Since(m.CreatedAt)),
+					m.ExpiresAt.Local().Format("2006-01-02 15:04"),
+					m.LastError,
+				)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&state, "state", "", "Only list messages in this state (pending, delivered, dead)")
+	cmd.Flags().StringVar(&replay, "replay", "", "ID of a dead letter to queue again")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format (table, json)")
+
+	return cmd
+}
@@ -0,0 +1,148 @@
+package display
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/client"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+func newProposalsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "proposals",
+		Short: "Review location changes proposed by displays",
+		Long: `Displays can propose a correction to their own location, for example when
+an installer mounted one somewhere other than planned. A proposal changes
+nothing until an operator approves it; approving applies the location like
+'wsignctl display update' would and is recorded in the server's audit log.
+
+Proposals nobody decides on expire after a while
+(WSIGN_DISPLAY_LOCATION_PROPOSAL_MAX_AGE, a week by default).`,
+	}
+
+	cmd.AddCommand(
+		newProposalsListCommand(),
+		newProposalDecisionCommand("approve", "Apply a proposed location to its display", (*client.Client).ApproveLocationProposal),
+		newProposalDecisionCommand("reject", "Turn a proposed location down", (*client.Client).RejectLocationProposal),
+	)
+
+	return cmd
+}
+
+func newProposalsListCommand() *cobra.Command {
+	var (
+		state  string
+		output string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List location proposals",
+		Example: `  # Show proposals waiting for a decision
+  wsignctl display proposals list
+
+  # Show every proposal, whatever became of it
+  wsignctl display proposals list --state ""`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := getClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			proposals, err := client.ListLocationProposals(cmd.Context(), v1alpha1.LocationProposalState(state))
+			if err != nil {
+				return fmt.Errorf("error listing location proposals: %w", err)
+			}
+
+			if output == "json" {
+				return util.PrintJSON(cmd.OutOrStdout(), proposals)
+			}
+
+			if len(proposals) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "No location proposals found")
+				return nil
+			}
+
+			names := displayNames(cmd.Context(), client, proposals)
+			tw := util.NewTabWriter(cmd.OutOrStdout())
+			defer tw.Flush()
+			fmt.Fprintf(tw, "ID\tDISPLAY\tFROM\tTO\tSTATE\tPROPOSED\tNOTE\n")
+			for _, p := range proposals {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					p.ID,
+					names[p.DisplayID.String()],
+					formatLocation(p.PreviousLocation),
+					formatLocation(p.Location),
+					p.State,
+					util.FormatDuration(time.Since(p.CreatedAt)),
+					p.Note,
+				)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&state, "state", string(v1alpha1.LocationProposalPending), "Only list proposals in this state (pending, approved, rejected, expired; empty for all)")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format (table, json)")
+
+	return cmd
+}
+
+func newProposalDecisionCommand(action, short string, decide func(*client.Client, context.Context, string) (*v1alpha1.LocationProposal, error)) *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   action + " ID",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := getClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			p, err := decide(client, cmd.Context(), args[0])
+			if err != nil {
+				return fmt.Errorf("error deciding location proposal: %w", err)
+			}
+
+			if output == "json" {
+				return util.PrintJSON(cmd.OutOrStdout(), p)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Location proposal %s %s: %s\n", p.ID, p.State, formatLocation(p.Location))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format (table, json)")
+
+	return cmd
+}
+
+// displayNames maps the IDs of the proposing displays to their names,
+// falling back to the ID for displays that cannot be looked up
+func displayNames(ctx context.Context, c *client.Client, proposals []v1alpha1.LocationProposal) map[string]string {
+	names := make(map[string]string)
+	for _, p := range proposals {
+		id := p.DisplayID.String()
+		if _, ok := names[id]; ok {
+			continue
+		}
+		names[id] = id
+		if d, err := c.GetDisplay(ctx, id); err == nil {
+			names[id] = d.Name
+		}
+	}
+	return names
+}
+
+// formatLocation writes a location as site/zone/position
+func formatLocation(l v1alpha1.DisplayLocation) string {
+	return fmt.Sprintf("%s/%s/%s", l.SiteID, l.Zone, l.Position)
+}
@@ -0,0 +1,151 @@
+package display
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+// newProvisionCommand creates a command for provisioning displays that are
+// set up offline
+func newProvisionCommand() *cobra.Command {
+	var (
+		siteID   string
+		zone     string
+		position string
+		labels   []string
+		output   string
+		force    bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "provision NAME",
+		Short: "Provision a display that is set up offline",
+		Long: `Register a display ahead of installation and write the signed provisioning
+file it is set up from, for sites where displays cannot reach the server
+during installation.
+
+The file holds the display's identity, location and labels, the server URL
+and a single use enrollment token, signed with the server's signing key so
+that the display can check it came from the server. Copy it to the display,
+for example on a USB stick; once online the display exchanges the token for
+its access token. Keep the file safe until then: anyone holding it can
+enroll as the display.
+
+The file is written to NAME.wsp unless --output names another file, and
+existing files are not overwritten unless --force is given.`,
+		Example: `  # Provision the north lobby display onto a USB stick
+  wsignctl display provision lobby-north --site-id=hq --zone=lobby --position=north \
+    --output=/media/usb/lobby-north.wsp
+
+  # Provision a display with additional metadata
+  wsignctl display provision cafe-menu-1 --site-id=hq --zone=cafeteria --position=menu-1 \
+    --label=orientation=portrait`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			properties, err := util.ParseLabels("label", labels)
+			if err != nil {
+				return err
+			}
+			if output == "" {
+				output = name + ".wsp"
+			}
+			// Checked before the display is registered, so that a mistyped
+			// path does not leave a display behind without its file
+			if !force {
+				if _, err := os.Stat(output); err == nil {
+					return fmt.Errorf("%s already exists; use --force to overwrite it", output)
+				} else if !errors.Is(err, fs.ErrNotExist) {
+					return err
+				}
+			}
+
+			client, err := util.GetClientFromCommand(cmd)
+			if err != nil {
+				return err
+			}
+
+			file, err := client.ProvisionDisplay(cmd.Context(), &v1alpha1.DisplayProvisionRequest{
+				Name: name,
+				Location: v1alpha1.DisplayLocation{
+					SiteID:   siteID,
+					Zone:     zone,
+					Position: position,
+				},
+				Properties: properties,
+			})
+			if err != nil {
+				return fmt.Errorf("error provisioning display: %w", err)
+			}
+			bundle, err := file.DecodeBundle()
+			if err != nil {
+				return err
+			}
+
+			if err := writeProvisioningFile(output, file, force); err != nil {
+				return fmt.Errorf("display %q was provisioned but its file could not be written: %w", name, err)
+			}
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "Display %q provisioned and written to %s\n\n", bundle.Name, output)
+			fmt.Fprintf(out, "Details:\n")
+			fmt.Fprintf(out, "  ID:       %s\n", bundle.DisplayID)
+			fmt.Fprintf(out, "  Location: %s/%s/%s\n",
+				bundle.Location.SiteID,
+				bundle.Location.Zone,
+				bundle.Location.Position)
+			fmt.Fprintf(out, "  Server:   %s\n", bundle.ServerURL)
+			fmt.Fprintf(out, "  Signed:   %s key %s\n", file.Signature.Algorithm, file.Signature.KeyID)
+			fmt.Fprintf(out, "  Expires:  %s\n", bundle.ExpiresAt.Local().Format(time.RFC3339))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&siteID, "site-id", "", "Site identifier (required)")
+	cmd.Flags().StringVar(&zone, "zone", "", "Zone within site (required)")
+	cmd.Flags().StringVar(&position, "position", "", "Position within zone (required)")
+	cmd.Flags().StringArrayVar(&labels, "label", nil, "Additional labels in key=value format")
+	cmd.Flags().StringVar(&output, "output", "", "File to write the provisioning file to (default NAME.wsp)")
+	cmd.Flags().BoolVar(&force, "force", false, "Overwrite an existing file")
+
+	for _, flag := range []string{"site-id", "zone", "position"} {
+		if err := cmd.MarkFlagRequired(flag); err != nil {
+			panic(fmt.Sprintf("failed to mark %q flag as required: %v", flag, err))
+		}
+	}
+
+	return cmd
+}
+
+// writeProvisioningFile writes file to path, readable only by its owner
+// since it holds the enrollment token
+func writeProvisioningFile(path string, file *v1alpha1.ProvisioningFile, force bool) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
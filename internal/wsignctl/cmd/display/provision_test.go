@@ -0,0 +1,198 @@
+package display
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
+	authmemory "github.com/wrale/wrale-signage/internal/wsignd/auth/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/activation"
+	displayhttp "github.com/wrale/wrale-signage/internal/wsignd/display/http"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+func TestProvisionCommand(t *testing.T) {
+	t.Setenv("WSIGN_INSECURE_SKIP_TLS", "false")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	service := display.NewService(memory.NewRepository(), noopPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{},
+		display.Capacity{}, logger)
+	activations := activation.NewService(memory.NewActivationRepository(), time.Minute, activation.SitePolicy{})
+	key, err := auth.NewEd25519Key("k1", bytes.Repeat([]byte{7}, 32))
+	require.NoError(t, err)
+	keys, err := auth.NewKeyRing(key)
+	require.NoError(t, err)
+	tokens := auth.NewService(keys, authmemory.NewRepository(), time.Hour)
+	router := displayhttp.NewRouter(displayhttp.NewHandler(service, activations, tokens, logger), ratelimit.NewMemoryService(nil), nil)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	ctx := context.Background()
+
+	run := func(args ...string) (string, error) {
+		cmd := newProvisionCommand()
+		cmd.Flags().String("server", server.URL, "")
+		cmd.Flags().String("token", "test-token", "")
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&out)
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return out.String(), err
+	}
+	enroll := func(token string) (int, []byte) {
+		body, err := json.Marshal(&v1alpha1.EnrollmentRequest{EnrollmentToken: token})
+		require.NoError(t, err)
+		resp, err := http.Post(server.URL+"/api/v1alpha1/displays/enroll", "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, data
+	}
+
+	path := filepath.Join(t.TempDir(), "lobby-north.wsp")
+	out, err := run("lobby-north", "--site-id=hq", "--zone=lobby", "--position=north",
+		"--label=orientation=portrait", "--output="+path)
+	require.NoError(t, err)
+	assert.Contains(t, out, `Display "lobby-north" provisioned and written to `+path)
+	assert.Contains(t, out, "EdDSA key k1")
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "the file holds a secret")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var file v1alpha1.ProvisioningFile
+	require.NoError(t, json.Unmarshal(data, &file))
+	assert.Equal(t, displayhttp.ProvisioningFileKind, file.Kind)
+
+	registered, err := service.GetByName(ctx, "lobby-north")
+	require.NoError(t, err)
+	assert.Equal(t, display.StateActive, registered.State)
+
+	t.Run("the signature covers the bundle", func(t *testing.T) {
+		sig := auth.Signature{
+			KeyID:     file.Signature.KeyID,
+			Algorithm: auth.Algorithm(file.Signature.Algorithm),
+			Value:     file.Signature.Value,
+		}
+		require.NoError(t, keys.VerifyDocument(file.Bundle, sig))
+
+		bundle, err := file.DecodeBundle()
+		require.NoError(t, err)
+		assert.Equal(t, registered.ID, bundle.DisplayID)
+		assert.Equal(t, v1alpha1.DisplayLocation{SiteID: "hq", Zone: "lobby", Position: "north"}, bundle.Location)
+		assert.Equal(t, map[string]string{"orientation": "portrait"}, bundle.Properties)
+		assert.Equal(t, server.URL, bundle.ServerURL)
+		assert.NotEmpty(t, bundle.EnrollmentToken)
+
+		// Pointing the display at another server invalidates the file
+		bundle.ServerURL = "https://attacker.example.com"
+		forged, err := json.Marshal(bundle)
+		require.NoError(t, err)
+		assert.ErrorIs(t, keys.VerifyDocument(forged, sig), auth.ErrInvalidSignature)
+	})
+
+	t.Run("the token is exchanged once", func(t *testing.T) {
+		bundle, err := file.DecodeBundle()
+		require.NoError(t, err)
+
+		const attempts = 10
+		var (
+			wg        sync.WaitGroup
+			mu        sync.Mutex
+			responses []v1alpha1.DeviceTokenResponse
+			refusals  []v1alpha1.OAuthError
+		)
+		for i := 0; i < attempts; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				status, body := enroll(bundle.EnrollmentToken)
+				mu.Lock()
+				defer mu.Unlock()
+				if status == http.StatusOK {
+					var resp v1alpha1.DeviceTokenResponse
+					assert.NoError(t, json.Unmarshal(body, &resp))
+					responses = append(responses, resp)
+					return
+				}
+				assert.Equal(t, http.StatusBadRequest, status, string(body))
+				var refusal v1alpha1.OAuthError
+				assert.NoError(t, json.Unmarshal(body, &refusal))
+				refusals = append(refusals, refusal)
+			}()
+		}
+		wg.Wait()
+
+		require.Len(t, responses, 1)
+		assert.Equal(t, registered.ID, responses[0].Display.ID)
+		token, err := tokens.ValidateToken(ctx, responses[0].AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, registered.ID, token.DisplayID)
+
+		require.Len(t, refusals, attempts-1)
+		for _, refusal := range refusals {
+			assert.Equal(t, "invalid_grant", refusal.Error)
+		}
+	})
+
+	t.Run("unknown tokens are refused", func(t *testing.T) {
+		status, body := enroll("not-a-token")
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Contains(t, string(body), "invalid_grant")
+	})
+
+	t.Run("existing files are kept", func(t *testing.T) {
+		_, err := run("lobby-south", "--site-id=hq", "--zone=lobby", "--position=south", "--output="+path)
+		assert.ErrorContains(t, err, "already exists")
+		_, err = service.GetByName(ctx, "lobby-south")
+		assert.Error(t, err, "nothing is registered")
+
+		_, err = run("lobby-south", "--site-id=hq", "--zone=lobby", "--position=south", "--output="+path, "--force")
+		require.NoError(t, err)
+		_, err = service.GetByName(ctx, "lobby-south")
+		assert.NoError(t, err)
+	})
+
+	t.Run("HS256 servers refuse", func(t *testing.T) {
+		key, err := auth.NewHMACKey("k1", []byte("shared-secret"))
+		require.NoError(t, err)
+		keys, err := auth.NewKeyRing(key)
+		require.NoError(t, err)
+		tokens := auth.NewService(keys, authmemory.NewRepository(), time.Hour)
+		hmacServer := httptest.NewServer(displayhttp.NewRouter(
+			displayhttp.NewHandler(service, activations, tokens, logger), ratelimit.NewMemoryService(nil), nil))
+		t.Cleanup(hmacServer.Close)
+
+		cmd := newProvisionCommand()
+		cmd.Flags().String("server", hmacServer.URL, "")
+		cmd.Flags().String("token", "test-token", "")
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+		output := filepath.Join(t.TempDir(), "lobby-east.wsp")
+		cmd.SetArgs([]string{"lobby-east", "--site-id=hq", "--zone=lobby", "--position=east", "--output=" + output})
+		assert.ErrorContains(t, cmd.Execute(), "EdDSA primary signing key")
+
+		_, err = service.GetByName(ctx, "lobby-east")
+		assert.Error(t, err, "nothing is registered")
+		_, err = os.Stat(output)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
@@ -0,0 +1,143 @@
+package display
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+func newPruneCommand() *cobra.Command {
+	var (
+		offlineFor string
+		site       string
+		dryRun     bool
+		yes        bool
+		output     string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete displays that have been offline for a long time",
+		Long: `Delete every display that has been offline for longer than --offline-for,
+for example after a site has been decommissioned. Only displays in the
+OFFLINE state are deleted; active, disabled and pending displays are kept
+however long ago they were last seen. A display that checks in while the
+prune runs is kept as well.
+
+The displays to be deleted are listed and confirmation is asked for before
+anything is deleted. Use --dry-run to only list them, or --yes to skip the
+question in scripts. Each deletion is recorded in the server's audit log.
+
+Deleted displays lose their tokens and history and must be activated again
+to show content. The command requires an admin token.`,
+		Example: `  # See which displays at hq have been offline for 30 days
+  wsignctl display prune --offline-for 30d --site hq --dry-run
+
+  # Delete them
+  wsignctl display prune --offline-for 30d --site hq
+
+  # Delete displays offline for 90 days anywhere, without asking
+  wsignctl display prune --offline-for 90d --yes`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if offlineFor == "" {
+				return fmt.Errorf("--offline-for is required")
+			}
+			if _, err := v1alpha1.ParsePeriod(offlineFor); err != nil {
+				return fmt.Errorf("invalid --offline-for: %w", err)
+			}
+
+			client, err := getClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			req := &v1alpha1.DisplayPruneRequest{OfflineFor: offlineFor, SiteID: site, DryRun: true}
+			preview, err := client.PruneDisplays(cmd.Context(), req)
+			if err != nil {
+				return fmt.Errorf("error finding offline displays: %w", err)
+			}
+			if dryRun || preview.Matched == 0 {
+				return printPruneResult(cmd, preview, output)
+			}
+
+			if !yes {
+				if err := printPruneResult(cmd, preview, "table"); err != nil {
+					return err
+				}
+				ok, err := confirm(cmd.InOrStdin(), cmd.OutOrStdout(),
+					fmt.Sprintf("Delete %d displays?", preview.Matched))
+				if err != nil {
+					return err
+				}
+				if !ok {
+					fmt.Fprintln(cmd.OutOrStdout(), "Nothing deleted")
+					return nil
+				}
+			}
+
+			req.DryRun = false
+			result, err := client.PruneDisplays(cmd.Context(), req)
+			if err != nil {
+				return fmt.Errorf("error pruning displays: %w", err)
+			}
+			return printPruneResult(cmd, result, output)
+		},
+	}
+
+	cmd.Flags().StringVar(&offlineFor, "offline-for", "", "How long displays must have been offline, e.g. 30d or 720h")
+	cmd.Flags().StringVar(&site, "site", "", "Only prune displays at this site")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the displays that would be deleted without deleting them")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Delete without asking for confirmation")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format (table, json)")
+
+	return cmd
+}
+
+// confirm asks question and reports whether the answer was yes. Anything
+// else, including no answer at all, is a no.
+func confirm(in io.Reader, out io.Writer, question string) (bool, error) {
+	fmt.Fprintf(out, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("error reading confirmation: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+func printPruneResult(cmd *cobra.Command, result *v1alpha1.DisplayPruneResult, output string) error {
+	if output == "json" {
+		return util.PrintJSON(cmd.OutOrStdout(), result)
+	}
+
+	out := cmd.OutOrStdout()
+	if len(result.Items) > 0 {
+		tw := util.NewTabWriter(out)
+		fmt.Fprintf(tw, "NAME\tSITE\tLAST SEEN\n")
+		for _, d := range result.Items {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", d.Name, d.SiteID, d.LastSeen.Format(time.RFC3339))
+		}
+		tw.Flush()
+	}
+
+	switch {
+	case result.DryRun:
+		fmt.Fprintf(out, "%d displays would be deleted\n", result.Matched)
+	case result.Skipped > 0:
+		fmt.Fprintf(out, "%d displays deleted, %d kept because they came back or changed state\n", result.Deleted, result.Skipped)
+	default:
+		fmt.Fprintf(out, "%d displays deleted\n", result.Deleted)
+	}
+	return nil
+}
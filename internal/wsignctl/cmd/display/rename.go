@@ -0,0 +1,53 @@
+package display
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/internal/wsignctl/client"
+)
+
+func newRenameCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rename OLD NEW",
+		Short: "Rename a display",
+		Long: `Change a display's name without deleting and recreating it.
+
+The display keeps its ID, so its tokens, open connection, content history and
+events are unaffected; only lookups by name change. OLD may be the display's
+name or ID.
+
+Names may contain letters, digits, '.', '_' and '-', and must begin and end
+with a letter or digit. The command fails if another display already has the
+new name, ignoring case.`,
+		Example: `  # Rename a display
+  wsignctl display rename lobby-north lobby-east`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			oldName, newName := args[0], args[1]
+
+			c, err := getClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			renamed, err := c.RenameDisplay(cmd.Context(), oldName, newName)
+			var taken *client.DisplayNameTakenError
+			if errors.As(err, &taken) {
+				return fmt.Errorf("display %q already exists (ID %s, site %s, state %s)",
+					taken.Existing.Name, taken.Existing.ID,
+					taken.Existing.Spec.Location.SiteID, taken.Existing.Status.State)
+			}
+			if err != nil {
+				return fmt.Errorf("error renaming display: %w", err)
+			}
+
+			fmt.Printf("Display %q renamed to %q (ID %s)\n", oldName, renamed.Name, renamed.ID)
+			return nil
+		},
+	}
+
+	return cmd
+}
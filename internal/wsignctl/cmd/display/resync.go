@@ -0,0 +1,45 @@
+package display
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func newResyncCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "resync NAME",
+		Short: "Resend a display its assigned content",
+		Long: `Push the content currently assigned to a display over its control
+connection, without waiting for it to reconnect or poll.
+
+This is useful when a display comes back blank after a restart. Resyncing a
+display that already shows its content sends the same content again, so the
+command is safe to repeat.
+
+The command fails if the display is not currently connected or no content is
+assigned to it. It requires a token with the admin scope.`,
+		Example: `  # Resend a display its content
+  wsignctl display resync lobby-north`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			client, err := getClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			resync, err := client.ResyncDisplay(cmd.Context(), name)
+			if err != nil {
+				return fmt.Errorf("error resyncing display: %w", err)
+			}
+
+			fmt.Printf("Display %q resynced: %s (assignment %s)\n",
+				name, resync.Content.URL, resync.Content.Assignment)
+			return nil
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,84 @@
+package display
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+func newSessionsCommand() *cobra.Command {
+	var (
+		output    string
+		terminate string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "sessions NAME",
+		Short: "List or end a display's sessions",
+		Long: `List the sessions a display holds. A session is the access token issued
+when the display was activated together with any tokens refreshed from it.
+
+Use --terminate with a session ID to end one session. Its tokens stop working
+at once and any control connection that authenticated with it is closed,
+while the display's other sessions are left alone.`,
+		Example: `  # Show a display's sessions
+  wsignctl display sessions lobby-north
+
+  # End one session
+  wsignctl display sessions lobby-north --terminate 5f0c6c1e-2b4a-4d6e-9c1f-0a7d3e8b9c21`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			client, err := getClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			if terminate != "" {
+				if err := client.TerminateDisplaySession(cmd.Context(), name, terminate); err != nil {
+					return fmt.Errorf("error terminating session: %w", err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Session %s of display %q terminated\n", terminate, name)
+				return nil
+			}
+
+			sessions, err := client.ListDisplaySessions(cmd.Context(), name)
+			if err != nil {
+				return fmt.Errorf("error listing sessions: %w", err)
+			}
+
+			if output == "json" {
+				return util.PrintJSON(cmd.OutOrStdout(), sessions)
+			}
+
+			if len(sessions.Items) == 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "Display %q has no active sessions\n", name)
+				return nil
+			}
+
+			tw := util.NewTabWriter(cmd.OutOrStdout())
+			defer tw.Flush()
+			fmt.Fprintf(tw, "SESSION\tISSUED\tLAST REFRESH\tEXPIRES\tKEY\tCONNECTIONS\n")
+			for _, s := range sessions.Items {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\n",
+					s.ID,
+					util.FormatDuration(time.Since(s.IssuedAt)),
+					util.FormatDuration(time.Since(s.LastRefreshedAt)),
+					s.ExpiresAt.Local().Format("2006-01-02 15:04"),
+					s.KeyID,
+					s.Connections,
+				)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&terminate, "terminate", "", "ID of a session to end")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format (table, json)")
+
+	return cmd
+}
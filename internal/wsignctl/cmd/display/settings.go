@@ -0,0 +1,147 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+func newSettingsCommand() *cobra.Command {
+	var (
+		orientation string
+		brightness  int
+		volume      int
+		screenOn    []string
+		reset       bool
+		output      string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "settings NAME",
+		Short: "Show or change a display's device settings",
+		Long: `Show or change the orientation, brightness, volume and screen schedule a
+display applies to itself.
+
+Settings not named on the command line keep their current value, and
+--reset starts from no settings at all. The server checks every value, then
+stores the settings and sends them to the display if it is connected. A
+display that is offline, or that restarts, is sent its settings when it
+next connects. Without any flags the current settings are shown.
+
+Each --screen-on window is START-END in the display's local time; a window
+that ends before it starts runs past midnight. Without windows the screen
+stays on.`,
+		Example: `  # Rotate a display and dim it
+  wsignctl display settings lobby-north --orientation portrait --brightness 80
+
+  # Turn the screen on for opening hours only
+  wsignctl display settings cafe-menu-1 --screen-on 07:00-15:00 --screen-on 17:00-21:00
+
+  # Hand every setting back to the device
+  wsignctl display settings cafe-menu-1 --reset`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			flags := cmd.Flags()
+
+			var schedule []v1alpha1.ScreenWindow
+			for _, window := range screenOn {
+				start, end, ok := strings.Cut(window, "-")
+				if !ok {
+					return fmt.Errorf("invalid screen window %q - use START-END, e.g. 07:00-19:00", window)
+				}
+				schedule = append(schedule, v1alpha1.ScreenWindow{Start: start, End: end})
+			}
+
+			client, err := getClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			d, err := client.GetDisplay(cmd.Context(), name)
+			if err != nil {
+				return fmt.Errorf("error getting display: %w", err)
+			}
+
+			changed := reset
+			for _, flag := range []string{"orientation", "brightness", "volume", "screen-on"} {
+				changed = changed || flags.Changed(flag)
+			}
+			if changed {
+				settings := &v1alpha1.DisplaySettings{}
+				if d.Spec.Settings != nil && !reset {
+					settings = d.Spec.Settings
+				}
+				if flags.Changed("orientation") {
+					settings.Orientation = orientation
+				}
+				if flags.Changed("brightness") {
+					settings.Brightness = &brightness
+				}
+				if flags.Changed("volume") {
+					settings.Volume = &volume
+				}
+				if flags.Changed("screen-on") {
+					settings.ScreenSchedule = schedule
+				}
+
+				if d, err = client.UpdateDisplaySettings(cmd.Context(), name, settings); err != nil {
+					return fmt.Errorf("error updating display settings: %w", err)
+				}
+			}
+
+			if output == "json" {
+				return util.PrintJSON(cmd.OutOrStdout(), d.Spec.Settings)
+			}
+
+			out := cmd.OutOrStdout()
+			if changed {
+				fmt.Fprintf(out, "Display %q settings updated\n", d.Name)
+			}
+			fmt.Fprintf(out, "Settings:    %s\n", formatSettings(d.Spec.Settings))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&orientation, "orientation", "", "Orientation: landscape, portrait, landscape-flipped or portrait-flipped")
+	cmd.Flags().IntVar(&brightness, "brightness", 0, "Backlight level, 0 to 100")
+	cmd.Flags().IntVar(&volume, "volume", 0, "Audio level, 0 to 100")
+	cmd.Flags().StringArrayVar(&screenOn, "screen-on", nil, "Time window the screen is on, as START-END (repeatable)")
+	cmd.Flags().BoolVar(&reset, "reset", false, "Clear settings not given on the command line")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format (table, json)")
+
+	return cmd
+}
+
+// formatSettings describes device settings on one line
+func formatSettings(s *v1alpha1.DisplaySettings) string {
+	if s == nil {
+		return "device defaults"
+	}
+
+	var parts []string
+	if s.Orientation != "" {
+		parts = append(parts, "orientation="+s.Orientation)
+	}
+	if s.Brightness != nil {
+		parts = append(parts, fmt.Sprintf("brightness=%d", *s.Brightness))
+	}
+	if s.Volume != nil {
+		parts = append(parts, fmt.Sprintf("volume=%d", *s.Volume))
+	}
+	if len(s.ScreenSchedule) > 0 {
+		windows := make([]string, len(s.ScreenSchedule))
+		for i, w := range s.ScreenSchedule {
+			windows[i] = w.Start + "-" + w.End
+		}
+		parts = append(parts, "screen-on="+strings.Join(windows, ","))
+	}
+	if len(parts) == 0 {
+		return "device defaults"
+	}
+	return strings.Join(parts, " ")
+}
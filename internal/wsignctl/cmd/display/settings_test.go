@@ -0,0 +1,79 @@
+package display
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	displayhttp "github.com/wrale/wrale-signage/internal/wsignd/display/http"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+func TestSettingsCommand(t *testing.T) {
+	t.Setenv("WSIGN_INSECURE_SKIP_TLS", "false")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	service := display.NewService(memory.NewRepository(), noopPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, logger)
+	router := displayhttp.NewRouter(displayhttp.NewHandler(service, nil, nil, logger), ratelimit.NewMemoryService(nil), nil)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	ctx := context.Background()
+
+	_, err := service.Register(ctx, "lobby-north", display.Location{SiteID: "hq", Zone: "lobby", Position: "north"}, nil)
+	require.NoError(t, err)
+
+	run := func(args ...string) (string, error) {
+		cmd := newSettingsCommand()
+		cmd.Flags().String("server", server.URL, "")
+		cmd.Flags().String("token", "test-token", "")
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&out)
+		cmd.SetArgs(append([]string{"lobby-north"}, args...))
+		err := cmd.Execute()
+		return out.String(), err
+	}
+	stored := func() display.Settings {
+		d, err := service.GetByName(ctx, "lobby-north")
+		require.NoError(t, err)
+		return d.Settings
+	}
+
+	out, err := run()
+	require.NoError(t, err)
+	assert.Contains(t, out, "Settings:    device defaults")
+
+	out, err = run("--orientation", "portrait", "--brightness", "80")
+	require.NoError(t, err)
+	assert.Contains(t, out, "orientation=portrait brightness=80")
+
+	// Settings not named keep their value
+	_, err = run("--volume", "0", "--screen-on", "22:00-06:00")
+	require.NoError(t, err)
+	settings := stored()
+	assert.Equal(t, display.OrientationPortrait, settings.Orientation)
+	require.NotNil(t, settings.Volume)
+	assert.Equal(t, 0, *settings.Volume)
+	assert.Equal(t, []display.ScreenWindow{{Start: "22:00", End: "06:00"}}, settings.ScreenSchedule)
+
+	_, err = run("--brightness", "150")
+	require.ErrorContains(t, err, "brightness 150 must be between 0 and 100")
+	assert.Equal(t, 80, *stored().Brightness)
+
+	_, err = run("--screen-on", "22:00")
+	require.ErrorContains(t, err, "use START-END")
+
+	_, err = run("--reset", "--brightness", "50")
+	require.NoError(t, err)
+	settings = stored()
+	assert.Empty(t, settings.Orientation)
+	assert.Nil(t, settings.Volume)
+	assert.Equal(t, 50, *settings.Brightness)
+}
@@ -0,0 +1,199 @@
+package display
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+// statsStates is the column order of the stats tables
+var statsStates = []v1alpha1.DisplayState{
+	v1alpha1.DisplayStateActive,
+	v1alpha1.DisplayStateOffline,
+	v1alpha1.DisplayStatePendingApproval,
+	v1alpha1.DisplayStateUnregistered,
+	v1alpha1.DisplayStateDisabled,
+}
+
+// sparkBars draw the trend lines, lowest first
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+func newStatsCommand() *cobra.Command {
+	var (
+		siteID  string
+		history bool
+		since   string
+		output  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Count displays by state",
+		Long: `Count displays by state for each site.
+
+Use --history to show how the counts changed over time instead. The server
+records the counts of every site periodically and keeps them for a limited
+time, so the history only reaches back as far as that retention.`,
+		Example: `  # Count displays by state at every site
+  wsignctl display stats
+
+  # Show how the displays at a site fared over the last week
+  wsignctl display stats --site-id=hq --history --since=7d`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := getClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			if history {
+				h, err := client.GetDisplayStateHistory(cmd.Context(), siteID, since)
+				if err != nil {
+					return fmt.Errorf("error getting state history: %w", err)
+				}
+				if output == "json" {
+					return util.PrintJSON(cmd.OutOrStdout(), h)
+				}
+				printStateHistory(cmd.OutOrStdout(), h)
+				return nil
+			}
+
+			current, err := client.GetDisplayStateCounts(cmd.Context(), siteID)
+			if err != nil {
+				return fmt.Errorf("error counting displays: %w", err)
+			}
+			counts := make(map[string]map[v1alpha1.DisplayState]int, len(current.Items))
+			for _, item := range current.Items {
+				counts[item.SiteID] = item.Counts
+			}
+			if output == "json" {
+				return util.PrintJSON(cmd.OutOrStdout(), counts)
+			}
+			printStateCounts(cmd.OutOrStdout(), counts)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&siteID, "site-id", "", "Only count displays at this site")
+	cmd.Flags().BoolVar(&history, "history", false, "Show recorded counts over time")
+	cmd.Flags().StringVar(&since, "since", "7d", "How far back the history goes, as a duration such as 7d or 12h")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format (table, json)")
+
+	return cmd
+}
+
+// printStateCounts writes one row of counts per site
+func printStateCounts(w io.Writer, counts map[string]map[v1alpha1.DisplayState]int) {
+	sites := make([]string, 0, len(counts))
+	for site := range counts {
+		sites = append(sites, site)
+	}
+	sort.Strings(sites)
+
+	tw := util.NewTabWriter(w)
+	defer tw.Flush()
+	fmt.Fprintf(tw, "SITE\tTOTAL\t%s\n", stateHeader())
+	for _, site := range sites {
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", site, total(counts[site]), stateCells(counts[site]))
+	}
+}
+
+// printStateHistory writes the counts at each snapshot, summed over sites
+// when the history covers more than one, followed by a trend line per state
+func printStateHistory(w io.Writer, h *v1alpha1.DisplayStateHistory) {
+	if len(h.Items) == 0 {
+		fmt.Fprintln(w, "No state snapshots recorded in this period")
+		return
+	}
+
+	// Snapshots of different sites taken together share a timestamp
+	var points []map[v1alpha1.DisplayState]int
+	var times []string
+	for _, s := range h.Items {
+		at := s.Timestamp.Local().Format("2006-01-02 15:04")
+		if len(times) == 0 || times[len(times)-1] != at {
+			times = append(times, at)
+			points = append(points, make(map[v1alpha1.DisplayState]int))
+		}
+		for state, n := range s.Counts {
+			points[len(points)-1][state] += n
+		}
+	}
+
+	tw := util.NewTabWriter(w)
+	fmt.Fprintf(tw, "TIME\tTOTAL\t%s\n", stateHeader())
+	for i, p := range points {
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", times[i], total(p), stateCells(p))
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	tw = util.NewTabWriter(w)
+	defer tw.Flush()
+	for _, state := range statsStates {
+		series := make([]int, len(points))
+		for i, p := range points {
+			series[i] = p[state]
+		}
+		low, high := bounds(series)
+		if high == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d-%d\n", state, sparkline(series, low, high), low, high)
+	}
+}
+
+// sparkline draws values between low and high as a row of bars
+func sparkline(values []int, low, high int) string {
+	var b strings.Builder
+	for _, v := range values {
+		i := len(sparkBars) - 1
+		if high > low {
+			i = (v - low) * (len(sparkBars) - 1) / (high - low)
+		}
+		b.WriteRune(sparkBars[i])
+	}
+	return b.String()
+}
+
+// bounds returns the smallest and largest of values
+func bounds(values []int) (low, high int) {
+	for i, v := range values {
+		if i == 0 || v < low {
+			low = v
+		}
+		if v > high {
+			high = v
+		}
+	}
+	return low, high
+}
+
+func stateHeader() string {
+	names := make([]string, len(statsStates))
+	for i, s := range statsStates {
+		names[i] = strings.ReplaceAll(string(s), "_", " ")
+	}
+	return strings.Join(names, "\t")
+}
+
+func stateCells(counts map[v1alpha1.DisplayState]int) string {
+	cells := make([]string, len(statsStates))
+	for i, s := range statsStates {
+		cells[i] = fmt.Sprint(counts[s])
+	}
+	return strings.Join(cells, "\t")
+}
+
+func total(counts map[v1alpha1.DisplayState]int) int {
+	n := 0
+	for _, c := range counts {
+		n += c
+	}
+	return n
+}
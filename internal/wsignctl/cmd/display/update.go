@@ -1,12 +1,13 @@
 package display
 
 import (
+	"context"
 	"fmt"
-	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
 )
 
 func newUpdateCommand() *cobra.Command {
@@ -16,46 +17,53 @@ func newUpdateCommand() *cobra.Command {
 		position     string
 		addLabels    []string
 		removeLabels []string
+		bulk         util.BulkOptions
 	)
 
 	cmd := &cobra.Command{
-		Use:   "update NAME",
+		Use:   "update NAME [NAME...]",
 		Short: "Update display configuration",
-		Long: `Update a display's location or properties.
+		Long: `Update the location or properties of one or more displays.
 		
 Location changes are useful when physically moving displays. Labels can be
-added or removed to update display metadata.`,
+added or removed to update display metadata. The same change is applied to
+every display named.
+
+Several displays are updated --concurrency at a time. A failure does not
+stop the others unless --fail-fast is set, and Ctrl-C stops starting new
+updates once those in flight have finished. The displays that failed or
+were never updated are listed at the end.`,
 		Example: `  # Update display location
   wsignctl display update lobby-north --site-id=hq --zone=lobby --position=south
   
   # Add and remove labels
   wsignctl display update cafe-menu-1 \
     --add-label=screen-size=55 \
-    --remove-label=temporary`,
-		Args: cobra.ExactArgs(1),
-		RunE: func(cmd *cobra.Command, args []string) error {
-			name := args[0]
+    --remove-label=temporary
 
-			// Parse labels to add into properties map
-			addProps := make(map[string]string)
-			for _, label := range addLabels {
-				parts := strings.SplitN(label, "=", 2)
-				if len(parts) != 2 {
-					return fmt.Errorf("invalid label format %q - use key=value", label)
-				}
-				addProps[parts[0]] = parts[1]
+  # Label several displays at once
+  wsignctl display update lobby-north lobby-south --add-label=campaign=spring`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := bulk.Validate(); err != nil {
+				return err
 			}
 
-			// Validate remove label format
-			var removeProps []string
-			for _, label := range removeLabels {
-				if strings.Contains(label, "=") {
-					return fmt.Errorf("use just the key name to remove labels, not %q", label)
+			addProps, err := util.ParseLabels("add-label", addLabels)
+			if err != nil {
+				return err
+			}
+			removeProps, err := util.ParseLabelKeys("remove-label", removeLabels)
+			if err != nil {
+				return err
+			}
+			for _, key := range removeProps {
+				if _, ok := addProps[key]; ok {
+					return fmt.Errorf("label %q is both added and removed", key)
 				}
-				removeProps = append(removeProps, label)
 			}
 
-			client, err := getClient()
+			client, err := getClient(cmd)
 			if err != nil {
 				return err
 			}
@@ -70,12 +78,25 @@ added or removed to update display metadata.`,
 				}
 			}
 
-			if err := client.UpdateDisplay(cmd.Context(), name, location, addProps, removeProps); err != nil {
-				return fmt.Errorf("error updating display: %w", err)
+			if len(args) == 1 {
+				if err := client.UpdateDisplay(cmd.Context(), args[0], location, addProps, removeProps); err != nil {
+					return fmt.Errorf("error updating display %q: %w", args[0], err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Display %q updated successfully\n", args[0])
+				return nil
 			}
 
-			fmt.Printf("Display %q updated successfully\n", name)
-			return nil
+			util.CheckFeature(cmd, client, v1alpha1.FeatureBulkUpdate,
+				fmt.Sprintf("updating %d displays one request each", len(args)))
+
+			ctx, stop := util.NotifyInterrupt(cmd.Context())
+			defer stop()
+			progress := util.NewProgress(cmd.ErrOrStderr(), "Updating displays", len(args))
+			summary := util.RunBulk(ctx, args, bulk, progress, func(ctx context.Context, i int) (string, error) {
+				return outcomeUpdated, client.UpdateDisplay(ctx, args[i], location, addProps, removeProps)
+			})
+			util.PrintBulkSummary(cmd.OutOrStdout(), summary, outcomeUpdated)
+			return summary.Err("display(s)")
 		},
 	}
 
@@ -85,6 +106,7 @@ added or removed to update display metadata.`,
 	cmd.Flags().StringVar(&position, "position", "", "New position")
 	cmd.Flags().StringArrayVar(&addLabels, "add-label", nil, "Add labels in key=value format")
 	cmd.Flags().StringArrayVar(&removeLabels, "remove-label", nil, "Remove labels by key")
+	util.AddBulkFlags(cmd, &bulk)
 
 	return cmd
 }
@@ -0,0 +1,72 @@
+// Package maintenance implements the maintenance mode commands
+package maintenance
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/client"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
+)
+
+// NewCommand creates the maintenance command and its subcommands
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "maintenance",
+		Short: "Show every display a maintenance notice",
+		Long: `The maintenance command puts the server in maintenance mode, during which
+every display is shown one notice instead of its assigned content. Connected
+displays are sent the notice straight away and their content again when
+maintenance ends. Maintenance is kept across restarts and applies on every
+replica.
+They require a token with the admin scope.`,
+	}
+
+	cmd.AddCommand(
+		newOnCommand(),
+		newOffCommand(),
+		newStatusCommand(),
+	)
+
+	return cmd
+}
+
+// getClient returns an API client honouring the global connection flags
+func getClient(cmd *cobra.Command) (*client.Client, error) {
+	return util.GetClientFromCommand(cmd)
+}
+
+// printStatus writes status as a table or as JSON
+func printStatus(cmd *cobra.Command, status *v1alpha1.MaintenanceStatus, output string) error {
+	if output == "json" {
+		return util.PrintJSON(cmd.OutOrStdout(), status)
+	}
+
+	out := cmd.OutOrStdout()
+	if !status.Enabled {
+		fmt.Fprintln(out, "Maintenance: off")
+	} else {
+		fmt.Fprintln(out, "Maintenance: on")
+		fmt.Fprintf(out, "Content URL: %s\n", status.ContentURL)
+		if status.Message != "" {
+			fmt.Fprintf(out, "Message:     %s\n", status.Message)
+		}
+		if status.StartedAt != nil {
+			fmt.Fprintf(out, "Started:     %s", status.StartedAt.Local().Format(time.RFC3339))
+			if status.StartedBy != "" {
+				fmt.Fprintf(out, " by %s", status.StartedBy)
+			}
+			fmt.Fprintln(out)
+		}
+		if status.Until != nil {
+			fmt.Fprintf(out, "Until:       %s\n", status.Until.Local().Format(time.RFC3339))
+		}
+	}
+	if status.DisplaysNotified > 0 {
+		fmt.Fprintf(out, "Displays notified: %d\n", status.DisplaysNotified)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package maintenance
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func newOffCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "off",
+		Short: "End maintenance mode",
+		Long: `End maintenance mode. Connected displays are sent their assigned content
+again; others read it when they next check in. Ending when the server is
+not in maintenance does nothing.`,
+		Example: `  # End maintenance
+  wsignctl maintenance off`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := getClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			status, err := client.EndMaintenance(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("error ending maintenance: %w", err)
+			}
+			return printStatus(cmd, status, output)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format (table, json)")
+
+	return cmd
+}
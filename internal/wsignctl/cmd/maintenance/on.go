@@ -0,0 +1,81 @@
+package maintenance
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+)
+
+func newOnCommand() *cobra.Command {
+	var (
+		contentURL string
+		message    string
+		until      string
+		duration   time.Duration
+		output     string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "on",
+		Short: "Start maintenance mode",
+		Long: `Show every display the notice at --url instead of its assigned content.
+Starting while already in maintenance replaces the notice.
+
+Maintenance lasts until "wsignctl maintenance off", or until the time given
+with --until or --for.`,
+		Example: `  # Show a "back shortly" page until turned off
+  wsignctl maintenance on --url https://cdn.example.com/back-shortly.html
+
+  # Show it for an hour with a message
+  wsignctl maintenance on --url https://cdn.example.com/back-shortly.html \
+    --message "Network upgrade" --for 1h
+
+  # Show it until a given time
+  wsignctl maintenance on --url https://cdn.example.com/back-shortly.html \
+    --until 2024-06-01T06:00:00Z`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := &v1alpha1.MaintenanceRequest{ContentURL: contentURL, Message: message}
+			switch {
+			case until != "" && duration != 0:
+				return fmt.Errorf("--until cannot be combined with --for")
+			case until != "":
+				t, err := time.Parse(time.RFC3339, until)
+				if err != nil {
+					return fmt.Errorf("invalid --until, expected RFC 3339 such as 2024-06-01T06:00:00Z: %w", err)
+				}
+				req.Until = &t
+			case duration < 0:
+				return fmt.Errorf("--for must be positive")
+			case duration > 0:
+				t := time.Now().Add(duration)
+				req.Until = &t
+			}
+
+			client, err := getClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			status, err := client.StartMaintenance(cmd.Context(), req)
+			if err != nil {
+				return fmt.Errorf("error starting maintenance: %w", err)
+			}
+			return printStatus(cmd, status, output)
+		},
+	}
+
+	cmd.Flags().StringVar(&contentURL, "url", "", "URL of the notice displays show (required)")
+	cmd.Flags().StringVar(&message, "message", "", "Message describing the maintenance")
+	cmd.Flags().StringVar(&until, "until", "", "End maintenance at this time (RFC 3339)")
+	cmd.Flags().DurationVar(&duration, "for", 0, "End maintenance after this long")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format (table, json)")
+	if err := cmd.MarkFlagRequired("url"); err != nil {
+		panic(fmt.Sprintf("failed to mark url flag as required: %v", err))
+	}
+
+	return cmd
+}
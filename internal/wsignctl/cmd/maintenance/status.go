@@ -0,0 +1,35 @@
+package maintenance
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func newStatusCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show whether the server is in maintenance mode",
+		Example: `  # Show maintenance mode
+  wsignctl maintenance status`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := getClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			status, err := client.GetMaintenance(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("error getting maintenance mode: %w", err)
+			}
+			return printStatus(cmd, status, output)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format (table, json)")
+
+	return cmd
+}
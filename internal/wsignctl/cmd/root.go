@@ -2,13 +2,18 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
 
+	"github.com/wrale/wrale-signage/internal/wsignctl/client"
+	"github.com/wrale/wrale-signage/internal/wsignctl/cmd/admin"
+	"github.com/wrale/wrale-signage/internal/wsignctl/cmd/assignment"
 	"github.com/wrale/wrale-signage/internal/wsignctl/cmd/content"
 	"github.com/wrale/wrale-signage/internal/wsignctl/cmd/display"
+	"github.com/wrale/wrale-signage/internal/wsignctl/cmd/maintenance"
 	"github.com/wrale/wrale-signage/internal/wsignctl/cmd/rule"
 	"github.com/wrale/wrale-signage/internal/wsignctl/config"
 )
@@ -30,10 +35,21 @@ your digital signage deployment.`,
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	cmd, err := rootCmd.ExecuteC()
+	if err == nil {
+		return
+	}
+
+	// Commands asked for JSON hand API errors through as sent, so scripts
+	// can read the invalid fields without parsing the rendered message.
+	var apiErr *client.APIError
+	if output, _ := cmd.Flags().GetString("output"); output == "json" &&
+		errors.As(err, &apiErr) && len(apiErr.Body) > 0 {
+		fmt.Println(string(apiErr.Body))
+	} else {
 		fmt.Println(err)
-		os.Exit(1)
 	}
+	os.Exit(1)
 }
 
 // markFlagRequired is a helper that handles the error from MarkFlagRequired
@@ -48,15 +64,19 @@ func init() {
 
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.wsignctl.yaml)")
-	rootCmd.PersistentFlags().String("server", "", "API server address")
-	rootCmd.PersistentFlags().String("token", "", "Authentication token")
+	rootCmd.PersistentFlags().String("server", "", "API server address (overrides $WSIGN_SERVER and the current context)")
+	rootCmd.PersistentFlags().String("token", "", "Authentication token (overrides $WSIGN_TOKEN and the current context)")
+	rootCmd.PersistentFlags().Bool("insecure-skip-tls", false, "Skip TLS certificate verification")
 	rootCmd.PersistentFlags().String("context", "", "Configuration context to use")
 
 	// Add commands
 	rootCmd.AddCommand(
 		display.NewCommand(),
 		content.NewCommand(),
+		assignment.NewCommand(),
 		rule.NewCommand(),
+		admin.NewCommand(),
+		maintenance.NewCommand(),
 		newVersionCmd(),
 		newConfigCmd(),
 	)
@@ -78,18 +98,6 @@ func initConfig() {
 		}
 	}
 
-	// Get current context
-	context, err := cfg.GetCurrentContext()
-	if err != nil {
-		fmt.Printf("Warning: %v\n", err)
-		return
-	}
-
-	// Allow command line flags to override context settings
-	if server, _ := rootCmd.PersistentFlags().GetString("server"); server != "" {
-		context.Server = server
-	}
-	if token, _ := rootCmd.PersistentFlags().GetString("token"); token != "" {
-		context.Token = token
-	}
+	// --server, --token and --insecure-skip-tls are applied when each command
+	// builds its client so they never leak into saved contexts.
 }
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRunWithoutConfigFile runs commands given their server and token by
+// flag or environment on a machine where no context was ever saved
+func TestRunWithoutConfigFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[]`))
+	}))
+	t.Cleanup(server.Close)
+
+	path := filepath.Join(t.TempDir(), ".wsignctl", "config.yaml")
+	t.Setenv("WSIGNCTL_CONFIG", path)
+
+	run := func(t *testing.T, args ...string) {
+		t.Helper()
+		var out bytes.Buffer
+		rootCmd.SetOut(&out)
+		rootCmd.SetErr(&out)
+		rootCmd.SetArgs(args)
+		require.NoError(t, rootCmd.Execute(), out.String())
+		_, err := os.Stat(path)
+		assert.ErrorIs(t, err, os.ErrNotExist, "read-only commands do not write a config file")
+	}
+
+	t.Run("environment", func(t *testing.T) {
+		t.Setenv("WSIGN_SERVER", server.URL)
+		t.Setenv("WSIGN_TOKEN", "test-token")
+		run(t, "display", "list")
+	})
+
+	t.Run("flags", func(t *testing.T) {
+		run(t, "display", "list", "--server", server.URL, "--token", "test-token")
+	})
+}
@@ -21,7 +21,7 @@ func newAddCmd() *cobra.Command {
 
 Required fields:
 - NAME: A unique identifier for the rule (e.g., "lobby-welcome")
-- Content type, version, and hash specifying what to show
+- Content type or content tags, plus version and hash, specifying what to show
 
 Optional fields:
 - Priority number (defaults to 500, higher numbers evaluated first)
@@ -48,10 +48,10 @@ Rules are evaluated in priority order until a matching rule is found.`,
     --days=Mon,Tue,Wed,Thu,Fri \
     --time=11:00-14:00
 
-  # Emergency notification rule
+  # Emergency notification rule targeting every source tagged emergency
   wsignctl rule add emergency \
     --priority 1000 \
-    --content-type=alert \
+    --content-tag=emergency \
     --version=current \
     --hash=xyz789`,
 		Args: cobra.ExactArgs(1),
@@ -64,6 +64,10 @@ Rules are evaluated in priority order until a matching rule is found.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			name := args[0]
 
+			if opts.contentType == "" && len(opts.contentTags) == 0 {
+				return fmt.Errorf("one of --content-type or --content-tag is required")
+			}
+
 			// Parse schedule if any schedule flags were set
 			schedule, err := util.ParseSchedule(
 				opts.startTime,
@@ -88,6 +92,7 @@ Rules are evaluated in priority order until a matching rule is found.`,
 					ContentType: opts.contentType,
 					Version:     opts.version,
 					Hash:        opts.hash,
+					Tags:        opts.contentTags,
 				},
 				Schedule: schedule,
 			}
@@ -113,7 +118,8 @@ Rules are evaluated in priority order until a matching rule is found.`,
 	f.StringVar(&opts.siteID, "site-id", "", "Site ID selector")
 	f.StringVar(&opts.zone, "zone", "", "Zone selector")
 	f.StringVar(&opts.position, "position", "", "Position selector")
-	f.StringVar(&opts.contentType, "content-type", "", "Content type to redirect to (required)")
+	f.StringVar(&opts.contentType, "content-type", "", "Content type to redirect to (required unless --content-tag is set)")
+	f.StringArrayVar(&opts.contentTags, "content-tag", nil, "Redirect to content carrying this tag (repeatable)")
 	f.StringVar(&opts.version, "version", "", "Content version (required)")
 	f.StringVar(&opts.hash, "hash", "", "Content hash (required)")
 
@@ -124,7 +130,7 @@ Rules are evaluated in priority order until a matching rule is found.`,
 	f.StringVar(&opts.timeOfDay, "time", "", "Active time range (HH:MM-HH:MM)")
 
 	// Mark required flags and handle potential errors
-	for _, flagName := range []string{"content-type", "version", "hash"} {
+	for _, flagName := range []string{"version", "hash"} {
 		if err := cmd.MarkFlagRequired(flagName); err != nil {
 			// This would only happen if we specified a flag name that doesn't exist
 			panic(fmt.Sprintf("failed to mark required flag %q: %v", flagName, err))
@@ -2,6 +2,8 @@ package rule
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/wrale/wrale-signage/api/types/v1alpha1"
@@ -52,6 +54,7 @@ is the order they will be checked when a display requests content.`,
 			if err != nil {
 				return fmt.Errorf("error listing rules: %w", err)
 			}
+			sortRules(rules)
 
 			switch opts.output {
 			case "json":
@@ -69,9 +72,14 @@ is the order they will be checked when a display requests content.`,
 					// Format location selectors
 					selectors := util.FormatSelectors(r.DisplaySelector)
 
-					// Format content target
+					// Format content target, naming the tags when the
+					// rule targets content by tag
+					target := r.Content.ContentType
+					if len(r.Content.Tags) > 0 {
+						target = "tag:" + strings.Join(r.Content.Tags, ",")
+					}
 					content := fmt.Sprintf("%s/%s/%s",
-						r.Content.ContentType,
+						target,
 						r.Content.Version,
 						r.Content.Hash[:8]) // Show first 8 chars of hash
 
@@ -101,3 +109,15 @@ is the order they will be checked when a display requests content.`,
 
 	return cmd
 }
+
+// sortRules puts rules in evaluation order, highest priority first. The
+// server does not order them, so rules of equal priority are sorted by name
+// to print the same way on every run.
+func sortRules(rules []v1alpha1.RedirectRule) {
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Priority != rules[j].Priority {
+			return rules[i].Priority > rules[j].Priority
+		}
+		return rules[i].Name < rules[j].Name
+	})
+}
@@ -3,14 +3,15 @@ package rule
 // options holds common flags used across rule commands
 type options struct {
 	// Core options
-	priority    *int   // Rule evaluation priority
-	siteID      string // Site ID selector
-	zone        string // Zone selector
-	position    string // Position selector
-	contentType string // Content type to redirect to
-	version     string // Content version
-	hash        string // Content hash
-	output      string // Output format for list command
+	priority    *int     // Rule evaluation priority
+	siteID      string   // Site ID selector
+	zone        string   // Zone selector
+	position    string   // Position selector
+	contentType string   // Content type to redirect to
+	contentTags []string // Content tags to redirect to
+	version     string   // Content version
+	hash        string   // Content hash
+	output      string   // Output format for list command
 
 	// Schedule options
 	startTime  string   // Rule validity start time
@@ -36,6 +36,12 @@ name and remove the old one if you need to rename a rule.`,
     --version=spring-2024 \
     --hash=abc123
 
+  # Target emergency content by tag
+  wsignctl rule update fire-alarm \
+    --content-tag=emergency \
+    --version=current \
+    --hash=xyz789
+
   # Modify schedule
   wsignctl rule update daily-special \
     --days=Mon,Tue,Wed,Thu,Fri \
@@ -74,11 +80,13 @@ name and remove the old one if you need to rename a rule.`,
 					Position: opts.position,
 				}
 			}
-			if cmd.Flags().Changed("content-type") || cmd.Flags().Changed("version") || cmd.Flags().Changed("hash") {
+			if cmd.Flags().Changed("content-type") || cmd.Flags().Changed("content-tag") ||
+				cmd.Flags().Changed("version") || cmd.Flags().Changed("hash") {
 				update.Content = &v1alpha1.ContentRedirect{
 					ContentType: opts.contentType,
 					Version:     opts.version,
 					Hash:        opts.hash,
+					Tags:        opts.contentTags,
 				}
 			}
 			if schedule != nil {
@@ -107,6 +115,7 @@ name and remove the old one if you need to rename a rule.`,
 	f.StringVar(&opts.zone, "zone", "", "Zone selector")
 	f.StringVar(&opts.position, "position", "", "Position selector")
 	f.StringVar(&opts.contentType, "content-type", "", "Content type to redirect to")
+	f.StringArrayVar(&opts.contentTags, "content-tag", nil, "Redirect to content carrying this tag (repeatable)")
 	f.StringVar(&opts.version, "version", "", "Content version")
 	f.StringVar(&opts.hash, "hash", "", "Content hash")
 
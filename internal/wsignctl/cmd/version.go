@@ -2,35 +2,114 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/version"
+	"github.com/wrale/wrale-signage/internal/wsignctl/util"
 )
 
 var (
-	// Version is set during build
-	Version = "dev"
-	// Commit is set during build
-	Commit = "none"
-	// BuildDate is set during build
-	BuildDate = "unknown"
-
 	debugVersion bool
+	clientOnly   bool
 )
 
 func newVersionCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "version",
 		Short: "Print version information",
+		Long: `Print the wsignctl version and the version of the server in the current
+context. Use --client to skip contacting the server. A note is printed when
+the client and server come from release lines with breaking changes between
+them.`,
 		Run: func(cmd *cobra.Command, args []string) {
-			if debugVersion {
-				fmt.Printf("Version:\t%s\nCommit:\t\t%s\nBuild Date:\t%s\n",
-					Version, Commit, BuildDate)
-			} else {
-				fmt.Printf("wsignctl version %s\n", Version)
+			out := cmd.OutOrStdout()
+			printVersion(out, "Client", &v1alpha1.VersionInfo{
+				Version:   version.Version,
+				Commit:    version.Commit,
+				BuildDate: version.BuildDate,
+			})
+			if clientOnly {
+				return
+			}
+
+			c, err := util.GetClientFromCommand(cmd)
+			if err != nil {
+				fmt.Fprintf(out, "Server Version: unknown (%v)\n", err)
+				return
+			}
+			info, err := c.ServerVersion(cmd.Context())
+			if err != nil {
+				fmt.Fprintf(out, "Server Version: unknown (%v)\n", err)
+				return
+			}
+			printVersion(out, "Server", info)
+
+			if note := compatibilityNote(version.Version, info.Version); note != "" {
+				fmt.Fprintln(out, note)
 			}
 		},
 	}
 
 	cmd.Flags().BoolVar(&debugVersion, "debug", false, "Show detailed version information")
+	cmd.Flags().BoolVar(&clientOnly, "client", false, "Only show the client version")
 	return cmd
 }
+
+// printVersion writes the version of one side, with its build details when
+// --debug is set. Servers that predate the version endpoint report no
+// build details.
+func printVersion(out io.Writer, side string, info *v1alpha1.VersionInfo) {
+	if !debugVersion {
+		fmt.Fprintf(out, "%s Version: %s\n", side, info.Version)
+		return
+	}
+	commit, buildDate := info.Commit, info.BuildDate
+	if commit == "" {
+		commit = "unknown"
+	}
+	if buildDate == "" {
+		buildDate = "unknown"
+	}
+	fmt.Fprintf(out, "%s Version:\t%s\nCommit:\t\t%s\nBuild Date:\t%s\n", side, info.Version, commit, buildDate)
+}
+
+// compatibilityNote warns when the client and server are from release
+// lines that may break each other: a different major version, or before
+// 1.0 a different minor version. Development builds carry no version to
+// compare and get no note.
+func compatibilityNote(client, server string) string {
+	clientLine, ok := releaseLine(client)
+	if !ok {
+		return ""
+	}
+	serverLine, ok := releaseLine(server)
+	if !ok || clientLine == serverLine {
+		return ""
+	}
+	return fmt.Sprintf("Note: client version %s and server version %s are not compatible releases; some commands may not work as expected", client, server)
+}
+
+// releaseLine returns the part of a version such as v1.2.3 that changes
+// with breaking changes: the major version, or major and minor before 1.0
+func releaseLine(v string) (string, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(v, "v"), ".", 3)
+	major, err := strconv.Atoi(parts[0])
+	if err != nil || major < 0 {
+		return "", false
+	}
+	if major > 0 {
+		return parts[0], true
+	}
+	if len(parts) < 2 {
+		return "", false
+	}
+	if _, err := strconv.Atoi(parts[1]); err != nil {
+		return "", false
+	}
+	return parts[0] + "." + parts[1], true
+}
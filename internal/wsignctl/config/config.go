@@ -2,9 +2,12 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -27,6 +30,29 @@ type Context struct {
 	Token string `mapstructure:"token"`
 	// InsecureSkipVerify disables TLS verification
 	InsecureSkipVerify bool `mapstructure:"insecure-skip-verify"`
+	// Timezone is the zone times are shown in: an IANA name, or "local"
+	// for each display's own zone. Empty means UTC.
+	Timezone string `mapstructure:"timezone"`
+}
+
+// LocalTimezone asks for times in each display's own zone
+const LocalTimezone = "local"
+
+// ValidateTimezone checks that name is LocalTimezone or an IANA time zone
+// name the server will accept
+func ValidateTimezone(name string) error {
+	if name == LocalTimezone {
+		return nil
+	}
+	// LoadLocation also accepts "" and "Local", which mean UTC and the
+	// zone of whichever machine reads them
+	if name == "" || name == "Local" {
+		return fmt.Errorf("invalid time zone %q: use an IANA name such as Europe/London, or %q", name, LocalTimezone)
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return fmt.Errorf("invalid time zone %q: %w", name, err)
+	}
+	return nil
 }
 
 // defaultConfigPath returns the default config file path
@@ -38,12 +64,23 @@ func defaultConfigPath() string {
 	return filepath.Join(home, ".wsignctl/config.yaml")
 }
 
+// configPath returns the config file in use
+func configPath() string {
+	if path := os.Getenv("WSIGNCTL_CONFIG"); path != "" {
+		return path
+	}
+	return defaultConfigPath()
+}
+
+// CacheDir returns the directory for data cached per context, next to the
+// config file
+func CacheDir() string {
+	return filepath.Join(filepath.Dir(configPath()), "cache")
+}
+
 // LoadConfig loads the configuration from disk
 func LoadConfig() (*Config, error) {
-	configPath := os.Getenv("WSIGNCTL_CONFIG")
-	if configPath == "" {
-		configPath = defaultConfigPath()
-	}
+	configPath := configPath()
 
 	// Initialize viper with default values
 	viper.SetDefault("current-context", "")
@@ -53,21 +90,13 @@ func LoadConfig() (*Config, error) {
 	viper.SetConfigFile(configPath)
 	viper.SetConfigType("yaml")
 
-	// Try to read the config file
+	// A missing file means no contexts have been saved yet; commands given
+	// --server and --token or the environment run without one, and the
+	// file is only created when a context is saved. SetConfigFile reports a
+	// missing file as a path error rather than ConfigFileNotFoundError.
 	if err := viper.ReadInConfig(); err != nil {
-		// If the config file doesn't exist, create a default one
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			// Ensure directory exists with restricted permissions
-			configDir := filepath.Dir(configPath)
-			if err := os.MkdirAll(configDir, 0750); err != nil {
-				return nil, fmt.Errorf("error creating config directory: %w", err)
-			}
-
-			// Write default config
-			if err := viper.SafeWriteConfig(); err != nil {
-				return nil, fmt.Errorf("error writing default config: %w", err)
-			}
-		} else {
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) && !errors.Is(err, fs.ErrNotExist) {
 			return nil, fmt.Errorf("error reading config: %w", err)
 		}
 	}
@@ -87,6 +116,11 @@ func SaveConfig(config *Config) error {
 	viper.Set("current-context", config.CurrentContext)
 	viper.Set("contexts", config.Contexts)
 
+	// Ensure directory exists with restricted permissions
+	if err := os.MkdirAll(filepath.Dir(configPath()), 0750); err != nil {
+		return fmt.Errorf("error creating config directory: %w", err)
+	}
+
 	// Write to disk
 	if err := viper.WriteConfig(); err != nil {
 		return fmt.Errorf("error writing config: %w", err)
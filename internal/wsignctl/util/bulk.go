@@ -0,0 +1,214 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// DefaultConcurrency is how many items a bulk operation works on at once
+// unless --concurrency says otherwise
+const DefaultConcurrency = 5
+
+// Outcomes of a bulk item that every operation shares. Operations report
+// their own outcomes, such as "created", for items that succeed.
+const (
+	BulkFailed     = "failed"
+	BulkNotStarted = "not started"
+)
+
+// BulkOptions controls how a bulk operation runs
+type BulkOptions struct {
+	// Concurrency is how many items are worked on at once
+	Concurrency int
+	// FailFast stops starting new items after the first failure
+	FailFast bool
+}
+
+// AddBulkFlags registers the --concurrency and --fail-fast flags
+func AddBulkFlags(cmd *cobra.Command, opts *BulkOptions) {
+	cmd.Flags().IntVar(&opts.Concurrency, "concurrency", DefaultConcurrency, "Number of requests to run at once")
+	cmd.Flags().BoolVar(&opts.FailFast, "fail-fast", false, "Stop starting new work after the first failure")
+}
+
+// Validate checks the options given on the command line
+func (o BulkOptions) Validate() error {
+	if o.Concurrency < 1 {
+		return fmt.Errorf("--concurrency must be at least 1")
+	}
+	return nil
+}
+
+// BulkResult is what happened to one item of a bulk operation
+type BulkResult struct {
+	// Item names the item in the summary
+	Item string
+	// Outcome is what the operation did, BulkFailed if it returned an
+	// error, or BulkNotStarted if it never ran
+	Outcome string
+	Err     error
+}
+
+// BulkSummary collects the result of every item of a bulk operation
+type BulkSummary struct {
+	// Results are in the order the items were given
+	Results []BulkResult
+	// Interrupted is set when the operation was cancelled before every
+	// item was started
+	Interrupted bool
+	// Stopped is set when --fail-fast stopped the operation
+	Stopped bool
+}
+
+// Count returns how many items had outcome
+func (s *BulkSummary) Count(outcome string) int {
+	n := 0
+	for _, r := range s.Results {
+		if r.Outcome == outcome {
+			n++
+		}
+	}
+	return n
+}
+
+// Err returns an error describing the failed and unstarted items, or nil
+// if every item succeeded. noun names the items, as in "display(s)".
+func (s *BulkSummary) Err(noun string) error {
+	failed, notStarted := s.Count(BulkFailed), s.Count(BulkNotStarted)
+	switch {
+	case s.Interrupted:
+		return fmt.Errorf("interrupted with %d of %d %s not started", notStarted, len(s.Results), noun)
+	case failed > 0:
+		return fmt.Errorf("%d of %d %s failed", failed, len(s.Results), noun)
+	}
+	return nil
+}
+
+// RunBulk calls fn for every item, at most opts.Concurrency at a time, and
+// collects what happened to each rather than stopping at the first error.
+// fn returns the outcome of a successful item.
+//
+// Once ctx is cancelled, or with opts.FailFast once an item fails, no new
+// items are started. Items already running are waited for, and the context
+// they are given is not cancelled with ctx, so each request in flight
+// completes and its result is reported.
+func RunBulk(ctx context.Context, items []string, opts BulkOptions, progress *Progress, fn func(ctx context.Context, i int) (string, error)) *BulkSummary {
+	summary := &BulkSummary{Results: make([]BulkResult, len(items))}
+	for i, item := range items {
+		summary.Results[i] = BulkResult{Item: item, Outcome: BulkNotStarted}
+	}
+
+	work := context.WithoutCancel(ctx)
+	slots := make(chan struct{}, opts.Concurrency)
+	stop := make(chan struct{})
+	var (
+		stopOnce sync.Once
+		wg       sync.WaitGroup
+	)
+
+	for i := range items {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		case <-stop:
+		}
+		// A slot may come free at the same moment as the stop, so both
+		// are checked again whichever case was chosen
+		if ctx.Err() != nil {
+			summary.Interrupted = true
+			break
+		}
+		if stopped(stop) {
+			summary.Stopped = true
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			outcome, err := fn(work, i)
+			if err != nil {
+				outcome = BulkFailed
+				if opts.FailFast {
+					stopOnce.Do(func() { close(stop) })
+				}
+			}
+			summary.Results[i].Outcome, summary.Results[i].Err = outcome, err
+			progress.Add(err != nil)
+		}(i)
+	}
+	wg.Wait()
+	progress.Finish()
+	return summary
+}
+
+// stopped reports whether stop is closed
+func stopped(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// NotifyInterrupt returns a context that is cancelled on the first Ctrl-C,
+// so a bulk operation can stop starting new work and report what it did.
+// A second Ctrl-C ends the program as usual.
+func NotifyInterrupt(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(parent, os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// PrintBulkSummary writes a table of the items that failed or were never
+// started, followed by a count of each outcome. outcomes lists the
+// successful outcomes in the order they are counted; failures are always
+// counted, and unstarted items when there are any.
+func PrintBulkSummary(w io.Writer, s *BulkSummary, outcomes ...string) {
+	tw := NewTabWriter(w)
+	header := false
+	for _, r := range s.Results {
+		if r.Outcome != BulkFailed && r.Outcome != BulkNotStarted {
+			continue
+		}
+		if !header {
+			fmt.Fprintf(tw, "ITEM\tRESULT\tERROR\n")
+			header = true
+		}
+		message := ""
+		if r.Err != nil {
+			message = strings.Join(strings.Fields(r.Err.Error()), " ")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Item, r.Outcome, message)
+	}
+	tw.Flush()
+
+	counts := make([]string, 0, len(outcomes)+2)
+	for _, outcome := range append(outcomes, BulkFailed) {
+		counts = append(counts, fmt.Sprintf("%s %d", outcome, s.Count(outcome)))
+	}
+	if n := s.Count(BulkNotStarted); n > 0 {
+		counts = append(counts, fmt.Sprintf("%s %d", BulkNotStarted, n))
+	}
+	line := strings.Join(counts, ", ")
+	line = strings.ToUpper(line[:1]) + line[1:]
+	switch {
+	case s.Interrupted:
+		line += " (interrupted)"
+	case s.Stopped:
+		line += " (stopped after the first failure)"
+	}
+	fmt.Fprintln(w, line)
+}
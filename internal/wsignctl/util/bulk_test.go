@@ -0,0 +1,167 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bulkItems returns n item names
+func bulkItems(n int) []string {
+	items := make([]string, n)
+	for i := range items {
+		items[i] = fmt.Sprintf("item-%d", i)
+	}
+	return items
+}
+
+func TestRunBulk(t *testing.T) {
+	t.Run("every item runs within the concurrency limit", func(t *testing.T) {
+		var running, peak atomic.Int64
+		summary := RunBulk(context.Background(), bulkItems(20), BulkOptions{Concurrency: 3}, nil,
+			func(ctx context.Context, i int) (string, error) {
+				n := running.Add(1)
+				defer running.Add(-1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				if i%4 == 0 {
+					return "", errors.New("refused")
+				}
+				return "done", nil
+			})
+
+		assert.LessOrEqual(t, peak.Load(), int64(3))
+		assert.Equal(t, 15, summary.Count("done"))
+		assert.Equal(t, 5, summary.Count(BulkFailed))
+		assert.False(t, summary.Interrupted || summary.Stopped)
+		assert.EqualError(t, summary.Err("item(s)"), "5 of 20 item(s) failed")
+		assert.Equal(t, "item-4", summary.Results[4].Item, "results keep the order of the items")
+		assert.EqualError(t, summary.Results[4].Err, "refused")
+	})
+
+	t.Run("interrupting stops new work and lets work in flight finish", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		release := make(chan struct{})
+		var started atomic.Int64
+
+		done := make(chan *BulkSummary)
+		go func() {
+			done <- RunBulk(ctx, bulkItems(10), BulkOptions{Concurrency: 2}, nil,
+				func(ctx context.Context, i int) (string, error) {
+					started.Add(1)
+					<-release
+					// Work already started is not cancelled with the operation
+					return "done", ctx.Err()
+				})
+		}()
+
+		require.Eventually(t, func() bool { return started.Load() == 2 }, time.Second, time.Millisecond)
+		cancel()
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+		summary := <-done
+
+		assert.Equal(t, int64(2), started.Load())
+		assert.True(t, summary.Interrupted)
+		assert.Equal(t, 2, summary.Count("done"))
+		assert.Equal(t, 8, summary.Count(BulkNotStarted))
+		for _, r := range summary.Results {
+			assert.NoError(t, r.Err)
+		}
+		assert.EqualError(t, summary.Err("item(s)"), "interrupted with 8 of 10 item(s) not started")
+	})
+
+	t.Run("fail fast stops after the first failure", func(t *testing.T) {
+		summary := RunBulk(context.Background(), bulkItems(10), BulkOptions{Concurrency: 1, FailFast: true}, nil,
+			func(ctx context.Context, i int) (string, error) {
+				if i == 3 {
+					return "", errors.New("refused")
+				}
+				return "done", nil
+			})
+
+		assert.True(t, summary.Stopped)
+		assert.Equal(t, 3, summary.Count("done"))
+		assert.Equal(t, 1, summary.Count(BulkFailed))
+		assert.Equal(t, 6, summary.Count(BulkNotStarted))
+	})
+
+	t.Run("a cancelled context starts nothing", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		summary := RunBulk(ctx, bulkItems(3), BulkOptions{Concurrency: 5}, nil,
+			func(ctx context.Context, i int) (string, error) {
+				t.Error("no item should start")
+				return "", nil
+			})
+		assert.True(t, summary.Interrupted)
+		assert.Equal(t, 3, summary.Count(BulkNotStarted))
+	})
+}
+
+func TestPrintBulkSummary(t *testing.T) {
+	summary := &BulkSummary{
+		Results: []BulkResult{
+			{Item: "lobby-north", Outcome: "created"},
+			{Item: "roof-1", Outcome: BulkFailed, Err: errors.New("HTTP 409:\n  zone is full")},
+			{Item: "lobby-south", Outcome: "skipped"},
+			{Item: "cafe-menu-1", Outcome: "created"},
+			{Item: "cafe-menu-2", Outcome: BulkNotStarted},
+		},
+		Interrupted: true,
+	}
+
+	var out bytes.Buffer
+	PrintBulkSummary(&out, summary, "created", "updated", "skipped")
+	assert.Equal(t, ""+
+		"ITEM         RESULT       ERROR\n"+
+		"roof-1       failed       HTTP 409: zone is full\n"+
+		"cafe-menu-2  not started  \n"+
+		"Created 2, updated 0, skipped 1, failed 1, not started 1 (interrupted)\n", out.String())
+
+	out.Reset()
+	PrintBulkSummary(&out, &BulkSummary{Results: []BulkResult{{Item: "a", Outcome: "updated"}}}, "updated")
+	assert.Equal(t, "Updated 1, failed 0\n", out.String())
+	assert.NoError(t, (&BulkSummary{Results: []BulkResult{{Outcome: "updated"}}}).Err("item(s)"))
+}
+
+func TestProgress(t *testing.T) {
+	var out bytes.Buffer
+	p := NewProgress(&out, "Importing displays", 25)
+	for i := 0; i < 23; i++ {
+		p.Add(i == 7)
+	}
+	p.Finish()
+
+	// Piped output gets a line every tenth of the way and the final count
+	assert.Equal(t, ""+
+		"Importing displays: 2/25\n"+
+		"Importing displays: 4/25\n"+
+		"Importing displays: 6/25\n"+
+		"Importing displays: 8/25 (1 failed)\n"+
+		"Importing displays: 10/25 (1 failed)\n"+
+		"Importing displays: 12/25 (1 failed)\n"+
+		"Importing displays: 14/25 (1 failed)\n"+
+		"Importing displays: 16/25 (1 failed)\n"+
+		"Importing displays: 18/25 (1 failed)\n"+
+		"Importing displays: 20/25 (1 failed)\n"+
+		"Importing displays: 22/25 (1 failed)\n"+
+		"Importing displays: 23/25 (1 failed)\n", out.String())
+
+	assert.False(t, IsTerminal(&out))
+	var none *Progress
+	none.Add(true)
+	none.Finish()
+}
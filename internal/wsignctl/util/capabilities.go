@@ -0,0 +1,23 @@
+package util
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/internal/wsignctl/client"
+)
+
+// CheckFeature reports whether the server advertises feature. When it does
+// not, or cannot describe itself, a warning is written to the command's
+// error output explaining the fallback the command uses instead.
+func CheckFeature(cmd *cobra.Command, c *client.Client, feature, fallback string) bool {
+	supported, err := c.SupportsFeature(cmd.Context(), feature)
+	switch {
+	case err != nil:
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: unable to discover server capabilities (%v); %s\n", err, fallback)
+	case !supported:
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: server does not support %s; %s\n", feature, fallback)
+	}
+	return err == nil && supported
+}
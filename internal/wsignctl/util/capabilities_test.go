@@ -0,0 +1,93 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignctl/client"
+)
+
+func TestCheckFeature(t *testing.T) {
+	// serve answers discovery requests with doc, or 404 when doc is nil
+	serve := func(doc *v1alpha1.APIDiscovery, requests *int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*requests++
+			if doc == nil || r.URL.Path != "/api/v1alpha1" {
+				http.NotFound(w, r)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(doc)
+		}))
+	}
+	newCmd := func() (*cobra.Command, *bytes.Buffer) {
+		var stderr bytes.Buffer
+		cmd := &cobra.Command{}
+		cmd.SetErr(&stderr)
+		cmd.SetContext(context.Background())
+		return cmd, &stderr
+	}
+	doc := &v1alpha1.APIDiscovery{ServerVersion: "0.2.0", Features: []string{v1alpha1.FeatureBulkUpdate}}
+
+	t.Run("supported feature is silent", func(t *testing.T) {
+		var requests int
+		srv := serve(doc, &requests)
+		defer srv.Close()
+		c, err := client.NewClient(srv.URL)
+		require.NoError(t, err)
+		cmd, stderr := newCmd()
+
+		assert.True(t, CheckFeature(cmd, c, v1alpha1.FeatureBulkUpdate, "updating one at a time"))
+		assert.Empty(t, stderr.String())
+	})
+
+	t.Run("missing feature warns with the fallback", func(t *testing.T) {
+		var requests int
+		srv := serve(doc, &requests)
+		defer srv.Close()
+		c, err := client.NewClient(srv.URL)
+		require.NoError(t, err)
+		cmd, stderr := newCmd()
+
+		assert.False(t, CheckFeature(cmd, c, v1alpha1.FeatureWatch, "refreshing every 5s"))
+		assert.Equal(t, "Warning: server does not support watch; refreshing every 5s\n", stderr.String())
+	})
+
+	t.Run("server without discovery warns", func(t *testing.T) {
+		var requests int
+		srv := serve(nil, &requests)
+		defer srv.Close()
+		c, err := client.NewClient(srv.URL)
+		require.NoError(t, err)
+		cmd, stderr := newCmd()
+
+		assert.False(t, CheckFeature(cmd, c, v1alpha1.FeatureWatch, "refreshing every 5s"))
+		assert.Contains(t, stderr.String(), "Warning: unable to discover server capabilities")
+		assert.Contains(t, stderr.String(), "refreshing every 5s")
+	})
+
+	t.Run("discovery is cached per context", func(t *testing.T) {
+		var requests int
+		srv := serve(doc, &requests)
+		defer srv.Close()
+		cache := filepath.Join(t.TempDir(), "ctx", "discovery.json")
+
+		for i := 0; i < 2; i++ {
+			c, err := client.NewClient(srv.URL, client.WithDiscoveryCache(cache, time.Minute))
+			require.NoError(t, err)
+			cmd, _ := newCmd()
+			assert.True(t, CheckFeature(cmd, c, v1alpha1.FeatureBulkUpdate, ""))
+		}
+		assert.Equal(t, 1, requests)
+	})
+}
@@ -2,8 +2,13 @@
 package util
 
 import (
+	"crypto/tls"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -11,10 +16,26 @@ import (
 	"github.com/wrale/wrale-signage/internal/wsignctl/config"
 )
 
+// Environment variables that override the configured context. The WRALE_*
+// names are still honoured for compatibility with older scripts.
+const (
+	envServer        = "WSIGN_SERVER"
+	envToken         = "WSIGN_TOKEN"
+	envInsecure      = "WSIGN_INSECURE_SKIP_TLS"
+	envLegacyAPIURL  = "WRALE_API_URL"
+	envLegacyAuthKey = "WRALE_AUTH_TOKEN"
+)
+
+// discoveryTTL is how long a context's cached discovery document is used
+const discoveryTTL = 10 * time.Minute
+
 // clientConfig holds the configuration needed to create an API client
 type clientConfig struct {
-	apiURL string
-	token  string
+	apiURL   string
+	token    string
+	insecure bool
+	// contextName is set when the server address came from a saved context
+	contextName string
 }
 
 // GetClient creates a new API client configured from the environment and config file.
@@ -43,8 +64,13 @@ func GetClientFromCommand(cmd *cobra.Command) (*client.Client, error) {
 // 1. Command flags (if cmd is provided)
 // 2. Environment variables
 // 3. Configuration file
+//
+// Overrides only affect the client being built; the saved contexts are never
+// modified.
 func getClientConfig(cmd *cobra.Command) (*clientConfig, error) {
 	cfg := &clientConfig{}
+	insecureSet := false
+	contextName := ""
 
 	// Try command flags first if available
 	if cmd != nil {
@@ -54,53 +80,127 @@ func getClientConfig(cmd *cobra.Command) (*clientConfig, error) {
 		if token, err := cmd.Flags().GetString("token"); err == nil && token != "" {
 			cfg.token = token
 		}
+		if cmd.Flags().Changed("insecure-skip-tls") {
+			cfg.insecure, _ = cmd.Flags().GetBool("insecure-skip-tls")
+			insecureSet = true
+		}
+		contextName, _ = cmd.Flags().GetString("context")
 	}
 
 	// Check environment variables next
 	if cfg.apiURL == "" {
-		cfg.apiURL = os.Getenv("WRALE_API_URL")
+		cfg.apiURL = firstEnv(envServer, envLegacyAPIURL)
 	}
 	if cfg.token == "" {
-		cfg.token = os.Getenv("WRALE_AUTH_TOKEN")
+		cfg.token = firstEnv(envToken, envLegacyAuthKey)
+	}
+	if !insecureSet {
+		if v, ok := os.LookupEnv(envInsecure); ok {
+			insecure, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value %q: %w", envInsecure, v, err)
+			}
+			cfg.insecure = insecure
+			insecureSet = true
+		}
 	}
 
 	// If still missing values, try config file
-	if cfg.apiURL == "" || cfg.token == "" {
-		fileCfg, err := config.LoadConfig()
+	if cfg.apiURL == "" || cfg.token == "" || !insecureSet {
+		name, ctx, err := loadContext(contextName)
 		if err != nil {
-			return nil, fmt.Errorf("failed to load config: %w", err)
+			// A fully specified override does not need a saved context
+			if cfg.apiURL != "" && cfg.token != "" {
+				return cfg, nil
+			}
+			return nil, err
 		}
 
-		ctx, err := fileCfg.GetCurrentContext()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get current context: %w", err)
-		}
+		// A context's token and TLS setting belong to its server. A server
+		// given by flag or environment is never sent them unless it is
+		// that same server.
+		sameServer := cfg.apiURL == "" || sameURL(cfg.apiURL, ctx.Server)
 
 		// Use context values if still not set
 		if cfg.apiURL == "" {
 			if ctx.Server == "" {
-				return nil, fmt.Errorf("no API server configured - set WRALE_API_URL, use --server flag, or configure server in wsignctl config")
+				return nil, fmt.Errorf("no API server configured - set %s, use --server flag, or configure server in wsignctl config", envServer)
 			}
 			cfg.apiURL = ctx.Server
+			cfg.contextName = name
 		}
 
 		if cfg.token == "" {
+			if !sameServer {
+				return nil, fmt.Errorf("the token of context %q is only sent to its own server - set %s or use --token flag to authenticate to %s", name, envToken, cfg.apiURL)
+			}
 			if ctx.Token == "" {
-				return nil, fmt.Errorf("no auth token configured - set WRALE_AUTH_TOKEN, use --token flag, or authenticate using 'wsignctl login'")
+				return nil, fmt.Errorf("no auth token configured - set %s, use --token flag, or authenticate using 'wsignctl login'", envToken)
 			}
 			cfg.token = ctx.Token
 		}
+
+		if !insecureSet && sameServer {
+			cfg.insecure = ctx.InsecureSkipVerify
+		}
 	}
 
 	return cfg, nil
 }
 
+// loadContext returns the named context from the config file, or the current
+// context if name is empty, along with the resolved context name
+func loadContext(name string) (string, *config.Context, error) {
+	fileCfg, err := config.LoadConfig()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if name != "" {
+		ctx, ok := fileCfg.Contexts[name]
+		if !ok {
+			return "", nil, fmt.Errorf("context %q not found", name)
+		}
+		return name, ctx, nil
+	}
+
+	ctx, err := fileCfg.GetCurrentContext()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get current context: %w", err)
+	}
+	return fileCfg.CurrentContext, ctx, nil
+}
+
+// sameURL reports whether two server addresses name the same server,
+// ignoring a trailing slash
+func sameURL(a, b string) bool {
+	return strings.TrimRight(a, "/") == strings.TrimRight(b, "/")
+}
+
+// firstEnv returns the value of the first environment variable that is set
+func firstEnv(keys ...string) string {
+	for _, key := range keys {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
 // createClient creates a new API client using the provided configuration
 func createClient(cfg *clientConfig) (*client.Client, error) {
-	c, err := client.NewClient(
-		cfg.apiURL,
-		client.WithToken(cfg.token),
-	)
+	options := []client.ClientOption{client.WithToken(cfg.token)}
+	if cfg.contextName != "" {
+		// Each context talks to its own server, so each gets its own cache
+		path := filepath.Join(config.CacheDir(), cfg.contextName, "discovery.json")
+		options = append(options, client.WithDiscoveryCache(path, discoveryTTL))
+	}
+	if cfg.insecure {
+		// #nosec G402 -- explicitly requested by the user
+		options = append(options, client.WithTLSConfig(&tls.Config{InsecureSkipVerify: true}))
+	}
+
+	c, err := client.NewClient(cfg.apiURL, options...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create API client: %w", err)
 	}
@@ -0,0 +1,72 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestClientConfigOverrides checks which context values a server given by
+// flag or environment is sent
+func TestClientConfigOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`current-context: prod
+contexts:
+  prod:
+    name: prod
+    server: https://signage.example.com
+    token: prod-token
+    insecure-skip-verify: true
+`), 0o600))
+	t.Setenv("WSIGNCTL_CONFIG", path)
+	for _, key := range []string{envServer, envToken, envInsecure, envLegacyAPIURL, envLegacyAuthKey} {
+		t.Setenv(key, "")
+	}
+	os.Unsetenv(envInsecure)
+
+	command := func(flags ...string) *cobra.Command {
+		cmd := &cobra.Command{}
+		cmd.Flags().String("server", "", "")
+		cmd.Flags().String("token", "", "")
+		cmd.Flags().Bool("insecure-skip-tls", false, "")
+		cmd.Flags().String("context", "", "")
+		require.NoError(t, cmd.Flags().Parse(flags))
+		return cmd
+	}
+
+	t.Run("the current context is used as saved", func(t *testing.T) {
+		cfg, err := getClientConfig(command())
+		require.NoError(t, err)
+		assert.Equal(t, "https://signage.example.com", cfg.apiURL)
+		assert.Equal(t, "prod-token", cfg.token)
+		assert.True(t, cfg.insecure)
+	})
+
+	t.Run("another server is not sent the context's token", func(t *testing.T) {
+		_, err := getClientConfig(command("--server", "https://other.example"))
+		assert.ErrorContains(t, err, "--token")
+
+		t.Setenv(envServer, "https://other.example")
+		_, err = getClientConfig(command())
+		assert.ErrorContains(t, err, envToken)
+	})
+
+	t.Run("another server with its own token", func(t *testing.T) {
+		cfg, err := getClientConfig(command("--server", "https://other.example", "--token", "other-token"))
+		require.NoError(t, err)
+		assert.Equal(t, "https://other.example", cfg.apiURL)
+		assert.Equal(t, "other-token", cfg.token)
+		assert.False(t, cfg.insecure, "TLS checks are only skipped for the context's server")
+	})
+
+	t.Run("the context's own server keeps its token", func(t *testing.T) {
+		cfg, err := getClientConfig(command("--server", "https://signage.example.com/"))
+		require.NoError(t, err)
+		assert.Equal(t, "prod-token", cfg.token)
+		assert.True(t, cfg.insecure)
+	})
+}
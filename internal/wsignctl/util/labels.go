@@ -0,0 +1,82 @@
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxLabelKeyLength is the longest label key the server accepts
+const MaxLabelKeyLength = 63
+
+// ReservedLabelPrefix starts the label keys set by the system itself, such
+// as sys.agentVersion. Operators cannot set or remove them.
+const ReservedLabelPrefix = "sys."
+
+// labelKeyPattern allows letters, digits, dashes and dots, starting and
+// ending with a letter or digit
+var labelKeyPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9.-]*[A-Za-z0-9])?$`)
+
+// ValidateLabelKey checks a label or property key. Keys are 1 to 63
+// letters, digits, dashes and dots, start and end with a letter or digit,
+// and must not use the reserved sys. prefix.
+func ValidateLabelKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("label key must not be empty")
+	}
+	if len(key) > MaxLabelKeyLength {
+		return fmt.Errorf("label key %q is %d characters, the limit is %d", key, len(key), MaxLabelKeyLength)
+	}
+	if !labelKeyPattern.MatchString(key) {
+		return fmt.Errorf("invalid label key %q - use letters, digits, '-' and '.', starting and ending with a letter or digit", key)
+	}
+	if strings.HasPrefix(strings.ToLower(key), ReservedLabelPrefix) {
+		return fmt.Errorf("label key %q uses the reserved %q prefix", key, ReservedLabelPrefix)
+	}
+	return nil
+}
+
+// ParseLabels parses repeated key=value flags into a map. flag names the
+// flag in error messages. A value may be empty only when written as key=;
+// a bare key or a value of only whitespace is rejected as most likely a
+// quoting mistake. A key given twice is an error rather than letting the
+// last one win silently.
+func ParseLabels(flag string, values []string) (map[string]string, error) {
+	labels := make(map[string]string, len(values))
+	for _, value := range values {
+		key, v, ok := strings.Cut(value, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid --%s %q - use key=value, or key= for an empty value", flag, value)
+		}
+		if err := ValidateLabelKey(key); err != nil {
+			return nil, fmt.Errorf("invalid --%s %q: %w", flag, value, err)
+		}
+		if v != "" && strings.TrimSpace(v) == "" {
+			return nil, fmt.Errorf("invalid --%s %q: the value is only whitespace; use %s= if it should be empty", flag, value, key)
+		}
+		if _, dup := labels[key]; dup {
+			return nil, fmt.Errorf("--%s %q is given more than once", flag, key)
+		}
+		labels[key] = v
+	}
+	return labels, nil
+}
+
+// ParseLabelKeys checks the keys named by a repeated flag, such as
+// --remove-label, which take a key without a value
+func ParseLabelKeys(flag string, keys []string) ([]string, error) {
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if strings.Contains(key, "=") {
+			return nil, fmt.Errorf("use just the key with --%s, not %q", flag, key)
+		}
+		if err := ValidateLabelKey(key); err != nil {
+			return nil, fmt.Errorf("invalid --%s: %w", flag, err)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("--%s %q is given more than once", flag, key)
+		}
+		seen[key] = true
+	}
+	return keys, nil
+}
@@ -0,0 +1,124 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:   "key value pairs",
+			values: []string{"orientation=portrait", "screen-size=55", "floor.level=2"},
+			want:   map[string]string{"orientation": "portrait", "screen-size": "55", "floor.level": "2"},
+		},
+		{
+			name:   "values keep everything after the first equals sign",
+			values: []string{"query=a=b"},
+			want:   map[string]string{"query": "a=b"},
+		},
+		{
+			name:   "explicit empty value",
+			values: []string{"note="},
+			want:   map[string]string{"note": ""},
+		},
+		{
+			name:   "no labels",
+			values: nil,
+			want:   map[string]string{},
+		},
+		{
+			name:    "bare key",
+			values:  []string{"orientation"},
+			wantErr: `invalid --label "orientation" - use key=value, or key= for an empty value`,
+		},
+		{
+			name:    "whitespace value",
+			values:  []string{"note= "},
+			wantErr: "use note= if it should be empty",
+		},
+		{
+			name:    "spaces in key",
+			values:  []string{"  weird key =x"},
+			wantErr: `invalid label key "  weird key "`,
+		},
+		{
+			name:    "empty key",
+			values:  []string{"=x"},
+			wantErr: "label key must not be empty",
+		},
+		{
+			name:    "underscore",
+			values:  []string{"screen_size=55"},
+			wantErr: `invalid label key "screen_size"`,
+		},
+		{
+			name:    "trailing dot",
+			values:  []string{"floor.=2"},
+			wantErr: `invalid label key "floor."`,
+		},
+		{
+			name:    "too long",
+			values:  []string{strings.Repeat("a", 64) + "=x"},
+			wantErr: "is 64 characters, the limit is 63",
+		},
+		{
+			name:    "reserved prefix",
+			values:  []string{"sys.agentVersion=hacked"},
+			wantErr: `label key "sys.agentVersion" uses the reserved "sys." prefix`,
+		},
+		{
+			name:    "reserved prefix in another case",
+			values:  []string{"SYS.agentVersion=hacked"},
+			wantErr: "reserved",
+		},
+		{
+			name:    "duplicate key",
+			values:  []string{"orientation=portrait", "zone=lobby", "orientation=landscape"},
+			wantErr: `--label "orientation" is given more than once`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLabels("label", tt.values)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("the longest key is accepted", func(t *testing.T) {
+		key := strings.Repeat("a", MaxLabelKeyLength)
+		got, err := ParseLabels("label", []string{key + "=x"})
+		require.NoError(t, err)
+		assert.Equal(t, "x", got[key])
+	})
+}
+
+func TestParseLabelKeys(t *testing.T) {
+	keys, err := ParseLabelKeys("remove-label", []string{"temporary", "campaign"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"temporary", "campaign"}, keys)
+
+	_, err = ParseLabelKeys("remove-label", []string{"temporary=1"})
+	assert.ErrorContains(t, err, `use just the key with --remove-label, not "temporary=1"`)
+
+	_, err = ParseLabelKeys("remove-label", []string{"sys.agentVersion"})
+	assert.ErrorContains(t, err, "reserved")
+
+	_, err = ParseLabelKeys("remove-label", []string{"temporary", "temporary"})
+	assert.ErrorContains(t, err, `--remove-label "temporary" is given more than once`)
+}
@@ -5,12 +5,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 	"text/tabwriter"
 	"time"
 )
 
-// PrintJSON writes a JSON representation of v to w with proper indentation
+// PrintJSON writes a JSON representation of v to w with proper indentation.
+// Map keys are written in sorted order, as encoding/json does for maps at
+// any depth, so the output is the same on every run.
 func PrintJSON(w io.Writer, v interface{}) error {
 	enc := json.NewEncoder(w)
 	enc.SetIndent("", "  ")
@@ -36,14 +39,26 @@ func FormatDuration(d time.Duration) string {
 	return fmt.Sprintf("%dd ago", int(d.Hours()/24))
 }
 
-// FormatProperties formats a map of properties as a comma-separated string of key=value pairs
+// FormatProperties formats a map of properties as a comma-separated string
+// of key=value pairs, sorted by key so the output is the same on every run
 func FormatProperties(props map[string]string) string {
 	if len(props) == 0 {
 		return ""
 	}
-	var pairs []string
-	for k, v := range props {
-		pairs = append(pairs, fmt.Sprintf("%s=%s", k, v))
+	pairs := make([]string, 0, len(props))
+	for _, k := range SortedKeys(props) {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, props[k]))
 	}
 	return strings.Join(pairs, ",")
 }
+
+// SortedKeys returns the keys of m in ascending order, for printing maps
+// the same way on every run
+func SortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,72 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatProperties(t *testing.T) {
+	props := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		props[fmt.Sprintf("key-%02d", 19-i)] = fmt.Sprint(i)
+	}
+
+	first := FormatProperties(props)
+	assert.Regexp(t, `^key-00=19,key-01=18,.*,key-19=0$`, first)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, first, FormatProperties(props))
+	}
+
+	assert.Equal(t, "", FormatProperties(nil))
+	assert.Equal(t, "orientation=portrait", FormatProperties(map[string]string{"orientation": "portrait"}))
+}
+
+func TestSortedKeys(t *testing.T) {
+	assert.Equal(t, []string{"a", "b", "c"}, SortedKeys(map[string]int{"c": 1, "a": 2, "b": 3}))
+	assert.Empty(t, SortedKeys(map[string]bool(nil)))
+}
+
+func TestPrintJSONSortsMapKeys(t *testing.T) {
+	// Maps reached through interface{} values are sorted as well as typed
+	// ones
+	v := map[string]interface{}{
+		"zone":       "lobby",
+		"properties": map[string]string{"screen": "55", "floor": "2", "orientation": "portrait"},
+		"nested": []interface{}{
+			map[string]interface{}{"z": 1, "a": map[string]interface{}{"y": true, "b": nil}},
+		},
+		"active": true,
+	}
+
+	var first bytes.Buffer
+	require.NoError(t, PrintJSON(&first, v))
+	assert.Equal(t, `{
+  "active": true,
+  "nested": [
+    {
+      "a": {
+        "b": null,
+        "y": true
+      },
+      "z": 1
+    }
+  ],
+  "properties": {
+    "floor": "2",
+    "orientation": "portrait",
+    "screen": "55"
+  },
+  "zone": "lobby"
+}
+`, first.String())
+
+	for i := 0; i < 10; i++ {
+		var again bytes.Buffer
+		require.NoError(t, PrintJSON(&again, v))
+		assert.Equal(t, first.Bytes(), again.Bytes())
+	}
+}
@@ -0,0 +1,93 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Progress reports how far a bulk operation has got. On a terminal it
+// keeps a single counter line up to date; when the output is piped it
+// prints a plain line every tenth of the way, so logs show progress
+// without a line per item. A nil Progress reports nothing.
+type Progress struct {
+	mu      sync.Mutex
+	w       io.Writer
+	label   string
+	total   int
+	done    int
+	failed  int
+	tty     bool
+	every   int
+	printed int
+}
+
+// NewProgress creates a reporter for total items written to w, with each
+// line starting with label
+func NewProgress(w io.Writer, label string, total int) *Progress {
+	every := total / 10
+	if every < 1 {
+		every = 1
+	}
+	return &Progress{w: w, label: label, total: total, tty: IsTerminal(w), every: every}
+}
+
+// IsTerminal reports whether w is an interactive terminal
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Add records that one more item has finished
+func (p *Progress) Add(failed bool) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done++
+	if failed {
+		p.failed++
+	}
+	if p.tty || p.done%p.every == 0 || p.done == p.total {
+		p.print()
+	}
+}
+
+// Finish ends the progress output, printing the final count if it has
+// not been shown yet
+func (p *Progress) Finish() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.printed != p.done || p.done == 0 {
+		p.print()
+	}
+	if p.tty {
+		fmt.Fprintln(p.w)
+	}
+}
+
+// print writes the current count. On a terminal the line replaces the
+// previous one.
+func (p *Progress) print() {
+	line := fmt.Sprintf("%s: %d/%d", p.label, p.done, p.total)
+	if p.failed > 0 {
+		line += fmt.Sprintf(" (%d failed)", p.failed)
+	}
+	if p.tty {
+		fmt.Fprintf(p.w, "\r\033[K%s", line)
+	} else {
+		fmt.Fprintln(p.w, line)
+	}
+	p.printed = p.done
+}
@@ -111,6 +111,9 @@ func FormatSchedule(s *v1alpha1.Schedule) string {
 func FormatSelectors(s v1alpha1.DisplaySelector) string {
 	var parts []string
 
+	if s.DisplayRef != "" {
+		parts = append(parts, fmt.Sprintf("display=%s", s.DisplayRef))
+	}
 	if s.SiteID != "" {
 		parts = append(parts, fmt.Sprintf("site=%s", s.SiteID))
 	}
@@ -120,6 +123,7 @@ func FormatSelectors(s v1alpha1.DisplaySelector) string {
 	if s.Position != "" {
 		parts = append(parts, fmt.Sprintf("pos=%s", s.Position))
 	}
+	parts = append(parts, v1alpha1.FormatMatchProperties(s.MatchProperties)...)
 
 	if len(parts) == 0 {
 		return "*"
@@ -0,0 +1,37 @@
+package util
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/wrale/wrale-signage/internal/wsignctl/config"
+)
+
+// TimezoneFlag names the flag commands take to show times in another zone
+const TimezoneFlag = "tz"
+
+// AddTimezoneFlag adds the --tz flag read by Timezone to cmd
+func AddTimezoneFlag(cmd *cobra.Command) {
+	cmd.Flags().String(TimezoneFlag, "",
+		"Time zone to show times in: an IANA name such as Europe/London, or local for each display's own zone (default from the context, else UTC)")
+}
+
+// Timezone returns the zone cmd should ask the server to give times in:
+// the --tz flag when set, else the zone configured on the context in use.
+// An empty result leaves times in UTC.
+func Timezone(cmd *cobra.Command) (string, error) {
+	if flag := cmd.Flags().Lookup(TimezoneFlag); flag != nil && flag.Changed {
+		tz := flag.Value.String()
+		if err := config.ValidateTimezone(tz); err != nil {
+			return "", err
+		}
+		return tz, nil
+	}
+
+	contextName, _ := cmd.Flags().GetString("context")
+	if _, ctx, err := loadContext(contextName); err == nil {
+		return ctx.Timezone, nil
+	}
+	// Without a saved context the server is named by flags or the
+	// environment, so there is no configured zone either
+	return "", nil
+}
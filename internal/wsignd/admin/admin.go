@@ -0,0 +1,66 @@
+// Package admin serves diagnostics for operators supporting a deployment
+package admin
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+)
+
+// ConfigPath is where the effective configuration is served
+const ConfigPath = "/api/v1alpha1/admin/config"
+
+// RetentionPath is where the storage and retention of content events is
+// described
+const RetentionPath = "/api/v1alpha1/admin/retention"
+
+// EffectiveConfig describes the configuration cfg was loaded with, with
+// secrets redacted
+func EffectiveConfig(cfg *config.Config, serverVersion string) *v1alpha1.EffectiveConfig {
+	effective := cfg.Effective()
+	doc := &v1alpha1.EffectiveConfig{
+		TypeMeta:      v1alpha1.TypeMeta{Kind: "EffectiveConfig", APIVersion: "v1alpha1"},
+		ServerVersion: serverVersion,
+		Settings:      make([]v1alpha1.ConfigSetting, 0, len(effective)),
+	}
+	for _, s := range effective {
+		doc.Settings = append(doc.Settings, v1alpha1.ConfigSetting{
+			Name:   s.Name,
+			Value:  s.Value,
+			Source: string(s.Source),
+			Secret: config.Secret(s.Name),
+		})
+	}
+	return doc
+}
+
+// ConfigHandler serves doc as JSON. The document holds no secrets, but it
+// still describes the deployment, so the route should be limited to
+// administrators.
+func ConfigHandler(doc *v1alpha1.EffectiveConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		httpapi.WriteJSON(w, http.StatusOK, doc)
+	}
+}
+
+// RetentionStatus describes how content events are stored and pruned
+type RetentionStatus interface {
+	Status(ctx context.Context) (*v1alpha1.EventRetentionStatus, error)
+}
+
+// RetentionHandler serves the event retention status. Measuring the
+// partitions reads the database catalog, so the route should be limited
+// to administrators.
+func RetentionHandler(retention RetentionStatus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, err := retention.Status(r.Context())
+		if err != nil {
+			httpapi.WriteError(w, err, http.StatusInternalServerError)
+			return
+		}
+		httpapi.WriteJSON(w, http.StatusOK, status)
+	}
+}
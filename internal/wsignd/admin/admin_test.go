@@ -0,0 +1,75 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+)
+
+func TestConfigHandler(t *testing.T) {
+	t.Setenv("WSIGN_AUTH_TOKEN_KEY", "hunter2")
+	t.Setenv("WSIGN_SERVER_PORT", "9090")
+	cfg, err := config.Load()
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", ConfigPath, nil)
+	w := httptest.NewRecorder()
+	ConfigHandler(EffectiveConfig(cfg, "1.2.3")).ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "hunter2")
+
+	var doc v1alpha1.EffectiveConfig
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&doc))
+	assert.Equal(t, "EffectiveConfig", doc.Kind)
+	assert.Equal(t, "1.2.3", doc.ServerVersion)
+	assert.Contains(t, doc.Settings, v1alpha1.ConfigSetting{
+		Name: "WSIGN_AUTH_TOKEN_KEY", Value: config.Redacted, Source: v1alpha1.ConfigSourceEnv, Secret: true,
+	})
+	assert.Contains(t, doc.Settings, v1alpha1.ConfigSetting{
+		Name: "WSIGN_SERVER_PORT", Value: "9090", Source: v1alpha1.ConfigSourceEnv,
+	})
+	assert.Contains(t, doc.Settings, v1alpha1.ConfigSetting{
+		Name: "WSIGN_SERVER_HOST", Value: "0.0.0.0", Source: v1alpha1.ConfigSourceDefault,
+	})
+}
+
+type retentionStub struct {
+	status *v1alpha1.EventRetentionStatus
+	err    error
+}
+
+func (s retentionStub) Status(ctx context.Context) (*v1alpha1.EventRetentionStatus, error) {
+	return s.status, s.err
+}
+
+func TestRetentionHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", RetentionPath, nil)
+	w := httptest.NewRecorder()
+	RetentionHandler(retentionStub{status: &v1alpha1.EventRetentionStatus{
+		TypeMeta:    v1alpha1.TypeMeta{Kind: "EventRetentionStatus", APIVersion: "v1alpha1"},
+		Retention:   "720h0m0s",
+		Partitioned: true,
+		Tables:      []v1alpha1.EventTable{{Name: "content_events_default", Default: true, Bytes: 8192}},
+	}}).ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var status v1alpha1.EventRetentionStatus
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&status))
+	assert.Equal(t, "720h0m0s", status.Retention)
+	require.Len(t, status.Tables, 1)
+	assert.True(t, status.Tables[0].Default)
+
+	w = httptest.NewRecorder()
+	RetentionHandler(retentionStub{err: errors.New("connection refused")}).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "connection refused")
+}
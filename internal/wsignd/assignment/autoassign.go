@@ -0,0 +1,122 @@
+package assignment
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// SuppressAutoAssignProperty is the display property that, set to true,
+// keeps auto-assignment rules from assigning the display content
+const SuppressAutoAssignProperty = "suppressAutoAssign"
+
+// Rule assigns a content source to the displays Match selects when they
+// are activated, such as the "menus" source to every display in zone
+// "menu" whatever its site
+type Rule struct {
+	Match  v1alpha1.DisplaySelector
+	Source string
+}
+
+// Sources looks up the content sources rules assign. The content service
+// satisfies it.
+type Sources interface {
+	// GetContent retrieves a content source by name
+	GetContent(ctx context.Context, name string) (*v1alpha1.ContentSource, error)
+}
+
+// AutoAssigner assigns content to newly activated displays by convention,
+// so that they show something sensible without a separate assignment step
+type AutoAssigner struct {
+	service Service
+	sources Sources
+	rules   []Rule
+}
+
+// NewAutoAssigner creates an auto-assigner that applies rules, in order,
+// through service
+func NewAutoAssigner(service Service, sources Sources, rules []Rule) *AutoAssigner {
+	return &AutoAssigner{service: service, sources: sources, rules: rules}
+}
+
+// Match returns the first rule selecting a display at location with
+// properties, or nil when none does
+func (a *AutoAssigner) Match(location v1alpha1.DisplayLocation, properties map[string]string) *Rule {
+	for i := range a.rules {
+		if a.rules[i].Match.Matches(location, properties) {
+			return &a.rules[i]
+		}
+	}
+	return nil
+}
+
+// AutoAssign assigns the display with id at location with properties the
+// source of the first rule selecting it, naming the display, and returns
+// the assignment. It returns nil when no rule applies or a concurrent
+// activation of the same display created the assignment first. A display
+// keeps the auto-created assignment it already has for that source, so
+// activating it again changes nothing; one it has for another source,
+// because the display moved or the rules changed, is replaced. Nothing is
+// assigned to displays with SuppressAutoAssignProperty set to true, which
+// is how an operator keeps a deleted auto-created assignment from coming
+// back.
+func (a *AutoAssigner) AutoAssign(ctx context.Context, id uuid.UUID, location v1alpha1.DisplayLocation, properties map[string]string) (*v1alpha1.ContentAssignment, error) {
+	const op = "AutoAssigner.AutoAssign"
+
+	if suppressed, _ := strconv.ParseBool(properties[SuppressAutoAssignProperty]); suppressed {
+		return nil, nil
+	}
+	rule := a.Match(location, properties)
+	if rule == nil {
+		return nil, nil
+	}
+
+	named, err := a.service.List(ctx, Filter{Selector: v1alpha1.DisplaySelector{DisplayRef: id.String()}})
+	if err != nil {
+		return nil, err
+	}
+	for i := range named {
+		existing := &named[i]
+		if !existing.AutoCreated {
+			continue
+		}
+		if existing.Source == rule.Source {
+			return existing, nil
+		}
+		if err := a.service.Delete(ctx, existing.ID); err != nil && !werrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	source, err := a.sources.GetContent(ctx, rule.Source)
+	if err != nil {
+		if werrors.IsNotFound(err) {
+			return nil, werrors.NewError("SOURCE_NOT_FOUND",
+				fmt.Sprintf("content source not found: %s", rule.Source), op, err)
+		}
+		return nil, werrors.NewError("LOOKUP_FAILED", "Failed to look up content source", op, err)
+	}
+
+	created, err := a.service.Create(ctx, &v1alpha1.ContentAssignment{
+		// One name per display, so concurrent activations create it once
+		ObjectMeta:      v1alpha1.ObjectMeta{Name: autoAssignmentName(id)},
+		Source:          source.Name,
+		DisplaySelector: v1alpha1.DisplaySelector{DisplayRef: id.String()},
+		ContentURL:      source.Spec.URL,
+		AutoCreated:     true,
+	})
+	if werrors.IsConflict(err) {
+		return nil, nil
+	}
+	return created, err
+}
+
+// autoAssignmentName names the auto-created assignment of a display
+func autoAssignmentName(id uuid.UUID) string {
+	return "auto-" + id.String()
+}
@@ -0,0 +1,159 @@
+package assignment_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/assignment"
+	"github.com/wrale/wrale-signage/internal/wsignd/assignment/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// sources is a content source lookup holding sources by name
+type sources map[string]string
+
+func (s sources) GetContent(ctx context.Context, name string) (*v1alpha1.ContentSource, error) {
+	url, ok := s[name]
+	if !ok {
+		return nil, werrors.NewError("NOT_FOUND", "content source not found", "sources.GetContent", werrors.ErrNotFound)
+	}
+	return &v1alpha1.ContentSource{
+		ObjectMeta: v1alpha1.ObjectMeta{Name: name},
+		Spec:       v1alpha1.ContentSourceSpec{URL: url},
+	}, nil
+}
+
+func TestAutoAssigner(t *testing.T) {
+	ctx := context.Background()
+	lookup := &displays{}
+	service := assignment.NewService(memory.NewRepository(), assignment.WithDisplays(lookup))
+	content := sources{
+		"menus":         "https://example.com/menus",
+		"hq-menus":      "https://example.com/hq-menus",
+		"portrait-feed": "https://example.com/portrait",
+	}
+	assigner := assignment.NewAutoAssigner(service, content, []assignment.Rule{
+		{Match: v1alpha1.DisplaySelector{SiteID: "hq", Zone: "menu"}, Source: "hq-menus"},
+		{Match: v1alpha1.DisplaySelector{Zone: "menu"}, Source: "menus"},
+		{Match: v1alpha1.DisplaySelector{MatchProperties: map[string]string{"orientation": "portrait"}}, Source: "portrait-feed"},
+		{Match: v1alpha1.DisplaySelector{Zone: "lobby"}, Source: "missing"},
+	})
+
+	at := func(site, zone string) v1alpha1.DisplayLocation {
+		return v1alpha1.DisplayLocation{SiteID: site, Zone: zone, Position: "main"}
+	}
+	autoCreated := func(d *display.Display) []v1alpha1.ContentAssignment {
+		named, err := service.List(ctx, assignment.Filter{Selector: v1alpha1.DisplaySelector{DisplayRef: d.ID.String()}})
+		require.NoError(t, err)
+		var auto []v1alpha1.ContentAssignment
+		for _, a := range named {
+			if a.AutoCreated {
+				auto = append(auto, a)
+			}
+		}
+		return auto
+	}
+
+	t.Run("rules are tried in order and the first match wins", func(t *testing.T) {
+		for _, tc := range []struct {
+			location   v1alpha1.DisplayLocation
+			properties map[string]string
+			want       string
+		}{
+			{at("hq", "menu"), nil, "hq-menus"},
+			{at("annex", "menu"), nil, "menus"},
+			{at("annex", "menu"), map[string]string{"orientation": "portrait"}, "menus"},
+			{at("annex", "hall"), map[string]string{"orientation": "portrait"}, "portrait-feed"},
+		} {
+			d := lookup.add(uuid.NewString(), display.StateActive)
+			a, err := assigner.AutoAssign(ctx, d.ID, tc.location, tc.properties)
+			require.NoError(t, err)
+			require.NotNil(t, a, tc.want)
+			assert.Equal(t, tc.want, a.Source)
+			assert.Equal(t, content[tc.want], a.ContentURL)
+			assert.Equal(t, d.ID.String(), a.DisplaySelector.DisplayRef)
+			assert.True(t, a.AutoCreated)
+		}
+	})
+
+	t.Run("displays no rule matches are left alone", func(t *testing.T) {
+		d := lookup.add("annex-hall", display.StateActive)
+		a, err := assigner.AutoAssign(ctx, d.ID, at("annex", "hall"), nil)
+		require.NoError(t, err)
+		assert.Nil(t, a)
+		assert.Empty(t, autoCreated(d))
+	})
+
+	t.Run("activating again changes nothing", func(t *testing.T) {
+		d := lookup.add("annex-menu", display.StateActive)
+		first, err := assigner.AutoAssign(ctx, d.ID, at("annex", "menu"), nil)
+		require.NoError(t, err)
+		again, err := assigner.AutoAssign(ctx, d.ID, at("annex", "menu"), nil)
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, again.ID)
+		assert.Len(t, autoCreated(d), 1)
+	})
+
+	t.Run("a display that moved is assigned its new zone's content", func(t *testing.T) {
+		d := lookup.add("moved", display.StateActive)
+		_, err := assigner.AutoAssign(ctx, d.ID, at("annex", "menu"), nil)
+		require.NoError(t, err)
+		_, err = assigner.AutoAssign(ctx, d.ID, at("hq", "menu"), nil)
+		require.NoError(t, err)
+
+		auto := autoCreated(d)
+		require.Len(t, auto, 1)
+		assert.Equal(t, "hq-menus", auto[0].Source)
+	})
+
+	t.Run("deleted assignments come back unless suppressed", func(t *testing.T) {
+		d := lookup.add("suppressed", display.StateActive)
+		a, err := assigner.AutoAssign(ctx, d.ID, at("annex", "menu"), nil)
+		require.NoError(t, err)
+		require.NoError(t, service.Delete(ctx, a.ID))
+
+		suppressed := map[string]string{assignment.SuppressAutoAssignProperty: "true"}
+		a, err = assigner.AutoAssign(ctx, d.ID, at("annex", "menu"), suppressed)
+		require.NoError(t, err)
+		assert.Nil(t, a)
+		assert.Empty(t, autoCreated(d))
+
+		a, err = assigner.AutoAssign(ctx, d.ID, at("annex", "menu"), nil)
+		require.NoError(t, err)
+		require.NotNil(t, a)
+		assert.Len(t, autoCreated(d), 1)
+	})
+
+	t.Run("missing sources are reported", func(t *testing.T) {
+		d := lookup.add("lobby", display.StateActive)
+		_, err := assigner.AutoAssign(ctx, d.ID, at("hq", "lobby"), nil)
+		assert.True(t, werrors.IsNotFound(err), "%v", err)
+		assert.Empty(t, autoCreated(d))
+	})
+
+	t.Run("any other assignment outranks an auto-created one", func(t *testing.T) {
+		d := lookup.add("outranked", display.StateActive)
+		location := at("annex", "menu")
+		auto, err := assigner.AutoAssign(ctx, d.ID, location, nil)
+		require.NoError(t, err)
+
+		resolved, err := service.ForDisplay(ctx, d.ID, location, nil)
+		require.NoError(t, err)
+		assert.Equal(t, auto.ID, resolved.ID)
+
+		site, err := service.Create(ctx, &v1alpha1.ContentAssignment{
+			DisplaySelector: v1alpha1.DisplaySelector{SiteID: "annex"},
+			ContentURL:      "https://example.com/annex",
+		})
+		require.NoError(t, err)
+		resolved, err = service.ForDisplay(ctx, d.ID, location, nil)
+		require.NoError(t, err)
+		assert.Equal(t, site.ID, resolved.ID, "the site-wide assignment beats the display's auto-created one")
+	})
+}
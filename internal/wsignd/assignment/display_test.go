@@ -0,0 +1,170 @@
+package assignment_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/assignment"
+	"github.com/wrale/wrale-signage/internal/wsignd/assignment/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// displays is a display lookup that can rename and delete displays
+type displays struct {
+	mu   sync.Mutex
+	byID map[uuid.UUID]*display.Display
+}
+
+func (d *displays) add(name string, state display.State) *display.Display {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.byID == nil {
+		d.byID = make(map[uuid.UUID]*display.Display)
+	}
+	disp := &display.Display{
+		ID:       uuid.New(),
+		Name:     name,
+		State:    state,
+		Location: display.Location{SiteID: "hq", Zone: "reception", Position: "desk"},
+	}
+	d.byID[disp.ID] = disp
+	return disp
+}
+
+func (d *displays) Get(ctx context.Context, id uuid.UUID) (*display.Display, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if disp, ok := d.byID[id]; ok {
+		c := *disp
+		return &c, nil
+	}
+	return nil, werrors.NewError("NOT_FOUND", "display not found", "displays.Get", werrors.ErrNotFound)
+}
+
+func (d *displays) GetByName(ctx context.Context, name string) (*display.Display, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, disp := range d.byID {
+		if disp.Name == name {
+			c := *disp
+			return &c, nil
+		}
+	}
+	return nil, werrors.NewError("NOT_FOUND", "display not found", "displays.GetByName", werrors.ErrNotFound)
+}
+
+func TestService_DisplayRef(t *testing.T) {
+	ctx := context.Background()
+	lookup := &displays{}
+	ipad := lookup.add("reception-ipad", display.StateActive)
+	kiosk := lookup.add("reception-kiosk", display.StateActive)
+	service := assignment.NewService(memory.NewRepository(), assignment.WithDisplays(lookup))
+
+	create := func(name string, selector v1alpha1.DisplaySelector) (*v1alpha1.ContentAssignment, error) {
+		return service.Create(ctx, &v1alpha1.ContentAssignment{
+			ObjectMeta:      v1alpha1.ObjectMeta{Name: name},
+			DisplaySelector: selector,
+			ContentURL:      "https://example.com/" + name,
+		})
+	}
+	resolve := func(d *display.Display) string {
+		location := v1alpha1.DisplayLocation{SiteID: d.Location.SiteID, Zone: d.Location.Zone, Position: d.Location.Position}
+		a, err := service.ForDisplay(ctx, d.ID, location, nil)
+		require.NoError(t, err)
+		if a == nil {
+			return ""
+		}
+		return a.Name
+	}
+
+	visitors, err := create("visitors", v1alpha1.DisplaySelector{DisplayRef: "reception-ipad"})
+	require.NoError(t, err)
+	assert.Equal(t, ipad.ID.String(), visitors.DisplaySelector.DisplayRef, "the display is stored by ID")
+	require.NotNil(t, visitors.Status)
+	assert.Equal(t, "reception-ipad", visitors.Status.DisplayName)
+	assert.Empty(t, visitors.Status.Warnings)
+
+	// An assignment naming the display beats even a newer one for its
+	// exact position
+	_, err = create("desk", v1alpha1.DisplaySelector{SiteID: "hq", Zone: "reception", Position: "desk"})
+	require.NoError(t, err)
+	assert.Equal(t, "visitors", resolve(ipad))
+	assert.Equal(t, "desk", resolve(kiosk), "other displays at the position are unaffected")
+
+	t.Run("invalid references are refused", func(t *testing.T) {
+		_, err := create("nowhere", v1alpha1.DisplaySelector{DisplayRef: "lobby-tv"})
+		assert.ErrorIs(t, err, werrors.ErrInvalidInput)
+		var apiErr *werrors.Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, "DISPLAY_NOT_FOUND", apiErr.Code)
+
+		_, err = create("mixed", v1alpha1.DisplaySelector{DisplayRef: "reception-ipad", SiteID: "hq"})
+		assert.ErrorIs(t, err, werrors.ErrInvalidInput)
+
+		_, err = assignment.NewService(memory.NewRepository()).Create(ctx, &v1alpha1.ContentAssignment{
+			DisplaySelector: v1alpha1.DisplaySelector{DisplayRef: "reception-ipad"},
+			ContentURL:      "https://example.com/visitors",
+		})
+		assert.ErrorIs(t, err, werrors.ErrInvalidInput, "without displays nothing can be named")
+	})
+
+	t.Run("disabled displays are assigned with a warning", func(t *testing.T) {
+		spare := lookup.add("reception-spare", display.StateDisabled)
+		a, err := create("spare", v1alpha1.DisplaySelector{DisplayRef: spare.ID.String()})
+		require.NoError(t, err)
+		require.NotNil(t, a.Status)
+		require.Len(t, a.Status.Warnings, 1)
+		assert.Contains(t, a.Status.Warnings[0], "disabled")
+	})
+
+	t.Run("renaming keeps the assignment", func(t *testing.T) {
+		lookup.mu.Lock()
+		lookup.byID[ipad.ID].Name = "front-desk-ipad"
+		lookup.mu.Unlock()
+
+		assert.Equal(t, "visitors", resolve(ipad))
+		list, err := service.List(ctx, assignment.Filter{Selector: v1alpha1.DisplaySelector{DisplayRef: "front-desk-ipad"}})
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		assert.Equal(t, "visitors", list[0].Name)
+		assert.Equal(t, "front-desk-ipad", list[0].Status.DisplayName)
+
+		list, err = service.List(ctx, assignment.Filter{Selector: v1alpha1.DisplaySelector{DisplayRef: "reception-ipad"}})
+		require.NoError(t, err)
+		assert.Empty(t, list, "the old name no longer finds it")
+	})
+
+	t.Run("deleting the display orphans the assignment", func(t *testing.T) {
+		lookup.mu.Lock()
+		delete(lookup.byID, ipad.ID)
+		lookup.mu.Unlock()
+
+		assert.Equal(t, "desk", resolve(kiosk))
+		list, err := service.List(ctx, assignment.Filter{})
+		require.NoError(t, err)
+		var orphaned []string
+		for _, a := range list {
+			if a.Status != nil && a.Status.Orphaned {
+				orphaned = append(orphaned, a.Name)
+				require.Len(t, a.Status.Warnings, 1)
+				assert.Contains(t, a.Status.Warnings[0], ipad.ID.String())
+			}
+		}
+		assert.Equal(t, []string{"visitors"}, orphaned)
+
+		got, err := service.Get(ctx, visitors.ID)
+		require.NoError(t, err)
+		assert.True(t, got.Status.Orphaned)
+
+		list, err = service.List(ctx, assignment.Filter{Selector: v1alpha1.DisplaySelector{DisplayRef: ipad.ID.String()}})
+		require.NoError(t, err)
+		assert.Len(t, list, 1, "orphans are still found by the display's ID")
+	})
+}
@@ -0,0 +1,26 @@
+package assignment
+
+import "github.com/wrale/wrale-signage/api/types/v1alpha1"
+
+// Matches reports whether an assignment passes the filter. Filter
+// properties must all appear in the assignment's selector; the selector
+// may match further properties.
+func (f Filter) Matches(a *v1alpha1.ContentAssignment) bool {
+	if !matchField(f.Source, a.Source) ||
+		!matchField(f.Selector.DisplayRef, a.DisplaySelector.DisplayRef) ||
+		!matchField(f.Selector.SiteID, a.DisplaySelector.SiteID) ||
+		!matchField(f.Selector.Zone, a.DisplaySelector.Zone) ||
+		!matchField(f.Selector.Position, a.DisplaySelector.Position) {
+		return false
+	}
+	for key, value := range f.Selector.MatchProperties {
+		if got, ok := a.DisplaySelector.MatchProperties[key]; !ok || got != value {
+			return false
+		}
+	}
+	return true
+}
+
+func matchField(want, got string) bool {
+	return want == "" || want == got
+}
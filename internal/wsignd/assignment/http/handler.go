@@ -0,0 +1,126 @@
+// Package http serves the content assignment API
+package http
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/assignment"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+)
+
+// Handler serves assignment requests
+type Handler struct {
+	service assignment.Service
+	logger  *slog.Logger
+}
+
+// NewHandler creates an assignment handler
+func NewHandler(service assignment.Service, logger *slog.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// CreateAssignment handles assignment creation
+func (h *Handler) CreateAssignment(w http.ResponseWriter, r *http.Request) {
+	var a v1alpha1.ContentAssignment
+	if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+		httpapi.Error(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	// Only the server's auto-assignment rules create these
+	a.AutoCreated = false
+
+	created, err := h.service.Create(r.Context(), &a)
+	if err != nil {
+		h.logger.Error("failed to create assignment",
+			"error", err,
+			"source", a.Source,
+		)
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusCreated, created)
+}
+
+// ListAssignments handles assignment listing. ?source=, ?siteId=, ?zone= and
+// ?position= each restrict the list to exact matches, and ?displayRef= to
+// the assignments naming a display, by its name or ID. Repeated
+// ?matchProperty=key=value parameters keep the assignments whose selectors
+// match at least those properties.
+func (h *Handler) ListAssignments(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	properties, err := v1alpha1.ParseMatchProperties(query[v1alpha1.MatchPropertyParam])
+	if err != nil {
+		httpapi.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter := assignment.Filter{
+		Source: query.Get("source"),
+		Selector: v1alpha1.DisplaySelector{
+			DisplayRef:      query.Get("displayRef"),
+			SiteID:          query.Get("siteId"),
+			Zone:            query.Get("zone"),
+			Position:        query.Get("position"),
+			MatchProperties: properties,
+		},
+	}
+
+	assignments, err := h.service.List(r.Context(), filter)
+	if err != nil {
+		h.logger.Error("failed to list assignments", "error", err)
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, assignments)
+}
+
+// GetAssignment handles retrieving a single assignment
+func (h *Handler) GetAssignment(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		httpapi.Error(w, http.StatusBadRequest, "invalid assignment ID")
+		return
+	}
+
+	a, err := h.service.Get(r.Context(), id)
+	if err != nil {
+		h.logger.Error("failed to get assignment",
+			"error", err,
+			"id", id,
+		)
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, a)
+}
+
+// DeleteAssignment handles assignment removal
+func (h *Handler) DeleteAssignment(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		httpapi.Error(w, http.StatusBadRequest, "invalid assignment ID")
+		return
+	}
+
+	if err := h.service.Delete(r.Context(), id); err != nil {
+		h.logger.Error("failed to delete assignment",
+			"error", err,
+			"id", id,
+		)
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,31 @@
+package http
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+)
+
+// NewRouter mounts the assignment routes. Reading assignments requires the
+// content:read scope and changing them content:write; a nil guard leaves
+// the routes open.
+func NewRouter(h *Handler, guard operator.Guard) chi.Router {
+	r := chi.NewRouter()
+
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.RequestID)
+
+	read := guard.Require(operator.ScopeContentRead)
+	write := guard.Require(operator.ScopeContentWrite)
+
+	r.With(write).Post("/", h.CreateAssignment)
+	r.With(read).Get("/", h.ListAssignments)
+	r.Route("/{id}", func(r chi.Router) {
+		r.With(read).Get("/", h.GetAssignment)
+		r.With(write).Delete("/", h.DeleteAssignment)
+	})
+
+	return r
+}
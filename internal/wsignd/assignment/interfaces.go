@@ -0,0 +1,76 @@
+// Package assignment manages content assignments, which point the displays
+// matching a selector, or one display named outright, at a content URL
+package assignment
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+)
+
+// Filter narrows an assignment listing. Empty fields match everything; set
+// fields must match exactly.
+type Filter struct {
+	// Source restricts the list to assignments created from this content source
+	Source string
+	// Selector restricts the list to assignments whose selector has these values
+	Selector v1alpha1.DisplaySelector
+}
+
+// Service defines the assignment service interface
+type Service interface {
+	// Create validates and stores a new assignment
+	Create(ctx context.Context, a *v1alpha1.ContentAssignment) (*v1alpha1.ContentAssignment, error)
+	// Get retrieves an assignment by ID
+	Get(ctx context.Context, id uuid.UUID) (*v1alpha1.ContentAssignment, error)
+	// List retrieves the assignments matching filter, oldest first
+	List(ctx context.Context, filter Filter) ([]v1alpha1.ContentAssignment, error)
+	// Delete removes an assignment
+	Delete(ctx context.Context, id uuid.UUID) error
+	// ForDisplay returns the assignment deciding what the display with id
+	// at location with properties shows now, as Resolve picks it, or nil
+	// when none selects the display
+	ForDisplay(ctx context.Context, id uuid.UUID, location v1alpha1.DisplayLocation, properties map[string]string) (*v1alpha1.ContentAssignment, error)
+	// ExplainForDisplay describes each assignment ForDisplay considers for
+	// the display at the time at and what became of it, as Explain does.
+	// The zero time means now.
+	ExplainForDisplay(ctx context.Context, id uuid.UUID, location v1alpha1.DisplayLocation, properties map[string]string, at time.Time) ([]v1alpha1.ResolutionStep, error)
+}
+
+// Displays looks up the displays assignments name. The display service
+// satisfies it.
+type Displays interface {
+	// Get retrieves a display by ID
+	Get(ctx context.Context, id uuid.UUID) (*display.Display, error)
+	// GetByName retrieves a display by name
+	GetByName(ctx context.Context, name string) (*display.Display, error)
+}
+
+// Repository defines persistence for assignments
+type Repository interface {
+	// Create stores a new assignment, setting its ID if unset and its
+	// timestamps
+	Create(ctx context.Context, a *v1alpha1.ContentAssignment) error
+	// Get retrieves an assignment by ID
+	Get(ctx context.Context, id uuid.UUID) (*v1alpha1.ContentAssignment, error)
+	// List retrieves the assignments matching filter ordered by creation time,
+	// ties broken by name
+	List(ctx context.Context, filter Filter) ([]v1alpha1.ContentAssignment, error)
+	// Delete removes an assignment by ID
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// SourceLocker keeps content sources from being removed while references
+// to them are added. Stores that keep assignments in the same database as
+// sources rely on a foreign key instead; the others are handed one of
+// these.
+type SourceLocker interface {
+	// WithSource runs fn while the named content source cannot be removed.
+	// It fails with a not found error, without running fn, when there is
+	// no such source.
+	WithSource(ctx context.Context, name string, fn func() error) error
+}
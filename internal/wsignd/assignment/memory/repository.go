@@ -0,0 +1,222 @@
+// Package memory implements assignment persistence in process memory for
+// demo mode and tests
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/assignment"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// Repository implements assignment.Repository in memory. It also
+// implements content.SourceReferenceStore, so that the in-memory content
+// store can refuse to remove sources that are still assigned.
+type Repository struct {
+	mu          sync.RWMutex
+	assignments map[uuid.UUID]v1alpha1.ContentAssignment
+
+	// sources, when set, holds back removal of the source an assignment
+	// names while it is created, and refuses sources that do not exist
+	sources assignment.SourceLocker
+
+	// now returns the current time for created/updated timestamps
+	now func() time.Time
+}
+
+// NewRepository creates an empty in-memory assignment repository
+func NewRepository() *Repository {
+	return &Repository{
+		assignments: make(map[uuid.UUID]v1alpha1.ContentAssignment),
+		now:         time.Now,
+	}
+}
+
+// RequireSources makes Create refuse assignments naming a content source
+// that sources does not have, as the PostgreSQL repository's foreign key
+// does
+func (r *Repository) RequireSources(sources assignment.SourceLocker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sources = sources
+}
+
+// Create stores a new assignment, assigning an ID if it has none
+func (r *Repository) Create(ctx context.Context, a *v1alpha1.ContentAssignment) error {
+	r.mu.RLock()
+	sources := r.sources
+	r.mu.RUnlock()
+
+	// The source's lock is taken before ours, in the same order as when
+	// the source is removed
+	if sources != nil && a.Source != "" {
+		return sources.WithSource(ctx, a.Source, func() error {
+			return r.create(a)
+		})
+	}
+	return r.create(a)
+}
+
+func (r *Repository) create(a *v1alpha1.ContentAssignment) error {
+	const op = "AssignmentRepository.Create"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	for id, other := range r.assignments {
+		if id == a.ID || other.Name == a.Name {
+			return werrors.NewError("CONFLICT", "resource already exists", op, werrors.ErrConflict)
+		}
+	}
+
+	now := r.now()
+	a.CreatedAt = now
+	a.UpdatedAt = now
+	r.assignments[a.ID] = copyAssignment(a)
+	return nil
+}
+
+// Get retrieves an assignment by ID
+func (r *Repository) Get(ctx context.Context, id uuid.UUID) (*v1alpha1.ContentAssignment, error) {
+	const op = "AssignmentRepository.Get"
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	a, ok := r.assignments[id]
+	if !ok {
+		return nil, notFound(op)
+	}
+	c := copyAssignment(&a)
+	return &c, nil
+}
+
+// List retrieves the assignments matching filter, oldest first
+func (r *Repository) List(ctx context.Context, filter assignment.Filter) ([]v1alpha1.ContentAssignment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := []v1alpha1.ContentAssignment{}
+	for _, a := range r.assignments {
+		if filter.Matches(&a) {
+			out = append(out, copyAssignment(&a))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out, nil
+}
+
+// Delete removes an assignment by ID
+func (r *Repository) Delete(ctx context.Context, id uuid.UUID) error {
+	const op = "AssignmentRepository.Delete"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.assignments[id]; !ok {
+		return notFound(op)
+	}
+	delete(r.assignments, id)
+	return nil
+}
+
+// SourceReferences lists the assignments made from the named content
+// source, oldest first
+func (r *Repository) SourceReferences(ctx context.Context, name string) ([]v1alpha1.ResourceReference, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return references(r.fromSource(name)), nil
+}
+
+// RemoveSourceReferences deletes the assignments made from the named
+// content source and returns them, oldest first
+func (r *Repository) RemoveSourceReferences(ctx context.Context, name string) ([]v1alpha1.ResourceReference, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	removed := r.fromSource(name)
+	for _, a := range removed {
+		delete(r.assignments, a.ID)
+	}
+	return references(removed), nil
+}
+
+// fromSource returns the assignments made from the named source, oldest
+// first. The caller must hold the lock.
+func (r *Repository) fromSource(name string) []v1alpha1.ContentAssignment {
+	var out []v1alpha1.ContentAssignment
+	for _, a := range r.assignments {
+		if a.Source == name {
+			out = append(out, a)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// references names assignments as references to their source
+func references(assignments []v1alpha1.ContentAssignment) []v1alpha1.ResourceReference {
+	refs := make([]v1alpha1.ResourceReference, len(assignments))
+	for i, a := range assignments {
+		refs[i] = v1alpha1.ResourceReference{Kind: "ContentAssignment", Name: a.Name}
+	}
+	return refs
+}
+
+// copyAssignment returns a copy that shares no pointers with a
+func copyAssignment(a *v1alpha1.ContentAssignment) v1alpha1.ContentAssignment {
+	c := *a
+	c.TypeMeta = v1alpha1.TypeMeta{Kind: "ContentAssignment", APIVersion: "v1alpha1"}
+	// The status describes the display at the time of reading
+	c.Status = nil
+	if a.DisplaySelector.MatchProperties != nil {
+		c.DisplaySelector.MatchProperties = make(map[string]string, len(a.DisplaySelector.MatchProperties))
+		for k, v := range a.DisplaySelector.MatchProperties {
+			c.DisplaySelector.MatchProperties[k] = v
+		}
+	}
+	if a.ValidFrom != nil {
+		t := *a.ValidFrom
+		c.ValidFrom = &t
+	}
+	if a.ValidUntil != nil {
+		t := *a.ValidUntil
+		c.ValidUntil = &t
+	}
+	if a.Schedule != nil {
+		schedule := *a.Schedule
+		schedule.Windows = make([]v1alpha1.ScheduleWindow, len(a.Schedule.Windows))
+		for i, w := range a.Schedule.Windows {
+			schedule.Windows[i] = w
+			schedule.Windows[i].DaysOfWeek = append([]time.Weekday(nil), w.DaysOfWeek...)
+		}
+		c.Schedule = &schedule
+	}
+	return c
+}
+
+// notFound builds the error the PostgreSQL repository returns for a
+// missing row
+func notFound(op string) error {
+	return werrors.NewError("NOT_FOUND", "resource not found", op, werrors.ErrNotFound)
+}
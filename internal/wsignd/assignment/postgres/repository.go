@@ -0,0 +1,273 @@
+// Package postgres implements the assignment repository using PostgreSQL
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/assignment"
+	"github.com/wrale/wrale-signage/internal/wsignd/database"
+)
+
+const assignmentColumns = `
+	id, name, COALESCE(source, ''), content_url,
+	site_id, zone, position, match_properties, display_id,
+	valid_from, valid_until, schedule, auto_created,
+	created_at, updated_at`
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanAssignment reads a content_assignments row selected with assignmentColumns
+func scanAssignment(row rowScanner) (*v1alpha1.ContentAssignment, error) {
+	var (
+		a                     v1alpha1.ContentAssignment
+		matchJSON, schedule   []byte
+		validFrom, validUntil sql.NullTime
+		displayID             uuid.NullUUID
+	)
+
+	err := row.Scan(
+		&a.ID,
+		&a.Name,
+		&a.Source,
+		&a.ContentURL,
+		&a.DisplaySelector.SiteID,
+		&a.DisplaySelector.Zone,
+		&a.DisplaySelector.Position,
+		&matchJSON,
+		&displayID,
+		&validFrom,
+		&validUntil,
+		&schedule,
+		&a.AutoCreated,
+		&a.CreatedAt,
+		&a.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	a.TypeMeta = v1alpha1.TypeMeta{Kind: "ContentAssignment", APIVersion: "v1alpha1"}
+	if err := json.Unmarshal(matchJSON, &a.DisplaySelector.MatchProperties); err != nil {
+		return nil, fmt.Errorf("error unmarshaling match properties: %w", err)
+	}
+	if len(a.DisplaySelector.MatchProperties) == 0 {
+		a.DisplaySelector.MatchProperties = nil
+	}
+	if displayID.Valid {
+		a.DisplaySelector.DisplayRef = displayID.UUID.String()
+	}
+	if validFrom.Valid {
+		a.ValidFrom = &validFrom.Time
+	}
+	if validUntil.Valid {
+		a.ValidUntil = &validUntil.Time
+	}
+	if schedule != nil {
+		if err := json.Unmarshal(schedule, &a.Schedule); err != nil {
+			return nil, fmt.Errorf("error unmarshaling schedule: %w", err)
+		}
+	}
+
+	return &a, nil
+}
+
+// Repository implements assignment.Repository using PostgreSQL. Listings,
+// which content resolution is built on, are read from the reader
+// connection; when that is a replica a new or deleted assignment can take
+// as long as the replication lag to reach displays.
+type Repository struct {
+	db     *sql.DB
+	reader *sql.DB
+}
+
+// NewRepository creates a PostgreSQL assignment repository
+func NewRepository(db *sql.DB, opts ...database.RepositoryOption) *Repository {
+	conns := database.NewConnections(db, opts...)
+	return &Repository{db: conns.Primary, reader: conns.Reader}
+}
+
+// Create inserts a new assignment. An assignment naming a content source
+// that does not exist fails the source foreign key with a not found error.
+func (r *Repository) Create(ctx context.Context, a *v1alpha1.ContentAssignment) error {
+	const op = "AssignmentRepository.Create"
+
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+
+	matchJSON, err := propertiesJSON(a.DisplaySelector.MatchProperties)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+
+	schedule, err := scheduleJSON(a.Schedule)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+
+	displayID, err := displayRef(a.DisplaySelector.DisplayRef)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+
+	err = r.db.QueryRowContext(ctx, `
+		INSERT INTO content_assignments (
+			id, name, source, content_url,
+			site_id, zone, position, match_properties, display_id,
+			valid_from, valid_until, schedule, auto_created
+		) VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING created_at, updated_at
+	`,
+		a.ID,
+		a.Name,
+		a.Source,
+		a.ContentURL,
+		a.DisplaySelector.SiteID,
+		a.DisplaySelector.Zone,
+		a.DisplaySelector.Position,
+		matchJSON,
+		displayID,
+		nullTime(a.ValidFrom),
+		nullTime(a.ValidUntil),
+		schedule,
+		a.AutoCreated,
+	).Scan(&a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+
+	return nil
+}
+
+// Get retrieves an assignment by ID
+func (r *Repository) Get(ctx context.Context, id uuid.UUID) (*v1alpha1.ContentAssignment, error) {
+	const op = "AssignmentRepository.Get"
+
+	row := r.db.QueryRowContext(ctx,
+		"SELECT "+assignmentColumns+" FROM content_assignments WHERE id = $1", id)
+	a, err := scanAssignment(row)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	return a, nil
+}
+
+// List retrieves the assignments matching filter, oldest first. Empty filter
+// fields match every row.
+func (r *Repository) List(ctx context.Context, filter assignment.Filter) ([]v1alpha1.ContentAssignment, error) {
+	const op = "AssignmentRepository.List"
+
+	matchJSON, err := propertiesJSON(filter.Selector.MatchProperties)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	displayID, err := displayRef(filter.Selector.DisplayRef)
+	if err != nil {
+		// No assignment names a display by anything but its ID
+		return []v1alpha1.ContentAssignment{}, nil
+	}
+
+	rows, err := r.reader.QueryContext(ctx, `
+		SELECT `+assignmentColumns+`
+		FROM content_assignments
+		WHERE ($1 = '' OR source = $1)
+			AND ($2 = '' OR site_id = $2)
+			AND ($3 = '' OR zone = $3)
+			AND ($4 = '' OR position = $4)
+			AND match_properties @> $5::jsonb
+			AND ($6::uuid IS NULL OR display_id = $6)
+		ORDER BY created_at, name
+	`,
+		filter.Source,
+		filter.Selector.SiteID,
+		filter.Selector.Zone,
+		filter.Selector.Position,
+		matchJSON,
+		displayID,
+	)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+	defer rows.Close()
+
+	assignments := []v1alpha1.ContentAssignment{}
+	for rows.Next() {
+		a, err := scanAssignment(rows)
+		if err != nil {
+			return nil, database.MapError(err, op)
+		}
+		assignments = append(assignments, *a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	return assignments, nil
+}
+
+// Delete removes an assignment by ID
+func (r *Repository) Delete(ctx context.Context, id uuid.UUID) error {
+	const op = "AssignmentRepository.Delete"
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM content_assignments WHERE id = $1", id)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return database.MapError(err, op)
+	} else if rows == 0 {
+		return database.MapError(sql.ErrNoRows, op)
+	}
+
+	return nil
+}
+
+// propertiesJSON encodes selector properties, storing none as an empty
+// object so containment checks always have something to compare
+func propertiesJSON(properties map[string]string) ([]byte, error) {
+	if properties == nil {
+		properties = map[string]string{}
+	}
+	return json.Marshal(properties)
+}
+
+// scheduleJSON encodes a recurring schedule, storing none as NULL
+func scheduleJSON(s *v1alpha1.RecurringSchedule) ([]byte, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return json.Marshal(s)
+}
+
+// displayRef parses the ID of the display a selector names, NULL when it
+// names none
+func displayRef(ref string) (uuid.NullUUID, error) {
+	if ref == "" {
+		return uuid.NullUUID{}, nil
+	}
+	id, err := uuid.Parse(ref)
+	if err != nil {
+		return uuid.NullUUID{}, fmt.Errorf("display reference is not an ID: %w", err)
+	}
+	return uuid.NullUUID{UUID: id, Valid: true}, nil
+}
+
+// nullTime converts an unset time to NULL
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
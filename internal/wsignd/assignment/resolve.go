@@ -0,0 +1,82 @@
+package assignment
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+)
+
+// Current reports whether now falls within a's validity period. It does
+// not consult a's recurring schedule; Active does.
+func Current(a *v1alpha1.ContentAssignment, now time.Time) bool {
+	if a.ValidFrom != nil && now.Before(*a.ValidFrom) {
+		return false
+	}
+	return a.ValidUntil == nil || now.Before(*a.ValidUntil)
+}
+
+// Resolve picks the assignment that decides what the display with id at
+// location with properties shows at now. Of the active assignments
+// selecting it, the most specific wins, and of equally specific ones the
+// newest; an assignment naming the display beats any selecting it by
+// location. Auto-created assignments only fill in for displays no other
+// assignment selects. Schedules are read in the zones tz gives. It returns nil when
+// no active assignment selects the display.
+func Resolve(assignments []v1alpha1.ContentAssignment, id uuid.UUID, location v1alpha1.DisplayLocation, properties map[string]string, now time.Time, tz Timezones) *v1alpha1.ContentAssignment {
+	var best *v1alpha1.ContentAssignment
+	for i := range assignments {
+		a := &assignments[i]
+		if !Active(a, now, tz) || !a.DisplaySelector.Selects(id, location, properties) {
+			continue
+		}
+		if best == nil || (best.AutoCreated && !a.AutoCreated) {
+			best = a
+			continue
+		}
+		if a.AutoCreated && !best.AutoCreated {
+			continue
+		}
+		specificity, bestSpecificity := a.DisplaySelector.Specificity(), best.DisplaySelector.Specificity()
+		if specificity > bestSpecificity || (specificity == bestSpecificity && !a.CreatedAt.Before(best.CreatedAt)) {
+			best = a
+		}
+	}
+	return best
+}
+
+// Explain describes how Resolve treats each of assignments for the display
+// with id at location with properties at now: the one it selects, and why
+// each of the others does not decide the content. Steps are in the order
+// of assignments.
+func Explain(assignments []v1alpha1.ContentAssignment, id uuid.UUID, location v1alpha1.DisplayLocation, properties map[string]string, now time.Time, tz Timezones) []v1alpha1.ResolutionStep {
+	best := Resolve(assignments, id, location, properties, now, tz)
+	steps := make([]v1alpha1.ResolutionStep, 0, len(assignments))
+	for i := range assignments {
+		a := &assignments[i]
+		step := v1alpha1.ResolutionStep{Assignment: a.Name, ContentURL: a.ContentURL}
+		if a.Schedule != nil && Active(a, now, tz) {
+			step.Window = activeWindow(a, now, tz)
+			step.Timezone = scheduleLocation(a, tz).String()
+		}
+		switch {
+		case a == best:
+			step.Outcome = v1alpha1.ResolutionSelected
+		case !a.DisplaySelector.Selects(id, location, properties):
+			step.Outcome, step.Reason = v1alpha1.ResolutionSkipped, "selector does not match the display"
+		case !Current(a, now):
+			step.Outcome, step.Reason = v1alpha1.ResolutionSkipped, "outside its validity period"
+		case !Active(a, now, tz):
+			step.Outcome, step.Reason = v1alpha1.ResolutionSkipped, "outside its schedule"
+		case a.AutoCreated && !best.AutoCreated:
+			step.Outcome, step.Reason = v1alpha1.ResolutionOutranked, "auto-created assignments give way to others"
+		case a.DisplaySelector.Specificity() < best.DisplaySelector.Specificity():
+			step.Outcome, step.Reason = v1alpha1.ResolutionOutranked, "a more specific assignment selects the display"
+		default:
+			step.Outcome, step.Reason = v1alpha1.ResolutionOutranked, "a newer assignment is as specific"
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
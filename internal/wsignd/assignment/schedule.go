@@ -0,0 +1,172 @@
+package assignment
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+)
+
+// Timezones gives the time zone recurring schedules are read in at each
+// site, for schedules that do not name their own
+type Timezones struct {
+	// Default applies to sites without a zone of their own; nil means UTC
+	Default *time.Location
+	// Sites maps site IDs to their zone
+	Sites map[string]*time.Location
+}
+
+// For returns the time zone of the site
+func (t Timezones) For(siteID string) *time.Location {
+	if loc, ok := t.Sites[siteID]; ok {
+		return loc
+	}
+	if t.Default != nil {
+		return t.Default
+	}
+	return time.UTC
+}
+
+// locations caches loaded time zones, which are otherwise read from disk
+// on every lookup
+var locations sync.Map
+
+// loadLocation returns the named IANA time zone
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// scheduleLocation returns the time zone a's schedule is read in: its own,
+// or else that of the site it selects. Assignments naming a display select
+// no site and fall back to the default zone.
+func scheduleLocation(a *v1alpha1.ContentAssignment, tz Timezones) *time.Location {
+	if a.Schedule.Timezone != "" {
+		if loc, err := loadLocation(a.Schedule.Timezone); err == nil {
+			return loc
+		}
+	}
+	return tz.For(a.DisplaySelector.SiteID)
+}
+
+// Active reports whether a is in effect at now: within its validity period
+// and, when it has a schedule, inside one of its windows as read in the
+// schedule's time zone
+func Active(a *v1alpha1.ContentAssignment, now time.Time, tz Timezones) bool {
+	if !Current(a, now) {
+		return false
+	}
+	if a.Schedule == nil {
+		return true
+	}
+	return activeWindow(a, now, tz) != nil
+}
+
+// activeWindow returns the window of a's schedule that now falls in, read
+// in the schedule's time zone, or nil when it falls in none
+func activeWindow(a *v1alpha1.ContentAssignment, now time.Time, tz Timezones) *v1alpha1.ScheduleWindow {
+	local := now.In(scheduleLocation(a, tz))
+	minute := local.Hour()*60 + local.Minute()
+	for i, w := range a.Schedule.Windows {
+		start, end, err := windowBounds(w)
+		if err != nil || !onDay(w.DaysOfWeek, local.Weekday()) {
+			continue
+		}
+		if minute >= start && minute < end {
+			return &a.Schedule.Windows[i]
+		}
+	}
+	return nil
+}
+
+// onDay reports whether a window on days opens on day. No days means
+// every day.
+func onDay(days []time.Weekday, day time.Weekday) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// windowBounds returns a window's start and end in minutes since midnight
+func windowBounds(w v1alpha1.ScheduleWindow) (int, int, error) {
+	start, err := v1alpha1.ParseClock(w.TimeOfDay.Start)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := v1alpha1.ParseClock(w.TimeOfDay.End)
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// validateSchedule checks that a schedule names a known time zone and has
+// well formed windows, none overlapping another
+func validateSchedule(s *v1alpha1.RecurringSchedule) error {
+	if s.Timezone != "" {
+		if _, err := loadLocation(s.Timezone); err != nil {
+			return fmt.Errorf("unknown time zone %q", s.Timezone)
+		}
+	}
+	if len(s.Windows) == 0 {
+		return fmt.Errorf("schedule needs at least one window")
+	}
+
+	type bounds struct{ start, end int }
+	parsed := make([]bounds, len(s.Windows))
+	for i, w := range s.Windows {
+		start, end, err := windowBounds(w)
+		if err != nil {
+			return fmt.Errorf("schedule window %d: %w", i+1, err)
+		}
+		if end <= start {
+			return fmt.Errorf("schedule window %d: end %s must be after start %s; split windows past midnight in two", i+1, w.TimeOfDay.End, w.TimeOfDay.Start)
+		}
+		for _, d := range w.DaysOfWeek {
+			if d < time.Sunday || d > time.Saturday {
+				return fmt.Errorf("schedule window %d: invalid day of week %d", i+1, d)
+			}
+		}
+		parsed[i] = bounds{start, end}
+	}
+
+	for i := range s.Windows {
+		for j := i + 1; j < len(s.Windows); j++ {
+			if !shareDay(s.Windows[i].DaysOfWeek, s.Windows[j].DaysOfWeek) {
+				continue
+			}
+			if parsed[i].start < parsed[j].end && parsed[j].start < parsed[i].end {
+				return fmt.Errorf("schedule windows %d and %d overlap", i+1, j+1)
+			}
+		}
+	}
+	return nil
+}
+
+// shareDay reports whether windows on days a and on days b open on a
+// common day
+func shareDay(a, b []time.Weekday) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return true
+	}
+	for _, d := range a {
+		if onDay(b, d) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+package assignment_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/assignment"
+	"github.com/wrale/wrale-signage/internal/wsignd/assignment/memory"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	require.NoError(t, err)
+	return loc
+}
+
+// scheduled returns an assignment at hq shown in the given windows
+func scheduled(tz string, windows ...v1alpha1.ScheduleWindow) *v1alpha1.ContentAssignment {
+	return &v1alpha1.ContentAssignment{
+		DisplaySelector: v1alpha1.DisplaySelector{SiteID: "hq"},
+		Schedule:        &v1alpha1.RecurringSchedule{Timezone: tz, Windows: windows},
+	}
+}
+
+func window(start, end string, days ...time.Weekday) v1alpha1.ScheduleWindow {
+	return v1alpha1.ScheduleWindow{DaysOfWeek: days, TimeOfDay: v1alpha1.TimeRange{Start: start, End: end}}
+}
+
+func TestActive(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+	weekdays := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	breakfast := scheduled("America/New_York", window("06:00", "10:30", weekdays...))
+	lateShift := scheduled("America/New_York", window("18:00", "24:00"))
+
+	// 3 June 2024 is a Monday
+	tests := []struct {
+		name string
+		a    *v1alpha1.ContentAssignment
+		at   time.Time
+		want bool
+	}{
+		{"before the window", breakfast, time.Date(2024, 6, 3, 5, 59, 59, 0, ny), false},
+		{"opens on the minute", breakfast, time.Date(2024, 6, 3, 6, 0, 0, 0, ny), true},
+		{"last instant", breakfast, time.Date(2024, 6, 3, 10, 29, 59, 999, ny), true},
+		{"closes on the minute", breakfast, time.Date(2024, 6, 3, 10, 30, 0, 0, ny), false},
+		{"read in its own zone", breakfast, time.Date(2024, 6, 3, 13, 0, 0, 0, time.UTC), true},
+		{"not in the zone of the clock", breakfast, time.Date(2024, 6, 3, 8, 0, 0, 0, time.UTC), false},
+		{"not on other days", breakfast, time.Date(2024, 6, 8, 8, 0, 0, 0, ny), false},
+		{"local day decides", breakfast, time.Date(2024, 6, 8, 1, 0, 0, 0, time.UTC), false},
+		{"runs to midnight", lateShift, time.Date(2024, 6, 3, 23, 59, 59, 0, ny), true},
+		{"ends at midnight", lateShift, time.Date(2024, 6, 4, 0, 0, 0, 0, ny), false},
+		{"unscheduled", &v1alpha1.ContentAssignment{}, time.Date(2024, 6, 3, 3, 0, 0, 0, ny), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, assignment.Active(tt.a, tt.at, assignment.Timezones{}))
+		})
+	}
+
+	t.Run("outside the validity period", func(t *testing.T) {
+		until := time.Date(2024, 6, 3, 8, 0, 0, 0, ny)
+		a := *breakfast
+		a.ValidUntil = &until
+		assert.True(t, assignment.Active(&a, until.Add(-time.Minute), assignment.Timezones{}))
+		assert.False(t, assignment.Active(&a, until, assignment.Timezones{}))
+	})
+}
+
+func TestActive_SiteTimezones(t *testing.T) {
+	london := mustLoad(t, "Europe/London")
+	tz := assignment.Timezones{
+		Default: mustLoad(t, "America/Chicago"),
+		Sites:   map[string]*time.Location{"hq": london},
+	}
+	lunch := scheduled("", window("11:00", "14:00"))
+	lateMorning := time.Date(2024, 6, 3, 11, 30, 0, 0, london)
+
+	assert.True(t, assignment.Active(lunch, lateMorning, tz))
+
+	annex := *lunch
+	annex.DisplaySelector.SiteID = "annex"
+	assert.False(t, assignment.Active(&annex, lateMorning, tz), "sites without a zone use the default")
+	assert.True(t, assignment.Active(&annex, time.Date(2024, 6, 3, 12, 0, 0, 0, tz.Default), tz))
+
+	// Without any configured zone schedules are read in UTC
+	assert.False(t, assignment.Active(lunch, lateMorning, assignment.Timezones{}))
+	assert.True(t, assignment.Active(lunch, time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC), assignment.Timezones{}))
+}
+
+func TestActive_DaylightSaving(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+	breakfast := scheduled("America/New_York", window("06:00", "10:30"))
+
+	t.Run("windows follow the wall clock across the change", func(t *testing.T) {
+		// 06:00 is 11:00 UTC before clocks go forward on 10 March 2024 and
+		// 10:00 UTC from then on
+		assert.True(t, assignment.Active(breakfast, time.Date(2024, 3, 9, 11, 0, 0, 0, time.UTC), assignment.Timezones{}))
+		assert.False(t, assignment.Active(breakfast, time.Date(2024, 3, 9, 10, 59, 0, 0, time.UTC), assignment.Timezones{}))
+		assert.True(t, assignment.Active(breakfast, time.Date(2024, 3, 10, 10, 0, 0, 0, time.UTC), assignment.Timezones{}))
+		assert.False(t, assignment.Active(breakfast, time.Date(2024, 3, 10, 9, 59, 0, 0, time.UTC), assignment.Timezones{}))
+
+		// and back again when they fall back on 3 November
+		assert.True(t, assignment.Active(breakfast, time.Date(2024, 11, 2, 10, 0, 0, 0, time.UTC), assignment.Timezones{}))
+		assert.False(t, assignment.Active(breakfast, time.Date(2024, 11, 3, 10, 0, 0, 0, time.UTC), assignment.Timezones{}))
+		assert.True(t, assignment.Active(breakfast, time.Date(2024, 11, 3, 11, 0, 0, 0, time.UTC), assignment.Timezones{}))
+	})
+
+	t.Run("skipped hour never opens", func(t *testing.T) {
+		skipped := scheduled("America/New_York", window("02:00", "03:00"))
+		// Clocks go from 01:59:59 EST to 03:00 EDT, 06:59:59 to 07:00 UTC
+		for at := time.Date(2024, 3, 10, 5, 0, 0, 0, time.UTC); at.Before(time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)); at = at.Add(time.Minute) {
+			assert.False(t, assignment.Active(skipped, at, assignment.Timezones{}), at.In(ny).String())
+		}
+		assert.True(t, assignment.Active(skipped, time.Date(2024, 3, 11, 2, 30, 0, 0, ny), assignment.Timezones{}))
+	})
+
+	t.Run("repeated hour is covered twice", func(t *testing.T) {
+		repeated := scheduled("America/New_York", window("01:00", "01:30"))
+		// 01:15 EDT and 01:15 EST on 3 November 2024
+		assert.True(t, assignment.Active(repeated, time.Date(2024, 11, 3, 5, 15, 0, 0, time.UTC), assignment.Timezones{}))
+		assert.False(t, assignment.Active(repeated, time.Date(2024, 11, 3, 5, 45, 0, 0, time.UTC), assignment.Timezones{}))
+		assert.True(t, assignment.Active(repeated, time.Date(2024, 11, 3, 6, 15, 0, 0, time.UTC), assignment.Timezones{}))
+		assert.False(t, assignment.Active(repeated, time.Date(2024, 11, 3, 6, 30, 0, 0, time.UTC), assignment.Timezones{}))
+	})
+
+	t.Run("days are local days", func(t *testing.T) {
+		sundays := scheduled("America/New_York", window("00:00", "24:00", time.Sunday))
+		// The Sunday the clocks change is 23 hours long in March and 25 in
+		// November
+		assert.False(t, assignment.Active(sundays, time.Date(2024, 3, 10, 4, 59, 0, 0, time.UTC), assignment.Timezones{}))
+		assert.True(t, assignment.Active(sundays, time.Date(2024, 3, 10, 5, 0, 0, 0, time.UTC), assignment.Timezones{}))
+		assert.True(t, assignment.Active(sundays, time.Date(2024, 3, 11, 3, 59, 0, 0, time.UTC), assignment.Timezones{}))
+		assert.False(t, assignment.Active(sundays, time.Date(2024, 3, 11, 4, 0, 0, 0, time.UTC), assignment.Timezones{}))
+		assert.True(t, assignment.Active(sundays, time.Date(2024, 11, 3, 4, 0, 0, 0, time.UTC), assignment.Timezones{}))
+		assert.True(t, assignment.Active(sundays, time.Date(2024, 11, 4, 4, 59, 0, 0, time.UTC), assignment.Timezones{}))
+		assert.False(t, assignment.Active(sundays, time.Date(2024, 11, 4, 5, 0, 0, 0, time.UTC), assignment.Timezones{}))
+	})
+}
+
+func TestService_ForDisplay_Schedule(t *testing.T) {
+	ctx := context.Background()
+	ny := mustLoad(t, "America/New_York")
+	now := time.Date(2024, 6, 3, 10, 29, 0, 0, ny)
+	service := assignment.NewService(memory.NewRepository(),
+		assignment.WithClock(func() time.Time { return now }),
+		assignment.WithTimezones(assignment.Timezones{Sites: map[string]*time.Location{"hq": ny}}),
+	)
+
+	create := func(name string, schedule *v1alpha1.RecurringSchedule) {
+		_, err := service.Create(ctx, &v1alpha1.ContentAssignment{
+			ObjectMeta:      v1alpha1.ObjectMeta{Name: name},
+			DisplaySelector: v1alpha1.DisplaySelector{SiteID: "hq", Zone: "cafeteria"},
+			ContentURL:      "https://example.com/" + name,
+			Schedule:        schedule,
+		})
+		require.NoError(t, err)
+	}
+	create("all-day", nil)
+	create("breakfast", &v1alpha1.RecurringSchedule{Windows: []v1alpha1.ScheduleWindow{window("06:00", "10:30")}})
+	create("lunch", &v1alpha1.RecurringSchedule{Windows: []v1alpha1.ScheduleWindow{window("10:30", "14:00")}})
+
+	location := v1alpha1.DisplayLocation{SiteID: "hq", Zone: "cafeteria"}
+	for _, tt := range []struct {
+		at   time.Time
+		want string
+	}{
+		{time.Date(2024, 6, 3, 10, 29, 0, 0, ny), "breakfast"},
+		{time.Date(2024, 6, 3, 10, 30, 0, 0, ny), "lunch"},
+		{time.Date(2024, 6, 3, 14, 0, 0, 0, ny), "all-day"},
+	} {
+		now = tt.at
+		a, err := service.ForDisplay(ctx, uuid.New(), location, nil)
+		require.NoError(t, err)
+		require.NotNil(t, a)
+		assert.Equal(t, tt.want, a.Name, tt.at.String())
+	}
+
+	t.Run("explained at another time", func(t *testing.T) {
+		now = time.Date(2024, 6, 3, 13, 0, 0, 0, ny)
+		steps, err := service.ExplainForDisplay(ctx, uuid.New(), location, nil, time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		byName := make(map[string]v1alpha1.ResolutionStep)
+		for _, s := range steps {
+			byName[s.Assignment] = s
+		}
+
+		// Noon in UTC is 08:00 in New York, breakfast time, though the
+		// clock reads lunch time
+		assert.Equal(t, v1alpha1.ResolutionSelected, byName["breakfast"].Outcome)
+		assert.Equal(t, &v1alpha1.ScheduleWindow{TimeOfDay: v1alpha1.TimeRange{Start: "06:00", End: "10:30"}}, byName["breakfast"].Window)
+		assert.Equal(t, "America/New_York", byName["breakfast"].Timezone)
+		assert.Equal(t, "outside its schedule", byName["lunch"].Reason)
+		assert.Nil(t, byName["lunch"].Window)
+		assert.Equal(t, v1alpha1.ResolutionOutranked, byName["all-day"].Outcome)
+		assert.Nil(t, byName["all-day"].Window, "unscheduled assignments have no window")
+	})
+}
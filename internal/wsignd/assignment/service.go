@@ -0,0 +1,304 @@
+package assignment
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+type service struct {
+	repo     Repository
+	displays Displays
+	now      func() time.Time
+	tz       Timezones
+}
+
+// Option configures an assignment service
+type Option func(*service)
+
+// WithClock sets the clock assignments are resolved against
+func WithClock(now func() time.Time) Option {
+	return func(s *service) {
+		s.now = now
+	}
+}
+
+// WithTimezones sets the zones recurring schedules are read in
+func WithTimezones(tz Timezones) Option {
+	return func(s *service) {
+		s.tz = tz
+	}
+}
+
+// WithDisplays sets where the displays assignments name are looked up.
+// Without it, assignments naming a display are refused.
+func WithDisplays(displays Displays) Option {
+	return func(s *service) {
+		s.displays = displays
+	}
+}
+
+// NewService creates an assignment service backed by repo
+func NewService(repo Repository, opts ...Option) Service {
+	s := &service{repo: repo, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Create validates and stores a new assignment. Assignments without a name
+// are named after their ID. An assignment naming a content source or a
+// display that does not exist is refused; one naming a disabled display is
+// stored with a warning.
+func (s *service) Create(ctx context.Context, a *v1alpha1.ContentAssignment) (*v1alpha1.ContentAssignment, error) {
+	const op = "AssignmentService.Create"
+
+	if err := validateAssignment(a); err != nil {
+		return nil, werrors.NewError("INVALID_INPUT", err.Error(), op, werrors.ErrInvalidInput)
+	}
+
+	// The display is stored by ID so the assignment survives renames
+	var target *display.Display
+	if ref := a.DisplaySelector.DisplayRef; ref != "" {
+		var err error
+		if target, err = s.findDisplay(ctx, ref); err != nil {
+			if werrors.IsNotFound(err) {
+				return nil, werrors.NewError("DISPLAY_NOT_FOUND",
+					fmt.Sprintf("display not found: %s", ref), op, werrors.ErrInvalidInput)
+			}
+			return nil, werrors.NewError("LOOKUP_FAILED", "Failed to look up display", op, err)
+		}
+		a.DisplaySelector.DisplayRef = target.ID.String()
+	}
+
+	a.TypeMeta = v1alpha1.TypeMeta{Kind: "ContentAssignment", APIVersion: "v1alpha1"}
+	// Content source names are stored lower case
+	a.Source = strings.ToLower(a.Source)
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	if a.Name == "" {
+		a.Name = a.ID.String()
+	}
+
+	if err := s.repo.Create(ctx, a); err != nil {
+		if werrors.IsConflict(err) {
+			return nil, werrors.NewError("ALREADY_EXISTS",
+				fmt.Sprintf("assignment already exists: %s", a.Name), op, err)
+		}
+		if werrors.IsNotFound(err) {
+			return nil, werrors.NewError("SOURCE_NOT_FOUND",
+				fmt.Sprintf("content source not found: %s", a.Source), op, werrors.ErrInvalidInput)
+		}
+		return nil, werrors.NewError("SAVE_FAILED", "Failed to save assignment", op, err)
+	}
+
+	if target != nil {
+		a.Status = targetStatus(target)
+	}
+	return a, nil
+}
+
+// Get retrieves an assignment by ID
+func (s *service) Get(ctx context.Context, id uuid.UUID) (*v1alpha1.ContentAssignment, error) {
+	const op = "AssignmentService.Get"
+
+	a, err := s.repo.Get(ctx, id)
+	if err != nil {
+		if werrors.IsNotFound(err) {
+			return nil, werrors.NewError("NOT_FOUND", fmt.Sprintf("Assignment not found: %s", id), op, err)
+		}
+		return nil, werrors.NewError("LOOKUP_FAILED", "Failed to retrieve assignment", op, err)
+	}
+
+	described := []v1alpha1.ContentAssignment{*a}
+	if err := s.describeTargets(ctx, described); err != nil {
+		return nil, werrors.NewError("LOOKUP_FAILED", "Failed to look up assigned display", op, err)
+	}
+	return &described[0], nil
+}
+
+// List retrieves the assignments matching filter. A display filter may
+// name the display or give its ID. Assignments naming a display that has
+// since been deleted are listed as orphaned.
+func (s *service) List(ctx context.Context, filter Filter) ([]v1alpha1.ContentAssignment, error) {
+	const op = "AssignmentService.List"
+
+	if ref := filter.Selector.DisplayRef; ref != "" {
+		if _, err := uuid.Parse(ref); err != nil {
+			target, err := s.findDisplay(ctx, ref)
+			if werrors.IsNotFound(err) {
+				return []v1alpha1.ContentAssignment{}, nil
+			}
+			if err != nil {
+				return nil, werrors.NewError("LOOKUP_FAILED", "Failed to look up display", op, err)
+			}
+			filter.Selector.DisplayRef = target.ID.String()
+		}
+	}
+
+	assignments, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, werrors.NewError("LIST_FAILED", "Failed to list assignments", op, err)
+	}
+	if err := s.describeTargets(ctx, assignments); err != nil {
+		return nil, werrors.NewError("LOOKUP_FAILED", "Failed to look up assigned displays", op, err)
+	}
+
+	return assignments, nil
+}
+
+// Delete removes an assignment
+func (s *service) Delete(ctx context.Context, id uuid.UUID) error {
+	const op = "AssignmentService.Delete"
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		if werrors.IsNotFound(err) {
+			return werrors.NewError("NOT_FOUND", fmt.Sprintf("Assignment not found: %s", id), op, err)
+		}
+		return werrors.NewError("DELETE_FAILED", "Failed to delete assignment", op, err)
+	}
+
+	return nil
+}
+
+// ForDisplay resolves the assignment in effect for a display. Every
+// assignment names a site or a display, so only those of the display's
+// site and those naming it are listed.
+func (s *service) ForDisplay(ctx context.Context, id uuid.UUID, location v1alpha1.DisplayLocation, properties map[string]string) (*v1alpha1.ContentAssignment, error) {
+	const op = "AssignmentService.ForDisplay"
+
+	candidates, err := s.candidates(ctx, id, location)
+	if err != nil {
+		return nil, werrors.NewError("LIST_FAILED", "Failed to list assignments", op, err)
+	}
+	return Resolve(candidates, id, location, properties, s.now(), s.tz), nil
+}
+
+// ExplainForDisplay describes how ForDisplay treats each assignment it
+// considers for a display at the time at, or now when at is zero
+func (s *service) ExplainForDisplay(ctx context.Context, id uuid.UUID, location v1alpha1.DisplayLocation, properties map[string]string, at time.Time) ([]v1alpha1.ResolutionStep, error) {
+	const op = "AssignmentService.ExplainForDisplay"
+
+	candidates, err := s.candidates(ctx, id, location)
+	if err != nil {
+		return nil, werrors.NewError("LIST_FAILED", "Failed to list assignments", op, err)
+	}
+	if at.IsZero() {
+		at = s.now()
+	}
+	return Explain(candidates, id, location, properties, at, s.tz), nil
+}
+
+// candidates lists the assignments that may select a display: those of
+// its site and those naming it
+func (s *service) candidates(ctx context.Context, id uuid.UUID, location v1alpha1.DisplayLocation) ([]v1alpha1.ContentAssignment, error) {
+	assignments, err := s.repo.List(ctx, Filter{Selector: v1alpha1.DisplaySelector{SiteID: location.SiteID}})
+	if err != nil {
+		return nil, err
+	}
+	named, err := s.repo.List(ctx, Filter{Selector: v1alpha1.DisplaySelector{DisplayRef: id.String()}})
+	if err != nil {
+		return nil, err
+	}
+	return append(assignments, named...), nil
+}
+
+// findDisplay looks up a display by ID or, when ref is not one or no
+// display has it, by name
+func (s *service) findDisplay(ctx context.Context, ref string) (*display.Display, error) {
+	const op = "AssignmentService.findDisplay"
+
+	if s.displays == nil {
+		return nil, werrors.NewError("NOT_FOUND", "displays cannot be assigned content by name", op, werrors.ErrNotFound)
+	}
+	if id, err := uuid.Parse(ref); err == nil {
+		d, err := s.displays.Get(ctx, id)
+		if !werrors.IsNotFound(err) {
+			return d, err
+		}
+	}
+	return s.displays.GetByName(ctx, ref)
+}
+
+// describeTargets sets the status of the assignments naming a display,
+// marking those whose display has been deleted as orphaned
+func (s *service) describeTargets(ctx context.Context, assignments []v1alpha1.ContentAssignment) error {
+	for i := range assignments {
+		a := &assignments[i]
+		if a.DisplaySelector.DisplayRef == "" {
+			continue
+		}
+		id, err := uuid.Parse(a.DisplaySelector.DisplayRef)
+		if err != nil || s.displays == nil {
+			a.Status = orphanedStatus(a.DisplaySelector.DisplayRef)
+			continue
+		}
+		d, err := s.displays.Get(ctx, id)
+		switch {
+		case werrors.IsNotFound(err):
+			a.Status = orphanedStatus(a.DisplaySelector.DisplayRef)
+		case err != nil:
+			return err
+		default:
+			a.Status = targetStatus(d)
+		}
+	}
+	return nil
+}
+
+// targetStatus describes the display an assignment names
+func targetStatus(d *display.Display) *v1alpha1.ContentAssignmentStatus {
+	status := &v1alpha1.ContentAssignmentStatus{DisplayName: d.Name}
+	if d.State == display.StateDisabled {
+		status.Warnings = append(status.Warnings, fmt.Sprintf(
+			"display %s is disabled; the assignment applies once it is enabled", d.Name))
+	}
+	return status
+}
+
+// orphanedStatus describes an assignment whose display was deleted
+func orphanedStatus(ref string) *v1alpha1.ContentAssignmentStatus {
+	return &v1alpha1.ContentAssignmentStatus{
+		Orphaned: true,
+		Warnings: []string{fmt.Sprintf("display %s was deleted; the assignment reaches no display", ref)},
+	}
+}
+
+// validateAssignment checks the required fields of an assignment
+func validateAssignment(a *v1alpha1.ContentAssignment) error {
+	u, err := url.Parse(a.ContentURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("content URL must be an absolute http(s) URL")
+	}
+	if a.DisplaySelector.DisplayRef != "" {
+		sel := a.DisplaySelector
+		if sel.SiteID != "" || sel.Zone != "" || sel.Position != "" || len(sel.MatchProperties) > 0 {
+			return fmt.Errorf("display selector naming a display cannot also select by location or properties")
+		}
+	} else if a.DisplaySelector.SiteID == "" {
+		return fmt.Errorf("display selector must name a site or a display")
+	}
+	if a.DisplaySelector.Position != "" && a.DisplaySelector.Zone == "" {
+		return fmt.Errorf("display selector with a position must also name a zone")
+	}
+	if _, ok := a.DisplaySelector.MatchProperties[""]; ok {
+		return fmt.Errorf("display selector properties must have a key")
+	}
+	if a.ValidFrom != nil && a.ValidUntil != nil && !a.ValidUntil.After(*a.ValidFrom) {
+		return fmt.Errorf("validUntil must be after validFrom")
+	}
+	if a.Schedule != nil {
+		return validateSchedule(a.Schedule)
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package assignment_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/assignment"
+	"github.com/wrale/wrale-signage/internal/wsignd/assignment/memory"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+func TestService_Create(t *testing.T) {
+	ctx := context.Background()
+	from := time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)
+	until := from.Add(4 * time.Hour)
+
+	tests := []struct {
+		name    string
+		modify  func(a *v1alpha1.ContentAssignment)
+		wantErr bool
+	}{
+		{name: "valid", modify: func(a *v1alpha1.ContentAssignment) {}},
+		{name: "window", modify: func(a *v1alpha1.ContentAssignment) {
+			a.ValidFrom, a.ValidUntil = &from, &until
+		}},
+		{name: "relative URL", wantErr: true, modify: func(a *v1alpha1.ContentAssignment) {
+			a.ContentURL = "/menus"
+		}},
+		{name: "no site", wantErr: true, modify: func(a *v1alpha1.ContentAssignment) {
+			a.DisplaySelector.SiteID = ""
+		}},
+		{name: "position without zone", wantErr: true, modify: func(a *v1alpha1.ContentAssignment) {
+			a.DisplaySelector.Zone = ""
+		}},
+		{name: "window ends before it starts", wantErr: true, modify: func(a *v1alpha1.ContentAssignment) {
+			a.ValidFrom, a.ValidUntil = &until, &from
+		}},
+		{name: "schedule", modify: func(a *v1alpha1.ContentAssignment) {
+			a.Schedule = &v1alpha1.RecurringSchedule{Timezone: "America/New_York", Windows: []v1alpha1.ScheduleWindow{
+				{DaysOfWeek: []time.Weekday{time.Monday}, TimeOfDay: v1alpha1.TimeRange{Start: "06:00", End: "10:30"}},
+				{DaysOfWeek: []time.Weekday{time.Tuesday}, TimeOfDay: v1alpha1.TimeRange{Start: "08:00", End: "12:00"}},
+				{DaysOfWeek: []time.Weekday{time.Monday}, TimeOfDay: v1alpha1.TimeRange{Start: "10:30", End: "24:00"}},
+			}}
+		}},
+		{name: "schedule without windows", wantErr: true, modify: func(a *v1alpha1.ContentAssignment) {
+			a.Schedule = &v1alpha1.RecurringSchedule{}
+		}},
+		{name: "unknown time zone", wantErr: true, modify: func(a *v1alpha1.ContentAssignment) {
+			a.Schedule = &v1alpha1.RecurringSchedule{Timezone: "America/Atlantis", Windows: []v1alpha1.ScheduleWindow{
+				{TimeOfDay: v1alpha1.TimeRange{Start: "06:00", End: "10:30"}},
+			}}
+		}},
+		{name: "overlapping windows", wantErr: true, modify: func(a *v1alpha1.ContentAssignment) {
+			a.Schedule = &v1alpha1.RecurringSchedule{Windows: []v1alpha1.ScheduleWindow{
+				{DaysOfWeek: []time.Weekday{time.Monday, time.Tuesday}, TimeOfDay: v1alpha1.TimeRange{Start: "06:00", End: "10:30"}},
+				{TimeOfDay: v1alpha1.TimeRange{Start: "10:00", End: "14:00"}},
+			}}
+		}},
+		{name: "window past midnight", wantErr: true, modify: func(a *v1alpha1.ContentAssignment) {
+			a.Schedule = &v1alpha1.RecurringSchedule{Windows: []v1alpha1.ScheduleWindow{
+				{TimeOfDay: v1alpha1.TimeRange{Start: "22:00", End: "02:00"}},
+			}}
+		}},
+		{name: "malformed time of day", wantErr: true, modify: func(a *v1alpha1.ContentAssignment) {
+			a.Schedule = &v1alpha1.RecurringSchedule{Windows: []v1alpha1.ScheduleWindow{
+				{TimeOfDay: v1alpha1.TimeRange{Start: "6am", End: "10:30"}},
+			}}
+		}},
+		{name: "invalid day of week", wantErr: true, modify: func(a *v1alpha1.ContentAssignment) {
+			a.Schedule = &v1alpha1.RecurringSchedule{Windows: []v1alpha1.ScheduleWindow{
+				{DaysOfWeek: []time.Weekday{7}, TimeOfDay: v1alpha1.TimeRange{Start: "06:00", End: "10:30"}},
+			}}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := assignment.NewService(memory.NewRepository())
+			a := &v1alpha1.ContentAssignment{
+				Source:          "menus",
+				DisplaySelector: v1alpha1.DisplaySelector{SiteID: "hq", Zone: "cafeteria", Position: "menu-1"},
+				ContentURL:      "https://example.com/menus",
+			}
+			tt.modify(a)
+
+			created, err := service.Create(ctx, a)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, werrors.ErrInvalidInput)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEqual(t, uuid.Nil, created.ID)
+			assert.Equal(t, created.ID.String(), created.Name)
+			assert.Equal(t, "ContentAssignment", created.Kind)
+
+			stored, err := service.Get(ctx, created.ID)
+			require.NoError(t, err)
+			assert.Equal(t, created.DisplaySelector, stored.DisplaySelector)
+			assert.Equal(t, created.ValidUntil, stored.ValidUntil)
+			assert.Equal(t, created.Schedule, stored.Schedule)
+		})
+	}
+}
+
+// missingSources is a SourceLocker holding no content sources
+type missingSources struct{}
+
+func (missingSources) WithSource(ctx context.Context, name string, fn func() error) error {
+	return werrors.NewError("NOT_FOUND", "content source not found", "missingSources.WithSource", werrors.ErrNotFound)
+}
+
+func TestService_Create_UnknownSource(t *testing.T) {
+	repo := memory.NewRepository()
+	repo.RequireSources(missingSources{})
+	service := assignment.NewService(repo)
+
+	_, err := service.Create(context.Background(), &v1alpha1.ContentAssignment{
+		Source:          "Menus",
+		DisplaySelector: v1alpha1.DisplaySelector{SiteID: "hq"},
+		ContentURL:      "https://example.com/menus",
+	})
+	assert.ErrorIs(t, err, werrors.ErrInvalidInput)
+	var apiErr *werrors.Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "SOURCE_NOT_FOUND", apiErr.Code)
+	assert.Contains(t, apiErr.Message, "menus")
+}
+
+func TestService_ListAndDelete(t *testing.T) {
+	ctx := context.Background()
+	service := assignment.NewService(memory.NewRepository())
+
+	create := func(name, source, zone string) *v1alpha1.ContentAssignment {
+		a, err := service.Create(ctx, &v1alpha1.ContentAssignment{
+			ObjectMeta:      v1alpha1.ObjectMeta{Name: name},
+			Source:          source,
+			DisplaySelector: v1alpha1.DisplaySelector{SiteID: "hq", Zone: zone},
+			ContentURL:      "https://example.com/" + source,
+		})
+		require.NoError(t, err)
+		return a
+	}
+	lunch := create("cafeteria-lunch", "menus", "cafeteria")
+	create("lobby-welcome", "welcome", "lobby")
+	create("lobby-menu", "menus", "lobby")
+
+	names := func(filter assignment.Filter) []string {
+		list, err := service.List(ctx, filter)
+		require.NoError(t, err)
+		var out []string
+		for _, a := range list {
+			out = append(out, a.Name)
+		}
+		return out
+	}
+	assert.Len(t, names(assignment.Filter{}), 3)
+	assert.ElementsMatch(t, []string{"cafeteria-lunch", "lobby-menu"}, names(assignment.Filter{Source: "menus"}))
+	assert.Equal(t, []string{"lobby-menu"}, names(assignment.Filter{
+		Source:   "menus",
+		Selector: v1alpha1.DisplaySelector{Zone: "lobby"},
+	}))
+
+	_, err := service.Create(ctx, &v1alpha1.ContentAssignment{
+		ObjectMeta:      v1alpha1.ObjectMeta{Name: "lobby-menu"},
+		DisplaySelector: v1alpha1.DisplaySelector{SiteID: "hq"},
+		ContentURL:      "https://example.com/other",
+	})
+	assert.ErrorIs(t, err, werrors.ErrConflict)
+
+	require.NoError(t, service.Delete(ctx, lunch.ID))
+	assert.ErrorIs(t, service.Delete(ctx, lunch.ID), werrors.ErrNotFound)
+	_, err = service.Get(ctx, lunch.ID)
+	assert.ErrorIs(t, err, werrors.ErrNotFound)
+	assert.Equal(t, []string{"lobby-menu"}, names(assignment.Filter{Source: "menus"}))
+}
+
+func TestService_ForDisplay(t *testing.T) {
+	ctx := context.Background()
+	service := assignment.NewService(memory.NewRepository())
+	hour := time.Hour
+	now := time.Now()
+
+	create := func(name string, selector v1alpha1.DisplaySelector, from, until *time.Time) {
+		_, err := service.Create(ctx, &v1alpha1.ContentAssignment{
+			ObjectMeta:      v1alpha1.ObjectMeta{Name: name},
+			DisplaySelector: selector,
+			ContentURL:      "https://example.com/" + name,
+			ValidFrom:       from,
+			ValidUntil:      until,
+		})
+		require.NoError(t, err)
+	}
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	create("site-wide", v1alpha1.DisplaySelector{SiteID: "hq"}, nil, nil)
+	create("lobby", v1alpha1.DisplaySelector{SiteID: "hq", Zone: "lobby"}, nil, nil)
+	create("lobby-portrait", v1alpha1.DisplaySelector{
+		SiteID: "hq", Zone: "lobby", MatchProperties: map[string]string{"orientation": "portrait"},
+	}, nil, nil)
+	create("lobby-expired", v1alpha1.DisplaySelector{SiteID: "hq", Zone: "lobby", Position: "north"}, at(-2*hour), at(-hour))
+	create("lobby-later", v1alpha1.DisplaySelector{SiteID: "hq", Zone: "lobby", Position: "north"}, at(hour), nil)
+	create("lobby-override", v1alpha1.DisplaySelector{SiteID: "hq", Zone: "lobby"}, nil, nil)
+
+	tests := []struct {
+		name       string
+		location   v1alpha1.DisplayLocation
+		properties map[string]string
+		want       string
+	}{
+		{"most specific wins", v1alpha1.DisplayLocation{SiteID: "hq", Zone: "lobby"}, map[string]string{"orientation": "portrait"}, "lobby-portrait"},
+		{"newest of equally specific wins", v1alpha1.DisplayLocation{SiteID: "hq", Zone: "lobby", Position: "north"}, nil, "lobby-override"},
+		{"falls back to the site", v1alpha1.DisplayLocation{SiteID: "hq", Zone: "cafe"}, nil, "site-wide"},
+		{"nothing at other sites", v1alpha1.DisplayLocation{SiteID: "annex", Zone: "lobby"}, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := service.ForDisplay(ctx, uuid.New(), tt.location, tt.properties)
+			require.NoError(t, err)
+			if tt.want == "" {
+				assert.Nil(t, a)
+				return
+			}
+			require.NotNil(t, a)
+			assert.Equal(t, tt.want, a.Name)
+		})
+	}
+
+	t.Run("explains each assignment", func(t *testing.T) {
+		steps, err := service.ExplainForDisplay(ctx, uuid.New(), v1alpha1.DisplayLocation{SiteID: "hq", Zone: "lobby", Position: "north"}, nil, time.Time{})
+		require.NoError(t, err)
+		outcomes := make(map[string]string)
+		for _, s := range steps {
+			outcomes[s.Assignment] = s.Outcome + ": " + s.Reason
+		}
+		assert.Equal(t, map[string]string{
+			"site-wide":      "outranked: a more specific assignment selects the display",
+			"lobby":          "outranked: a newer assignment is as specific",
+			"lobby-portrait": "skipped: selector does not match the display",
+			"lobby-expired":  "skipped: outside its validity period",
+			"lobby-later":    "skipped: outside its validity period",
+			"lobby-override": "selected: ",
+		}, outcomes)
+	})
+}
@@ -0,0 +1,130 @@
+// Package auth issues and validates the access tokens displays use to talk
+// to the server. Tokens are signed JWTs carrying the ID of the signing key,
+// which lets keys be rotated without invalidating every token at once.
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidToken indicates a malformed token or a bad signature
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenExpired indicates a token past its expiry time
+	ErrTokenExpired = errors.New("token expired")
+	// ErrUnknownKey indicates a token signed by a key that is not configured
+	ErrUnknownKey = errors.New("unknown signing key")
+	// ErrInvalidSignature indicates a document whose detached signature does
+	// not match it
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// Token describes an issued display access token
+type Token struct {
+	// ID uniquely identifies the token
+	ID uuid.UUID
+	// DisplayID is the display the token was issued to
+	DisplayID uuid.UUID
+	// SessionID identifies the token family the token belongs to. Issuing a
+	// token to a display starts a new session; tokens refreshed from it
+	// keep its SessionID.
+	SessionID uuid.UUID
+	// KeyID identifies the key that signed the token
+	KeyID string
+	// IssuedAt is when the token was issued
+	IssuedAt time.Time
+	// ExpiresAt is when the token stops being valid
+	ExpiresAt time.Time
+}
+
+// Session summarises one token family of a display
+type Session struct {
+	// ID is the session's ID, shared by every token in the family
+	ID uuid.UUID
+	// DisplayID is the display the session belongs to
+	DisplayID uuid.UUID
+	// KeyID identifies the key that signed the newest token
+	KeyID string
+	// IssuedAt is when the session's first token was issued
+	IssuedAt time.Time
+	// LastRefreshedAt is when the session's newest token was issued
+	LastRefreshedAt time.Time
+	// ExpiresAt is when the session's last token stops being valid
+	ExpiresAt time.Time
+}
+
+// KeyUsage reports a configured signing key and how many unexpired tokens
+// it has signed
+type KeyUsage struct {
+	// KeyID identifies the key
+	KeyID string
+	// Algorithm is the key's signing algorithm
+	Algorithm Algorithm
+	// Primary is true for the key new tokens are signed with
+	Primary bool
+	// ActiveTokens is the number of unexpired tokens signed by the key
+	ActiveTokens int64
+	// PublicKey verifies the key's signatures away from the server. It is
+	// only set for configured EdDSA keys.
+	PublicKey ed25519.PublicKey
+}
+
+// Repository defines the interface for token persistence
+type Repository interface {
+	// Save persists an issued token
+	Save(ctx context.Context, token *Token) error
+
+	// FindByID retrieves a token by its ID
+	FindByID(ctx context.Context, id uuid.UUID) (*Token, error)
+
+	// ListActiveByDisplay retrieves a display's tokens expiring after now,
+	// oldest first
+	ListActiveByDisplay(ctx context.Context, displayID uuid.UUID, now time.Time) ([]Token, error)
+
+	// DeleteSession removes every token of one of a display's sessions
+	DeleteSession(ctx context.Context, displayID, sessionID uuid.UUID) error
+
+	// CountActiveByKey counts tokens expiring after now, grouped by key ID
+	CountActiveByKey(ctx context.Context, now time.Time) (map[string]int64, error)
+
+	// DeleteExpired removes tokens that expired before the given time
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Service defines the interface for token operations
+type Service interface {
+	// IssueDisplayToken signs a new token for a display with the primary key
+	IssueDisplayToken(ctx context.Context, displayID uuid.UUID) (string, *Token, error)
+
+	// ValidateToken checks a token's signature, expiry and that it is still
+	// on record
+	ValidateToken(ctx context.Context, raw string) (*Token, error)
+
+	// ListSessions lists a display's unexpired sessions, oldest first
+	ListSessions(ctx context.Context, displayID uuid.UUID) ([]Session, error)
+
+	// TerminateSession revokes every token of one of a display's sessions
+	TerminateSession(ctx context.Context, displayID, sessionID uuid.UUID) error
+
+	// KeyUsage lists the configured keys, primary first
+	KeyUsage(ctx context.Context) ([]KeyUsage, error)
+
+	// SignDocument makes a detached signature over data with the primary key
+	SignDocument(data []byte) Signature
+
+	// CanSignPublicly reports whether SignDocument makes signatures that
+	// devices can verify without the server's secret, which takes an EdDSA
+	// primary key
+	CanSignPublicly() bool
+
+	// VerifyDocument checks a detached signature over data
+	VerifyDocument(data []byte, sig Signature) error
+
+	// CleanupExpired removes expired tokens from the store
+	CleanupExpired(ctx context.Context) error
+}
@@ -0,0 +1,22 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type contextKey int
+
+const displayIDKey contextKey = iota
+
+// WithDisplayID returns a context carrying the authenticated display's ID
+func WithDisplayID(ctx context.Context, id uuid.UUID) context.Context {
+	return context.WithValue(ctx, displayIDKey, id)
+}
+
+// DisplayIDFromContext returns the authenticated display's ID, if any
+func DisplayIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	id, ok := ctx.Value(displayIDKey).(uuid.UUID)
+	return id, ok
+}
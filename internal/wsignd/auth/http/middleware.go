@@ -0,0 +1,58 @@
+// Package http provides HTTP middleware for authenticating displays and
+// operators
+package http
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
+)
+
+// RequireDisplayToken rejects requests without a valid display bearer token
+// and records the authenticated display's ID in the request context
+func RequireDisplayToken(tokens auth.Service, logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw, ok := BearerToken(r)
+			if !ok {
+				unauthorized(w, "missing bearer token")
+				return
+			}
+
+			token, err := tokens.ValidateToken(r.Context(), raw)
+			if err != nil {
+				logger.Warn("rejected display token",
+					"error", err,
+					"remoteAddr", r.RemoteAddr,
+				)
+				unauthorized(w, "invalid or expired token")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithDisplayID(r.Context(), token.DisplayID)))
+		})
+	}
+}
+
+// BearerToken extracts the token from an Authorization: Bearer header
+func BearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+func unauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("WWW-Authenticate", `Bearer realm="wsignd"`)
+	w.WriteHeader(http.StatusUnauthorized)
+	_ = json.NewEncoder(w).Encode(v1alpha1.Error{
+		Code:    "UNAUTHORIZED",
+		Message: message,
+	})
+}
@@ -0,0 +1,82 @@
+package http
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+)
+
+// OperatorAuth returns a guard that admits requests carrying an operator
+// API token with the required scope and records the operator in the request
+// context. Display tokens are recognised so that a display reaching an
+// admin route is told it is forbidden rather than unauthenticated.
+func OperatorAuth(operators operator.Service, displayTokens auth.Service, logger *slog.Logger) operator.Guard {
+	return func(scope operator.Scope) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				raw, ok := BearerToken(r)
+				if !ok {
+					unauthorized(w, "missing bearer token")
+					return
+				}
+
+				if !operator.IsAPIToken(raw) {
+					if displayTokens != nil {
+						if token, err := displayTokens.ValidateToken(r.Context(), raw); err == nil {
+							logger.Warn("display token used on operator route",
+								"displayId", token.DisplayID,
+								"method", r.Method,
+								"path", r.URL.Path,
+								"remoteAddr", r.RemoteAddr,
+							)
+							forbidden(w, "display tokens cannot be used for this operation")
+							return
+						}
+					}
+					unauthorized(w, "invalid or expired token")
+					return
+				}
+
+				principal, err := operators.Authenticate(r.Context(), raw)
+				if err != nil {
+					logger.Warn("rejected operator token",
+						"error", err,
+						"remoteAddr", r.RemoteAddr,
+					)
+					unauthorized(w, "invalid or expired token")
+					return
+				}
+
+				if !principal.HasScope(scope) {
+					logger.Info("operator lacks scope",
+						"operator", principal.OperatorName,
+						"tokenId", principal.TokenID,
+						"scope", scope,
+						"method", r.Method,
+						"path", r.URL.Path,
+						"requestId", middleware.GetReqID(r.Context()),
+					)
+					forbidden(w, "token lacks the "+string(scope)+" scope")
+					return
+				}
+
+				next.ServeHTTP(w, r.WithContext(operator.WithPrincipal(r.Context(), principal)))
+			})
+		}
+	}
+}
+
+func forbidden(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	_ = json.NewEncoder(w).Encode(v1alpha1.Error{
+		Code:    "FORBIDDEN",
+		Message: message,
+	})
+}
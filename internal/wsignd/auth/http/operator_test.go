@@ -0,0 +1,93 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
+	authmemory "github.com/wrale/wrale-signage/internal/wsignd/auth/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+	operatormemory "github.com/wrale/wrale-signage/internal/wsignd/operator/memory"
+)
+
+func TestOperatorAuth(t *testing.T) {
+	ctx := context.Background()
+
+	material := make([]byte, 32)
+	_, err := rand.Read(material)
+	require.NoError(t, err)
+	key, err := auth.ParseKey("test", string(auth.AlgorithmHS256), base64.StdEncoding.EncodeToString(material))
+	require.NoError(t, err)
+	keys, err := auth.NewKeyRing(key)
+	require.NoError(t, err)
+	displayTokens := auth.NewService(keys, authmemory.NewRepository(), time.Hour)
+	operators := operator.NewService(operatormemory.NewRepository())
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	guard := OperatorAuth(operators, displayTokens, logger)
+
+	var seen string
+	record := func(w http.ResponseWriter, r *http.Request) {
+		seen = operator.Name(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	}
+	router := chi.NewRouter()
+	router.With(guard.Require(operator.ScopeDisplaysRead)).Get("/displays/{id}", record)
+	router.With(guard.Require(operator.ScopeDisplaysWrite)).Delete("/displays/{id}", record)
+	router.With(guard.Require(operator.ScopeAdmin)).Get("/debug/overload", record)
+
+	do := func(method, path, token string) int {
+		seen = ""
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	readOnly, _, err := operators.CreateToken(ctx, "viewer", "", []operator.Scope{operator.ScopeDisplaysRead}, 0)
+	require.NoError(t, err)
+	admin, _, err := operators.CreateToken(ctx, "root", "", []operator.Scope{operator.ScopeAdmin}, 0)
+	require.NoError(t, err)
+	displayToken, _, err := displayTokens.IssueDisplayToken(ctx, uuid.New())
+	require.NoError(t, err)
+
+	t.Run("missing or invalid tokens are unauthorized", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/displays/1", ""))
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/displays/1", "garbage"))
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/displays/1", operator.TokenPrefix+"00_x"))
+	})
+
+	t.Run("read-only token can read but not delete", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, do(http.MethodGet, "/displays/1", readOnly))
+		assert.Equal(t, "viewer", seen)
+		assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, "/displays/1", readOnly))
+		assert.Empty(t, seen)
+	})
+
+	t.Run("admin token may do anything", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/displays/1", admin))
+		assert.Equal(t, http.StatusNoContent, do(http.MethodGet, "/debug/overload", admin))
+		assert.Equal(t, "root", seen)
+	})
+
+	t.Run("display tokens cannot reach operator routes", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/debug/overload", displayToken))
+		assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/displays/1", displayToken))
+		assert.Empty(t, seen)
+	})
+}
@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// Algorithm is a token signing algorithm, named as in the JWT "alg" header
+type Algorithm string
+
+const (
+	// AlgorithmHS256 signs with HMAC-SHA256 and a shared secret
+	AlgorithmHS256 Algorithm = "HS256"
+	// AlgorithmEdDSA signs with an Ed25519 private key
+	AlgorithmEdDSA Algorithm = "EdDSA"
+)
+
+// Key is a named signing key
+type Key struct {
+	// ID is the key ID carried in the "kid" header of tokens it signs
+	ID string
+	// Algorithm is the signing algorithm used with this key
+	Algorithm Algorithm
+
+	secret  []byte
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+}
+
+// NewHMACKey creates an HMAC-SHA256 key from a shared secret
+func NewHMACKey(id string, secret []byte) (*Key, error) {
+	if id == "" {
+		return nil, fmt.Errorf("key ID cannot be empty")
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("key %s: secret cannot be empty", id)
+	}
+	return &Key{ID: id, Algorithm: AlgorithmHS256, secret: secret}, nil
+}
+
+// NewEd25519Key creates an Ed25519 key from a 32 byte seed
+func NewEd25519Key(id string, seed []byte) (*Key, error) {
+	if id == "" {
+		return nil, fmt.Errorf("key ID cannot be empty")
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("key %s: Ed25519 seed must be %d bytes, got %d", id, ed25519.SeedSize, len(seed))
+	}
+	private := ed25519.NewKeyFromSeed(seed)
+	return &Key{
+		ID:        id,
+		Algorithm: AlgorithmEdDSA,
+		private:   private,
+		public:    private.Public().(ed25519.PublicKey),
+	}, nil
+}
+
+// ParseKey creates a key from configuration. The material is base64 encoded:
+// the shared secret for HS256, or the 32 byte seed for EdDSA.
+func ParseKey(id, algorithm, material string) (*Key, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(material))
+	if err != nil {
+		return nil, fmt.Errorf("key %s: invalid base64 key material: %w", id, err)
+	}
+	switch Algorithm(algorithm) {
+	case AlgorithmHS256:
+		return NewHMACKey(id, raw)
+	case AlgorithmEdDSA:
+		return NewEd25519Key(id, raw)
+	default:
+		return nil, fmt.Errorf("key %s: unsupported algorithm %q", id, algorithm)
+	}
+}
+
+// PublicKey returns the key that verifies an EdDSA key's signatures, or
+// nil for an HS256 key, whose secret must never leave the server
+func (k *Key) PublicKey() ed25519.PublicKey {
+	return k.public
+}
+
+func (k *Key) sign(data []byte) []byte {
+	if k.Algorithm == AlgorithmEdDSA {
+		return ed25519.Sign(k.private, data)
+	}
+	mac := hmac.New(sha256.New, k.secret)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func (k *Key) verify(data, sig []byte) bool {
+	if k.Algorithm == AlgorithmEdDSA {
+		return ed25519.Verify(k.public, data, sig)
+	}
+	return hmac.Equal(k.sign(data), sig)
+}
+
+// KeyRing holds the configured signing keys. The first key is the primary
+// key and signs all new tokens; the others are only used for validation so
+// that tokens signed before a rotation stay valid until they expire.
+type KeyRing struct {
+	keys []*Key
+	byID map[string]*Key
+}
+
+// NewKeyRing creates a key ring with keys[0] as the primary key
+func NewKeyRing(keys ...*Key) (*KeyRing, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one signing key is required")
+	}
+	r := &KeyRing{keys: keys, byID: make(map[string]*Key, len(keys))}
+	for _, k := range keys {
+		if _, ok := r.byID[k.ID]; ok {
+			return nil, fmt.Errorf("duplicate signing key ID %q", k.ID)
+		}
+		r.byID[k.ID] = k
+	}
+	return r, nil
+}
+
+// Primary returns the key used to sign new tokens
+func (r *KeyRing) Primary() *Key {
+	return r.keys[0]
+}
+
+// Keys returns all keys, primary first
+func (r *KeyRing) Keys() []*Key {
+	return r.keys
+}
+
+// candidates returns the keys to try for a token header, preferring the key
+// named by kid and falling back to every key of the same algorithm
+func (r *KeyRing) candidates(kid string, alg Algorithm) []*Key {
+	if k, ok := r.byID[kid]; ok && k.Algorithm == alg {
+		return []*Key{k}
+	}
+	var keys []*Key
+	for _, k := range r.keys {
+		if k.Algorithm == alg {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
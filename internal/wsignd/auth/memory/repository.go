@@ -0,0 +1,121 @@
+// Package memory implements token persistence in process memory for demo
+// mode and tests
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// Repository implements auth.Repository in memory. Unlike the PostgreSQL
+// store it does not check that a token's display exists.
+type Repository struct {
+	mu     sync.RWMutex
+	tokens map[uuid.UUID]auth.Token
+}
+
+// NewRepository creates an empty in-memory token repository
+func NewRepository() auth.Repository {
+	return &Repository{tokens: make(map[uuid.UUID]auth.Token)}
+}
+
+// Save records an issued token
+func (r *Repository) Save(ctx context.Context, t *auth.Token) error {
+	const op = "TokenRepository.Save"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.tokens[t.ID]; exists {
+		return werrors.NewError("CONFLICT", "resource already exists", op, werrors.ErrConflict)
+	}
+	r.tokens[t.ID] = *t
+	return nil
+}
+
+// FindByID retrieves a token by its ID
+func (r *Repository) FindByID(ctx context.Context, id uuid.UUID) (*auth.Token, error) {
+	const op = "TokenRepository.FindByID"
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.tokens[id]
+	if !ok {
+		return nil, werrors.NewError("NOT_FOUND", "resource not found", op, werrors.ErrNotFound)
+	}
+	return &t, nil
+}
+
+// ListActiveByDisplay retrieves a display's unexpired tokens, oldest first
+func (r *Repository) ListActiveByDisplay(ctx context.Context, displayID uuid.UUID, now time.Time) ([]auth.Token, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tokens := []auth.Token{}
+	for _, t := range r.tokens {
+		if t.DisplayID == displayID && t.ExpiresAt.After(now) {
+			tokens = append(tokens, t)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].IssuedAt.Before(tokens[j].IssuedAt)
+	})
+	return tokens, nil
+}
+
+// DeleteSession removes every token of a display's session
+func (r *Repository) DeleteSession(ctx context.Context, displayID, sessionID uuid.UUID) error {
+	const op = "TokenRepository.DeleteSession"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	found := false
+	for id, t := range r.tokens {
+		if t.DisplayID == displayID && t.SessionID == sessionID {
+			delete(r.tokens, id)
+			found = true
+		}
+	}
+	if !found {
+		return werrors.NewError("NOT_FOUND", "resource not found", op, werrors.ErrNotFound)
+	}
+	return nil
+}
+
+// CountActiveByKey counts unexpired tokens per signing key
+func (r *Repository) CountActiveByKey(ctx context.Context, now time.Time) (map[string]int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[string]int64)
+	for _, t := range r.tokens {
+		if t.ExpiresAt.After(now) {
+			counts[t.KeyID]++
+		}
+	}
+	return counts, nil
+}
+
+// DeleteExpired removes tokens that expired before the given time
+func (r *Repository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for id, t := range r.tokens {
+		if t.ExpiresAt.Before(before) {
+			delete(r.tokens, id)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,170 @@
+// Package postgres implements the token repository using PostgreSQL
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
+	"github.com/wrale/wrale-signage/internal/wsignd/database"
+)
+
+// Repository implements auth.Repository using PostgreSQL
+type Repository struct {
+	db *sql.DB
+}
+
+// NewRepository creates a new PostgreSQL token repository
+func NewRepository(db *sql.DB) auth.Repository {
+	return &Repository{db: db}
+}
+
+// Save records an issued token
+func (r *Repository) Save(ctx context.Context, t *auth.Token) error {
+	const op = "TokenRepository.Save"
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO display_tokens (
+			id, display_id, session_id, key_id, issued_at, expires_at
+		) VALUES ($1, $2, $3, $4, $5, $6)
+	`,
+		t.ID,
+		t.DisplayID,
+		t.SessionID,
+		t.KeyID,
+		t.IssuedAt,
+		t.ExpiresAt,
+	)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+
+	return nil
+}
+
+// FindByID retrieves a token by its ID
+func (r *Repository) FindByID(ctx context.Context, id uuid.UUID) (*auth.Token, error) {
+	const op = "TokenRepository.FindByID"
+
+	var t auth.Token
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, display_id, session_id, key_id, issued_at, expires_at
+		FROM display_tokens
+		WHERE id = $1
+	`, id).Scan(
+		&t.ID,
+		&t.DisplayID,
+		&t.SessionID,
+		&t.KeyID,
+		&t.IssuedAt,
+		&t.ExpiresAt,
+	)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	return &t, nil
+}
+
+// ListActiveByDisplay retrieves a display's unexpired tokens, oldest first
+func (r *Repository) ListActiveByDisplay(ctx context.Context, displayID uuid.UUID, now time.Time) ([]auth.Token, error) {
+	const op = "TokenRepository.ListActiveByDisplay"
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, display_id, session_id, key_id, issued_at, expires_at
+		FROM display_tokens
+		WHERE display_id = $1 AND expires_at > $2
+		ORDER BY issued_at, id
+	`, displayID, now)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+	defer rows.Close()
+
+	tokens := []auth.Token{}
+	for rows.Next() {
+		var t auth.Token
+		if err := rows.Scan(&t.ID, &t.DisplayID, &t.SessionID, &t.KeyID, &t.IssuedAt, &t.ExpiresAt); err != nil {
+			return nil, database.MapError(err, op)
+		}
+		tokens = append(tokens, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	return tokens, nil
+}
+
+// DeleteSession removes every token of a display's session
+func (r *Repository) DeleteSession(ctx context.Context, displayID, sessionID uuid.UUID) error {
+	const op = "TokenRepository.DeleteSession"
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM display_tokens WHERE display_id = $1 AND session_id = $2
+	`, displayID, sessionID)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return database.MapError(err, op)
+	} else if rows == 0 {
+		return database.MapError(sql.ErrNoRows, op)
+	}
+
+	return nil
+}
+
+// CountActiveByKey counts unexpired tokens per signing key
+func (r *Repository) CountActiveByKey(ctx context.Context, now time.Time) (map[string]int64, error) {
+	const op = "TokenRepository.CountActiveByKey"
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT key_id, COUNT(*)
+		FROM display_tokens
+		WHERE expires_at > $1
+		GROUP BY key_id
+	`, now)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var kid string
+		var n int64
+		if err := rows.Scan(&kid, &n); err != nil {
+			return nil, database.MapError(err, op)
+		}
+		counts[kid] = n
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	return counts, nil
+}
+
+// DeleteExpired removes tokens that expired before the given time
+func (r *Repository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	const op = "TokenRepository.DeleteExpired"
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM display_tokens WHERE expires_at < $1
+	`, before)
+	if err != nil {
+		return 0, database.MapError(err, op)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, database.MapError(err, op)
+	}
+
+	return n, nil
+}
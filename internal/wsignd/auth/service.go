@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/clock"
+	"github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// service implements Service on top of a key ring and token repository
+type service struct {
+	keys   *KeyRing
+	repo   Repository
+	expiry time.Duration
+	clock  clock.Clock
+}
+
+// Option configures a token service
+type Option func(*service)
+
+// WithClock sets the clock tokens are issued and checked against
+func WithClock(c clock.Clock) Option {
+	return func(s *service) {
+		s.clock = c
+	}
+}
+
+// NewService creates a token service that issues tokens valid for expiry.
+// It reads the system clock unless given another with WithClock.
+func NewService(keys *KeyRing, repo Repository, expiry time.Duration, opts ...Option) Service {
+	s := &service{
+		keys:   keys,
+		repo:   repo,
+		expiry: expiry,
+		clock:  clock.Real(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// IssueDisplayToken signs and records a new token for a display.
+func (s *service) IssueDisplayToken(ctx context.Context, displayID uuid.UUID) (string, *Token, error) {
+	const op = "AuthService.IssueDisplayToken"
+
+	now := s.clock.Now()
+	id := uuid.New()
+	token := &Token{
+		ID:        id,
+		DisplayID: displayID,
+		SessionID: id,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(s.expiry),
+	}
+
+	raw, err := s.keys.Sign(token)
+	if err != nil {
+		return "", nil, errors.NewError("SIGN_FAILED", "Failed to sign token", op, err)
+	}
+
+	if err := s.repo.Save(ctx, token); err != nil {
+		return "", nil, errors.NewError("SAVE_FAILED", "Failed to save token", op, err)
+	}
+
+	return raw, token, nil
+}
+
+// ValidateToken checks a token and that it has not been removed from the
+// store.
+func (s *service) ValidateToken(ctx context.Context, raw string) (*Token, error) {
+	const op = "AuthService.ValidateToken"
+
+	token, err := s.keys.Verify(raw, s.clock.Now())
+	if err != nil {
+		return nil, errors.NewError("INVALID_TOKEN", err.Error(), op, fmt.Errorf("%w: %w", errors.ErrUnauthorized, err))
+	}
+
+	if _, err := s.repo.FindByID(ctx, token.ID); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, errors.NewError("INVALID_TOKEN", "token has been revoked", op,
+				fmt.Errorf("%w: %w", errors.ErrUnauthorized, ErrInvalidToken))
+		}
+		return nil, errors.NewError("LOOKUP_FAILED", "Failed to look up token", op, err)
+	}
+
+	return token, nil
+}
+
+// ListSessions groups a display's unexpired tokens into sessions, ordered
+// by when each session began.
+func (s *service) ListSessions(ctx context.Context, displayID uuid.UUID) ([]Session, error) {
+	const op = "AuthService.ListSessions"
+
+	tokens, err := s.repo.ListActiveByDisplay(ctx, displayID, s.clock.Now())
+	if err != nil {
+		return nil, errors.NewError("LOOKUP_FAILED", "Failed to list tokens", op, err)
+	}
+
+	sessions := []Session{}
+	index := make(map[uuid.UUID]int)
+	for _, t := range tokens {
+		i, ok := index[t.SessionID]
+		if !ok {
+			index[t.SessionID] = len(sessions)
+			sessions = append(sessions, Session{
+				ID:              t.SessionID,
+				DisplayID:       t.DisplayID,
+				KeyID:           t.KeyID,
+				IssuedAt:        t.IssuedAt,
+				LastRefreshedAt: t.IssuedAt,
+				ExpiresAt:       t.ExpiresAt,
+			})
+			continue
+		}
+		session := &sessions[i]
+		if t.IssuedAt.After(session.LastRefreshedAt) {
+			session.LastRefreshedAt = t.IssuedAt
+			session.KeyID = t.KeyID
+		}
+		if t.ExpiresAt.After(session.ExpiresAt) {
+			session.ExpiresAt = t.ExpiresAt
+		}
+	}
+
+	return sessions, nil
+}
+
+// TerminateSession revokes a session by deleting its tokens; ValidateToken
+// rejects tokens that are no longer on record.
+func (s *service) TerminateSession(ctx context.Context, displayID, sessionID uuid.UUID) error {
+	const op = "AuthService.TerminateSession"
+
+	if err := s.repo.DeleteSession(ctx, displayID, sessionID); err != nil {
+		if errors.IsNotFound(err) {
+			return errors.NewError("NOT_FOUND", fmt.Sprintf("Session not found: %s", sessionID), op, err)
+		}
+		return errors.NewError("DELETE_FAILED", "Failed to terminate session", op, err)
+	}
+	return nil
+}
+
+// KeyUsage lists the configured keys with a count of the unexpired tokens
+// each has signed. Counts come from the token store and include keys that
+// are no longer configured, so operators can see when a retired key is safe
+// to drop.
+func (s *service) KeyUsage(ctx context.Context) ([]KeyUsage, error) {
+	const op = "AuthService.KeyUsage"
+
+	counts, err := s.repo.CountActiveByKey(ctx, s.clock.Now())
+	if err != nil {
+		return nil, errors.NewError("LOOKUP_FAILED", "Failed to count tokens", op, err)
+	}
+
+	usage := make([]KeyUsage, 0, len(counts)+len(s.keys.Keys()))
+	for i, k := range s.keys.Keys() {
+		usage = append(usage, KeyUsage{
+			KeyID:        k.ID,
+			Algorithm:    k.Algorithm,
+			Primary:      i == 0,
+			ActiveTokens: counts[k.ID],
+			PublicKey:    k.PublicKey(),
+		})
+		delete(counts, k.ID)
+	}
+
+	// Tokens signed by keys that have since been removed
+	var retired []string
+	for kid := range counts {
+		retired = append(retired, kid)
+	}
+	sort.Strings(retired)
+	for _, kid := range retired {
+		usage = append(usage, KeyUsage{KeyID: kid, ActiveTokens: counts[kid]})
+	}
+
+	return usage, nil
+}
+
+// SignDocument signs data with the primary key.
+func (s *service) SignDocument(data []byte) Signature {
+	return s.keys.SignDocument(data)
+}
+
+// CanSignPublicly reports whether the primary key is an EdDSA key.
+func (s *service) CanSignPublicly() bool {
+	return s.keys.CanSignPublicly()
+}
+
+// VerifyDocument checks a detached signature against the configured keys.
+func (s *service) VerifyDocument(data []byte, sig Signature) error {
+	return s.keys.VerifyDocument(data, sig)
+}
+
+// CleanupExpired removes expired tokens.
+func (s *service) CleanupExpired(ctx context.Context) error {
+	const op = "AuthService.CleanupExpired"
+
+	if _, err := s.repo.DeleteExpired(ctx, s.clock.Now()); err != nil {
+		return errors.NewError("CLEANUP_FAILED", "Failed to delete expired tokens", op, err)
+	}
+	return nil
+}
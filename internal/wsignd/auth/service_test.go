@@ -0,0 +1,355 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/clock"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// memoryRepository is an in-memory token store for tests
+type memoryRepository struct {
+	mu     sync.Mutex
+	tokens map[uuid.UUID]Token
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{tokens: make(map[uuid.UUID]Token)}
+}
+
+func (r *memoryRepository) Save(ctx context.Context, t *Token) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[t.ID] = *t
+	return nil
+}
+
+func (r *memoryRepository) FindByID(ctx context.Context, id uuid.UUID) (*Token, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tokens[id]
+	if !ok {
+		return nil, werrors.NewError("NOT_FOUND", "token not found", "test", werrors.ErrNotFound)
+	}
+	return &t, nil
+}
+
+func (r *memoryRepository) CountActiveByKey(ctx context.Context, now time.Time) (map[string]int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := make(map[string]int64)
+	for _, t := range r.tokens {
+		if t.ExpiresAt.After(now) {
+			counts[t.KeyID]++
+		}
+	}
+	return counts, nil
+}
+
+func (r *memoryRepository) ListActiveByDisplay(ctx context.Context, displayID uuid.UUID, now time.Time) ([]Token, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tokens := []Token{}
+	for _, t := range r.tokens {
+		if t.DisplayID == displayID && t.ExpiresAt.After(now) {
+			tokens = append(tokens, t)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].IssuedAt.Before(tokens[j].IssuedAt) })
+	return tokens, nil
+}
+
+func (r *memoryRepository) DeleteSession(ctx context.Context, displayID, sessionID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	found := false
+	for id, t := range r.tokens {
+		if t.DisplayID == displayID && t.SessionID == sessionID {
+			delete(r.tokens, id)
+			found = true
+		}
+	}
+	if !found {
+		return werrors.NewError("NOT_FOUND", "session not found", "test", werrors.ErrNotFound)
+	}
+	return nil
+}
+
+func (r *memoryRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int64
+	for id, t := range r.tokens {
+		if t.ExpiresAt.Before(before) {
+			delete(r.tokens, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func randomKey(t *testing.T, id string, alg Algorithm) *Key {
+	t.Helper()
+	material := make([]byte, 32)
+	_, err := rand.Read(material)
+	require.NoError(t, err)
+	key, err := ParseKey(id, string(alg), base64.StdEncoding.EncodeToString(material))
+	require.NoError(t, err)
+	return key
+}
+
+func mustKeyRing(t *testing.T, keys ...*Key) *KeyRing {
+	t.Helper()
+	ring, err := NewKeyRing(keys...)
+	require.NoError(t, err)
+	return ring
+}
+
+func TestKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemoryRepository()
+	displayID := uuid.New()
+
+	for _, alg := range []Algorithm{AlgorithmHS256, AlgorithmEdDSA} {
+		t.Run(string(alg), func(t *testing.T) {
+			keyA := randomKey(t, "a-"+string(alg), alg)
+			keyB := randomKey(t, "b-"+string(alg), alg)
+
+			// Issue under key A
+			svc := NewService(mustKeyRing(t, keyA), repo, time.Hour)
+			tokenA, issued, err := svc.IssueDisplayToken(ctx, displayID)
+			require.NoError(t, err)
+			assert.Equal(t, keyA.ID, issued.KeyID)
+
+			// Rotate: B becomes primary, A is kept for validation
+			svc = NewService(mustKeyRing(t, keyB, keyA), repo, time.Hour)
+			tokenB, issued, err := svc.IssueDisplayToken(ctx, displayID)
+			require.NoError(t, err)
+			assert.Equal(t, keyB.ID, issued.KeyID)
+
+			for _, raw := range []string{tokenA, tokenB} {
+				validated, err := svc.ValidateToken(ctx, raw)
+				require.NoError(t, err)
+				assert.Equal(t, displayID, validated.DisplayID)
+			}
+
+			// Once A is removed its tokens stop validating
+			svc = NewService(mustKeyRing(t, keyB), repo, time.Hour)
+			_, err = svc.ValidateToken(ctx, tokenA)
+			assert.True(t, errors.Is(err, ErrUnknownKey))
+			assert.True(t, werrors.IsUnauthorized(err))
+			_, err = svc.ValidateToken(ctx, tokenB)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestValidateToken(t *testing.T) {
+	ctx := context.Background()
+	key := randomKey(t, "primary", AlgorithmHS256)
+
+	t.Run("expired", func(t *testing.T) {
+		// Tokens carry whole seconds, so start on one to hit the boundary
+		now := clock.NewFake(time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC))
+		svc := NewService(mustKeyRing(t, key), newMemoryRepository(), time.Minute, WithClock(now))
+		raw, issued, err := svc.IssueDisplayToken(ctx, uuid.New())
+		require.NoError(t, err)
+		assert.Equal(t, now.Now().Add(time.Minute), issued.ExpiresAt)
+
+		now.Advance(time.Minute - time.Millisecond)
+		_, err = svc.ValidateToken(ctx, raw)
+		assert.NoError(t, err, "valid until the last moment")
+
+		now.Advance(time.Millisecond)
+		_, err = svc.ValidateToken(ctx, raw)
+		assert.True(t, errors.Is(err, ErrTokenExpired), "expired at its expiry time")
+	})
+
+	t.Run("tampered signature", func(t *testing.T) {
+		svc := NewService(mustKeyRing(t, key), newMemoryRepository(), time.Hour)
+		raw, _, err := svc.IssueDisplayToken(ctx, uuid.New())
+		require.NoError(t, err)
+
+		other := randomKey(t, "primary", AlgorithmHS256)
+		forged, _, err := NewService(mustKeyRing(t, other), newMemoryRepository(), time.Hour).IssueDisplayToken(ctx, uuid.New())
+		require.NoError(t, err)
+
+		_, err = svc.ValidateToken(ctx, raw[:len(raw)-2]+"xx")
+		assert.True(t, errors.Is(err, ErrInvalidToken))
+		_, err = svc.ValidateToken(ctx, forged)
+		assert.True(t, errors.Is(err, ErrInvalidToken))
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		repo := newMemoryRepository()
+		svc := NewService(mustKeyRing(t, key), repo, time.Hour)
+		raw, issued, err := svc.IssueDisplayToken(ctx, uuid.New())
+		require.NoError(t, err)
+
+		delete(repo.tokens, issued.ID)
+		_, err = svc.ValidateToken(ctx, raw)
+		assert.True(t, werrors.IsUnauthorized(err))
+	})
+}
+
+func TestSessions(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemoryRepository()
+	key := randomKey(t, "primary", AlgorithmHS256)
+	start := time.Now()
+	now := clock.NewFake(start)
+	svc := NewService(mustKeyRing(t, key), repo, time.Hour, WithClock(now))
+	displayID := uuid.New()
+
+	first, issued, err := svc.IssueDisplayToken(ctx, displayID)
+	require.NoError(t, err)
+	assert.Equal(t, issued.ID, issued.SessionID)
+
+	// A refreshed token joins the first session
+	refreshed := Token{
+		ID:        uuid.New(),
+		DisplayID: displayID,
+		SessionID: issued.SessionID,
+		KeyID:     key.ID,
+		IssuedAt:  start.Add(10 * time.Minute),
+		ExpiresAt: start.Add(70 * time.Minute),
+	}
+	require.NoError(t, repo.Save(ctx, &refreshed))
+
+	now.Advance(20 * time.Minute)
+	second, other, err := svc.IssueDisplayToken(ctx, displayID)
+	require.NoError(t, err)
+	_, _, err = svc.IssueDisplayToken(ctx, uuid.New())
+	require.NoError(t, err)
+
+	sessions, err := svc.ListSessions(ctx, displayID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, issued.SessionID, sessions[0].ID)
+	assert.True(t, sessions[0].IssuedAt.Equal(issued.IssuedAt))
+	assert.True(t, sessions[0].LastRefreshedAt.Equal(refreshed.IssuedAt))
+	assert.True(t, sessions[0].ExpiresAt.Equal(refreshed.ExpiresAt))
+	assert.Equal(t, other.SessionID, sessions[1].ID)
+
+	validated, err := svc.ValidateToken(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, issued.SessionID, validated.SessionID)
+
+	// Terminating a session revokes its tokens and leaves the others
+	require.NoError(t, svc.TerminateSession(ctx, displayID, issued.SessionID))
+	_, err = svc.ValidateToken(ctx, first)
+	assert.True(t, werrors.IsUnauthorized(err))
+	_, err = svc.ValidateToken(ctx, second)
+	assert.NoError(t, err)
+
+	sessions, err = svc.ListSessions(ctx, displayID)
+	require.NoError(t, err)
+	assert.Len(t, sessions, 1)
+
+	err = svc.TerminateSession(ctx, displayID, issued.SessionID)
+	assert.True(t, werrors.IsNotFound(err))
+	err = svc.TerminateSession(ctx, uuid.New(), other.SessionID)
+	assert.True(t, werrors.IsNotFound(err), "a session can only be ended through its own display")
+}
+
+func TestSignDocument(t *testing.T) {
+	document := []byte(`{"name":"lobby-north"}`)
+
+	for _, alg := range []Algorithm{AlgorithmEdDSA, AlgorithmHS256} {
+		t.Run(string(alg), func(t *testing.T) {
+			primary := randomKey(t, "k1", alg)
+			svc := NewService(mustKeyRing(t, primary), newMemoryRepository(), time.Hour)
+
+			sig := svc.SignDocument(document)
+			assert.Equal(t, "k1", sig.KeyID)
+			assert.Equal(t, alg, sig.Algorithm)
+			require.NoError(t, svc.VerifyDocument(document, sig))
+
+			tampered := []byte(`{"name":"lobby-south"}`)
+			assert.ErrorIs(t, svc.VerifyDocument(tampered, sig), ErrInvalidSignature)
+
+			// A key of the same ID but different material is another key
+			other := NewService(mustKeyRing(t, randomKey(t, "k1", alg)), newMemoryRepository(), time.Hour)
+			assert.ErrorIs(t, other.VerifyDocument(document, sig), ErrInvalidSignature)
+
+			relabelled := sig
+			relabelled.KeyID = "k2"
+			assert.ErrorIs(t, svc.VerifyDocument(document, relabelled), ErrUnknownKey)
+
+			// Documents signed before a rotation still verify
+			rotated := NewService(mustKeyRing(t, randomKey(t, "k2", alg), primary), newMemoryRepository(), time.Hour)
+			require.NoError(t, rotated.VerifyDocument(document, sig))
+			assert.Equal(t, "k2", rotated.SignDocument(document).KeyID)
+		})
+	}
+
+	t.Run("only EdDSA signatures are public", func(t *testing.T) {
+		eddsa := randomKey(t, "k1", AlgorithmEdDSA)
+		svc := NewService(mustKeyRing(t, eddsa), newMemoryRepository(), time.Hour)
+		assert.True(t, svc.CanSignPublicly())
+		sig := svc.SignDocument(document)
+		assert.True(t, ed25519.Verify(eddsa.PublicKey(), document, sig.Value))
+
+		hmac := randomKey(t, "k2", AlgorithmHS256)
+		assert.Nil(t, hmac.PublicKey())
+		svc = NewService(mustKeyRing(t, hmac, eddsa), newMemoryRepository(), time.Hour)
+		assert.False(t, svc.CanSignPublicly())
+	})
+
+	t.Run("algorithm must match the key", func(t *testing.T) {
+		svc := NewService(mustKeyRing(t, randomKey(t, "k1", AlgorithmEdDSA)), newMemoryRepository(), time.Hour)
+		sig := svc.SignDocument(document)
+		sig.Algorithm = AlgorithmHS256
+		assert.ErrorIs(t, svc.VerifyDocument(document, sig), ErrInvalidSignature)
+	})
+}
+
+func TestKeyUsage(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemoryRepository()
+	keyA := randomKey(t, "a", AlgorithmHS256)
+	keyB := randomKey(t, "b", AlgorithmEdDSA)
+
+	svc := NewService(mustKeyRing(t, keyA), repo, time.Hour)
+	for i := 0; i < 2; i++ {
+		_, _, err := svc.IssueDisplayToken(ctx, uuid.New())
+		require.NoError(t, err)
+	}
+
+	svc = NewService(mustKeyRing(t, keyB), repo, time.Hour)
+	_, _, err := svc.IssueDisplayToken(ctx, uuid.New())
+	require.NoError(t, err)
+
+	usage, err := svc.KeyUsage(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []KeyUsage{
+		{KeyID: "b", Algorithm: AlgorithmEdDSA, Primary: true, ActiveTokens: 1, PublicKey: keyB.PublicKey()},
+		{KeyID: "a", ActiveTokens: 2},
+	}, usage)
+}
+
+func TestKeyRingConfiguration(t *testing.T) {
+	_, err := NewKeyRing()
+	assert.Error(t, err)
+
+	key := randomKey(t, "dup", AlgorithmHS256)
+	_, err = NewKeyRing(key, key)
+	assert.Error(t, err)
+
+	_, err = ParseKey("k", "RS256", base64.StdEncoding.EncodeToString([]byte("secret")))
+	assert.Error(t, err)
+	_, err = ParseKey("k", string(AlgorithmEdDSA), base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.Error(t, err)
+}
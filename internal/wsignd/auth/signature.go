@@ -0,0 +1,40 @@
+package auth
+
+// Signature is a detached signature over a document, such as a display
+// provisioning file, that is read away from the server
+type Signature struct {
+	// KeyID identifies the key that made the signature
+	KeyID string
+	// Algorithm is the algorithm of that key
+	Algorithm Algorithm
+	// Value is the raw signature
+	Value []byte
+}
+
+// SignDocument signs data with the primary key. Only EdDSA signatures can
+// be checked without the server's secret, so devices verifying documents
+// offline need the primary key to be an Ed25519 key.
+func (r *KeyRing) SignDocument(data []byte) Signature {
+	key := r.Primary()
+	return Signature{KeyID: key.ID, Algorithm: key.Algorithm, Value: key.sign(data)}
+}
+
+// CanSignPublicly reports whether documents signed with the primary key can
+// be verified by holders of its public key alone
+func (r *KeyRing) CanSignPublicly() bool {
+	return r.Primary().Algorithm == AlgorithmEdDSA
+}
+
+// VerifyDocument checks a detached signature over data with the key it
+// names. Unlike tokens, documents are always signed with a key ID, so no
+// other key is tried.
+func (r *KeyRing) VerifyDocument(data []byte, sig Signature) error {
+	key, ok := r.byID[sig.KeyID]
+	if !ok {
+		return ErrUnknownKey
+	}
+	if key.Algorithm != sig.Algorithm || !key.verify(data, sig.Value) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
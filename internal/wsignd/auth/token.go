@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// header is the JWT header of a display token
+type header struct {
+	Algorithm Algorithm `json:"alg"`
+	Type      string    `json:"typ"`
+	KeyID     string    `json:"kid,omitempty"`
+}
+
+// claims is the JWT payload of a display token
+type claims struct {
+	TokenID   uuid.UUID `json:"jti"`
+	DisplayID uuid.UUID `json:"sub"`
+	SessionID uuid.UUID `json:"sid,omitempty"`
+	IssuedAt  int64     `json:"iat"`
+	ExpiresAt int64     `json:"exp"`
+}
+
+var encoding = base64.RawURLEncoding
+
+// Sign encodes a token as a JWT signed with the primary key and sets its
+// KeyID accordingly
+func (r *KeyRing) Sign(t *Token) (string, error) {
+	key := r.Primary()
+	t.KeyID = key.ID
+
+	h, err := json.Marshal(header{Algorithm: key.Algorithm, Type: "JWT", KeyID: key.ID})
+	if err != nil {
+		return "", fmt.Errorf("error encoding token header: %w", err)
+	}
+	c, err := json.Marshal(claims{
+		TokenID:   t.ID,
+		DisplayID: t.DisplayID,
+		SessionID: t.SessionID,
+		IssuedAt:  t.IssuedAt.Unix(),
+		ExpiresAt: t.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error encoding token claims: %w", err)
+	}
+
+	signed := encoding.EncodeToString(h) + "." + encoding.EncodeToString(c)
+	return signed + "." + encoding.EncodeToString(key.sign([]byte(signed))), nil
+}
+
+// Verify checks a token's signature and expiry. The key named by the token's
+// kid is tried first; tokens without a known kid are checked against every
+// key of the same algorithm.
+func (r *KeyRing) Verify(raw string, now time.Time) (*Token, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, ErrInvalidToken
+	}
+	sig, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	keys := r.candidates(h.KeyID, h.Algorithm)
+	if len(keys) == 0 {
+		return nil, ErrUnknownKey
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	var key *Key
+	for _, k := range keys {
+		if k.verify(signed, sig) {
+			key = k
+			break
+		}
+	}
+	if key == nil {
+		if _, known := r.byID[h.KeyID]; h.KeyID != "" && !known {
+			return nil, ErrUnknownKey
+		}
+		return nil, ErrInvalidToken
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, ErrInvalidToken
+	}
+	t := &Token{
+		ID:        c.TokenID,
+		DisplayID: c.DisplayID,
+		SessionID: c.SessionID,
+		KeyID:     key.ID,
+		IssuedAt:  time.Unix(c.IssuedAt, 0),
+		ExpiresAt: time.Unix(c.ExpiresAt, 0),
+	}
+	if t.SessionID == uuid.Nil {
+		// Tokens issued before sessions were tracked are their own session
+		t.SessionID = t.ID
+	}
+	if !now.Before(t.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+	return t, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := encoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	return dec.Decode(v)
+}
@@ -0,0 +1,56 @@
+// Package clock provides the time source services measure expiry and
+// liveness against, so that tests can move time instead of waiting for it
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock reads the system clock
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// Real returns the system clock. Services use it unless given another.
+func Real() Clock {
+	return realClock{}
+}
+
+// Fake is a clock that only moves when told to. It is safe for concurrent
+// use.
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFake returns a fake clock reading now
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now returns the fake's current time
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Advance moves the fake forward by d, or back when d is negative
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// Set moves the fake to t
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = t
+}
@@ -0,0 +1,97 @@
+// Package coalesce merges identical reads that are in flight at the same
+// time, so that a burst of displays asking for the same record costs a
+// single database round trip. Nothing is kept once the read that produced
+// a result returns: this is coalescing, not caching.
+package coalesce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Group runs at most one call per key at a time. Callers arriving while a
+// call for their key runs wait for it and share its result; the first
+// caller gets the value as returned and every other caller a copy made by
+// the group's clone function, so that no two callers share mutable state.
+// A nil *Group runs every call on its own.
+type Group[V any] struct {
+	clone func(V) V
+
+	mu    sync.Mutex
+	calls map[string]*call[V]
+}
+
+// call is a read in flight and, once done is closed, its result
+type call[V any] struct {
+	done chan struct{}
+	val  V
+	err  error
+}
+
+// New creates a group handing waiting callers clone of the result
+func New[V any](clone func(V) V) *Group[V] {
+	return &Group[V]{clone: clone, calls: make(map[string]*call[V])}
+}
+
+// Do returns the result of fn for key, running fn unless a call for the same
+// key is already in flight. Errors are returned to every caller sharing the
+// call, except that a caller whose own context is still live retries when
+// the call failed only because the context of the caller that started it
+// ended.
+func (g *Group[V]) Do(ctx context.Context, key string, fn func(ctx context.Context) (V, error)) (V, error) {
+	if g == nil {
+		return fn(ctx)
+	}
+
+	for {
+		g.mu.Lock()
+		c, ok := g.calls[key]
+		if !ok {
+			c = &call[V]{done: make(chan struct{})}
+			g.calls[key] = c
+			g.mu.Unlock()
+			g.run(ctx, key, c, fn)
+			return c.val, c.err
+		}
+		g.mu.Unlock()
+
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			var zero V
+			return zero, ctx.Err()
+		}
+		if c.err != nil && ctx.Err() == nil && isContextErr(c.err) {
+			continue
+		}
+		if c.err != nil {
+			var zero V
+			return zero, c.err
+		}
+		return g.clone(c.val), nil
+	}
+}
+
+// run makes the call and publishes its result. A panicking fn fails the
+// waiting callers before the panic carries on up the first caller's stack.
+func (g *Group[V]) run(ctx context.Context, key string, c *call[V], fn func(ctx context.Context) (V, error)) {
+	finished := false
+	defer func() {
+		if !finished {
+			c.err = fmt.Errorf("coalesced read of %q panicked", key)
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+
+	c.val, c.err = fn(ctx)
+	finished = true
+}
+
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
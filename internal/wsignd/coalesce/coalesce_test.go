@@ -0,0 +1,184 @@
+package coalesce_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/coalesce"
+)
+
+// record is a mutable value to check that callers get their own copies
+type record struct {
+	Key   string
+	Reads int
+}
+
+func cloneRecord(r *record) *record {
+	c := *r
+	return &c
+}
+
+// blockingRead returns a read that counts its calls and blocks until
+// release is closed
+func blockingRead(calls *atomic.Int32, release <-chan struct{}, key string, err error) func(context.Context) (*record, error) {
+	return func(ctx context.Context) (*record, error) {
+		n := calls.Add(1)
+		<-release
+		if err != nil {
+			return nil, err
+		}
+		return &record{Key: key, Reads: int(n)}, nil
+	}
+}
+
+// doConcurrently starts n calls of Do and waits until the first read has
+// begun and the rest are waiting on it
+func doConcurrently(t *testing.T, g *coalesce.Group[*record], n int, key string, read func(context.Context) (*record, error)) (results []*record, errs []error, wait func()) {
+	t.Helper()
+	results, errs = make([]*record, n), make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = g.Do(context.Background(), key, read)
+		}(i)
+	}
+	// Give the callers time to join the read in flight
+	time.Sleep(20 * time.Millisecond)
+	return results, errs, wg.Wait
+}
+
+func TestGroupSharesReadsInFlight(t *testing.T) {
+	g := coalesce.New(cloneRecord)
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	results, errs, wait := doConcurrently(t, g, 100, "lobby", blockingRead(&calls, release, "lobby", nil))
+	close(release)
+	wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	for i := range results {
+		require.NoError(t, errs[i])
+		assert.Equal(t, &record{Key: "lobby", Reads: 1}, results[i])
+	}
+
+	// Every caller holds its own copy
+	results[0].Key = "changed"
+	assert.Equal(t, "lobby", results[1].Key)
+
+	// Nothing is kept once the read returns
+	again, err := g.Do(context.Background(), "lobby", blockingRead(&calls, closed(), "lobby", nil))
+	require.NoError(t, err)
+	assert.Equal(t, 2, again.Reads)
+}
+
+func TestGroupKeepsKeysApart(t *testing.T) {
+	g := coalesce.New(cloneRecord)
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	lobby, _, waitLobby := doConcurrently(t, g, 10, "lobby", blockingRead(&calls, release, "lobby", nil))
+	cafeteria, _, waitCafeteria := doConcurrently(t, g, 10, "cafeteria", blockingRead(&calls, release, "cafeteria", nil))
+	close(release)
+	waitLobby()
+	waitCafeteria()
+
+	assert.Equal(t, int32(2), calls.Load())
+	for i := range lobby {
+		assert.Equal(t, "lobby", lobby[i].Key)
+		assert.Equal(t, "cafeteria", cafeteria[i].Key)
+	}
+}
+
+func TestGroupSharesErrors(t *testing.T) {
+	g := coalesce.New(cloneRecord)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	failed := errors.New("database unavailable")
+
+	results, errs, wait := doConcurrently(t, g, 10, "lobby", blockingRead(&calls, release, "lobby", failed))
+	close(release)
+	wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	for i := range errs {
+		assert.ErrorIs(t, errs[i], failed)
+		assert.Nil(t, results[i])
+	}
+}
+
+func TestGroupRetriesReadsCancelledByAnotherCaller(t *testing.T) {
+	g := coalesce.New(cloneRecord)
+	var calls atomic.Int32
+	started := make(chan struct{})
+
+	// The first caller gives up while its read is running
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error)
+	go func() {
+		_, err := g.Do(first, "lobby", func(ctx context.Context) (*record, error) {
+			calls.Add(1)
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		firstErr <- err
+	}()
+	<-started
+
+	waiter := make(chan *record)
+	go func() {
+		r, err := g.Do(context.Background(), "lobby", blockingRead(&calls, closed(), "lobby", nil))
+		assert.NoError(t, err)
+		waiter <- r
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	assert.ErrorIs(t, <-firstErr, context.Canceled)
+	assert.Equal(t, &record{Key: "lobby", Reads: 2}, <-waiter, "the waiter reads again under its own context")
+}
+
+func TestGroupWaitersGiveUpWithTheirContext(t *testing.T) {
+	g := coalesce.New(cloneRecord)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	defer close(release)
+	go func() {
+		_, _ = g.Do(context.Background(), "lobby", blockingRead(&calls, release, "lobby", nil))
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := g.Do(ctx, "lobby", blockingRead(&calls, release, "lobby", nil))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestNilGroupReadsEveryTime(t *testing.T) {
+	var g *coalesce.Group[*record]
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	_, _, wait := doConcurrently(t, g, 10, "lobby", blockingRead(&calls, release, "lobby", nil))
+	close(release)
+	wait()
+	assert.Equal(t, int32(10), calls.Load())
+}
+
+// closed returns a channel that never blocks
+func closed() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}
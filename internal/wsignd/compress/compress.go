@@ -0,0 +1,290 @@
+// Package compress compresses JSON API responses for clients that accept
+// gzip or deflate. Display lists for large fleets and event exports shrink
+// several times over, which matters on the slow links to remote sites.
+package compress
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+)
+
+// Content codings, as named in Accept-Encoding and Content-Encoding.
+// "deflate" is the zlib format (RFC 1950), not a raw deflate stream.
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
+// compressibleTypes are the media types worth compressing. Everything
+// else the server sends, such as uploaded images and video, is either
+// small or already compressed.
+var compressibleTypes = map[string]bool{
+	"application/json":     true,
+	"application/x-ndjson": true,
+}
+
+// encoder is the part of gzip.Writer and zlib.Writer the middleware uses
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressor holds the encoders of one configuration for reuse
+type compressor struct {
+	minSize int
+	pools   map[string]*sync.Pool
+}
+
+// Middleware compresses responses with a compressible content type for
+// clients that accept gzip or deflate, preferring gzip. Responses smaller
+// than the configured minimum are sent as they are unless the handler
+// flushes them first, as streaming handlers do; each flush then flushes
+// the encoder too, so streamed rows reach the client promptly. WebSocket
+// upgrades, HEAD requests and responses that already carry a
+// Content-Encoding pass through untouched. When compression is disabled
+// the middleware does nothing.
+func Middleware(cfg config.CompressionConfig) func(http.Handler) http.Handler {
+	if !cfg.Enabled {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	c := &compressor{
+		minSize: cfg.MinSize,
+		pools: map[string]*sync.Pool{
+			encodingGzip: {New: func() interface{} {
+				w, _ := gzip.NewWriterLevel(io.Discard, cfg.Level)
+				return w
+			}},
+			encodingDeflate: {New: func() interface{} {
+				w, _ := zlib.NewWriterLevel(io.Discard, cfg.Level)
+				return w
+			}},
+		},
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead || strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &responseWriter{
+				ResponseWriter: w,
+				c:              c,
+				encoding:       negotiate(r.Header.Get("Accept-Encoding")),
+			}
+			next.ServeHTTP(cw, r)
+			// Not deferred: a handler that aborts the response by
+			// panicking must not have its stream ended cleanly
+			cw.close()
+		})
+	}
+}
+
+// negotiate picks the coding to respond with from an Accept-Encoding
+// header: gzip or deflate, whichever has the higher quality, or none.
+// A wildcard stands for gzip unless gzip is listed itself.
+func negotiate(header string) string {
+	quality := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(strings.TrimSpace(name), "q") {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = v
+				}
+			}
+		}
+		quality[coding] = q
+	}
+	if _, ok := quality[encodingGzip]; !ok {
+		if q, ok := quality["*"]; ok {
+			quality[encodingGzip] = q
+		}
+	}
+
+	switch gzipQ, deflateQ := quality[encodingGzip], quality[encodingDeflate]; {
+	case gzipQ > 0 && gzipQ >= deflateQ:
+		return encodingGzip
+	case deflateQ > 0:
+		return encodingDeflate
+	}
+	return ""
+}
+
+// responseWriter holds back the start of a response until it knows
+// whether to compress it: once the body reaches the minimum size, the
+// handler flushes, or the handler returns
+type responseWriter struct {
+	http.ResponseWriter
+	c *compressor
+	// encoding is the coding the client accepts, empty for none
+	encoding string
+
+	status      int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	enc         encoder
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	// Informational responses go out as they come and the real one follows
+	if status < http.StatusOK {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+	w.wroteHeader = true
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < w.c.minSize {
+			return len(p), nil
+		}
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush starts the response, compressed if it may be however short it
+// turns out, and pushes what the encoder holds to the client
+func (w *responseWriter) Flush() {
+	_ = w.FlushError()
+}
+
+// FlushError is Flush reporting failures, as used by
+// http.ResponseController
+func (w *responseWriter) FlushError() error {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	// A flushed response is streamed and its final size unknown, so it is
+	// compressed however little has been written yet
+	if !w.decided {
+		if err := w.start(true); err != nil {
+			return err
+		}
+	}
+	if w.enc != nil {
+		if err := w.enc.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, for
+// write deadlines in particular
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// start writes the header and the buffered body, compressed if bigEnough
+// and the response and client allow it
+func (w *responseWriter) start(bigEnough bool) error {
+	w.decided = true
+	h := w.Header()
+
+	if w.compressible() {
+		// Whether the body is compressed depends on Accept-Encoding, so
+		// caches must key on it even when this response is not
+		addVary(h, "Accept-Encoding")
+		if bigEnough && w.encoding != "" {
+			h.Del("Content-Length")
+			h.Set("Content-Encoding", w.encoding)
+			// The compressed bytes differ from those a strong validator
+			// names
+			if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				h.Set("ETag", "W/"+etag)
+			}
+			w.enc = w.c.pools[w.encoding].Get().(encoder)
+			w.enc.Reset(w.ResponseWriter)
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.enc != nil {
+		_, err := w.enc.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// compressible reports whether the response may be compressed at all
+func (w *responseWriter) compressible() bool {
+	if w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		return false
+	}
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	return err == nil && compressibleTypes[mediaType]
+}
+
+// close finishes the response once the handler has returned
+func (w *responseWriter) close() {
+	if !w.wroteHeader {
+		// Nothing was written; net/http sends its empty 200
+		return
+	}
+	if !w.decided {
+		_ = w.start(len(w.buf) >= w.c.minSize)
+	}
+	if w.enc != nil {
+		_ = w.enc.Close()
+		w.enc.Reset(io.Discard)
+		w.c.pools[w.encoding].Put(w.enc)
+		w.enc = nil
+	}
+}
+
+// addVary adds a field to the Vary header unless it is already listed
+func addVary(h http.Header, field string) {
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f == "*" || strings.EqualFold(f, field) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", field)
+}
@@ -0,0 +1,282 @@
+package compress
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+)
+
+var testConfig = config.CompressionConfig{Enabled: true, Level: 5, MinSize: 1024}
+
+// get requests path with the given Accept-Encoding and returns the
+// response with its body decoded as its Content-Encoding says
+func get(t *testing.T, server *httptest.Server, path, acceptEncoding string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	require.NoError(t, err)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	// The transport would otherwise ask for gzip and decode it itself
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(decode(t, resp))
+	require.NoError(t, err)
+	return resp, body
+}
+
+func decode(t *testing.T, resp *http.Response) io.Reader {
+	t.Helper()
+	switch resp.Header.Get("Content-Encoding") {
+	case encodingGzip:
+		r, err := gzip.NewReader(resp.Body)
+		require.NoError(t, err)
+		return r
+	case encodingDeflate:
+		r, err := zlib.NewReader(resp.Body)
+		require.NoError(t, err)
+		return r
+	}
+	return resp.Body
+}
+
+func TestMiddleware(t *testing.T) {
+	type item struct {
+		Name string `json:"name"`
+		Site string `json:"site"`
+	}
+	list := make([]item, 500)
+	for i := range list {
+		list[i] = item{Name: fmt.Sprintf("display-%d", i), Site: "hq"}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/list", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("ETag", `"v1"`)
+		_ = json.NewEncoder(w).Encode(list)
+	})
+	mux.HandleFunc("/small", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"ok":true}`)
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(make([]byte, 4096))
+	})
+	mux.HandleFunc("/encoded", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "br")
+		_, _ = w.Write(make([]byte, 4096))
+	})
+	mux.HandleFunc("/created", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Vary", "Authorization")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(list)
+	})
+	server := httptest.NewServer(Middleware(testConfig)(mux))
+	defer server.Close()
+
+	identity, plain := get(t, server, "/list", "")
+	assert.Empty(t, identity.Header.Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", identity.Header.Get("Vary"))
+	assert.Equal(t, `"v1"`, identity.Header.Get("ETag"))
+
+	for _, accept := range []string{"gzip", "deflate", "deflate, gzip;q=0.5", "*", "br;q=1.0, gzip;q=0.8"} {
+		t.Run(accept, func(t *testing.T) {
+			resp, body := get(t, server, "/list", accept)
+			assert.NotEmpty(t, resp.Header.Get("Content-Encoding"))
+			assert.Equal(t, negotiate(accept), resp.Header.Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+			assert.Equal(t, `W/"v1"`, resp.Header.Get("ETag"), "compressed bytes are not those the strong ETag named")
+			assert.Equal(t, plain, body, "decoded bodies are identical")
+		})
+	}
+
+	t.Run("compressed bodies are smaller", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/list", nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := (&http.Client{Transport: &http.Transport{DisableCompression: true}}).Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		raw, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Less(t, len(raw)*4, len(plain))
+	})
+
+	t.Run("small responses are sent as they are", func(t *testing.T) {
+		resp, body := get(t, server, "/small", "gzip")
+		assert.Empty(t, resp.Header.Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+		assert.Equal(t, `{"ok":true}`, string(body))
+	})
+
+	t.Run("other content types are untouched", func(t *testing.T) {
+		resp, body := get(t, server, "/image", "gzip")
+		assert.Empty(t, resp.Header.Get("Content-Encoding"))
+		assert.Empty(t, resp.Header.Get("Vary"))
+		assert.Len(t, body, 4096)
+
+		resp, _ = get(t, server, "/encoded", "gzip")
+		assert.Equal(t, "br", resp.Header.Get("Content-Encoding"), "already encoded")
+	})
+
+	t.Run("status and existing Vary are kept", func(t *testing.T) {
+		resp, body := get(t, server, "/created", "gzip")
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, encodingGzip, resp.Header.Get("Content-Encoding"))
+		assert.Equal(t, []string{"Authorization", "Accept-Encoding"}, resp.Header.Values("Vary"))
+		assert.Equal(t, plain, body)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		off := httptest.NewServer(Middleware(config.CompressionConfig{})(mux))
+		defer off.Close()
+		resp, body := get(t, off, "/list", "gzip")
+		assert.Empty(t, resp.Header.Get("Content-Encoding"))
+		assert.Equal(t, plain, body)
+	})
+}
+
+func TestNegotiate(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                          "",
+		"identity":                  "",
+		"gzip":                      encodingGzip,
+		"GZIP":                      encodingGzip,
+		"deflate":                   encodingDeflate,
+		"gzip, deflate":             encodingGzip,
+		"deflate;q=1, gzip;q=0.9":   encodingDeflate,
+		"gzip;q=0, deflate":         encodingDeflate,
+		"gzip;q=0":                  "",
+		"*":                         encodingGzip,
+		"*;q=0.5, deflate;q=0.8":    encodingDeflate,
+		"gzip;q=0, *":               "",
+		"br, zstd":                  "",
+		" gzip ; q=0.3 , deflate ;": encodingDeflate,
+	} {
+		assert.Equal(t, want, negotiate(header), "Accept-Encoding: %q", header)
+	}
+}
+
+func TestMiddleware_StreamsFlushedRows(t *testing.T) {
+	const batches = 5
+	next := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		rc := http.NewResponseController(w)
+		for i := 0; i < batches; i++ {
+			if i > 0 {
+				// The next batch is only written once the client has read
+				// this one, so a response held back in the encoder stalls
+				select {
+				case <-next:
+				case <-r.Context().Done():
+					return
+				}
+			}
+			fmt.Fprintf(w, "{\"batch\":%d}\n", i)
+			assert.NoError(t, rc.Flush())
+		}
+	})
+	server := httptest.NewServer(Middleware(testConfig)(handler))
+	defer server.Close()
+
+	for _, accept := range []string{"gzip", "deflate", ""} {
+		t.Run("accept "+accept, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			require.NoError(t, err)
+			if accept != "" {
+				req.Header.Set("Accept-Encoding", accept)
+			}
+			client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, accept, resp.Header.Get("Content-Encoding"), "streamed rows are compressed however short")
+
+			lines := make(chan string)
+			go func() {
+				defer close(lines)
+				scanner := bufio.NewScanner(decode(t, resp))
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+			}()
+			for i := 0; i < batches; i++ {
+				select {
+				case line := <-lines:
+					assert.Equal(t, fmt.Sprintf(`{"batch":%d}`, i), line)
+				case <-time.After(2 * time.Second):
+					t.Fatalf("batch %d was not flushed to the client", i)
+				}
+				if i < batches-1 {
+					next <- struct{}{}
+				}
+			}
+			_, more := <-lines
+			assert.False(t, more)
+		})
+	}
+}
+
+func TestMiddleware_AbortedStreamIsNotEndedCleanly(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		_, _ = io.WriteString(w, strings.Repeat("{\"row\":1}\n", 200))
+		http.NewResponseController(w).Flush()
+		panic(http.ErrAbortHandler)
+	})
+	server := httptest.NewServer(Middleware(testConfig)(handler))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	_, err = io.ReadAll(decode(t, resp))
+	assert.Error(t, err, "the client sees a truncated stream")
+}
+
+func TestMiddleware_PassesWebSocketUpgrades(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(Middleware(testConfig)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(strings.Repeat("x", 4096)))
+	})))
+	defer server.Close()
+
+	dialer := websocket.Dialer{}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), http.Header{"Accept-Encoding": {"gzip"}})
+	require.NoError(t, err)
+	defer conn.Close()
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Len(t, msg, 4096)
+}
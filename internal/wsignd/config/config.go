@@ -3,18 +3,52 @@ package config
 
 import (
 	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// Server modes
+const (
+	// ModeServer runs against Postgres
+	ModeServer = "server"
+	// ModeDemo runs against seeded in-memory repositories. Nothing survives
+	// a restart.
+	ModeDemo = "demo"
+)
+
 // Config holds all configuration for the server
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Auth     AuthConfig
-	Content  ContentConfig
+	Mode      string
+	Log       LogConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Auth      AuthConfig
+	Content   ContentConfig
+	Display   DisplayConfig
+	RateLimit RateLimitConfig
+
+	// Properties bounds the properties of displays and content sources
+	Properties PropertyLimits
+
+	// settings records where Load found each value
+	settings []Setting
+	// warnings describe settings that are valid but probably not what
+	// was meant
+	warnings []string
+}
+
+// LogConfig holds logging settings
+type LogConfig struct {
+	Level slog.Level // least severe level logged
+	// SampleEvery logs one in every SampleEvery debug records with the same
+	// message, thinning out routine successes such as display check-ins.
+	// Values below 2 log every record.
+	SampleEvery int
 }
 
 // ServerConfig holds HTTP server settings
@@ -26,165 +60,1130 @@ type ServerConfig struct {
 	IdleTimeout  time.Duration
 	TLSCert      string
 	TLSKey       string
+	Overload     OverloadConfig
+	Shutdown     ShutdownConfig
+	WebSocket    WebSocketConfig
+	Compression  CompressionConfig
+
+	// ListenAddresses are host:port addresses the server listens on, one
+	// listener each, in place of Host and Port. Each IP address is bound
+	// in its own family only, so "0.0.0.0:8080" and "[::]:8080" may be
+	// listed together to serve IPv4 and IPv6.
+	ListenAddresses []string
+}
+
+// CompressionConfig decides which API responses are compressed for clients
+// that accept gzip or deflate. Only JSON and NDJSON bodies are compressed;
+// WebSocket upgrades and content that is already encoded never are.
+type CompressionConfig struct {
+	Enabled bool
+	Level   int // 1 (fastest) to 9 (smallest)
+	MinSize int // bytes a response must reach to be compressed; streamed responses always are
+}
+
+// WebSocketConfig sizes the display control sockets. Messages larger than
+// the write buffer are sent as several frames, and displays may fragment
+// theirs; the message size limits apply to the reassembled message.
+type WebSocketConfig struct {
+	ReadBufferSize         int // bytes buffered per connection for reading frames
+	WriteBufferSize        int // bytes buffered per connection for writing frames
+	MaxMessageSize         int // largest message accepted from a display
+	MaxOutboundMessageSize int // largest control message sent to a display
+}
+
+// ShutdownConfig bounds each stage of an orderly shutdown. The listener is
+// closed first, then control sockets are drained, then in-flight requests
+// are waited for, and only then are stores closed.
+type ShutdownConfig struct {
+	HubTimeout     time.Duration // how long displays get to disconnect
+	RequestTimeout time.Duration // how long in-flight requests get to finish
+	// ReconnectSpread is the window displays are asked to spread their
+	// reconnects over when their sockets are drained, so that a rolling
+	// deploy does not send the whole fleet to the first new server up
+	ReconnectSpread time.Duration
+}
+
+// OverloadConfig bounds concurrent work so that a saturated server sheds
+// requests with 503 instead of queueing them without limit
+type OverloadConfig struct {
+	MaxInFlight          int           // concurrent cheap requests, such as heartbeats
+	MaxExpensiveInFlight int           // concurrent expensive requests, such as metrics
+	MaxWebSockets        int           // open WebSocket connections
+	MaxQueueWait         time.Duration // how long a request may wait for a slot
+	// WebSocketRetryAfter is how long displays refused a WebSocket for
+	// want of a slot are told to wait before trying again
+	WebSocketRetryAfter time.Duration
 }
 
+// DefaultApplicationName identifies the server's connections in
+// pg_stat_activity unless Options names something else
+const DefaultApplicationName = "wsignd"
+
 // DatabaseConfig holds database connection settings
 type DatabaseConfig struct {
-	Host            string
-	Port            int
-	Name            string
-	User            string
-	Password        string
-	SSLMode         string
+	// URL is a complete connection string, either a postgres:// URL or
+	// key=value pairs. When set it is used verbatim and the fields below
+	// up to Options are ignored.
+	URL string
+
+	Host     string
+	Port     int
+	Name     string
+	User     string
+	Password string
+	SSLMode  string
+	// Options are extra connection parameters such as connect_timeout or
+	// search_path, added after the fields above so they can override them
+	Options map[string]string
+
 	MaxOpenConns    int
 	MaxIdleConns    int
 	ConnMaxLifetime time.Duration
+
+	// ConnectAttempts is how many times startup tries to reach a database
+	// that is not answering yet, waiting ConnectBackoff after the first
+	// failure and doubling the wait after each one after that
+	ConnectAttempts int
+	ConnectBackoff  time.Duration
+
+	// MigrationLockTimeout is how long startup waits for another replica
+	// that is applying migrations
+	MigrationLockTimeout time.Duration
+
+	// ReplicaURL is an optional connection string for a read-only replica.
+	// When set, listings, statistics and metrics are read from it and may
+	// lag behind recent writes; everything else uses the primary. The
+	// replica shares the pool settings above.
+	ReplicaURL string
+
+	// SlowQueryThreshold is how long a statement or transaction may run
+	// before it is logged as slow; zero disables the warnings
+	SlowQueryThreshold time.Duration
+
+	// StatementTimeout is how long the server lets any one statement run
+	// before cancelling it, set on every pooled connection; zero leaves the
+	// server's own setting. Migrations are exempt.
+	StatementTimeout time.Duration
+	// JobTimeout bounds each run of a background job, such as the offline
+	// reaper or token cleanup; zero lets a run take as long as it needs
+	JobTimeout time.Duration
+
+	// CoalesceReads shares identical display and content source lookups
+	// that are in flight at the same time, so that displays reconnecting
+	// together do not repeat the same query. Disabling it can help when
+	// debugging stale reads.
+	CoalesceReads bool
+}
+
+// Replica returns the settings for connecting to the read replica, and
+// false when none is configured
+func (c DatabaseConfig) Replica() (DatabaseConfig, bool) {
+	if c.ReplicaURL == "" {
+		return DatabaseConfig{}, false
+	}
+	replica := c
+	replica.URL = c.ReplicaURL
+	replica.ReplicaURL = ""
+	return replica, true
+}
+
+// ConnString returns the connection string for the database: URL when it
+// is set, otherwise a postgres:// URL assembled from the individual fields
+// and Options.
+func (c DatabaseConfig) ConnString() string {
+	if c.URL != "" {
+		return c.URL
+	}
+
+	query := url.Values{}
+	query.Set("sslmode", c.SSLMode)
+	query.Set("application_name", DefaultApplicationName)
+	for k, v := range c.Options {
+		query.Set(k, v)
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		Host:     net.JoinHostPort(c.Host, strconv.Itoa(c.Port)),
+		Path:     "/" + c.Name,
+		RawQuery: query.Encode(),
+	}
+	if c.Password != "" {
+		u.User = url.UserPassword(c.User, c.Password)
+	} else {
+		u.User = url.User(c.User)
+	}
+	return u.String()
 }
 
 // AuthConfig holds authentication settings
 type AuthConfig struct {
-	TokenSigningKey  string
+	TokenSigningKey  string             // legacy HMAC secret, used when SigningKeys is empty
+	SigningKeys      []SigningKeyConfig // primary key first
 	TokenExpiry      time.Duration
 	DeviceCodeExpiry time.Duration
+	// EnrollmentTokenExpiry is how long the enrollment token in a display
+	// provisioning file stays usable
+	EnrollmentTokenExpiry time.Duration
+}
+
+// SigningKeyConfig describes one token signing key
+type SigningKeyConfig struct {
+	ID        string
+	Algorithm string // HS256 or EdDSA
+	Key       string // base64 secret (HS256) or seed (EdDSA)
 }
 
 // ContentConfig holds content delivery settings
 type ContentConfig struct {
-	StoragePath  string
-	MaxCacheSize int64
-	DefaultTTL   time.Duration
+	StoragePath        string // root of the local blob store
+	MaxCacheSize       int64
+	DefaultTTL         time.Duration
+	ValidationInterval time.Duration // zero disables periodic revalidation
+	ValidationTimeout  time.Duration
+	MetricsWindow      time.Duration
+
+	// Content events older than EventRetention are deleted, or archived
+	// first when EventArchive is set, EventPruneBatchSize at a time so that
+	// no transaction holds locks for long. Zero keeps events forever.
+	EventRetention         time.Duration
+	EventArchive           bool // write pruned events to NDJSON files below StoragePath before deleting them
+	EventPruneBatchSize    int
+	EventRetentionInterval time.Duration // how often events are pruned and partitions prepared; zero disables both
+
+	Storage StorageConfig
+}
+
+// Blob storage backends
+const (
+	// StorageLocal keeps content blobs below StoragePath
+	StorageLocal = "local"
+	// StorageS3 keeps content blobs in an S3-compatible bucket shared by
+	// every replica
+	StorageS3 = "s3"
+)
+
+// StorageConfig selects where content blobs such as cached pages and
+// screenshots are kept
+type StorageConfig struct {
+	Backend string // local or s3
+	S3      S3Config
+}
+
+// S3Config locates the bucket used by the s3 storage backend
+type S3Config struct {
+	Endpoint        string // empty for AWS S3 in Region
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	PathStyle       bool // address the bucket as <endpoint>/<bucket>, as MinIO and most self-hosted services expect
+}
+
+// MaxContentHistorySize is the largest content history a display may keep,
+// so that a misconfiguration cannot grow the history table without bound
+const MaxContentHistorySize = 200
+
+// DisplayConfig holds display management settings
+type DisplayConfig struct {
+	ContentHistorySize  int // transitions kept per display, at most MaxContentHistorySize
+	SendQueueSize       int // messages buffered per WebSocket connection
+	MaxConsecutiveDrops int // queue overflows in a row before a connection is closed
+
+	OfflineAfter       time.Duration // silence before a display is considered gone
+	OfflineGracePeriod time.Duration // extra silence tolerated before marking it offline
+
+	// ApprovalSites lists the sites whose activated displays wait for an
+	// operator's approval before they are issued tokens; "*" covers every
+	// site. A display must be approved before its device code expires.
+	ApprovalSites []string
+
+	// ActivationSitePolicy decides where device codes that a display did
+	// not bind to a site may be activated: anywhere when permissive, nowhere
+	// when strict, where displays must name their site to get a code
+	ActivationSitePolicy string
+
+	StateSnapshotInterval  time.Duration // how often per-site state counts are recorded; zero disables
+	StateSnapshotRetention time.Duration // how long state snapshots are kept; zero keeps them all
+
+	LocationProposalMaxAge time.Duration // how long a location proposal waits for an operator before expiring
+
+	// Polling hints handed to displays: how often to check in and to look
+	// for new content. PollingSites overrides them by site ID.
+	HeartbeatInterval   time.Duration
+	ContentPollInterval time.Duration
+	PollingSites        map[string]PollingIntervals
+
+	OutboxTTL              time.Duration // how long control messages for disconnected displays are queued; zero disables the outbox
+	OutboxDeliveryInterval time.Duration // how often queued messages are checked for displays connected to this server
+
+	// Caps on registered displays; zero means no cap. SiteLimits overrides
+	// MaxPerSite by site ID and ZoneLimits overrides MaxPerZone by
+	// site/zone.
+	MaxPerSite int
+	MaxPerZone int
+	SiteLimits map[string]int
+	ZoneLimits map[string]int
+
+	// Time zones that assignment schedules are read in when they name none
+	// of their own: Timezone by default, SiteTimezones by site ID. Both
+	// hold IANA names such as "America/New_York".
+	Timezone      string
+	SiteTimezones map[string]string
+
+	ScheduleCheckInterval time.Duration // how often schedules are checked for windows opening or closing
+	ScheduleJitter        time.Duration // longest random delay before a display is told to reload at a transition
+
+	// AutoAssign lists the rules assigning content to displays as they are
+	// activated, in the order they are tried; the first matching a display
+	// applies
+	AutoAssign []AutoAssignRule
+}
+
+// AutoAssignRule assigns a content source to the activated displays with
+// one location field or property. Exactly one of SiteID, Zone and
+// Property is set.
+type AutoAssignRule struct {
+	SiteID   string // matches displays at this site
+	Zone     string // matches displays in this zone, whatever their site
+	Property string // matches displays with this property set to Value
+	Value    string
+	Source   string // name of the content source assigned
+}
+
+// PollingIntervals overrides the polling hints for one site. A zero
+// interval keeps the default.
+type PollingIntervals struct {
+	Heartbeat   time.Duration
+	ContentPoll time.Duration
+}
+
+// Activation site policies
+const (
+	// ActivationSitesPermissive lets unbound device codes be activated
+	// into any site
+	ActivationSitesPermissive = "permissive"
+	// ActivationSitesStrict requires every device code to be bound to a site
+	ActivationSitesStrict = "strict"
+)
+
+// RateLimitConfig holds per-client rate limits. Zero keeps a limit's default.
+type RateLimitConfig struct {
+	WSConnectionsPerMinute int // WebSocket connection attempts per client
+	WSMessagesInPerMinute  int // messages a display may send over its socket
+	WSMessagesOutPerMinute int // control messages that may be sent to a display
+
+	Profiles map[string]RateLimitProfile // named limits, replacing the default of a profile with the same name
+	Routes   map[string]string           // route group to the profile it is counted against, for groups not using their own name
+
+	Breaker BreakerConfig
+}
+
+// BreakerConfig controls the circuit breaker around the rate limit store.
+// While the circuit is open limits are not checked against the store:
+// requests are allowed, except for the limit types listed in FailClosed,
+// which are denied.
+type BreakerConfig struct {
+	Failures     int           // consecutive store errors that open the circuit; zero disables
+	ErrorPercent int           // percentage of failed calls within Window that opens the circuit; zero disables
+	MinCalls     int           // calls within Window before ErrorPercent applies
+	Window       time.Duration // window over which ErrorPercent is measured
+	Cooldown     time.Duration // how long the circuit stays open before a probe call is let through
+	FailClosed   []string      // limit types denied rather than allowed while the circuit is open
+}
+
+// RateLimitProfile is a named limit defined in configuration
+type RateLimitProfile struct {
+	Rate   int           // requests allowed per Period
+	Period time.Duration // window over which Rate applies
+	Burst  int           // requests allowed at once; zero allows Rate
+}
+
+// PropertyLimits bound the free-form properties stored with a display or
+// content source, which are read along with it on every lookup. A zero
+// limit is not enforced.
+type PropertyLimits struct {
+	MaxCount       int // properties per display or source
+	MaxKeyLength   int // bytes in a key
+	MaxValueLength int // bytes in a value
+	MaxTotalSize   int // bytes of all the properties encoded as a JSON object
+}
+
+// DefaultPropertyLimits returns the limits enforced unless configured
+// otherwise
+func DefaultPropertyLimits() PropertyLimits {
+	return PropertyLimits{
+		MaxCount:       64,
+		MaxKeyLength:   63,
+		MaxValueLength: 1024,
+		MaxTotalSize:   16 * 1024,
+	}
 }
 
 // Load creates a new Config from environment variables
 func Load() (*Config, error) {
-	cfg := &Config{}
+	l := &loader{}
+	cfg := &Config{
+		Mode: l.getEnv("WSIGN_MODE", ModeServer),
+	}
+
+	// Load logging config
+	cfg.Log.SampleEvery = l.getEnvAsInt("WSIGN_LOG_SAMPLE_EVERY", 1)
+	if err := cfg.Log.Level.UnmarshalText([]byte(l.getEnv("WSIGN_LOG_LEVEL", "info"))); err != nil {
+		return nil, fmt.Errorf("invalid WSIGN_LOG_LEVEL: use debug, info, warn or error")
+	}
 
 	// Load server config
 	cfg.Server = ServerConfig{
-		Host:         getEnv("WSIGN_SERVER_HOST", "0.0.0.0"),
-		Port:         getEnvAsInt("WSIGN_SERVER_PORT", 8080),
-		ReadTimeout:  getEnvAsDuration("WSIGN_SERVER_READ_TIMEOUT", 5*time.Second),
-		WriteTimeout: getEnvAsDuration("WSIGN_SERVER_WRITE_TIMEOUT", 10*time.Second),
-		IdleTimeout:  getEnvAsDuration("WSIGN_SERVER_IDLE_TIMEOUT", 120*time.Second),
-		TLSCert:      getEnv("WSIGN_TLS_CERT", ""),
-		TLSKey:       getEnv("WSIGN_TLS_KEY", ""),
+		Host:         l.getEnv("WSIGN_SERVER_HOST", "0.0.0.0"),
+		Port:         l.getEnvAsInt("WSIGN_SERVER_PORT", 8080),
+		ReadTimeout:  l.getEnvAsDuration("WSIGN_SERVER_READ_TIMEOUT", 5*time.Second),
+		WriteTimeout: l.getEnvAsDuration("WSIGN_SERVER_WRITE_TIMEOUT", 10*time.Second),
+		IdleTimeout:  l.getEnvAsDuration("WSIGN_SERVER_IDLE_TIMEOUT", 120*time.Second),
+		TLSCert:      l.getEnv("WSIGN_TLS_CERT", ""),
+		TLSKey:       l.getEnv("WSIGN_TLS_KEY", ""),
+		Overload: OverloadConfig{
+			MaxInFlight:          l.getEnvAsInt("WSIGN_SERVER_OVERLOAD_MAX_IN_FLIGHT", 512),
+			MaxExpensiveInFlight: l.getEnvAsInt("WSIGN_SERVER_OVERLOAD_MAX_EXPENSIVE_IN_FLIGHT", 32),
+			MaxWebSockets:        l.getEnvAsInt("WSIGN_SERVER_OVERLOAD_MAX_WEBSOCKETS", 10000),
+			MaxQueueWait:         l.getEnvAsDuration("WSIGN_SERVER_OVERLOAD_MAX_QUEUE_WAIT", 100*time.Millisecond),
+			WebSocketRetryAfter:  l.getEnvAsDuration("WSIGN_SERVER_OVERLOAD_WEBSOCKET_RETRY_AFTER", 10*time.Second),
+		},
+		Shutdown: ShutdownConfig{
+			HubTimeout:      l.getEnvAsDuration("WSIGN_SERVER_SHUTDOWN_HUB_TIMEOUT", 10*time.Second),
+			RequestTimeout:  l.getEnvAsDuration("WSIGN_SERVER_SHUTDOWN_REQUEST_TIMEOUT", 30*time.Second),
+			ReconnectSpread: l.getEnvAsDuration("WSIGN_SERVER_SHUTDOWN_RECONNECT_SPREAD", 15*time.Second),
+		},
+		WebSocket: WebSocketConfig{
+			ReadBufferSize:         l.getEnvAsInt("WSIGN_SERVER_WEBSOCKET_READ_BUFFER_SIZE", 4*1024),
+			WriteBufferSize:        l.getEnvAsInt("WSIGN_SERVER_WEBSOCKET_WRITE_BUFFER_SIZE", 4*1024),
+			MaxMessageSize:         l.getEnvAsInt("WSIGN_SERVER_WEBSOCKET_MAX_MESSAGE_SIZE", 32*1024),
+			MaxOutboundMessageSize: l.getEnvAsInt("WSIGN_SERVER_WEBSOCKET_MAX_OUTBOUND_MESSAGE_SIZE", 256*1024),
+		},
+		Compression: CompressionConfig{
+			Enabled: l.getEnvAsBool("WSIGN_SERVER_COMPRESSION_ENABLED", true),
+			Level:   l.getEnvAsInt("WSIGN_SERVER_COMPRESSION_LEVEL", 5),
+			MinSize: l.getEnvAsInt("WSIGN_SERVER_COMPRESSION_MIN_SIZE", 1024),
+		},
 	}
 
+	cfg.Server.ListenAddresses = l.getEnvAsSlice("WSIGN_SERVER_LISTEN_ADDRESSES", nil, ",")
+
 	// Load database config
+	dbOptions, err := parseDatabaseOptions(l.getEnv("WSIGN_DB_OPTIONS", ""))
+	if err != nil {
+		return nil, err
+	}
 	cfg.Database = DatabaseConfig{
-		Host:            getEnv("WSIGN_DB_HOST", "localhost"),
-		Port:            getEnvAsInt("WSIGN_DB_PORT", 5432),
-		Name:            getEnv("WSIGN_DB_NAME", "wrale_signage"),
-		User:            getEnv("WSIGN_DB_USER", "postgres"),
-		Password:        getEnv("WSIGN_DB_PASSWORD", ""),
-		SSLMode:         getEnv("WSIGN_DB_SSLMODE", "disable"),
-		MaxOpenConns:    getEnvAsInt("WSIGN_DB_MAX_OPEN_CONNS", 25),
-		MaxIdleConns:    getEnvAsInt("WSIGN_DB_MAX_IDLE_CONNS", 25),
-		ConnMaxLifetime: getEnvAsDuration("WSIGN_DB_CONN_MAX_LIFETIME", 5*time.Minute),
+		URL:             l.getEnv("WSIGN_DB_URL", ""),
+		Host:            l.getEnv("WSIGN_DB_HOST", "localhost"),
+		Port:            l.getEnvAsInt("WSIGN_DB_PORT", 5432),
+		Name:            l.getEnv("WSIGN_DB_NAME", "wrale_signage"),
+		User:            l.getEnv("WSIGN_DB_USER", "postgres"),
+		Password:        l.getEnv("WSIGN_DB_PASSWORD", ""),
+		SSLMode:         l.getEnv("WSIGN_DB_SSLMODE", "disable"),
+		Options:         dbOptions,
+		MaxOpenConns:    l.getEnvAsInt("WSIGN_DB_MAX_OPEN_CONNS", 25),
+		MaxIdleConns:    l.getEnvAsInt("WSIGN_DB_MAX_IDLE_CONNS", 25),
+		ConnMaxLifetime: l.getEnvAsDuration("WSIGN_DB_CONN_MAX_LIFETIME", 5*time.Minute),
+
+		ConnectAttempts: l.getEnvAsInt("WSIGN_DB_CONNECT_ATTEMPTS", 5),
+		ConnectBackoff:  l.getEnvAsDuration("WSIGN_DB_CONNECT_BACKOFF", time.Second),
+
+		MigrationLockTimeout: l.getEnvAsDuration("WSIGN_DB_MIGRATION_LOCK_TIMEOUT", 5*time.Minute),
+
+		ReplicaURL: l.getEnv("WSIGN_DB_REPLICA_URL", ""),
+
+		SlowQueryThreshold: l.getEnvAsDuration("WSIGN_DB_SLOW_QUERY_THRESHOLD", 500*time.Millisecond),
+		StatementTimeout:   l.getEnvAsDuration("WSIGN_DB_STATEMENT_TIMEOUT", 30*time.Second),
+		JobTimeout:         l.getEnvAsDuration("WSIGN_DB_JOB_TIMEOUT", 5*time.Minute),
+		CoalesceReads:      l.getEnvAsBool("WSIGN_DB_COALESCE_READS", true),
 	}
 
 	// Load auth config
+	signingKeys, err := parseSigningKeys(l.getEnv("WSIGN_AUTH_SIGNING_KEYS", ""))
+	if err != nil {
+		return nil, err
+	}
 	cfg.Auth = AuthConfig{
-		TokenSigningKey:  getEnvRequired("WSIGN_AUTH_TOKEN_KEY"),
-		TokenExpiry:      getEnvAsDuration("WSIGN_AUTH_TOKEN_EXPIRY", 1*time.Hour),
-		DeviceCodeExpiry: getEnvAsDuration("WSIGN_AUTH_DEVICE_CODE_EXPIRY", 15*time.Minute),
+		TokenSigningKey:  l.getEnv("WSIGN_AUTH_TOKEN_KEY", ""),
+		SigningKeys:      signingKeys,
+		TokenExpiry:      l.getEnvAsDuration("WSIGN_AUTH_TOKEN_EXPIRY", 1*time.Hour),
+		DeviceCodeExpiry: l.getEnvAsDuration("WSIGN_AUTH_DEVICE_CODE_EXPIRY", 15*time.Minute),
+		// Provisioning files can wait weeks on a USB stick for installation
+		EnrollmentTokenExpiry: l.getEnvAsDuration("WSIGN_AUTH_ENROLLMENT_TOKEN_EXPIRY", 30*24*time.Hour),
 	}
 
 	// Load content config
 	cfg.Content = ContentConfig{
-		StoragePath:  getEnv("WSIGN_CONTENT_PATH", "/var/lib/wrale-signage/content"),
-		MaxCacheSize: getEnvAsInt64("WSIGN_CONTENT_CACHE_SIZE", 1024*1024*1024), // 1GB
-		DefaultTTL:   getEnvAsDuration("WSIGN_CONTENT_TTL", 1*time.Hour),
+		StoragePath:        l.getEnv("WSIGN_CONTENT_PATH", "/var/lib/wrale-signage/content"),
+		MaxCacheSize:       l.getEnvAsInt64("WSIGN_CONTENT_CACHE_SIZE", 1024*1024*1024), // 1GB
+		DefaultTTL:         l.getEnvAsDuration("WSIGN_CONTENT_TTL", 1*time.Hour),
+		ValidationInterval: l.getEnvAsDuration("WSIGN_CONTENT_VALIDATION_INTERVAL", 15*time.Minute),
+		ValidationTimeout:  l.getEnvAsDuration("WSIGN_CONTENT_VALIDATION_TIMEOUT", 10*time.Second),
+		MetricsWindow:      l.getEnvAsDuration("WSIGN_CONTENT_METRICS_WINDOW", 1*time.Hour),
+
+		EventRetention:         l.getEnvAsDuration("WSIGN_CONTENT_EVENT_RETENTION", 0),
+		EventArchive:           l.getEnvAsBool("WSIGN_CONTENT_EVENT_ARCHIVE", false),
+		EventPruneBatchSize:    l.getEnvAsInt("WSIGN_CONTENT_EVENT_PRUNE_BATCH_SIZE", 5000),
+		EventRetentionInterval: l.getEnvAsDuration("WSIGN_CONTENT_EVENT_RETENTION_INTERVAL", 1*time.Hour),
+
+		Storage: StorageConfig{
+			Backend: l.getEnv("WSIGN_CONTENT_STORAGE_BACKEND", StorageLocal),
+			S3: S3Config{
+				Endpoint:        l.getEnv("WSIGN_CONTENT_S3_ENDPOINT", ""),
+				Region:          l.getEnv("WSIGN_CONTENT_S3_REGION", "us-east-1"),
+				Bucket:          l.getEnv("WSIGN_CONTENT_S3_BUCKET", ""),
+				AccessKeyID:     l.getEnv("WSIGN_CONTENT_S3_ACCESS_KEY_ID", ""),
+				SecretAccessKey: l.getEnv("WSIGN_CONTENT_S3_SECRET_ACCESS_KEY", ""),
+				SessionToken:    l.getEnv("WSIGN_CONTENT_S3_SESSION_TOKEN", ""),
+				PathStyle:       l.getEnvAsBool("WSIGN_CONTENT_S3_PATH_STYLE", false),
+			},
+		},
+	}
+
+	// Load display config
+	cfg.Display = DisplayConfig{
+		ContentHistorySize:  l.getEnvAsInt("WSIGN_DISPLAY_CONTENT_HISTORY_SIZE", 20),
+		SendQueueSize:       l.getEnvAsInt("WSIGN_DISPLAY_SEND_QUEUE_SIZE", 256),
+		MaxConsecutiveDrops: l.getEnvAsInt("WSIGN_DISPLAY_MAX_CONSECUTIVE_DROPS", 64),
+		OfflineAfter:        l.getEnvAsDuration("WSIGN_DISPLAY_OFFLINE_AFTER", 2*time.Minute),
+		OfflineGracePeriod:  l.getEnvAsDuration("WSIGN_DISPLAY_OFFLINE_GRACE_PERIOD", 30*time.Second),
+		ApprovalSites:       l.getEnvAsSlice("WSIGN_DISPLAY_APPROVAL_SITES", nil, ","),
+
+		ActivationSitePolicy: l.getEnv("WSIGN_DISPLAY_ACTIVATION_SITE_POLICY", ActivationSitesPermissive),
+
+		StateSnapshotInterval:  l.getEnvAsDuration("WSIGN_DISPLAY_STATE_SNAPSHOT_INTERVAL", 15*time.Minute),
+		StateSnapshotRetention: l.getEnvAsDuration("WSIGN_DISPLAY_STATE_SNAPSHOT_RETENTION", 90*24*time.Hour),
+		LocationProposalMaxAge: l.getEnvAsDuration("WSIGN_DISPLAY_LOCATION_PROPOSAL_MAX_AGE", 7*24*time.Hour),
+
+		HeartbeatInterval:   l.getEnvAsDuration("WSIGN_DISPLAY_HEARTBEAT_INTERVAL", time.Minute),
+		ContentPollInterval: l.getEnvAsDuration("WSIGN_DISPLAY_CONTENT_POLL_INTERVAL", 5*time.Minute),
+
+		OutboxTTL:              l.getEnvAsDuration("WSIGN_DISPLAY_OUTBOX_TTL", 0),
+		OutboxDeliveryInterval: l.getEnvAsDuration("WSIGN_DISPLAY_OUTBOX_DELIVERY_INTERVAL", 15*time.Second),
+
+		MaxPerSite: l.getEnvAsInt("WSIGN_DISPLAY_MAX_PER_SITE", 0),
+		MaxPerZone: l.getEnvAsInt("WSIGN_DISPLAY_MAX_PER_ZONE", 0),
+
+		Timezone:              l.getEnv("WSIGN_DISPLAY_TIMEZONE", "UTC"),
+		ScheduleCheckInterval: l.getEnvAsDuration("WSIGN_DISPLAY_SCHEDULE_CHECK_INTERVAL", 30*time.Second),
+		ScheduleJitter:        l.getEnvAsDuration("WSIGN_DISPLAY_SCHEDULE_JITTER", 10*time.Second),
+	}
+	if cfg.Display.SiteLimits, err = parseDisplayLimits(l.getEnv("WSIGN_DISPLAY_SITE_LIMITS", ""), false); err != nil {
+		return nil, err
+	}
+	if cfg.Display.ZoneLimits, err = parseDisplayLimits(l.getEnv("WSIGN_DISPLAY_ZONE_LIMITS", ""), true); err != nil {
+		return nil, err
+	}
+	if cfg.Display.PollingSites, err = parsePollingSites(l.getEnv("WSIGN_DISPLAY_POLLING_SITES", "")); err != nil {
+		return nil, err
+	}
+	if cfg.Display.SiteTimezones, err = parseSiteTimezones(l.getEnv("WSIGN_DISPLAY_SITE_TIMEZONES", "")); err != nil {
+		return nil, err
+	}
+	if cfg.Display.AutoAssign, err = parseAutoAssignRules(l.getEnv("WSIGN_DISPLAY_AUTO_ASSIGN", "")); err != nil {
+		return nil, err
+	}
+
+	// Load rate limit config
+	cfg.RateLimit = RateLimitConfig{
+		WSConnectionsPerMinute: l.getEnvAsInt("WSIGN_RATELIMIT_WS_CONNECTIONS_PER_MINUTE", 0),
+		WSMessagesInPerMinute:  l.getEnvAsInt("WSIGN_RATELIMIT_WS_MESSAGES_IN_PER_MINUTE", 0),
+		WSMessagesOutPerMinute: l.getEnvAsInt("WSIGN_RATELIMIT_WS_MESSAGES_OUT_PER_MINUTE", 0),
+		Breaker: BreakerConfig{
+			Failures:     l.getEnvAsInt("WSIGN_RATELIMIT_BREAKER_FAILURES", 5),
+			ErrorPercent: l.getEnvAsInt("WSIGN_RATELIMIT_BREAKER_ERROR_PERCENT", 50),
+			MinCalls:     l.getEnvAsInt("WSIGN_RATELIMIT_BREAKER_MIN_CALLS", 20),
+			Window:       l.getEnvAsDuration("WSIGN_RATELIMIT_BREAKER_WINDOW", 10*time.Second),
+			Cooldown:     l.getEnvAsDuration("WSIGN_RATELIMIT_BREAKER_COOLDOWN", 30*time.Second),
+			FailClosed:   l.getEnvAsSlice("WSIGN_RATELIMIT_BREAKER_FAIL_CLOSED", nil, ","),
+		},
+	}
+	if cfg.RateLimit.Profiles, err = parseRateLimitProfiles(l.getEnv("WSIGN_RATELIMIT_PROFILES", "")); err != nil {
+		return nil, err
+	}
+	if cfg.RateLimit.Routes, err = parseRateLimitRoutes(l.getEnv("WSIGN_RATELIMIT_ROUTES", "")); err != nil {
+		return nil, err
+	}
+
+	// Load property limits
+	defaults := DefaultPropertyLimits()
+	cfg.Properties = PropertyLimits{
+		MaxCount:       l.getEnvAsInt("WSIGN_PROPERTIES_MAX_COUNT", defaults.MaxCount),
+		MaxKeyLength:   l.getEnvAsInt("WSIGN_PROPERTIES_MAX_KEY_LENGTH", defaults.MaxKeyLength),
+		MaxValueLength: l.getEnvAsInt("WSIGN_PROPERTIES_MAX_VALUE_LENGTH", defaults.MaxValueLength),
+		MaxTotalSize:   l.getEnvAsInt("WSIGN_PROPERTIES_MAX_TOTAL_SIZE", defaults.MaxTotalSize),
 	}
 
+	cfg.settings = l.settings
 	return cfg, cfg.validate()
 }
 
+// validateListen checks the addresses the server listens on, so that a
+// mistyped host is reported here rather than as a listen error once
+// everything else has started
+func (c *Config) validateListen() error {
+	if len(c.Server.ListenAddresses) == 0 {
+		return c.checkListenHost("WSIGN_SERVER_HOST", c.Server.Host)
+	}
+
+	seen := make(map[string]bool, len(c.Server.ListenAddresses))
+	for _, addr := range c.Server.ListenAddresses {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return fmt.Errorf("invalid listen address %q: use host:port, with IPv6 addresses in brackets as in [::]:8080", addr)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("invalid listen address %q: port must be between 1 and 65535", addr)
+		}
+		if seen[addr] {
+			return fmt.Errorf("listen address %q is listed more than once", addr)
+		}
+		seen[addr] = true
+		if err := c.checkListenHost("WSIGN_SERVER_LISTEN_ADDRESSES host", host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkListenHost checks that host, named by setting in messages, can be
+// listened on: empty for every interface, an IP address, or a name that
+// resolves. A name resolving to several addresses is only listened on at
+// one of them, which is warned about.
+func (c *Config) checkListenHost(setting, host string) error {
+	if host == "" || net.ParseIP(host) != nil {
+		return nil
+	}
+	addrs, err := lookupHost(host)
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("invalid %s %q: it is not an IP address and does not resolve; use an address such as 0.0.0.0 or ::, or leave it empty to listen on every interface", setting, host)
+	}
+	if len(addrs) > 1 {
+		c.warnings = append(c.warnings, fmt.Sprintf(
+			"%s %q resolves to %s but only one of them is listened on; list each address in WSIGN_SERVER_LISTEN_ADDRESSES to listen on all of them",
+			setting, host, strings.Join(addrs, ", ")))
+	}
+	return nil
+}
+
+// Warnings describe settings Load accepted that are probably not what was
+// meant, to be logged at startup
+func (c *Config) Warnings() []string {
+	return c.warnings
+}
+
+// lookupHost resolves the host names the server is asked to listen on
+var lookupHost = net.LookupHost
+
+// Demo reports whether the server runs in demo mode
+func (c *Config) Demo() bool {
+	return c.Mode == ModeDemo
+}
+
 func (c *Config) validate() error {
+	if c.Mode != ModeServer && c.Mode != ModeDemo {
+		return fmt.Errorf("invalid mode %q: use %s or %s", c.Mode, ModeServer, ModeDemo)
+	}
+	if c.Log.SampleEvery < 0 {
+		return fmt.Errorf("log sample rate cannot be negative")
+	}
 	if c.Server.Port < 1 || c.Server.Port > 65535 {
 		return fmt.Errorf("invalid server port: %d", c.Server.Port)
 	}
+	if err := c.validateListen(); err != nil {
+		return err
+	}
 	if (c.Server.TLSCert != "") != (c.Server.TLSKey != "") {
 		return fmt.Errorf("both TLS cert and key must be provided")
 	}
+	if c.Server.Overload.MaxInFlight < 1 || c.Server.Overload.MaxExpensiveInFlight < 1 || c.Server.Overload.MaxWebSockets < 1 {
+		return fmt.Errorf("server overload limits must be at least 1")
+	}
+	if c.Server.Overload.MaxQueueWait < 0 || c.Server.Overload.WebSocketRetryAfter < 0 {
+		return fmt.Errorf("server overload waits cannot be negative")
+	}
+	if c.Server.Shutdown.HubTimeout < 0 || c.Server.Shutdown.RequestTimeout < 0 || c.Server.Shutdown.ReconnectSpread < 0 {
+		return fmt.Errorf("server shutdown timeouts cannot be negative")
+	}
+	if ws := c.Server.WebSocket; ws.ReadBufferSize < 1 || ws.WriteBufferSize < 1 || ws.MaxMessageSize < 1 {
+		return fmt.Errorf("websocket buffer and message sizes must be at least 1")
+	}
+	// Status reports are relayed to other displays, so anything accepted
+	// must be sendable
+	if c.Server.WebSocket.MaxOutboundMessageSize < c.Server.WebSocket.MaxMessageSize {
+		return fmt.Errorf("websocket max outbound message size cannot be less than the max message size")
+	}
+	if c.Server.Compression.Enabled {
+		if c.Server.Compression.Level < 1 || c.Server.Compression.Level > 9 {
+			return fmt.Errorf("compression level must be between 1 and 9")
+		}
+		if c.Server.Compression.MinSize < 0 {
+			return fmt.Errorf("compression min size cannot be negative")
+		}
+	}
+	if c.Database.URL != "" && len(c.Database.Options) > 0 {
+		return fmt.Errorf("database options cannot be combined with a database URL; add them to the URL instead")
+	}
 	if c.Database.Port < 1 || c.Database.Port > 65535 {
 		return fmt.Errorf("invalid database port: %d", c.Database.Port)
 	}
 	if c.Database.MaxOpenConns < 1 {
 		return fmt.Errorf("invalid max open connections: %d", c.Database.MaxOpenConns)
 	}
+	if c.Database.ConnectAttempts < 1 {
+		return fmt.Errorf("database connect attempts must be at least 1")
+	}
+	if c.Database.ConnectBackoff < 0 {
+		return fmt.Errorf("database connect backoff cannot be negative")
+	}
+	if c.Database.MigrationLockTimeout <= 0 {
+		return fmt.Errorf("invalid migration lock timeout: %s", c.Database.MigrationLockTimeout)
+	}
 	if c.Database.MaxIdleConns < 1 {
 		return fmt.Errorf("invalid max idle connections: %d", c.Database.MaxIdleConns)
 	}
-	if c.Auth.TokenSigningKey == "" {
-		return fmt.Errorf("token signing key is required")
+	if c.Database.SlowQueryThreshold < 0 {
+		return fmt.Errorf("database slow query threshold cannot be negative")
+	}
+	if c.Database.StatementTimeout < 0 {
+		return fmt.Errorf("database statement timeout cannot be negative")
+	}
+	if c.Database.JobTimeout < 0 {
+		return fmt.Errorf("background job timeout cannot be negative")
+	}
+	// Demo mode generates an ephemeral key when none is configured
+	if c.Auth.TokenSigningKey == "" && len(c.Auth.SigningKeys) == 0 && !c.Demo() {
+		return fmt.Errorf("a token signing key is required")
 	}
 	if c.Auth.TokenExpiry < 1*time.Minute {
 		return fmt.Errorf("token expiry must be at least 1 minute")
 	}
+	if c.Auth.EnrollmentTokenExpiry <= 0 {
+		return fmt.Errorf("enrollment token expiry must be positive")
+	}
 	if c.Content.MaxCacheSize < 1024*1024 { // 1MB minimum
 		return fmt.Errorf("cache size must be at least 1MB")
 	}
+	if c.Content.ValidationTimeout <= 0 {
+		return fmt.Errorf("content validation timeout must be positive")
+	}
+	switch c.Content.Storage.Backend {
+	case StorageLocal:
+		if c.Content.StoragePath == "" {
+			return fmt.Errorf("content storage path is required for the local storage backend")
+		}
+	case StorageS3:
+		s3 := c.Content.Storage.S3
+		if s3.Bucket == "" || s3.Region == "" {
+			return fmt.Errorf("S3 bucket and region are required for the s3 storage backend")
+		}
+		if s3.AccessKeyID == "" || s3.SecretAccessKey == "" {
+			return fmt.Errorf("S3 access key ID and secret access key are required for the s3 storage backend")
+		}
+	default:
+		return fmt.Errorf("invalid content storage backend %q: use %s or %s", c.Content.Storage.Backend, StorageLocal, StorageS3)
+	}
+	if c.Content.EventRetention < 0 || c.Content.EventRetentionInterval < 0 {
+		return fmt.Errorf("content event retention and retention interval cannot be negative")
+	}
+	if c.Content.EventPruneBatchSize < 1 {
+		return fmt.Errorf("content event prune batch size must be at least 1")
+	}
+	if c.Content.EventArchive && c.Content.StoragePath == "" {
+		return fmt.Errorf("content storage path is required to archive content events")
+	}
+	if c.Display.ContentHistorySize < 1 || c.Display.ContentHistorySize > MaxContentHistorySize {
+		return fmt.Errorf("display content history size must be between 1 and %d, got %d", MaxContentHistorySize, c.Display.ContentHistorySize)
+	}
+	if c.Display.SendQueueSize < 1 {
+		return fmt.Errorf("display send queue size must be at least 1")
+	}
+	if c.Display.MaxConsecutiveDrops < 1 {
+		return fmt.Errorf("display max consecutive drops must be at least 1")
+	}
+	if c.Display.OfflineAfter < 0 {
+		return fmt.Errorf("display offline threshold cannot be negative")
+	}
+	if c.Display.OfflineGracePeriod < 0 {
+		return fmt.Errorf("display offline grace period cannot be negative")
+	}
+	if p := c.Display.ActivationSitePolicy; p != ActivationSitesPermissive && p != ActivationSitesStrict {
+		return fmt.Errorf("invalid display activation site policy %q: use %s or %s", p, ActivationSitesPermissive, ActivationSitesStrict)
+	}
+	if c.Display.StateSnapshotInterval < 0 || c.Display.StateSnapshotRetention < 0 {
+		return fmt.Errorf("display state snapshot interval and retention cannot be negative")
+	}
+	if c.Display.LocationProposalMaxAge <= 0 {
+		return fmt.Errorf("display location proposal max age must be positive")
+	}
+	if c.Display.OutboxTTL < 0 {
+		return fmt.Errorf("display outbox TTL cannot be negative")
+	}
+	if c.Display.OutboxTTL > 0 && c.Display.OutboxDeliveryInterval <= 0 {
+		return fmt.Errorf("display outbox delivery interval must be positive")
+	}
+	if c.Display.HeartbeatInterval <= 0 || c.Display.ContentPollInterval <= 0 {
+		return fmt.Errorf("display heartbeat and content poll intervals must be positive")
+	}
+	for site, intervals := range c.Display.PollingSites {
+		if intervals.Heartbeat < 0 || intervals.ContentPoll < 0 {
+			return fmt.Errorf("display polling intervals for site %s cannot be negative", site)
+		}
+	}
+	if c.Display.MaxPerSite < 0 || c.Display.MaxPerZone < 0 {
+		return fmt.Errorf("display caps cannot be negative")
+	}
+	if _, err := time.LoadLocation(c.Display.Timezone); err != nil {
+		return fmt.Errorf("unknown display time zone %q", c.Display.Timezone)
+	}
+	for site, name := range c.Display.SiteTimezones {
+		if _, err := time.LoadLocation(name); err != nil {
+			return fmt.Errorf("unknown time zone %q for site %s", name, site)
+		}
+	}
+	if c.Display.ScheduleCheckInterval <= 0 {
+		return fmt.Errorf("display schedule check interval must be positive")
+	}
+	if c.Display.ScheduleJitter < 0 {
+		return fmt.Errorf("display schedule jitter cannot be negative")
+	}
+	if c.RateLimit.WSConnectionsPerMinute < 0 || c.RateLimit.WSMessagesInPerMinute < 0 || c.RateLimit.WSMessagesOutPerMinute < 0 {
+		return fmt.Errorf("rate limits cannot be negative")
+	}
+	breaker := c.RateLimit.Breaker
+	if breaker.Failures < 0 || breaker.MinCalls < 0 || breaker.Cooldown < 0 {
+		return fmt.Errorf("rate limit breaker settings cannot be negative")
+	}
+	if breaker.ErrorPercent < 0 || breaker.ErrorPercent > 100 {
+		return fmt.Errorf("rate limit breaker error percent must be between 0 and 100")
+	}
+	if breaker.ErrorPercent > 0 && breaker.Window <= 0 {
+		return fmt.Errorf("rate limit breaker window must be positive")
+	}
+	props := c.Properties
+	if props.MaxCount < 0 || props.MaxKeyLength < 0 || props.MaxValueLength < 0 || props.MaxTotalSize < 0 {
+		return fmt.Errorf("property limits cannot be negative")
+	}
 	return nil
 }
 
-func getEnv(key, fallback string) string {
+// parseSigningKeys parses a comma separated list of id:algorithm:key entries
+func parseSigningKeys(value string) ([]SigningKeyConfig, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	var keys []SigningKeyConfig
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid signing key entry %q: use id:algorithm:key", entry)
+		}
+		keys = append(keys, SigningKeyConfig{
+			ID:        parts[0],
+			Algorithm: parts[1],
+			Key:       parts[2],
+		})
+	}
+	return keys, nil
+}
+
+// parseRateLimitProfiles parses a comma separated list of
+// name=rate/period[/burst] entries, such as "display_stats=30/1m/10"
+func parseRateLimitProfiles(value string) (map[string]RateLimitProfile, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	profiles := make(map[string]RateLimitProfile)
+	for _, entry := range strings.Split(value, ",") {
+		name, spec, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		parts := strings.Split(spec, "/")
+		if !ok || name == "" || len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("invalid rate limit profile %q: use name=rate/period[/burst]", entry)
+		}
+		if _, dup := profiles[name]; dup {
+			return nil, fmt.Errorf("rate limit profile %q is defined twice", name)
+		}
+
+		var p RateLimitProfile
+		var err error
+		if p.Rate, err = strconv.Atoi(parts[0]); err != nil || p.Rate < 1 {
+			return nil, fmt.Errorf("invalid rate in rate limit profile %q: must be a positive integer", name)
+		}
+		if p.Period, err = time.ParseDuration(parts[1]); err != nil || p.Period <= 0 {
+			return nil, fmt.Errorf("invalid period in rate limit profile %q: must be a positive duration", name)
+		}
+		if len(parts) == 3 {
+			if p.Burst, err = strconv.Atoi(parts[2]); err != nil || p.Burst < 1 {
+				return nil, fmt.Errorf("invalid burst in rate limit profile %q: must be a positive integer", name)
+			}
+		}
+		profiles[name] = p
+	}
+	return profiles, nil
+}
+
+// parseRateLimitRoutes parses a comma separated list of group=profile
+// bindings, such as "display_stats=reports"
+func parseRateLimitRoutes(value string) (map[string]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	routes := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		group, profile, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || group == "" || profile == "" {
+			return nil, fmt.Errorf("invalid rate limit route binding %q: use group=profile", entry)
+		}
+		if _, dup := routes[group]; dup {
+			return nil, fmt.Errorf("route group %q is bound twice", group)
+		}
+		routes[group] = profile
+	}
+	return routes, nil
+}
+
+// parseDisplayLimits parses a comma separated list of scope=cap entries,
+// such as "hq=200,annex=50". Zone scopes are written site/zone. A cap of zero
+// lifts the default for that scope.
+func parseDisplayLimits(value string, zones bool) (map[string]int, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	format := "site=cap"
+	if zones {
+		format = "site/zone=cap"
+	}
+	limits := make(map[string]int)
+	for _, entry := range strings.Split(value, ",") {
+		scope, n, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		limit, err := strconv.Atoi(n)
+		if !ok || scope == "" || err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid display cap %q: use %s", entry, format)
+		}
+		if site, zone, isZone := strings.Cut(scope, "/"); isZone != zones || (zones && (site == "" || zone == "")) {
+			return nil, fmt.Errorf("invalid display cap %q: use %s", entry, format)
+		}
+		if _, dup := limits[scope]; dup {
+			return nil, fmt.Errorf("display cap for %q is given twice", scope)
+		}
+		limits[scope] = limit
+	}
+	return limits, nil
+}
+
+// parsePollingSites parses a comma separated list of per-site polling
+// overrides written as site=heartbeat/contentPoll, such as
+// "hq=30s/2m,annex=/10m". Either interval may be left empty to keep the
+// default.
+func parsePollingSites(value string) (map[string]PollingIntervals, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	sites := make(map[string]PollingIntervals)
+	for _, entry := range strings.Split(value, ",") {
+		site, spec, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		heartbeat, contentPoll, hasSlash := strings.Cut(spec, "/")
+		if !ok || site == "" || !hasSlash || (heartbeat == "" && contentPoll == "") {
+			return nil, fmt.Errorf("invalid display polling override %q: use site=heartbeat/contentPoll", entry)
+		}
+		var intervals PollingIntervals
+		for _, p := range []struct {
+			value  string
+			target *time.Duration
+		}{{heartbeat, &intervals.Heartbeat}, {contentPoll, &intervals.ContentPoll}} {
+			if p.value == "" {
+				continue
+			}
+			d, err := time.ParseDuration(p.value)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid display polling override %q: intervals must be positive durations", entry)
+			}
+			*p.target = d
+		}
+		if _, dup := sites[site]; dup {
+			return nil, fmt.Errorf("display polling override for %q is given twice", site)
+		}
+		sites[site] = intervals
+	}
+	return sites, nil
+}
+
+// parseSiteTimezones parses a comma separated list of site=zone entries,
+// such as "hq=America/New_York,depot=Europe/London". Zones are checked
+// when the configuration is validated.
+func parseSiteTimezones(value string) (map[string]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	zones := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		site, zone, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || site == "" || zone == "" {
+			return nil, fmt.Errorf("invalid site time zone %q: use site=zone", entry)
+		}
+		if _, dup := zones[site]; dup {
+			return nil, fmt.Errorf("time zone for site %q is given twice", site)
+		}
+		zones[site] = zone
+	}
+	return zones, nil
+}
+
+// parseAutoAssignRules parses a comma separated list of match:source
+// rules, such as "zone=menu:menus,property.orientation=portrait:portrait".
+// A rule matches siteId=, zone= or property.<key>= and keeps its place in
+// the list, since the first rule matching a display applies.
+func parseAutoAssignRules(value string) ([]AutoAssignRule, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	var rules []AutoAssignRule
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		sep := strings.LastIndex(entry, ":")
+		if sep < 0 {
+			return nil, fmt.Errorf("invalid auto-assign rule %q: use match:source", entry)
+		}
+		match, source := entry[:sep], strings.ToLower(entry[sep+1:])
+		field, want, ok := strings.Cut(match, "=")
+		if !ok || want == "" || source == "" {
+			return nil, fmt.Errorf("invalid auto-assign rule %q: use match:source", entry)
+		}
+
+		rule := AutoAssignRule{Source: source}
+		switch key, isProperty := strings.CutPrefix(field, "property."); {
+		case field == "siteId":
+			rule.SiteID = want
+		case field == "zone":
+			rule.Zone = want
+		case isProperty && key != "":
+			rule.Property, rule.Value = key, want
+		default:
+			return nil, fmt.Errorf("invalid auto-assign rule %q: match siteId=, zone= or property.<key>=", entry)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// parseDatabaseOptions parses connection parameters written as a URL query,
+// such as "connect_timeout=5&search_path=signage,public"
+func parseDatabaseOptions(value string) (map[string]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	query, err := url.ParseQuery(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database options %q: %w", value, err)
+	}
+	options := make(map[string]string, len(query))
+	for k, v := range query {
+		if k == "" || len(v) != 1 {
+			return nil, fmt.Errorf("invalid database option %q: give each option once as key=value", k)
+		}
+		options[k] = v[0]
+	}
+	return options, nil
+}
+
+// loader reads configuration from the environment, recording every
+// variable it looks up and whether the value came from the environment or
+// was a default
+type loader struct {
+	settings []Setting
+}
+
+// record notes the value used for key. Values that could not be parsed are
+// recorded as the default that replaced them.
+func (l *loader) record(key, value string, fromEnv bool) {
+	source := SourceDefault
+	if fromEnv {
+		source = SourceEnv
+	}
+	l.settings = append(l.settings, Setting{Name: key, Value: value, Source: source})
+}
+
+func (l *loader) getEnv(key, fallback string) string {
 	if value, exists := os.LookupEnv(key); exists {
+		l.record(key, value, true)
 		return value
 	}
+	l.record(key, fallback, false)
 	return fallback
 }
 
-func getEnvRequired(key string) string {
+// getEnvRequired returns a required environment variable, panicking if unset
+// nolint:unused // Reserved for future use
+func (l *loader) getEnvRequired(key string) string {
 	if value, exists := os.LookupEnv(key); exists {
+		l.record(key, value, true)
 		return value
 	}
 	panic(fmt.Sprintf("required environment variable not set: %s", key))
 }
 
-func getEnvAsInt(key string, fallback int) int {
+func (l *loader) getEnvAsInt(key string, fallback int) int {
 	if strValue, exists := os.LookupEnv(key); exists {
 		if value, err := strconv.Atoi(strValue); err == nil {
+			l.record(key, strconv.Itoa(value), true)
 			return value
 		}
 	}
+	l.record(key, strconv.Itoa(fallback), false)
 	return fallback
 }
 
-func getEnvAsInt64(key string, fallback int64) int64 {
+func (l *loader) getEnvAsInt64(key string, fallback int64) int64 {
 	if strValue, exists := os.LookupEnv(key); exists {
 		if value, err := strconv.ParseInt(strValue, 10, 64); err == nil {
+			l.record(key, strconv.FormatInt(value, 10), true)
 			return value
 		}
 	}
+	l.record(key, strconv.FormatInt(fallback, 10), false)
 	return fallback
 }
 
-func getEnvAsDuration(key string, fallback time.Duration) time.Duration {
+func (l *loader) getEnvAsDuration(key string, fallback time.Duration) time.Duration {
 	if strValue, exists := os.LookupEnv(key); exists {
 		if value, err := time.ParseDuration(strValue); err == nil {
+			l.record(key, value.String(), true)
 			return value
 		}
 	}
+	l.record(key, fallback.String(), false)
 	return fallback
 }
 
 // getEnvAsBool parses a boolean environment variable with fallback
-// nolint:unused // Reserved for future use
-func getEnvAsBool(key string, fallback bool) bool {
+func (l *loader) getEnvAsBool(key string, fallback bool) bool {
 	if strValue, exists := os.LookupEnv(key); exists {
 		if value, err := strconv.ParseBool(strValue); err == nil {
+			l.record(key, strconv.FormatBool(value), true)
 			return value
 		}
 	}
+	l.record(key, strconv.FormatBool(fallback), false)
 	return fallback
 }
 
-// getEnvAsSlice splits an environment variable into a slice with fallback
-// nolint:unused // Reserved for future use
-func getEnvAsSlice(key string, fallback []string, sep string) []string {
-	if strValue, exists := os.LookupEnv(key); exists {
-		return strings.Split(strValue, sep)
+// getEnvAsSlice splits an environment variable into trimmed, non-empty
+// values with fallback
+func (l *loader) getEnvAsSlice(key string, fallback []string, sep string) []string {
+	strValue, exists := os.LookupEnv(key)
+	if !exists {
+		l.record(key, strings.Join(fallback, sep), false)
+		return fallback
 	}
-	return fallback
+	var values []string
+	for _, v := range strings.Split(strValue, sep) {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	l.record(key, strings.Join(values, sep), true)
+	return values
 }
@@ -0,0 +1,237 @@
+package config
+
+import (
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatabaseConnString(t *testing.T) {
+	base := DatabaseConfig{
+		Host:     "db.internal",
+		Port:     6432,
+		Name:     "signage",
+		User:     "wsignd",
+		Password: "p@ss word",
+		SSLMode:  "require",
+	}
+
+	t.Run("assembles a URL with a default application name", func(t *testing.T) {
+		u, err := url.Parse(base.ConnString())
+		require.NoError(t, err)
+		assert.Equal(t, "postgres", u.Scheme)
+		assert.Equal(t, "db.internal:6432", u.Host)
+		assert.Equal(t, "/signage", u.Path)
+		assert.Equal(t, "wsignd", u.User.Username())
+		password, _ := u.User.Password()
+		assert.Equal(t, "p@ss word", password)
+		assert.Equal(t, "require", u.Query().Get("sslmode"))
+		assert.Equal(t, DefaultApplicationName, u.Query().Get("application_name"))
+	})
+
+	t.Run("appends options, which may override defaults", func(t *testing.T) {
+		cfg := base
+		cfg.Options = map[string]string{
+			"connect_timeout":  "5",
+			"search_path":      "signage,public",
+			"application_name": "wsignd-eu",
+		}
+		u, err := url.Parse(cfg.ConnString())
+		require.NoError(t, err)
+		assert.Equal(t, "5", u.Query().Get("connect_timeout"))
+		assert.Equal(t, "signage,public", u.Query().Get("search_path"))
+		assert.Equal(t, "wsignd-eu", u.Query().Get("application_name"))
+	})
+
+	t.Run("uses a full URL verbatim", func(t *testing.T) {
+		cfg := base
+		cfg.URL = "host=pgbouncer port=6432 dbname=signage sslmode=disable"
+		assert.Equal(t, cfg.URL, cfg.ConnString())
+	})
+
+	t.Run("replica", func(t *testing.T) {
+		_, ok := base.Replica()
+		assert.False(t, ok)
+
+		cfg := base
+		cfg.MaxOpenConns = 10
+		cfg.ReplicaURL = "postgres://reader@db-replica.internal/signage"
+		replica, ok := cfg.Replica()
+		require.True(t, ok)
+		assert.Equal(t, cfg.ReplicaURL, replica.ConnString())
+		assert.Equal(t, 10, replica.MaxOpenConns)
+		_, ok = replica.Replica()
+		assert.False(t, ok)
+	})
+}
+
+func TestParseDatabaseOptions(t *testing.T) {
+	options, err := parseDatabaseOptions("connect_timeout=5&search_path=signage,public")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"connect_timeout": "5", "search_path": "signage,public"}, options)
+
+	options, err = parseDatabaseOptions("")
+	require.NoError(t, err)
+	assert.Nil(t, options)
+
+	_, err = parseDatabaseOptions("a=1&a=2")
+	assert.Error(t, err)
+}
+
+func TestParseRateLimitProfiles(t *testing.T) {
+	profiles, err := parseRateLimitProfiles("display_stats=30/1m/10, reports=5/1h")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]RateLimitProfile{
+		"display_stats": {Rate: 30, Period: time.Minute, Burst: 10},
+		"reports":       {Rate: 5, Period: time.Hour},
+	}, profiles)
+
+	profiles, err = parseRateLimitProfiles("")
+	require.NoError(t, err)
+	assert.Nil(t, profiles)
+
+	for _, bad := range []string{"stats", "stats=30", "=30/1m", "stats=0/1m", "stats=30/0s", "stats=30/1m/0", "stats=30/1m/5/1", "a=1/1m,a=2/1m"} {
+		_, err := parseRateLimitProfiles(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestParseRateLimitRoutes(t *testing.T) {
+	routes, err := parseRateLimitRoutes("display_stats=reports,display_api=reports")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"display_stats": "reports", "display_api": "reports"}, routes)
+
+	for _, bad := range []string{"display_stats", "display_stats=", "=reports", "a=x,a=y"} {
+		_, err := parseRateLimitRoutes(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestParseDisplayLimits(t *testing.T) {
+	sites, err := parseDisplayLimits("hq=200, annex=0", false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"hq": 200, "annex": 0}, sites)
+
+	zones, err := parseDisplayLimits("hq/lobby=20", true)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"hq/lobby": 20}, zones)
+
+	for _, bad := range []string{"hq", "hq=", "=5", "hq=-1", "hq=many", "hq=1,hq=2", "hq/lobby=5"} {
+		_, err := parseDisplayLimits(bad, false)
+		assert.Error(t, err, bad)
+	}
+	for _, bad := range []string{"hq=5", "hq/=5", "/lobby=5"} {
+		_, err := parseDisplayLimits(bad, true)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestParsePollingSites(t *testing.T) {
+	sites, err := parsePollingSites("hq=30s/2m, annex=/10m")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]PollingIntervals{
+		"hq":    {Heartbeat: 30 * time.Second, ContentPoll: 2 * time.Minute},
+		"annex": {ContentPoll: 10 * time.Minute},
+	}, sites)
+
+	for _, bad := range []string{"hq", "hq=30s", "hq=/", "=30s/2m", "hq=soon/2m", "hq=-30s/2m", "hq=30s/2m,hq=1m/5m"} {
+		_, err := parsePollingSites(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestParseSiteTimezones(t *testing.T) {
+	zones, err := parseSiteTimezones("hq=America/New_York, depot=Europe/London")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"hq": "America/New_York", "depot": "Europe/London"}, zones)
+
+	for _, bad := range []string{"hq", "hq=", "=UTC", "hq=UTC,hq=Europe/Paris"} {
+		_, err := parseSiteTimezones(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestParseAutoAssignRules(t *testing.T) {
+	rules, err := parseAutoAssignRules("zone=menu:Menus, siteId=hq:lobby-loop,property.orientation=portrait:portrait-feed")
+	require.NoError(t, err)
+	assert.Equal(t, []AutoAssignRule{
+		{Zone: "menu", Source: "menus"},
+		{SiteID: "hq", Source: "lobby-loop"},
+		{Property: "orientation", Value: "portrait", Source: "portrait-feed"},
+	}, rules, "in the order given")
+
+	for _, bad := range []string{"zone=menu", "zone=menu:", "zone=:menus", "zone:menus", "position=a:menus", "property.=x:menus", "property=x:menus"} {
+		_, err := parseAutoAssignRules(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestValidateListen(t *testing.T) {
+	lookupHost = func(host string) ([]string, error) {
+		switch host {
+		case "localhost":
+			return []string{"127.0.0.1", "::1"}, nil
+		case "signage.internal":
+			return []string{"10.0.0.5"}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	t.Cleanup(func() { lookupHost = net.LookupHost })
+
+	validate := func(host string, addrs ...string) (*Config, error) {
+		c := &Config{Server: ServerConfig{Host: host, Port: 8080, ListenAddresses: addrs}}
+		return c, c.validateListen()
+	}
+
+	for _, host := range []string{"", "0.0.0.0", "::", "signage.internal"} {
+		c, err := validate(host)
+		assert.NoError(t, err, host)
+		assert.Empty(t, c.Warnings(), host)
+	}
+
+	c, err := validate("localhost")
+	require.NoError(t, err)
+	require.Len(t, c.Warnings(), 1)
+	assert.Contains(t, c.Warnings()[0], `WSIGN_SERVER_HOST "localhost" resolves to 127.0.0.1, ::1 but only one of them is listened on`)
+
+	_, err = validate("signage.internl")
+	assert.EqualError(t, err, `invalid WSIGN_SERVER_HOST "signage.internl": it is not an IP address and does not resolve; use an address such as 0.0.0.0 or ::, or leave it empty to listen on every interface`)
+
+	// Listen addresses replace the host, which is then not checked
+	c, err = validate("signage.internl", "0.0.0.0:8080", "[::]:8080", ":9090", "localhost:8081")
+	require.NoError(t, err)
+	assert.Len(t, c.Warnings(), 1)
+
+	for _, bad := range [][]string{
+		{"0.0.0.0"},
+		{"::1:8080"},
+		{"0.0.0.0:0"},
+		{"0.0.0.0:http"},
+		{"0.0.0.0:8080", "0.0.0.0:8080"},
+		{"nowhere.invalid:8080"},
+	} {
+		_, err := validate("", bad...)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestValidateContentHistorySize(t *testing.T) {
+	t.Setenv("WSIGN_AUTH_TOKEN_KEY", "hunter2")
+	for _, size := range []string{"1", "200"} {
+		t.Setenv("WSIGN_DISPLAY_CONTENT_HISTORY_SIZE", size)
+		_, err := Load()
+		assert.NoError(t, err, size)
+	}
+
+	t.Setenv("WSIGN_DISPLAY_CONTENT_HISTORY_SIZE", "201")
+	_, err := Load()
+	assert.ErrorContains(t, err, "display content history size must be between 1 and 200, got 201")
+
+	t.Setenv("WSIGN_DISPLAY_CONTENT_HISTORY_SIZE", "0")
+	_, err = Load()
+	assert.ErrorContains(t, err, "display content history size must be between 1 and 200, got 0")
+}
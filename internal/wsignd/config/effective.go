@@ -0,0 +1,148 @@
+package config
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Source tells where a configuration value came from
+type Source string
+
+const (
+	// SourceEnv marks a value read from an environment variable
+	SourceEnv Source = "env"
+	// SourceDefault marks a built-in default, used when the variable is
+	// unset or could not be parsed
+	SourceDefault Source = "default"
+)
+
+// Redacted replaces secrets in the effective configuration
+const Redacted = "<redacted>"
+
+// Setting is one configuration value as Load resolved it
+type Setting struct {
+	// Name is the environment variable, e.g. WSIGN_DB_HOST
+	Name string
+	// Value is the value in effect, with secrets replaced by Redacted
+	Value string
+	// Source tells whether Value came from the environment or a default
+	Source Source
+}
+
+// secrets maps the variables holding secrets to the function that redacts
+// them. Values that only partly consist of secrets, such as connection
+// strings, keep their other parts.
+var secrets = map[string]func(string) string{
+	"WSIGN_DB_URL":                       redactConnString,
+	"WSIGN_DB_REPLICA_URL":               redactConnString,
+	"WSIGN_DB_PASSWORD":                  redactAll,
+	"WSIGN_DB_OPTIONS":                   redactQuery,
+	"WSIGN_AUTH_TOKEN_KEY":               redactAll,
+	"WSIGN_AUTH_SIGNING_KEYS":            redactSigningKeys,
+	"WSIGN_CONTENT_S3_SECRET_ACCESS_KEY": redactAll,
+	"WSIGN_CONTENT_S3_SESSION_TOKEN":     redactAll,
+}
+
+// Secret reports whether the variable name holds a secret
+func Secret(name string) bool {
+	_, ok := secrets[name]
+	return ok
+}
+
+// Effective returns every setting Load looked up, sorted by name, with
+// secrets redacted. Empty secrets are left empty so that a missing secret
+// can still be told apart from a set one.
+func (c *Config) Effective() []Setting {
+	settings := make([]Setting, len(c.settings))
+	copy(settings, c.settings)
+	for i, s := range settings {
+		if redact, ok := secrets[s.Name]; ok && s.Value != "" {
+			settings[i].Value = redact(s.Value)
+		}
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Name < settings[j].Name })
+	return settings
+}
+
+func redactAll(string) string {
+	return Redacted
+}
+
+// passwordParams are the connection parameters that hold secrets
+var passwordParams = map[string]bool{"password": true, "sslpassword": true}
+
+// redactQuery redacts the secret parameters of options written as a URL
+// query
+func redactQuery(value string) string {
+	query, err := url.ParseQuery(value)
+	if err != nil {
+		return Redacted
+	}
+	var parts []string
+	for _, key := range sortedKeys(query) {
+		for _, v := range query[key] {
+			if passwordParams[strings.ToLower(key)] {
+				v = Redacted
+			}
+			parts = append(parts, key+"="+v)
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// keywordPassword matches the secret parameters of a key=value connection
+// string, quoted or not
+var keywordPassword = regexp.MustCompile(`(?i)\b((?:ssl)?password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// redactConnString redacts the password of a postgres:// URL or a
+// key=value connection string
+func redactConnString(value string) string {
+	if !strings.Contains(value, "://") {
+		return keywordPassword.ReplaceAllString(value, "${1}"+Redacted)
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return Redacted
+	}
+	var userinfo string
+	if u.User != nil {
+		userinfo = u.User.Username()
+		if _, ok := u.User.Password(); ok {
+			userinfo += ":" + Redacted
+		}
+		userinfo += "@"
+	}
+	u.User = nil
+	redacted := strings.Replace(u.String(), u.Scheme+"://", u.Scheme+"://"+userinfo, 1)
+	if u.RawQuery != "" {
+		redacted = strings.TrimSuffix(redacted, u.RawQuery) + redactQuery(u.RawQuery)
+	}
+	return redacted
+}
+
+// redactSigningKeys keeps the ID and algorithm of each id:algorithm:key
+// entry
+func redactSigningKeys(value string) string {
+	entries := strings.Split(value, ",")
+	for i, entry := range entries {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+		if len(parts) != 3 {
+			entries[i] = Redacted
+			continue
+		}
+		entries[i] = parts[0] + ":" + parts[1] + ":" + Redacted
+	}
+	return strings.Join(entries, ",")
+}
+
+func sortedKeys(query url.Values) []string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
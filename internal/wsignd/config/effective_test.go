@@ -0,0 +1,98 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffective(t *testing.T) {
+	const secret = "hunter2"
+	env := map[string]string{
+		"WSIGN_DB_URL":                       "postgres://wsignd:" + secret + "@db.internal:5432/signage?sslmode=require&sslpassword=" + secret,
+		"WSIGN_DB_REPLICA_URL":               "postgres://reader:" + secret + "@db-replica.internal:5432/signage",
+		"WSIGN_DB_PASSWORD":                  secret,
+		"WSIGN_DB_OPTIONS":                   "connect_timeout=5&password=" + secret,
+		"WSIGN_AUTH_TOKEN_KEY":               secret,
+		"WSIGN_AUTH_SIGNING_KEYS":            "k2:HS256:" + secret + ",k1:HS256:" + secret,
+		"WSIGN_CONTENT_STORAGE_BACKEND":      StorageS3,
+		"WSIGN_CONTENT_S3_BUCKET":            "signage",
+		"WSIGN_CONTENT_S3_ACCESS_KEY_ID":     "AKIAEXAMPLE",
+		"WSIGN_CONTENT_S3_SECRET_ACCESS_KEY": secret,
+		"WSIGN_CONTENT_S3_SESSION_TOKEN":     secret,
+		"WSIGN_RATELIMIT_BREAKER_COOLDOWN":   "45s",
+		"WSIGN_DISPLAY_SEND_QUEUE_SIZE":      "not-a-number",
+	}
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+
+	// Options cannot be combined with a URL, so the options are checked
+	// with a configuration of their own
+	t.Setenv("WSIGN_DB_OPTIONS", "")
+	cfg, err := Load()
+	require.NoError(t, err)
+	t.Setenv("WSIGN_DB_URL", "")
+	t.Setenv("WSIGN_DB_OPTIONS", env["WSIGN_DB_OPTIONS"])
+	withOptions, err := Load()
+	require.NoError(t, err)
+
+	settings := make(map[string]Setting)
+	for _, s := range append(cfg.Effective(), withOptions.Effective()...) {
+		if s.Value != "" {
+			settings[s.Name] = s
+		}
+	}
+
+	t.Run("every secret is redacted", func(t *testing.T) {
+		for name := range secrets {
+			s, ok := settings[name]
+			require.True(t, ok, "%s was not reported", name)
+			assert.NotContains(t, s.Value, secret, name)
+			assert.Contains(t, s.Value, Redacted, name)
+			assert.True(t, Secret(name))
+		}
+		for _, s := range settings {
+			assert.NotContains(t, s.Value, secret, s.Name)
+		}
+	})
+
+	t.Run("redaction keeps the rest of a value", func(t *testing.T) {
+		assert.Equal(t, "postgres://wsignd:<redacted>@db.internal:5432/signage?sslmode=require&sslpassword=<redacted>",
+			settings["WSIGN_DB_URL"].Value)
+		assert.Equal(t, "connect_timeout=5&password=<redacted>", settings["WSIGN_DB_OPTIONS"].Value)
+		assert.Equal(t, "k2:HS256:<redacted>,k1:HS256:<redacted>", settings["WSIGN_AUTH_SIGNING_KEYS"].Value)
+		assert.Equal(t, "AKIAEXAMPLE", settings["WSIGN_CONTENT_S3_ACCESS_KEY_ID"].Value)
+		assert.Equal(t, "host=pgbouncer password=<redacted> dbname=signage",
+			redactConnString("host=pgbouncer password='it\\'s "+secret+"' dbname=signage"))
+	})
+
+	t.Run("provenance", func(t *testing.T) {
+		assert.Equal(t, Setting{Name: "WSIGN_RATELIMIT_BREAKER_COOLDOWN", Value: "45s", Source: SourceEnv},
+			settings["WSIGN_RATELIMIT_BREAKER_COOLDOWN"])
+		assert.Equal(t, Setting{Name: "WSIGN_SERVER_PORT", Value: "8080", Source: SourceDefault},
+			settings["WSIGN_SERVER_PORT"])
+		assert.Equal(t, SourceEnv, settings["WSIGN_DB_PASSWORD"].Source)
+		assert.Equal(t, Setting{Name: "WSIGN_DISPLAY_SEND_QUEUE_SIZE", Value: "256", Source: SourceDefault},
+			settings["WSIGN_DISPLAY_SEND_QUEUE_SIZE"], "unparseable values report the default used instead")
+	})
+
+	t.Run("settings are sorted and empty secrets stay empty", func(t *testing.T) {
+		t.Setenv("WSIGN_DB_PASSWORD", "")
+		cfg, err := Load()
+		require.NoError(t, err)
+
+		effective := cfg.Effective()
+		names := make([]string, len(effective))
+		for i, s := range effective {
+			names[i] = s.Name
+			if s.Name == "WSIGN_DB_PASSWORD" {
+				assert.Empty(t, s.Value)
+			}
+		}
+		assert.IsIncreasing(t, names)
+		assert.True(t, strings.HasPrefix(names[0], "WSIGN_"))
+	})
+}
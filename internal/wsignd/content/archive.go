@@ -0,0 +1,72 @@
+package content
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// EventArchive appends pruned events to NDJSON files, one per UTC day of
+// the events' timestamps, in the form the event query API exports them, so
+// that archived events can be read with the same tools
+type EventArchive struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewEventArchive creates an archive writing to files in dir, which is
+// created when first written to
+func NewEventArchive(dir string) *EventArchive {
+	return &EventArchive{dir: dir}
+}
+
+// Path returns the file e is archived in
+func (a *EventArchive) Path(e Event) string {
+	return filepath.Join(a.dir, "content-events-"+e.Timestamp.UTC().Format("2006-01-02")+".ndjson")
+}
+
+// Write appends events to the files of their days and syncs the files
+// before returning, so that events are not deleted until they are safely
+// archived
+func (a *EventArchive) Write(events []Event) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.MkdirAll(a.dir, 0750); err != nil {
+		return fmt.Errorf("error creating event archive directory: %w", err)
+	}
+
+	files := make(map[string]*os.File)
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	for _, e := range events {
+		path := a.Path(e)
+		f, ok := files[path]
+		if !ok {
+			var err error
+			// #nosec G304 -- the name is built from a date, below a configured directory
+			if f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640); err != nil {
+				return fmt.Errorf("error opening event archive: %w", err)
+			}
+			files[path] = f
+		}
+		line, err := json.Marshal(e.API())
+		if err != nil {
+			return fmt.Errorf("error encoding archived event: %w", err)
+		}
+		if _, err := f.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("error writing event archive: %w", err)
+		}
+	}
+	for path, f := range files {
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("error syncing event archive %s: %w", path, err)
+		}
+	}
+	return nil
+}
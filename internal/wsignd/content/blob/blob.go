@@ -0,0 +1,109 @@
+// Package blob stores opaque content objects, such as cached pages and
+// display screenshots, behind a Store interface so that replicas behind a
+// load balancer can share them. Objects live on the local filesystem or in
+// an S3-compatible bucket.
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// Object describes a stored blob
+type Object struct {
+	// Key identifies the blob within its store
+	Key string
+	// Size is the blob's length in bytes
+	Size int64
+	// ModTime is when the blob was last written
+	ModTime time.Time
+}
+
+// Store holds blobs under slash separated keys such as
+// "screenshots/sha256/ab/ab12...". Keys must be valid according to
+// ValidateKey.
+type Store interface {
+	// Put writes the blob read from r under key, replacing any blob already
+	// there. Readers never see a partially written blob.
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get opens the blob stored under key. The caller must close it. A
+	// missing blob is a not found error.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob stored under key. Deleting a missing blob is
+	// not an error.
+	Delete(ctx context.Context, key string) error
+	// List returns the blobs whose keys start with prefix, ordered by key
+	List(ctx context.Context, prefix string) ([]Object, error)
+}
+
+// ValidateKey checks that key is a relative slash separated path without
+// empty, "." or ".." segments, so that every backend maps it to the same
+// object and none can escape its root
+func ValidateKey(key string) error {
+	const op = "blob.ValidateKey"
+
+	if key == "" {
+		return werrors.NewError("INVALID_INPUT", "blob key is required", op, werrors.ErrInvalidInput)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." || strings.ContainsAny(segment, "\\\x00") {
+			return werrors.NewError("INVALID_INPUT", fmt.Sprintf("invalid blob key %q", key), op, werrors.ErrInvalidInput)
+		}
+	}
+	return nil
+}
+
+// Key returns the content-addressed key for data in namespace:
+// <namespace>/sha256/<first two hex digits>/<hex digest>. Identical content
+// shares a key, and the short fan-out directory keeps local directories
+// small.
+func Key(namespace string, data []byte) string {
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	return namespace + "/sha256/" + digest[:2] + "/" + digest
+}
+
+// PutContent stores data under its content-addressed key in namespace and
+// returns the key
+func PutContent(ctx context.Context, s Store, namespace string, data []byte) (string, error) {
+	key := Key(namespace, data)
+	if err := s.Put(ctx, key, bytes.NewReader(data)); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func notFound(op, key string) error {
+	return werrors.NewError("NOT_FOUND", fmt.Sprintf("blob not found: %s", key), op, werrors.ErrNotFound)
+}
+
+// Open creates the store selected by cfg.Storage.Backend
+func Open(cfg config.ContentConfig) (Store, error) {
+	switch cfg.Storage.Backend {
+	case config.StorageLocal:
+		return NewLocalStore(cfg.StoragePath), nil
+	case config.StorageS3:
+		s3 := cfg.Storage.S3
+		return NewS3Store(S3Options{
+			Endpoint:        s3.Endpoint,
+			Region:          s3.Region,
+			Bucket:          s3.Bucket,
+			AccessKeyID:     s3.AccessKeyID,
+			SecretAccessKey: s3.SecretAccessKey,
+			SessionToken:    s3.SessionToken,
+			PathStyle:       s3.PathStyle,
+		}, &http.Client{Timeout: 5 * time.Minute})
+	default:
+		return nil, fmt.Errorf("unknown content storage backend %q", cfg.Storage.Backend)
+	}
+}
@@ -0,0 +1,121 @@
+// Package blobtest provides a conformance suite for blob.Store
+// implementations so that every backend honours the same contract
+package blobtest
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/content/blob"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// Run exercises a blob store. newStore must return an empty store each
+// time it is called.
+func Run(t *testing.T, newStore func(t *testing.T) blob.Store) {
+	ctx := context.Background()
+
+	read := func(t *testing.T, s blob.Store, key string) string {
+		r, err := s.Get(ctx, key)
+		require.NoError(t, err)
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		return string(data)
+	}
+	keys := func(objects []blob.Object) []string {
+		var keys []string
+		for _, o := range objects {
+			keys = append(keys, o.Key)
+		}
+		return keys
+	}
+
+	t.Run("put and get", func(t *testing.T) {
+		s := newStore(t)
+		require.NoError(t, s.Put(ctx, "pages/welcome.html", strings.NewReader("<h1>Welcome</h1>")))
+		assert.Equal(t, "<h1>Welcome</h1>", read(t, s, "pages/welcome.html"))
+
+		require.NoError(t, s.Put(ctx, "pages/welcome.html", strings.NewReader("<h1>Hello</h1>")))
+		assert.Equal(t, "<h1>Hello</h1>", read(t, s, "pages/welcome.html"), "put replaces")
+
+		require.NoError(t, s.Put(ctx, "pages/empty", strings.NewReader("")))
+		assert.Equal(t, "", read(t, s, "pages/empty"))
+	})
+
+	t.Run("missing blobs", func(t *testing.T) {
+		s := newStore(t)
+		_, err := s.Get(ctx, "pages/missing")
+		assert.True(t, werrors.IsNotFound(err), "got %v", err)
+		assert.NoError(t, s.Delete(ctx, "pages/missing"))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		s := newStore(t)
+		require.NoError(t, s.Put(ctx, "shots/a.png", strings.NewReader("a")))
+		require.NoError(t, s.Delete(ctx, "shots/a.png"))
+
+		_, err := s.Get(ctx, "shots/a.png")
+		assert.True(t, werrors.IsNotFound(err), "got %v", err)
+		objects, err := s.List(ctx, "")
+		require.NoError(t, err)
+		assert.Empty(t, objects)
+	})
+
+	t.Run("list by prefix", func(t *testing.T) {
+		s := newStore(t)
+		for _, key := range []string{"shots/b/2.png", "pages/index.html", "shots/a.png", "shots/b/1.png", "shotsextra"} {
+			require.NoError(t, s.Put(ctx, key, strings.NewReader(key)))
+		}
+
+		objects, err := s.List(ctx, "")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"pages/index.html", "shots/a.png", "shots/b/1.png", "shots/b/2.png", "shotsextra"}, keys(objects))
+
+		objects, err = s.List(ctx, "shots/")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"shots/a.png", "shots/b/1.png", "shots/b/2.png"}, keys(objects))
+		assert.Equal(t, int64(len("shots/a.png")), objects[0].Size)
+		assert.False(t, objects[0].ModTime.IsZero())
+
+		objects, err = s.List(ctx, "shots/b/1")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"shots/b/1.png"}, keys(objects))
+
+		objects, err = s.List(ctx, "nothing/")
+		require.NoError(t, err)
+		assert.Empty(t, objects)
+	})
+
+	t.Run("content addressed keys", func(t *testing.T) {
+		s := newStore(t)
+		key, err := blob.PutContent(ctx, s, "screenshots", []byte("frame"))
+		require.NoError(t, err)
+		assert.Equal(t, blob.Key("screenshots", []byte("frame")), key)
+		assert.Equal(t, "frame", read(t, s, key))
+
+		again, err := blob.PutContent(ctx, s, "screenshots", []byte("frame"))
+		require.NoError(t, err)
+		assert.Equal(t, key, again)
+		objects, err := s.List(ctx, "screenshots/")
+		require.NoError(t, err)
+		assert.Len(t, objects, 1)
+	})
+
+	t.Run("invalid keys", func(t *testing.T) {
+		s := newStore(t)
+		for _, key := range []string{"", "/abs", "a//b", "../escape", "a/./b", "trailing/"} {
+			err := s.Put(ctx, key, strings.NewReader("x"))
+			assert.True(t, werrors.IsInvalidInput(err), "put %q: got %v", key, err)
+			_, err = s.Get(ctx, key)
+			assert.True(t, werrors.IsInvalidInput(err), "get %q: got %v", key, err)
+			err = s.Delete(ctx, key)
+			assert.True(t, werrors.IsInvalidInput(err), "delete %q: got %v", key, err)
+		}
+	})
+}
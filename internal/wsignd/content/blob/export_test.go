@@ -0,0 +1,5 @@
+package blob
+
+// NewFakeS3Store gives the external conformance test an S3Store backed by
+// an in-process fake
+var NewFakeS3Store = newFakeS3Store
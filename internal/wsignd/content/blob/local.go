@@ -0,0 +1,152 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// tempPrefix marks files being written by Put; List skips them
+const tempPrefix = ".tmp-"
+
+// LocalStore keeps blobs as files below a root directory. It suits a
+// single server, or replicas sharing a network filesystem.
+type LocalStore struct {
+	root string
+}
+
+// NewLocalStore creates a store rooted at dir. The directory is created
+// when the first blob is written.
+func NewLocalStore(dir string) *LocalStore {
+	return &LocalStore{root: dir}
+}
+
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
+
+// Put writes the blob to a temporary file beside its destination and
+// renames it into place
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader) error {
+	const op = "LocalStore.Put"
+
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	dest := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), tempPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("%s: writing %s: %w", op, key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("%s: writing %s: %w", op, key, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	const op = "LocalStore.Get"
+
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, notFound(op, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if info, err := f.Stat(); err == nil && info.IsDir() {
+		f.Close()
+		return nil, notFound(op, key)
+	}
+	return f, nil
+}
+
+// Delete removes the blob's file. Emptied directories are left behind.
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	const op = "LocalStore.Delete"
+
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	err := os.Remove(s.path(key))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// List walks the directory the prefix falls in, so a prefix ending in "/"
+// only reads that part of the tree
+func (s *LocalStore) List(ctx context.Context, prefix string) ([]Object, error) {
+	const op = "LocalStore.List"
+
+	start := s.root
+	if dir := path.Dir(prefix); strings.Contains(prefix, "/") && ValidateKey(dir) == nil {
+		start = s.path(dir)
+	}
+
+	var objects []Object
+	err := filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), tempPrefix) {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil // deleted while listing
+		}
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
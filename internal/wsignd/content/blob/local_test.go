@@ -0,0 +1,56 @@
+package blob_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/content/blob"
+	"github.com/wrale/wrale-signage/internal/wsignd/content/blob/blobtest"
+)
+
+func TestLocalStore(t *testing.T) {
+	blobtest.Run(t, func(t *testing.T) blob.Store {
+		return blob.NewLocalStore(filepath.Join(t.TempDir(), "blobs"))
+	})
+}
+
+func TestLocalStoreLayout(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := blob.NewLocalStore(dir)
+
+	key, err := blob.PutContent(ctx, s, "screenshots", []byte("frame"))
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(key)))
+	require.NoError(t, err)
+	assert.Equal(t, "frame", string(data))
+
+	// A write that is still in progress is not listed
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "screenshots", ".tmp-123"), []byte("partial"), 0o644))
+	objects, err := s.List(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	assert.Equal(t, key, objects[0].Key)
+
+	// A failed write leaves the previous blob in place
+	err = s.Put(ctx, key, &failingReader{})
+	require.Error(t, err)
+	data, err = os.ReadFile(filepath.Join(dir, filepath.FromSlash(key)))
+	require.NoError(t, err)
+	assert.Equal(t, "frame", string(data))
+	entries, err := os.ReadDir(filepath.Dir(filepath.Join(dir, filepath.FromSlash(key))))
+	require.NoError(t, err)
+	for _, e := range entries {
+		assert.False(t, strings.HasPrefix(e.Name(), ".tmp-"), "temporary file %s left behind", e.Name())
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) { return 0, os.ErrClosed }
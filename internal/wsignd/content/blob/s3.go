@@ -0,0 +1,324 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty request body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Options locates a bucket and the credentials to reach it
+type S3Options struct {
+	// Endpoint is the service URL, such as https://minio.internal:9000.
+	// Empty means AWS S3 in Region.
+	Endpoint string
+	// Region is the bucket's region, used when signing requests
+	Region string
+	// Bucket is the bucket blobs are stored in
+	Bucket string
+	// AccessKeyID and SecretAccessKey sign requests
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken accompanies temporary credentials
+	SessionToken string
+	// PathStyle addresses the bucket as <endpoint>/<bucket> rather than
+	// <bucket>.<endpoint>, as most self-hosted S3-compatible services expect
+	PathStyle bool
+}
+
+// S3Store keeps blobs in an S3-compatible bucket, which lets every replica
+// see every blob. Requests are signed with AWS Signature Version 4.
+type S3Store struct {
+	opts     S3Options
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewS3Store creates a store for the bucket in opts. A nil client uses
+// http.DefaultClient.
+func NewS3Store(opts S3Options, client *http.Client) (*S3Store, error) {
+	const op = "blob.NewS3Store"
+
+	if opts.Bucket == "" || opts.Region == "" {
+		return nil, werrors.NewError("INVALID_INPUT", "S3 bucket and region are required", op, werrors.ErrInvalidInput)
+	}
+	if opts.AccessKeyID == "" || opts.SecretAccessKey == "" {
+		return nil, werrors.NewError("INVALID_INPUT", "S3 access key ID and secret access key are required", op, werrors.ErrInvalidInput)
+	}
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + opts.Region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, werrors.NewError("INVALID_INPUT", fmt.Sprintf("invalid S3 endpoint %q", endpoint), op, werrors.ErrInvalidInput)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &S3Store{opts: opts, endpoint: u, client: client, now: time.Now}, nil
+}
+
+// objectURL returns the URL of key, or of the bucket itself when key is
+// empty
+func (s *S3Store) objectURL(key string) *url.URL {
+	u := &url.URL{Scheme: s.endpoint.Scheme, Host: s.endpoint.Host}
+	base := strings.TrimSuffix(s.endpoint.Path, "/")
+	if s.opts.PathStyle {
+		base += "/" + s.opts.Bucket
+	} else {
+		u.Host = s.opts.Bucket + "." + u.Host
+	}
+	u.Path = base + "/" + key
+	u.RawPath = uriEncode(base, false) + "/" + uriEncode(key, false)
+	return u
+}
+
+// Put reads the whole blob into memory, since S3 needs its length and the
+// signature covers its hash
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader) error {
+	const op = "S3Store.Put"
+
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("%s: reading %s: %w", op, key, err)
+	}
+	sum := sha256.Sum256(data)
+	resp, err := s.do(ctx, http.MethodPut, s.objectURL(key), bytes.NewReader(data), hex.EncodeToString(sum[:]))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %w", op, readS3Error(resp))
+	}
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	const op = "S3Store.Get"
+
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodGet, s.objectURL(key), nil, emptyPayloadHash)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp.Body, nil
+	}
+	defer resp.Body.Close()
+	s3Err := readS3Error(resp)
+	if s3Err.Code == "NoSuchKey" {
+		return nil, notFound(op, key)
+	}
+	return nil, fmt.Errorf("%s: %w", op, s3Err)
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	const op = "S3Store.Delete"
+
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodDelete, s.objectURL(key), nil, emptyPayloadHash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %w", op, readS3Error(resp))
+	}
+	return nil
+}
+
+// listBucketResult is the part of a ListObjectsV2 response the store reads
+type listBucketResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+}
+
+// List pages through ListObjectsV2, which returns keys in order
+func (s *S3Store) List(ctx context.Context, prefix string) ([]Object, error) {
+	const op = "S3Store.List"
+
+	var objects []Object
+	token := ""
+	for {
+		u := s.objectURL("")
+		query := url.Values{"list-type": {"2"}}
+		if prefix != "" {
+			query.Set("prefix", prefix)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		u.RawQuery = canonicalQuery(query)
+
+		resp, err := s.do(ctx, http.MethodGet, u, nil, emptyPayloadHash)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := readS3Error(resp)
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		var page listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: decoding listing: %w", op, err)
+		}
+
+		for _, c := range page.Contents {
+			objects = append(objects, Object{Key: c.Key, Size: c.Size, ModTime: c.LastModified})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+func (s *S3Store) do(ctx context.Context, method string, u *url.URL, body io.Reader, payloadHash string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if s.opts.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.opts.SessionToken)
+	}
+	s.sign(req, payloadHash, s.now().UTC())
+	return s.client.Do(req)
+}
+
+// sign adds a Signature Version 4 Authorization header covering the host
+// and every header already set on req
+func (s *S3Store) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.opts.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.opts.SecretAccessKey), date)
+	key = hmacSHA256(key, s.opts.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.opts.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query sorted by name the way the signature
+// expects, which is stricter than url.Values.Encode
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters, and
+// slashes too when encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// S3Error is an error response from the S3 service
+type S3Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *S3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("S3 request failed with status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("S3 request failed with status %d: %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+func readS3Error(resp *http.Response) *S3Error {
+	e := &S3Error{StatusCode: resp.StatusCode}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	_ = xml.Unmarshal(body, e)
+	e.StatusCode = resp.StatusCode
+	return e
+}
@@ -0,0 +1,15 @@
+package blob_test
+
+import (
+	"testing"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/content/blob"
+	"github.com/wrale/wrale-signage/internal/wsignd/content/blob/blobtest"
+)
+
+// TestS3Store runs the suite against an in-process fake of the S3 API
+func TestS3Store(t *testing.T) {
+	blobtest.Run(t, func(t *testing.T) blob.Store {
+		return blob.NewFakeS3Store(t)
+	})
+}
@@ -0,0 +1,97 @@
+package content
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/coalesce"
+)
+
+// coalescingRepository shares source lookups that are in flight at the same
+// time, such as those made when the displays at a site reconnect together
+// and resolve the same sequence
+type coalescingRepository struct {
+	Repository
+	byName  *coalesce.Group[*v1alpha1.ContentSource]
+	byNames *coalesce.Group[map[string]*v1alpha1.ContentSource]
+}
+
+// NewCoalescingRepository wraps repo so that concurrent GetContent calls for
+// the same name, and GetContentByNames calls for the same set of names,
+// make a single query. A source found this way may miss a change saved while
+// the shared query ran, as any read may; updates still check the version
+// they were read at.
+func NewCoalescingRepository(repo Repository) Repository {
+	return &coalescingRepository{
+		Repository: repo,
+		byName:     coalesce.New(cloneSource),
+		byNames:    coalesce.New(cloneSources),
+	}
+}
+
+func (r *coalescingRepository) GetContent(ctx context.Context, name string) (*v1alpha1.ContentSource, error) {
+	return r.byName.Do(ctx, name, func(ctx context.Context) (*v1alpha1.ContentSource, error) {
+		return r.Repository.GetContent(ctx, name)
+	})
+}
+
+// GetContentByNames keys calls by their distinct names in order, since the
+// result does not depend on how the names were given
+func (r *coalescingRepository) GetContentByNames(ctx context.Context, names []string) (map[string]*v1alpha1.ContentSource, error) {
+	key := append([]string(nil), names...)
+	sort.Strings(key)
+	key = compactStrings(key)
+	return r.byNames.Do(ctx, strings.Join(key, "\x00"), func(ctx context.Context) (map[string]*v1alpha1.ContentSource, error) {
+		return r.Repository.GetContentByNames(ctx, names)
+	})
+}
+
+// compactStrings drops adjacent duplicates from sorted
+func compactStrings(sorted []string) []string {
+	out := sorted[:0]
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// cloneSource returns a copy of source that shares no mutable state with it
+func cloneSource(source *v1alpha1.ContentSource) *v1alpha1.ContentSource {
+	if source == nil {
+		return nil
+	}
+	c := *source
+	if source.Spec.Properties != nil {
+		c.Spec.Properties = make(map[string]string, len(source.Spec.Properties))
+		for k, v := range source.Spec.Properties {
+			c.Spec.Properties[k] = v
+		}
+	}
+	if source.Spec.AllowedPaths != nil {
+		c.Spec.AllowedPaths = append([]string{}, source.Spec.AllowedPaths...)
+	}
+	if source.Spec.Tags != nil {
+		c.Spec.Tags = append([]string{}, source.Spec.Tags...)
+	}
+	if source.Status.Validation != nil {
+		report := *source.Status.Validation
+		c.Status.Validation = &report
+	}
+	return &c
+}
+
+// cloneSources copies each source in a lookup by name
+func cloneSources(sources map[string]*v1alpha1.ContentSource) map[string]*v1alpha1.ContentSource {
+	if sources == nil {
+		return nil
+	}
+	c := make(map[string]*v1alpha1.ContentSource, len(sources))
+	for name, source := range sources {
+		c[name] = cloneSource(source)
+	}
+	return c
+}
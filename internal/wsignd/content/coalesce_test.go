@@ -0,0 +1,181 @@
+package content_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	"github.com/wrale/wrale-signage/internal/wsignd/content/memory"
+)
+
+// roundTrip is how long each simulated query takes
+const roundTrip = 5 * time.Millisecond
+
+// countingRepository counts source lookups and makes each take a round trip
+type countingRepository struct {
+	content.Repository
+	queries atomic.Int64
+}
+
+func (r *countingRepository) GetContent(ctx context.Context, name string) (*v1alpha1.ContentSource, error) {
+	r.queries.Add(1)
+	time.Sleep(roundTrip)
+	return r.Repository.GetContent(ctx, name)
+}
+
+func (r *countingRepository) GetContentByNames(ctx context.Context, names []string) (map[string]*v1alpha1.ContentSource, error) {
+	r.queries.Add(1)
+	time.Sleep(roundTrip)
+	return r.Repository.GetContentByNames(ctx, names)
+}
+
+// newCountingRepository stores the named sources
+func newCountingRepository(t testing.TB, names ...string) *countingRepository {
+	repo := &countingRepository{Repository: memory.NewRepository()}
+	for _, name := range names {
+		require.NoError(t, repo.CreateContent(context.Background(), &v1alpha1.ContentSource{
+			ObjectMeta: v1alpha1.ObjectMeta{Name: name},
+			Spec: v1alpha1.ContentSourceSpec{
+				URL:  "https://example.com/" + name,
+				Type: "static-page",
+				Tags: []string{"lobby"},
+			},
+		}))
+	}
+	return repo
+}
+
+// storm makes n concurrent calls of get, as displays reconnecting together
+// do, and returns what each got
+func storm[V any](n int, get func(i int) (V, error)) ([]V, []error) {
+	results, errs := make([]V, n), make([]error, n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			results[i], errs[i] = get(i)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return results, errs
+}
+
+func TestCoalescingRepository(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("identical lookups share a query", func(t *testing.T) {
+		counting := newCountingRepository(t, "menus")
+		repo := content.NewCoalescingRepository(counting)
+
+		sources, errs := storm(100, func(int) (*v1alpha1.ContentSource, error) {
+			return repo.GetContent(ctx, "menus")
+		})
+		assert.LessOrEqual(t, counting.queries.Load(), int64(5))
+		for i := range sources {
+			require.NoError(t, errs[i])
+			assert.Equal(t, "menus", sources[i].Name)
+		}
+
+		// Each caller can change its copy without affecting the others
+		sources[0].Spec.Tags[0] = "changed"
+		assert.Equal(t, []string{"lobby"}, sources[1].Spec.Tags)
+	})
+
+	t.Run("different names are not shared", func(t *testing.T) {
+		names := []string{"menus", "events", "weather", "news"}
+		counting := newCountingRepository(t, names...)
+		repo := content.NewCoalescingRepository(counting)
+
+		sources, errs := storm(100, func(i int) (*v1alpha1.ContentSource, error) {
+			return repo.GetContent(ctx, names[i%len(names)])
+		})
+		for i := range sources {
+			require.NoError(t, errs[i])
+			assert.Equal(t, names[i%len(names)], sources[i].Name)
+		}
+	})
+
+	t.Run("missing sources fail every caller", func(t *testing.T) {
+		counting := newCountingRepository(t)
+		repo := content.NewCoalescingRepository(counting)
+
+		_, errs := storm(20, func(int) (*v1alpha1.ContentSource, error) {
+			return repo.GetContent(ctx, "menus")
+		})
+		for _, err := range errs {
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("name sets are shared regardless of order", func(t *testing.T) {
+		counting := newCountingRepository(t, "menus", "events", "weather")
+		repo := content.NewCoalescingRepository(counting)
+		orders := [][]string{
+			{"menus", "events", "weather"},
+			{"weather", "menus", "events", "menus"},
+		}
+
+		found, errs := storm(100, func(i int) (map[string]*v1alpha1.ContentSource, error) {
+			return repo.GetContentByNames(ctx, orders[i%len(orders)])
+		})
+		assert.LessOrEqual(t, counting.queries.Load(), int64(5))
+		for i := range found {
+			require.NoError(t, errs[i])
+			assert.Len(t, found[i], 3)
+		}
+
+		// A different set is looked up on its own
+		subset, err := repo.GetContentByNames(ctx, []string{"menus"})
+		require.NoError(t, err)
+		assert.Len(t, subset, 1)
+	})
+}
+
+// BenchmarkReconnectStorm resolves one sequence for 100 displays at once
+// and reports how many queries each storm costs
+func BenchmarkReconnectStorm(b *testing.B) {
+	const displays = 100
+	names := make([]string, 12)
+	for i := range names {
+		names[i] = fmt.Sprintf("slide-%02d", i)
+	}
+
+	for _, bc := range []struct {
+		name string
+		wrap func(content.Repository) content.Repository
+	}{
+		{name: "direct", wrap: func(r content.Repository) content.Repository { return r }},
+		{name: "coalesced", wrap: content.NewCoalescingRepository},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			counting := newCountingRepository(b, names...)
+			repo := bc.wrap(counting)
+			ctx := context.Background()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, errs := storm(displays, func(int) (map[string]*v1alpha1.ContentSource, error) {
+					return repo.GetContentByNames(ctx, names)
+				})
+				for _, err := range errs {
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+			b.ReportMetric(float64(counting.queries.Load())/float64(b.N), "queries/storm")
+		})
+	}
+}
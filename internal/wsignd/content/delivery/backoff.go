@@ -0,0 +1,74 @@
+package delivery
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Backoff paces the reconnects of a manager kept connected by Run. Waits
+// use full jitter: each is picked at random up to a ceiling that doubles
+// with every consecutive failure, so a fleet that lost the same server
+// spreads out instead of retrying in lockstep.
+type Backoff struct {
+	// Initial is the ceiling of the wait after the first failure
+	Initial time.Duration
+	// Max caps the ceiling however many attempts have failed
+	Max time.Duration
+	// Splay is the window the first connection is delayed by at random, so
+	// that displays powered on together do not connect together
+	Splay time.Duration
+	// Reset is how long a connection must stay up for the next disconnect
+	// to count as the first failure again. A server that accepts and then
+	// drops connections at once is backed off from like one that refuses
+	// them.
+	Reset time.Duration
+}
+
+// DefaultBackoff returns the backoff used when none is configured
+func DefaultBackoff() Backoff {
+	return Backoff{
+		Initial: time.Second,
+		Max:     2 * time.Minute,
+		Splay:   10 * time.Second,
+		Reset:   time.Minute,
+	}
+}
+
+// ceiling returns the longest wait after attempt consecutive failures
+func (b Backoff) ceiling(attempt int) time.Duration {
+	d := b.Initial
+	for i := 1; i < attempt && d < b.Max; i++ {
+		d *= 2
+	}
+	if d > b.Max {
+		d = b.Max
+	}
+	return d
+}
+
+// reconnectHint is a server's request to wait at least delay before
+// reconnecting, plus a random part of spread
+type reconnectHint struct {
+	delay  time.Duration
+	spread time.Duration
+}
+
+// retryAfter reads the Retry-After header of a refused upgrade, given in
+// seconds or as an HTTP date. It returns zero when there is none.
+func retryAfter(resp *http.Response, now time.Time) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,307 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+	"github.com/wrale/wrale-signage/internal/wsignd/overload"
+)
+
+// instance is a server replica accepting control sockets up to a cap, the
+// WebSocket upgrades past it refused by the server's load shedder
+type instance struct {
+	server *httptest.Server
+
+	mu    sync.Mutex
+	conns map[*websocket.Conn]bool
+}
+
+func newInstance(t *testing.T, maxSockets int) *instance {
+	in := &instance{conns: make(map[*websocket.Conn]bool)}
+	shedder := overload.New(context.Background(), config.OverloadConfig{
+		MaxInFlight:          1,
+		MaxExpensiveInFlight: 1,
+		MaxWebSockets:        maxSockets,
+		WebSocketRetryAfter:  time.Second,
+	}, func(*http.Request) overload.Class { return overload.ClassWebSocket }, discardLogger())
+
+	upgrader := websocket.Upgrader{}
+	in.server = httptest.NewUnstartedServer(shedder.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		in.mu.Lock()
+		in.conns[ws] = true
+		in.mu.Unlock()
+		defer func() {
+			in.mu.Lock()
+			delete(in.conns, ws)
+			in.mu.Unlock()
+			ws.Close()
+		}()
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})))
+	t.Cleanup(in.server.Close)
+	return in
+}
+
+// count returns how many displays are connected
+func (in *instance) count() int {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return len(in.conns)
+}
+
+// drain tells every display to spread its reconnect over spread and closes
+// its connection, as a server shutting down does
+func (in *instance) drain(spread time.Duration) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	for ws := range in.conns {
+		_ = ws.WriteJSON(&v1alpha1.ControlMessage{
+			TypeMeta:  v1alpha1.TypeMeta{APIVersion: v1alpha1.ControlAPIVersion},
+			Type:      v1alpha1.ControlMessageReconnect,
+			Reconnect: &v1alpha1.ControlReconnect{Spread: v1alpha1.Milliseconds(spread.Milliseconds())},
+		})
+		_ = ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"))
+	}
+}
+
+// balancer routes each new connection to the next instance in service,
+// round robin, the way a load balancer in front of the replicas does.
+// Connections fail when none is in service.
+type balancer struct {
+	mu        sync.Mutex
+	instances []*instance
+	next      int
+}
+
+func (b *balancer) route(in ...*instance) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.instances = in
+}
+
+func (b *balancer) dial(ctx context.Context) (net.Conn, error) {
+	b.mu.Lock()
+	if len(b.instances) == 0 {
+		b.mu.Unlock()
+		return nil, errors.New("connection refused")
+	}
+	in := b.instances[b.next%len(b.instances)]
+	b.next++
+	b.mu.Unlock()
+
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", in.server.Listener.Addr().String())
+}
+
+// fleet is many displays kept connected through a balancer
+type fleet struct {
+	managers []*Manager
+	dials    []atomic.Int64
+}
+
+func startFleet(t *testing.T, lb *balancer, n int, backoff Backoff) *fleet {
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &fleet{dials: make([]atomic.Int64, n)}
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		dials := &f.dials[i]
+		m := NewManager(uuid.New(), discardLogger(), WithBackoff(backoff), WithDialer(&websocket.Dialer{
+			NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				dials.Add(1)
+				return lb.dial(ctx)
+			},
+		}))
+		f.managers = append(f.managers, m)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = m.Run(ctx, "ws://signage.example.com/ws")
+		}()
+	}
+	t.Cleanup(func() {
+		cancel()
+		for _, m := range f.managers {
+			_ = m.Close()
+		}
+		wg.Wait()
+	})
+	return f
+}
+
+// maxDials returns the most connection attempts any display made since
+// the counts were last reset
+func (f *fleet) maxDials() int64 {
+	var most int64
+	for i := range f.dials {
+		if n := f.dials[i].Swap(0); n > most {
+			most = n
+		}
+	}
+	return most
+}
+
+func TestRun_RollingDeploySpreadsFleet(t *testing.T) {
+	if testing.Short() {
+		t.Skip("simulates a rolling deploy over several seconds")
+	}
+	const displays = 120
+	backoff := Backoff{
+		Initial: 20 * time.Millisecond,
+		Max:     400 * time.Millisecond,
+		Splay:   200 * time.Millisecond,
+		Reset:   100 * time.Millisecond,
+	}
+
+	old := newInstance(t, displays)
+	old.server.Start()
+	lb := &balancer{}
+	lb.route(old)
+	f := startFleet(t, lb, displays, backoff)
+	require.Eventually(t, func() bool { return old.count() == displays }, 5*time.Second, 10*time.Millisecond)
+	f.maxDials()
+
+	// Each new replica takes 60% of the fleet at most. The first comes up
+	// a while before the second; the old one is drained once the first is
+	// in service.
+	first, second := newInstance(t, displays*6/10), newInstance(t, displays*6/10)
+	first.server.Start()
+	lb.route(first)
+	old.drain(600 * time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	second.server.Start()
+	lb.route(first, second)
+
+	require.Eventually(t, func() bool {
+		return first.count()+second.count() == displays
+	}, 10*time.Second, 10*time.Millisecond, "first %d, second %d", first.count(), second.count())
+	assert.Zero(t, old.count())
+
+	// Neither replica is left with the whole fleet, and refused displays
+	// waited as told instead of retrying in a loop
+	tolerance := displays / 10
+	assert.InDelta(t, displays/2, first.count(), float64(tolerance), "first replica")
+	assert.InDelta(t, displays/2, second.count(), float64(tolerance), "second replica")
+	assert.LessOrEqual(t, f.maxDials(), int64(6))
+}
+
+func TestRun_BacksOffWhileNoServerIsUp(t *testing.T) {
+	lb := &balancer{}
+	f := startFleet(t, lb, 50, Backoff{
+		Initial: 10 * time.Millisecond,
+		Max:     200 * time.Millisecond,
+		Reset:   time.Second,
+	})
+	time.Sleep(time.Second)
+
+	// Waits average half the ceiling, so about ten attempts a second once
+	// it is reached and a few more on the way up; a hot loop makes
+	// thousands
+	assert.LessOrEqual(t, f.maxDials(), int64(30), "displays hot-loop")
+
+	in := newInstance(t, 50)
+	in.server.Start()
+	lb.route(in)
+	require.Eventually(t, func() bool { return in.count() == 50 }, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestRun_HonoursRetryAfter(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts []time.Time
+	)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts = append(attempts, time.Now())
+		refused := len(attempts) == 1
+		mu.Unlock()
+		if refused {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	m := NewManager(uuid.New(), discardLogger(), WithBackoff(Backoff{Initial: 10 * time.Millisecond, Max: 10 * time.Millisecond}))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.Run(ctx, "ws"+server.URL[len("http"):]) }()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(attempts) >= 2
+	}, 3*time.Second, 10*time.Millisecond)
+	mu.Lock()
+	gap := attempts[1].Sub(attempts[0])
+	mu.Unlock()
+	assert.GreaterOrEqual(t, gap, time.Second)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	require.NoError(t, m.Close())
+}
+
+func TestBackoffCeiling(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: 10 * time.Second}
+	for attempt, want := range map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		4:  8 * time.Second,
+		5:  10 * time.Second,
+		80: 10 * time.Second,
+	} {
+		assert.Equal(t, want, b.ceiling(attempt), "attempt %d", attempt)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	header := func(value string) *http.Response {
+		return &http.Response{Header: http.Header{"Retry-After": []string{value}}}
+	}
+	assert.Equal(t, 30*time.Second, retryAfter(header("30"), now))
+	assert.Equal(t, 90*time.Second, retryAfter(header(now.Add(90*time.Second).Format(http.TimeFormat)), now))
+	assert.Zero(t, retryAfter(header("soon"), now))
+	assert.Zero(t, retryAfter(&http.Response{Header: http.Header{}}, now))
+	assert.Zero(t, retryAfter(nil, now))
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
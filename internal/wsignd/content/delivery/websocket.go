@@ -2,6 +2,9 @@ package delivery
 
 import (
 	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,46 +15,211 @@ import (
 
 type Manager struct {
 	displayID uuid.UUID
-	conn      *websocket.Conn
 	sequence  chan *v1alpha1.ContentSequence
 	errors    chan error
 	done      chan struct{}
+	closeOnce sync.Once
 	logger    *slog.Logger
+
+	dialer  *websocket.Dialer
+	backoff Backoff
+	// rand picks reconnect waits; it is only used by Run
+	rand *rand.Rand
+
+	// mu guards the current connection and the reconnect hint the server
+	// sent on it. writeMu serialises writes, which the connection does not
+	// allow concurrently.
+	mu      sync.Mutex
+	conn    *websocket.Conn
+	hint    *reconnectHint
+	writeMu sync.Mutex
+
+	// unknown counts messages ignored because their type or version is not
+	// understood
+	unknown atomic.Uint64
+}
+
+// capabilities are the message types the manager acts on, declared to the
+// server in status reports
+var capabilities = []v1alpha1.ControlMessageType{
+	v1alpha1.ControlMessageSequenceUpdate,
+	v1alpha1.ControlMessageReload,
+	v1alpha1.ControlMessageReconnect,
+}
+
+// Option configures a Manager
+type Option func(*Manager)
+
+// WithBackoff sets how Run paces reconnects
+func WithBackoff(b Backoff) Option {
+	return func(m *Manager) {
+		m.backoff = b
+	}
+}
+
+// WithDialer sets the dialer connections are opened with
+func WithDialer(d *websocket.Dialer) Option {
+	return func(m *Manager) {
+		m.dialer = d
+	}
 }
 
-func NewManager(displayID uuid.UUID, logger *slog.Logger) *Manager {
-	return &Manager{
+func NewManager(displayID uuid.UUID, logger *slog.Logger, opts ...Option) *Manager {
+	m := &Manager{
 		displayID: displayID,
 		sequence:  make(chan *v1alpha1.ContentSequence, 1),
 		errors:    make(chan error, 1),
 		done:      make(chan struct{}),
 		logger:    logger,
+		dialer:    websocket.DefaultDialer,
+		backoff:   DefaultBackoff(),
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
 }
 
+// Connect opens one connection to wsURL. Errors ending it are delivered on
+// GetErrors and it is not reopened; use Run to stay connected.
 func (m *Manager) Connect(ctx context.Context, wsURL string) error {
-	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+	conn, resp, err := m.dialer.DialContext(ctx, wsURL, nil)
+	if resp != nil && resp.Body != nil {
+		resp.Body.Close()
+	}
 	if err != nil {
 		return err
 	}
-	m.conn = conn
 
-	go m.readMessages()
-	go m.writeStatus()
+	go func() {
+		if err := m.serve(conn); err != nil {
+			m.report(err)
+		}
+	}()
 
 	return nil
 }
 
+// Run keeps the manager connected to wsURL until ctx is done or the manager
+// is closed. The first attempt is delayed by a random part of the backoff's
+// splay. After a failed attempt or a lost connection Run waits as the
+// backoff allows, or as long as the server asked, with a Retry-After on a
+// refused upgrade or a RECONNECT message before it closed the connection,
+// plus a random spread. Errors ending a connection are logged rather than
+// delivered on GetErrors, which only carries ReloadRequiredError.
+func (m *Manager) Run(ctx context.Context, wsURL string) error {
+	wait := m.random(m.backoff.Splay)
+	attempt := 0
+	for {
+		if !m.sleep(ctx, wait) {
+			return ctx.Err()
+		}
+
+		conn, resp, err := m.dialer.DialContext(ctx, wsURL, nil)
+		if resp != nil && resp.Body != nil {
+			resp.Body.Close()
+		}
+		if err != nil {
+			attempt++
+			var hint *reconnectHint
+			if after := retryAfter(resp, time.Now()); after > 0 {
+				hint = &reconnectHint{delay: after, spread: m.backoff.ceiling(attempt)}
+			}
+			wait = m.retryWait(attempt, hint)
+			m.logger.Warn("connection attempt failed",
+				"error", err,
+				"attempt", attempt,
+				"retryIn", wait,
+				"displayId", m.displayID,
+			)
+			continue
+		}
+
+		// Closing the connection is what ends serve when ctx is done
+		connected := time.Now()
+		stop := context.AfterFunc(ctx, func() { conn.Close() })
+		err = m.serve(conn)
+		stop()
+		select {
+		case <-m.done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if time.Since(connected) >= m.backoff.Reset {
+			attempt = 0
+		}
+		attempt++
+		wait = m.retryWait(attempt, m.takeHint())
+		m.logger.Info("connection lost",
+			"error", err,
+			"retryIn", wait,
+			"displayId", m.displayID,
+		)
+	}
+}
+
+// retryWait returns how long to wait after attempt consecutive failures.
+// A server hint sets the least wait; otherwise the wait is picked at random
+// up to the backoff's ceiling.
+func (m *Manager) retryWait(attempt int, hint *reconnectHint) time.Duration {
+	if hint != nil {
+		return hint.delay + m.random(hint.spread)
+	}
+	return m.random(m.backoff.ceiling(attempt))
+}
+
+// random returns a random duration below d, or zero when d is not positive
+func (m *Manager) random(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(m.rand.Int63n(int64(d)))
+}
+
+// sleep waits for d and reports false if ctx is done or the manager is
+// closed first
+func (m *Manager) sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-m.done:
+		return false
+	}
+}
+
+// takeHint returns and clears the reconnect hint the server sent
+func (m *Manager) takeHint() *reconnectHint {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hint := m.hint
+	m.hint = nil
+	return hint
+}
+
 func (m *Manager) Close() error {
-	close(m.done)
-	if m.conn != nil {
-		if err := m.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")); err != nil {
+	m.closeOnce.Do(func() { close(m.done) })
+
+	m.mu.Lock()
+	conn := m.conn
+	m.mu.Unlock()
+	if conn != nil {
+		m.writeMu.Lock()
+		err := conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		m.writeMu.Unlock()
+		if err != nil {
 			m.logger.Error("error sending close message",
 				"error", err,
 				"displayId", m.displayID,
 			)
 		}
-		if err := m.conn.Close(); err != nil {
+		if err := conn.Close(); err != nil {
 			m.logger.Error("error closing websocket connection",
 				"error", err,
 				"displayId", m.displayID,
@@ -70,36 +238,76 @@ func (m *Manager) GetErrors() <-chan error {
 	return m.errors
 }
 
-func (m *Manager) readMessages() {
-	defer func() {
-		if err := m.conn.Close(); err != nil {
-			m.logger.Error("error closing websocket read connection",
-				"error", err,
-				"displayId", m.displayID,
-			)
-		}
-	}()
+// UnknownMessages returns how many messages from the server were ignored
+// because their type or version is not understood
+func (m *Manager) UnknownMessages() uint64 {
+	return m.unknown.Load()
+}
+
+// report delivers err on GetErrors unless the manager is closed first
+func (m *Manager) report(err error) {
+	select {
+	case m.errors <- err:
+	case <-m.done:
+	}
+}
+
+// serve reads messages from conn and reports status on it until either
+// fails or the manager is closed. It returns the error that ended the
+// connection, nil once the manager is closed.
+func (m *Manager) serve(conn *websocket.Conn) error {
+	m.mu.Lock()
+	m.conn = conn
+	m.hint = nil
+	m.mu.Unlock()
+
+	stop := make(chan struct{})
+	written := make(chan error, 1)
+	go func() { written <- m.writeStatus(conn, stop) }()
+
+	err := m.readMessages(conn)
+	close(stop)
+	m.mu.Lock()
+	m.conn = nil
+	m.mu.Unlock()
+	if writeErr := <-written; err == nil {
+		err = writeErr
+	}
+	if closeErr := conn.Close(); closeErr != nil && err == nil {
+		m.logger.Error("error closing websocket read connection",
+			"error", closeErr,
+			"displayId", m.displayID,
+		)
+	}
+
+	select {
+	case <-m.done:
+		return nil
+	default:
+		return err
+	}
+}
 
-	if err := m.conn.SetReadDeadline(time.Now().Add(60 * time.Second)); err != nil {
+func (m *Manager) readMessages(conn *websocket.Conn) error {
+	if err := conn.SetReadDeadline(time.Now().Add(60 * time.Second)); err != nil {
 		m.logger.Error("error setting read deadline",
 			"error", err,
 			"displayId", m.displayID,
 		)
-		m.errors <- err
-		return
+		return err
 	}
 
-	m.conn.SetPongHandler(func(string) error {
-		return m.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 	})
 
 	for {
 		select {
 		case <-m.done:
-			return
+			return nil
 		default:
 			var msg v1alpha1.ControlMessage
-			err := m.conn.ReadJSON(&msg)
+			err := conn.ReadJSON(&msg)
 			if err != nil {
 				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
 					m.logger.Error("websocket read error",
@@ -107,8 +315,17 @@ func (m *Manager) readMessages() {
 						"displayId", m.displayID,
 					)
 				}
-				m.errors <- err
-				return
+				return err
+			}
+
+			if !msg.Understood() {
+				m.logger.Debug("ignored unknown message",
+					"type", msg.Type,
+					"apiVersion", msg.APIVersion,
+					"displayId", m.displayID,
+					"unknownMessages", m.unknown.Add(1),
+				)
+				continue
 			}
 
 			switch msg.Type {
@@ -117,31 +334,45 @@ func (m *Manager) readMessages() {
 					m.sequence <- msg.Sequence
 				}
 			case v1alpha1.ControlMessageReload:
-				m.errors <- &ReloadRequiredError{At: time.Now()}
+				m.report(&ReloadRequiredError{At: time.Now()})
+			case v1alpha1.ControlMessageReconnect:
+				// The server closes the connection next; Run waits as asked
+				if msg.Reconnect != nil {
+					m.mu.Lock()
+					m.hint = &reconnectHint{
+						delay:  time.Duration(msg.Reconnect.Delay) * time.Millisecond,
+						spread: time.Duration(msg.Reconnect.Spread) * time.Millisecond,
+					}
+					m.mu.Unlock()
+				}
 			}
 		}
 	}
 }
 
-func (m *Manager) writeStatus() {
+// writeStatus reports status on conn periodically and keeps it alive with
+// pings until stop is closed. A failed write closes conn, ending the read
+// loop, and is returned.
+func (m *Manager) writeStatus(conn *websocket.Conn, stop <-chan struct{}) error {
 	ticker := time.NewTicker(30 * time.Second)
-	defer func() {
-		ticker.Stop()
-		if err := m.conn.Close(); err != nil {
+	defer ticker.Stop()
+
+	fail := func(msg string, err error) error {
+		m.logger.Error(msg,
+			"error", err,
+			"displayId", m.displayID,
+		)
+		if err := conn.Close(); err != nil {
 			m.logger.Error("error closing websocket write connection",
 				"error", err,
 				"displayId", m.displayID,
 			)
 		}
-	}()
+		return err
+	}
 
-	if err := m.sendStatus("", nil); err != nil {
-		m.logger.Error("error sending initial status",
-			"error", err,
-			"displayId", m.displayID,
-		)
-		m.errors <- err
-		return
+	if err := m.sendStatus(conn, "", nil); err != nil {
+		return fail("error sending initial status", err)
 	}
 
 	pingTicker := time.NewTicker(54 * time.Second)
@@ -149,46 +380,42 @@ func (m *Manager) writeStatus() {
 
 	for {
 		select {
-		case <-m.done:
-			return
+		case <-stop:
+			return nil
 		case <-ticker.C:
-			if err := m.sendStatus("", nil); err != nil {
-				m.logger.Error("error sending status update",
-					"error", err,
-					"displayId", m.displayID,
-				)
-				m.errors <- err
-				return
+			if err := m.sendStatus(conn, "", nil); err != nil {
+				return fail("error sending status update", err)
 			}
 		case <-pingTicker.C:
-			if err := m.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-				m.logger.Error("error sending ping",
-					"error", err,
-					"displayId", m.displayID,
-				)
-				m.errors <- err
-				return
+			m.writeMu.Lock()
+			err := conn.WriteMessage(websocket.PingMessage, nil)
+			m.writeMu.Unlock()
+			if err != nil {
+				return fail("error sending ping", err)
 			}
 		}
 	}
 }
 
-func (m *Manager) sendStatus(currentURL string, lastErr *string) error {
+func (m *Manager) sendStatus(conn *websocket.Conn, currentURL string, lastErr *string) error {
 	msg := v1alpha1.ControlMessage{
 		Type: v1alpha1.ControlMessageStatus,
 		TypeMeta: v1alpha1.TypeMeta{
 			Kind:       "ControlMessage",
-			APIVersion: "v1alpha1",
+			APIVersion: v1alpha1.ControlAPIVersion,
 		},
 		Timestamp: time.Now(),
 		Status: &v1alpha1.ControlStatus{
-			CurrentURL: currentURL,
-			LastError:  lastErr,
-			UpdatedAt:  time.Now(),
+			CurrentURL:   currentURL,
+			LastError:    lastErr,
+			UpdatedAt:    time.Now(),
+			Capabilities: capabilities,
 		},
 	}
 
-	if err := m.conn.SetWriteDeadline(time.Now().Add(10 * time.Second)); err != nil {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+	if err := conn.SetWriteDeadline(time.Now().Add(10 * time.Second)); err != nil {
 		m.logger.Error("error setting write deadline",
 			"error", err,
 			"displayId", m.displayID,
@@ -196,7 +423,7 @@ func (m *Manager) sendStatus(currentURL string, lastErr *string) error {
 		return err
 	}
 
-	return m.conn.WriteJSON(msg)
+	return conn.WriteJSON(msg)
 }
 
 type ReloadRequiredError struct {
@@ -0,0 +1,73 @@
+package delivery
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+)
+
+func TestManagerIgnoresUnknownMessages(t *testing.T) {
+	statuses := make(chan v1alpha1.ControlMessage, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+
+		var status v1alpha1.ControlMessage
+		if err := ws.ReadJSON(&status); err != nil {
+			return
+		}
+		statuses <- status
+
+		for _, m := range []string{
+			`{"apiVersion":"v1alpha1","type":"SHUTDOWN","timestamp":"2024-05-01T12:00:00Z"}`,
+			`{"apiVersion":"v2","type":"SEQUENCE_UPDATE","sequence":{"items":[{"url":"https://example.com/v2"}]}}`,
+			`{"apiVersion":"v1alpha1","type":"SEQUENCE_UPDATE","sequence":{"items":[{"url":"https://example.com/menu"}]},"extra":true}`,
+		} {
+			if err := ws.WriteMessage(websocket.TextMessage, []byte(m)); err != nil {
+				return
+			}
+		}
+		// Hold the connection open until the client closes it
+		_, _, _ = ws.ReadMessage()
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	m := NewManager(uuid.New(), logger)
+	require.NoError(t, m.Connect(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http")))
+	defer m.Close()
+
+	select {
+	case status := <-statuses:
+		assert.Equal(t, v1alpha1.ControlAPIVersion, status.APIVersion)
+		assert.ElementsMatch(t, capabilities, status.Status.Capabilities)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no initial status report")
+	}
+
+	select {
+	case seq := <-m.GetSequence():
+		assert.Equal(t, "https://example.com/menu", seq.Items[0].URL)
+	case err := <-m.GetErrors():
+		t.Fatalf("unexpected error: %v", err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("sequence update not delivered")
+	}
+	assert.Equal(t, uint64(2), m.UnknownMessages())
+}
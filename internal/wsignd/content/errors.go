@@ -1,8 +1,78 @@
 package content
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
 
 var (
 	ErrContentStale      = errors.New("content not seen recently")
 	ErrContentUnreliable = errors.New("content has high error rate")
 )
+
+// MissingSourcesError reports the content sources a sequence refers to
+// that do not exist. It matches werrors.ErrNotFound.
+type MissingSourcesError struct {
+	// Names lists the missing sources in the order they were referenced
+	Names []string
+}
+
+func (e *MissingSourcesError) Error() string {
+	if len(e.Names) == 1 {
+		return fmt.Sprintf("content source %q does not exist", e.Names[0])
+	}
+	quoted := make([]string, len(e.Names))
+	for i, name := range e.Names {
+		quoted[i] = fmt.Sprintf("%q", name)
+	}
+	return fmt.Sprintf("content sources %s do not exist", strings.Join(quoted, ", "))
+}
+
+// Unwrap lets callers treat missing sources as not found
+func (e *MissingSourcesError) Unwrap() error {
+	return werrors.ErrNotFound
+}
+
+// SourceExistsError reports that a content source could not be created
+// because one with the same name, ignoring case, already exists. It matches
+// werrors.ErrConflict.
+type SourceExistsError struct {
+	// Existing is the source already stored under the name
+	Existing *v1alpha1.ContentSource
+}
+
+func (e *SourceExistsError) Error() string {
+	return fmt.Sprintf("content source %q already exists", e.Existing.Name)
+}
+
+// Unwrap lets callers treat an existing source as a conflict
+func (e *SourceExistsError) Unwrap() error {
+	return werrors.ErrConflict
+}
+
+// SourceInUseError reports that a content source could not be removed
+// because other resources still refer to it. It matches
+// werrors.ErrConflict.
+type SourceInUseError struct {
+	// Name is the source that was to be removed
+	Name string
+	// References lists the resources referring to it
+	References []v1alpha1.ResourceReference
+}
+
+func (e *SourceInUseError) Error() string {
+	names := make([]string, len(e.References))
+	for i, ref := range e.References {
+		names[i] = ref.Kind + " " + ref.Name
+	}
+	return fmt.Sprintf("content source %q is still used by %s", e.Name, strings.Join(names, ", "))
+}
+
+// Unwrap lets callers treat a source in use as a conflict
+func (e *SourceInUseError) Unwrap() error {
+	return werrors.ErrConflict
+}
@@ -1,9 +1,12 @@
 package content
 
 import (
+	"bytes"
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
 )
 
 type EventType string
@@ -14,6 +17,9 @@ const (
 	EventContentVisible     EventType = "CONTENT_VISIBLE"
 	EventContentHidden      EventType = "CONTENT_HIDDEN"
 	EventContentInteractive EventType = "CONTENT_INTERACTIVE"
+	// EventContentPrefetchProgress reports how much of a URL a display has
+	// downloaded ahead of showing it, in the event's metrics
+	EventContentPrefetchProgress EventType = "CONTENT_PREFETCH_PROGRESS"
 )
 
 type Event struct {
@@ -33,11 +39,16 @@ type EventError struct {
 	Details map[string]interface{}
 }
 
+// EventMetrics are the timings of a content load in whole milliseconds.
+// Displays may report fractions, which are rounded as they are read.
+// Prefetch progress events carry byte counts instead.
 type EventMetrics struct {
-	LoadTime        int64
-	RenderTime      int64
-	InteractiveTime int64
+	LoadTime        v1alpha1.Milliseconds
+	RenderTime      v1alpha1.Milliseconds
+	InteractiveTime v1alpha1.Milliseconds
 	ResourceStats   *ResourceStats
+	BytesDownloaded int64
+	BytesTotal      int64
 }
 
 type ResourceStats struct {
@@ -50,3 +61,131 @@ type EventBatch struct {
 	DisplayID uuid.UUID
 	Events    []Event
 }
+
+// PrefetchProgress is the latest prefetch progress a display reported for a
+// URL. Stores keep one per display and URL, replacing it only with progress
+// reported at the same time or later, so events arriving out of order
+// cannot move it backwards.
+type PrefetchProgress struct {
+	DisplayID       uuid.UUID
+	URL             string
+	BytesDownloaded int64
+	BytesTotal      int64
+	ReportedAt      time.Time
+}
+
+// NewPrefetchProgress reads the progress a prefetch progress event reports
+func NewPrefetchProgress(event Event) PrefetchProgress {
+	p := PrefetchProgress{DisplayID: event.DisplayID, URL: event.URL, ReportedAt: event.Timestamp}
+	if event.Metrics != nil {
+		p.BytesDownloaded = event.Metrics.BytesDownloaded
+		p.BytesTotal = event.Metrics.BytesTotal
+	}
+	return p
+}
+
+// Complete reports whether the whole of a URL of known size was fetched
+func (p PrefetchProgress) Complete() bool {
+	return p.BytesTotal > 0 && p.BytesDownloaded >= p.BytesTotal
+}
+
+// Percent is how much of the URL was fetched, from 0 to 100. It is 0 while
+// the size is unknown.
+func (p PrefetchProgress) Percent() float64 {
+	if p.BytesTotal <= 0 {
+		return 0
+	}
+	if p.Complete() {
+		return 100
+	}
+	return 100 * float64(p.BytesDownloaded) / float64(p.BytesTotal)
+}
+
+// API converts a stored event to its API form
+func (e Event) API() v1alpha1.ContentEvent {
+	event := v1alpha1.ContentEvent{
+		ID:        e.ID,
+		DisplayID: e.DisplayID,
+		Type:      v1alpha1.ContentEventType(e.Type),
+		URL:       e.URL,
+		Timestamp: e.Timestamp,
+		Context:   e.Context,
+	}
+	if e.Error != nil {
+		event.Error = &v1alpha1.EventError{
+			Code:    e.Error.Code,
+			Message: e.Error.Message,
+			Details: e.Error.Details,
+		}
+	}
+	if m := e.Metrics; m != nil {
+		event.Metrics = &v1alpha1.EventMetrics{
+			LoadTime:        m.LoadTime,
+			RenderTime:      m.RenderTime,
+			InteractiveTime: m.InteractiveTime,
+			BytesDownloaded: m.BytesDownloaded,
+			BytesTotal:      m.BytesTotal,
+		}
+		if s := m.ResourceStats; s != nil {
+			event.Metrics.ResourceStats = &v1alpha1.ResourceStats{
+				ImageCount:  s.ImageCount,
+				ScriptCount: s.ScriptCount,
+				TotalBytes:  s.TotalBytes,
+			}
+		}
+	}
+	return event
+}
+
+// MaxEventQueryWindow is the longest period a single event query may cover
+const MaxEventQueryWindow = 31 * 24 * time.Hour
+
+// EventCursor is a position in the order events are read back in: by
+// timestamp, then by ID for events reported at the same instant
+type EventCursor struct {
+	Timestamp time.Time
+	ID        uuid.UUID
+}
+
+// Cursor returns the position of the event in read order
+func (e Event) Cursor() EventCursor {
+	return EventCursor{Timestamp: e.Timestamp, ID: e.ID}
+}
+
+// Before reports whether c comes before other in read order
+func (c EventCursor) Before(other EventCursor) bool {
+	if !c.Timestamp.Equal(other.Timestamp) {
+		return c.Timestamp.Before(other.Timestamp)
+	}
+	return bytes.Compare(c.ID[:], other.ID[:]) < 0
+}
+
+// EventQuery selects stored events by display, URL and type within a time
+// window. Empty fields match every event.
+type EventQuery struct {
+	DisplayID uuid.UUID
+	URL       string
+	Type      EventType
+	// Since is the earliest event timestamp included
+	Since time.Time
+	// Until is the timestamp events must be before
+	Until time.Time
+	// After, when set, skips events up to and including this position so a
+	// query can resume where an earlier one stopped
+	After *EventCursor
+	// Limit caps the number of events read; zero reads them all
+	Limit int
+}
+
+// Matches reports whether the query selects event, ignoring Limit
+func (q EventQuery) Matches(event Event) bool {
+	switch {
+	case q.DisplayID != uuid.Nil && event.DisplayID != q.DisplayID,
+		q.URL != "" && event.URL != q.URL,
+		q.Type != "" && event.Type != q.Type,
+		event.Timestamp.Before(q.Since),
+		!event.Timestamp.Before(q.Until):
+		return false
+	}
+	return q.After == nil || q.After.Before(event.Cursor())
+}
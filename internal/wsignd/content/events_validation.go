@@ -0,0 +1,74 @@
+package content
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// Valid reports whether t is a known event type
+func (t EventType) Valid() bool {
+	switch t {
+	case EventContentLoaded, EventContentError, EventContentVisible,
+		EventContentHidden, EventContentInteractive, EventContentPrefetchProgress:
+		return true
+	}
+	return false
+}
+
+// ValidateEventBatch checks that a batch and each of its events are complete
+// and that every event belongs to the display reporting the batch. All
+// problems are reported rather than just the first.
+func ValidateEventBatch(batch EventBatch) error {
+	verr := &werrors.ValidationError{}
+
+	if batch.DisplayID == uuid.Nil {
+		verr.Required("displayId")
+	}
+	if len(batch.Events) == 0 {
+		verr.Add("events", v1alpha1.FieldRequired, "at least one event is required")
+	}
+
+	for i, event := range batch.Events {
+		field := fmt.Sprintf("events[%d].", i)
+		if strings.TrimSpace(event.URL) == "" {
+			verr.Required(field + "url")
+		}
+		if !event.Type.Valid() {
+			verr.Invalid(field+"type", fmt.Sprintf("unknown event type %q", event.Type))
+		}
+		switch {
+		case event.DisplayID == uuid.Nil:
+			verr.Required(field + "displayId")
+		case event.DisplayID != batch.DisplayID:
+			verr.Invalid(field+"displayId", "does not match the batch display")
+		}
+		if event.Timestamp.IsZero() {
+			verr.Required(field + "timestamp")
+		}
+		if event.Type == EventContentPrefetchProgress {
+			validatePrefetchMetrics(verr, field+"metrics", event.Metrics)
+		}
+	}
+
+	return verr.Err()
+}
+
+// validatePrefetchMetrics checks the byte counts of a prefetch progress
+// event. A total of zero means the size is not known yet.
+func validatePrefetchMetrics(verr *werrors.ValidationError, field string, metrics *EventMetrics) {
+	switch {
+	case metrics == nil:
+		verr.Required(field)
+	case metrics.BytesDownloaded < 0:
+		verr.Invalid(field+".bytesDownloaded", "must not be negative")
+	case metrics.BytesTotal < 0:
+		verr.Invalid(field+".bytesTotal", "must not be negative")
+	case metrics.BytesTotal > 0 && metrics.BytesDownloaded > metrics.BytesTotal:
+		verr.Invalid(field+".bytesDownloaded", "must not exceed bytesTotal")
+	}
+}
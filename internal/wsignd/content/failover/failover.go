@@ -0,0 +1,170 @@
+// Package failover swaps the content assigned to a display for a fallback
+// source while the assigned source is unhealthy. It sits between the
+// display handler and the assignment service, so displays resolving their
+// content pick up a fallback without the assignment changing.
+package failover
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// Assignments resolves the assignment in effect for a display
+type Assignments interface {
+	ForDisplay(ctx context.Context, id uuid.UUID, location v1alpha1.DisplayLocation, properties map[string]string) (*v1alpha1.ContentAssignment, error)
+}
+
+// Sources looks up content sources by name
+type Sources interface {
+	GetContent(ctx context.Context, name string) (*v1alpha1.ContentSource, error)
+}
+
+// Resolver resolves a display's assignment and, when the assigned source
+// declares fallbacks and is unhealthy, points it at the first healthy
+// source in the chain instead
+type Resolver struct {
+	assignments Assignments
+	sources     Sources
+	monitor     content.HealthMonitor
+	logger      *slog.Logger
+}
+
+// New creates a Resolver judging sources with monitor
+func New(assignments Assignments, sources Sources, monitor content.HealthMonitor, logger *slog.Logger) *Resolver {
+	return &Resolver{
+		assignments: assignments,
+		sources:     sources,
+		monitor:     monitor,
+		logger:      logger,
+	}
+}
+
+// ForDisplay returns the assignment in effect for the display with id at
+// location with properties, or nil when none selects it. When the assigned source
+// is unhealthy the assignment returned is a copy pointing at the first
+// healthy fallback, tried in order with each fallback's own fallbacks
+// following it. Fallbacks that no longer exist are skipped. When no
+// fallback is healthy, or health cannot be judged, the assigned content is
+// kept.
+func (r *Resolver) ForDisplay(ctx context.Context, id uuid.UUID, location v1alpha1.DisplayLocation, properties map[string]string) (*v1alpha1.ContentAssignment, error) {
+	a, err := r.assignments.ForDisplay(ctx, id, location, properties)
+	if err != nil || a == nil || a.Source == "" {
+		return a, err
+	}
+
+	primary, err := r.sources.GetContent(ctx, a.Source)
+	if err != nil {
+		r.logger.Warn("failed to look up assigned content source, keeping assigned content",
+			"error", err,
+			"assignment", a.Name,
+			"source", a.Source,
+		)
+		return a, nil
+	}
+	if len(primary.Spec.Fallbacks) == 0 {
+		return a, nil
+	}
+
+	reason, ok := r.unhealthy(ctx, primary, a.ContentURL)
+	if !ok {
+		return a, nil
+	}
+	if reason == "" {
+		r.logger.Debug("assigned content source is healthy",
+			"assignment", a.Name,
+			"source", primary.Name,
+		)
+		return a, nil
+	}
+
+	visited := map[string]bool{primary.Name: true}
+	var skipped []string
+	var choose func(names []string) *v1alpha1.ContentSource
+	choose = func(names []string) *v1alpha1.ContentSource {
+		for _, name := range names {
+			if visited[name] {
+				continue
+			}
+			visited[name] = true
+			fallback, err := r.sources.GetContent(ctx, name)
+			if err != nil {
+				if werrors.IsNotFound(err) {
+					skipped = append(skipped, name+": not found")
+				} else {
+					skipped = append(skipped, name+": lookup failed")
+				}
+				continue
+			}
+			fallbackReason, ok := r.unhealthy(ctx, fallback, fallback.Spec.URL)
+			switch {
+			case !ok:
+				skipped = append(skipped, name+": health unknown")
+			case fallbackReason != "":
+				skipped = append(skipped, name+": "+fallbackReason)
+			default:
+				return fallback
+			}
+			if chosen := choose(fallback.Spec.Fallbacks); chosen != nil {
+				return chosen
+			}
+		}
+		return nil
+	}
+
+	chosen := choose(primary.Spec.Fallbacks)
+	if chosen == nil {
+		r.logger.Warn("assigned content source is unhealthy and no fallback is healthy, keeping assigned content",
+			"assignment", a.Name,
+			"source", primary.Name,
+			"reason", reason,
+			"skipped", skipped,
+		)
+		return a, nil
+	}
+
+	r.logger.Info("content source failed over",
+		"assignment", a.Name,
+		"source", primary.Name,
+		"reason", reason,
+		"chosen", chosen.Name,
+		"skipped", skipped,
+	)
+	failed := *a
+	failed.Source = chosen.Name
+	failed.ContentURL = chosen.Spec.URL
+	return &failed, nil
+}
+
+// unhealthy explains why the content at url from source should not be
+// shown, or returns an empty reason when it may be. A failed validation of
+// the source counts against it, as does content displays fail to load too
+// often. Content displays have not reported recently does not: fallbacks
+// are rarely shown, so few reports are expected of them. ok is false when
+// the health monitor could not be asked and nothing else is known.
+func (r *Resolver) unhealthy(ctx context.Context, source *v1alpha1.ContentSource, url string) (reason string, ok bool) {
+	var issues []string
+	if source.Status.Validation != nil && !source.Status.Validation.Passed {
+		issues = append(issues, "validation failed: "+source.Status.Validation.Reason)
+	}
+
+	health, err := r.monitor.CheckHealth(ctx, url)
+	if err != nil {
+		r.logger.Warn("failed to check content health",
+			"error", err,
+			"source", source.Name,
+			"url", url,
+		)
+		return strings.Join(issues, "; "), len(issues) > 0
+	}
+	if health.Failing {
+		issues = append(issues, content.ErrContentUnreliable.Error())
+	}
+	return strings.Join(issues, "; "), true
+}
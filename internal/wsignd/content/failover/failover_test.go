@@ -0,0 +1,160 @@
+package failover_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	"github.com/wrale/wrale-signage/internal/wsignd/content/failover"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// fixedAssignment resolves every display to one assignment
+type fixedAssignment struct {
+	assignment *v1alpha1.ContentAssignment
+}
+
+func (f fixedAssignment) ForDisplay(ctx context.Context, id uuid.UUID, location v1alpha1.DisplayLocation, properties map[string]string) (*v1alpha1.ContentAssignment, error) {
+	return f.assignment, nil
+}
+
+// sources serves content sources from a map
+type sources map[string]*v1alpha1.ContentSource
+
+func (s sources) GetContent(ctx context.Context, name string) (*v1alpha1.ContentSource, error) {
+	source, ok := s[name]
+	if !ok {
+		return nil, werrors.NewError("NOT_FOUND", "Content source not found: "+name, "test", werrors.ErrNotFound)
+	}
+	return source, nil
+}
+
+// monitor reports the URLs in failing as failing to load and every other
+// URL as not reported recently
+type monitor struct {
+	failing map[string]bool
+	err     error
+}
+
+func (m monitor) CheckHealth(ctx context.Context, url string) (*content.HealthStatus, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.failing[url] {
+		return &content.HealthStatus{URL: url, Failing: true, Issues: []string{content.ErrContentUnreliable.Error()}}, nil
+	}
+	return &content.HealthStatus{URL: url, Issues: []string{content.ErrContentStale.Error()}}, nil
+}
+
+func (m monitor) GetHealthHistory(ctx context.Context, url string) ([]content.HealthStatus, error) {
+	return nil, nil
+}
+
+func source(name string, fallbacks ...string) *v1alpha1.ContentSource {
+	return &v1alpha1.ContentSource{
+		ObjectMeta: v1alpha1.ObjectMeta{Name: name},
+		Spec:       v1alpha1.ContentSourceSpec{URL: "https://example.com/" + name, Fallbacks: fallbacks},
+		Status: v1alpha1.ContentSourceStatus{
+			IsHealthy:  true,
+			Validation: &v1alpha1.ContentValidationReport{Passed: true},
+		},
+	}
+}
+
+func invalid(s *v1alpha1.ContentSource) *v1alpha1.ContentSource {
+	s.Status.IsHealthy = false
+	s.Status.Validation = &v1alpha1.ContentValidationReport{Passed: false, Reason: "HTTP 503"}
+	return s
+}
+
+func TestForDisplay(t *testing.T) {
+	ctx := context.Background()
+	assigned := &v1alpha1.ContentAssignment{
+		ObjectMeta: v1alpha1.ObjectMeta{Name: "lobby-alerts"},
+		Source:     "alerts",
+		ContentURL: "https://example.com/alerts/lobby",
+	}
+
+	resolve := func(t *testing.T, all sources, health monitor) (*v1alpha1.ContentAssignment, string) {
+		t.Helper()
+		var logs bytes.Buffer
+		r := failover.New(fixedAssignment{assigned}, all, health, slog.New(slog.NewTextHandler(&logs, nil)))
+		a, err := r.ForDisplay(ctx, uuid.New(), v1alpha1.DisplayLocation{SiteID: "hq"}, nil)
+		require.NoError(t, err)
+		return a, logs.String()
+	}
+
+	t.Run("healthy primary is kept", func(t *testing.T) {
+		a, _ := resolve(t, sources{"alerts": source("alerts", "static"), "static": source("static")}, monitor{})
+		assert.Same(t, assigned, a, "content nobody reported recently is not failed over")
+	})
+
+	t.Run("primary failing on displays falls back", func(t *testing.T) {
+		all := sources{"alerts": source("alerts", "static"), "static": source("static")}
+		a, logs := resolve(t, all, monitor{failing: map[string]bool{assigned.ContentURL: true}})
+		assert.Equal(t, "static", a.Source)
+		assert.Equal(t, "https://example.com/static", a.ContentURL)
+		assert.Equal(t, "lobby-alerts", a.Name)
+		assert.Equal(t, "alerts", assigned.Source, "the assignment itself is not changed")
+		assert.Contains(t, logs, `msg="content source failed over"`)
+		assert.Contains(t, logs, "chosen=static")
+		assert.Contains(t, logs, `reason="content has high error rate"`)
+	})
+
+	t.Run("unhealthy fallbacks are skipped in order", func(t *testing.T) {
+		all := sources{
+			"alerts": invalid(source("alerts", "static", "map")),
+			"static": source("static"),
+			"map":    source("map"),
+		}
+		a, logs := resolve(t, all, monitor{failing: map[string]bool{"https://example.com/static": true}})
+		assert.Equal(t, "map", a.Source)
+		assert.Contains(t, logs, "validation failed: HTTP 503")
+		assert.Contains(t, logs, "static: content has high error rate")
+	})
+
+	t.Run("a fallback's own fallbacks come before the next one", func(t *testing.T) {
+		all := sources{
+			"alerts": invalid(source("alerts", "static", "map")),
+			"static": invalid(source("static", "banner", "alerts")),
+			"banner": source("banner"),
+			"map":    source("map"),
+		}
+		a, _ := resolve(t, all, monitor{})
+		assert.Equal(t, "banner", a.Source)
+	})
+
+	t.Run("missing fallbacks are skipped", func(t *testing.T) {
+		all := sources{"alerts": invalid(source("alerts", "gone", "map")), "map": source("map")}
+		a, logs := resolve(t, all, monitor{})
+		assert.Equal(t, "map", a.Source)
+		assert.Contains(t, logs, "gone: not found")
+	})
+
+	t.Run("no healthy fallback keeps the primary", func(t *testing.T) {
+		all := sources{"alerts": invalid(source("alerts", "static")), "static": invalid(source("static"))}
+		a, logs := resolve(t, all, monitor{})
+		assert.Same(t, assigned, a)
+		assert.Contains(t, logs, "no fallback is healthy")
+	})
+
+	t.Run("sources without fallbacks are not judged", func(t *testing.T) {
+		a, _ := resolve(t, sources{"alerts": invalid(source("alerts"))}, monitor{err: errors.New("unused")})
+		assert.Same(t, assigned, a)
+	})
+
+	t.Run("unknown health keeps the primary", func(t *testing.T) {
+		all := sources{"alerts": source("alerts", "static"), "static": source("static")}
+		a, logs := resolve(t, all, monitor{err: errors.New("metrics unavailable")})
+		assert.Same(t, assigned, a)
+		assert.Contains(t, logs, "failed to check content health")
+	})
+}
@@ -0,0 +1,87 @@
+package content_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	"github.com/wrale/wrale-signage/internal/wsignd/content/memory"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+func TestService_Fallbacks(t *testing.T) {
+	ctx := context.Background()
+	service := content.NewService(memory.NewRepository(), passingValidator{}, nil, nil, nil, nil)
+
+	create := func(name string, fallbacks ...string) (*v1alpha1.ContentSource, error) {
+		return service.CreateContent(ctx, &v1alpha1.ContentSource{
+			ObjectMeta: v1alpha1.ObjectMeta{Name: name},
+			Spec:       v1alpha1.ContentSourceSpec{URL: "https://example.com/" + name, Type: "notice", Fallbacks: fallbacks},
+		}, false)
+	}
+	setFallbacks := func(name string, fallbacks ...string) (*v1alpha1.ContentSourceUpdateResult, error) {
+		return service.UpdateContent(ctx, name, &v1alpha1.ContentSourceUpdate{Fallbacks: &fallbacks}, false)
+	}
+
+	_, err := create("static")
+	require.NoError(t, err)
+	_, err = create("map")
+	require.NoError(t, err)
+
+	created, err := create("alerts", "Static", "map")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"static", "map"}, created.Spec.Fallbacks, "names are folded and order kept")
+
+	got, err := service.GetContent(ctx, "alerts")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"static", "map"}, got.Spec.Fallbacks)
+
+	for name, tc := range map[string]struct {
+		source    string
+		fallbacks []string
+		message   string
+	}{
+		"missing source": {"alerts", []string{"static", "drill"}, `content source "drill" does not exist`},
+		"itself":         {"alerts", []string{"alerts"}, "cannot fall back to itself"},
+		"duplicate":      {"alerts", []string{"map", "MAP"}, "listed more than once"},
+		"cycle":          {"static", []string{"alerts"}, "cycle: static -> alerts -> static"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := setFallbacks(tc.source, tc.fallbacks...)
+			require.Error(t, err)
+			assert.True(t, werrors.IsInvalidInput(err), "%v", err)
+			assert.ErrorContains(t, err, tc.message)
+		})
+	}
+
+	t.Run("chains are followed to find cycles", func(t *testing.T) {
+		result, err := setFallbacks("static", "map")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"map"}, result.Spec.Fallbacks)
+
+		_, err = setFallbacks("map", "alerts")
+		assert.ErrorContains(t, err, "cycle: map -> alerts -> static -> map")
+	})
+
+	t.Run("an empty list clears them", func(t *testing.T) {
+		result, err := setFallbacks("alerts")
+		require.NoError(t, err)
+		assert.Empty(t, result.Spec.Fallbacks)
+	})
+
+	t.Run("other updates leave them alone", func(t *testing.T) {
+		url := "https://example.com/static-v2"
+		result, err := service.UpdateContent(ctx, "static", &v1alpha1.ContentSourceUpdate{URL: &url}, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"map"}, result.Spec.Fallbacks)
+	})
+
+	t.Run("create checks them too", func(t *testing.T) {
+		_, err := create("ticker", "nowhere")
+		assert.ErrorContains(t, err, `content source "nowhere" does not exist`)
+	})
+}
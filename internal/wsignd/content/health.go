@@ -0,0 +1,51 @@
+package content
+
+import (
+	"context"
+	"time"
+)
+
+// metricsMonitor derives content health from display-reported metrics
+type metricsMonitor struct {
+	metrics MetricsAggregator
+}
+
+// NewHealthMonitor creates a HealthMonitor that judges health from the
+// events displays report, using the same thresholds as ValidateContent.
+func NewHealthMonitor(metrics MetricsAggregator) HealthMonitor {
+	return &metricsMonitor{metrics: metrics}
+}
+
+func (m *metricsMonitor) CheckHealth(ctx context.Context, url string) (*HealthStatus, error) {
+	metrics, err := m.metrics.GetURLMetrics(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &HealthStatus{
+		URL:       url,
+		Healthy:   true,
+		LastCheck: time.Now().Unix(),
+	}
+
+	if time.Now().Unix()-metrics.LastSeen > 3600 {
+		status.Healthy = false
+		status.Issues = append(status.Issues, ErrContentStale.Error())
+	}
+	if metrics.LoadCount > 0 && float64(metrics.ErrorCount)/float64(metrics.LoadCount) > 0.1 {
+		status.Healthy = false
+		status.Failing = true
+		status.Issues = append(status.Issues, ErrContentUnreliable.Error())
+	}
+
+	return status, nil
+}
+
+// GetHealthHistory returns the current health only; history is not retained yet
+func (m *metricsMonitor) GetHealthHistory(ctx context.Context, url string) ([]HealthStatus, error) {
+	status, err := m.CheckHealth(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return []HealthStatus{*status}, nil
+}
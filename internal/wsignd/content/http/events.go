@@ -0,0 +1,279 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+)
+
+const (
+	// defaultEventPageSize and maxEventPageSize bound the events on one
+	// JSON page. Exports are bounded by the query window instead.
+	defaultEventPageSize = 100
+	maxEventPageSize     = 1000
+
+	// defaultEventWindow is how far back a query without since reaches
+	defaultEventWindow = 24 * time.Hour
+
+	// ndjsonContentType selects the streaming export
+	ndjsonContentType = "application/x-ndjson"
+
+	// ndjsonFlushInterval is how many exported events are written between
+	// flushes, so rows reach the client while the query runs
+	ndjsonFlushInterval = 100
+
+	// ndjsonWriteTimeout replaces the server's write timeout for exports,
+	// which may run far longer. Each flush pushes the deadline out again,
+	// so only an export that stalls is cut off.
+	ndjsonWriteTimeout = time.Minute
+)
+
+// eventPageToken is what a page token carries: where the previous page
+// ended and the window the first page settled on. Later pages reuse that
+// window, so a query relative to now does not drift while it is paged
+// through and events reported meanwhile do not shift the pages.
+type eventPageToken struct {
+	Since     time.Time `json:"since"`
+	Until     time.Time `json:"until"`
+	Timestamp time.Time `json:"timestamp"`
+	ID        uuid.UUID `json:"id"`
+}
+
+func encodePageToken(query content.EventQuery, last content.Event) string {
+	data, _ := json.Marshal(eventPageToken{
+		Since:     query.Since,
+		Until:     query.Until,
+		Timestamp: last.Timestamp,
+		ID:        last.ID,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodePageToken(s string) (*eventPageToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var token eventPageToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// QueryEvents returns stored content events, oldest first, for analysis.
+// The displayId, url and type query parameters narrow the events; since
+// and until bound them, as durations such as 24h or 7d before now or as
+// RFC 3339 times, defaulting to the last day. Results come in pages of
+// limit events linked by nextPageToken, or, when the client accepts
+// application/x-ndjson or asks for format=ndjson, as one event per line
+// streamed as the query runs. tz=<IANA name> gives timestamps in that zone
+// and tz=local in the zone of the display reporting each event.
+func (h *Handler) QueryEvents(w http.ResponseWriter, r *http.Request) {
+	ndjson, err := wantsNDJSON(r)
+	if err != nil {
+		httpapi.WriteError(w, err, http.StatusBadRequest)
+		return
+	}
+	query, err := parseEventQuery(r, ndjson)
+	if err != nil {
+		httpapi.WriteError(w, err, http.StatusBadRequest)
+		return
+	}
+	zones, err := h.eventZones(r)
+	if err != nil {
+		httpapi.WriteError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if ndjson {
+		h.exportEvents(w, r, query, zones)
+		return
+	}
+
+	// One more event than the page holds tells whether another page follows
+	pageSize := query.Limit
+	query.Limit++
+	list := v1alpha1.ContentEventList{
+		TypeMeta: v1alpha1.TypeMeta{Kind: "ContentEventList", APIVersion: "v1alpha1"},
+		Items:    make([]v1alpha1.ContentEvent, 0, pageSize),
+	}
+	var last content.Event
+	err = h.service.QueryEvents(r.Context(), query, func(e content.Event) error {
+		if len(list.Items) == pageSize {
+			list.NextPageToken = encodePageToken(query, last)
+			return errPageFull
+		}
+		list.Items = append(list.Items, zones.localize(e.API()))
+		last = e
+		return nil
+	})
+	if err != nil && !errors.Is(err, errPageFull) {
+		h.logEventQueryError(r, err)
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, list)
+}
+
+// errPageFull stops reading events once a page is complete
+var errPageFull = errors.New("page full")
+
+// exportEvents streams the events as newline-delimited JSON. The status is
+// only written with the first event, so an invalid query still gets an
+// error body. A failure after that cannot be reported in the body, so the
+// connection is aborted and the client sees a truncated stream rather than
+// a clean end.
+func (h *Handler) exportEvents(w http.ResponseWriter, r *http.Request, query content.EventQuery, zones *eventZones) {
+	rc := http.NewResponseController(w)
+	extendDeadline := func() {
+		_ = rc.SetWriteDeadline(time.Now().Add(ndjsonWriteTimeout))
+	}
+	extendDeadline()
+
+	enc := json.NewEncoder(w)
+	written := 0
+	start := func() {
+		w.Header().Set("Content-Type", ndjsonContentType)
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+	}
+
+	err := h.service.QueryEvents(r.Context(), query, func(e content.Event) error {
+		if written == 0 {
+			start()
+		}
+		if err := enc.Encode(zones.localize(e.API())); err != nil {
+			return err
+		}
+		written++
+		if written%ndjsonFlushInterval == 0 {
+			_ = rc.Flush()
+			extendDeadline()
+		}
+		return nil
+	})
+	switch {
+	case err == nil && written == 0:
+		start()
+	case err == nil:
+	case r.Context().Err() != nil:
+		// The client went away; the query was cancelled with its context
+		h.logger.Info("event export cancelled by client", "events", written)
+		return
+	case written == 0:
+		h.logEventQueryError(r, err)
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return
+	default:
+		h.logger.Error("event export failed", "error", err, "events", written)
+		panic(http.ErrAbortHandler)
+	}
+	_ = rc.Flush()
+}
+
+// logEventQueryError logs failures of the event query other than the
+// client's mistakes
+func (h *Handler) logEventQueryError(r *http.Request, err error) {
+	if werrors.IsInvalidInput(err) {
+		return
+	}
+	h.logger.Error("failed to query content events",
+		"error", err,
+		"query", r.URL.RawQuery,
+	)
+}
+
+// wantsNDJSON reports whether the request asks for the streaming export,
+// either with format=ndjson or by accepting application/x-ndjson
+func wantsNDJSON(r *http.Request) (bool, error) {
+	const op = "ContentHandler.wantsNDJSON"
+
+	switch r.URL.Query().Get("format") {
+	case "ndjson":
+		return true, nil
+	case "json":
+		return false, nil
+	case "":
+		return strings.Contains(r.Header.Get("Accept"), ndjsonContentType), nil
+	}
+	return false, werrors.NewError("INVALID_INPUT", "format must be json or ndjson", op, werrors.ErrInvalidInput)
+}
+
+// parseEventQuery reads an event query from the request's query
+// parameters. Pages default to defaultEventPageSize events; an export reads
+// every event unless limit is given.
+func parseEventQuery(r *http.Request, ndjson bool) (content.EventQuery, error) {
+	const op = "ContentHandler.parseEventQuery"
+	invalid := func(msg string) (content.EventQuery, error) {
+		return content.EventQuery{}, werrors.NewError("INVALID_INPUT", msg, op, werrors.ErrInvalidInput)
+	}
+
+	params := r.URL.Query()
+	query := content.EventQuery{
+		URL:  params.Get("url"),
+		Type: content.EventType(params.Get("type")),
+	}
+	if v := params.Get("displayId"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return invalid("displayId must be a UUID")
+		}
+		query.DisplayID = id
+	}
+	if query.Type != "" && !query.Type.Valid() {
+		return invalid("unknown event type: " + string(query.Type))
+	}
+
+	if !ndjson {
+		query.Limit = defaultEventPageSize
+	}
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || (!ndjson && limit > maxEventPageSize) {
+			return invalid("limit must be between 1 and " + strconv.Itoa(maxEventPageSize))
+		}
+		query.Limit = limit
+	}
+
+	if v := params.Get("pageToken"); v != "" {
+		token, err := decodePageToken(v)
+		if err != nil {
+			return invalid("invalid pageToken")
+		}
+		query.Since, query.Until = token.Since, token.Until
+		query.After = &content.EventCursor{Timestamp: token.Timestamp, ID: token.ID}
+		return query, nil
+	}
+
+	now := time.Now()
+	query.Until = now
+	if v := params.Get("until"); v != "" {
+		until, err := v1alpha1.ParseSince(v, now)
+		if err != nil {
+			return invalid("invalid until: " + err.Error())
+		}
+		query.Until = until
+	}
+	query.Since = query.Until.Add(-defaultEventWindow)
+	if v := params.Get("since"); v != "" {
+		since, err := v1alpha1.ParseSince(v, now)
+		if err != nil {
+			return invalid("invalid since: " + err.Error())
+		}
+		query.Since = since
+	}
+	return query, nil
+}
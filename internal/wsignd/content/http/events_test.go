@@ -0,0 +1,246 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	"github.com/wrale/wrale-signage/internal/wsignd/content/memory"
+)
+
+func TestQueryEvents(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewRepository()
+	handler := NewHandler(content.NewService(nil, nil, repo, nil, nil, nil), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	router := NewRouter(handler, nil, nil)
+
+	lobby, cafe := uuid.New(), uuid.New()
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	handler.SetDisplayTimezones(displayZones{lobby: newYork})
+	menu := "https://example.com/menu"
+	start := time.Now().Add(-time.Hour).UTC().Truncate(time.Millisecond)
+	report := func(displayID uuid.UUID, eventType content.EventType, url string, at time.Time) content.Event {
+		event := content.Event{ID: uuid.New(), DisplayID: displayID, Type: eventType, URL: url, Timestamp: at}
+		require.NoError(t, repo.ProcessEvents(ctx, content.EventBatch{DisplayID: displayID, Events: []content.Event{event}}))
+		return event
+	}
+	var reported []uuid.UUID
+	for i := 0; i < 5; i++ {
+		e := report(lobby, content.EventContentLoaded, menu, start.Add(time.Duration(i)*time.Minute))
+		reported = append(reported, e.ID)
+	}
+	// Another display fails to load other content alongside one of the loads
+	tied := report(cafe, content.EventContentError, "https://example.com/news", start.Add(2*time.Minute))
+
+	get := func(target string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	page := func(target string) v1alpha1.ContentEventList {
+		w := get(target, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var list v1alpha1.ContentEventList
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+		return list
+	}
+	ids := func(events []v1alpha1.ContentEvent) []uuid.UUID {
+		var ids []uuid.UUID
+		for _, e := range events {
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+
+	t.Run("pages are stable while events arrive", func(t *testing.T) {
+		first := page("/events?url=" + neturl.QueryEscape(menu) + "&limit=2")
+		require.Len(t, first.Items, 2)
+		require.NotEmpty(t, first.NextPageToken)
+		got := ids(first.Items)
+
+		// Neither a late report from before this page's end nor an event
+		// after the window the first page settled on joins the listing
+		report(lobby, content.EventContentLoaded, menu, start.Add(30*time.Second))
+		report(lobby, content.EventContentLoaded, menu, time.Now().Add(time.Minute))
+
+		for token := first.NextPageToken; token != ""; {
+			next := page("/events?limit=2&url=" + neturl.QueryEscape(menu) + "&pageToken=" + token)
+			got = append(got, ids(next.Items)...)
+			token = next.NextPageToken
+		}
+		assert.Equal(t, reported, got)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		assert.Equal(t, []uuid.UUID{tied.ID}, ids(page("/events?type=CONTENT_ERROR").Items))
+		assert.Equal(t, []uuid.UUID{tied.ID}, ids(page("/events?displayId="+cafe.String()).Items))
+		assert.Len(t, page("/events?since=30m").Items, 0)
+	})
+
+	t.Run("ndjson framing", func(t *testing.T) {
+		for name, w := range map[string]*httptest.ResponseRecorder{
+			"format":  get("/events?format=ndjson&until=1m", nil),
+			"accept":  get("/events?until=1m", http.Header{"Accept": {ndjsonContentType}}),
+			"limited": get("/events?format=ndjson&until=1m&limit=3", nil),
+		} {
+			require.Equal(t, http.StatusOK, w.Code, name)
+			assert.Equal(t, ndjsonContentType, w.Header().Get("Content-Type"), name)
+			body := w.Body.String()
+			require.True(t, strings.HasSuffix(body, "\n"), "%s: every event ends its line", name)
+
+			lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+			want := 7
+			if name == "limited" {
+				want = 3
+			}
+			require.Len(t, lines, want, name)
+			var previous time.Time
+			for _, line := range lines {
+				var event v1alpha1.ContentEvent
+				require.NoError(t, json.Unmarshal([]byte(line), &event), name)
+				assert.False(t, event.Timestamp.Before(previous), "%s: events are oldest first", name)
+				previous = event.Timestamp
+			}
+		}
+
+		w := get("/events?format=ndjson&since=5m", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, ndjsonContentType, w.Header().Get("Content-Type"))
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("time zones", func(t *testing.T) {
+		offset := func(e v1alpha1.ContentEvent) int {
+			_, offset := e.Timestamp.Zone()
+			return offset
+		}
+		_, newYorkOffset := start.In(newYork).Zone()
+
+		for _, e := range page("/events?tz=Asia/Tokyo").Items {
+			assert.Equal(t, 9*60*60, offset(e))
+		}
+
+		// Each display's events are in its own zone; displays whose zone
+		// cannot be found stay in UTC
+		local := page("/events?tz=local&since=" + neturl.QueryEscape(start.Format(time.RFC3339)) + "&until=" + neturl.QueryEscape(start.Add(3*time.Minute).Format(time.RFC3339)))
+		require.Contains(t, ids(local.Items), tied.ID)
+		for _, e := range local.Items {
+			want := newYorkOffset
+			if e.DisplayID == cafe {
+				want = 0
+			}
+			assert.Equal(t, want, offset(e), e.DisplayID)
+		}
+
+		w := get("/events?format=ndjson&tz=local&displayId="+lobby.String(), nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var event v1alpha1.ContentEvent
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&event))
+		assert.Equal(t, newYorkOffset, offset(event))
+	})
+
+	t.Run("invalid queries", func(t *testing.T) {
+		for _, target := range []string{
+			"/events?since=40d",
+			"/events?since=1h&until=2h",
+			"/events?limit=0",
+			"/events?limit=5000",
+			"/events?format=xml",
+			"/events?format=ndjson&since=32d",
+			"/events?pageToken=not-a-token",
+			"/events?type=CONTENT_EATEN",
+			"/events?displayId=lobby",
+			"/events?tz=Mars/Olympus",
+		} {
+			w := get(target, nil)
+			assert.Equal(t, http.StatusBadRequest, w.Code, target)
+			assert.Equal(t, "INVALID_INPUT", decodeAPIError(t, w).Code, target)
+		}
+	})
+}
+
+// displayZones finds the time zones of the displays it holds
+type displayZones map[uuid.UUID]*time.Location
+
+func (z displayZones) DisplayTimezone(ctx context.Context, displayID uuid.UUID) (*time.Location, error) {
+	if loc, ok := z[displayID]; ok {
+		return loc, nil
+	}
+	return nil, errors.New("display not found")
+}
+
+// endlessEvents is an event store whose queries never run out of events,
+// recording when a query's context is cancelled
+type endlessEvents struct {
+	cancelled chan struct{}
+}
+
+func (e *endlessEvents) ProcessEvents(ctx context.Context, batch content.EventBatch) error {
+	return nil
+}
+
+func (e *endlessEvents) ForEachEvent(ctx context.Context, query content.EventQuery, fn func(content.Event) error) error {
+	at := query.Since
+	for {
+		if err := ctx.Err(); err != nil {
+			close(e.cancelled)
+			return err
+		}
+		at = at.Add(time.Millisecond)
+		if err := fn(content.Event{ID: uuid.New(), Type: content.EventContentLoaded, URL: "https://example.com/menu", Timestamp: at}); err != nil {
+			<-ctx.Done()
+			close(e.cancelled)
+			return err
+		}
+	}
+}
+
+func (e *endlessEvents) PrefetchProgress(ctx context.Context, url string) ([]content.PrefetchProgress, error) {
+	return nil, nil
+}
+
+func TestExportEventsClientDisconnect(t *testing.T) {
+	store := &endlessEvents{cancelled: make(chan struct{})}
+	handler := NewHandler(content.NewService(nil, nil, store, nil, nil, nil), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	server := httptest.NewServer(NewRouter(handler, nil, nil))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/events?format=ndjson", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	line, err := bufio.NewReader(resp.Body).ReadBytes('\n')
+	require.NoError(t, err)
+	var event v1alpha1.ContentEvent
+	require.NoError(t, json.Unmarshal(line, &event))
+
+	cancel()
+	select {
+	case <-store.cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("export query was not cancelled after the client went away")
+	}
+}
@@ -4,14 +4,20 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"time"
 
-	"github.com/go-chi/chi/v5"
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
 	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
 )
 
 type Handler struct {
 	service content.Service
 	logger  *slog.Logger
+	zones   DisplayTimezones
+	preview *Previewer
 }
 
 func NewHandler(service content.Service, logger *slog.Logger) *Handler {
@@ -22,9 +28,29 @@ func NewHandler(service content.Service, logger *slog.Logger) *Handler {
 }
 
 func (h *Handler) ReportEvents(w http.ResponseWriter, r *http.Request) {
+	const op = "ContentHandler.ReportEvents"
+
 	var batch content.EventBatch
 	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+		httpapi.Error(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	// A display may only report events for itself
+	if displayID, ok := auth.DisplayIDFromContext(r.Context()); ok && displayID != batch.DisplayID {
+		h.logger.Warn("display reported events for another display",
+			"displayId", displayID,
+			"batchDisplayId", batch.DisplayID,
+		)
+		httpapi.WriteJSON(w, http.StatusForbidden, v1alpha1.Error{
+			Code:    "FORBIDDEN",
+			Message: "batch display does not match authenticated display",
+		})
+		return
+	}
+
+	if err := content.ValidateEventBatch(batch); err != nil {
+		httpapi.WriteError(w, werrors.NewError("INVALID_EVENTS", "event batch failed validation", op, err), http.StatusBadRequest)
 		return
 	}
 
@@ -33,63 +59,139 @@ func (h *Handler) ReportEvents(w http.ResponseWriter, r *http.Request) {
 			"error", err,
 			"displayId", batch.DisplayID,
 		)
-		http.Error(w, "failed to process events", http.StatusInternalServerError)
+		httpapi.Error(w, http.StatusInternalServerError, "failed to process events")
 		return
 	}
 
 	w.WriteHeader(http.StatusAccepted)
 }
 
+// GetURLHealth reports the health of the content URL given by the url
+// query parameter
 func (h *Handler) GetURLHealth(w http.ResponseWriter, r *http.Request) {
-	url := chi.URLParam(r, "url")
-	if url == "" {
-		http.Error(w, "missing url parameter", http.StatusBadRequest)
+	target, err := targetURL(r)
+	if err != nil {
+		httpapi.WriteError(w, err, http.StatusBadRequest)
+		return
+	}
+	health, ok := h.urlHealth(w, r, target)
+	if !ok {
 		return
 	}
 
-	health, err := h.service.GetURLHealth(r.Context(), url)
+	httpapi.WriteJSON(w, http.StatusOK, &v1alpha1.ContentHealth{
+		URL:       health.URL,
+		Healthy:   health.Healthy,
+		Issues:    health.Issues,
+		CheckedAt: time.Unix(health.LastCheck, 0).UTC(),
+	})
+}
+
+// GetURLHealthByPath serves the deprecated /health/{url} route, which can
+// only carry a URL encoded into a single path segment. It keeps the
+// response format of its time.
+func (h *Handler) GetURLHealthByPath(w http.ResponseWriter, r *http.Request) {
+	target, err := pathTargetURL(r)
 	if err != nil {
-		h.logger.Error("failed to get URL health",
-			"error", err,
-			"url", url,
-		)
-		http.Error(w, "health check failed", http.StatusInternalServerError)
+		httpapi.WriteError(w, err, http.StatusBadRequest)
 		return
 	}
+	deprecateForQuery(w, "health", target)
+	if health, ok := h.urlHealth(w, r, target); ok {
+		httpapi.WriteJSON(w, http.StatusOK, health)
+	}
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(health); err != nil {
-		h.logger.Error("failed to encode response",
-			"error", err,
-		)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
+// urlHealth looks up the health of target, writing an error response when
+// that fails
+func (h *Handler) urlHealth(w http.ResponseWriter, r *http.Request, target string) (*content.HealthStatus, bool) {
+	health, err := h.service.GetURLHealth(r.Context(), target)
+	if err != nil {
+		if !werrors.IsNotFound(err) {
+			h.logger.Error("failed to get URL health",
+				"error", err,
+				"url", target,
+			)
+		}
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return nil, false
 	}
+	return health, true
 }
 
+// GetURLMetrics reports the aggregated display events for the content URL
+// given by the url query parameter
 func (h *Handler) GetURLMetrics(w http.ResponseWriter, r *http.Request) {
-	url := chi.URLParam(r, "url")
-	if url == "" {
-		http.Error(w, "missing url parameter", http.StatusBadRequest)
+	target, err := targetURL(r)
+	if err != nil {
+		httpapi.WriteError(w, err, http.StatusBadRequest)
 		return
 	}
+	metrics, ok := h.urlMetrics(w, r, target)
+	if !ok {
+		return
+	}
+
+	resp := &v1alpha1.ContentMetrics{
+		URL:           metrics.URL,
+		LoadCount:     metrics.LoadCount,
+		ErrorCount:    metrics.ErrorCount,
+		AvgLoadTime:   metrics.AvgLoadTime,
+		AvgRenderTime: metrics.AvgRenderTime,
+		ErrorRates:    metrics.ErrorRates,
+	}
+	if metrics.LastSeen != 0 {
+		resp.LastSeen = time.Unix(metrics.LastSeen, 0).UTC()
+	}
+	httpapi.WriteJSON(w, http.StatusOK, resp)
+}
 
-	metrics, err := h.service.GetURLMetrics(r.Context(), url)
+// GetURLMetricsByPath serves the deprecated /metrics/{url} route
+func (h *Handler) GetURLMetricsByPath(w http.ResponseWriter, r *http.Request) {
+	target, err := pathTargetURL(r)
 	if err != nil {
-		h.logger.Error("failed to get URL metrics",
-			"error", err,
-			"url", url,
-		)
-		http.Error(w, "metrics retrieval failed", http.StatusInternalServerError)
+		httpapi.WriteError(w, err, http.StatusBadRequest)
 		return
 	}
+	deprecateForQuery(w, "metrics", target)
+	if metrics, ok := h.urlMetrics(w, r, target); ok {
+		httpapi.WriteJSON(w, http.StatusOK, metrics)
+	}
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(metrics); err != nil {
-		h.logger.Error("failed to encode response",
+// GetPrefetchStatus reports how far the displays assigned the content URL
+// given by the url query parameter have got downloading it
+func (h *Handler) GetPrefetchStatus(w http.ResponseWriter, r *http.Request) {
+	target, err := targetURL(r)
+	if err != nil {
+		httpapi.WriteError(w, err, http.StatusBadRequest)
+		return
+	}
+	status, err := h.service.PrefetchStatus(r.Context(), target)
+	if err != nil {
+		h.logger.Error("failed to get prefetch status",
 			"error", err,
+			"url", target,
 		)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
 		return
 	}
+	httpapi.WriteJSON(w, http.StatusOK, status)
+}
+
+// urlMetrics looks up the metrics of target, writing an error response
+// when that fails
+func (h *Handler) urlMetrics(w http.ResponseWriter, r *http.Request, target string) (*content.URLMetrics, bool) {
+	metrics, err := h.service.GetURLMetrics(r.Context(), target)
+	if err != nil {
+		if !werrors.IsNotFound(err) {
+			h.logger.Error("failed to get URL metrics",
+				"error", err,
+				"url", target,
+			)
+		}
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return nil, false
+	}
+	return metrics, true
 }
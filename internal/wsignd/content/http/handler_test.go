@@ -4,17 +4,24 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	neturl "net/url"
+	"strings"
 	"testing"
 	"time"
 
-	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
 	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/jsonpatch"
 )
 
 type mockService struct {
@@ -47,6 +54,108 @@ func (m *mockService) ValidateContent(ctx context.Context, url string) error {
 	return args.Error(0)
 }
 
+func (m *mockService) QueryEvents(ctx context.Context, query content.EventQuery, fn func(content.Event) error) error {
+	args := m.Called(ctx, query)
+	return args.Error(0)
+}
+
+func (m *mockService) PrefetchStatus(ctx context.Context, url string) (*v1alpha1.PrefetchStatus, error) {
+	args := m.Called(ctx, url)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*v1alpha1.PrefetchStatus), args.Error(1)
+}
+
+func (m *mockService) CreateContent(ctx context.Context, source *v1alpha1.ContentSource, strict bool) (*v1alpha1.ContentSource, error) {
+	args := m.Called(ctx, source, strict)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*v1alpha1.ContentSource), args.Error(1)
+}
+
+func (m *mockService) GetContent(ctx context.Context, name string) (*v1alpha1.ContentSource, error) {
+	args := m.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*v1alpha1.ContentSource), args.Error(1)
+}
+
+func (m *mockService) ListContent(ctx context.Context) ([]v1alpha1.ContentSource, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]v1alpha1.ContentSource), args.Error(1)
+}
+
+func (m *mockService) ListContentByType(ctx context.Context, contentType string) ([]v1alpha1.ContentSource, error) {
+	args := m.Called(ctx, contentType)
+	return args.Get(0).([]v1alpha1.ContentSource), args.Error(1)
+}
+
+func (m *mockService) ListContentByTags(ctx context.Context, tags []string) ([]v1alpha1.ContentSource, error) {
+	args := m.Called(ctx, tags)
+	return args.Get(0).([]v1alpha1.ContentSource), args.Error(1)
+}
+
+func (m *mockService) GetContentByNames(ctx context.Context, names []string) ([]v1alpha1.ContentSource, error) {
+	args := m.Called(ctx, names)
+	return args.Get(0).([]v1alpha1.ContentSource), args.Error(1)
+}
+
+func (m *mockService) ResolveSources(ctx context.Context, names []string) (map[string]*v1alpha1.ContentSource, error) {
+	args := m.Called(ctx, names)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]*v1alpha1.ContentSource), args.Error(1)
+}
+
+func (m *mockService) UpdateContent(ctx context.Context, name string, update *v1alpha1.ContentSourceUpdate, strict bool) (*v1alpha1.ContentSourceUpdateResult, error) {
+	args := m.Called(ctx, name, update, strict)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*v1alpha1.ContentSourceUpdateResult), args.Error(1)
+}
+
+func (m *mockService) PatchContent(ctx context.Context, name string, patch jsonpatch.Patch, strict bool) (*v1alpha1.ContentSourceUpdateResult, error) {
+	args := m.Called(ctx, name, patch, strict)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*v1alpha1.ContentSourceUpdateResult), args.Error(1)
+}
+
+func (m *mockService) DeleteContent(ctx context.Context, name string, force bool) (*v1alpha1.ContentSourceDeletion, error) {
+	args := m.Called(ctx, name, force)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*v1alpha1.ContentSourceDeletion), args.Error(1)
+}
+
+func (m *mockService) ValidateSource(ctx context.Context, name string) (*v1alpha1.ContentSource, error) {
+	args := m.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*v1alpha1.ContentSource), args.Error(1)
+}
+
+func (m *mockService) ProbeSource(ctx context.Context, name, path string) (*v1alpha1.ContentValidationReport, error) {
+	args := m.Called(ctx, name, path)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*v1alpha1.ContentValidationReport), args.Error(1)
+}
+
+func (m *mockService) RefreshValidations(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
 // matchEventBatch is a custom matcher for EventBatch that ignores monotonic clock values
 func matchEventBatch(expected content.EventBatch) interface{} {
 	return mock.MatchedBy(func(actual content.EventBatch) bool {
@@ -73,41 +182,136 @@ func matchEventBatch(expected content.EventBatch) interface{} {
 	})
 }
 
+// decodeAPIError decodes an error response, which must have the shape
+// every API handler uses
+func decodeAPIError(t *testing.T, w *httptest.ResponseRecorder) v1alpha1.Error {
+	t.Helper()
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var apiErr v1alpha1.Error
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&apiErr))
+	assert.NotEmpty(t, apiErr.Code)
+	assert.NotEmpty(t, apiErr.Message)
+	return apiErr
+}
+
 func TestReportEvents(t *testing.T) {
+	displayID := uuid.New()
+	validEvent := func() content.Event {
+		return content.Event{
+			ID:        uuid.New(),
+			DisplayID: displayID,
+			Type:      content.EventContentLoaded,
+			URL:       "https://example.com/content",
+			Timestamp: time.Now(),
+		}
+	}
+	withEvent := func(modify func(*content.Event)) *content.EventBatch {
+		event := validEvent()
+		modify(&event)
+		return &content.EventBatch{DisplayID: displayID, Events: []content.Event{event}}
+	}
+
 	tests := []struct {
-		name         string
-		batch        *content.EventBatch
-		serviceError error
-		expectedCode int
+		name           string
+		batch          *content.EventBatch
+		authDisplayID  uuid.UUID
+		serviceCalled  bool
+		serviceError   error
+		expectedCode   int
+		expectedError  string
+		expectedFields []string
 	}{
 		{
-			name: "successful_report",
-			batch: &content.EventBatch{
-				DisplayID: uuid.New(),
-				Events: []content.Event{
-					{
-						ID:        uuid.New(),
-						Type:      content.EventContentLoaded,
-						URL:       "https://example.com/content",
-						Timestamp: time.Now(),
-					},
-				},
-			},
-			serviceError: nil,
-			expectedCode: http.StatusAccepted,
+			name:          "successful_report",
+			batch:         withEvent(func(*content.Event) {}),
+			serviceCalled: true,
+			expectedCode:  http.StatusAccepted,
+		},
+		{
+			name:          "authenticated_display",
+			batch:         withEvent(func(*content.Event) {}),
+			authDisplayID: displayID,
+			serviceCalled: true,
+			expectedCode:  http.StatusAccepted,
+		},
+		{
+			name:          "service_failure",
+			batch:         withEvent(func(*content.Event) {}),
+			serviceCalled: true,
+			serviceError:  errors.New("database unavailable"),
+			expectedCode:  http.StatusInternalServerError,
+			expectedError: "INTERNAL",
+		},
+		{
+			name:          "invalid_request",
+			batch:         nil,
+			expectedCode:  http.StatusBadRequest,
+			expectedError: "INVALID_INPUT",
+		},
+		{
+			name:           "missing_url",
+			batch:          withEvent(func(e *content.Event) { e.URL = "" }),
+			expectedCode:   http.StatusBadRequest,
+			expectedFields: []string{"events[0].url"},
+		},
+		{
+			name:           "unknown_type",
+			batch:          withEvent(func(e *content.Event) { e.Type = "CONTENT_EXPLODED" }),
+			expectedCode:   http.StatusBadRequest,
+			expectedFields: []string{"events[0].type"},
+		},
+		{
+			name: "prefetch_progress",
+			batch: withEvent(func(e *content.Event) {
+				e.Type = content.EventContentPrefetchProgress
+				e.Metrics = &content.EventMetrics{BytesDownloaded: 512, BytesTotal: 2048}
+			}),
+			serviceCalled: true,
+			expectedCode:  http.StatusAccepted,
 		},
 		{
-			name:         "invalid_request",
-			batch:        nil,
-			serviceError: nil,
-			expectedCode: http.StatusBadRequest,
+			name:           "prefetch_progress_without_metrics",
+			batch:          withEvent(func(e *content.Event) { e.Type = content.EventContentPrefetchProgress }),
+			expectedCode:   http.StatusBadRequest,
+			expectedFields: []string{"events[0].metrics"},
+		},
+		{
+			name: "prefetch_progress_past_total",
+			batch: withEvent(func(e *content.Event) {
+				e.Type = content.EventContentPrefetchProgress
+				e.Metrics = &content.EventMetrics{BytesDownloaded: 4096, BytesTotal: 2048}
+			}),
+			expectedCode:   http.StatusBadRequest,
+			expectedFields: []string{"events[0].metrics.bytesDownloaded"},
+		},
+		{
+			name: "multiple_invalid_fields",
+			batch: withEvent(func(e *content.Event) {
+				e.DisplayID = uuid.Nil
+				e.Timestamp = time.Time{}
+			}),
+			expectedCode:   http.StatusBadRequest,
+			expectedFields: []string{"events[0].displayId", "events[0].timestamp"},
+		},
+		{
+			name:           "event_for_other_display",
+			batch:          withEvent(func(e *content.Event) { e.DisplayID = uuid.New() }),
+			expectedCode:   http.StatusBadRequest,
+			expectedFields: []string{"events[0].displayId"},
+		},
+		{
+			name:          "batch_for_other_display",
+			batch:         withEvent(func(*content.Event) {}),
+			authDisplayID: uuid.New(),
+			expectedCode:  http.StatusForbidden,
+			expectedError: "FORBIDDEN",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockSvc := new(mockService)
-			if tt.batch != nil {
+			if tt.serviceCalled {
 				mockSvc.On("ReportEvents", mock.Anything, matchEventBatch(*tt.batch)).Return(tt.serviceError)
 			}
 
@@ -119,71 +323,474 @@ func TestReportEvents(t *testing.T) {
 			}
 
 			req := httptest.NewRequest("POST", "/events", bytes.NewReader(body))
+			if tt.authDisplayID != uuid.Nil {
+				req = req.WithContext(auth.WithDisplayID(req.Context(), tt.authDisplayID))
+			}
 			w := httptest.NewRecorder()
 
 			handler.ReportEvents(w, req)
 			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedError != "" {
+				assert.Equal(t, tt.expectedError, decodeAPIError(t, w).Code)
+			}
+
+			if tt.expectedFields != nil {
+				var resp v1alpha1.Error
+				assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Equal(t, "INVALID_EVENTS", resp.Code)
+
+				var fields []string
+				for _, f := range resp.Errors {
+					fields = append(fields, f.Field)
+				}
+				assert.Equal(t, tt.expectedFields, fields)
+			}
 			mockSvc.AssertExpectations(t)
 		})
 	}
 }
 
 func TestGetURLHealth(t *testing.T) {
-	testURL := "https://example.com/content"
+	known := map[string]bool{
+		"https://example.com/content":               true,
+		"https://example.com/a/b?x=1&y=two%20words": true,
+		"https://例え.jp/メニュー?lang=日本語":               true,
+	}
+	notFound := werrors.NewError("NOT_FOUND", "No content source uses URL", "ContentService.GetURLHealth", werrors.ErrNotFound)
+
+	mockSvc := new(mockService)
+	for url := range known {
+		mockSvc.On("GetURLHealth", mock.Anything, url).
+			Return(&content.HealthStatus{URL: url, Healthy: true, LastCheck: time.Now().Unix()}, nil)
+	}
+	mockSvc.On("GetURLHealth", mock.Anything, mock.Anything).Return(nil, notFound)
+	router := NewRouter(NewHandler(mockSvc, slog.Default()), nil, nil)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for url := range known {
+		t.Run("query "+url, func(t *testing.T) {
+			w := get("/health?url=" + neturl.QueryEscape(url))
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			var health v1alpha1.ContentHealth
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&health))
+			assert.Equal(t, url, health.URL)
+			assert.True(t, health.Healthy)
+			assert.False(t, health.CheckedAt.IsZero())
+		})
+	}
+
 	tests := []struct {
 		name         string
-		url          string
-		mockHealth   *content.HealthStatus
-		mockError    error
+		target       string
 		expectedCode int
+	}{
+		{name: "missing_url", target: "/health", expectedCode: http.StatusBadRequest},
+		{name: "empty_url", target: "/health?url=", expectedCode: http.StatusBadRequest},
+		{name: "repeated_url", target: "/health?url=https%3A%2F%2Fexample.com%2Fcontent&url=https%3A%2F%2Fexample.com%2Fcontent", expectedCode: http.StatusBadRequest},
+		{name: "malformed_query", target: "/health?url=https%3A%2F%2Fexample.com%2F%zz", expectedCode: http.StatusBadRequest},
+		{name: "relative_url", target: "/health?url=%2Fcontent", expectedCode: http.StatusBadRequest},
+		{name: "other_scheme", target: "/health?url=file%3A%2F%2F%2Fetc%2Fpasswd", expectedCode: http.StatusBadRequest},
+		{name: "too_long", target: "/health?url=" + neturl.QueryEscape("https://example.com/"+strings.Repeat("a", maxTargetURLLength)), expectedCode: http.StatusBadRequest},
+		{name: "unknown_source", target: "/health?url=" + neturl.QueryEscape("http://169.254.169.254/latest/meta-data"), expectedCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(tt.target)
+			assert.Equal(t, tt.expectedCode, w.Code)
+			decodeAPIError(t, w)
+		})
+	}
+
+	t.Run("deprecated path parameter", func(t *testing.T) {
+		w := get("/health/" + neturl.PathEscape("https://example.com/content"))
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "true", w.Header().Get("Deprecation"))
+		assert.Contains(t, w.Header().Get("Link"), "/api/v1alpha1/content/health?url=https%3A%2F%2Fexample.com%2Fcontent")
+		var health content.HealthStatus
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&health))
+		assert.Equal(t, "https://example.com/content", health.URL)
+
+		// Unencoded URLs span several segments and never reach the handler
+		assert.Equal(t, http.StatusNotFound, get("/health/https://example.com/content").Code)
+	})
+}
+
+func TestGetURLMetrics(t *testing.T) {
+	url := "https://example.com/a/b?x=1"
+	mockSvc := new(mockService)
+	mockSvc.On("GetURLMetrics", mock.Anything, url).Return(&content.URLMetrics{
+		URL:         url,
+		LastSeen:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC).Unix(),
+		LoadCount:   10,
+		ErrorCount:  1,
+		AvgLoadTime: 250,
+		ErrorRates:  map[string]float64{"LOAD_FAILED": 0.1},
+	}, nil)
+	router := NewRouter(NewHandler(mockSvc, slog.Default()), nil, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics?url="+neturl.QueryEscape(url), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var metrics v1alpha1.ContentMetrics
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&metrics))
+	assert.Equal(t, url, metrics.URL)
+	assert.Equal(t, int64(10), metrics.LoadCount)
+	assert.Equal(t, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), metrics.LastSeen)
+	assert.Equal(t, 0.1, metrics.ErrorRates["LOAD_FAILED"])
+	mockSvc.AssertExpectations(t)
+}
+
+func TestGetPrefetchStatus(t *testing.T) {
+	url := "https://example.com/promo.mp4"
+	displayID := uuid.New()
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mockSvc := new(mockService)
+	mockSvc.On("PrefetchStatus", mock.Anything, url).Return(&v1alpha1.PrefetchStatus{
+		URL:      url,
+		Targeted: 1,
+		Displays: []v1alpha1.DisplayPrefetch{{DisplayID: displayID, BytesDownloaded: 10, BytesTotal: 40, Percent: 25, ReportedAt: &at}},
+	}, nil)
+	router := NewRouter(NewHandler(mockSvc, slog.Default()), nil, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/prefetch-status?url="+neturl.QueryEscape(url), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var status v1alpha1.PrefetchStatus
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&status))
+	assert.Equal(t, 1, status.Targeted)
+	require.Len(t, status.Displays, 1)
+	assert.Equal(t, 25.0, status.Displays[0].Percent)
+
+	req = httptest.NewRequest(http.MethodGet, "/prefetch-status", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestCreateContent(t *testing.T) {
+	source := &v1alpha1.ContentSource{
+		ObjectMeta: v1alpha1.ObjectMeta{Name: "welcome"},
+		Spec: v1alpha1.ContentSourceSpec{
+			URL:  "https://example.com/welcome",
+			Type: "static-page",
+		},
+	}
+
+	tests := []struct {
+		name          string
+		query         string
+		strict        bool
+		mockSource    *v1alpha1.ContentSource
+		mockError     error
+		expectedCode  int
+		expectedError string
 	}{
 		{
-			name: "healthy_url",
-			url:  testURL,
-			mockHealth: &content.HealthStatus{
-				URL:       testURL,
-				Healthy:   true,
-				LastCheck: time.Now().Unix(),
-			},
-			mockError:    nil,
-			expectedCode: http.StatusOK,
+			name:         "lenient_create",
+			query:        "",
+			strict:       false,
+			mockSource:   source,
+			expectedCode: http.StatusCreated,
 		},
 		{
-			name:         "missing_url",
-			url:          "",
-			mockHealth:   nil,
-			mockError:    nil,
-			expectedCode: http.StatusBadRequest,
+			name:   "strict_validation_failure",
+			query:  "?strict=true",
+			strict: true,
+			mockError: werrors.NewError("VALIDATION_FAILED", "content validation failed: unexpected status 404",
+				"ContentService.CreateContent", werrors.ErrInvalidInput),
+			expectedCode:  http.StatusUnprocessableEntity,
+			expectedError: "VALIDATION_FAILED",
+		},
+		{
+			name:   "duplicate_name",
+			query:  "",
+			strict: false,
+			mockError: werrors.NewError("ALREADY_EXISTS", "content source already exists: welcome",
+				"ContentService.CreateContent", werrors.ErrConflict),
+			expectedCode:  http.StatusConflict,
+			expectedError: "ALREADY_EXISTS",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockSvc := new(mockService)
-			if tt.mockHealth != nil {
-				mockSvc.On("GetURLHealth", mock.Anything, tt.url).Return(tt.mockHealth, tt.mockError)
-			}
+			mockSvc.On("CreateContent", mock.Anything, mock.AnythingOfType("*v1alpha1.ContentSource"), tt.strict).
+				Return(tt.mockSource, tt.mockError)
 
 			handler := NewHandler(mockSvc, slog.Default())
 
-			req := httptest.NewRequest("GET", "/health/"+tt.url, nil)
+			body, _ := json.Marshal(source)
+			req := httptest.NewRequest("POST", "/"+tt.query, bytes.NewReader(body))
 			w := httptest.NewRecorder()
 
-			// Add URL parameter to context using chi
-			rctx := chi.NewRouteContext()
-			rctx.URLParams.Add("url", tt.url)
-			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			handler.CreateContent(w, req)
+			assert.Equal(t, tt.expectedCode, w.Code)
+			mockSvc.AssertExpectations(t)
+
+			if tt.mockError != nil {
+				assert.Equal(t, tt.expectedError, decodeAPIError(t, w).Code)
+			}
+		})
+	}
+}
+
+func TestCreateContentConflictReturnsExisting(t *testing.T) {
+	existing := &v1alpha1.ContentSource{
+		TypeMeta:   v1alpha1.TypeMeta{Kind: "ContentSource", APIVersion: "v1alpha1"},
+		ObjectMeta: v1alpha1.ObjectMeta{Name: "menus"},
+		Spec:       v1alpha1.ContentSourceSpec{URL: "https://menu.example.com", Type: "menu"},
+		Status:     v1alpha1.ContentSourceStatus{Version: 3},
+	}
+	mockSvc := new(mockService)
+	mockSvc.On("CreateContent", mock.Anything, mock.AnythingOfType("*v1alpha1.ContentSource"), false).
+		Return(nil, werrors.NewError("ALREADY_EXISTS", "content source already exists: menus",
+			"ContentService.CreateContent", &content.SourceExistsError{Existing: existing}))
+
+	handler := NewHandler(mockSvc, slog.Default())
+	body, _ := json.Marshal(&v1alpha1.ContentSource{
+		ObjectMeta: v1alpha1.ObjectMeta{Name: "Menus"},
+		Spec:       v1alpha1.ContentSourceSpec{URL: "https://other.example.com", Type: "menu"},
+	})
+	w := httptest.NewRecorder()
+	handler.CreateContent(w, httptest.NewRequest("POST", "/", bytes.NewReader(body)))
+
+	require.Equal(t, http.StatusConflict, w.Code)
+	var apiErr struct {
+		Code    string                 `json:"code"`
+		Message string                 `json:"message"`
+		Details v1alpha1.ContentSource `json:"details"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&apiErr))
+	assert.Equal(t, "ALREADY_EXISTS", apiErr.Code)
+	assert.Contains(t, apiErr.Message, `"menus"`)
+	assert.Equal(t, *existing, apiErr.Details)
+}
+
+func TestUpdateContentStaleVersion(t *testing.T) {
+	mockSvc := new(mockService)
+	mockSvc.On("UpdateContent", mock.Anything, "", mock.MatchedBy(func(u *v1alpha1.ContentSourceUpdate) bool {
+		return u.Version == 2
+	}), false).Return(nil, werrors.NewError("VERSION_MISMATCH", "Content source welcome is at version 3, not 2",
+		"ContentService.UpdateContent", werrors.ErrVersionMismatch))
+
+	handler := NewHandler(mockSvc, slog.Default())
+	req := httptest.NewRequest("PATCH", "/", strings.NewReader(`{"url":"https://example.com/v2","version":2}`))
+	w := httptest.NewRecorder()
+
+	handler.UpdateContent(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	apiErr := decodeAPIError(t, w)
+	assert.Equal(t, "VERSION_MISMATCH", apiErr.Code)
+	assert.Equal(t, "Content source welcome is at version 3, not 2", apiErr.Message)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestPatchContent(t *testing.T) {
+	body := `[{"op":"test","path":"/status/version","value":2},{"op":"add","path":"/spec/allowedPaths/-","value":"/lunch"}]`
+	patch := jsonpatch.Patch{
+		{Op: "test", Path: "/status/version", Value: json.RawMessage(`2`)},
+		{Op: "add", Path: "/spec/allowedPaths/-", Value: json.RawMessage(`"/lunch"`)},
+	}
+	request := func() *http.Request {
+		req := httptest.NewRequest("PATCH", "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json-patch+json; charset=utf-8")
+		return req
+	}
+
+	t.Run("patches are applied", func(t *testing.T) {
+		patched := &v1alpha1.ContentSourceUpdateResult{ContentSource: v1alpha1.ContentSource{
+			ObjectMeta: v1alpha1.ObjectMeta{Name: "menus"},
+			Spec:       v1alpha1.ContentSourceSpec{AllowedPaths: []string{"/lunch"}},
+		}}
+		mockSvc := new(mockService)
+		mockSvc.On("PatchContent", mock.Anything, "", patch, false).Return(patched, nil)
+
+		w := httptest.NewRecorder()
+		NewHandler(mockSvc, slog.Default()).UpdateContent(w, request())
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("failing tests conflict", func(t *testing.T) {
+		mockSvc := new(mockService)
+		mockSvc.On("PatchContent", mock.Anything, "", patch, false).Return(nil, werrors.NewError("PATCH_TEST_FAILED",
+			"operation 0 (test /status/version): test operation failed", "ContentService.PatchContent", werrors.ErrConflict))
+
+		w := httptest.NewRecorder()
+		NewHandler(mockSvc, slog.Default()).UpdateContent(w, request())
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "PATCH_TEST_FAILED", decodeAPIError(t, w).Code)
+	})
+
+	t.Run("malformed patches are rejected", func(t *testing.T) {
+		req := httptest.NewRequest("PATCH", "/", strings.NewReader(`{"url":"https://example.com/v2"}`))
+		req.Header.Set("Content-Type", "application/json-patch+json")
+		mockSvc := new(mockService)
+
+		w := httptest.NewRecorder()
+		NewHandler(mockSvc, slog.Default()).UpdateContent(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockSvc.AssertNotCalled(t, "PatchContent", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestDeleteContentInUse(t *testing.T) {
+	refs := []v1alpha1.ResourceReference{
+		{Kind: "ContentAssignment", Name: "cafeteria"},
+		{Kind: "ContentAssignment", Name: "lobby"},
+	}
+
+	t.Run("refused while referenced", func(t *testing.T) {
+		mockSvc := new(mockService)
+		mockSvc.On("DeleteContent", mock.Anything, "", false).Return(nil,
+			werrors.NewError("SOURCE_IN_USE", "content source in use", "ContentService.DeleteContent",
+				&content.SourceInUseError{Name: "menus", References: refs}))
+
+		handler := NewHandler(mockSvc, slog.Default())
+		w := httptest.NewRecorder()
+		handler.DeleteContent(w, httptest.NewRequest("DELETE", "/", nil))
+
+		require.Equal(t, http.StatusConflict, w.Code)
+		var apiErr struct {
+			Code    string                       `json:"code"`
+			Message string                       `json:"message"`
+			Details []v1alpha1.ResourceReference `json:"details"`
+		}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&apiErr))
+		assert.Equal(t, "SOURCE_IN_USE", apiErr.Code)
+		assert.Contains(t, apiErr.Message, "ContentAssignment cafeteria")
+		assert.Equal(t, refs, apiErr.Details)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("forced", func(t *testing.T) {
+		deletion := &v1alpha1.ContentSourceDeletion{
+			TypeMeta: v1alpha1.TypeMeta{Kind: "ContentSourceDeletion", APIVersion: "v1alpha1"},
+			Name:     "menus",
+			Removed:  refs,
+		}
+		mockSvc := new(mockService)
+		mockSvc.On("DeleteContent", mock.Anything, "", true).Return(deletion, nil)
+
+		handler := NewHandler(mockSvc, slog.Default())
+		w := httptest.NewRecorder()
+		handler.DeleteContent(w, httptest.NewRequest("DELETE", "/?force=true", nil))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var got v1alpha1.ContentSourceDeletion
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+		assert.Equal(t, *deletion, got)
+		mockSvc.AssertExpectations(t)
+	})
+}
+
+func TestListContent(t *testing.T) {
+	sources := []v1alpha1.ContentSource{
+		{ObjectMeta: v1alpha1.ObjectMeta{Name: "menu"}, Spec: v1alpha1.ContentSourceSpec{Type: "static-page"}},
+		{ObjectMeta: v1alpha1.ObjectMeta{Name: "welcome"}, Spec: v1alpha1.ContentSourceSpec{Type: "static-page"}},
+	}
+
+	tests := []struct {
+		name          string
+		query         string
+		setupMock     func(m *mockService)
+		expectedCode  int
+		expectedError string
+		expectedLen   int
+	}{
+		{
+			name:  "unfiltered",
+			query: "",
+			setupMock: func(m *mockService) {
+				m.On("ListContent", mock.Anything).Return(sources, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedLen:  2,
+		},
+		{
+			name:  "by_type",
+			query: "?type=static-page",
+			setupMock: func(m *mockService) {
+				m.On("ListContentByType", mock.Anything, "static-page").Return(sources, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedLen:  2,
+		},
+		{
+			name:  "by_names",
+			query: "?name=welcome&name=missing",
+			setupMock: func(m *mockService) {
+				m.On("GetContentByNames", mock.Anything, []string{"welcome", "missing"}).Return(sources[1:], nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedLen:  1,
+		},
+		{
+			name:  "by_tags",
+			query: "?tag=emergency&tag=lobby",
+			setupMock: func(m *mockService) {
+				m.On("ListContentByTags", mock.Anything, []string{"emergency", "lobby"}).Return(sources[:1], nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedLen:  1,
+		},
+		{
+			name:          "type_and_names",
+			query:         "?type=static-page&name=welcome",
+			setupMock:     func(m *mockService) {},
+			expectedCode:  http.StatusBadRequest,
+			expectedError: "INVALID_INPUT",
+		},
+		{
+			name:          "names_and_tags",
+			query:         "?name=welcome&tag=emergency",
+			setupMock:     func(m *mockService) {},
+			expectedCode:  http.StatusBadRequest,
+			expectedError: "INVALID_INPUT",
+		},
+		{
+			name:  "service_error",
+			query: "?type=static-page",
+			setupMock: func(m *mockService) {
+				m.On("ListContentByType", mock.Anything, "static-page").
+					Return([]v1alpha1.ContentSource(nil), errors.New("database unavailable"))
+			},
+			expectedCode:  http.StatusInternalServerError,
+			expectedError: "INTERNAL",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(mockService)
+			tt.setupMock(mockSvc)
+
+			handler := NewHandler(mockSvc, slog.Default())
+			req := httptest.NewRequest("GET", "/"+tt.query, nil)
+			w := httptest.NewRecorder()
 
-			handler.GetURLHealth(w, req)
+			handler.ListContent(w, req)
 			assert.Equal(t, tt.expectedCode, w.Code)
 			mockSvc.AssertExpectations(t)
 
-			if tt.mockHealth != nil && tt.expectedCode == http.StatusOK {
-				var response content.HealthStatus
-				err := json.NewDecoder(w.Body).Decode(&response)
-				assert.NoError(t, err)
-				assert.Equal(t, tt.mockHealth.URL, response.URL)
-				assert.Equal(t, tt.mockHealth.Healthy, response.Healthy)
+			if tt.expectedCode == http.StatusOK {
+				var list v1alpha1.ContentSourceList
+				assert.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+				assert.Len(t, list.Items, tt.expectedLen)
+			} else {
+				assert.Equal(t, tt.expectedError, decodeAPIError(t, w).Code)
 			}
 		})
 	}
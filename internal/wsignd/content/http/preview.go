@@ -0,0 +1,181 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+// PreviewDisplays finds the registered display a preview is made as. The
+// display service satisfies it.
+type PreviewDisplays interface {
+	GetByName(ctx context.Context, name string) (*display.Display, error)
+}
+
+// PreviewResolver explains which assignment decides a display's content
+// at a given time. The assignment service satisfies it.
+type PreviewResolver interface {
+	ExplainForDisplay(ctx context.Context, id uuid.UUID, location v1alpha1.DisplayLocation, properties map[string]string, at time.Time) ([]v1alpha1.ResolutionStep, error)
+}
+
+// Previewer holds what content previews need beyond the content service
+type Previewer struct {
+	displays PreviewDisplays
+	resolver PreviewResolver
+	limiter  ratelimit.Service
+}
+
+// NewPreviewer creates a Previewer. Probes are counted against limiter per
+// content source, so that however many operators preview a source its
+// origin is only fetched as often as the content_probe limit allows.
+func NewPreviewer(displays PreviewDisplays, resolver PreviewResolver, limiter ratelimit.Service) *Previewer {
+	return &Previewer{displays: displays, resolver: resolver, limiter: limiter}
+}
+
+// SetPreviewer enables content previews. Without a previewer they are
+// refused. It must be called before the handler serves requests.
+func (h *Handler) SetPreviewer(p *Previewer) {
+	h.preview = p
+}
+
+// PreviewContent shows what a display would be sent for a content source:
+// the URL once the requested path has passed the source's allowed paths,
+// how the display's assignments resolve at the requested time and, when
+// asked, what fetching the URL observes. Only URLs beneath the source are
+// ever fetched.
+func (h *Handler) PreviewContent(w http.ResponseWriter, r *http.Request) {
+	const op = "ContentHandler.PreviewContent"
+
+	if h.preview == nil {
+		httpapi.Error(w, http.StatusNotImplemented, "content previews are not enabled")
+		return
+	}
+
+	var req v1alpha1.ContentPreviewRequest
+	if err := httpapi.DecodeJSON(r, &req); err != nil {
+		httpapi.WriteError(w, err, http.StatusBadRequest)
+		return
+	}
+	if req.Display != "" && (req.Location != nil || len(req.Properties) > 0) {
+		verr := &werrors.ValidationError{}
+		verr.Invalid("display", "a registered display cannot be given a location or properties")
+		httpapi.WriteError(w, werrors.NewError("INVALID_INPUT", verr.Error(), op, verr), http.StatusBadRequest)
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	source, err := h.service.GetContent(r.Context(), name)
+	if err != nil {
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return
+	}
+	target, err := content.ResolveURL(source, req.Path)
+	if err != nil {
+		httpapi.WriteError(w, werrors.NewError("INVALID_PATH", err.Error(), op, err), http.StatusBadRequest)
+		return
+	}
+
+	at := time.Now()
+	if req.At != nil {
+		at = *req.At
+	}
+	preview := &v1alpha1.ContentPreview{
+		TypeMeta:      v1alpha1.TypeMeta{Kind: "ContentPreview", APIVersion: "v1alpha1"},
+		Source:        source.Name,
+		URL:           target,
+		At:            at.UTC(),
+		SourceHealthy: source.Status.IsHealthy,
+	}
+
+	id, d, err := h.preview.display(r.Context(), req)
+	if err != nil {
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if d != nil {
+		preview.Display = d
+		steps, err := h.preview.resolver.ExplainForDisplay(r.Context(), id, d.Location, d.Properties, at)
+		if err != nil {
+			h.logger.Error("failed to resolve content preview",
+				"error", err,
+				"source", source.Name,
+			)
+			httpapi.WriteError(w, err, http.StatusInternalServerError)
+			return
+		}
+		preview.Resolution = steps
+		for _, step := range steps {
+			if step.Outcome == v1alpha1.ResolutionSelected {
+				preview.Assigned = step.ContentURL == target
+			}
+		}
+	}
+
+	if req.Probe {
+		status, err := h.preview.limiter.Allow(r.Context(), ratelimit.LimitKey{Type: ratelimit.LimitTypeContentProbe, Key: source.Name})
+		if err != nil {
+			retryAfter := int(math.Ceil(status.RetryAfter.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			w.Header().Set("Retry-After", fmt.Sprint(retryAfter))
+			httpapi.Error(w, http.StatusTooManyRequests,
+				fmt.Sprintf("content source %s was probed too often; retry in %ds", source.Name, retryAfter))
+			return
+		}
+		report, err := h.service.ProbeSource(r.Context(), source.Name, req.Path)
+		if err != nil {
+			httpapi.WriteError(w, err, http.StatusInternalServerError)
+			return
+		}
+		h.logger.Info("probed content source",
+			"source", source.Name,
+			"url", target,
+			"status", report.HTTPStatus,
+			"passed", report.Passed,
+		)
+		preview.Probe = report
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, preview)
+}
+
+// display returns the display req previews as and its ID, which is nil for
+// a hypothetical display, or no display when req describes none
+func (p *Previewer) display(ctx context.Context, req v1alpha1.ContentPreviewRequest) (uuid.UUID, *v1alpha1.ContentPreviewDisplay, error) {
+	switch {
+	case req.Display != "":
+		d, err := p.displays.GetByName(ctx, req.Display)
+		if err != nil {
+			return uuid.Nil, nil, err
+		}
+		return d.ID, &v1alpha1.ContentPreviewDisplay{
+			Name: d.Name,
+			Location: v1alpha1.DisplayLocation{
+				SiteID:   d.Location.SiteID,
+				Zone:     d.Location.Zone,
+				Position: d.Location.Position,
+			},
+			Properties: d.Properties,
+		}, nil
+	case req.Location != nil || len(req.Properties) > 0:
+		d := &v1alpha1.ContentPreviewDisplay{Properties: req.Properties}
+		if req.Location != nil {
+			d.Location = *req.Location
+		}
+		return uuid.Nil, d, nil
+	}
+	return uuid.Nil, nil, nil
+}
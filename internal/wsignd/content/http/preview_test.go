@@ -0,0 +1,210 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/assignment"
+	assignmentmemory "github.com/wrale/wrale-signage/internal/wsignd/assignment/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	contentmemory "github.com/wrale/wrale-signage/internal/wsignd/content/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+// stubDisplays finds displays by name
+type stubDisplays map[string]*display.Display
+
+func (s stubDisplays) GetByName(ctx context.Context, name string) (*display.Display, error) {
+	if d, ok := s[name]; ok {
+		return d, nil
+	}
+	return nil, werrors.NewError("NOT_FOUND", "display not found: "+name, "stubDisplays.GetByName", werrors.ErrNotFound)
+}
+
+func TestPreviewContent(t *testing.T) {
+	ctx := context.Background()
+
+	// The origin records the paths it is asked for
+	var mu sync.Mutex
+	var fetched []string
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetched = append(fetched, r.URL.Path)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<h1>Specials</h1>")
+	}))
+	defer origin.Close()
+	originFetches := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, fetched...)
+	}
+
+	contentService := content.NewService(contentmemory.NewRepository(), content.NewHTTPValidator(time.Second), nil, nil, nil, nil)
+	_, err := contentService.CreateContent(ctx, &v1alpha1.ContentSource{
+		ObjectMeta: v1alpha1.ObjectMeta{Name: "menu"},
+		Spec:       v1alpha1.ContentSourceSpec{URL: origin.URL, Type: "menu", AllowedPaths: []string{"/specials"}},
+	}, false)
+	require.NoError(t, err)
+
+	// Specials are shown in the cafeteria over lunch in New York, and the
+	// regular menu at other times
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	assignments := assignment.NewService(assignmentmemory.NewRepository())
+	for _, a := range []v1alpha1.ContentAssignment{
+		{ObjectMeta: v1alpha1.ObjectMeta{Name: "menu"}, ContentURL: origin.URL},
+		{
+			ObjectMeta: v1alpha1.ObjectMeta{Name: "lunch-specials"},
+			ContentURL: origin.URL + "/specials",
+			Schedule: &v1alpha1.RecurringSchedule{
+				Timezone: "America/New_York",
+				Windows:  []v1alpha1.ScheduleWindow{{TimeOfDay: v1alpha1.TimeRange{Start: "11:00", End: "14:00"}}},
+			},
+		},
+	} {
+		a.DisplaySelector = v1alpha1.DisplaySelector{SiteID: "hq", Zone: "cafeteria"}
+		_, err := assignments.Create(ctx, &a)
+		require.NoError(t, err)
+	}
+	displays := stubDisplays{"cafe-1": {
+		ID:       uuid.New(),
+		Name:     "cafe-1",
+		Location: display.Location{SiteID: "hq", Zone: "cafeteria"},
+	}}
+
+	handler := NewHandler(contentService, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	handler.SetPreviewer(NewPreviewer(displays, assignments, ratelimit.NewMemoryService(map[string]ratelimit.Limit{
+		ratelimit.LimitTypeContentProbe: {Rate: 2, Period: time.Hour, BurstSize: 2},
+	})))
+	server := httptest.NewServer(NewRouter(handler, nil, nil))
+	defer server.Close()
+
+	preview := func(t *testing.T, body string) (*http.Response, []byte) {
+		t.Helper()
+		resp, err := http.Post(server.URL+"/menu/preview", "application/json", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, data
+	}
+	decode := func(t *testing.T, data []byte) v1alpha1.ContentPreview {
+		t.Helper()
+		var p v1alpha1.ContentPreview
+		require.NoError(t, json.Unmarshal(data, &p))
+		return p
+	}
+
+	t.Run("schedules are evaluated at the given time", func(t *testing.T) {
+		lunch := time.Date(2024, 6, 3, 12, 30, 0, 0, ny).Format(time.RFC3339)
+		resp, data := preview(t, `{"path": "/specials", "display": "cafe-1", "at": "`+lunch+`"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode, string(data))
+		p := decode(t, data)
+		assert.Equal(t, origin.URL+"/specials", p.URL)
+		assert.Equal(t, "cafe-1", p.Display.Name)
+		assert.True(t, p.Assigned)
+		assert.True(t, p.SourceHealthy)
+		assert.Nil(t, p.Probe)
+		var selected v1alpha1.ResolutionStep
+		for _, step := range p.Resolution {
+			if step.Outcome == v1alpha1.ResolutionSelected {
+				selected = step
+			}
+		}
+		assert.Equal(t, "lunch-specials", selected.Assignment)
+		require.NotNil(t, selected.Window)
+		assert.Equal(t, "11:00", selected.Window.TimeOfDay.Start)
+		assert.Equal(t, "America/New_York", selected.Timezone)
+
+		// In the evening the same display is sent the regular menu
+		evening := time.Date(2024, 6, 3, 19, 0, 0, 0, ny).Format(time.RFC3339)
+		resp, data = preview(t, `{"path": "/specials", "location": {"siteId": "hq", "zone": "cafeteria"}, "at": "`+evening+`"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode, string(data))
+		p = decode(t, data)
+		assert.False(t, p.Assigned)
+		assert.Empty(t, p.Display.Name, "a hypothetical display has no name")
+		reasons := make(map[string]string)
+		for _, step := range p.Resolution {
+			reasons[step.Assignment] = step.Outcome + ": " + step.Reason
+		}
+		assert.Equal(t, map[string]string{
+			"menu":           "selected: ",
+			"lunch-specials": "skipped: outside its schedule",
+		}, reasons)
+		assert.Empty(t, originFetches()[1:], "previews without a probe fetch nothing")
+	})
+
+	t.Run("probes fetch the resolved URL", func(t *testing.T) {
+		resp, data := preview(t, `{"path": "/specials", "probe": true}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode, string(data))
+		p := decode(t, data)
+		require.NotNil(t, p.Probe)
+		assert.True(t, p.Probe.Passed)
+		assert.Equal(t, http.StatusOK, p.Probe.HTTPStatus)
+		assert.Equal(t, "text/html", p.Probe.ContentType)
+		assert.Equal(t, int64(len("<h1>Specials</h1>")), p.Probe.Size)
+		assert.Nil(t, p.Display)
+		assert.Equal(t, "/specials", originFetches()[len(originFetches())-1])
+	})
+
+	t.Run("URLs outside the source are refused", func(t *testing.T) {
+		before := len(originFetches())
+		for _, body := range []string{
+			`{"path": "http://169.254.169.254/latest/meta-data", "probe": true}`,
+			`{"path": "//169.254.169.254/latest", "probe": true}`,
+			`{"path": "/specials/../../admin", "probe": true}`,
+			`{"path": "/kitchen", "probe": true}`,
+			`{"url": "http://169.254.169.254/", "probe": true}`,
+		} {
+			resp, data := preview(t, body)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+			assert.NotContains(t, string(data), `"probe"`, body)
+		}
+		assert.Len(t, originFetches(), before, "nothing was fetched")
+	})
+
+	t.Run("probes are rate limited per source", func(t *testing.T) {
+		resp, data := preview(t, `{"probe": true}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode, string(data))
+
+		// The limit allows two probes of the source, one of them above
+		resp, data = preview(t, `{"probe": true}`)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+		assert.Contains(t, string(data), "content source menu was probed too often")
+
+		// Previews that do not probe are still answered
+		resp, _ = preview(t, `{}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("unknown displays are not found", func(t *testing.T) {
+		resp, _ := preview(t, `{"display": "nowhere"}`)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("previews must be enabled", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		NewRouter(NewHandler(contentService, slog.Default()), nil, nil).ServeHTTP(rec,
+			httptest.NewRequest(http.MethodPost, "/menu/preview", bytes.NewBufferString(`{}`)))
+		assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	})
+}
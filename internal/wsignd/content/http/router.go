@@ -1,15 +1,53 @@
 package http
 
 import (
+	"net/http"
+
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
 )
 
-func NewRouter(h *Handler) chi.Router {
+// NewRouter mounts the content routes. When displayAuth is non-nil it guards
+// event reporting so that only authenticated displays can submit events.
+// The remaining routes require operator scopes from guard, or are open when
+// guard is nil.
+func NewRouter(h *Handler, displayAuth func(http.Handler) http.Handler, guard operator.Guard) chi.Router {
 	r := chi.NewRouter()
 
-	r.Post("/events", h.ReportEvents)
-	r.Get("/health/{url}", h.GetURLHealth)
-	r.Get("/metrics/{url}", h.GetURLMetrics)
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.RequestID)
+
+	r.Group(func(r chi.Router) {
+		if displayAuth != nil {
+			r.Use(displayAuth)
+		}
+		r.Post("/events", h.ReportEvents)
+	})
+
+	read := guard.Require(operator.ScopeContentRead)
+	write := guard.Require(operator.ScopeContentWrite)
+
+	r.With(read).Get("/events", h.QueryEvents)
+	r.With(read).Get("/health", h.GetURLHealth)
+	r.With(read).Get("/metrics", h.GetURLMetrics)
+	r.With(read).Get("/prefetch-status", h.GetPrefetchStatus)
+	// Deprecated path parameter forms, kept for one release
+	r.With(read).Get("/health/{url}", h.GetURLHealthByPath)
+	r.With(read).Get("/metrics/{url}", h.GetURLMetricsByPath)
+
+	// Content source management
+	r.With(write).Post("/", h.CreateContent)
+	r.With(read).Get("/", h.ListContent)
+	r.Route("/{name}", func(r chi.Router) {
+		r.With(read).Get("/", h.GetContent)
+		r.With(write).Patch("/", h.UpdateContent)
+		r.With(write).Delete("/", h.DeleteContent)
+		r.With(read).Post("/validate", h.ValidateContent)
+		r.With(read).Post("/preview", h.PreviewContent)
+	})
 
 	return r
 }
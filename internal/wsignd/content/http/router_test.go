@@ -0,0 +1,109 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
+	authhttp "github.com/wrale/wrale-signage/internal/wsignd/auth/http"
+	authmemory "github.com/wrale/wrale-signage/internal/wsignd/auth/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+	operatormemory "github.com/wrale/wrale-signage/internal/wsignd/operator/memory"
+)
+
+// TestRouterEventAuth mounts the router the way the server does and checks
+// that event reports are tied to the display that sent them
+func TestRouterEventAuth(t *testing.T) {
+	ctx := context.Background()
+
+	material := make([]byte, 32)
+	_, err := rand.Read(material)
+	require.NoError(t, err)
+	key, err := auth.ParseKey("test", string(auth.AlgorithmHS256), base64.StdEncoding.EncodeToString(material))
+	require.NoError(t, err)
+	keys, err := auth.NewKeyRing(key)
+	require.NoError(t, err)
+	tokens := auth.NewService(keys, authmemory.NewRepository(), time.Hour)
+	operators := operator.NewService(operatormemory.NewRepository())
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	mockSvc := new(mockService)
+	router := NewRouter(NewHandler(mockSvc, logger),
+		authhttp.RequireDisplayToken(tokens, logger),
+		authhttp.OperatorAuth(operators, tokens, logger))
+
+	displayID := uuid.New()
+	displayToken, _, err := tokens.IssueDisplayToken(ctx, displayID)
+	require.NoError(t, err)
+
+	batchFor := func(id uuid.UUID) []byte {
+		body, err := json.Marshal(content.EventBatch{
+			DisplayID: id,
+			Events: []content.Event{{
+				ID:        uuid.New(),
+				DisplayID: id,
+				Type:      content.EventContentLoaded,
+				URL:       "https://example.com/content",
+				Timestamp: time.Now(),
+			}},
+		})
+		require.NoError(t, err)
+		return body
+	}
+	post := func(token string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/events", bytes.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("unauthenticated reports are rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, post("", batchFor(displayID)).Code)
+	})
+
+	t.Run("reports for another display are forbidden", func(t *testing.T) {
+		w := post(displayToken, batchFor(uuid.New()))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		var apiErr v1alpha1.Error
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&apiErr))
+		assert.Equal(t, "FORBIDDEN", apiErr.Code)
+	})
+
+	t.Run("reports for the authenticated display are accepted", func(t *testing.T) {
+		mockSvc.On("ReportEvents", mock.Anything, mock.MatchedBy(func(b content.EventBatch) bool {
+			return b.DisplayID == displayID
+		})).Return(nil).Once()
+
+		assert.Equal(t, http.StatusAccepted, post(displayToken, batchFor(displayID)).Code)
+	})
+
+	t.Run("display tokens cannot manage content", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+displayToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	mockSvc.AssertExpectations(t)
+}
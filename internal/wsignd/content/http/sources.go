@@ -0,0 +1,223 @@
+package http
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+	"github.com/wrale/wrale-signage/internal/wsignd/jsonpatch"
+)
+
+// strictParam reports whether the request asked for strict validation
+func strictParam(r *http.Request) bool {
+	strict, _ := strconv.ParseBool(r.URL.Query().Get("strict"))
+	return strict
+}
+
+// CreateContent handles content source registration. With ?strict=true a
+// source that fails validation is rejected instead of stored unhealthy.
+// Names are case-insensitive; when the name is taken the conflict response
+// carries the existing source in its details.
+func (h *Handler) CreateContent(w http.ResponseWriter, r *http.Request) {
+	var source v1alpha1.ContentSource
+	if err := httpapi.DecodeJSON(r, &source); err != nil {
+		httpapi.WriteError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.service.CreateContent(r.Context(), &source, strictParam(r))
+	var exists *content.SourceExistsError
+	if errors.As(err, &exists) {
+		httpapi.WriteJSON(w, http.StatusConflict, v1alpha1.Error{
+			Code:    "ALREADY_EXISTS",
+			Message: exists.Error(),
+			Details: exists.Existing,
+		})
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to create content source",
+			"error", err,
+			"name", source.Name,
+		)
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusCreated, created)
+}
+
+// ListContent handles content source listing. ?type= restricts the list to
+// one content type, repeated ?name= parameters fetch specific sources and
+// repeated ?tag= parameters select the sources carrying every tag.
+// Only one kind of filter may be used at a time.
+func (h *Handler) ListContent(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	contentType := query.Get("type")
+	names := query["name"]
+	tags := query["tag"]
+
+	var (
+		sources []v1alpha1.ContentSource
+		err     error
+	)
+	switch {
+	case countFilters(contentType != "", len(names) > 0, len(tags) > 0) > 1:
+		httpapi.Error(w, http.StatusBadRequest, "type, name and tag filters cannot be combined")
+		return
+	case contentType != "":
+		sources, err = h.service.ListContentByType(r.Context(), contentType)
+	case len(names) > 0:
+		sources, err = h.service.GetContentByNames(r.Context(), names)
+	case len(tags) > 0:
+		sources, err = h.service.ListContentByTags(r.Context(), tags)
+	default:
+		sources, err = h.service.ListContent(r.Context())
+	}
+	if err != nil {
+		h.logger.Error("failed to list content sources",
+			"error", err,
+		)
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, &v1alpha1.ContentSourceList{
+		TypeMeta: v1alpha1.TypeMeta{Kind: "ContentSourceList", APIVersion: "v1alpha1"},
+		Items:    sources,
+	})
+}
+
+// countFilters reports how many of the given filters are in use
+func countFilters(used ...bool) int {
+	n := 0
+	for _, u := range used {
+		if u {
+			n++
+		}
+	}
+	return n
+}
+
+// GetContent handles content source lookup by name
+func (h *Handler) GetContent(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	source, err := h.service.GetContent(r.Context(), name)
+	if err != nil {
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, source)
+}
+
+// UpdateContent handles partial content source updates. With "notify" set
+// in the body the response also reports how many displays were reloaded.
+// A body of type application/json-patch+json is applied as a JSON Patch.
+func (h *Handler) UpdateContent(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == jsonpatch.ContentType {
+		h.patchContent(w, r, name)
+		return
+	}
+
+	var update v1alpha1.ContentSourceUpdate
+	if err := httpapi.DecodeJSON(r, &update); err != nil {
+		httpapi.WriteError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.service.UpdateContent(r.Context(), name, &update, strictParam(r))
+	if err != nil {
+		h.logger.Error("failed to update content source",
+			"error", err,
+			"name", name,
+		)
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, result)
+}
+
+// patchContent applies a JSON Patch to a content source. A failing test
+// operation is reported as a conflict, since the source is not in the state
+// the patch expected.
+func (h *Handler) patchContent(w http.ResponseWriter, r *http.Request, name string) {
+	var patch jsonpatch.Patch
+	if err := httpapi.DecodeJSON(r, &patch); err != nil {
+		httpapi.WriteError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.service.PatchContent(r.Context(), name, patch, strictParam(r))
+	if err != nil {
+		h.logger.Error("failed to patch content source",
+			"error", err,
+			"name", name,
+		)
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, result)
+}
+
+// DeleteContent handles content source removal. A source that assignments
+// still refer to is not removed unless ?force=true is given; the conflict
+// response lists them in its details. With force they are removed along
+// with the source, and the response lists what was removed.
+func (h *Handler) DeleteContent(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+
+	deletion, err := h.service.DeleteContent(r.Context(), name, force)
+	var inUse *content.SourceInUseError
+	if errors.As(err, &inUse) {
+		httpapi.WriteJSON(w, http.StatusConflict, v1alpha1.Error{
+			Code:    "SOURCE_IN_USE",
+			Message: inUse.Error(),
+			Details: inUse.References,
+		})
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to delete content source",
+			"error", err,
+			"name", name,
+		)
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if len(deletion.Removed) > 0 {
+		h.logger.Info("removed content source by force",
+			"name", deletion.Name,
+			"removed", len(deletion.Removed),
+		)
+	}
+	httpapi.WriteJSON(w, http.StatusOK, deletion)
+}
+
+// ValidateContent revalidates a content source immediately
+func (h *Handler) ValidateContent(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	source, err := h.service.ValidateSource(r.Context(), name)
+	if err != nil {
+		h.logger.Error("failed to validate content source",
+			"error", err,
+			"name", name,
+		)
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, source)
+}
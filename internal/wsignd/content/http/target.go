@@ -0,0 +1,68 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// maxTargetURLLength bounds the URLs health and metrics are requested for.
+// Content source URLs are stored in full, but nothing longer is served by
+// browsers and proxies in practice.
+const maxTargetURLLength = 2048
+
+// targetURL reads the content URL a health or metrics request is about
+// from its url query parameter. The query must decode cleanly and carry
+// exactly one url.
+func targetURL(r *http.Request) (string, error) {
+	const op = "ContentHandler.targetURL"
+
+	query, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		return "", werrors.NewError("INVALID_INPUT", "malformed query string: "+err.Error(), op, werrors.ErrInvalidInput)
+	}
+	values := query["url"]
+	switch {
+	case len(values) == 0 || values[0] == "":
+		return "", werrors.NewError("INVALID_INPUT", "url query parameter is required", op, werrors.ErrInvalidInput)
+	case len(values) > 1:
+		return "", werrors.NewError("INVALID_INPUT", "only one url query parameter may be given", op, werrors.ErrInvalidInput)
+	}
+	return checkTargetURL(op, values[0])
+}
+
+// pathTargetURL reads the content URL from the {url} path parameter of the
+// deprecated routes. The URL must be percent-encoded into that one segment.
+func pathTargetURL(r *http.Request) (string, error) {
+	const op = "ContentHandler.pathTargetURL"
+
+	// chi routes on the escaped path when there is one, leaving the
+	// parameter encoded
+	raw, err := url.PathUnescape(chi.URLParam(r, "url"))
+	if err != nil || raw == "" {
+		return "", werrors.NewError("INVALID_INPUT", "url path parameter is missing or malformed", op, werrors.ErrInvalidInput)
+	}
+	return checkTargetURL(op, raw)
+}
+
+// checkTargetURL accepts absolute http and https URLs of a sensible length
+func checkTargetURL(op, raw string) (string, error) {
+	if len(raw) > maxTargetURLLength {
+		return "", werrors.NewError("INVALID_INPUT", "url is too long", op, werrors.ErrInvalidInput)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", werrors.NewError("INVALID_INPUT", "url must be an absolute http or https URL", op, werrors.ErrInvalidInput)
+	}
+	return raw, nil
+}
+
+// deprecateForQuery marks a response from a path parameter route and
+// points at the query parameter route that replaces it
+func deprecateForQuery(w http.ResponseWriter, endpoint, target string) {
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", "</api/v1alpha1/content/"+endpoint+"?url="+url.QueryEscape(target)+`>; rel="successor-version"`)
+}
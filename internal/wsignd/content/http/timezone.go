@@ -0,0 +1,77 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/timezone"
+)
+
+// DisplayTimezones finds the time zone a display is in
+type DisplayTimezones interface {
+	DisplayTimezone(ctx context.Context, displayID uuid.UUID) (*time.Location, error)
+}
+
+// SetDisplayTimezones lets event queries ask for each event in the zone of
+// the display reporting it. Without it such events are given in UTC. It
+// must be called before the handler serves requests.
+func (h *Handler) SetDisplayTimezones(zones DisplayTimezones) {
+	h.zones = zones
+}
+
+// eventZones gives event timestamps in the zone a request asked for,
+// looking each display's zone up once per request
+type eventZones struct {
+	ctx      context.Context
+	tz       *timezone.Request
+	lookup   DisplayTimezones
+	displays map[uuid.UUID]*time.Location
+}
+
+// eventZones reads the zone r asks for, returning nil when it asks for none
+func (h *Handler) eventZones(r *http.Request) (*eventZones, error) {
+	tz, err := timezone.FromRequest(r)
+	if tz == nil || err != nil {
+		return nil, err
+	}
+	return &eventZones{
+		ctx:      r.Context(),
+		tz:       tz,
+		lookup:   h.zones,
+		displays: make(map[uuid.UUID]*time.Location),
+	}, nil
+}
+
+// localize returns e with its timestamp in the zone asked for. Events of
+// displays whose zone cannot be found, such as removed ones, are given in
+// UTC.
+func (z *eventZones) localize(e v1alpha1.ContentEvent) v1alpha1.ContentEvent {
+	if z == nil {
+		return e
+	}
+	loc := z.tz.Location
+	if z.tz.Local {
+		loc = z.display(e.DisplayID)
+	}
+	e.Timestamp = timezone.In(e.Timestamp, loc)
+	return e
+}
+
+// display returns the zone of a display
+func (z *eventZones) display(id uuid.UUID) *time.Location {
+	if loc, ok := z.displays[id]; ok {
+		return loc
+	}
+	loc := time.UTC
+	if z.lookup != nil {
+		if found, err := z.lookup.DisplayTimezone(z.ctx, id); err == nil {
+			loc = found
+		}
+	}
+	z.displays[id] = loc
+	return loc
+}
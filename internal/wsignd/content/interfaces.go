@@ -4,6 +4,9 @@ import (
 	"context"
 
 	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/jsonpatch"
 )
 
 // Service defines the content service interface
@@ -12,12 +15,145 @@ type Service interface {
 	GetURLHealth(ctx context.Context, url string) (*HealthStatus, error)
 	GetURLMetrics(ctx context.Context, url string) (*URLMetrics, error)
 	ValidateContent(ctx context.Context, url string) error
+	// QueryEvents calls fn for each stored event the query selects, in
+	// timestamp order, stopping at the first error fn returns. The query
+	// must give a window of at most MaxEventQueryWindow.
+	QueryEvents(ctx context.Context, query EventQuery, fn func(Event) error) error
+	// PrefetchStatus reports how far the displays assigned url have got
+	// downloading it
+	PrefetchStatus(ctx context.Context, url string) (*v1alpha1.PrefetchStatus, error)
+
+	// CreateContent registers a new content source after validating it. When
+	// strict is set a failing validation rejects the source.
+	CreateContent(ctx context.Context, source *v1alpha1.ContentSource, strict bool) (*v1alpha1.ContentSource, error)
+	// GetContent retrieves a content source by name
+	GetContent(ctx context.Context, name string) (*v1alpha1.ContentSource, error)
+	// ListContent retrieves all content sources
+	ListContent(ctx context.Context) ([]v1alpha1.ContentSource, error)
+	// ListContentByType retrieves the content sources of one type
+	ListContentByType(ctx context.Context, contentType string) ([]v1alpha1.ContentSource, error)
+	// ListContentByTags retrieves the content sources carrying all of tags
+	ListContentByTags(ctx context.Context, tags []string) ([]v1alpha1.ContentSource, error)
+	// GetContentByNames retrieves several content sources at once. Names
+	// that do not exist are left out of the result.
+	GetContentByNames(ctx context.Context, names []string) ([]v1alpha1.ContentSource, error)
+	// ResolveSources looks up every source a sequence refers to in one
+	// query. When any are missing it returns a *MissingSourcesError naming
+	// them.
+	ResolveSources(ctx context.Context, names []string) (map[string]*v1alpha1.ContentSource, error)
+	// UpdateContent applies a partial update to a content source and
+	// revalidates it. When strict is set a failing validation rejects the
+	// update. When the update sets Notify the displays showing the source
+	// are told to reload once it is saved. When it sets Version the update
+	// fails with a version mismatch unless the source is at that version.
+	UpdateContent(ctx context.Context, name string, update *v1alpha1.ContentSourceUpdate, strict bool) (*v1alpha1.ContentSourceUpdateResult, error)
+	// PatchContent applies a JSON Patch to a content source and saves the
+	// result like UpdateContent, conditional on the version patched. Patches
+	// changing anything but the source's URL, properties, allowed paths,
+	// tags and fallbacks are rejected, as are those whose test operations
+	// fail.
+	PatchContent(ctx context.Context, name string, patch jsonpatch.Patch, strict bool) (*v1alpha1.ContentSourceUpdateResult, error)
+	// DeleteContent removes a content source. When resources still refer to
+	// it the removal fails with a *SourceInUseError listing them, unless
+	// force is set, in which case they are removed along with it.
+	DeleteContent(ctx context.Context, name string, force bool) (*v1alpha1.ContentSourceDeletion, error)
+	// ValidateSource validates a content source now and persists the report
+	ValidateSource(ctx context.Context, name string) (*v1alpha1.ContentSource, error)
+	// ProbeSource fetches the URL path selects beneath a content source,
+	// as ResolveURL resolves it, without storing the report
+	ProbeSource(ctx context.Context, name, path string) (*v1alpha1.ContentValidationReport, error)
+	// RefreshValidations revalidates every content source
+	RefreshValidations(ctx context.Context) error
+}
+
+// Repository defines persistence for content sources
+type Repository interface {
+	// CreateContent stores a new content source
+	CreateContent(ctx context.Context, source *v1alpha1.ContentSource) error
+	// GetContent retrieves a content source by name
+	GetContent(ctx context.Context, name string) (*v1alpha1.ContentSource, error)
+	// ListContent retrieves all content sources ordered by name
+	ListContent(ctx context.Context) ([]v1alpha1.ContentSource, error)
+	// ListContentByType retrieves the content sources of one type ordered by name
+	ListContentByType(ctx context.Context, contentType string) ([]v1alpha1.ContentSource, error)
+	// ListContentByTags retrieves the content sources carrying all of tags
+	// ordered by name
+	ListContentByTags(ctx context.Context, tags []string) ([]v1alpha1.ContentSource, error)
+	// GetContentByNames retrieves the named content sources in a single
+	// query, keyed by name. Names that do not exist have no entry.
+	GetContentByNames(ctx context.Context, names []string) (map[string]*v1alpha1.ContentSource, error)
+	// UpdateContent stores changes to an existing content source's spec and
+	// status and advances its version. It returns a version mismatch error
+	// unless the stored source is still at source.Status.Version.
+	UpdateContent(ctx context.Context, source *v1alpha1.ContentSource) error
+	// DeleteContent removes a content source by name, in one transaction
+	// with the check for resources referring to it. Unless force is set it
+	// fails with a *SourceInUseError when there are any; with force they are
+	// removed too, and returned.
+	DeleteContent(ctx context.Context, name string, force bool) ([]v1alpha1.ResourceReference, error)
+	// UpdateValidation records a validation report without changing the spec
+	UpdateValidation(ctx context.Context, name string, report *v1alpha1.ContentValidationReport) error
+}
+
+// SourceReferenceStore finds and removes the resources that refer to
+// content sources by name. Stores that keep sources in the same database as
+// assignments check references themselves; the others are handed one of
+// these.
+type SourceReferenceStore interface {
+	// SourceReferences lists the resources referring to the named source
+	SourceReferences(ctx context.Context, name string) ([]v1alpha1.ResourceReference, error)
+	// RemoveSourceReferences removes the resources referring to the named
+	// source and returns them
+	RemoveSourceReferences(ctx context.Context, name string) ([]v1alpha1.ResourceReference, error)
+}
+
+// Validator checks that content can be fetched from a URL
+type Validator interface {
+	// Validate fetches url and reports what was observed. Failures are
+	// described in the report rather than returned as errors.
+	Validate(ctx context.Context, url string) *v1alpha1.ContentValidationReport
+}
+
+// Notifier finds the displays assigned content and tells them when it has
+// changed
+type Notifier interface {
+	// NotifySourceUpdated asks the connected displays assigned content from
+	// source to reload and returns how many were reached. Displays that
+	// cannot be reached are skipped.
+	NotifySourceUpdated(ctx context.Context, source *v1alpha1.ContentSource) int
+	// AssignedDisplays lists the displays selected by an assignment of url
+	// that has not expired, including assignments yet to start
+	AssignedDisplays(ctx context.Context, url string) ([]uuid.UUID, error)
 }
 
 type EventProcessor interface {
 	ProcessEvents(ctx context.Context, batch EventBatch) error
 }
 
+// EventReader reads back stored events
+type EventReader interface {
+	// ForEachEvent calls fn for each event the query selects, ordered by
+	// timestamp and then ID, stopping at the first error fn returns. Events
+	// are read as fn consumes them rather than collected first.
+	ForEachEvent(ctx context.Context, query EventQuery, fn func(Event) error) error
+}
+
+// PrefetchReader reads back the prefetch progress displays reported
+type PrefetchReader interface {
+	// PrefetchProgress lists the latest progress each display reported for
+	// url, ordered by display ID
+	PrefetchProgress(ctx context.Context, url string) ([]PrefetchProgress, error)
+}
+
+// EventStore keeps reported events and reads them back. Processing a
+// prefetch progress event also records it as its display's latest progress
+// for the URL unless newer progress is already recorded.
+type EventStore interface {
+	EventProcessor
+	EventReader
+	PrefetchReader
+}
+
 type MetricsAggregator interface {
 	RecordMetrics(ctx context.Context, event Event) error
 	GetURLMetrics(ctx context.Context, url string) (*URLMetrics, error)
@@ -28,19 +164,25 @@ type HealthMonitor interface {
 	GetHealthHistory(ctx context.Context, url string) ([]HealthStatus, error)
 }
 
+// URLMetrics summarises the events reported for a content URL. The average
+// timings are rounded to whole milliseconds.
 type URLMetrics struct {
 	URL           string
 	LastSeen      int64
 	LoadCount     int64
 	ErrorCount    int64
-	AvgLoadTime   float64
-	AvgRenderTime float64
+	AvgLoadTime   int64
+	AvgRenderTime int64
 	ErrorRates    map[string]float64
 }
 
 type HealthStatus struct {
-	URL       string
-	Healthy   bool
+	URL     string
+	Healthy bool
+	// Failing is set when displays report failing to load the content too
+	// often, as opposed to it being unhealthy only for not being reported
+	// recently
+	Failing   bool
 	Issues    []string
 	LastCheck int64
 	Displays  []uuid.UUID
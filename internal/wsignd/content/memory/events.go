@@ -0,0 +1,224 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// ProcessEvents stores each event in a batch, defaulting an event's display
+// to the batch's, records prefetch progress and updates the displays' last
+// errors when tracked
+func (r *Repository) ProcessEvents(ctx context.Context, batch content.EventBatch) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, event := range batch.Events {
+		if event.DisplayID == uuid.Nil {
+			event.DisplayID = batch.DisplayID
+		}
+		r.events = append(r.events, event)
+		if event.Type == content.EventContentPrefetchProgress {
+			r.recordPrefetch(content.NewPrefetchProgress(event))
+		}
+
+		if err := r.trackLastError(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prefetchKey identifies the progress of one display prefetching one URL
+type prefetchKey struct {
+	displayID uuid.UUID
+	url       string
+}
+
+// recordPrefetch keeps p as its display's progress for the URL unless
+// progress reported later is already kept
+func (r *Repository) recordPrefetch(p content.PrefetchProgress) {
+	key := prefetchKey{displayID: p.DisplayID, url: p.URL}
+	if kept, ok := r.prefetch[key]; ok && kept.ReportedAt.After(p.ReportedAt) {
+		return
+	}
+	r.prefetch[key] = p
+}
+
+// PrefetchProgress lists the latest progress each display reported for
+// url, ordered by display ID
+func (r *Repository) PrefetchProgress(ctx context.Context, url string) ([]content.PrefetchProgress, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var progress []content.PrefetchProgress
+	for key, p := range r.prefetch {
+		if key.url == url {
+			progress = append(progress, p)
+		}
+	}
+	sort.Slice(progress, func(i, j int) bool {
+		return bytes.Compare(progress[i].DisplayID[:], progress[j].DisplayID[:]) < 0
+	})
+	return progress, nil
+}
+
+// trackLastError records an error event as its display's last error, or
+// clears the last error on a successful load. Events from displays the
+// store does not know are ignored since events are not checked against
+// displays here.
+func (r *Repository) trackLastError(ctx context.Context, event content.Event) error {
+	if r.lastErrors == nil {
+		return nil
+	}
+
+	var err error
+	switch event.Type {
+	case content.EventContentError:
+		e := display.ContentError{URL: event.URL, At: event.Timestamp}
+		if event.Error != nil {
+			e.Code = event.Error.Code
+			e.Message = event.Error.Message
+		}
+		err = r.lastErrors.SetLastError(ctx, event.DisplayID, e)
+	case content.EventContentLoaded:
+		err = r.lastErrors.ClearLastError(ctx, event.DisplayID, event.Timestamp)
+	}
+	if werrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// ForEachEvent calls fn for each event the query selects, ordered by
+// timestamp and then ID. The matching events are copied out first so fn
+// may take as long as it likes without holding up event reports.
+func (r *Repository) ForEachEvent(ctx context.Context, query content.EventQuery, fn func(content.Event) error) error {
+	r.mu.RLock()
+	var events []content.Event
+	for _, event := range r.events {
+		if query.Matches(event) {
+			events = append(events, event)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Cursor().Before(events[j].Cursor())
+	})
+	if query.Limit > 0 && len(events) > query.Limit {
+		events = events[:query.Limit]
+	}
+	for _, event := range events {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetURLMetrics summarises the events reported for url since the given time
+func (r *Repository) GetURLMetrics(ctx context.Context, url string, since time.Time) (*content.URLMetrics, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	metrics := &content.URLMetrics{
+		URL:        url,
+		ErrorRates: make(map[string]float64),
+	}
+
+	var total, timed int64
+	loadTime, renderTime := new(big.Int), new(big.Int)
+	errorCodes := make(map[string]int64)
+	var lastSeen time.Time
+	for _, event := range r.events {
+		if event.URL != url {
+			continue
+		}
+		if event.Timestamp.After(lastSeen) {
+			lastSeen = event.Timestamp
+		}
+		if event.Timestamp.Before(since) {
+			continue
+		}
+
+		total++
+		switch event.Type {
+		case content.EventContentLoaded:
+			metrics.LoadCount++
+			if event.Metrics != nil {
+				timed++
+				loadTime.Add(loadTime, big.NewInt(int64(event.Metrics.LoadTime)))
+				renderTime.Add(renderTime, big.NewInt(int64(event.Metrics.RenderTime)))
+			}
+		case content.EventContentError:
+			metrics.ErrorCount++
+			if event.Error != nil {
+				errorCodes[event.Error.Code]++
+			}
+		}
+	}
+
+	if metrics.LoadCount == 0 && metrics.ErrorCount == 0 {
+		return metrics, nil
+	}
+
+	metrics.LastSeen = lastSeen.Unix()
+	if timed > 0 {
+		metrics.AvgLoadTime = meanMillis(loadTime, timed)
+		metrics.AvgRenderTime = meanMillis(renderTime, timed)
+	}
+	for code, n := range errorCodes {
+		metrics.ErrorRates[code] = float64(n) / float64(total)
+	}
+	return metrics, nil
+}
+
+// meanMillis divides total by n, rounding half away from zero as
+// PostgreSQL's ROUND does. Totals are summed as big integers, as PostgreSQL
+// sums numerics, so large timings neither overflow nor drift.
+func meanMillis(total *big.Int, n int64) int64 {
+	divisor := big.NewInt(n)
+	q, r := new(big.Int).QuoRem(total, divisor, new(big.Int))
+	if r.Lsh(r.Abs(r), 1).Cmp(divisor) >= 0 {
+		if total.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q.Int64()
+}
+
+// metricsAggregator computes URL metrics from stored events over a sliding
+// window
+type metricsAggregator struct {
+	repo   *Repository
+	window time.Duration
+}
+
+// NewMetricsAggregator creates a MetricsAggregator backed by the in-memory
+// event store
+func NewMetricsAggregator(repo *Repository, window time.Duration) content.MetricsAggregator {
+	return &metricsAggregator{repo: repo, window: window}
+}
+
+// RecordMetrics is a no-op; metrics are derived from events saved by ProcessEvents
+func (a *metricsAggregator) RecordMetrics(ctx context.Context, event content.Event) error {
+	return nil
+}
+
+func (a *metricsAggregator) GetURLMetrics(ctx context.Context, url string) (*content.URLMetrics, error) {
+	return a.repo.GetURLMetrics(ctx, url, time.Now().Add(-a.window))
+}
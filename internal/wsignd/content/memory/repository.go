@@ -0,0 +1,299 @@
+// Package memory implements content persistence in process memory. It backs
+// demo mode and serves as a fast fake in tests; data is lost when the
+// process exits.
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// Repository stores content sources and reported events in memory. It
+// implements content.Repository and content.EventStore with the same
+// semantics as the PostgreSQL repository, except that events are not
+// checked against known displays.
+type Repository struct {
+	mu      sync.RWMutex
+	sources map[string]v1alpha1.ContentSource
+	events  []content.Event
+
+	// prefetch holds the latest prefetch progress per display and URL
+	prefetch map[prefetchKey]content.PrefetchProgress
+
+	// lastErrors, when set, is told about content errors and loads so that
+	// displays' LastError stays current
+	lastErrors display.LastErrorStore
+
+	// references, when set, finds and removes the resources that refer to
+	// a source when it is deleted
+	references content.SourceReferenceStore
+
+	// now returns the current time for created/updated timestamps
+	now func() time.Time
+}
+
+// NewRepository creates an empty in-memory content repository
+func NewRepository() *Repository {
+	return &Repository{
+		sources:  make(map[string]v1alpha1.ContentSource),
+		prefetch: make(map[prefetchKey]content.PrefetchProgress),
+		now:      time.Now,
+	}
+}
+
+// TrackDisplayErrors makes ProcessEvents keep each display's LastError in
+// store current, as the PostgreSQL event store does for its displays table
+func (r *Repository) TrackDisplayErrors(store display.LastErrorStore) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastErrors = store
+}
+
+// TrackReferences makes DeleteContent check store for resources referring
+// to a source, as the PostgreSQL repository checks its assignments table.
+// Resources adding references should do so through WithSource.
+func (r *Repository) TrackReferences(store content.SourceReferenceStore) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.references = store
+}
+
+// WithSource runs fn while the named source exists and cannot be deleted,
+// failing with a not found error when there is no such source. It
+// implements assignment.SourceLocker.
+func (r *Repository) WithSource(ctx context.Context, name string, fn func() error) error {
+	const op = "ContentRepository.WithSource"
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.sources[name]; !ok {
+		return werrors.NewError("NOT_FOUND", "referenced resource not found", op, werrors.ErrNotFound)
+	}
+	return fn()
+}
+
+// CreateContent stores a new content source, assigning an ID if it has none
+func (r *Repository) CreateContent(ctx context.Context, source *v1alpha1.ContentSource) error {
+	const op = "ContentRepository.CreateContent"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if source.ID == uuid.Nil {
+		source.ID = uuid.New()
+	}
+	for _, other := range r.sources {
+		if other.Name == source.Name || other.ID == source.ID {
+			return werrors.NewError("CONFLICT", "resource already exists", op, werrors.ErrConflict)
+		}
+	}
+
+	now := r.now()
+	source.CreatedAt = now
+	source.UpdatedAt = now
+	r.sources[source.Name] = copySource(source)
+	return nil
+}
+
+// GetContent retrieves a content source by name
+func (r *Repository) GetContent(ctx context.Context, name string) (*v1alpha1.ContentSource, error) {
+	const op = "ContentRepository.GetContent"
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	source, ok := r.sources[name]
+	if !ok {
+		return nil, notFound(op)
+	}
+	c := copySource(&source)
+	return &c, nil
+}
+
+// ListContent retrieves all content sources ordered by name
+func (r *Repository) ListContent(ctx context.Context) ([]v1alpha1.ContentSource, error) {
+	return r.list(func(*v1alpha1.ContentSource) bool { return true }), nil
+}
+
+// ListContentByType retrieves the content sources of one type ordered by name
+func (r *Repository) ListContentByType(ctx context.Context, contentType string) ([]v1alpha1.ContentSource, error) {
+	return r.list(func(s *v1alpha1.ContentSource) bool { return s.Spec.Type == contentType }), nil
+}
+
+// ListContentByTags retrieves the content sources carrying all of tags
+// ordered by name
+func (r *Repository) ListContentByTags(ctx context.Context, tags []string) ([]v1alpha1.ContentSource, error) {
+	return r.list(func(s *v1alpha1.ContentSource) bool {
+		for _, tag := range tags {
+			if !slices.Contains(s.Spec.Tags, tag) {
+				return false
+			}
+		}
+		return true
+	}), nil
+}
+
+// GetContentByNames retrieves the named content sources keyed by name,
+// skipping names that do not exist
+func (r *Repository) GetContentByNames(ctx context.Context, names []string) (map[string]*v1alpha1.ContentSource, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sources := make(map[string]*v1alpha1.ContentSource, len(names))
+	for _, name := range names {
+		if source, ok := r.sources[name]; ok {
+			copied := copySource(&source)
+			sources[name] = &copied
+		}
+	}
+	return sources, nil
+}
+
+// list returns copies of the sources that match, ordered by name
+func (r *Repository) list(match func(*v1alpha1.ContentSource) bool) []v1alpha1.ContentSource {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sources := make([]v1alpha1.ContentSource, 0, len(r.sources))
+	for _, source := range r.sources {
+		if match(&source) {
+			sources = append(sources, copySource(&source))
+		}
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].Name < sources[j].Name
+	})
+	return sources
+}
+
+// UpdateContent stores changes to a source's URL, properties, tags and status,
+// advancing its version, unless the source was changed since it was read
+func (r *Repository) UpdateContent(ctx context.Context, source *v1alpha1.ContentSource) error {
+	const op = "ContentRepository.UpdateContent"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.sources[source.Name]
+	if !ok {
+		return notFound(op)
+	}
+	if stored.Status.Version != source.Status.Version {
+		return versionMismatch(op)
+	}
+
+	stored.Spec.URL = source.Spec.URL
+	stored.Spec.Properties = source.Spec.Properties
+	stored.Spec.AllowedPaths = source.Spec.AllowedPaths
+	stored.Spec.Tags = source.Spec.Tags
+	stored.Spec.Fallbacks = source.Spec.Fallbacks
+	stored.Status.LastValidated = source.Status.LastValidated
+	stored.Status.IsHealthy = source.Status.IsHealthy
+	stored.Status.Validation = source.Status.Validation
+	stored.Status.Version++
+	stored.UpdatedAt = r.now()
+	r.sources[source.Name] = copySource(&stored)
+
+	source.Status.Version = stored.Status.Version
+	source.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+// DeleteContent removes a content source by name. The lock is held
+// throughout, so no reference can be added between the check and the
+// removal.
+func (r *Repository) DeleteContent(ctx context.Context, name string, force bool) ([]v1alpha1.ResourceReference, error) {
+	const op = "ContentRepository.DeleteContent"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sources[name]; !ok {
+		return nil, notFound(op)
+	}
+
+	var removed []v1alpha1.ResourceReference
+	if r.references != nil {
+		refs, err := r.references.SourceReferences(ctx, name)
+		if err != nil {
+			return nil, werrors.NewError("INTERNAL", "listing source references", op, err)
+		}
+		if len(refs) > 0 && !force {
+			return nil, &content.SourceInUseError{Name: name, References: refs}
+		}
+		if len(refs) > 0 {
+			if removed, err = r.references.RemoveSourceReferences(ctx, name); err != nil {
+				return nil, werrors.NewError("INTERNAL", "removing source references", op, err)
+			}
+		}
+	}
+	delete(r.sources, name)
+	return removed, nil
+}
+
+// UpdateValidation records a validation report without changing the spec
+func (r *Repository) UpdateValidation(ctx context.Context, name string, report *v1alpha1.ContentValidationReport) error {
+	const op = "ContentRepository.UpdateValidation"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.sources[name]
+	if !ok {
+		return notFound(op)
+	}
+
+	stored.Status.LastValidated = report.ValidatedAt
+	stored.Status.IsHealthy = report.Passed
+	stored.Status.Validation = report
+	stored.UpdatedAt = r.now()
+	r.sources[name] = copySource(&stored)
+	return nil
+}
+
+// copySource returns a copy of source that shares no mutable state with it
+func copySource(source *v1alpha1.ContentSource) v1alpha1.ContentSource {
+	c := *source
+	c.TypeMeta = v1alpha1.TypeMeta{Kind: "ContentSource", APIVersion: "v1alpha1"}
+	if source.Spec.Properties != nil {
+		c.Spec.Properties = make(map[string]string, len(source.Spec.Properties))
+		for k, v := range source.Spec.Properties {
+			c.Spec.Properties[k] = v
+		}
+	}
+	if source.Spec.AllowedPaths != nil {
+		c.Spec.AllowedPaths = append([]string{}, source.Spec.AllowedPaths...)
+	}
+	if source.Spec.Tags != nil {
+		c.Spec.Tags = append([]string{}, source.Spec.Tags...)
+	}
+	if source.Spec.Fallbacks != nil {
+		c.Spec.Fallbacks = append([]string{}, source.Spec.Fallbacks...)
+	}
+	if source.Status.Validation != nil {
+		report := *source.Status.Validation
+		c.Status.Validation = &report
+	}
+	return c
+}
+
+// notFound builds the error the PostgreSQL repositories return for a
+// missing row
+func notFound(op string) error {
+	return werrors.NewError("NOT_FOUND", "resource not found", op, werrors.ErrNotFound)
+}
+
+func versionMismatch(op string) error {
+	return werrors.NewError("VERSION_MISMATCH", "resource was modified concurrently", op, werrors.ErrVersionMismatch)
+}
@@ -0,0 +1,201 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/assignment"
+	assignmentmemory "github.com/wrale/wrale-signage/internal/wsignd/assignment/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	"github.com/wrale/wrale-signage/internal/wsignd/content/repotest"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	displaymemory "github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+)
+
+func TestRepositoryConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) content.Repository {
+		return NewRepository()
+	})
+}
+
+func TestReferenceConformance(t *testing.T) {
+	repotest.RunReferences(t, func(t *testing.T) (content.Repository, assignment.Repository) {
+		sources := NewRepository()
+		assignments := assignmentmemory.NewRepository()
+		sources.TrackReferences(assignments)
+		assignments.RequireSources(sources)
+		return sources, assignments
+	})
+}
+
+func TestGetURLMetrics(t *testing.T) {
+	repo := NewRepository()
+	ctx := context.Background()
+	url := "https://example.com/menu"
+	displayID := uuid.New()
+	now := time.Now()
+
+	err := repo.ProcessEvents(ctx, content.EventBatch{
+		DisplayID: displayID,
+		Events: []content.Event{
+			{ID: uuid.New(), Type: content.EventContentLoaded, URL: url, Timestamp: now.Add(-2 * time.Hour),
+				Metrics: &content.EventMetrics{LoadTime: 900, RenderTime: 900}},
+			{ID: uuid.New(), Type: content.EventContentLoaded, URL: url, Timestamp: now.Add(-time.Minute),
+				Metrics: &content.EventMetrics{LoadTime: 100, RenderTime: 50}},
+			{ID: uuid.New(), Type: content.EventContentLoaded, URL: url, Timestamp: now,
+				Metrics: &content.EventMetrics{LoadTime: 300, RenderTime: 150}},
+			{ID: uuid.New(), Type: content.EventContentError, URL: url, Timestamp: now,
+				Error: &content.EventError{Code: "TIMEOUT"}},
+			{ID: uuid.New(), Type: content.EventContentLoaded, URL: "https://example.com/other", Timestamp: now},
+		},
+	})
+	require.NoError(t, err)
+
+	metrics, err := repo.GetURLMetrics(ctx, url, now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), metrics.LoadCount)
+	assert.Equal(t, int64(1), metrics.ErrorCount)
+	assert.Equal(t, now.Unix(), metrics.LastSeen)
+	assert.Equal(t, int64(200), metrics.AvgLoadTime)
+	assert.Equal(t, int64(100), metrics.AvgRenderTime)
+	assert.InDelta(t, 1.0/3, metrics.ErrorRates["TIMEOUT"], 0.001)
+
+	empty, err := repo.GetURLMetrics(ctx, "https://example.com/unknown", now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, empty.LoadCount)
+	assert.Empty(t, empty.ErrorRates)
+}
+
+func TestGetURLMetricsPrecision(t *testing.T) {
+	repo := NewRepository()
+	ctx := context.Background()
+	url := "https://example.com/menu"
+	now := time.Now()
+
+	// Near the top of the range a float64 sum would both drift and
+	// overflow; 2^53 + 1 is the first integer a float64 cannot hold
+	const large = v1alpha1.Milliseconds(math.MaxInt64 - 1)
+	err := repo.ProcessEvents(ctx, content.EventBatch{
+		DisplayID: uuid.New(),
+		Events: []content.Event{
+			{ID: uuid.New(), Type: content.EventContentLoaded, URL: url, Timestamp: now,
+				Metrics: &content.EventMetrics{LoadTime: large, RenderTime: 1<<53 + 1}},
+			{ID: uuid.New(), Type: content.EventContentLoaded, URL: url, Timestamp: now,
+				Metrics: &content.EventMetrics{LoadTime: large, RenderTime: 1<<53 + 2}},
+		},
+	})
+	require.NoError(t, err)
+
+	metrics, err := repo.GetURLMetrics(ctx, url, now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(large), metrics.AvgLoadTime)
+	// The mean 2^53 + 1.5 rounds away from zero, as in PostgreSQL
+	assert.Equal(t, int64(1<<53+2), metrics.AvgRenderTime)
+}
+
+func TestProcessEventsTracksLastError(t *testing.T) {
+	ctx := context.Background()
+	displays := displaymemory.NewRepository()
+	d, err := display.NewDisplay("lobby-north", display.Location{SiteID: "hq"})
+	require.NoError(t, err)
+	require.NoError(t, displays.Save(ctx, d))
+
+	repo := NewRepository()
+	repo.TrackDisplayErrors(displays.(display.LastErrorStore))
+	url := "https://example.com/menu"
+	now := time.Now()
+	lastError := func() *display.ContentError {
+		stored, err := displays.FindByID(ctx, d.ID)
+		require.NoError(t, err)
+		return stored.LastError
+	}
+
+	require.NoError(t, repo.ProcessEvents(ctx, content.EventBatch{
+		DisplayID: d.ID,
+		Events: []content.Event{{
+			ID: uuid.New(), Type: content.EventContentError, URL: url, Timestamp: now,
+			Error: &content.EventError{Code: "TIMEOUT", Message: "load timed out"},
+		}},
+	}))
+	assert.Equal(t, &display.ContentError{Code: "TIMEOUT", Message: "load timed out", URL: url, At: now}, lastError())
+
+	// A load reported from before the error leaves it in place
+	require.NoError(t, repo.ProcessEvents(ctx, content.EventBatch{
+		DisplayID: d.ID,
+		Events:    []content.Event{{ID: uuid.New(), Type: content.EventContentLoaded, URL: url, Timestamp: now.Add(-time.Second)}},
+	}))
+	assert.NotNil(t, lastError())
+
+	require.NoError(t, repo.ProcessEvents(ctx, content.EventBatch{
+		DisplayID: d.ID,
+		Events:    []content.Event{{ID: uuid.New(), Type: content.EventContentLoaded, URL: url, Timestamp: now.Add(time.Second)}},
+	}))
+	assert.Nil(t, lastError())
+
+	// Saving the display does not resurrect or overwrite the error
+	d.LastError = &display.ContentError{Code: "STALE"}
+	require.NoError(t, displays.Save(ctx, d))
+	assert.Nil(t, lastError())
+}
+
+func TestProcessEventsKeepsLatestPrefetchProgress(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRepository()
+	url := "https://example.com/promo.mp4"
+	first, second := uuid.New(), uuid.New()
+	now := time.Now()
+	progress := func(displayID uuid.UUID, at time.Time, downloaded int64) content.EventBatch {
+		return content.EventBatch{
+			DisplayID: displayID,
+			Events: []content.Event{{
+				ID: uuid.New(), Type: content.EventContentPrefetchProgress, URL: url, Timestamp: at,
+				Metrics: &content.EventMetrics{BytesDownloaded: downloaded, BytesTotal: 1000},
+			}},
+		}
+	}
+	latest := func(displayID uuid.UUID) content.PrefetchProgress {
+		all, err := repo.PrefetchProgress(ctx, url)
+		require.NoError(t, err)
+		for _, p := range all {
+			if p.DisplayID == displayID {
+				return p
+			}
+		}
+		t.Fatalf("no progress recorded for %s", displayID)
+		return content.PrefetchProgress{}
+	}
+
+	require.NoError(t, repo.ProcessEvents(ctx, progress(first, now, 600)))
+	assert.Equal(t, int64(600), latest(first).BytesDownloaded)
+
+	// Progress reported earlier but delivered later is not kept
+	require.NoError(t, repo.ProcessEvents(ctx, progress(first, now.Add(-time.Second), 200)))
+	assert.Equal(t, int64(600), latest(first).BytesDownloaded)
+	assert.Equal(t, now, latest(first).ReportedAt)
+
+	// Within one batch the order of the events does not matter either
+	batch := progress(first, now.Add(2*time.Second), 1000)
+	batch.Events = append(batch.Events, progress(first, now.Add(time.Second), 800).Events...)
+	require.NoError(t, repo.ProcessEvents(ctx, batch))
+	assert.Equal(t, int64(1000), latest(first).BytesDownloaded)
+	assert.True(t, latest(first).Complete())
+
+	// Progress at the same instant replaces what is kept
+	require.NoError(t, repo.ProcessEvents(ctx, progress(first, now.Add(2*time.Second), 900)))
+	assert.Equal(t, int64(900), latest(first).BytesDownloaded)
+
+	// Each display's progress is kept separately, and other URLs are not listed
+	require.NoError(t, repo.ProcessEvents(ctx, progress(second, now.Add(-time.Hour), 100)))
+	assert.Equal(t, int64(100), latest(second).BytesDownloaded)
+	assert.Equal(t, int64(900), latest(first).BytesDownloaded)
+	other, err := repo.PrefetchProgress(ctx, "https://example.com/other.mp4")
+	require.NoError(t, err)
+	assert.Empty(t, other)
+}
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// DeleteEventsBefore deletes up to limit of the oldest events reported
+// before cutoff, passing them to archive first when it is non-nil. An
+// archive error keeps them.
+func (r *Repository) DeleteEventsBefore(ctx context.Context, cutoff time.Time, limit int, archive func([]content.Event) error) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var expired []int
+	for i, event := range r.events {
+		if event.Timestamp.Before(cutoff) {
+			expired = append(expired, i)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		return r.events[expired[i]].Cursor().Before(r.events[expired[j]].Cursor())
+	})
+	if limit > 0 && len(expired) > limit {
+		expired = expired[:limit]
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	deleted := make([]content.Event, 0, len(expired))
+	remove := make(map[int]bool, len(expired))
+	for _, i := range expired {
+		deleted = append(deleted, r.events[i])
+		remove[i] = true
+	}
+	if archive != nil {
+		if err := archive(deleted); err != nil {
+			return 0, err
+		}
+	}
+
+	kept := r.events[:0]
+	for i, event := range r.events {
+		if !remove[i] {
+			kept = append(kept, event)
+		}
+	}
+	r.events = kept
+	return len(deleted), nil
+}
+
+// EventStorage describes the events held, which are not partitioned
+func (r *Repository) EventStorage(ctx context.Context) (*content.EventStorage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	storage := &content.EventStorage{
+		Tables: []content.EventPartition{{Name: "content_events", Rows: int64(len(r.events))}},
+	}
+	for _, event := range r.events {
+		if storage.OldestEvent == nil || event.Timestamp.Before(*storage.OldestEvent) {
+			oldest := event.Timestamp
+			storage.OldestEvent = &oldest
+		}
+	}
+	return storage, nil
+}
+
+// EnsureEventPartitions creates nothing, since events held in memory are
+// not partitioned
+func (r *Repository) EnsureEventPartitions(ctx context.Context, through time.Time) ([]string, error) {
+	return nil, nil
+}
+
+// DropEventPartition always fails, since events held in memory are not
+// partitioned
+func (r *Repository) DropEventPartition(ctx context.Context, name string) error {
+	const op = "ContentRepository.DropEventPartition"
+	return werrors.NewError("NOT_FOUND", "Event partition not found: "+name, op, werrors.ErrNotFound)
+}
@@ -0,0 +1,88 @@
+package content_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	"github.com/wrale/wrale-signage/internal/wsignd/content/memory"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// passingValidator reports every URL as reachable
+type passingValidator struct{}
+
+func (passingValidator) Validate(ctx context.Context, url string) *v1alpha1.ContentValidationReport {
+	return &v1alpha1.ContentValidationReport{ValidatedAt: time.Now(), Passed: true, HTTPStatus: 200}
+}
+
+func TestService_SourceNamesIgnoreCase(t *testing.T) {
+	ctx := context.Background()
+	service := content.NewService(memory.NewRepository(), passingValidator{}, nil, nil, nil, nil)
+
+	newSource := func(name, url string) *v1alpha1.ContentSource {
+		return &v1alpha1.ContentSource{
+			ObjectMeta: v1alpha1.ObjectMeta{Name: name},
+			Spec:       v1alpha1.ContentSourceSpec{URL: url, Type: "menu"},
+		}
+	}
+
+	created, err := service.CreateContent(ctx, newSource("Menus", "https://menu.example.com"), false)
+	require.NoError(t, err)
+	assert.Equal(t, "menus", created.Name, "names are stored in lower case")
+
+	t.Run("create conflicts with any case", func(t *testing.T) {
+		_, err := service.CreateContent(ctx, newSource("MENUS", "https://other.example.com"), false)
+		require.Error(t, err)
+		assert.True(t, werrors.IsConflict(err))
+
+		var exists *content.SourceExistsError
+		require.ErrorAs(t, err, &exists)
+		assert.Equal(t, "menus", exists.Existing.Name)
+		assert.Equal(t, "https://menu.example.com", exists.Existing.Spec.URL)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		got, err := service.GetContent(ctx, "MeNuS")
+		require.NoError(t, err)
+		assert.Equal(t, "menus", got.Name)
+	})
+
+	t.Run("get by names and resolve", func(t *testing.T) {
+		sources, err := service.GetContentByNames(ctx, []string{"Menus"})
+		require.NoError(t, err)
+		require.Len(t, sources, 1)
+
+		resolved, err := service.ResolveSources(ctx, []string{"MENUS"})
+		require.NoError(t, err)
+		require.Contains(t, resolved, "MENUS", "resolved sources are keyed by the names given")
+		assert.Equal(t, "menus", resolved["MENUS"].Name)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		url := "https://menu.example.com/v2"
+		result, err := service.UpdateContent(ctx, "Menus", &v1alpha1.ContentSourceUpdate{URL: &url}, false)
+		require.NoError(t, err)
+		assert.Equal(t, "menus", result.Name)
+		assert.Equal(t, url, result.Spec.URL)
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		got, err := service.ValidateSource(ctx, "MENUS")
+		require.NoError(t, err)
+		assert.True(t, got.Status.IsHealthy)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		deletion, err := service.DeleteContent(ctx, "mENUs", false)
+		require.NoError(t, err)
+		assert.Equal(t, "menus", deletion.Name)
+		_, err = service.GetContent(ctx, "menus")
+		assert.True(t, werrors.IsNotFound(err))
+	})
+}
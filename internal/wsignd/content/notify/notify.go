@@ -0,0 +1,162 @@
+// Package notify connects content source updates to the displays showing
+// them. It finds the displays assigned content from a source and sends each
+// connected one a reload control message.
+package notify
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/assignment"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/outbox"
+)
+
+// Sender delivers a control message to a display's open connection. It
+// fails for displays that are not connected, unless it is an outbox that
+// queues the message for them instead.
+type Sender interface {
+	SendControlMessage(displayID uuid.UUID, message *v1alpha1.ControlMessage) error
+}
+
+// Notifier implements content.Notifier
+type Notifier struct {
+	assignments assignment.Service
+	displays    display.Service
+	sender      Sender
+	logger      *slog.Logger
+}
+
+// New creates a Notifier that resolves assignments to displays and sends
+// them reloads through sender
+func New(assignments assignment.Service, displays display.Service, sender Sender, logger *slog.Logger) *Notifier {
+	return &Notifier{
+		assignments: assignments,
+		displays:    displays,
+		sender:      sender,
+		logger:      logger,
+	}
+}
+
+// NotifySourceUpdated sends a reload to every connected display selected by
+// a current assignment of source. Each display is reloaded once however
+// many assignments select it. Reloads queued for disconnected displays are
+// not counted. Lookup failures reach no displays and are
+// logged, since the update itself has already been saved.
+func (n *Notifier) NotifySourceUpdated(ctx context.Context, source *v1alpha1.ContentSource) int {
+	assignments, err := n.assignments.List(ctx, assignment.Filter{Source: source.Name})
+	if err != nil {
+		n.logger.Error("failed to list assignments for content notification",
+			"error", err,
+			"source", source.Name,
+		)
+		return 0
+	}
+
+	now := time.Now()
+	var selectors []v1alpha1.DisplaySelector
+	for _, a := range assignments {
+		if !assignment.Current(&a, now) {
+			continue
+		}
+		selectors = append(selectors, a.DisplaySelector)
+	}
+	if len(selectors) == 0 {
+		return 0
+	}
+
+	displays, err := n.displays.List(ctx, display.DisplayFilter{})
+	if err != nil {
+		n.logger.Error("failed to list displays for content notification",
+			"error", err,
+			"source", source.Name,
+		)
+		return 0
+	}
+
+	msg := &v1alpha1.ControlMessage{
+		TypeMeta:  v1alpha1.TypeMeta{Kind: "ControlMessage", APIVersion: "v1alpha1"},
+		Type:      v1alpha1.ControlMessageReload,
+		Timestamp: now,
+	}
+	notified, queued := 0, 0
+	for _, d := range displays {
+		if !selected(selectors, d) {
+			continue
+		}
+		if err := n.sender.SendControlMessage(d.ID, msg); err != nil {
+			if errors.Is(err, outbox.ErrQueued) {
+				queued++
+				continue
+			}
+			n.logger.Debug("display not notified of content update",
+				"error", err,
+				"displayId", d.ID,
+				"source", source.Name,
+			)
+			continue
+		}
+		notified++
+	}
+
+	n.logger.Info("notified displays of content update",
+		"source", source.Name,
+		"notified", notified,
+		"queued", queued,
+	)
+	return notified
+}
+
+// AssignedDisplays lists the displays selected by an assignment pointing at
+// url that has not expired. Assignments yet to start count, so that the
+// displays they will reach can be checked before they do.
+func (n *Notifier) AssignedDisplays(ctx context.Context, url string) ([]uuid.UUID, error) {
+	assignments, err := n.assignments.List(ctx, assignment.Filter{})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var selectors []v1alpha1.DisplaySelector
+	for _, a := range assignments {
+		if a.ContentURL != url || (a.ValidUntil != nil && !now.Before(*a.ValidUntil)) {
+			continue
+		}
+		selectors = append(selectors, a.DisplaySelector)
+	}
+	if len(selectors) == 0 {
+		return nil, nil
+	}
+
+	displays, err := n.displays.List(ctx, display.DisplayFilter{})
+	if err != nil {
+		return nil, err
+	}
+	var ids []uuid.UUID
+	for _, d := range displays {
+		if selected(selectors, d) {
+			ids = append(ids, d.ID)
+		}
+	}
+	return ids, nil
+}
+
+// selected reports whether any of selectors picks d
+func selected(selectors []v1alpha1.DisplaySelector, d *display.Display) bool {
+	location := v1alpha1.DisplayLocation{
+		SiteID:   d.Location.SiteID,
+		Zone:     d.Location.Zone,
+		Position: d.Location.Position,
+	}
+	for _, s := range selectors {
+		if s.Selects(d.ID, location, d.Properties) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package notify_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/assignment"
+	assignmentmemory "github.com/wrale/wrale-signage/internal/wsignd/assignment/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/content/notify"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	displaymemory "github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+)
+
+type discardPublisher struct{}
+
+func (discardPublisher) Publish(ctx context.Context, event display.Event) error { return nil }
+
+// recordingSender records reloads and fails for displays that are offline
+type recordingSender struct {
+	offline map[uuid.UUID]bool
+	sent    []uuid.UUID
+}
+
+func (s *recordingSender) SendControlMessage(displayID uuid.UUID, message *v1alpha1.ControlMessage) error {
+	if s.offline[displayID] {
+		return errors.New("display not connected")
+	}
+	if message.Type != v1alpha1.ControlMessageReload {
+		return errors.New("unexpected message type")
+	}
+	s.sent = append(s.sent, displayID)
+	return nil
+}
+
+func TestNotifySourceUpdated(t *testing.T) {
+	ctx := context.Background()
+	displayRepo := displaymemory.NewRepository()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	displays := display.NewService(displayRepo, discardPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, logger)
+	assignments := assignment.NewService(assignmentmemory.NewRepository())
+
+	newDisplay := func(name, site, zone string) *display.Display {
+		d, err := display.NewDisplay(name, display.Location{SiteID: site, Zone: zone})
+		require.NoError(t, err)
+		require.NoError(t, displayRepo.Save(ctx, d))
+		return d
+	}
+	cafeteria := newDisplay("cafeteria-1", "hq", "cafeteria")
+	cafeteriaOffline := newDisplay("cafeteria-2", "hq", "cafeteria")
+	lobby := newDisplay("lobby-1", "hq", "lobby")
+	annex := newDisplay("annex-1", "annex", "lobby")
+
+	past := time.Now().Add(-2 * time.Hour)
+	expired := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	assign := func(source string, selector v1alpha1.DisplaySelector, from, until *time.Time) {
+		_, err := assignments.Create(ctx, &v1alpha1.ContentAssignment{
+			Source:          source,
+			DisplaySelector: selector,
+			ContentURL:      "https://example.com/" + source,
+			ValidFrom:       from,
+			ValidUntil:      until,
+		})
+		require.NoError(t, err)
+	}
+	// Both assignments select the cafeteria; it is still reloaded once
+	assign("menus", v1alpha1.DisplaySelector{SiteID: "hq", Zone: "cafeteria"}, nil, nil)
+	assign("menus", v1alpha1.DisplaySelector{SiteID: "hq"}, nil, nil)
+	assign("menus", v1alpha1.DisplaySelector{SiteID: "annex"}, &past, &expired)
+	assign("menus", v1alpha1.DisplaySelector{SiteID: "annex"}, &future, nil)
+	assign("news", v1alpha1.DisplaySelector{SiteID: "annex"}, nil, nil)
+
+	sender := &recordingSender{offline: map[uuid.UUID]bool{cafeteriaOffline.ID: true}}
+	n := notify.New(assignments, displays, sender, logger)
+
+	notified := n.NotifySourceUpdated(ctx, &v1alpha1.ContentSource{ObjectMeta: v1alpha1.ObjectMeta{Name: "menus"}})
+	assert.Equal(t, 2, notified)
+
+	want := []uuid.UUID{cafeteria.ID, lobby.ID}
+	sortIDs := func(ids []uuid.UUID) {
+		sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+	}
+	sortIDs(want)
+	sortIDs(sender.sent)
+	assert.Equal(t, want, sender.sent)
+	assert.NotContains(t, sender.sent, annex.ID)
+
+	t.Run("sources without assignments reach no displays", func(t *testing.T) {
+		sender.sent = nil
+		notified := n.NotifySourceUpdated(ctx, &v1alpha1.ContentSource{ObjectMeta: v1alpha1.ObjectMeta{Name: "weather"}})
+		assert.Zero(t, notified)
+		assert.Empty(t, sender.sent)
+	})
+
+	t.Run("assigned displays include assignments yet to start", func(t *testing.T) {
+		ids, err := n.AssignedDisplays(ctx, "https://example.com/menus")
+		require.NoError(t, err)
+		want := []uuid.UUID{cafeteria.ID, cafeteriaOffline.ID, lobby.ID, annex.ID}
+		sortIDs(want)
+		sortIDs(ids)
+		assert.Equal(t, want, ids)
+
+		assign("promo", v1alpha1.DisplaySelector{SiteID: "hq"}, &past, &expired)
+		ids, err = n.AssignedDisplays(ctx, "https://example.com/promo")
+		require.NoError(t, err)
+		assert.Empty(t, ids, "expired assignments target no displays")
+	})
+}
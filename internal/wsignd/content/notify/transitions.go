@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/assignment"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/outbox"
+)
+
+// Transitions reloads displays when a scheduled assignment's window opens
+// or closes, so they pick up the content now in effect without waiting for
+// their next poll. It remembers whether each assignment was active when it
+// last checked; an assignment is first seen without a reload.
+type Transitions struct {
+	assignments assignment.Service
+	displays    display.Service
+	sender      Sender
+	tz          assignment.Timezones
+	jitter      time.Duration
+	logger      *slog.Logger
+
+	// now and after are replaced in tests
+	now   func() time.Time
+	after func(d time.Duration, f func())
+
+	mu     sync.Mutex
+	active map[uuid.UUID]bool
+}
+
+// NewTransitions creates a Transitions that reads schedules in the zones tz
+// gives and spreads each display's reload over up to jitter, so that a
+// site's displays do not all fetch content at the same instant
+func NewTransitions(assignments assignment.Service, displays display.Service, sender Sender, tz assignment.Timezones, jitter time.Duration, logger *slog.Logger) *Transitions {
+	return &Transitions{
+		assignments: assignments,
+		displays:    displays,
+		sender:      sender,
+		tz:          tz,
+		jitter:      jitter,
+		logger:      logger,
+		now:         time.Now,
+		after: func(d time.Duration, f func()) {
+			time.AfterFunc(d, f)
+		},
+		active: make(map[uuid.UUID]bool),
+	}
+}
+
+// Run checks for transitions at the current time, for use with the
+// scheduler
+func (t *Transitions) Run(ctx context.Context) error {
+	_, err := t.Check(ctx, t.now())
+	return err
+}
+
+// Check compares each scheduled assignment's state at now with its state
+// at the previous check and reloads the displays selected by those that
+// changed. It returns the number of displays reloads were sent or
+// scheduled for.
+func (t *Transitions) Check(ctx context.Context, now time.Time) (int, error) {
+	assignments, err := t.assignments.List(ctx, assignment.Filter{})
+	if err != nil {
+		return 0, err
+	}
+
+	t.mu.Lock()
+	seen := make(map[uuid.UUID]bool, len(assignments))
+	var selectors []v1alpha1.DisplaySelector
+	for i := range assignments {
+		a := &assignments[i]
+		if a.Schedule == nil {
+			continue
+		}
+		active := assignment.Active(a, now, t.tz)
+		if was, known := t.active[a.ID]; known && was != active {
+			selectors = append(selectors, a.DisplaySelector)
+			t.logger.Info("assignment schedule transition",
+				"assignment", a.Name,
+				"active", active,
+			)
+		}
+		seen[a.ID] = active
+	}
+	t.active = seen
+	t.mu.Unlock()
+
+	if len(selectors) == 0 {
+		return 0, nil
+	}
+
+	displays, err := t.displays.List(ctx, display.DisplayFilter{})
+	if err != nil {
+		return 0, err
+	}
+
+	msg := &v1alpha1.ControlMessage{
+		TypeMeta:  v1alpha1.TypeMeta{Kind: "ControlMessage", APIVersion: "v1alpha1"},
+		Type:      v1alpha1.ControlMessageReload,
+		Timestamp: now,
+	}
+	reloaded := 0
+	for _, d := range displays {
+		if !selected(selectors, d) {
+			continue
+		}
+		reloaded++
+		id := d.ID
+		if t.jitter <= 0 {
+			t.reload(id, msg)
+			continue
+		}
+		t.after(time.Duration(rand.Int63n(int64(t.jitter))), func() {
+			t.reload(id, msg)
+		})
+	}
+	return reloaded, nil
+}
+
+// reload sends msg to a display, logging displays that could not be reached
+func (t *Transitions) reload(displayID uuid.UUID, msg *v1alpha1.ControlMessage) {
+	if err := t.sender.SendControlMessage(displayID, msg); err != nil && !errors.Is(err, outbox.ErrQueued) {
+		t.logger.Debug("display not reloaded at schedule transition",
+			"error", err,
+			"displayId", displayID,
+		)
+	}
+}
@@ -0,0 +1,86 @@
+package notify_test
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/assignment"
+	assignmentmemory "github.com/wrale/wrale-signage/internal/wsignd/assignment/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/content/notify"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	displaymemory "github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+)
+
+func TestTransitions(t *testing.T) {
+	ctx := context.Background()
+	displayRepo := displaymemory.NewRepository()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	displays := display.NewService(displayRepo, discardPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, logger)
+	assignments := assignment.NewService(assignmentmemory.NewRepository())
+
+	newDisplay := func(name, zone string) *display.Display {
+		d, err := display.NewDisplay(name, display.Location{SiteID: "hq", Zone: zone})
+		require.NoError(t, err)
+		require.NoError(t, displayRepo.Save(ctx, d))
+		return d
+	}
+	cafeteria := newDisplay("cafeteria-1", "cafeteria")
+	newDisplay("lobby-1", "lobby")
+
+	assign := func(zone string, schedule *v1alpha1.RecurringSchedule) {
+		_, err := assignments.Create(ctx, &v1alpha1.ContentAssignment{
+			Source:          "menus",
+			DisplaySelector: v1alpha1.DisplaySelector{SiteID: "hq", Zone: zone},
+			ContentURL:      "https://example.com/" + zone,
+			Schedule:        schedule,
+		})
+		require.NoError(t, err)
+	}
+	assign("cafeteria", &v1alpha1.RecurringSchedule{
+		Timezone: "America/New_York",
+		Windows:  []v1alpha1.ScheduleWindow{{TimeOfDay: v1alpha1.TimeRange{Start: "06:00", End: "10:30"}}},
+	})
+	// Unscheduled assignments never transition
+	assign("lobby", nil)
+
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 6, 3, hour, minute, 0, 0, ny)
+	}
+
+	sender := &recordingSender{}
+	transitions := notify.NewTransitions(assignments, displays, sender, assignment.Timezones{}, 0, logger)
+
+	// The first check only learns each assignment's state
+	reloaded, err := transitions.Check(ctx, at(6, 30))
+	require.NoError(t, err)
+	assert.Zero(t, reloaded)
+
+	reloaded, err = transitions.Check(ctx, at(10, 29))
+	require.NoError(t, err)
+	assert.Zero(t, reloaded)
+
+	reloaded, err = transitions.Check(ctx, at(10, 30))
+	require.NoError(t, err)
+	assert.Equal(t, 1, reloaded)
+	assert.Equal(t, []uuid.UUID{cafeteria.ID}, sender.sent)
+
+	reloaded, err = transitions.Check(ctx, at(11, 0))
+	require.NoError(t, err)
+	assert.Zero(t, reloaded)
+
+	sender.sent = nil
+	reloaded, err = transitions.Check(ctx, at(6, 0).AddDate(0, 0, 1))
+	require.NoError(t, err)
+	assert.Equal(t, 1, reloaded)
+	assert.Equal(t, []uuid.UUID{cafeteria.ID}, sender.sent)
+}
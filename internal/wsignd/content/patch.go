@@ -0,0 +1,124 @@
+package content
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/jsonpatch"
+)
+
+// PatchContent applies a JSON Patch to the content source as stored and
+// saves the parts of its spec the patch changed as an update conditional on
+// the version the patch was applied to. The name, type, metadata and status
+// cannot be patched; a test operation is the way to make a patch
+// conditional on them, such as on /status/version.
+func (s *contentService) PatchContent(ctx context.Context, name string, patch jsonpatch.Patch, strict bool) (*v1alpha1.ContentSourceUpdateResult, error) {
+	const op = "ContentService.PatchContent"
+
+	source, err := s.GetContent(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := json.Marshal(source)
+	if err != nil {
+		return nil, werrors.NewError("PATCH_FAILED", "Failed to encode content source", op, err)
+	}
+
+	doc, err = patch.Apply(doc)
+	switch {
+	case errors.Is(err, jsonpatch.ErrTestFailed):
+		return nil, werrors.NewError("PATCH_TEST_FAILED", err.Error(), op, werrors.ErrConflict)
+	case err != nil:
+		return nil, werrors.NewError("INVALID_INPUT", fmt.Sprintf("invalid patch: %v", err), op, werrors.ErrInvalidInput)
+	}
+
+	var patched v1alpha1.ContentSource
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&patched); err != nil {
+		return nil, werrors.NewError("INVALID_INPUT", fmt.Sprintf("patched content source is invalid: %v", err), op, werrors.ErrInvalidInput)
+	}
+	if err := checkReadOnly(source, &patched); err != nil {
+		return nil, werrors.NewError("INVALID_INPUT", err.Error(), op, err)
+	}
+
+	update := specUpdate(source.Spec, patched.Spec)
+	update.Version = source.Status.Version
+	return s.UpdateContent(ctx, source.Name, update, strict)
+}
+
+// checkReadOnly rejects changes to the fields of a content source that a
+// patch may not change
+func checkReadOnly(source, patched *v1alpha1.ContentSource) error {
+	verr := &werrors.ValidationError{}
+	fields := []struct {
+		name          string
+		before, after interface{}
+	}{
+		{"kind", source.Kind, patched.Kind},
+		{"apiVersion", source.APIVersion, patched.APIVersion},
+		{"metadata.id", source.ID, patched.ID},
+		{"metadata.name", source.Name, patched.Name},
+		{"metadata.createdAt", source.CreatedAt, patched.CreatedAt},
+		{"metadata.updatedAt", source.UpdatedAt, patched.UpdatedAt},
+		{"spec.type", source.Spec.Type, patched.Spec.Type},
+		{"status", source.Status, patched.Status},
+	}
+	for _, f := range fields {
+		// Compared as JSON, which is what the patch saw, so that times
+		// read back from it compare equal
+		before, err := json.Marshal(f.before)
+		if err != nil {
+			return err
+		}
+		after, err := json.Marshal(f.after)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(before, after) {
+			verr.Invalid(f.name, "cannot be changed")
+		}
+	}
+	return verr.Err()
+}
+
+// specUpdate returns the update turning the spec before into the spec
+// after. Properties missing from after are removed.
+func specUpdate(before, after v1alpha1.ContentSourceSpec) *v1alpha1.ContentSourceUpdate {
+	update := &v1alpha1.ContentSourceUpdate{}
+	if after.URL != before.URL {
+		update.URL = &after.URL
+	}
+	if !slices.Equal(after.AllowedPaths, before.AllowedPaths) {
+		update.AllowedPaths = &after.AllowedPaths
+	}
+	if !slices.Equal(after.Tags, before.Tags) {
+		update.Tags = &after.Tags
+	}
+	if !slices.Equal(after.Fallbacks, before.Fallbacks) {
+		update.Fallbacks = &after.Fallbacks
+	}
+	for k, v := range after.Properties {
+		if before.Properties[k] != v {
+			if update.Properties == nil {
+				update.Properties = make(map[string]string)
+			}
+			update.Properties[k] = v
+		}
+	}
+	for k := range before.Properties {
+		if _, ok := after.Properties[k]; !ok {
+			if update.Properties == nil {
+				update.Properties = make(map[string]string)
+			}
+			update.Properties[k] = ""
+		}
+	}
+	return update
+}
@@ -0,0 +1,110 @@
+package content_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	"github.com/wrale/wrale-signage/internal/wsignd/content/memory"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/jsonpatch"
+)
+
+func TestService_PatchContent(t *testing.T) {
+	ctx := context.Background()
+	service := content.NewService(memory.NewRepository(), passingValidator{}, nil, nil, nil, nil)
+
+	_, err := service.CreateContent(ctx, &v1alpha1.ContentSource{
+		ObjectMeta: v1alpha1.ObjectMeta{Name: "menus"},
+		Spec: v1alpha1.ContentSourceSpec{
+			URL:          "https://example.com/menus",
+			Type:         "menu",
+			Properties:   map[string]string{"theme": "dark", "lang": "en"},
+			AllowedPaths: []string{"/breakfast"},
+			Tags:         []string{"food", "seasonal"},
+		},
+	}, false)
+	require.NoError(t, err)
+
+	patch := func(ops string) (*v1alpha1.ContentSourceUpdateResult, error) {
+		t.Helper()
+		var p jsonpatch.Patch
+		require.NoError(t, json.Unmarshal([]byte(ops), &p))
+		return service.PatchContent(ctx, "Menus", p, false)
+	}
+
+	t.Run("add, replace and remove", func(t *testing.T) {
+		result, err := patch(`[
+			{"op": "add", "path": "/spec/allowedPaths/-", "value": "/lunch"},
+			{"op": "replace", "path": "/spec/properties/theme", "value": "light"},
+			{"op": "remove", "path": "/spec/properties/lang"},
+			{"op": "remove", "path": "/spec/tags/1"}
+		]`)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/breakfast", "/lunch"}, result.Spec.AllowedPaths)
+		assert.Equal(t, map[string]string{"theme": "light"}, result.Spec.Properties)
+		assert.Equal(t, []string{"food"}, result.Spec.Tags)
+		assert.Equal(t, "https://example.com/menus", result.Spec.URL)
+		assert.Equal(t, 2, result.Status.Version)
+	})
+
+	t.Run("a failing version test conflicts", func(t *testing.T) {
+		_, err := patch(`[
+			{"op": "test", "path": "/status/version", "value": 1},
+			{"op": "replace", "path": "/spec/url", "value": "https://example.com/v2"}
+		]`)
+		require.Error(t, err)
+		assert.True(t, werrors.IsConflict(err))
+
+		got, err := service.GetContent(ctx, "menus")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/menus", got.Spec.URL, "nothing is saved")
+		assert.Equal(t, 2, got.Status.Version)
+	})
+
+	t.Run("a passing version test applies", func(t *testing.T) {
+		result, err := patch(`[
+			{"op": "test", "path": "/status/version", "value": 2},
+			{"op": "replace", "path": "/spec/url", "value": "https://example.com/v2"}
+		]`)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/v2", result.Spec.URL)
+		assert.Equal(t, 3, result.Status.Version)
+	})
+
+	t.Run("read-only fields are rejected", func(t *testing.T) {
+		for _, ops := range []string{
+			`[{"op": "replace", "path": "/metadata/name", "value": "lunch"}]`,
+			`[{"op": "replace", "path": "/spec/type", "value": "welcome"}]`,
+			`[{"op": "replace", "path": "/status/version", "value": 9}]`,
+		} {
+			_, err := patch(ops)
+			require.Error(t, err, ops)
+			assert.True(t, werrors.IsInvalidInput(err), ops)
+			assert.ErrorContains(t, err, "cannot be changed", ops)
+		}
+	})
+
+	t.Run("invalid results are rejected", func(t *testing.T) {
+		_, err := patch(`[{"op": "add", "path": "/spec/color", "value": "red"}]`)
+		assert.True(t, werrors.IsInvalidInput(err))
+		assert.ErrorContains(t, err, `unknown field "color"`)
+
+		_, err = patch(`[{"op": "remove", "path": "/spec/properties/missing"}]`)
+		assert.True(t, werrors.IsInvalidInput(err))
+		assert.ErrorContains(t, err, "no value at /spec/properties/missing")
+
+		_, err = patch(`[{"op": "replace", "path": "/spec/url", "value": ""}]`)
+		assert.True(t, werrors.IsInvalidInput(err), "the patched spec is validated")
+	})
+
+	t.Run("missing sources are not found", func(t *testing.T) {
+		_, err := service.PatchContent(ctx, "lunch", nil, false)
+		assert.True(t, werrors.IsNotFound(err))
+	})
+}
@@ -0,0 +1,27 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/assignment"
+	assignmentpostgres "github.com/wrale/wrale-signage/internal/wsignd/assignment/postgres"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	"github.com/wrale/wrale-signage/internal/wsignd/content/repotest"
+	"github.com/wrale/wrale-signage/internal/wsignd/testutil"
+)
+
+func TestRepositoryConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) content.Repository {
+		db, cleanup := testutil.SetupTestDB(t)
+		t.Cleanup(cleanup)
+		return NewRepository(db)
+	})
+}
+
+func TestReferenceConformance(t *testing.T) {
+	repotest.RunReferences(t, func(t *testing.T) (content.Repository, assignment.Repository) {
+		db, cleanup := testutil.SetupTestDB(t)
+		t.Cleanup(cleanup)
+		return NewRepository(db), assignmentpostgres.NewRepository(db)
+	})
+}
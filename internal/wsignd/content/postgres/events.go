@@ -0,0 +1,146 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	"github.com/wrale/wrale-signage/internal/wsignd/database"
+)
+
+const eventColumns = `id, display_id, type, url, timestamp, error, metrics, context`
+
+// scanEvent reads a content_events row selected with eventColumns. Error,
+// metrics and context stored as JSON null are left unset.
+func scanEvent(rows *sql.Rows) (content.Event, error) {
+	var event content.Event
+	var errorJSON, metricsJSON, contextJSON []byte
+
+	err := rows.Scan(
+		&event.ID,
+		&event.DisplayID,
+		&event.Type,
+		&event.URL,
+		&event.Timestamp,
+		&errorJSON,
+		&metricsJSON,
+		&contextJSON,
+	)
+	if err != nil {
+		return event, err
+	}
+
+	if present(errorJSON) {
+		event.Error = &content.EventError{}
+		if err := json.Unmarshal(errorJSON, event.Error); err != nil {
+			return event, err
+		}
+	}
+	if present(metricsJSON) {
+		event.Metrics = &content.EventMetrics{}
+		if err := json.Unmarshal(metricsJSON, event.Metrics); err != nil {
+			return event, err
+		}
+	}
+	if present(contextJSON) {
+		if err := json.Unmarshal(contextJSON, &event.Context); err != nil {
+			return event, err
+		}
+	}
+	return event, nil
+}
+
+// present reports whether a JSON column holds a value worth decoding
+func present(data []byte) bool {
+	s := string(data)
+	return s != "" && s != "null" && s != "{}"
+}
+
+// ForEachEvent streams the events the query selects, ordered by timestamp
+// and then ID. Rows are read from the connection as fn consumes them, and
+// cancelling ctx cancels the query.
+func (r *repository) ForEachEvent(ctx context.Context, query content.EventQuery, fn func(content.Event) error) error {
+	const op = "ContentRepository.ForEachEvent"
+
+	args := []interface{}{query.Since, query.Until}
+	conditions := []string{"timestamp >= $1", "timestamp < $2"}
+	if query.DisplayID != uuid.Nil {
+		args = append(args, query.DisplayID)
+		conditions = append(conditions, fmt.Sprintf("display_id = $%d", len(args)))
+	}
+	if query.URL != "" {
+		args = append(args, query.URL)
+		conditions = append(conditions, fmt.Sprintf("url = $%d", len(args)))
+	}
+	if query.Type != "" {
+		args = append(args, query.Type)
+		conditions = append(conditions, fmt.Sprintf("type = $%d", len(args)))
+	}
+	if query.After != nil {
+		args = append(args, query.After.Timestamp, query.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(timestamp, id) > ($%d, $%d)", len(args)-1, len(args)))
+	}
+	limit := ""
+	if query.Limit > 0 {
+		args = append(args, query.Limit)
+		limit = fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := r.reader.QueryContext(ctx,
+		"SELECT "+eventColumns+" FROM content_events WHERE "+strings.Join(conditions, " AND ")+
+			" ORDER BY timestamp, id"+limit,
+		args...)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return database.MapError(err, op)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return database.MapError(err, op)
+	}
+	return nil
+}
+
+// PrefetchProgress lists the latest progress each display reported for
+// url, ordered by display ID
+func (r *repository) PrefetchProgress(ctx context.Context, url string) ([]content.PrefetchProgress, error) {
+	const op = "ContentRepository.PrefetchProgress"
+
+	rows, err := r.reader.QueryContext(ctx, `
+		SELECT display_id, url, bytes_downloaded, bytes_total, reported_at
+		FROM content_prefetch_progress
+		WHERE url = $1
+		ORDER BY display_id
+	`, url)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+	defer rows.Close()
+
+	var progress []content.PrefetchProgress
+	for rows.Next() {
+		var p content.PrefetchProgress
+		if err := rows.Scan(&p.DisplayID, &p.URL, &p.BytesDownloaded, &p.BytesTotal, &p.ReportedAt); err != nil {
+			return nil, database.MapError(err, op)
+		}
+		progress = append(progress, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, database.MapError(err, op)
+	}
+	return progress, nil
+}
@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+)
+
+// metricsAggregator computes URL metrics from stored events over a
+// sliding window
+type metricsAggregator struct {
+	repo   *repository
+	window time.Duration
+}
+
+// NewMetricsAggregator creates a MetricsAggregator backed by the event store
+func NewMetricsAggregator(repo *repository, window time.Duration) content.MetricsAggregator {
+	return &metricsAggregator{repo: repo, window: window}
+}
+
+// RecordMetrics is a no-op; metrics are derived from events saved by ProcessEvents
+func (a *metricsAggregator) RecordMetrics(ctx context.Context, event content.Event) error {
+	return nil
+}
+
+func (a *metricsAggregator) GetURLMetrics(ctx context.Context, url string) (*content.URLMetrics, error) {
+	return a.repo.GetURLMetrics(ctx, url, time.Now().Add(-a.window))
+}
@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	"github.com/wrale/wrale-signage/internal/wsignd/database"
+	"github.com/wrale/wrale-signage/internal/wsignd/testutil"
+)
+
+func TestReadsUseReader(t *testing.T) {
+	ctx := context.Background()
+	primary, writes := testutil.RecordingDB(t)
+	reader, reads := testutil.RecordingDB(t)
+	repo := NewRepository(primary, database.WithReader(reader))
+	metrics := NewMetricsAggregator(repo, time.Hour)
+
+	// Every statement fails on a recording database; only where it was
+	// sent matters here
+	_, _ = repo.ListContent(ctx)
+	_, _ = repo.ListContentByType(ctx, "menu")
+	_, _ = repo.ListContentByTags(ctx, []string{"lobby"})
+	_, _ = repo.GetDisplayEvents(ctx, uuid.New(), time.Now())
+	_ = repo.ForEachEvent(ctx, content.EventQuery{}, func(content.Event) error { return nil })
+	_, _ = metrics.GetURLMetrics(ctx, "https://example.com/menus")
+	assert.Len(t, reads.Queries(), 6)
+	assert.Empty(t, writes.Queries())
+
+	// Sources just created must be found by name
+	reads.Reset()
+	_, _ = repo.GetContent(ctx, "menus")
+	_, _ = repo.GetContentByNames(ctx, []string{"menus"})
+	_, _ = repo.DeleteContent(ctx, "menus", false)
+	assert.Len(t, writes.Queries(), 3)
+	assert.Empty(t, reads.Queries())
+}
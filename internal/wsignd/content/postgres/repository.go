@@ -11,12 +11,18 @@ import (
 	"github.com/wrale/wrale-signage/internal/wsignd/database"
 )
 
+// repository stores content sources and events. Source listings, event
+// queries and metrics are read from the reader connection and may miss the
+// latest writes when it is a replica; lookups of sources by name always
+// read the primary.
 type repository struct {
-	db *sql.DB
+	db     *sql.DB
+	reader *sql.DB
 }
 
-func NewRepository(db *sql.DB) *repository {
-	return &repository{db: db}
+func NewRepository(db *sql.DB, opts ...database.RepositoryOption) *repository {
+	conns := database.NewConnections(db, opts...)
+	return &repository{db: conns.Primary, reader: conns.Reader}
 }
 
 func (r *repository) SaveEvent(ctx context.Context, event content.Event) error {
@@ -32,6 +38,10 @@ func (r *repository) SaveEvent(ctx context.Context, event content.Event) error {
 		if event.Metrics.ResourceStats != nil {
 			metrics["resourceStats"] = event.Metrics.ResourceStats
 		}
+		if event.Type == content.EventContentPrefetchProgress {
+			metrics["bytesDownloaded"] = event.Metrics.BytesDownloaded
+			metrics["bytesTotal"] = event.Metrics.BytesTotal
+		}
 	}
 	metricsJSON, err := json.Marshal(metrics)
 	if err != nil {
@@ -88,7 +98,14 @@ func (r *repository) SaveEvent(ctx context.Context, event content.Event) error {
 			metricsJSON,
 			contextJSON,
 		)
-		return err
+		if err != nil {
+			return err
+		}
+
+		if err := updatePrefetchProgress(ctx, tx, event); err != nil {
+			return err
+		}
+		return updateLastError(ctx, tx, event)
 	})
 
 	if err != nil {
@@ -98,6 +115,81 @@ func (r *repository) SaveEvent(ctx context.Context, event content.Event) error {
 	return nil
 }
 
+// updatePrefetchProgress records a prefetch progress event as its display's
+// progress for the URL, unless progress reported after it is already
+// recorded
+func updatePrefetchProgress(ctx context.Context, tx *database.Tx, event content.Event) error {
+	if event.Type != content.EventContentPrefetchProgress {
+		return nil
+	}
+	p := content.NewPrefetchProgress(event)
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO content_prefetch_progress (
+			display_id, url, bytes_downloaded, bytes_total, reported_at
+		) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (display_id, url) DO UPDATE
+		SET bytes_downloaded = excluded.bytes_downloaded,
+			bytes_total = excluded.bytes_total,
+			reported_at = excluded.reported_at
+		WHERE content_prefetch_progress.reported_at <= excluded.reported_at
+	`, p.DisplayID, p.URL, p.BytesDownloaded, p.BytesTotal, p.ReportedAt)
+	return err
+}
+
+// updateLastError keeps the display's last error in step with an event: an
+// error replaces an older last error and a successful load clears one
+// reported no later than it
+func updateLastError(ctx context.Context, tx *database.Tx, event content.Event) error {
+	switch event.Type {
+	case content.EventContentError:
+		var code, message string
+		if event.Error != nil {
+			code = event.Error.Code
+			message = event.Error.Message
+		}
+		_, err := tx.ExecContext(ctx, `
+			UPDATE displays
+			SET last_error_code = $2,
+				last_error_message = $3,
+				last_error_url = $4,
+				last_error_at = $5
+			WHERE id = $1
+			  AND (last_error_at IS NULL OR last_error_at <= $5)
+		`, event.DisplayID, code, message, event.URL, event.Timestamp)
+		return err
+	case content.EventContentLoaded:
+		_, err := tx.ExecContext(ctx, `
+			UPDATE displays
+			SET last_error_code = NULL,
+				last_error_message = NULL,
+				last_error_url = NULL,
+				last_error_at = NULL
+			WHERE id = $1
+			  AND last_error_at <= $2
+		`, event.DisplayID, event.Timestamp)
+		return err
+	}
+	return nil
+}
+
+// urlCountsQuery counts the loads and errors of a URL since a time
+const urlCountsQuery = `
+	SELECT
+		COUNT(*) FILTER (WHERE type = 'CONTENT_LOADED'),
+		COUNT(*) FILTER (WHERE type = 'CONTENT_ERROR')
+	FROM content_events
+	WHERE url = $1 AND timestamp >= $2
+`
+
+// urlLastSeenQuery finds the latest event of a URL. It is only run when
+// the URL has events since the time given, so bounding it by that time
+// finds the same event while reading only the partitions since then.
+const urlLastSeenQuery = `
+	SELECT EXTRACT(EPOCH FROM MAX(timestamp))::bigint
+	FROM content_events
+	WHERE url = $1 AND timestamp >= $2
+`
+
 func (r *repository) GetURLMetrics(ctx context.Context, url string, since time.Time) (*content.URLMetrics, error) {
 	const op = "ContentRepository.GetURLMetrics"
 
@@ -105,15 +197,9 @@ func (r *repository) GetURLMetrics(ctx context.Context, url string, since time.T
 	metrics.URL = url
 	metrics.ErrorRates = make(map[string]float64)
 
-	err := database.RunInTx(ctx, r.db, &database.TxOptions{ReadOnly: true}, func(tx *database.Tx) error {
+	err := database.RunInTx(ctx, r.reader, &database.TxOptions{ReadOnly: true}, func(tx *database.Tx) error {
 		// Get load and error counts
-		err := tx.QueryRowContext(ctx, `
-			SELECT 
-				COUNT(*) FILTER (WHERE type = 'CONTENT_LOADED'),
-				COUNT(*) FILTER (WHERE type = 'CONTENT_ERROR')
-			FROM content_events 
-			WHERE url = $1 AND timestamp >= $2
-		`, url, since).Scan(&metrics.LoadCount, &metrics.ErrorCount)
+		err := tx.QueryRowContext(ctx, urlCountsQuery, url, since).Scan(&metrics.LoadCount, &metrics.ErrorCount)
 		if err != nil {
 			return err
 		}
@@ -124,21 +210,19 @@ func (r *repository) GetURLMetrics(ctx context.Context, url string, since time.T
 		}
 
 		// Get last seen timestamp
-		err = tx.QueryRowContext(ctx, `
-			SELECT EXTRACT(EPOCH FROM MAX(timestamp))::bigint
-			FROM content_events 
-			WHERE url = $1
-		`, url).Scan(&metrics.LastSeen)
+		err = tx.QueryRowContext(ctx, urlLastSeenQuery, url, since).Scan(&metrics.LastSeen)
 		if err != nil && err != sql.ErrNoRows {
 			return err
 		}
 
-		// Get average timing metrics
+		// Get average timing metrics. Timings are averaged as numeric, which
+		// reads the fractions older servers stored and is exact for large
+		// values, then rounded to whole milliseconds.
 		err = tx.QueryRowContext(ctx, `
 			WITH valid_metrics AS (
 				SELECT 
-					(metrics->>'loadTime')::float8 as load_time,
-					(metrics->>'renderTime')::float8 as render_time
+					(metrics->>'loadTime')::numeric as load_time,
+					(metrics->>'renderTime')::numeric as render_time
 				FROM content_events 
 				WHERE url = $1 
 					AND timestamp >= $2
@@ -150,8 +234,8 @@ func (r *repository) GetURLMetrics(ctx context.Context, url string, since time.T
 					AND jsonb_typeof(metrics->'renderTime') = 'number'
 			)
 			SELECT 
-				COALESCE(AVG(load_time), 0),
-				COALESCE(AVG(render_time), 0)
+				COALESCE(ROUND(AVG(load_time)), 0)::bigint,
+				COALESCE(ROUND(AVG(render_time)), 0)::bigint
 			FROM valid_metrics
 		`, url, since).Scan(&metrics.AvgLoadTime, &metrics.AvgRenderTime)
 		if err != nil && err != sql.ErrNoRows {
@@ -212,11 +296,9 @@ func (r *repository) GetDisplayEvents(ctx context.Context, displayID uuid.UUID,
 
 	var events []content.Event
 
-	err := database.RunInTx(ctx, r.db, &database.TxOptions{ReadOnly: true}, func(tx *database.Tx) error {
+	err := database.RunInTx(ctx, r.reader, &database.TxOptions{ReadOnly: true}, func(tx *database.Tx) error {
 		rows, err := tx.QueryContext(ctx, `
-			SELECT 
-				id, display_id, type, url, timestamp,
-				error, metrics, context
+			SELECT `+eventColumns+`
 			FROM content_events
 			WHERE display_id = $1 AND timestamp >= $2
 			ORDER BY timestamp DESC
@@ -227,43 +309,10 @@ func (r *repository) GetDisplayEvents(ctx context.Context, displayID uuid.UUID,
 		defer rows.Close()
 
 		for rows.Next() {
-			var event content.Event
-			var errorJSON, metricsJSON, contextJSON []byte
-
-			err := rows.Scan(
-				&event.ID,
-				&event.DisplayID,
-				&event.Type,
-				&event.URL,
-				&event.Timestamp,
-				&errorJSON,
-				&metricsJSON,
-				&contextJSON,
-			)
+			event, err := scanEvent(rows)
 			if err != nil {
 				return err
 			}
-
-			if len(errorJSON) > 0 && string(errorJSON) != "null" {
-				event.Error = &content.EventError{}
-				if err := json.Unmarshal(errorJSON, event.Error); err != nil {
-					return err
-				}
-			}
-
-			if len(metricsJSON) > 0 && string(metricsJSON) != "{}" {
-				event.Metrics = &content.EventMetrics{}
-				if err := json.Unmarshal(metricsJSON, event.Metrics); err != nil {
-					return err
-				}
-			}
-
-			if len(contextJSON) > 0 && string(contextJSON) != "{}" {
-				if err := json.Unmarshal(contextJSON, &event.Context); err != nil {
-					return err
-				}
-			}
-
 			events = append(events, event)
 		}
 
@@ -276,3 +325,16 @@ func (r *repository) GetDisplayEvents(ctx context.Context, displayID uuid.UUID,
 
 	return events, nil
 }
+
+// ProcessEvents stores each event in a batch
+func (r *repository) ProcessEvents(ctx context.Context, batch content.EventBatch) error {
+	for _, event := range batch.Events {
+		if event.DisplayID == uuid.Nil {
+			event.DisplayID = batch.DisplayID
+		}
+		if err := r.SaveEvent(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
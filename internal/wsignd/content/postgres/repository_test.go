@@ -3,12 +3,14 @@ package postgres
 import (
 	"context"
 	"encoding/json"
+	"sort"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
 	"github.com/wrale/wrale-signage/internal/wsignd/content"
 	"github.com/wrale/wrale-signage/internal/wsignd/testutil"
 )
@@ -180,7 +182,239 @@ func TestGetURLMetrics(t *testing.T) {
 	assert.Equal(t, url, metrics.URL)
 	assert.Equal(t, int64(1), metrics.LoadCount)
 	assert.Equal(t, int64(1), metrics.ErrorCount)
-	assert.Equal(t, float64(1000), metrics.AvgLoadTime)
-	assert.Equal(t, float64(500), metrics.AvgRenderTime)
+	assert.Equal(t, int64(1000), metrics.AvgLoadTime)
+	assert.Equal(t, int64(500), metrics.AvgRenderTime)
 	assert.Contains(t, metrics.ErrorRates, "LOAD_FAILED")
 }
+
+func TestGetURLMetricsPrecision(t *testing.T) {
+	db, cleanup := testutil.SetupTestDB(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+	displayID := uuid.New()
+	url := "https://example.com/content"
+
+	_, err := db.Exec(`
+		INSERT INTO displays (id, name, site_id, zone, position, state, last_seen)
+		VALUES ($1, 'test-display', 'site-1', 'zone-1', 'pos-1', 'ACTIVE', NOW())
+	`, displayID)
+	require.NoError(t, err)
+
+	// 2^53 + 1 is the first integer a float64 cannot hold
+	const large = v1alpha1.Milliseconds(1<<53 + 1)
+	event := content.Event{
+		ID:        uuid.New(),
+		DisplayID: displayID,
+		Type:      content.EventContentLoaded,
+		URL:       url,
+		Timestamp: time.Now(),
+		Metrics:   &content.EventMetrics{LoadTime: large, RenderTime: large + 2},
+	}
+	require.NoError(t, repo.SaveEvent(ctx, event))
+
+	var stored string
+	require.NoError(t, db.QueryRow(`SELECT metrics->>'loadTime' FROM content_events WHERE id = $1`, event.ID).Scan(&stored))
+	assert.Equal(t, "9007199254740993", stored, "timings are stored as integers")
+
+	metrics, err := repo.GetURLMetrics(ctx, url, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(large), metrics.AvgLoadTime)
+	assert.Equal(t, int64(large+2), metrics.AvgRenderTime)
+
+	var got []content.Event
+	require.NoError(t, repo.ForEachEvent(ctx, content.EventQuery{URL: url, Since: time.Now().Add(-time.Hour), Until: time.Now()},
+		func(e content.Event) error { got = append(got, e); return nil }))
+	require.Len(t, got, 1)
+	assert.Equal(t, large, got[0].Metrics.LoadTime)
+
+	// Older servers stored the fractions displays sent
+	legacy := uuid.New()
+	_, err = db.Exec(`
+		INSERT INTO content_events (id, display_id, type, url, timestamp, metrics)
+		VALUES ($1, $2, 'CONTENT_LOADED', 'https://example.com/legacy', NOW(), '{"loadTime": 1500.5, "renderTime": 20.4}')
+	`, legacy, displayID)
+	require.NoError(t, err)
+
+	metrics, err = repo.GetURLMetrics(ctx, "https://example.com/legacy", time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1501), metrics.AvgLoadTime)
+	assert.Equal(t, int64(20), metrics.AvgRenderTime)
+
+	got = nil
+	require.NoError(t, repo.ForEachEvent(ctx, content.EventQuery{URL: "https://example.com/legacy", Since: time.Now().Add(-time.Hour), Until: time.Now()},
+		func(e content.Event) error { got = append(got, e); return nil }))
+	require.Len(t, got, 1)
+	assert.Equal(t, v1alpha1.Milliseconds(1501), got[0].Metrics.LoadTime)
+	assert.Equal(t, v1alpha1.Milliseconds(20), got[0].Metrics.RenderTime)
+}
+
+func TestSaveEventTracksLastError(t *testing.T) {
+	db, cleanup := testutil.SetupTestDB(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	displayID := uuid.New()
+	_, err := db.Exec(`
+		INSERT INTO displays (id, name, site_id, zone, position, state, last_seen)
+		VALUES ($1, 'test-display', 'site-1', 'zone-1', 'pos-1', 'ACTIVE', NOW())
+	`, displayID)
+	require.NoError(t, err)
+
+	lastErrorCode := func() *string {
+		var code *string
+		require.NoError(t, db.QueryRow(`SELECT last_error_code FROM displays WHERE id = $1`, displayID).Scan(&code))
+		return code
+	}
+
+	now := time.Now()
+	require.NoError(t, repo.SaveEvent(ctx, content.Event{
+		ID:        uuid.New(),
+		DisplayID: displayID,
+		Type:      content.EventContentError,
+		URL:       "https://example.com/content",
+		Timestamp: now,
+		Error:     &content.EventError{Code: "LOAD_FAILED", Message: "Failed to load content"},
+	}))
+	require.NotNil(t, lastErrorCode())
+	assert.Equal(t, "LOAD_FAILED", *lastErrorCode())
+
+	require.NoError(t, repo.SaveEvent(ctx, content.Event{
+		ID:        uuid.New(),
+		DisplayID: displayID,
+		Type:      content.EventContentLoaded,
+		URL:       "https://example.com/content",
+		Timestamp: now.Add(time.Second),
+	}))
+	assert.Nil(t, lastErrorCode())
+}
+
+func TestSaveEventKeepsLatestPrefetchProgress(t *testing.T) {
+	db, cleanup := testutil.SetupTestDB(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	displayID := uuid.New()
+	_, err := db.Exec(`
+		INSERT INTO displays (id, name, site_id, zone, position, state, last_seen)
+		VALUES ($1, 'test-display', 'site-1', 'zone-1', 'pos-1', 'ACTIVE', NOW())
+	`, displayID)
+	require.NoError(t, err)
+
+	url := "https://example.com/promo.mp4"
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	save := func(at time.Time, downloaded int64) {
+		require.NoError(t, repo.SaveEvent(ctx, content.Event{
+			ID:        uuid.New(),
+			DisplayID: displayID,
+			Type:      content.EventContentPrefetchProgress,
+			URL:       url,
+			Timestamp: at,
+			Metrics:   &content.EventMetrics{BytesDownloaded: downloaded, BytesTotal: 1000},
+		}))
+	}
+	latest := func() content.PrefetchProgress {
+		progress, err := repo.PrefetchProgress(ctx, url)
+		require.NoError(t, err)
+		require.Len(t, progress, 1)
+		return progress[0]
+	}
+
+	save(now, 600)
+	assert.Equal(t, int64(600), latest().BytesDownloaded)
+
+	// Progress reported earlier but delivered later is not kept
+	save(now.Add(-time.Second), 200)
+	assert.Equal(t, int64(600), latest().BytesDownloaded)
+	assert.True(t, now.Equal(latest().ReportedAt))
+
+	save(now.Add(time.Second), 1000)
+	got := latest()
+	assert.Equal(t, int64(1000), got.BytesDownloaded)
+	assert.Equal(t, int64(1000), got.BytesTotal)
+	assert.True(t, got.Complete())
+
+	// Every event is still stored with its byte counts
+	var stored []content.Event
+	require.NoError(t, repo.ForEachEvent(ctx, content.EventQuery{
+		URL: url, Since: now.Add(-time.Minute), Until: now.Add(time.Minute),
+	}, func(e content.Event) error {
+		stored = append(stored, e)
+		return nil
+	}))
+	require.Len(t, stored, 3)
+	assert.Equal(t, int64(200), stored[0].Metrics.BytesDownloaded)
+
+	// Deleting the display removes its progress
+	_, err = db.Exec(`DELETE FROM displays WHERE id = $1`, displayID)
+	require.NoError(t, err)
+	progress, err := repo.PrefetchProgress(ctx, url)
+	require.NoError(t, err)
+	assert.Empty(t, progress)
+}
+
+func TestForEachEvent(t *testing.T) {
+	db, cleanup := testutil.SetupTestDB(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	displayID := uuid.New()
+	_, err := db.Exec(`
+		INSERT INTO displays (id, name, site_id, zone, position, state, last_seen)
+		VALUES ($1, 'test-display', 'site-1', 'zone-1', 'pos-1', 'ACTIVE', NOW())
+	`, displayID)
+	require.NoError(t, err)
+
+	// Events share timestamps in pairs so pages must break ties by ID
+	start := time.Now().Add(-time.Hour).UTC().Truncate(time.Millisecond)
+	var want []content.Event
+	for i := 0; i < 6; i++ {
+		event := content.Event{
+			ID:        uuid.New(),
+			DisplayID: displayID,
+			Type:      content.EventContentLoaded,
+			URL:       "https://example.com/content",
+			Timestamp: start.Add(time.Duration(i/2) * time.Minute),
+			Metrics:   &content.EventMetrics{LoadTime: v1alpha1.Milliseconds(i)},
+		}
+		require.NoError(t, repo.SaveEvent(ctx, event))
+		want = append(want, event)
+	}
+	sort.Slice(want, func(i, j int) bool { return want[i].Cursor().Before(want[j].Cursor()) })
+
+	query := content.EventQuery{URL: "https://example.com/content", Since: start, Until: time.Now(), Limit: 4}
+	var got []content.Event
+	for {
+		n := 0
+		require.NoError(t, repo.ForEachEvent(ctx, query, func(e content.Event) error {
+			got = append(got, e)
+			n++
+			return nil
+		}))
+		if n < query.Limit {
+			break
+		}
+		cursor := got[len(got)-1].Cursor()
+		query.After = &cursor
+	}
+	require.Len(t, got, len(want))
+	for i := range want {
+		assert.Equal(t, want[i].ID, got[i].ID)
+		assert.True(t, want[i].Timestamp.Equal(got[i].Timestamp))
+		assert.Equal(t, want[i].Metrics.LoadTime, got[i].Metrics.LoadTime)
+		assert.Nil(t, got[i].Error, "a stored null error is left unset")
+	}
+
+	var none int
+	require.NoError(t, repo.ForEachEvent(ctx, content.EventQuery{
+		Type: content.EventContentError, Since: start, Until: time.Now(),
+	}, func(content.Event) error { none++; return nil }))
+	assert.Zero(t, none)
+}
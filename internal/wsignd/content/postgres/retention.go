@@ -0,0 +1,279 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	"github.com/wrale/wrale-signage/internal/wsignd/database"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// partitionLock serializes creating and dropping event partitions across
+// servers sharing the database
+const partitionLock = `SELECT pg_advisory_xact_lock(hashtext('content_events/partitions'))`
+
+// partitionsQuery lists the partitions of content_events with their bounds
+// parsed from the partition expression, which renders timestamps with
+// their offset. Unbounded ends read as NULL.
+const partitionsQuery = `
+	SELECT
+		c.relname,
+		pg_get_expr(c.relpartbound, c.oid) = 'DEFAULT',
+		(regexp_match(pg_get_expr(c.relpartbound, c.oid), 'FROM \(''([^'']+)''\)'))[1]::timestamptz,
+		(regexp_match(pg_get_expr(c.relpartbound, c.oid), 'TO \(''([^'']+)''\)'))[1]::timestamptz,
+		pg_get_expr(c.relpartbound, c.oid) LIKE '%TO (MAXVALUE)%',
+		pg_total_relation_size(c.oid),
+		GREATEST(c.reltuples, 0)::bigint
+	FROM pg_inherits i
+	JOIN pg_class c ON c.oid = i.inhrelid
+	WHERE i.inhparent = 'content_events'::regclass
+`
+
+// eventPartition is a partition as listed by partitionsQuery
+type eventPartition struct {
+	content.EventPartition
+	// unboundedAbove is set when the partition holds every later event
+	unboundedAbove bool
+}
+
+// queryer runs queries on a connection or in a transaction
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// listPartitions lists the partitions of content_events, oldest first and
+// the default partition last
+func listPartitions(ctx context.Context, q queryer) ([]eventPartition, error) {
+	rows, err := q.QueryContext(ctx, partitionsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var partitions []eventPartition
+	for rows.Next() {
+		var p eventPartition
+		var from, to sql.NullTime
+		if err := rows.Scan(&p.Name, &p.Default, &from, &to, &p.unboundedAbove, &p.Bytes, &p.Rows); err != nil {
+			return nil, err
+		}
+		if from.Valid {
+			p.From = &from.Time
+		}
+		if to.Valid {
+			p.To = &to.Time
+		}
+		partitions = append(partitions, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(partitions, func(i, j int) bool {
+		a, b := partitions[i], partitions[j]
+		if a.Default != b.Default {
+			return b.Default
+		}
+		if a.From == nil || b.From == nil {
+			return a.From == nil && b.From != nil
+		}
+		return a.From.Before(*b.From)
+	})
+	return partitions, nil
+}
+
+// DeleteEventsBefore deletes up to limit of the oldest events reported
+// before cutoff. Rows another server is deleting are skipped rather than
+// waited for.
+func (r *repository) DeleteEventsBefore(ctx context.Context, cutoff time.Time, limit int, archive func([]content.Event) error) (int, error) {
+	const op = "ContentRepository.DeleteEventsBefore"
+
+	var deleted []content.Event
+	err := database.RunInTx(ctx, r.db, nil, func(tx *database.Tx) error {
+		deleted = deleted[:0]
+		rows, err := tx.QueryContext(ctx, `
+			DELETE FROM content_events
+			WHERE (id, timestamp) IN (
+				SELECT id, timestamp
+				FROM content_events
+				WHERE timestamp < $1
+				ORDER BY timestamp, id
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING `+eventColumns, cutoff, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			event, err := scanEvent(rows)
+			if err != nil {
+				return err
+			}
+			deleted = append(deleted, event)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if archive != nil && len(deleted) > 0 {
+			return archive(deleted)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, database.MapError(err, op)
+	}
+
+	return len(deleted), nil
+}
+
+// EventStorage lists the partitions events are stored in and the oldest
+// event kept
+func (r *repository) EventStorage(ctx context.Context) (*content.EventStorage, error) {
+	const op = "ContentRepository.EventStorage"
+
+	partitions, err := listPartitions(ctx, r.db)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+	storage := &content.EventStorage{Partitioned: true}
+	for _, p := range partitions {
+		storage.Tables = append(storage.Tables, p.EventPartition)
+	}
+
+	var oldest sql.NullTime
+	if err := r.db.QueryRowContext(ctx, `SELECT MIN(timestamp) FROM content_events`).Scan(&oldest); err != nil {
+		return nil, database.MapError(err, op)
+	}
+	if oldest.Valid {
+		storage.OldestEvent = &oldest.Time
+	}
+
+	return storage, nil
+}
+
+// EnsureEventPartitions creates the monthly partitions following the last
+// one through the month of through. Events already stored in the default
+// partition for a new month are moved into it.
+func (r *repository) EnsureEventPartitions(ctx context.Context, through time.Time) ([]string, error) {
+	const op = "ContentRepository.EnsureEventPartitions"
+
+	var created []string
+	err := database.RunInTx(ctx, r.db, nil, func(tx *database.Tx) error {
+		created = created[:0]
+		if _, err := tx.ExecContext(ctx, partitionLock); err != nil {
+			return err
+		}
+		partitions, err := listPartitions(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		// Start after the last month partitioned, or with the month before
+		// through when there are none
+		start := monthStart(through).AddDate(0, -1, 0)
+		var last *time.Time
+		defaultName := ""
+		for _, p := range partitions {
+			switch {
+			case p.Default:
+				defaultName = p.Name
+			case p.unboundedAbove:
+				return nil
+			case p.To != nil && (last == nil || p.To.After(*last)):
+				last = p.To
+			}
+		}
+		if last != nil {
+			start = last.UTC()
+		}
+
+		for ; !start.After(through); start = start.AddDate(0, 1, 0) {
+			name := "content_events_p" + start.Format("200601")
+			if err := createPartition(ctx, tx, name, defaultName, start, start.AddDate(0, 1, 0)); err != nil {
+				return err
+			}
+			created = append(created, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	return created, nil
+}
+
+// createPartition creates the partition for events from start up to end,
+// moving the events the default partition holds for that range into it
+// first, since attaching fails while the default partition holds any
+func createPartition(ctx context.Context, tx *database.Tx, name, defaultName string, start, end time.Time) error {
+	table := pq.QuoteIdentifier(name)
+	if _, err := tx.ExecContext(ctx,
+		`CREATE TABLE `+table+` (LIKE content_events INCLUDING DEFAULTS INCLUDING CONSTRAINTS)`); err != nil {
+		return err
+	}
+	if defaultName != "" {
+		if _, err := tx.ExecContext(ctx, `
+			WITH moved AS (
+				DELETE FROM `+pq.QuoteIdentifier(defaultName)+`
+				WHERE timestamp >= $1 AND timestamp < $2
+				RETURNING *
+			)
+			INSERT INTO `+table+` SELECT * FROM moved
+		`, start, end); err != nil {
+			return err
+		}
+	}
+	_, err := tx.ExecContext(ctx, `ALTER TABLE content_events ATTACH PARTITION `+table+
+		` FOR VALUES FROM (`+pq.QuoteLiteral(start.Format(time.RFC3339))+`) TO (`+pq.QuoteLiteral(end.Format(time.RFC3339))+`)`)
+	return err
+}
+
+// DropEventPartition drops a partition of content_events with the events
+// in it
+func (r *repository) DropEventPartition(ctx context.Context, name string) error {
+	const op = "ContentRepository.DropEventPartition"
+
+	err := database.RunInTx(ctx, r.db, nil, func(tx *database.Tx) error {
+		if _, err := tx.ExecContext(ctx, partitionLock); err != nil {
+			return err
+		}
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS (
+				SELECT 1
+				FROM pg_inherits i
+				JOIN pg_class c ON c.oid = i.inhrelid
+				WHERE i.inhparent = 'content_events'::regclass AND c.relname = $1
+			)
+		`, name).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return werrors.NewError("NOT_FOUND", "Event partition not found: "+name, op, werrors.ErrNotFound)
+		}
+		_, err := tx.ExecContext(ctx, `DROP TABLE `+pq.QuoteIdentifier(name))
+		return err
+	})
+	if err != nil {
+		if werrors.IsNotFound(err) {
+			return err
+		}
+		return database.MapError(err, op)
+	}
+
+	return nil
+}
+
+// monthStart returns midnight UTC on the first day of t's month in UTC
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,181 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/testutil"
+)
+
+func TestDeleteEventsBefore(t *testing.T) {
+	db, cleanup := testutil.SetupTestDB(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+	displayID := createEventDisplay(t, db)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	var old []uuid.UUID
+	for i := 0; i < 5; i++ {
+		old = append(old, saveEventAt(t, repo, displayID, now.Add(-48*time.Hour+time.Duration(i)*time.Minute)))
+	}
+	saveEventAt(t, repo, displayID, now.Add(-time.Hour))
+	saveEventAt(t, repo, displayID, now)
+	cutoff := now.Add(-24 * time.Hour)
+
+	// An archive failure keeps the batch
+	_, err := repo.DeleteEventsBefore(ctx, cutoff, 3, func([]content.Event) error {
+		return errors.New("disk full")
+	})
+	require.Error(t, err)
+	assert.Equal(t, 7, countEvents(t, db))
+
+	var archived []content.Event
+	archive := func(events []content.Event) error {
+		archived = append(archived, events...)
+		return nil
+	}
+	n, err := repo.DeleteEventsBefore(ctx, cutoff, 3, archive)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	n, err = repo.DeleteEventsBefore(ctx, cutoff, 3, archive)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	n, err = repo.DeleteEventsBefore(ctx, cutoff, 3, archive)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	require.Len(t, archived, 5)
+	for i, e := range archived {
+		assert.Equal(t, old[i], e.ID, "oldest first")
+		assert.Equal(t, displayID, e.DisplayID)
+	}
+	assert.Equal(t, 2, countEvents(t, db))
+}
+
+func TestEventPartitions(t *testing.T) {
+	db, cleanup := testutil.SetupTestDB(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+	displayID := createEventDisplay(t, db)
+	firstMonth := monthStart(time.Now()).AddDate(0, 1, 0)
+
+	storage, err := repo.EventStorage(ctx)
+	require.NoError(t, err)
+	assert.True(t, storage.Partitioned)
+	assert.Nil(t, storage.OldestEvent)
+	require.Len(t, storage.Tables, 3)
+	legacy, first, def := storage.Tables[0], storage.Tables[1], storage.Tables[2]
+	assert.Equal(t, "content_events_legacy", legacy.Name)
+	assert.Nil(t, legacy.From)
+	require.NotNil(t, legacy.To)
+	assert.True(t, legacy.To.Equal(firstMonth))
+	assert.Equal(t, "content_events_p"+firstMonth.Format("200601"), first.Name)
+	assert.True(t, first.From.Equal(firstMonth))
+	assert.True(t, first.To.Equal(firstMonth.AddDate(0, 1, 0)))
+	assert.True(t, def.Default)
+
+	// An event from a month not yet partitioned lands in the default
+	// partition and moves when its month is created
+	later := firstMonth.AddDate(0, 2, 3)
+	id := saveEventAt(t, repo, displayID, later)
+	created, err := repo.EnsureEventPartitions(ctx, later)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"content_events_p" + firstMonth.AddDate(0, 1, 0).Format("200601"),
+		"content_events_p" + firstMonth.AddDate(0, 2, 0).Format("200601"),
+	}, created)
+
+	var partition string
+	require.NoError(t, db.QueryRow(
+		`SELECT tableoid::regclass::text FROM content_events WHERE id = $1`, id).Scan(&partition))
+	assert.Equal(t, created[1], partition)
+
+	created, err = repo.EnsureEventPartitions(ctx, later)
+	require.NoError(t, err)
+	assert.Empty(t, created, "partitions are only created once")
+
+	storage, err = repo.EventStorage(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, storage.OldestEvent)
+	assert.True(t, storage.OldestEvent.Equal(later))
+
+	require.NoError(t, repo.DropEventPartition(ctx, "content_events_legacy"))
+	assert.True(t, werrors.IsNotFound(repo.DropEventPartition(ctx, "content_events_legacy")))
+	assert.True(t, werrors.IsNotFound(repo.DropEventPartition(ctx, "displays")),
+		"only partitions of content_events are dropped")
+
+	storage, err = repo.EventStorage(ctx)
+	require.NoError(t, err)
+	assert.Len(t, storage.Tables, 4)
+}
+
+func TestURLMetricsPrunePartitions(t *testing.T) {
+	db, cleanup := testutil.SetupTestDB(t)
+	defer cleanup()
+
+	// Metrics over the latest month must not read the partition of older
+	// events
+	since := monthStart(time.Now()).AddDate(0, 1, 0)
+	for _, query := range []string{urlCountsQuery, urlLastSeenQuery} {
+		rows, err := db.Query("EXPLAIN "+query, "https://example.com/menu", since)
+		require.NoError(t, err)
+
+		var plan []string
+		for rows.Next() {
+			var line string
+			require.NoError(t, rows.Scan(&line))
+			plan = append(plan, line)
+		}
+		require.NoError(t, rows.Err())
+		rows.Close()
+
+		joined := strings.Join(plan, "\n")
+		assert.NotContains(t, joined, "content_events_legacy", joined)
+		assert.Contains(t, joined, "content_events_p"+since.Format("200601"), joined)
+	}
+}
+
+func createEventDisplay(t *testing.T, db *sql.DB) uuid.UUID {
+	t.Helper()
+	id := uuid.New()
+	_, err := db.Exec(`
+		INSERT INTO displays (id, name, site_id, zone, position, state, last_seen)
+		VALUES ($1, $2, 'site-1', 'zone-1', 'pos-1', 'ACTIVE', NOW())
+	`, id, "display-"+id.String()[:8])
+	require.NoError(t, err)
+	return id
+}
+
+func saveEventAt(t *testing.T, repo *repository, displayID uuid.UUID, at time.Time) uuid.UUID {
+	t.Helper()
+	id := uuid.New()
+	require.NoError(t, repo.SaveEvent(context.Background(), content.Event{
+		ID:        id,
+		DisplayID: displayID,
+		Type:      content.EventContentLoaded,
+		URL:       "https://example.com/menu",
+		Timestamp: at,
+	}))
+	return id
+}
+
+func countEvents(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var n int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM content_events`).Scan(&n))
+	return n
+}
@@ -0,0 +1,399 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	"github.com/wrale/wrale-signage/internal/wsignd/database"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+const sourceColumns = `
+	id, name, url, type, properties, allowed_paths, tags, fallbacks, version,
+	last_validated, is_healthy, status_report,
+	created_at, updated_at`
+
+type sourceScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSource reads a content_sources row into a ContentSource
+func scanSource(row sourceScanner) (*v1alpha1.ContentSource, error) {
+	var (
+		source        v1alpha1.ContentSource
+		propsJSON     []byte
+		tagsJSON      []byte
+		lastValidated sql.NullTime
+		reportJSON    []byte
+	)
+
+	err := row.Scan(
+		&source.ID,
+		&source.Name,
+		&source.Spec.URL,
+		&source.Spec.Type,
+		&propsJSON,
+		pq.Array(&source.Spec.AllowedPaths),
+		&tagsJSON,
+		pq.Array(&source.Spec.Fallbacks),
+		&source.Status.Version,
+		&lastValidated,
+		&source.Status.IsHealthy,
+		&reportJSON,
+		&source.CreatedAt,
+		&source.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	source.TypeMeta = v1alpha1.TypeMeta{Kind: "ContentSource", APIVersion: "v1alpha1"}
+	if len(source.Spec.AllowedPaths) == 0 {
+		source.Spec.AllowedPaths = nil
+	}
+	if len(source.Spec.Fallbacks) == 0 {
+		source.Spec.Fallbacks = nil
+	}
+	if len(tagsJSON) > 0 {
+		if err := json.Unmarshal(tagsJSON, &source.Spec.Tags); err != nil {
+			return nil, err
+		}
+		if len(source.Spec.Tags) == 0 {
+			source.Spec.Tags = nil
+		}
+	}
+	if lastValidated.Valid {
+		source.Status.LastValidated = lastValidated.Time
+	}
+	if len(propsJSON) > 0 {
+		if err := json.Unmarshal(propsJSON, &source.Spec.Properties); err != nil {
+			return nil, err
+		}
+	}
+	if len(reportJSON) > 0 && string(reportJSON) != "null" {
+		source.Status.Validation = &v1alpha1.ContentValidationReport{}
+		if err := json.Unmarshal(reportJSON, source.Status.Validation); err != nil {
+			return nil, err
+		}
+	}
+
+	return &source, nil
+}
+
+// allowedPaths stores a nil list as an empty array, which the column
+// requires. Fallbacks are stored the same way.
+func allowedPaths(paths []string) []string {
+	if paths == nil {
+		return []string{}
+	}
+	return paths
+}
+
+// tagsJSON encodes tags for the JSONB column, storing a nil list as []
+func tagsJSON(tags []string) ([]byte, error) {
+	if tags == nil {
+		tags = []string{}
+	}
+	return json.Marshal(tags)
+}
+
+// nullTime converts a zero time to NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+func (r *repository) CreateContent(ctx context.Context, source *v1alpha1.ContentSource) error {
+	const op = "ContentRepository.CreateContent"
+
+	propsJSON, err := json.Marshal(source.Spec.Properties)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+	reportJSON, err := json.Marshal(source.Status.Validation)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+	tags, err := tagsJSON(source.Spec.Tags)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+
+	if source.ID == uuid.Nil {
+		source.ID = uuid.New()
+	}
+
+	err = r.db.QueryRowContext(ctx, `
+		INSERT INTO content_sources (
+			id, name, url, type, properties, allowed_paths, tags, fallbacks, version,
+			last_validated, is_healthy, status_report
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING created_at, updated_at
+	`,
+		source.ID,
+		source.Name,
+		source.Spec.URL,
+		source.Spec.Type,
+		propsJSON,
+		pq.Array(allowedPaths(source.Spec.AllowedPaths)),
+		tags,
+		pq.Array(allowedPaths(source.Spec.Fallbacks)),
+		source.Status.Version,
+		nullTime(source.Status.LastValidated),
+		source.Status.IsHealthy,
+		reportJSON,
+	).Scan(&source.CreatedAt, &source.UpdatedAt)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+
+	return nil
+}
+
+func (r *repository) GetContent(ctx context.Context, name string) (*v1alpha1.ContentSource, error) {
+	const op = "ContentRepository.GetContent"
+
+	row := r.db.QueryRowContext(ctx,
+		"SELECT "+sourceColumns+" FROM content_sources WHERE name = $1", name)
+	source, err := scanSource(row)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	return source, nil
+}
+
+func (r *repository) ListContent(ctx context.Context) ([]v1alpha1.ContentSource, error) {
+	const op = "ContentRepository.ListContent"
+
+	return r.listSources(ctx, r.reader, op,
+		"SELECT "+sourceColumns+" FROM content_sources ORDER BY name")
+}
+
+func (r *repository) ListContentByType(ctx context.Context, contentType string) ([]v1alpha1.ContentSource, error) {
+	const op = "ContentRepository.ListContentByType"
+
+	return r.listSources(ctx, r.reader, op,
+		"SELECT "+sourceColumns+" FROM content_sources WHERE type = $1 ORDER BY name", contentType)
+}
+
+// ListContentByTags uses JSONB containment, so a source matches when its
+// tags include every tag asked for
+func (r *repository) ListContentByTags(ctx context.Context, tags []string) ([]v1alpha1.ContentSource, error) {
+	const op = "ContentRepository.ListContentByTags"
+
+	want, err := tagsJSON(tags)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	return r.listSources(ctx, r.reader, op,
+		"SELECT "+sourceColumns+" FROM content_sources WHERE tags @> $1::jsonb ORDER BY name", want)
+}
+
+// GetContentByNames fetches every named source in one round trip rather
+// than one query per name
+func (r *repository) GetContentByNames(ctx context.Context, names []string) (map[string]*v1alpha1.ContentSource, error) {
+	const op = "ContentRepository.GetContentByNames"
+
+	sources, err := r.listSources(ctx, r.db, op,
+		"SELECT "+sourceColumns+" FROM content_sources WHERE name = ANY($1)", pq.Array(names))
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*v1alpha1.ContentSource, len(sources))
+	for i := range sources {
+		byName[sources[i].Name] = &sources[i]
+	}
+	return byName, nil
+}
+
+// listSources runs a query selecting sourceColumns on db and collects the
+// rows
+func (r *repository) listSources(ctx context.Context, db *sql.DB, op, query string, args ...interface{}) ([]v1alpha1.ContentSource, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+	defer rows.Close()
+
+	sources := []v1alpha1.ContentSource{}
+	for rows.Next() {
+		source, err := scanSource(rows)
+		if err != nil {
+			return nil, database.MapError(err, op)
+		}
+		sources = append(sources, *source)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	return sources, nil
+}
+
+func (r *repository) UpdateContent(ctx context.Context, source *v1alpha1.ContentSource) error {
+	const op = "ContentRepository.UpdateContent"
+
+	propsJSON, err := json.Marshal(source.Spec.Properties)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+	reportJSON, err := json.Marshal(source.Status.Validation)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+	tags, err := tagsJSON(source.Spec.Tags)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+
+	err = r.db.QueryRowContext(ctx, `
+		UPDATE content_sources SET
+			url = $2,
+			properties = $3,
+			last_validated = $4,
+			is_healthy = $5,
+			status_report = $6,
+			allowed_paths = $7,
+			tags = $8,
+			fallbacks = $10,
+			version = version + 1
+		WHERE name = $1
+		  AND version = $9
+		RETURNING version, updated_at
+	`,
+		source.Name,
+		source.Spec.URL,
+		propsJSON,
+		nullTime(source.Status.LastValidated),
+		source.Status.IsHealthy,
+		reportJSON,
+		pq.Array(allowedPaths(source.Spec.AllowedPaths)),
+		tags,
+		source.Status.Version,
+		pq.Array(allowedPaths(source.Spec.Fallbacks)),
+	).Scan(&source.Status.Version, &source.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Either the source is gone or another update advanced its version
+		var exists bool
+		if err := r.db.QueryRowContext(ctx,
+			"SELECT EXISTS(SELECT 1 FROM content_sources WHERE name = $1)", source.Name,
+		).Scan(&exists); err != nil {
+			return database.MapError(err, op)
+		}
+		if exists {
+			return werrors.NewError("VERSION_MISMATCH", "resource was modified concurrently", op, werrors.ErrVersionMismatch)
+		}
+	}
+	if err != nil {
+		return database.MapError(err, op)
+	}
+
+	return nil
+}
+
+// DeleteContent removes a content source. The source's row is locked
+// first, so assignments made from it concurrently wait for the removal and
+// then fail their foreign key check rather than being missed by it.
+func (r *repository) DeleteContent(ctx context.Context, name string, force bool) ([]v1alpha1.ResourceReference, error) {
+	const op = "ContentRepository.DeleteContent"
+
+	var removed []v1alpha1.ResourceReference
+	err := database.RunInTx(ctx, r.db, nil, func(tx *database.Tx) error {
+		var id uuid.UUID
+		if err := tx.QueryRowContext(ctx,
+			"SELECT id FROM content_sources WHERE name = $1 FOR UPDATE", name,
+		).Scan(&id); err != nil {
+			return err
+		}
+
+		refs, err := sourceReferences(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+		if len(refs) > 0 && !force {
+			return &content.SourceInUseError{Name: name, References: refs}
+		}
+		if len(refs) > 0 {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM content_assignments WHERE source = $1", name); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM content_sources WHERE id = $1", id); err != nil {
+			return err
+		}
+		removed = refs
+		return nil
+	})
+
+	var (
+		inUse *content.SourceInUseError
+		pqErr *pq.Error
+	)
+	switch {
+	case errors.As(err, &inUse):
+		return nil, err
+	case errors.As(err, &pqErr) && pqErr.Code == "23503": // foreign_key_violation
+		return nil, werrors.NewError("CONFLICT", "content source is still referenced", op, werrors.ErrConflict)
+	case err != nil:
+		return nil, database.MapError(err, op)
+	}
+	return removed, nil
+}
+
+// sourceReferences lists the assignments made from the named source, oldest
+// first
+func sourceReferences(ctx context.Context, tx *database.Tx, name string) ([]v1alpha1.ResourceReference, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT name FROM content_assignments WHERE source = $1 ORDER BY created_at, name", name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refs []v1alpha1.ResourceReference
+	for rows.Next() {
+		ref := v1alpha1.ResourceReference{Kind: "ContentAssignment"}
+		if err := rows.Scan(&ref.Name); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+func (r *repository) UpdateValidation(ctx context.Context, name string, report *v1alpha1.ContentValidationReport) error {
+	const op = "ContentRepository.UpdateValidation"
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE content_sources SET
+			last_validated = $2,
+			is_healthy = $3,
+			status_report = $4
+		WHERE name = $1
+	`, name, report.ValidatedAt, report.Passed, reportJSON)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return database.MapError(err, op)
+	} else if rows == 0 {
+		return database.MapError(sql.ErrNoRows, op)
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/testutil"
+)
+
+func TestContentSourceValidationPersistence(t *testing.T) {
+	db, cleanup := testutil.SetupTestDB(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	report := &v1alpha1.ContentValidationReport{
+		ValidatedAt: time.Now().UTC().Truncate(time.Millisecond),
+		HTTPStatus:  503,
+		LatencyMs:   120,
+		ContentType: "text/html",
+		Reason:      "unexpected status 503",
+	}
+	source := &v1alpha1.ContentSource{
+		ObjectMeta: v1alpha1.ObjectMeta{Name: "welcome"},
+		Spec: v1alpha1.ContentSourceSpec{
+			URL:        "https://example.com/welcome",
+			Type:       "static-page",
+			Properties: map[string]string{"audience": "lobby"},
+		},
+		Status: v1alpha1.ContentSourceStatus{
+			LastValidated: report.ValidatedAt,
+			Version:       1,
+			Validation:    report,
+		},
+	}
+	require.NoError(t, repo.CreateContent(ctx, source))
+
+	stored, err := repo.GetContent(ctx, "welcome")
+	require.NoError(t, err)
+	assert.False(t, stored.Status.IsHealthy)
+	require.NotNil(t, stored.Status.Validation)
+	assert.Equal(t, 503, stored.Status.Validation.HTTPStatus)
+	assert.Equal(t, "unexpected status 503", stored.Status.Validation.Reason)
+	assert.Equal(t, "lobby", stored.Spec.Properties["audience"])
+
+	// A later passing validation replaces the report without bumping the version
+	passed := &v1alpha1.ContentValidationReport{
+		ValidatedAt: time.Now().UTC(),
+		Passed:      true,
+		HTTPStatus:  200,
+		Size:        2048,
+	}
+	require.NoError(t, repo.UpdateValidation(ctx, "welcome", passed))
+
+	stored, err = repo.GetContent(ctx, "welcome")
+	require.NoError(t, err)
+	assert.True(t, stored.Status.IsHealthy)
+	assert.Equal(t, int64(2048), stored.Status.Validation.Size)
+	assert.Equal(t, 1, stored.Status.Version)
+
+	// Duplicate names and unknown sources map to domain errors
+	err = repo.CreateContent(ctx, &v1alpha1.ContentSource{
+		ObjectMeta: v1alpha1.ObjectMeta{Name: "welcome"},
+		Spec:       source.Spec,
+	})
+	assert.True(t, werrors.IsConflict(err))
+	assert.True(t, werrors.IsNotFound(repo.UpdateValidation(ctx, "missing", passed)))
+}
+
+// sequenceLength matches a typical lobby rotation
+const sequenceLength = 12
+
+func BenchmarkResolveSequence(b *testing.B) {
+	db, cleanup := testutil.SetupTestDB(b)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	names := make([]string, sequenceLength)
+	for i := range names {
+		names[i] = fmt.Sprintf("slide-%02d", i)
+		require.NoError(b, repo.CreateContent(ctx, &v1alpha1.ContentSource{
+			ObjectMeta: v1alpha1.ObjectMeta{Name: names[i]},
+			Spec: v1alpha1.ContentSourceSpec{
+				URL:  "https://example.com/" + names[i],
+				Type: "static-page",
+			},
+		}))
+	}
+
+	b.Run("per-item", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, name := range names {
+				if _, err := repo.GetContent(ctx, name); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("batched", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			sources, err := repo.GetContentByNames(ctx, names)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(sources) != sequenceLength {
+				b.Fatalf("resolved %d of %d sources", len(sources), sequenceLength)
+			}
+		}
+	})
+}
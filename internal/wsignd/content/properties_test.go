@@ -0,0 +1,56 @@
+package content_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	"github.com/wrale/wrale-signage/internal/wsignd/content/memory"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+func TestService_PropertyLimits(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewRepository()
+	service := content.NewService(repo, passingValidator{}, nil, nil, nil, nil,
+		content.WithPropertyLimits(config.PropertyLimits{MaxCount: 2, MaxValueLength: 8}))
+	invalidField := func(t *testing.T, err error) string {
+		t.Helper()
+		var verr *werrors.ValidationError
+		require.ErrorAs(t, err, &verr)
+		require.Len(t, verr.Fields, 1)
+		return verr.Fields[0].Field
+	}
+	source := func(props map[string]string) *v1alpha1.ContentSource {
+		return &v1alpha1.ContentSource{
+			ObjectMeta: v1alpha1.ObjectMeta{Name: "menus"},
+			Spec:       v1alpha1.ContentSourceSpec{URL: "https://example.com/menus", Type: "menu", Properties: props},
+		}
+	}
+
+	_, err := service.CreateContent(ctx, source(map[string]string{"theme": strings.Repeat("v", 9)}), false)
+	assert.Equal(t, "spec.properties[theme]", invalidField(t, err))
+
+	_, err = service.CreateContent(ctx, source(map[string]string{"theme": strings.Repeat("v", 8), "lang": "en"}), false)
+	require.NoError(t, err, "limits are inclusive")
+
+	_, err = service.UpdateContent(ctx, "menus", &v1alpha1.ContentSourceUpdate{Properties: map[string]string{"size": "xl"}}, false)
+	assert.Equal(t, "properties", invalidField(t, err))
+
+	// A source stored before the limits were lowered can still be updated
+	// and have properties removed
+	strict := content.NewService(repo, passingValidator{}, nil, nil, nil, nil,
+		content.WithPropertyLimits(config.PropertyLimits{MaxCount: 1}))
+	url := "https://example.com/menus/v2"
+	_, err = strict.UpdateContent(ctx, "menus", &v1alpha1.ContentSourceUpdate{URL: &url}, false)
+	require.NoError(t, err)
+	result, err := strict.UpdateContent(ctx, "menus", &v1alpha1.ContentSourceUpdate{Properties: map[string]string{"lang": ""}}, false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"theme": strings.Repeat("v", 8)}, result.Spec.Properties)
+}
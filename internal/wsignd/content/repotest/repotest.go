@@ -0,0 +1,387 @@
+// Package repotest provides a conformance suite for content.Repository
+// implementations so that every store honours the same contract
+package repotest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/assignment"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// Run exercises a content source repository. newRepo must return an empty
+// repository each time it is called.
+func Run(t *testing.T, newRepo func(t *testing.T) content.Repository) {
+	ctx := context.Background()
+
+	newSource := func(name string) *v1alpha1.ContentSource {
+		return &v1alpha1.ContentSource{
+			ObjectMeta: v1alpha1.ObjectMeta{Name: name},
+			Spec: v1alpha1.ContentSourceSpec{
+				URL:          "https://example.com/" + name,
+				Type:         "static-page",
+				Properties:   map[string]string{"audience": "lobby"},
+				AllowedPaths: []string{"/today"},
+				Tags:         []string{"lobby"},
+				Fallbacks:    []string{"holding-page"},
+			},
+			Status: v1alpha1.ContentSourceStatus{Version: 1},
+		}
+	}
+
+	t.Run("create and get", func(t *testing.T) {
+		repo := newRepo(t)
+		source := newSource("welcome")
+		require.NoError(t, repo.CreateContent(ctx, source))
+		assert.NotZero(t, source.ID)
+		assert.False(t, source.CreatedAt.IsZero())
+		assert.False(t, source.UpdatedAt.IsZero())
+
+		stored, err := repo.GetContent(ctx, "welcome")
+		require.NoError(t, err)
+		assert.Equal(t, v1alpha1.TypeMeta{Kind: "ContentSource", APIVersion: "v1alpha1"}, stored.TypeMeta)
+		assert.Equal(t, source.ID, stored.ID)
+		assert.Equal(t, source.Spec, stored.Spec)
+		assert.Equal(t, 1, stored.Status.Version)
+		assert.Nil(t, stored.Status.Validation)
+		assert.True(t, source.CreatedAt.Equal(stored.CreatedAt))
+	})
+
+	t.Run("duplicate name conflicts", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.CreateContent(ctx, newSource("welcome")))
+
+		err := repo.CreateContent(ctx, newSource("welcome"))
+		assert.True(t, werrors.IsConflict(err), "got %v", err)
+	})
+
+	t.Run("list is ordered by name", func(t *testing.T) {
+		repo := newRepo(t)
+
+		sources, err := repo.ListContent(ctx)
+		require.NoError(t, err)
+		assert.NotNil(t, sources)
+		assert.Empty(t, sources)
+
+		for _, name := range []string{"menu", "alerts", "welcome"} {
+			require.NoError(t, repo.CreateContent(ctx, newSource(name)))
+		}
+		sources, err = repo.ListContent(ctx)
+		require.NoError(t, err)
+		var names []string
+		for _, s := range sources {
+			names = append(names, s.Name)
+		}
+		assert.Equal(t, []string{"alerts", "menu", "welcome"}, names)
+	})
+
+	t.Run("list by type and by names", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, name := range []string{"menu", "alerts", "welcome"} {
+			source := newSource(name)
+			if name == "alerts" {
+				source.Spec.Type = "ticker"
+			}
+			require.NoError(t, repo.CreateContent(ctx, source))
+		}
+		namesOf := func(sources []v1alpha1.ContentSource) []string {
+			names := []string{}
+			for _, s := range sources {
+				names = append(names, s.Name)
+			}
+			return names
+		}
+
+		pages, err := repo.ListContentByType(ctx, "static-page")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"menu", "welcome"}, namesOf(pages))
+
+		none, err := repo.ListContentByType(ctx, "video")
+		require.NoError(t, err)
+		assert.NotNil(t, none)
+		assert.Empty(t, none)
+	})
+
+	t.Run("get by names keys hits and leaves out misses", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, name := range []string{"alerts", "menu", "welcome"} {
+			require.NoError(t, repo.CreateContent(ctx, newSource(name)))
+		}
+
+		named, err := repo.GetContentByNames(ctx, []string{"welcome", "missing", "alerts", "welcome"})
+		require.NoError(t, err)
+		require.Len(t, named, 2)
+		assert.Equal(t, "alerts", named["alerts"].Name)
+		assert.Equal(t, "welcome", named["welcome"].Name)
+		assert.NotContains(t, named, "missing")
+		assert.NotContains(t, named, "menu")
+
+		none, err := repo.GetContentByNames(ctx, []string{"missing", "gone"})
+		require.NoError(t, err)
+		assert.NotNil(t, none)
+		assert.Empty(t, none)
+	})
+
+	t.Run("list by tags matches sources carrying every tag", func(t *testing.T) {
+		repo := newRepo(t)
+		for name, tags := range map[string][]string{
+			"alerts":  {"emergency", "lobby"},
+			"drills":  {"emergency"},
+			"menu":    {"cafeteria"},
+			"welcome": nil,
+		} {
+			source := newSource(name)
+			source.Spec.Tags = tags
+			require.NoError(t, repo.CreateContent(ctx, source))
+		}
+		namesOf := func(sources []v1alpha1.ContentSource) []string {
+			names := []string{}
+			for _, s := range sources {
+				names = append(names, s.Name)
+			}
+			return names
+		}
+
+		emergency, err := repo.ListContentByTags(ctx, []string{"emergency"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alerts", "drills"}, namesOf(emergency))
+
+		both, err := repo.ListContentByTags(ctx, []string{"emergency", "lobby"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alerts"}, namesOf(both))
+
+		none, err := repo.ListContentByTags(ctx, []string{"parking"})
+		require.NoError(t, err)
+		assert.NotNil(t, none)
+		assert.Empty(t, none)
+
+		untagged, err := repo.GetContent(ctx, "welcome")
+		require.NoError(t, err)
+		assert.Nil(t, untagged.Spec.Tags)
+	})
+
+	t.Run("update advances the version", func(t *testing.T) {
+		repo := newRepo(t)
+		source := newSource("welcome")
+		require.NoError(t, repo.CreateContent(ctx, source))
+
+		source.Spec.URL = "https://example.com/welcome-v2"
+		source.Spec.Properties = map[string]string{"audience": "everyone"}
+		source.Spec.AllowedPaths = []string{"/today", "/tomorrow"}
+		source.Spec.Tags = []string{"everyone", "lobby"}
+		source.Spec.Fallbacks = []string{"status-board", "holding-page"}
+		require.NoError(t, repo.UpdateContent(ctx, source))
+		assert.Equal(t, 2, source.Status.Version)
+		assert.False(t, source.UpdatedAt.Before(source.CreatedAt))
+
+		stored, err := repo.GetContent(ctx, "welcome")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/welcome-v2", stored.Spec.URL)
+		assert.Equal(t, "everyone", stored.Spec.Properties["audience"])
+		assert.Equal(t, []string{"/today", "/tomorrow"}, stored.Spec.AllowedPaths)
+		assert.Equal(t, []string{"everyone", "lobby"}, stored.Spec.Tags)
+		assert.Equal(t, []string{"status-board", "holding-page"}, stored.Spec.Fallbacks, "fallbacks keep their order")
+		assert.Equal(t, 2, stored.Status.Version)
+
+		source.Spec.Fallbacks = nil
+		require.NoError(t, repo.UpdateContent(ctx, source))
+		stored, err = repo.GetContent(ctx, "welcome")
+		require.NoError(t, err)
+		assert.Nil(t, stored.Spec.Fallbacks)
+	})
+
+	t.Run("stale update is rejected", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.CreateContent(ctx, newSource("welcome")))
+
+		first, err := repo.GetContent(ctx, "welcome")
+		require.NoError(t, err)
+		second, err := repo.GetContent(ctx, "welcome")
+		require.NoError(t, err)
+
+		first.Spec.URL = "https://example.com/first"
+		require.NoError(t, repo.UpdateContent(ctx, first))
+
+		second.Spec.URL = "https://example.com/second"
+		err = repo.UpdateContent(ctx, second)
+		assert.True(t, werrors.IsVersionMismatch(err), "got %v", err)
+
+		stored, err := repo.GetContent(ctx, "welcome")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/first", stored.Spec.URL)
+		assert.Equal(t, 2, stored.Status.Version)
+	})
+
+	t.Run("validation keeps the version", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.CreateContent(ctx, newSource("welcome")))
+
+		report := &v1alpha1.ContentValidationReport{
+			ValidatedAt: time.Now().UTC().Truncate(time.Millisecond),
+			Passed:      true,
+			HTTPStatus:  200,
+			Size:        2048,
+		}
+		require.NoError(t, repo.UpdateValidation(ctx, "welcome", report))
+
+		stored, err := repo.GetContent(ctx, "welcome")
+		require.NoError(t, err)
+		assert.True(t, stored.Status.IsHealthy)
+		assert.True(t, report.ValidatedAt.Equal(stored.Status.LastValidated))
+		require.NotNil(t, stored.Status.Validation)
+		assert.Equal(t, 200, stored.Status.Validation.HTTPStatus)
+		assert.Equal(t, 1, stored.Status.Version)
+	})
+
+	t.Run("missing sources are not found", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.GetContent(ctx, "missing")
+		assert.True(t, werrors.IsNotFound(err), "got %v", err)
+		err = repo.UpdateContent(ctx, newSource("missing"))
+		assert.True(t, werrors.IsNotFound(err), "got %v", err)
+		err = repo.UpdateValidation(ctx, "missing", &v1alpha1.ContentValidationReport{ValidatedAt: time.Now()})
+		assert.True(t, werrors.IsNotFound(err), "got %v", err)
+		_, err = repo.DeleteContent(ctx, "missing", false)
+		assert.True(t, werrors.IsNotFound(err), "got %v", err)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.CreateContent(ctx, newSource("welcome")))
+
+		removed, err := repo.DeleteContent(ctx, "welcome", false)
+		require.NoError(t, err)
+		assert.Empty(t, removed)
+		_, err = repo.GetContent(ctx, "welcome")
+		assert.True(t, werrors.IsNotFound(err), "got %v", err)
+	})
+}
+
+// RunReferences exercises the removal of content sources that assignments
+// refer to. newRepos must return an empty content repository and an
+// assignment repository sharing its storage each time it is called.
+func RunReferences(t *testing.T, newRepos func(t *testing.T) (content.Repository, assignment.Repository)) {
+	ctx := context.Background()
+
+	newSource := func(name string) *v1alpha1.ContentSource {
+		return &v1alpha1.ContentSource{
+			ObjectMeta: v1alpha1.ObjectMeta{Name: name},
+			Spec:       v1alpha1.ContentSourceSpec{URL: "https://example.com/" + name, Type: "static-page"},
+			Status:     v1alpha1.ContentSourceStatus{Version: 1},
+		}
+	}
+	newAssignment := func(name, source string) *v1alpha1.ContentAssignment {
+		return &v1alpha1.ContentAssignment{
+			ObjectMeta:      v1alpha1.ObjectMeta{Name: name},
+			Source:          source,
+			DisplaySelector: v1alpha1.DisplaySelector{SiteID: "hq", Zone: name},
+			ContentURL:      "https://example.com/" + source,
+		}
+	}
+	// setup stores the menus source, two assignments made from it and one
+	// made without a source
+	setup := func(t *testing.T) (content.Repository, assignment.Repository) {
+		sources, assignments := newRepos(t)
+		require.NoError(t, sources.CreateContent(ctx, newSource("menus")))
+		require.NoError(t, assignments.Create(ctx, newAssignment("cafeteria", "menus")))
+		require.NoError(t, assignments.Create(ctx, newAssignment("lobby", "menus")))
+		require.NoError(t, assignments.Create(ctx, newAssignment("reception", "")))
+		return sources, assignments
+	}
+	assigned := func(t *testing.T, assignments assignment.Repository, source string) []string {
+		list, err := assignments.List(ctx, assignment.Filter{Source: source})
+		require.NoError(t, err)
+		names := []string{}
+		for _, a := range list {
+			names = append(names, a.Name)
+		}
+		return names
+	}
+	refs := []v1alpha1.ResourceReference{
+		{Kind: "ContentAssignment", Name: "cafeteria"},
+		{Kind: "ContentAssignment", Name: "lobby"},
+	}
+
+	t.Run("sources in use are not removed", func(t *testing.T) {
+		sources, assignments := setup(t)
+
+		removed, err := sources.DeleteContent(ctx, "menus", false)
+		assert.Nil(t, removed)
+		assert.True(t, werrors.IsConflict(err), "got %v", err)
+		var inUse *content.SourceInUseError
+		require.ErrorAs(t, err, &inUse)
+		assert.Equal(t, "menus", inUse.Name)
+		assert.Equal(t, refs, inUse.References)
+
+		_, err = sources.GetContent(ctx, "menus")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"cafeteria", "lobby"}, assigned(t, assignments, "menus"))
+	})
+
+	t.Run("force removes the assignments too", func(t *testing.T) {
+		sources, assignments := setup(t)
+
+		removed, err := sources.DeleteContent(ctx, "menus", true)
+		require.NoError(t, err)
+		assert.Equal(t, refs, removed)
+
+		_, err = sources.GetContent(ctx, "menus")
+		assert.True(t, werrors.IsNotFound(err), "got %v", err)
+		assert.Empty(t, assigned(t, assignments, "menus"))
+		assert.Equal(t, []string{"reception"}, assigned(t, assignments, ""))
+	})
+
+	t.Run("assignments need an existing source", func(t *testing.T) {
+		_, assignments := newRepos(t)
+
+		err := assignments.Create(ctx, newAssignment("cafeteria", "menus"))
+		assert.True(t, werrors.IsNotFound(err), "got %v", err)
+		assert.Empty(t, assigned(t, assignments, ""))
+	})
+
+	t.Run("assignments made during removal", func(t *testing.T) {
+		sources, assignments := newRepos(t)
+
+		// Each round races an assignment from a source against the
+		// source's removal; whichever comes second must fail
+		for i := 0; i < 20; i++ {
+			name := fmt.Sprintf("source-%d", i)
+			require.NoError(t, sources.CreateContent(ctx, newSource(name)))
+
+			var (
+				wg                   sync.WaitGroup
+				createErr, deleteErr error
+			)
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				createErr = assignments.Create(ctx, newAssignment("from-"+name, name))
+			}()
+			go func() {
+				defer wg.Done()
+				_, deleteErr = sources.DeleteContent(ctx, name, false)
+			}()
+			wg.Wait()
+
+			if createErr == nil {
+				assert.True(t, werrors.IsConflict(deleteErr), "round %d: assignment made, but removal got %v", i, deleteErr)
+				_, err := sources.GetContent(ctx, name)
+				assert.NoError(t, err, "round %d: assigned source is gone", i)
+			} else {
+				assert.True(t, werrors.IsNotFound(createErr), "round %d: got %v", i, createErr)
+				assert.NoError(t, deleteErr, "round %d", i)
+				assert.Empty(t, assigned(t, assignments, name), "round %d", i)
+			}
+		}
+	})
+}
@@ -0,0 +1,229 @@
+package content
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// EventPartition is one of the tables events are stored in
+type EventPartition struct {
+	Name string
+	// From and To bound the timestamps of the events the partition holds,
+	// From inclusive and To exclusive; nil means unbounded
+	From, To *time.Time
+	// Default is set for the partition holding events no other covers
+	Default bool
+	// Bytes is the space the partition takes, including its indexes
+	Bytes int64
+	// Rows is an estimate of how many events the partition holds
+	Rows int64
+}
+
+// Expired reports whether every event the partition can hold is older than
+// cutoff
+func (p EventPartition) Expired(cutoff time.Time) bool {
+	return !p.Default && p.To != nil && !p.To.After(cutoff)
+}
+
+// EventStorage describes how stored events are laid out
+type EventStorage struct {
+	// Partitioned is set when events are stored in monthly partitions
+	Partitioned bool
+	// Tables lists the partitions, oldest first, or the one table events
+	// are stored in when they are not partitioned
+	Tables []EventPartition
+	// OldestEvent is the timestamp of the oldest event kept, nil when there
+	// are none
+	OldestEvent *time.Time
+}
+
+// EventRetentionStore removes old events
+type EventRetentionStore interface {
+	// DeleteEventsBefore deletes up to limit of the oldest events reported
+	// before cutoff in one transaction and returns how many it deleted.
+	// When archive is non-nil it is passed the events before the
+	// transaction commits, and an error from it keeps them.
+	DeleteEventsBefore(ctx context.Context, cutoff time.Time, limit int, archive func([]Event) error) (int, error)
+	// EventStorage describes the tables events are stored in
+	EventStorage(ctx context.Context) (*EventStorage, error)
+	// EnsureEventPartitions creates the monthly partitions missing for
+	// events reported up to through and returns their names. Stores that do
+	// not partition events create none.
+	EnsureEventPartitions(ctx context.Context, through time.Time) ([]string, error)
+	// DropEventPartition removes a partition and the events in it
+	DropEventPartition(ctx context.Context, name string) error
+}
+
+// RetentionOption configures event retention
+type RetentionOption func(*Retention)
+
+// WithRetentionClock sets the clock event ages are measured against
+func WithRetentionClock(now func() time.Time) RetentionOption {
+	return func(r *Retention) {
+		r.now = now
+	}
+}
+
+// WithEventArchive archives events to a before they are deleted
+func WithEventArchive(a *EventArchive) RetentionOption {
+	return func(r *Retention) {
+		r.archive = a
+	}
+}
+
+// Retention deletes events older than a maximum age and keeps the monthly
+// partitions events are stored in ready for the coming month. Events are
+// deleted in batches, each its own transaction, so that pruning a large
+// backlog never holds locks for long.
+type Retention struct {
+	store     EventRetentionStore
+	maxAge    time.Duration
+	batchSize int
+	archive   *EventArchive
+	logger    *slog.Logger
+	now       func() time.Time
+
+	mu      sync.Mutex
+	lastRun *v1alpha1.EventPruneRun
+}
+
+// NewRetention creates event retention over store. A maxAge of zero keeps
+// events forever while still preparing partitions.
+func NewRetention(store EventRetentionStore, maxAge time.Duration, batchSize int, logger *slog.Logger, opts ...RetentionOption) *Retention {
+	r := &Retention{store: store, maxAge: maxAge, batchSize: batchSize, logger: logger, now: time.Now}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run creates the partitions needed through next month, then deletes the
+// events older than the maximum age. Partitions holding only such events
+// are dropped whole; when archiving they are emptied first so that every
+// event is archived. It is meant to be run periodically.
+func (r *Retention) Run(ctx context.Context) error {
+	const op = "EventRetention.Run"
+
+	now := r.now()
+	created, err := r.store.EnsureEventPartitions(ctx, now.AddDate(0, 1, 0))
+	if err != nil {
+		return werrors.NewError("PARTITION_FAILED", "Failed to create event partitions", op, err)
+	}
+	if len(created) > 0 {
+		r.logger.Info("created content event partitions", "partitions", created)
+	}
+	if r.maxAge <= 0 {
+		return nil
+	}
+
+	cutoff := now.Add(-r.maxAge)
+	run := &v1alpha1.EventPruneRun{StartedAt: now, Cutoff: cutoff, Archived: r.archive != nil}
+	defer r.finish(run)
+
+	if r.archive == nil {
+		if run.DroppedPartitions, err = r.dropExpired(ctx, cutoff); err != nil {
+			return werrors.NewError("PRUNE_FAILED", "Failed to drop expired event partitions", op, err)
+		}
+	}
+	var archive func([]Event) error
+	if r.archive != nil {
+		archive = r.archive.Write
+	}
+	for ctx.Err() == nil {
+		n, err := r.store.DeleteEventsBefore(ctx, cutoff, r.batchSize, archive)
+		run.Deleted += n
+		if err != nil {
+			return werrors.NewError("PRUNE_FAILED", "Failed to delete expired events", op, err)
+		}
+		if n < r.batchSize {
+			break
+		}
+	}
+	dropped, err := r.dropExpired(ctx, cutoff)
+	run.DroppedPartitions = append(run.DroppedPartitions, dropped...)
+	if err != nil {
+		return werrors.NewError("PRUNE_FAILED", "Failed to drop expired event partitions", op, err)
+	}
+	return nil
+}
+
+// dropExpired drops the partitions holding only events older than cutoff
+func (r *Retention) dropExpired(ctx context.Context, cutoff time.Time) ([]string, error) {
+	storage, err := r.store.EventStorage(ctx)
+	if err != nil || !storage.Partitioned {
+		return nil, err
+	}
+	var dropped []string
+	for _, p := range storage.Tables {
+		if !p.Expired(cutoff) {
+			continue
+		}
+		if err := r.store.DropEventPartition(ctx, p.Name); err != nil {
+			// Another server may have dropped it since it was listed
+			if werrors.IsNotFound(err) {
+				continue
+			}
+			return dropped, err
+		}
+		dropped = append(dropped, p.Name)
+	}
+	return dropped, nil
+}
+
+// finish records a run and logs what it removed
+func (r *Retention) finish(run *v1alpha1.EventPruneRun) {
+	run.Duration = v1alpha1.Milliseconds(r.now().Sub(run.StartedAt).Milliseconds())
+	r.mu.Lock()
+	r.lastRun = run
+	r.mu.Unlock()
+
+	if run.Deleted > 0 || len(run.DroppedPartitions) > 0 {
+		r.logger.Info("pruned content events",
+			"cutoff", run.Cutoff,
+			"deleted", run.Deleted,
+			"droppedPartitions", run.DroppedPartitions,
+			"archived", run.Archived,
+		)
+	}
+}
+
+// Status describes the stored events and how they are kept. The last run
+// is the one made by this server.
+func (r *Retention) Status(ctx context.Context) (*v1alpha1.EventRetentionStatus, error) {
+	const op = "EventRetention.Status"
+
+	storage, err := r.store.EventStorage(ctx)
+	if err != nil {
+		return nil, werrors.NewError("LOOKUP_FAILED", "Failed to read event storage", op, err)
+	}
+	status := &v1alpha1.EventRetentionStatus{
+		TypeMeta:    v1alpha1.TypeMeta{Kind: "EventRetentionStatus", APIVersion: "v1alpha1"},
+		Archive:     r.archive != nil,
+		Partitioned: storage.Partitioned,
+		OldestEvent: storage.OldestEvent,
+		Tables:      make([]v1alpha1.EventTable, 0, len(storage.Tables)),
+	}
+	if r.maxAge > 0 {
+		status.Retention = r.maxAge.String()
+	}
+	for _, p := range storage.Tables {
+		status.TotalBytes += p.Bytes
+		status.Tables = append(status.Tables, v1alpha1.EventTable{
+			Name:          p.Name,
+			From:          p.From,
+			To:            p.To,
+			Default:       p.Default,
+			Bytes:         p.Bytes,
+			EstimatedRows: p.Rows,
+		})
+	}
+	r.mu.Lock()
+	status.LastRun = r.lastRun
+	r.mu.Unlock()
+	return status, nil
+}
@@ -0,0 +1,248 @@
+package content_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/clock"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	"github.com/wrale/wrale-signage/internal/wsignd/content/memory"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+func TestRetention_DeletesInBatches(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	repo := memory.NewRepository()
+	displayID := uuid.New()
+
+	// Seven expired events, two on the cutoff and after it
+	for i := 0; i < 7; i++ {
+		saveEventAt(t, repo, displayID, now.Add(-48*time.Hour-time.Duration(i)*time.Minute))
+	}
+	saveEventAt(t, repo, displayID, now.Add(-24*time.Hour))
+	saveEventAt(t, repo, displayID, now)
+
+	store := &countingStore{EventRetentionStore: repo}
+	retention := content.NewRetention(store, 24*time.Hour, 3, discardLogger(),
+		content.WithRetentionClock(clock.NewFake(now).Now))
+	require.NoError(t, retention.Run(ctx))
+
+	assert.Equal(t, []int{3, 3, 1}, store.batches, "batches stop once one comes back short")
+	remaining := storedEvents(t, repo)
+	require.Len(t, remaining, 2, "events at the cutoff are kept")
+
+	status, err := retention.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "24h0m0s", status.Retention)
+	assert.False(t, status.Partitioned)
+	require.NotNil(t, status.OldestEvent)
+	assert.True(t, status.OldestEvent.Equal(now.Add(-24*time.Hour)))
+	require.NotNil(t, status.LastRun)
+	assert.Equal(t, 7, status.LastRun.Deleted)
+	assert.True(t, status.LastRun.Cutoff.Equal(now.Add(-24*time.Hour)))
+
+	t.Run("without a maximum age nothing is deleted", func(t *testing.T) {
+		store := &countingStore{EventRetentionStore: repo}
+		require.NoError(t, content.NewRetention(store, 0, 3, discardLogger()).Run(ctx))
+		assert.Empty(t, store.batches)
+	})
+}
+
+func TestRetention_Archive(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	repo := memory.NewRepository()
+	displayID := uuid.New()
+
+	saveEventAt(t, repo, displayID, time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC))
+	saveEventAt(t, repo, displayID, time.Date(2026, 3, 2, 1, 0, 0, 0, time.UTC))
+	saveEventAt(t, repo, displayID, time.Date(2026, 3, 2, 2, 0, 0, 0, time.UTC))
+	saveEventAt(t, repo, displayID, now)
+
+	t.Run("a failed archive keeps the events", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "archive")
+		require.NoError(t, os.WriteFile(dir, nil, 0600), "a file where the directory should be")
+
+		retention := content.NewRetention(repo, 7*24*time.Hour, 2, discardLogger(),
+			content.WithRetentionClock(clock.NewFake(now).Now),
+			content.WithEventArchive(content.NewEventArchive(dir)))
+		require.Error(t, retention.Run(ctx))
+
+		remaining := storedEvents(t, repo)
+		assert.Len(t, remaining, 4)
+	})
+
+	t.Run("events are written by day before they are deleted", func(t *testing.T) {
+		dir := t.TempDir()
+		archive := content.NewEventArchive(dir)
+		retention := content.NewRetention(repo, 7*24*time.Hour, 2, discardLogger(),
+			content.WithRetentionClock(clock.NewFake(now).Now),
+			content.WithEventArchive(archive))
+		require.NoError(t, retention.Run(ctx))
+
+		remaining := storedEvents(t, repo)
+		assert.Len(t, remaining, 1)
+
+		assert.Len(t, readArchive(t, filepath.Join(dir, "content-events-2026-03-01.ndjson")), 1)
+		day := readArchive(t, filepath.Join(dir, "content-events-2026-03-02.ndjson"))
+		require.Len(t, day, 2)
+		assert.Equal(t, displayID, day[0].DisplayID)
+		assert.Equal(t, "https://example.com/menu", day[0].URL)
+
+		status, err := retention.Status(ctx)
+		require.NoError(t, err)
+		assert.True(t, status.Archive)
+		assert.True(t, status.LastRun.Archived)
+	})
+}
+
+func TestRetention_Partitions(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	month := func(m time.Month) *time.Time {
+		t := time.Date(2026, m, 1, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	newStore := func() *partitionedStore {
+		return &partitionedStore{
+			countingStore: countingStore{EventRetentionStore: memory.NewRepository()},
+			tables: []content.EventPartition{
+				{Name: "content_events_legacy", To: month(1)},
+				{Name: "content_events_p202601", From: month(1), To: month(2)},
+				{Name: "content_events_p202602", From: month(2), To: month(3)},
+				{Name: "content_events_p202603", From: month(3), To: month(4)},
+				{Name: "content_events_default", Default: true},
+			},
+		}
+	}
+
+	t.Run("expired partitions are dropped whole", func(t *testing.T) {
+		store := newStore()
+		store.gone = map[string]bool{"content_events_legacy": true}
+		retention := content.NewRetention(store, 30*24*time.Hour, 100, discardLogger(),
+			content.WithRetentionClock(clock.NewFake(now).Now))
+		require.NoError(t, retention.Run(ctx))
+
+		assert.Equal(t, now.AddDate(0, 1, 0), store.through)
+		assert.Equal(t, []string{"content_events_p202601"}, store.dropped,
+			"partitions already dropped elsewhere are skipped")
+
+		status, err := retention.Status(ctx)
+		require.NoError(t, err)
+		assert.True(t, status.Partitioned)
+		assert.Equal(t, []string{"content_events_p202601"}, status.LastRun.DroppedPartitions)
+	})
+
+	t.Run("archiving deletes the events before dropping", func(t *testing.T) {
+		store := newStore()
+		retention := content.NewRetention(store, 30*24*time.Hour, 100, discardLogger(),
+			content.WithRetentionClock(clock.NewFake(now).Now),
+			content.WithEventArchive(content.NewEventArchive(t.TempDir())))
+		require.NoError(t, retention.Run(ctx))
+
+		assert.Equal(t, []int{0}, store.batches)
+		assert.Equal(t, []string{"content_events_legacy", "content_events_p202601"}, store.dropped)
+	})
+}
+
+// countingStore records the size of each batch of events deleted
+type countingStore struct {
+	content.EventRetentionStore
+	batches []int
+}
+
+func (s *countingStore) DeleteEventsBefore(ctx context.Context, cutoff time.Time, limit int, archive func([]content.Event) error) (int, error) {
+	n, err := s.EventRetentionStore.DeleteEventsBefore(ctx, cutoff, limit, archive)
+	s.batches = append(s.batches, n)
+	return n, err
+}
+
+// partitionedStore reports fixed partitions and records which are dropped.
+// Partitions listed as gone were dropped by another server and are not
+// found when dropped again.
+type partitionedStore struct {
+	countingStore
+	tables  []content.EventPartition
+	gone    map[string]bool
+	through time.Time
+	dropped []string
+}
+
+func (s *partitionedStore) EventStorage(ctx context.Context) (*content.EventStorage, error) {
+	tables := append([]content.EventPartition(nil), s.tables...)
+	return &content.EventStorage{Partitioned: true, Tables: tables}, nil
+}
+
+func (s *partitionedStore) EnsureEventPartitions(ctx context.Context, through time.Time) ([]string, error) {
+	s.through = through
+	return nil, nil
+}
+
+func (s *partitionedStore) DropEventPartition(ctx context.Context, name string) error {
+	for i, p := range s.tables {
+		if p.Name == name && !s.gone[name] {
+			s.tables = append(s.tables[:i], s.tables[i+1:]...)
+			s.dropped = append(s.dropped, name)
+			return nil
+		}
+	}
+	return werrors.NewError("NOT_FOUND", "Event partition not found", "partitionedStore.DropEventPartition", werrors.ErrNotFound)
+}
+
+func saveEventAt(t *testing.T, repo *memory.Repository, displayID uuid.UUID, at time.Time) {
+	t.Helper()
+	require.NoError(t, repo.ProcessEvents(context.Background(), content.EventBatch{
+		DisplayID: displayID,
+		Events: []content.Event{{
+			ID:        uuid.New(),
+			DisplayID: displayID,
+			Type:      content.EventContentLoaded,
+			URL:       "https://example.com/menu",
+			Timestamp: at,
+		}},
+	}))
+}
+
+func storedEvents(t *testing.T, repo *memory.Repository) []content.Event {
+	t.Helper()
+	var events []content.Event
+	require.NoError(t, repo.ForEachEvent(context.Background(), content.EventQuery{Until: time.Now().AddDate(100, 0, 0)}, func(e content.Event) error {
+		events = append(events, e)
+		return nil
+	}))
+	return events
+}
+
+func readArchive(t *testing.T, path string) []v1alpha1.ContentEvent {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var events []v1alpha1.ContentEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e v1alpha1.ContentEvent
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		events = append(events, e)
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
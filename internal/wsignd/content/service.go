@@ -2,25 +2,58 @@ package content
 
 import (
 	"context"
+	"fmt"
 	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
 )
 
 type contentService struct {
-	processor EventProcessor
+	repo      Repository
+	validator Validator
+	events    EventStore
 	metrics   MetricsAggregator
 	monitor   HealthMonitor
+	notifier  Notifier
+	limits    config.PropertyLimits
 }
 
-func NewService(processor EventProcessor, metrics MetricsAggregator, monitor HealthMonitor) Service {
-	return &contentService{
-		processor: processor,
+// Option configures a content service
+type Option func(*contentService)
+
+// WithPropertyLimits sets the limits content source properties are held to
+func WithPropertyLimits(limits config.PropertyLimits) Option {
+	return func(s *contentService) {
+		s.limits = limits
+	}
+}
+
+// NewService creates a content service. notifier may be nil, in which case
+// updates that ask for display notification reach no displays. Properties
+// are held to config.DefaultPropertyLimits unless others are given with
+// WithPropertyLimits.
+func NewService(repo Repository, validator Validator, events EventStore, metrics MetricsAggregator, monitor HealthMonitor, notifier Notifier, opts ...Option) Service {
+	s := &contentService{
+		repo:      repo,
+		validator: validator,
+		events:    events,
 		metrics:   metrics,
 		monitor:   monitor,
+		notifier:  notifier,
+		limits:    config.DefaultPropertyLimits(),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 func (s *contentService) ReportEvents(ctx context.Context, batch EventBatch) error {
-	if err := s.processor.ProcessEvents(ctx, batch); err != nil {
+	if err := s.events.ProcessEvents(ctx, batch); err != nil {
 		return err
 	}
 
@@ -35,14 +68,106 @@ func (s *contentService) ReportEvents(ctx context.Context, batch EventBatch) err
 	return nil
 }
 
+// GetURLHealth reports the health of a content source's URL. URLs that no
+// content source uses are not found, so the call cannot be used to probe
+// arbitrary hosts.
 func (s *contentService) GetURLHealth(ctx context.Context, url string) (*HealthStatus, error) {
+	if err := s.requireSourceURL(ctx, "ContentService.GetURLHealth", url); err != nil {
+		return nil, err
+	}
 	return s.monitor.CheckHealth(ctx, url)
 }
 
+// GetURLMetrics aggregates the events reported for a content source's URL.
+// Like GetURLHealth it only answers for URLs a content source uses.
 func (s *contentService) GetURLMetrics(ctx context.Context, url string) (*URLMetrics, error) {
+	if err := s.requireSourceURL(ctx, "ContentService.GetURLMetrics", url); err != nil {
+		return nil, err
+	}
 	return s.metrics.GetURLMetrics(ctx, url)
 }
 
+// requireSourceURL returns a not found error unless some content source
+// uses url
+func (s *contentService) requireSourceURL(ctx context.Context, op, url string) error {
+	sources, err := s.repo.ListContent(ctx)
+	if err != nil {
+		return werrors.NewError("LOOKUP_FAILED", "Failed to retrieve content sources", op, err)
+	}
+	for _, source := range sources {
+		if source.Spec.URL == url {
+			return nil
+		}
+	}
+	return werrors.NewError("NOT_FOUND", fmt.Sprintf("No content source uses URL: %s", url), op, werrors.ErrNotFound)
+}
+
+// QueryEvents reads back stored events for analysis. The window is
+// checked here so that no caller can scan the whole event table.
+func (s *contentService) QueryEvents(ctx context.Context, query EventQuery, fn func(Event) error) error {
+	const op = "ContentService.QueryEvents"
+
+	if query.Since.IsZero() || query.Until.IsZero() {
+		return werrors.NewError("INVALID_INPUT", "since and until are required", op, werrors.ErrInvalidInput)
+	}
+	if !query.Since.Before(query.Until) {
+		return werrors.NewError("INVALID_INPUT", "since must be before until", op, werrors.ErrInvalidInput)
+	}
+	if query.Until.Sub(query.Since) > MaxEventQueryWindow {
+		return werrors.NewError("INVALID_INPUT",
+			fmt.Sprintf("window from since to until is longer than %d days", MaxEventQueryWindow/(24*time.Hour)),
+			op, werrors.ErrInvalidInput)
+	}
+	return s.events.ForEachEvent(ctx, query, fn)
+}
+
+// PrefetchStatus combines the progress displays reported downloading url
+// with the displays its assignments target. Displays that reported
+// progress but are no longer targeted are left out. Without a notifier the
+// targets cannot be found, so the displays that reported are counted
+// instead.
+func (s *contentService) PrefetchStatus(ctx context.Context, url string) (*v1alpha1.PrefetchStatus, error) {
+	const op = "ContentService.PrefetchStatus"
+
+	progress, err := s.events.PrefetchProgress(ctx, url)
+	if err != nil {
+		return nil, werrors.NewError("LOOKUP_FAILED", "Failed to retrieve prefetch progress", op, err)
+	}
+	reported := make(map[uuid.UUID]PrefetchProgress, len(progress))
+	targets := make([]uuid.UUID, 0, len(progress))
+	for _, p := range progress {
+		reported[p.DisplayID] = p
+		targets = append(targets, p.DisplayID)
+	}
+	if s.notifier != nil {
+		if targets, err = s.notifier.AssignedDisplays(ctx, url); err != nil {
+			return nil, werrors.NewError("LOOKUP_FAILED", "Failed to find the displays assigned the URL", op, err)
+		}
+	}
+
+	status := &v1alpha1.PrefetchStatus{
+		URL:      url,
+		Targeted: len(targets),
+		Displays: make([]v1alpha1.DisplayPrefetch, 0, len(targets)),
+	}
+	for _, id := range targets {
+		d := v1alpha1.DisplayPrefetch{DisplayID: id}
+		if p, ok := reported[id]; ok {
+			reportedAt := p.ReportedAt
+			d.BytesDownloaded = p.BytesDownloaded
+			d.BytesTotal = p.BytesTotal
+			d.Percent = p.Percent()
+			d.Complete = p.Complete()
+			d.ReportedAt = &reportedAt
+		}
+		if d.Complete {
+			status.Complete++
+		}
+		status.Displays = append(status.Displays, d)
+	}
+	return status, nil
+}
+
 func (s *contentService) ValidateContent(ctx context.Context, url string) error {
 	// Initial implementation just checks if we have recent successful loads
 	metrics, err := s.metrics.GetURLMetrics(ctx, url)
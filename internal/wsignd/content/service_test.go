@@ -8,6 +8,10 @@ import (
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
 )
 
 type mockProcessor struct {
@@ -19,6 +23,21 @@ func (m *mockProcessor) ProcessEvents(ctx context.Context, batch EventBatch) err
 	return args.Error(0)
 }
 
+func (m *mockProcessor) ForEachEvent(ctx context.Context, query EventQuery, fn func(Event) error) error {
+	args := m.Called(ctx, query)
+	for _, event := range args.Get(0).([]Event) {
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *mockProcessor) PrefetchProgress(ctx context.Context, url string) ([]PrefetchProgress, error) {
+	args := m.Called(ctx, url)
+	return args.Get(0).([]PrefetchProgress), args.Error(1)
+}
+
 type mockMetrics struct {
 	mock.Mock
 }
@@ -33,6 +52,84 @@ func (m *mockMetrics) GetURLMetrics(ctx context.Context, url string) (*URLMetric
 	return args.Get(0).(*URLMetrics), args.Error(1)
 }
 
+type mockNotifier struct {
+	mock.Mock
+}
+
+func (m *mockNotifier) NotifySourceUpdated(ctx context.Context, source *v1alpha1.ContentSource) int {
+	args := m.Called(ctx, source)
+	return args.Int(0)
+}
+
+func (m *mockNotifier) AssignedDisplays(ctx context.Context, url string) ([]uuid.UUID, error) {
+	args := m.Called(ctx, url)
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+type mockRepository struct {
+	mock.Mock
+}
+
+func (m *mockRepository) CreateContent(ctx context.Context, source *v1alpha1.ContentSource) error {
+	args := m.Called(ctx, source)
+	return args.Error(0)
+}
+
+func (m *mockRepository) GetContent(ctx context.Context, name string) (*v1alpha1.ContentSource, error) {
+	args := m.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*v1alpha1.ContentSource), args.Error(1)
+}
+
+func (m *mockRepository) ListContent(ctx context.Context) ([]v1alpha1.ContentSource, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]v1alpha1.ContentSource), args.Error(1)
+}
+
+func (m *mockRepository) ListContentByType(ctx context.Context, contentType string) ([]v1alpha1.ContentSource, error) {
+	args := m.Called(ctx, contentType)
+	return args.Get(0).([]v1alpha1.ContentSource), args.Error(1)
+}
+
+func (m *mockRepository) ListContentByTags(ctx context.Context, tags []string) ([]v1alpha1.ContentSource, error) {
+	args := m.Called(ctx, tags)
+	return args.Get(0).([]v1alpha1.ContentSource), args.Error(1)
+}
+
+func (m *mockRepository) GetContentByNames(ctx context.Context, names []string) (map[string]*v1alpha1.ContentSource, error) {
+	args := m.Called(ctx, names)
+	return args.Get(0).(map[string]*v1alpha1.ContentSource), args.Error(1)
+}
+
+func (m *mockRepository) UpdateContent(ctx context.Context, source *v1alpha1.ContentSource) error {
+	args := m.Called(ctx, source)
+	return args.Error(0)
+}
+
+func (m *mockRepository) DeleteContent(ctx context.Context, name string, force bool) ([]v1alpha1.ResourceReference, error) {
+	args := m.Called(ctx, name, force)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]v1alpha1.ResourceReference), args.Error(1)
+}
+
+func (m *mockRepository) UpdateValidation(ctx context.Context, name string, report *v1alpha1.ContentValidationReport) error {
+	args := m.Called(ctx, name, report)
+	return args.Error(0)
+}
+
+type mockValidator struct {
+	mock.Mock
+}
+
+func (m *mockValidator) Validate(ctx context.Context, url string) *v1alpha1.ContentValidationReport {
+	args := m.Called(ctx, url)
+	return args.Get(0).(*v1alpha1.ContentValidationReport)
+}
+
 type mockMonitor struct {
 	mock.Mock
 }
@@ -69,7 +166,7 @@ func TestService_ReportEvents(t *testing.T) {
 
 	monitor := new(mockMonitor)
 
-	service := NewService(processor, metrics, monitor)
+	service := NewService(nil, nil, processor, metrics, monitor, nil)
 	err := service.ReportEvents(ctx, batch)
 	assert.NoError(t, err)
 
@@ -77,6 +174,74 @@ func TestService_ReportEvents(t *testing.T) {
 	metrics.AssertExpectations(t)
 }
 
+func TestService_PrefetchStatus(t *testing.T) {
+	ctx := context.Background()
+	url := "https://example.com/promo.mp4"
+	at := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	done, partial, silent, unassigned := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	processor := new(mockProcessor)
+	processor.On("PrefetchProgress", ctx, url).Return([]PrefetchProgress{
+		{DisplayID: done, URL: url, BytesDownloaded: 1000, BytesTotal: 1000, ReportedAt: at},
+		{DisplayID: partial, URL: url, BytesDownloaded: 250, BytesTotal: 1000, ReportedAt: at},
+		{DisplayID: unassigned, URL: url, BytesDownloaded: 1000, BytesTotal: 1000, ReportedAt: at},
+	}, nil)
+
+	t.Run("counts the assigned displays", func(t *testing.T) {
+		notifier := new(mockNotifier)
+		notifier.On("AssignedDisplays", ctx, url).Return([]uuid.UUID{done, partial, silent}, nil)
+		service := NewService(nil, nil, processor, nil, nil, notifier)
+
+		status, err := service.PrefetchStatus(ctx, url)
+		require.NoError(t, err)
+		assert.Equal(t, url, status.URL)
+		assert.Equal(t, 1, status.Complete)
+		assert.Equal(t, 3, status.Targeted)
+		assert.Equal(t, []v1alpha1.DisplayPrefetch{
+			{DisplayID: done, BytesDownloaded: 1000, BytesTotal: 1000, Percent: 100, Complete: true, ReportedAt: &at},
+			{DisplayID: partial, BytesDownloaded: 250, BytesTotal: 1000, Percent: 25, ReportedAt: &at},
+			{DisplayID: silent},
+		}, status.Displays)
+	})
+
+	t.Run("without a notifier counts the displays that reported", func(t *testing.T) {
+		service := NewService(nil, nil, processor, nil, nil, nil)
+
+		status, err := service.PrefetchStatus(ctx, url)
+		require.NoError(t, err)
+		assert.Equal(t, 2, status.Complete)
+		assert.Equal(t, 3, status.Targeted)
+	})
+}
+
+func TestService_QueryEvents(t *testing.T) {
+	ctx := context.Background()
+	until := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	event := Event{ID: uuid.New(), Type: EventContentLoaded, URL: "https://example.com/menu", Timestamp: until.Add(-time.Hour)}
+
+	processor := new(mockProcessor)
+	service := NewService(nil, nil, processor, nil, nil, nil)
+
+	valid := EventQuery{Since: until.Add(-MaxEventQueryWindow), Until: until}
+	processor.On("ForEachEvent", ctx, valid).Return([]Event{event}, nil)
+	var got []Event
+	require.NoError(t, service.QueryEvents(ctx, valid, func(e Event) error {
+		got = append(got, e)
+		return nil
+	}))
+	assert.Equal(t, []Event{event}, got)
+
+	for _, query := range []EventQuery{
+		{Until: until},
+		{Since: until, Until: until},
+		{Since: until.Add(-MaxEventQueryWindow - time.Second), Until: until},
+	} {
+		err := service.QueryEvents(ctx, query, func(Event) error { return nil })
+		assert.True(t, werrors.IsInvalidInput(err), "%+v: %v", query, err)
+	}
+	processor.AssertExpectations(t)
+}
+
 func TestService_ValidateContent(t *testing.T) {
 	ctx := context.Background()
 	url := "https://example.com/content"
@@ -123,7 +288,7 @@ func TestService_ValidateContent(t *testing.T) {
 			metrics.On("GetURLMetrics", ctx, url).Return(tt.metrics, nil)
 			monitor := new(mockMonitor)
 
-			service := NewService(processor, metrics, monitor)
+			service := NewService(nil, nil, processor, metrics, monitor, nil)
 			err := service.ValidateContent(ctx, url)
 
 			if tt.wantError != nil {
@@ -150,13 +315,25 @@ func TestService_GetURLHealth(t *testing.T) {
 	metrics := new(mockMetrics)
 	monitor := new(mockMonitor)
 	monitor.On("CheckHealth", ctx, url).Return(status, nil)
+	repo := new(mockRepository)
+	repo.On("ListContent", ctx).Return([]v1alpha1.ContentSource{
+		{ObjectMeta: v1alpha1.ObjectMeta{Name: "content"}, Spec: v1alpha1.ContentSourceSpec{URL: url}},
+	}, nil)
 
-	service := NewService(processor, metrics, monitor)
+	service := NewService(repo, nil, processor, metrics, monitor, nil)
 	result, err := service.GetURLHealth(ctx, url)
 
 	assert.NoError(t, err)
 	assert.Equal(t, status, result)
 	monitor.AssertExpectations(t)
+
+	// URLs no source uses are not looked up at all
+	_, err = service.GetURLHealth(ctx, "https://internal.example.com/admin")
+	assert.True(t, werrors.IsNotFound(err), "got %v", err)
+	_, err = service.GetURLMetrics(ctx, "https://internal.example.com/admin")
+	assert.True(t, werrors.IsNotFound(err), "got %v", err)
+	monitor.AssertNumberOfCalls(t, "CheckHealth", 1)
+	metrics.AssertNotCalled(t, "GetURLMetrics", mock.Anything, mock.Anything)
 }
 
 func TestService_GetURLMetrics(t *testing.T) {
@@ -178,11 +355,423 @@ func TestService_GetURLMetrics(t *testing.T) {
 	metricsAggregator := new(mockMetrics)
 	metricsAggregator.On("GetURLMetrics", ctx, url).Return(metrics, nil)
 	monitor := new(mockMonitor)
+	repo := new(mockRepository)
+	repo.On("ListContent", ctx).Return([]v1alpha1.ContentSource{
+		{ObjectMeta: v1alpha1.ObjectMeta{Name: "content"}, Spec: v1alpha1.ContentSourceSpec{URL: url}},
+	}, nil)
 
-	service := NewService(processor, metricsAggregator, monitor)
+	service := NewService(repo, nil, processor, metricsAggregator, monitor, nil)
 	result, err := service.GetURLMetrics(ctx, url)
 
 	assert.NoError(t, err)
 	assert.Equal(t, metrics, result)
 	metricsAggregator.AssertExpectations(t)
 }
+
+func TestService_CreateContent(t *testing.T) {
+	ctx := context.Background()
+	failed := &v1alpha1.ContentValidationReport{
+		ValidatedAt: time.Now(),
+		HTTPStatus:  404,
+		Reason:      "unexpected status 404",
+	}
+
+	tests := []struct {
+		name      string
+		strict    bool
+		wantSaved bool
+		wantError error
+	}{
+		{
+			name:      "lenient_records_failure",
+			strict:    false,
+			wantSaved: true,
+		},
+		{
+			name:      "strict_rejects_failure",
+			strict:    true,
+			wantError: werrors.ErrInvalidInput,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mockRepository)
+			validator := new(mockValidator)
+			validator.On("Validate", ctx, "https://example.com/welcome").Return(failed)
+			if tt.wantSaved {
+				repo.On("CreateContent", ctx, mock.AnythingOfType("*v1alpha1.ContentSource")).Return(nil)
+			}
+
+			service := NewService(repo, validator, nil, nil, nil, nil)
+			source, err := service.CreateContent(ctx, &v1alpha1.ContentSource{
+				ObjectMeta: v1alpha1.ObjectMeta{Name: "welcome"},
+				Spec: v1alpha1.ContentSourceSpec{
+					URL:  "https://example.com/welcome",
+					Type: "static-page",
+				},
+			}, tt.strict)
+
+			if tt.wantError != nil {
+				assert.ErrorIs(t, err, tt.wantError)
+			} else {
+				require.NoError(t, err)
+				assert.False(t, source.Status.IsHealthy)
+				assert.Equal(t, failed, source.Status.Validation)
+				assert.Equal(t, 1, source.Status.Version)
+			}
+			repo.AssertExpectations(t)
+			validator.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_CreateContentRejectsBadAllowedPaths(t *testing.T) {
+	ctx := context.Background()
+	service := NewService(new(mockRepository), new(mockValidator), nil, nil, nil, nil)
+
+	for _, path := range []string{"breakfast", "/menus/../admin"} {
+		_, err := service.CreateContent(ctx, &v1alpha1.ContentSource{
+			ObjectMeta: v1alpha1.ObjectMeta{Name: "menus"},
+			Spec: v1alpha1.ContentSourceSpec{
+				URL:          "https://example.com/menus",
+				Type:         "menu",
+				AllowedPaths: []string{"/breakfast", path},
+			},
+		}, false)
+		assert.ErrorIs(t, err, werrors.ErrInvalidInput, path)
+	}
+}
+
+func TestService_CreateContentRejectsReservedNames(t *testing.T) {
+	ctx := context.Background()
+	service := NewService(new(mockRepository), new(mockValidator), nil, nil, nil, nil)
+
+	for _, name := range []string{"events", "health", "metrics"} {
+		_, err := service.CreateContent(ctx, &v1alpha1.ContentSource{
+			ObjectMeta: v1alpha1.ObjectMeta{Name: name},
+			Spec:       v1alpha1.ContentSourceSpec{URL: "https://example.com/" + name, Type: "static-page"},
+		}, false)
+		assert.ErrorIs(t, err, werrors.ErrInvalidInput, name)
+	}
+}
+
+func TestService_UpdateContentTags(t *testing.T) {
+	ctx := context.Background()
+	replaced := []string{"lobby"}
+
+	tests := []struct {
+		name   string
+		update v1alpha1.ContentSourceUpdate
+		want   []string
+	}{
+		{
+			name:   "add_merges",
+			update: v1alpha1.ContentSourceUpdate{AddTags: []string{"emergency", "cafeteria"}},
+			want:   []string{"cafeteria", "emergency", "menus"},
+		},
+		{
+			name:   "remove",
+			update: v1alpha1.ContentSourceUpdate{RemoveTags: []string{"menus"}},
+			want:   nil,
+		},
+		{
+			name:   "replace_then_add",
+			update: v1alpha1.ContentSourceUpdate{Tags: &replaced, AddTags: []string{"emergency"}},
+			want:   []string{"emergency", "lobby"},
+		},
+		{
+			name:   "untouched",
+			update: v1alpha1.ContentSourceUpdate{},
+			want:   []string{"menus"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mockRepository)
+			validator := new(mockValidator)
+			repo.On("GetContent", ctx, "menus").Return(&v1alpha1.ContentSource{
+				ObjectMeta: v1alpha1.ObjectMeta{Name: "menus"},
+				Spec: v1alpha1.ContentSourceSpec{
+					URL:  "https://example.com/menus",
+					Type: "menu",
+					Tags: []string{"menus"},
+				},
+			}, nil)
+			validator.On("Validate", ctx, "https://example.com/menus").
+				Return(&v1alpha1.ContentValidationReport{Passed: true})
+			repo.On("UpdateContent", ctx, mock.AnythingOfType("*v1alpha1.ContentSource")).Return(nil)
+
+			service := NewService(repo, validator, nil, nil, nil, nil)
+			source, err := service.UpdateContent(ctx, "menus", &tt.update, false)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, source.Spec.Tags)
+		})
+	}
+}
+
+func TestService_UpdateContentNotify(t *testing.T) {
+	ctx := context.Background()
+	newRepo := func() *mockRepository {
+		repo := new(mockRepository)
+		repo.On("GetContent", ctx, "menus").Return(&v1alpha1.ContentSource{
+			ObjectMeta: v1alpha1.ObjectMeta{Name: "menus"},
+			Spec:       v1alpha1.ContentSourceSpec{URL: "https://example.com/menus", Type: "menu"},
+		}, nil)
+		repo.On("UpdateContent", ctx, mock.AnythingOfType("*v1alpha1.ContentSource")).Return(nil)
+		return repo
+	}
+	newValidator := func() *mockValidator {
+		validator := new(mockValidator)
+		validator.On("Validate", ctx, mock.Anything).
+			Return(&v1alpha1.ContentValidationReport{Passed: true})
+		return validator
+	}
+	url := "https://example.com/menus/v2"
+
+	t.Run("notify reports reloaded displays", func(t *testing.T) {
+		notifier := new(mockNotifier)
+		notifier.On("NotifySourceUpdated", ctx, mock.MatchedBy(func(s *v1alpha1.ContentSource) bool {
+			return s.Spec.URL == url
+		})).Return(3).Once()
+
+		service := NewService(newRepo(), newValidator(), nil, nil, nil, notifier)
+		result, err := service.UpdateContent(ctx, "menus", &v1alpha1.ContentSourceUpdate{URL: &url, Notify: true}, false)
+		require.NoError(t, err)
+		require.NotNil(t, result.NotifiedDisplays)
+		assert.Equal(t, 3, *result.NotifiedDisplays)
+		notifier.AssertExpectations(t)
+	})
+
+	t.Run("updates do not notify by default", func(t *testing.T) {
+		notifier := new(mockNotifier)
+		service := NewService(newRepo(), newValidator(), nil, nil, nil, notifier)
+		result, err := service.UpdateContent(ctx, "menus", &v1alpha1.ContentSourceUpdate{URL: &url}, false)
+		require.NoError(t, err)
+		assert.Nil(t, result.NotifiedDisplays)
+		notifier.AssertNotCalled(t, "NotifySourceUpdated", mock.Anything, mock.Anything)
+	})
+
+	t.Run("without a notifier no displays are reached", func(t *testing.T) {
+		service := NewService(newRepo(), newValidator(), nil, nil, nil, nil)
+		result, err := service.UpdateContent(ctx, "menus", &v1alpha1.ContentSourceUpdate{URL: &url, Notify: true}, false)
+		require.NoError(t, err)
+		require.NotNil(t, result.NotifiedDisplays)
+		assert.Zero(t, *result.NotifiedDisplays)
+	})
+}
+
+func TestService_UpdateContentVersion(t *testing.T) {
+	ctx := context.Background()
+	url := "https://example.com/menus/v2"
+	mismatch := werrors.NewError("VERSION_MISMATCH", "resource was modified concurrently", "test", werrors.ErrVersionMismatch)
+	setup := func() (*mockRepository, Service) {
+		repo := new(mockRepository)
+		repo.On("GetContent", ctx, "menus").Return(&v1alpha1.ContentSource{
+			ObjectMeta: v1alpha1.ObjectMeta{Name: "menus"},
+			Spec:       v1alpha1.ContentSourceSpec{URL: "https://example.com/menus", Type: "menu"},
+			Status:     v1alpha1.ContentSourceStatus{Version: 3},
+		}, nil)
+		validator := new(mockValidator)
+		validator.On("Validate", ctx, mock.Anything).
+			Return(&v1alpha1.ContentValidationReport{Passed: true})
+		return repo, NewService(repo, validator, nil, nil, nil, nil)
+	}
+
+	t.Run("stale version is rejected", func(t *testing.T) {
+		repo, service := setup()
+		_, err := service.UpdateContent(ctx, "menus", &v1alpha1.ContentSourceUpdate{URL: &url, Version: 2}, false)
+		require.Error(t, err)
+		assert.True(t, werrors.IsVersionMismatch(err), "got %v", err)
+		assert.Contains(t, err.Error(), "at version 3, not 2")
+		repo.AssertNotCalled(t, "UpdateContent", mock.Anything, mock.Anything)
+	})
+
+	t.Run("current version is saved", func(t *testing.T) {
+		repo, service := setup()
+		repo.On("UpdateContent", ctx, mock.AnythingOfType("*v1alpha1.ContentSource")).Return(nil).Once()
+		result, err := service.UpdateContent(ctx, "menus", &v1alpha1.ContentSourceUpdate{URL: &url, Version: 3}, false)
+		require.NoError(t, err)
+		assert.Equal(t, url, result.Spec.URL)
+	})
+
+	t.Run("versioned update losing a race fails", func(t *testing.T) {
+		repo, service := setup()
+		repo.On("UpdateContent", ctx, mock.Anything).Return(mismatch).Once()
+		_, err := service.UpdateContent(ctx, "menus", &v1alpha1.ContentSourceUpdate{URL: &url, Version: 3}, false)
+		assert.True(t, werrors.IsVersionMismatch(err), "got %v", err)
+		repo.AssertNumberOfCalls(t, "UpdateContent", 1)
+	})
+
+	t.Run("unversioned update is reapplied", func(t *testing.T) {
+		repo, service := setup()
+		repo.On("UpdateContent", ctx, mock.Anything).Return(mismatch).Once()
+		repo.On("UpdateContent", ctx, mock.Anything).Return(nil).Once()
+		_, err := service.UpdateContent(ctx, "menus", &v1alpha1.ContentSourceUpdate{URL: &url}, false)
+		require.NoError(t, err)
+		repo.AssertNumberOfCalls(t, "GetContent", 2)
+		repo.AssertNumberOfCalls(t, "UpdateContent", 2)
+	})
+
+	t.Run("unversioned update gives up", func(t *testing.T) {
+		repo, service := setup()
+		repo.On("UpdateContent", ctx, mock.Anything).Return(mismatch)
+		_, err := service.UpdateContent(ctx, "menus", &v1alpha1.ContentSourceUpdate{URL: &url}, false)
+		assert.True(t, werrors.IsVersionMismatch(err), "got %v", err)
+		repo.AssertNumberOfCalls(t, "UpdateContent", maxUpdateAttempts)
+	})
+}
+
+func TestService_RefreshValidations(t *testing.T) {
+	ctx := context.Background()
+	sources := []v1alpha1.ContentSource{
+		{ObjectMeta: v1alpha1.ObjectMeta{Name: "welcome"}, Spec: v1alpha1.ContentSourceSpec{URL: "https://example.com/welcome"}},
+		{ObjectMeta: v1alpha1.ObjectMeta{Name: "menu"}, Spec: v1alpha1.ContentSourceSpec{URL: "https://example.com/menu"}},
+	}
+	passed := &v1alpha1.ContentValidationReport{ValidatedAt: time.Now(), Passed: true, HTTPStatus: 200}
+	failed := &v1alpha1.ContentValidationReport{ValidatedAt: time.Now(), Reason: "fetch failed"}
+
+	repo := new(mockRepository)
+	repo.On("ListContent", ctx).Return(sources, nil)
+	repo.On("UpdateValidation", ctx, "welcome", passed).Return(nil)
+	repo.On("UpdateValidation", ctx, "menu", failed).Return(nil)
+
+	validator := new(mockValidator)
+	validator.On("Validate", ctx, "https://example.com/welcome").Return(passed)
+	validator.On("Validate", ctx, "https://example.com/menu").Return(failed)
+
+	service := NewService(repo, validator, nil, nil, nil, nil)
+	err := service.RefreshValidations(ctx)
+	assert.NoError(t, err)
+
+	repo.AssertExpectations(t)
+	validator.AssertExpectations(t)
+}
+
+func TestResolveURL(t *testing.T) {
+	source := &v1alpha1.ContentSource{
+		ObjectMeta: v1alpha1.ObjectMeta{Name: "menu"},
+		Spec: v1alpha1.ContentSourceSpec{
+			URL:          "https://menu.example.com/boards/",
+			AllowedPaths: []string{"/specials", "/drinks/"},
+		},
+	}
+
+	for _, tt := range []struct {
+		path string
+		want string
+	}{
+		{"", "https://menu.example.com/boards/"},
+		{"/specials", "https://menu.example.com/boards/specials"},
+		{"/specials/friday", "https://menu.example.com/boards/specials/friday"},
+		{"/drinks", "https://menu.example.com/boards/drinks"},
+		{"/specials/a b?c#d", "https://menu.example.com/boards/specials/a%20b%3Fc%23d"},
+	} {
+		got, err := ResolveURL(source, tt.path)
+		require.NoError(t, err, tt.path)
+		assert.Equal(t, tt.want, got, tt.path)
+	}
+
+	// Nothing but a path beneath the source is accepted, so no other
+	// server can be reached
+	for _, path := range []string{
+		"http://169.254.169.254/latest/meta-data",
+		"//internal.example.com/specials",
+		"/specialsx",
+		"/specials/../../admin",
+		"/kitchen",
+		"specials",
+	} {
+		_, err := ResolveURL(source, path)
+		assert.True(t, werrors.IsInvalidInput(err), path)
+	}
+
+	_, err := ResolveURL(&v1alpha1.ContentSource{
+		ObjectMeta: v1alpha1.ObjectMeta{Name: "welcome"},
+		Spec:       v1alpha1.ContentSourceSpec{URL: "https://example.com/welcome"},
+	}, "/today")
+	assert.ErrorContains(t, err, `content source "welcome" does not allow paths`)
+}
+
+func TestService_ProbeSource(t *testing.T) {
+	ctx := context.Background()
+	source := &v1alpha1.ContentSource{
+		ObjectMeta: v1alpha1.ObjectMeta{Name: "menu"},
+		Spec:       v1alpha1.ContentSourceSpec{URL: "https://menu.example.com", AllowedPaths: []string{"/specials"}},
+	}
+	report := &v1alpha1.ContentValidationReport{Passed: true, HTTPStatus: 200}
+
+	repo := new(mockRepository)
+	repo.On("GetContent", ctx, "menu").Return(source, nil)
+	validator := new(mockValidator)
+	validator.On("Validate", ctx, "https://menu.example.com/specials").Return(report).Once()
+	service := NewService(repo, validator, nil, nil, nil, nil)
+
+	got, err := service.ProbeSource(ctx, "menu", "/specials")
+	require.NoError(t, err)
+	assert.Same(t, report, got)
+
+	_, err = service.ProbeSource(ctx, "menu", "http://127.0.0.1:8080/")
+	assert.True(t, werrors.IsInvalidInput(err))
+
+	// The probe is reported, not stored
+	repo.AssertNotCalled(t, "UpdateValidation", mock.Anything, mock.Anything, mock.Anything)
+	validator.AssertExpectations(t)
+}
+
+func TestService_ResolveSources(t *testing.T) {
+	ctx := context.Background()
+	welcome := &v1alpha1.ContentSource{ObjectMeta: v1alpha1.ObjectMeta{Name: "welcome"}}
+	alerts := &v1alpha1.ContentSource{ObjectMeta: v1alpha1.ObjectMeta{Name: "alerts"}}
+
+	t.Run("resolves every name with one lookup", func(t *testing.T) {
+		names := []string{"welcome", "alerts", "welcome"}
+		repo := new(mockRepository)
+		repo.On("GetContentByNames", ctx, names).Return(map[string]*v1alpha1.ContentSource{
+			"welcome": welcome,
+			"alerts":  alerts,
+		}, nil).Once()
+
+		service := NewService(repo, nil, nil, nil, nil, nil)
+		resolved, err := service.ResolveSources(ctx, names)
+		require.NoError(t, err)
+		assert.Same(t, welcome, resolved["welcome"])
+		assert.Same(t, alerts, resolved["alerts"])
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("names every missing reference", func(t *testing.T) {
+		names := []string{"menu", "welcome", "ticker", "menu"}
+		repo := new(mockRepository)
+		repo.On("GetContentByNames", ctx, names).Return(map[string]*v1alpha1.ContentSource{
+			"welcome": welcome,
+		}, nil)
+
+		service := NewService(repo, nil, nil, nil, nil, nil)
+		_, err := service.ResolveSources(ctx, names)
+		require.Error(t, err)
+		assert.True(t, werrors.IsNotFound(err))
+
+		var missing *MissingSourcesError
+		require.ErrorAs(t, err, &missing)
+		assert.Equal(t, []string{"menu", "ticker"}, missing.Names)
+		assert.Contains(t, err.Error(), `"menu", "ticker"`)
+	})
+
+	t.Run("list by names is ordered by name", func(t *testing.T) {
+		names := []string{"welcome", "alerts"}
+		repo := new(mockRepository)
+		repo.On("GetContentByNames", ctx, names).Return(map[string]*v1alpha1.ContentSource{
+			"welcome": welcome,
+			"alerts":  alerts,
+		}, nil)
+
+		service := NewService(repo, nil, nil, nil, nil, nil)
+		sources, err := service.GetContentByNames(ctx, names)
+		require.NoError(t, err)
+		require.Len(t, sources, 2)
+		assert.Equal(t, "alerts", sources[0].Name)
+		assert.Equal(t, "welcome", sources[1].Name)
+	})
+}
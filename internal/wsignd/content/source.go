@@ -0,0 +1,610 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/properties"
+)
+
+// CreateContent validates and stores a new content source. A failing
+// validation is recorded on the source's status unless strict is set, in
+// which case the source is rejected. When a source already has the name the
+// error is a *SourceExistsError carrying it.
+func (s *contentService) CreateContent(ctx context.Context, source *v1alpha1.ContentSource, strict bool) (*v1alpha1.ContentSource, error) {
+	const op = "ContentService.CreateContent"
+
+	source.Name = normalizeSourceName(source.Name)
+	source.Spec.Fallbacks = normalizeFallbacks(source.Spec.Fallbacks)
+	if err := validateSourceSpec(source.Name, source.Spec, "spec.", s.limits); err != nil {
+		return nil, werrors.NewError("INVALID_INPUT", err.Error(), op, err)
+	}
+	if err := s.checkFallbacks(ctx, op, source.Name, source.Spec.Fallbacks, "spec."); err != nil {
+		return nil, err
+	}
+
+	report := s.validator.Validate(ctx, source.Spec.URL)
+	if strict && !report.Passed {
+		return nil, werrors.NewError("VALIDATION_FAILED",
+			fmt.Sprintf("content validation failed: %s", report.Reason), op, werrors.ErrInvalidInput)
+	}
+
+	source.TypeMeta = v1alpha1.TypeMeta{Kind: "ContentSource", APIVersion: "v1alpha1"}
+	source.Spec.Tags = normalizeTags(source.Spec.Tags)
+	source.Status = v1alpha1.ContentSourceStatus{Version: 1}
+	applyValidation(&source.Status, report)
+
+	if err := s.repo.CreateContent(ctx, source); err != nil {
+		if werrors.IsConflict(err) {
+			if existing, getErr := s.repo.GetContent(ctx, source.Name); getErr == nil {
+				err = &SourceExistsError{Existing: existing}
+			}
+			return nil, werrors.NewError("ALREADY_EXISTS",
+				fmt.Sprintf("content source already exists: %s", source.Name), op, err)
+		}
+		return nil, werrors.NewError("SAVE_FAILED", "Failed to save content source", op, err)
+	}
+
+	return source, nil
+}
+
+// GetContent retrieves a content source by name, ignoring case.
+func (s *contentService) GetContent(ctx context.Context, name string) (*v1alpha1.ContentSource, error) {
+	const op = "ContentService.GetContent"
+
+	name = normalizeSourceName(name)
+	source, err := s.repo.GetContent(ctx, name)
+	if err != nil {
+		if werrors.IsNotFound(err) {
+			return nil, werrors.NewError("NOT_FOUND", fmt.Sprintf("Content source not found: %s", name), op, err)
+		}
+		return nil, werrors.NewError("LOOKUP_FAILED", "Failed to retrieve content source", op, err)
+	}
+
+	return source, nil
+}
+
+// ListContent retrieves all content sources.
+func (s *contentService) ListContent(ctx context.Context) ([]v1alpha1.ContentSource, error) {
+	const op = "ContentService.ListContent"
+
+	sources, err := s.repo.ListContent(ctx)
+	if err != nil {
+		return nil, werrors.NewError("LIST_FAILED", "Failed to list content sources", op, err)
+	}
+
+	return sources, nil
+}
+
+// ListContentByType retrieves the content sources of one type.
+func (s *contentService) ListContentByType(ctx context.Context, contentType string) ([]v1alpha1.ContentSource, error) {
+	const op = "ContentService.ListContentByType"
+
+	sources, err := s.repo.ListContentByType(ctx, contentType)
+	if err != nil {
+		return nil, werrors.NewError("LIST_FAILED", "Failed to list content sources", op, err)
+	}
+
+	return sources, nil
+}
+
+// ListContentByTags retrieves the content sources carrying all of tags.
+func (s *contentService) ListContentByTags(ctx context.Context, tags []string) ([]v1alpha1.ContentSource, error) {
+	const op = "ContentService.ListContentByTags"
+
+	sources, err := s.repo.ListContentByTags(ctx, normalizeTags(tags))
+	if err != nil {
+		return nil, werrors.NewError("LIST_FAILED", "Failed to list content sources", op, err)
+	}
+
+	return sources, nil
+}
+
+// GetContentByNames retrieves several content sources in one call.
+func (s *contentService) GetContentByNames(ctx context.Context, names []string) ([]v1alpha1.ContentSource, error) {
+	const op = "ContentService.GetContentByNames"
+
+	if len(names) == 0 {
+		return []v1alpha1.ContentSource{}, nil
+	}
+
+	found, err := s.repo.GetContentByNames(ctx, normalizeSourceNames(names))
+	if err != nil {
+		return nil, werrors.NewError("LOOKUP_FAILED", "Failed to retrieve content sources", op, err)
+	}
+
+	sources := make([]v1alpha1.ContentSource, 0, len(found))
+	for _, source := range found {
+		sources = append(sources, *source)
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Name < sources[j].Name })
+
+	return sources, nil
+}
+
+// ResolveSources looks up the sources a sequence refers to with one
+// repository call, so a long sequence costs a single round trip. Every
+// missing name is reported, not just the first, so a broken sequence can be
+// fixed in one pass. Names are matched ignoring case, and the result is
+// keyed by the names as given.
+func (s *contentService) ResolveSources(ctx context.Context, names []string) (map[string]*v1alpha1.ContentSource, error) {
+	const op = "ContentService.ResolveSources"
+
+	if len(names) == 0 {
+		return map[string]*v1alpha1.ContentSource{}, nil
+	}
+
+	found, err := s.repo.GetContentByNames(ctx, normalizeSourceNames(names))
+	if err != nil {
+		return nil, werrors.NewError("LOOKUP_FAILED", "Failed to retrieve content sources", op, err)
+	}
+
+	resolved := make(map[string]*v1alpha1.ContentSource, len(names))
+	var missing []string
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if source, ok := found[normalizeSourceName(name)]; ok {
+			resolved[name] = source
+			continue
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		missing = append(missing, name)
+	}
+	if len(missing) > 0 {
+		missingErr := &MissingSourcesError{Names: missing}
+		return nil, werrors.NewError("SOURCE_NOT_FOUND", missingErr.Error(), op, missingErr)
+	}
+
+	return resolved, nil
+}
+
+// maxUpdateAttempts bounds how often an unconditional update is reapplied
+// when another update saves first
+const maxUpdateAttempts = 3
+
+// UpdateContent applies a partial update and revalidates the content. Empty
+// property values remove the property. Notification happens only after the
+// update is saved, so displays reload into the new content. An update naming
+// a version fails when the source has moved on; one without a version is
+// reapplied to the latest source when it loses a race.
+func (s *contentService) UpdateContent(ctx context.Context, name string, update *v1alpha1.ContentSourceUpdate, strict bool) (*v1alpha1.ContentSourceUpdateResult, error) {
+	const op = "ContentService.UpdateContent"
+
+	name = normalizeSourceName(name)
+	for attempt := 1; ; attempt++ {
+		source, err := s.GetContent(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if update.Version != 0 && source.Status.Version != update.Version {
+			return nil, werrors.NewError("VERSION_MISMATCH",
+				fmt.Sprintf("Content source %s is at version %d, not %d", name, source.Status.Version, update.Version),
+				op, werrors.ErrVersionMismatch)
+		}
+
+		if update.URL != nil {
+			source.Spec.URL = *update.URL
+		}
+		if update.AllowedPaths != nil {
+			source.Spec.AllowedPaths = *update.AllowedPaths
+		}
+		source.Spec.Tags = updateTags(source.Spec.Tags, update)
+		if update.Fallbacks != nil {
+			source.Spec.Fallbacks = normalizeFallbacks(*update.Fallbacks)
+		}
+		for k, v := range update.Properties {
+			if v == "" {
+				delete(source.Spec.Properties, k)
+				continue
+			}
+			if source.Spec.Properties == nil {
+				source.Spec.Properties = make(map[string]string)
+			}
+			source.Spec.Properties[k] = v
+		}
+
+		// Updates that only remove properties are not held to the limits,
+		// so that sources stored before they were lowered can be fixed
+		limits := s.limits
+		if !setsProperties(update) {
+			limits = config.PropertyLimits{}
+		}
+		if err := validateSourceSpec(source.Name, source.Spec, "", limits); err != nil {
+			return nil, werrors.NewError("INVALID_INPUT", err.Error(), op, err)
+		}
+		if update.Fallbacks != nil {
+			if err := s.checkFallbacks(ctx, op, source.Name, source.Spec.Fallbacks, ""); err != nil {
+				return nil, err
+			}
+		}
+
+		report := s.validator.Validate(ctx, source.Spec.URL)
+		if strict && !report.Passed {
+			return nil, werrors.NewError("VALIDATION_FAILED",
+				fmt.Sprintf("content validation failed: %s", report.Reason), op, werrors.ErrInvalidInput)
+		}
+		applyValidation(&source.Status, report)
+
+		err = s.repo.UpdateContent(ctx, source)
+		switch {
+		case err == nil:
+			result := &v1alpha1.ContentSourceUpdateResult{ContentSource: *source}
+			if update.Notify {
+				notified := 0
+				if s.notifier != nil {
+					notified = s.notifier.NotifySourceUpdated(ctx, source)
+				}
+				result.NotifiedDisplays = &notified
+			}
+			return result, nil
+		case werrors.IsNotFound(err):
+			return nil, werrors.NewError("NOT_FOUND", fmt.Sprintf("Content source not found: %s", name), op, err)
+		case !werrors.IsVersionMismatch(err):
+			return nil, werrors.NewError("SAVE_FAILED", "Failed to save content source", op, err)
+		case update.Version != 0 || attempt == maxUpdateAttempts:
+			return nil, werrors.NewError("VERSION_MISMATCH",
+				fmt.Sprintf("Content source %s was modified concurrently", name), op, err)
+		}
+	}
+}
+
+// DeleteContent removes a content source, named ignoring case. The
+// assignments made from it are removed too when force is set; otherwise
+// their existence fails the removal.
+func (s *contentService) DeleteContent(ctx context.Context, name string, force bool) (*v1alpha1.ContentSourceDeletion, error) {
+	const op = "ContentService.DeleteContent"
+
+	name = normalizeSourceName(name)
+	removed, err := s.repo.DeleteContent(ctx, name, force)
+	if err != nil {
+		var inUse *SourceInUseError
+		switch {
+		case errors.As(err, &inUse):
+			return nil, werrors.NewError("SOURCE_IN_USE", inUse.Error(), op, err)
+		case werrors.IsNotFound(err):
+			return nil, werrors.NewError("NOT_FOUND", fmt.Sprintf("Content source not found: %s", name), op, err)
+		}
+		return nil, werrors.NewError("DELETE_FAILED", "Failed to delete content source", op, err)
+	}
+
+	if removed == nil {
+		removed = []v1alpha1.ResourceReference{}
+	}
+	return &v1alpha1.ContentSourceDeletion{
+		TypeMeta: v1alpha1.TypeMeta{Kind: "ContentSourceDeletion", APIVersion: "v1alpha1"},
+		Name:     name,
+		Removed:  removed,
+	}, nil
+}
+
+// ValidateSource validates a content source immediately and stores the report.
+func (s *contentService) ValidateSource(ctx context.Context, name string) (*v1alpha1.ContentSource, error) {
+	const op = "ContentService.ValidateSource"
+
+	source, err := s.GetContent(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	report := s.validator.Validate(ctx, source.Spec.URL)
+	if err := s.repo.UpdateValidation(ctx, source.Name, report); err != nil {
+		return nil, werrors.NewError("SAVE_FAILED", "Failed to save validation report", op, err)
+	}
+	applyValidation(&source.Status, report)
+
+	return source, nil
+}
+
+// ProbeSource fetches the URL that path selects beneath a content source,
+// as ResolveURL resolves it, and reports what was observed. Unlike
+// ValidateSource the report is not stored. Only URLs beneath a registered
+// source are ever fetched, whatever path is given.
+func (s *contentService) ProbeSource(ctx context.Context, name, path string) (*v1alpha1.ContentValidationReport, error) {
+	const op = "ContentService.ProbeSource"
+
+	source, err := s.GetContent(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	target, err := ResolveURL(source, path)
+	if err != nil {
+		return nil, werrors.NewError("INVALID_PATH", err.Error(), op, err)
+	}
+
+	return s.validator.Validate(ctx, target), nil
+}
+
+// ResolveURL returns the URL a display is sent for path beneath source:
+// the source URL itself for an empty path, or the source URL with path
+// appended when path is one of the source's allowed paths or lies beneath
+// one. The URL always has the scheme and host of the source, so no other
+// server can be reached through a path.
+func ResolveURL(source *v1alpha1.ContentSource, path string) (string, error) {
+	if path == "" {
+		return source.Spec.URL, nil
+	}
+
+	verr := &werrors.ValidationError{}
+	switch {
+	case !strings.HasPrefix(path, "/") || strings.Contains(path, ".."):
+		verr.Invalid("path", fmt.Sprintf("path %q must start with / and may not contain ..", path))
+	case len(source.Spec.AllowedPaths) == 0:
+		verr.Invalid("path", fmt.Sprintf("content source %q does not allow paths", source.Name))
+	case !pathAllowed(source.Spec.AllowedPaths, path):
+		verr.Invalid("path", fmt.Sprintf("path %q is not allowed for content source %q; allowed paths: %s",
+			path, source.Name, strings.Join(source.Spec.AllowedPaths, ", ")))
+	}
+	if err := verr.Err(); err != nil {
+		return "", err
+	}
+
+	base, err := url.Parse(source.Spec.URL)
+	if err != nil {
+		return "", fmt.Errorf("content source %q has an invalid URL: %w", source.Name, err)
+	}
+	u := *base
+	u.Path = strings.TrimSuffix(base.Path, "/") + path
+	u.RawPath = ""
+
+	// Read back what would be sent, so that nothing in the path can move
+	// the request to another host
+	resolved, err := url.Parse(u.String())
+	if err != nil || resolved.Scheme != base.Scheme || resolved.Host != base.Host {
+		verr.Invalid("path", fmt.Sprintf("path %q leads outside content source %q", path, source.Name))
+		return "", verr
+	}
+	return resolved.String(), nil
+}
+
+// pathAllowed reports whether path equals or lies beneath an allowed path
+func pathAllowed(allowed []string, path string) bool {
+	for _, p := range allowed {
+		p = strings.TrimSuffix(p, "/")
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// RefreshValidations revalidates every content source. It keeps going after
+// individual failures and returns them together.
+func (s *contentService) RefreshValidations(ctx context.Context) error {
+	const op = "ContentService.RefreshValidations"
+
+	sources, err := s.ListContent(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, source := range sources {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+		report := s.validator.Validate(ctx, source.Spec.URL)
+		if err := s.repo.UpdateValidation(ctx, source.Name, report); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", source.Name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return werrors.NewError("REFRESH_FAILED", "Failed to refresh some content validations", op, errors.Join(errs...))
+	}
+	return nil
+}
+
+// applyValidation copies a validation report onto a content source status
+func applyValidation(status *v1alpha1.ContentSourceStatus, report *v1alpha1.ContentValidationReport) {
+	status.Validation = report
+	status.LastValidated = report.ValidatedAt
+	status.IsHealthy = report.Passed
+}
+
+// normalizeSourceName folds a content source name to the lower case it is
+// stored under, so names differing only in case refer to one source
+func normalizeSourceName(name string) string {
+	return strings.ToLower(name)
+}
+
+// normalizeSourceNames normalizes each of names
+func normalizeSourceNames(names []string) []string {
+	normalized := make([]string, len(names))
+	for i, name := range names {
+		normalized[i] = normalizeSourceName(name)
+	}
+	return normalized
+}
+
+// reservedSourceNames are taken by content API routes, so sources with
+// these names could not be addressed
+var reservedSourceNames = map[string]bool{"events": true, "health": true, "metrics": true}
+
+// validateSourceSpec checks the required fields of a content source,
+// reporting every invalid one. Spec fields are named with specPath before
+// them, "spec." for a whole source and nothing for an update, whose fields
+// sit at the top level. Properties are held to limits.
+func validateSourceSpec(name string, spec v1alpha1.ContentSourceSpec, specPath string, limits config.PropertyLimits) error {
+	verr := &werrors.ValidationError{}
+	if name == "" {
+		verr.Required("metadata.name")
+	} else if reservedSourceNames[name] {
+		verr.Invalid("metadata.name", fmt.Sprintf("%q is reserved and cannot name a content source", name))
+	}
+	if spec.Type == "" {
+		verr.Required(specPath + "type")
+	}
+	u, err := url.Parse(spec.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		verr.Invalid(specPath+"url", "must be an absolute http(s) URL")
+	}
+	for _, tag := range spec.Tags {
+		if tag == "" || strings.ContainsAny(tag, ", \t\n") {
+			verr.Invalid(specPath+"tags", fmt.Sprintf("tag %q must be non-empty without spaces or commas", tag))
+		}
+	}
+	for i, p := range spec.AllowedPaths {
+		if !strings.HasPrefix(p, "/") || strings.Contains(p, "..") {
+			verr.Invalid(fmt.Sprintf("%sallowedPaths[%d]", specPath, i), fmt.Sprintf("allowed path %q must start with / and may not contain ..", p))
+		}
+	}
+	seen := make(map[string]bool, len(spec.Fallbacks))
+	for i, fallback := range spec.Fallbacks {
+		field := fmt.Sprintf("%sfallbacks[%d]", specPath, i)
+		switch {
+		case fallback == "":
+			verr.Invalid(field, "fallback source name must not be empty")
+		case fallback == name:
+			verr.Invalid(field, "a content source cannot fall back to itself")
+		case seen[fallback]:
+			verr.Invalid(field, fmt.Sprintf("fallback %q is listed more than once", fallback))
+		}
+		seen[fallback] = true
+	}
+	properties.Validate(verr, limits, specPath+"properties", spec.Properties)
+	return verr.Err()
+}
+
+// setsProperties reports whether update adds or changes any property
+func setsProperties(update *v1alpha1.ContentSourceUpdate) bool {
+	for _, v := range update.Properties {
+		if v != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeFallbacks folds fallback names as source names are folded,
+// keeping their order
+func normalizeFallbacks(fallbacks []string) []string {
+	if len(fallbacks) == 0 {
+		return nil
+	}
+	return normalizeSourceNames(fallbacks)
+}
+
+// checkFallbacks verifies that the fallbacks of the named source exist and
+// that following fallbacks from it never comes back to a source already
+// followed. The sources reachable from it are looked up a level at a time.
+// Fallbacks of stored sources that no longer exist are not reported here;
+// resolution skips them.
+func (s *contentService) checkFallbacks(ctx context.Context, op, name string, fallbacks []string, specPath string) error {
+	if len(fallbacks) == 0 {
+		return nil
+	}
+
+	graph := map[string][]string{name: fallbacks}
+	verr := &werrors.ValidationError{}
+	pending := fallbacks
+	for depth := 0; len(pending) > 0; depth++ {
+		found, err := s.repo.GetContentByNames(ctx, pending)
+		if err != nil {
+			return werrors.NewError("LOOKUP_FAILED", "Failed to retrieve fallback sources", op, err)
+		}
+		var next []string
+		for i, n := range pending {
+			source, ok := found[n]
+			if !ok {
+				if depth == 0 {
+					verr.Invalid(fmt.Sprintf("%sfallbacks[%d]", specPath, i), fmt.Sprintf("content source %q does not exist", n))
+				}
+				graph[n] = nil
+				continue
+			}
+			graph[n] = source.Spec.Fallbacks
+			for _, f := range source.Spec.Fallbacks {
+				if _, known := graph[f]; !known && !slices.Contains(next, f) && !slices.Contains(pending, f) {
+					next = append(next, f)
+				}
+			}
+		}
+		pending = next
+	}
+
+	if cycle := fallbackCycle(graph, name); cycle != nil {
+		verr.Invalid(specPath+"fallbacks", "fallbacks would form a cycle: "+strings.Join(cycle, " -> "))
+	}
+	if err := verr.Err(); err != nil {
+		return werrors.NewError("INVALID_INPUT", err.Error(), op, err)
+	}
+	return nil
+}
+
+// fallbackCycle follows the fallbacks in graph depth first from start and
+// returns the first path found that comes back to a source already on it,
+// ending with that source, or nil when there is none
+func fallbackCycle(graph map[string][]string, start string) []string {
+	var path []string
+	onPath := make(map[string]bool)
+	done := make(map[string]bool)
+
+	var visit func(name string) bool
+	visit = func(name string) bool {
+		path = append(path, name)
+		if onPath[name] {
+			return true
+		}
+		if done[name] {
+			path = path[:len(path)-1]
+			return false
+		}
+		onPath[name] = true
+		for _, next := range graph[name] {
+			if visit(next) {
+				return true
+			}
+		}
+		onPath[name] = false
+		done[name] = true
+		path = path[:len(path)-1]
+		return false
+	}
+
+	if visit(start) {
+		return path
+	}
+	return nil
+}
+
+// updateTags applies a tag update: a replacement list first, then additions
+// and removals merged into what is left
+func updateTags(current []string, update *v1alpha1.ContentSourceUpdate) []string {
+	if update.Tags != nil {
+		current = *update.Tags
+	}
+	removed := make(map[string]bool, len(update.RemoveTags))
+	for _, tag := range update.RemoveTags {
+		removed[tag] = true
+	}
+
+	var tags []string
+	for _, tag := range append(append([]string{}, current...), update.AddTags...) {
+		if !removed[tag] {
+			tags = append(tags, tag)
+		}
+	}
+	return normalizeTags(tags)
+}
+
+// normalizeTags sorts tags and drops duplicates
+func normalizeTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	out := append([]string{}, tags...)
+	sort.Strings(out)
+	return slices.Compact(out)
+}
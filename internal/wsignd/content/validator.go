@@ -0,0 +1,70 @@
+package content
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+)
+
+// maxValidationBytes bounds how much of a response body is read while validating
+const maxValidationBytes = 10 * 1024 * 1024
+
+// httpValidator validates content by fetching it over HTTP
+type httpValidator struct {
+	client *http.Client
+}
+
+// NewHTTPValidator creates a Validator that fetches content with the given timeout
+func NewHTTPValidator(timeout time.Duration) Validator {
+	return &httpValidator{
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Validate fetches url and records status, latency, content type, and size
+func (v *httpValidator) Validate(ctx context.Context, url string) *v1alpha1.ContentValidationReport {
+	start := time.Now()
+	report := &v1alpha1.ContentValidationReport{
+		ValidatedAt: start.UTC(),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		report.Reason = fmt.Sprintf("invalid content URL: %v", err)
+		return report
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		report.LatencyMs = time.Since(start).Milliseconds()
+		report.Reason = fmt.Sprintf("fetch failed: %v", err)
+		return report
+	}
+	defer resp.Body.Close()
+
+	report.HTTPStatus = resp.StatusCode
+	report.ContentType = resp.Header.Get("Content-Type")
+
+	n, err := io.Copy(io.Discard, io.LimitReader(resp.Body, maxValidationBytes))
+	report.LatencyMs = time.Since(start).Milliseconds()
+	report.Size = n
+	if resp.ContentLength > n {
+		report.Size = resp.ContentLength
+	}
+	if err != nil {
+		report.Reason = fmt.Sprintf("reading content failed: %v", err)
+		return report
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		report.Reason = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+		return report
+	}
+
+	report.Passed = true
+	return report
+}
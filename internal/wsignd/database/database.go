@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/lib/pq"
 	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
@@ -26,10 +27,12 @@ type TxOptions struct {
 	ReadOnly bool
 }
 
-// RunMigrations executes all SQL migrations using the migration manager
-func RunMigrations(db *sql.DB) error {
-	manager := migrations.NewManager(db)
-	if err := manager.ApplyMigrations(context.Background()); err != nil {
+// RunMigrations executes all SQL migrations using the migration manager,
+// waiting up to lockTimeout for other instances that are migrating the same
+// database. A zero lockTimeout uses migrations.DefaultLockTimeout.
+func RunMigrations(ctx context.Context, db *sql.DB, lockTimeout time.Duration) error {
+	manager := migrations.NewManager(db, lockTimeout)
+	if err := manager.ApplyMigrations(ctx); err != nil {
 		return fmt.Errorf("failed to apply migrations: %w", err)
 	}
 	return nil
@@ -105,6 +108,18 @@ func MapError(err error, op string) error {
 		}
 	}
 
+	// Statements cut off by their context's deadline or by the server's
+	// statement_timeout (query_canceled) are reported as timeouts, so
+	// callers can tell a slow database from a broken one
+	if errors.Is(err, context.DeadlineExceeded) || (pqErr != nil && pqErr.Code == "57014") {
+		return werrors.NewError(
+			"DEADLINE_EXCEEDED",
+			"database operation timed out",
+			op,
+			werrors.ErrTimeout,
+		)
+	}
+
 	// Handle sql.ErrNoRows
 	if errors.Is(err, sql.ErrNoRows) {
 		return werrors.NewError(
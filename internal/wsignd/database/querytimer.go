@@ -0,0 +1,372 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLoggedQuery caps how much of a slow statement's text is logged
+const maxLoggedQuery = 200
+
+// modulePrefix is trimmed from the functions named in slow query warnings
+const modulePrefix = "github.com/wrale/wrale-signage/internal/wsignd/"
+
+// skippedCallers are the packages between a repository and the driver;
+// the first caller outside them is reported as the op
+var skippedCallers = []string{
+	"runtime.",
+	"database/sql.",
+	modulePrefix + "database.",
+}
+
+// histogramBounds are the upper bounds of the duration histogram buckets.
+// A final bucket counts everything slower.
+var histogramBounds = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+// QueryStats is a snapshot of the durations a QueryTimer has seen
+type QueryStats struct {
+	// SlowThreshold is the duration above which statements and
+	// transactions are logged; empty when logging is disabled
+	SlowThreshold string `json:"slowThreshold"`
+	// Statements are the queries and commands run outside prepared
+	// statements, timed until the database answered
+	Statements Histogram `json:"statements"`
+	// Transactions are timed from begin to commit or rollback
+	Transactions Histogram `json:"transactions"`
+}
+
+// Histogram counts durations into cumulative buckets
+type Histogram struct {
+	Count      uint64  `json:"count"`
+	Slow       uint64  `json:"slow"`
+	SumSeconds float64 `json:"sumSeconds"`
+	// Buckets count the durations at or below each bound, in increasing
+	// order, ending with "+Inf"
+	Buckets []Bucket `json:"buckets"`
+}
+
+// Bucket is one cumulative histogram bucket
+type Bucket struct {
+	LE    string `json:"le"`
+	Count uint64 `json:"count"`
+}
+
+// histogram accumulates the durations of one kind of work
+type histogram struct {
+	count  uint64
+	slow   uint64
+	sum    time.Duration
+	counts []uint64
+}
+
+func (h *histogram) observe(d time.Duration, slow bool) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(histogramBounds)+1)
+	}
+	h.count++
+	h.sum += d
+	if slow {
+		h.slow++
+	}
+	i := 0
+	for i < len(histogramBounds) && d > histogramBounds[i] {
+		i++
+	}
+	h.counts[i]++
+}
+
+func (h *histogram) snapshot() Histogram {
+	out := Histogram{
+		Count:      h.count,
+		Slow:       h.slow,
+		SumSeconds: h.sum.Seconds(),
+		Buckets:    make([]Bucket, 0, len(histogramBounds)+1),
+	}
+	var cumulative uint64
+	for i := 0; i <= len(histogramBounds); i++ {
+		if h.counts != nil {
+			cumulative += h.counts[i]
+		}
+		le := "+Inf"
+		if i < len(histogramBounds) {
+			le = histogramBounds[i].String()
+		}
+		out.Buckets = append(out.Buckets, Bucket{LE: le, Count: cumulative})
+	}
+	return out
+}
+
+// QueryTimer times the statements and transactions run through the
+// connectors it wraps. Those taking longer than the threshold are logged as
+// warnings naming the op, the function that ran them, which is read from
+// the call stack so that repositories need no changes to be timed.
+type QueryTimer struct {
+	threshold time.Duration
+	logger    *slog.Logger
+
+	mu           sync.Mutex
+	statements   histogram
+	transactions histogram
+}
+
+// NewQueryTimer creates a timer logging work slower than threshold. A zero
+// threshold disables the warnings; durations are still counted.
+func NewQueryTimer(threshold time.Duration, logger *slog.Logger) *QueryTimer {
+	return &QueryTimer{threshold: threshold, logger: logger}
+}
+
+// Wrap returns a connector whose connections are timed. A nil timer
+// returns c unchanged.
+func (t *QueryTimer) Wrap(c driver.Connector) driver.Connector {
+	if t == nil {
+		return c
+	}
+	return &timedConnector{Connector: c, timer: t}
+}
+
+// Stats returns the durations counted so far
+func (t *QueryTimer) Stats() QueryStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := QueryStats{
+		Statements:   t.statements.snapshot(),
+		Transactions: t.transactions.snapshot(),
+	}
+	if t.threshold > 0 {
+		stats.SlowThreshold = t.threshold.String()
+	}
+	return stats
+}
+
+// StatsHandler serves the durations as JSON for metrics scrapers
+func (t *QueryTimer) StatsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(t.Stats()); err != nil {
+			t.logger.Error("failed to write query stats", "error", err)
+		}
+	}
+}
+
+// slow reports whether d is over the threshold
+func (t *QueryTimer) slow(d time.Duration) bool {
+	return t.threshold > 0 && d > t.threshold
+}
+
+// observeStatement counts a statement that took d and logs it when slow
+func (t *QueryTimer) observeStatement(query string, d time.Duration, err error) {
+	slow := t.slow(d)
+	t.mu.Lock()
+	t.statements.observe(d, slow)
+	t.mu.Unlock()
+	if !slow {
+		return
+	}
+
+	var pcs [32]uintptr
+	n := runtime.Callers(2, pcs[:])
+	attrs := []any{
+		"op", callerOp(pcs[:n]),
+		"duration", d,
+		"threshold", t.threshold,
+		"query", compactQuery(query),
+	}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+	t.logger.Warn("slow database query", attrs...)
+}
+
+// observeTransaction counts a transaction begun from the call stack pcs
+// that ended after d, and logs it when slow
+func (t *QueryTimer) observeTransaction(pcs []uintptr, d time.Duration, outcome string) {
+	slow := t.slow(d)
+	t.mu.Lock()
+	t.transactions.observe(d, slow)
+	t.mu.Unlock()
+	if !slow {
+		return
+	}
+	t.logger.Warn("slow database transaction",
+		"op", callerOp(pcs),
+		"duration", d,
+		"threshold", t.threshold,
+		"outcome", outcome,
+	)
+}
+
+// callerOp names the first function on the call stack pcs outside the
+// runtime, database/sql and this package, without the module path or the
+// suffix of a closure
+func callerOp(pcs []uintptr) string {
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if !skipCaller(frame.Function) {
+			name := strings.TrimPrefix(frame.Function, modulePrefix)
+			for {
+				i := strings.LastIndexByte(name, '.')
+				suffix := strings.TrimPrefix(name[i+1:], "func")
+				if i < 0 || suffix == "" || strings.Trim(suffix, "0123456789") != "" {
+					return name
+				}
+				name = name[:i]
+			}
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+func skipCaller(function string) bool {
+	for _, prefix := range skippedCallers {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// compactQuery folds a statement onto one line and caps its length
+func compactQuery(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if len(query) > maxLoggedQuery {
+		query = query[:maxLoggedQuery] + "..."
+	}
+	return query
+}
+
+// timedConnector hands out timed connections
+type timedConnector struct {
+	driver.Connector
+	timer *QueryTimer
+}
+
+func (c *timedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &timedConn{Conn: conn, timer: c.timer}, nil
+}
+
+// timedConn times the statements run on a driver connection and the
+// transactions begun on it. Statements prepared explicitly are not timed.
+type timedConn struct {
+	driver.Conn
+	timer *QueryTimer
+}
+
+func (c *timedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	c.timer.observeStatement(query, time.Since(start), err)
+	return rows, err
+}
+
+func (c *timedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	res, err := execer.ExecContext(ctx, query, args)
+	c.timer.observeStatement(query, time.Since(start), err)
+	return res, err
+}
+
+func (c *timedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *timedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var pcs [32]uintptr
+	n := runtime.Callers(2, pcs[:])
+	start := time.Now()
+
+	var (
+		tx  driver.Tx
+		err error
+	)
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = beginner.BeginTx(ctx, opts)
+	} else if opts.Isolation != 0 || opts.ReadOnly {
+		return nil, errors.New("driver does not support transaction options")
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &timedTx{Tx: tx, timer: c.timer, start: start, pcs: pcs[:n]}, nil
+}
+
+func (c *timedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *timedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *timedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// timedTx reports its transaction's duration when it ends
+type timedTx struct {
+	driver.Tx
+	timer *QueryTimer
+	start time.Time
+	pcs   []uintptr
+}
+
+func (tx *timedTx) Commit() error {
+	err := tx.Tx.Commit()
+	tx.timer.observeTransaction(tx.pcs, time.Since(tx.start), "commit")
+	return err
+}
+
+func (tx *timedTx) Rollback() error {
+	err := tx.Tx.Rollback()
+	tx.timer.observeTransaction(tx.pcs, time.Since(tx.start), "rollback")
+	return err
+}
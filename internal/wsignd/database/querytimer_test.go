@@ -0,0 +1,147 @@
+package database_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/database"
+)
+
+// slowConnector opens connections whose statements sleep for as long as
+// the number in their text says, in milliseconds
+type slowConnector struct{}
+
+func (slowConnector) Connect(context.Context) (driver.Conn, error) { return slowConn{}, nil }
+func (slowConnector) Driver() driver.Driver                        { return nil }
+
+type slowConn struct{}
+
+func (slowConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (slowConn) Close() error                        { return nil }
+func (slowConn) Begin() (driver.Tx, error)           { return slowTx{}, nil }
+
+func (slowConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(0), sleepFor(ctx, query)
+}
+
+type slowTx struct{}
+
+func (slowTx) Commit() error   { return nil }
+func (slowTx) Rollback() error { return nil }
+
+func sleepFor(ctx context.Context, query string) error {
+	var ms int
+	for _, f := range strings.Fields(query) {
+		if n, err := strconv.Atoi(f); err == nil {
+			ms = n
+			break
+		}
+	}
+	select {
+	case <-time.After(time.Duration(ms) * time.Millisecond):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// logged decodes the JSON log lines written to buf
+func logged(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var lines []map[string]interface{}
+	dec := json.NewDecoder(buf)
+	for {
+		var line map[string]interface{}
+		err := dec.Decode(&line)
+		if err == io.EOF {
+			return lines
+		}
+		require.NoError(t, err)
+		lines = append(lines, line)
+	}
+}
+
+func TestQueryTimer(t *testing.T) {
+	var buf bytes.Buffer
+	timer := database.NewQueryTimer(50*time.Millisecond, slog.New(slog.NewJSONHandler(&buf, nil)))
+	db := sql.OpenDB(timer.Wrap(slowConnector{}))
+	defer db.Close()
+	ctx := context.Background()
+
+	_, err := db.ExecContext(ctx, "SELECT pg_sleep( 0 )")
+	require.NoError(t, err)
+	assert.Empty(t, logged(t, &buf), "fast statements are not logged")
+
+	_, err = db.ExecContext(ctx, "SELECT pg_sleep( 80 )\n  FROM content_events")
+	require.NoError(t, err)
+	lines := logged(t, &buf)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "WARN", lines[0]["level"])
+	assert.Equal(t, "slow database query", lines[0]["msg"])
+	assert.Equal(t, "database_test.TestQueryTimer", lines[0]["op"])
+	assert.Equal(t, "SELECT pg_sleep( 80 ) FROM content_events", lines[0]["query"])
+
+	err = database.RunInTx(ctx, db, nil, func(tx *database.Tx) error {
+		_, err := tx.ExecContext(ctx, "SELECT pg_sleep( 30 )")
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "SELECT pg_sleep( 30 )")
+		return err
+	})
+	require.NoError(t, err)
+	lines = logged(t, &buf)
+	require.Len(t, lines, 1, "neither statement is slow, but the transaction is")
+	assert.Equal(t, "slow database transaction", lines[0]["msg"])
+	assert.Equal(t, "database_test.TestQueryTimer", lines[0]["op"])
+	assert.Equal(t, "commit", lines[0]["outcome"])
+
+	stats := timer.Stats()
+	assert.Equal(t, "50ms", stats.SlowThreshold)
+	assert.Equal(t, uint64(4), stats.Statements.Count)
+	assert.Equal(t, uint64(1), stats.Statements.Slow)
+	assert.Equal(t, uint64(1), stats.Transactions.Count)
+	assert.Equal(t, uint64(1), stats.Transactions.Slow)
+
+	buckets := stats.Statements.Buckets
+	require.NotEmpty(t, buckets)
+	assert.Equal(t, "1ms", buckets[0].LE)
+	assert.Equal(t, uint64(1), buckets[0].Count, "only the instant statement took under 1ms")
+	assert.Equal(t, "+Inf", buckets[len(buckets)-1].LE)
+	assert.Equal(t, uint64(4), buckets[len(buckets)-1].Count)
+
+	rec := httptest.NewRecorder()
+	timer.StatsHandler()(rec, httptest.NewRequest(http.MethodGet, "/debug/queries", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var served database.QueryStats
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&served))
+	assert.Equal(t, stats, served)
+}
+
+func TestQueryTimerWithoutThreshold(t *testing.T) {
+	var buf bytes.Buffer
+	timer := database.NewQueryTimer(0, slog.New(slog.NewJSONHandler(&buf, nil)))
+	db := sql.OpenDB(timer.Wrap(slowConnector{}))
+	defer db.Close()
+
+	_, err := db.ExecContext(context.Background(), "SELECT pg_sleep( 5 )")
+	require.NoError(t, err)
+	assert.Empty(t, logged(t, &buf))
+	assert.Equal(t, uint64(1), timer.Stats().Statements.Count)
+	assert.Empty(t, timer.Stats().SlowThreshold)
+}
@@ -0,0 +1,34 @@
+package database
+
+import "database/sql"
+
+// Connections are the pools a PostgreSQL repository runs its queries on
+type Connections struct {
+	// Primary takes every write, and every read that must see them
+	Primary *sql.DB
+	// Reader takes reads that can tolerate replication lag: listings,
+	// statistics and metrics. It is Primary when no replica is configured.
+	Reader *sql.DB
+}
+
+// RepositoryOption configures the connections of a PostgreSQL repository
+type RepositoryOption func(*Connections)
+
+// WithReader sends a repository's lag-tolerant reads to reader, usually a
+// read replica. A nil reader leaves them on the primary.
+func WithReader(reader *sql.DB) RepositoryOption {
+	return func(c *Connections) {
+		if reader != nil {
+			c.Reader = reader
+		}
+	}
+}
+
+// NewConnections returns the connections for a repository on primary
+func NewConnections(primary *sql.DB, opts ...RepositoryOption) Connections {
+	c := Connections{Primary: primary, Reader: primary}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
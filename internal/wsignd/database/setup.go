@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+)
+
+const (
+	// pingTimeout bounds each attempt to reach the database
+	pingTimeout = 5 * time.Second
+	// maxConnectBackoff caps the doubling wait between attempts
+	maxConnectBackoff = 30 * time.Second
+)
+
+// SetupStage names a step of SetupDatabase
+type SetupStage string
+
+const (
+	// StageConnect is opening the pool and reaching the database
+	StageConnect SetupStage = "connect"
+	// StageMigrate is applying migrations
+	StageMigrate SetupStage = "migrate"
+)
+
+// SetupError reports the stage at which database setup failed
+type SetupError struct {
+	// Stage is the step that failed
+	Stage SetupStage
+	// Attempts is how many times the database was tried before giving up.
+	// It is zero when the connection settings were rejected outright.
+	Attempts int
+	// Err is the last error seen
+	Err error
+}
+
+func (e *SetupError) Error() string {
+	if e.Stage == StageConnect && e.Attempts > 0 {
+		return fmt.Sprintf("database %s failed after %d attempts: %v", e.Stage, e.Attempts, e.Err)
+	}
+	return fmt.Sprintf("database %s failed: %v", e.Stage, e.Err)
+}
+
+func (e *SetupError) Unwrap() error {
+	return e.Err
+}
+
+// SetupDatabase connects to the database described by cfg and applies
+// migrations. Statements run on the pool are timed by timer unless it is
+// nil. Failures are reported as a *SetupError naming the stage.
+func SetupDatabase(ctx context.Context, cfg config.DatabaseConfig, logger *slog.Logger, timer *QueryTimer) (*sql.DB, error) {
+	db, err := Connect(ctx, cfg, logger, timer)
+	if err != nil {
+		return nil, err
+	}
+
+	// Replicas starting together take turns applying migrations
+	if err := RunMigrations(ctx, db, cfg.MigrationLockTimeout); err != nil {
+		db.Close()
+		return nil, &SetupError{Stage: StageMigrate, Err: err}
+	}
+	return db, nil
+}
+
+// Connect opens a connection pool with the pool settings from cfg and
+// waits until the database answers. A database that is still starting is
+// pinged up to cfg.ConnectAttempts times, waiting cfg.ConnectBackoff after
+// the first failure and twice as long after each one that follows.
+// Statements run on the pool are timed by timer unless it is nil, and the
+// server cancels any that run longer than cfg.StatementTimeout.
+// Failures are reported as a *SetupError at StageConnect.
+func Connect(ctx context.Context, cfg config.DatabaseConfig, logger *slog.Logger, timer *QueryTimer) (*sql.DB, error) {
+	// Parse the connection string up front so that a malformed URL is
+	// reported as such rather than as a failure to connect
+	connector, err := pq.NewConnector(withStatementTimeout(cfg.ConnString(), cfg.StatementTimeout))
+	if err != nil {
+		// URL parse errors quote the URL, password included
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, &SetupError{Stage: StageConnect, Err: fmt.Errorf("invalid connection string: %w", err)}
+	}
+	db := sql.OpenDB(timer.Wrap(connector))
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	attempts := max(cfg.ConnectAttempts, 1)
+	backoff := cfg.ConnectBackoff
+	for attempt := 1; ; attempt++ {
+		err = ping(ctx, db)
+		if err == nil {
+			return db, nil
+		}
+		if attempt == attempts || ctx.Err() != nil {
+			db.Close()
+			return nil, &SetupError{Stage: StageConnect, Attempts: attempt, Err: err}
+		}
+
+		logger.Warn("database not reachable, retrying",
+			"error", err,
+			"attempt", attempt,
+			"attempts", attempts,
+			"retryIn", backoff,
+		)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			db.Close()
+			return nil, &SetupError{Stage: StageConnect, Attempts: attempt, Err: ctx.Err()}
+		}
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+}
+
+// ping checks the database answers within pingTimeout
+func ping(ctx context.Context, db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	return db.PingContext(ctx)
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+)
+
+// closedPort returns a local port nothing is listening on
+func closedPort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+	return port
+}
+
+func TestSetupDatabaseRetriesThenFails(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := config.DatabaseConfig{
+		Host:            "127.0.0.1",
+		Port:            closedPort(t),
+		Name:            "wrale_signage",
+		User:            "postgres",
+		SSLMode:         "disable",
+		MaxOpenConns:    1,
+		MaxIdleConns:    1,
+		ConnectAttempts: 3,
+		ConnectBackoff:  20 * time.Millisecond,
+	}
+
+	start := time.Now()
+	db, err := SetupDatabase(context.Background(), cfg, logger, nil)
+	require.Error(t, err)
+	assert.Nil(t, db)
+
+	var setupErr *SetupError
+	require.True(t, errors.As(err, &setupErr))
+	assert.Equal(t, StageConnect, setupErr.Stage)
+	assert.Equal(t, 3, setupErr.Attempts)
+	// Waited 20ms, then 40ms, between the three attempts
+	assert.GreaterOrEqual(t, time.Since(start), 60*time.Millisecond)
+
+	t.Run("gives up when cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cfg := cfg
+		cfg.ConnectAttempts = 100
+		cfg.ConnectBackoff = time.Hour
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		_, err := SetupDatabase(ctx, cfg, logger, nil)
+		require.True(t, errors.As(err, &setupErr))
+		assert.Equal(t, StageConnect, setupErr.Stage)
+		assert.Equal(t, 1, setupErr.Attempts)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("rejects a malformed connection string without retrying", func(t *testing.T) {
+		cfg := cfg
+		cfg.URL = "postgres://user:secret@%zz/db"
+
+		_, err := SetupDatabase(context.Background(), cfg, logger, nil)
+		require.True(t, errors.As(err, &setupErr))
+		assert.Equal(t, StageConnect, setupErr.Stage)
+		assert.Zero(t, setupErr.Attempts)
+		assert.NotContains(t, err.Error(), "secret")
+	})
+}
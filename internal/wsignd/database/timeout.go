@@ -0,0 +1,45 @@
+package database
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// statementTimeoutParam is the server setting that cancels statements
+// running longer than it, in milliseconds. Unknown connection parameters are
+// sent to the server as settings for the session.
+const statementTimeoutParam = "statement_timeout"
+
+// withStatementTimeout adds a statement_timeout of d to a connection
+// string, in either URL or key=value form, unless it already names one. A
+// d of zero or less leaves the server's own setting.
+func withStatementTimeout(conn string, d time.Duration) string {
+	if d <= 0 {
+		return conn
+	}
+	ms := strconv.FormatInt(d.Milliseconds(), 10)
+
+	if strings.HasPrefix(conn, "postgres://") || strings.HasPrefix(conn, "postgresql://") {
+		u, err := url.Parse(conn)
+		if err != nil {
+			// Left for the driver to report
+			return conn
+		}
+		query := u.Query()
+		if query.Has(statementTimeoutParam) {
+			return conn
+		}
+		query.Set(statementTimeoutParam, ms)
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+
+	for _, field := range strings.Fields(conn) {
+		if strings.HasPrefix(field, statementTimeoutParam+"=") {
+			return conn
+		}
+	}
+	return strings.TrimSpace(conn + " " + statementTimeoutParam + "=" + ms)
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+func TestWithStatementTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		conn    string
+		timeout time.Duration
+		want    string
+	}{
+		{
+			name:    "URL",
+			conn:    "postgres://app@db:5432/signage?sslmode=disable",
+			timeout: 30 * time.Second,
+			want:    "postgres://app@db:5432/signage?sslmode=disable&statement_timeout=30000",
+		},
+		{
+			name:    "URL that sets its own",
+			conn:    "postgresql://app@db/signage?statement_timeout=5000",
+			timeout: 30 * time.Second,
+			want:    "postgresql://app@db/signage?statement_timeout=5000",
+		},
+		{
+			name:    "key value pairs",
+			conn:    "host=db dbname=signage sslmode=disable",
+			timeout: 1500 * time.Millisecond,
+			want:    "host=db dbname=signage sslmode=disable statement_timeout=1500",
+		},
+		{
+			name:    "key value pairs that set their own",
+			conn:    "host=db statement_timeout=0",
+			timeout: time.Second,
+			want:    "host=db statement_timeout=0",
+		},
+		{
+			name: "disabled",
+			conn: "postgres://app@db/signage",
+			want: "postgres://app@db/signage",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, withStatementTimeout(tt.conn, tt.timeout))
+		})
+	}
+}
+
+func TestMapErrorTimeouts(t *testing.T) {
+	for name, err := range map[string]error{
+		"context deadline":  fmt.Errorf("query: %w", context.DeadlineExceeded),
+		"statement timeout": &pq.Error{Code: "57014", Message: "canceling statement due to statement timeout"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mapped := MapError(err, "test")
+			assert.True(t, werrors.IsTimeout(mapped))
+			var domainErr *werrors.Error
+			if assert.ErrorAs(t, mapped, &domainErr) {
+				assert.Equal(t, "DEADLINE_EXCEEDED", domainErr.Code)
+			}
+		})
+	}
+
+	// A cancelled request is not a timeout
+	assert.False(t, werrors.IsTimeout(MapError(context.Canceled, "test")))
+}
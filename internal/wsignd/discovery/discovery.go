@@ -0,0 +1,63 @@
+// Package discovery serves the API discovery document that tells clients
+// which server version they are talking to and what it supports
+package discovery
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+)
+
+// Path is where the discovery document is served
+const Path = "/api/v1alpha1"
+
+// VersionPath is where the server's build information is served
+const VersionPath = Path + "/version"
+
+// Document builds the discovery document for this server
+func Document(serverVersion string) *v1alpha1.APIDiscovery {
+	return &v1alpha1.APIDiscovery{
+		TypeMeta:      v1alpha1.TypeMeta{Kind: "APIDiscovery", APIVersion: "v1alpha1"},
+		ServerVersion: serverVersion,
+		Resources: []v1alpha1.APIResource{
+			{Kind: "Display", Path: Path + "/displays"},
+			{Kind: "ContentSource", Path: Path + "/content"},
+			{Kind: "ContentAssignment", Path: Path + "/assignments"},
+		},
+		Features: []string{
+			v1alpha1.FeatureContentFilters,
+			v1alpha1.FeatureDisplayPatch,
+			v1alpha1.FeatureDisplaySelf,
+			v1alpha1.FeatureDisplaySelfHead,
+		},
+	}
+}
+
+// Handler serves doc as JSON
+func Handler(doc *v1alpha1.APIDiscovery) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(doc)
+	}
+}
+
+// Version builds the description of this server's build
+func Version(version, commit, buildDate string) *v1alpha1.VersionInfo {
+	return &v1alpha1.VersionInfo{
+		TypeMeta:  v1alpha1.TypeMeta{Kind: "VersionInfo", APIVersion: "v1alpha1"},
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+	}
+}
+
+// VersionHandler serves info as JSON
+func VersionHandler(info *v1alpha1.VersionInfo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(info)
+	}
+}
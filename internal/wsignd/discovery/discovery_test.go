@@ -0,0 +1,47 @@
+package discovery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+)
+
+func TestHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", Path, nil)
+	w := httptest.NewRecorder()
+
+	Handler(Document("1.2.3")).ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var doc v1alpha1.APIDiscovery
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&doc))
+	assert.Equal(t, "APIDiscovery", doc.Kind)
+	assert.Equal(t, "1.2.3", doc.ServerVersion)
+	assert.Contains(t, doc.Resources, v1alpha1.APIResource{Kind: "Display", Path: "/api/v1alpha1/displays"})
+	assert.True(t, doc.HasFeature(v1alpha1.FeatureDisplayPatch))
+	assert.True(t, doc.HasFeature(v1alpha1.FeatureDisplaySelfHead))
+	assert.False(t, doc.HasFeature(v1alpha1.FeatureWatch))
+}
+
+func TestVersionHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", VersionPath, nil)
+	w := httptest.NewRecorder()
+
+	VersionHandler(Version("1.2.3", "abc1234", "2024-05-01T12:00:00Z")).ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var info v1alpha1.VersionInfo
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&info))
+	assert.Equal(t, "VersionInfo", info.Kind)
+	assert.Equal(t, "1.2.3", info.Version)
+	assert.Equal(t, "abc1234", info.Commit)
+	assert.Equal(t, "2024-05-01T12:00:00Z", info.BuildDate)
+}
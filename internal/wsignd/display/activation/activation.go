@@ -0,0 +1,178 @@
+// Package activation implements the device code flow used to activate
+// displays, and the enrollment tokens provisioned displays activate with
+// instead
+package activation
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+)
+
+var (
+	// ErrCodeNotFound indicates an unknown device or user code
+	ErrCodeNotFound = errors.New("activation code not found")
+	// ErrCodeExpired indicates a code that can no longer be used
+	ErrCodeExpired = errors.New("activation code expired")
+	// ErrAlreadyActivated indicates a code that has already been used
+	ErrAlreadyActivated = errors.New("activation code already used")
+	// ErrCodeRedeemed indicates a device code that has already been
+	// exchanged for a token
+	ErrCodeRedeemed = errors.New("device code already redeemed")
+	// ErrAuthorizationPending indicates a code that has not been activated yet
+	ErrAuthorizationPending = errors.New("activation pending")
+	// ErrSiteNotAllowed indicates an activation into a site the code is not
+	// bound to
+	ErrSiteNotAllowed = errors.New("activation code not allowed at site")
+)
+
+// Kind distinguishes the codes kept in a Repository
+type Kind string
+
+const (
+	// KindDeviceCode is a device and user code pair of the device flow
+	KindDeviceCode Kind = "device_code"
+	// KindEnrollment is a single use enrollment token minted for a
+	// provisioned display. It has no user code; the display presents the
+	// token, kept in DeviceCode, once to be issued its access token.
+	KindEnrollment Kind = "enrollment"
+)
+
+// DeviceCode is a pending activation request from a display. The display
+// shows UserCode to an operator and polls with DeviceCode until the operator
+// completes activation.
+type DeviceCode struct {
+	// ID uniquely identifies this activation request
+	ID uuid.UUID
+	// Kind is the kind of code; empty is treated as KindDeviceCode
+	Kind Kind
+	// DeviceCode is the secret the display polls with
+	DeviceCode string
+	// UserCode is the short code shown on screen
+	UserCode string
+	// ExpiresAt is when the codes stop being accepted
+	ExpiresAt time.Time
+	// PollInterval is the minimum number of seconds between polls
+	PollInterval int
+	// Activated indicates an operator has completed activation, or that
+	// an enrollment token has been used
+	Activated bool
+	// Redeemed indicates the display has been issued its token for an
+	// activated device code
+	Redeemed bool
+	// DisplayID is the display created on activation, or the provisioned
+	// display an enrollment token was minted for
+	DisplayID uuid.UUID
+	// AllowedSite is the only site the code may be activated into. Empty
+	// codes are unbound and the SitePolicy decides where they may go.
+	AllowedSite string
+	// Hints are the location and name the display suggested for itself
+	Hints Hints
+	// CreatedAt is when the codes were issued
+	CreatedAt time.Time
+}
+
+// Hints are where a pre-staged display expects to be installed and what
+// it expects to be called, sent when it requests its codes. They only
+// pre-fill activation: the operator still gives or confirms the values the
+// display is activated with. Any field may be empty.
+type Hints struct {
+	Location display.Location
+	Name     string
+}
+
+// maxHintLength caps each hinted value, which arrive from displays that
+// have not been activated yet
+const maxHintLength = 128
+
+// Empty reports whether no hint was given
+func (h Hints) Empty() bool {
+	return h == Hints{}
+}
+
+// Enrollment reports whether the code is an enrollment token
+func (c *DeviceCode) Enrollment() bool {
+	return c.Kind == KindEnrollment
+}
+
+// Expired reports whether the code is no longer valid at t
+func (c *DeviceCode) Expired(t time.Time) bool {
+	return !t.Before(c.ExpiresAt)
+}
+
+// SitePolicy decides where device codes may be activated. A code bound to
+// a site can only ever be activated into that site.
+type SitePolicy struct {
+	// Strict requires every code to be bound to a site: codes cannot be
+	// issued without one, and unbound codes issued before are refused.
+	// Otherwise unbound codes may be activated into any site.
+	Strict bool
+}
+
+// Allows reports whether code may be activated into siteID
+func (p SitePolicy) Allows(code *DeviceCode, siteID string) bool {
+	if code.AllowedSite == "" {
+		return !p.Strict
+	}
+	return code.AllowedSite == siteID
+}
+
+// Repository defines persistence for device codes
+type Repository interface {
+	// Save stores a newly issued device code
+	Save(ctx context.Context, code *DeviceCode) error
+	// FindByDeviceCode retrieves a code by the secret device code
+	FindByDeviceCode(ctx context.Context, deviceCode string) (*DeviceCode, error)
+	// FindByUserCode retrieves a code by the user code shown on screen
+	FindByUserCode(ctx context.Context, userCode string) (*DeviceCode, error)
+	// MarkActivated binds a code to the display created for it. It fails if
+	// the code was already activated, which makes it the single point where
+	// concurrent uses of one enrollment token are decided.
+	MarkActivated(ctx context.Context, id uuid.UUID, displayID uuid.UUID) error
+	// MarkRedeemed records that a token has been issued for a code. It
+	// fails if the code was already redeemed, so that concurrent polls of
+	// one device code are issued one token between them.
+	MarkRedeemed(ctx context.Context, id uuid.UUID) error
+	// DeleteExpired removes codes that expired before the given time
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Service defines the device code activation operations
+type Service interface {
+	// GenerateCode issues a new device and user code pair. A non-empty
+	// allowedSite binds the codes to that site, and hints are kept with
+	// them for the operator who activates the display.
+	GenerateCode(ctx context.Context, allowedSite string, hints Hints) (*DeviceCode, error)
+	// CheckCode checks that a user code can still be activated somewhere,
+	// returning it with its hints
+	CheckCode(ctx context.Context, userCode string) (*DeviceCode, error)
+	// ValidateCode checks that a user code can still be activated into
+	// siteID
+	ValidateCode(ctx context.Context, userCode, siteID string) (*DeviceCode, error)
+	// ActivateCode completes activation of a user code for a display at
+	// siteID
+	ActivateCode(ctx context.Context, userCode, siteID string, displayID uuid.UUID) error
+	// CheckActivation reports the state of a device code. It returns
+	// ErrAuthorizationPending until the code has been activated, and
+	// ErrCodeRedeemed once it has been redeemed.
+	CheckActivation(ctx context.Context, deviceCode string) (*DeviceCode, error)
+	// Redeem uses an activated device code to issue the display its token.
+	// Of concurrent redemptions of one code exactly one succeeds; the
+	// others fail with ErrCodeRedeemed.
+	Redeem(ctx context.Context, deviceCode string) (*DeviceCode, error)
+	// IssueEnrollmentToken mints a single use enrollment token for a
+	// provisioned display
+	IssueEnrollmentToken(ctx context.Context, displayID uuid.UUID) (*DeviceCode, error)
+	// CheckEnrollment checks that an enrollment token can still be used
+	// without using it
+	CheckEnrollment(ctx context.Context, token string) (*DeviceCode, error)
+	// Enroll uses an enrollment token. Of concurrent uses of one token
+	// exactly one succeeds; the others fail with ErrAlreadyActivated.
+	Enroll(ctx context.Context, token string) (*DeviceCode, error)
+	// CleanupExpired removes expired codes
+	CleanupExpired(ctx context.Context) error
+}
@@ -0,0 +1,386 @@
+package activation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/clock"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+const (
+	// defaultPollInterval is the number of seconds displays wait between polls
+	defaultPollInterval = 5
+	// deviceCodeBytes is the entropy of the secret device code
+	deviceCodeBytes = 32
+	// maxGenerateAttempts bounds retries when a user code is already in use
+	maxGenerateAttempts = 5
+	// DefaultEnrollmentExpiry is how long enrollment tokens stay valid unless
+	// configured otherwise. Provisioning files may sit on a USB stick for
+	// weeks before a display is installed.
+	DefaultEnrollmentExpiry = 30 * 24 * time.Hour
+)
+
+// userCodeWords are combined into short, readable user codes such as
+// BLUE-FISH. Words are chosen to be easy to read aloud and hard to confuse.
+var userCodeWords = []string{
+	"AMBER", "APPLE", "BEACH", "BIRCH", "BLUE", "BRAVE", "CAKE", "CEDAR",
+	"CLOUD", "CORAL", "DELTA", "EAGLE", "EMBER", "FERN", "FISH", "FLAME",
+	"FROST", "GOLD", "GRAPE", "HAWK", "HONEY", "IVORY", "JADE", "KITE",
+	"LAKE", "LEMON", "LUNAR", "MAPLE", "MINT", "MOON", "NORTH", "OCEAN",
+	"OLIVE", "OTTER", "PEARL", "PINE", "PLUM", "QUILL", "RAVEN", "RIVER",
+	"ROBIN", "SAGE", "SOLAR", "STONE", "TIGER", "TULIP", "VIOLET", "WAVE",
+}
+
+type service struct {
+	repo             Repository
+	expiry           time.Duration
+	enrollmentExpiry time.Duration
+	sites            SitePolicy
+	clock            clock.Clock
+}
+
+// Option configures an activation service
+type Option func(*service)
+
+// WithClock sets the clock codes are issued and expired against
+func WithClock(c clock.Clock) Option {
+	return func(s *service) {
+		s.clock = c
+	}
+}
+
+// WithEnrollmentExpiry sets how long enrollment tokens stay valid
+func WithEnrollmentExpiry(d time.Duration) Option {
+	return func(s *service) {
+		s.enrollmentExpiry = d
+	}
+}
+
+// NewService creates an activation service issuing codes valid for expiry.
+// sites decides where the codes may be activated. The service reads the
+// system clock unless given another with WithClock, and issues enrollment
+// tokens valid for DefaultEnrollmentExpiry unless given another expiry with
+// WithEnrollmentExpiry.
+func NewService(repo Repository, expiry time.Duration, sites SitePolicy, opts ...Option) Service {
+	s := &service{
+		repo:             repo,
+		expiry:           expiry,
+		enrollmentExpiry: DefaultEnrollmentExpiry,
+		sites:            sites,
+		clock:            clock.Real(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *service) GenerateCode(ctx context.Context, allowedSite string, hints Hints) (*DeviceCode, error) {
+	const op = "ActivationService.GenerateCode"
+
+	allowedSite = strings.TrimSpace(allowedSite)
+	if allowedSite == "" && s.sites.Strict {
+		return nil, werrors.NewError("INVALID_INPUT", "a site is required to request an activation code", op, werrors.ErrInvalidInput)
+	}
+	hints, err := normalizeHints(hints, allowedSite)
+	if err != nil {
+		return nil, werrors.NewError("INVALID_INPUT", err.Error(), op, err)
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < maxGenerateAttempts; attempt++ {
+		deviceCode, err := randomDeviceCode()
+		if err != nil {
+			return nil, werrors.NewError("GENERATE_FAILED", "Failed to generate device code", op, err)
+		}
+		userCode, err := randomUserCode()
+		if err != nil {
+			return nil, werrors.NewError("GENERATE_FAILED", "Failed to generate user code", op, err)
+		}
+
+		now := s.clock.Now()
+		code := &DeviceCode{
+			ID:           uuid.New(),
+			Kind:         KindDeviceCode,
+			DeviceCode:   deviceCode,
+			UserCode:     userCode,
+			ExpiresAt:    now.Add(s.expiry),
+			PollInterval: defaultPollInterval,
+			AllowedSite:  allowedSite,
+			Hints:        hints,
+			CreatedAt:    now,
+		}
+
+		err = s.repo.Save(ctx, code)
+		if err == nil {
+			return code, nil
+		}
+		// User codes are short, so retry with a fresh pair on collision
+		if !werrors.IsConflict(err) {
+			return nil, werrors.NewError("SAVE_FAILED", "Failed to save device code", op, err)
+		}
+		lastErr = err
+	}
+
+	return nil, werrors.NewError("GENERATE_FAILED", "Failed to generate a unique user code", op, lastErr)
+}
+
+// CleanupExpired removes device codes that have expired. It is intended to
+// run as a scheduled job.
+func (s *service) CleanupExpired(ctx context.Context) error {
+	const op = "ActivationService.CleanupExpired"
+
+	if _, err := s.repo.DeleteExpired(ctx, s.clock.Now()); err != nil {
+		return werrors.NewError("CLEANUP_FAILED", "Failed to remove expired device codes", op, err)
+	}
+	return nil
+}
+
+// normalizeHints trims the hints a display sent and checks them as the
+// values they suggest would be checked, reporting every invalid field. A
+// hinted site must be the site the code is bound to, if it is bound.
+func normalizeHints(hints Hints, allowedSite string) (Hints, error) {
+	hints = Hints{
+		Location: display.Location{
+			SiteID:   strings.TrimSpace(hints.Location.SiteID),
+			Zone:     strings.TrimSpace(hints.Location.Zone),
+			Position: strings.TrimSpace(hints.Location.Position),
+		},
+		Name: strings.TrimSpace(hints.Name),
+	}
+
+	verr := &werrors.ValidationError{}
+	for _, field := range []struct{ name, value string }{
+		{"locationHint.siteId", hints.Location.SiteID},
+		{"locationHint.zone", hints.Location.Zone},
+		{"locationHint.position", hints.Location.Position},
+	} {
+		if len(field.value) > maxHintLength {
+			verr.Invalid(field.name, fmt.Sprintf("is longer than %d characters", maxHintLength))
+		}
+	}
+	if hints.Name != "" {
+		if err := display.ValidateName(hints.Name); err != nil {
+			verr.Invalid("nameHint", err.Error())
+		}
+	}
+	if allowedSite != "" && hints.Location.SiteID != "" && hints.Location.SiteID != allowedSite {
+		verr.Invalid("locationHint.siteId", "must be the site the display is bound to")
+	}
+	return hints, verr.Err()
+}
+
+func (s *service) CheckCode(ctx context.Context, userCode string) (*DeviceCode, error) {
+	const op = "ActivationService.CheckCode"
+
+	code, err := s.repo.FindByUserCode(ctx, NormalizeUserCode(userCode))
+	if err == nil && code.Enrollment() {
+		err = werrors.NewError("NOT_FOUND", "not an activation code", op, werrors.ErrNotFound)
+	}
+	if err != nil {
+		if werrors.IsNotFound(err) {
+			return nil, werrors.NewError("CODE_NOT_FOUND", "Activation code not found", op, ErrCodeNotFound)
+		}
+		return nil, werrors.NewError("LOOKUP_FAILED", "Failed to look up activation code", op, err)
+	}
+
+	if code.Activated {
+		return nil, werrors.NewError("CODE_USED", "Activation code already used", op, ErrAlreadyActivated)
+	}
+	if code.Expired(s.clock.Now()) {
+		return nil, werrors.NewError("CODE_EXPIRED", "Activation code expired", op, ErrCodeExpired)
+	}
+
+	return code, nil
+}
+
+func (s *service) ValidateCode(ctx context.Context, userCode, siteID string) (*DeviceCode, error) {
+	const op = "ActivationService.ValidateCode"
+
+	code, err := s.CheckCode(ctx, userCode)
+	if err != nil {
+		return nil, err
+	}
+	// Checked last so that a code from another site is only reported as
+	// such while it could otherwise be used
+	if !s.sites.Allows(code, siteID) {
+		return nil, werrors.NewError("ACCESS_DENIED", "Activation code cannot be used at this site", op, ErrSiteNotAllowed)
+	}
+
+	return code, nil
+}
+
+func (s *service) ActivateCode(ctx context.Context, userCode, siteID string, displayID uuid.UUID) error {
+	const op = "ActivationService.ActivateCode"
+
+	code, err := s.ValidateCode(ctx, userCode, siteID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.MarkActivated(ctx, code.ID, displayID); err != nil {
+		if werrors.IsConflict(err) {
+			return werrors.NewError("CODE_USED", "Activation code already used", op, ErrAlreadyActivated)
+		}
+		return werrors.NewError("SAVE_FAILED", "Failed to record activation", op, err)
+	}
+
+	return nil
+}
+
+func (s *service) CheckActivation(ctx context.Context, deviceCode string) (*DeviceCode, error) {
+	const op = "ActivationService.CheckActivation"
+
+	// Enrollment tokens are kept with device codes but are not polled with
+	code, err := s.repo.FindByDeviceCode(ctx, deviceCode)
+	if err == nil && code.Enrollment() {
+		err = werrors.NewError("NOT_FOUND", "not a device code", op, werrors.ErrNotFound)
+	}
+	if err != nil {
+		if werrors.IsNotFound(err) {
+			return nil, werrors.NewError("CODE_NOT_FOUND", "Device code not found", op, ErrCodeNotFound)
+		}
+		return nil, werrors.NewError("LOOKUP_FAILED", "Failed to look up device code", op, err)
+	}
+
+	if code.Redeemed {
+		return nil, werrors.NewError("CODE_REDEEMED", "Device code already redeemed", op, ErrCodeRedeemed)
+	}
+	if code.Activated {
+		return code, nil
+	}
+	if code.Expired(s.clock.Now()) {
+		return nil, werrors.NewError("CODE_EXPIRED", "Device code expired", op, ErrCodeExpired)
+	}
+
+	return nil, werrors.NewError("AUTHORIZATION_PENDING", "Activation pending", op, ErrAuthorizationPending)
+}
+
+func (s *service) Redeem(ctx context.Context, deviceCode string) (*DeviceCode, error) {
+	const op = "ActivationService.Redeem"
+
+	code, err := s.CheckActivation(ctx, deviceCode)
+	if err != nil {
+		return nil, err
+	}
+
+	// Concurrent polls all pass the check above; marking the code is
+	// atomic, so only one of them gets past here
+	if err := s.repo.MarkRedeemed(ctx, code.ID); err != nil {
+		if werrors.IsConflict(err) {
+			return nil, werrors.NewError("CODE_REDEEMED", "Device code already redeemed", op, ErrCodeRedeemed)
+		}
+		return nil, werrors.NewError("SAVE_FAILED", "Failed to record redemption", op, err)
+	}
+	code.Redeemed = true
+
+	return code, nil
+}
+
+// IssueEnrollmentToken mints an enrollment token for a provisioned display.
+// The token is a device code without a user code, so it cannot be entered
+// on the activation page.
+func (s *service) IssueEnrollmentToken(ctx context.Context, displayID uuid.UUID) (*DeviceCode, error) {
+	const op = "ActivationService.IssueEnrollmentToken"
+
+	token, err := randomDeviceCode()
+	if err != nil {
+		return nil, werrors.NewError("GENERATE_FAILED", "Failed to generate enrollment token", op, err)
+	}
+
+	now := s.clock.Now()
+	code := &DeviceCode{
+		ID:         uuid.New(),
+		Kind:       KindEnrollment,
+		DeviceCode: token,
+		ExpiresAt:  now.Add(s.enrollmentExpiry),
+		DisplayID:  displayID,
+		CreatedAt:  now,
+	}
+	if err := s.repo.Save(ctx, code); err != nil {
+		return nil, werrors.NewError("SAVE_FAILED", "Failed to save enrollment token", op, err)
+	}
+	return code, nil
+}
+
+func (s *service) CheckEnrollment(ctx context.Context, token string) (*DeviceCode, error) {
+	const op = "ActivationService.CheckEnrollment"
+
+	code, err := s.repo.FindByDeviceCode(ctx, token)
+	if err == nil && !code.Enrollment() {
+		err = werrors.NewError("NOT_FOUND", "not an enrollment token", op, werrors.ErrNotFound)
+	}
+	if err != nil {
+		if werrors.IsNotFound(err) {
+			return nil, werrors.NewError("CODE_NOT_FOUND", "Enrollment token not found", op, ErrCodeNotFound)
+		}
+		return nil, werrors.NewError("LOOKUP_FAILED", "Failed to look up enrollment token", op, err)
+	}
+
+	if code.Activated {
+		return nil, werrors.NewError("CODE_USED", "Enrollment token already used", op, ErrAlreadyActivated)
+	}
+	if code.Expired(s.clock.Now()) {
+		return nil, werrors.NewError("CODE_EXPIRED", "Enrollment token expired", op, ErrCodeExpired)
+	}
+
+	return code, nil
+}
+
+func (s *service) Enroll(ctx context.Context, token string) (*DeviceCode, error) {
+	const op = "ActivationService.Enroll"
+
+	code, err := s.CheckEnrollment(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	// Concurrent uses all pass the check above; marking the token is
+	// atomic, so only one of them gets past here
+	if err := s.repo.MarkActivated(ctx, code.ID, code.DisplayID); err != nil {
+		if werrors.IsConflict(err) {
+			return nil, werrors.NewError("CODE_USED", "Enrollment token already used", op, ErrAlreadyActivated)
+		}
+		return nil, werrors.NewError("SAVE_FAILED", "Failed to record enrollment", op, err)
+	}
+	code.Activated = true
+
+	return code, nil
+}
+
+// NormalizeUserCode canonicalises user input so that codes are matched
+// regardless of case or surrounding whitespace
+func NormalizeUserCode(userCode string) string {
+	return strings.ToUpper(strings.TrimSpace(userCode))
+}
+
+// randomDeviceCode returns a hex encoded random secret
+func randomDeviceCode() (string, error) {
+	b := make([]byte, deviceCodeBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// randomUserCode returns two random words joined by a dash
+func randomUserCode() (string, error) {
+	words := make([]string, 2)
+	for i := range words {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(userCodeWords))))
+		if err != nil {
+			return "", fmt.Errorf("failed to pick code word: %w", err)
+		}
+		words[i] = userCodeWords[n.Int64()]
+	}
+	return strings.Join(words, "-"), nil
+}
@@ -0,0 +1,159 @@
+package activation_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/clock"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/activation"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+)
+
+func TestCodeExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := clock.NewFake(time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC))
+	svc := activation.NewService(memory.NewActivationRepository(), 10*time.Minute, activation.SitePolicy{}, activation.WithClock(now))
+
+	code, err := svc.GenerateCode(ctx, "", activation.Hints{})
+	require.NoError(t, err)
+	assert.Equal(t, now.Now(), code.CreatedAt)
+	assert.Equal(t, now.Now().Add(10*time.Minute), code.ExpiresAt)
+
+	// Usable until the last moment before it expires
+	now.Advance(10*time.Minute - time.Nanosecond)
+	_, err = svc.ValidateCode(ctx, code.UserCode, "")
+	assert.NoError(t, err)
+	_, err = svc.CheckActivation(ctx, code.DeviceCode)
+	assert.ErrorIs(t, err, activation.ErrAuthorizationPending)
+
+	// and expired from its expiry time on
+	now.Advance(time.Nanosecond)
+	_, err = svc.ValidateCode(ctx, code.UserCode, "")
+	assert.ErrorIs(t, err, activation.ErrCodeExpired)
+	_, err = svc.CheckActivation(ctx, code.DeviceCode)
+	assert.ErrorIs(t, err, activation.ErrCodeExpired)
+	assert.ErrorIs(t, svc.ActivateCode(ctx, code.UserCode, "", uuid.New()), activation.ErrCodeExpired)
+
+	// Cleanup keeps the code while it is only just expired
+	require.NoError(t, svc.CleanupExpired(ctx))
+	_, err = svc.CheckActivation(ctx, code.DeviceCode)
+	assert.ErrorIs(t, err, activation.ErrCodeExpired)
+
+	now.Advance(time.Nanosecond)
+	require.NoError(t, svc.CleanupExpired(ctx))
+	_, err = svc.CheckActivation(ctx, code.DeviceCode)
+	assert.ErrorIs(t, err, activation.ErrCodeNotFound)
+}
+
+func TestActivatedCodesOutliveExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := clock.NewFake(time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC))
+	svc := activation.NewService(memory.NewActivationRepository(), time.Minute, activation.SitePolicy{}, activation.WithClock(now))
+
+	code, err := svc.GenerateCode(ctx, "", activation.Hints{})
+	require.NoError(t, err)
+	displayID := uuid.New()
+	require.NoError(t, svc.ActivateCode(ctx, code.UserCode, "", displayID))
+
+	// A display that polls late still collects its activation
+	now.Advance(time.Hour)
+	activated, err := svc.CheckActivation(ctx, code.DeviceCode)
+	require.NoError(t, err)
+	assert.Equal(t, displayID, activated.DisplayID)
+
+	// Once redeemed the code is not honoured again
+	redeemed, err := svc.Redeem(ctx, code.DeviceCode)
+	require.NoError(t, err)
+	assert.True(t, redeemed.Redeemed)
+	_, err = svc.Redeem(ctx, code.DeviceCode)
+	assert.ErrorIs(t, err, activation.ErrCodeRedeemed)
+	_, err = svc.CheckActivation(ctx, code.DeviceCode)
+	assert.ErrorIs(t, err, activation.ErrCodeRedeemed)
+}
+
+func TestEnrollmentTokens(t *testing.T) {
+	ctx := context.Background()
+	now := clock.NewFake(time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC))
+	svc := activation.NewService(memory.NewActivationRepository(), time.Minute, activation.SitePolicy{},
+		activation.WithClock(now), activation.WithEnrollmentExpiry(7*24*time.Hour))
+
+	displayID := uuid.New()
+	code, err := svc.IssueEnrollmentToken(ctx, displayID)
+	require.NoError(t, err)
+	assert.Equal(t, activation.KindEnrollment, code.Kind)
+	assert.Equal(t, displayID, code.DisplayID)
+	assert.Empty(t, code.UserCode)
+	assert.Equal(t, now.Now().Add(7*24*time.Hour), code.ExpiresAt)
+
+	t.Run("not usable in the device flow", func(t *testing.T) {
+		_, err := svc.CheckActivation(ctx, code.DeviceCode)
+		assert.ErrorIs(t, err, activation.ErrCodeNotFound)
+		_, err = svc.ValidateCode(ctx, "", "")
+		assert.ErrorIs(t, err, activation.ErrCodeNotFound)
+
+		device, err := svc.GenerateCode(ctx, "", activation.Hints{})
+		require.NoError(t, err)
+		_, err = svc.Enroll(ctx, device.DeviceCode)
+		assert.ErrorIs(t, err, activation.ErrCodeNotFound)
+	})
+
+	t.Run("used once however many displays race for it", func(t *testing.T) {
+		other, err := svc.IssueEnrollmentToken(ctx, uuid.New())
+		require.NoError(t, err)
+
+		const attempts = 20
+		var (
+			wg        sync.WaitGroup
+			mu        sync.Mutex
+			enrolled  int
+			refused   int
+			otherErrs []error
+		)
+		for i := 0; i < attempts; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := svc.Enroll(ctx, other.DeviceCode)
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case err == nil:
+					enrolled++
+				case errors.Is(err, activation.ErrAlreadyActivated):
+					refused++
+				default:
+					otherErrs = append(otherErrs, err)
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Empty(t, otherErrs)
+		assert.Equal(t, 1, enrolled)
+		assert.Equal(t, attempts-1, refused)
+
+		_, err = svc.CheckEnrollment(ctx, other.DeviceCode)
+		assert.ErrorIs(t, err, activation.ErrAlreadyActivated)
+	})
+
+	t.Run("expires", func(t *testing.T) {
+		now.Advance(7*24*time.Hour - time.Nanosecond)
+		_, err := svc.CheckEnrollment(ctx, code.DeviceCode)
+		require.NoError(t, err)
+
+		now.Advance(time.Nanosecond)
+		_, err = svc.CheckEnrollment(ctx, code.DeviceCode)
+		assert.ErrorIs(t, err, activation.ErrCodeExpired)
+		_, err = svc.Enroll(ctx, code.DeviceCode)
+		assert.ErrorIs(t, err, activation.ErrCodeExpired)
+
+		_, err = svc.Enroll(ctx, "no-such-token")
+		assert.ErrorIs(t, err, activation.ErrCodeNotFound)
+	})
+}
@@ -0,0 +1,22 @@
+package display
+
+// AllSites is the ApprovalPolicy entry that requires approval everywhere
+const AllSites = "*"
+
+// ApprovalPolicy decides which displays wait for an operator's approval
+// after activation instead of becoming active at once
+type ApprovalPolicy struct {
+	// Sites lists the sites whose displays need approval. AllSites covers
+	// every site; an empty list disables approval.
+	Sites []string
+}
+
+// Requires reports whether displays at siteID need approval
+func (p ApprovalPolicy) Requires(siteID string) bool {
+	for _, site := range p.Sites {
+		if site == AllSites || site == siteID {
+			return true
+		}
+	}
+	return false
+}
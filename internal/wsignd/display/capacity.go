@@ -0,0 +1,43 @@
+package display
+
+// Capacity caps how many displays a site and each zone may hold, so that a
+// provisioning script gone wrong cannot register displays without bound. A
+// cap of zero means no cap.
+type Capacity struct {
+	// MaxPerSite caps the displays at each site not listed in Sites
+	MaxPerSite int
+	// Sites overrides MaxPerSite by site ID
+	Sites map[string]int
+	// MaxPerZone caps the displays in each zone not listed in Zones
+	MaxPerZone int
+	// Zones overrides MaxPerZone by ZoneKey
+	Zones map[string]int
+}
+
+// Limits are the caps that apply to one location. Zero means no cap.
+type Limits struct {
+	Site int
+	Zone int
+}
+
+// Unlimited reports whether neither cap applies
+func (l Limits) Unlimited() bool {
+	return l.Site <= 0 && l.Zone <= 0
+}
+
+// ZoneKey identifies a zone in Capacity.Zones as site/zone
+func ZoneKey(siteID, zone string) string {
+	return siteID + "/" + zone
+}
+
+// Limits returns the caps that apply to a display at loc
+func (c Capacity) Limits(loc Location) Limits {
+	limits := Limits{Site: c.MaxPerSite, Zone: c.MaxPerZone}
+	if n, ok := c.Sites[loc.SiteID]; ok {
+		limits.Site = n
+	}
+	if n, ok := c.Zones[ZoneKey(loc.SiteID, loc.Zone)]; ok {
+		limits.Zone = n
+	}
+	return limits
+}
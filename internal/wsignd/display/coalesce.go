@@ -0,0 +1,33 @@
+package display
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/coalesce"
+)
+
+// coalescingRepository shares lookups by ID that are in flight at the same
+// time, such as those made when the displays at a site reconnect together
+type coalescingRepository struct {
+	Repository
+	byID *coalesce.Group[*Display]
+}
+
+// NewCoalescingRepository wraps repo so that concurrent FindByID calls for
+// the same display make a single query. A display found this way may miss a
+// change saved while the shared query ran, as any read may; saves still
+// check the version they were read at.
+func NewCoalescingRepository(repo Repository) Repository {
+	return &coalescingRepository{
+		Repository: repo,
+		byID:       coalesce.New((*Display).Clone),
+	}
+}
+
+func (r *coalescingRepository) FindByID(ctx context.Context, id uuid.UUID) (*Display, error) {
+	return r.byID.Do(ctx, id.String(), func(ctx context.Context) (*Display, error) {
+		return r.Repository.FindByID(ctx, id)
+	})
+}
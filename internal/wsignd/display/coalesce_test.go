@@ -0,0 +1,82 @@
+package display_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// countingRepository counts lookups by ID and makes each take a moment, as
+// a query would
+type countingRepository struct {
+	display.Repository
+	queries atomic.Int64
+}
+
+func (r *countingRepository) FindByID(ctx context.Context, id uuid.UUID) (*display.Display, error) {
+	r.queries.Add(1)
+	time.Sleep(5 * time.Millisecond)
+	return r.Repository.FindByID(ctx, id)
+}
+
+func TestCoalescingRepository(t *testing.T) {
+	ctx := context.Background()
+	counting := &countingRepository{Repository: memory.NewRepository()}
+	repo := display.NewCoalescingRepository(counting)
+
+	ids := make([]uuid.UUID, 4)
+	for i := range ids {
+		d, err := display.NewDisplay(fmt.Sprintf("display-%d", i), display.Location{SiteID: "hq", Zone: "lobby"})
+		require.NoError(t, err)
+		d.Properties["orientation"] = "landscape"
+		require.NoError(t, repo.Save(ctx, d))
+		ids[i] = d.ID
+	}
+
+	// findAll looks up the display for each of n callers at once
+	findAll := func(n int, id func(i int) uuid.UUID) ([]*display.Display, []error) {
+		found, errs := make([]*display.Display, n), make([]error, n)
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				found[i], errs[i] = repo.FindByID(ctx, id(i))
+			}(i)
+		}
+		wg.Wait()
+		return found, errs
+	}
+
+	found, errs := findAll(100, func(int) uuid.UUID { return ids[0] })
+	assert.LessOrEqual(t, counting.queries.Load(), int64(5))
+	for i := range found {
+		require.NoError(t, errs[i])
+		assert.Equal(t, ids[0], found[i].ID)
+	}
+	found[0].Properties["orientation"] = "portrait"
+	assert.Equal(t, "landscape", found[1].Properties["orientation"], "callers get their own copies")
+
+	found, errs = findAll(100, func(i int) uuid.UUID { return ids[i%len(ids)] })
+	for i := range found {
+		require.NoError(t, errs[i])
+		assert.Equal(t, ids[i%len(ids)], found[i].ID, "displays are not shared between IDs")
+	}
+
+	missing := uuid.New()
+	_, errs = findAll(10, func(int) uuid.UUID { return missing })
+	for _, err := range errs {
+		assert.True(t, werrors.IsNotFound(err), "got %v", err)
+	}
+}
@@ -6,6 +6,11 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+	"github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/properties"
+	"github.com/wrale/wrale-signage/internal/wsignd/timezone"
 )
 
 // State represents the current state of a display
@@ -14,6 +19,9 @@ type State string
 const (
 	// StateUnregistered indicates a display that hasn't completed registration
 	StateUnregistered State = "UNREGISTERED"
+	// StatePendingApproval indicates an activated display that waits for an
+	// operator's approval before it is issued tokens
+	StatePendingApproval State = "PENDING_APPROVAL"
 	// StateActive indicates a properly registered and active display
 	StateActive State = "ACTIVE"
 	// StateOffline indicates a display that hasn't communicated recently
@@ -32,12 +40,36 @@ type Display struct {
 	Location Location
 	// State represents the display's current operational state
 	State State
-	// LastSeen is when the display last contacted the server
+	// LastSeen is when the display last contacted the server. It is zero
+	// until the display first does, e.g. for a display created ahead of
+	// installation.
 	LastSeen time.Time
-	// Version tracks optimistic concurrency control
+	// Version tracks optimistic concurrency control. It is advanced by the
+	// repository when changes are saved, not by the mutating methods.
 	Version int
 	// Properties contains arbitrary key-value pairs for display metadata
 	Properties map[string]string
+	// Settings are the device settings pushed to the display
+	Settings Settings
+	// LastError is the content failure the display most recently reported,
+	// nil once it has loaded content successfully since. It is maintained
+	// from content events and not written by Save.
+	LastError *ContentError
+	// CreatedAt and UpdatedAt are maintained by the repository
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ContentError describes a content failure reported by a display
+type ContentError struct {
+	// Code classifies the failure
+	Code string
+	// Message describes the failure
+	Message string
+	// URL is the content that failed
+	URL string
+	// At is when the display reported the failure
+	At time.Time
 }
 
 // Location represents where a display is physically located
@@ -50,6 +82,46 @@ type Location struct {
 	Position string
 }
 
+// maxNameLength caps display names, which appear in URLs and logs
+const maxNameLength = 128
+
+// nameProblem describes what keeps name from identifying a display, or
+// returns "" for a valid name. Names are up to 128 ASCII letters, digits,
+// dots, underscores and hyphens, beginning and ending with a letter or digit.
+// Since displays are looked up by name or ID, a name may not parse as a UUID.
+func nameProblem(name string) string {
+	switch {
+	case name == "":
+		return "name cannot be empty"
+	case len(name) > maxNameLength:
+		return fmt.Sprintf("name is longer than %d characters", maxNameLength)
+	case !alphanumeric(name[0]) || !alphanumeric(name[len(name)-1]):
+		return "name must begin and end with a letter or digit"
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; !alphanumeric(c) && c != '.' && c != '_' && c != '-' {
+			return "name may only contain letters, digits, '.', '_' and '-'"
+		}
+	}
+	if _, err := uuid.Parse(name); err == nil {
+		return "name cannot be a UUID"
+	}
+	return ""
+}
+
+// ValidateName returns an error describing why name cannot identify a
+// display, or nil if it can
+func ValidateName(name string) error {
+	if reason := nameProblem(name); reason != "" {
+		return fmt.Errorf("%s", reason)
+	}
+	return nil
+}
+
+func alphanumeric(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
 // NewDisplay creates a new display with the given name and location
 func NewDisplay(name string, location Location) (*Display, error) {
 	if name == "" {
@@ -64,32 +136,89 @@ func NewDisplay(name string, location Location) (*Display, error) {
 		Name:       name,
 		Location:   location,
 		State:      StateUnregistered,
-		LastSeen:   time.Now(),
 		Version:    1,
 		Properties: make(map[string]string),
 	}, nil
 }
 
-// Activate transitions the display to the active state
+// Activate transitions the display to the active state. A display awaiting
+// approval must be approved instead.
 func (d *Display) Activate() error {
-	if d.State == StateDisabled {
+	switch d.State {
+	case StateDisabled:
 		return fmt.Errorf("cannot activate disabled display")
+	case StatePendingApproval:
+		return fmt.Errorf("display is awaiting approval")
+	}
+	d.State = StateActive
+	return nil
+}
+
+// AwaitApproval holds a newly registered display until an operator approves
+// it
+func (d *Display) AwaitApproval() error {
+	if d.State != StateUnregistered {
+		return fmt.Errorf("only unregistered displays can await approval, display is %s", d.State)
+	}
+	d.State = StatePendingApproval
+	return nil
+}
+
+// Approve activates a display that is awaiting approval
+func (d *Display) Approve() error {
+	if d.State != StatePendingApproval {
+		return fmt.Errorf("display is not awaiting approval, it is %s", d.State)
 	}
 	d.State = StateActive
-	d.Version++
 	return nil
 }
 
+// Clone returns a copy of d that shares no mutable state with it
+func (d *Display) Clone() *Display {
+	c := *d
+	c.Properties = make(map[string]string, len(d.Properties))
+	for k, v := range d.Properties {
+		c.Properties[k] = v
+	}
+	c.Settings = d.Settings.Clone()
+	if d.LastError != nil {
+		e := *d.LastError
+		c.LastError = &e
+	}
+	return &c
+}
+
 // Disable transitions the display to the disabled state
 func (d *Display) Disable() {
 	d.State = StateDisabled
-	d.Version++
 }
 
 // UpdateLastSeen updates the display's last seen timestamp
 func (d *Display) UpdateLastSeen() {
 	d.LastSeen = time.Now()
-	d.Version++
+}
+
+// Seen records contact from the display at the given time. An offline
+// display is returned to the active state; it reports whether the state
+// changed.
+func (d *Display) Seen(at time.Time) bool {
+	d.LastSeen = at
+	if d.State != StateOffline {
+		return false
+	}
+	d.State = StateActive
+	return true
+}
+
+// MarkOffline transitions an active display to the offline state. Displays in
+// other states are not expected to check in and are left alone; it reports
+// whether the state changed.
+func (d *Display) MarkOffline() bool {
+	if d.State != StateActive {
+		return false
+	}
+	d.State = StateOffline
+	return true
 }
 
 // UpdateLocation updates the display's physical location
@@ -98,15 +227,119 @@ func (d *Display) UpdateLocation(location Location) error {
 		return fmt.Errorf("site ID cannot be empty")
 	}
 	d.Location = location
-	d.Version++
 	return nil
 }
 
-// SetProperty sets a display property
-func (d *Display) SetProperty(key, value string) {
-	if d.Properties == nil {
-		d.Properties = make(map[string]string)
+// SetProperty sets a display property. It fails, leaving the display
+// untouched, when the display's properties would then exceed limits.
+func (d *Display) SetProperty(key, value string, limits config.PropertyLimits) error {
+	return d.ApplyPatch(Patch{SetProperties: map[string]string{key: value}}, limits)
+}
+
+// Patch describes a partial update to a display
+type Patch struct {
+	// Name renames the display when set. The ID is kept, so tokens,
+	// connections and history are unaffected.
+	Name string
+	// Location updates the display's location when set. Empty fields keep
+	// their current value.
+	Location *Location
+	// SetProperties adds or overwrites the given properties
+	SetProperties map[string]string
+	// RemoveProperties deletes the given property keys
+	RemoveProperties []string
+	// Settings replaces the display's device settings when set
+	Settings *Settings
+}
+
+// Empty reports whether the patch changes nothing
+func (p Patch) Empty() bool {
+	return p.Name == "" && p.Location == nil && len(p.SetProperties) == 0 && len(p.RemoveProperties) == 0 && p.Settings == nil
+}
+
+// ApplyPatch applies a partial update. The patch is validated before any
+// change is made, so an invalid patch leaves the display untouched. A patch
+// setting properties must leave all of the display's properties within
+// limits; one that only removes them is always accepted, so that displays
+// stored before the limits were lowered can be brought within them.
+func (d *Display) ApplyPatch(p Patch, limits config.PropertyLimits) error {
+	verr := &errors.ValidationError{}
+	if p.Name != "" {
+		if reason := nameProblem(p.Name); reason != "" {
+			verr.Invalid("name", reason)
+		}
+	}
+	for key := range p.SetProperties {
+		if key == "" {
+			verr.Invalid("addProperties", "property key cannot be empty")
+		}
+	}
+	for i, key := range p.RemoveProperties {
+		field := fmt.Sprintf("removeProperties[%d]", i)
+		if key == "" {
+			verr.Invalid(field, "property key cannot be empty")
+		} else if _, ok := p.SetProperties[key]; ok {
+			verr.Invalid(field, fmt.Sprintf("property %q cannot be both set and removed", key))
+		}
+	}
+	if p.Settings != nil {
+		if err := p.Settings.Validate(); err != nil {
+			verr.Invalid("settings", err.Error())
+		}
+	}
+	if len(p.SetProperties) > 0 {
+		properties.Validate(verr, limits, "properties", p.patchedProperties(d.Properties))
+	}
+	if name, ok := p.SetProperties[TimezoneProperty]; ok {
+		if _, err := timezone.Load(name); err != nil {
+			verr.Invalid(fmt.Sprintf("properties[%s]", TimezoneProperty), err.Error())
+		}
+	}
+	if err := verr.Err(); err != nil {
+		return err
+	}
+
+	if p.Name != "" {
+		d.Name = p.Name
+	}
+	if p.Location != nil {
+		d.Location = mergeLocation(d.Location, *p.Location)
+	}
+	if len(p.SetProperties) > 0 || len(p.RemoveProperties) > 0 {
+		d.Properties = p.patchedProperties(d.Properties)
+	}
+	if p.Settings != nil {
+		d.Settings = p.Settings.Clone()
+	}
+	return nil
+}
+
+// patchedProperties returns a copy of current with the patch's property
+// changes made
+func (p Patch) patchedProperties(current map[string]string) map[string]string {
+	out := make(map[string]string, len(current)+len(p.SetProperties))
+	for k, v := range current {
+		out[k] = v
+	}
+	for k, v := range p.SetProperties {
+		out[k] = v
+	}
+	for _, k := range p.RemoveProperties {
+		delete(out, k)
+	}
+	return out
+}
+
+// mergeLocation overlays the non-empty fields of update onto current
+func mergeLocation(current, update Location) Location {
+	if update.SiteID != "" {
+		current.SiteID = update.SiteID
+	}
+	if update.Zone != "" {
+		current.Zone = update.Zone
+	}
+	if update.Position != "" {
+		current.Position = update.Position
 	}
-	d.Properties[key] = value
-	d.Version++
+	return current
 }
@@ -0,0 +1,102 @@
+package display
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// Runtime is what other subsystems hold about a display at the moment, as
+// opposed to its stored record
+type Runtime struct {
+	// Connected is set while the display holds a control connection to
+	// this server
+	Connected bool
+	// PendingMessages counts the control messages queued for the display
+	// until it connects
+	PendingMessages int
+	// LastContent is the display's newest content transition, nil when it
+	// has none
+	LastContent *ContentTransition
+}
+
+// EnrichedDisplay is a display along with its runtime state
+type EnrichedDisplay struct {
+	*Display
+	Runtime Runtime
+}
+
+// Presence tells which displays hold a control connection
+type Presence interface {
+	// ConnectedDisplays returns the IDs of the displays connected to this
+	// server, taken as one snapshot
+	ConnectedDisplays() map[uuid.UUID]bool
+}
+
+// PendingCounter counts the control messages queued for displays
+type PendingCounter interface {
+	// CountPending counts the pending messages of each of the displays at
+	// once. Displays with none have no entry.
+	CountPending(ctx context.Context, displayIDs []uuid.UUID) (map[uuid.UUID]int, error)
+}
+
+// Enricher decorates displays with their runtime state in bulk, so that a
+// list of any length costs one presence snapshot, one count of queued
+// messages and one history query rather than a lookup per display
+type Enricher struct {
+	repo     Repository
+	presence Presence
+	pending  PendingCounter
+}
+
+// NewEnricher creates an enricher reading content history from repo.
+// presence and pending may be nil when there is no hub or no outbox, in
+// which case displays are reported disconnected with nothing queued.
+func NewEnricher(repo Repository, presence Presence, pending PendingCounter) *Enricher {
+	return &Enricher{repo: repo, presence: presence, pending: pending}
+}
+
+// Enrich returns displays decorated with their runtime state, in the same
+// order
+func (e *Enricher) Enrich(ctx context.Context, displays []*Display) ([]*EnrichedDisplay, error) {
+	const op = "DisplayEnricher.Enrich"
+
+	enriched := make([]*EnrichedDisplay, len(displays))
+	if len(displays) == 0 {
+		return enriched, nil
+	}
+	ids := make([]uuid.UUID, len(displays))
+	for i, d := range displays {
+		ids[i] = d.ID
+	}
+
+	var connected map[uuid.UUID]bool
+	if e.presence != nil {
+		connected = e.presence.ConnectedDisplays()
+	}
+	var pending map[uuid.UUID]int
+	if e.pending != nil {
+		var err error
+		if pending, err = e.pending.CountPending(ctx, ids); err != nil {
+			return nil, errors.NewError("LOOKUP_FAILED", "Failed to count queued messages", op, err)
+		}
+	}
+	latest, err := e.repo.LatestContentTransitions(ctx, ids)
+	if err != nil {
+		return nil, errors.NewError("LOOKUP_FAILED", "Failed to retrieve content history", op, err)
+	}
+
+	for i, d := range displays {
+		enriched[i] = &EnrichedDisplay{
+			Display: d,
+			Runtime: Runtime{
+				Connected:       connected[d.ID],
+				PendingMessages: pending[d.ID],
+				LastContent:     latest[d.ID],
+			},
+		}
+	}
+	return enriched, nil
+}
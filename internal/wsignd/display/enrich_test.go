@@ -0,0 +1,176 @@
+package display_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// countingHistory counts the history queries made through it
+type countingHistory struct {
+	display.Repository
+	queries atomic.Int64
+}
+
+func (r *countingHistory) LatestContentTransitions(ctx context.Context, displayIDs []uuid.UUID) (map[uuid.UUID]*display.ContentTransition, error) {
+	r.queries.Add(1)
+	return r.Repository.LatestContentTransitions(ctx, displayIDs)
+}
+
+// presence is a fixed set of connected displays that counts its snapshots
+type presence struct {
+	connected map[uuid.UUID]bool
+	snapshots atomic.Int64
+}
+
+func (p *presence) ConnectedDisplays() map[uuid.UUID]bool {
+	p.snapshots.Add(1)
+	return p.connected
+}
+
+// pendingCounts is a fixed count of queued messages that counts its queries
+type pendingCounts struct {
+	counts  map[uuid.UUID]int
+	err     error
+	queries atomic.Int64
+}
+
+func (p *pendingCounts) CountPending(ctx context.Context, displayIDs []uuid.UUID) (map[uuid.UUID]int, error) {
+	p.queries.Add(1)
+	if p.err != nil {
+		return nil, p.err
+	}
+	counts := make(map[uuid.UUID]int)
+	for _, id := range displayIDs {
+		if n, ok := p.counts[id]; ok {
+			counts[id] = n
+		}
+	}
+	return counts, nil
+}
+
+// enrichFixture creates n displays in an in-memory repository, every
+// third connected, every fifth with queued messages and every other with
+// content history
+func enrichFixture(tb testing.TB, n int) ([]*display.Display, *countingHistory, *presence, *pendingCounts) {
+	tb.Helper()
+	ctx := context.Background()
+	repo := &countingHistory{Repository: memory.NewRepository()}
+	live := &presence{connected: make(map[uuid.UUID]bool)}
+	queued := &pendingCounts{counts: make(map[uuid.UUID]int)}
+
+	displays := make([]*display.Display, n)
+	for i := range displays {
+		d, err := display.NewDisplay(fmt.Sprintf("display-%04d", i), display.Location{SiteID: "hq", Zone: "lobby"})
+		require.NoError(tb, err)
+		require.NoError(tb, repo.Create(ctx, d, display.Limits{}))
+		displays[i] = d
+
+		if i%3 == 0 {
+			live.connected[d.ID] = true
+		}
+		if i%5 == 0 {
+			queued.counts[d.ID] = i%7 + 1
+		}
+		if i%2 == 0 {
+			for j := 0; j < 2; j++ {
+				require.NoError(tb, repo.AppendContentTransition(ctx, &display.ContentTransition{
+					DisplayID: d.ID,
+					Timestamp: time.Date(2024, 1, 1, 0, i%60, j, 0, time.UTC),
+					ToURL:     fmt.Sprintf("https://example.com/%d/%d", i, j),
+					Trigger:   display.TriggerAssignmentChange,
+				}, 10))
+			}
+		}
+	}
+	return displays, repo, live, queued
+}
+
+func TestEnricher(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("runtime state matches each source", func(t *testing.T) {
+		displays, repo, live, queued := enrichFixture(t, 30)
+		enriched, err := display.NewEnricher(repo, live, queued).Enrich(ctx, displays)
+		require.NoError(t, err)
+		require.Len(t, enriched, len(displays))
+
+		for i, e := range enriched {
+			assert.Same(t, displays[i], e.Display, "order is kept")
+			assert.Equal(t, live.connected[e.ID], e.Runtime.Connected)
+			assert.Equal(t, queued.counts[e.ID], e.Runtime.PendingMessages)
+
+			history, err := repo.ListContentTransitions(ctx, e.ID, 1)
+			require.NoError(t, err)
+			if len(history) == 0 {
+				assert.Nil(t, e.Runtime.LastContent)
+				continue
+			}
+			require.NotNil(t, e.Runtime.LastContent)
+			assert.Equal(t, history[0].ToURL, e.Runtime.LastContent.ToURL)
+			assert.Equal(t, history[0].Timestamp, e.Runtime.LastContent.Timestamp)
+		}
+		assert.Equal(t, int64(1), live.snapshots.Load())
+		assert.Equal(t, int64(1), queued.queries.Load())
+		assert.Equal(t, int64(1), repo.queries.Load())
+	})
+
+	t.Run("no hub or outbox leaves displays idle", func(t *testing.T) {
+		displays, repo, _, _ := enrichFixture(t, 4)
+		enriched, err := display.NewEnricher(repo, nil, nil).Enrich(ctx, displays)
+		require.NoError(t, err)
+		for _, e := range enriched {
+			assert.False(t, e.Runtime.Connected)
+			assert.Zero(t, e.Runtime.PendingMessages)
+		}
+		assert.NotNil(t, enriched[0].Runtime.LastContent)
+	})
+
+	t.Run("an empty list queries nothing", func(t *testing.T) {
+		_, repo, live, queued := enrichFixture(t, 0)
+		enriched, err := display.NewEnricher(repo, live, queued).Enrich(ctx, nil)
+		require.NoError(t, err)
+		assert.Empty(t, enriched)
+		assert.Zero(t, live.snapshots.Load()+queued.queries.Load()+repo.queries.Load())
+	})
+
+	t.Run("source failures are returned", func(t *testing.T) {
+		displays, repo, live, queued := enrichFixture(t, 2)
+		queued.err = werrors.NewError("DATABASE_ERROR", "connection refused", "test", nil)
+		_, err := display.NewEnricher(repo, live, queued).Enrich(ctx, displays)
+		assert.Error(t, err)
+	})
+}
+
+// BenchmarkEnrich decorates 1,000 displays and reports the lookups each
+// list costs, which stay at one per source however long the list
+func BenchmarkEnrich(b *testing.B) {
+	const displays = 1000
+	list, repo, live, queued := enrichFixture(b, displays)
+	enricher := display.NewEnricher(repo, live, queued)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := enricher.Enrich(ctx, list); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	lookups := live.snapshots.Load() + queued.queries.Load() + repo.queries.Load()
+	if per := float64(lookups) / float64(b.N); per != 3 {
+		b.Fatalf("enriching %d displays took %.1f lookups, want 3", displays, per)
+	}
+	b.ReportMetric(float64(repo.queries.Load())/float64(b.N), "queries/list")
+}
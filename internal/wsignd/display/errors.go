@@ -1,7 +1,16 @@
 // Package display implements the display domain model and business logic
 package display
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// ErrNotConnected indicates a display has no open control connection to
+// deliver a message over
+var ErrNotConnected = errors.New("display not connected")
 
 // ErrVersionMismatch indicates a concurrent modification conflict
 type ErrVersionMismatch struct {
@@ -12,6 +21,11 @@ func (e ErrVersionMismatch) Error() string {
 	return fmt.Sprintf("version mismatch for display %s: concurrent modification detected", e.ID)
 }
 
+// Is reports whether target is the shared version mismatch sentinel
+func (e ErrVersionMismatch) Is(target error) bool {
+	return target == werrors.ErrVersionMismatch
+}
+
 // ErrNotFound indicates a display lookup failure
 type ErrNotFound struct {
 	ID string
@@ -21,6 +35,28 @@ func (e ErrNotFound) Error() string {
 	return fmt.Sprintf("display not found: %s", e.ID)
 }
 
+// Is reports whether target is the shared not found sentinel
+func (e ErrNotFound) Is(target error) bool {
+	return target == werrors.ErrNotFound
+}
+
+// ErrLimitExceeded indicates that adding a display would take a site or
+// zone past its configured cap
+type ErrLimitExceeded struct {
+	// Scope names the site or zone that is full, e.g. site "hq"
+	Scope string
+	Limit int
+}
+
+func (e ErrLimitExceeded) Error() string {
+	return fmt.Sprintf("%s already has its maximum of %d displays", e.Scope, e.Limit)
+}
+
+// Is reports whether target is the shared limit exceeded sentinel
+func (e ErrLimitExceeded) Is(target error) bool {
+	return target == werrors.ErrLimitExceeded
+}
+
 // ErrInvalidState indicates an invalid state transition
 type ErrInvalidState struct {
 	Current State
@@ -31,6 +67,11 @@ func (e ErrInvalidState) Error() string {
 	return fmt.Sprintf("invalid state transition from %s to %s", e.Current, e.Target)
 }
 
+// Is reports whether target is the shared invalid input sentinel
+func (e ErrInvalidState) Is(target error) bool {
+	return target == werrors.ErrInvalidInput
+}
+
 // ErrInvalidName indicates an invalid display name
 type ErrInvalidName struct {
 	Name   string
@@ -41,6 +82,28 @@ func (e ErrInvalidName) Error() string {
 	return fmt.Sprintf("invalid display name %q: %s", e.Name, e.Reason)
 }
 
+// Is reports whether target is the shared invalid input sentinel
+func (e ErrInvalidName) Is(target error) bool {
+	return target == werrors.ErrInvalidInput
+}
+
+// ErrNameTaken indicates that another display already has a name, compared
+// ignoring case
+type ErrNameTaken struct {
+	Name string
+	// Existing is the display holding the name
+	Existing *Display
+}
+
+func (e ErrNameTaken) Error() string {
+	return fmt.Sprintf("display name %q is taken by display %s (%s)", e.Name, e.Existing.Name, e.Existing.ID)
+}
+
+// Is reports whether target is the shared conflict sentinel
+func (e ErrNameTaken) Is(target error) bool {
+	return target == werrors.ErrConflict
+}
+
 // ErrInvalidLocation indicates an invalid display location
 type ErrInvalidLocation struct {
 	Reason string
@@ -49,3 +112,8 @@ type ErrInvalidLocation struct {
 func (e ErrInvalidLocation) Error() string {
 	return fmt.Sprintf("invalid display location: %s", e.Reason)
 }
+
+// Is reports whether target is the shared invalid input sentinel
+func (e ErrInvalidLocation) Is(target error) bool {
+	return target == werrors.ErrInvalidInput
+}
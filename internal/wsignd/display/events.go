@@ -0,0 +1,75 @@
+package display
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// DefaultEventLogSize is how many lifecycle events are kept per display
+// when no size is given
+const DefaultEventLogSize = 20
+
+// EventLog keeps each display's most recent lifecycle events in memory so
+// support can see what happened to a display lately, and passes every
+// event on to the next publisher. The log is per server and starts empty,
+// so events from before a restart or published by another server are not
+// in it. A deleted display's events are dropped with it.
+type EventLog struct {
+	next EventPublisher
+	size int
+
+	mu     sync.Mutex
+	events map[uuid.UUID][]Event
+}
+
+// NewEventLog creates a log of up to size events per display in front of
+// next. A non-positive size keeps DefaultEventLogSize events.
+func NewEventLog(size int, next EventPublisher) *EventLog {
+	if size <= 0 {
+		size = DefaultEventLogSize
+	}
+	return &EventLog{
+		next:   next,
+		size:   size,
+		events: make(map[uuid.UUID][]Event),
+	}
+}
+
+// Publish records event and passes it on
+func (l *EventLog) Publish(ctx context.Context, event Event) error {
+	l.mu.Lock()
+	if event.Type == EventDeleted {
+		delete(l.events, event.DisplayID)
+	} else {
+		events := append(l.events[event.DisplayID], event)
+		if len(events) > l.size {
+			events = append([]Event(nil), events[len(events)-l.size:]...)
+		}
+		l.events[event.DisplayID] = events
+	}
+	l.mu.Unlock()
+
+	if l.next == nil {
+		return nil
+	}
+	return l.next.Publish(ctx, event)
+}
+
+// Recent returns up to limit of a display's events, newest first. A
+// non-positive limit returns every event kept.
+func (l *EventLog) Recent(displayID uuid.UUID, limit int) []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	events := l.events[displayID]
+	if limit <= 0 || limit > len(events) {
+		limit = len(events)
+	}
+	recent := make([]Event, 0, limit)
+	for i := len(events) - 1; i >= 0 && len(recent) < limit; i-- {
+		recent = append(recent, events[i])
+	}
+	return recent
+}
@@ -0,0 +1,46 @@
+package display
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingPublisher counts the events passed on to it
+type countingPublisher struct {
+	n int
+}
+
+func (p *countingPublisher) Publish(ctx context.Context, event Event) error {
+	p.n++
+	return nil
+}
+
+func TestEventLog(t *testing.T) {
+	ctx := context.Background()
+	next := &countingPublisher{}
+	log := NewEventLog(3, next)
+	id, other := uuid.New(), uuid.New()
+	start := time.Now()
+
+	types := []EventType{EventRegistered, EventActivated, EventOffline, EventOnline}
+	for i, eventType := range types {
+		require.NoError(t, log.Publish(ctx, Event{Type: eventType, DisplayID: id, Timestamp: start.Add(time.Duration(i) * time.Second)}))
+	}
+	require.NoError(t, log.Publish(ctx, Event{Type: EventRegistered, DisplayID: other, Timestamp: start}))
+	assert.Equal(t, 5, next.n, "every event is passed on")
+
+	recent := log.Recent(id, 0)
+	require.Len(t, recent, 3, "only the newest events are kept")
+	assert.Equal(t, EventOnline, recent[0].Type)
+	assert.Equal(t, EventActivated, recent[2].Type)
+	assert.Len(t, log.Recent(id, 2), 2)
+
+	require.NoError(t, log.Publish(ctx, Event{Type: EventDeleted, DisplayID: id, Timestamp: start}))
+	assert.Empty(t, log.Recent(id, 0), "a deleted display's events are dropped")
+	assert.Len(t, log.Recent(other, 0), 1)
+}
@@ -0,0 +1,51 @@
+package display
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+)
+
+// MaxContentHistorySize is the most content transitions kept per display,
+// as enforced by the configuration
+const MaxContentHistorySize = config.MaxContentHistorySize
+
+// ContentTrigger describes what caused a display to change content
+type ContentTrigger string
+
+const (
+	// TriggerSequence indicates the display advanced through its sequence
+	TriggerSequence ContentTrigger = "sequence"
+	// TriggerReload indicates the server told the display to reload
+	TriggerReload ContentTrigger = "reload"
+	// TriggerAssignmentChange indicates the server pushed new content
+	TriggerAssignmentChange ContentTrigger = "assignment-change"
+	// TriggerResync indicates an operator had the server push the
+	// display's current content again
+	TriggerResync ContentTrigger = "resync"
+	// TriggerMaintenance indicates the server pushed the maintenance notice,
+	// or the assigned content again once maintenance ended
+	TriggerMaintenance ContentTrigger = "maintenance"
+)
+
+// ContentTransition records a display switching from one content URL to another
+type ContentTransition struct {
+	// DisplayID identifies the display that changed content
+	DisplayID uuid.UUID
+	// Timestamp is when the change happened
+	Timestamp time.Time
+	// FromURL is the content shown before the change, empty if unknown
+	FromURL string
+	// ToURL is the content shown after the change
+	ToURL string
+	// Trigger is what caused the change
+	Trigger ContentTrigger
+}
+
+// ClampContentHistorySize caps a history size at MaxContentHistorySize.
+// Sizes below 1 are refused by the configuration before they get here.
+func ClampContentHistorySize(n int) int {
+	return min(n, MaxContentHistorySize)
+}
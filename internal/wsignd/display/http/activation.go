@@ -0,0 +1,375 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/activation"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+)
+
+// activationPagePath is where operators enter the code shown on a display
+const activationPagePath = "/activate"
+
+// maxDeviceCodeRequestBytes caps the body of a device code request, which
+// anyone may send before they have a token
+const maxDeviceCodeRequestBytes = 4 << 10
+
+// RequestDeviceCode starts device activation for a display, returning the
+// codes it should show and poll with. The optional body names the site the
+// display may be activated into, and where and under what name it expects
+// to be installed.
+func (h *Handler) RequestDeviceCode(w http.ResponseWriter, r *http.Request) {
+	const op = "DisplayHandler.RequestDeviceCode"
+
+	var req v1alpha1.DeviceCodeRequest
+	body := http.MaxBytesReader(w, r.Body, maxDeviceCodeRequestBytes)
+	if err := json.NewDecoder(body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, werrors.NewError("INVALID_INPUT", "invalid request body", op, werrors.ErrInvalidInput), http.StatusBadRequest)
+		return
+	}
+
+	hints := activation.Hints{Name: req.NameHint}
+	if req.LocationHint != nil {
+		hints.Location = display.Location{
+			SiteID:   req.LocationHint.SiteID,
+			Zone:     req.LocationHint.Zone,
+			Position: req.LocationHint.Position,
+		}
+	}
+
+	code, err := h.activation.GenerateCode(r.Context(), req.SiteID, hints)
+	if err != nil {
+		h.logRequestError(r, "failed to generate device code", err,
+			"siteId", req.SiteID,
+		)
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	// The code was stamped by the activation service's clock a moment
+	// ago, so its lifetime is measured against that clock rather than
+	// the handler's
+	verificationURI := baseURL(r) + activationPagePath
+	httpapi.WriteJSON(w, http.StatusOK, &v1alpha1.DeviceCodeResponse{
+		DeviceCode:              code.DeviceCode,
+		UserCode:                code.UserCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?code=" + url.QueryEscape(code.UserCode),
+		ExpiresIn:               int(code.ExpiresAt.Sub(code.CreatedAt).Seconds()),
+		Interval:                code.PollInterval,
+		SiteID:                  code.AllowedSite,
+	})
+}
+
+// CheckActivationCode describes a user code that can still be activated,
+// with the location and name the display suggested, so that activation
+// forms can be pre-filled
+func (h *Handler) CheckActivationCode(w http.ResponseWriter, r *http.Request) {
+	code, err := h.activation.CheckCode(r.Context(), chi.URLParam(r, "code"))
+	if err != nil {
+		h.logRequestError(r, "failed to check activation code", err)
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, toAPIActivationCode(code))
+}
+
+// toAPIActivationCode converts a device code to the description operators
+// see, leaving out the secret device code
+func toAPIActivationCode(code *activation.DeviceCode) *v1alpha1.ActivationCode {
+	resp := &v1alpha1.ActivationCode{
+		UserCode:  code.UserCode,
+		ExpiresAt: code.ExpiresAt,
+		SiteID:    code.AllowedSite,
+		NameHint:  code.Hints.Name,
+	}
+	if location := code.Hints.Location; location != (display.Location{}) {
+		resp.LocationHint = &v1alpha1.DisplayLocation{
+			SiteID:   location.SiteID,
+			Zone:     location.Zone,
+			Position: location.Position,
+		}
+	}
+	return resp
+}
+
+// PollDeviceCode reports whether a display's device code has been activated.
+// Errors follow RFC 8628 so that displays can use standard device flow logic.
+func (h *Handler) PollDeviceCode(w http.ResponseWriter, r *http.Request) {
+	var req v1alpha1.DeviceTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.DeviceCode == "" {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidRequest, "deviceCode is required", v1alpha1.FieldError{
+			Field:   "deviceCode",
+			Code:    v1alpha1.FieldRequired,
+			Message: "is required",
+		})
+		return
+	}
+
+	code, err := h.activation.CheckActivation(r.Context(), req.DeviceCode)
+	switch {
+	case errors.Is(err, activation.ErrAuthorizationPending):
+		writeOAuthError(w, http.StatusBadRequest, oauthAuthorizationPending, "activation pending")
+		return
+	case errors.Is(err, activation.ErrCodeExpired):
+		writeOAuthError(w, http.StatusBadRequest, oauthExpiredToken, "device code expired")
+		return
+	case errors.Is(err, activation.ErrCodeNotFound):
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, "unknown device code")
+		return
+	case errors.Is(err, activation.ErrCodeRedeemed):
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, "device code already used")
+		return
+	case err != nil:
+		h.logger.Error("failed to check activation",
+			"error", err,
+		)
+		writeOAuthError(w, http.StatusInternalServerError, oauthServerError, "")
+		return
+	}
+
+	d, err := h.service.Get(r.Context(), code.DisplayID)
+	if err != nil {
+		h.logger.Error("failed to get activated display",
+			"error", err,
+			"displayId", code.DisplayID,
+		)
+		writeOAuthError(w, http.StatusInternalServerError, oauthServerError, "")
+		return
+	}
+
+	// Displays held for approval keep polling until an operator approves
+	// them; a display disabled instead will never be issued a token
+	switch d.State {
+	case display.StatePendingApproval:
+		writeOAuthError(w, http.StatusBadRequest, oauthAuthorizationPending, "awaiting operator approval")
+		return
+	case display.StateDisabled:
+		writeOAuthError(w, http.StatusBadRequest, oauthAccessDenied, "display is disabled")
+		return
+	}
+
+	// A device code is exchanged for one token; of concurrent polls only
+	// one redeems it
+	if _, err := h.activation.Redeem(r.Context(), req.DeviceCode); err != nil {
+		if errors.Is(err, activation.ErrCodeRedeemed) {
+			writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, "device code already used")
+			return
+		}
+		h.logger.Error("failed to redeem device code",
+			"error", err,
+			"displayId", d.ID,
+		)
+		writeOAuthError(w, http.StatusInternalServerError, oauthServerError, "")
+		return
+	}
+
+	h.writeDeviceToken(w, r, d)
+}
+
+// writeDeviceToken issues an activated display its access token and writes
+// it with the display
+func (h *Handler) writeDeviceToken(w http.ResponseWriter, r *http.Request, d *display.Display) {
+	resp := &v1alpha1.DeviceTokenResponse{
+		Display: toAPIDisplay(d),
+	}
+	if h.tokens != nil {
+		raw, token, err := h.tokens.IssueDisplayToken(r.Context(), d.ID)
+		if err != nil {
+			h.logger.Error("failed to issue display token",
+				"error", err,
+				"displayId", d.ID,
+			)
+			writeOAuthError(w, http.StatusInternalServerError, oauthServerError, "")
+			return
+		}
+		resp.AccessToken = raw
+		resp.TokenType = "Bearer"
+		// Measured against the auth service's clock, which stamped the
+		// token as it issued it
+		resp.ExpiresIn = int(token.ExpiresAt.Sub(token.IssuedAt).Seconds())
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, resp)
+}
+
+// ActivateDeviceCode registers and activates the display showing the given
+// user code
+func (h *Handler) ActivateDeviceCode(w http.ResponseWriter, r *http.Request) {
+	var req v1alpha1.DisplayRegistrationRequest
+	if err := httpapi.DecodeJSON(r, &req); err != nil {
+		httpapi.WriteError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	d, err := h.activate(r.Context(), &req)
+	if err != nil {
+		h.logRequestError(r, "failed to activate display", err,
+			"name", req.Name,
+			"siteId", req.Location.SiteID,
+		)
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusCreated, &v1alpha1.DisplayRegistrationResponse{
+		Display: toAPIDisplay(d),
+	})
+}
+
+// validateActivationRequest checks that an activation names its code and
+// the display's full location, reporting every missing field
+func validateActivationRequest(code string, location display.Location) error {
+	verr := &werrors.ValidationError{}
+	if strings.TrimSpace(code) == "" {
+		verr.Required("activationCode")
+	}
+	if location.SiteID == "" {
+		verr.Required("location.siteId")
+	}
+	if location.Zone == "" {
+		verr.Required("location.zone")
+	}
+	if location.Position == "" {
+		verr.Required("location.position")
+	}
+	return verr.Err()
+}
+
+// activate validates an activation request, creates and activates the
+// display, and binds it to the device code. A code bound to another site is
+// refused before anything is created. Where the approval policy applies the
+// display is left awaiting approval; otherwise it is assigned content by
+// the auto-assignment rules. It is shared by the API and the activation
+// page.
+func (h *Handler) activate(ctx context.Context, req *v1alpha1.DisplayRegistrationRequest) (*display.Display, error) {
+	const op = "DisplayHandler.activate"
+
+	location := display.Location{
+		SiteID:   strings.TrimSpace(req.Location.SiteID),
+		Zone:     strings.TrimSpace(req.Location.Zone),
+		Position: strings.TrimSpace(req.Location.Position),
+	}
+	if err := validateActivationRequest(req.ActivationCode, location); err != nil {
+		return nil, werrors.NewError("INVALID_INPUT", err.Error(), op, err)
+	}
+
+	if _, err := h.activation.ValidateCode(ctx, req.ActivationCode, location.SiteID); err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = generateDisplayName(location)
+	}
+
+	d, err := h.service.Register(ctx, name, location, req.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.activation.ActivateCode(ctx, req.ActivationCode, location.SiteID, d.ID); err != nil {
+		return nil, err
+	}
+
+	if err := h.service.Activate(ctx, d.ID); err != nil {
+		return nil, err
+	}
+
+	activated, err := h.service.Get(ctx, d.ID)
+	if err != nil {
+		return nil, err
+	}
+	h.autoAssign(ctx, activated)
+	return activated, nil
+}
+
+// generateDisplayName derives a display name from its location with a short
+// random suffix to keep it unique
+func generateDisplayName(location display.Location) string {
+	var parts []string
+	for _, p := range []string{location.SiteID, location.Zone, location.Position} {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			parts = append(parts, strings.Join(strings.Fields(p), "-"))
+		}
+	}
+
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err == nil {
+		parts = append(parts, hex.EncodeToString(suffix))
+	}
+	return strings.Join(parts, "-")
+}
+
+// baseURL returns the scheme and host the request was addressed to
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return fmt.Sprintf("%s://%s", scheme, r.Host)
+}
+
+// toAPIDisplay converts a domain display to its API representation
+func toAPIDisplay(d *display.Display) *v1alpha1.Display {
+	var lastError *v1alpha1.DisplayContentError
+	if d.LastError != nil {
+		lastError = &v1alpha1.DisplayContentError{
+			Code:      d.LastError.Code,
+			Message:   d.LastError.Message,
+			URL:       d.LastError.URL,
+			Timestamp: d.LastError.At,
+		}
+	}
+
+	var settings *v1alpha1.DisplaySettings
+	if !d.Settings.Empty() {
+		settings = toAPISettings(d.Settings)
+	}
+
+	return &v1alpha1.Display{
+		TypeMeta: v1alpha1.TypeMeta{
+			Kind:       "Display",
+			APIVersion: "v1alpha1",
+		},
+		ObjectMeta: v1alpha1.ObjectMeta{
+			ID:        d.ID,
+			Name:      d.Name,
+			CreatedAt: d.CreatedAt,
+			UpdatedAt: d.UpdatedAt,
+		},
+		Spec: v1alpha1.DisplaySpec{
+			Location: v1alpha1.DisplayLocation{
+				SiteID:   d.Location.SiteID,
+				Zone:     d.Location.Zone,
+				Position: d.Location.Position,
+			},
+			Properties: d.Properties,
+			Settings:   settings,
+		},
+		Status: v1alpha1.DisplayStatus{
+			State:     v1alpha1.DisplayState(d.State),
+			LastSeen:  d.LastSeen,
+			Version:   d.Version,
+			LastError: lastError,
+		},
+	}
+}
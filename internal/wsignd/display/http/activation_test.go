@@ -0,0 +1,549 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
+	authmemory "github.com/wrale/wrale-signage/internal/wsignd/auth/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/clock"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/activation"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+type mockActivation struct {
+	mock.Mock
+}
+
+func (m *mockActivation) GenerateCode(ctx context.Context, allowedSite string, hints activation.Hints) (*activation.DeviceCode, error) {
+	args := m.Called(ctx, allowedSite, hints)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*activation.DeviceCode), args.Error(1)
+}
+
+func (m *mockActivation) Redeem(ctx context.Context, deviceCode string) (*activation.DeviceCode, error) {
+	args := m.Called(ctx, deviceCode)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*activation.DeviceCode), args.Error(1)
+}
+
+func (m *mockActivation) CheckCode(ctx context.Context, userCode string) (*activation.DeviceCode, error) {
+	args := m.Called(ctx, userCode)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*activation.DeviceCode), args.Error(1)
+}
+
+func (m *mockActivation) ValidateCode(ctx context.Context, userCode, siteID string) (*activation.DeviceCode, error) {
+	args := m.Called(ctx, userCode, siteID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*activation.DeviceCode), args.Error(1)
+}
+
+func (m *mockActivation) ActivateCode(ctx context.Context, userCode, siteID string, displayID uuid.UUID) error {
+	args := m.Called(ctx, userCode, siteID, displayID)
+	return args.Error(0)
+}
+
+func (m *mockActivation) CheckActivation(ctx context.Context, deviceCode string) (*activation.DeviceCode, error) {
+	args := m.Called(ctx, deviceCode)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*activation.DeviceCode), args.Error(1)
+}
+
+func (m *mockActivation) IssueEnrollmentToken(ctx context.Context, displayID uuid.UUID) (*activation.DeviceCode, error) {
+	args := m.Called(ctx, displayID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*activation.DeviceCode), args.Error(1)
+}
+
+func (m *mockActivation) CheckEnrollment(ctx context.Context, token string) (*activation.DeviceCode, error) {
+	args := m.Called(ctx, token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*activation.DeviceCode), args.Error(1)
+}
+
+func (m *mockActivation) Enroll(ctx context.Context, token string) (*activation.DeviceCode, error) {
+	args := m.Called(ctx, token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*activation.DeviceCode), args.Error(1)
+}
+
+func (m *mockActivation) CleanupExpired(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func TestActivationPage(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	t.Run("prefills code and disables caching", func(t *testing.T) {
+		mockAct := &mockActivation{}
+		mockAct.On("CheckCode", mock.Anything, "BLUE-FISH").Return(nil, werrors.NewError("CODE_NOT_FOUND", "Activation code not found", "test", activation.ErrCodeNotFound))
+		handler := NewHandler(&mockService{}, mockAct, nil, logger)
+		router := NewRouter(handler, ratelimit.NewMemoryService(nil), nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/activate?code=blue-fish", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+		assert.Contains(t, rec.Header().Get("Cache-Control"), "no-store")
+		assert.Equal(t, "no-cache", rec.Header().Get("Pragma"))
+		assert.Contains(t, rec.Body.String(), `value="BLUE-FISH"`)
+		assert.NotContains(t, rec.Body.String(), "suggested by the display")
+	})
+
+	t.Run("prefills the display's hints", func(t *testing.T) {
+		mockAct := &mockActivation{}
+		mockAct.On("CheckCode", mock.Anything, "BLUE-FISH").Return(&activation.DeviceCode{
+			UserCode:    "BLUE-FISH",
+			AllowedSite: "hq",
+			Hints: activation.Hints{
+				Location: display.Location{Zone: "lobby", Position: "north"},
+				Name:     "lobby-north",
+			},
+		}, nil)
+		handler := NewHandler(&mockService{}, mockAct, nil, logger)
+		router := NewRouter(handler, ratelimit.NewMemoryService(nil), nil)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/activate?code=blue-fish", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		for _, field := range []string{`name="site" value="hq"`, `name="zone" value="lobby"`, `name="position" value="north"`, `name="name" value="lobby-north"`} {
+			assert.Contains(t, body, field)
+		}
+		assert.Contains(t, body, "suggested by the display")
+	})
+
+	t.Run("successful activation", func(t *testing.T) {
+		displayID := uuid.New()
+		d := &display.Display{
+			ID:       displayID,
+			Name:     "lobby-north",
+			Location: display.Location{SiteID: "hq", Zone: "lobby", Position: "north"},
+			State:    display.StateActive,
+		}
+
+		mockSvc := &mockService{}
+		mockSvc.On("Register", mock.Anything, "lobby-north", d.Location, mock.Anything).Return(d, nil)
+		mockSvc.On("Activate", mock.Anything, displayID).Return(nil)
+		mockSvc.On("Get", mock.Anything, displayID).Return(d, nil)
+
+		mockAct := &mockActivation{}
+		mockAct.On("ValidateCode", mock.Anything, "BLUE-FISH", "hq").Return(&activation.DeviceCode{UserCode: "BLUE-FISH"}, nil)
+		mockAct.On("ActivateCode", mock.Anything, "BLUE-FISH", "hq", displayID).Return(nil)
+
+		handler := NewHandler(mockSvc, mockAct, nil, logger)
+		router := NewRouter(handler, ratelimit.NewMemoryService(nil), nil)
+
+		form := url.Values{
+			"code":     {"blue-fish"},
+			"site":     {"hq"},
+			"zone":     {"lobby"},
+			"position": {"north"},
+			"name":     {"lobby-north"},
+		}
+		req := httptest.NewRequest(http.MethodPost, "/activate", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "lobby-north")
+		assert.Contains(t, rec.Body.String(), "activated")
+		mockSvc.AssertExpectations(t)
+		mockAct.AssertExpectations(t)
+	})
+
+	t.Run("expired code shows error", func(t *testing.T) {
+		mockAct := &mockActivation{}
+		mockAct.On("ValidateCode", mock.Anything, "BLUE-FISH", "hq").Return(nil,
+			werrors.NewError("CODE_EXPIRED", "Activation code expired", "test", activation.ErrCodeExpired))
+
+		handler := NewHandler(&mockService{}, mockAct, nil, logger)
+		router := NewRouter(handler, ratelimit.NewMemoryService(nil), nil)
+
+		form := url.Values{"code": {"BLUE-FISH"}, "site": {"hq"}, "zone": {"lobby"}, "position": {"north"}}
+		req := httptest.NewRequest(http.MethodPost, "/activate", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusGone, rec.Code)
+		assert.Contains(t, rec.Body.String(), "expired")
+		// Form values are preserved for correction
+		assert.Contains(t, rec.Body.String(), `value="hq"`)
+	})
+
+	t.Run("rate limited under device_code", func(t *testing.T) {
+		limiter := ratelimit.NewMemoryService(map[string]ratelimit.Limit{
+			ratelimit.LimitTypeDeviceCode: {Rate: 1, Period: time.Hour, BurstSize: 1},
+		})
+		handler := NewHandler(&mockService{}, &mockActivation{}, nil, logger)
+		router := NewRouter(handler, limiter, nil)
+
+		for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+			req := httptest.NewRequest(http.MethodGet, "/activate", nil)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			assert.Equal(t, want, rec.Code, "request %d", i)
+		}
+	})
+}
+
+// TestActivateWithProperties activates a display with labels through the
+// API and reads them back
+func TestActivateWithProperties(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	service := display.NewService(memory.NewRepository(), discardPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, logger)
+	activations := activation.NewService(memory.NewActivationRepository(), time.Minute, activation.SitePolicy{})
+	router := NewRouter(NewHandler(service, activations, nil, logger), ratelimit.NewMemoryService(nil), nil)
+
+	do := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, &buf))
+		return rec
+	}
+	userCode := func() string {
+		rec := do(http.MethodPost, "/api/v1alpha1/displays/device/code", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var code v1alpha1.DeviceCodeResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&code))
+		return code.UserCode
+	}
+	labels := map[string]string{"orientation": "portrait", "screen-size": "55"}
+
+	rec := do(http.MethodPost, "/api/v1alpha1/displays/activate", &v1alpha1.DisplayRegistrationRequest{
+		Name:           "cafe-menu-1",
+		ActivationCode: userCode(),
+		Location:       v1alpha1.DisplayLocation{SiteID: "hq", Zone: "cafeteria", Position: "menu-1"},
+		Properties:     labels,
+	})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var resp v1alpha1.DisplayRegistrationResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, labels, resp.Display.Spec.Properties)
+
+	rec = do(http.MethodGet, "/api/v1alpha1/displays/cafe-menu-1", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var got v1alpha1.Display
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	assert.Equal(t, labels, got.Spec.Properties)
+
+	t.Run("invalid properties leave no display behind", func(t *testing.T) {
+		rec := do(http.MethodPost, "/api/v1alpha1/displays/activate", &v1alpha1.DisplayRegistrationRequest{
+			Name:           "cafe-menu-2",
+			ActivationCode: userCode(),
+			Location:       v1alpha1.DisplayLocation{SiteID: "hq", Zone: "cafeteria", Position: "menu-2"},
+			Properties:     map[string]string{"": "portrait"},
+		})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec = do(http.MethodGet, "/api/v1alpha1/displays/cafe-menu-2", nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("every missing field is reported", func(t *testing.T) {
+		rec := do(http.MethodPost, "/api/v1alpha1/displays/activate", &v1alpha1.DisplayRegistrationRequest{
+			Name:           "cafe-menu-3",
+			ActivationCode: userCode(),
+		})
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		var resp v1alpha1.Error
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		assert.Equal(t, "INVALID_INPUT", resp.Code)
+		assert.Equal(t, []v1alpha1.FieldError{
+			{Field: "location.siteId", Code: v1alpha1.FieldRequired, Message: "is required"},
+			{Field: "location.zone", Code: v1alpha1.FieldRequired, Message: "is required"},
+			{Field: "location.position", Code: v1alpha1.FieldRequired, Message: "is required"},
+		}, resp.Errors)
+	})
+
+	t.Run("unknown fields are reported", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1alpha1/displays/activate",
+			strings.NewReader(`{"name":"cafe-menu-4","activationCode":"BLUE-FISH","zone":"cafeteria"}`)))
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		var resp v1alpha1.Error
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, "zone", resp.Errors[0].Field)
+		assert.Equal(t, v1alpha1.FieldUnknown, resp.Errors[0].Code)
+	})
+}
+
+// TestActivationSitePolicy activates device codes bound to a site into that
+// site and others, under both site policies
+func TestActivationSitePolicy(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	newRouter := func(policy activation.SitePolicy) http.Handler {
+		service := display.NewService(memory.NewRepository(), discardPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, logger)
+		activations := activation.NewService(memory.NewActivationRepository(), time.Minute, policy)
+		return NewRouter(NewHandler(service, activations, nil, logger), ratelimit.NewMemoryService(nil), nil)
+	}
+	do := func(router http.Handler, method, path string, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, &buf))
+		return rec
+	}
+	requestCode := func(router http.Handler, siteID string) v1alpha1.DeviceCodeResponse {
+		var body interface{}
+		if siteID != "" {
+			body = &v1alpha1.DeviceCodeRequest{SiteID: siteID}
+		}
+		rec := do(router, http.MethodPost, "/api/v1alpha1/displays/device/code", body)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var code v1alpha1.DeviceCodeResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&code))
+		return code
+	}
+	activate := func(router http.Handler, userCode, siteID, name string) *httptest.ResponseRecorder {
+		return do(router, http.MethodPost, "/api/v1alpha1/displays/activate", &v1alpha1.DisplayRegistrationRequest{
+			Name:           name,
+			ActivationCode: userCode,
+			Location:       v1alpha1.DisplayLocation{SiteID: siteID, Zone: "lobby", Position: "north"},
+		})
+	}
+
+	t.Run("bound code activates into its site only", func(t *testing.T) {
+		router := newRouter(activation.SitePolicy{})
+		code := requestCode(router, "hq")
+		assert.Equal(t, "hq", code.SiteID)
+
+		rec := activate(router, code.UserCode, "annex", "annex-lobby")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		var apiErr v1alpha1.Error
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&apiErr))
+		assert.Equal(t, "ACCESS_DENIED", apiErr.Code)
+		// Nothing was registered for the refused activation
+		assert.Equal(t, http.StatusNotFound, do(router, http.MethodGet, "/api/v1alpha1/displays/annex-lobby", nil).Code)
+
+		// The refusal does not use the code up
+		rec = activate(router, code.UserCode, "hq", "hq-lobby")
+		assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	})
+
+	t.Run("permissive policy lets unbound codes activate anywhere", func(t *testing.T) {
+		router := newRouter(activation.SitePolicy{})
+		code := requestCode(router, "")
+		assert.Empty(t, code.SiteID)
+
+		rec := activate(router, code.UserCode, "annex", "annex-lobby")
+		assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	})
+
+	t.Run("strict policy requires bound codes", func(t *testing.T) {
+		router := newRouter(activation.SitePolicy{Strict: true})
+		rec := do(router, http.MethodPost, "/api/v1alpha1/displays/device/code", nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		code := requestCode(router, "hq")
+		assert.Equal(t, http.StatusForbidden, activate(router, code.UserCode, "annex", "annex-lobby").Code)
+		assert.Equal(t, http.StatusCreated, activate(router, code.UserCode, "hq", "hq-lobby").Code)
+	})
+
+	t.Run("activation page explains the refusal", func(t *testing.T) {
+		router := newRouter(activation.SitePolicy{})
+		code := requestCode(router, "hq")
+
+		form := url.Values{"code": {code.UserCode}, "site": {"annex"}, "zone": {"lobby"}, "position": {"north"}}
+		req := httptest.NewRequest(http.MethodPost, "/activate", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), "cannot be activated at this site")
+	})
+}
+
+// TestActivationHints requests codes with the location and name a display
+// suggests, reads them back as an operator would and activates elsewhere
+func TestActivationHints(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	service := display.NewService(memory.NewRepository(), discardPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, logger)
+	activations := activation.NewService(memory.NewActivationRepository(), time.Minute, activation.SitePolicy{})
+	router := NewRouter(NewHandler(service, activations, nil, logger), ratelimit.NewMemoryService(nil), nil)
+
+	do := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, &buf))
+		return rec
+	}
+	requestCode := func(req *v1alpha1.DeviceCodeRequest) string {
+		rec := do(http.MethodPost, "/api/v1alpha1/displays/device/code", req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var code v1alpha1.DeviceCodeResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&code))
+		return code.UserCode
+	}
+	checkCode := func(userCode string) v1alpha1.ActivationCode {
+		rec := do(http.MethodGet, "/api/v1alpha1/displays/activate/"+strings.ToLower(userCode), nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var code v1alpha1.ActivationCode
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&code))
+		return code
+	}
+
+	t.Run("hints round trip to the operator", func(t *testing.T) {
+		userCode := requestCode(&v1alpha1.DeviceCodeRequest{
+			LocationHint: &v1alpha1.DisplayLocation{SiteID: " hq ", Zone: "lobby", Position: "north"},
+			NameHint:     "lobby-north-47",
+		})
+
+		code := checkCode(userCode)
+		assert.Equal(t, userCode, code.UserCode)
+		assert.Equal(t, &v1alpha1.DisplayLocation{SiteID: "hq", Zone: "lobby", Position: "north"}, code.LocationHint)
+		assert.Equal(t, "lobby-north-47", code.NameHint)
+		assert.NotContains(t, do(http.MethodGet, "/api/v1alpha1/displays/activate/"+userCode, nil).Body.String(), "deviceCode")
+	})
+
+	t.Run("codes without hints have none", func(t *testing.T) {
+		code := checkCode(requestCode(nil))
+		assert.Nil(t, code.LocationHint)
+		assert.Empty(t, code.NameHint)
+	})
+
+	t.Run("activation takes the given location over the hints", func(t *testing.T) {
+		userCode := requestCode(&v1alpha1.DeviceCodeRequest{
+			LocationHint: &v1alpha1.DisplayLocation{SiteID: "hq", Zone: "lobby", Position: "north"},
+			NameHint:     "lobby-north-48",
+		})
+
+		rec := do(http.MethodPost, "/api/v1alpha1/displays/activate", &v1alpha1.DisplayRegistrationRequest{
+			ActivationCode: userCode,
+			Location:       v1alpha1.DisplayLocation{SiteID: "hq", Zone: "cafeteria", Position: "menu-1"},
+		})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var resp v1alpha1.DisplayRegistrationResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		assert.Equal(t, v1alpha1.DisplayLocation{SiteID: "hq", Zone: "cafeteria", Position: "menu-1"}, resp.Display.Spec.Location)
+		assert.NotEqual(t, "lobby-north-48", resp.Display.Name, "the name hint is not applied either")
+
+		// Hints alone do not activate a display
+		rec = do(http.MethodPost, "/api/v1alpha1/displays/activate", &v1alpha1.DisplayRegistrationRequest{
+			ActivationCode: requestCode(&v1alpha1.DeviceCodeRequest{
+				LocationHint: &v1alpha1.DisplayLocation{SiteID: "hq", Zone: "lobby", Position: "south"},
+			}),
+		})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("invalid hints are refused", func(t *testing.T) {
+		rec := do(http.MethodPost, "/api/v1alpha1/displays/device/code", &v1alpha1.DeviceCodeRequest{
+			SiteID:       "hq",
+			LocationHint: &v1alpha1.DisplayLocation{SiteID: "annex", Zone: strings.Repeat("z", 129), Position: "north"},
+			NameHint:     "lobby north",
+		})
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr v1alpha1.Error
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&apiErr))
+		var fields []string
+		for _, e := range apiErr.Errors {
+			fields = append(fields, e.Field)
+		}
+		assert.ElementsMatch(t, []string{"locationHint.zone", "nameHint", "locationHint.siteId"}, fields)
+
+		// Bodies much larger than any valid request are not read
+		rec = do(http.MethodPost, "/api/v1alpha1/displays/device/code", &v1alpha1.DeviceCodeRequest{
+			NameHint: strings.Repeat("n", 8<<10),
+		})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("unknown codes are not found", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/v1alpha1/displays/activate/NO-SUCH", nil).Code)
+	})
+}
+
+// TestActivationExpiresIn checks that the lifetimes reported to a display
+// are measured against the clocks of the services that issued them
+func TestActivationExpiresIn(t *testing.T) {
+	now := clock.NewFake(time.Date(2020, 1, 1, 9, 0, 0, 0, time.UTC))
+	key, err := auth.NewEd25519Key("k1", bytes.Repeat([]byte{7}, 32))
+	require.NoError(t, err)
+	keys, err := auth.NewKeyRing(key)
+	require.NoError(t, err)
+	tokens := auth.NewService(keys, authmemory.NewRepository(), time.Hour, auth.WithClock(now))
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	service := display.NewService(memory.NewRepository(), discardPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, logger)
+	activations := activation.NewService(memory.NewActivationRepository(), 10*time.Minute, activation.SitePolicy{}, activation.WithClock(now))
+	router := NewRouter(NewHandler(service, activations, tokens, logger), ratelimit.NewMemoryService(nil), nil)
+
+	do := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, &buf))
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/api/v1alpha1/displays/device/code", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var code v1alpha1.DeviceCodeResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&code))
+	assert.Equal(t, 600, code.ExpiresIn)
+
+	now.Advance(9 * time.Minute)
+	rec = do(http.MethodPost, "/api/v1alpha1/displays/activate", &v1alpha1.DisplayRegistrationRequest{
+		ActivationCode: code.UserCode,
+		Location:       v1alpha1.DisplayLocation{SiteID: "hq", Zone: "lobby", Position: "north"},
+	})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = do(http.MethodPost, "/api/v1alpha1/displays/device/token", &v1alpha1.DeviceTokenRequest{DeviceCode: code.DeviceCode})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var token v1alpha1.DeviceTokenResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&token))
+	assert.Equal(t, 3600, token.ExpiresIn)
+}
@@ -0,0 +1,52 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+)
+
+// ApproveDisplay approves a display awaiting approval, after which its next
+// device code poll is issued tokens, and assigns it content by the
+// auto-assignment rules. The display may be given by ID or name. Approvals
+// are recorded in the audit log along with the operator who made them.
+func (h *Handler) ApproveDisplay(w http.ResponseWriter, r *http.Request) {
+	ref := chi.URLParam(r, "id")
+
+	d, err := h.lookupDisplay(r, ref)
+	if err != nil {
+		h.logRequestError(r, "failed to get display", err, "id", ref)
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.service.Approve(r.Context(), d.ID); err != nil {
+		h.logRequestError(r, "failed to approve display", err, "id", d.ID)
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("display approved",
+		"audit", true,
+		"displayId", d.ID,
+		"displayName", d.Name,
+		"siteId", d.Location.SiteID,
+		"operator", operator.Name(r.Context()),
+		"remoteAddr", r.RemoteAddr,
+		"requestId", middleware.GetReqID(r.Context()),
+	)
+
+	approved, err := h.service.Get(r.Context(), d.ID)
+	if err != nil {
+		h.logRequestError(r, "failed to get approved display", err, "id", d.ID)
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	h.autoAssign(r.Context(), approved)
+
+	httpapi.WriteJSON(w, http.StatusOK, toAPIDisplay(approved))
+}
@@ -0,0 +1,165 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
+	authmemory "github.com/wrale/wrale-signage/internal/wsignd/auth/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/activation"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+type discardPublisher struct{}
+
+func (discardPublisher) Publish(ctx context.Context, event display.Event) error { return nil }
+
+// recordingAssigner records the displays it is asked to assign content
+type recordingAssigner struct {
+	mu       sync.Mutex
+	assigned []uuid.UUID
+}
+
+func (a *recordingAssigner) AutoAssign(ctx context.Context, id uuid.UUID, location v1alpha1.DisplayLocation, properties map[string]string) (*v1alpha1.ContentAssignment, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.assigned = append(a.assigned, id)
+	return nil, nil
+}
+
+func (a *recordingAssigner) displays() []uuid.UUID {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]uuid.UUID(nil), a.assigned...)
+}
+
+// TestApprovalWorkflow runs the device flow against a server that requires
+// approval at one site
+func TestApprovalWorkflow(t *testing.T) {
+	material := make([]byte, 32)
+	_, err := rand.Read(material)
+	require.NoError(t, err)
+	key, err := auth.ParseKey("test", string(auth.AlgorithmHS256), base64.StdEncoding.EncodeToString(material))
+	require.NoError(t, err)
+	keys, err := auth.NewKeyRing(key)
+	require.NoError(t, err)
+	tokens := auth.NewService(keys, authmemory.NewRepository(), time.Hour)
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	service := display.NewService(memory.NewRepository(), discardPublisher{}, 10, display.Liveness{},
+		display.ApprovalPolicy{Sites: []string{"hq"}}, display.Capacity{}, logger)
+	activations := activation.NewService(memory.NewActivationRepository(), time.Minute, activation.SitePolicy{})
+
+	handler := NewHandler(service, activations, tokens, logger)
+	assigner := &recordingAssigner{}
+	handler.SetAutoAssigner(assigner)
+	router := NewRouter(handler, ratelimit.NewMemoryService(nil), nil)
+
+	do := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	activate := func(site string) (deviceCode string, d *v1alpha1.Display) {
+		rec := do(http.MethodPost, "/api/v1alpha1/displays/device/code", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var code v1alpha1.DeviceCodeResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&code))
+
+		rec = do(http.MethodPost, "/api/v1alpha1/displays/activate", &v1alpha1.DisplayRegistrationRequest{
+			Name:           site + "-lobby",
+			ActivationCode: code.UserCode,
+			Location:       v1alpha1.DisplayLocation{SiteID: site, Zone: "lobby", Position: "north"},
+		})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var resp v1alpha1.DisplayRegistrationResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		return code.DeviceCode, resp.Display
+	}
+	poll := func(deviceCode string) (int, v1alpha1.DeviceTokenResponse, v1alpha1.OAuthError) {
+		rec := do(http.MethodPost, "/api/v1alpha1/displays/device/token", &v1alpha1.DeviceTokenRequest{DeviceCode: deviceCode})
+		var token v1alpha1.DeviceTokenResponse
+		var oauthErr v1alpha1.OAuthError
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&token))
+		} else {
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&oauthErr))
+		}
+		return rec.Code, token, oauthErr
+	}
+
+	t.Run("displays at covered sites wait for approval", func(t *testing.T) {
+		deviceCode, d := activate("hq")
+		assert.Equal(t, v1alpha1.DisplayStatePendingApproval, d.Status.State)
+
+		status, _, oauthErr := poll(deviceCode)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, oauthAuthorizationPending, oauthErr.Error)
+		assert.NotContains(t, assigner.displays(), d.ID, "content waits for the approval")
+
+		// Activating directly does not skip the approval
+		rec := do(http.MethodPut, "/api/v1alpha1/displays/"+d.ID.String()+"/activate", nil)
+		assert.NotEqual(t, http.StatusOK, rec.Code)
+		status, _, _ = poll(deviceCode)
+		assert.Equal(t, http.StatusBadRequest, status)
+
+		rec = do(http.MethodPost, "/api/v1alpha1/displays/hq-lobby/approve", nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var approved v1alpha1.Display
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&approved))
+		assert.Equal(t, v1alpha1.DisplayStateActive, approved.Status.State)
+		assert.Contains(t, assigner.displays(), d.ID)
+
+		status, token, _ := poll(deviceCode)
+		require.Equal(t, http.StatusOK, status)
+		assert.NotEmpty(t, token.AccessToken)
+
+		rec = do(http.MethodPost, "/api/v1alpha1/displays/hq-lobby/approve", nil)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("other sites activate at once", func(t *testing.T) {
+		deviceCode, d := activate("annex")
+		assert.Equal(t, v1alpha1.DisplayStateActive, d.Status.State)
+		assert.Contains(t, assigner.displays(), d.ID)
+
+		status, token, _ := poll(deviceCode)
+		require.Equal(t, http.StatusOK, status)
+		assert.NotEmpty(t, token.AccessToken)
+	})
+
+	t.Run("device codes are exchanged for one token", func(t *testing.T) {
+		deviceCode, _ := activate("depot")
+
+		status, token, _ := poll(deviceCode)
+		require.Equal(t, http.StatusOK, status)
+		assert.NotEmpty(t, token.AccessToken)
+
+		status, token, oauthErr := poll(deviceCode)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, oauthInvalidGrant, oauthErr.Error)
+		assert.Empty(t, token.AccessToken)
+	})
+}
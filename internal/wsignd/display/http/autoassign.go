@@ -0,0 +1,58 @@
+package http
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+)
+
+// AutoAssigner assigns content to displays by convention as they are
+// activated
+type AutoAssigner interface {
+	// AutoAssign assigns the display with id at location with properties
+	// the content its rules give, returning the assignment or nil when no
+	// rule applies
+	AutoAssign(ctx context.Context, id uuid.UUID, location v1alpha1.DisplayLocation, properties map[string]string) (*v1alpha1.ContentAssignment, error)
+}
+
+// SetAutoAssigner has displays assigned content by convention when they
+// are activated, approved or provisioned. Without one, displays show
+// nothing until an operator assigns them content. It must be called before
+// the handler serves requests.
+func (h *Handler) SetAutoAssigner(a AutoAssigner) {
+	h.autoAssigner = a
+}
+
+// autoAssign applies the auto-assignment rules to a display that has just
+// become active. Failures are logged rather than returned: the display is
+// activated either way and an operator can still assign it content.
+func (h *Handler) autoAssign(ctx context.Context, d *display.Display) {
+	if h.autoAssigner == nil || d.State != display.StateActive {
+		return
+	}
+	location := v1alpha1.DisplayLocation{
+		SiteID:   d.Location.SiteID,
+		Zone:     d.Location.Zone,
+		Position: d.Location.Position,
+	}
+	a, err := h.autoAssigner.AutoAssign(ctx, d.ID, location, d.Properties)
+	if err != nil {
+		h.logger.Warn("failed to auto-assign content",
+			"error", err,
+			"displayId", d.ID,
+			"displayName", d.Name,
+		)
+		return
+	}
+	if a != nil {
+		h.logger.Info("content auto-assigned",
+			"displayId", d.ID,
+			"displayName", d.Name,
+			"source", a.Source,
+			"assignmentId", a.ID,
+		)
+	}
+}
@@ -0,0 +1,386 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/outbox"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+const (
+	// DefaultDebugTimeout bounds how long a debug snapshot waits on its
+	// sections when no timeout is given
+	DefaultDebugTimeout = 5 * time.Second
+	// debugHistoryLimit is how many events and content transitions a
+	// snapshot includes
+	debugHistoryLimit = 20
+)
+
+// RecentEvents returns a display's recent lifecycle events, newest first.
+// display.EventLog satisfies it.
+type RecentEvents interface {
+	Recent(displayID uuid.UUID, limit int) []display.Event
+}
+
+// ContentTracer describes how the assignment deciding a display's content
+// was chosen. The assignment service satisfies it.
+type ContentTracer interface {
+	ExplainForDisplay(ctx context.Context, id uuid.UUID, location v1alpha1.DisplayLocation, properties map[string]string, at time.Time) ([]v1alpha1.ResolutionStep, error)
+}
+
+// DebugService assembles debug snapshots of displays from the handler's
+// services. The sections of a snapshot are read concurrently under one
+// timeout, and a section that fails or runs out of time is noted in the
+// snapshot rather than failing it.
+type DebugService struct {
+	h       *Handler
+	events  RecentEvents
+	tracer  ContentTracer
+	timeout time.Duration
+}
+
+// NewDebugService creates a DebugService reading from h, with events and
+// tracer filling in the lifecycle events and the resolution trace. Either
+// may be nil to leave its part out. A non-positive timeout uses
+// DefaultDebugTimeout.
+func NewDebugService(h *Handler, events RecentEvents, tracer ContentTracer, timeout time.Duration) *DebugService {
+	if timeout <= 0 {
+		timeout = DefaultDebugTimeout
+	}
+	return &DebugService{h: h, events: events, tracer: tracer, timeout: timeout}
+}
+
+// SetDebugService enables the debug snapshot route. Without a debug service
+// snapshots are refused. It must be called before the handler serves
+// requests.
+func (h *Handler) SetDebugService(d *DebugService) {
+	h.debug = d
+}
+
+// GetDisplayDebug returns a debug snapshot of a display for support. The
+// display may be given by ID or name.
+func (h *Handler) GetDisplayDebug(w http.ResponseWriter, r *http.Request) {
+	if h.debug == nil {
+		httpapi.Error(w, http.StatusNotImplemented, "debug snapshots are not enabled")
+		return
+	}
+
+	d, err := h.lookupDisplay(r, chi.URLParam(r, "id"))
+	if err != nil {
+		h.logRequestError(r, "failed to get display", err, "id", chi.URLParam(r, "id"))
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, h.debug.Snapshot(r.Context(), d))
+}
+
+// debugSection is one part of a snapshot
+type debugSection struct {
+	// read gathers the section and returns what to set on the snapshot
+	read func(ctx context.Context) func(*v1alpha1.DisplayDebugSnapshot)
+	// fail notes on the snapshot why the section is missing
+	fail func(s *v1alpha1.DisplayDebugSnapshot, note string)
+}
+
+// debugResult is a section that finished reading
+type debugResult struct {
+	index int
+	apply func(*v1alpha1.DisplayDebugSnapshot)
+}
+
+// Snapshot gathers everything known about d. It always returns a
+// snapshot; sections that could not be read carry an error note.
+func (s *DebugService) Snapshot(ctx context.Context, d *display.Display) *v1alpha1.DisplayDebugSnapshot {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	snapshot := &v1alpha1.DisplayDebugSnapshot{
+		TypeMeta: v1alpha1.TypeMeta{
+			Kind:       "DisplayDebugSnapshot",
+			APIVersion: "v1alpha1",
+		},
+		DisplayID:   d.ID,
+		GeneratedAt: time.Now().UTC(),
+		Display:     toAPIDisplay(d),
+	}
+
+	sections := s.sections(d)
+	// Sections still running when the time is up write to the buffer and
+	// exit rather than block
+	results := make(chan debugResult, len(sections))
+	for i, section := range sections {
+		go func(i int, section debugSection) {
+			defer func() {
+				if p := recover(); p != nil {
+					note := fmt.Sprintf("failed: %v", p)
+					results <- debugResult{i, func(snapshot *v1alpha1.DisplayDebugSnapshot) { section.fail(snapshot, note) }}
+				}
+			}()
+			results <- debugResult{i, section.read(ctx)}
+		}(i, section)
+	}
+
+	done := make([]bool, len(sections))
+	for range sections {
+		select {
+		case r := <-results:
+			r.apply(snapshot)
+			done[r.index] = true
+		case <-ctx.Done():
+			note := fmt.Sprintf("not read within %s", s.timeout)
+			for i, section := range sections {
+				if !done[i] {
+					section.fail(snapshot, note)
+				}
+			}
+			return snapshot
+		}
+	}
+	return snapshot
+}
+
+// sections lists the parts of d's snapshot
+func (s *DebugService) sections(d *display.Display) []debugSection {
+	return []debugSection{
+		{
+			read: s.readConnection(d),
+			fail: func(snapshot *v1alpha1.DisplayDebugSnapshot, note string) { snapshot.Connection.Error = note },
+		},
+		{
+			read: s.readEvents(d),
+			fail: func(snapshot *v1alpha1.DisplayDebugSnapshot, note string) { snapshot.Events.Error = note },
+		},
+		{
+			read: s.readContentHistory(d),
+			fail: func(snapshot *v1alpha1.DisplayDebugSnapshot, note string) { snapshot.ContentHistory.Error = note },
+		},
+		{
+			read: s.readContent(d),
+			fail: func(snapshot *v1alpha1.DisplayDebugSnapshot, note string) { snapshot.Content.Error = note },
+		},
+		{
+			read: s.readPendingMessages(d),
+			fail: func(snapshot *v1alpha1.DisplayDebugSnapshot, note string) { snapshot.PendingMessages.Error = note },
+		},
+		{
+			read: s.readSessions(d),
+			fail: func(snapshot *v1alpha1.DisplayDebugSnapshot, note string) { snapshot.Sessions.Error = note },
+		},
+		{
+			read: s.readRateLimits(d),
+			fail: func(snapshot *v1alpha1.DisplayDebugSnapshot, note string) { snapshot.RateLimits.Error = note },
+		},
+	}
+}
+
+// readConnection describes d's connections to this server
+func (s *DebugService) readConnection(d *display.Display) func(context.Context) func(*v1alpha1.DisplayDebugSnapshot) {
+	return func(ctx context.Context) func(*v1alpha1.DisplayDebugSnapshot) {
+		section := v1alpha1.DebugConnection{Items: s.h.hub.connectionInfo(d.ID)}
+		if len(section.Items) > 0 {
+			since := section.Items[0].ConnectedAt
+			section.Connected, section.Since = true, &since
+		}
+		return func(snapshot *v1alpha1.DisplayDebugSnapshot) { snapshot.Connection = section }
+	}
+}
+
+// readEvents lists d's recent lifecycle events
+func (s *DebugService) readEvents(d *display.Display) func(context.Context) func(*v1alpha1.DisplayDebugSnapshot) {
+	return func(ctx context.Context) func(*v1alpha1.DisplayDebugSnapshot) {
+		section := v1alpha1.DebugEvents{Items: []v1alpha1.DebugEvent{}}
+		if s.events == nil {
+			section.Error = "lifecycle events are not recorded"
+		} else {
+			section.Note = "events are kept in memory by each server since it started"
+			for _, e := range s.events.Recent(d.ID, debugHistoryLimit) {
+				section.Items = append(section.Items, v1alpha1.DebugEvent{
+					Type:      string(e.Type),
+					Timestamp: e.Timestamp,
+					Data:      e.Data,
+				})
+			}
+		}
+		return func(snapshot *v1alpha1.DisplayDebugSnapshot) { snapshot.Events = section }
+	}
+}
+
+// readContentHistory lists d's recent content transitions
+func (s *DebugService) readContentHistory(d *display.Display) func(context.Context) func(*v1alpha1.DisplayDebugSnapshot) {
+	return func(ctx context.Context) func(*v1alpha1.DisplayDebugSnapshot) {
+		section := v1alpha1.DebugContentHistory{Items: []v1alpha1.ContentHistoryEntry{}}
+		history, err := s.h.service.ContentHistory(ctx, d.ID, debugHistoryLimit)
+		if err != nil {
+			section.Error = err.Error()
+		}
+		for _, t := range history {
+			section.Items = append(section.Items, v1alpha1.ContentHistoryEntry{
+				Timestamp: t.Timestamp,
+				FromURL:   t.FromURL,
+				ToURL:     t.ToURL,
+				Trigger:   string(t.Trigger),
+			})
+		}
+		return func(snapshot *v1alpha1.DisplayDebugSnapshot) { snapshot.ContentHistory = section }
+	}
+}
+
+// readContent resolves d's content and traces how it was chosen. Content
+// served in place of the selected assignment, a maintenance notice or a
+// fallback source, ends the trace as overridden.
+func (s *DebugService) readContent(d *display.Display) func(context.Context) func(*v1alpha1.DisplayDebugSnapshot) {
+	return func(ctx context.Context) func(*v1alpha1.DisplayDebugSnapshot) {
+		section := v1alpha1.DebugContent{Trace: []v1alpha1.ResolutionStep{}}
+		set := func(snapshot *v1alpha1.DisplayDebugSnapshot) { snapshot.Content = section }
+		if s.h.content == nil {
+			section.Error = "content resolution is not enabled"
+			return set
+		}
+
+		location := toAPIDisplay(d).Spec.Location
+		a, err := s.h.content.ForDisplay(ctx, d.ID, location, d.Properties)
+		if err != nil {
+			section.Error = err.Error()
+			return set
+		}
+		section.Assignment = a
+		if s.tracer == nil {
+			return set
+		}
+
+		trace, err := s.tracer.ExplainForDisplay(ctx, d.ID, location, d.Properties, time.Time{})
+		if err != nil {
+			section.Error = fmt.Sprintf("tracing resolution: %v", err)
+			return set
+		}
+		section.Trace = append(section.Trace, trace...)
+		if a != nil && !servedAsSelected(trace, a) {
+			reason := "the assigned source is unhealthy, so a fallback is served"
+			if s.h.notices != nil && s.h.notices.Notice() != nil {
+				reason = "maintenance is in progress"
+			}
+			section.Trace = append(section.Trace, v1alpha1.ResolutionStep{
+				Assignment: a.Name,
+				ContentURL: a.ContentURL,
+				Outcome:    v1alpha1.ResolutionOverridden,
+				Reason:     reason,
+			})
+		}
+		return set
+	}
+}
+
+// servedAsSelected reports whether the assignment served is the one the
+// trace selected, unchanged
+func servedAsSelected(trace []v1alpha1.ResolutionStep, served *v1alpha1.ContentAssignment) bool {
+	for _, step := range trace {
+		if step.Outcome == v1alpha1.ResolutionSelected {
+			return step.Assignment == served.Name && step.ContentURL == served.ContentURL
+		}
+	}
+	return false
+}
+
+// readPendingMessages lists the messages queued for d
+func (s *DebugService) readPendingMessages(d *display.Display) func(context.Context) func(*v1alpha1.DisplayDebugSnapshot) {
+	return func(ctx context.Context) func(*v1alpha1.DisplayDebugSnapshot) {
+		section := v1alpha1.DebugPendingMessages{Items: []v1alpha1.PendingMessage{}}
+		if s.h.outbox == nil {
+			section.Error = "message outbox is not enabled"
+		} else if messages, err := s.h.outbox.List(ctx, outbox.Filter{DisplayID: d.ID, State: outbox.StatePending}); err != nil {
+			section.Error = err.Error()
+		} else {
+			for _, m := range messages {
+				section.Items = append(section.Items, toAPIPendingMessage(m))
+			}
+		}
+		return func(snapshot *v1alpha1.DisplayDebugSnapshot) { snapshot.PendingMessages = section }
+	}
+}
+
+// readSessions describes d's token sessions. Only their metadata is read;
+// the tokens themselves are never stored.
+func (s *DebugService) readSessions(d *display.Display) func(context.Context) func(*v1alpha1.DisplayDebugSnapshot) {
+	return func(ctx context.Context) func(*v1alpha1.DisplayDebugSnapshot) {
+		section := v1alpha1.DebugSessions{Items: []v1alpha1.DisplaySession{}}
+		if s.h.tokens == nil {
+			section.Error = "sessions are not available"
+		} else if sessions, err := s.h.tokens.ListSessions(ctx, d.ID); err != nil {
+			section.Error = err.Error()
+		} else {
+			connections := s.h.hub.sessionConnections(d.ID)
+			for _, session := range sessions {
+				section.Items = append(section.Items, v1alpha1.DisplaySession{
+					ID:              session.ID,
+					KeyID:           session.KeyID,
+					IssuedAt:        session.IssuedAt,
+					LastRefreshedAt: session.LastRefreshedAt,
+					ExpiresAt:       session.ExpiresAt,
+					Connections:     connections[session.ID],
+				})
+			}
+		}
+		return func(snapshot *v1alpha1.DisplayDebugSnapshot) { snapshot.Sessions = section }
+	}
+}
+
+// readRateLimits reports the buckets d's messages are counted against, and
+// the connection buckets of the addresses it is connected from, without
+// counting against them
+func (s *DebugService) readRateLimits(d *display.Display) func(context.Context) func(*v1alpha1.DisplayDebugSnapshot) {
+	return func(ctx context.Context) func(*v1alpha1.DisplayDebugSnapshot) {
+		section := v1alpha1.DebugRateLimits{Items: []v1alpha1.DebugRateLimit{}}
+		set := func(snapshot *v1alpha1.DisplayDebugSnapshot) { snapshot.RateLimits = section }
+		inspector, ok := s.h.hub.limiter.(ratelimit.Inspector)
+		if !ok {
+			section.Error = ratelimit.ErrPeekUnsupported.Error()
+			return set
+		}
+
+		keys := []ratelimit.LimitKey{
+			{Type: ratelimit.LimitTypeWSMessageIn, Key: d.ID.String()},
+			{Type: ratelimit.LimitTypeWSMessageOut, Key: d.ID.String()},
+		}
+		seen := make(map[string]bool)
+		for _, c := range s.h.hub.connectionInfo(d.ID) {
+			host, _, err := net.SplitHostPort(c.RemoteAddr)
+			if err != nil {
+				host = c.RemoteAddr
+			}
+			if host != "" && !seen[host] {
+				seen[host] = true
+				keys = append(keys, ratelimit.LimitKey{Type: ratelimit.LimitTypeWSConnection, Key: host})
+			}
+		}
+
+		for _, key := range keys {
+			status, err := inspector.Peek(ctx, key)
+			if err != nil {
+				section.Error = err.Error()
+				return set
+			}
+			section.Items = append(section.Items, v1alpha1.DebugRateLimit{
+				Type:              key.Type,
+				Key:               key.Key,
+				Rate:              status.Limit.Rate,
+				PeriodSeconds:     int(status.Limit.Period / time.Second),
+				Burst:             status.Limit.BurstSize,
+				Remaining:         status.Remaining,
+				RetryAfterSeconds: int(math.Ceil(status.RetryAfter.Seconds())),
+			})
+		}
+		return set
+	}
+}
@@ -0,0 +1,182 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
+	authmemory "github.com/wrale/wrale-signage/internal/wsignd/auth/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+// blockingResolver never resolves before its context ends
+type blockingResolver struct{}
+
+func (blockingResolver) ForDisplay(ctx context.Context, id uuid.UUID, location v1alpha1.DisplayLocation, properties map[string]string) (*v1alpha1.ContentAssignment, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// stubTracer returns a fixed resolution trace
+type stubTracer struct {
+	trace []v1alpha1.ResolutionStep
+}
+
+func (s stubTracer) ExplainForDisplay(ctx context.Context, id uuid.UUID, location v1alpha1.DisplayLocation, properties map[string]string, at time.Time) ([]v1alpha1.ResolutionStep, error) {
+	return s.trace, nil
+}
+
+func TestDisplayDebug(t *testing.T) {
+	ctx := context.Background()
+	displayID := uuid.New()
+	activeDisplay := &display.Display{
+		ID:         displayID,
+		Name:       "lobby-north",
+		Location:   display.Location{SiteID: "hq", Zone: "lobby", Position: "north"},
+		State:      display.StateActive,
+		Properties: map[string]string{"orientation": "portrait"},
+	}
+
+	material := make([]byte, 32)
+	_, err := rand.Read(material)
+	require.NoError(t, err)
+	secretKey := base64.StdEncoding.EncodeToString(material)
+	key, err := auth.ParseKey("test", string(auth.AlgorithmHS256), secretKey)
+	require.NoError(t, err)
+	keys, err := auth.NewKeyRing(key)
+	require.NoError(t, err)
+	tokens := auth.NewService(keys, authmemory.NewRepository(), time.Hour)
+	rawToken, _, err := tokens.IssueDisplayToken(ctx, displayID)
+	require.NoError(t, err)
+
+	events := display.NewEventLog(0, nil)
+	require.NoError(t, events.Publish(ctx, display.Event{
+		Type:      display.EventActivated,
+		DisplayID: displayID,
+		Timestamp: time.Now(),
+		Data:      map[string]string{"state": "ACTIVE"},
+	}))
+
+	// setup serves a handler whose content history fails, with the content
+	// resolver and debug service given
+	setup := func(t *testing.T, resolver ContentResolver, tracer ContentTracer, timeout time.Duration) (*Handler, *httptest.Server) {
+		mockSvc := &mockService{}
+		mockSvc.On("Get", mock.Anything, displayID).Return(activeDisplay, nil)
+		mockSvc.On("GetByName", mock.Anything, "lobby-north").Return(activeDisplay, nil)
+		mockSvc.On("UpdateLastSeen", mock.Anything, displayID).Return(nil)
+		mockSvc.On("ContentHistory", mock.Anything, displayID, debugHistoryLimit).Return(nil, errors.New("history store unavailable"))
+
+		handler := NewHandler(mockSvc, nil, tokens, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		handler.SetContentResolver(resolver)
+		handler.SetDebugService(NewDebugService(handler, events, tracer, timeout))
+		server := httptest.NewServer(NewRouter(handler, ratelimit.NewMemoryService(nil), nil))
+		t.Cleanup(server.Close)
+		return handler, server
+	}
+	snapshot := func(t *testing.T, server *httptest.Server) (string, v1alpha1.DisplayDebugSnapshot) {
+		resp, err := http.Get(server.URL + "/api/v1alpha1/displays/lobby-north/debug")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		var s v1alpha1.DisplayDebugSnapshot
+		require.NoError(t, json.Unmarshal(body, &s))
+		return string(body), s
+	}
+
+	t.Run("failing sections are noted and the rest are filled in", func(t *testing.T) {
+		handler, server := setup(t, blockingResolver{}, nil, 200*time.Millisecond)
+
+		wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1alpha1/displays/ws?id=" + displayID.String()
+		ws, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Authorization": {"Bearer " + rawToken}})
+		require.NoError(t, err)
+		defer ws.Close()
+		require.Eventually(t, func() bool { return handler.hub.connected(displayID) }, time.Second, 10*time.Millisecond)
+
+		start := time.Now()
+		body, s := snapshot(t, server)
+		assert.Less(t, time.Since(start), 2*time.Second, "a stuck section does not hold up the snapshot")
+
+		assert.Equal(t, "DisplayDebugSnapshot", s.Kind)
+		assert.Equal(t, displayID, s.DisplayID)
+		require.NotNil(t, s.Display)
+		assert.Equal(t, "portrait", s.Display.Spec.Properties["orientation"])
+
+		assert.Equal(t, "not read within 200ms", s.Content.Error)
+		assert.Contains(t, s.ContentHistory.Error, "history store unavailable")
+		assert.Equal(t, "message outbox is not enabled", s.PendingMessages.Error)
+
+		assert.True(t, s.Connection.Connected)
+		assert.NotNil(t, s.Connection.Since)
+		require.Len(t, s.Connection.Items, 1)
+		assert.Empty(t, s.Connection.Error)
+		require.Len(t, s.Events.Items, 1)
+		assert.Equal(t, string(display.EventActivated), s.Events.Items[0].Type)
+		require.Len(t, s.Sessions.Items, 1)
+		assert.Equal(t, 1, s.Sessions.Items[0].Connections)
+		assert.Empty(t, s.RateLimits.Error)
+		var limitTypes []string
+		for _, l := range s.RateLimits.Items {
+			limitTypes = append(limitTypes, l.Type)
+		}
+		assert.Equal(t, []string{ratelimit.LimitTypeWSMessageIn, ratelimit.LimitTypeWSMessageOut, ratelimit.LimitTypeWSConnection}, limitTypes)
+
+		// Neither the display's token nor the key that signed it appear
+		// anywhere, whole or in part
+		assert.NotContains(t, body, rawToken)
+		for _, part := range strings.Split(rawToken, ".") {
+			assert.NotContains(t, body, part)
+		}
+		assert.NotContains(t, body, secretKey)
+		assert.NotContains(t, body, base64.RawURLEncoding.EncodeToString(material))
+	})
+
+	t.Run("content served in place of the selected assignment ends the trace", func(t *testing.T) {
+		resolver := &stubResolver{assignment: &v1alpha1.ContentAssignment{
+			ObjectMeta: v1alpha1.ObjectMeta{Name: "lobby-welcome"},
+			ContentURL: "https://example.com/fallback",
+		}}
+		tracer := stubTracer{trace: []v1alpha1.ResolutionStep{
+			{Assignment: "site-wide", ContentURL: "https://example.com/site", Outcome: v1alpha1.ResolutionOutranked, Reason: "a more specific assignment selects the display"},
+			{Assignment: "lobby-welcome", ContentURL: "https://example.com/welcome", Outcome: v1alpha1.ResolutionSelected},
+		}}
+		_, server := setup(t, resolver, tracer, 0)
+
+		_, s := snapshot(t, server)
+		assert.Empty(t, s.Content.Error)
+		require.NotNil(t, s.Content.Assignment)
+		assert.Equal(t, "https://example.com/fallback", s.Content.Assignment.ContentURL)
+		require.Len(t, s.Content.Trace, 3)
+		assert.Equal(t, v1alpha1.ResolutionOverridden, s.Content.Trace[2].Outcome)
+		assert.Equal(t, "the assigned source is unhealthy, so a fallback is served", s.Content.Trace[2].Reason)
+		assert.Empty(t, s.Connection.Items)
+		assert.False(t, s.Connection.Connected)
+	})
+
+	t.Run("snapshots must be enabled", func(t *testing.T) {
+		handler := NewHandler(&mockService{}, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		rec := httptest.NewRecorder()
+		NewRouter(handler, ratelimit.NewMemoryService(nil), nil).ServeHTTP(rec,
+			httptest.NewRequest(http.MethodGet, "/api/v1alpha1/displays/lobby-north/debug", nil))
+		assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	})
+}
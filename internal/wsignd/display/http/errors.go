@@ -0,0 +1,64 @@
+package http
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/activation"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+)
+
+// OAuth error codes used by the device activation endpoints
+const (
+	oauthAccessDenied         = "access_denied"
+	oauthAuthorizationPending = "authorization_pending"
+	oauthExpiredToken         = "expired_token"
+	oauthInvalidGrant         = "invalid_grant"
+	oauthInvalidRequest       = "invalid_request"
+	oauthServerError          = "server_error"
+)
+
+// errorStatus maps a domain error to an HTTP status. Activation code errors
+// are display specific; everything else is mapped as in every other API.
+func errorStatus(err error, defaultStatus int) int {
+	switch {
+	case errors.Is(err, activation.ErrCodeNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, activation.ErrCodeExpired):
+		return http.StatusGone
+	case errors.Is(err, activation.ErrAlreadyActivated):
+		return http.StatusConflict
+	case errors.Is(err, activation.ErrSiteNotAllowed):
+		return http.StatusForbidden
+	}
+	return httpapi.ErrorStatus(err, defaultStatus)
+}
+
+// writeError writes err as an API error body with the status errorStatus
+// maps it to
+func writeError(w http.ResponseWriter, err error, defaultStatus int) {
+	httpapi.WriteErrorStatus(w, err, errorStatus(err, defaultStatus))
+}
+
+// logRequestError logs a failed request. Failures the caller caused, such
+// as asking for a display that does not exist, are logged at info level;
+// only errors that would be reported as 5xx are logged as errors.
+func (h *Handler) logRequestError(r *http.Request, msg string, err error, args ...interface{}) {
+	level := slog.LevelError
+	if errorStatus(err, http.StatusInternalServerError) < http.StatusInternalServerError {
+		level = slog.LevelInfo
+	}
+	h.logger.Log(r.Context(), level, msg, append([]interface{}{"error", err}, args...)...)
+}
+
+// writeOAuthError writes an RFC 8628 style error body, listing the
+// invalid fields of an invalid request
+func writeOAuthError(w http.ResponseWriter, status int, code, description string, fields ...v1alpha1.FieldError) {
+	httpapi.WriteJSON(w, status, v1alpha1.OAuthError{
+		Error:            code,
+		ErrorDescription: description,
+		Errors:           fields,
+	})
+}
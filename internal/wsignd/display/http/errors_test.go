@@ -0,0 +1,61 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/activation"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// TestErrorStatusThroughWraps checks that every display and activation
+// error keeps its status and code however many times it is wrapped on its
+// way to the handler
+func TestErrorStatusThroughWraps(t *testing.T) {
+	tests := []struct {
+		err    error
+		code   string
+		status int
+	}{
+		{display.ErrNotFound{ID: "lobby"}, "NOT_FOUND", http.StatusNotFound},
+		{display.ErrVersionMismatch{ID: "lobby"}, "VERSION_MISMATCH", http.StatusConflict},
+		{display.ErrLimitExceeded{Scope: `site "hq"`, Limit: 2}, "LIMIT_EXCEEDED", http.StatusConflict},
+		{display.ErrNameTaken{Name: "lobby", Existing: &display.Display{Name: "Lobby"}}, "DISPLAY_EXISTS", http.StatusConflict},
+		{display.ErrInvalidName{Name: "-", Reason: "name must begin and end with a letter or digit"}, "INVALID_NAME", http.StatusBadRequest},
+		{display.ErrInvalidLocation{Reason: "site is required"}, "INVALID_LOCATION", http.StatusBadRequest},
+		{display.ErrInvalidState{Current: display.StateDisabled, Target: display.StateActive}, "INVALID_STATE", http.StatusBadRequest},
+		{activation.ErrCodeNotFound, "CODE_NOT_FOUND", http.StatusNotFound},
+		{activation.ErrCodeExpired, "CODE_EXPIRED", http.StatusGone},
+		{activation.ErrAlreadyActivated, "CODE_USED", http.StatusConflict},
+		{activation.ErrSiteNotAllowed, "ACCESS_DENIED", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		once := werrors.NewError(tt.code, tt.err.Error(), "DisplayService.Op", tt.err)
+		wraps := map[string]error{
+			"once":    once,
+			"twice":   werrors.NewError(tt.code, tt.err.Error(), "DisplayHandler.Op", once),
+			"wrapped": fmt.Errorf("handling request: %w", werrors.NewError(tt.code, tt.err.Error(), "DisplayHandler.Op", once)),
+		}
+		for name, err := range wraps {
+			t.Run(tt.code+"/"+name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				writeError(w, err, http.StatusInternalServerError)
+				require.Equal(t, tt.status, w.Code)
+
+				var body v1alpha1.Error
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+				assert.Equal(t, tt.code, body.Code)
+				assert.Equal(t, tt.err.Error(), body.Message)
+			})
+		}
+	}
+}
@@ -4,29 +4,59 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
 	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/activation"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/outbox"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+	"github.com/wrale/wrale-signage/internal/wsignd/timezone"
 )
 
 // Handler implements HTTP handlers for display management
 type Handler struct {
-	service display.Service
-	logger  *slog.Logger
-	hub     *Hub
+	service    display.Service
+	activation activation.Service
+	tokens     auth.Service
+	logger     *slog.Logger
+	hub        *Hub
+	outbox     outbox.Service
+	content    ContentResolver
+	notices    MaintenanceNotices
+	polling    *display.Polling
+	timezones  display.Timezones
+
+	autoAssigner AutoAssigner
+	enricher     *display.Enricher
+	debug        *DebugService
+}
+
+// NewHandler creates a new display HTTP handler using the default hub limits
+func NewHandler(service display.Service, activation activation.Service, tokens auth.Service, logger *slog.Logger) *Handler {
+	return NewHandlerWithHubConfig(service, activation, tokens, logger, DefaultHubConfig())
 }
 
-// NewHandler creates a new display HTTP handler
-func NewHandler(service display.Service, logger *slog.Logger) *Handler {
+// NewHandlerWithHubConfig creates a new display HTTP handler whose
+// WebSocket hub uses the given queue limits
+func NewHandlerWithHubConfig(service display.Service, activation activation.Service, tokens auth.Service, logger *slog.Logger, hubCfg HubConfig) *Handler {
 	h := &Handler{
-		service: service,
-		logger:  logger,
+		service:    service,
+		activation: activation,
+		tokens:     tokens,
+		logger:     logger,
 	}
-	h.hub = newHub(logger)
+	// The default policy is always valid
+	h.polling, _ = display.NewPolling(display.DefaultPollingPolicy(), display.Liveness{})
+	h.hub = newHub(hubCfg, logger)
 	go h.hub.run(context.Background()) // TODO: manage lifecycle with context
 	return h
 }
@@ -35,7 +65,7 @@ func NewHandler(service display.Service, logger *slog.Logger) *Handler {
 func (h *Handler) RegisterDisplay(w http.ResponseWriter, r *http.Request) {
 	var req v1alpha1.DisplayRegistrationRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+		httpapi.Error(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
@@ -47,41 +77,16 @@ func (h *Handler) RegisterDisplay(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Register display through service
-	d, err := h.service.Register(r.Context(), req.Name, location)
+	d, err := h.service.Register(r.Context(), req.Name, location, req.Properties)
 	if err != nil {
-		h.logger.Error("failed to register display",
-			"error", err,
-			"name", req.Name,
-		)
-		http.Error(w, "registration failed", http.StatusInternalServerError)
+		h.logRequestError(r, "failed to register display", err, "name", req.Name)
+		writeError(w, err, http.StatusInternalServerError)
 		return
 	}
 
 	// Convert domain type to API response
 	resp := &v1alpha1.DisplayRegistrationResponse{
-		Display: &v1alpha1.Display{
-			TypeMeta: v1alpha1.TypeMeta{
-				Kind:       "Display",
-				APIVersion: "v1alpha1",
-			},
-			ObjectMeta: v1alpha1.ObjectMeta{
-				ID:   d.ID,
-				Name: d.Name,
-			},
-			Spec: v1alpha1.DisplaySpec{
-				Location: v1alpha1.DisplayLocation{
-					SiteID:   d.Location.SiteID,
-					Zone:     d.Location.Zone,
-					Position: d.Location.Position,
-				},
-				Properties: d.Properties,
-			},
-			Status: v1alpha1.DisplayStatus{
-				State:    v1alpha1.DisplayState(d.State),
-				LastSeen: d.LastSeen,
-				Version:  d.Version,
-			},
-		},
+		Display: toAPIDisplay(d),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -89,71 +94,178 @@ func (h *Handler) RegisterDisplay(w http.ResponseWriter, r *http.Request) {
 		h.logger.Error("failed to encode response",
 			"error", err,
 		)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+		httpapi.Error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
 }
 
-// GetDisplay handles requests to get display status
-func (h *Handler) GetDisplay(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := uuid.Parse(idStr)
+// ListDisplays handles display listing, oldest first. The siteId, zone and
+// position query parameters narrow the list, as do repeated
+// matchProperty=key=value parameters. neverSeen=true keeps only displays
+// that have never contacted the server and neverSeen=false only those that
+// have. tz gives timestamps in a time zone, as for GetDisplay.
+// include=runtime adds whether each display is connected, its queued
+// messages and its last content, read in bulk for the whole list.
+func (h *Handler) ListDisplays(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	properties, err := v1alpha1.ParseMatchProperties(query[v1alpha1.MatchPropertyParam])
+	if err != nil {
+		httpapi.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter := display.DisplayFilter{
+		SiteID:     query.Get("siteId"),
+		Zone:       query.Get("zone"),
+		Properties: properties,
+	}
+	if v := query.Get("neverSeen"); v != "" {
+		neverSeen, err := strconv.ParseBool(v)
+		if err != nil {
+			httpapi.Error(w, http.StatusBadRequest, "neverSeen must be true or false")
+			return
+		}
+		filter.NeverSeen, filter.Seen = neverSeen, !neverSeen
+	}
+	runtime, err := includesRuntime(r)
 	if err != nil {
-		http.Error(w, "invalid display ID", http.StatusBadRequest)
+		httpapi.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if runtime && h.enricher == nil {
+		httpapi.Error(w, http.StatusNotImplemented, "runtime state is not available")
+		return
+	}
+	tz, err := timezone.FromRequest(r)
+	if err != nil {
+		httpapi.WriteError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	d, err := h.service.Get(r.Context(), id)
+	displays, err := h.service.List(r.Context(), filter)
 	if err != nil {
-		h.logger.Error("failed to get display",
+		h.logger.Error("failed to list displays",
 			"error", err,
-			"id", id,
 		)
-		http.Error(w, "display not found", http.StatusNotFound)
+		writeError(w, err, http.StatusInternalServerError)
 		return
 	}
 
-	// Convert to API type
-	resp := &v1alpha1.Display{
-		TypeMeta: v1alpha1.TypeMeta{
-			Kind:       "Display",
-			APIVersion: "v1alpha1",
-		},
-		ObjectMeta: v1alpha1.ObjectMeta{
-			ID:   d.ID,
-			Name: d.Name,
-		},
-		Spec: v1alpha1.DisplaySpec{
-			Location: v1alpha1.DisplayLocation{
-				SiteID:   d.Location.SiteID,
-				Zone:     d.Location.Zone,
-				Position: d.Location.Position,
-			},
-			Properties: d.Properties,
-		},
-		Status: v1alpha1.DisplayStatus{
-			State:    v1alpha1.DisplayState(d.State),
-			LastSeen: d.LastSeen,
-			Version:  d.Version,
-		},
+	position := query.Get("position")
+	listed := make([]*display.Display, 0, len(displays))
+	resp := []*v1alpha1.Display{}
+	for _, d := range displays {
+		if position != "" && d.Location.Position != position {
+			continue
+		}
+		listed = append(listed, d)
+		resp = append(resp, toAPIDisplay(d))
+	}
+	if runtime {
+		if err := h.withRuntime(r.Context(), resp, listed); err != nil {
+			h.logRequestError(r, "failed to read display runtime state", err)
+			writeError(w, err, http.StatusInternalServerError)
+			return
+		}
+	}
+	for i, d := range listed {
+		h.localize(resp[i], d, tz)
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, resp)
+}
+
+// GetDisplay handles requests to get display status. The display may be
+// given by ID or name. tz=<IANA name> gives the display's timestamps in
+// that zone and tz=local in the display's own, with the zone used noted in
+// the status.
+func (h *Handler) GetDisplay(w http.ResponseWriter, r *http.Request) {
+	ref := chi.URLParam(r, "id")
+	tz, err := timezone.FromRequest(r)
+	if err != nil {
+		httpapi.WriteError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	d, err := h.lookupDisplay(r, ref)
+	if err != nil {
+		h.logRequestError(r, "failed to get display", err, "id", ref)
+		writeError(w, err, http.StatusInternalServerError)
+		return
 	}
 
+	// Convert to API type
+	resp := h.localize(toAPIDisplay(d), d, tz)
+
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		h.logger.Error("failed to encode response",
 			"error", err,
 		)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+		httpapi.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+}
+
+// PatchDisplay applies a partial update to a display. The display may be
+// given by ID or name. Conflicting concurrent updates are reported with 409
+// when the request names an expected version. A rename to a name already in
+// use is reported with 409 carrying the display that holds it.
+func (h *Handler) PatchDisplay(w http.ResponseWriter, r *http.Request) {
+	var req v1alpha1.DisplayPatchRequest
+	if err := httpapi.DecodeJSON(r, &req); err != nil {
+		httpapi.WriteError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	d, err := h.lookupDisplay(r, chi.URLParam(r, "id"))
+	if err != nil {
+		h.logRequestError(r, "failed to get display", err, "id", chi.URLParam(r, "id"))
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	patch := display.Patch{
+		Name:             req.Name,
+		SetProperties:    req.AddProperties,
+		RemoveProperties: req.RemoveProperties,
+	}
+	if req.Location != nil {
+		patch.Location = &display.Location{
+			SiteID:   req.Location.SiteID,
+			Zone:     req.Location.Zone,
+			Position: req.Location.Position,
+		}
+	}
+
+	updated, err := h.service.Patch(r.Context(), d.ID, patch, req.Version)
+	var taken display.ErrNameTaken
+	if errors.As(err, &taken) {
+		httpapi.WriteJSON(w, http.StatusConflict, v1alpha1.Error{
+			Code:    "DISPLAY_EXISTS",
+			Message: taken.Error(),
+			Details: toAPIDisplay(taken.Existing),
+		})
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to patch display",
+			"error", err,
+			"id", d.ID,
+		)
+		writeError(w, err, http.StatusInternalServerError)
 		return
 	}
+
+	httpapi.WriteJSON(w, http.StatusOK, toAPIDisplay(updated))
 }
 
-// ActivateDisplay handles display activation requests
+// ActivateDisplay handles display activation requests. Displays that
+// become active are assigned content by the auto-assignment rules.
 func (h *Handler) ActivateDisplay(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		http.Error(w, "invalid display ID", http.StatusBadRequest)
+		httpapi.Error(w, http.StatusBadRequest, "invalid display ID")
 		return
 	}
 
@@ -162,19 +274,28 @@ func (h *Handler) ActivateDisplay(w http.ResponseWriter, r *http.Request) {
 			"error", err,
 			"id", id,
 		)
-		http.Error(w, "activation failed", http.StatusInternalServerError)
+		httpapi.Error(w, http.StatusInternalServerError, "activation failed")
 		return
 	}
 
+	if h.autoAssigner != nil {
+		if d, err := h.service.Get(r.Context(), id); err != nil {
+			h.logRequestError(r, "failed to get activated display", err, "id", id)
+		} else {
+			h.autoAssign(r.Context(), d)
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
 
-// UpdateLastSeen updates the display's last seen timestamp
+// UpdateLastSeen updates the display's last seen timestamp and returns the
+// polling hints for the display's site
 func (h *Handler) UpdateLastSeen(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		http.Error(w, "invalid display ID", http.StatusBadRequest)
+		httpapi.Error(w, http.StatusBadRequest, "invalid display ID")
 		return
 	}
 
@@ -183,9 +304,130 @@ func (h *Handler) UpdateLastSeen(w http.ResponseWriter, r *http.Request) {
 			"error", err,
 			"id", id,
 		)
-		http.Error(w, "update failed", http.StatusInternalServerError)
+		httpapi.Error(w, http.StatusInternalServerError, "update failed")
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	d, err := h.service.Get(r.Context(), id)
+	if err != nil {
+		h.logRequestError(r, "failed to get display", err, "id", id)
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	httpapi.WriteJSON(w, http.StatusOK, &v1alpha1.HeartbeatResponse{
+		PollingHints: *h.pollingHints(d.Location.SiteID),
+	})
+}
+
+// DisconnectDisplay closes a display's control connection so that it
+// reconnects cleanly. The display may be given by ID or name. The close code
+// and reason can be set with the code and reason query parameters, and
+// reload=true sends a reload message before the connection is closed.
+func (h *Handler) DisconnectDisplay(w http.ResponseWriter, r *http.Request) {
+	d, err := h.lookupDisplay(r, chi.URLParam(r, "id"))
+	if err != nil {
+		h.logger.Error("failed to get display",
+			"error", err,
+			"id", chi.URLParam(r, "id"),
+		)
+		httpapi.Error(w, http.StatusNotFound, "display not found")
+		return
+	}
+
+	query := r.URL.Query()
+	code := websocket.CloseServiceRestart
+	if v := query.Get("code"); v != "" {
+		code, err = strconv.Atoi(v)
+		if err != nil || !validCloseCode(code) {
+			httpapi.Error(w, http.StatusBadRequest, "invalid close code")
+			return
+		}
+	}
+	reason := query.Get("reason")
+	if reason == "" {
+		reason = "disconnected by administrator"
+	}
+	// Close frame payloads are limited to 125 bytes including the code
+	if len(reason) > 123 {
+		httpapi.Error(w, http.StatusBadRequest, "close reason too long")
+		return
+	}
+
+	if reload, _ := strconv.ParseBool(query.Get("reload")); reload {
+		msg := &v1alpha1.ControlMessage{
+			TypeMeta:  v1alpha1.TypeMeta{Kind: "ControlMessage", APIVersion: "v1alpha1"},
+			Type:      v1alpha1.ControlMessageReload,
+			Timestamp: time.Now(),
+		}
+		if err := h.SendControlMessage(d.ID, msg); err != nil {
+			if errors.Is(err, errNotConnected) {
+				httpapi.Error(w, http.StatusNotFound, "display not connected")
+				return
+			}
+			h.logger.Warn("failed to send reload before disconnect",
+				"error", err,
+				"id", d.ID,
+			)
+		}
+	}
+
+	if err := h.hub.DisconnectDisplay(d.ID, code, reason); err != nil {
+		if errors.Is(err, errNotConnected) {
+			httpapi.Error(w, http.StatusNotFound, "display not connected")
+			return
+		}
+		h.logger.Error("failed to disconnect display",
+			"error", err,
+			"id", d.ID,
+		)
+		httpapi.Error(w, http.StatusInternalServerError, "disconnect failed")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// GetConnections reports the send queue state of a display's open control
+// connections. A display that is not connected has an empty list.
+func (h *Handler) GetConnections(w http.ResponseWriter, r *http.Request) {
+	d, err := h.lookupDisplay(r, chi.URLParam(r, "id"))
+	if err != nil {
+		h.logger.Error("failed to get display",
+			"error", err,
+			"id", chi.URLParam(r, "id"),
+		)
+		httpapi.Error(w, http.StatusNotFound, "display not found")
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, &v1alpha1.DisplayConnections{
+		TypeMeta: v1alpha1.TypeMeta{
+			Kind:       "DisplayConnections",
+			APIVersion: "v1alpha1",
+		},
+		DisplayID: d.ID,
+		Items:     h.hub.connectionInfo(d.ID),
+	})
+}
+
+// lookupDisplay resolves a display from either its ID or its name
+func (h *Handler) lookupDisplay(r *http.Request, ref string) (*display.Display, error) {
+	if id, err := uuid.Parse(ref); err == nil {
+		return h.service.Get(r.Context(), id)
+	}
+	return h.service.GetByName(r.Context(), ref)
+}
+
+// validCloseCode reports whether code may be sent in a close frame
+func validCloseCode(code int) bool {
+	switch code {
+	case websocket.CloseNormalClosure,
+		websocket.CloseGoingAway,
+		websocket.ClosePolicyViolation,
+		websocket.CloseServiceRestart,
+		websocket.CloseTryAgainLater:
+		return true
+	}
+	// Registered (3000-3999) and private (4000-4999) application codes
+	return code >= 3000 && code <= 4999
 }
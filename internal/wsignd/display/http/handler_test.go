@@ -4,27 +4,35 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"github.com/wrale/wrale-signage/api/types/v1alpha1"
 	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
 )
 
 type mockService struct {
 	mock.Mock
 }
 
-func (m *mockService) Register(ctx context.Context, name string, location display.Location) (*display.Display, error) {
-	args := m.Called(ctx, name, location)
+func (m *mockService) Register(ctx context.Context, name string, location display.Location, properties map[string]string) (*display.Display, error) {
+	args := m.Called(ctx, name, location, properties)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -39,6 +47,14 @@ func (m *mockService) Get(ctx context.Context, id uuid.UUID) (*display.Display,
 	return args.Get(0).(*display.Display), args.Error(1)
 }
 
+func (m *mockService) GetByName(ctx context.Context, name string) (*display.Display, error) {
+	args := m.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*display.Display), args.Error(1)
+}
+
 func (m *mockService) List(ctx context.Context, filter display.DisplayFilter) ([]*display.Display, error) {
 	args := m.Called(ctx, filter)
 	return args.Get(0).([]*display.Display), args.Error(1)
@@ -54,6 +70,11 @@ func (m *mockService) Activate(ctx context.Context, id uuid.UUID) error {
 	return args.Error(0)
 }
 
+func (m *mockService) Approve(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
 func (m *mockService) Disable(ctx context.Context, id uuid.UUID) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
@@ -64,11 +85,71 @@ func (m *mockService) UpdateLastSeen(ctx context.Context, id uuid.UUID) error {
 	return args.Error(0)
 }
 
+func (m *mockService) ReapOffline(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *mockService) CountByState(ctx context.Context, filter display.DisplayFilter) ([]display.StateCount, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]display.StateCount), args.Error(1)
+}
+
 func (m *mockService) SetProperty(ctx context.Context, id uuid.UUID, key, value string) error {
 	args := m.Called(ctx, id, key, value)
 	return args.Error(0)
 }
 
+func (m *mockService) Patch(ctx context.Context, id uuid.UUID, patch display.Patch, expectedVersion int) (*display.Display, error) {
+	args := m.Called(ctx, id, patch, expectedVersion)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*display.Display), args.Error(1)
+}
+
+func (m *mockService) RecordContentChange(ctx context.Context, id uuid.UUID, url string, trigger display.ContentTrigger) error {
+	args := m.Called(ctx, id, url, trigger)
+	return args.Error(0)
+}
+
+func (m *mockService) ContentHistory(ctx context.Context, id uuid.UUID, limit int) ([]*display.ContentTransition, error) {
+	args := m.Called(ctx, id, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*display.ContentTransition), args.Error(1)
+}
+
+func (m *mockService) SnapshotStates(ctx context.Context, retention time.Duration) error {
+	args := m.Called(ctx, retention)
+	return args.Error(0)
+}
+
+func (m *mockService) StateHistory(ctx context.Context, siteID string, since time.Time) ([]*display.StateSnapshot, error) {
+	args := m.Called(ctx, siteID, since)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*display.StateSnapshot), args.Error(1)
+}
+
+func (m *mockService) Delete(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockService) PruneOffline(ctx context.Context, filter display.PruneFilter, dryRun bool) (*display.PruneResult, error) {
+	args := m.Called(ctx, filter, dryRun)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*display.PruneResult), args.Error(1)
+}
+
 // Helper function to mock chi routing context
 func mockChiContext(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -81,7 +162,7 @@ func mockChiContext(next http.Handler) http.Handler {
 func TestRegisterDisplay(t *testing.T) {
 	mockSvc := &mockService{}
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-	handler := NewHandler(mockSvc, logger)
+	handler := NewHandler(mockSvc, nil, nil, logger)
 
 	tests := []struct {
 		name       string
@@ -109,6 +190,7 @@ func TestRegisterDisplay(t *testing.T) {
 						Zone:     "lobby",
 						Position: "main",
 					},
+					mock.Anything,
 				).Return(&display.Display{
 					ID:   uuid.New(),
 					Name: "test-display",
@@ -135,9 +217,42 @@ func TestRegisterDisplay(t *testing.T) {
 					mock.Anything,
 					"",
 					mock.Anything,
+					mock.Anything,
 				).Return(nil, display.ErrInvalidName{Name: "", Reason: "name cannot be empty"})
 			},
-			wantStatus: http.StatusInternalServerError,
+			wantStatus: http.StatusBadRequest,
+			wantErr:    true,
+		},
+		{
+			name: "site at capacity",
+			input: v1alpha1.DisplayRegistrationRequest{
+				Name: "lobby-3",
+			},
+			mockSetup: func() {
+				mockSvc.On("Register",
+					mock.Anything,
+					"lobby-3",
+					mock.Anything,
+					mock.Anything,
+				).Return(nil, werrors.NewError("LIMIT_EXCEEDED", "site \"hq\" already has its maximum of 2 displays", "DisplayService.Register", display.ErrLimitExceeded{Scope: `site "hq"`, Limit: 2}))
+			},
+			wantStatus: http.StatusConflict,
+			wantErr:    true,
+		},
+		{
+			name: "name taken",
+			input: v1alpha1.DisplayRegistrationRequest{
+				Name: "lobby-1",
+			},
+			mockSetup: func() {
+				mockSvc.On("Register",
+					mock.Anything,
+					"lobby-1",
+					mock.Anything,
+					mock.Anything,
+				).Return(nil, werrors.NewError("DISPLAY_EXISTS", "Display already exists with name: lobby-1", "DisplayService.Register", werrors.ErrConflict))
+			},
+			wantStatus: http.StatusConflict,
 			wantErr:    true,
 		},
 	}
@@ -175,8 +290,9 @@ func TestRegisterDisplay(t *testing.T) {
 
 func TestGetDisplay(t *testing.T) {
 	mockSvc := &mockService{}
-	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-	handler := NewHandler(mockSvc, logger)
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	handler := NewHandler(mockSvc, nil, nil, logger)
 
 	displayID := uuid.New()
 	existingDisplay := &display.Display{
@@ -187,9 +303,16 @@ func TestGetDisplay(t *testing.T) {
 			Zone:     "lobby",
 			Position: "main",
 		},
-		State:    display.StateActive,
-		LastSeen: time.Now(),
-		Version:  1,
+		State:     display.StateActive,
+		LastSeen:  time.Now(),
+		Version:   1,
+		CreatedAt: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2024, 3, 2, 9, 0, 0, 0, time.UTC),
+	}
+
+	// notFound wraps a repository miss the way the display service does
+	notFound := func(ref string) error {
+		return werrors.NewError("NOT_FOUND", "Display not found: "+ref, "DisplayService.Get", display.ErrNotFound{ID: ref})
 	}
 
 	tests := []struct {
@@ -197,6 +320,8 @@ func TestGetDisplay(t *testing.T) {
 		displayID  string
 		mockSetup  func()
 		wantStatus int
+		wantCode   string
+		wantLevel  string
 	}{
 		{
 			name:      "successful get",
@@ -207,27 +332,51 @@ func TestGetDisplay(t *testing.T) {
 			wantStatus: http.StatusOK,
 		},
 		{
-			name:      "invalid uuid",
-			displayID: "not-a-uuid",
+			name:      "get by name",
+			displayID: "test-display",
 			mockSetup: func() {
-				// No mock setup needed - should fail before service call
+				mockSvc.On("GetByName", mock.Anything, "test-display").Return(existingDisplay, nil)
 			},
-			wantStatus: http.StatusBadRequest,
+			wantStatus: http.StatusOK,
 		},
 		{
-			name:      "display not found",
+			name:      "unknown uuid",
 			displayID: uuid.New().String(),
 			mockSetup: func() {
-				mockSvc.On("Get", mock.Anything, mock.Anything).Return(nil, display.ErrNotFound{ID: "unknown"})
+				mockSvc.On("Get", mock.Anything, mock.Anything).Return(nil, notFound("unknown"))
 			},
 			wantStatus: http.StatusNotFound,
+			wantCode:   "NOT_FOUND",
+			wantLevel:  "level=INFO",
+		},
+		{
+			name:      "unknown name",
+			displayID: "lobby-typo",
+			mockSetup: func() {
+				mockSvc.On("GetByName", mock.Anything, "lobby-typo").Return(nil, notFound("lobby-typo"))
+			},
+			wantStatus: http.StatusNotFound,
+			wantCode:   "NOT_FOUND",
+			wantLevel:  "level=INFO",
+		},
+		{
+			name:      "repository failure",
+			displayID: displayID.String(),
+			mockSetup: func() {
+				mockSvc.On("Get", mock.Anything, displayID).Return(nil,
+					werrors.NewError("LOOKUP_FAILED", "Failed to retrieve display", "DisplayService.Get", errors.New("connection reset")))
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   "INTERNAL",
+			wantLevel:  "level=ERROR",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Reset mock
+			// Reset mock and captured logs
 			mockSvc.Mock = mock.Mock{}
+			logs.Reset()
 
 			// Setup mock expectations
 			tt.mockSetup()
@@ -247,16 +396,75 @@ func TestGetDisplay(t *testing.T) {
 			// Check status code
 			assert.Equal(t, tt.wantStatus, rec.Code)
 
+			// Timestamps survive the trip to the API type
+			if rec.Code == http.StatusOK {
+				var got v1alpha1.Display
+				require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+				assert.True(t, existingDisplay.CreatedAt.Equal(got.CreatedAt))
+				assert.True(t, existingDisplay.UpdatedAt.Equal(got.UpdatedAt))
+			} else {
+				var apiErr v1alpha1.Error
+				require.NoError(t, json.NewDecoder(rec.Body).Decode(&apiErr))
+				assert.Equal(t, tt.wantCode, apiErr.Code)
+				assert.Contains(t, logs.String(), tt.wantLevel)
+			}
+
 			// Verify mock
 			mockSvc.AssertExpectations(t)
 		})
 	}
 }
 
+func TestListDisplays(t *testing.T) {
+	mockSvc := &mockService{}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandler(mockSvc, nil, nil, logger)
+
+	created := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	displays := []*display.Display{
+		{ID: uuid.New(), Name: "lobby-north", Location: display.Location{SiteID: "hq", Zone: "lobby", Position: "north"}, CreatedAt: created},
+		{ID: uuid.New(), Name: "lobby-south", Location: display.Location{SiteID: "hq", Zone: "lobby", Position: "south"}, CreatedAt: created.Add(time.Hour)},
+	}
+	mockSvc.On("List", mock.Anything, display.DisplayFilter{SiteID: "hq", Zone: "lobby"}).Return(displays, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1alpha1/displays?siteId=hq&zone=lobby&position=south", nil)
+	rec := httptest.NewRecorder()
+	handler.ListDisplays(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var got []v1alpha1.Display
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	require.Len(t, got, 1)
+	assert.Equal(t, "lobby-south", got[0].Name)
+	assert.True(t, created.Add(time.Hour).Equal(got[0].CreatedAt))
+	mockSvc.AssertExpectations(t)
+}
+
+func TestListDisplaysNeverSeen(t *testing.T) {
+	mockSvc := &mockService{}
+	handler := NewHandler(mockSvc, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	mockSvc.On("List", mock.Anything, display.DisplayFilter{NeverSeen: true}).Return([]*display.Display{}, nil)
+	mockSvc.On("List", mock.Anything, display.DisplayFilter{Seen: true}).Return([]*display.Display{}, nil)
+
+	for _, tt := range []struct {
+		query string
+		want  int
+	}{
+		{"neverSeen=true", http.StatusOK},
+		{"neverSeen=false", http.StatusOK},
+		{"neverSeen=sometimes", http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		handler.ListDisplays(rec, httptest.NewRequest(http.MethodGet, "/api/v1alpha1/displays?"+tt.query, nil))
+		assert.Equal(t, tt.want, rec.Code, tt.query)
+	}
+	mockSvc.AssertExpectations(t)
+}
+
 func TestActivateDisplay(t *testing.T) {
 	mockSvc := &mockService{}
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-	handler := NewHandler(mockSvc, logger)
+	handler := NewHandler(mockSvc, nil, nil, logger)
 
 	displayID := uuid.New()
 
@@ -323,3 +531,189 @@ func TestActivateDisplay(t *testing.T) {
 		})
 	}
 }
+
+func TestPatchDisplay(t *testing.T) {
+	mockSvc := &mockService{}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandler(mockSvc, nil, nil, logger)
+
+	displayID := uuid.New()
+	existingDisplay := &display.Display{
+		ID:         displayID,
+		Name:       "test-display",
+		Location:   display.Location{SiteID: "site-1", Zone: "lobby"},
+		State:      display.StateActive,
+		Version:    2,
+		Properties: map[string]string{"screen-size": "55"},
+	}
+	patch := display.Patch{
+		SetProperties:    map[string]string{"owner": "facilities"},
+		RemoveProperties: []string{"temporary"},
+	}
+
+	tests := []struct {
+		name       string
+		ref        string
+		body       string
+		mockSetup  func()
+		wantStatus int
+	}{
+		{
+			name: "successful patch by name",
+			ref:  "test-display",
+			body: `{"addProperties":{"owner":"facilities"},"removeProperties":["temporary"]}`,
+			mockSetup: func() {
+				mockSvc.On("GetByName", mock.Anything, "test-display").Return(existingDisplay, nil)
+				mockSvc.On("Patch", mock.Anything, displayID, patch, 0).Return(existingDisplay, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "version conflict",
+			ref:  displayID.String(),
+			body: `{"addProperties":{"owner":"facilities"},"removeProperties":["temporary"],"version":1}`,
+			mockSetup: func() {
+				mockSvc.On("Get", mock.Anything, displayID).Return(existingDisplay, nil)
+				mockSvc.On("Patch", mock.Anything, displayID, patch, 1).Return(nil,
+					werrors.NewError("VERSION_CONFLICT", "Display was modified", "test", werrors.ErrVersionMismatch))
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "invalid body",
+			ref:        displayID.String(),
+			body:       `{`,
+			mockSetup:  func() {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "display not found",
+			ref:  "missing",
+			body: `{}`,
+			mockSetup: func() {
+				mockSvc.On("GetByName", mock.Anything, "missing").Return(nil, display.ErrNotFound{ID: "missing"})
+			},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc.Mock = mock.Mock{}
+			tt.mockSetup()
+
+			req := httptest.NewRequest(http.MethodPatch, "/api/v1alpha1/displays/"+tt.ref, bytes.NewBufferString(tt.body))
+			chiCtx := chi.NewRouteContext()
+			chiCtx.URLParams.Add("id", tt.ref)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+			rec := httptest.NewRecorder()
+
+			handler.PatchDisplay(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusOK {
+				var resp v1alpha1.Display
+				assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+				assert.Equal(t, "55", resp.Spec.Properties["screen-size"])
+			}
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRenameDisplay(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := memory.NewRepository()
+	service := display.NewService(repo, discardPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, logger)
+	handler := NewHandler(service, nil, nil, logger)
+	server := httptest.NewServer(NewRouter(handler, ratelimit.NewMemoryService(nil), nil))
+	defer server.Close()
+
+	seed := func(name string) *display.Display {
+		d, err := display.NewDisplay(name, display.Location{SiteID: "hq", Zone: "lobby"})
+		require.NoError(t, err)
+		d.State = display.StateActive
+		require.NoError(t, repo.Save(ctx, d))
+		return d
+	}
+	d := seed("lobby-north")
+	other := seed("Lobby-South")
+
+	patch := func(ref, body string) *http.Response {
+		req, err := http.NewRequest(http.MethodPatch, server.URL+"/api/v1alpha1/displays/"+ref, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+	get := func(ref string) int {
+		resp, err := http.Get(server.URL + "/api/v1alpha1/displays/" + ref)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// The display is connected while it is renamed
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1alpha1/displays/ws?id=" + d.ID.String()
+	ws, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	defer ws.Close()
+	require.Eventually(t, func() bool {
+		handler.hub.mu.RLock()
+		defer handler.hub.mu.RUnlock()
+		return len(handler.hub.connections) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	t.Run("name in use conflicts with the existing display", func(t *testing.T) {
+		resp := patch("lobby-north", `{"name":"lobby-south"}`)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+		var body struct {
+			Code    string           `json:"code"`
+			Details v1alpha1.Display `json:"details"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "DISPLAY_EXISTS", body.Code)
+		assert.Equal(t, other.ID, body.Details.ID)
+		assert.Equal(t, "Lobby-South", body.Details.Name)
+	})
+
+	t.Run("invalid name is rejected", func(t *testing.T) {
+		resp := patch("lobby-north", `{"name":"lobby north"}`)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("renames by ID", func(t *testing.T) {
+		before, err := repo.FindByID(ctx, d.ID)
+		require.NoError(t, err)
+
+		resp := patch(d.ID.String(), `{"name":"lobby-east"}`)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var renamed v1alpha1.Display
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&renamed))
+		assert.Equal(t, d.ID, renamed.ID)
+		assert.Equal(t, "lobby-east", renamed.Name)
+		assert.Equal(t, before.Version+1, renamed.Status.Version)
+
+		assert.Equal(t, http.StatusOK, get("lobby-east"))
+		assert.Equal(t, http.StatusNotFound, get("lobby-north"))
+	})
+
+	t.Run("connection survives the rename", func(t *testing.T) {
+		reload := &v1alpha1.ControlMessage{Type: v1alpha1.ControlMessageReload, Timestamp: time.Now()}
+		require.NoError(t, handler.SendControlMessage(d.ID, reload))
+
+		require.NoError(t, ws.SetReadDeadline(time.Now().Add(2*time.Second)))
+		_, data, err := ws.ReadMessage()
+		require.NoError(t, err)
+		var msg v1alpha1.ControlMessage
+		require.NoError(t, json.Unmarshal(data, &msg))
+		assert.Equal(t, v1alpha1.ControlMessageReload, msg.Type)
+	})
+}
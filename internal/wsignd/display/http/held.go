@@ -0,0 +1,128 @@
+package http
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+// maxHeldMessages bounds how many messages over a display's outbound rate
+// are kept for redelivery. Beyond it the oldest held message is dropped.
+const maxHeldMessages = 16
+
+// heldMessage is a control message waiting out its display's rate limit
+type heldMessage struct {
+	msgType v1alpha1.ControlMessageType
+	data    []byte
+}
+
+// heldQueue holds a display's messages until its rate limit allows them.
+// timer redelivers the queue once the limit's window has passed.
+type heldQueue struct {
+	messages []heldMessage
+	timer    *time.Timer
+}
+
+// hold queues a message that is over its display's outbound rate and, when
+// the queue was empty, tells the display how long it will wait and
+// schedules redelivery. A sequence update replaces one held directly before
+// it, since displays only act on the latest sequence. Callers hold heldMu.
+func (h *Hub) hold(displayID uuid.UUID, msgType v1alpha1.ControlMessageType, data []byte, retryAfter time.Duration) {
+	q := h.held[displayID]
+	if q == nil {
+		q = &heldQueue{}
+		h.held[displayID] = q
+		q.timer = time.AfterFunc(retryAfter, func() { h.redeliver(displayID) })
+		h.notifyRateLimited(displayID, v1alpha1.RateLimitOutbound, retryAfter)
+	}
+
+	if n := len(q.messages); n > 0 && msgType == v1alpha1.ControlMessageSequenceUpdate && q.messages[n-1].msgType == msgType {
+		q.messages[n-1].data = data
+		return
+	}
+	if len(q.messages) == maxHeldMessages {
+		h.logger.Warn("held message queue full, dropped oldest",
+			"displayId", displayID,
+			"type", q.messages[0].msgType,
+		)
+		q.messages = q.messages[1:]
+	}
+	q.messages = append(q.messages, heldMessage{msgType: msgType, data: data})
+}
+
+// redeliver sends a display's held messages in order for as long as its
+// rate limit allows, and schedules another attempt for the rest. The
+// display was told when the first message was held, so it is not told
+// again.
+func (h *Hub) redeliver(displayID uuid.UUID) {
+	h.heldMu.Lock()
+	defer h.heldMu.Unlock()
+
+	q := h.held[displayID]
+	if q == nil {
+		return
+	}
+	for len(q.messages) > 0 {
+		status, err := h.allowMessage(ratelimit.LimitTypeWSMessageOut, displayID)
+		if err != nil {
+			retryAfter := time.Second
+			if status != nil && status.RetryAfter > 0 {
+				retryAfter = status.RetryAfter
+			}
+			q.timer = time.AfterFunc(retryAfter, func() { h.redeliver(displayID) })
+			return
+		}
+
+		m := q.messages[0]
+		q.messages = q.messages[1:]
+		if err := h.deliver(displayID, m.msgType, m.data); err != nil {
+			// A display that went away gets what it missed from the outbox
+			// and its settings on reconnect
+			h.logger.Warn("dropped held messages",
+				"error", err,
+				"displayId", displayID,
+				"messages", len(q.messages)+1,
+			)
+			break
+		}
+	}
+	delete(h.held, displayID)
+}
+
+// notifyRateLimited tells a display's connections that accept it that
+// messages hit its rate limit and when the limit allows them again. The
+// notice itself is not counted against the limit.
+func (h *Hub) notifyRateLimited(displayID uuid.UUID, direction v1alpha1.RateLimitDirection, retryAfter time.Duration) {
+	data, err := json.Marshal(rateLimitedMessage(direction, retryAfter))
+	if err != nil {
+		h.logger.Error("failed to marshal rate limit notice", "error", err)
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for c := range h.connections {
+		if c.displayID == displayID && c.accepts(v1alpha1.ControlMessageRateLimited) {
+			c.enqueue(data)
+		}
+	}
+}
+
+// rateLimitedMessage builds a RATE_LIMITED notice, rounding retryAfter up to
+// whole seconds
+func rateLimitedMessage(direction v1alpha1.RateLimitDirection, retryAfter time.Duration) *v1alpha1.ControlMessage {
+	return &v1alpha1.ControlMessage{
+		TypeMeta:  v1alpha1.TypeMeta{Kind: "ControlMessage", APIVersion: v1alpha1.ControlAPIVersion},
+		Type:      v1alpha1.ControlMessageRateLimited,
+		Timestamp: time.Now(),
+		RateLimit: &v1alpha1.ControlRateLimit{
+			Direction:         direction,
+			RetryAfterSeconds: int(math.Max(1, math.Ceil(retryAfter.Seconds()))),
+		},
+	}
+}
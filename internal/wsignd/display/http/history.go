@@ -0,0 +1,99 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+)
+
+// recordTimeout bounds content history writes made outside a request
+const recordTimeout = 5 * time.Second
+
+// GetContentHistory returns a display's most recent content transitions,
+// newest first. The display may be given by ID or name, and the limit query
+// parameter caps the number of entries returned.
+func (h *Handler) GetContentHistory(w http.ResponseWriter, r *http.Request) {
+	d, err := h.lookupDisplay(r, chi.URLParam(r, "id"))
+	if err != nil {
+		h.logger.Error("failed to get display",
+			"error", err,
+			"id", chi.URLParam(r, "id"),
+		)
+		httpapi.Error(w, http.StatusNotFound, "display not found")
+		return
+	}
+
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			httpapi.Error(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+	}
+
+	history, err := h.service.ContentHistory(r.Context(), d.ID, limit)
+	if err != nil {
+		h.logger.Error("failed to get content history",
+			"error", err,
+			"id", d.ID,
+		)
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := &v1alpha1.ContentHistory{
+		TypeMeta: v1alpha1.TypeMeta{
+			Kind:       "ContentHistory",
+			APIVersion: "v1alpha1",
+		},
+		DisplayID: d.ID,
+		Items:     make([]v1alpha1.ContentHistoryEntry, 0, len(history)),
+	}
+	for _, t := range history {
+		resp.Items = append(resp.Items, v1alpha1.ContentHistoryEntry{
+			Timestamp: t.Timestamp,
+			FromURL:   t.FromURL,
+			ToURL:     t.ToURL,
+			Trigger:   string(t.Trigger),
+		})
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, resp)
+}
+
+// recordContentChange stores a content transition for a display. Failures are
+// logged rather than returned since history is diagnostic only.
+func (h *Handler) recordContentChange(displayID uuid.UUID, url string, trigger display.ContentTrigger) {
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+
+	if err := h.service.RecordContentChange(ctx, displayID, url, trigger); err != nil {
+		h.logger.Warn("failed to record content change",
+			"error", err,
+			"displayId", displayID,
+			"trigger", trigger,
+		)
+	}
+}
+
+// recordControlMessage records the content change implied by a control
+// message pushed to a display
+func (h *Handler) recordControlMessage(displayID uuid.UUID, message *v1alpha1.ControlMessage) {
+	switch message.Type {
+	case v1alpha1.ControlMessageReload:
+		h.recordContentChange(displayID, "", display.TriggerReload)
+	case v1alpha1.ControlMessageSequenceUpdate:
+		if message.Sequence != nil && len(message.Sequence.Items) > 0 {
+			h.recordContentChange(displayID, message.Sequence.Items[0].URL, display.TriggerAssignmentChange)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+)
+
+func TestGetContentHistory(t *testing.T) {
+	mockSvc := &mockService{}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandler(mockSvc, nil, nil, logger)
+
+	displayID := uuid.New()
+	d := &display.Display{ID: displayID, Name: "lobby-north", State: display.StateActive}
+	now := time.Now().UTC()
+	history := []*display.ContentTransition{
+		{DisplayID: displayID, Timestamp: now, FromURL: "https://example.com/a", ToURL: "https://example.com/b", Trigger: display.TriggerSequence},
+		{DisplayID: displayID, Timestamp: now.Add(-time.Minute), ToURL: "https://example.com/a", Trigger: display.TriggerAssignmentChange},
+	}
+
+	tests := []struct {
+		name       string
+		ref        string
+		query      string
+		mockSetup  func()
+		wantStatus int
+		wantItems  int
+	}{
+		{
+			name: "by name with limit",
+			ref:  "lobby-north",
+			mockSetup: func() {
+				mockSvc.On("GetByName", mock.Anything, "lobby-north").Return(d, nil)
+				mockSvc.On("ContentHistory", mock.Anything, displayID, 2).Return(history, nil)
+			},
+			query:      "?limit=2",
+			wantStatus: http.StatusOK,
+			wantItems:  2,
+		},
+		{
+			name: "by id without history",
+			ref:  displayID.String(),
+			mockSetup: func() {
+				mockSvc.On("Get", mock.Anything, displayID).Return(d, nil)
+				mockSvc.On("ContentHistory", mock.Anything, displayID, 0).Return(nil, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantItems:  0,
+		},
+		{
+			name: "invalid limit",
+			ref:  displayID.String(),
+			mockSetup: func() {
+				mockSvc.On("Get", mock.Anything, displayID).Return(d, nil)
+			},
+			query:      "?limit=-1",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "display not found",
+			ref:  "missing",
+			mockSetup: func() {
+				mockSvc.On("GetByName", mock.Anything, "missing").Return(nil, display.ErrNotFound{ID: "missing"})
+			},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc.Mock = mock.Mock{}
+			tt.mockSetup()
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1alpha1/displays/"+tt.ref+"/content-history"+tt.query, nil)
+			chiCtx := chi.NewRouteContext()
+			chiCtx.URLParams.Add("id", tt.ref)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+			rec := httptest.NewRecorder()
+
+			handler.GetContentHistory(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusOK {
+				var resp v1alpha1.ContentHistory
+				require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+				assert.Equal(t, displayID, resp.DisplayID)
+				require.Len(t, resp.Items, tt.wantItems)
+				if tt.wantItems > 0 {
+					assert.Equal(t, "https://example.com/b", resp.Items[0].ToURL)
+					assert.Equal(t, "sequence", resp.Items[0].Trigger)
+				}
+			}
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
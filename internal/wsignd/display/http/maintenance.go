@@ -0,0 +1,99 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+)
+
+// MaintenanceNotices reports the maintenance in progress
+type MaintenanceNotices interface {
+	// Notice returns what displays are told about the maintenance in
+	// progress, or nil when there is none
+	Notice() *v1alpha1.MaintenanceNotice
+}
+
+// SetMaintenanceNotices has GetSelf tell displays when their content is a
+// maintenance notice. It must be called before the handler serves
+// requests.
+func (h *Handler) SetMaintenanceNotices(notices MaintenanceNotices) {
+	h.notices = notices
+}
+
+// BroadcastContent resolves the content of each connected display that
+// accepts sequence updates and sends it to the display, as a resync would,
+// returning how many displays were sent their content. It is used when
+// maintenance starts or ends, so the change is recorded in each display's
+// content history as maintenance. Displays that no assignment selects are
+// left alone and pick up the change when they next read their content.
+func (h *Handler) BroadcastContent(ctx context.Context) int {
+	if h.content == nil {
+		return 0
+	}
+
+	sent := 0
+	for displayID := range h.hub.displaysAccepting(v1alpha1.ControlMessageSequenceUpdate) {
+		if h.sendContent(ctx, displayID) {
+			sent++
+		}
+	}
+	return sent
+}
+
+// sendContent sends a display the content resolved for it now, reporting
+// whether it was sent
+func (h *Handler) sendContent(ctx context.Context, displayID uuid.UUID) bool {
+	d, err := h.service.Get(ctx, displayID)
+	if err != nil {
+		h.logger.Warn("failed to get display",
+			"error", err,
+			"displayId", displayID,
+		)
+		return false
+	}
+	location := v1alpha1.DisplayLocation{
+		SiteID:   d.Location.SiteID,
+		Zone:     d.Location.Zone,
+		Position: d.Location.Position,
+	}
+	a, err := h.content.ForDisplay(ctx, d.ID, location, d.Properties)
+	if err != nil {
+		h.logger.Warn("failed to resolve display content",
+			"error", err,
+			"displayId", displayID,
+		)
+		return false
+	}
+	if a == nil {
+		return false
+	}
+
+	err = h.sendControlMessage(displayID, &v1alpha1.ControlMessage{
+		TypeMeta: v1alpha1.TypeMeta{Kind: "ControlMessage", APIVersion: "v1alpha1"},
+		Type:     v1alpha1.ControlMessageSequenceUpdate,
+		Sequence: &v1alpha1.ContentSequence{
+			Items: []v1alpha1.ContentItem{{URL: a.ContentURL}},
+		},
+		Timestamp: time.Now(),
+	})
+	switch {
+	case err == nil:
+	case errors.Is(err, errNotConnected):
+		// The display went away since the list was taken; it reads its
+		// content when it reconnects
+		return false
+	default:
+		h.logger.Warn("failed to send display content",
+			"error", err,
+			"displayId", displayID,
+		)
+		return false
+	}
+	h.recordContentChange(displayID, a.ContentURL, display.TriggerMaintenance)
+	return true
+}
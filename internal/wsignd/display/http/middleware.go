@@ -0,0 +1,14 @@
+package http
+
+import "net/http"
+
+// noCache marks responses as uncacheable. Activation codes and their results
+// must never be served from a shared cache.
+func noCache(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate, max-age=0")
+		w.Header().Set("Pragma", "no-cache")
+		w.Header().Set("Expires", "0")
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,162 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/outbox"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+)
+
+// SetOutbox enables store-and-forward delivery: displays are sent their
+// queued messages as soon as they connect, and the pending message routes
+// become available. It must be called before the handler serves requests.
+func (h *Handler) SetOutbox(o outbox.Service) {
+	h.outbox = o
+	h.hub.onRegister = h.deliverQueued
+}
+
+// DeliverQueued sends queued messages to the displays connected to this
+// server. Run periodically, it picks up messages queued by other servers
+// for displays that are connected here.
+func (h *Handler) DeliverQueued(ctx context.Context) error {
+	if h.outbox == nil {
+		return nil
+	}
+	return h.outbox.DeliverConnected(ctx, h.hub.connected)
+}
+
+// deliverQueued sends a newly connected display its queued messages
+func (h *Handler) deliverQueued(displayID uuid.UUID) {
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+
+	if _, err := h.outbox.Deliver(ctx, displayID); err != nil {
+		h.logger.Warn("failed to deliver queued messages",
+			"error", err,
+			"displayId", displayID,
+		)
+	}
+}
+
+// ListPendingMessages lists the control messages queued for a display in
+// queue order. The display may be given by ID or name, and the state query
+// parameter narrows the list, e.g. state=dead for dead letters.
+func (h *Handler) ListPendingMessages(w http.ResponseWriter, r *http.Request) {
+	if h.outbox == nil {
+		httpapi.Error(w, http.StatusNotImplemented, "message outbox is not enabled")
+		return
+	}
+
+	d, err := h.lookupDisplay(r, chi.URLParam(r, "id"))
+	if err != nil {
+		h.logRequestError(r, "failed to get display", err, "id", chi.URLParam(r, "id"))
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	messages, err := h.outbox.List(r.Context(), outbox.Filter{
+		DisplayID: d.ID,
+		State:     outbox.State(r.URL.Query().Get("state")),
+	})
+	if err != nil {
+		h.logRequestError(r, "failed to list pending messages", err, "id", d.ID)
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := &v1alpha1.PendingMessageList{
+		TypeMeta: v1alpha1.TypeMeta{
+			Kind:       "PendingMessageList",
+			APIVersion: "v1alpha1",
+		},
+		DisplayID: d.ID,
+		Items:     make([]v1alpha1.PendingMessage, 0, len(messages)),
+	}
+	for _, m := range messages {
+		resp.Items = append(resp.Items, toAPIPendingMessage(m))
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, resp)
+}
+
+// ReplayPendingMessage queues a dead letter again and delivers it at once
+// if the display is connected. Replays are recorded in the audit log along
+// with the operator who made them.
+func (h *Handler) ReplayPendingMessage(w http.ResponseWriter, r *http.Request) {
+	if h.outbox == nil {
+		httpapi.Error(w, http.StatusNotImplemented, "message outbox is not enabled")
+		return
+	}
+
+	d, err := h.lookupDisplay(r, chi.URLParam(r, "id"))
+	if err != nil {
+		h.logRequestError(r, "failed to get display", err, "id", chi.URLParam(r, "id"))
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	messageID, err := uuid.Parse(chi.URLParam(r, "messageId"))
+	if err != nil {
+		httpapi.Error(w, http.StatusBadRequest, "invalid message ID")
+		return
+	}
+
+	// A message of another display is reported as missing rather than
+	// replayed through the wrong URL
+	m, err := h.outbox.Get(r.Context(), messageID)
+	if err == nil && m.DisplayID != d.ID {
+		httpapi.Error(w, http.StatusNotFound, "message not found")
+		return
+	}
+	if err == nil {
+		m, err = h.outbox.Replay(r.Context(), messageID)
+	}
+	if err != nil {
+		h.logRequestError(r, "failed to replay message", err,
+			"id", d.ID,
+			"messageId", messageID,
+		)
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("dead letter replayed",
+		"audit", true,
+		"displayId", d.ID,
+		"displayName", d.Name,
+		"messageId", messageID,
+		"type", m.Message.Type,
+		"state", m.State,
+		"operator", operator.Name(r.Context()),
+		"remoteAddr", r.RemoteAddr,
+		"requestId", middleware.GetReqID(r.Context()),
+	)
+
+	httpapi.WriteJSON(w, http.StatusOK, toAPIPendingMessage(m))
+}
+
+// toAPIPendingMessage converts a queued message to its API form
+func toAPIPendingMessage(m *outbox.Message) v1alpha1.PendingMessage {
+	msg := v1alpha1.PendingMessage{
+		ID:        m.ID,
+		DisplayID: m.DisplayID,
+		Message:   m.Message,
+		State:     v1alpha1.PendingMessageState(m.State),
+		Attempts:  m.Attempts,
+		LastError: m.LastError,
+		CreatedAt: m.CreatedAt,
+		ExpiresAt: m.ExpiresAt,
+	}
+	if !m.DeliveredAt.IsZero() {
+		deliveredAt := m.DeliveredAt
+		msg.DeliveredAt = &deliveredAt
+	}
+	return msg
+}
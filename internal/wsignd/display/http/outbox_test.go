@@ -0,0 +1,149 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/outbox"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+// TestOutboxDeliversOnConnect queues messages for a disconnected display
+// and checks they arrive in order once its control socket connects
+func TestOutboxDeliversOnConnect(t *testing.T) {
+	ctx := context.Background()
+	displayID := uuid.New()
+	activeDisplay := &display.Display{
+		ID:    displayID,
+		Name:  "lobby-north",
+		State: display.StateActive,
+	}
+
+	mockSvc := &mockService{}
+	mockSvc.On("Get", mock.Anything, displayID).Return(activeDisplay, nil)
+	mockSvc.On("GetByName", mock.Anything, "lobby-north").Return(activeDisplay, nil)
+	mockSvc.On("UpdateLastSeen", mock.Anything, displayID).Return(nil)
+	mockSvc.On("RecordContentChange", mock.Anything, displayID, mock.Anything, mock.Anything).Return(nil)
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandler(mockSvc, nil, nil, logger)
+	repo := memory.NewOutboxRepository()
+	box := outbox.NewService(repo, handler, time.Hour, logger)
+	handler.SetOutbox(box)
+	server := httptest.NewServer(NewRouter(handler, ratelimit.NewMemoryService(nil), nil))
+	defer server.Close()
+
+	messagesURL := server.URL + "/api/v1alpha1/displays/lobby-north/pending-messages"
+
+	sequence := &v1alpha1.ControlMessage{
+		Type: v1alpha1.ControlMessageSequenceUpdate,
+		Sequence: &v1alpha1.ContentSequence{
+			Items: []v1alpha1.ContentItem{{URL: "https://example.com/fixed"}},
+		},
+	}
+	reload := &v1alpha1.ControlMessage{Type: v1alpha1.ControlMessageReload}
+	require.ErrorIs(t, box.Send(ctx, displayID, sequence), outbox.ErrQueued)
+	require.ErrorIs(t, box.Send(ctx, displayID, reload), outbox.ErrQueued)
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1alpha1/displays/ws?id=" + displayID.String()
+	ws, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	read := func() v1alpha1.ControlMessageType {
+		require.NoError(t, ws.SetReadDeadline(time.Now().Add(2*time.Second)))
+		var msg v1alpha1.ControlMessage
+		require.NoError(t, ws.ReadJSON(&msg))
+		return msg.Type
+	}
+	assert.Equal(t, v1alpha1.ControlMessageSequenceUpdate, read())
+	assert.Equal(t, v1alpha1.ControlMessageReload, read())
+
+	list := func(query string) v1alpha1.PendingMessageList {
+		resp, err := http.Get(messagesURL + query)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var list v1alpha1.PendingMessageList
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+		return list
+	}
+	delivered := list("?state=delivered")
+	require.Len(t, delivered.Items, 2)
+	for _, m := range delivered.Items {
+		assert.Equal(t, 1, m.Attempts)
+		assert.NotNil(t, m.DeliveredAt)
+	}
+
+	replay := func(id uuid.UUID) *http.Response {
+		resp, err := http.Post(messagesURL+"/"+id.String()+"/replay", "application/json", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	t.Run("dead letters are replayed to a connected display", func(t *testing.T) {
+		dead := &outbox.Message{
+			ID:        uuid.New(),
+			DisplayID: displayID,
+			Message:   v1alpha1.ControlMessage{Type: v1alpha1.ControlMessageReload},
+			State:     outbox.StateDead,
+			CreatedAt: time.Now().Add(-2 * time.Hour),
+			ExpiresAt: time.Now().Add(-time.Hour),
+		}
+		require.NoError(t, repo.Create(ctx, dead))
+		require.Len(t, list("?state=dead").Items, 1)
+
+		assert.Equal(t, http.StatusOK, replay(dead.ID).StatusCode)
+		assert.Equal(t, v1alpha1.ControlMessageReload, read())
+		assert.Empty(t, list("?state=dead").Items)
+
+		// Only dead letters can be replayed
+		assert.Equal(t, http.StatusConflict, replay(dead.ID).StatusCode)
+	})
+
+	t.Run("messages of other displays are not found", func(t *testing.T) {
+		other := &outbox.Message{
+			ID:        uuid.New(),
+			DisplayID: uuid.New(),
+			State:     outbox.StateDead,
+			ExpiresAt: time.Now(),
+		}
+		require.NoError(t, repo.Create(ctx, other))
+		assert.Equal(t, http.StatusNotFound, replay(other.ID).StatusCode)
+		assert.Equal(t, http.StatusNotFound, replay(uuid.New()).StatusCode)
+	})
+
+	t.Run("unknown states are rejected", func(t *testing.T) {
+		resp, err := http.Get(messagesURL + "?state=lost")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestPendingMessagesWithoutOutbox(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	router := NewRouter(NewHandler(&mockService{}, nil, nil, logger), ratelimit.NewMemoryService(nil), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1alpha1/displays/lobby-north/pending-messages", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotImplemented, rec.Code)
+}
@@ -0,0 +1,142 @@
+package http
+
+import (
+	"embed"
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/activation"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var activatePage = template.Must(template.ParseFS(templateFS, "templates/activate.html"))
+
+// activatePageData is the view model for the activation page
+type activatePageData struct {
+	Action   string
+	Code     string
+	SiteID   string
+	Zone     string
+	Position string
+	Name     string
+	Error    string
+	Display  *display.Display
+	// Suggested is set when the form was pre-filled from the display's
+	// hints
+	Suggested bool
+}
+
+// ActivationPage renders the form operators use to activate a display from
+// a browser. A code query parameter pre-fills the form, along with the
+// location and name the display suggested for itself, if any.
+func (h *Handler) ActivationPage(w http.ResponseWriter, r *http.Request) {
+	data := &activatePageData{
+		Action: activationPagePath,
+		Code:   activation.NormalizeUserCode(r.URL.Query().Get("code")),
+	}
+	// A code that cannot be used is reported when the form is submitted
+	if data.Code != "" {
+		if code, err := h.activation.CheckCode(r.Context(), data.Code); err == nil {
+			data.fillHints(code)
+		}
+	}
+	h.renderActivatePage(w, http.StatusOK, data)
+}
+
+// fillHints pre-fills the form with the site a code is bound to and the
+// hints its display sent
+func (d *activatePageData) fillHints(code *activation.DeviceCode) {
+	hints := code.Hints
+	d.SiteID = code.AllowedSite
+	if d.SiteID == "" {
+		d.SiteID = hints.Location.SiteID
+	}
+	d.Zone = hints.Location.Zone
+	d.Position = hints.Location.Position
+	d.Name = hints.Name
+	d.Suggested = !hints.Empty()
+}
+
+// SubmitActivation handles the activation form
+func (h *Handler) SubmitActivation(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.renderActivatePage(w, http.StatusBadRequest, &activatePageData{
+			Action: activationPagePath,
+			Error:  "The form could not be read. Please try again.",
+		})
+		return
+	}
+
+	data := &activatePageData{
+		Action:   activationPagePath,
+		Code:     activation.NormalizeUserCode(r.PostForm.Get("code")),
+		SiteID:   r.PostForm.Get("site"),
+		Zone:     r.PostForm.Get("zone"),
+		Position: r.PostForm.Get("position"),
+		Name:     r.PostForm.Get("name"),
+	}
+
+	d, err := h.activate(r.Context(), &v1alpha1.DisplayRegistrationRequest{
+		Name:           data.Name,
+		ActivationCode: data.Code,
+		Location: v1alpha1.DisplayLocation{
+			SiteID:   data.SiteID,
+			Zone:     data.Zone,
+			Position: data.Position,
+		},
+	})
+	if err != nil {
+		status := errorStatus(err, http.StatusInternalServerError)
+		if status >= http.StatusInternalServerError {
+			h.logger.Error("failed to activate display",
+				"error", err,
+				"name", data.Name,
+			)
+		}
+		data.Error = activationErrorMessage(err)
+		h.renderActivatePage(w, status, data)
+		return
+	}
+
+	data.Display = d
+	h.renderActivatePage(w, http.StatusOK, data)
+}
+
+// renderActivatePage writes the activation page with the given status
+func (h *Handler) renderActivatePage(w http.ResponseWriter, status int, data *activatePageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(status)
+	if err := activatePage.Execute(w, data); err != nil {
+		h.logger.Error("failed to render activation page",
+			"error", err,
+		)
+	}
+}
+
+// activationErrorMessage returns a user-facing explanation of an activation
+// failure
+func activationErrorMessage(err error) string {
+	var domainErr *werrors.Error
+	switch {
+	case errors.Is(err, activation.ErrCodeNotFound):
+		return "That code was not recognised. Check the code shown on the display."
+	case errors.Is(err, activation.ErrCodeExpired):
+		return "That code has expired. Restart the display to get a new code."
+	case errors.Is(err, activation.ErrAlreadyActivated):
+		return "That code has already been used."
+	case errors.Is(err, activation.ErrSiteNotAllowed):
+		return "That display cannot be activated at this site."
+	case werrors.IsConflict(err):
+		return "A display with that name already exists. Choose another name."
+	case werrors.IsInvalidInput(err) && errors.As(err, &domainErr):
+		return "Please check the form: " + domainErr.Message + "."
+	}
+	return "Activation failed. Please try again."
+}
@@ -0,0 +1,138 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+)
+
+// PollingPath is where administrators read and change the polling policy
+const PollingPath = "/api/v1alpha1/admin/polling"
+
+// SetPolling replaces the default polling policy with the configured one.
+// It must be called before the handler serves requests.
+func (h *Handler) SetPolling(p *display.Polling) {
+	h.polling = p
+}
+
+// pollingHints returns the API form of the hints for displays at siteID
+func (h *Handler) pollingHints(siteID string) *v1alpha1.PollingHints {
+	hints := toAPIPollingHints(h.polling.For(siteID))
+	return &hints
+}
+
+// GetPollingPolicy returns the polling policy in effect
+func (h *Handler) GetPollingPolicy(w http.ResponseWriter, r *http.Request) {
+	httpapi.WriteJSON(w, http.StatusOK, toAPIPollingPolicy(h.polling.Policy()))
+}
+
+// UpdatePollingPolicy replaces the polling policy and sends every connected
+// display that accepts settings its new hints. The change is held by this
+// server until it restarts; other replicas keep their own policy.
+func (h *Handler) UpdatePollingPolicy(w http.ResponseWriter, r *http.Request) {
+	const op = "DisplayHandler.UpdatePollingPolicy"
+
+	var req v1alpha1.PollingPolicy
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpapi.Error(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := h.polling.SetPolicy(fromAPIPollingPolicy(req)); err != nil {
+		httpapi.WriteError(w, werrors.NewError("INVALID_INPUT", err.Error(), op, werrors.ErrInvalidInput), http.StatusBadRequest)
+		return
+	}
+
+	policy := h.polling.Policy()
+	h.logger.Info("polling policy changed",
+		"heartbeatInterval", policy.Default.HeartbeatInterval,
+		"contentPollInterval", policy.Default.ContentPollInterval,
+		"siteOverrides", len(policy.Sites),
+		"displaysNotified", h.broadcastPolling(),
+	)
+	httpapi.WriteJSON(w, http.StatusOK, toAPIPollingPolicy(policy))
+}
+
+// broadcastPolling sends each connected display that accepts settings the
+// hints for its site, returning how many displays were sent them. The
+// messages carry no device settings, so displays keep theirs.
+func (h *Handler) broadcastPolling() int {
+	sent := 0
+	for displayID, siteID := range h.hub.displaysAccepting(v1alpha1.ControlMessageSettings) {
+		if h.sendPolling(displayID, siteID) {
+			sent++
+		}
+	}
+	return sent
+}
+
+// sendPolling sends a display the hints for its site, reporting whether it
+// was sent them
+func (h *Handler) sendPolling(displayID uuid.UUID, siteID string) bool {
+	err := h.SendControlMessage(displayID, &v1alpha1.ControlMessage{
+		TypeMeta: v1alpha1.TypeMeta{
+			Kind:       "ControlMessage",
+			APIVersion: v1alpha1.ControlAPIVersion,
+		},
+		Type:      v1alpha1.ControlMessageSettings,
+		Timestamp: time.Now(),
+		Polling:   h.pollingHints(siteID),
+	})
+	if err != nil {
+		// The display went away since the list was taken; it gets its
+		// hints when it reconnects
+		h.logger.Debug("failed to send polling hints",
+			"error", err,
+			"displayId", displayID,
+		)
+		return false
+	}
+	return true
+}
+
+// toAPIPollingPolicy converts a polling policy to its API representation
+func toAPIPollingPolicy(p display.PollingPolicy) *v1alpha1.PollingPolicy {
+	policy := &v1alpha1.PollingPolicy{
+		TypeMeta: v1alpha1.TypeMeta{Kind: "PollingPolicy", APIVersion: "v1alpha1"},
+		Default:  toAPIPollingHints(p.Default),
+	}
+	if len(p.Sites) > 0 {
+		policy.Sites = make(map[string]v1alpha1.PollingHints, len(p.Sites))
+		for site, hints := range p.Sites {
+			policy.Sites[site] = toAPIPollingHints(hints)
+		}
+	}
+	return policy
+}
+
+func toAPIPollingHints(h display.PollingHints) v1alpha1.PollingHints {
+	return v1alpha1.PollingHints{
+		HeartbeatInterval:   int(h.HeartbeatInterval / time.Second),
+		ContentPollInterval: int(h.ContentPollInterval / time.Second),
+	}
+}
+
+// fromAPIPollingPolicy converts an API polling policy to the domain policy
+func fromAPIPollingPolicy(p v1alpha1.PollingPolicy) display.PollingPolicy {
+	policy := display.PollingPolicy{Default: fromAPIPollingHints(p.Default)}
+	if len(p.Sites) > 0 {
+		policy.Sites = make(map[string]display.PollingHints, len(p.Sites))
+		for site, hints := range p.Sites {
+			policy.Sites[site] = fromAPIPollingHints(hints)
+		}
+	}
+	return policy
+}
+
+func fromAPIPollingHints(h v1alpha1.PollingHints) display.PollingHints {
+	return display.PollingHints{
+		HeartbeatInterval:   time.Duration(h.HeartbeatInterval) * time.Second,
+		ContentPollInterval: time.Duration(h.ContentPollInterval) * time.Second,
+	}
+}
@@ -0,0 +1,135 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+func TestPollingHints(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	liveness := display.Liveness{OfflineAfter: 10 * time.Minute}
+	service := display.NewService(memory.NewRepository(), discardPublisher{}, 10, liveness, display.ApprovalPolicy{}, display.Capacity{}, logger)
+	handler := NewHandler(service, nil, nil, logger)
+	polling, err := display.NewPolling(display.PollingPolicy{
+		Default: display.PollingHints{HeartbeatInterval: time.Minute, ContentPollInterval: 5 * time.Minute},
+		Sites:   map[string]display.PollingHints{"annex": {HeartbeatInterval: 2 * time.Minute}},
+	}, liveness)
+	require.NoError(t, err)
+	handler.SetPolling(polling)
+	server := httptest.NewServer(NewRouter(handler, ratelimit.NewMemoryService(nil), nil))
+	defer server.Close()
+
+	register := func(name, site string) *display.Display {
+		d, err := service.Register(ctx, name, display.Location{SiteID: site, Zone: "lobby", Position: "north"}, nil)
+		require.NoError(t, err)
+		require.NoError(t, service.Activate(ctx, d.ID))
+		return d
+	}
+	hq := register("hq-lobby", "hq")
+	annex := register("annex-lobby", "annex")
+
+	lastSeen := func(d *display.Display) v1alpha1.HeartbeatResponse {
+		req, err := http.NewRequest(http.MethodPut, server.URL+"/api/v1alpha1/displays/"+d.ID.String()+"/last-seen", nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var got v1alpha1.HeartbeatResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+		return got
+	}
+
+	putPolicy := func(body string) (*http.Response, v1alpha1.PollingPolicy) {
+		req, err := http.NewRequest(http.MethodPut, server.URL+PollingPath, strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var got v1alpha1.PollingPolicy
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+		}
+		return resp, got
+	}
+
+	// readPolling reads control messages until one carries polling hints
+	readPolling := func(ws *websocket.Conn) *v1alpha1.ControlMessage {
+		require.NoError(t, ws.SetReadDeadline(time.Now().Add(2*time.Second)))
+		for {
+			_, data, err := ws.ReadMessage()
+			require.NoError(t, err)
+			var msg v1alpha1.ControlMessage
+			require.NoError(t, json.Unmarshal(data, &msg))
+			if msg.Type == v1alpha1.ControlMessageSettings && msg.Polling != nil {
+				return &msg
+			}
+		}
+	}
+
+	t.Run("check-ins return the hints for the display's site", func(t *testing.T) {
+		assert.Equal(t, v1alpha1.PollingHints{HeartbeatInterval: 60, ContentPollInterval: 300}, lastSeen(hq).PollingHints)
+		assert.Equal(t, v1alpha1.PollingHints{HeartbeatInterval: 120, ContentPollInterval: 300}, lastSeen(annex).PollingHints,
+			"intervals a site leaves unset come from the default")
+	})
+
+	t.Run("connected displays are sent a changed policy", func(t *testing.T) {
+		wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1alpha1/displays/ws?id=" + annex.ID.String()
+		ws, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		require.NoError(t, err)
+		defer ws.Close()
+		require.NoError(t, ws.WriteJSON(v1alpha1.ControlMessage{
+			TypeMeta: v1alpha1.TypeMeta{APIVersion: v1alpha1.ControlAPIVersion},
+			Type:     v1alpha1.ControlMessageStatus,
+			Status: &v1alpha1.ControlStatus{
+				State:        v1alpha1.DisplayStateActive,
+				Capabilities: v1alpha1.KnownControlMessageTypes(),
+			},
+		}))
+
+		// Declaring capabilities brings the current hints
+		msg := readPolling(ws)
+		assert.Equal(t, 120, msg.Polling.HeartbeatInterval)
+		assert.Nil(t, msg.Settings, "a display without settings is sent none")
+
+		resp, got := putPolicy(`{"default":{"heartbeatInterval":300,"contentPollInterval":1800},"sites":{"annex":{"contentPollInterval":600}}}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 300, got.Default.HeartbeatInterval)
+
+		msg = readPolling(ws)
+		assert.Equal(t, v1alpha1.PollingHints{HeartbeatInterval: 300, ContentPollInterval: 600}, *msg.Polling)
+		assert.Equal(t, v1alpha1.PollingHints{HeartbeatInterval: 300, ContentPollInterval: 1800}, lastSeen(hq).PollingHints)
+	})
+
+	t.Run("absurd intervals are rejected", func(t *testing.T) {
+		for _, body := range []string{
+			`{"default":{"heartbeatInterval":1,"contentPollInterval":300}}`,
+			`{"default":{"heartbeatInterval":60,"contentPollInterval":31536000}}`,
+			// Displays would be marked offline between heartbeats
+			`{"default":{"heartbeatInterval":900,"contentPollInterval":300}}`,
+			`{"default":{"heartbeatInterval":60,"contentPollInterval":300},"sites":{"annex":{"heartbeatInterval":-5}}}`,
+		} {
+			resp, _ := putPolicy(body)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+		}
+
+		assert.Equal(t, 300, lastSeen(hq).HeartbeatInterval, "a rejected policy leaves the current one in effect")
+	})
+}
@@ -0,0 +1,222 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/activation"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+)
+
+// ProvisioningFileKind is the kind of the signed files displays are
+// provisioned from
+const ProvisioningFileKind = "DisplayProvisioningFile"
+
+// ProvisionDisplay registers and activates a display ahead of its
+// installation and returns its provisioning file: the display's identity,
+// initial configuration and a single use enrollment token, signed with the
+// server's primary key. The primary key must be an EdDSA key, whose public
+// key "wsignd keys list" prints for displays to verify files with. Where
+// the approval policy applies the display cannot enroll until it is
+// approved; otherwise it is assigned content by the auto-assignment rules
+// straight away.
+func (h *Handler) ProvisionDisplay(w http.ResponseWriter, r *http.Request) {
+	const op = "DisplayHandler.ProvisionDisplay"
+
+	if h.tokens == nil {
+		httpapi.Error(w, http.StatusNotImplemented, "provisioning is not available")
+		return
+	}
+	// Displays verify their files with the public key alone; an HS256
+	// signature could only be checked with the server's secret
+	if !h.tokens.CanSignPublicly() {
+		httpapi.Error(w, http.StatusNotImplemented,
+			"provisioning needs an EdDSA primary signing key; configure one first in WSIGN_AUTH_SIGNING_KEYS")
+		return
+	}
+
+	var req v1alpha1.DisplayProvisionRequest
+	if err := httpapi.DecodeJSON(r, &req); err != nil {
+		httpapi.WriteError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	location := display.Location{
+		SiteID:   strings.TrimSpace(req.Location.SiteID),
+		Zone:     strings.TrimSpace(req.Location.Zone),
+		Position: strings.TrimSpace(req.Location.Position),
+	}
+	if err := validateProvisionRequest(name, location); err != nil {
+		httpapi.WriteError(w, werrors.NewError("INVALID_INPUT", err.Error(), op, err), http.StatusBadRequest)
+		return
+	}
+
+	d, err := h.service.Register(r.Context(), name, location, req.Properties)
+	if err != nil {
+		h.logRequestError(r, "failed to register provisioned display", err, "name", name)
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	id := d.ID
+
+	// Until the file is written nothing has been handed out, so a failure
+	// removes the display again and the request can simply be retried
+	code, err := h.activation.IssueEnrollmentToken(r.Context(), id)
+	if err != nil {
+		h.logRequestError(r, "failed to issue enrollment token", err, "displayId", id)
+		h.unprovision(r, id, name)
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	err = h.service.Activate(r.Context(), id)
+	if err == nil {
+		d, err = h.service.Get(r.Context(), id)
+	}
+	if err != nil {
+		h.logRequestError(r, "failed to activate provisioned display", err, "displayId", id)
+		h.unprovision(r, id, name)
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	bundle, err := json.Marshal(&v1alpha1.ProvisioningBundle{
+		DisplayID: d.ID,
+		Name:      d.Name,
+		Location: v1alpha1.DisplayLocation{
+			SiteID:   d.Location.SiteID,
+			Zone:     d.Location.Zone,
+			Position: d.Location.Position,
+		},
+		Properties:      d.Properties,
+		ServerURL:       baseURL(r),
+		EnrollmentToken: code.DeviceCode,
+		IssuedAt:        code.CreatedAt,
+		ExpiresAt:       code.ExpiresAt,
+	})
+	if err != nil {
+		h.logRequestError(r, "failed to encode provisioning bundle", err, "displayId", id)
+		h.unprovision(r, id, name)
+		httpapi.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	sig := h.tokens.SignDocument(bundle)
+
+	// The display finds its content assigned when it enrolls
+	h.autoAssign(r.Context(), d)
+
+	httpapi.WriteJSON(w, http.StatusCreated, &v1alpha1.ProvisioningFile{
+		TypeMeta: v1alpha1.TypeMeta{
+			Kind:       ProvisioningFileKind,
+			APIVersion: "v1alpha1",
+		},
+		Bundle: bundle,
+		Signature: v1alpha1.ProvisioningSignature{
+			KeyID:     sig.KeyID,
+			Algorithm: string(sig.Algorithm),
+			Value:     sig.Value,
+		},
+	})
+}
+
+// unprovision removes a display whose provisioning failed. The enrollment
+// token issued for it, if any, is never handed out and expires unused.
+func (h *Handler) unprovision(r *http.Request, id uuid.UUID, name string) {
+	if err := h.service.Delete(r.Context(), id); err != nil {
+		h.logRequestError(r, "failed to remove display after failed provisioning", err,
+			"displayId", id,
+			"name", name,
+		)
+	}
+}
+
+// validateProvisionRequest checks that a provisioned display is named and
+// fully located, reporting every missing field
+func validateProvisionRequest(name string, location display.Location) error {
+	verr := &werrors.ValidationError{}
+	if name == "" {
+		verr.Required("name")
+	}
+	if location.SiteID == "" {
+		verr.Required("location.siteId")
+	}
+	if location.Zone == "" {
+		verr.Required("location.zone")
+	}
+	if location.Position == "" {
+		verr.Required("location.position")
+	}
+	return verr.Err()
+}
+
+// EnrollDisplay exchanges a provisioned display's enrollment token for an
+// access token. The token is used up by the first exchange that succeeds;
+// a display awaiting approval keeps its token and retries, as it would
+// poll in the device flow. Errors follow RFC 8628 like the device flow's.
+func (h *Handler) EnrollDisplay(w http.ResponseWriter, r *http.Request) {
+	var req v1alpha1.EnrollmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.EnrollmentToken == "" {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidRequest, "enrollmentToken is required", v1alpha1.FieldError{
+			Field:   "enrollmentToken",
+			Code:    v1alpha1.FieldRequired,
+			Message: "is required",
+		})
+		return
+	}
+
+	code, err := h.activation.CheckEnrollment(r.Context(), req.EnrollmentToken)
+	if err != nil {
+		h.writeEnrollmentError(w, err)
+		return
+	}
+
+	d, err := h.service.Get(r.Context(), code.DisplayID)
+	if err != nil {
+		h.logger.Error("failed to get provisioned display",
+			"error", err,
+			"displayId", code.DisplayID,
+		)
+		writeOAuthError(w, http.StatusInternalServerError, oauthServerError, "")
+		return
+	}
+	switch d.State {
+	case display.StatePendingApproval:
+		writeOAuthError(w, http.StatusBadRequest, oauthAuthorizationPending, "awaiting operator approval")
+		return
+	case display.StateDisabled:
+		writeOAuthError(w, http.StatusBadRequest, oauthAccessDenied, "display is disabled")
+		return
+	}
+
+	if _, err := h.activation.Enroll(r.Context(), req.EnrollmentToken); err != nil {
+		h.writeEnrollmentError(w, err)
+		return
+	}
+
+	h.writeDeviceToken(w, r, d)
+}
+
+// writeEnrollmentError writes the OAuth error for an enrollment token that
+// cannot be used
+func (h *Handler) writeEnrollmentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, activation.ErrCodeExpired):
+		writeOAuthError(w, http.StatusBadRequest, oauthExpiredToken, "enrollment token expired")
+	case errors.Is(err, activation.ErrAlreadyActivated):
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, "enrollment token already used")
+	case errors.Is(err, activation.ErrCodeNotFound):
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, "unknown enrollment token")
+	default:
+		h.logger.Error("failed to enroll display",
+			"error", err,
+		)
+		writeOAuthError(w, http.StatusInternalServerError, oauthServerError, "")
+	}
+}
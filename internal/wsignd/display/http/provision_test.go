@@ -0,0 +1,82 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
+	authmemory "github.com/wrale/wrale-signage/internal/wsignd/auth/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/activation"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+// TestProvisionRollback checks that a provisioning that fails part way
+// leaves no display behind, so that it can be retried under the same name
+func TestProvisionRollback(t *testing.T) {
+	key, err := auth.NewEd25519Key("k1", bytes.Repeat([]byte{7}, 32))
+	require.NoError(t, err)
+	keys, err := auth.NewKeyRing(key)
+	require.NoError(t, err)
+	tokens := auth.NewService(keys, authmemory.NewRepository(), time.Hour)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	service := display.NewService(memory.NewRepository(), discardPublisher{}, 10, display.Liveness{},
+		display.ApprovalPolicy{}, display.Capacity{}, logger)
+	activations := &mockActivation{}
+	handler := NewHandler(service, activations, tokens, logger)
+	assigner := &recordingAssigner{}
+	handler.SetAutoAssigner(assigner)
+	router := NewRouter(handler, ratelimit.NewMemoryService(nil), nil)
+
+	provision := func() *httptest.ResponseRecorder {
+		body, err := json.Marshal(&v1alpha1.DisplayProvisionRequest{
+			Name:     "lobby-north",
+			Location: v1alpha1.DisplayLocation{SiteID: "hq", Zone: "lobby", Position: "north"},
+		})
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1alpha1/displays/provision", bytes.NewReader(body)))
+		return rec
+	}
+
+	activations.On("IssueEnrollmentToken", mock.Anything, mock.Anything).
+		Return(nil, fmt.Errorf("token store unavailable")).Once()
+	rec := provision()
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	_, err = service.GetByName(context.Background(), "lobby-north")
+	assert.Error(t, err, "the registered display is removed")
+	assert.Empty(t, assigner.displays(), "nothing is assigned to it")
+
+	now := time.Now()
+	activations.On("IssueEnrollmentToken", mock.Anything, mock.Anything).
+		Return(&activation.DeviceCode{
+			ID:         uuid.New(),
+			Kind:       activation.KindEnrollment,
+			DeviceCode: "enrollment-token",
+			ExpiresAt:  now.Add(time.Hour),
+			CreatedAt:  now,
+		}, nil).Once()
+	rec = provision()
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	d, err := service.GetByName(context.Background(), "lobby-north")
+	require.NoError(t, err)
+	assert.Equal(t, display.StateActive, d.State)
+	assert.Equal(t, []uuid.UUID{d.ID}, assigner.displays())
+	activations.AssertExpectations(t)
+}
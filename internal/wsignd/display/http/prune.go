@@ -0,0 +1,78 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+)
+
+// PruneDisplays removes displays that have been offline for longer than
+// requested, or on a dry run lists them. Active displays are never removed.
+// Each removal is recorded in the audit log along with the operator who
+// asked for it.
+func (h *Handler) PruneDisplays(w http.ResponseWriter, r *http.Request) {
+	var req v1alpha1.DisplayPruneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpapi.Error(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	offlineFor, err := v1alpha1.ParsePeriod(req.OfflineFor)
+	if err != nil {
+		httpapi.Error(w, http.StatusBadRequest, "invalid offlineFor: "+err.Error())
+		return
+	}
+
+	result, err := h.service.PruneOffline(r.Context(), display.PruneFilter{
+		OfflineFor: offlineFor,
+		SiteID:     req.SiteID,
+	}, req.DryRun)
+	if result != nil {
+		// Displays removed before a failure are gone all the same
+		for _, d := range result.Deleted {
+			h.logger.Info("display pruned",
+				"audit", true,
+				"displayId", d.ID,
+				"displayName", d.Name,
+				"siteId", d.Location.SiteID,
+				"lastSeen", d.LastSeen,
+				"offlineFor", offlineFor,
+				"operator", operator.Name(r.Context()),
+				"remoteAddr", r.RemoteAddr,
+				"requestId", middleware.GetReqID(r.Context()),
+			)
+		}
+	}
+	if err != nil {
+		h.logRequestError(r, "failed to prune offline displays", err, "siteId", req.SiteID)
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	listed := result.Deleted
+	if req.DryRun {
+		listed = result.Matched
+	}
+	resp := &v1alpha1.DisplayPruneResult{
+		TypeMeta: v1alpha1.TypeMeta{Kind: "DisplayPruneResult", APIVersion: "v1alpha1"},
+		DryRun:   req.DryRun,
+		Matched:  len(result.Matched),
+		Deleted:  len(result.Deleted),
+		Skipped:  result.Skipped,
+		Items:    make([]v1alpha1.PrunedDisplay, 0, len(listed)),
+	}
+	for _, d := range listed {
+		resp.Items = append(resp.Items, v1alpha1.PrunedDisplay{
+			ID:       d.ID,
+			Name:     d.Name,
+			SiteID:   d.Location.SiteID,
+			LastSeen: d.LastSeen,
+		})
+	}
+	httpapi.WriteJSON(w, http.StatusOK, resp)
+}
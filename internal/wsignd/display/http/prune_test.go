@@ -0,0 +1,75 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+func TestPruneDisplays(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := memory.NewRepository()
+	service := display.NewService(repo, discardPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, logger)
+	server := httptest.NewServer(NewRouter(NewHandler(service, nil, nil, logger), ratelimit.NewMemoryService(nil), nil))
+	defer server.Close()
+
+	for name, state := range map[string]display.State{
+		"hq-gone":   display.StateOffline,
+		"hq-active": display.StateActive,
+	} {
+		d, err := display.NewDisplay(name, display.Location{SiteID: "hq", Zone: "lobby"})
+		require.NoError(t, err)
+		d.State = state
+		d.LastSeen = time.Now().Add(-60 * 24 * time.Hour)
+		require.NoError(t, repo.Save(ctx, d))
+	}
+
+	prune := func(body string) (*http.Response, v1alpha1.DisplayPruneResult) {
+		resp, err := http.Post(server.URL+"/api/v1alpha1/displays/prune", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var got v1alpha1.DisplayPruneResult
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+		}
+		return resp, got
+	}
+
+	resp, got := prune(`{"offlineFor":"30d","siteId":"hq","dryRun":true}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, got.DryRun)
+	assert.Equal(t, 1, got.Matched)
+	assert.Zero(t, got.Deleted)
+	require.Len(t, got.Items, 1)
+	assert.Equal(t, "hq-gone", got.Items[0].Name)
+
+	resp, got = prune(`{"offlineFor":"30d","siteId":"hq"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 1, got.Deleted)
+	_, err := service.GetByName(ctx, "hq-active")
+	assert.NoError(t, err, "active displays are never pruned")
+
+	for _, body := range []string{
+		`{"offlineFor":"soon"}`,
+		`{"offlineFor":"1h"}`,
+		`{}`,
+	} {
+		resp, _ := prune(body)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+)
+
+// ResyncDisplay resolves the content assigned to a display now and pushes
+// it over the display's control connection, for a display that came back
+// blank after a restart. The display may be given by ID or name. Resyncing
+// again sends the same content again and changes nothing else; the content
+// history records it once. Resyncs are recorded in the audit log along with
+// the operator who made them.
+func (h *Handler) ResyncDisplay(w http.ResponseWriter, r *http.Request) {
+	if h.content == nil {
+		httpapi.Error(w, http.StatusNotImplemented, "content resolution is not enabled")
+		return
+	}
+
+	d, err := h.lookupDisplay(r, chi.URLParam(r, "id"))
+	if err != nil {
+		h.logger.Error("failed to get display",
+			"error", err,
+			"id", chi.URLParam(r, "id"),
+		)
+		httpapi.Error(w, http.StatusNotFound, "display not found")
+		return
+	}
+
+	location := v1alpha1.DisplayLocation{
+		SiteID:   d.Location.SiteID,
+		Zone:     d.Location.Zone,
+		Position: d.Location.Position,
+	}
+	a, err := h.content.ForDisplay(r.Context(), d.ID, location, d.Properties)
+	if err != nil {
+		h.logRequestError(r, "failed to resolve display content", err, "id", d.ID)
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if a == nil {
+		httpapi.Error(w, http.StatusConflict, "no content is assigned to the display")
+		return
+	}
+
+	now := time.Now()
+	msg := &v1alpha1.ControlMessage{
+		TypeMeta: v1alpha1.TypeMeta{Kind: "ControlMessage", APIVersion: "v1alpha1"},
+		Type:     v1alpha1.ControlMessageSequenceUpdate,
+		Sequence: &v1alpha1.ContentSequence{
+			Items: []v1alpha1.ContentItem{{URL: a.ContentURL}},
+		},
+		Timestamp: now,
+	}
+	if err := h.sendControlMessage(d.ID, msg); err != nil {
+		switch {
+		case errors.Is(err, errNotConnected):
+			httpapi.Error(w, http.StatusNotFound, "display not connected")
+		case errors.Is(err, errUnsupportedMessage):
+			httpapi.Error(w, http.StatusConflict, "display does not accept sequence updates")
+		default:
+			h.logRequestError(r, "failed to resync display", err, "id", d.ID)
+			writeError(w, err, http.StatusInternalServerError)
+		}
+		return
+	}
+	h.recordContentChange(d.ID, a.ContentURL, display.TriggerResync)
+
+	h.logger.Info("display resynced",
+		"audit", true,
+		"displayId", d.ID,
+		"displayName", d.Name,
+		"assignment", a.Name,
+		"url", a.ContentURL,
+		"operator", operator.Name(r.Context()),
+		"remoteAddr", r.RemoteAddr,
+		"requestId", middleware.GetReqID(r.Context()),
+	)
+
+	httpapi.WriteJSON(w, http.StatusOK, &v1alpha1.DisplayResync{
+		TypeMeta:  v1alpha1.TypeMeta{Kind: "DisplayResync", APIVersion: "v1alpha1"},
+		DisplayID: d.ID,
+		Content:   *assignedContent(a),
+		SentAt:    now,
+	})
+}
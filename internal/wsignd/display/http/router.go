@@ -3,10 +3,27 @@ package http
 import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
 )
 
-// NewRouter creates a new HTTP router for display endpoints
-func NewRouter(h *Handler) chi.Router {
+// NewRouter creates a new HTTP router for display endpoints whose route
+// groups are each limited by the profile of the same name
+func NewRouter(h *Handler, limiter ratelimit.Service, guard operator.Guard) chi.Router {
+	return NewRouterWithLimiters(h, ratelimit.NewCommonRateLimiters(limiter, nil, h.logger), guard)
+}
+
+// NewRouterWithLimiters creates a new HTTP router for display endpoints.
+// Management routes require operator scopes from guard; a nil guard leaves
+// them open. The device flow, enrollment, the activation page and the
+// control socket are used by displays and browsers and are never guarded. /me is for
+// displays only and takes a display token instead.
+//
+// Each route group is rate limited through limiters: the device flow,
+// the control socket, state history and the remaining display management
+// routes are counted separately.
+func NewRouterWithLimiters(h *Handler, limiters *ratelimit.CommonRateLimiters, guard operator.Guard) chi.Router {
 	r := chi.NewRouter()
 
 	// Middleware
@@ -14,20 +31,82 @@ func NewRouter(h *Handler) chi.Router {
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.RequestID)
 
+	// Browser page for entering activation codes
+	r.Group(func(r chi.Router) {
+		r.Use(noCache)
+		r.Use(limiters.DeviceCode())
+		r.Get(activationPagePath, h.ActivationPage)
+		r.Post(activationPagePath, h.SubmitActivation)
+	})
+
 	// API Routes v1alpha1
+	r.With(limiters.NamedLimiter(ratelimit.LimitTypeDisplayAPI), guard.Require(operator.ScopeAdmin)).Group(func(r chi.Router) {
+		r.Get(PollingPath, h.GetPollingPolicy)
+		r.Put(PollingPath, h.UpdatePollingPolicy)
+	})
+
 	r.Route("/api/v1alpha1/displays", func(r chi.Router) {
-		// Display registration
-		r.Post("/", h.RegisterDisplay)
+		displayAPI := limiters.NamedLimiter(ratelimit.LimitTypeDisplayAPI)
+		read := chi.Chain(displayAPI, guard.Require(operator.ScopeDisplaysRead)).Handler
+		write := chi.Chain(displayAPI, guard.Require(operator.ScopeDisplaysWrite)).Handler
+
+		// Display registration and listing
+		r.With(write).Post("/", h.RegisterDisplay)
+		r.With(read).Get("/", h.ListDisplays)
+
+		// State counts and history aggregate over every display and have
+		// their own limit
+		r.Group(func(r chi.Router) {
+			r.Use(limiters.NamedLimiter(ratelimit.LimitTypeDisplayStats), guard.Require(operator.ScopeDisplaysRead))
+			r.Get("/stats", h.GetStateCounts)
+			r.Get("/stats/history", h.GetStateHistory)
+		})
+
+		// Registration ahead of installation, returning the signed file the
+		// display is set up from offline
+		r.With(noCache, write).Post("/provision", h.ProvisionDisplay)
+
+		// Bulk removal of long-offline displays
+		r.With(displayAPI, guard.Require(operator.ScopeAdmin)).Post("/prune", h.PruneDisplays)
+
+		// Device code activation flow
+		r.Group(func(r chi.Router) {
+			r.Use(noCache)
+			r.Use(limiters.DeviceCode())
+			r.Post("/device/code", h.RequestDeviceCode)
+			r.Post("/device/token", h.PollDeviceCode)
+			r.With(write).Post("/activate", h.ActivateDeviceCode)
+			r.With(read).Get("/activate/{code}", h.CheckActivationCode)
+			r.Post("/enroll", h.EnrollDisplay)
+		})
+
+		// Displays read their own record with their token
+		r.With(displayAPI, h.requireDisplayToken).Get("/me", h.GetSelf)
+		r.With(displayAPI, h.requireDisplayToken).Head("/me", h.GetSelf)
 
 		// Display management
 		r.Route("/{id}", func(r chi.Router) {
-			r.Get("/", h.GetDisplay)
-			r.Put("/activate", h.ActivateDisplay)
-			r.Put("/last-seen", h.UpdateLastSeen)
+			r.With(read).Get("/", h.GetDisplay)
+			r.With(write).Patch("/", h.PatchDisplay)
+			r.With(write).Put("/settings", h.UpdateSettings)
+			r.With(write).Put("/activate", h.ActivateDisplay)
+			r.With(displayAPI, guard.Require(operator.ScopeDisplaysApprove)).Post("/approve", h.ApproveDisplay)
+			r.With(write).Put("/last-seen", h.UpdateLastSeen)
+			// Dropping or resetting a display's control socket is an
+			// administrative action, like ending its sessions
+			r.With(displayAPI, guard.Require(operator.ScopeAdmin)).Post("/disconnect", h.DisconnectDisplay)
+			r.With(displayAPI, guard.Require(operator.ScopeAdmin)).Post("/resync", h.ResyncDisplay)
+			r.With(read).Get("/connections", h.GetConnections)
+			r.With(read).Get("/content-history", h.GetContentHistory)
+			r.With(read).Get("/sessions", h.ListSessions)
+			r.With(displayAPI, guard.Require(operator.ScopeAdmin)).Delete("/sessions/{sessionId}", h.TerminateSession)
+			r.With(read).Get("/pending-messages", h.ListPendingMessages)
+			r.With(write).Post("/pending-messages/{messageId}/replay", h.ReplayPendingMessage)
+			r.With(displayAPI, guard.Require(operator.ScopeAdmin)).Get("/debug", h.GetDisplayDebug)
 		})
 
 		// WebSocket control endpoint
-		r.Get("/ws", h.ServeWs)
+		r.With(limiters.WSConnection()).Get("/ws", h.ServeWs)
 	})
 
 	return r
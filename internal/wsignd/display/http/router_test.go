@@ -7,19 +7,27 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"log/slog"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
 )
 
 func TestRouter(t *testing.T) {
 	mockSvc := &mockService{}
+	mockSvc.On("GetByName", mock.Anything, "123").Return(nil, display.ErrNotFound{ID: "123"})
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-	handler := NewHandler(mockSvc, logger)
-	router := NewRouter(handler)
+	handler := NewHandler(mockSvc, nil, nil, logger)
+	router := NewRouter(handler, ratelimit.NewMemoryService(nil), nil)
 
 	tests := []struct {
 		name           string
@@ -37,7 +45,7 @@ func TestRouter(t *testing.T) {
 			name:           "display get endpoint exists",
 			method:         http.MethodGet,
 			path:           "/api/v1alpha1/displays/123",
-			wantStatusCode: http.StatusBadRequest, // Expect bad request due to invalid UUID
+			wantStatusCode: http.StatusNotFound, // Not a UUID, so looked up as an unknown name
 		},
 		{
 			name:           "display activate endpoint exists",
@@ -71,10 +79,55 @@ func TestRouter(t *testing.T) {
 	}
 }
 
+// scopeGuard admits requests as an operator holding scopes
+func scopeGuard(scopes ...operator.Scope) operator.Guard {
+	p := &operator.Principal{OperatorName: "test", Scopes: scopes}
+	return func(scope operator.Scope) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !p.HasScope(scope) {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(operator.WithPrincipal(r.Context(), p)))
+			})
+		}
+	}
+}
+
+// TestRouterAdminRoutes checks that routes acting on a display's
+// connection need the admin scope, not just displays:write
+func TestRouterAdminRoutes(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	id := uuid.New().String()
+	mockSvc := &mockService{}
+	mockSvc.On("Get", mock.Anything, mock.Anything).Return(nil, display.ErrNotFound{ID: id})
+
+	for _, path := range []string{
+		"/api/v1alpha1/displays/" + id + "/disconnect",
+		"/api/v1alpha1/displays/" + id + "/resync",
+	} {
+		t.Run(path, func(t *testing.T) {
+			writer := NewRouter(NewHandler(mockSvc, nil, nil, logger), ratelimit.NewMemoryService(nil),
+				scopeGuard(operator.ScopeDisplaysRead, operator.ScopeDisplaysWrite))
+			rec := httptest.NewRecorder()
+			writer.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+
+			admin := NewRouter(NewHandler(mockSvc, nil, nil, logger), ratelimit.NewMemoryService(nil),
+				scopeGuard(operator.ScopeAdmin))
+			rec = httptest.NewRecorder()
+			admin.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+			assert.NotEqual(t, http.StatusForbidden, rec.Code)
+		})
+	}
+}
+
 func TestRouterMiddleware(t *testing.T) {
 	mockSvc := &mockService{}
+	mockSvc.On("GetByName", mock.Anything, "123").Return(nil, display.ErrNotFound{ID: "123"})
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-	handler := NewHandler(mockSvc, logger)
+	handler := NewHandler(mockSvc, nil, nil, logger)
 
 	t.Run("adds request id header", func(t *testing.T) {
 		router := chi.NewRouter()
@@ -133,7 +186,7 @@ func TestRouterMiddleware(t *testing.T) {
 	})
 
 	t.Run("handles context cancellation", func(t *testing.T) {
-		router := NewRouter(handler)
+		router := NewRouter(handler, ratelimit.NewMemoryService(nil), nil)
 
 		ctx, cancel := context.WithCancel(context.Background())
 
@@ -146,8 +199,45 @@ func TestRouterMiddleware(t *testing.T) {
 
 		router.ServeHTTP(rec, req)
 
-		// Should still get bad request due to invalid UUID, context cancellation
-		// is handled gracefully
-		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		// Should still get not found for the unknown name, context
+		// cancellation is handled gracefully
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+// TestRouterRateLimitGroups checks state history and display management are
+// limited separately
+func TestRouterRateLimitGroups(t *testing.T) {
+	displayID := uuid.New()
+	mockSvc := &mockService{}
+	mockSvc.On("Get", mock.Anything, displayID).Return(&display.Display{ID: displayID, Name: "lobby", State: display.StateActive}, nil)
+	mockSvc.On("StateHistory", mock.Anything, "", mock.Anything).Return([]*display.StateSnapshot{}, nil)
+
+	limiter := ratelimit.NewMemoryService(map[string]ratelimit.Limit{
+		ratelimit.LimitTypeDisplayStats: {Rate: 60, Period: time.Hour, BurstSize: 2},
+		ratelimit.LimitTypeDisplayAPI:   {Rate: 60, Period: time.Hour, BurstSize: 4},
 	})
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	limiters := ratelimit.NewCommonRateLimiters(limiter, nil, logger)
+	router := NewRouterWithLimiters(NewHandler(mockSvc, nil, nil, logger), limiters, nil)
+	require.NoError(t, limiters.Validate())
+
+	get := func(path string) int {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+	stats := "/api/v1alpha1/displays/stats/history"
+	displayPath := "/api/v1alpha1/displays/" + displayID.String()
+
+	for i := 0; i < 2; i++ {
+		require.Equal(t, http.StatusOK, get(stats))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, get(stats))
+
+	// Display lookups have a budget of their own
+	for i := 0; i < 4; i++ {
+		require.Equal(t, http.StatusOK, get(displayPath))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, get(displayPath))
 }
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+)
+
+// SetEnricher lets display lists include each display's runtime state with
+// ?include=runtime. Without one such lists are refused. It must be called
+// before the handler serves requests.
+func (h *Handler) SetEnricher(e *display.Enricher) {
+	h.enricher = e
+}
+
+// ConnectedDisplays returns the displays holding a control connection to
+// this server, so the handler can serve as an enricher's presence
+func (h *Handler) ConnectedDisplays() map[uuid.UUID]bool {
+	return h.hub.connectedDisplays()
+}
+
+// includesRuntime reports whether a list request asked for runtime state.
+// include is a comma-separated list and runtime is the only value known.
+func includesRuntime(r *http.Request) (bool, error) {
+	runtime := false
+	for _, v := range r.URL.Query()["include"] {
+		for _, part := range strings.Split(v, ",") {
+			switch strings.TrimSpace(part) {
+			case "":
+			case v1alpha1.DisplayIncludeRuntime:
+				runtime = true
+			default:
+				return false, fmt.Errorf("unknown include %q, expected %s", part, v1alpha1.DisplayIncludeRuntime)
+			}
+		}
+	}
+	return runtime, nil
+}
+
+// withRuntime decorates the API forms of displays, in the same order, with
+// their runtime state
+func (h *Handler) withRuntime(ctx context.Context, api []*v1alpha1.Display, displays []*display.Display) error {
+	enriched, err := h.enricher.Enrich(ctx, displays)
+	if err != nil {
+		return err
+	}
+	for i, e := range enriched {
+		rt := &v1alpha1.DisplayRuntime{
+			Connected:       e.Runtime.Connected,
+			PendingMessages: e.Runtime.PendingMessages,
+		}
+		if last := e.Runtime.LastContent; last != nil {
+			at := last.Timestamp
+			rt.LastContentURL, rt.LastContentAt = last.ToURL, &at
+		}
+		api[i].Status.Runtime = rt
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/outbox"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+// historyCounter counts the bulk history queries made through it
+type historyCounter struct {
+	display.Repository
+	queries atomic.Int64
+}
+
+func (r *historyCounter) LatestContentTransitions(ctx context.Context, displayIDs []uuid.UUID) (map[uuid.UUID]*display.ContentTransition, error) {
+	r.queries.Add(1)
+	return r.Repository.LatestContentTransitions(ctx, displayIDs)
+}
+
+// TestListDisplaysRuntime checks that ?include=runtime reports what the
+// hub, the outbox and the content history hold for each display
+func TestListDisplaysRuntime(t *testing.T) {
+	ctx := context.Background()
+	repo := &historyCounter{Repository: memory.NewRepository()}
+	var displays []*display.Display
+	for _, name := range []string{"connected", "queued", "showing"} {
+		d, err := display.NewDisplay(name, display.Location{SiteID: "hq", Zone: "lobby"})
+		require.NoError(t, err)
+		d.State = display.StateActive
+		require.NoError(t, repo.Create(ctx, d, display.Limits{}))
+		displays = append(displays, d)
+	}
+	connected, queued, showing := displays[0], displays[1], displays[2]
+
+	mockSvc := &mockService{}
+	mockSvc.On("List", mock.Anything, mock.Anything).Return(displays, nil)
+	mockSvc.On("Get", mock.Anything, connected.ID).Return(connected, nil)
+	mockSvc.On("UpdateLastSeen", mock.Anything, connected.ID).Return(nil)
+	mockSvc.On("RecordContentChange", mock.Anything, connected.ID, mock.Anything, mock.Anything).Return(nil)
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandler(mockSvc, nil, nil, logger)
+	box := outbox.NewService(memory.NewOutboxRepository(), handler, time.Hour, logger)
+	handler.SetOutbox(box)
+	handler.SetEnricher(display.NewEnricher(repo, handler, box))
+	server := httptest.NewServer(NewRouter(handler, ratelimit.NewMemoryService(nil), nil))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1alpha1/displays/ws?id=" + connected.ID.String()
+	ws, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	defer ws.Close()
+	require.Eventually(t, func() bool { return handler.hub.connected(connected.ID) }, 2*time.Second, 10*time.Millisecond)
+
+	reload := &v1alpha1.ControlMessage{Type: v1alpha1.ControlMessageReload}
+	require.ErrorIs(t, box.Send(ctx, queued.ID, reload), outbox.ErrQueued)
+	require.ErrorIs(t, box.Send(ctx, queued.ID, reload), outbox.ErrQueued)
+
+	shownAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.AppendContentTransition(ctx, &display.ContentTransition{
+		DisplayID: showing.ID,
+		Timestamp: shownAt,
+		ToURL:     "https://example.com/menu",
+		Trigger:   display.TriggerAssignmentChange,
+	}, 10))
+
+	list := func(query string) (int, []*v1alpha1.Display) {
+		resp, err := http.Get(server.URL + "/api/v1alpha1/displays" + query)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return resp.StatusCode, nil
+		}
+		var list []*v1alpha1.Display
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+		return resp.StatusCode, list
+	}
+
+	t.Run("runtime state is read once for the whole list", func(t *testing.T) {
+		repo.queries.Store(0)
+		status, list := list("?include=runtime")
+		require.Equal(t, http.StatusOK, status)
+		require.Len(t, list, 3)
+		assert.Equal(t, int64(1), repo.queries.Load())
+
+		byName := make(map[string]*v1alpha1.DisplayRuntime)
+		for _, d := range list {
+			require.NotNil(t, d.Status.Runtime, d.Name)
+			byName[d.Name] = d.Status.Runtime
+		}
+		assert.Equal(t, &v1alpha1.DisplayRuntime{Connected: true}, byName["connected"])
+		assert.Equal(t, &v1alpha1.DisplayRuntime{PendingMessages: 2}, byName["queued"])
+		assert.Equal(t, "https://example.com/menu", byName["showing"].LastContentURL)
+		require.NotNil(t, byName["showing"].LastContentAt)
+		assert.True(t, shownAt.Equal(*byName["showing"].LastContentAt))
+	})
+
+	t.Run("plain lists leave runtime state out", func(t *testing.T) {
+		repo.queries.Store(0)
+		status, list := list("")
+		require.Equal(t, http.StatusOK, status)
+		for _, d := range list {
+			assert.Nil(t, d.Status.Runtime)
+		}
+		assert.Zero(t, repo.queries.Load())
+	})
+
+	t.Run("runtime timestamps follow tz", func(t *testing.T) {
+		status, list := list("?include=runtime&tz=America/New_York")
+		require.Equal(t, http.StatusOK, status)
+		for _, d := range list {
+			if at := d.Status.Runtime.LastContentAt; at != nil {
+				_, offset := at.Zone()
+				assert.Equal(t, -5*60*60, offset)
+			}
+		}
+	})
+
+	t.Run("unknown includes are rejected", func(t *testing.T) {
+		status, _ := list("?include=runtime,metrics")
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("runtime state needs an enricher", func(t *testing.T) {
+		plain := NewHandler(mockSvc, nil, nil, logger)
+		rec := httptest.NewRecorder()
+		plain.ListDisplays(rec, httptest.NewRequest(http.MethodGet, "/api/v1alpha1/displays?include=runtime", nil))
+		assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	})
+}
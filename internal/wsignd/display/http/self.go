@@ -0,0 +1,132 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
+	authhttp "github.com/wrale/wrale-signage/internal/wsignd/auth/http"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+)
+
+// ContentResolver finds the content assigned to a display
+type ContentResolver interface {
+	// ForDisplay returns the assignment in effect for the display with id
+	// at location with properties, or nil when none selects it
+	ForDisplay(ctx context.Context, id uuid.UUID, location v1alpha1.DisplayLocation, properties map[string]string) (*v1alpha1.ContentAssignment, error)
+}
+
+// SetContentResolver lets displays read the content assigned to them from
+// GetSelf and operators resend it with ResyncDisplay. Without a resolver the
+// content is left out and resyncs are refused. It must be called before the
+// handler serves requests.
+func (h *Handler) SetContentResolver(content ContentResolver) {
+	h.content = content
+}
+
+// requireDisplayToken admits requests carrying a valid display token and
+// records the display's ID in the request context. Without a token service
+// no display can authenticate, so every request is refused.
+func (h *Handler) requireDisplayToken(next http.Handler) http.Handler {
+	if h.tokens == nil {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="wsignd"`)
+			httpapi.Error(w, http.StatusUnauthorized, "display tokens are not enabled")
+		})
+	}
+	return authhttp.RequireDisplayToken(h.tokens, h.logger)(next)
+}
+
+// GetSelf returns the calling display's state, location and settings along
+// with the content assigned to it, so a display can initialize from one
+// request without knowing its own ID. The display is identified by its
+// token. The response may be cached by the display only and is revalidated
+// with its ETag. HEAD returns the same headers without the body, so a
+// display on a metered link can poll for changes for a few hundred bytes.
+func (h *Handler) GetSelf(w http.ResponseWriter, r *http.Request) {
+	displayID, ok := auth.DisplayIDFromContext(r.Context())
+	if !ok {
+		httpapi.Error(w, http.StatusUnauthorized, "display token required")
+		return
+	}
+
+	d, err := h.service.Get(r.Context(), displayID)
+	if err != nil {
+		h.logRequestError(r, "failed to get display", err, "displayId", displayID)
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	self := &v1alpha1.DisplaySelf{Display: *toAPIDisplay(d)}
+	self.Kind = "DisplaySelf"
+	self.Polling = h.pollingHints(d.Location.SiteID)
+	if h.content != nil {
+		a, err := h.content.ForDisplay(r.Context(), d.ID, self.Spec.Location, d.Properties)
+		if err != nil {
+			h.logRequestError(r, "failed to resolve display content", err, "displayId", displayID)
+			writeError(w, err, http.StatusInternalServerError)
+			return
+		}
+		if a != nil {
+			self.Content = assignedContent(a)
+		}
+	}
+	if h.notices != nil {
+		self.Maintenance = h.notices.Notice()
+	}
+
+	body, err := json.Marshal(self)
+	if err != nil {
+		h.logRequestError(r, "failed to encode display", err, "displayId", displayID)
+		httpapi.Error(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Vary", "Authorization")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body = append(body, '\n')
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write(body)
+}
+
+// assignedContent describes the content an assignment points a display at
+func assignedContent(a *v1alpha1.ContentAssignment) *v1alpha1.AssignedContent {
+	return &v1alpha1.AssignedContent{
+		URL:        a.ContentURL,
+		Assignment: a.Name,
+		Source:     a.Source,
+		ValidUntil: a.ValidUntil,
+	}
+}
+
+// etagMatches reports whether an If-None-Match header lists etag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,199 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
+	authmemory "github.com/wrale/wrale-signage/internal/wsignd/auth/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+type stubResolver struct {
+	assignment *v1alpha1.ContentAssignment
+	id         uuid.UUID
+	location   v1alpha1.DisplayLocation
+}
+
+func (s *stubResolver) ForDisplay(ctx context.Context, id uuid.UUID, location v1alpha1.DisplayLocation, properties map[string]string) (*v1alpha1.ContentAssignment, error) {
+	s.id, s.location = id, location
+	return s.assignment, nil
+}
+
+type stubNotices struct {
+	notice *v1alpha1.MaintenanceNotice
+}
+
+func (s *stubNotices) Notice() *v1alpha1.MaintenanceNotice {
+	return s.notice
+}
+
+func TestGetSelf(t *testing.T) {
+	ctx := context.Background()
+	displayID := uuid.New()
+	d := &display.Display{
+		ID:       displayID,
+		Name:     "lobby-north",
+		Location: display.Location{SiteID: "hq", Zone: "lobby", Position: "north"},
+		State:    display.StateActive,
+	}
+
+	mockSvc := &mockService{}
+	mockSvc.On("Get", mock.Anything, displayID).Return(d, nil)
+
+	material := make([]byte, 32)
+	_, err := rand.Read(material)
+	require.NoError(t, err)
+	key, err := auth.ParseKey("test", string(auth.AlgorithmHS256), base64.StdEncoding.EncodeToString(material))
+	require.NoError(t, err)
+	keys, err := auth.NewKeyRing(key)
+	require.NoError(t, err)
+	tokens := auth.NewService(keys, authmemory.NewRepository(), time.Hour)
+
+	validUntil := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	resolver := &stubResolver{assignment: &v1alpha1.ContentAssignment{
+		ObjectMeta: v1alpha1.ObjectMeta{Name: "lobby-welcome"},
+		Source:     "welcome",
+		ContentURL: "https://example.com/welcome",
+		ValidUntil: &validUntil,
+	}}
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandler(mockSvc, nil, tokens, logger)
+	handler.SetContentResolver(resolver)
+	router := NewRouter(handler, ratelimit.NewMemoryService(nil), nil)
+
+	token, _, err := tokens.IssueDisplayToken(ctx, displayID)
+	require.NoError(t, err)
+
+	request := func(method, token, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1alpha1/displays/me", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	get := func(token, etag string) *httptest.ResponseRecorder {
+		return request(http.MethodGet, token, etag)
+	}
+
+	t.Run("unauthenticated requests are rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, get("", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, get("not-a-token", "").Code)
+
+		// Without a token service no display can authenticate
+		noTokens := NewRouter(NewHandler(mockSvc, nil, nil, logger), ratelimit.NewMemoryService(nil), nil)
+		req := httptest.NewRequest(http.MethodGet, "/api/v1alpha1/displays/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		noTokens.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("returns the display and its content", func(t *testing.T) {
+		w := get(token, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+		assert.NotEmpty(t, w.Header().Get("ETag"))
+
+		var self v1alpha1.DisplaySelf
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&self))
+		assert.Equal(t, "DisplaySelf", self.Kind)
+		assert.Equal(t, "lobby-north", self.Name)
+		assert.Equal(t, v1alpha1.DisplayLocation{SiteID: "hq", Zone: "lobby", Position: "north"}, self.Spec.Location)
+		require.NotNil(t, self.Content)
+		assert.Equal(t, v1alpha1.AssignedContent{
+			URL:        "https://example.com/welcome",
+			Assignment: "lobby-welcome",
+			Source:     "welcome",
+			ValidUntil: &validUntil,
+		}, *self.Content)
+		assert.Equal(t, "hq", resolver.location.SiteID)
+		assert.Equal(t, displayID, resolver.id, "content is resolved for the display asking")
+	})
+
+	t.Run("HEAD returns the headers without the body", func(t *testing.T) {
+		full := get(token, "")
+		w := request(http.MethodHead, token, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Body.String())
+		assert.Equal(t, full.Header().Get("ETag"), w.Header().Get("ETag"))
+		assert.Equal(t, strconv.Itoa(full.Body.Len()), w.Header().Get("Content-Length"))
+		assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+
+		assert.Equal(t, http.StatusNotModified, request(http.MethodHead, token, w.Header().Get("ETag")).Code)
+		assert.Equal(t, http.StatusUnauthorized, request(http.MethodHead, "", "").Code)
+	})
+
+	t.Run("the ETag follows the assigned content", func(t *testing.T) {
+		etag := get(token, "").Header().Get("ETag")
+		assert.Equal(t, etag, get(token, "").Header().Get("ETag"), "stable while nothing changes")
+
+		previous := *resolver.assignment
+		changed := previous
+		changed.ContentURL = "https://example.com/welcome/v2"
+		resolver.assignment = &changed
+		assert.NotEqual(t, etag, get(token, "").Header().Get("ETag"))
+
+		resolver.assignment = &previous
+		assert.Equal(t, etag, get(token, "").Header().Get("ETag"), "derived from the content alone")
+	})
+
+	t.Run("maintenance notices are included", func(t *testing.T) {
+		notices := &stubNotices{}
+		handler.SetMaintenanceNotices(notices)
+		defer handler.SetMaintenanceNotices(nil)
+		etag := get(token, "").Header().Get("ETag")
+
+		notices.notice = &v1alpha1.MaintenanceNotice{Message: "Network upgrade", Until: &validUntil}
+		w := get(token, etag)
+		require.Equal(t, http.StatusOK, w.Code, "the notice changes the ETag")
+		var self v1alpha1.DisplaySelf
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&self))
+		assert.Equal(t, notices.notice, self.Maintenance)
+
+		notices.notice = nil
+		assert.Equal(t, etag, get(token, "").Header().Get("ETag"))
+	})
+
+	t.Run("unchanged responses are revalidated", func(t *testing.T) {
+		etag := get(token, "").Header().Get("ETag")
+		w := get(token, `"stale", `+etag)
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+		assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+		assert.Equal(t, "Authorization", w.Header().Get("Vary"))
+		assert.Empty(t, w.Header().Get("Content-Type"))
+
+		resolver.assignment = nil
+		w = get(token, etag)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotEqual(t, etag, w.Header().Get("ETag"))
+		var self v1alpha1.DisplaySelf
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&self))
+		assert.Nil(t, self.Content)
+	})
+}
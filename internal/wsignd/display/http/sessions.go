@@ -0,0 +1,109 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+)
+
+// sessionTerminatedReason is the close reason sent to connections whose
+// session is terminated
+const sessionTerminatedReason = "session terminated"
+
+// ListSessions lists a display's active token sessions, oldest first. The
+// display may be given by ID or name.
+func (h *Handler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	if h.tokens == nil {
+		httpapi.Error(w, http.StatusNotImplemented, "sessions are not available")
+		return
+	}
+
+	d, err := h.lookupDisplay(r, chi.URLParam(r, "id"))
+	if err != nil {
+		h.logRequestError(r, "failed to get display", err, "id", chi.URLParam(r, "id"))
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	sessions, err := h.tokens.ListSessions(r.Context(), d.ID)
+	if err != nil {
+		h.logRequestError(r, "failed to list sessions", err, "id", d.ID)
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	connections := h.hub.sessionConnections(d.ID)
+	resp := &v1alpha1.DisplaySessionList{
+		TypeMeta: v1alpha1.TypeMeta{
+			Kind:       "DisplaySessionList",
+			APIVersion: "v1alpha1",
+		},
+		DisplayID: d.ID,
+		Items:     make([]v1alpha1.DisplaySession, 0, len(sessions)),
+	}
+	for _, s := range sessions {
+		resp.Items = append(resp.Items, v1alpha1.DisplaySession{
+			ID:              s.ID,
+			KeyID:           s.KeyID,
+			IssuedAt:        s.IssuedAt,
+			LastRefreshedAt: s.LastRefreshedAt,
+			ExpiresAt:       s.ExpiresAt,
+			Connections:     connections[s.ID],
+		})
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, resp)
+}
+
+// TerminateSession revokes every token of one of a display's sessions and
+// closes the control connections that authenticated with it. Terminations
+// are recorded in the audit log along with the operator who made them.
+func (h *Handler) TerminateSession(w http.ResponseWriter, r *http.Request) {
+	if h.tokens == nil {
+		httpapi.Error(w, http.StatusNotImplemented, "sessions are not available")
+		return
+	}
+
+	d, err := h.lookupDisplay(r, chi.URLParam(r, "id"))
+	if err != nil {
+		h.logRequestError(r, "failed to get display", err, "id", chi.URLParam(r, "id"))
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	sessionID, err := uuid.Parse(chi.URLParam(r, "sessionId"))
+	if err != nil {
+		httpapi.Error(w, http.StatusBadRequest, "invalid session ID")
+		return
+	}
+
+	if err := h.tokens.TerminateSession(r.Context(), d.ID, sessionID); err != nil {
+		h.logRequestError(r, "failed to terminate session", err,
+			"id", d.ID,
+			"sessionId", sessionID,
+		)
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	closed := h.hub.DisconnectSession(d.ID, sessionID, websocket.ClosePolicyViolation, sessionTerminatedReason)
+	h.logger.Info("display session terminated",
+		"audit", true,
+		"displayId", d.ID,
+		"displayName", d.Name,
+		"sessionId", sessionID,
+		"closedConnections", closed,
+		"operator", operator.Name(r.Context()),
+		"remoteAddr", r.RemoteAddr,
+		"requestId", middleware.GetReqID(r.Context()),
+	)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,144 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
+	authmemory "github.com/wrale/wrale-signage/internal/wsignd/auth/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+func TestDisplaySessions(t *testing.T) {
+	ctx := context.Background()
+	displayID := uuid.New()
+	activeDisplay := &display.Display{
+		ID:    displayID,
+		Name:  "lobby-north",
+		State: display.StateActive,
+	}
+
+	mockSvc := &mockService{}
+	mockSvc.On("Get", mock.Anything, displayID).Return(activeDisplay, nil)
+	mockSvc.On("GetByName", mock.Anything, "lobby-north").Return(activeDisplay, nil)
+	mockSvc.On("UpdateLastSeen", mock.Anything, displayID).Return(nil)
+
+	material := make([]byte, 32)
+	_, err := rand.Read(material)
+	require.NoError(t, err)
+	key, err := auth.ParseKey("test", string(auth.AlgorithmHS256), base64.StdEncoding.EncodeToString(material))
+	require.NoError(t, err)
+	keys, err := auth.NewKeyRing(key)
+	require.NoError(t, err)
+	tokens := auth.NewService(keys, authmemory.NewRepository(), time.Hour)
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandler(mockSvc, nil, tokens, logger)
+	server := httptest.NewServer(NewRouter(handler, ratelimit.NewMemoryService(nil), nil))
+	defer server.Close()
+
+	sessionsURL := server.URL + "/api/v1alpha1/displays/lobby-north/sessions"
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1alpha1/displays/ws?id=" + displayID.String()
+
+	kept, _, err := tokens.IssueDisplayToken(ctx, displayID)
+	require.NoError(t, err)
+	ended, endedToken, err := tokens.IssueDisplayToken(ctx, displayID)
+	require.NoError(t, err)
+
+	dial := func(raw string) (*websocket.Conn, *http.Response, error) {
+		return websocket.DefaultDialer.Dial(wsURL, http.Header{"Authorization": {"Bearer " + raw}})
+	}
+	listSessions := func() v1alpha1.DisplaySessionList {
+		resp, err := http.Get(sessionsURL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var list v1alpha1.DisplaySessionList
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+		return list
+	}
+	terminate := func(sessionID string) int {
+		req, err := http.NewRequest(http.MethodDelete, sessionsURL+"/"+sessionID, nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	ws, _, err := dial(ended)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	t.Run("lists sessions with their connections", func(t *testing.T) {
+		require.Eventually(t, func() bool {
+			return handler.hub.sessionConnections(displayID)[endedToken.SessionID] == 1
+		}, time.Second, 10*time.Millisecond)
+
+		list := listSessions()
+		assert.Equal(t, displayID, list.DisplayID)
+		require.Len(t, list.Items, 2)
+		for _, s := range list.Items {
+			want := 0
+			if s.ID == endedToken.SessionID {
+				want = 1
+			}
+			assert.Equal(t, want, s.Connections, s.ID.String())
+			assert.Equal(t, "test", s.KeyID)
+		}
+	})
+
+	t.Run("terminating a session closes its socket and revokes its tokens", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, terminate(endedToken.SessionID.String()))
+
+		require.NoError(t, ws.SetReadDeadline(time.Now().Add(2*time.Second)))
+		_, _, err := ws.ReadMessage()
+		var closeErr *websocket.CloseError
+		require.ErrorAs(t, err, &closeErr)
+		assert.Equal(t, websocket.ClosePolicyViolation, closeErr.Code)
+		assert.Equal(t, sessionTerminatedReason, closeErr.Text)
+
+		_, err = tokens.ValidateToken(ctx, ended)
+		assert.Error(t, err)
+		_, err = tokens.ValidateToken(ctx, kept)
+		assert.NoError(t, err)
+
+		list := listSessions()
+		require.Len(t, list.Items, 1)
+		assert.NotEqual(t, endedToken.SessionID, list.Items[0].ID)
+	})
+
+	t.Run("unknown session returns 404", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, terminate(endedToken.SessionID.String()))
+		assert.Equal(t, http.StatusBadRequest, terminate("not-a-uuid"))
+	})
+
+	t.Run("revoked or foreign tokens cannot connect", func(t *testing.T) {
+		_, resp, err := dial(ended)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		foreign, _, err := tokens.IssueDisplayToken(ctx, uuid.New())
+		require.NoError(t, err)
+		_, resp, err = dial(foreign)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+}
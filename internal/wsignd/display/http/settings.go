@@ -0,0 +1,129 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+)
+
+// UpdateSettings replaces a display's device settings and pushes them to the
+// display if it is connected. The display may be given by ID or name.
+// Displays that are not connected are sent their settings when they next
+// connect, so nothing is queued for them.
+func (h *Handler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	var req v1alpha1.DisplaySettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpapi.Error(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	d, err := h.lookupDisplay(r, chi.URLParam(r, "id"))
+	if err != nil {
+		h.logRequestError(r, "failed to get display", err, "id", chi.URLParam(r, "id"))
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	settings := fromAPISettings(req)
+	updated, err := h.service.Patch(r.Context(), d.ID, display.Patch{Settings: &settings}, 0)
+	if err != nil {
+		h.logRequestError(r, "failed to update display settings", err, "id", d.ID)
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.pushSettings(updated)
+	httpapi.WriteJSON(w, http.StatusOK, toAPIDisplay(updated))
+}
+
+// resendSettings sends a display that has just declared its capabilities
+// its stored settings and polling hints, so a display that restarted
+// reapplies them
+func (h *Handler) resendSettings(displayID uuid.UUID) {
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+
+	d, err := h.service.Get(ctx, displayID)
+	if err != nil {
+		h.logger.Warn("failed to load display settings",
+			"error", err,
+			"displayId", displayID,
+		)
+		return
+	}
+	h.pushSettings(d)
+}
+
+// pushSettings sends a connected display its settings along with the
+// polling hints for its site. Settings that leave everything alone are left
+// out. Displays that are not connected, or whose firmware predates settings,
+// are skipped.
+func (h *Handler) pushSettings(d *display.Display) {
+	msg := &v1alpha1.ControlMessage{
+		TypeMeta: v1alpha1.TypeMeta{
+			Kind:       "ControlMessage",
+			APIVersion: v1alpha1.ControlAPIVersion,
+		},
+		Type:      v1alpha1.ControlMessageSettings,
+		Timestamp: time.Now(),
+		Polling:   h.pollingHints(d.Location.SiteID),
+	}
+	if !d.Settings.Empty() {
+		msg.Settings = toAPISettings(d.Settings)
+	}
+
+	err := h.SendControlMessage(d.ID, msg)
+	switch {
+	case err == nil:
+	case errors.Is(err, errNotConnected):
+		h.logger.Debug("display not connected, settings will be sent when it connects",
+			"displayId", d.ID,
+		)
+	case errors.Is(err, errUnsupportedMessage):
+		h.logger.Info("display does not support settings",
+			"displayId", d.ID,
+		)
+	default:
+		h.logger.Warn("failed to send display settings",
+			"error", err,
+			"displayId", d.ID,
+		)
+	}
+}
+
+// toAPISettings converts domain settings to their API representation.
+// Settings that leave everything alone are sent as an empty object.
+func toAPISettings(s display.Settings) *v1alpha1.DisplaySettings {
+	s = s.Clone()
+	settings := &v1alpha1.DisplaySettings{
+		Orientation: string(s.Orientation),
+		Brightness:  s.Brightness,
+		Volume:      s.Volume,
+	}
+	for _, w := range s.ScreenSchedule {
+		settings.ScreenSchedule = append(settings.ScreenSchedule, v1alpha1.ScreenWindow{Start: w.Start, End: w.End})
+	}
+	return settings
+}
+
+// fromAPISettings converts API settings to domain settings
+func fromAPISettings(s v1alpha1.DisplaySettings) display.Settings {
+	settings := display.Settings{
+		Orientation: display.Orientation(s.Orientation),
+		Brightness:  s.Brightness,
+		Volume:      s.Volume,
+	}
+	for _, w := range s.ScreenSchedule {
+		settings.ScreenSchedule = append(settings.ScreenSchedule, display.ScreenWindow{Start: w.Start, End: w.End})
+	}
+	return settings
+}
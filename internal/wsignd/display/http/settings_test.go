@@ -0,0 +1,149 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+func TestUpdateSettings(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	service := display.NewService(memory.NewRepository(), discardPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, logger)
+	handler := NewHandler(service, nil, nil, logger)
+	server := httptest.NewServer(NewRouter(handler, ratelimit.NewMemoryService(nil), nil))
+	defer server.Close()
+
+	d, err := service.Register(ctx, "lobby-north", display.Location{SiteID: "hq", Zone: "lobby", Position: "north"}, nil)
+	require.NoError(t, err)
+	require.NoError(t, service.Activate(ctx, d.ID))
+
+	put := func(body string) (*http.Response, v1alpha1.Display) {
+		req, err := http.NewRequest(http.MethodPut, server.URL+"/api/v1alpha1/displays/lobby-north/settings", strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var got v1alpha1.Display
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+		}
+		return resp, got
+	}
+
+	// connect dials the control socket and declares support for settings
+	connect := func() *websocket.Conn {
+		wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1alpha1/displays/ws?id=" + d.ID.String()
+		ws, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		require.NoError(t, err)
+		require.NoError(t, ws.WriteJSON(v1alpha1.ControlMessage{
+			TypeMeta: v1alpha1.TypeMeta{APIVersion: v1alpha1.ControlAPIVersion},
+			Type:     v1alpha1.ControlMessageStatus,
+			Status: &v1alpha1.ControlStatus{
+				State:        v1alpha1.DisplayStateActive,
+				Capabilities: v1alpha1.KnownControlMessageTypes(),
+			},
+		}))
+		require.Eventually(t, func() bool {
+			infos := handler.hub.connectionInfo(d.ID)
+			return len(infos) == 1 && len(infos[0].Capabilities) > 0
+		}, time.Second, 10*time.Millisecond)
+		return ws
+	}
+
+	// readSettings skips messages that only carry polling hints, such as
+	// the one sent when the display declares its capabilities
+	readSettings := func(ws *websocket.Conn) *v1alpha1.DisplaySettings {
+		require.NoError(t, ws.SetReadDeadline(time.Now().Add(2*time.Second)))
+		for {
+			_, data, err := ws.ReadMessage()
+			require.NoError(t, err)
+			var msg v1alpha1.ControlMessage
+			require.NoError(t, json.Unmarshal(data, &msg))
+			if msg.Type == v1alpha1.ControlMessageSettings && msg.Settings != nil {
+				return msg.Settings
+			}
+		}
+	}
+
+	t.Run("settings are stored and pushed", func(t *testing.T) {
+		ws := connect()
+		defer ws.Close()
+
+		resp, got := put(`{"orientation":"portrait","brightness":80,"screenSchedule":[{"start":"07:00","end":"19:00"}]}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NotNil(t, got.Spec.Settings)
+		assert.Equal(t, "portrait", got.Spec.Settings.Orientation)
+
+		pushed := readSettings(ws)
+		require.NotNil(t, pushed)
+		assert.Equal(t, "portrait", pushed.Orientation)
+		require.NotNil(t, pushed.Brightness)
+		assert.Equal(t, 80, *pushed.Brightness)
+		assert.Nil(t, pushed.Volume)
+		assert.Equal(t, []v1alpha1.ScreenWindow{{Start: "07:00", End: "19:00"}}, pushed.ScreenSchedule)
+	})
+
+	t.Run("settings are sent again on reconnect", func(t *testing.T) {
+		require.Eventually(t, func() bool { return len(handler.hub.connectionInfo(d.ID)) == 0 }, time.Second, 10*time.Millisecond)
+
+		ws := connect()
+		defer ws.Close()
+
+		pushed := readSettings(ws)
+		require.NotNil(t, pushed)
+		assert.Equal(t, "portrait", pushed.Orientation)
+	})
+
+	t.Run("out of range values are rejected", func(t *testing.T) {
+		for _, body := range []string{
+			`{"brightness":101}`,
+			`{"volume":-1}`,
+			`{"orientation":"sideways"}`,
+			`{"screenSchedule":[{"start":"7am","end":"19:00"}]}`,
+		} {
+			resp, _ := put(body)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+		}
+
+		stored, err := service.Get(ctx, d.ID)
+		require.NoError(t, err)
+		require.NotNil(t, stored.Settings.Brightness)
+		assert.Equal(t, 80, *stored.Settings.Brightness, "rejected settings leave the stored ones alone")
+	})
+
+	t.Run("displays that are not connected are updated", func(t *testing.T) {
+		require.Eventually(t, func() bool { return len(handler.hub.connectionInfo(d.ID)) == 0 }, time.Second, 10*time.Millisecond)
+
+		resp, got := put(`{"brightness":0}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NotNil(t, got.Spec.Settings)
+		assert.Empty(t, got.Spec.Settings.Orientation, "settings are replaced as a whole")
+		assert.Equal(t, 0, *got.Spec.Settings.Brightness)
+	})
+
+	t.Run("unknown display", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, server.URL+"/api/v1alpha1/displays/nowhere/settings", bytes.NewReader([]byte(`{}`)))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
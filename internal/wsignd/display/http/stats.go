@@ -0,0 +1,97 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+)
+
+// defaultStateHistoryPeriod is how far back the state history goes when no
+// since parameter is given
+const defaultStateHistoryPeriod = 7 * 24 * time.Hour
+
+// GetStateCounts counts the displays at each site by state. The site and
+// zone query parameters limit the count to matching displays.
+func (h *Handler) GetStateCounts(w http.ResponseWriter, r *http.Request) {
+	filter := display.DisplayFilter{
+		SiteID: r.URL.Query().Get("site"),
+		Zone:   r.URL.Query().Get("zone"),
+	}
+
+	counts, err := h.service.CountByState(r.Context(), filter)
+	if err != nil {
+		h.logRequestError(r, "failed to count displays by state", err, "siteId", filter.SiteID)
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	snapshots := display.Snapshots(counts, time.Now())
+	resp := &v1alpha1.DisplayStateCounts{
+		TypeMeta: v1alpha1.TypeMeta{
+			Kind:       "DisplayStateCounts",
+			APIVersion: "v1alpha1",
+		},
+		Items: make([]v1alpha1.DisplayStateSnapshot, 0, len(snapshots)),
+	}
+	for _, s := range snapshots {
+		resp.Items = append(resp.Items, toAPIStateSnapshot(s))
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, resp)
+}
+
+// GetStateHistory returns the periodic display state snapshots, oldest
+// first. The site query parameter limits them to one site and since sets
+// how far back they go, as a duration such as 7d or 12h or as an RFC 3339
+// time.
+func (h *Handler) GetStateHistory(w http.ResponseWriter, r *http.Request) {
+	siteID := r.URL.Query().Get("site")
+
+	since := time.Now().Add(-defaultStateHistoryPeriod)
+	if v := r.URL.Query().Get("since"); v != "" {
+		var err error
+		since, err = v1alpha1.ParseSince(v, time.Now())
+		if err != nil {
+			httpapi.Error(w, http.StatusBadRequest, "invalid since: "+err.Error())
+			return
+		}
+	}
+
+	snapshots, err := h.service.StateHistory(r.Context(), siteID, since)
+	if err != nil {
+		h.logRequestError(r, "failed to get state history", err, "siteId", siteID)
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := &v1alpha1.DisplayStateHistory{
+		TypeMeta: v1alpha1.TypeMeta{
+			Kind:       "DisplayStateHistory",
+			APIVersion: "v1alpha1",
+		},
+		SiteID: siteID,
+		Since:  since,
+		Items:  make([]v1alpha1.DisplayStateSnapshot, 0, len(snapshots)),
+	}
+	for _, s := range snapshots {
+		resp.Items = append(resp.Items, toAPIStateSnapshot(s))
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, resp)
+}
+
+// toAPIStateSnapshot converts a state snapshot to its API form
+func toAPIStateSnapshot(s *display.StateSnapshot) v1alpha1.DisplayStateSnapshot {
+	counts := make(map[v1alpha1.DisplayState]int, len(s.Counts))
+	for state, n := range s.Counts {
+		counts[v1alpha1.DisplayState(state)] = n
+	}
+	return v1alpha1.DisplayStateSnapshot{
+		Timestamp: s.TakenAt,
+		SiteID:    s.SiteID,
+		Counts:    counts,
+	}
+}
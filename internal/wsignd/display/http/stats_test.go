@@ -0,0 +1,84 @@
+package http
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+func TestGetStateCounts(t *testing.T) {
+	mockSvc := &mockService{}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	router := NewRouter(NewHandler(mockSvc, nil, nil, logger), ratelimit.NewMemoryService(nil), nil)
+
+	mockSvc.On("CountByState", mock.Anything, display.DisplayFilter{Zone: "lobby"}).Return([]display.StateCount{
+		{SiteID: "annex", State: display.StateActive, Count: 2},
+		{SiteID: "hq", State: display.StateActive, Count: 4},
+		{SiteID: "hq", State: display.StateOffline, Count: 1},
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1alpha1/displays/stats?zone=lobby", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var counts v1alpha1.DisplayStateCounts
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&counts))
+	assert.Equal(t, "DisplayStateCounts", counts.Kind)
+	require.Len(t, counts.Items, 2)
+	assert.Equal(t, "annex", counts.Items[0].SiteID)
+	assert.Equal(t, "hq", counts.Items[1].SiteID)
+	assert.Equal(t, map[v1alpha1.DisplayState]int{
+		v1alpha1.DisplayStateActive:  4,
+		v1alpha1.DisplayStateOffline: 1,
+	}, counts.Items[1].Counts)
+
+	mockSvc.AssertExpectations(t)
+}
+
+func TestGetStateHistory(t *testing.T) {
+	mockSvc := &mockService{}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	router := NewRouter(NewHandler(mockSvc, nil, nil, logger), ratelimit.NewMemoryService(nil), nil)
+
+	taken := time.Now().UTC().Add(-time.Hour)
+	mockSvc.On("StateHistory", mock.Anything, "hq", mock.MatchedBy(func(since time.Time) bool {
+		return time.Since(since).Round(time.Hour) == 3*24*time.Hour
+	})).Return([]*display.StateSnapshot{
+		{TakenAt: taken, SiteID: "hq", Counts: map[display.State]int{display.StateActive: 4, display.StateOffline: 1}},
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1alpha1/displays/stats/history?site=hq&since=3d", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var history v1alpha1.DisplayStateHistory
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&history))
+	assert.Equal(t, "hq", history.SiteID)
+	require.Len(t, history.Items, 1)
+	assert.True(t, taken.Equal(history.Items[0].Timestamp))
+	assert.Equal(t, map[v1alpha1.DisplayState]int{
+		v1alpha1.DisplayStateActive:  4,
+		v1alpha1.DisplayStateOffline: 1,
+	}, history.Items[0].Counts)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1alpha1/displays/stats/history?since=soon", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	mockSvc.AssertExpectations(t)
+}
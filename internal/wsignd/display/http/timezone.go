@@ -0,0 +1,55 @@
+package http
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/timezone"
+)
+
+// SetTimezones sets the time zones of sites, used for displays without a
+// zone of their own when a request asks for local time. Without them every
+// site is in UTC. It must be called before the handler serves requests.
+func (h *Handler) SetTimezones(t display.Timezones) {
+	h.timezones = t
+}
+
+// DisplayTimezone returns the time zone a display is in
+func (h *Handler) DisplayTimezone(ctx context.Context, displayID uuid.UUID) (*time.Location, error) {
+	d, err := h.service.Get(ctx, displayID)
+	if err != nil {
+		return nil, err
+	}
+	loc, _ := h.timezones.For(d)
+	return loc, nil
+}
+
+// localize gives the timestamps of api, the API form of d, in the zone tz
+// asks for and notes the zone used. A nil tz leaves api as it is.
+func (h *Handler) localize(api *v1alpha1.Display, d *display.Display, tz *timezone.Request) *v1alpha1.Display {
+	if tz == nil {
+		return api
+	}
+	loc, source := tz.Location, display.TimezoneFromRequest
+	if tz.Local {
+		loc, source = h.timezones.For(d)
+	}
+
+	api.CreatedAt = timezone.In(api.CreatedAt, loc)
+	api.UpdatedAt = timezone.In(api.UpdatedAt, loc)
+	api.Status.LastSeen = timezone.In(api.Status.LastSeen, loc)
+	if api.Status.LastError != nil {
+		api.Status.LastError.Timestamp = timezone.In(api.Status.LastError.Timestamp, loc)
+	}
+	if rt := api.Status.Runtime; rt != nil && rt.LastContentAt != nil {
+		at := timezone.In(*rt.LastContentAt, loc)
+		rt.LastContentAt = &at
+	}
+	api.Status.Timezone = loc.String()
+	api.Status.TimezoneSource = string(source)
+	return api
+}
@@ -0,0 +1,118 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+)
+
+func TestDisplayTimezones(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	require.NoError(t, err)
+
+	// New York moved to daylight saving time at 2024-03-10 07:00 UTC
+	beforeDST := time.Date(2024, 3, 10, 6, 59, 0, 0, time.UTC)
+	afterDST := time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC)
+	displays := []*display.Display{
+		{
+			ID: uuid.New(), Name: "ny-before", Location: display.Location{SiteID: "paris"},
+			Properties: map[string]string{display.TimezoneProperty: "America/New_York"},
+			LastSeen:   beforeDST, CreatedAt: beforeDST, UpdatedAt: beforeDST,
+		},
+		{
+			ID: uuid.New(), Name: "ny-after", Location: display.Location{SiteID: "paris"},
+			Properties: map[string]string{display.TimezoneProperty: "America/New_York"},
+			LastSeen:   afterDST, CreatedAt: afterDST, UpdatedAt: afterDST,
+		},
+		{ID: uuid.New(), Name: "paris", Location: display.Location{SiteID: "paris"}, LastSeen: afterDST},
+		{ID: uuid.New(), Name: "remote", Location: display.Location{SiteID: "remote"}, LastSeen: afterDST},
+		{ID: uuid.New(), Name: "unseen", Location: display.Location{SiteID: "remote"}},
+	}
+
+	mockSvc := &mockService{}
+	mockSvc.On("List", mock.Anything, display.DisplayFilter{}).Return(displays, nil)
+	handler := NewHandler(mockSvc, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	handler.SetTimezones(display.Timezones{Sites: map[string]*time.Location{"paris": paris}})
+
+	// list returns each display's raw JSON status by name
+	list := func(t *testing.T, query string) map[string]map[string]any {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.ListDisplays(rec, httptest.NewRequest(http.MethodGet, "/api/v1alpha1/displays"+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var got []struct {
+			v1alpha1.ObjectMeta `json:"metadata"`
+			Status              map[string]any `json:"status"`
+		}
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+		byName := make(map[string]map[string]any, len(got))
+		for _, d := range got {
+			byName[d.Name] = d.Status
+		}
+		return byName
+	}
+
+	t.Run("a named zone follows its daylight saving time", func(t *testing.T) {
+		got := list(t, "?tz=America/New_York")
+		assert.Equal(t, "2024-03-10T01:59:00-05:00", got["ny-before"]["lastSeen"])
+		assert.Equal(t, "2024-03-10T03:00:00-04:00", got["ny-after"]["lastSeen"])
+		assert.Equal(t, "2024-03-10T03:00:00-04:00", got["remote"]["lastSeen"])
+		assert.Equal(t, "America/New_York", got["remote"]["timezone"])
+		assert.Equal(t, string(display.TimezoneFromRequest), got["remote"]["timezoneSource"])
+		assert.Equal(t, "0001-01-01T00:00:00Z", got["unseen"]["lastSeen"], "never stays recognisable")
+	})
+
+	t.Run("local uses the display's zone, then its site's, then UTC", func(t *testing.T) {
+		got := list(t, "?tz=local")
+		assert.Equal(t, "2024-03-10T01:59:00-05:00", got["ny-before"]["lastSeen"])
+		assert.Equal(t, string(display.TimezoneFromDisplay), got["ny-before"]["timezoneSource"])
+		assert.Equal(t, "2024-03-10T08:00:00+01:00", got["paris"]["lastSeen"])
+		assert.Equal(t, "Europe/Paris", got["paris"]["timezone"])
+		assert.Equal(t, string(display.TimezoneFromSite), got["paris"]["timezoneSource"])
+		assert.Equal(t, "2024-03-10T07:00:00Z", got["remote"]["lastSeen"])
+		assert.Equal(t, "UTC", got["remote"]["timezone"])
+		assert.Equal(t, string(display.TimezoneDefault), got["remote"]["timezoneSource"])
+	})
+
+	t.Run("without tz timestamps are left alone", func(t *testing.T) {
+		got := list(t, "")
+		assert.Equal(t, "2024-03-10T07:00:00Z", got["paris"]["lastSeen"])
+		assert.NotContains(t, got["paris"], "timezone")
+	})
+
+	t.Run("unknown zones are rejected", func(t *testing.T) {
+		for _, tz := range []string{"Mars/Olympus", "Local"} {
+			rec := httptest.NewRecorder()
+			handler.ListDisplays(rec, httptest.NewRequest(http.MethodGet, "/api/v1alpha1/displays?tz="+tz, nil))
+			assert.Equal(t, http.StatusBadRequest, rec.Code, tz)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1alpha1/displays/paris?tz="+tz, nil)
+			chiCtx := chi.NewRouteContext()
+			chiCtx.URLParams.Add("id", "paris")
+			rec = httptest.NewRecorder()
+			handler.GetDisplay(rec, req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx)))
+			assert.Equal(t, http.StatusBadRequest, rec.Code, tz)
+		}
+	})
+
+	t.Run("the zone of a display can be looked up", func(t *testing.T) {
+		mockSvc.On("Get", mock.Anything, displays[0].ID).Return(displays[0], nil)
+		loc, err := handler.DisplayTimezone(context.Background(), displays[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, "America/New_York", loc.String())
+	})
+}
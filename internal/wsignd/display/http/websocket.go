@@ -3,16 +3,25 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 
 	v1alpha1 "github.com/wrale/wrale-signage/api/types/v1alpha1"
+	authhttp "github.com/wrale/wrale-signage/internal/wsignd/auth/http"
 	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
 )
 
 const (
@@ -25,26 +34,200 @@ const (
 	// Send pings to peer with this period
 	pingPeriod = (pongWait * 9) / 10
 
-	// Maximum message size allowed from peer
-	maxMessageSize = 512
+	// Minimum time between last-seen updates for a connected display
+	seenInterval = 15 * time.Second
+
+	// Messages from a peer up to this many times the size limit are
+	// dropped with an ERROR reply and the connection kept; larger ones
+	// close it with 1009 message too big
+	oversizeTolerance = 4
+
+	// Status messages waiting for the hub to fan them out
+	broadcastBufferSize = 256
+
+	// How often an idle hub loop wakes to show it is running
+	hubTickInterval = 5 * time.Second
+
+	// How long the hub loop may go without running before the server is
+	// reported as not ready
+	hubStallAfter = 30 * time.Second
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		// TODO: Implement proper origin checking
-		return true
-	},
+// newUpgrader returns the upgrader for control sockets with the given
+// frame buffer sizes
+func newUpgrader(readBufferSize, writeBufferSize int) websocket.Upgrader {
+	return websocket.Upgrader{
+		ReadBufferSize:  readBufferSize,
+		WriteBufferSize: writeBufferSize,
+		CheckOrigin: func(r *http.Request) bool {
+			// TODO: Implement proper origin checking
+			return true
+		},
+	}
 }
 
+// errNotConnected is returned when a display has no open connection
+var errNotConnected = display.ErrNotConnected
+
+// errUnsupportedMessage is returned when a display's connections do not
+// accept a message type
+var errUnsupportedMessage = errors.New("message type not supported by display")
+
 // connection is an middleman between the websocket connection and the hub
 type connection struct {
 	displayID uuid.UUID
-	ws        *websocket.Conn
-	send      chan []byte
-	hub       *Hub
-	logger    *slog.Logger
+	// siteID is the display's site when it connected, used to pick its
+	// polling hints
+	siteID string
+	ws     *websocket.Conn
+	send   chan []byte
+	hub    *Hub
+	logger *slog.Logger
+
+	// onStatus is called with each status report from the display
+	onStatus func(displayID uuid.UUID, status *v1alpha1.ControlStatus)
+
+	// onSeen is called when the peer shows it is alive, at most once per
+	// seenInterval. lastSeen is only touched by the read pump.
+	onSeen   func(displayID uuid.UUID)
+	lastSeen time.Time
+
+	// inboundLimitedUntil is when the display was told its inbound limit
+	// allows messages again. It is only touched by the read pump.
+	inboundLimitedUntil time.Time
+
+	// onCapabilities is run in its own goroutine the first time the peer
+	// declares its capabilities
+	onCapabilities func(displayID uuid.UUID)
+
+	// closeReq carries a close frame payload that the write pump sends after
+	// flushing any queued messages
+	closeReq chan []byte
+
+	// remoteAddr and connectedAt describe the connection to operators
+	remoteAddr  string
+	connectedAt time.Time
+
+	// sessionID is the token session the display authenticated with, or
+	// uuid.Nil when it connected without a token
+	sessionID uuid.UUID
+
+	// sendMu serialises enqueue so that evicting the oldest message and
+	// queueing its replacement cannot interleave with another sender
+	sendMu sync.Mutex
+	// dropped counts messages evicted from a full send queue;
+	// consecutiveDrops resets whenever a message is queued without eviction
+	dropped          atomic.Uint64
+	consecutiveDrops atomic.Uint64
+
+	// unknownMessages counts messages from the peer that were ignored
+	// because their type or version is not understood
+	unknownMessages atomic.Uint64
+
+	// capabilities are the message types the peer declared in a status
+	// report, nil until it declares any; declared is set by the first
+	// report that carries them
+	capMu        sync.RWMutex
+	capabilities []v1alpha1.ControlMessageType
+	declared     bool
+}
+
+// setCapabilities records the message types the peer says it handles. It
+// reports whether this is the first declaration of the connection.
+func (c *connection) setCapabilities(types []v1alpha1.ControlMessageType) bool {
+	c.capMu.Lock()
+	defer c.capMu.Unlock()
+	first := !c.declared
+	c.declared = true
+	c.capabilities = append([]v1alpha1.ControlMessageType(nil), types...)
+	return first
+}
+
+// accepts reports whether the peer can handle messages of type t. Peers
+// that declared nothing are assumed to handle the baseline types.
+func (c *connection) accepts(t v1alpha1.ControlMessageType) bool {
+	c.capMu.RLock()
+	declared := c.capabilities
+	c.capMu.RUnlock()
+
+	if declared == nil {
+		declared = v1alpha1.BaselineCapabilities()
+	}
+	for _, d := range declared {
+		if d == t {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueue queues a message for the peer. When the queue is full the oldest
+// queued message is evicted to make room, so a display that falls behind
+// still receives the most recent state once it catches up. It returns the
+// number of consecutive evictions, zero if nothing was dropped.
+//
+// Callers must hold the hub's lock so the send channel cannot be closed
+// underneath them.
+func (c *connection) enqueue(message []byte) uint64 {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	select {
+	case c.send <- message:
+		c.consecutiveDrops.Store(0)
+		return 0
+	default:
+	}
+
+	select {
+	case <-c.send:
+	default:
+	}
+	// Only enqueue writes to send, so the eviction above made room
+	c.send <- message
+
+	dropped := c.dropped.Add(1)
+	drops := c.consecutiveDrops.Add(1)
+	c.logger.Warn("send queue full, dropped oldest message",
+		"displayId", c.displayID,
+		"dropped", dropped,
+		"consecutiveDrops", drops,
+	)
+	return drops
+}
+
+// discardQueued empties the send queue without writing to the peer
+func (c *connection) discardQueued() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	for {
+		select {
+		case <-c.send:
+		default:
+			return
+		}
+	}
+}
+
+// info reports the connection's queue state
+func (c *connection) info() v1alpha1.ConnectionInfo {
+	info := v1alpha1.ConnectionInfo{
+		ConnectedAt:      c.connectedAt,
+		RemoteAddr:       c.remoteAddr,
+		QueueDepth:       len(c.send),
+		QueueCapacity:    cap(c.send),
+		DroppedFrames:    c.dropped.Load(),
+		ConsecutiveDrops: c.consecutiveDrops.Load(),
+		UnknownMessages:  c.unknownMessages.Load(),
+	}
+	c.capMu.RLock()
+	info.Capabilities = c.capabilities
+	c.capMu.RUnlock()
+	if c.sessionID != uuid.Nil {
+		sessionID := c.sessionID
+		info.SessionID = &sessionID
+	}
+	return info
 }
 
 // cleanup handles proper connection closure and cleanup
@@ -61,10 +244,73 @@ func (c *connection) cleanup() {
 	}
 }
 
+// noticeInboundLimit tells the peer that its messages are being dropped
+// and when to send again, at most once per limit window
+func (c *connection) noticeInboundLimit(status *ratelimit.LimitStatus) {
+	now := time.Now()
+	if status == nil || now.Before(c.inboundLimitedUntil) || !c.accepts(v1alpha1.ControlMessageRateLimited) {
+		return
+	}
+	c.inboundLimitedUntil = now.Add(status.RetryAfter)
+
+	data, err := json.Marshal(rateLimitedMessage(v1alpha1.RateLimitInbound, status.RetryAfter))
+	if err != nil {
+		c.logger.Error("failed to marshal rate limit notice", "error", err)
+		return
+	}
+	c.hub.mu.RLock()
+	defer c.hub.mu.RUnlock()
+	if c.hub.connections[c] {
+		c.enqueue(data)
+	}
+}
+
+// rejectOversized tells the peer that a message of size bytes was dropped
+// for exceeding the inbound size limit. Peers that predate ERROR messages
+// are not told.
+func (c *connection) rejectOversized(size int) {
+	if !c.accepts(v1alpha1.ControlMessageError) {
+		return
+	}
+	data, err := json.Marshal(&v1alpha1.ControlMessage{
+		TypeMeta:  v1alpha1.TypeMeta{Kind: "ControlMessage", APIVersion: v1alpha1.ControlAPIVersion},
+		Type:      v1alpha1.ControlMessageError,
+		Timestamp: time.Now(),
+		Error: &v1alpha1.ControlError{
+			Code:    v1alpha1.ControlErrorMessageTooLarge,
+			Message: fmt.Sprintf("message of %d bytes dropped, more than the limit of %d", size, c.hub.maxMessageSize),
+			Limit:   c.hub.maxMessageSize,
+		},
+	})
+	if err != nil {
+		c.logger.Error("failed to marshal message size error", "error", err)
+		return
+	}
+	c.hub.mu.RLock()
+	defer c.hub.mu.RUnlock()
+	if c.hub.connections[c] {
+		c.enqueue(data)
+	}
+}
+
+// reportSeen tells the display service the peer is alive unless it was told
+// recently
+func (c *connection) reportSeen() {
+	if c.onSeen == nil || time.Since(c.lastSeen) < seenInterval {
+		return
+	}
+	c.lastSeen = time.Now()
+	c.onSeen(c.displayID)
+}
+
 func (c *connection) readPump() {
 	defer c.cleanup()
 
-	c.ws.SetReadLimit(maxMessageSize)
+	c.reportSeen()
+
+	// Messages somewhat over the limit are read so that the peer can be
+	// told and the connection kept
+	c.ws.SetReadLimit(int64(c.hub.maxMessageSize) * oversizeTolerance)
 	if err := c.ws.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
 		c.logger.Error("failed to set read deadline",
 			"error", err,
@@ -74,6 +320,7 @@ func (c *connection) readPump() {
 	}
 
 	c.ws.SetPongHandler(func(string) error {
+		c.reportSeen()
 		if err := c.ws.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
 			c.logger.Error("failed to set read deadline in pong handler",
 				"error", err,
@@ -96,6 +343,27 @@ func (c *connection) readPump() {
 			break
 		}
 
+		// A display flooding the socket has its excess messages dropped;
+		// the connection stays up so later status reports still arrive. The
+		// display is told once per window so it can resend its status.
+		if status, err := c.hub.allowMessage(ratelimit.LimitTypeWSMessageIn, c.displayID); err != nil {
+			c.logger.Warn("inbound message rate exceeded, dropped message",
+				"displayId", c.displayID,
+			)
+			c.noticeInboundLimit(status)
+			continue
+		}
+
+		if len(message) > c.hub.maxMessageSize {
+			c.logger.Warn("inbound message too large, dropped message",
+				"size", len(message),
+				"limit", c.hub.maxMessageSize,
+				"displayId", c.displayID,
+			)
+			c.rejectOversized(len(message))
+			continue
+		}
+
 		var status v1alpha1.ControlMessage
 		if err := json.Unmarshal(message, &status); err != nil {
 			c.logger.Error("invalid status message",
@@ -105,6 +373,18 @@ func (c *connection) readPump() {
 			continue
 		}
 
+		// Newer firmware may send types this server predates; they are
+		// expected, so note them quietly rather than as errors
+		if !status.Understood() {
+			c.logger.Debug("ignored unknown message",
+				"type", status.Type,
+				"apiVersion", status.APIVersion,
+				"displayId", c.displayID,
+				"unknownMessages", c.unknownMessages.Add(1),
+			)
+			continue
+		}
+
 		if status.Type != v1alpha1.ControlMessageStatus {
 			c.logger.Error("unexpected message type",
 				"type", status.Type,
@@ -114,7 +394,16 @@ func (c *connection) readPump() {
 		}
 
 		// Process display status update
-		c.hub.broadcast <- message
+		c.reportSeen()
+		if status.Status != nil && status.Status.Capabilities != nil {
+			if c.setCapabilities(status.Status.Capabilities) && c.onCapabilities != nil {
+				go c.onCapabilities(c.displayID)
+			}
+		}
+		if status.Status != nil && c.onStatus != nil {
+			c.onStatus(c.displayID, status.Status)
+		}
+		c.hub.publish(message)
 	}
 }
 
@@ -143,6 +432,15 @@ func (c *connection) writePump() {
 
 	for {
 		select {
+		case payload := <-c.closeReq:
+			c.flush()
+			if err := c.write(websocket.CloseMessage, payload); err != nil {
+				c.logger.Error("failed to write close message",
+					"error", err,
+					"displayId", c.displayID,
+				)
+			}
+			return
 		case message, ok := <-c.send:
 			if !ok {
 				if err := c.write(websocket.CloseMessage, []byte{}); err != nil {
@@ -172,9 +470,32 @@ func (c *connection) writePump() {
 	}
 }
 
+// flush writes any messages already queued for the peer
+func (c *connection) flush() {
+	for {
+		select {
+		case message, ok := <-c.send:
+			if !ok {
+				return
+			}
+			if err := c.write(websocket.TextMessage, message); err != nil {
+				c.logger.Error("failed to write message",
+					"error", err,
+					"displayId", c.displayID,
+				)
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
 // Hub maintains the set of active connections and broadcasts messages
 type Hub struct {
-	// Registered connections
+	// Registered connections, guarded by mu since handlers look up
+	// connections outside the run loop
+	mu          sync.RWMutex
 	connections map[*connection]bool
 
 	// Register requests from the connections
@@ -186,51 +507,370 @@ type Hub struct {
 	// Inbound messages from the connections
 	broadcast chan []byte
 
+	// droppedBroadcasts counts inbound messages discarded because the
+	// broadcast buffer was full
+	droppedBroadcasts atomic.Uint64
+
+	// sendQueueSize is the capacity of each connection's send queue
+	sendQueueSize int
+
+	// upgrader accepts control sockets with the configured buffer sizes
+	upgrader websocket.Upgrader
+
+	// maxMessageSize bounds messages from displays and
+	// maxOutboundMessageSize control messages to them, in bytes
+	maxMessageSize         int
+	maxOutboundMessageSize int
+
+	// maxConsecutiveDrops is how many messages in a row a connection may
+	// lose before it is closed
+	maxConsecutiveDrops uint64
+
+	// reconnectSpread is the window drained displays are asked to spread
+	// their reconnects over
+	reconnectSpread time.Duration
+
+	// limiter bounds the message rate in each direction per display
+	limiter ratelimit.Service
+
+	// held keeps messages over a display's outbound rate until the limit
+	// allows them. heldMu is taken before mu when both are needed.
+	heldMu sync.Mutex
+	held   map[uuid.UUID]*heldQueue
+
+	// onRegister, when set, is run in its own goroutine once a new
+	// connection can be sent messages
+	onRegister func(displayID uuid.UUID)
+
+	// lastTick is when the run loop last ran, in Unix nanoseconds. It is
+	// zero until the loop starts. A loop that has not run within stallAfter
+	// is reported as stalled.
+	lastTick     atomic.Int64
+	tickInterval time.Duration
+	stallAfter   time.Duration
+
+	// restarts counts run loops restarted after a panic
+	restarts atomic.Uint64
+
 	// Logger instance
 	logger *slog.Logger
 }
 
-func newHub(logger *slog.Logger) *Hub {
+// HubConfig bounds how far a display may fall behind before the hub gives
+// up on its connection
+type HubConfig struct {
+	// SendQueueSize is how many messages may wait to be written to each
+	// connection
+	SendQueueSize int
+	// MaxConsecutiveDrops is how many messages in a row may be evicted from
+	// a full send queue before the connection is closed
+	MaxConsecutiveDrops int
+	// Limiter applies the ws_message_in and ws_message_out limits. When nil
+	// the default limits are used.
+	Limiter ratelimit.Service
+	// ReadBufferSize and WriteBufferSize are the frame buffers of each
+	// connection. Messages larger than the write buffer are sent as
+	// several frames.
+	ReadBufferSize  int
+	WriteBufferSize int
+	// MaxMessageSize is the largest message in bytes accepted from a
+	// display, after reassembling fragmented frames
+	MaxMessageSize int
+	// MaxOutboundMessageSize is the largest control message in bytes sent
+	// to a display; larger ones are refused
+	MaxOutboundMessageSize int
+	// ReconnectSpread is the window displays are asked to spread their
+	// reconnects over when their connections are drained. Zero lets them
+	// reconnect at once.
+	ReconnectSpread time.Duration
+}
+
+// DefaultHubConfig returns the queue limits used when none are configured
+func DefaultHubConfig() HubConfig {
+	return HubConfig{
+		SendQueueSize:          256,
+		MaxConsecutiveDrops:    64,
+		ReadBufferSize:         4 * 1024,
+		WriteBufferSize:        4 * 1024,
+		MaxMessageSize:         32 * 1024,
+		MaxOutboundMessageSize: 256 * 1024,
+	}
+}
+
+func newHub(cfg HubConfig, logger *slog.Logger) *Hub {
+	defaults := DefaultHubConfig()
+	if cfg.SendQueueSize < 1 {
+		cfg.SendQueueSize = defaults.SendQueueSize
+	}
+	if cfg.MaxConsecutiveDrops < 1 {
+		cfg.MaxConsecutiveDrops = defaults.MaxConsecutiveDrops
+	}
+	if cfg.Limiter == nil {
+		cfg.Limiter = ratelimit.NewMemoryService(ratelimit.DefaultLimits())
+	}
+	if cfg.ReadBufferSize < 1 {
+		cfg.ReadBufferSize = defaults.ReadBufferSize
+	}
+	if cfg.WriteBufferSize < 1 {
+		cfg.WriteBufferSize = defaults.WriteBufferSize
+	}
+	if cfg.MaxMessageSize < 1 {
+		cfg.MaxMessageSize = defaults.MaxMessageSize
+	}
+	if cfg.MaxOutboundMessageSize < 1 {
+		cfg.MaxOutboundMessageSize = defaults.MaxOutboundMessageSize
+	}
+
 	return &Hub{
-		broadcast:   make(chan []byte),
-		register:    make(chan *connection),
-		unregister:  make(chan *connection),
-		connections: make(map[*connection]bool),
-		logger:      logger,
+		broadcast:              make(chan []byte, broadcastBufferSize),
+		register:               make(chan *connection),
+		unregister:             make(chan *connection),
+		connections:            make(map[*connection]bool),
+		sendQueueSize:          cfg.SendQueueSize,
+		maxConsecutiveDrops:    uint64(cfg.MaxConsecutiveDrops),
+		upgrader:               newUpgrader(cfg.ReadBufferSize, cfg.WriteBufferSize),
+		maxMessageSize:         cfg.MaxMessageSize,
+		maxOutboundMessageSize: cfg.MaxOutboundMessageSize,
+		reconnectSpread:        cfg.ReconnectSpread,
+		limiter:                cfg.Limiter,
+		held:                   make(map[uuid.UUID]*heldQueue),
+		tickInterval:           hubTickInterval,
+		stallAfter:             hubStallAfter,
+		logger:                 logger,
 	}
 }
 
+// allowMessage counts a message to or from a display against limitType
+func (h *Hub) allowMessage(limitType string, displayID uuid.UUID) (*ratelimit.LimitStatus, error) {
+	// Socket pumps have no request context, so the lookup gets its own
+	// deadline
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+	return h.limiter.Allow(ctx, ratelimit.LimitKey{
+		Type: limitType,
+		Key:  displayID.String(),
+	})
+}
+
+// publish hands an inbound message to the run loop for fan out. A full
+// broadcast buffer drops the message rather than stalling the reader.
+func (h *Hub) publish(message []byte) {
+	select {
+	case h.broadcast <- message:
+	default:
+		h.logger.Warn("broadcast buffer full, dropped message",
+			"dropped", h.droppedBroadcasts.Add(1),
+		)
+	}
+}
+
+// run services the hub's channels until ctx is done. A panic while handling
+// an event is logged and counted and the loop restarts, so that one bad
+// event does not leave connections that are never serviced.
 func (h *Hub) run(ctx context.Context) {
+	for !h.loop(ctx) {
+	}
+}
+
+// loop is one run of the hub loop. It returns true when ctx is done and
+// false when it stopped on a panic.
+func (h *Hub) loop(ctx context.Context) (done bool) {
+	defer func() {
+		if p := recover(); p != nil {
+			h.logger.Error("hub loop panicked, restarting",
+				"panic", p,
+				"restarts", h.restarts.Add(1),
+				"stack", string(debug.Stack()),
+			)
+		}
+	}()
+
+	ticker := time.NewTicker(h.tickInterval)
+	defer ticker.Stop()
+
 	for {
+		h.lastTick.Store(time.Now().UnixNano())
+
 		select {
 		case <-ctx.Done():
-			return
+			return true
+		case <-ticker.C:
 		case c := <-h.register:
-			h.connections[c] = true
+			count := h.add(c)
 			h.logger.Info("display connected",
 				"displayId", c.displayID,
-				"connections", len(h.connections),
+				"connections", count,
 			)
+			if h.onRegister != nil {
+				go h.onRegister(c.displayID)
+			}
 		case c := <-h.unregister:
-			if _, ok := h.connections[c]; ok {
-				delete(h.connections, c)
-				close(c.send)
+			if ok, count := h.remove(c); ok {
 				h.logger.Info("display disconnected",
 					"displayId", c.displayID,
-					"connections", len(h.connections),
+					"connections", count,
 				)
 			}
 		case m := <-h.broadcast:
-			for c := range h.connections {
-				select {
-				case c.send <- m:
-				default:
-					close(c.send)
-					delete(h.connections, c)
-				}
-			}
+			// Stalled connections are closed once fan out is complete so
+			// the map is never modified while it is being ranged over
+			h.evict(h.fanOut(m))
+		}
+	}
+}
+
+// add registers a connection and returns how many are open
+func (h *Hub) add(c *connection) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.connections[c] = true
+	return len(h.connections)
+}
+
+// remove unregisters a connection and closes its send queue. It reports
+// whether the connection was still registered and how many remain open.
+func (h *Hub) remove(c *connection) (bool, int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, ok := h.connections[c]
+	if ok {
+		delete(h.connections, c)
+		close(c.send)
+	}
+	return ok, len(h.connections)
+}
+
+// fanOut queues a status report for every connection that accepts them and
+// returns the connections that have stopped reading
+func (h *Hub) fanOut(m []byte) []*connection {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var stalled []*connection
+	for c := range h.connections {
+		// Only status reports are fanned out
+		if !c.accepts(v1alpha1.ControlMessageStatus) {
+			continue
+		}
+		if c.enqueue(m) >= h.maxConsecutiveDrops {
+			stalled = append(stalled, c)
+		}
+	}
+	return stalled
+}
+
+// ready returns an error unless the run loop has run within stallAfter of
+// now
+func (h *Hub) ready(now time.Time) error {
+	last := h.lastTick.Load()
+	if last == 0 {
+		return errors.New("hub is not running")
+	}
+	if idle := now.Sub(time.Unix(0, last)); idle > h.stallAfter {
+		return fmt.Errorf("hub has not run for %s (%d restarts)", idle.Truncate(time.Second), h.restarts.Load())
+	}
+	return nil
+}
+
+// evict unregisters connections whose peers have stopped reading and asks
+// their write pumps to close them. Queued messages are discarded since the
+// peer is not consuming them; the display is expected to reconnect.
+func (h *Hub) evict(conns []*connection) {
+	if len(conns) == 0 {
+		return
+	}
+
+	payload := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "send queue overflow")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, c := range conns {
+		if _, ok := h.connections[c]; !ok {
+			continue
+		}
+		delete(h.connections, c)
+
+		c.discardQueued()
+		select {
+		case c.closeReq <- payload:
+		default:
+			// A close is already pending for this connection
+		}
+
+		h.logger.Warn("closing connection to slow display",
+			"displayId", c.displayID,
+			"dropped", c.dropped.Load(),
+			"consecutiveDrops", c.consecutiveDrops.Load(),
+			"connections", len(h.connections),
+		)
+	}
+}
+
+// connectionInfo describes the open connections held by a display, oldest
+// first
+func (h *Hub) connectionInfo(displayID uuid.UUID) []v1alpha1.ConnectionInfo {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	infos := []v1alpha1.ConnectionInfo{}
+	for c := range h.connections {
+		if c.displayID == displayID {
+			infos = append(infos, c.info())
 		}
 	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ConnectedAt.Before(infos[j].ConnectedAt)
+	})
+	return infos
+}
+
+// connected reports whether a display holds a connection to this server
+func (h *Hub) connected(displayID uuid.UUID) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for c := range h.connections {
+		if c.displayID == displayID {
+			return true
+		}
+	}
+	return false
+}
+
+// connectedDisplays returns the displays holding a connection to this
+// server, taken under one lock however many there are
+func (h *Hub) connectedDisplays() map[uuid.UUID]bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	displays := make(map[uuid.UUID]bool, len(h.connections))
+	for c := range h.connections {
+		displays[c.displayID] = true
+	}
+	return displays
+}
+
+// DisconnectDisplay closes every connection held by a display with the given
+// close code and reason. Messages already queued for the display are
+// delivered before the close frame. It returns errNotConnected if the display
+// has no open connection.
+func (h *Hub) DisconnectDisplay(displayID uuid.UUID, code int, reason string) error {
+	closed := h.closeMatching(code, reason, func(c *connection) bool {
+		return c.displayID == displayID
+	})
+	if closed == 0 {
+		return errNotConnected
+	}
+
+	h.logger.Info("disconnecting display",
+		"displayId", displayID,
+		"code", code,
+		"reason", reason,
+	)
+	return nil
 }
 
 // convert converts between domain and API display states
@@ -249,11 +889,143 @@ func convert(s display.State) v1alpha1.DisplayState {
 	}
 }
 
+// DisconnectSession closes a display's connections that authenticated with
+// the given token session and returns how many were closed
+func (h *Hub) DisconnectSession(displayID, sessionID uuid.UUID, code int, reason string) int {
+	closed := h.closeMatching(code, reason, func(c *connection) bool {
+		return c.displayID == displayID && c.sessionID == sessionID
+	})
+	if closed > 0 {
+		h.logger.Info("disconnecting display session",
+			"displayId", displayID,
+			"sessionId", sessionID,
+			"connections", closed,
+		)
+	}
+	return closed
+}
+
+// drainPollInterval is how often DrainConnections checks whether the
+// connections it closed have unregistered
+const drainPollInterval = 10 * time.Millisecond
+
+// DrainConnections closes every control socket with 1001 going away, after
+// its queued messages, and waits until they have unregistered or ctx is
+// done. It returns how many connections were asked to close and how many
+// were still open when it returned. Displays reconnect on their own, to
+// another server if this one is going away; those that understand RECONNECT
+// are first asked to spread their reconnects over the configured window.
+func (h *Handler) DrainConnections(ctx context.Context) (closed, remaining int) {
+	closed = h.hub.drain("server shutting down")
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		if remaining = h.hub.count(); remaining == 0 {
+			return closed, 0
+		}
+		select {
+		case <-ctx.Done():
+			return closed, remaining
+		case <-ticker.C:
+		}
+	}
+}
+
+// displaysAccepting returns the site of each connected display that accepts
+// messages of msgType, keyed by display ID
+func (h *Hub) displaysAccepting(msgType v1alpha1.ControlMessageType) map[uuid.UUID]string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	displays := make(map[uuid.UUID]string)
+	for c := range h.connections {
+		if c.accepts(msgType) {
+			displays[c.displayID] = c.siteID
+		}
+	}
+	return displays
+}
+
+// count returns how many connections are open
+func (h *Hub) count() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.connections)
+}
+
+// sessionConnections counts a display's open connections per token session
+func (h *Hub) sessionConnections(displayID uuid.UUID) map[uuid.UUID]int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	counts := make(map[uuid.UUID]int)
+	for c := range h.connections {
+		if c.displayID == displayID && c.sessionID != uuid.Nil {
+			counts[c.sessionID]++
+		}
+	}
+	return counts
+}
+
+// closeMatching asks every matching connection to close after flushing its
+// queue and returns how many matched
+func (h *Hub) closeMatching(code int, reason string, match func(*connection) bool) int {
+	payload := websocket.FormatCloseMessage(code, reason)
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	n := 0
+	for c := range h.connections {
+		if !match(c) {
+			continue
+		}
+		n++
+		select {
+		case c.closeReq <- payload:
+		default:
+			// A close is already pending for this connection
+		}
+	}
+	return n
+}
+
+// drain tells every connection that accepts it when to reconnect, then asks
+// every connection to close with 1001 going away. It returns how many were
+// asked to close.
+func (h *Hub) drain(reason string) int {
+	notice, err := json.Marshal(&v1alpha1.ControlMessage{
+		TypeMeta:  v1alpha1.TypeMeta{Kind: "ControlMessage", APIVersion: v1alpha1.ControlAPIVersion},
+		Type:      v1alpha1.ControlMessageReconnect,
+		Timestamp: time.Now(),
+		Reconnect: &v1alpha1.ControlReconnect{
+			Spread: v1alpha1.Milliseconds(h.reconnectSpread.Milliseconds()),
+			Reason: reason,
+		},
+	})
+	if err != nil {
+		h.logger.Error("failed to marshal reconnect notice", "error", err)
+	}
+
+	h.mu.RLock()
+	for c := range h.connections {
+		if notice != nil && c.accepts(v1alpha1.ControlMessageReconnect) {
+			c.enqueue(notice)
+		}
+	}
+	h.mu.RUnlock()
+
+	return h.closeMatching(websocket.CloseGoingAway, reason, func(*connection) bool {
+		return true
+	})
+}
+
 // ServeWs handles websocket requests from displays
 func (h *Handler) ServeWs(w http.ResponseWriter, r *http.Request) {
 	displayID, err := uuid.Parse(r.URL.Query().Get("id"))
 	if err != nil {
-		http.Error(w, "missing or invalid display ID", http.StatusBadRequest)
+		httpapi.Error(w, http.StatusBadRequest, "missing or invalid display ID")
 		return
 	}
 
@@ -264,16 +1036,21 @@ func (h *Handler) ServeWs(w http.ResponseWriter, r *http.Request) {
 			"error", err,
 			"displayId", displayID,
 		)
-		http.Error(w, fmt.Sprintf("display not found: %s", displayID), http.StatusNotFound)
+		httpapi.Error(w, http.StatusNotFound, fmt.Sprintf("display not found: %s", displayID))
 		return
 	}
 
 	if convert(d.State) != v1alpha1.DisplayStateActive {
-		http.Error(w, "display not active", http.StatusForbidden)
+		httpapi.Error(w, http.StatusForbidden, "display not active")
+		return
+	}
+
+	sessionID, ok := h.connectionSession(w, r, displayID)
+	if !ok {
 		return
 	}
 
-	ws, err := upgrader.Upgrade(w, r, nil)
+	ws, err := h.hub.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		h.logger.Error("websocket upgrade failed",
 			"error", err,
@@ -283,11 +1060,20 @@ func (h *Handler) ServeWs(w http.ResponseWriter, r *http.Request) {
 	}
 
 	c := &connection{
-		displayID: displayID,
-		send:      make(chan []byte, 256),
-		ws:        ws,
-		hub:       h.hub,
-		logger:    h.logger,
+		displayID:   displayID,
+		siteID:      d.Location.SiteID,
+		send:        make(chan []byte, h.hub.sendQueueSize),
+		ws:          ws,
+		hub:         h.hub,
+		logger:      h.logger,
+		closeReq:    make(chan []byte, 1),
+		onStatus:    h.handleStatus,
+		onSeen:      h.markSeen,
+		remoteAddr:  r.RemoteAddr,
+		connectedAt: time.Now(),
+		sessionID:   sessionID,
+
+		onCapabilities: h.resendSettings,
 	}
 
 	c.hub.register <- c
@@ -296,24 +1082,184 @@ func (h *Handler) ServeWs(w http.ResponseWriter, r *http.Request) {
 	c.readPump()
 }
 
-// SendControlMessage sends a control message to a specific display
+// connectionSession returns the token session a connecting display
+// authenticated with. Displays may still connect without a token, in which
+// case the connection belongs to no session. A token that is invalid or
+// issued to another display is rejected and false is returned.
+func (h *Handler) connectionSession(w http.ResponseWriter, r *http.Request, displayID uuid.UUID) (uuid.UUID, bool) {
+	raw, ok := authhttp.BearerToken(r)
+	if !ok || h.tokens == nil {
+		return uuid.Nil, true
+	}
+
+	token, err := h.tokens.ValidateToken(r.Context(), raw)
+	if err != nil {
+		h.logger.Warn("rejected display token",
+			"error", err,
+			"displayId", displayID,
+		)
+		httpapi.Error(w, http.StatusUnauthorized, "invalid or expired token")
+		return uuid.Nil, false
+	}
+	if token.DisplayID != displayID {
+		httpapi.Error(w, http.StatusForbidden, "token was issued to another display")
+		return uuid.Nil, false
+	}
+	return token.SessionID, true
+}
+
+// HubReady is a readiness check that fails when the hub has stopped
+// servicing connections, in which case new control sockets would be
+// accepted but never registered
+func (h *Handler) HubReady(ctx context.Context) error {
+	return h.hub.ready(time.Now())
+}
+
+// SendControlMessage sends a control message to a specific display. A
+// sequence with a negative weight is refused before anything is sent, and
+// unset weights are sent as the default.
 func (h *Handler) SendControlMessage(displayID uuid.UUID, message *v1alpha1.ControlMessage) error {
+	if err := h.sendControlMessage(displayID, message); err != nil {
+		return err
+	}
+
+	h.recordControlMessage(displayID, message)
+	return nil
+}
+
+// sendControlMessage queues a control message for a display without
+// recording the content change it implies
+func (h *Handler) sendControlMessage(displayID uuid.UUID, message *v1alpha1.ControlMessage) error {
+	const op = "DisplayHandler.SendControlMessage"
+
+	if message.Sequence != nil {
+		if err := message.Sequence.Validate(); err != nil {
+			return werrors.NewError("INVALID_INPUT", "invalid content sequence: "+err.Error(), op, werrors.ErrInvalidInput)
+		}
+		withDefaults := *message
+		withDefaults.Sequence = message.Sequence.WithDefaults()
+		message = &withDefaults
+	}
+
 	data, err := json.Marshal(message)
 	if err != nil {
 		return fmt.Errorf("failed to marshal control message: %w", err)
 	}
+	if len(data) > h.hub.maxOutboundMessageSize {
+		return werrors.NewError("MESSAGE_TOO_LARGE",
+			fmt.Sprintf("control message is %d bytes, more than the limit of %d", len(data), h.hub.maxOutboundMessageSize),
+			op, werrors.ErrInvalidInput)
+	}
 
-	// Find connection for display
-	for c := range h.hub.connections {
-		if c.displayID == displayID {
-			select {
-			case c.send <- data:
-				return nil
-			default:
-				return fmt.Errorf("display connection buffer full: %s", displayID)
-			}
+	return h.hub.send(displayID, message.Type, data)
+}
+
+// send queues data, a message of type msgType, for a display's connection
+// that accepts that type. A full queue drops its oldest message; a
+// connection that keeps overflowing is closed. Messages over the display's
+// outbound rate are held and delivered once the limit allows, as are
+// messages sent while earlier ones are held, so that order is kept.
+// Messages none of its connections accept are rejected with
+// errUnsupportedMessage.
+func (h *Hub) send(displayID uuid.UUID, msgType v1alpha1.ControlMessageType, data []byte) error {
+	h.heldMu.Lock()
+	defer h.heldMu.Unlock()
+
+	if _, held := h.held[displayID]; held {
+		if _, err := h.target(displayID, msgType); err != nil {
+			return err
 		}
+		h.hold(displayID, msgType, data, 0)
+		return nil
+	}
+
+	status, err := h.allowMessage(ratelimit.LimitTypeWSMessageOut, displayID)
+	if errors.Is(err, ratelimit.ErrLimitExceeded) && status != nil {
+		if _, err := h.target(displayID, msgType); err != nil {
+			return err
+		}
+		h.hold(displayID, msgType, data, status.RetryAfter)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, displayID)
 	}
+	return h.deliver(displayID, msgType, data)
+}
+
+// target returns the connection of a display that accepts msgType
+func (h *Hub) target(displayID uuid.UUID, msgType v1alpha1.ControlMessageType) (*connection, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	connected := false
+	for c := range h.connections {
+		if c.displayID != displayID {
+			continue
+		}
+		connected = true
+		if c.accepts(msgType) {
+			return c, nil
+		}
+	}
+	if !connected {
+		return nil, fmt.Errorf("%w: %s", errNotConnected, displayID)
+	}
+	return nil, fmt.Errorf("%w: %s to %s", errUnsupportedMessage, msgType, displayID)
+}
+
+// deliver queues data on the display's connection that accepts msgType
+// without checking the rate limit
+func (h *Hub) deliver(displayID uuid.UUID, msgType v1alpha1.ControlMessageType, data []byte) error {
+	var target *connection
+	var drops uint64
+	connected := false
 
-	return fmt.Errorf("display not connected: %s", displayID)
+	h.mu.RLock()
+	for c := range h.connections {
+		if c.displayID != displayID {
+			continue
+		}
+		connected = true
+		if c.accepts(msgType) {
+			target = c
+			drops = c.enqueue(data)
+			break
+		}
+	}
+	h.mu.RUnlock()
+
+	if !connected {
+		return fmt.Errorf("%w: %s", errNotConnected, displayID)
+	}
+	if target == nil {
+		return fmt.Errorf("%w: %s to %s", errUnsupportedMessage, msgType, displayID)
+	}
+	if drops >= h.maxConsecutiveDrops {
+		h.evict([]*connection{target})
+	}
+	return nil
+}
+
+// markSeen records that a connected display is alive. Failures are logged
+// since the reaper only acts after a grace period and the next check-in
+// retries.
+func (h *Handler) markSeen(displayID uuid.UUID) {
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+
+	if err := h.service.UpdateLastSeen(ctx, displayID); err != nil {
+		h.logger.Warn("failed to update last seen",
+			"error", err,
+			"displayId", displayID,
+		)
+	}
+}
+
+// handleStatus records content changes reported by a display
+func (h *Handler) handleStatus(displayID uuid.UUID, status *v1alpha1.ControlStatus) {
+	if status.CurrentURL == "" {
+		return
+	}
+	h.recordContentChange(displayID, status.CurrentURL, display.TriggerSequence)
 }
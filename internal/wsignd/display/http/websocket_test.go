@@ -0,0 +1,835 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+func TestDisconnectDisplay(t *testing.T) {
+	displayID := uuid.New()
+	activeDisplay := &display.Display{
+		ID:    displayID,
+		Name:  "lobby-north",
+		State: display.StateActive,
+	}
+
+	mockSvc := &mockService{}
+	mockSvc.On("Get", mock.Anything, displayID).Return(activeDisplay, nil)
+	mockSvc.On("GetByName", mock.Anything, "lobby-north").Return(activeDisplay, nil)
+	mockSvc.On("RecordContentChange", mock.Anything, displayID, "", display.TriggerReload).Return(nil)
+	mockSvc.On("UpdateLastSeen", mock.Anything, displayID).Return(nil)
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandler(mockSvc, nil, nil, logger)
+	server := httptest.NewServer(NewRouter(handler, ratelimit.NewMemoryService(nil), nil))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1alpha1/displays/ws?id=" + displayID.String()
+	disconnectURL := server.URL + "/api/v1alpha1/displays/lobby-north/disconnect"
+
+	// Registration and unregistration happen asynchronously in the hub loop
+	connected := func() bool {
+		handler.hub.mu.RLock()
+		defer handler.hub.mu.RUnlock()
+		for c := range handler.hub.connections {
+			if c.displayID == displayID {
+				return true
+			}
+		}
+		return false
+	}
+	disconnected := func() bool { return !connected() }
+
+	dial := func() *websocket.Conn {
+		ws, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		require.NoError(t, err)
+		require.Eventually(t, connected, time.Second, 10*time.Millisecond)
+		return ws
+	}
+
+	t.Run("closes with requested code after reload", func(t *testing.T) {
+		ws := dial()
+		defer ws.Close()
+
+		resp, err := http.Post(disconnectURL+"?reload=true&code=4001&reason=stuck", "application/json", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+		require.NoError(t, ws.SetReadDeadline(time.Now().Add(2*time.Second)))
+		_, data, err := ws.ReadMessage()
+		require.NoError(t, err)
+		var msg v1alpha1.ControlMessage
+		require.NoError(t, json.Unmarshal(data, &msg))
+		assert.Equal(t, v1alpha1.ControlMessageReload, msg.Type)
+
+		_, _, err = ws.ReadMessage()
+		var closeErr *websocket.CloseError
+		require.ErrorAs(t, err, &closeErr)
+		assert.Equal(t, 4001, closeErr.Code)
+		assert.Equal(t, "stuck", closeErr.Text)
+
+		// The pushed reload is recorded in the display's content history
+		mockSvc.AssertCalled(t, "RecordContentChange", mock.Anything, displayID, "", display.TriggerReload)
+	})
+
+	t.Run("display can reconnect", func(t *testing.T) {
+		require.Eventually(t, disconnected, time.Second, 10*time.Millisecond)
+
+		ws := dial()
+		defer ws.Close()
+
+		resp, err := http.Get(server.URL + "/api/v1alpha1/displays/lobby-north/connections")
+		require.NoError(t, err)
+		var conns v1alpha1.DisplayConnections
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&conns))
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, displayID, conns.DisplayID)
+		require.Len(t, conns.Items, 1)
+		assert.Equal(t, DefaultHubConfig().SendQueueSize, conns.Items[0].QueueCapacity)
+		assert.Zero(t, conns.Items[0].DroppedFrames)
+
+		resp, err = http.Post(disconnectURL, "application/json", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+		require.NoError(t, ws.SetReadDeadline(time.Now().Add(2*time.Second)))
+		_, _, err = ws.ReadMessage()
+		var closeErr *websocket.CloseError
+		require.ErrorAs(t, err, &closeErr)
+		assert.Equal(t, websocket.CloseServiceRestart, closeErr.Code)
+	})
+
+	t.Run("not connected returns 404", func(t *testing.T) {
+		require.Eventually(t, disconnected, time.Second, 10*time.Millisecond)
+
+		resp, err := http.Post(disconnectURL, "application/json", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("invalid close code", func(t *testing.T) {
+		resp, err := http.Post(disconnectURL+"?code=1005", "application/json", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestStatusRecordsContentChange(t *testing.T) {
+	displayID := uuid.New()
+	activeDisplay := &display.Display{
+		ID:    displayID,
+		Name:  "lobby-north",
+		State: display.StateActive,
+	}
+
+	recorded := make(chan string, 1)
+	mockSvc := &mockService{}
+	mockSvc.On("Get", mock.Anything, displayID).Return(activeDisplay, nil)
+	mockSvc.On("UpdateLastSeen", mock.Anything, displayID).Return(nil)
+	mockSvc.On("RecordContentChange", mock.Anything, displayID, "https://example.com/menu", display.TriggerSequence).
+		Run(func(args mock.Arguments) { recorded <- args.String(2) }).
+		Return(nil)
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandler(mockSvc, nil, nil, logger)
+	server := httptest.NewServer(NewRouter(handler, ratelimit.NewMemoryService(nil), nil))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1alpha1/displays/ws?id=" + displayID.String()
+	ws, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	require.NoError(t, ws.WriteJSON(v1alpha1.ControlMessage{
+		Type:      v1alpha1.ControlMessageStatus,
+		Timestamp: time.Now(),
+		Status: &v1alpha1.ControlStatus{
+			CurrentURL: "https://example.com/menu",
+			State:      v1alpha1.DisplayStateActive,
+			UpdatedAt:  time.Now(),
+		},
+	}))
+
+	select {
+	case url := <-recorded:
+		assert.Equal(t, "https://example.com/menu", url)
+	case <-time.After(2 * time.Second):
+		t.Fatal("status report was not recorded")
+	}
+
+	// Connecting counts as a check-in
+	mockSvc.AssertCalled(t, "UpdateLastSeen", mock.Anything, displayID)
+}
+
+func TestSlowDisplayDropsOldest(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	// The server side of a real connection whose send queue nobody drains,
+	// standing in for a display that has stopped reading
+	serverConns := make(chan *websocket.Conn, 1)
+	upgrader := newUpgrader(1024, 1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		serverConns <- ws
+	}))
+	defer server.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hub := newHub(HubConfig{SendQueueSize: 2, MaxConsecutiveDrops: 3}, logger)
+	go hub.run(ctx)
+
+	displayID := uuid.New()
+	c := &connection{
+		displayID:   displayID,
+		ws:          <-serverConns,
+		send:        make(chan []byte, hub.sendQueueSize),
+		hub:         hub,
+		logger:      logger,
+		closeReq:    make(chan []byte, 1),
+		connectedAt: time.Now(),
+	}
+	hub.register <- c
+
+	info := func() v1alpha1.ConnectionInfo {
+		infos := hub.connectionInfo(displayID)
+		require.Len(t, infos, 1)
+		return infos[0]
+	}
+
+	// A third message evicts the oldest rather than closing the connection
+	for _, m := range []string{"m1", "m2", "m3"} {
+		hub.publish([]byte(m))
+	}
+	require.Eventually(t, func() bool { return info().DroppedFrames == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, v1alpha1.ConnectionInfo{
+		ConnectedAt:      c.connectedAt,
+		QueueDepth:       2,
+		QueueCapacity:    2,
+		DroppedFrames:    1,
+		ConsecutiveDrops: 1,
+	}, info())
+	assert.Equal(t, "m2", string(<-c.send))
+	assert.Equal(t, "m3", string(<-c.send))
+
+	// Messages that queue cleanly reset the consecutive drop count
+	require.NoError(t, hub.send(displayID, v1alpha1.ControlMessageSequenceUpdate, []byte("m4")))
+	require.NoError(t, hub.send(displayID, v1alpha1.ControlMessageSequenceUpdate, []byte("m5")))
+	assert.Zero(t, info().ConsecutiveDrops)
+
+	// Reaching the threshold closes the connection with a retry code
+	for _, m := range []string{"m6", "m7"} {
+		require.NoError(t, hub.send(displayID, v1alpha1.ControlMessageSequenceUpdate, []byte(m)))
+	}
+	assert.Equal(t, uint64(2), info().ConsecutiveDrops)
+	hub.publish([]byte("m8"))
+	require.Eventually(t, func() bool { return len(hub.connectionInfo(displayID)) == 0 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, uint64(4), c.dropped.Load())
+	assert.ErrorIs(t, hub.send(displayID, v1alpha1.ControlMessageSequenceUpdate, []byte("m9")), errNotConnected)
+
+	// Queued messages are discarded, so the display sees the close first
+	go c.writePump()
+	require.NoError(t, client.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, _, err = client.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, websocket.CloseTryAgainLater, closeErr.Code)
+}
+
+func TestHubSendRateLimit(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	limiter := ratelimit.NewMemoryService(map[string]ratelimit.Limit{
+		ratelimit.LimitTypeWSMessageOut: {Rate: 20, Period: time.Second, BurstSize: 1},
+	})
+	hub := newHub(HubConfig{Limiter: limiter}, logger)
+
+	connect := func(capabilities ...v1alpha1.ControlMessageType) (uuid.UUID, *connection) {
+		c := &connection{
+			displayID: uuid.New(),
+			send:      make(chan []byte, hub.sendQueueSize),
+			hub:       hub,
+			logger:    logger,
+		}
+		if capabilities != nil {
+			c.setCapabilities(capabilities)
+		}
+		hub.mu.Lock()
+		hub.connections[c] = true
+		hub.mu.Unlock()
+		return c.displayID, c
+	}
+	// received collects what was queued for c until nothing more arrives
+	received := func(c *connection) []string {
+		var got []string
+		for {
+			select {
+			case m := <-c.send:
+				var msg v1alpha1.ControlMessage
+				if json.Unmarshal(m, &msg) == nil && msg.Type == v1alpha1.ControlMessageRateLimited {
+					require.NotNil(t, msg.RateLimit)
+					assert.Equal(t, v1alpha1.RateLimitOutbound, msg.RateLimit.Direction)
+					assert.Equal(t, 1, msg.RateLimit.RetryAfterSeconds)
+					got = append(got, "RATE_LIMITED")
+					continue
+				}
+				got = append(got, string(m))
+			case <-time.After(500 * time.Millisecond):
+				return got
+			}
+		}
+	}
+
+	t.Run("held messages are redelivered with sequence updates merged", func(t *testing.T) {
+		displayID, c := connect(append(v1alpha1.BaselineCapabilities(), v1alpha1.ControlMessageRateLimited)...)
+
+		require.NoError(t, hub.send(displayID, v1alpha1.ControlMessageSequenceUpdate, []byte("s1")))
+		require.NoError(t, hub.send(displayID, v1alpha1.ControlMessageSequenceUpdate, []byte("s2")))
+		require.NoError(t, hub.send(displayID, v1alpha1.ControlMessageSequenceUpdate, []byte("s3")))
+		require.NoError(t, hub.send(displayID, v1alpha1.ControlMessageReload, []byte("r1")))
+		require.NoError(t, hub.send(displayID, v1alpha1.ControlMessageSequenceUpdate, []byte("s4")))
+
+		// s2 is superseded by s3 before it could be sent; s3 and s4 are kept
+		// because the reload between them must run in order
+		assert.Equal(t, []string{"s1", "RATE_LIMITED", "s3", "r1", "s4"}, received(c))
+
+		hub.heldMu.Lock()
+		assert.Empty(t, hub.held)
+		hub.heldMu.Unlock()
+	})
+
+	t.Run("displays that predate the notice only get the messages", func(t *testing.T) {
+		displayID, c := connect()
+
+		require.NoError(t, hub.send(displayID, v1alpha1.ControlMessageSequenceUpdate, []byte("s1")))
+		require.NoError(t, hub.send(displayID, v1alpha1.ControlMessageSequenceUpdate, []byte("s2")))
+		assert.Equal(t, []string{"s1", "s2"}, received(c))
+	})
+
+	t.Run("held queues are bounded", func(t *testing.T) {
+		displayID, c := connect()
+
+		require.NoError(t, hub.send(displayID, v1alpha1.ControlMessageSequenceUpdate, []byte("first")))
+		for i := 0; i <= maxHeldMessages; i++ {
+			require.NoError(t, hub.send(displayID, v1alpha1.ControlMessageReload, []byte(fmt.Sprintf("r%d", i))))
+		}
+		got := received(c)
+		require.NotEmpty(t, got)
+		assert.Equal(t, "first", got[0])
+		assert.NotContains(t, got, "r0", "the oldest held message is dropped")
+		assert.Contains(t, got, fmt.Sprintf("r%d", maxHeldMessages))
+	})
+
+	t.Run("dropped inbound messages are noticed once per window", func(t *testing.T) {
+		_, c := connect(v1alpha1.ControlMessageRateLimited)
+		status := &ratelimit.LimitStatus{RetryAfter: 1500 * time.Millisecond}
+		c.noticeInboundLimit(status)
+		c.noticeInboundLimit(status)
+
+		require.Len(t, c.send, 1)
+		var msg v1alpha1.ControlMessage
+		require.NoError(t, json.Unmarshal(<-c.send, &msg))
+		assert.Equal(t, v1alpha1.ControlMessageRateLimited, msg.Type)
+		assert.Equal(t, &v1alpha1.ControlRateLimit{Direction: v1alpha1.RateLimitInbound, RetryAfterSeconds: 2}, msg.RateLimit)
+	})
+
+	t.Run("messages for displays that cannot take them are rejected", func(t *testing.T) {
+		assert.ErrorIs(t, hub.send(uuid.New(), v1alpha1.ControlMessageSequenceUpdate, []byte("m1")), errNotConnected)
+
+		displayID, _ := connect(v1alpha1.ControlMessageSequenceUpdate)
+		require.NoError(t, hub.send(displayID, v1alpha1.ControlMessageSequenceUpdate, []byte("m1")))
+		assert.ErrorIs(t, hub.send(displayID, v1alpha1.ControlMessageSettings, []byte("m2")), errUnsupportedMessage)
+	})
+}
+
+// TestHubReadiness stalls and crashes the hub loop and checks that
+// readiness follows it
+func TestHubReadiness(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	hub := newHub(DefaultHubConfig(), logger)
+	hub.tickInterval = 10 * time.Millisecond
+	hub.stallAfter = 100 * time.Millisecond
+	handler := &Handler{hub: hub, logger: logger}
+
+	require.Error(t, handler.HubReady(ctx), "hub not started")
+	go hub.run(ctx)
+	require.Eventually(t, func() bool { return handler.HubReady(ctx) == nil }, time.Second, 10*time.Millisecond)
+
+	t.Run("a stalled hub is not ready", func(t *testing.T) {
+		// The loop blocks registering a connection while the lock is held
+		hub.mu.Lock()
+		hub.register <- &connection{displayID: uuid.New(), logger: logger}
+		time.Sleep(2 * hub.stallAfter)
+		err := handler.HubReady(ctx)
+		hub.mu.Unlock()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "has not run")
+
+		assert.Eventually(t, func() bool { return handler.HubReady(ctx) == nil }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("a panic restarts the loop", func(t *testing.T) {
+		// Closing the nil send queue of this connection panics
+		broken := &connection{displayID: uuid.New(), logger: logger}
+		hub.add(broken)
+		hub.unregister <- broken
+
+		require.Eventually(t, func() bool { return hub.restarts.Load() == 1 }, time.Second, 10*time.Millisecond)
+		assert.NoError(t, handler.HubReady(ctx))
+
+		// The restarted loop still registers connections
+		displayID := uuid.New()
+		hub.register <- &connection{displayID: displayID, logger: logger}
+		assert.Eventually(t, func() bool { return hub.connected(displayID) }, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestControlMessageCompatibility(t *testing.T) {
+	displayID := uuid.New()
+	activeDisplay := &display.Display{
+		ID:    displayID,
+		Name:  "lobby-north",
+		State: display.StateActive,
+	}
+
+	mockSvc := &mockService{}
+	mockSvc.On("Get", mock.Anything, displayID).Return(activeDisplay, nil)
+	mockSvc.On("UpdateLastSeen", mock.Anything, displayID).Return(nil)
+	mockSvc.On("RecordContentChange", mock.Anything, displayID, "", display.TriggerReload).Return(nil)
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandler(mockSvc, nil, nil, logger)
+	server := httptest.NewServer(NewRouter(handler, ratelimit.NewMemoryService(nil), nil))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1alpha1/displays/ws?id=" + displayID.String()
+	ws, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	info := func() v1alpha1.ConnectionInfo {
+		infos := handler.hub.connectionInfo(displayID)
+		if len(infos) != 1 {
+			return v1alpha1.ConnectionInfo{}
+		}
+		return infos[0]
+	}
+	require.Eventually(t, func() bool { return len(handler.hub.connectionInfo(displayID)) == 1 }, time.Second, 10*time.Millisecond)
+
+	t.Run("unknown types and versions are counted and ignored", func(t *testing.T) {
+		require.NoError(t, ws.WriteMessage(websocket.TextMessage, []byte(`{"type":"EVENT_BATCH","events":[]}`)))
+		require.NoError(t, ws.WriteJSON(v1alpha1.ControlMessage{
+			TypeMeta: v1alpha1.TypeMeta{APIVersion: "v2"},
+			Type:     v1alpha1.ControlMessageStatus,
+		}))
+		require.Eventually(t, func() bool { return info().UnknownMessages == 2 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("declared capabilities limit what is sent", func(t *testing.T) {
+		// Baseline types go to a display that has declared nothing
+		require.NoError(t, handler.SendControlMessage(displayID, &v1alpha1.ControlMessage{Type: v1alpha1.ControlMessageReload}))
+		_, _, err := ws.ReadMessage()
+		require.NoError(t, err)
+
+		require.NoError(t, ws.WriteJSON(v1alpha1.ControlMessage{
+			TypeMeta: v1alpha1.TypeMeta{APIVersion: v1alpha1.ControlAPIVersion},
+			Type:     v1alpha1.ControlMessageStatus,
+			Status: &v1alpha1.ControlStatus{
+				Capabilities: []v1alpha1.ControlMessageType{v1alpha1.ControlMessageSequenceUpdate},
+			},
+		}))
+		require.Eventually(t, func() bool { return len(info().Capabilities) == 1 }, time.Second, 10*time.Millisecond)
+
+		err = handler.SendControlMessage(displayID, &v1alpha1.ControlMessage{Type: v1alpha1.ControlMessageReload})
+		assert.ErrorIs(t, err, errUnsupportedMessage)
+		assert.NoError(t, handler.SendControlMessage(displayID, &v1alpha1.ControlMessage{Type: v1alpha1.ControlMessageSequenceUpdate}))
+		_, _, err = ws.ReadMessage()
+		require.NoError(t, err)
+	})
+
+	t.Run("sequence weights are checked and defaulted", func(t *testing.T) {
+		mockSvc.On("RecordContentChange", mock.Anything, displayID, "https://example.com/promo", display.TriggerAssignmentChange).Return(nil)
+
+		err := handler.SendControlMessage(displayID, &v1alpha1.ControlMessage{
+			Type:     v1alpha1.ControlMessageSequenceUpdate,
+			Sequence: &v1alpha1.ContentSequence{Items: []v1alpha1.ContentItem{{URL: "https://example.com/promo", Weight: -1}}},
+		})
+		assert.ErrorIs(t, err, werrors.ErrInvalidInput)
+
+		require.NoError(t, handler.SendControlMessage(displayID, &v1alpha1.ControlMessage{
+			Type: v1alpha1.ControlMessageSequenceUpdate,
+			Sequence: &v1alpha1.ContentSequence{Items: []v1alpha1.ContentItem{
+				{URL: "https://example.com/promo", Weight: 2},
+				{URL: "https://example.com/filler"},
+			}},
+		}))
+		require.NoError(t, ws.SetReadDeadline(time.Now().Add(2*time.Second)))
+		var msg v1alpha1.ControlMessage
+		require.NoError(t, ws.ReadJSON(&msg))
+		require.NotNil(t, msg.Sequence)
+		require.Len(t, msg.Sequence.Items, 2)
+		assert.Equal(t, 2, msg.Sequence.Items[0].Weight)
+		assert.Equal(t, v1alpha1.DefaultContentWeight, msg.Sequence.Items[1].Weight)
+	})
+}
+
+// sizedMessage returns the message build makes with padding, grown so that
+// it is exactly size bytes as JSON
+func sizedMessage(t *testing.T, size int, build func(pad string) *v1alpha1.ControlMessage) *v1alpha1.ControlMessage {
+	t.Helper()
+	base, err := json.Marshal(build(""))
+	require.NoError(t, err)
+	require.LessOrEqual(t, len(base), size)
+	msg := build(strings.Repeat("x", size-len(base)))
+	data, err := json.Marshal(msg)
+	require.NoError(t, err)
+	require.Len(t, data, size)
+	return msg
+}
+
+func TestMessageSizeLimits(t *testing.T) {
+	displayID := uuid.New()
+	activeDisplay := &display.Display{
+		ID:    displayID,
+		Name:  "lobby-north",
+		State: display.StateActive,
+	}
+
+	mockSvc := &mockService{}
+	mockSvc.On("Get", mock.Anything, displayID).Return(activeDisplay, nil)
+	mockSvc.On("UpdateLastSeen", mock.Anything, displayID).Return(nil)
+	mockSvc.On("RecordContentChange", mock.Anything, displayID, mock.Anything, mock.Anything).Return(nil)
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandlerWithHubConfig(mockSvc, nil, nil, logger, HubConfig{
+		MaxMessageSize:         1024,
+		MaxOutboundMessageSize: 2048,
+	})
+	server := httptest.NewServer(NewRouter(handler, ratelimit.NewMemoryService(nil), nil))
+	defer server.Close()
+
+	// A small write buffer makes the client fragment larger messages
+	dialer := websocket.Dialer{WriteBufferSize: 256}
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1alpha1/displays/ws?id=" + displayID.String()
+	ws, _, err := dialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	status := func(pad string) *v1alpha1.ControlMessage {
+		return &v1alpha1.ControlMessage{
+			TypeMeta: v1alpha1.TypeMeta{APIVersion: v1alpha1.ControlAPIVersion},
+			Type:     v1alpha1.ControlMessageStatus,
+			Status: &v1alpha1.ControlStatus{
+				LastError:    &pad,
+				Capabilities: append(v1alpha1.BaselineCapabilities(), v1alpha1.ControlMessageError),
+			},
+		}
+	}
+	// write sends msg as JSON without the newline WriteJSON appends
+	write := func(msg *v1alpha1.ControlMessage) {
+		t.Helper()
+		data, err := json.Marshal(msg)
+		require.NoError(t, err)
+		require.NoError(t, ws.WriteMessage(websocket.TextMessage, data))
+	}
+	// next reads the next message sent to the display
+	next := func() v1alpha1.ControlMessage {
+		t.Helper()
+		require.NoError(t, ws.SetReadDeadline(time.Now().Add(2*time.Second)))
+		var msg v1alpha1.ControlMessage
+		require.NoError(t, ws.ReadJSON(&msg))
+		return msg
+	}
+
+	// Status reports are relayed back to the display, which shows they
+	// were accepted
+	write(status(""))
+	assert.Equal(t, v1alpha1.ControlMessageStatus, next().Type)
+
+	t.Run("messages up to the limit are accepted, fragmented or not", func(t *testing.T) {
+		write(sizedMessage(t, 1024, status))
+		assert.Equal(t, v1alpha1.ControlMessageStatus, next().Type)
+	})
+
+	t.Run("messages slightly over the limit are refused and the connection kept", func(t *testing.T) {
+		write(sizedMessage(t, 1025, status))
+		msg := next()
+		assert.Equal(t, v1alpha1.ControlMessageError, msg.Type)
+		require.NotNil(t, msg.Error)
+		assert.Equal(t, v1alpha1.ControlErrorMessageTooLarge, msg.Error.Code)
+		assert.Equal(t, 1024, msg.Error.Limit)
+
+		write(sizedMessage(t, 4*1024, status))
+		assert.Equal(t, v1alpha1.ControlMessageError, next().Type, "up to the tolerance")
+
+		write(status(""))
+		assert.Equal(t, v1alpha1.ControlMessageStatus, next().Type)
+	})
+
+	t.Run("control messages over the outbound limit are not sent", func(t *testing.T) {
+		sequence := func(pad string) *v1alpha1.ControlMessage {
+			return &v1alpha1.ControlMessage{
+				TypeMeta: v1alpha1.TypeMeta{Kind: "ControlMessage", APIVersion: v1alpha1.ControlAPIVersion},
+				Type:     v1alpha1.ControlMessageSequenceUpdate,
+				Sequence: &v1alpha1.ContentSequence{Items: []v1alpha1.ContentItem{
+					{URL: "https://example.com/" + pad, Weight: 1},
+				}},
+			}
+		}
+		require.NoError(t, handler.SendControlMessage(displayID, sizedMessage(t, 2048, sequence)))
+		assert.Equal(t, v1alpha1.ControlMessageSequenceUpdate, next().Type)
+
+		err := handler.SendControlMessage(displayID, sizedMessage(t, 2049, sequence))
+		assert.ErrorIs(t, err, werrors.ErrInvalidInput)
+		assert.ErrorContains(t, err, "2049 bytes, more than the limit of 2048")
+	})
+
+	t.Run("grossly oversized messages close the connection", func(t *testing.T) {
+		write(sizedMessage(t, 4*1024+1, status))
+		require.NoError(t, ws.SetReadDeadline(time.Now().Add(2*time.Second)))
+		_, _, err := ws.ReadMessage()
+		var closeErr *websocket.CloseError
+		require.ErrorAs(t, err, &closeErr)
+		assert.Equal(t, websocket.CloseMessageTooBig, closeErr.Code)
+	})
+}
+
+func TestDrainConnections(t *testing.T) {
+	displayID := uuid.New()
+	activeDisplay := &display.Display{
+		ID:    displayID,
+		Name:  "lobby-north",
+		State: display.StateActive,
+	}
+
+	mockSvc := &mockService{}
+	mockSvc.On("Get", mock.Anything, displayID).Return(activeDisplay, nil)
+	mockSvc.On("UpdateLastSeen", mock.Anything, displayID).Return(nil)
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandlerWithHubConfig(mockSvc, nil, nil, logger, HubConfig{ReconnectSpread: 15 * time.Second})
+	server := httptest.NewServer(NewRouter(handler, ratelimit.NewMemoryService(nil), nil))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1alpha1/displays/ws?id=" + displayID.String()
+	var clients []*websocket.Conn
+	for i := 0; i < 3; i++ {
+		ws, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		require.NoError(t, err)
+		defer ws.Close()
+		clients = append(clients, ws)
+	}
+	require.Eventually(t, func() bool { return handler.hub.count() == 3 }, time.Second, 10*time.Millisecond)
+
+	// Only the first display understands reconnect hints
+	require.NoError(t, clients[0].WriteJSON(&v1alpha1.ControlMessage{
+		TypeMeta: v1alpha1.TypeMeta{APIVersion: v1alpha1.ControlAPIVersion},
+		Type:     v1alpha1.ControlMessageStatus,
+		Status: &v1alpha1.ControlStatus{
+			Capabilities: append(v1alpha1.BaselineCapabilities(), v1alpha1.ControlMessageReconnect),
+		},
+	}))
+	require.Eventually(t, func() bool {
+		_, ok := handler.hub.displaysAccepting(v1alpha1.ControlMessageReconnect)[displayID]
+		return ok
+	}, time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	closed, remaining := handler.DrainConnections(ctx)
+	assert.Equal(t, 3, closed)
+	assert.Zero(t, remaining)
+
+	// Every display is told the server is going away
+	for i, ws := range clients {
+		var reconnect *v1alpha1.ControlReconnect
+		require.NoError(t, ws.SetReadDeadline(time.Now().Add(2*time.Second)))
+		for {
+			var msg v1alpha1.ControlMessage
+			err := ws.ReadJSON(&msg)
+			if err != nil {
+				var closeErr *websocket.CloseError
+				require.ErrorAs(t, err, &closeErr)
+				assert.Equal(t, websocket.CloseGoingAway, closeErr.Code)
+				break
+			}
+			if msg.Type == v1alpha1.ControlMessageReconnect {
+				reconnect = msg.Reconnect
+			}
+		}
+		if i == 0 {
+			require.NotNil(t, reconnect, "told to spread its reconnect before the close")
+			assert.Equal(t, v1alpha1.Milliseconds(15000), reconnect.Spread)
+		} else {
+			assert.Nil(t, reconnect)
+		}
+	}
+}
+
+func TestResyncDisplay(t *testing.T) {
+	displayID := uuid.New()
+	d := &display.Display{
+		ID:       displayID,
+		Name:     "lobby-north",
+		Location: display.Location{SiteID: "hq", Zone: "lobby", Position: "north"},
+		State:    display.StateActive,
+	}
+
+	mockSvc := &mockService{}
+	mockSvc.On("Get", mock.Anything, displayID).Return(d, nil)
+	mockSvc.On("GetByName", mock.Anything, "lobby-north").Return(d, nil)
+	mockSvc.On("RecordContentChange", mock.Anything, displayID, "https://example.com/welcome", display.TriggerResync).Return(nil)
+	mockSvc.On("UpdateLastSeen", mock.Anything, displayID).Return(nil)
+
+	resolver := &stubResolver{assignment: &v1alpha1.ContentAssignment{
+		ObjectMeta: v1alpha1.ObjectMeta{Name: "lobby-welcome"},
+		Source:     "welcome",
+		ContentURL: "https://example.com/welcome",
+	}}
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandler(mockSvc, nil, nil, logger)
+	handler.SetContentResolver(resolver)
+	server := httptest.NewServer(NewRouter(handler, ratelimit.NewMemoryService(nil), nil))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1alpha1/displays/ws?id=" + displayID.String()
+	resyncURL := server.URL + "/api/v1alpha1/displays/lobby-north/resync"
+
+	t.Run("not connected returns 404", func(t *testing.T) {
+		resp, err := http.Post(resyncURL, "application/json", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	ws, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	defer ws.Close()
+	require.Eventually(t, func() bool {
+		handler.hub.mu.RLock()
+		defer handler.hub.mu.RUnlock()
+		return len(handler.hub.connections) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	t.Run("pushes the assigned content", func(t *testing.T) {
+		// Resyncing again resends the same content
+		for i := 0; i < 2; i++ {
+			resp, err := http.Post(resyncURL, "application/json", nil)
+			require.NoError(t, err)
+			var resync v1alpha1.DisplayResync
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&resync))
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, displayID, resync.DisplayID)
+			assert.Equal(t, "lobby-welcome", resync.Content.Assignment)
+			assert.Equal(t, "https://example.com/welcome", resync.Content.URL)
+
+			require.NoError(t, ws.SetReadDeadline(time.Now().Add(2*time.Second)))
+			_, data, err := ws.ReadMessage()
+			require.NoError(t, err)
+			var msg v1alpha1.ControlMessage
+			require.NoError(t, json.Unmarshal(data, &msg))
+			assert.Equal(t, v1alpha1.ControlMessageSequenceUpdate, msg.Type)
+			require.NotNil(t, msg.Sequence)
+			require.Len(t, msg.Sequence.Items, 1)
+			assert.Equal(t, "https://example.com/welcome", msg.Sequence.Items[0].URL)
+		}
+
+		assert.Equal(t, v1alpha1.DisplayLocation{SiteID: "hq", Zone: "lobby", Position: "north"}, resolver.location)
+		mockSvc.AssertNumberOfCalls(t, "RecordContentChange", 2)
+	})
+
+	t.Run("nothing assigned returns 409", func(t *testing.T) {
+		resolver.assignment = nil
+		resp, err := http.Post(resyncURL, "application/json", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	})
+}
+
+func TestBroadcastContent(t *testing.T) {
+	displayID := uuid.New()
+	d := &display.Display{
+		ID:       displayID,
+		Name:     "lobby-north",
+		Location: display.Location{SiteID: "hq", Zone: "lobby", Position: "north"},
+		State:    display.StateActive,
+	}
+
+	mockSvc := &mockService{}
+	mockSvc.On("Get", mock.Anything, displayID).Return(d, nil)
+	mockSvc.On("RecordContentChange", mock.Anything, displayID, "https://example.com/back-shortly", display.TriggerMaintenance).Return(nil)
+	mockSvc.On("UpdateLastSeen", mock.Anything, displayID).Return(nil)
+
+	resolver := &stubResolver{assignment: &v1alpha1.ContentAssignment{
+		ObjectMeta: v1alpha1.ObjectMeta{Name: "maintenance"},
+		ContentURL: "https://example.com/back-shortly",
+	}}
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandler(mockSvc, nil, nil, logger)
+	assert.Zero(t, handler.BroadcastContent(context.Background()), "nothing is sent without a resolver")
+	handler.SetContentResolver(resolver)
+	server := httptest.NewServer(NewRouter(handler, ratelimit.NewMemoryService(nil), nil))
+	defer server.Close()
+
+	assert.Zero(t, handler.BroadcastContent(context.Background()), "no display is connected")
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1alpha1/displays/ws?id=" + displayID.String()
+	ws, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	defer ws.Close()
+	require.Eventually(t, func() bool {
+		handler.hub.mu.RLock()
+		defer handler.hub.mu.RUnlock()
+		return len(handler.hub.connections) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, 1, handler.BroadcastContent(context.Background()))
+	require.NoError(t, ws.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, data, err := ws.ReadMessage()
+	require.NoError(t, err)
+	var msg v1alpha1.ControlMessage
+	require.NoError(t, json.Unmarshal(data, &msg))
+	assert.Equal(t, v1alpha1.ControlMessageSequenceUpdate, msg.Type)
+	require.NotNil(t, msg.Sequence)
+	require.Len(t, msg.Sequence.Items, 1)
+	assert.Equal(t, "https://example.com/back-shortly", msg.Sequence.Items[0].URL)
+	assert.Equal(t, v1alpha1.DisplayLocation{SiteID: "hq", Zone: "lobby", Position: "north"}, resolver.location)
+	mockSvc.AssertNumberOfCalls(t, "RecordContentChange", 1)
+
+	// A display nothing selects keeps what it shows
+	resolver.assignment = nil
+	assert.Zero(t, handler.BroadcastContent(context.Background()))
+}
@@ -12,17 +12,78 @@ type Repository interface {
 	// Save persists a display to storage
 	Save(ctx context.Context, display *Display) error
 
+	// Create inserts a new display unless its site or zone already holds
+	// as many displays as limits allow, in which case it returns
+	// ErrLimitExceeded. Counting and inserting are atomic with respect to
+	// other creates, so concurrent registrations cannot overshoot a cap.
+	Create(ctx context.Context, display *Display, limits Limits) error
+
 	// FindByID retrieves a display by its unique identifier
 	FindByID(ctx context.Context, id uuid.UUID) (*Display, error)
 
 	// FindByName retrieves a display by its name
 	FindByName(ctx context.Context, name string) (*Display, error)
 
-	// List retrieves displays matching the given filter
+	// FindByNameFold retrieves a display whose name equals name ignoring
+	// case, preferring an exact match
+	FindByNameFold(ctx context.Context, name string) (*Display, error)
+
+	// List retrieves displays matching the given filter, oldest first
 	List(ctx context.Context, filter DisplayFilter) ([]*Display, error)
 
 	// Delete removes a display from storage
 	Delete(ctx context.Context, id uuid.UUID) error
+
+	// DeleteOffline removes a display only if it is offline and was last
+	// seen before seenBefore, reporting whether it was removed. The check
+	// and the removal are atomic, so a display that checks in meanwhile is
+	// kept.
+	DeleteOffline(ctx context.Context, id uuid.UUID, seenBefore time.Time) (bool, error)
+
+	// AppendContentTransition records a content transition, setting FromURL
+	// from the display's previous transition, and trims the display's history
+	// to the newest keep entries
+	AppendContentTransition(ctx context.Context, t *ContentTransition, keep int) error
+
+	// ListContentTransitions retrieves up to limit of a display's content
+	// transitions, newest first
+	ListContentTransitions(ctx context.Context, displayID uuid.UUID, limit int) ([]*ContentTransition, error)
+
+	// LatestContentTransitions retrieves the newest content transition of
+	// each of the displays in a single query. Displays without history have
+	// no entry.
+	LatestContentTransitions(ctx context.Context, displayIDs []uuid.UUID) (map[uuid.UUID]*ContentTransition, error)
+
+	// FindStale retrieves up to limit active displays last seen before
+	// olderThan, longest silent first. Displays never seen are not stale.
+	FindStale(ctx context.Context, olderThan time.Time, limit int) ([]*Display, error)
+
+	// CountByState counts the displays matching filter per site and state
+	CountByState(ctx context.Context, filter DisplayFilter) ([]StateCount, error)
+
+	// SaveStateSnapshots stores state snapshots
+	SaveStateSnapshots(ctx context.Context, snapshots []*StateSnapshot) error
+
+	// ListStateSnapshots retrieves the snapshots taken at or after since,
+	// oldest first. An empty siteID lists every site.
+	ListStateSnapshots(ctx context.Context, siteID string, since time.Time) ([]*StateSnapshot, error)
+
+	// PruneStateSnapshots removes the snapshots taken before the given time
+	PruneStateSnapshots(ctx context.Context, before time.Time) error
+}
+
+// LastErrorStore updates a display's LastError without touching the rest of
+// the record or its version. Stores that persist content events in the same
+// database as displays update it themselves; the others are handed one of
+// these.
+type LastErrorStore interface {
+	// SetLastError records e as the display's last error unless a newer
+	// error is already recorded
+	SetLastError(ctx context.Context, displayID uuid.UUID, e ContentError) error
+
+	// ClearLastError clears the display's last error if it was reported no
+	// later than at
+	ClearLastError(ctx context.Context, displayID uuid.UUID, at time.Time) error
 }
 
 // DisplayFilter defines criteria for listing displays
@@ -33,33 +94,121 @@ type DisplayFilter struct {
 	Zone string
 	// States filters by display states
 	States []State
+	// Properties filters by display properties; a display matches when it
+	// has every listed key with exactly the given value
+	Properties map[string]string
+	// NeverSeen keeps only displays that have never contacted the server
+	NeverSeen bool
+	// Seen keeps only displays that have contacted the server at least
+	// once
+	Seen bool
+	// SeenBefore, when set, keeps only displays last seen before it.
+	// Displays never seen are left out.
+	SeenBefore time.Time
+}
+
+// Matches reports whether d satisfies every criterion in the filter. The
+// repositories that filter in SQL must agree with it.
+func (f DisplayFilter) Matches(d *Display) bool {
+	if f.SiteID != "" && d.Location.SiteID != f.SiteID {
+		return false
+	}
+	if f.Zone != "" && d.Location.Zone != f.Zone {
+		return false
+	}
+	for key, value := range f.Properties {
+		if got, ok := d.Properties[key]; !ok || got != value {
+			return false
+		}
+	}
+	if f.NeverSeen && !d.LastSeen.IsZero() || f.Seen && d.LastSeen.IsZero() {
+		return false
+	}
+	if !f.SeenBefore.IsZero() && (d.LastSeen.IsZero() || !d.LastSeen.Before(f.SeenBefore)) {
+		return false
+	}
+	if len(f.States) == 0 {
+		return true
+	}
+	for _, s := range f.States {
+		if d.State == s {
+			return true
+		}
+	}
+	return false
 }
 
 // Service defines the interface for display business operations
 type Service interface {
-	// Register creates a new display
-	Register(ctx context.Context, name string, location Location) (*Display, error)
+	// Register creates a new display with the given properties, which may be
+	// nil
+	Register(ctx context.Context, name string, location Location, properties map[string]string) (*Display, error)
 
 	// Get retrieves a display by ID
 	Get(ctx context.Context, id uuid.UUID) (*Display, error)
 
+	// GetByName retrieves a display by name
+	GetByName(ctx context.Context, name string) (*Display, error)
+
 	// List retrieves displays matching the filter
 	List(ctx context.Context, filter DisplayFilter) ([]*Display, error)
 
 	// UpdateLocation updates a display's physical location
 	UpdateLocation(ctx context.Context, id uuid.UUID, location Location) error
 
-	// Activate transitions a display to the active state
+	// Activate transitions a display to the active state, or to
+	// StatePendingApproval when the approval policy covers its site
 	Activate(ctx context.Context, id uuid.UUID) error
 
+	// Approve activates a display that is awaiting approval
+	Approve(ctx context.Context, id uuid.UUID) error
+
 	// Disable transitions a display to the disabled state
 	Disable(ctx context.Context, id uuid.UUID) error
 
-	// UpdateLastSeen updates the display's last seen timestamp
+	// Delete removes a display whatever its state
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	// UpdateLastSeen updates the display's last seen timestamp, bringing an
+	// offline display back to the active state
 	UpdateLastSeen(ctx context.Context, id uuid.UUID) error
 
+	// ReapOffline marks active displays that have stopped checking in as
+	// offline
+	ReapOffline(ctx context.Context) error
+
+	// CountByState counts the displays matching filter per site and state
+	CountByState(ctx context.Context, filter DisplayFilter) ([]StateCount, error)
+
 	// SetProperty sets a display property
 	SetProperty(ctx context.Context, id uuid.UUID, key, value string) error
+
+	// Patch applies a partial update to a display. If expectedVersion is
+	// non-zero the update fails with a version conflict unless the display
+	// is at that version. A rename to a name another display holds, ignoring
+	// case, fails with ErrNameTaken.
+	Patch(ctx context.Context, id uuid.UUID, patch Patch, expectedVersion int) (*Display, error)
+
+	// RecordContentChange records that a display switched to the given
+	// content URL
+	RecordContentChange(ctx context.Context, id uuid.UUID, url string, trigger ContentTrigger) error
+
+	// ContentHistory retrieves up to limit of a display's most recent content
+	// transitions, newest first
+	ContentHistory(ctx context.Context, id uuid.UUID, limit int) ([]*ContentTransition, error)
+
+	// SnapshotStates records how many displays each site has in each state
+	// and prunes snapshots older than retention. A retention of zero or less
+	// keeps every snapshot.
+	SnapshotStates(ctx context.Context, retention time.Duration) error
+
+	// StateHistory retrieves the state snapshots taken at or after since,
+	// oldest first. An empty siteID covers every site.
+	StateHistory(ctx context.Context, siteID string, since time.Time) ([]*StateSnapshot, error)
+
+	// PruneOffline deletes the displays matching filter, or with dryRun
+	// only finds them. Only offline displays are ever deleted.
+	PruneOffline(ctx context.Context, filter PruneFilter, dryRun bool) (*PruneResult, error)
 }
 
 // EventType represents types of display events
@@ -70,10 +219,24 @@ const (
 	EventRegistered EventType = "REGISTERED"
 	// EventActivated indicates a display activation
 	EventActivated EventType = "ACTIVATED"
+	// EventApprovalRequested indicates an activated display is awaiting
+	// approval
+	EventApprovalRequested EventType = "APPROVAL_REQUESTED"
+	// EventApproved indicates an operator approved a display
+	EventApproved EventType = "APPROVED"
 	// EventDisabled indicates a display was disabled
 	EventDisabled EventType = "DISABLED"
 	// EventLocationChanged indicates a display location change
 	EventLocationChanged EventType = "LOCATION_CHANGED"
+	// EventRenamed indicates a display's name changed; its data carries
+	// the old and new names
+	EventRenamed EventType = "RENAMED"
+	// EventOffline indicates a display stopped checking in
+	EventOffline EventType = "OFFLINE"
+	// EventOnline indicates an offline display checked in again
+	EventOnline EventType = "ONLINE"
+	// EventDeleted indicates a display was removed
+	EventDeleted EventType = "DELETED"
 )
 
 // Event represents something that happened to a display
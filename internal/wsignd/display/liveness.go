@@ -0,0 +1,29 @@
+package display
+
+import "time"
+
+// Liveness controls when a display that has stopped checking in is
+// considered offline
+type Liveness struct {
+	// OfflineAfter is how long a display may go without contacting the
+	// server before it is considered gone. Zero disables offline detection.
+	OfflineAfter time.Duration
+	// GracePeriod is added to OfflineAfter before the state change is made.
+	// A display that reconnects within the window never leaves the active
+	// state, so brief network drops do not produce OFFLINE/ONLINE pairs.
+	GracePeriod time.Duration
+}
+
+// DefaultLiveness returns the liveness settings used when none are configured
+func DefaultLiveness() Liveness {
+	return Liveness{
+		OfflineAfter: 2 * time.Minute,
+		GracePeriod:  30 * time.Second,
+	}
+}
+
+// offlineCutoff returns the last-seen time before which an active display is
+// marked offline
+func (l Liveness) offlineCutoff(now time.Time) time.Time {
+	return now.Add(-(l.OfflineAfter + l.GracePeriod))
+}
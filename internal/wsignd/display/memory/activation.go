@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/display/activation"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// ActivationRepository implements activation.Repository in memory
+type ActivationRepository struct {
+	mu    sync.RWMutex
+	codes map[uuid.UUID]activation.DeviceCode
+}
+
+// NewActivationRepository creates an empty in-memory device code repository
+func NewActivationRepository() activation.Repository {
+	return &ActivationRepository{codes: make(map[uuid.UUID]activation.DeviceCode)}
+}
+
+// Save stores a newly issued device code. Device and user codes must be
+// unique; enrollment tokens have no user code.
+func (r *ActivationRepository) Save(ctx context.Context, code *activation.DeviceCode) error {
+	const op = "ActivationRepository.Save"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if code.Kind == "" {
+		code.Kind = activation.KindDeviceCode
+	}
+	for id, other := range r.codes {
+		if id == code.ID || other.DeviceCode == code.DeviceCode || (code.UserCode != "" && other.UserCode == code.UserCode) {
+			return werrors.NewError("CONFLICT", "resource already exists", op, werrors.ErrConflict)
+		}
+	}
+	r.codes[code.ID] = *code
+	return nil
+}
+
+// FindByDeviceCode retrieves a code by the secret device code
+func (r *ActivationRepository) FindByDeviceCode(ctx context.Context, deviceCode string) (*activation.DeviceCode, error) {
+	const op = "ActivationRepository.FindByDeviceCode"
+	return r.find(op, func(c *activation.DeviceCode) bool { return c.DeviceCode == deviceCode })
+}
+
+// FindByUserCode retrieves a code by the user code shown on screen
+func (r *ActivationRepository) FindByUserCode(ctx context.Context, userCode string) (*activation.DeviceCode, error) {
+	const op = "ActivationRepository.FindByUserCode"
+	return r.find(op, func(c *activation.DeviceCode) bool { return c.UserCode == userCode })
+}
+
+// find returns a copy of the first code satisfying match
+func (r *ActivationRepository) find(op string, match func(*activation.DeviceCode) bool) (*activation.DeviceCode, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, code := range r.codes {
+		if match(&code) {
+			c := code
+			return &c, nil
+		}
+	}
+	return nil, notFound(op)
+}
+
+// MarkActivated binds a code to its display. A code that is already
+// activated is reported as a conflict.
+func (r *ActivationRepository) MarkActivated(ctx context.Context, id uuid.UUID, displayID uuid.UUID) error {
+	const op = "ActivationRepository.MarkActivated"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	code, ok := r.codes[id]
+	if !ok {
+		return notFound(op)
+	}
+	if code.Activated {
+		return werrors.NewError("CONFLICT", "device code already activated", op, werrors.ErrConflict)
+	}
+	code.Activated = true
+	code.DisplayID = displayID
+	r.codes[id] = code
+	return nil
+}
+
+// MarkRedeemed records that a token has been issued for a code. A code
+// that is already redeemed is reported as a conflict.
+func (r *ActivationRepository) MarkRedeemed(ctx context.Context, id uuid.UUID) error {
+	const op = "ActivationRepository.MarkRedeemed"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	code, ok := r.codes[id]
+	if !ok {
+		return notFound(op)
+	}
+	if code.Redeemed {
+		return werrors.NewError("CONFLICT", "device code already redeemed", op, werrors.ErrConflict)
+	}
+	code.Redeemed = true
+	r.codes[id] = code
+	return nil
+}
+
+// DeleteExpired removes codes that expired before the given time
+func (r *ActivationRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for id, code := range r.codes {
+		if code.ExpiresAt.Before(before) {
+			delete(r.codes, id)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,185 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/display/outbox"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// OutboxRepository implements outbox.Repository in memory
+type OutboxRepository struct {
+	mu       sync.Mutex
+	messages map[uuid.UUID]outbox.Message
+	seq      int64
+}
+
+// NewOutboxRepository creates an empty in-memory outbox repository
+func NewOutboxRepository() outbox.Repository {
+	return &OutboxRepository{messages: make(map[uuid.UUID]outbox.Message)}
+}
+
+// Create stores a new message, setting its Seq
+func (r *OutboxRepository) Create(ctx context.Context, m *outbox.Message) error {
+	const op = "OutboxRepository.Create"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.messages[m.ID]; ok {
+		return werrors.NewError("CONFLICT", "resource already exists", op, werrors.ErrConflict)
+	}
+	r.seq++
+	m.Seq = r.seq
+	r.messages[m.ID] = *m
+	return nil
+}
+
+// Get retrieves a message by ID
+func (r *OutboxRepository) Get(ctx context.Context, id uuid.UUID) (*outbox.Message, error) {
+	const op = "OutboxRepository.Get"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, ok := r.messages[id]
+	if !ok {
+		return nil, notFound(op)
+	}
+	return &m, nil
+}
+
+// List retrieves messages matching the filter in queue order
+func (r *OutboxRepository) List(ctx context.Context, filter outbox.Filter) ([]*outbox.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var messages []*outbox.Message
+	for _, m := range r.messages {
+		if filter.DisplayID != uuid.Nil && m.DisplayID != filter.DisplayID {
+			continue
+		}
+		if filter.State != "" && m.State != filter.State {
+			continue
+		}
+		m := m
+		messages = append(messages, &m)
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].Seq < messages[j].Seq
+	})
+	return messages, nil
+}
+
+// CountPending counts the pending messages of each of the displays
+func (r *OutboxRepository) CountPending(ctx context.Context, displayIDs []uuid.UUID) (map[uuid.UUID]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wanted := make(map[uuid.UUID]bool, len(displayIDs))
+	for _, id := range displayIDs {
+		wanted[id] = true
+	}
+	counts := make(map[uuid.UUID]int)
+	for _, m := range r.messages {
+		if m.State == outbox.StatePending && wanted[m.DisplayID] {
+			counts[m.DisplayID]++
+		}
+	}
+	return counts, nil
+}
+
+// Claim marks a pending, unexpired message delivered
+func (r *OutboxRepository) Claim(ctx context.Context, id uuid.UUID, at time.Time) error {
+	const op = "OutboxRepository.Claim"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, ok := r.messages[id]
+	if !ok {
+		return notFound(op)
+	}
+	if m.State != outbox.StatePending || !m.DeliveredAt.IsZero() || !at.Before(m.ExpiresAt) {
+		return werrors.NewError("CONFLICT", "message is not pending", op, werrors.ErrConflict)
+	}
+	m.State = outbox.StateDelivered
+	m.DeliveredAt = at
+	m.Attempts++
+	r.messages[id] = m
+	return nil
+}
+
+// Release returns a claimed message to pending
+func (r *OutboxRepository) Release(ctx context.Context, id uuid.UUID, reason string) error {
+	const op = "OutboxRepository.Release"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, ok := r.messages[id]
+	if !ok {
+		return notFound(op)
+	}
+	m.State = outbox.StatePending
+	m.DeliveredAt = time.Time{}
+	m.LastError = reason
+	r.messages[id] = m
+	return nil
+}
+
+// Requeue moves a dead message back to pending
+func (r *OutboxRepository) Requeue(ctx context.Context, id uuid.UUID, expiresAt time.Time) error {
+	const op = "OutboxRepository.Requeue"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, ok := r.messages[id]
+	if !ok {
+		return notFound(op)
+	}
+	if m.State != outbox.StateDead {
+		return werrors.NewError("CONFLICT", "message is not dead", op, werrors.ErrConflict)
+	}
+	m.State = outbox.StatePending
+	m.ExpiresAt = expiresAt
+	r.messages[id] = m
+	return nil
+}
+
+// ExpirePending turns pending messages that expired by at into dead letters
+func (r *OutboxRepository) ExpirePending(ctx context.Context, at time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for id, m := range r.messages {
+		if m.State == outbox.StatePending && !at.Before(m.ExpiresAt) {
+			m.State = outbox.StateDead
+			r.messages[id] = m
+			n++
+		}
+	}
+	return n, nil
+}
+
+// DeleteDelivered removes messages delivered before the given time
+func (r *OutboxRepository) DeleteDelivered(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for id, m := range r.messages {
+		if m.State == outbox.StateDelivered && m.DeliveredAt.Before(before) {
+			delete(r.messages, id)
+			n++
+		}
+	}
+	return n, nil
+}
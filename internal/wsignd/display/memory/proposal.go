@@ -0,0 +1,113 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/display/proposal"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// ProposalRepository implements proposal.Repository in memory
+type ProposalRepository struct {
+	mu        sync.RWMutex
+	proposals map[uuid.UUID]proposal.Proposal
+}
+
+// NewProposalRepository creates an empty in-memory proposal repository
+func NewProposalRepository() proposal.Repository {
+	return &ProposalRepository{proposals: make(map[uuid.UUID]proposal.Proposal)}
+}
+
+// Create stores a new proposal
+func (r *ProposalRepository) Create(ctx context.Context, p *proposal.Proposal) error {
+	const op = "ProposalRepository.Create"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.proposals[p.ID]; ok {
+		return werrors.NewError("CONFLICT", "resource already exists", op, werrors.ErrConflict)
+	}
+	r.proposals[p.ID] = *p
+	return nil
+}
+
+// Get retrieves a proposal by ID
+func (r *ProposalRepository) Get(ctx context.Context, id uuid.UUID) (*proposal.Proposal, error) {
+	const op = "ProposalRepository.Get"
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.proposals[id]
+	if !ok {
+		return nil, notFound(op)
+	}
+	return &p, nil
+}
+
+// List retrieves proposals matching the filter, oldest first
+func (r *ProposalRepository) List(ctx context.Context, filter proposal.Filter) ([]*proposal.Proposal, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var proposals []*proposal.Proposal
+	for _, p := range r.proposals {
+		if filter.State != "" && p.State != filter.State {
+			continue
+		}
+		if filter.DisplayID != uuid.Nil && p.DisplayID != filter.DisplayID {
+			continue
+		}
+		p := p
+		proposals = append(proposals, &p)
+	}
+	sort.Slice(proposals, func(i, j int) bool {
+		return proposals[i].CreatedAt.Before(proposals[j].CreatedAt)
+	})
+	return proposals, nil
+}
+
+// Decide moves a pending proposal to state. A proposal that is no longer
+// pending is reported as a conflict.
+func (r *ProposalRepository) Decide(ctx context.Context, id uuid.UUID, state proposal.State, by string, at time.Time) error {
+	const op = "ProposalRepository.Decide"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.proposals[id]
+	if !ok {
+		return notFound(op)
+	}
+	if p.State != proposal.StatePending {
+		return werrors.NewError("CONFLICT", "proposal already decided", op, werrors.ErrConflict)
+	}
+	p.State = state
+	p.DecidedBy = by
+	p.DecidedAt = at
+	r.proposals[id] = p
+	return nil
+}
+
+// ExpirePending expires pending proposals created before the given time
+func (r *ProposalRepository) ExpirePending(ctx context.Context, before, at time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for id, p := range r.proposals {
+		if p.State == proposal.StatePending && p.CreatedAt.Before(before) {
+			p.State = proposal.StateExpired
+			p.DecidedAt = at
+			r.proposals[id] = p
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,344 @@
+// Package memory implements the display repositories in process memory. It
+// backs demo mode and serves as a fast fake in tests; data is lost when the
+// process exits.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// Repository implements display.Repository in memory with the same
+// optimistic locking and error semantics as the PostgreSQL repository
+type Repository struct {
+	mu       sync.RWMutex
+	displays map[uuid.UUID]display.Display
+	history  map[uuid.UUID][]display.ContentTransition
+	// snapshots are kept in the order they were saved
+	snapshots []display.StateSnapshot
+
+	// now returns the current time for created/updated timestamps
+	now func() time.Time
+}
+
+// The content event store keeps LastError current through this interface
+var _ display.LastErrorStore = (*Repository)(nil)
+
+// NewRepository creates an empty in-memory display repository
+func NewRepository() display.Repository {
+	return &Repository{
+		displays: make(map[uuid.UUID]display.Display),
+		history:  make(map[uuid.UUID][]display.ContentTransition),
+		now:      time.Now,
+	}
+}
+
+// Save inserts a new display or updates an existing one. Updates must carry
+// the stored version and advance it by one; names must be unique.
+func (r *Repository) Save(ctx context.Context, d *display.Display) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.save(d)
+}
+
+// Create inserts a new display unless its site or zone is full
+func (r *Repository) Create(ctx context.Context, d *display.Display, limits display.Limits) error {
+	const op = "DisplayRepository.Create"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.displays[d.ID]; exists {
+		return werrors.NewError("CONFLICT", "resource already exists", op, werrors.ErrConflict)
+	}
+
+	var site, zone int
+	for _, other := range r.displays {
+		if other.Location.SiteID != d.Location.SiteID {
+			continue
+		}
+		site++
+		if other.Location.Zone == d.Location.Zone {
+			zone++
+		}
+	}
+	if limits.Site > 0 && site >= limits.Site {
+		return display.ErrLimitExceeded{Scope: fmt.Sprintf("site %q", d.Location.SiteID), Limit: limits.Site}
+	}
+	if limits.Zone > 0 && zone >= limits.Zone {
+		return display.ErrLimitExceeded{Scope: fmt.Sprintf("zone %q", display.ZoneKey(d.Location.SiteID, d.Location.Zone)), Limit: limits.Zone}
+	}
+
+	return r.save(d)
+}
+
+// save stores d; callers hold the write lock
+func (r *Repository) save(d *display.Display) error {
+	const op = "DisplayRepository.Save"
+
+	for id, other := range r.displays {
+		if id != d.ID && other.Name == d.Name {
+			return werrors.NewError("CONFLICT", "resource already exists", op, werrors.ErrConflict)
+		}
+	}
+
+	now := r.now()
+	stored, exists := r.displays[d.ID]
+	if exists {
+		if stored.Version != d.Version {
+			return display.ErrVersionMismatch{ID: d.ID.String()}
+		}
+		d.Version++
+		d.CreatedAt = stored.CreatedAt
+	} else {
+		d.CreatedAt = now
+	}
+	d.UpdatedAt = now
+
+	// LastError belongs to content event processing, as in PostgreSQL
+	c := copyDisplay(d)
+	c.LastError = stored.LastError
+	r.displays[d.ID] = c
+	return nil
+}
+
+// SetLastError records a display's last content error unless a newer one
+// is already recorded
+func (r *Repository) SetLastError(ctx context.Context, displayID uuid.UUID, e display.ContentError) error {
+	const op = "DisplayRepository.SetLastError"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.displays[displayID]
+	if !ok {
+		return notFound(op)
+	}
+	if d.LastError != nil && d.LastError.At.After(e.At) {
+		return nil
+	}
+	d.LastError = &e
+	r.displays[displayID] = d
+	return nil
+}
+
+// ClearLastError clears a display's last content error if it was reported
+// no later than at
+func (r *Repository) ClearLastError(ctx context.Context, displayID uuid.UUID, at time.Time) error {
+	const op = "DisplayRepository.ClearLastError"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.displays[displayID]
+	if !ok {
+		return notFound(op)
+	}
+	if d.LastError != nil && !d.LastError.At.After(at) {
+		d.LastError = nil
+		r.displays[displayID] = d
+	}
+	return nil
+}
+
+// FindByID retrieves a display by its unique identifier
+func (r *Repository) FindByID(ctx context.Context, id uuid.UUID) (*display.Display, error) {
+	const op = "DisplayRepository.FindByID"
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	d, ok := r.displays[id]
+	if !ok {
+		return nil, notFound(op)
+	}
+	c := copyDisplay(&d)
+	return &c, nil
+}
+
+// FindByName retrieves a display by its name
+func (r *Repository) FindByName(ctx context.Context, name string) (*display.Display, error) {
+	const op = "DisplayRepository.FindByName"
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, d := range r.displays {
+		if d.Name == name {
+			c := copyDisplay(&d)
+			return &c, nil
+		}
+	}
+	return nil, notFound(op)
+}
+
+// FindByNameFold retrieves a display whose name equals name ignoring case,
+// preferring an exact match
+func (r *Repository) FindByNameFold(ctx context.Context, name string) (*display.Display, error) {
+	const op = "DisplayRepository.FindByNameFold"
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var found *display.Display
+	for _, d := range r.displays {
+		if d.Name == name || found == nil && strings.EqualFold(d.Name, name) {
+			c := copyDisplay(&d)
+			found = &c
+		}
+	}
+	if found == nil {
+		return nil, notFound(op)
+	}
+	return found, nil
+}
+
+// List retrieves displays matching the filter, oldest first
+func (r *Repository) List(ctx context.Context, filter display.DisplayFilter) ([]*display.Display, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var displays []*display.Display
+	for _, d := range r.displays {
+		if filter.Matches(&d) {
+			c := copyDisplay(&d)
+			displays = append(displays, &c)
+		}
+	}
+	sort.Slice(displays, func(i, j int) bool {
+		if !displays[i].CreatedAt.Equal(displays[j].CreatedAt) {
+			return displays[i].CreatedAt.Before(displays[j].CreatedAt)
+		}
+		return displays[i].Name < displays[j].Name
+	})
+	return displays, nil
+}
+
+// FindStale retrieves up to limit active displays last seen before
+// olderThan, longest silent first. Displays never seen are left out, as a
+// NULL last_seen is in SQL.
+func (r *Repository) FindStale(ctx context.Context, olderThan time.Time, limit int) ([]*display.Display, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var displays []*display.Display
+	for _, d := range r.displays {
+		if d.State == display.StateActive && !d.LastSeen.IsZero() && d.LastSeen.Before(olderThan) {
+			c := copyDisplay(&d)
+			displays = append(displays, &c)
+		}
+	}
+	sort.Slice(displays, func(i, j int) bool {
+		return displays[i].LastSeen.Before(displays[j].LastSeen)
+	})
+	if len(displays) > limit {
+		displays = displays[:limit]
+	}
+	return displays, nil
+}
+
+// Delete removes a display and its content history
+func (r *Repository) Delete(ctx context.Context, id uuid.UUID) error {
+	const op = "DisplayRepository.Delete"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.displays[id]; !ok {
+		return notFound(op)
+	}
+	delete(r.displays, id)
+	delete(r.history, id)
+	return nil
+}
+
+// DeleteOffline removes a display and its content history if it is offline
+// and was last seen before seenBefore
+func (r *Repository) DeleteOffline(ctx context.Context, id uuid.UUID, seenBefore time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.displays[id]
+	if !ok || d.State != display.StateOffline || d.LastSeen.IsZero() || !d.LastSeen.Before(seenBefore) {
+		return false, nil
+	}
+	delete(r.displays, id)
+	delete(r.history, id)
+	return true, nil
+}
+
+// AppendContentTransition records a transition, setting FromURL from the
+// display's previous transition, and keeps only the newest keep entries
+func (r *Repository) AppendContentTransition(ctx context.Context, t *display.ContentTransition, keep int) error {
+	const op = "DisplayRepository.AppendContentTransition"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.displays[t.DisplayID]; !ok {
+		return notFound(op)
+	}
+
+	history := r.history[t.DisplayID]
+	if len(history) > 0 {
+		t.FromURL = history[len(history)-1].ToURL
+	}
+	history = append(history, *t)
+	if keep >= 0 && len(history) > keep {
+		history = append([]display.ContentTransition(nil), history[len(history)-keep:]...)
+	}
+	r.history[t.DisplayID] = history
+	return nil
+}
+
+// ListContentTransitions retrieves up to limit of a display's transitions,
+// newest first
+func (r *Repository) ListContentTransitions(ctx context.Context, displayID uuid.UUID, limit int) ([]*display.ContentTransition, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	history := r.history[displayID]
+	var transitions []*display.ContentTransition
+	for i := len(history) - 1; i >= 0 && len(transitions) < limit; i-- {
+		t := history[i]
+		transitions = append(transitions, &t)
+	}
+	return transitions, nil
+}
+
+// LatestContentTransitions retrieves the newest transition of each of the
+// displays
+func (r *Repository) LatestContentTransitions(ctx context.Context, displayIDs []uuid.UUID) (map[uuid.UUID]*display.ContentTransition, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	latest := make(map[uuid.UUID]*display.ContentTransition)
+	for _, id := range displayIDs {
+		if history := r.history[id]; len(history) > 0 {
+			t := history[len(history)-1]
+			latest[id] = &t
+		}
+	}
+	return latest, nil
+}
+
+// copyDisplay returns a copy of d that shares no mutable state with it
+func copyDisplay(d *display.Display) display.Display {
+	return *d.Clone()
+}
+
+// notFound builds the error the PostgreSQL repositories return for a
+// missing row
+func notFound(op string) error {
+	return werrors.NewError("NOT_FOUND", "resource not found", op, werrors.ErrNotFound)
+}
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/repotest"
+)
+
+func TestRepositoryConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) display.Repository {
+		return NewRepository()
+	})
+}
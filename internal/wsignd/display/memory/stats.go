@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"time"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+)
+
+// CountByState counts the displays matching filter per site and state,
+// ordered by site and state
+func (r *Repository) CountByState(ctx context.Context, filter display.DisplayFilter) ([]display.StateCount, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	type key struct {
+		site  string
+		state display.State
+	}
+	totals := make(map[key]int)
+	for _, d := range r.displays {
+		if filter.Matches(&d) {
+			totals[key{d.Location.SiteID, d.State}]++
+		}
+	}
+
+	counts := make([]display.StateCount, 0, len(totals))
+	for k, n := range totals {
+		counts = append(counts, display.StateCount{SiteID: k.site, State: k.state, Count: n})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].SiteID != counts[j].SiteID {
+			return counts[i].SiteID < counts[j].SiteID
+		}
+		return counts[i].State < counts[j].State
+	})
+	return counts, nil
+}
+
+// SaveStateSnapshots stores copies of the given snapshots
+func (r *Repository) SaveStateSnapshots(ctx context.Context, snapshots []*display.StateSnapshot) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, s := range snapshots {
+		c := *s
+		c.Counts = maps.Clone(s.Counts)
+		r.snapshots = append(r.snapshots, c)
+	}
+	return nil
+}
+
+// ListStateSnapshots retrieves the snapshots taken at or after since, oldest
+// first and then by site
+func (r *Repository) ListStateSnapshots(ctx context.Context, siteID string, since time.Time) ([]*display.StateSnapshot, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var snapshots []*display.StateSnapshot
+	for _, s := range r.snapshots {
+		if s.TakenAt.Before(since) || (siteID != "" && s.SiteID != siteID) {
+			continue
+		}
+		c := s
+		c.Counts = maps.Clone(s.Counts)
+		snapshots = append(snapshots, &c)
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		if !snapshots[i].TakenAt.Equal(snapshots[j].TakenAt) {
+			return snapshots[i].TakenAt.Before(snapshots[j].TakenAt)
+		}
+		return snapshots[i].SiteID < snapshots[j].SiteID
+	})
+	return snapshots, nil
+}
+
+// PruneStateSnapshots removes the snapshots taken before the given time
+func (r *Repository) PruneStateSnapshots(ctx context.Context, before time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.snapshots[:0]
+	for _, s := range r.snapshots {
+		if !s.TakenAt.Before(before) {
+			kept = append(kept, s)
+		}
+	}
+	r.snapshots = kept
+	return nil
+}
@@ -0,0 +1,137 @@
+// Package outbox stores control messages for displays that could not be
+// reached when the message was sent. Queued messages are delivered in order
+// once the display connects, and messages nobody could deliver before they
+// expired are kept as dead letters an operator can replay.
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+)
+
+// State is where a queued message is in its lifecycle
+type State string
+
+const (
+	// StatePending indicates a message waiting for its display to connect
+	StatePending State = "pending"
+	// StateDelivered indicates a message handed to the display's connection
+	StateDelivered State = "delivered"
+	// StateDead indicates a message that expired before it could be
+	// delivered
+	StateDead State = "dead"
+)
+
+// Valid reports whether s is a known state
+func (s State) Valid() bool {
+	switch s {
+	case StatePending, StateDelivered, StateDead:
+		return true
+	}
+	return false
+}
+
+// Message is a control message queued for one display
+type Message struct {
+	// ID uniquely identifies the message
+	ID uuid.UUID
+	// DisplayID is the display the message is for
+	DisplayID uuid.UUID
+	// Seq orders messages; it is assigned by the repository and increases
+	// with every message queued
+	Seq int64
+	// Message is the control message to deliver
+	Message v1alpha1.ControlMessage
+	// State is where the message is in its lifecycle
+	State State
+	// Attempts counts how often delivery was tried
+	Attempts int
+	// LastError describes why the last delivery attempt failed
+	LastError string
+	// CreatedAt is when the message was queued
+	CreatedAt time.Time
+	// ExpiresAt is when an undelivered message becomes a dead letter
+	ExpiresAt time.Time
+	// DeliveredAt is when the message was delivered; zero until then
+	DeliveredAt time.Time
+}
+
+// Filter defines criteria for listing messages
+type Filter struct {
+	// DisplayID filters by display
+	DisplayID uuid.UUID
+	// State filters by message state
+	State State
+}
+
+// Repository defines persistence for queued messages
+type Repository interface {
+	// Create stores a new message, setting its Seq
+	Create(ctx context.Context, m *Message) error
+	// Get retrieves a message by ID
+	Get(ctx context.Context, id uuid.UUID) (*Message, error)
+	// List retrieves messages matching the filter in queue order
+	List(ctx context.Context, filter Filter) ([]*Message, error)
+	// CountPending counts the pending messages of each of the displays in
+	// a single query. Displays with none have no entry.
+	CountPending(ctx context.Context, displayIDs []uuid.UUID) (map[uuid.UUID]int, error)
+	// Claim marks a pending, unexpired message delivered at the given time
+	// and counts the attempt. Only a message whose delivered_at is still
+	// unset can be claimed, so two servers never both deliver it; anything
+	// else is reported as a conflict.
+	Claim(ctx context.Context, id uuid.UUID, at time.Time) error
+	// Release returns a claimed message to pending after its delivery
+	// failed, recording why
+	Release(ctx context.Context, id uuid.UUID, reason string) error
+	// Requeue moves a dead message back to pending with a new expiry. A
+	// message in any other state is reported as a conflict.
+	Requeue(ctx context.Context, id uuid.UUID, expiresAt time.Time) error
+	// ExpirePending moves pending messages that expired by the given time
+	// to StateDead
+	ExpirePending(ctx context.Context, at time.Time) (int64, error)
+	// DeleteDelivered removes messages delivered before the given time
+	DeleteDelivered(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Sender delivers a control message over a display's open connection. It
+// fails with display.ErrNotConnected when this server holds no connection
+// for the display.
+type Sender interface {
+	SendControlMessage(displayID uuid.UUID, message *v1alpha1.ControlMessage) error
+}
+
+// Service defines the outbox operations
+type Service interface {
+	// Send delivers a message to a connected display, or queues it if the
+	// display is not connected. Queued messages are reported with an error
+	// wrapping ErrQueued; other delivery failures are returned as they are
+	// and nothing is queued.
+	Send(ctx context.Context, displayID uuid.UUID, message *v1alpha1.ControlMessage) error
+	// SendControlMessage is Send for callers that have no context, so the
+	// outbox can stand in for a Sender
+	SendControlMessage(displayID uuid.UUID, message *v1alpha1.ControlMessage) error
+	// Deliver sends a display's pending messages in order and returns how
+	// many were delivered. It stops at the first message that cannot be
+	// delivered so later messages never overtake it.
+	Deliver(ctx context.Context, displayID uuid.UUID) (int, error)
+	// DeliverConnected delivers the pending messages of every display for
+	// which connected reports true
+	DeliverConnected(ctx context.Context, connected func(displayID uuid.UUID) bool) error
+	// Get retrieves a message by ID
+	Get(ctx context.Context, id uuid.UUID) (*Message, error)
+	// List retrieves messages matching the filter in queue order
+	List(ctx context.Context, filter Filter) ([]*Message, error)
+	// CountPending counts the pending messages of each of the displays at
+	// once. Displays with none have no entry.
+	CountPending(ctx context.Context, displayIDs []uuid.UUID) (map[uuid.UUID]int, error)
+	// Replay queues a dead message again with a fresh expiry and tries to
+	// deliver it at once
+	Replay(ctx context.Context, id uuid.UUID) (*Message, error)
+	// ExpireStale turns expired pending messages into dead letters and
+	// removes old delivered ones
+	ExpireStale(ctx context.Context) error
+}
@@ -0,0 +1,281 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// DefaultTTL is how long a message waits for its display when no TTL is
+// configured
+const DefaultTTL = 24 * time.Hour
+
+// sendTimeout bounds the queueing done for callers without a context
+const sendTimeout = 5 * time.Second
+
+// ErrQueued reports that a message was queued because its display is not
+// connected
+var ErrQueued = errors.New("display not connected, message queued")
+
+type service struct {
+	repo   Repository
+	sender Sender
+	ttl    time.Duration
+	logger *slog.Logger
+
+	// delivering holds the displays whose queue this server is working
+	// through, so the connect hook and the periodic sweep never deliver
+	// the same display's messages side by side and out of order
+	mu         sync.Mutex
+	delivering map[uuid.UUID]bool
+}
+
+// NewService creates an outbox that delivers through sender. Messages not
+// delivered within ttl become dead letters; a ttl of zero or less uses
+// DefaultTTL.
+func NewService(repo Repository, sender Sender, ttl time.Duration, logger *slog.Logger) Service {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &service{
+		repo:       repo,
+		sender:     sender,
+		ttl:        ttl,
+		logger:     logger,
+		delivering: make(map[uuid.UUID]bool),
+	}
+}
+
+func (s *service) Send(ctx context.Context, displayID uuid.UUID, message *v1alpha1.ControlMessage) error {
+	const op = "OutboxService.Send"
+
+	err := s.sender.SendControlMessage(displayID, message)
+	if err == nil || !errors.Is(err, display.ErrNotConnected) {
+		return err
+	}
+
+	now := time.Now()
+	m := &Message{
+		ID:        uuid.New(),
+		DisplayID: displayID,
+		Message:   *message,
+		State:     StatePending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+	if err := s.repo.Create(ctx, m); err != nil {
+		return werrors.NewError("SAVE_FAILED", "Failed to queue control message", op, err)
+	}
+
+	s.logger.Info("queued control message for disconnected display",
+		"displayId", displayID,
+		"messageId", m.ID,
+		"type", message.Type,
+	)
+	return fmt.Errorf("%w: %s", ErrQueued, displayID)
+}
+
+func (s *service) SendControlMessage(displayID uuid.UUID, message *v1alpha1.ControlMessage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	return s.Send(ctx, displayID, message)
+}
+
+func (s *service) Deliver(ctx context.Context, displayID uuid.UUID) (int, error) {
+	const op = "OutboxService.Deliver"
+
+	if !s.startDelivery(displayID) {
+		return 0, nil
+	}
+	defer s.finishDelivery(displayID)
+
+	messages, err := s.repo.List(ctx, Filter{DisplayID: displayID, State: StatePending})
+	if err != nil {
+		return 0, werrors.NewError("LOOKUP_FAILED", "Failed to list queued messages", op, err)
+	}
+
+	delivered := 0
+	for _, m := range messages {
+		now := time.Now()
+		if !now.Before(m.ExpiresAt) {
+			// Left for ExpireStale to turn into a dead letter
+			continue
+		}
+
+		if err := s.repo.Claim(ctx, m.ID, now); err != nil {
+			if werrors.IsConflict(err) {
+				// Another server is delivering this display's queue
+				return delivered, nil
+			}
+			return delivered, werrors.NewError("SAVE_FAILED", "Failed to claim queued message", op, err)
+		}
+
+		if err := s.sender.SendControlMessage(displayID, &m.Message); err != nil {
+			if releaseErr := s.repo.Release(ctx, m.ID, err.Error()); releaseErr != nil {
+				s.logger.Error("failed to release undelivered message",
+					"error", releaseErr,
+					"displayId", displayID,
+					"messageId", m.ID,
+				)
+			}
+			s.logger.Debug("queued message not delivered",
+				"error", err,
+				"displayId", displayID,
+				"messageId", m.ID,
+			)
+			return delivered, nil
+		}
+		delivered++
+	}
+
+	if delivered > 0 {
+		s.logger.Info("delivered queued control messages",
+			"displayId", displayID,
+			"delivered", delivered,
+		)
+	}
+	return delivered, nil
+}
+
+func (s *service) DeliverConnected(ctx context.Context, connected func(displayID uuid.UUID) bool) error {
+	const op = "OutboxService.DeliverConnected"
+
+	messages, err := s.repo.List(ctx, Filter{State: StatePending})
+	if err != nil {
+		return werrors.NewError("LOOKUP_FAILED", "Failed to list queued messages", op, err)
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	for _, m := range messages {
+		if seen[m.DisplayID] {
+			continue
+		}
+		seen[m.DisplayID] = true
+		if !connected(m.DisplayID) {
+			continue
+		}
+		if _, err := s.Deliver(ctx, m.DisplayID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *service) Get(ctx context.Context, id uuid.UUID) (*Message, error) {
+	const op = "OutboxService.Get"
+
+	m, err := s.repo.Get(ctx, id)
+	if err != nil {
+		if werrors.IsNotFound(err) {
+			return nil, werrors.NewError("NOT_FOUND", fmt.Sprintf("Queued message not found: %s", id), op, err)
+		}
+		return nil, werrors.NewError("LOOKUP_FAILED", "Failed to retrieve queued message", op, err)
+	}
+	return m, nil
+}
+
+func (s *service) List(ctx context.Context, filter Filter) ([]*Message, error) {
+	const op = "OutboxService.List"
+
+	if filter.State != "" && !filter.State.Valid() {
+		return nil, werrors.NewError("INVALID_INPUT", fmt.Sprintf("unknown message state %q", filter.State), op, werrors.ErrInvalidInput)
+	}
+
+	messages, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, werrors.NewError("LOOKUP_FAILED", "Failed to list queued messages", op, err)
+	}
+	return messages, nil
+}
+
+func (s *service) CountPending(ctx context.Context, displayIDs []uuid.UUID) (map[uuid.UUID]int, error) {
+	const op = "OutboxService.CountPending"
+
+	if len(displayIDs) == 0 {
+		return map[uuid.UUID]int{}, nil
+	}
+	counts, err := s.repo.CountPending(ctx, displayIDs)
+	if err != nil {
+		return nil, werrors.NewError("LOOKUP_FAILED", "Failed to count queued messages", op, err)
+	}
+	return counts, nil
+}
+
+func (s *service) Replay(ctx context.Context, id uuid.UUID) (*Message, error) {
+	const op = "OutboxService.Replay"
+
+	m, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Requeue(ctx, id, time.Now().Add(s.ttl)); err != nil {
+		if werrors.IsConflict(err) {
+			return nil, werrors.NewError("INVALID_STATE", fmt.Sprintf("message is %s, not dead", m.State), op, err)
+		}
+		return nil, werrors.NewError("SAVE_FAILED", "Failed to requeue message", op, err)
+	}
+
+	// A connected display gets the message now; otherwise it waits in the
+	// queue like any other
+	if _, err := s.Deliver(ctx, m.DisplayID); err != nil {
+		s.logger.Warn("failed to deliver replayed message",
+			"error", err,
+			"displayId", m.DisplayID,
+			"messageId", id,
+		)
+	}
+
+	return s.Get(ctx, id)
+}
+
+func (s *service) ExpireStale(ctx context.Context) error {
+	const op = "OutboxService.ExpireStale"
+
+	now := time.Now()
+	expired, err := s.repo.ExpirePending(ctx, now)
+	if err != nil {
+		return werrors.NewError("EXPIRE_FAILED", "Failed to expire queued messages", op, err)
+	}
+	if expired > 0 {
+		s.logger.Warn("queued control messages expired undelivered",
+			"expired", expired,
+		)
+	}
+
+	// Delivered messages stay visible for one TTL so operators can see a
+	// queue drain
+	if _, err := s.repo.DeleteDelivered(ctx, now.Add(-s.ttl)); err != nil {
+		return werrors.NewError("EXPIRE_FAILED", "Failed to remove delivered messages", op, err)
+	}
+	return nil
+}
+
+// startDelivery reserves a display's queue for this server, reporting
+// false if it is already being delivered
+func (s *service) startDelivery(displayID uuid.UUID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.delivering[displayID] {
+		return false
+	}
+	s.delivering[displayID] = true
+	return true
+}
+
+// finishDelivery releases a display's queue reserved by startDelivery
+func (s *service) finishDelivery(displayID uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.delivering, displayID)
+}
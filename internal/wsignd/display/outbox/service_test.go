@@ -0,0 +1,272 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/outbox"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// fakeSender stands in for one server's control sockets. It records the
+// URLs of the messages it delivered.
+type fakeSender struct {
+	mu        sync.Mutex
+	connected bool
+	failAfter int // deliveries before every send fails; zero never fails
+	sent      []string
+}
+
+func (s *fakeSender) SendControlMessage(displayID uuid.UUID, message *v1alpha1.ControlMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.connected {
+		return fmt.Errorf("%w: %s", display.ErrNotConnected, displayID)
+	}
+	if s.failAfter > 0 && len(s.sent) >= s.failAfter {
+		return errors.New("send queue overflow")
+	}
+	s.sent = append(s.sent, message.Sequence.Items[0].URL)
+	return nil
+}
+
+func (s *fakeSender) connect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connected = true
+}
+
+func (s *fakeSender) delivered() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.sent...)
+}
+
+func sequenceMessage(url string) *v1alpha1.ControlMessage {
+	return &v1alpha1.ControlMessage{
+		Type:      v1alpha1.ControlMessageSequenceUpdate,
+		Sequence:  &v1alpha1.ContentSequence{Items: []v1alpha1.ContentItem{{URL: url}}},
+		Timestamp: time.Now(),
+	}
+}
+
+func urls(n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = fmt.Sprintf("https://example.com/%d", i)
+	}
+	return out
+}
+
+func TestQueueThenConnect(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	sender := &fakeSender{}
+	service := outbox.NewService(memory.NewOutboxRepository(), sender, time.Hour, logger)
+	displayID := uuid.New()
+
+	for _, url := range urls(5) {
+		err := service.Send(ctx, displayID, sequenceMessage(url))
+		require.ErrorIs(t, err, outbox.ErrQueued)
+	}
+
+	// Nothing reaches a display that is still away
+	delivered, err := service.Deliver(ctx, displayID)
+	require.NoError(t, err)
+	assert.Zero(t, delivered)
+
+	sender.connect()
+	delivered, err = service.Deliver(ctx, displayID)
+	require.NoError(t, err)
+	assert.Equal(t, 5, delivered)
+	assert.Equal(t, urls(5), sender.delivered())
+
+	messages, err := service.List(ctx, outbox.Filter{DisplayID: displayID})
+	require.NoError(t, err)
+	require.Len(t, messages, 5)
+	for _, m := range messages {
+		assert.Equal(t, outbox.StateDelivered, m.State)
+		assert.False(t, m.DeliveredAt.IsZero())
+	}
+
+	// Delivered messages are not sent again, and connected displays are
+	// sent new messages directly
+	delivered, err = service.Deliver(ctx, displayID)
+	require.NoError(t, err)
+	assert.Zero(t, delivered)
+	require.NoError(t, service.Send(ctx, displayID, sequenceMessage("https://example.com/direct")))
+	assert.Len(t, sender.delivered(), 6)
+}
+
+func TestCountPending(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	sender := &fakeSender{}
+	service := outbox.NewService(memory.NewOutboxRepository(), sender, time.Hour, logger)
+	busy, delivered, idle := uuid.New(), uuid.New(), uuid.New()
+
+	for _, url := range urls(3) {
+		require.ErrorIs(t, service.Send(ctx, busy, sequenceMessage(url)), outbox.ErrQueued)
+	}
+	require.ErrorIs(t, service.Send(ctx, delivered, sequenceMessage("https://example.com/once")), outbox.ErrQueued)
+	sender.connect()
+	_, err := service.Deliver(ctx, delivered)
+	require.NoError(t, err)
+
+	counts, err := service.CountPending(ctx, []uuid.UUID{busy, delivered, idle})
+	require.NoError(t, err)
+	assert.Equal(t, map[uuid.UUID]int{busy: 3}, counts, "only pending messages count")
+
+	counts, err = service.CountPending(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, counts)
+}
+
+func TestDeliverStopsAtFailure(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	sender := &fakeSender{}
+	service := outbox.NewService(memory.NewOutboxRepository(), sender, time.Hour, logger)
+	displayID := uuid.New()
+
+	for _, url := range urls(3) {
+		require.ErrorIs(t, service.Send(ctx, displayID, sequenceMessage(url)), outbox.ErrQueued)
+	}
+
+	sender.connect()
+	sender.failAfter = 1
+	delivered, err := service.Deliver(ctx, displayID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+
+	pending, err := service.List(ctx, outbox.Filter{DisplayID: displayID, State: outbox.StatePending})
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, "send queue overflow", pending[0].LastError)
+	// The message behind the failure was never tried
+	assert.Zero(t, pending[1].Attempts)
+
+	sender.failAfter = 0
+	delivered, err = service.Deliver(ctx, displayID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, delivered)
+	assert.Equal(t, urls(3), sender.delivered())
+}
+
+func TestExpiryAndReplay(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	sender := &fakeSender{}
+	const ttl = 50 * time.Millisecond
+	service := outbox.NewService(memory.NewOutboxRepository(), sender, ttl, logger)
+	displayID := uuid.New()
+
+	require.ErrorIs(t, service.Send(ctx, displayID, sequenceMessage("https://example.com/fix")), outbox.ErrQueued)
+	time.Sleep(2 * ttl)
+
+	// An expired message is not delivered even before the expiry job runs
+	sender.connect()
+	delivered, err := service.Deliver(ctx, displayID)
+	require.NoError(t, err)
+	assert.Zero(t, delivered)
+
+	require.NoError(t, service.ExpireStale(ctx))
+	dead, err := service.List(ctx, outbox.Filter{DisplayID: displayID, State: outbox.StateDead})
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Zero(t, dead[0].Attempts)
+
+	replayed, err := service.Replay(ctx, dead[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, outbox.StateDelivered, replayed.State)
+	assert.Equal(t, []string{"https://example.com/fix"}, sender.delivered())
+
+	_, err = service.Replay(ctx, dead[0].ID)
+	assert.ErrorIs(t, err, werrors.ErrConflict)
+
+	_, err = service.Replay(ctx, uuid.New())
+	assert.ErrorIs(t, err, werrors.ErrNotFound)
+
+	// Delivered messages are removed once they are a TTL old
+	time.Sleep(2 * ttl)
+	require.NoError(t, service.ExpireStale(ctx))
+	remaining, err := service.List(ctx, outbox.Filter{DisplayID: displayID})
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+}
+
+// TestConcurrentDelivery runs two servers sharing one queue, with the
+// display connected to both, and checks every message is delivered exactly
+// once and in order on each server
+func TestConcurrentDelivery(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	repo := memory.NewOutboxRepository()
+	displayID := uuid.New()
+
+	queued := outbox.NewService(repo, &fakeSender{}, time.Hour, logger)
+	const n = 200
+	for _, url := range urls(n) {
+		require.ErrorIs(t, queued.Send(ctx, displayID, sequenceMessage(url)), outbox.ErrQueued)
+	}
+
+	senders := []*fakeSender{{connected: true}, {connected: true}}
+	var wg sync.WaitGroup
+	for _, sender := range senders {
+		service := outbox.NewService(repo, sender, time.Hour, logger)
+		// Each server runs its connect hook and its sweep at once
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					delivered, err := service.Deliver(ctx, displayID)
+					assert.NoError(t, err)
+					pending, err := repo.List(ctx, outbox.Filter{State: outbox.StatePending})
+					assert.NoError(t, err)
+					if delivered == 0 && len(pending) == 0 {
+						return
+					}
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	seen := make(map[string]int)
+	for _, sender := range senders {
+		got := sender.delivered()
+		for i := 1; i < len(got); i++ {
+			assert.Less(t, index(got[i-1]), index(got[i]), "messages out of order")
+		}
+		for _, url := range got {
+			seen[url]++
+		}
+	}
+	assert.Len(t, seen, n)
+	for url, count := range seen {
+		assert.Equal(t, 1, count, url)
+	}
+}
+
+// index recovers the position of a URL made by urls
+func index(url string) int {
+	var i int
+	_, _ = fmt.Sscanf(url, "https://example.com/%d", &i)
+	return i
+}
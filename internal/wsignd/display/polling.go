@@ -0,0 +1,148 @@
+package display
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Bounds on the polling intervals handed to displays. Heartbeats more often
+// than a connected display's last-seen updates are recorded gain nothing.
+const (
+	MinHeartbeatInterval   = 15 * time.Second
+	MaxHeartbeatInterval   = time.Hour
+	MinContentPollInterval = 30 * time.Second
+	MaxContentPollInterval = 24 * time.Hour
+)
+
+// PollingHints are the intervals a display is asked to check in and to look
+// for new content at
+type PollingHints struct {
+	HeartbeatInterval   time.Duration
+	ContentPollInterval time.Duration
+}
+
+// PollingPolicy assigns polling hints to displays by site
+type PollingPolicy struct {
+	// Default applies to sites without an override
+	Default PollingHints
+	// Sites overrides Default by site ID. An interval an override leaves
+	// zero is taken from Default.
+	Sites map[string]PollingHints
+}
+
+// DefaultPollingPolicy returns the hints used when none are configured
+func DefaultPollingPolicy() PollingPolicy {
+	return PollingPolicy{
+		Default: PollingHints{
+			HeartbeatInterval:   time.Minute,
+			ContentPollInterval: 5 * time.Minute,
+		},
+	}
+}
+
+// For returns the hints for displays at siteID
+func (p PollingPolicy) For(siteID string) PollingHints {
+	hints := p.Default
+	if site, ok := p.Sites[siteID]; ok {
+		if site.HeartbeatInterval != 0 {
+			hints.HeartbeatInterval = site.HeartbeatInterval
+		}
+		if site.ContentPollInterval != 0 {
+			hints.ContentPollInterval = site.ContentPollInterval
+		}
+	}
+	return hints
+}
+
+// Validate checks that every interval is within bounds and that displays
+// following the policy check in before liveness would mark them offline
+func (p PollingPolicy) Validate(liveness Liveness) error {
+	if err := p.Default.validate(liveness, false); err != nil {
+		return fmt.Errorf("default %w", err)
+	}
+	for site, hints := range p.Sites {
+		if site == "" {
+			return fmt.Errorf("site override without a site ID")
+		}
+		if err := hints.validate(liveness, true); err != nil {
+			return fmt.Errorf("site %s %w", site, err)
+		}
+	}
+	return nil
+}
+
+// validate checks the hints' intervals. An override may leave an interval
+// zero to inherit it.
+func (h PollingHints) validate(liveness Liveness, override bool) error {
+	if !(override && h.HeartbeatInterval == 0) {
+		if h.HeartbeatInterval < MinHeartbeatInterval || h.HeartbeatInterval > MaxHeartbeatInterval {
+			return fmt.Errorf("heartbeat interval %s must be between %s and %s", h.HeartbeatInterval, MinHeartbeatInterval, MaxHeartbeatInterval)
+		}
+		if liveness.OfflineAfter > 0 && h.HeartbeatInterval >= liveness.OfflineAfter {
+			return fmt.Errorf("heartbeat interval %s must be shorter than the %s after which displays are considered offline", h.HeartbeatInterval, liveness.OfflineAfter)
+		}
+	}
+	if !(override && h.ContentPollInterval == 0) {
+		if h.ContentPollInterval < MinContentPollInterval || h.ContentPollInterval > MaxContentPollInterval {
+			return fmt.Errorf("content poll interval %s must be between %s and %s", h.ContentPollInterval, MinContentPollInterval, MaxContentPollInterval)
+		}
+	}
+	return nil
+}
+
+// Polling holds the polling policy in effect. Operators may replace it while
+// the server runs, for example to slow every display down during an
+// incident; the change lasts until the server restarts.
+type Polling struct {
+	liveness Liveness
+
+	mu     sync.RWMutex
+	policy PollingPolicy
+}
+
+// NewPolling returns a holder for policy, which must be valid for liveness
+func NewPolling(policy PollingPolicy, liveness Liveness) (*Polling, error) {
+	if err := policy.Validate(liveness); err != nil {
+		return nil, err
+	}
+	return &Polling{liveness: liveness, policy: policy.clone()}, nil
+}
+
+// Policy returns the policy in effect
+func (p *Polling) Policy() PollingPolicy {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.policy.clone()
+}
+
+// For returns the hints for displays at siteID
+func (p *Polling) For(siteID string) PollingHints {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.policy.For(siteID)
+}
+
+// SetPolicy replaces the policy in effect. An invalid policy is rejected and
+// the current one kept.
+func (p *Polling) SetPolicy(policy PollingPolicy) error {
+	if err := policy.Validate(p.liveness); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.policy = policy.clone()
+	return nil
+}
+
+// clone returns a copy of the policy that shares no map with it
+func (p PollingPolicy) clone() PollingPolicy {
+	c := PollingPolicy{Default: p.Default}
+	if len(p.Sites) > 0 {
+		c.Sites = make(map[string]PollingHints, len(p.Sites))
+		for site, hints := range p.Sites {
+			c.Sites[site] = hints
+		}
+	}
+	return c
+}
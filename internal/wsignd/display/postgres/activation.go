@@ -0,0 +1,204 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/database"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/activation"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// ActivationRepository implements activation.Repository using PostgreSQL
+type ActivationRepository struct {
+	db *sql.DB
+}
+
+// NewActivationRepository creates a new PostgreSQL device code repository
+func NewActivationRepository(db *sql.DB) activation.Repository {
+	return &ActivationRepository{db: db}
+}
+
+// Save stores a newly issued device code
+func (r *ActivationRepository) Save(ctx context.Context, code *activation.DeviceCode) error {
+	const op = "ActivationRepository.Save"
+
+	kind := code.Kind
+	if kind == "" {
+		kind = activation.KindDeviceCode
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO device_codes (
+			id, kind, device_code, user_code, expires_at,
+			poll_interval, display_id, allowed_site, created_at,
+			hint_site_id, hint_zone, hint_position, hint_name
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`,
+		code.ID,
+		kind,
+		code.DeviceCode,
+		sql.NullString{String: code.UserCode, Valid: code.UserCode != ""},
+		code.ExpiresAt,
+		code.PollInterval,
+		uuid.NullUUID{UUID: code.DisplayID, Valid: code.DisplayID != uuid.Nil},
+		sql.NullString{String: code.AllowedSite, Valid: code.AllowedSite != ""},
+		code.CreatedAt,
+		nullString(code.Hints.Location.SiteID),
+		nullString(code.Hints.Location.Zone),
+		nullString(code.Hints.Location.Position),
+		nullString(code.Hints.Name),
+	)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+
+	return nil
+}
+
+// nullString stores an empty string, a value that was not given, as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// FindByDeviceCode retrieves a code by the secret device code
+func (r *ActivationRepository) FindByDeviceCode(ctx context.Context, deviceCode string) (*activation.DeviceCode, error) {
+	const op = "ActivationRepository.FindByDeviceCode"
+	return r.find(ctx, op, "device_code", deviceCode)
+}
+
+// FindByUserCode retrieves a code by the user code shown on screen
+func (r *ActivationRepository) FindByUserCode(ctx context.Context, userCode string) (*activation.DeviceCode, error) {
+	const op = "ActivationRepository.FindByUserCode"
+	return r.find(ctx, op, "user_code", userCode)
+}
+
+// find looks up a device code by one of its unique columns
+func (r *ActivationRepository) find(ctx context.Context, op, column, value string) (*activation.DeviceCode, error) {
+	var code activation.DeviceCode
+	var userCode sql.NullString
+	var displayID uuid.NullUUID
+	var allowedSite sql.NullString
+	var hintSite, hintZone, hintPosition, hintName sql.NullString
+
+	// column is one of a fixed set of identifiers, never user input
+	err := r.db.QueryRowContext(ctx, `
+		SELECT
+			id, kind, device_code, user_code, expires_at,
+			poll_interval, activated, redeemed, display_id, allowed_site, created_at,
+			hint_site_id, hint_zone, hint_position, hint_name
+		FROM device_codes
+		WHERE `+column+` = $1
+	`, value).Scan(
+		&code.ID,
+		&code.Kind,
+		&code.DeviceCode,
+		&userCode,
+		&code.ExpiresAt,
+		&code.PollInterval,
+		&code.Activated,
+		&code.Redeemed,
+		&displayID,
+		&allowedSite,
+		&code.CreatedAt,
+		&hintSite,
+		&hintZone,
+		&hintPosition,
+		&hintName,
+	)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+	code.UserCode = userCode.String
+	if displayID.Valid {
+		code.DisplayID = displayID.UUID
+	}
+	code.AllowedSite = allowedSite.String
+	code.Hints.Location.SiteID = hintSite.String
+	code.Hints.Location.Zone = hintZone.String
+	code.Hints.Location.Position = hintPosition.String
+	code.Hints.Name = hintName.String
+
+	return &code, nil
+}
+
+// MarkActivated binds a code to its display. A code that is already
+// activated is reported as a conflict.
+func (r *ActivationRepository) MarkActivated(ctx context.Context, id uuid.UUID, displayID uuid.UUID) error {
+	const op = "ActivationRepository.MarkActivated"
+
+	err := database.RunInTx(ctx, r.db, nil, func(tx *database.Tx) error {
+		var activated bool
+		err := tx.QueryRowContext(ctx,
+			"SELECT activated FROM device_codes WHERE id = $1 FOR UPDATE", id,
+		).Scan(&activated)
+		if err != nil {
+			return err
+		}
+		if activated {
+			return werrors.NewError("CONFLICT", "device code already activated", op, werrors.ErrConflict)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE device_codes
+			SET activated = TRUE, display_id = $2
+			WHERE id = $1
+		`, id, displayID)
+		return err
+	})
+	if err != nil {
+		if werrors.IsConflict(err) {
+			return err
+		}
+		return database.MapError(err, op)
+	}
+
+	return nil
+}
+
+// MarkRedeemed records that a token has been issued for a code. A code
+// that is already redeemed is reported as a conflict.
+func (r *ActivationRepository) MarkRedeemed(ctx context.Context, id uuid.UUID) error {
+	const op = "ActivationRepository.MarkRedeemed"
+
+	err := database.RunInTx(ctx, r.db, nil, func(tx *database.Tx) error {
+		var redeemed bool
+		err := tx.QueryRowContext(ctx,
+			"SELECT redeemed FROM device_codes WHERE id = $1 FOR UPDATE", id,
+		).Scan(&redeemed)
+		if err != nil {
+			return err
+		}
+		if redeemed {
+			return werrors.NewError("CONFLICT", "device code already redeemed", op, werrors.ErrConflict)
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE device_codes SET redeemed = TRUE WHERE id = $1", id)
+		return err
+	})
+	if err != nil {
+		if werrors.IsConflict(err) {
+			return err
+		}
+		return database.MapError(err, op)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes codes that expired before the given time
+func (r *ActivationRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	const op = "ActivationRepository.DeleteExpired"
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM device_codes WHERE expires_at < $1", before)
+	if err != nil {
+		return 0, database.MapError(err, op)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, database.MapError(err, op)
+	}
+	return n, nil
+}
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/activation"
+	"github.com/wrale/wrale-signage/internal/wsignd/testutil"
+)
+
+func TestActivationRepositoryHints(t *testing.T) {
+	db, cleanup := testutil.SetupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	repo := NewActivationRepository(db)
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	save := func(userCode string, hints activation.Hints) {
+		require.NoError(t, repo.Save(ctx, &activation.DeviceCode{
+			ID:           uuid.New(),
+			Kind:         activation.KindDeviceCode,
+			DeviceCode:   uuid.NewString(),
+			UserCode:     userCode,
+			ExpiresAt:    now.Add(time.Minute),
+			PollInterval: 5,
+			Hints:        hints,
+			CreatedAt:    now,
+		}))
+	}
+
+	hinted := activation.Hints{
+		Location: display.Location{SiteID: "hq", Zone: "lobby"},
+		Name:     "lobby-north-47",
+	}
+	save("BLUE-FISH", hinted)
+	save("CAKE-MOON", activation.Hints{})
+
+	code, err := repo.FindByUserCode(ctx, "BLUE-FISH")
+	require.NoError(t, err)
+	assert.Equal(t, hinted, code.Hints)
+
+	code, err = repo.FindByUserCode(ctx, "CAKE-MOON")
+	require.NoError(t, err)
+	assert.True(t, code.Hints.Empty())
+}
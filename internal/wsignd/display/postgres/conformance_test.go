@@ -0,0 +1,17 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/repotest"
+	"github.com/wrale/wrale-signage/internal/wsignd/testutil"
+)
+
+func TestRepositoryConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) display.Repository {
+		db, cleanup := testutil.SetupTestDB(t)
+		t.Cleanup(cleanup)
+		return NewRepository(db)
+	})
+}
@@ -0,0 +1,149 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/database"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+)
+
+// AppendContentTransition records a content transition and trims the display's
+// history to the newest keep entries in the same transaction. The display row
+// is locked so that concurrent appends for one display are serialized and
+// always see each other's URLs.
+func (r *Repository) AppendContentTransition(ctx context.Context, t *display.ContentTransition, keep int) error {
+	const op = "DisplayRepository.AppendContentTransition"
+
+	err := database.RunInTx(ctx, r.db, nil, func(tx *database.Tx) error {
+		var id uuid.UUID
+		err := tx.QueryRowContext(ctx, `
+			SELECT id FROM displays WHERE id = $1 FOR UPDATE
+		`, t.DisplayID).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx, `
+			SELECT to_url
+			FROM display_content_history
+			WHERE display_id = $1
+			ORDER BY id DESC
+			LIMIT 1
+		`, t.DisplayID).Scan(&t.FromURL)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO display_content_history (
+				display_id, changed_at, from_url, to_url, trigger
+			) VALUES ($1, $2, $3, $4, $5)
+		`,
+			t.DisplayID,
+			t.Timestamp,
+			t.FromURL,
+			t.ToURL,
+			t.Trigger,
+		)
+		if err != nil {
+			return err
+		}
+
+		// Keep only the newest entries for this display
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM display_content_history
+			WHERE display_id = $1
+			  AND id NOT IN (
+				SELECT id FROM display_content_history
+				WHERE display_id = $1
+				ORDER BY id DESC
+				LIMIT $2
+			  )
+		`, t.DisplayID, keep)
+		return err
+	})
+
+	if err != nil {
+		return database.MapError(err, op)
+	}
+
+	return nil
+}
+
+// ListContentTransitions retrieves up to limit of a display's content
+// transitions, newest first. Insertion order is used rather than timestamps so
+// that transitions recorded within the same instant keep a stable order.
+func (r *Repository) ListContentTransitions(ctx context.Context, displayID uuid.UUID, limit int) ([]*display.ContentTransition, error) {
+	const op = "DisplayRepository.ListContentTransitions"
+
+	rows, err := r.reader.QueryContext(ctx, `
+		SELECT display_id, changed_at, from_url, to_url, trigger
+		FROM display_content_history
+		WHERE display_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`, displayID, limit)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+	defer rows.Close()
+
+	var history []*display.ContentTransition
+	for rows.Next() {
+		var t display.ContentTransition
+		if err := rows.Scan(&t.DisplayID, &t.Timestamp, &t.FromURL, &t.ToURL, &t.Trigger); err != nil {
+			return nil, database.MapError(err, op)
+		}
+		history = append(history, &t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	return history, nil
+}
+
+// LatestContentTransitions retrieves the newest transition of each of the
+// displays in one query, read from the history index newest first
+func (r *Repository) LatestContentTransitions(ctx context.Context, displayIDs []uuid.UUID) (map[uuid.UUID]*display.ContentTransition, error) {
+	const op = "DisplayRepository.LatestContentTransitions"
+
+	rows, err := r.reader.QueryContext(ctx, `
+		SELECT DISTINCT ON (display_id) display_id, changed_at, from_url, to_url, trigger
+		FROM display_content_history
+		WHERE display_id = ANY($1::uuid[])
+		ORDER BY display_id, id DESC
+	`, pq.Array(uuidStrings(displayIDs)))
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+	defer rows.Close()
+
+	latest := make(map[uuid.UUID]*display.ContentTransition)
+	for rows.Next() {
+		var t display.ContentTransition
+		if err := rows.Scan(&t.DisplayID, &t.Timestamp, &t.FromURL, &t.ToURL, &t.Trigger); err != nil {
+			return nil, database.MapError(err, op)
+		}
+		latest[t.DisplayID] = &t
+	}
+	if err := rows.Err(); err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	return latest, nil
+}
+
+// uuidStrings renders IDs for a uuid[] parameter
+func uuidStrings(ids []uuid.UUID) []string {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = id.String()
+	}
+	return strs
+}
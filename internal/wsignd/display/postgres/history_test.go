@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/testutil"
+)
+
+func TestContentHistory(t *testing.T) {
+	db, cleanup := testutil.SetupTestDB(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	d, err := display.NewDisplay("lobby-north", display.Location{SiteID: "hq", Zone: "lobby"})
+	require.NoError(t, err)
+	require.NoError(t, repo.Save(ctx, d))
+
+	const keep = 5
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	t.Run("cap and ordering", func(t *testing.T) {
+		// Every transition shares a timestamp so ordering relies on insertion
+		for i := 0; i < 50; i++ {
+			err := repo.AppendContentTransition(ctx, &display.ContentTransition{
+				DisplayID: d.ID,
+				Timestamp: now,
+				ToURL:     fmt.Sprintf("https://example.com/%d", i),
+				Trigger:   display.TriggerSequence,
+			}, keep)
+			require.NoError(t, err)
+		}
+
+		history, err := repo.ListContentTransitions(ctx, d.ID, 100)
+		require.NoError(t, err)
+		require.Len(t, history, keep)
+		for i, tr := range history {
+			assert.Equal(t, fmt.Sprintf("https://example.com/%d", 49-i), tr.ToURL)
+			assert.Equal(t, fmt.Sprintf("https://example.com/%d", 48-i), tr.FromURL)
+		}
+
+		var rows int
+		require.NoError(t, db.QueryRow(
+			"SELECT COUNT(*) FROM display_content_history WHERE display_id = $1", d.ID,
+		).Scan(&rows))
+		assert.Equal(t, keep, rows)
+	})
+
+	t.Run("concurrent appends stay capped", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				assert.NoError(t, repo.AppendContentTransition(ctx, &display.ContentTransition{
+					DisplayID: d.ID,
+					Timestamp: time.Now(),
+					ToURL:     fmt.Sprintf("https://example.com/c%d", i),
+					Trigger:   display.TriggerAssignmentChange,
+				}, keep))
+			}(i)
+		}
+		wg.Wait()
+
+		history, err := repo.ListContentTransitions(ctx, d.ID, 100)
+		require.NoError(t, err)
+		require.Len(t, history, keep)
+		// Each entry continues from the one before it
+		for i := 0; i < len(history)-1; i++ {
+			assert.Equal(t, history[i+1].ToURL, history[i].FromURL)
+		}
+	})
+
+	t.Run("unknown display", func(t *testing.T) {
+		err := repo.AppendContentTransition(ctx, &display.ContentTransition{
+			DisplayID: uuid.New(),
+			Timestamp: now,
+			ToURL:     "https://example.com/",
+			Trigger:   display.TriggerSequence,
+		}, keep)
+		assert.True(t, werrors.IsNotFound(err))
+	})
+
+	t.Run("history removed with display", func(t *testing.T) {
+		require.NoError(t, repo.Delete(ctx, d.ID))
+		history, err := repo.ListContentTransitions(ctx, d.ID, 100)
+		require.NoError(t, err)
+		assert.Empty(t, history)
+	})
+}
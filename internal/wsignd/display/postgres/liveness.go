@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/database"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+)
+
+// findStaleQuery selects active displays silent since $1, longest silent
+// first. It must keep matching the predicate of displays_active_last_seen_idx
+// so that it never reads the rest of the table.
+const findStaleQuery = "SELECT " + displayColumns + `
+	FROM displays
+	WHERE state = 'ACTIVE'
+	  AND last_seen < $1
+	ORDER BY last_seen
+	LIMIT $2`
+
+// FindStale retrieves up to limit active displays that have not checked in
+// since olderThan, longest silent first
+func (r *Repository) FindStale(ctx context.Context, olderThan time.Time, limit int) ([]*display.Display, error) {
+	const op = "DisplayRepository.FindStale"
+
+	rows, err := r.db.QueryContext(ctx, findStaleQuery, olderThan, limit)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+	defer rows.Close()
+
+	var displays []*display.Display
+	for rows.Next() {
+		d, err := scanDisplay(rows)
+		if err != nil {
+			return nil, database.MapError(err, op)
+		}
+		displays = append(displays, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	return displays, nil
+}
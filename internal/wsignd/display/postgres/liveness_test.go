@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/testutil"
+)
+
+// TestStateQueriesUseIndexes guards the reaper and state count queries
+// against schema changes that would send them back to reading every display
+func TestStateQueriesUseIndexes(t *testing.T) {
+	db, cleanup := testutil.SetupTestDB(t)
+	defer cleanup()
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	// 5000 displays across 20 sites: every 100th is offline and every 97th
+	// has been silent for two hours, leaving 51 stale active displays
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO displays (id, name, site_id, zone, position, state, last_seen)
+		SELECT md5(i::text)::uuid, 'display-' || i, 'site-' || (i % 20), 'lobby', 'main',
+			CASE WHEN i % 100 = 0 THEN 'OFFLINE' ELSE 'ACTIVE' END,
+			CASE WHEN i % 97 = 0 THEN NOW() - INTERVAL '2 hours' ELSE NOW() END
+		FROM generate_series(1, 5000) AS i
+	`)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "ANALYZE displays")
+	require.NoError(t, err)
+
+	cutoff := time.Now().Add(-time.Hour)
+
+	t.Run("find stale", func(t *testing.T) {
+		plan := explain(t, db, findStaleQuery, cutoff, 500)
+		assert.Contains(t, plan, "displays_active_last_seen_idx", plan)
+		assert.NotContains(t, plan, "Seq Scan on displays", plan)
+
+		start := time.Now()
+		stale, err := repo.FindStale(ctx, cutoff, 500)
+		require.NoError(t, err)
+		assert.Less(t, time.Since(start), time.Second)
+		require.Len(t, stale, 51)
+		for i, d := range stale {
+			assert.Equal(t, display.StateActive, d.State)
+			if i > 0 {
+				assert.False(t, d.LastSeen.Before(stale[i-1].LastSeen))
+			}
+		}
+
+		batch, err := repo.FindStale(ctx, cutoff, 20)
+		require.NoError(t, err)
+		assert.Len(t, batch, 20)
+	})
+
+	t.Run("count by state", func(t *testing.T) {
+		filter := display.DisplayFilter{States: []display.State{display.StateOffline}}
+		where, args, err := filterClause(filter)
+		require.NoError(t, err)
+		plan := explain(t, db, countByStateQuery(where), args...)
+		assert.Contains(t, plan, "displays_state_last_seen_idx", plan)
+		assert.NotContains(t, plan, "Seq Scan on displays", plan)
+
+		start := time.Now()
+		counts, err := repo.CountByState(ctx, filter)
+		require.NoError(t, err)
+		assert.Less(t, time.Since(start), time.Second)
+		var total int
+		for _, c := range counts {
+			assert.Equal(t, display.StateOffline, c.State)
+			total += c.Count
+		}
+		assert.Equal(t, 50, total)
+
+		all, err := repo.CountByState(ctx, display.DisplayFilter{})
+		require.NoError(t, err)
+		total = 0
+		for _, c := range all {
+			total += c.Count
+		}
+		assert.Equal(t, 5000, total)
+	})
+}
+
+// explain returns the plan PostgreSQL chooses for query
+func explain(t *testing.T, db *sql.DB, query string, args ...interface{}) string {
+	t.Helper()
+
+	rows, err := db.Query("EXPLAIN "+query, args...)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		require.NoError(t, rows.Scan(&line))
+		lines = append(lines, line)
+	}
+	require.NoError(t, rows.Err())
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,284 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/database"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/outbox"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+const outboxColumns = `
+	id, seq, display_id, message, state, attempts, last_error,
+	created_at, expires_at, delivered_at`
+
+// OutboxRepository implements outbox.Repository using PostgreSQL
+type OutboxRepository struct {
+	db *sql.DB
+}
+
+// NewOutboxRepository creates a new PostgreSQL outbox repository
+func NewOutboxRepository(db *sql.DB) outbox.Repository {
+	return &OutboxRepository{db: db}
+}
+
+// Create stores a new message, setting its Seq
+func (r *OutboxRepository) Create(ctx context.Context, m *outbox.Message) error {
+	const op = "OutboxRepository.Create"
+
+	message, err := json.Marshal(m.Message)
+	if err != nil {
+		return werrors.NewError("INVALID_INPUT", "failed to encode control message", op, err)
+	}
+
+	err = r.db.QueryRowContext(ctx, `
+		INSERT INTO pending_messages (
+			id, display_id, message, state, created_at, expires_at
+		) VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING seq
+	`,
+		m.ID,
+		m.DisplayID,
+		message,
+		m.State,
+		m.CreatedAt,
+		m.ExpiresAt,
+	).Scan(&m.Seq)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+
+	return nil
+}
+
+// Get retrieves a message by ID
+func (r *OutboxRepository) Get(ctx context.Context, id uuid.UUID) (*outbox.Message, error) {
+	const op = "OutboxRepository.Get"
+
+	m, err := scanOutboxMessage(r.db.QueryRowContext(ctx, `
+		SELECT `+outboxColumns+`
+		FROM pending_messages
+		WHERE id = $1
+	`, id))
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	return m, nil
+}
+
+// List retrieves messages matching the filter in queue order
+func (r *OutboxRepository) List(ctx context.Context, filter outbox.Filter) ([]*outbox.Message, error) {
+	const op = "OutboxRepository.List"
+
+	var conditions []string
+	var args []interface{}
+	if filter.DisplayID != uuid.Nil {
+		args = append(args, filter.DisplayID)
+		conditions = append(conditions, fmt.Sprintf("display_id = $%d", len(args)))
+	}
+	if filter.State != "" {
+		args = append(args, filter.State)
+		conditions = append(conditions, fmt.Sprintf("state = $%d", len(args)))
+	}
+
+	query := `SELECT ` + outboxColumns + ` FROM pending_messages`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY seq"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+	defer rows.Close()
+
+	var messages []*outbox.Message
+	for rows.Next() {
+		m, err := scanOutboxMessage(rows)
+		if err != nil {
+			return nil, database.MapError(err, op)
+		}
+		messages = append(messages, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	return messages, nil
+}
+
+// CountPending counts the pending messages of each of the displays with
+// one grouped query
+func (r *OutboxRepository) CountPending(ctx context.Context, displayIDs []uuid.UUID) (map[uuid.UUID]int, error) {
+	const op = "OutboxRepository.CountPending"
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT display_id, COUNT(*)
+		FROM pending_messages
+		WHERE display_id = ANY($1::uuid[]) AND state = $2
+		GROUP BY display_id
+	`, pq.Array(uuidStrings(displayIDs)), outbox.StatePending)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+	defer rows.Close()
+
+	counts := make(map[uuid.UUID]int)
+	for rows.Next() {
+		var id uuid.UUID
+		var n int
+		if err := rows.Scan(&id, &n); err != nil {
+			return nil, database.MapError(err, op)
+		}
+		counts[id] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	return counts, nil
+}
+
+// Claim marks a pending, unexpired message delivered. The update only
+// matches while delivered_at is unset, so of two servers claiming the same
+// message exactly one succeeds.
+func (r *OutboxRepository) Claim(ctx context.Context, id uuid.UUID, at time.Time) error {
+	const op = "OutboxRepository.Claim"
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE pending_messages
+		SET state = $3, delivered_at = $2, attempts = attempts + 1
+		WHERE id = $1 AND state = $4 AND delivered_at IS NULL AND expires_at > $2
+	`, id, at, outbox.StateDelivered, outbox.StatePending)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+	return r.requireUpdated(ctx, op, result, id, "message is not pending")
+}
+
+// Release returns a claimed message to pending
+func (r *OutboxRepository) Release(ctx context.Context, id uuid.UUID, reason string) error {
+	const op = "OutboxRepository.Release"
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE pending_messages
+		SET state = $2, delivered_at = NULL, last_error = $3
+		WHERE id = $1
+	`, id, outbox.StatePending, reason)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+	return r.requireUpdated(ctx, op, result, id, "message not found")
+}
+
+// Requeue moves a dead message back to pending
+func (r *OutboxRepository) Requeue(ctx context.Context, id uuid.UUID, expiresAt time.Time) error {
+	const op = "OutboxRepository.Requeue"
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE pending_messages
+		SET state = $2, expires_at = $3
+		WHERE id = $1 AND state = $4
+	`, id, outbox.StatePending, expiresAt, outbox.StateDead)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+	return r.requireUpdated(ctx, op, result, id, "message is not dead")
+}
+
+// ExpirePending turns pending messages that expired by at into dead letters
+func (r *OutboxRepository) ExpirePending(ctx context.Context, at time.Time) (int64, error) {
+	const op = "OutboxRepository.ExpirePending"
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE pending_messages
+		SET state = $2
+		WHERE state = $3 AND expires_at <= $1
+	`, at, outbox.StateDead, outbox.StatePending)
+	if err != nil {
+		return 0, database.MapError(err, op)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, database.MapError(err, op)
+	}
+	return n, nil
+}
+
+// DeleteDelivered removes messages delivered before the given time
+func (r *OutboxRepository) DeleteDelivered(ctx context.Context, before time.Time) (int64, error) {
+	const op = "OutboxRepository.DeleteDelivered"
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM pending_messages
+		WHERE state = $2 AND delivered_at < $1
+	`, before, outbox.StateDelivered)
+	if err != nil {
+		return 0, database.MapError(err, op)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, database.MapError(err, op)
+	}
+	return n, nil
+}
+
+// requireUpdated turns an update that matched no rows into a not found or
+// conflict error, telling a missing message apart from one in the wrong
+// state
+func (r *OutboxRepository) requireUpdated(ctx context.Context, op string, result sql.Result, id uuid.UUID, conflict string) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return database.MapError(err, op)
+	}
+	if n > 0 {
+		return nil
+	}
+
+	if _, err := r.Get(ctx, id); err != nil {
+		return err
+	}
+	return werrors.NewError("CONFLICT", conflict, op, werrors.ErrConflict)
+}
+
+// scanOutboxMessage reads a row selected with outboxColumns
+func scanOutboxMessage(row rowScanner) (*outbox.Message, error) {
+	var m outbox.Message
+	var message []byte
+	var deliveredAt sql.NullTime
+
+	err := row.Scan(
+		&m.ID,
+		&m.Seq,
+		&m.DisplayID,
+		&message,
+		&m.State,
+		&m.Attempts,
+		&m.LastError,
+		&m.CreatedAt,
+		&m.ExpiresAt,
+		&deliveredAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(message, &m.Message); err != nil {
+		return nil, fmt.Errorf("failed to decode control message: %w", err)
+	}
+	if deliveredAt.Valid {
+		m.DeliveredAt = deliveredAt.Time
+	}
+
+	return &m, nil
+}
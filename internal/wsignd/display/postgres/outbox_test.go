@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/outbox"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/testutil"
+)
+
+func TestOutboxRepository(t *testing.T) {
+	db, cleanup := testutil.SetupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	displays := NewRepository(db)
+	repo := NewOutboxRepository(db)
+
+	d, err := display.NewDisplay("lobby-north", display.Location{SiteID: "hq", Zone: "lobby"})
+	require.NoError(t, err)
+	require.NoError(t, displays.Save(ctx, d))
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	queue := func(expiresAt time.Time) *outbox.Message {
+		m := &outbox.Message{
+			ID:        uuid.New(),
+			DisplayID: d.ID,
+			Message:   v1alpha1.ControlMessage{Type: v1alpha1.ControlMessageReload, Timestamp: now},
+			State:     outbox.StatePending,
+			CreatedAt: now,
+			ExpiresAt: expiresAt,
+		}
+		require.NoError(t, repo.Create(ctx, m))
+		return m
+	}
+
+	t.Run("queue order", func(t *testing.T) {
+		first := queue(now.Add(time.Hour))
+		second := queue(now.Add(time.Hour))
+		assert.Greater(t, second.Seq, first.Seq)
+
+		messages, err := repo.List(ctx, outbox.Filter{DisplayID: d.ID, State: outbox.StatePending})
+		require.NoError(t, err)
+		require.Len(t, messages, 2)
+		assert.Equal(t, first.ID, messages[0].ID)
+		assert.Equal(t, v1alpha1.ControlMessageReload, messages[0].Message.Type)
+	})
+
+	t.Run("concurrent claims deliver once", func(t *testing.T) {
+		m := queue(now.Add(time.Hour))
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		claimed, conflicts := 0, 0
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := repo.Claim(ctx, m.ID, time.Now())
+				mu.Lock()
+				defer mu.Unlock()
+				if err == nil {
+					claimed++
+				} else if assert.ErrorIs(t, err, werrors.ErrConflict) {
+					conflicts++
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, claimed)
+		assert.Equal(t, 7, conflicts)
+
+		got, err := repo.Get(ctx, m.ID)
+		require.NoError(t, err)
+		assert.Equal(t, outbox.StateDelivered, got.State)
+		assert.Equal(t, 1, got.Attempts)
+
+		// A released message can be claimed again
+		require.NoError(t, repo.Release(ctx, m.ID, "not connected"))
+		require.NoError(t, repo.Claim(ctx, m.ID, time.Now()))
+	})
+
+	t.Run("expiry and requeue", func(t *testing.T) {
+		m := queue(now.Add(-time.Second))
+		assert.ErrorIs(t, repo.Claim(ctx, m.ID, now), werrors.ErrConflict)
+		assert.ErrorIs(t, repo.Requeue(ctx, m.ID, now.Add(time.Hour)), werrors.ErrConflict)
+
+		n, err := repo.ExpirePending(ctx, now)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+
+		require.NoError(t, repo.Requeue(ctx, m.ID, now.Add(time.Hour)))
+		require.NoError(t, repo.Claim(ctx, m.ID, now))
+
+		assert.ErrorIs(t, repo.Claim(ctx, uuid.New(), now), werrors.ErrNotFound)
+	})
+}
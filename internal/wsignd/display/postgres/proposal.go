@@ -0,0 +1,196 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/database"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/proposal"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+const proposalColumns = `
+	id, display_id, site_id, zone, position,
+	previous_site_id, previous_zone, previous_position,
+	note, state, created_at, decided_at, decided_by`
+
+// ProposalRepository implements proposal.Repository using PostgreSQL
+type ProposalRepository struct {
+	db *sql.DB
+}
+
+// NewProposalRepository creates a new PostgreSQL proposal repository
+func NewProposalRepository(db *sql.DB) proposal.Repository {
+	return &ProposalRepository{db: db}
+}
+
+// Create stores a new proposal
+func (r *ProposalRepository) Create(ctx context.Context, p *proposal.Proposal) error {
+	const op = "ProposalRepository.Create"
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO display_location_proposals (
+			id, display_id, site_id, zone, position,
+			previous_site_id, previous_zone, previous_position,
+			note, state, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`,
+		p.ID,
+		p.DisplayID,
+		p.Location.SiteID,
+		p.Location.Zone,
+		p.Location.Position,
+		p.PreviousLocation.SiteID,
+		p.PreviousLocation.Zone,
+		p.PreviousLocation.Position,
+		p.Note,
+		p.State,
+		p.CreatedAt,
+	)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+
+	return nil
+}
+
+// Get retrieves a proposal by ID
+func (r *ProposalRepository) Get(ctx context.Context, id uuid.UUID) (*proposal.Proposal, error) {
+	const op = "ProposalRepository.Get"
+
+	p, err := scanProposal(r.db.QueryRowContext(ctx, `
+		SELECT `+proposalColumns+`
+		FROM display_location_proposals
+		WHERE id = $1
+	`, id))
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	return p, nil
+}
+
+// List retrieves proposals matching the filter, oldest first
+func (r *ProposalRepository) List(ctx context.Context, filter proposal.Filter) ([]*proposal.Proposal, error) {
+	const op = "ProposalRepository.List"
+
+	var conditions []string
+	var args []interface{}
+	if filter.State != "" {
+		args = append(args, filter.State)
+		conditions = append(conditions, fmt.Sprintf("state = $%d", len(args)))
+	}
+	if filter.DisplayID != uuid.Nil {
+		args = append(args, filter.DisplayID)
+		conditions = append(conditions, fmt.Sprintf("display_id = $%d", len(args)))
+	}
+
+	query := `SELECT ` + proposalColumns + ` FROM display_location_proposals`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at, id"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+	defer rows.Close()
+
+	var proposals []*proposal.Proposal
+	for rows.Next() {
+		p, err := scanProposal(rows)
+		if err != nil {
+			return nil, database.MapError(err, op)
+		}
+		proposals = append(proposals, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	return proposals, nil
+}
+
+// Decide moves a pending proposal to state. A proposal that is no longer
+// pending is reported as a conflict.
+func (r *ProposalRepository) Decide(ctx context.Context, id uuid.UUID, state proposal.State, by string, at time.Time) error {
+	const op = "ProposalRepository.Decide"
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE display_location_proposals
+		SET state = $2, decided_by = $3, decided_at = $4
+		WHERE id = $1 AND state = $5
+	`, id, state, by, at, proposal.StatePending)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return database.MapError(err, op)
+	}
+	if n > 0 {
+		return nil
+	}
+
+	// Tell a missing proposal apart from one decided already
+	if _, err := r.Get(ctx, id); err != nil {
+		return err
+	}
+	return werrors.NewError("CONFLICT", "proposal already decided", op, werrors.ErrConflict)
+}
+
+// ExpirePending expires pending proposals created before the given time
+func (r *ProposalRepository) ExpirePending(ctx context.Context, before, at time.Time) (int64, error) {
+	const op = "ProposalRepository.ExpirePending"
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE display_location_proposals
+		SET state = $3, decided_at = $2
+		WHERE state = $4 AND created_at < $1
+	`, before, at, proposal.StateExpired, proposal.StatePending)
+	if err != nil {
+		return 0, database.MapError(err, op)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, database.MapError(err, op)
+	}
+	return n, nil
+}
+
+// scanProposal reads a row selected with proposalColumns
+func scanProposal(row rowScanner) (*proposal.Proposal, error) {
+	var p proposal.Proposal
+	var decidedAt sql.NullTime
+
+	err := row.Scan(
+		&p.ID,
+		&p.DisplayID,
+		&p.Location.SiteID,
+		&p.Location.Zone,
+		&p.Location.Position,
+		&p.PreviousLocation.SiteID,
+		&p.PreviousLocation.Zone,
+		&p.PreviousLocation.Position,
+		&p.Note,
+		&p.State,
+		&p.CreatedAt,
+		&decidedAt,
+		&p.DecidedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if decidedAt.Valid {
+		p.DecidedAt = decidedAt.Time
+	}
+
+	return &p, nil
+}
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/database"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/testutil"
+)
+
+func TestReadsUseReader(t *testing.T) {
+	ctx := context.Background()
+	primary, writes := testutil.RecordingDB(t)
+	reader, reads := testutil.RecordingDB(t)
+	repo := NewRepository(primary, database.WithReader(reader)).(*Repository)
+
+	// Every statement fails on a recording database; only where it was
+	// sent matters here
+	_, _ = repo.List(ctx, display.DisplayFilter{SiteID: "hq"})
+	_, _ = repo.CountByState(ctx, display.DisplayFilter{})
+	_, _ = repo.ListStateSnapshots(ctx, "hq", time.Now())
+	_, _ = repo.ListContentTransitions(ctx, uuid.New(), 10)
+	assert.Len(t, reads.Queries(), 4)
+	assert.Empty(t, writes.Queries())
+
+	// Lookups feed updates, so they must see the latest version
+	reads.Reset()
+	_, _ = repo.FindByID(ctx, uuid.New())
+	_, _ = repo.FindByName(ctx, "lobby-1")
+	_, _ = repo.FindStale(ctx, time.Now(), 10)
+	_ = repo.Delete(ctx, uuid.New())
+	assert.Len(t, writes.Queries(), 4)
+	assert.Empty(t, reads.Queries())
+
+	t.Run("without a reader everything uses the primary", func(t *testing.T) {
+		writes.Reset()
+		repo := NewRepository(primary, database.WithReader(nil)).(*Repository)
+		_, _ = repo.List(ctx, display.DisplayFilter{})
+		assert.Len(t, writes.Queries(), 1)
+	})
+}
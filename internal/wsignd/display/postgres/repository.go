@@ -5,25 +5,97 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 
 	"github.com/wrale/wrale-signage/internal/wsignd/database"
 	"github.com/wrale/wrale-signage/internal/wsignd/display"
 )
 
+const displayColumns = `
+	id, name, site_id, zone, position,
+	state, last_seen, version, properties, settings,
+	created_at, updated_at,
+	last_error_code, last_error_message, last_error_url, last_error_at`
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanDisplay reads a displays row selected with displayColumns
+func scanDisplay(row rowScanner) (*display.Display, error) {
+	var d display.Display
+	var propertiesJSON, settingsJSON []byte
+	var errCode, errMessage, errURL sql.NullString
+	var lastSeen, errAt sql.NullTime
+
+	err := row.Scan(
+		&d.ID,
+		&d.Name,
+		&d.Location.SiteID,
+		&d.Location.Zone,
+		&d.Location.Position,
+		&d.State,
+		&lastSeen,
+		&d.Version,
+		&propertiesJSON,
+		&settingsJSON,
+		&d.CreatedAt,
+		&d.UpdatedAt,
+		&errCode,
+		&errMessage,
+		&errURL,
+		&errAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	d.LastSeen = lastSeen.Time
+
+	if errAt.Valid {
+		d.LastError = &display.ContentError{
+			Code:    errCode.String,
+			Message: errMessage.String,
+			URL:     errURL.String,
+			At:      errAt.Time,
+		}
+	}
+
+	// Parse the JSON properties into the map
+	if err := json.Unmarshal(propertiesJSON, &d.Properties); err != nil {
+		return nil, fmt.Errorf("error unmarshaling properties: %w", err)
+	}
+	if d.Settings, err = unmarshalSettings(settingsJSON); err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
 // Repository implements the display.Repository interface using PostgreSQL. It provides
 // persistent storage for display entities while maintaining consistency through
 // optimistic locking and proper transaction management.
+//
+// Listings, state counts, state snapshots and content history are read
+// from the reader connection and may miss the latest writes when it is a
+// replica. Lookups by ID or name always read the primary, since callers
+// update what they find and would otherwise trip the version check.
 type Repository struct {
-	db *sql.DB
+	db     *sql.DB
+	reader *sql.DB
 }
 
 // NewRepository creates a new PostgreSQL display repository that fulfills the
 // display.Repository interface contract.
-func NewRepository(db *sql.DB) display.Repository {
-	return &Repository{db: db}
+func NewRepository(db *sql.DB, opts ...database.RepositoryOption) display.Repository {
+	conns := database.NewConnections(db, opts...)
+	return &Repository{db: conns.Primary, reader: conns.Reader}
 }
 
 // Save persists a display to the database, handling both creation and updates.
@@ -37,6 +109,10 @@ func (r *Repository) Save(ctx context.Context, d *display.Display) error {
 	if err != nil {
 		return fmt.Errorf("error marshaling properties: %w", err)
 	}
+	settings, err := marshalSettings(d.Settings)
+	if err != nil {
+		return err
+	}
 
 	// Handle upsert with optimistic locking within a transaction
 	err = database.RunInTx(ctx, r.db, nil, func(tx *database.Tx) error {
@@ -52,8 +128,9 @@ func (r *Repository) Save(ctx context.Context, d *display.Display) error {
 		}
 
 		if exists {
-			// Update existing display with version check for optimistic locking
-			result, err := tx.ExecContext(ctx, `
+			// Update existing display with version check for optimistic
+			// locking; the trigger advances updated_at
+			err := tx.QueryRowContext(ctx, `
 				UPDATE displays 
 				SET name = $1,
 					site_id = $2,
@@ -62,61 +139,92 @@ func (r *Repository) Save(ctx context.Context, d *display.Display) error {
 					state = $5,
 					last_seen = $6,
 					version = $7,
-					properties = $8
-				WHERE id = $9
-				  AND version = $10
+					properties = $8,
+					settings = $9
+				WHERE id = $10
+				  AND version = $11
+				RETURNING updated_at
 			`,
 				d.Name,
 				d.Location.SiteID,
 				d.Location.Zone,
 				d.Location.Position,
 				d.State,
-				d.LastSeen,
+				nullTime(d.LastSeen),
 				d.Version+1,
 				properties,
+				settings,
 				d.ID,
 				d.Version,
-			)
-			if err != nil {
-				return err
+			).Scan(&d.UpdatedAt)
+			if errors.Is(err, sql.ErrNoRows) {
+				return display.ErrVersionMismatch{ID: d.ID.String()}
 			}
-
-			rows, err := result.RowsAffected()
 			if err != nil {
 				return err
 			}
-			if rows == 0 {
-				return display.ErrVersionMismatch{ID: d.ID.String()}
-			}
 
 			// Update the version number on successful update
 			d.Version++
-		} else {
-			// Insert new display record
-			_, err = tx.ExecContext(ctx, `
-				INSERT INTO displays (
-					id, name, site_id, zone, position,
-					state, last_seen, version, properties
-				) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-			`,
-				d.ID,
-				d.Name,
-				d.Location.SiteID,
-				d.Location.Zone,
-				d.Location.Position,
-				d.State,
-				d.LastSeen,
-				d.Version,
-				properties,
-			)
+		} else if err := insertDisplay(ctx, tx, d, properties, settings); err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return database.MapError(err, op)
+	}
+
+	return nil
+}
+
+// Create inserts a new display unless its site or zone is full. Creates at
+// the same site take turns through a transaction-scoped advisory lock, so
+// the count cannot change between checking it and inserting.
+func (r *Repository) Create(ctx context.Context, d *display.Display, limits display.Limits) error {
+	const op = "DisplayRepository.Create"
+
+	properties, err := json.Marshal(d.Properties)
+	if err != nil {
+		return fmt.Errorf("error marshaling properties: %w", err)
+	}
+	settings, err := marshalSettings(d.Settings)
+	if err != nil {
+		return err
+	}
+
+	err = database.RunInTx(ctx, r.db, nil, func(tx *database.Tx) error {
+		if !limits.Unlimited() {
+			if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('displays/site/' || $1))`, d.Location.SiteID); err != nil {
+				return err
+			}
+
+			var site, zone int
+			err := tx.QueryRowContext(ctx, `
+				SELECT count(*), count(*) FILTER (WHERE zone = $2)
+				FROM displays
+				WHERE site_id = $1
+			`, d.Location.SiteID, d.Location.Zone).Scan(&site, &zone)
 			if err != nil {
 				return err
 			}
+			if limits.Site > 0 && site >= limits.Site {
+				return display.ErrLimitExceeded{Scope: fmt.Sprintf("site %q", d.Location.SiteID), Limit: limits.Site}
+			}
+			if limits.Zone > 0 && zone >= limits.Zone {
+				return display.ErrLimitExceeded{Scope: fmt.Sprintf("zone %q", display.ZoneKey(d.Location.SiteID, d.Location.Zone)), Limit: limits.Zone}
+			}
 		}
 
-		return nil
+		return insertDisplay(ctx, tx, d, properties, settings)
 	})
 
+	var limitErr display.ErrLimitExceeded
+	if errors.As(err, &limitErr) {
+		return limitErr
+	}
 	if err != nil {
 		return database.MapError(err, op)
 	}
@@ -124,41 +232,46 @@ func (r *Repository) Save(ctx context.Context, d *display.Display) error {
 	return nil
 }
 
+// insertDisplay inserts a new display record; the database assigns
+// timestamps
+func insertDisplay(ctx context.Context, tx *database.Tx, d *display.Display, properties, settings []byte) error {
+	return tx.QueryRowContext(ctx, `
+		INSERT INTO displays (
+			id, name, site_id, zone, position,
+			state, last_seen, version, properties, settings
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING created_at, updated_at
+	`,
+		d.ID,
+		d.Name,
+		d.Location.SiteID,
+		d.Location.Zone,
+		d.Location.Position,
+		d.State,
+		nullTime(d.LastSeen),
+		d.Version,
+		properties,
+		settings,
+	).Scan(&d.CreatedAt, &d.UpdatedAt)
+}
+
+// nullTime stores the zero time, a display never seen, as NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
 // FindByID retrieves a display by its unique identifier. It returns ErrNotFound
 // if no display exists with the given ID.
 func (r *Repository) FindByID(ctx context.Context, id uuid.UUID) (*display.Display, error) {
 	const op = "DisplayRepository.FindByID"
 
-	var d display.Display
-	var propertiesJSON []byte
-
-	err := r.db.QueryRowContext(ctx, `
-		SELECT 
-			id, name, site_id, zone, position,
-			state, last_seen, version, properties
-		FROM displays
-		WHERE id = $1
-	`, id).Scan(
-		&d.ID,
-		&d.Name,
-		&d.Location.SiteID,
-		&d.Location.Zone,
-		&d.Location.Position,
-		&d.State,
-		&d.LastSeen,
-		&d.Version,
-		&propertiesJSON,
-	)
+	d, err := scanDisplay(r.db.QueryRowContext(ctx,
+		"SELECT "+displayColumns+" FROM displays WHERE id = $1", id))
 	if err != nil {
 		return nil, database.MapError(err, op)
 	}
 
-	// Parse the JSON properties into the map
-	if err := json.Unmarshal(propertiesJSON, &d.Properties); err != nil {
-		return nil, fmt.Errorf("error unmarshaling properties: %w", err)
-	}
-
-	return &d, nil
+	return d, nil
 }
 
 // FindByName retrieves a display by its name, which must be unique across the system.
@@ -166,74 +279,41 @@ func (r *Repository) FindByID(ctx context.Context, id uuid.UUID) (*display.Displ
 func (r *Repository) FindByName(ctx context.Context, name string) (*display.Display, error) {
 	const op = "DisplayRepository.FindByName"
 
-	var d display.Display
-	var propertiesJSON []byte
-
-	err := r.db.QueryRowContext(ctx, `
-		SELECT 
-			id, name, site_id, zone, position,
-			state, last_seen, version, properties
-		FROM displays
-		WHERE name = $1
-	`, name).Scan(
-		&d.ID,
-		&d.Name,
-		&d.Location.SiteID,
-		&d.Location.Zone,
-		&d.Location.Position,
-		&d.State,
-		&d.LastSeen,
-		&d.Version,
-		&propertiesJSON,
-	)
+	d, err := scanDisplay(r.db.QueryRowContext(ctx,
+		"SELECT "+displayColumns+" FROM displays WHERE name = $1", name))
 	if err != nil {
 		return nil, database.MapError(err, op)
 	}
 
-	if err := json.Unmarshal(propertiesJSON, &d.Properties); err != nil {
-		return nil, fmt.Errorf("error unmarshaling properties: %w", err)
+	return d, nil
+}
+
+// FindByNameFold retrieves a display whose name equals name ignoring case,
+// preferring an exact match. It returns ErrNotFound if there is none.
+func (r *Repository) FindByNameFold(ctx context.Context, name string) (*display.Display, error) {
+	const op = "DisplayRepository.FindByNameFold"
+
+	d, err := scanDisplay(r.db.QueryRowContext(ctx,
+		"SELECT "+displayColumns+" FROM displays WHERE lower(name) = lower($1) ORDER BY name = $1 DESC, created_at LIMIT 1", name))
+	if err != nil {
+		return nil, database.MapError(err, op)
 	}
 
-	return &d, nil
+	return d, nil
 }
 
-// List retrieves displays matching the provided filter criteria. It returns
-// an empty slice if no matching displays are found.
+// List retrieves displays matching the provided filter criteria, oldest
+// first. It returns an empty slice if no matching displays are found.
 func (r *Repository) List(ctx context.Context, filter display.DisplayFilter) ([]*display.Display, error) {
 	const op = "DisplayRepository.List"
 
-	// Build query with dynamic WHERE clause based on filter
-	query := `
-		SELECT 
-			id, name, site_id, zone, position,
-			state, last_seen, version, properties
-		FROM displays
-		WHERE 1=1
-	`
-	var args []interface{}
-	var conditions []string
-
-	// Add filter conditions
-	if filter.SiteID != "" {
-		args = append(args, filter.SiteID)
-		conditions = append(conditions, fmt.Sprintf("site_id = $%d", len(args)))
-	}
-	if filter.Zone != "" {
-		args = append(args, filter.Zone)
-		conditions = append(conditions, fmt.Sprintf("zone = $%d", len(args)))
-	}
-	if len(filter.States) > 0 {
-		args = append(args, filter.States)
-		conditions = append(conditions, fmt.Sprintf("state = ANY($%d)", len(args)))
-	}
-
-	// Apply conditions to query
-	for _, cond := range conditions {
-		query += " AND " + cond
+	where, args, err := filterClause(filter)
+	if err != nil {
+		return nil, database.MapError(err, op)
 	}
+	query := "SELECT " + displayColumns + " FROM displays WHERE " + where + " ORDER BY created_at, name"
 
-	// Execute query
-	rows, err := r.db.QueryContext(ctx, query, args...)
+	rows, err := r.reader.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, database.MapError(err, op)
 	}
@@ -242,29 +322,11 @@ func (r *Repository) List(ctx context.Context, filter display.DisplayFilter) ([]
 	// Collect results
 	var displays []*display.Display
 	for rows.Next() {
-		var d display.Display
-		var propertiesJSON []byte
-
-		err := rows.Scan(
-			&d.ID,
-			&d.Name,
-			&d.Location.SiteID,
-			&d.Location.Zone,
-			&d.Location.Position,
-			&d.State,
-			&d.LastSeen,
-			&d.Version,
-			&propertiesJSON,
-		)
+		d, err := scanDisplay(rows)
 		if err != nil {
 			return nil, database.MapError(err, op)
 		}
-
-		if err := json.Unmarshal(propertiesJSON, &d.Properties); err != nil {
-			return nil, fmt.Errorf("error unmarshaling properties: %w", err)
-		}
-
-		displays = append(displays, &d)
+		displays = append(displays, d)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -274,6 +336,50 @@ func (r *Repository) List(ctx context.Context, filter display.DisplayFilter) ([]
 	return displays, nil
 }
 
+// filterClause builds the WHERE condition selecting the displays that match
+// filter, with its arguments numbered from $1
+func filterClause(filter display.DisplayFilter) (string, []interface{}, error) {
+	var args []interface{}
+	conditions := []string{"TRUE"}
+
+	if filter.SiteID != "" {
+		args = append(args, filter.SiteID)
+		conditions = append(conditions, fmt.Sprintf("site_id = $%d", len(args)))
+	}
+	if filter.Zone != "" {
+		args = append(args, filter.Zone)
+		conditions = append(conditions, fmt.Sprintf("zone = $%d", len(args)))
+	}
+	if len(filter.Properties) > 0 {
+		properties, err := json.Marshal(filter.Properties)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, properties)
+		conditions = append(conditions, fmt.Sprintf("properties @> $%d::jsonb", len(args)))
+	}
+	if len(filter.States) > 0 {
+		states := make([]string, len(filter.States))
+		for i, s := range filter.States {
+			states[i] = string(s)
+		}
+		args = append(args, pq.Array(states))
+		conditions = append(conditions, fmt.Sprintf("state = ANY($%d)", len(args)))
+	}
+	if filter.NeverSeen {
+		conditions = append(conditions, "last_seen IS NULL")
+	}
+	if filter.Seen {
+		conditions = append(conditions, "last_seen IS NOT NULL")
+	}
+	if !filter.SeenBefore.IsZero() {
+		args = append(args, filter.SeenBefore)
+		conditions = append(conditions, fmt.Sprintf("last_seen < $%d", len(args)))
+	}
+
+	return strings.Join(conditions, " AND "), args, nil
+}
+
 // Delete removes a display from storage by its ID. It returns ErrNotFound
 // if no display exists with the given ID.
 func (r *Repository) Delete(ctx context.Context, id uuid.UUID) error {
@@ -298,3 +404,26 @@ func (r *Repository) Delete(ctx context.Context, id uuid.UUID) error {
 
 	return nil
 }
+
+// DeleteOffline removes a display if it is offline and was last seen before
+// seenBefore. Its tokens, content history and other dependent rows go with
+// it.
+func (r *Repository) DeleteOffline(ctx context.Context, id uuid.UUID, seenBefore time.Time) (bool, error) {
+	const op = "DisplayRepository.DeleteOffline"
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM displays
+		WHERE id = $1
+		  AND state = 'OFFLINE'
+		  AND last_seen < $2
+	`, id, seenBefore)
+	if err != nil {
+		return false, database.MapError(err, op)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, database.MapError(err, op)
+	}
+	return rows > 0, nil
+}
@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+)
+
+// settingsRecord is the JSON stored in the settings column
+type settingsRecord struct {
+	Orientation    display.Orientation `json:"orientation,omitempty"`
+	Brightness     *int                `json:"brightness,omitempty"`
+	Volume         *int                `json:"volume,omitempty"`
+	ScreenSchedule []screenWindow      `json:"screenSchedule,omitempty"`
+}
+
+type screenWindow struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// marshalSettings converts display settings to the settings column's JSON
+func marshalSettings(s display.Settings) ([]byte, error) {
+	rec := settingsRecord{
+		Orientation: s.Orientation,
+		Brightness:  s.Brightness,
+		Volume:      s.Volume,
+	}
+	for _, w := range s.ScreenSchedule {
+		rec.ScreenSchedule = append(rec.ScreenSchedule, screenWindow{Start: w.Start, End: w.End})
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling settings: %w", err)
+	}
+	return data, nil
+}
+
+// unmarshalSettings parses the settings column
+func unmarshalSettings(data []byte) (display.Settings, error) {
+	var rec settingsRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return display.Settings{}, fmt.Errorf("error unmarshaling settings: %w", err)
+	}
+	s := display.Settings{
+		Orientation: rec.Orientation,
+		Brightness:  rec.Brightness,
+		Volume:      rec.Volume,
+	}
+	for _, w := range rec.ScreenSchedule {
+		s.ScreenSchedule = append(s.ScreenSchedule, display.ScreenWindow{Start: w.Start, End: w.End})
+	}
+	return s, nil
+}
@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/database"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+)
+
+// CountByState counts the displays matching filter per site and state in a
+// single grouped query
+func (r *Repository) CountByState(ctx context.Context, filter display.DisplayFilter) ([]display.StateCount, error) {
+	const op = "DisplayRepository.CountByState"
+
+	where, args, err := filterClause(filter)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+	rows, err := r.reader.QueryContext(ctx, countByStateQuery(where), args...)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+	defer rows.Close()
+
+	var counts []display.StateCount
+	for rows.Next() {
+		var c display.StateCount
+		if err := rows.Scan(&c.SiteID, &c.State, &c.Count); err != nil {
+			return nil, database.MapError(err, op)
+		}
+		counts = append(counts, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	return counts, nil
+}
+
+// countByStateQuery counts the displays matching the where condition per
+// site and state
+func countByStateQuery(where string) string {
+	return `
+		SELECT site_id, state, COUNT(*)
+		FROM displays
+		WHERE ` + where + `
+		GROUP BY site_id, state
+		ORDER BY site_id, state`
+}
+
+// SaveStateSnapshots stores snapshots in one transaction. Saving a site's
+// snapshot again for the same time replaces its counts.
+func (r *Repository) SaveStateSnapshots(ctx context.Context, snapshots []*display.StateSnapshot) error {
+	const op = "DisplayRepository.SaveStateSnapshots"
+
+	err := database.RunInTx(ctx, r.db, nil, func(tx *database.Tx) error {
+		for _, s := range snapshots {
+			counts, err := json.Marshal(s.Counts)
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO display_state_snapshots (taken_at, site_id, counts)
+				VALUES ($1, $2, $3)
+				ON CONFLICT (site_id, taken_at) DO UPDATE SET counts = EXCLUDED.counts
+			`, s.TakenAt, s.SiteID, counts)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return database.MapError(err, op)
+	}
+
+	return nil
+}
+
+// ListStateSnapshots retrieves the snapshots taken at or after since, oldest
+// first and then by site
+func (r *Repository) ListStateSnapshots(ctx context.Context, siteID string, since time.Time) ([]*display.StateSnapshot, error) {
+	const op = "DisplayRepository.ListStateSnapshots"
+
+	rows, err := r.reader.QueryContext(ctx, `
+		SELECT taken_at, site_id, counts
+		FROM display_state_snapshots
+		WHERE taken_at >= $1
+		  AND ($2::text = '' OR site_id = $2)
+		ORDER BY taken_at, site_id
+	`, since, siteID)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+	defer rows.Close()
+
+	var snapshots []*display.StateSnapshot
+	for rows.Next() {
+		var s display.StateSnapshot
+		var counts []byte
+		if err := rows.Scan(&s.TakenAt, &s.SiteID, &counts); err != nil {
+			return nil, database.MapError(err, op)
+		}
+		if err := json.Unmarshal(counts, &s.Counts); err != nil {
+			return nil, database.MapError(err, op)
+		}
+		snapshots = append(snapshots, &s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	return snapshots, nil
+}
+
+// PruneStateSnapshots removes the snapshots taken before the given time
+func (r *Repository) PruneStateSnapshots(ctx context.Context, before time.Time) error {
+	const op = "DisplayRepository.PruneStateSnapshots"
+
+	if _, err := r.db.ExecContext(ctx, `
+		DELETE FROM display_state_snapshots WHERE taken_at < $1
+	`, before); err != nil {
+		return database.MapError(err, op)
+	}
+
+	return nil
+}
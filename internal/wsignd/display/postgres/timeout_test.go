@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+	"github.com/wrale/wrale-signage/internal/wsignd/database"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/testutil"
+)
+
+// assertTimeout checks err is the domain timeout error, not an internal one
+func assertTimeout(t *testing.T, err error) {
+	t.Helper()
+	require.Error(t, err)
+	assert.True(t, werrors.IsTimeout(err), "%v", err)
+	var domainErr *werrors.Error
+	require.ErrorAs(t, err, &domainErr)
+	assert.Equal(t, "DEADLINE_EXCEEDED", domainErr.Code)
+}
+
+func TestTimeouts(t *testing.T) {
+	t.Run("the pool's statement timeout cancels slow queries", func(t *testing.T) {
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		db, err := database.Connect(context.Background(), config.DatabaseConfig{
+			URL:              testutil.DatabaseURL(),
+			MaxOpenConns:     1,
+			MaxIdleConns:     1,
+			ConnectAttempts:  1,
+			StatementTimeout: 50 * time.Millisecond,
+		}, logger, nil)
+		require.NoError(t, err)
+		defer db.Close()
+
+		start := time.Now()
+		_, err = db.ExecContext(context.Background(), `SELECT pg_sleep(5)`)
+		assertTimeout(t, database.MapError(err, "test"))
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+
+	t.Run("a context deadline stops a blocked repository call", func(t *testing.T) {
+		db, cleanup := testutil.SetupTestDB(t)
+		defer cleanup()
+		repo := NewRepository(db)
+		ctx := context.Background()
+
+		d, err := display.NewDisplay("lobby-north", display.Location{SiteID: "hq", Zone: "lobby"})
+		require.NoError(t, err)
+		require.NoError(t, repo.Save(ctx, d))
+
+		// Another session holds the row, as a stuck transaction would
+		blocker, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer blocker.Rollback()
+		_, err = blocker.ExecContext(ctx, `SELECT 1 FROM displays WHERE id = $1 FOR UPDATE`, d.ID)
+		require.NoError(t, err)
+
+		saveCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		d.Name = "lobby-south"
+		assertTimeout(t, repo.Save(saveCtx, d))
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+}
@@ -0,0 +1,223 @@
+// Package http serves the display location proposal API
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/proposal"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+)
+
+// Handler serves location proposal requests
+type Handler struct {
+	service proposal.Service
+	logger  *slog.Logger
+}
+
+// NewHandler creates a location proposal handler
+func NewHandler(service proposal.Service, logger *slog.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// ProposeLocation records a location change proposed by a display. A
+// display token may only propose changes for its own display.
+func (h *Handler) ProposeLocation(w http.ResponseWriter, r *http.Request) {
+	displayID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		httpapi.Error(w, http.StatusBadRequest, "invalid display ID")
+		return
+	}
+
+	if authenticated, ok := auth.DisplayIDFromContext(r.Context()); ok && authenticated != displayID {
+		h.logger.Warn("display proposed a location for another display",
+			"displayId", authenticated,
+			"targetDisplayId", displayID,
+		)
+		httpapi.WriteJSON(w, http.StatusForbidden, v1alpha1.Error{
+			Code:    "FORBIDDEN",
+			Message: "displays may only propose their own location",
+		})
+		return
+	}
+
+	var req v1alpha1.LocationProposalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpapi.Error(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	p, err := h.service.Propose(r.Context(), displayID, display.Location{
+		SiteID:   req.Location.SiteID,
+		Zone:     req.Location.Zone,
+		Position: req.Location.Position,
+	}, req.Note)
+	if err != nil {
+		h.logger.Info("failed to record location proposal",
+			"error", err,
+			"displayId", displayID,
+		)
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("location proposed",
+		"proposalId", p.ID,
+		"displayId", p.DisplayID,
+		"siteId", p.Location.SiteID,
+		"zone", p.Location.Zone,
+		"position", p.Location.Position,
+	)
+
+	httpapi.WriteJSON(w, http.StatusCreated, toAPIProposal(p))
+}
+
+// ListProposals lists proposals, optionally filtered by the state and
+// displayId query parameters
+func (h *Handler) ListProposals(w http.ResponseWriter, r *http.Request) {
+	filter := proposal.Filter{State: proposal.State(r.URL.Query().Get("state"))}
+	if v := r.URL.Query().Get("displayId"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			httpapi.Error(w, http.StatusBadRequest, "invalid display ID")
+			return
+		}
+		filter.DisplayID = id
+	}
+
+	proposals, err := h.service.List(r.Context(), filter)
+	if err != nil {
+		h.logger.Error("failed to list proposals", "error", err)
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := v1alpha1.LocationProposalList{
+		TypeMeta: v1alpha1.TypeMeta{
+			Kind:       "LocationProposalList",
+			APIVersion: "v1alpha1",
+		},
+		Items: make([]v1alpha1.LocationProposal, 0, len(proposals)),
+	}
+	for _, p := range proposals {
+		resp.Items = append(resp.Items, *toAPIProposal(p))
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, resp)
+}
+
+// GetProposal returns a single proposal
+func (h *Handler) GetProposal(w http.ResponseWriter, r *http.Request) {
+	id, ok := proposalID(w, r)
+	if !ok {
+		return
+	}
+
+	p, err := h.service.Get(r.Context(), id)
+	if err != nil {
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, toAPIProposal(p))
+}
+
+// ApproveProposal applies a pending proposal's location to its display
+func (h *Handler) ApproveProposal(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, "approved", h.service.Approve)
+}
+
+// RejectProposal turns a pending proposal down
+func (h *Handler) RejectProposal(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, "rejected", h.service.Reject)
+}
+
+// decide runs an approval or rejection and records it in the audit log,
+// attributing it to both the proposing display and the deciding operator
+func (h *Handler) decide(w http.ResponseWriter, r *http.Request, outcome string,
+	fn func(ctx context.Context, id uuid.UUID, operator string) (*proposal.Proposal, error)) {
+	id, ok := proposalID(w, r)
+	if !ok {
+		return
+	}
+
+	name := operator.Name(r.Context())
+	p, err := fn(r.Context(), id, name)
+	if err != nil {
+		h.logger.Info("failed to decide location proposal",
+			"error", err,
+			"proposalId", id,
+			"outcome", outcome,
+		)
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("location proposal "+outcome,
+		"audit", true,
+		"proposalId", p.ID,
+		"displayId", p.DisplayID,
+		"siteId", p.Location.SiteID,
+		"zone", p.Location.Zone,
+		"position", p.Location.Position,
+		"previousSiteId", p.PreviousLocation.SiteID,
+		"previousZone", p.PreviousLocation.Zone,
+		"previousPosition", p.PreviousLocation.Position,
+		"operator", name,
+		"remoteAddr", r.RemoteAddr,
+		"requestId", middleware.GetReqID(r.Context()),
+	)
+
+	httpapi.WriteJSON(w, http.StatusOK, toAPIProposal(p))
+}
+
+// proposalID parses the proposal ID URL parameter, answering 400 when it
+// is malformed
+func proposalID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "proposalId"))
+	if err != nil {
+		httpapi.Error(w, http.StatusBadRequest, "invalid proposal ID")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// toAPIProposal converts a proposal to its API representation
+func toAPIProposal(p *proposal.Proposal) *v1alpha1.LocationProposal {
+	resp := &v1alpha1.LocationProposal{
+		ID:        p.ID,
+		DisplayID: p.DisplayID,
+		Location: v1alpha1.DisplayLocation{
+			SiteID:   p.Location.SiteID,
+			Zone:     p.Location.Zone,
+			Position: p.Location.Position,
+		},
+		PreviousLocation: v1alpha1.DisplayLocation{
+			SiteID:   p.PreviousLocation.SiteID,
+			Zone:     p.PreviousLocation.Zone,
+			Position: p.PreviousLocation.Position,
+		},
+		Note:      p.Note,
+		State:     v1alpha1.LocationProposalState(p.State),
+		CreatedAt: p.CreatedAt,
+		DecidedBy: p.DecidedBy,
+	}
+	if !p.DecidedAt.IsZero() {
+		decidedAt := p.DecidedAt
+		resp.DecidedAt = &decidedAt
+	}
+	return resp
+}
@@ -0,0 +1,52 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+)
+
+// NewRouter mounts the operator routes for reviewing proposals. Listing
+// them requires the displays:read scope and deciding them displays:write;
+// a nil guard leaves the routes open.
+func NewRouter(h *Handler, guard operator.Guard) chi.Router {
+	r := chi.NewRouter()
+
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.RequestID)
+
+	read := guard.Require(operator.ScopeDisplaysRead)
+	write := guard.Require(operator.ScopeDisplaysWrite)
+
+	r.With(read).Get("/", h.ListProposals)
+	r.Route("/{proposalId}", func(r chi.Router) {
+		r.With(read).Get("/", h.GetProposal)
+		r.With(write).Post("/approve", h.ApproveProposal)
+		r.With(write).Post("/reject", h.RejectProposal)
+	})
+
+	return r
+}
+
+// NewDisplayRouter mounts the route displays propose location changes on.
+// It is mounted below a display's path, so the display ID is read from the
+// id URL parameter. When displayAuth is non-nil only the display itself may
+// propose.
+func NewDisplayRouter(h *Handler, displayAuth func(http.Handler) http.Handler) chi.Router {
+	r := chi.NewRouter()
+
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.RequestID)
+
+	if displayAuth != nil {
+		r.Use(displayAuth)
+	}
+	r.Post("/", h.ProposeLocation)
+
+	return r
+}
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
+	authhttp "github.com/wrale/wrale-signage/internal/wsignd/auth/http"
+	authmemory "github.com/wrale/wrale-signage/internal/wsignd/auth/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/proposal"
+)
+
+type discardPublisher struct{}
+
+func (discardPublisher) Publish(ctx context.Context, event display.Event) error { return nil }
+
+// TestProposalWorkflow mounts both routers the way the server does and runs
+// a proposal from the display through to an operator's decision
+func TestProposalWorkflow(t *testing.T) {
+	ctx := context.Background()
+
+	material := make([]byte, 32)
+	_, err := rand.Read(material)
+	require.NoError(t, err)
+	key, err := auth.ParseKey("test", string(auth.AlgorithmHS256), base64.StdEncoding.EncodeToString(material))
+	require.NoError(t, err)
+	keys, err := auth.NewKeyRing(key)
+	require.NoError(t, err)
+	tokens := auth.NewService(keys, authmemory.NewRepository(), time.Hour)
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	displays := display.NewService(memory.NewRepository(), discardPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, logger)
+	planned := display.Location{SiteID: "hq", Zone: "lobby", Position: "north"}
+	d, err := displays.Register(ctx, "lobby-north", planned, nil)
+	require.NoError(t, err)
+	other, err := displays.Register(ctx, "lobby-south", planned, nil)
+	require.NoError(t, err)
+	displayToken, _, err := tokens.IssueDisplayToken(ctx, d.ID)
+	require.NoError(t, err)
+
+	h := NewHandler(proposal.NewService(memory.NewProposalRepository(), displays, time.Hour), logger)
+	router := chi.NewRouter()
+	router.Mount("/api/v1alpha1/displays/{id}/location-proposals", NewDisplayRouter(h, authhttp.RequireDisplayToken(tokens, logger)))
+	router.Mount("/api/v1alpha1/location-proposals", NewRouter(h, nil))
+
+	do := func(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	propose := func(displayPath, token, position string) *httptest.ResponseRecorder {
+		return do(http.MethodPost, "/api/v1alpha1/displays/"+displayPath+"/location-proposals", token,
+			&v1alpha1.LocationProposalRequest{
+				Location: v1alpha1.DisplayLocation{SiteID: "hq", Zone: "lobby", Position: position},
+				Note:     "installed elsewhere",
+			})
+	}
+	location := func() display.Location {
+		stored, err := displays.Get(ctx, d.ID)
+		require.NoError(t, err)
+		return stored.Location
+	}
+
+	t.Run("proposals need the display's own token", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, propose(d.ID.String(), "", "south").Code)
+		assert.Equal(t, http.StatusForbidden, propose(other.ID.String(), displayToken, "south").Code)
+	})
+
+	t.Run("approval applies the location", func(t *testing.T) {
+		rec := propose(d.ID.String(), displayToken, "south")
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var created v1alpha1.LocationProposal
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&created))
+		assert.Equal(t, v1alpha1.LocationProposalPending, created.State)
+		assert.Equal(t, "north", created.PreviousLocation.Position)
+		assert.Equal(t, planned, location())
+
+		rec = do(http.MethodGet, "/api/v1alpha1/location-proposals?state=pending", "", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var list v1alpha1.LocationProposalList
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&list))
+		require.Len(t, list.Items, 1)
+		assert.Equal(t, created.ID, list.Items[0].ID)
+
+		rec = do(http.MethodPost, "/api/v1alpha1/location-proposals/"+created.ID.String()+"/approve", "", nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var approved v1alpha1.LocationProposal
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&approved))
+		assert.Equal(t, v1alpha1.LocationProposalApproved, approved.State)
+		assert.NotNil(t, approved.DecidedAt)
+		assert.Equal(t, "south", location().Position)
+
+		rec = do(http.MethodPost, "/api/v1alpha1/location-proposals/"+created.ID.String()+"/reject", "", nil)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("rejection leaves the display untouched", func(t *testing.T) {
+		rec := propose(d.ID.String(), displayToken, "east")
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var created v1alpha1.LocationProposal
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&created))
+
+		rec = do(http.MethodPost, "/api/v1alpha1/location-proposals/"+created.ID.String()+"/reject", "", nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "south", location().Position)
+	})
+}
@@ -0,0 +1,100 @@
+// Package proposal lets displays propose corrections to their own location.
+// The device knows where it was actually mounted better than the back
+// office does, but a change only takes effect once an operator approves it.
+package proposal
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+)
+
+// State is where a proposal is in its lifecycle
+type State string
+
+const (
+	// StatePending indicates a proposal waiting for an operator
+	StatePending State = "pending"
+	// StateApproved indicates the proposed location was applied
+	StateApproved State = "approved"
+	// StateRejected indicates an operator turned the proposal down
+	StateRejected State = "rejected"
+	// StateExpired indicates nobody decided before the proposal got too old
+	StateExpired State = "expired"
+)
+
+// Valid reports whether s is a known state
+func (s State) Valid() bool {
+	switch s {
+	case StatePending, StateApproved, StateRejected, StateExpired:
+		return true
+	}
+	return false
+}
+
+// Proposal is a location change suggested by a display
+type Proposal struct {
+	// ID uniquely identifies the proposal
+	ID uuid.UUID
+	// DisplayID is the display that proposed the change
+	DisplayID uuid.UUID
+	// Location is the proposed location
+	Location display.Location
+	// PreviousLocation is where the display was recorded when it proposed
+	// the change
+	PreviousLocation display.Location
+	// Note is an optional explanation from the installer
+	Note string
+	// State is where the proposal is in its lifecycle
+	State State
+	// CreatedAt is when the display made the proposal
+	CreatedAt time.Time
+	// DecidedAt is when the proposal left the pending state; zero while
+	// pending
+	DecidedAt time.Time
+	// DecidedBy names the operator who approved or rejected the proposal
+	DecidedBy string
+}
+
+// Filter defines criteria for listing proposals
+type Filter struct {
+	// State filters by proposal state
+	State State
+	// DisplayID filters by proposing display
+	DisplayID uuid.UUID
+}
+
+// Repository defines persistence for location proposals
+type Repository interface {
+	// Create stores a new proposal
+	Create(ctx context.Context, p *Proposal) error
+	// Get retrieves a proposal by ID
+	Get(ctx context.Context, id uuid.UUID) (*Proposal, error)
+	// List retrieves proposals matching the filter, oldest first
+	List(ctx context.Context, filter Filter) ([]*Proposal, error)
+	// Decide moves a pending proposal to state, recording who decided and
+	// when. It fails with a conflict if the proposal is no longer pending.
+	Decide(ctx context.Context, id uuid.UUID, state State, by string, at time.Time) error
+	// ExpirePending moves proposals still pending that were created before
+	// the given time to StateExpired
+	ExpirePending(ctx context.Context, before, at time.Time) (int64, error)
+}
+
+// Service defines the location proposal operations
+type Service interface {
+	// Propose records a location change suggested by a display
+	Propose(ctx context.Context, displayID uuid.UUID, location display.Location, note string) (*Proposal, error)
+	// Get retrieves a proposal by ID
+	Get(ctx context.Context, id uuid.UUID) (*Proposal, error)
+	// List retrieves proposals matching the filter, oldest first
+	List(ctx context.Context, filter Filter) ([]*Proposal, error)
+	// Approve applies a pending proposal's location to its display
+	Approve(ctx context.Context, id uuid.UUID, operator string) (*Proposal, error)
+	// Reject turns a pending proposal down, leaving the display untouched
+	Reject(ctx context.Context, id uuid.UUID, operator string) (*Proposal, error)
+	// ExpireStale expires pending proposals older than the maximum age
+	ExpireStale(ctx context.Context) error
+}
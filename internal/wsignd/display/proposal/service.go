@@ -0,0 +1,165 @@
+package proposal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// DefaultMaxAge is how long a proposal waits for an operator when no
+// maximum age is configured
+const DefaultMaxAge = 7 * 24 * time.Hour
+
+type service struct {
+	repo     Repository
+	displays display.Service
+	maxAge   time.Duration
+}
+
+// NewService creates a proposal service. Approved locations are applied
+// through displays, and proposals still pending after maxAge expire; a
+// maxAge of zero or less uses DefaultMaxAge.
+func NewService(repo Repository, displays display.Service, maxAge time.Duration) Service {
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+	return &service{
+		repo:     repo,
+		displays: displays,
+		maxAge:   maxAge,
+	}
+}
+
+func (s *service) Propose(ctx context.Context, displayID uuid.UUID, location display.Location, note string) (*Proposal, error) {
+	const op = "ProposalService.Propose"
+
+	if location.SiteID == "" {
+		return nil, werrors.NewError("INVALID_INPUT", "site ID cannot be empty", op, werrors.ErrInvalidInput)
+	}
+
+	d, err := s.displays.Get(ctx, displayID)
+	if err != nil {
+		return nil, err
+	}
+	if d.Location == location {
+		return nil, werrors.NewError("INVALID_INPUT", "display is already at the proposed location", op, werrors.ErrInvalidInput)
+	}
+
+	p := &Proposal{
+		ID:               uuid.New(),
+		DisplayID:        displayID,
+		Location:         location,
+		PreviousLocation: d.Location,
+		Note:             note,
+		State:            StatePending,
+		CreatedAt:        time.Now(),
+	}
+	if err := s.repo.Create(ctx, p); err != nil {
+		return nil, werrors.NewError("SAVE_FAILED", "Failed to save proposal", op, err)
+	}
+
+	return p, nil
+}
+
+func (s *service) Get(ctx context.Context, id uuid.UUID) (*Proposal, error) {
+	const op = "ProposalService.Get"
+
+	p, err := s.repo.Get(ctx, id)
+	if err != nil {
+		if werrors.IsNotFound(err) {
+			return nil, werrors.NewError("NOT_FOUND", fmt.Sprintf("Proposal not found: %s", id), op, err)
+		}
+		return nil, werrors.NewError("LOOKUP_FAILED", "Failed to retrieve proposal", op, err)
+	}
+	return p, nil
+}
+
+func (s *service) List(ctx context.Context, filter Filter) ([]*Proposal, error) {
+	const op = "ProposalService.List"
+
+	if filter.State != "" && !filter.State.Valid() {
+		return nil, werrors.NewError("INVALID_INPUT", fmt.Sprintf("unknown proposal state %q", filter.State), op, werrors.ErrInvalidInput)
+	}
+
+	proposals, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, werrors.NewError("LOOKUP_FAILED", "Failed to list proposals", op, err)
+	}
+	return proposals, nil
+}
+
+func (s *service) Approve(ctx context.Context, id uuid.UUID, operator string) (*Proposal, error) {
+	const op = "ProposalService.Approve"
+
+	p, err := s.pending(ctx, op, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// The change goes through the display service like any other location
+	// update, so it is validated and announced the same way
+	if err := s.displays.UpdateLocation(ctx, p.DisplayID, p.Location); err != nil {
+		return nil, err
+	}
+
+	return s.decide(ctx, op, p, StateApproved, operator)
+}
+
+func (s *service) Reject(ctx context.Context, id uuid.UUID, operator string) (*Proposal, error) {
+	const op = "ProposalService.Reject"
+
+	p, err := s.pending(ctx, op, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.decide(ctx, op, p, StateRejected, operator)
+}
+
+func (s *service) ExpireStale(ctx context.Context) error {
+	const op = "ProposalService.ExpireStale"
+
+	now := time.Now()
+	if _, err := s.repo.ExpirePending(ctx, now.Add(-s.maxAge), now); err != nil {
+		return werrors.NewError("EXPIRE_FAILED", "Failed to expire proposals", op, err)
+	}
+	return nil
+}
+
+// pending retrieves a proposal that can still be decided. Proposals past
+// the maximum age are refused even if the expiry job has not run yet.
+func (s *service) pending(ctx context.Context, op string, id uuid.UUID) (*Proposal, error) {
+	p, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.State == StatePending && time.Since(p.CreatedAt) > s.maxAge {
+		p.State = StateExpired
+	}
+	if p.State != StatePending {
+		return nil, werrors.NewError("INVALID_STATE", fmt.Sprintf("proposal is %s, not pending", p.State), op, werrors.ErrConflict)
+	}
+	return p, nil
+}
+
+// decide records the outcome of a pending proposal
+func (s *service) decide(ctx context.Context, op string, p *Proposal, state State, operator string) (*Proposal, error) {
+	now := time.Now()
+	if err := s.repo.Decide(ctx, p.ID, state, operator, now); err != nil {
+		if werrors.IsConflict(err) {
+			return nil, werrors.NewError("INVALID_STATE", "proposal was decided concurrently", op, err)
+		}
+		return nil, werrors.NewError("SAVE_FAILED", "Failed to record decision", op, err)
+	}
+
+	p.State = state
+	p.DecidedAt = now
+	p.DecidedBy = operator
+	return p, nil
+}
@@ -0,0 +1,119 @@
+package proposal_test
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/proposal"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+type discardPublisher struct{}
+
+func (discardPublisher) Publish(ctx context.Context, event display.Event) error { return nil }
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	planned := display.Location{SiteID: "hq", Zone: "lobby", Position: "north"}
+	actual := display.Location{SiteID: "hq", Zone: "lobby", Position: "south"}
+
+	setup := func(t *testing.T) (proposal.Service, proposal.Repository, display.Service, *display.Display) {
+		logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+		displays := display.NewService(memory.NewRepository(), discardPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, logger)
+		d, err := displays.Register(ctx, "lobby-north", planned, nil)
+		require.NoError(t, err)
+		repo := memory.NewProposalRepository()
+		return proposal.NewService(repo, displays, time.Hour), repo, displays, d
+	}
+	location := func(t *testing.T, displays display.Service, d *display.Display) display.Location {
+		stored, err := displays.Get(ctx, d.ID)
+		require.NoError(t, err)
+		return stored.Location
+	}
+
+	t.Run("approval applies the location", func(t *testing.T) {
+		svc, _, displays, d := setup(t)
+
+		p, err := svc.Propose(ctx, d.ID, actual, "mounted by the south door")
+		require.NoError(t, err)
+		assert.Equal(t, proposal.StatePending, p.State)
+		assert.Equal(t, planned, p.PreviousLocation)
+		assert.Equal(t, planned, location(t, displays, d), "proposing changes nothing")
+
+		pending, err := svc.List(ctx, proposal.Filter{State: proposal.StatePending})
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+
+		approved, err := svc.Approve(ctx, p.ID, "alice@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, proposal.StateApproved, approved.State)
+		assert.Equal(t, "alice@example.com", approved.DecidedBy)
+		assert.Equal(t, actual, location(t, displays, d))
+
+		_, err = svc.Reject(ctx, p.ID, "bob@example.com")
+		assert.True(t, werrors.IsConflict(err), "got %v", err)
+	})
+
+	t.Run("rejection leaves the display untouched", func(t *testing.T) {
+		svc, _, displays, d := setup(t)
+
+		p, err := svc.Propose(ctx, d.ID, actual, "")
+		require.NoError(t, err)
+
+		rejected, err := svc.Reject(ctx, p.ID, "alice@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, proposal.StateRejected, rejected.State)
+		assert.Equal(t, planned, location(t, displays, d))
+
+		_, err = svc.Approve(ctx, p.ID, "alice@example.com")
+		assert.True(t, werrors.IsConflict(err), "got %v", err)
+		assert.Equal(t, planned, location(t, displays, d))
+	})
+
+	t.Run("invalid proposals are refused", func(t *testing.T) {
+		svc, _, _, d := setup(t)
+
+		_, err := svc.Propose(ctx, d.ID, planned, "")
+		assert.True(t, werrors.IsInvalidInput(err), "got %v", err)
+		_, err = svc.Propose(ctx, d.ID, display.Location{Zone: "lobby"}, "")
+		assert.True(t, werrors.IsInvalidInput(err), "got %v", err)
+	})
+
+	t.Run("old proposals expire", func(t *testing.T) {
+		svc, repo, displays, d := setup(t)
+
+		stale := &proposal.Proposal{
+			ID:        uuid.New(),
+			DisplayID: d.ID,
+			Location:  actual,
+			State:     proposal.StatePending,
+			CreatedAt: time.Now().Add(-2 * time.Hour),
+		}
+		require.NoError(t, repo.Create(ctx, stale))
+
+		// Too old to approve even before the expiry job has run
+		_, err := svc.Approve(ctx, stale.ID, "alice@example.com")
+		assert.True(t, werrors.IsConflict(err), "got %v", err)
+		assert.Equal(t, planned, location(t, displays, d))
+
+		fresh, err := svc.Propose(ctx, d.ID, actual, "")
+		require.NoError(t, err)
+
+		require.NoError(t, svc.ExpireStale(ctx))
+		expired, err := svc.Get(ctx, stale.ID)
+		require.NoError(t, err)
+		assert.Equal(t, proposal.StateExpired, expired.State)
+		kept, err := svc.Get(ctx, fresh.ID)
+		require.NoError(t, err)
+		assert.Equal(t, proposal.StatePending, kept.State)
+	})
+}
@@ -0,0 +1,106 @@
+package display
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// MinPruneOfflineFor is the shortest time a display must have been silent
+// before it may be pruned. It keeps a mistyped threshold from sweeping up
+// displays that are only briefly down.
+const MinPruneOfflineFor = 24 * time.Hour
+
+// PruneFilter selects the offline displays to prune
+type PruneFilter struct {
+	// OfflineFor is how long a display must have gone without checking in
+	OfflineFor time.Duration
+	// SiteID limits pruning to one site when set
+	SiteID string
+}
+
+// PruneResult reports what pruning found and did
+type PruneResult struct {
+	// Matched are the offline displays silent for longer than the
+	// threshold, longest silent first
+	Matched []*Display
+	// Deleted are the matched displays that were removed. It is empty on a
+	// dry run.
+	Deleted []*Display
+	// Skipped counts matched displays that were kept because they checked
+	// in or changed state before they could be removed
+	Skipped int
+}
+
+// PruneOffline deletes the offline displays silent for longer than
+// filter.OfflineFor. Displays in any other state are never deleted, however
+// long ago they were last seen. Each deletion is checked against the
+// display's current state, so one that comes back while pruning runs is
+// kept. When some deletions fail the result still lists those that
+// succeeded.
+func (s *service) PruneOffline(ctx context.Context, filter PruneFilter, dryRun bool) (*PruneResult, error) {
+	const op = "DisplayService.PruneOffline"
+
+	if filter.OfflineFor < MinPruneOfflineFor {
+		return nil, errors.NewError("INVALID_INPUT",
+			fmt.Sprintf("offline period %s must be at least %s", filter.OfflineFor, MinPruneOfflineFor),
+			op, errors.ErrInvalidInput)
+	}
+
+	cutoff := s.clock.Now().Add(-filter.OfflineFor)
+	matched, err := s.repo.List(ctx, DisplayFilter{
+		SiteID:     filter.SiteID,
+		States:     []State{StateOffline},
+		SeenBefore: cutoff,
+	})
+	if err != nil {
+		return nil, errors.NewError("LIST_FAILED", "Failed to find offline displays", op, err)
+	}
+	sortByLastSeen(matched)
+
+	result := &PruneResult{Matched: matched, Deleted: []*Display{}}
+	if dryRun {
+		return result, nil
+	}
+
+	var failed int
+	var lastErr error
+	for _, display := range matched {
+		deleted, err := s.repo.DeleteOffline(ctx, display.ID, cutoff)
+		if err != nil {
+			failed++
+			lastErr = err
+			continue
+		}
+		if !deleted {
+			result.Skipped++
+			continue
+		}
+
+		result.Deleted = append(result.Deleted, display)
+		s.publish(ctx, Event{
+			Type:      EventDeleted,
+			DisplayID: display.ID,
+			Timestamp: s.clock.Now(),
+			Data: map[string]string{
+				"siteId":   display.Location.SiteID,
+				"lastSeen": display.LastSeen.Format(time.RFC3339),
+			},
+		})
+	}
+
+	if lastErr != nil {
+		return result, errors.NewError("DELETE_FAILED", fmt.Sprintf("Failed to delete %d offline displays", failed), op, lastErr)
+	}
+	return result, nil
+}
+
+// sortByLastSeen orders displays longest silent first
+func sortByLastSeen(displays []*Display) {
+	sort.SliceStable(displays, func(i, j int) bool {
+		return displays[i].LastSeen.Before(displays[j].LastSeen)
+	})
+}
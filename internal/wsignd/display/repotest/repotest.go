@@ -0,0 +1,583 @@
+// Package repotest provides a conformance suite for display.Repository
+// implementations so that every store honours the same contract
+package repotest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// Run exercises a display repository. newRepo must return an empty
+// repository each time it is called.
+func Run(t *testing.T, newRepo func(t *testing.T) display.Repository) {
+	ctx := context.Background()
+
+	newDisplay := func(t *testing.T, name, site, zone string) *display.Display {
+		d, err := display.NewDisplay(name, display.Location{SiteID: site, Zone: zone, Position: "main"})
+		require.NoError(t, err)
+		// Stores keep at least microsecond precision
+		d.LastSeen = d.LastSeen.UTC().Truncate(time.Millisecond)
+		return d
+	}
+
+	t.Run("save and find", func(t *testing.T) {
+		repo := newRepo(t)
+		d := newDisplay(t, "lobby-north", "hq", "lobby")
+		d.Properties["orientation"] = "portrait"
+		require.NoError(t, repo.Save(ctx, d))
+		assert.Equal(t, 1, d.Version)
+
+		for _, find := range []func() (*display.Display, error){
+			func() (*display.Display, error) { return repo.FindByID(ctx, d.ID) },
+			func() (*display.Display, error) { return repo.FindByName(ctx, d.Name) },
+		} {
+			stored, err := find()
+			require.NoError(t, err)
+			assert.Equal(t, d.ID, stored.ID)
+			assert.Equal(t, d.Name, stored.Name)
+			assert.Equal(t, d.Location, stored.Location)
+			assert.Equal(t, d.State, stored.State)
+			assert.True(t, d.LastSeen.Equal(stored.LastSeen))
+			assert.Equal(t, 1, stored.Version)
+			assert.Equal(t, map[string]string{"orientation": "portrait"}, stored.Properties)
+		}
+
+		// Returned displays do not alias stored state
+		stored, err := repo.FindByID(ctx, d.ID)
+		require.NoError(t, err)
+		stored.Properties["orientation"] = "landscape"
+		again, err := repo.FindByID(ctx, d.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "portrait", again.Properties["orientation"])
+	})
+
+	t.Run("updates advance the version", func(t *testing.T) {
+		repo := newRepo(t)
+		d := newDisplay(t, "lobby-north", "hq", "lobby")
+		require.NoError(t, repo.Save(ctx, d))
+
+		require.NoError(t, d.Activate())
+		require.NoError(t, repo.Save(ctx, d))
+		assert.Equal(t, 2, d.Version)
+
+		stored, err := repo.FindByID(ctx, d.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, stored.Version)
+		assert.Equal(t, display.StateActive, stored.State)
+	})
+
+	t.Run("settings are kept", func(t *testing.T) {
+		repo := newRepo(t)
+		d := newDisplay(t, "lobby-north", "hq", "lobby")
+		require.NoError(t, repo.Save(ctx, d))
+
+		stored, err := repo.FindByID(ctx, d.ID)
+		require.NoError(t, err)
+		assert.True(t, stored.Settings.Empty())
+
+		brightness := 80
+		settings := display.Settings{
+			Orientation:    display.OrientationPortrait,
+			Brightness:     &brightness,
+			ScreenSchedule: []display.ScreenWindow{{Start: "07:00", End: "19:30"}},
+		}
+		stored.Settings = settings
+		require.NoError(t, repo.Save(ctx, stored))
+
+		// Changing the saved settings must not reach the store
+		*stored.Settings.Brightness = 10
+		again, err := repo.FindByID(ctx, d.ID)
+		require.NoError(t, err)
+		assert.Equal(t, display.OrientationPortrait, again.Settings.Orientation)
+		require.NotNil(t, again.Settings.Brightness)
+		assert.Equal(t, 80, *again.Settings.Brightness)
+		assert.Nil(t, again.Settings.Volume)
+		assert.Equal(t, settings.ScreenSchedule, again.Settings.ScreenSchedule)
+	})
+
+	t.Run("stale version is rejected", func(t *testing.T) {
+		repo := newRepo(t)
+		d := newDisplay(t, "lobby-north", "hq", "lobby")
+		require.NoError(t, repo.Save(ctx, d))
+
+		first, err := repo.FindByID(ctx, d.ID)
+		require.NoError(t, err)
+		second, err := repo.FindByID(ctx, d.ID)
+		require.NoError(t, err)
+
+		require.NoError(t, first.SetProperty("owner", "facilities", config.DefaultPropertyLimits()))
+		require.NoError(t, repo.Save(ctx, first))
+
+		require.NoError(t, second.SetProperty("owner", "marketing", config.DefaultPropertyLimits()))
+		err = repo.Save(ctx, second)
+		assert.True(t, werrors.IsVersionMismatch(err), "got %v", err)
+
+		stored, err := repo.FindByID(ctx, d.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "facilities", stored.Properties["owner"])
+	})
+
+	t.Run("duplicate name conflicts", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.Save(ctx, newDisplay(t, "lobby-north", "hq", "lobby")))
+
+		err := repo.Save(ctx, newDisplay(t, "lobby-north", "hq", "lobby"))
+		assert.True(t, werrors.IsConflict(err), "got %v", err)
+	})
+
+	t.Run("rename keeps the display", func(t *testing.T) {
+		repo := newRepo(t)
+		d := newDisplay(t, "lobby-north", "hq", "lobby")
+		require.NoError(t, repo.Save(ctx, d))
+		require.NoError(t, repo.Save(ctx, newDisplay(t, "Lobby-South", "hq", "lobby")))
+
+		d.Name = "lobby-east"
+		require.NoError(t, repo.Save(ctx, d))
+
+		stored, err := repo.FindByName(ctx, "lobby-east")
+		require.NoError(t, err)
+		assert.Equal(t, d.ID, stored.ID)
+		assert.Equal(t, 2, stored.Version)
+		_, err = repo.FindByName(ctx, "lobby-north")
+		assert.True(t, werrors.IsNotFound(err), "got %v", err)
+
+		// Renaming onto a name in use conflicts
+		d.Name = "Lobby-South"
+		err = repo.Save(ctx, d)
+		assert.True(t, werrors.IsConflict(err), "got %v", err)
+	})
+
+	t.Run("find by name ignoring case", func(t *testing.T) {
+		repo := newRepo(t)
+		upper := newDisplay(t, "LOBBY-North", "hq", "lobby")
+		require.NoError(t, repo.Save(ctx, upper))
+
+		found, err := repo.FindByNameFold(ctx, "lobby-north")
+		require.NoError(t, err)
+		assert.Equal(t, upper.ID, found.ID)
+
+		// An exact match wins over names differing only in case
+		exact := newDisplay(t, "lobby-north", "hq", "lobby")
+		require.NoError(t, repo.Save(ctx, exact))
+		found, err = repo.FindByNameFold(ctx, "lobby-north")
+		require.NoError(t, err)
+		assert.Equal(t, exact.ID, found.ID)
+
+		_, err = repo.FindByNameFold(ctx, "lobby-south")
+		assert.True(t, werrors.IsNotFound(err), "got %v", err)
+	})
+
+	t.Run("create stops at the caps", func(t *testing.T) {
+		repo := newRepo(t)
+		limits := display.Limits{Site: 3, Zone: 2}
+		create := func(name, site, zone string) error {
+			return repo.Create(ctx, newDisplay(t, name, site, zone), limits)
+		}
+
+		require.NoError(t, create("lobby-1", "hq", "lobby"))
+		require.NoError(t, create("lobby-2", "hq", "lobby"))
+		err := create("lobby-3", "hq", "lobby")
+		assert.True(t, werrors.IsLimitExceeded(err), "got %v", err)
+		assert.Contains(t, err.Error(), `zone "hq/lobby"`)
+
+		// The site has room for exactly one more in another zone
+		require.NoError(t, create("cafe-1", "hq", "cafeteria"))
+		err = create("cafe-2", "hq", "cafeteria")
+		assert.True(t, werrors.IsLimitExceeded(err), "got %v", err)
+		assert.Contains(t, err.Error(), `site "hq"`)
+
+		// Other sites have their own count, and no caps means none apply
+		require.NoError(t, create("annex-1", "annex", "lobby"))
+		require.NoError(t, repo.Create(ctx, newDisplay(t, "lobby-4", "hq", "lobby"), display.Limits{}))
+
+		displays, err := repo.List(ctx, display.DisplayFilter{SiteID: "hq"})
+		require.NoError(t, err)
+		assert.Len(t, displays, 4)
+
+		err = repo.Create(ctx, displays[0], display.Limits{})
+		assert.True(t, werrors.IsConflict(err), "got %v", err)
+	})
+
+	t.Run("concurrent creates do not overshoot a cap", func(t *testing.T) {
+		repo := newRepo(t)
+		const limit, attempts = 5, 12
+
+		var wg sync.WaitGroup
+		results := make(chan error, attempts)
+		for i := 0; i < attempts; i++ {
+			d := newDisplay(t, fmt.Sprintf("lobby-%d", i), "hq", "lobby")
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results <- repo.Create(ctx, d, display.Limits{Site: limit})
+			}()
+		}
+		wg.Wait()
+		close(results)
+
+		created := 0
+		for err := range results {
+			if err == nil {
+				created++
+				continue
+			}
+			assert.True(t, werrors.IsLimitExceeded(err), "got %v", err)
+		}
+		assert.Equal(t, limit, created)
+	})
+
+	t.Run("missing displays are not found", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.FindByID(ctx, uuid.New())
+		assert.True(t, werrors.IsNotFound(err), "got %v", err)
+		_, err = repo.FindByName(ctx, "missing")
+		assert.True(t, werrors.IsNotFound(err), "got %v", err)
+		err = repo.Delete(ctx, uuid.New())
+		assert.True(t, werrors.IsNotFound(err), "got %v", err)
+	})
+
+	t.Run("timestamps are kept", func(t *testing.T) {
+		repo := newRepo(t)
+		d := newDisplay(t, "lobby-north", "hq", "lobby")
+		require.NoError(t, repo.Save(ctx, d))
+		require.False(t, d.CreatedAt.IsZero())
+		assert.True(t, d.UpdatedAt.Equal(d.CreatedAt))
+		created := d.CreatedAt
+
+		// Let the clock move so the update is distinguishable
+		time.Sleep(10 * time.Millisecond)
+		d.Properties["orientation"] = "portrait"
+		require.NoError(t, repo.Save(ctx, d))
+		assert.True(t, d.CreatedAt.Equal(created))
+		assert.True(t, d.UpdatedAt.After(created), "updated_at did not advance")
+
+		stored, err := repo.FindByID(ctx, d.ID)
+		require.NoError(t, err)
+		assert.True(t, stored.CreatedAt.Equal(created))
+		assert.True(t, stored.UpdatedAt.Equal(d.UpdatedAt))
+	})
+
+	t.Run("list is oldest first", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, name := range []string{"c-display", "a-display", "b-display"} {
+			require.NoError(t, repo.Save(ctx, newDisplay(t, name, "hq", "lobby")))
+			time.Sleep(2 * time.Millisecond)
+		}
+
+		displays, err := repo.List(ctx, display.DisplayFilter{})
+		require.NoError(t, err)
+		var names []string
+		for _, d := range displays {
+			names = append(names, d.Name)
+		}
+		assert.Equal(t, []string{"c-display", "a-display", "b-display"}, names)
+	})
+
+	t.Run("list filters", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, d := range []*display.Display{
+			newDisplay(t, "hq-lobby", "hq", "lobby"),
+			newDisplay(t, "hq-cafe", "hq", "cafeteria"),
+			newDisplay(t, "annex-lobby", "annex", "lobby"),
+		} {
+			if d.Name != "hq-cafe" {
+				require.NoError(t, d.Activate())
+				d.Seen(time.Now().UTC().Truncate(time.Millisecond))
+			}
+			require.NoError(t, repo.Save(ctx, d))
+		}
+
+		names := func(filter display.DisplayFilter) []string {
+			displays, err := repo.List(ctx, filter)
+			require.NoError(t, err)
+			var names []string
+			for _, d := range displays {
+				names = append(names, d.Name)
+			}
+			return names
+		}
+
+		assert.ElementsMatch(t, []string{"hq-lobby", "hq-cafe", "annex-lobby"}, names(display.DisplayFilter{}))
+		assert.ElementsMatch(t, []string{"hq-lobby", "hq-cafe"}, names(display.DisplayFilter{SiteID: "hq"}))
+		assert.ElementsMatch(t, []string{"hq-lobby", "annex-lobby"}, names(display.DisplayFilter{Zone: "lobby"}))
+		assert.ElementsMatch(t, []string{"hq-lobby", "annex-lobby"}, names(display.DisplayFilter{
+			States: []display.State{display.StateActive},
+		}))
+		assert.ElementsMatch(t, []string{"hq-cafe"}, names(display.DisplayFilter{
+			SiteID: "hq",
+			States: []display.State{display.StateUnregistered, display.StateDisabled},
+		}))
+		assert.Empty(t, names(display.DisplayFilter{SiteID: "elsewhere"}))
+		assert.ElementsMatch(t, []string{"hq-cafe"}, names(display.DisplayFilter{NeverSeen: true}))
+		assert.ElementsMatch(t, []string{"hq-lobby", "annex-lobby"}, names(display.DisplayFilter{Seen: true}))
+		assert.Empty(t, names(display.DisplayFilter{Zone: "lobby", NeverSeen: true}))
+	})
+
+	t.Run("property filters agree with in-memory matching", func(t *testing.T) {
+		repo := newRepo(t)
+		fixtures := []*display.Display{
+			newDisplay(t, "hq-portrait", "hq", "lobby"),
+			newDisplay(t, "hq-landscape", "hq", "lobby"),
+			newDisplay(t, "hq-portrait-4k", "hq", "cafeteria"),
+			newDisplay(t, "annex-portrait", "annex", "lobby"),
+			newDisplay(t, "hq-bare", "hq", "lobby"),
+		}
+		properties := map[string]map[string]string{
+			"hq-portrait":    {"orientation": "portrait"},
+			"hq-landscape":   {"orientation": "landscape"},
+			"hq-portrait-4k": {"orientation": "portrait", "resolution": "4k"},
+			"annex-portrait": {"orientation": "portrait"},
+		}
+		for _, d := range fixtures {
+			d.Properties = properties[d.Name]
+			if d.Properties == nil {
+				d.Properties = map[string]string{}
+			}
+			require.NoError(t, repo.Save(ctx, d))
+		}
+
+		filters := []display.DisplayFilter{
+			{Properties: map[string]string{"orientation": "portrait"}},
+			{SiteID: "hq", Properties: map[string]string{"orientation": "portrait"}},
+			{Properties: map[string]string{"orientation": "portrait", "resolution": "4k"}},
+			{Properties: map[string]string{"resolution": "8k"}},
+			{Properties: map[string]string{"orientation": ""}},
+			{Zone: "lobby", Properties: map[string]string{}},
+		}
+		for _, filter := range filters {
+			var want []string
+			for _, d := range fixtures {
+				if filter.Matches(d) {
+					want = append(want, d.Name)
+				}
+			}
+
+			displays, err := repo.List(ctx, filter)
+			require.NoError(t, err)
+			var got []string
+			for _, d := range displays {
+				got = append(got, d.Name)
+			}
+			assert.ElementsMatch(t, want, got, "filter %+v", filter)
+		}
+
+		displays, err := repo.List(ctx, filters[1])
+		require.NoError(t, err)
+		require.Len(t, displays, 2)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		repo := newRepo(t)
+		d := newDisplay(t, "lobby-north", "hq", "lobby")
+		require.NoError(t, repo.Save(ctx, d))
+
+		require.NoError(t, repo.Delete(ctx, d.ID))
+		_, err := repo.FindByID(ctx, d.ID)
+		assert.True(t, werrors.IsNotFound(err), "got %v", err)
+	})
+
+	t.Run("delete offline", func(t *testing.T) {
+		repo := newRepo(t)
+		now := time.Now().UTC().Truncate(time.Millisecond)
+		save := func(name string, state display.State, ago time.Duration) *display.Display {
+			d := newDisplay(t, name, "hq", "lobby")
+			d.State = state
+			d.LastSeen = now.Add(-ago)
+			require.NoError(t, repo.Save(ctx, d))
+			return d
+		}
+		gone := save("gone", display.StateOffline, 48*time.Hour)
+		recent := save("recent", display.StateOffline, time.Hour)
+		active := save("active", display.StateActive, 48*time.Hour)
+		save("disabled", display.StateDisabled, 48*time.Hour)
+
+		cutoff := now.Add(-24 * time.Hour)
+		seenBefore, err := repo.List(ctx, display.DisplayFilter{SeenBefore: cutoff})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"gone", "active", "disabled"}, displayNames(seenBefore))
+
+		for _, d := range []*display.Display{recent, active} {
+			deleted, err := repo.DeleteOffline(ctx, d.ID, cutoff)
+			require.NoError(t, err)
+			assert.False(t, deleted, d.Name)
+		}
+
+		deleted, err := repo.DeleteOffline(ctx, gone.ID, cutoff)
+		require.NoError(t, err)
+		assert.True(t, deleted)
+		_, err = repo.FindByID(ctx, gone.ID)
+		assert.True(t, werrors.IsNotFound(err), "got %v", err)
+
+		deleted, err = repo.DeleteOffline(ctx, gone.ID, cutoff)
+		require.NoError(t, err)
+		assert.False(t, deleted, "a display already gone is not deleted again")
+	})
+
+	t.Run("content history", func(t *testing.T) {
+		repo := newRepo(t)
+		d := newDisplay(t, "lobby-north", "hq", "lobby")
+		require.NoError(t, repo.Save(ctx, d))
+
+		const keep = 3
+		now := time.Now().UTC().Truncate(time.Millisecond)
+		for i := 0; i < 5; i++ {
+			err := repo.AppendContentTransition(ctx, &display.ContentTransition{
+				DisplayID: d.ID,
+				Timestamp: now,
+				ToURL:     fmt.Sprintf("https://example.com/%d", i),
+				Trigger:   display.TriggerSequence,
+			}, keep)
+			require.NoError(t, err)
+		}
+
+		history, err := repo.ListContentTransitions(ctx, d.ID, 10)
+		require.NoError(t, err)
+		require.Len(t, history, keep)
+		for i, tr := range history {
+			assert.Equal(t, d.ID, tr.DisplayID)
+			assert.Equal(t, fmt.Sprintf("https://example.com/%d", 4-i), tr.ToURL)
+			assert.Equal(t, fmt.Sprintf("https://example.com/%d", 3-i), tr.FromURL)
+			assert.Equal(t, display.TriggerSequence, tr.Trigger)
+			assert.True(t, now.Equal(tr.Timestamp))
+		}
+
+		limited, err := repo.ListContentTransitions(ctx, d.ID, 1)
+		require.NoError(t, err)
+		require.Len(t, limited, 1)
+		assert.Equal(t, "https://example.com/4", limited[0].ToURL)
+
+		empty, err := repo.ListContentTransitions(ctx, uuid.New(), 10)
+		require.NoError(t, err)
+		assert.Empty(t, empty)
+
+		err = repo.AppendContentTransition(ctx, &display.ContentTransition{
+			DisplayID: uuid.New(),
+			Timestamp: now,
+			ToURL:     "https://example.com/orphan",
+			Trigger:   display.TriggerSequence,
+		}, keep)
+		assert.True(t, werrors.IsNotFound(err), "got %v", err)
+	})
+
+	t.Run("count by state", func(t *testing.T) {
+		repo := newRepo(t)
+		for i, state := range []display.State{display.StateActive, display.StateActive, display.StateOffline} {
+			d := newDisplay(t, fmt.Sprintf("hq-%d", i), "hq", "lobby")
+			d.State = state
+			require.NoError(t, repo.Save(ctx, d))
+		}
+		require.NoError(t, repo.Save(ctx, newDisplay(t, "annex-0", "annex", "lobby")))
+
+		counts, err := repo.CountByState(ctx, display.DisplayFilter{})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []display.StateCount{
+			{SiteID: "annex", State: display.StateUnregistered, Count: 1},
+			{SiteID: "hq", State: display.StateActive, Count: 2},
+			{SiteID: "hq", State: display.StateOffline, Count: 1},
+		}, counts)
+
+		counts, err = repo.CountByState(ctx, display.DisplayFilter{SiteID: "hq", States: []display.State{display.StateActive}})
+		require.NoError(t, err)
+		assert.Equal(t, []display.StateCount{{SiteID: "hq", State: display.StateActive, Count: 2}}, counts)
+
+		counts, err = repo.CountByState(ctx, display.DisplayFilter{SiteID: "elsewhere"})
+		require.NoError(t, err)
+		assert.Empty(t, counts)
+	})
+
+	t.Run("find stale", func(t *testing.T) {
+		repo := newRepo(t)
+		now := time.Now().UTC().Truncate(time.Millisecond)
+		seen := map[string]struct {
+			state display.State
+			ago   time.Duration
+		}{
+			"silent-long":  {display.StateActive, 3 * time.Hour},
+			"silent-short": {display.StateActive, 2 * time.Hour},
+			"silent-most":  {display.StateActive, 4 * time.Hour},
+			"recent":       {display.StateActive, time.Minute},
+			"offline":      {display.StateOffline, 5 * time.Hour},
+			"disabled":     {display.StateDisabled, 5 * time.Hour},
+		}
+		for name, s := range seen {
+			d := newDisplay(t, name, "hq", "lobby")
+			d.State = s.state
+			d.LastSeen = now.Add(-s.ago)
+			require.NoError(t, repo.Save(ctx, d))
+		}
+		// An active display that has never connected is not stale
+		never := newDisplay(t, "never", "hq", "lobby")
+		never.State = display.StateActive
+		require.NoError(t, repo.Save(ctx, never))
+
+		stale, err := repo.FindStale(ctx, now.Add(-time.Hour), 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"silent-most", "silent-long", "silent-short"}, displayNames(stale))
+
+		stale, err = repo.FindStale(ctx, now.Add(-time.Hour), 2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"silent-most", "silent-long"}, displayNames(stale))
+
+		stale, err = repo.FindStale(ctx, now.Add(-10*time.Hour), 10)
+		require.NoError(t, err)
+		assert.Empty(t, stale)
+	})
+
+	t.Run("state snapshots", func(t *testing.T) {
+		repo := newRepo(t)
+		start := time.Now().UTC().Truncate(time.Millisecond)
+		at := func(hours int) time.Time { return start.Add(time.Duration(hours) * time.Hour) }
+
+		for hours := 0; hours < 3; hours++ {
+			require.NoError(t, repo.SaveStateSnapshots(ctx, []*display.StateSnapshot{
+				{TakenAt: at(hours), SiteID: "hq", Counts: map[display.State]int{display.StateActive: 10 + hours}},
+				{TakenAt: at(hours), SiteID: "annex", Counts: map[display.State]int{display.StateOffline: hours}},
+			}))
+		}
+
+		all, err := repo.ListStateSnapshots(ctx, "", at(1))
+		require.NoError(t, err)
+		require.Len(t, all, 4)
+		assert.Equal(t, "annex", all[0].SiteID)
+		assert.True(t, at(1).Equal(all[0].TakenAt))
+		assert.Equal(t, "hq", all[3].SiteID)
+		assert.Equal(t, map[display.State]int{display.StateActive: 12}, all[3].Counts)
+
+		hq, err := repo.ListStateSnapshots(ctx, "hq", at(0))
+		require.NoError(t, err)
+		require.Len(t, hq, 3)
+		for i, s := range hq {
+			assert.Equal(t, "hq", s.SiteID)
+			assert.Equal(t, 10+i, s.Counts[display.StateActive])
+		}
+
+		require.NoError(t, repo.PruneStateSnapshots(ctx, at(2)))
+		kept, err := repo.ListStateSnapshots(ctx, "", at(0))
+		require.NoError(t, err)
+		require.Len(t, kept, 2)
+		for _, s := range kept {
+			assert.True(t, at(2).Equal(s.TakenAt))
+		}
+	})
+}
+
+// displayNames lists the names of displays in order
+func displayNames(displays []*display.Display) []string {
+	names := make([]string, len(displays))
+	for i, d := range displays {
+		names[i] = d.Name
+	}
+	return names
+}
@@ -4,30 +4,87 @@ package display
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/google/uuid"
 
+	"github.com/wrale/wrale-signage/internal/wsignd/clock"
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
 	"github.com/wrale/wrale-signage/internal/wsignd/errors"
 )
 
+// maxPatchAttempts bounds how often an unconditional patch is reapplied when
+// it races with another update
+const maxPatchAttempts = 3
+
+// reapBatchSize is how many stale displays ReapOffline loads at a time
+const reapBatchSize = 500
+
 // service implements the display.Service interface by coordinating between
 // the domain model, repository, and event publisher while enforcing business rules.
 type service struct {
-	repo      Repository
-	publisher EventPublisher
+	repo        Repository
+	publisher   EventPublisher
+	historySize int
+	liveness    Liveness
+	approval    ApprovalPolicy
+	capacity    Capacity
+	logger      *slog.Logger
+	clock       clock.Clock
+	limits      config.PropertyLimits
 }
 
-// NewService creates a new display service instance.
-func NewService(repo Repository, publisher EventPublisher) Service {
-	return &service{
-		repo:      repo,
-		publisher: publisher,
+// Option configures a display service
+type Option func(*service)
+
+// WithClock sets the clock check-ins are recorded and silence is measured
+// against
+func WithClock(c clock.Clock) Option {
+	return func(s *service) {
+		s.clock = c
 	}
 }
 
+// WithPropertyLimits sets the limits display properties are held to
+func WithPropertyLimits(limits config.PropertyLimits) Option {
+	return func(s *service) {
+		s.limits = limits
+	}
+}
+
+// NewService creates a new display service instance. historySize is the
+// number of content transitions kept per display and is clamped to
+// MaxContentHistorySize. liveness controls when silent displays are marked
+// offline, approval which newly activated displays must be approved first,
+// and capacity how many displays each site and zone may hold. Registrations and state changes are logged at info level; check-ins
+// that change nothing are logged at debug level since every display makes
+// one each minute. The service reads the system clock unless given another
+// with WithClock, and holds properties to config.DefaultPropertyLimits
+// unless given others with WithPropertyLimits.
+func NewService(repo Repository, publisher EventPublisher, historySize int, liveness Liveness, approval ApprovalPolicy, capacity Capacity, logger *slog.Logger, opts ...Option) Service {
+	s := &service{
+		repo:        repo,
+		publisher:   publisher,
+		historySize: ClampContentHistorySize(historySize),
+		liveness:    liveness,
+		approval:    approval,
+		capacity:    capacity,
+		logger:      logger,
+		clock:       clock.Real(),
+		limits:      config.DefaultPropertyLimits(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
 // Register creates a new display with the given name and location.
-func (s *service) Register(ctx context.Context, name string, location Location) (*Display, error) {
+// Properties are stored with the display, so a display never exists without
+// the properties it was registered with. Registration fails with
+// LIMIT_EXCEEDED when the display's site or zone is at its cap.
+func (s *service) Register(ctx context.Context, name string, location Location, properties map[string]string) (*Display, error) {
 	const op = "DisplayService.Register"
 
 	// Check if display already exists with this name
@@ -44,9 +101,15 @@ func (s *service) Register(ctx context.Context, name string, location Location)
 	if err != nil {
 		return nil, errors.NewError("INVALID_INPUT", "Failed to create display", op, err)
 	}
+	if err := display.ApplyPatch(Patch{SetProperties: properties}, s.limits); err != nil {
+		return nil, errors.NewError("INVALID_INPUT", err.Error(), op, err)
+	}
 
-	// Persist the new display
-	if err := s.repo.Save(ctx, display); err != nil {
+	// Persist the new display within its site and zone caps
+	if err := s.repo.Create(ctx, display, s.capacity.Limits(location)); err != nil {
+		if errors.IsLimitExceeded(err) {
+			return nil, errors.NewError("LIMIT_EXCEEDED", err.Error(), op, err)
+		}
 		return nil, errors.NewError("SAVE_FAILED", "Failed to save display", op, err)
 	}
 
@@ -54,7 +117,7 @@ func (s *service) Register(ctx context.Context, name string, location Location)
 	event := Event{
 		Type:      EventRegistered,
 		DisplayID: display.ID,
-		Timestamp: time.Now(),
+		Timestamp: s.clock.Now(),
 		Data: map[string]string{
 			"name":    display.Name,
 			"siteId":  display.Location.SiteID,
@@ -64,11 +127,13 @@ func (s *service) Register(ctx context.Context, name string, location Location)
 		},
 	}
 
-	if err := s.publisher.Publish(ctx, event); err != nil {
-		// Log but don't fail the operation if event publishing fails
-		// TODO: Add proper logging
-		fmt.Printf("Failed to publish registration event: %v\n", err)
-	}
+	s.publish(ctx, event)
+
+	s.logger.Info("display registered",
+		"displayId", display.ID,
+		"name", display.Name,
+		"siteId", display.Location.SiteID,
+	)
 
 	return display, nil
 }
@@ -88,6 +153,21 @@ func (s *service) Get(ctx context.Context, id uuid.UUID) (*Display, error) {
 	return display, nil
 }
 
+// GetByName retrieves a display by name.
+func (s *service) GetByName(ctx context.Context, name string) (*Display, error) {
+	const op = "DisplayService.GetByName"
+
+	display, err := s.repo.FindByName(ctx, name)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, errors.NewError("NOT_FOUND", fmt.Sprintf("Display not found: %s", name), op, err)
+		}
+		return nil, errors.NewError("LOOKUP_FAILED", "Failed to retrieve display", op, err)
+	}
+
+	return display, nil
+}
+
 // List retrieves displays matching the filter.
 func (s *service) List(ctx context.Context, filter DisplayFilter) ([]*Display, error) {
 	const op = "DisplayService.List"
@@ -126,11 +206,17 @@ func (s *service) UpdateLocation(ctx context.Context, id uuid.UUID, location Loc
 		return errors.NewError("SAVE_FAILED", "Failed to save location update", op, err)
 	}
 
-	// Publish location changed event
+	s.publishLocationChanged(ctx, display)
+
+	return nil
+}
+
+// publishLocationChanged publishes a location changed event for a display
+func (s *service) publishLocationChanged(ctx context.Context, display *Display) {
 	event := Event{
 		Type:      EventLocationChanged,
 		DisplayID: display.ID,
-		Timestamp: time.Now(),
+		Timestamp: s.clock.Now(),
 		Data: map[string]string{
 			"siteId":   display.Location.SiteID,
 			"zone":     display.Location.Zone,
@@ -139,16 +225,11 @@ func (s *service) UpdateLocation(ctx context.Context, id uuid.UUID, location Loc
 		},
 	}
 
-	if err := s.publisher.Publish(ctx, event); err != nil {
-		// Log but don't fail the operation if event publishing fails
-		// TODO: Add proper logging
-		fmt.Printf("Failed to publish location changed event: %v\n", err)
-	}
-
-	return nil
+	s.publish(ctx, event)
 }
 
-// Activate transitions a display to the active state.
+// Activate transitions a display to the active state. A newly registered
+// display at a site the approval policy covers is held for approval instead.
 func (s *service) Activate(ctx context.Context, id uuid.UUID) error {
 	const op = "DisplayService.Activate"
 
@@ -161,6 +242,10 @@ func (s *service) Activate(ctx context.Context, id uuid.UUID) error {
 		return errors.NewError("LOOKUP_FAILED", "Failed to retrieve display", op, err)
 	}
 
+	if display.State == StateUnregistered && s.approval.Requires(display.Location.SiteID) {
+		return s.changeState(ctx, op, display, EventApprovalRequested, display.AwaitApproval)
+	}
+
 	// Activate through domain model
 	if err := display.Activate(); err != nil {
 		return errors.NewError("INVALID_STATE", "Cannot activate display", op, err)
@@ -178,19 +263,59 @@ func (s *service) Activate(ctx context.Context, id uuid.UUID) error {
 	event := Event{
 		Type:      EventActivated,
 		DisplayID: display.ID,
-		Timestamp: time.Now(),
+		Timestamp: s.clock.Now(),
 		Data: map[string]string{
 			"state":   string(display.State),
 			"version": fmt.Sprint(display.Version),
 		},
 	}
 
-	if err := s.publisher.Publish(ctx, event); err != nil {
-		// Log but don't fail the operation if event publishing fails
-		// TODO: Add proper logging
-		fmt.Printf("Failed to publish activated event: %v\n", err)
+	s.publish(ctx, event)
+	s.logStateChange(display, EventActivated)
+
+	return nil
+}
+
+// Approve activates a display that is awaiting approval.
+func (s *service) Approve(ctx context.Context, id uuid.UUID) error {
+	const op = "DisplayService.Approve"
+
+	display, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return errors.NewError("NOT_FOUND", fmt.Sprintf("Display not found: %s", id), op, err)
+		}
+		return errors.NewError("LOOKUP_FAILED", "Failed to retrieve display", op, err)
+	}
+
+	return s.changeState(ctx, op, display, EventApproved, display.Approve)
+}
+
+// changeState applies a state transition through the domain model, saves
+// the display and publishes event
+func (s *service) changeState(ctx context.Context, op string, display *Display, event EventType, transition func() error) error {
+	if err := transition(); err != nil {
+		return errors.NewError("INVALID_STATE", err.Error(), op, errors.ErrConflict)
 	}
 
+	if err := s.repo.Save(ctx, display); err != nil {
+		if errors.IsVersionMismatch(err) {
+			return errors.NewError("VERSION_CONFLICT", "Display was modified", op, err)
+		}
+		return errors.NewError("SAVE_FAILED", "Failed to save state change", op, err)
+	}
+
+	s.publish(ctx, Event{
+		Type:      event,
+		DisplayID: display.ID,
+		Timestamp: s.clock.Now(),
+		Data: map[string]string{
+			"state":   string(display.State),
+			"version": fmt.Sprint(display.Version),
+		},
+	})
+	s.logStateChange(display, event)
+
 	return nil
 }
 
@@ -222,19 +347,47 @@ func (s *service) Disable(ctx context.Context, id uuid.UUID) error {
 	event := Event{
 		Type:      EventDisabled,
 		DisplayID: display.ID,
-		Timestamp: time.Now(),
+		Timestamp: s.clock.Now(),
 		Data: map[string]string{
 			"state":   string(display.State),
 			"version": fmt.Sprint(display.Version),
 		},
 	}
 
-	if err := s.publisher.Publish(ctx, event); err != nil {
-		// Log but don't fail the operation if event publishing fails
-		// TODO: Add proper logging
-		fmt.Printf("Failed to publish disabled event: %v\n", err)
+	s.publish(ctx, event)
+	s.logStateChange(display, EventDisabled)
+
+	return nil
+}
+
+// Delete removes a display and publishes its removal.
+func (s *service) Delete(ctx context.Context, id uuid.UUID) error {
+	const op = "DisplayService.Delete"
+
+	display, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return errors.NewError("NOT_FOUND", fmt.Sprintf("Display not found: %s", id), op, err)
+		}
+		return errors.NewError("LOOKUP_FAILED", "Failed to retrieve display", op, err)
 	}
 
+	if err := s.repo.Delete(ctx, id); err != nil {
+		if errors.IsNotFound(err) {
+			return errors.NewError("NOT_FOUND", fmt.Sprintf("Display not found: %s", id), op, err)
+		}
+		return errors.NewError("DELETE_FAILED", "Failed to delete display", op, err)
+	}
+
+	s.publish(ctx, Event{
+		Type:      EventDeleted,
+		DisplayID: display.ID,
+		Timestamp: s.clock.Now(),
+		Data: map[string]string{
+			"siteId": display.Location.SiteID,
+		},
+	})
+
 	return nil
 }
 
@@ -252,7 +405,7 @@ func (s *service) UpdateLastSeen(ctx context.Context, id uuid.UUID) error {
 	}
 
 	// Update timestamp through domain model
-	display.UpdateLastSeen()
+	online := display.Seen(s.clock.Now())
 
 	// Persist changes with retry on version conflicts
 	if err := s.repo.Save(ctx, display); err != nil {
@@ -262,9 +415,109 @@ func (s *service) UpdateLastSeen(ctx context.Context, id uuid.UUID) error {
 		return errors.NewError("SAVE_FAILED", "Failed to save timestamp update", op, err)
 	}
 
+	if online {
+		s.publishStateChanged(ctx, display, EventOnline)
+	} else {
+		s.logger.Debug("display seen", "displayId", display.ID)
+	}
+
 	return nil
 }
 
+// ReapOffline marks active displays offline once they have been silent for
+// longer than the offline threshold plus the grace period. Each display
+// produces a single OFFLINE event when it is marked; displays that check in
+// while being reaped are skipped. Stale displays are loaded in batches, so a
+// large outage does not pull every display into memory at once.
+func (s *service) ReapOffline(ctx context.Context) error {
+	const op = "DisplayService.ReapOffline"
+
+	if s.liveness.OfflineAfter <= 0 {
+		return nil
+	}
+
+	cutoff := s.liveness.offlineCutoff(s.clock.Now())
+	var failed int
+	var lastErr error
+	for {
+		displays, err := s.repo.FindStale(ctx, cutoff, reapBatchSize)
+		if err != nil {
+			return errors.NewError("LIST_FAILED", "Failed to find stale displays", op, err)
+		}
+
+		var reaped int
+		for _, display := range displays {
+			if !display.MarkOffline() {
+				continue
+			}
+
+			if err := s.repo.Save(ctx, display); err != nil {
+				if errors.IsVersionMismatch(err) {
+					// Updated since it was found, most likely by a check-in
+					continue
+				}
+				failed++
+				lastErr = err
+				continue
+			}
+
+			reaped++
+			s.publishStateChanged(ctx, display, EventOffline)
+		}
+
+		// A short batch was the last one. A batch in which nothing could be
+		// marked would only be found again, so it ends the run as well.
+		if len(displays) < reapBatchSize || reaped == 0 {
+			break
+		}
+	}
+
+	if lastErr != nil {
+		return errors.NewError("SAVE_FAILED", fmt.Sprintf("Failed to mark %d displays offline", failed), op, lastErr)
+	}
+	return nil
+}
+
+// publishStateChanged publishes and logs a liveness state change
+func (s *service) publishStateChanged(ctx context.Context, display *Display, eventType EventType) {
+	event := Event{
+		Type:      eventType,
+		DisplayID: display.ID,
+		Timestamp: s.clock.Now(),
+		Data: map[string]string{
+			"state":    string(display.State),
+			"lastSeen": display.LastSeen.Format(time.RFC3339),
+			"version":  fmt.Sprint(display.Version),
+		},
+	}
+
+	s.publish(ctx, event)
+	s.logStateChange(display, eventType)
+}
+
+// publish publishes event. A failure is logged but does not fail the
+// operation, which has already been saved.
+func (s *service) publish(ctx context.Context, event Event) {
+	if err := s.publisher.Publish(ctx, event); err != nil {
+		s.logger.Error("failed to publish display event",
+			"error", err,
+			"event", event.Type,
+			"displayId", event.DisplayID,
+		)
+	}
+}
+
+// logStateChange logs that display moved to a new state through event
+func (s *service) logStateChange(display *Display, event EventType) {
+	s.logger.Info("display state changed",
+		"displayId", display.ID,
+		"name", display.Name,
+		"event", event,
+		"state", display.State,
+		"version", display.Version,
+	)
+}
+
 // SetProperty sets a display property.
 func (s *service) SetProperty(ctx context.Context, id uuid.UUID, key, value string) error {
 	const op = "DisplayService.SetProperty"
@@ -283,7 +536,9 @@ func (s *service) SetProperty(ctx context.Context, id uuid.UUID, key, value stri
 	}
 
 	// Update property through domain model
-	display.SetProperty(key, value)
+	if err := display.SetProperty(key, value, s.limits); err != nil {
+		return errors.NewError("INVALID_INPUT", err.Error(), op, err)
+	}
 
 	// Persist changes
 	if err := s.repo.Save(ctx, display); err != nil {
@@ -295,3 +550,226 @@ func (s *service) SetProperty(ctx context.Context, id uuid.UUID, key, value stri
 
 	return nil
 }
+
+// Patch applies a partial update to a display. Property changes are merged
+// into the stored display rather than replacing it, so concurrent edits of
+// different properties are all kept. Without an expected version the patch is
+// reapplied to the latest display when it loses a race with another update.
+// A new name must not be held by another display, ignoring case; the display
+// keeps its ID, so its tokens, connections and history are unaffected.
+func (s *service) Patch(ctx context.Context, id uuid.UUID, patch Patch, expectedVersion int) (*Display, error) {
+	const op = "DisplayService.Patch"
+
+	for attempt := 1; ; attempt++ {
+		display, err := s.repo.FindByID(ctx, id)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return nil, errors.NewError("NOT_FOUND", fmt.Sprintf("Display not found: %s", id), op, err)
+			}
+			return nil, errors.NewError("LOOKUP_FAILED", "Failed to retrieve display", op, err)
+		}
+
+		if expectedVersion != 0 && display.Version != expectedVersion {
+			return nil, errors.NewError("VERSION_CONFLICT",
+				fmt.Sprintf("Display is at version %d, not %d", display.Version, expectedVersion),
+				op, errors.ErrVersionMismatch)
+		}
+
+		p, oldName := patch, display.Name
+		if p.Name == oldName {
+			p.Name = ""
+		}
+
+		// Apply changes through domain model
+		if err := display.ApplyPatch(p, s.limits); err != nil {
+			return nil, errors.NewError("INVALID_INPUT", err.Error(), op, err)
+		}
+		if p.Empty() {
+			return display, nil
+		}
+		if p.Name != "" {
+			if err := s.checkNameFree(ctx, op, display); err != nil {
+				return nil, err
+			}
+		}
+
+		err = s.repo.Save(ctx, display)
+		if err == nil {
+			if p.Name != "" {
+				s.publishRenamed(ctx, display, oldName)
+			}
+			if p.Location != nil {
+				s.publishLocationChanged(ctx, display)
+			}
+			return display, nil
+		}
+		if errors.IsConflict(err) {
+			return nil, errors.NewError("DISPLAY_EXISTS", fmt.Sprintf("Display already exists with name: %s", display.Name), op, err)
+		}
+		if !errors.IsVersionMismatch(err) {
+			return nil, errors.NewError("SAVE_FAILED", "Failed to save display update", op, err)
+		}
+		if expectedVersion != 0 || attempt == maxPatchAttempts {
+			return nil, errors.NewError("VERSION_CONFLICT", "Display was modified", op, err)
+		}
+	}
+}
+
+// checkNameFree fails with ErrNameTaken when a display other than d has d's
+// name, ignoring case. The database only rejects exact duplicates, so two
+// displays renamed at once to names differing in case can both succeed.
+func (s *service) checkNameFree(ctx context.Context, op string, d *Display) error {
+	existing, err := s.repo.FindByNameFold(ctx, d.Name)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.NewError("LOOKUP_FAILED", "Failed to check existing display", op, err)
+	}
+	if existing.ID == d.ID {
+		return nil
+	}
+	taken := ErrNameTaken{Name: d.Name, Existing: existing}
+	return errors.NewError("DISPLAY_EXISTS", taken.Error(), op, taken)
+}
+
+// publishRenamed publishes a renamed event for a display
+func (s *service) publishRenamed(ctx context.Context, display *Display, oldName string) {
+	s.publish(ctx, Event{
+		Type:      EventRenamed,
+		DisplayID: display.ID,
+		Timestamp: s.clock.Now(),
+		Data: map[string]string{
+			"oldName": oldName,
+			"name":    display.Name,
+			"version": fmt.Sprint(display.Version),
+		},
+	})
+
+	s.logger.Info("display renamed",
+		"displayId", display.ID,
+		"oldName", oldName,
+		"name", display.Name,
+		"version", display.Version,
+	)
+}
+
+// RecordContentChange records that a display switched to the given content
+// URL. Displays report their current URL periodically, so a sequence change to
+// the URL already shown is ignored, as is a resync that pushed it again. A
+// reload with an empty URL reloads the last known content.
+func (s *service) RecordContentChange(ctx context.Context, id uuid.UUID, url string, trigger ContentTrigger) error {
+	const op = "DisplayService.RecordContentChange"
+
+	if url == "" && trigger != TriggerReload {
+		return errors.NewError("INVALID_INPUT", "Content URL cannot be empty", op, errors.ErrInvalidInput)
+	}
+
+	history, err := s.repo.ListContentTransitions(ctx, id, 1)
+	if err != nil {
+		return errors.NewError("LOOKUP_FAILED", "Failed to retrieve content history", op, err)
+	}
+	var last *ContentTransition
+	if len(history) > 0 {
+		last = history[0]
+	}
+
+	switch {
+	case trigger == TriggerReload && url == "":
+		if last == nil {
+			// Nothing known to reload
+			return nil
+		}
+		url = last.ToURL
+	case (trigger == TriggerSequence || trigger == TriggerResync) && last != nil && last.ToURL == url:
+		return nil
+	}
+
+	transition := &ContentTransition{
+		DisplayID: id,
+		Timestamp: s.clock.Now(),
+		ToURL:     url,
+		Trigger:   trigger,
+	}
+	if err := s.repo.AppendContentTransition(ctx, transition, s.historySize); err != nil {
+		if errors.IsNotFound(err) {
+			return errors.NewError("NOT_FOUND", fmt.Sprintf("Display not found: %s", id), op, err)
+		}
+		return errors.NewError("SAVE_FAILED", "Failed to save content transition", op, err)
+	}
+
+	return nil
+}
+
+// ContentHistory retrieves a display's most recent content transitions.
+func (s *service) ContentHistory(ctx context.Context, id uuid.UUID, limit int) ([]*ContentTransition, error) {
+	const op = "DisplayService.ContentHistory"
+
+	if limit <= 0 || limit > s.historySize {
+		limit = s.historySize
+	}
+
+	// Distinguish an unknown display from one with no history yet
+	if _, err := s.repo.FindByID(ctx, id); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, errors.NewError("NOT_FOUND", fmt.Sprintf("Display not found: %s", id), op, err)
+		}
+		return nil, errors.NewError("LOOKUP_FAILED", "Failed to retrieve display", op, err)
+	}
+
+	history, err := s.repo.ListContentTransitions(ctx, id, limit)
+	if err != nil {
+		return nil, errors.NewError("LOOKUP_FAILED", "Failed to retrieve content history", op, err)
+	}
+
+	return history, nil
+}
+
+// CountByState counts the displays matching filter per site and state.
+func (s *service) CountByState(ctx context.Context, filter DisplayFilter) ([]StateCount, error) {
+	const op = "DisplayService.CountByState"
+
+	counts, err := s.repo.CountByState(ctx, filter)
+	if err != nil {
+		return nil, errors.NewError("LOOKUP_FAILED", "Failed to count displays by state", op, err)
+	}
+
+	return counts, nil
+}
+
+// SnapshotStates records per-site state counts and prunes old snapshots.
+func (s *service) SnapshotStates(ctx context.Context, retention time.Duration) error {
+	const op = "DisplayService.SnapshotStates"
+
+	counts, err := s.repo.CountByState(ctx, DisplayFilter{})
+	if err != nil {
+		return errors.NewError("LOOKUP_FAILED", "Failed to count displays by state", op, err)
+	}
+
+	now := s.clock.Now()
+	if snapshots := Snapshots(counts, now); len(snapshots) > 0 {
+		if err := s.repo.SaveStateSnapshots(ctx, snapshots); err != nil {
+			return errors.NewError("SAVE_FAILED", "Failed to save state snapshots", op, err)
+		}
+	}
+
+	if retention > 0 {
+		if err := s.repo.PruneStateSnapshots(ctx, now.Add(-retention)); err != nil {
+			return errors.NewError("PRUNE_FAILED", "Failed to prune state snapshots", op, err)
+		}
+	}
+
+	return nil
+}
+
+// StateHistory retrieves state snapshots taken at or after since.
+func (s *service) StateHistory(ctx context.Context, siteID string, since time.Time) ([]*StateSnapshot, error) {
+	const op = "DisplayService.StateHistory"
+
+	snapshots, err := s.repo.ListStateSnapshots(ctx, siteID, since)
+	if err != nil {
+		return nil, errors.NewError("LOOKUP_FAILED", "Failed to retrieve state history", op, err)
+	}
+
+	return snapshots, nil
+}
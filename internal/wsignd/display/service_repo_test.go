@@ -0,0 +1,625 @@
+package display_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/clock"
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// These tests run the service against the in-memory repository, which
+// enforces optimistic locking the same way the Postgres repository does.
+
+// hookedRepository runs a hook once before the next save is applied
+type hookedRepository struct {
+	display.Repository
+
+	mu         sync.Mutex
+	beforeSave func()
+}
+
+func (r *hookedRepository) Save(ctx context.Context, d *display.Display) error {
+	r.mu.Lock()
+	hook := r.beforeSave
+	r.beforeSave = nil
+	r.mu.Unlock()
+	if hook != nil {
+		hook()
+	}
+	return r.Repository.Save(ctx, d)
+}
+
+// recordingPublisher keeps published events in order
+type recordingPublisher struct {
+	mu     sync.Mutex
+	events []display.Event
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event display.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+func (p *recordingPublisher) types() []display.EventType {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	types := make([]display.EventType, len(p.events))
+	for i, e := range p.events {
+		types[i] = e.Type
+	}
+	return types
+}
+
+// discardLogger drops the service's logs
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// seed stores a display in a fresh repository
+func seed(t *testing.T, modify func(d *display.Display)) (*hookedRepository, *display.Display) {
+	t.Helper()
+	d, err := display.NewDisplay("lobby-north", display.Location{SiteID: "hq", Zone: "lobby"})
+	require.NoError(t, err)
+	modify(d)
+	repo := &hookedRepository{Repository: memory.NewRepository()}
+	require.NoError(t, repo.Save(context.Background(), d))
+	return repo, d
+}
+
+func TestPatchConcurrentLabelEdits(t *testing.T) {
+	ctx := context.Background()
+	withOrientation := func(d *display.Display) { d.Properties["orientation"] = "landscape" }
+
+	t.Run("interleaved edits are merged", func(t *testing.T) {
+		repo, d := seed(t, withOrientation)
+		svc := display.NewService(repo, &recordingPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, discardLogger)
+
+		// Another client adds a label between our read and our save
+		repo.beforeSave = func() {
+			_, err := svc.Patch(ctx, d.ID, display.Patch{SetProperties: map[string]string{"screen-size": "55"}}, 0)
+			require.NoError(t, err)
+		}
+
+		updated, err := svc.Patch(ctx, d.ID, display.Patch{
+			SetProperties:    map[string]string{"owner": "facilities"},
+			RemoveProperties: []string{"orientation"},
+		}, 0)
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]string{"screen-size": "55", "owner": "facilities"}, updated.Properties)
+		assert.Equal(t, 3, updated.Version)
+		stored, _ := repo.FindByID(ctx, d.ID)
+		assert.Equal(t, updated.Properties, stored.Properties)
+	})
+
+	t.Run("parallel edits keep every label", func(t *testing.T) {
+		repo, d := seed(t, withOrientation)
+		svc := display.NewService(repo, &recordingPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, discardLogger)
+
+		keys := []string{"a", "b"}
+		var wg sync.WaitGroup
+		for _, key := range keys {
+			wg.Add(1)
+			go func(key string) {
+				defer wg.Done()
+				_, err := svc.Patch(ctx, d.ID, display.Patch{SetProperties: map[string]string{key: "1"}}, 0)
+				assert.NoError(t, err)
+			}(key)
+		}
+		wg.Wait()
+
+		stored, _ := repo.FindByID(ctx, d.ID)
+		for _, key := range keys {
+			assert.Equal(t, "1", stored.Properties[key])
+		}
+		assert.Equal(t, "landscape", stored.Properties["orientation"])
+	})
+
+	t.Run("stale expected version conflicts", func(t *testing.T) {
+		repo, d := seed(t, withOrientation)
+		svc := display.NewService(repo, &recordingPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, discardLogger)
+
+		_, err := svc.Patch(ctx, d.ID, display.Patch{SetProperties: map[string]string{"a": "1"}}, 1)
+		require.NoError(t, err)
+
+		_, err = svc.Patch(ctx, d.ID, display.Patch{SetProperties: map[string]string{"b": "1"}}, 1)
+		assert.True(t, werrors.IsVersionMismatch(err))
+
+		stored, _ := repo.FindByID(ctx, d.ID)
+		assert.NotContains(t, stored.Properties, "b")
+	})
+
+	t.Run("partial location keeps other fields", func(t *testing.T) {
+		repo, d := seed(t, withOrientation)
+		svc := display.NewService(repo, &recordingPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, discardLogger)
+
+		updated, err := svc.Patch(ctx, d.ID, display.Patch{Location: &display.Location{Zone: "cafeteria"}}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, display.Location{SiteID: "hq", Zone: "cafeteria"}, updated.Location)
+	})
+
+	t.Run("set and remove of one key is rejected", func(t *testing.T) {
+		repo, d := seed(t, withOrientation)
+		svc := display.NewService(repo, &recordingPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, discardLogger)
+
+		_, err := svc.Patch(ctx, d.ID, display.Patch{
+			SetProperties:    map[string]string{"a": "1"},
+			RemoveProperties: []string{"a"},
+		}, 0)
+		assert.True(t, werrors.IsInvalidInput(err))
+	})
+
+	t.Run("missing display is not found", func(t *testing.T) {
+		repo, _ := seed(t, withOrientation)
+		svc := display.NewService(repo, &recordingPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, discardLogger)
+
+		_, err := svc.Patch(ctx, uuid.New(), display.Patch{SetProperties: map[string]string{"a": "1"}}, 0)
+		assert.True(t, werrors.IsNotFound(err))
+	})
+}
+
+func TestPatchRename(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("keeps the ID and publishes both names", func(t *testing.T) {
+		repo, d := seed(t, func(d *display.Display) {})
+		publisher := &recordingPublisher{}
+		svc := display.NewService(repo, publisher, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, discardLogger)
+
+		renamed, err := svc.Patch(ctx, d.ID, display.Patch{Name: "lobby-east"}, 1)
+		require.NoError(t, err)
+		assert.Equal(t, d.ID, renamed.ID)
+		assert.Equal(t, "lobby-east", renamed.Name)
+		assert.Equal(t, 2, renamed.Version)
+
+		require.Equal(t, []display.EventType{display.EventRenamed}, publisher.types())
+		assert.Equal(t, d.ID, publisher.events[0].DisplayID)
+		assert.Equal(t, map[string]string{"oldName": "lobby-north", "name": "lobby-east", "version": "2"}, publisher.events[0].Data)
+
+		found, err := svc.GetByName(ctx, "lobby-east")
+		require.NoError(t, err)
+		assert.Equal(t, d.ID, found.ID)
+		_, err = svc.GetByName(ctx, "lobby-north")
+		assert.True(t, werrors.IsNotFound(err), "got %v", err)
+	})
+
+	t.Run("name in use ignoring case conflicts", func(t *testing.T) {
+		repo, d := seed(t, func(d *display.Display) {})
+		other, err := display.NewDisplay("Lobby-South", display.Location{SiteID: "hq", Zone: "lobby"})
+		require.NoError(t, err)
+		require.NoError(t, repo.Save(ctx, other))
+		publisher := &recordingPublisher{}
+		svc := display.NewService(repo, publisher, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, discardLogger)
+
+		_, err = svc.Patch(ctx, d.ID, display.Patch{Name: "lobby-south"}, 0)
+		assert.True(t, werrors.IsConflict(err), "got %v", err)
+		var taken display.ErrNameTaken
+		require.ErrorAs(t, err, &taken)
+		assert.Equal(t, other.ID, taken.Existing.ID)
+		assert.Empty(t, publisher.types())
+
+		stored, _ := repo.FindByID(ctx, d.ID)
+		assert.Equal(t, "lobby-north", stored.Name)
+		assert.Equal(t, 1, stored.Version)
+	})
+
+	t.Run("changing only the case is allowed", func(t *testing.T) {
+		repo, d := seed(t, func(d *display.Display) {})
+		svc := display.NewService(repo, &recordingPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, discardLogger)
+
+		renamed, err := svc.Patch(ctx, d.ID, display.Patch{Name: "Lobby-North"}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, "Lobby-North", renamed.Name)
+	})
+
+	t.Run("same name changes nothing", func(t *testing.T) {
+		repo, d := seed(t, func(d *display.Display) {})
+		publisher := &recordingPublisher{}
+		svc := display.NewService(repo, publisher, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, discardLogger)
+
+		same, err := svc.Patch(ctx, d.ID, display.Patch{Name: "lobby-north"}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 1, same.Version)
+		assert.Empty(t, publisher.types())
+	})
+
+	t.Run("invalid names are rejected", func(t *testing.T) {
+		repo, d := seed(t, func(d *display.Display) {})
+		svc := display.NewService(repo, &recordingPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, discardLogger)
+
+		for _, name := range []string{"lobby north", "-lobby", "lobby/north", strings.Repeat("a", 129), uuid.NewString()} {
+			_, err := svc.Patch(ctx, d.ID, display.Patch{Name: name}, 0)
+			assert.True(t, werrors.IsInvalidInput(err), "%q: got %v", name, err)
+		}
+	})
+}
+
+func TestReapOfflineGracePeriod(t *testing.T) {
+	ctx := context.Background()
+	liveness := display.Liveness{OfflineAfter: time.Minute, GracePeriod: 30 * time.Second}
+	now := clock.NewFake(time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC))
+
+	repo, d := seed(t, func(d *display.Display) { d.State = display.StateActive })
+	publisher := &recordingPublisher{}
+	svc := display.NewService(repo, publisher, 10, liveness, display.ApprovalPolicy{}, display.Capacity{}, discardLogger,
+		display.WithClock(now))
+	state := func() display.State {
+		stored, err := repo.FindByID(ctx, d.ID)
+		require.NoError(t, err)
+		return stored.State
+	}
+	require.NoError(t, svc.UpdateLastSeen(ctx, d.ID))
+
+	// Past the threshold but inside the grace period nothing changes, up
+	// to the last moment of it
+	now.Advance(80 * time.Second)
+	require.NoError(t, svc.ReapOffline(ctx))
+	assert.Equal(t, display.StateActive, state())
+	now.Advance(10 * time.Second)
+	require.NoError(t, svc.ReapOffline(ctx))
+	assert.Equal(t, display.StateActive, state())
+
+	// The display reconnects: the flap is absorbed without events
+	require.NoError(t, svc.UpdateLastSeen(ctx, d.ID))
+	require.NoError(t, svc.ReapOffline(ctx))
+	assert.Equal(t, display.StateActive, state())
+	assert.Empty(t, publisher.types())
+
+	// Silent beyond the grace period it goes offline once
+	now.Advance(90*time.Second + time.Nanosecond)
+	require.NoError(t, svc.ReapOffline(ctx))
+	require.NoError(t, svc.ReapOffline(ctx))
+	assert.Equal(t, display.StateOffline, state())
+	assert.Equal(t, []display.EventType{display.EventOffline}, publisher.types())
+
+	// Checking in again brings it back with a single event
+	require.NoError(t, svc.UpdateLastSeen(ctx, d.ID))
+	require.NoError(t, svc.UpdateLastSeen(ctx, d.ID))
+	assert.Equal(t, display.StateActive, state())
+	assert.Equal(t, []display.EventType{display.EventOffline, display.EventOnline}, publisher.types())
+}
+
+func TestReapOfflineBatches(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewRepository()
+	publisher := &recordingPublisher{}
+	svc := display.NewService(repo, publisher, 10, display.Liveness{OfflineAfter: time.Minute}, display.ApprovalPolicy{}, display.Capacity{}, discardLogger)
+
+	// More silent displays than one batch holds, plus one still checking in
+	const silent = 1234
+	for i := 0; i <= silent; i++ {
+		d, err := display.NewDisplay(fmt.Sprintf("display-%d", i), display.Location{SiteID: "hq", Zone: "lobby"})
+		require.NoError(t, err)
+		d.State = display.StateActive
+		d.LastSeen = time.Now().Add(-time.Hour)
+		if i == silent {
+			d.LastSeen = time.Now()
+		}
+		require.NoError(t, repo.Save(ctx, d))
+	}
+
+	require.NoError(t, svc.ReapOffline(ctx))
+
+	counts, err := svc.CountByState(ctx, display.DisplayFilter{SiteID: "hq"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []display.StateCount{
+		{SiteID: "hq", State: display.StateActive, Count: 1},
+		{SiteID: "hq", State: display.StateOffline, Count: silent},
+	}, counts)
+	assert.Len(t, publisher.types(), silent)
+}
+
+func TestActivateWithApproval(t *testing.T) {
+	ctx := context.Background()
+	policy := display.ApprovalPolicy{Sites: []string{"hq"}}
+
+	t.Run("covered sites wait for an operator", func(t *testing.T) {
+		repo, d := seed(t, func(d *display.Display) {})
+		publisher := &recordingPublisher{}
+		svc := display.NewService(repo, publisher, 10, display.Liveness{}, policy, display.Capacity{}, discardLogger)
+
+		require.NoError(t, svc.Activate(ctx, d.ID))
+		stored, err := repo.FindByID(ctx, d.ID)
+		require.NoError(t, err)
+		assert.Equal(t, display.StatePendingApproval, stored.State)
+
+		// Activating again cannot bypass the approval
+		assert.Error(t, svc.Activate(ctx, d.ID))
+
+		require.NoError(t, svc.Approve(ctx, d.ID))
+		stored, err = repo.FindByID(ctx, d.ID)
+		require.NoError(t, err)
+		assert.Equal(t, display.StateActive, stored.State)
+		assert.Equal(t, []display.EventType{display.EventApprovalRequested, display.EventApproved}, publisher.types())
+
+		err = svc.Approve(ctx, d.ID)
+		assert.True(t, werrors.IsConflict(err), "got %v", err)
+	})
+
+	t.Run("other sites activate directly", func(t *testing.T) {
+		repo, d := seed(t, func(d *display.Display) { d.Location.SiteID = "annex" })
+		svc := display.NewService(repo, &recordingPublisher{}, 10, display.Liveness{}, policy, display.Capacity{}, discardLogger)
+
+		require.NoError(t, svc.Activate(ctx, d.ID))
+		stored, err := repo.FindByID(ctx, d.ID)
+		require.NoError(t, err)
+		assert.Equal(t, display.StateActive, stored.State)
+	})
+}
+
+func TestRegisterCapacity(t *testing.T) {
+	ctx := context.Background()
+	capacity := display.Capacity{
+		MaxPerSite: 2,
+		Sites:      map[string]int{"annex": 3},
+		Zones:      map[string]int{display.ZoneKey("annex", "lobby"): 1},
+	}
+	svc := display.NewService(memory.NewRepository(), &recordingPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, capacity, discardLogger)
+	register := func(name, site, zone string) error {
+		_, err := svc.Register(ctx, name, display.Location{SiteID: site, Zone: zone}, nil)
+		return err
+	}
+	assertLimited := func(t *testing.T, err error) {
+		t.Helper()
+		var domainErr *werrors.Error
+		require.ErrorAs(t, err, &domainErr)
+		assert.Equal(t, "LIMIT_EXCEEDED", domainErr.Code)
+		assert.True(t, werrors.IsLimitExceeded(err))
+	}
+
+	t.Run("the default cap is reached exactly", func(t *testing.T) {
+		require.NoError(t, register("hq-1", "hq", "lobby"))
+		require.NoError(t, register("hq-2", "hq", "cafeteria"))
+		assertLimited(t, register("hq-3", "hq", "lobby"))
+
+		_, err := svc.GetByName(ctx, "hq-3")
+		assert.True(t, werrors.IsNotFound(err), "got %v", err)
+	})
+
+	t.Run("overrides replace the default", func(t *testing.T) {
+		require.NoError(t, register("annex-1", "annex", "lobby"))
+		assertLimited(t, register("annex-2", "annex", "lobby"))
+		require.NoError(t, register("annex-2", "annex", "atrium"))
+		require.NoError(t, register("annex-3", "annex", "atrium"))
+		assertLimited(t, register("annex-4", "annex", "atrium"))
+	})
+}
+
+func TestPropertyLimits(t *testing.T) {
+	ctx := context.Background()
+	limits := config.PropertyLimits{MaxCount: 2, MaxKeyLength: 8, MaxValueLength: 16}
+	svc := display.NewService(memory.NewRepository(), &recordingPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, discardLogger,
+		display.WithPropertyLimits(limits))
+	assertInvalid := func(t *testing.T, err error, field string) {
+		t.Helper()
+		var verr *werrors.ValidationError
+		require.ErrorAs(t, err, &verr)
+		assert.True(t, werrors.IsInvalidInput(err))
+		require.Len(t, verr.Fields, 1)
+		assert.Equal(t, field, verr.Fields[0].Field)
+	}
+
+	_, err := svc.Register(ctx, "lobby-1", display.Location{SiteID: "hq"}, map[string]string{"owner": strings.Repeat("v", 17)})
+	assertInvalid(t, err, "properties[owner]")
+
+	d, err := svc.Register(ctx, "lobby-1", display.Location{SiteID: "hq"}, map[string]string{"owner": strings.Repeat("v", 16)})
+	require.NoError(t, err, "a value exactly at the limit is accepted")
+
+	err = svc.SetProperty(ctx, d.ID, strings.Repeat("k", 9), "v")
+	assertInvalid(t, err, "properties["+strings.Repeat("k", 9)+"]")
+	require.NoError(t, svc.SetProperty(ctx, d.ID, strings.Repeat("k", 8), "v"))
+
+	_, err = svc.Patch(ctx, d.ID, display.Patch{SetProperties: map[string]string{"floor": "2"}}, 0)
+	assertInvalid(t, err, "properties")
+
+	stored, err := svc.Get(ctx, d.ID)
+	require.NoError(t, err)
+	assert.Len(t, stored.Properties, 2, "rejected changes are not saved")
+
+	// Shrinking the limits leaves existing displays in breach, but they can
+	// still be brought back within them
+	shrunk := display.NewService(memoryWith(t, stored), &recordingPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, discardLogger,
+		display.WithPropertyLimits(config.PropertyLimits{MaxCount: 1}))
+	_, err = shrunk.Patch(ctx, d.ID, display.Patch{Name: "lobby-2"}, 0)
+	require.NoError(t, err, "changes that set no properties are not held to the limits")
+	patched, err := shrunk.Patch(ctx, d.ID, display.Patch{
+		SetProperties:    map[string]string{"floor": "2"},
+		RemoveProperties: []string{"owner", strings.Repeat("k", 8)},
+	}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"floor": "2"}, patched.Properties)
+}
+
+func TestTimezoneProperty(t *testing.T) {
+	ctx := context.Background()
+	svc := display.NewService(memory.NewRepository(), &recordingPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, discardLogger)
+	field := "properties[" + display.TimezoneProperty + "]"
+	assertInvalid := func(t *testing.T, err error) {
+		t.Helper()
+		var verr *werrors.ValidationError
+		require.ErrorAs(t, err, &verr)
+		require.Len(t, verr.Fields, 1)
+		assert.Equal(t, field, verr.Fields[0].Field)
+	}
+
+	_, err := svc.Register(ctx, "lobby-1", display.Location{SiteID: "hq"}, map[string]string{display.TimezoneProperty: "Mars/Olympus"})
+	assertInvalid(t, err)
+	d, err := svc.Register(ctx, "lobby-1", display.Location{SiteID: "hq"}, map[string]string{display.TimezoneProperty: "Europe/Paris"})
+	require.NoError(t, err)
+
+	for _, name := range []string{"", "Local", "local", "Europe/Pariss"} {
+		assertInvalid(t, svc.SetProperty(ctx, d.ID, display.TimezoneProperty, name))
+	}
+	require.NoError(t, svc.SetProperty(ctx, d.ID, display.TimezoneProperty, "America/New_York"))
+
+	_, err = svc.Patch(ctx, d.ID, display.Patch{SetProperties: map[string]string{display.TimezoneProperty: "EST5EDTT"}}, 0)
+	assertInvalid(t, err)
+	patched, err := svc.Patch(ctx, d.ID, display.Patch{RemoveProperties: []string{display.TimezoneProperty}}, 0)
+	require.NoError(t, err, "the zone can be removed")
+	assert.NotContains(t, patched.Properties, display.TimezoneProperty)
+}
+
+func TestTimezonesFor(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	require.NoError(t, err)
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	zones := display.Timezones{Default: tokyo, Sites: map[string]*time.Location{"paris": paris}}
+
+	for _, tt := range []struct {
+		name       string
+		d          *display.Display
+		zones      display.Timezones
+		wantZone   string
+		wantSource display.TimezoneSource
+	}{
+		{"own zone", &display.Display{Location: display.Location{SiteID: "paris"}, Properties: map[string]string{display.TimezoneProperty: "America/New_York"}}, zones, "America/New_York", display.TimezoneFromDisplay},
+		{"unknown own zone", &display.Display{Location: display.Location{SiteID: "paris"}, Properties: map[string]string{display.TimezoneProperty: "Mars/Olympus"}}, zones, "Europe/Paris", display.TimezoneFromSite},
+		{"site zone", &display.Display{Location: display.Location{SiteID: "paris"}}, zones, "Europe/Paris", display.TimezoneFromSite},
+		{"default zone", &display.Display{Location: display.Location{SiteID: "remote"}}, zones, "Asia/Tokyo", display.TimezoneDefault},
+		{"no zones configured", &display.Display{Location: display.Location{SiteID: "remote"}}, display.Timezones{}, "UTC", display.TimezoneDefault},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, source := tt.zones.For(tt.d)
+			assert.Equal(t, tt.wantZone, loc.String())
+			assert.Equal(t, tt.wantSource, source)
+		})
+	}
+}
+
+// memoryWith returns an in-memory repository holding d
+func memoryWith(t *testing.T, d *display.Display) display.Repository {
+	t.Helper()
+	repo := memory.NewRepository()
+	require.NoError(t, repo.Save(context.Background(), d))
+	return repo
+}
+
+func TestSnapshotStates(t *testing.T) {
+	ctx := context.Background()
+
+	repo, _ := seed(t, func(d *display.Display) { d.State = display.StateActive })
+	svc := display.NewService(repo, &recordingPublisher{}, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, discardLogger)
+
+	// A snapshot from long ago falls outside the retention
+	old := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, repo.SaveStateSnapshots(ctx, []*display.StateSnapshot{
+		{TakenAt: old, SiteID: "hq", Counts: map[display.State]int{display.StateOffline: 1}},
+	}))
+
+	require.NoError(t, svc.SnapshotStates(ctx, 24*time.Hour))
+
+	history, err := svc.StateHistory(ctx, "", old.Add(-time.Hour))
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, "hq", history[0].SiteID)
+	assert.Equal(t, map[display.State]int{display.StateActive: 1}, history[0].Counts)
+}
+
+func TestServiceLogLevels(t *testing.T) {
+	ctx := context.Background()
+	liveness := display.Liveness{OfflineAfter: time.Minute}
+
+	repo, d := seed(t, func(d *display.Display) { d.State = display.StateActive })
+	// Logged the way the server logs by default: info level, no sampling
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	svc := display.NewService(repo, &recordingPublisher{}, 10, liveness, display.ApprovalPolicy{}, display.Capacity{}, logger)
+
+	lines := func() []string {
+		defer logs.Reset()
+		return strings.FieldsFunc(logs.String(), func(r rune) bool { return r == '\n' })
+	}
+
+	// A heartbeat from a display that is already online is routine
+	require.NoError(t, svc.UpdateLastSeen(ctx, d.ID))
+	assert.Empty(t, lines())
+
+	// Coming back online is a state change and is logged once
+	stored, err := repo.FindByID(ctx, d.ID)
+	require.NoError(t, err)
+	require.True(t, stored.MarkOffline())
+	require.NoError(t, repo.Save(ctx, stored))
+
+	require.NoError(t, svc.UpdateLastSeen(ctx, d.ID))
+	got := lines()
+	require.Len(t, got, 1)
+	assert.Contains(t, got[0], "level=INFO")
+	assert.Contains(t, got[0], `msg="display state changed"`)
+	assert.Contains(t, got[0], "event=ONLINE")
+}
+
+func TestPruneOffline(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewRepository()
+	publisher := &recordingPublisher{}
+	svc := display.NewService(repo, publisher, 10, display.Liveness{}, display.ApprovalPolicy{}, display.Capacity{}, discardLogger)
+
+	save := func(name, site string, state display.State, ago time.Duration) {
+		d, err := display.NewDisplay(name, display.Location{SiteID: site, Zone: "lobby"})
+		require.NoError(t, err)
+		d.State = state
+		d.LastSeen = time.Now().Add(-ago)
+		require.NoError(t, repo.Save(ctx, d))
+	}
+	month := 30 * 24 * time.Hour
+	save("hq-gone", "hq", display.StateOffline, 2*month)
+	save("hq-older", "hq", display.StateOffline, 3*month)
+	save("hq-recent", "hq", display.StateOffline, 24*time.Hour)
+	// Active displays are kept however stale their last check-in looks
+	save("hq-active", "hq", display.StateActive, 2*month)
+	save("hq-disabled", "hq", display.StateDisabled, 2*month)
+	save("annex-gone", "annex", display.StateOffline, 2*month)
+
+	filter := display.PruneFilter{OfflineFor: month, SiteID: "hq"}
+
+	t.Run("dry run only lists", func(t *testing.T) {
+		result, err := svc.PruneOffline(ctx, filter, true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"hq-older", "hq-gone"}, displayNames(result.Matched))
+		assert.Empty(t, result.Deleted)
+
+		all, err := repo.List(ctx, display.DisplayFilter{})
+		require.NoError(t, err)
+		assert.Len(t, all, 6)
+	})
+
+	t.Run("thresholds below the minimum are rejected", func(t *testing.T) {
+		_, err := svc.PruneOffline(ctx, display.PruneFilter{OfflineFor: time.Hour}, true)
+		assert.True(t, werrors.IsInvalidInput(err), "got %v", err)
+	})
+
+	t.Run("offline displays are deleted", func(t *testing.T) {
+		result, err := svc.PruneOffline(ctx, filter, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"hq-older", "hq-gone"}, displayNames(result.Deleted))
+		assert.Equal(t, []display.EventType{display.EventDeleted, display.EventDeleted}, publisher.types())
+
+		left, err := repo.List(ctx, display.DisplayFilter{})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"hq-recent", "hq-active", "hq-disabled", "annex-gone"}, displayNames(left))
+	})
+}
+
+func displayNames(displays []*display.Display) []string {
+	names := make([]string, len(displays))
+	for i, d := range displays {
+		names[i] = d.Name
+	}
+	return names
+}
@@ -0,0 +1,200 @@
+package display
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// discardLogger drops the service's logs
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+type mockRepository struct {
+	mock.Mock
+}
+
+func (m *mockRepository) Save(ctx context.Context, d *Display) error {
+	args := m.Called(ctx, d)
+	return args.Error(0)
+}
+
+func (m *mockRepository) Create(ctx context.Context, d *Display, limits Limits) error {
+	args := m.Called(ctx, d, limits)
+	return args.Error(0)
+}
+
+func (m *mockRepository) FindByID(ctx context.Context, id uuid.UUID) (*Display, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Display), args.Error(1)
+}
+
+func (m *mockRepository) FindByName(ctx context.Context, name string) (*Display, error) {
+	args := m.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Display), args.Error(1)
+}
+
+func (m *mockRepository) FindByNameFold(ctx context.Context, name string) (*Display, error) {
+	args := m.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Display), args.Error(1)
+}
+
+func (m *mockRepository) List(ctx context.Context, filter DisplayFilter) ([]*Display, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*Display), args.Error(1)
+}
+
+func (m *mockRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockRepository) DeleteOffline(ctx context.Context, id uuid.UUID, seenBefore time.Time) (bool, error) {
+	args := m.Called(ctx, id, seenBefore)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockRepository) AppendContentTransition(ctx context.Context, t *ContentTransition, keep int) error {
+	args := m.Called(ctx, t, keep)
+	return args.Error(0)
+}
+
+func (m *mockRepository) ListContentTransitions(ctx context.Context, displayID uuid.UUID, limit int) ([]*ContentTransition, error) {
+	args := m.Called(ctx, displayID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*ContentTransition), args.Error(1)
+}
+
+func (m *mockRepository) LatestContentTransitions(ctx context.Context, displayIDs []uuid.UUID) (map[uuid.UUID]*ContentTransition, error) {
+	args := m.Called(ctx, displayIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[uuid.UUID]*ContentTransition), args.Error(1)
+}
+
+func (m *mockRepository) FindStale(ctx context.Context, olderThan time.Time, limit int) ([]*Display, error) {
+	args := m.Called(ctx, olderThan, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Display), args.Error(1)
+}
+
+func (m *mockRepository) CountByState(ctx context.Context, filter DisplayFilter) ([]StateCount, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]StateCount), args.Error(1)
+}
+
+func (m *mockRepository) SaveStateSnapshots(ctx context.Context, snapshots []*StateSnapshot) error {
+	args := m.Called(ctx, snapshots)
+	return args.Error(0)
+}
+
+func (m *mockRepository) ListStateSnapshots(ctx context.Context, siteID string, since time.Time) ([]*StateSnapshot, error) {
+	args := m.Called(ctx, siteID, since)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*StateSnapshot), args.Error(1)
+}
+
+func (m *mockRepository) PruneStateSnapshots(ctx context.Context, before time.Time) error {
+	args := m.Called(ctx, before)
+	return args.Error(0)
+}
+
+type mockPublisher struct {
+	mock.Mock
+}
+
+func (m *mockPublisher) Publish(ctx context.Context, event Event) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func TestRecordContentChange(t *testing.T) {
+	ctx := context.Background()
+	id := uuid.New()
+	last := []*ContentTransition{{DisplayID: id, ToURL: "https://example.com/a", Trigger: TriggerSequence}}
+
+	t.Run("repeated status report is ignored", func(t *testing.T) {
+		repo := &mockRepository{}
+		repo.On("ListContentTransitions", ctx, id, 1).Return(last, nil)
+
+		svc := NewService(repo, &mockPublisher{}, 10, Liveness{}, ApprovalPolicy{}, Capacity{}, discardLogger)
+		require.NoError(t, svc.RecordContentChange(ctx, id, "https://example.com/a", TriggerSequence))
+		repo.AssertNotCalled(t, "AppendContentTransition", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("new url is appended with configured cap", func(t *testing.T) {
+		repo := &mockRepository{}
+		repo.On("ListContentTransitions", ctx, id, 1).Return(last, nil)
+		repo.On("AppendContentTransition", ctx, mock.MatchedBy(func(tr *ContentTransition) bool {
+			return tr.ToURL == "https://example.com/b" && tr.Trigger == TriggerSequence && !tr.Timestamp.IsZero()
+		}), 10).Return(nil)
+
+		svc := NewService(repo, &mockPublisher{}, 10, Liveness{}, ApprovalPolicy{}, Capacity{}, discardLogger)
+		require.NoError(t, svc.RecordContentChange(ctx, id, "https://example.com/b", TriggerSequence))
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("reload repeats last url", func(t *testing.T) {
+		repo := &mockRepository{}
+		repo.On("ListContentTransitions", ctx, id, 1).Return(last, nil)
+		repo.On("AppendContentTransition", ctx, mock.MatchedBy(func(tr *ContentTransition) bool {
+			return tr.ToURL == "https://example.com/a" && tr.Trigger == TriggerReload
+		}), MaxContentHistorySize).Return(nil)
+
+		// Oversized history configuration is capped
+		svc := NewService(repo, &mockPublisher{}, MaxContentHistorySize*10, Liveness{}, ApprovalPolicy{}, Capacity{}, discardLogger)
+		require.NoError(t, svc.RecordContentChange(ctx, id, "", TriggerReload))
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("empty url is rejected", func(t *testing.T) {
+		svc := NewService(&mockRepository{}, &mockPublisher{}, 10, Liveness{}, ApprovalPolicy{}, Capacity{}, discardLogger)
+		err := svc.RecordContentChange(ctx, id, "", TriggerSequence)
+		assert.True(t, werrors.IsInvalidInput(err))
+	})
+}
+
+func TestContentHistoryLimit(t *testing.T) {
+	ctx := context.Background()
+	id := uuid.New()
+
+	repo := &mockRepository{}
+	repo.On("FindByID", ctx, id).Return(&Display{ID: id}, nil)
+	repo.On("ListContentTransitions", ctx, id, 5).Return([]*ContentTransition{}, nil)
+
+	svc := NewService(repo, &mockPublisher{}, 5, Liveness{}, ApprovalPolicy{}, Capacity{}, discardLogger)
+	_, err := svc.ContentHistory(ctx, id, 50)
+	require.NoError(t, err)
+	repo.AssertExpectations(t)
+
+	repo.On("FindByID", ctx, mock.Anything).Return(nil, werrors.NewError("NOT_FOUND", "missing", "test", werrors.ErrNotFound))
+	_, err = svc.ContentHistory(ctx, uuid.New(), 1)
+	assert.True(t, werrors.IsNotFound(err))
+}
@@ -0,0 +1,97 @@
+package display
+
+import (
+	"fmt"
+	"time"
+)
+
+// Orientation is the rotation a display applies to what it shows
+type Orientation string
+
+const (
+	// OrientationLandscape shows content unrotated
+	OrientationLandscape Orientation = "landscape"
+	// OrientationPortrait rotates content a quarter turn clockwise
+	OrientationPortrait Orientation = "portrait"
+	// OrientationLandscapeFlipped rotates content half a turn
+	OrientationLandscapeFlipped Orientation = "landscape-flipped"
+	// OrientationPortraitFlipped rotates content a quarter turn
+	// counter-clockwise
+	OrientationPortraitFlipped Orientation = "portrait-flipped"
+)
+
+// screenTimeLayout is the layout of the times in a screen schedule
+const screenTimeLayout = "15:04"
+
+// Settings are device settings the display applies itself. Unset fields
+// leave the device's own setting alone.
+type Settings struct {
+	// Orientation rotates what the display shows
+	Orientation Orientation
+	// Brightness is the backlight level, 0 to 100
+	Brightness *int
+	// Volume is the audio level, 0 to 100
+	Volume *int
+	// ScreenSchedule lists the times of day the screen is on, in the
+	// display's local time. An empty schedule keeps the screen on.
+	ScreenSchedule []ScreenWindow
+}
+
+// ScreenWindow is a daily period during which the screen is on. A window
+// whose end is earlier than its start runs past midnight.
+type ScreenWindow struct {
+	// Start is when the screen turns on, as HH:MM
+	Start string
+	// End is when the screen turns off, as HH:MM
+	End string
+}
+
+// Empty reports whether the settings leave every device setting alone
+func (s Settings) Empty() bool {
+	return s.Orientation == "" && s.Brightness == nil && s.Volume == nil && len(s.ScreenSchedule) == 0
+}
+
+// Validate checks that every set field is within its range
+func (s Settings) Validate() error {
+	switch s.Orientation {
+	case "", OrientationLandscape, OrientationPortrait, OrientationLandscapeFlipped, OrientationPortraitFlipped:
+	default:
+		return fmt.Errorf("orientation %q must be one of %s, %s, %s or %s", s.Orientation,
+			OrientationLandscape, OrientationPortrait, OrientationLandscapeFlipped, OrientationPortraitFlipped)
+	}
+	if s.Brightness != nil && (*s.Brightness < 0 || *s.Brightness > 100) {
+		return fmt.Errorf("brightness %d must be between 0 and 100", *s.Brightness)
+	}
+	if s.Volume != nil && (*s.Volume < 0 || *s.Volume > 100) {
+		return fmt.Errorf("volume %d must be between 0 and 100", *s.Volume)
+	}
+	for i, w := range s.ScreenSchedule {
+		start, err := time.Parse(screenTimeLayout, w.Start)
+		if err != nil {
+			return fmt.Errorf("screen schedule window %d: start %q must be a time as HH:MM", i+1, w.Start)
+		}
+		end, err := time.Parse(screenTimeLayout, w.End)
+		if err != nil {
+			return fmt.Errorf("screen schedule window %d: end %q must be a time as HH:MM", i+1, w.End)
+		}
+		if start.Equal(end) {
+			return fmt.Errorf("screen schedule window %d starts and ends at %s", i+1, w.Start)
+		}
+	}
+	return nil
+}
+
+// Clone returns a copy of s that shares no mutable state with it
+func (s Settings) Clone() Settings {
+	c := s
+	if s.Brightness != nil {
+		v := *s.Brightness
+		c.Brightness = &v
+	}
+	if s.Volume != nil {
+		v := *s.Volume
+		c.Volume = &v
+	}
+	c.ScreenSchedule = append([]ScreenWindow(nil), s.ScreenSchedule...)
+	return c
+}
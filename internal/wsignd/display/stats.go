@@ -0,0 +1,51 @@
+package display
+
+import (
+	"sort"
+	"time"
+)
+
+// StateCount is the number of displays at a site in one state
+type StateCount struct {
+	// SiteID identifies the site
+	SiteID string
+	// State is the display state counted
+	State State
+	// Count is how many of the site's displays are in State
+	Count int
+}
+
+// StateSnapshot records how many displays at a site were in each state at
+// one point in time. States without displays are left out of Counts.
+type StateSnapshot struct {
+	// TakenAt is when the counts were taken
+	TakenAt time.Time
+	// SiteID identifies the site
+	SiteID string
+	// Counts maps each state to the number of displays in it
+	Counts map[State]int
+}
+
+// Snapshots folds per-state counts into one snapshot per site, ordered by
+// site, all taken at the given time
+func Snapshots(counts []StateCount, at time.Time) []*StateSnapshot {
+	bySite := make(map[string]*StateSnapshot)
+	var snapshots []*StateSnapshot
+	for _, c := range counts {
+		if c.Count == 0 {
+			continue
+		}
+		s, ok := bySite[c.SiteID]
+		if !ok {
+			s = &StateSnapshot{TakenAt: at, SiteID: c.SiteID, Counts: make(map[State]int)}
+			bySite[c.SiteID] = s
+			snapshots = append(snapshots, s)
+		}
+		s.Counts[c.State] += c.Count
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].SiteID < snapshots[j].SiteID
+	})
+	return snapshots
+}
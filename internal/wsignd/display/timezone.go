@@ -0,0 +1,54 @@
+package display
+
+import (
+	"time"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/timezone"
+)
+
+// TimezoneProperty is the display property naming the IANA time zone a
+// display is in, for displays in a different zone from the rest of their
+// site
+const TimezoneProperty = "sys.timezone"
+
+// TimezoneSource tells where the time zone given for a display came from
+type TimezoneSource string
+
+const (
+	// TimezoneFromRequest indicates the request named the zone
+	TimezoneFromRequest TimezoneSource = "request"
+	// TimezoneFromDisplay indicates the display's own TimezoneProperty
+	TimezoneFromDisplay TimezoneSource = "display"
+	// TimezoneFromSite indicates the zone configured for the display's site
+	TimezoneFromSite TimezoneSource = "site"
+	// TimezoneDefault indicates neither the display nor its site names a
+	// zone, so the deployment's default zone, UTC unless configured, was
+	// used
+	TimezoneDefault TimezoneSource = "default"
+)
+
+// Timezones holds the time zones of sites
+type Timezones struct {
+	// Default applies to sites without a zone of their own; nil means UTC
+	Default *time.Location
+	// Sites maps site IDs to their zone
+	Sites map[string]*time.Location
+}
+
+// For returns the time zone d is in and where it came from: the display's
+// TimezoneProperty, else its site's zone, else the default. A property
+// naming an unknown zone, stored before zones were checked, is ignored.
+func (t Timezones) For(d *Display) (*time.Location, TimezoneSource) {
+	if name := d.Properties[TimezoneProperty]; name != "" {
+		if loc, err := timezone.Load(name); err == nil {
+			return loc, TimezoneFromDisplay
+		}
+	}
+	if loc, ok := t.Sites[d.Location.SiteID]; ok {
+		return loc, TimezoneFromSite
+	}
+	if t.Default != nil {
+		return t.Default, TimezoneDefault
+	}
+	return time.UTC, TimezoneDefault
+}
@@ -0,0 +1,108 @@
+// Package drain tracks the HTTP requests a server is handling so that
+// shutdown can wait for them, report how many it waited for, and count the
+// ones it had to abandon.
+package drain
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// pollInterval is how often Wait checks the in-flight count
+const pollInterval = 10 * time.Millisecond
+
+// Stats is a snapshot of the tracker's counters
+type Stats struct {
+	// InFlight is the number of requests being handled
+	InFlight int64 `json:"inFlight"`
+	// Draining is true once shutdown has begun
+	Draining bool `json:"draining"`
+	// Ungraceful counts requests still running when shutdown gave up
+	// waiting for them
+	Ungraceful uint64 `json:"ungracefulTerminations"`
+}
+
+// Tracker counts in-flight HTTP requests. WebSocket upgrades are not
+// counted; their handlers run for the lifetime of the connection and are
+// drained through the hub instead.
+type Tracker struct {
+	inFlight   atomic.Int64
+	draining   atomic.Bool
+	ungraceful atomic.Uint64
+	logger     *slog.Logger
+}
+
+// NewTracker creates a tracker with nothing in flight
+func NewTracker(logger *slog.Logger) *Tracker {
+	return &Tracker{logger: logger}
+}
+
+// Middleware counts each request for as long as its handler runs
+func (t *Tracker) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		t.inFlight.Add(1)
+		defer t.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// InFlight returns the number of requests being handled
+func (t *Tracker) InFlight() int64 {
+	return t.inFlight.Load()
+}
+
+// Begin marks the start of shutdown and returns the number of requests in
+// flight at that moment
+func (t *Tracker) Begin() int64 {
+	t.draining.Store(true)
+	return t.inFlight.Load()
+}
+
+// Wait blocks until no request is in flight or ctx is done. When ctx ends
+// first, the requests still running are counted as ungraceful terminations
+// and their number is returned.
+func (t *Tracker) Wait(ctx context.Context) int64 {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		n := t.inFlight.Load()
+		if n == 0 {
+			return 0
+		}
+		select {
+		case <-ctx.Done():
+			t.ungraceful.Add(uint64(n))
+			return n
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stats returns the current counters
+func (t *Tracker) Stats() Stats {
+	return Stats{
+		InFlight:   t.inFlight.Load(),
+		Draining:   t.draining.Load(),
+		Ungraceful: t.ungraceful.Load(),
+	}
+}
+
+// StatsHandler serves the counters as JSON for metrics scrapers
+func (t *Tracker) StatsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(t.Stats()); err != nil {
+			t.logger.Error("failed to write drain stats", "error", err)
+		}
+	}
+}
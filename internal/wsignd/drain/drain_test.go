@@ -0,0 +1,72 @@
+package drain
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// Handlers block until released, so the test controls what is in flight
+	release := make(chan struct{})
+	entered := make(chan struct{}, 4)
+	handler := tracker.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}))
+	serve := func(upgrade string) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if upgrade != "" {
+			req.Header.Set("Upgrade", upgrade)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	done := make(chan struct{})
+	for _, upgrade := range []string{"", "", "websocket"} {
+		go func(upgrade string) {
+			serve(upgrade)
+			done <- struct{}{}
+		}(upgrade)
+		<-entered
+	}
+	assert.Equal(t, int64(2), tracker.InFlight(), "websocket upgrades are not counted")
+	assert.Equal(t, int64(2), tracker.Begin())
+
+	t.Run("wait gives up and counts what is left", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		assert.Equal(t, int64(2), tracker.Wait(ctx))
+		assert.Equal(t, Stats{InFlight: 2, Draining: true, Ungraceful: 2}, tracker.Stats())
+	})
+
+	t.Run("wait returns once requests finish", func(t *testing.T) {
+		close(release)
+		for i := 0; i < 3; i++ {
+			<-done
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.Zero(t, tracker.Wait(ctx))
+		assert.Equal(t, uint64(2), tracker.Stats().Ungraceful)
+	})
+
+	t.Run("stats handler", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		tracker.StatsHandler()(w, httptest.NewRequest(http.MethodGet, "/debug/requests", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var stats Stats
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&stats))
+		assert.Equal(t, Stats{Draining: true, Ungraceful: 2}, stats)
+	})
+}
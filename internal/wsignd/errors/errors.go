@@ -25,6 +25,12 @@ var (
 
 	// ErrVersionMismatch indicates optimistic concurrency failure
 	ErrVersionMismatch = errors.New("version mismatch")
+
+	// ErrLimitExceeded indicates a configured cap on resources was reached
+	ErrLimitExceeded = errors.New("limit exceeded")
+
+	// ErrTimeout indicates an operation was abandoned at its deadline
+	ErrTimeout = errors.New("deadline exceeded")
 )
 
 // Error represents a domain error with additional context
@@ -91,3 +97,13 @@ func IsForbidden(err error) bool {
 func IsVersionMismatch(err error) bool {
 	return errors.Is(err, ErrVersionMismatch)
 }
+
+// IsLimitExceeded returns true if err represents a reached resource cap
+func IsLimitExceeded(err error) bool {
+	return errors.Is(err, ErrLimitExceeded)
+}
+
+// IsTimeout returns true if err represents an operation that ran out of time
+func IsTimeout(err error) bool {
+	return errors.Is(err, ErrTimeout)
+}
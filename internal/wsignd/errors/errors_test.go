@@ -0,0 +1,83 @@
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// missingDisplay mimics a domain error type that reports itself as
+// ErrNotFound, like display.ErrNotFound
+type missingDisplay struct{ name string }
+
+func (e missingDisplay) Error() string        { return "display not found: " + e.name }
+func (e missingDisplay) Is(target error) bool { return target == ErrNotFound }
+
+func TestError_ChainTraversal(t *testing.T) {
+	cause := missingDisplay{name: "lobby"}
+	inner := NewError("NOT_FOUND", "Display not found: lobby", "DisplayService.GetByName", cause)
+	err := fmt.Errorf("handling request: %w", inner)
+
+	t.Run("Is reaches sentinels and causes", func(t *testing.T) {
+		assert.True(t, stderrors.Is(err, ErrNotFound))
+		assert.True(t, IsNotFound(err))
+		assert.True(t, stderrors.Is(err, inner))
+		assert.False(t, IsConflict(err))
+	})
+
+	t.Run("As finds the domain error and its cause", func(t *testing.T) {
+		var domainErr *Error
+		require.True(t, stderrors.As(err, &domainErr))
+		assert.Equal(t, "NOT_FOUND", domainErr.Code)
+		assert.Equal(t, "DisplayService.GetByName", domainErr.Op)
+
+		var missing missingDisplay
+		require.True(t, stderrors.As(err, &missing))
+		assert.Equal(t, "lobby", missing.name)
+	})
+
+	t.Run("nested domain errors", func(t *testing.T) {
+		outer := NewError("LOOKUP_FAILED", "Failed to retrieve display", "Handler.GetDisplay", inner)
+		assert.True(t, IsNotFound(outer))
+		assert.Equal(t, inner, stderrors.Unwrap(outer))
+	})
+
+	t.Run("without a cause", func(t *testing.T) {
+		bare := NewError("INTERNAL", "boom", "", nil)
+		assert.Nil(t, bare.Unwrap())
+		assert.False(t, IsNotFound(bare))
+		assert.Equal(t, "boom", bare.Error())
+	})
+}
+
+func TestHelpers_ThroughWraps(t *testing.T) {
+	helpers := map[error]func(error) bool{
+		ErrNotFound:        IsNotFound,
+		ErrConflict:        IsConflict,
+		ErrInvalidInput:    IsInvalidInput,
+		ErrUnauthorized:    IsUnauthorized,
+		ErrForbidden:       IsForbidden,
+		ErrVersionMismatch: IsVersionMismatch,
+		ErrLimitExceeded:   IsLimitExceeded,
+	}
+
+	for sentinel := range helpers {
+		once := NewError("CODE", "failed", "Service.Op", sentinel)
+		wraps := map[string]error{
+			"bare":    sentinel,
+			"once":    once,
+			"twice":   NewError("CODE", "failed", "Handler.Op", once),
+			"wrapped": fmt.Errorf("request: %w", NewError("CODE", "failed", "Handler.Op", once)),
+		}
+		for name, err := range wraps {
+			t.Run(sentinel.Error()+"/"+name, func(t *testing.T) {
+				for other, is := range helpers {
+					assert.Equal(t, other == sentinel, is(err), "helper for %q", other)
+				}
+			})
+		}
+	}
+}
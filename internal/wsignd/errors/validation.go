@@ -0,0 +1,50 @@
+package errors
+
+import (
+	"strings"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+)
+
+// ValidationError lists every invalid field found in a request, so that
+// clients can point at each of them rather than parse a message. It is an
+// invalid input error.
+type ValidationError struct {
+	Fields []v1alpha1.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return "invalid fields: " + strings.Join(msgs, "; ")
+}
+
+// Is reports whether target is the shared invalid input sentinel
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidInput
+}
+
+// Add records a problem with field
+func (e *ValidationError) Add(field, code, message string) {
+	e.Fields = append(e.Fields, v1alpha1.FieldError{Field: field, Code: code, Message: message})
+}
+
+// Required records that field is missing
+func (e *ValidationError) Required(field string) {
+	e.Add(field, v1alpha1.FieldRequired, "is required")
+}
+
+// Invalid records that field has an unacceptable value
+func (e *ValidationError) Invalid(field, message string) {
+	e.Add(field, v1alpha1.FieldInvalid, message)
+}
+
+// Err returns e when it holds any field errors and nil otherwise
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
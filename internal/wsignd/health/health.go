@@ -0,0 +1,149 @@
+// Package health serves the liveness and readiness probes. Liveness only
+// shows the process is serving HTTP; readiness also runs checks of the
+// components requests depend on, so that a load balancer stops routing to a
+// server that accepts connections it cannot service.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Probe paths
+const (
+	LivePath  = "/healthz"
+	ReadyPath = "/readyz"
+)
+
+// checkTimeout bounds each readiness check
+const checkTimeout = 2 * time.Second
+
+// Check reports whether a component is ready to serve. It returns nil when
+// it is, and an error made with Degraded when the component is impaired but
+// the server can still serve without it.
+type Check func(ctx context.Context) error
+
+// degradedError marks a check failure that does not make the server unready
+type degradedError struct {
+	err error
+}
+
+func (e degradedError) Error() string { return e.err.Error() }
+func (e degradedError) Unwrap() error { return e.err }
+
+// Degraded wraps err so that the check is reported as degraded without
+// failing readiness
+func Degraded(err error) error {
+	return degradedError{err: err}
+}
+
+// Report is the body of a readiness response
+type Report struct {
+	// Status is "unavailable" when any check failed, "degraded" when checks
+	// only reported degradation and "ok" otherwise
+	Status string `json:"status"`
+	// Checks holds "ok", or the error of each check prefixed with
+	// "degraded: " where it is only degraded, by name
+	Checks map[string]string `json:"checks"`
+}
+
+// Checks holds the readiness checks of a server
+type Checks struct {
+	mu     sync.RWMutex
+	checks map[string]Check
+	logger *slog.Logger
+}
+
+// New creates an empty set of readiness checks
+func New(logger *slog.Logger) *Checks {
+	return &Checks{
+		checks: make(map[string]Check),
+		logger: logger,
+	}
+}
+
+// Add registers check under name, replacing any check of that name
+func (c *Checks) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// Run runs every check and reports the results
+func (c *Checks) Run(ctx context.Context) *Report {
+	c.mu.RLock()
+	names := make([]string, 0, len(c.checks))
+	for name := range c.checks {
+		names = append(names, name)
+	}
+	c.mu.RUnlock()
+	sort.Strings(names)
+
+	report := &Report{Status: "ok", Checks: make(map[string]string, len(names))}
+	for _, name := range names {
+		c.mu.RLock()
+		check := c.checks[name]
+		c.mu.RUnlock()
+
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		err := check(checkCtx)
+		cancel()
+
+		var degraded degradedError
+		switch {
+		case errors.As(err, &degraded):
+			c.logger.Warn("readiness check degraded",
+				"check", name,
+				"error", err,
+			)
+			if report.Status == "ok" {
+				report.Status = "degraded"
+			}
+			report.Checks[name] = "degraded: " + err.Error()
+			continue
+		case err != nil:
+			c.logger.Warn("readiness check failed",
+				"check", name,
+				"error", err,
+			)
+			report.Status = "unavailable"
+			report.Checks[name] = err.Error()
+			continue
+		}
+		report.Checks[name] = "ok"
+	}
+	return report
+}
+
+// LiveHandler answers 200 for as long as the server handles requests
+func LiveHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok\n"))
+	}
+}
+
+// ReadyHandler runs the checks and answers 503 when any of them failed and
+// 200 otherwise, with the report as the body
+func (c *Checks) ReadyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := c.Run(r.Context())
+
+		status := http.StatusOK
+		if report.Status == "unavailable" {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(report)
+	}
+}
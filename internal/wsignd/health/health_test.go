@@ -0,0 +1,64 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadyHandler(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	checks := New(logger)
+
+	ready := func() (int, Report) {
+		rec := httptest.NewRecorder()
+		checks.ReadyHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, ReadyPath, nil))
+		var report Report
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+		return rec.Code, report
+	}
+
+	// With nothing to check the server is ready
+	status, report := ready()
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ok", report.Status)
+
+	var hubErr error
+	checks.Add("hub", func(ctx context.Context) error { return hubErr })
+	checks.Add("cache", func(ctx context.Context) error { return nil })
+
+	status, report = ready()
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]string{"hub": "ok", "cache": "ok"}, report.Checks)
+
+	hubErr = errors.New("hub stalled")
+	status, report = ready()
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "unavailable", report.Status)
+	assert.Equal(t, map[string]string{"hub": "hub stalled", "cache": "ok"}, report.Checks)
+
+	// A degraded check keeps the server ready, unless another check fails
+	checks.Add("cache", func(ctx context.Context) error { return Degraded(errors.New("circuit open")) })
+	status, report = ready()
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "unavailable", report.Status)
+	assert.Equal(t, "degraded: circuit open", report.Checks["cache"])
+
+	hubErr = nil
+	status, report = ready()
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "degraded", report.Status)
+
+	// Liveness does not depend on the checks
+	rec := httptest.NewRecorder()
+	LiveHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, LivePath, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,148 @@
+// Package httpapi writes the response bodies shared by the API handlers.
+// Every error response is a v1alpha1.Error, whichever handler wrote it, so
+// clients only need to understand one error shape.
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// statusCodes are the error codes reported for each status when the error
+// does not carry a code of its own
+var statusCodes = map[int]string{
+	http.StatusBadRequest:          "INVALID_INPUT",
+	http.StatusUnauthorized:        "UNAUTHORIZED",
+	http.StatusForbidden:           "FORBIDDEN",
+	http.StatusNotFound:            "NOT_FOUND",
+	http.StatusConflict:            "CONFLICT",
+	http.StatusUnprocessableEntity: "VALIDATION_FAILED",
+	http.StatusTooManyRequests:     "RATE_LIMITED",
+	http.StatusNotImplemented:      "NOT_IMPLEMENTED",
+	http.StatusServiceUnavailable:  "UNAVAILABLE",
+	http.StatusGatewayTimeout:      "DEADLINE_EXCEEDED",
+}
+
+// StatusCode returns the error code reported for status by default
+func StatusCode(status int) string {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	return "INTERNAL"
+}
+
+// WriteJSON encodes v as the response body with the given status
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// Error writes an error body with the given status and message, for
+// failures detected by a handler itself rather than returned by a service
+func Error(w http.ResponseWriter, status int, message string) {
+	WriteJSON(w, status, v1alpha1.Error{Code: StatusCode(status), Message: message})
+}
+
+// ErrorStatus maps a domain error to an HTTP status, falling back to
+// defaultStatus for errors without a recognised kind
+func ErrorStatus(err error, defaultStatus int) int {
+	var domainErr *werrors.Error
+	switch {
+	case errors.As(err, &domainErr) && domainErr.Code == StatusCode(http.StatusUnprocessableEntity):
+		return http.StatusUnprocessableEntity
+	case werrors.IsNotFound(err):
+		return http.StatusNotFound
+	case werrors.IsConflict(err), werrors.IsVersionMismatch(err), werrors.IsLimitExceeded(err):
+		return http.StatusConflict
+	case werrors.IsInvalidInput(err):
+		return http.StatusBadRequest
+	case werrors.IsTimeout(err):
+		return http.StatusGatewayTimeout
+	}
+	return defaultStatus
+}
+
+// WriteError maps err to a status with ErrorStatus and writes it as an
+// error body
+func WriteError(w http.ResponseWriter, err error, defaultStatus int) {
+	WriteErrorStatus(w, err, ErrorStatus(err, defaultStatus))
+}
+
+// WriteErrorStatus writes err as an error body with the given status. For
+// client errors the code and message of a domain error are passed on, and
+// other errors are described by their message, along with the invalid
+// fields of a validation error. Server errors report only the status text,
+// so internal details are not exposed; timeouts keep their own code so
+// clients know a retry may succeed.
+func WriteErrorStatus(w http.ResponseWriter, err error, status int) {
+	if status >= http.StatusInternalServerError {
+		code := "INTERNAL"
+		if status == http.StatusGatewayTimeout {
+			code = StatusCode(status)
+		}
+		WriteJSON(w, status, v1alpha1.Error{Code: code, Message: http.StatusText(status)})
+		return
+	}
+
+	apiErr := v1alpha1.Error{
+		Code:    StatusCode(status),
+		Message: err.Error(),
+	}
+	var domainErr *werrors.Error
+	if errors.As(err, &domainErr) {
+		apiErr.Code = domainErr.Code
+		apiErr.Message = domainErr.Message
+	}
+	var validationErr *werrors.ValidationError
+	if errors.As(err, &validationErr) {
+		apiErr.Errors = validationErr.Fields
+	}
+	WriteJSON(w, status, apiErr)
+}
+
+// unknownFieldPrefix starts the error encoding/json reports for a field
+// the target type does not have
+const unknownFieldPrefix = "json: unknown field "
+
+// DecodeJSON decodes the request body into v, rejecting fields v does not
+// have. Unknown fields and values of the wrong JSON type are reported as a
+// validation error naming the field, so they reach clients in the same
+// shape as any other invalid field.
+func DecodeJSON(r *http.Request, v interface{}) error {
+	const op = "httpapi.DecodeJSON"
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, io.EOF) {
+		return werrors.NewError("INVALID_INPUT", "request body is required", op, werrors.ErrInvalidInput)
+	}
+
+	verr := &werrors.ValidationError{}
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		verr.Invalid(typeErr.Field, fmt.Sprintf("cannot be a JSON %s", typeErr.Value))
+	case strings.HasPrefix(err.Error(), unknownFieldPrefix):
+		field, uerr := strconv.Unquote(strings.TrimPrefix(err.Error(), unknownFieldPrefix))
+		if uerr != nil {
+			field = strings.TrimPrefix(err.Error(), unknownFieldPrefix)
+		}
+		verr.Add(field, v1alpha1.FieldUnknown, "is not a known field")
+	default:
+		return werrors.NewError("INVALID_INPUT", "invalid request body", op, werrors.ErrInvalidInput)
+	}
+	return werrors.NewError("INVALID_INPUT", "invalid request body", op, verr)
+}
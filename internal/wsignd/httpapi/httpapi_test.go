@@ -0,0 +1,175 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+	}{
+		{
+			name:        "domain error keeps its code",
+			err:         werrors.NewError("ALREADY_EXISTS", "content source already exists: welcome", "test", werrors.ErrConflict),
+			wantStatus:  http.StatusConflict,
+			wantCode:    "ALREADY_EXISTS",
+			wantMessage: "content source already exists: welcome",
+		},
+		{
+			name:        "validation failure",
+			err:         werrors.NewError("VALIDATION_FAILED", "unexpected status 404", "test", werrors.ErrInvalidInput),
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantCode:    "VALIDATION_FAILED",
+			wantMessage: "unexpected status 404",
+		},
+		{
+			name:        "plain error of a known kind",
+			err:         fmt.Errorf("display lobby-1: %w", werrors.ErrNotFound),
+			wantStatus:  http.StatusNotFound,
+			wantCode:    "NOT_FOUND",
+			wantMessage: "display lobby-1: resource not found",
+		},
+		{
+			name:        "limit exceeded",
+			err:         fmt.Errorf("site hq is full: %w", werrors.ErrLimitExceeded),
+			wantStatus:  http.StatusConflict,
+			wantCode:    "CONFLICT",
+			wantMessage: "site hq is full: limit exceeded",
+		},
+		{
+			name:        "server errors hide their details",
+			err:         werrors.NewError("LOOKUP_FAILED", "dial tcp 10.0.0.5:5432", "test", errors.New("connection refused")),
+			wantStatus:  http.StatusInternalServerError,
+			wantCode:    "INTERNAL",
+			wantMessage: "Internal Server Error",
+		},
+		{
+			name:        "timeouts say so without their details",
+			err:         werrors.NewError("DEADLINE_EXCEEDED", "database operation timed out", "test", werrors.ErrTimeout),
+			wantStatus:  http.StatusGatewayTimeout,
+			wantCode:    "DEADLINE_EXCEEDED",
+			wantMessage: "Gateway Timeout",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			WriteError(w, tt.err, http.StatusInternalServerError)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			var got v1alpha1.Error
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+			assert.Equal(t, tt.wantCode, got.Code)
+			assert.Equal(t, tt.wantMessage, got.Message)
+		})
+	}
+}
+
+func TestWriteErrorFields(t *testing.T) {
+	verr := &werrors.ValidationError{}
+	verr.Required("location.zone")
+	verr.Invalid("location.siteId", "must be lower case")
+	w := httptest.NewRecorder()
+	WriteError(w, werrors.NewError("INVALID_INPUT", verr.Error(), "test", verr), http.StatusBadRequest)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var got v1alpha1.Error
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.Equal(t, "INVALID_INPUT", got.Code)
+	assert.Equal(t, []v1alpha1.FieldError{
+		{Field: "location.zone", Code: v1alpha1.FieldRequired, Message: "is required"},
+		{Field: "location.siteId", Code: v1alpha1.FieldInvalid, Message: "must be lower case"},
+	}, got.Errors)
+}
+
+func TestDecodeJSON(t *testing.T) {
+	type request struct {
+		Name     string `json:"name"`
+		Location struct {
+			Zone string `json:"zone"`
+		} `json:"location"`
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantErr    bool
+		wantFields []v1alpha1.FieldError
+	}{
+		{
+			name: "valid body",
+			body: `{"name":"lobby-1","location":{"zone":"lobby"}}`,
+		},
+		{
+			name:       "unknown field",
+			body:       `{"name":"lobby-1","zone":"lobby"}`,
+			wantErr:    true,
+			wantFields: []v1alpha1.FieldError{{Field: "zone", Code: v1alpha1.FieldUnknown, Message: "is not a known field"}},
+		},
+		{
+			name:       "wrong type",
+			body:       `{"name":"lobby-1","location":{"zone":7}}`,
+			wantErr:    true,
+			wantFields: []v1alpha1.FieldError{{Field: "location.zone", Code: v1alpha1.FieldInvalid, Message: "cannot be a JSON number"}},
+		},
+		{
+			name:    "empty body",
+			body:    "",
+			wantErr: true,
+		},
+		{
+			name:    "malformed body",
+			body:    `{"name":`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v request
+			err := DecodeJSON(httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)), &v)
+			if !tt.wantErr {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.ErrorIs(t, err, werrors.ErrInvalidInput)
+
+			var verr *werrors.ValidationError
+			if tt.wantFields == nil {
+				assert.False(t, errors.As(err, &verr))
+				return
+			}
+			require.True(t, errors.As(err, &verr))
+			assert.Equal(t, tt.wantFields, verr.Fields)
+		})
+	}
+}
+
+func TestError(t *testing.T) {
+	w := httptest.NewRecorder()
+	Error(w, http.StatusNotImplemented, "sessions are not available")
+
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+	var got v1alpha1.Error
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.Equal(t, v1alpha1.Error{Code: "NOT_IMPLEMENTED", Message: "sessions are not available"}, got)
+}
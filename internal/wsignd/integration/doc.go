@@ -0,0 +1,12 @@
+// Package integration exercises the full wsignd server stack: the real
+// router, services and Postgres stores, driven over HTTP the way displays
+// and operators use it.
+//
+// The tests need a database and are built only with the integration tag:
+//
+//	make test-integration
+//
+// or, against a database named by TEST_DATABASE_URL,
+//
+//	go test -tags integration ./internal/wsignd/integration/...
+package integration
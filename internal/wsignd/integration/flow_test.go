@@ -0,0 +1,150 @@
+//go:build integration
+
+package integration
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	"github.com/wrale/wrale-signage/internal/wsignd/discovery"
+	"github.com/wrale/wrale-signage/internal/wsignd/health"
+)
+
+// TestDisplayLifecycle follows a display from its first request for a
+// device code to the metrics an operator reads about the content it shows
+func TestDisplayLifecycle(t *testing.T) {
+	s := newStack(t)
+	location := v1alpha1.DisplayLocation{SiteID: "hq", Zone: "lobby", Position: "north"}
+
+	// The display asks for a device code and polls before anyone enters it
+	var code v1alpha1.DeviceCodeResponse
+	require.Equal(t, http.StatusOK, s.do("POST", "/api/v1alpha1/displays/device/code", "", nil, &code))
+	require.NotEmpty(t, code.DeviceCode)
+	require.NotEmpty(t, code.UserCode)
+
+	var pending v1alpha1.OAuthError
+	require.Equal(t, http.StatusBadRequest, s.do("POST", "/api/v1alpha1/displays/device/token", "",
+		v1alpha1.DeviceTokenRequest{DeviceCode: code.DeviceCode}, &pending))
+	assert.Equal(t, "authorization_pending", pending.Error)
+
+	// An operator activates the display with the code it shows
+	var registered v1alpha1.DisplayRegistrationResponse
+	require.Equal(t, http.StatusCreated, s.do("POST", "/api/v1alpha1/displays/activate", s.AdminToken,
+		v1alpha1.DisplayRegistrationRequest{
+			Name:           "lobby-north",
+			Location:       location,
+			ActivationCode: code.UserCode,
+		}, &registered))
+	require.NotNil(t, registered.Display)
+	displayID := registered.Display.ID
+	assert.Equal(t, v1alpha1.DisplayStateActive, registered.Display.Status.State)
+
+	// Polling again now hands the display its token
+	var issued v1alpha1.DeviceTokenResponse
+	require.Equal(t, http.StatusOK, s.do("POST", "/api/v1alpha1/displays/device/token", "",
+		v1alpha1.DeviceTokenRequest{DeviceCode: code.DeviceCode}, &issued))
+	require.NotEmpty(t, issued.AccessToken)
+	assert.Equal(t, "Bearer", issued.TokenType)
+	assert.Equal(t, displayID, issued.Display.ID)
+	displayToken := issued.AccessToken
+
+	// The token identifies the display, and only the display
+	var self v1alpha1.DisplaySelf
+	require.Equal(t, http.StatusOK, s.do("GET", "/api/v1alpha1/displays/me", displayToken, nil, &self))
+	assert.Equal(t, displayID, self.ID)
+	assert.Nil(t, self.Content, "nothing is assigned yet")
+	require.NotNil(t, self.Polling)
+	assert.Equal(t, http.StatusForbidden, s.do("GET", "/api/v1alpha1/displays", displayToken, nil, nil))
+
+	// Recording a heartbeat returns the display's polling hints
+	var heartbeat v1alpha1.HeartbeatResponse
+	require.Equal(t, http.StatusOK, s.do("PUT", "/api/v1alpha1/displays/"+displayID.String()+"/last-seen", s.AdminToken, nil, &heartbeat))
+	assert.Equal(t, self.Polling.HeartbeatInterval, heartbeat.HeartbeatInterval)
+
+	var seen v1alpha1.Display
+	require.Equal(t, http.StatusOK, s.do("GET", "/api/v1alpha1/displays/"+displayID.String(), s.AdminToken, nil, &seen))
+	assert.WithinDuration(t, time.Now(), seen.Status.LastSeen, time.Minute)
+
+	// An operator adds content and assigns it to the display's zone
+	var source v1alpha1.ContentSource
+	require.Equal(t, http.StatusCreated, s.do("POST", "/api/v1alpha1/content", s.AdminToken, v1alpha1.ContentSource{
+		ObjectMeta: v1alpha1.ObjectMeta{Name: "Welcome"},
+		Spec:       v1alpha1.ContentSourceSpec{URL: s.ContentURL, Type: "html"},
+	}, &source))
+	assert.Equal(t, "welcome", source.Name)
+
+	var assignment v1alpha1.ContentAssignment
+	require.Equal(t, http.StatusCreated, s.do("POST", "/api/v1alpha1/assignments", s.AdminToken, v1alpha1.ContentAssignment{
+		ObjectMeta:      v1alpha1.ObjectMeta{Name: "lobby-welcome"},
+		Source:          source.Name,
+		ContentURL:      s.ContentURL,
+		DisplaySelector: v1alpha1.DisplaySelector{SiteID: location.SiteID, Zone: location.Zone},
+	}, &assignment))
+
+	// The display now resolves to the assigned content
+	require.Equal(t, http.StatusOK, s.do("GET", "/api/v1alpha1/displays/me", displayToken, nil, &self))
+	require.NotNil(t, self.Content)
+	assert.Equal(t, s.ContentURL, self.Content.URL)
+	assert.Equal(t, "lobby-welcome", self.Content.Assignment)
+	assert.Equal(t, "welcome", self.Content.Source)
+
+	// The display reports showing the content; it cannot report for
+	// another display
+	now := time.Now().UTC()
+	batch := content.EventBatch{
+		DisplayID: displayID,
+		Events: []content.Event{
+			{
+				ID:        uuid.New(),
+				DisplayID: displayID,
+				Type:      content.EventContentLoaded,
+				URL:       s.ContentURL,
+				Timestamp: now,
+				Metrics:   &content.EventMetrics{LoadTime: 120, RenderTime: 40},
+			},
+			{
+				ID:        uuid.New(),
+				DisplayID: displayID,
+				Type:      content.EventContentVisible,
+				URL:       s.ContentURL,
+				Timestamp: now,
+			},
+		},
+	}
+	require.Equal(t, http.StatusAccepted, s.do("POST", "/api/v1alpha1/content/events", displayToken, batch, nil))
+
+	other := batch
+	other.DisplayID = uuid.New()
+	assert.Equal(t, http.StatusForbidden, s.do("POST", "/api/v1alpha1/content/events", displayToken, other, nil))
+
+	// The operator reads the reported load back from the metrics
+	var metrics v1alpha1.ContentMetrics
+	require.Equal(t, http.StatusOK, s.do("GET", "/api/v1alpha1/content/metrics?url="+url.QueryEscape(s.ContentURL), s.AdminToken, nil, &metrics))
+	assert.Equal(t, s.ContentURL, metrics.URL)
+	assert.EqualValues(t, 1, metrics.LoadCount)
+	assert.Zero(t, metrics.ErrorCount)
+	assert.InDelta(t, 120, metrics.AvgLoadTime, 0.01)
+}
+
+// TestOperatorScopes checks that the router guards management routes for
+// every kind of caller
+func TestOperatorScopes(t *testing.T) {
+	s := newStack(t)
+
+	assert.Equal(t, http.StatusUnauthorized, s.do("GET", "/api/v1alpha1/displays", "", nil, nil))
+	assert.Equal(t, http.StatusUnauthorized, s.do("GET", "/api/v1alpha1/displays", "not-a-token", nil, nil))
+	assert.Equal(t, http.StatusOK, s.do("GET", "/api/v1alpha1/displays", s.AdminToken, nil, nil))
+
+	// Probes, discovery and the version stay open
+	assert.Equal(t, http.StatusOK, s.do("GET", health.LivePath, "", nil, nil))
+	assert.Equal(t, http.StatusOK, s.do("GET", discovery.Path, "", nil, nil))
+	assert.Equal(t, http.StatusOK, s.do("GET", discovery.VersionPath, "", nil, nil))
+}
@@ -0,0 +1,113 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+	"github.com/wrale/wrale-signage/internal/wsignd/scheduler"
+	"github.com/wrale/wrale-signage/internal/wsignd/server"
+	"github.com/wrale/wrale-signage/internal/wsignd/testutil"
+)
+
+// signingSecret signs the display tokens the server issues
+const signingSecret = "integration-test-signing-key-0123"
+
+// stack is a running server with its own database
+type stack struct {
+	t *testing.T
+
+	// URL is the base URL of the server
+	URL string
+	// AdminToken is an operator API token with the admin scope
+	AdminToken string
+	// ContentURL serves a page content sources can point at
+	ContentURL string
+}
+
+// newStack starts the server on a fresh database, with rate limits counted
+// in memory and background jobs left unscheduled so that tests see only
+// their own changes
+func newStack(t *testing.T) *stack {
+	t.Helper()
+
+	db, cleanup := testutil.SetupTestDB(t)
+	t.Cleanup(cleanup)
+
+	t.Setenv("WSIGN_AUTH_TOKEN_KEY", signingSecret)
+	cfg, err := config.Load()
+	require.NoError(t, err)
+
+	key, err := auth.NewHMACKey("integration", []byte(signingSecret))
+	require.NoError(t, err)
+	keys, err := auth.NewKeyRing(key)
+	require.NoError(t, err)
+
+	stores := server.PostgresStores(db, nil, cfg)
+	stores.RateLimits = ratelimit.NewMemoryService(ratelimit.DefaultLimits())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	app, err := server.New(ctx, cfg, stores, keys, logger, scheduler.New(logger))
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(app.Handler)
+	t.Cleanup(srv.Close)
+	content := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = io.WriteString(w, "<html><body>welcome</body></html>")
+	}))
+	t.Cleanup(content.Close)
+
+	adminToken, _, err := operator.NewService(stores.Operators).CreateToken(ctx, "integration", "integration admin", []operator.Scope{operator.ScopeAdmin}, 0)
+	require.NoError(t, err)
+
+	return &stack{t: t, URL: srv.URL, AdminToken: adminToken, ContentURL: content.URL + "/welcome"}
+}
+
+// do sends body, if any, as JSON with token as the bearer token and
+// returns the response status, decoding the response body into out when
+// out is not nil
+func (s *stack) do(method, path, token string, body, out interface{}) int {
+	s.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		require.NoError(s.t, err)
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequest(method, s.URL+path, reader)
+	require.NoError(s.t, err)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(s.t, err)
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(s.t, err)
+	if out != nil && len(raw) > 0 {
+		require.NoError(s.t, json.Unmarshal(raw, out), "decoding %s %s: %s", method, path, raw)
+	}
+	return resp.StatusCode
+}
@@ -0,0 +1,366 @@
+// Package jsonpatch applies JSON Patch documents (RFC 6902) to JSON values.
+// Paths are JSON Pointers (RFC 6901). Every operation is supported; the
+// patch is applied as a whole or not at all.
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the media type of a JSON Patch document
+const ContentType = "application/json-patch+json"
+
+// ErrTestFailed indicates a test operation found a value other than the
+// one it expected. The patch is well formed, but the document is not in
+// the state it was written against.
+var ErrTestFailed = errors.New("test operation failed")
+
+// Operation is one step of a patch
+type Operation struct {
+	// Op is one of add, remove, replace, move, copy and test
+	Op string `json:"op"`
+	// Path points at the value the operation acts on
+	Path string `json:"path"`
+	// From points at the value moved or copied
+	From string `json:"from,omitempty"`
+	// Value is the value added, replaced or tested for
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Patch is a sequence of operations applied in order
+type Patch []Operation
+
+// Apply applies the patch to the JSON document doc and returns the result.
+// When an operation fails nothing is returned and the error names it.
+func (p Patch) Apply(doc []byte) ([]byte, error) {
+	value, err := decode(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid document: %w", err)
+	}
+	for i, op := range p {
+		if value, err = op.apply(value); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return json.Marshal(value)
+}
+
+// decode parses a JSON value, keeping numbers as written
+func decode(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("trailing data after JSON value")
+	}
+	return v, nil
+}
+
+func (op Operation) apply(doc interface{}) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, errors.New("value is required")
+		}
+		value, err := decode(op.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value: %w", err)
+		}
+		switch op.Op {
+		case "add":
+			return add(doc, path, value)
+		case "replace":
+			return replace(doc, path, value)
+		}
+		current, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(current, value) {
+			return nil, ErrTestFailed
+		}
+		return doc, nil
+	case "remove":
+		doc, _, err = remove(doc, path)
+		return doc, err
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from: %w", err)
+		}
+		if op.Op == "copy" {
+			value, err := get(doc, from)
+			if err != nil {
+				return nil, err
+			}
+			return add(doc, path, deepCopy(value))
+		}
+		if len(path) > len(from) && isPrefix(from, path) {
+			return nil, errors.New("a value cannot be moved into itself")
+		}
+		doc, value, err := remove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "":
+		return nil, errors.New("op is required")
+	}
+	return nil, fmt.Errorf("unknown op %q", op.Op)
+}
+
+// parsePointer splits a JSON Pointer into its unescaped reference tokens.
+// The empty pointer refers to the whole document.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("path %q must be empty or start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+func isPrefix(prefix, path []string) bool {
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// get returns the value path points at
+func get(doc interface{}, path []string) (interface{}, error) {
+	for i, token := range path {
+		switch container := doc.(type) {
+		case map[string]interface{}:
+			v, ok := container[token]
+			if !ok {
+				return nil, notFound(path[:i+1])
+			}
+			doc = v
+		case []interface{}:
+			idx, err := index(token, len(container)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = container[idx]
+		default:
+			return nil, notFound(path[:i+1])
+		}
+	}
+	return doc, nil
+}
+
+// update replaces the container holding the last token of path with what
+// fn makes of it, and returns the document with the change. Arrays may be
+// reallocated, so each level is stored back into its parent.
+func update(doc interface{}, path []string, fn func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+	token := path[0]
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		child, ok := container[token]
+		if !ok {
+			return nil, notFound(path[:1])
+		}
+		child, err := update(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		container[token] = child
+		return container, nil
+	case []interface{}:
+		idx, err := index(token, len(container)-1)
+		if err != nil {
+			return nil, err
+		}
+		child, err := update(container[idx], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		container[idx] = child
+		return container, nil
+	}
+	return nil, notFound(path[:1])
+}
+
+// add sets the member path points at, or inserts before the array element
+// it points at; "-" appends to an array
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return update(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			c[token] = value
+			return c, nil
+		case []interface{}:
+			if token == "-" {
+				return append(c, value), nil
+			}
+			idx, err := index(token, len(c))
+			if err != nil {
+				return nil, err
+			}
+			c = append(c, nil)
+			copy(c[idx+1:], c[idx:])
+			c[idx] = value
+			return c, nil
+		}
+		return nil, errors.New("parent is not an object or array")
+	})
+}
+
+// remove deletes the value path points at and returns it
+func remove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, errors.New("the whole document cannot be removed")
+	}
+	var removed interface{}
+	doc, err := update(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			v, ok := c[token]
+			if !ok {
+				return nil, notFound(path)
+			}
+			removed = v
+			delete(c, token)
+			return c, nil
+		case []interface{}:
+			idx, err := index(token, len(c)-1)
+			if err != nil {
+				return nil, err
+			}
+			removed = c[idx]
+			return append(c[:idx:idx], c[idx+1:]...), nil
+		}
+		return nil, notFound(path)
+	})
+	return doc, removed, err
+}
+
+// replace sets the value path points at, which must exist
+func replace(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if _, err := get(doc, path); err != nil {
+		return nil, err
+	}
+	if len(path) == 0 {
+		return value, nil
+	}
+	return update(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			c[token] = value
+			return c, nil
+		case []interface{}:
+			idx, _ := index(token, len(c)-1)
+			c[idx] = value
+			return c, nil
+		}
+		return nil, notFound(path)
+	})
+}
+
+// index parses an array index no greater than max
+func index(token string, max int) (int, error) {
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%q is not an array index", token)
+	}
+	if idx > max {
+		return 0, fmt.Errorf("array index %d is out of range", idx)
+	}
+	return idx, nil
+}
+
+func notFound(path []string) error {
+	escaped := make([]string, len(path))
+	for i, t := range path {
+		escaped[i] = strings.NewReplacer("~", "~0", "/", "~1").Replace(t)
+	}
+	return fmt.Errorf("no value at /%s", strings.Join(escaped, "/"))
+}
+
+// equal compares JSON values, numbers by their value
+func equal(a, b interface{}) bool {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			w, ok := b[k]
+			if !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if a == b {
+			return true
+		}
+		x, errA := a.Float64()
+		y, errB := b.Float64()
+		return errA == nil && errB == nil && x == y
+	}
+	return a == b
+}
+
+// deepCopy copies a decoded JSON value so that the copy can be changed
+// without affecting the original
+func deepCopy(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			c[k] = deepCopy(e)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, e := range v {
+			c[i] = deepCopy(e)
+		}
+		return c
+	}
+	return v
+}
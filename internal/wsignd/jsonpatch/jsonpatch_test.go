@@ -0,0 +1,115 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApply(t *testing.T) {
+	doc := `{"name":"menus","spec":{"url":"https://example.com","tags":["a","b"],"properties":{"a/b":"1","m~n":"2"}},"status":{"version":3}}`
+
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+		err   string
+	}{
+		{
+			name:  "add a member",
+			patch: `[{"op":"add","path":"/spec/type","value":"menu"}]`,
+			want:  `{"name":"menus","spec":{"url":"https://example.com","type":"menu","tags":["a","b"],"properties":{"a/b":"1","m~n":"2"}},"status":{"version":3}}`,
+		},
+		{
+			name:  "insert into and append to an array",
+			patch: `[{"op":"add","path":"/spec/tags/1","value":"x"},{"op":"add","path":"/spec/tags/-","value":"z"}]`,
+			want:  `{"name":"menus","spec":{"url":"https://example.com","tags":["a","x","b","z"],"properties":{"a/b":"1","m~n":"2"}},"status":{"version":3}}`,
+		},
+		{
+			name:  "escaped tokens",
+			patch: `[{"op":"replace","path":"/spec/properties/a~1b","value":"3"},{"op":"remove","path":"/spec/properties/m~0n"}]`,
+			want:  `{"name":"menus","spec":{"url":"https://example.com","tags":["a","b"],"properties":{"a/b":"3"}},"status":{"version":3}}`,
+		},
+		{
+			name:  "remove an array element",
+			patch: `[{"op":"remove","path":"/spec/tags/0"}]`,
+			want:  `{"name":"menus","spec":{"url":"https://example.com","tags":["b"],"properties":{"a/b":"1","m~n":"2"}},"status":{"version":3}}`,
+		},
+		{
+			name:  "move and copy",
+			patch: `[{"op":"move","from":"/spec/tags","path":"/tags"},{"op":"copy","from":"/tags/1","path":"/spec/first"}]`,
+			want:  `{"name":"menus","tags":["a","b"],"spec":{"url":"https://example.com","first":"b","properties":{"a/b":"1","m~n":"2"}},"status":{"version":3}}`,
+		},
+		{
+			name:  "passing test",
+			patch: `[{"op":"test","path":"/status/version","value":3.0},{"op":"test","path":"/spec/tags","value":["a","b"]}]`,
+			want:  doc,
+		},
+		{
+			name:  "failing test",
+			patch: `[{"op":"test","path":"/status/version","value":2}]`,
+			err:   "operation 0 (test /status/version): test operation failed",
+		},
+		{
+			name:  "replace a missing member",
+			patch: `[{"op":"replace","path":"/spec/type","value":"menu"}]`,
+			err:   "no value at /spec/type",
+		},
+		{
+			name:  "remove past the end of an array",
+			patch: `[{"op":"remove","path":"/spec/tags/2"}]`,
+			err:   "array index 2 is out of range",
+		},
+		{
+			name:  "leading zero index",
+			patch: `[{"op":"add","path":"/spec/tags/01","value":"x"}]`,
+			err:   `"01" is not an array index`,
+		},
+		{
+			name:  "move into itself",
+			patch: `[{"op":"move","from":"/spec","path":"/spec/inner"}]`,
+			err:   "cannot be moved into itself",
+		},
+		{
+			name:  "missing value",
+			patch: `[{"op":"add","path":"/spec/type"}]`,
+			err:   "value is required",
+		},
+		{
+			name:  "unknown op",
+			patch: `[{"op":"merge","path":"/spec"}]`,
+			err:   `unknown op "merge"`,
+		},
+		{
+			name:  "relative path",
+			patch: `[{"op":"remove","path":"spec"}]`,
+			err:   `path "spec" must be empty or start with /`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patch Patch
+			require.NoError(t, json.Unmarshal([]byte(tt.patch), &patch))
+
+			got, err := patch.Apply([]byte(doc))
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestApplyKeepsNumbers(t *testing.T) {
+	var patch Patch
+	require.NoError(t, json.Unmarshal([]byte(`[{"op":"add","path":"/b","value":9007199254740993}]`), &patch))
+
+	got, err := patch.Apply([]byte(`{"a":1000000}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":1000000,"b":9007199254740993}`, string(got))
+}
@@ -0,0 +1,155 @@
+// Package listen opens the sockets the server listens on and serves one
+// handler on all of them. Every address is bound before any is served, so
+// a server that cannot listen everywhere it was asked to fails at startup
+// with an error naming the address and what to try, rather than running
+// on some of them.
+package listen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+)
+
+// Address is a socket to listen on
+type Address struct {
+	// Network is tcp, tcp4 or tcp6
+	Network string
+	// Addr is the host:port to bind
+	Addr string
+}
+
+func (a Address) String() string {
+	return a.Addr
+}
+
+// Exact returns the address addr binds to when listed explicitly: an IPv4
+// address is bound for IPv4 only and an IPv6 address for IPv6 only, so
+// that "0.0.0.0:8080" and "[::]:8080" can be listened on together. Names
+// and empty hosts are bound as net.Listen binds them.
+func Exact(addr string) Address {
+	host, _, err := net.SplitHostPort(addr)
+	if err == nil {
+		if ip := net.ParseIP(host); ip != nil {
+			if ip.To4() != nil {
+				return Address{Network: "tcp4", Addr: addr}
+			}
+			return Address{Network: "tcp6", Addr: addr}
+		}
+	}
+	return Address{Network: "tcp", Addr: addr}
+}
+
+// Addresses returns the addresses cfg asks the server to listen on: each
+// of its listen addresses exactly, or its host and port as net.Listen
+// binds them, which for the default 0.0.0.0 is both IPv4 and IPv6 where
+// the system allows it
+func Addresses(cfg config.ServerConfig) []Address {
+	if len(cfg.ListenAddresses) == 0 {
+		return []Address{{Network: "tcp", Addr: net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))}}
+	}
+	addrs := make([]Address, 0, len(cfg.ListenAddresses))
+	for _, addr := range cfg.ListenAddresses {
+		addrs = append(addrs, Exact(addr))
+	}
+	return addrs
+}
+
+// BindError reports an address that could not be listened on
+type BindError struct {
+	// Addr is the address that was tried
+	Addr string
+	// Owner names the process already listening on the port, when the
+	// port was in use and the process could be found
+	Owner string
+	// Err is the error from the system
+	Err error
+}
+
+func (e *BindError) Error() string {
+	msg := fmt.Sprintf("cannot listen on %s: %v", e.Addr, e.Err)
+	if hint := e.hint(); hint != "" {
+		msg += "; " + hint
+	}
+	return msg
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// hint suggests what to do about the error
+func (e *BindError) hint() string {
+	var dnsErr *net.DNSError
+	switch {
+	case errors.Is(e.Err, syscall.EADDRINUSE):
+		hint := "the port is already in use"
+		if e.Owner != "" {
+			hint += " by " + e.Owner
+		}
+		return hint + "; stop the other process or choose another port with WSIGN_SERVER_PORT or WSIGN_SERVER_LISTEN_ADDRESSES"
+	case errors.Is(e.Err, syscall.EACCES):
+		return "ports below 1024 need root or the CAP_NET_BIND_SERVICE capability; choose a port above 1023"
+	case errors.Is(e.Err, syscall.EADDRNOTAVAIL):
+		return "the address is not assigned to this host; use one of its addresses, or 0.0.0.0 or :: for every interface"
+	case errors.As(e.Err, &dnsErr):
+		return "the host name does not resolve; use an IP address instead"
+	}
+	return ""
+}
+
+// Listen binds every one of addrs. If any cannot be bound, those already
+// bound are closed and a *BindError for the first failure is returned.
+func Listen(ctx context.Context, addrs []Address) ([]net.Listener, error) {
+	var lc net.ListenConfig
+	listeners := make([]net.Listener, 0, len(addrs))
+	for _, addr := range addrs {
+		ln, err := lc.Listen(ctx, addr.Network, addr.Addr)
+		if err != nil {
+			for _, bound := range listeners {
+				bound.Close()
+			}
+			bindErr := &BindError{Addr: addr.Addr, Err: err}
+			if errors.Is(err, syscall.EADDRINUSE) {
+				bindErr.Owner = portOwner(addr.Addr)
+			}
+			return nil, bindErr
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// Serve serves srv on every listener, over TLS when certFile is set, and
+// returns once each has stopped. Shutting srv down closes them all and
+// Serve then returns http.ErrServerClosed. If one listener fails, srv is
+// closed so that the server does not carry on with only some of its
+// addresses, and that listener's error is returned.
+func Serve(srv *http.Server, listeners []net.Listener, certFile, keyFile string) error {
+	errs := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		go func(ln net.Listener) {
+			if certFile != "" {
+				errs <- srv.ServeTLS(ln, certFile, keyFile)
+			} else {
+				errs <- srv.Serve(ln)
+			}
+		}(ln)
+	}
+
+	result := http.ErrServerClosed
+	for range listeners {
+		err := <-errs
+		if err != nil && !errors.Is(err, http.ErrServerClosed) && errors.Is(result, http.ErrServerClosed) {
+			result = err
+			srv.Close()
+		}
+	}
+	return result
+}
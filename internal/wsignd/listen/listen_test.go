@@ -0,0 +1,140 @@
+package listen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+)
+
+// freePort returns a port on host nothing is listening on
+func freePort(t *testing.T, network, host string) string {
+	t.Helper()
+	ln, err := net.Listen(network, net.JoinHostPort(host, "0"))
+	require.NoError(t, err)
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	require.NoError(t, err)
+	require.NoError(t, ln.Close())
+	return port
+}
+
+// requireIPv6 skips tests on hosts without IPv6 loopback
+func requireIPv6(t *testing.T) {
+	t.Helper()
+	ln, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 is not available")
+	}
+	ln.Close()
+}
+
+func TestAddresses(t *testing.T) {
+	assert.Equal(t, []Address{{Network: "tcp", Addr: "0.0.0.0:8080"}},
+		Addresses(config.ServerConfig{Host: "0.0.0.0", Port: 8080}))
+	assert.Equal(t, []Address{
+		{Network: "tcp4", Addr: "0.0.0.0:8080"},
+		{Network: "tcp6", Addr: "[::]:8080"},
+		{Network: "tcp", Addr: ":9090"},
+		{Network: "tcp", Addr: "localhost:9091"},
+	}, Addresses(config.ServerConfig{
+		Host:            "ignored",
+		Port:            8080,
+		ListenAddresses: []string{"0.0.0.0:8080", "[::]:8080", ":9090", "localhost:9091"},
+	}))
+}
+
+func TestServe(t *testing.T) {
+	requireIPv6(t)
+	ctx := context.Background()
+
+	// The same port on both families, as dual-stack deployments list it
+	port := freePort(t, "tcp4", "127.0.0.1")
+	listeners, err := Listen(ctx, []Address{
+		Exact(net.JoinHostPort("127.0.0.1", port)),
+		Exact(net.JoinHostPort("::1", port)),
+	})
+	require.NoError(t, err)
+	require.Len(t, listeners, 2)
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	})}
+	done := make(chan error, 1)
+	go func() { done <- Serve(srv, listeners, "", "") }()
+
+	for _, ln := range listeners {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		require.NoError(t, err, ln.Addr())
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, "ok", string(body), ln.Addr())
+	}
+
+	// One shutdown stops every listener
+	require.NoError(t, srv.Shutdown(ctx))
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, http.ErrServerClosed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after shutdown")
+	}
+	for _, ln := range listeners {
+		_, err := net.DialTimeout("tcp", ln.Addr().String(), time.Second)
+		assert.Error(t, err, "%s still accepts connections", ln.Addr())
+	}
+}
+
+func TestListenFailure(t *testing.T) {
+	ctx := context.Background()
+	taken, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer taken.Close()
+
+	free := net.JoinHostPort("127.0.0.1", freePort(t, "tcp4", "127.0.0.1"))
+	listeners, err := Listen(ctx, []Address{Exact(free), Exact(taken.Addr().String())})
+	assert.Nil(t, listeners)
+
+	var bindErr *BindError
+	require.ErrorAs(t, err, &bindErr)
+	assert.Equal(t, taken.Addr().String(), bindErr.Addr)
+	assert.ErrorIs(t, err, syscall.EADDRINUSE)
+	assert.Contains(t, err.Error(), "cannot listen on "+taken.Addr().String())
+	assert.Contains(t, err.Error(), "the port is already in use")
+	assert.Contains(t, err.Error(), "choose another port")
+	if runtime.GOOS == "linux" {
+		// This test holds the port, so it is the process to name
+		assert.Contains(t, bindErr.Owner, fmt.Sprintf("(pid %d)", os.Getpid()))
+	}
+
+	// The address bound before the failure was released
+	ln, err := net.Listen("tcp4", free)
+	require.NoError(t, err, "the listener bound before the failure was left open")
+	ln.Close()
+}
+
+func TestBindErrorHints(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want string
+	}{
+		{syscall.EACCES, "CAP_NET_BIND_SERVICE"},
+		{syscall.EADDRNOTAVAIL, "not assigned to this host"},
+		{&net.DNSError{Err: "no such host", Name: "signage.internl", IsNotFound: true}, "does not resolve"},
+	} {
+		err := &BindError{Addr: "x:80", Err: &net.OpError{Op: "listen", Net: "tcp", Err: tt.err}}
+		assert.Contains(t, err.Error(), tt.want)
+	}
+	assert.Equal(t, "cannot listen on x:80: boom", (&BindError{Addr: "x:80", Err: errors.New("boom")}).Error())
+}
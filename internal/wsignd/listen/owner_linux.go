@@ -0,0 +1,76 @@
+package listen
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// tcpListen is the state of a listening socket in /proc/net/tcp
+const tcpListen = "0A"
+
+// portOwner names the process listening on addr's port, as in
+// "nginx (pid 812)", or returns "" when it cannot be found. It is best
+// effort: another user's processes are usually not visible.
+func portOwner(addr string) string {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return ""
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return ""
+	}
+
+	inodes := make(map[string]bool)
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		listeningInodes(table, n, inodes)
+	}
+	if len(inodes) == 0 {
+		return ""
+	}
+
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+	for _, fd := range fds {
+		link, err := os.Readlink(fd)
+		if err != nil || !strings.HasPrefix(link, "socket:[") {
+			continue
+		}
+		if !inodes[strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")] {
+			continue
+		}
+		pid := strings.Split(fd, "/")[2]
+		comm, err := os.ReadFile(filepath.Join("/proc", pid, "comm"))
+		if err != nil {
+			return fmt.Sprintf("pid %s", pid)
+		}
+		return fmt.Sprintf("%s (pid %s)", strings.TrimSpace(string(comm)), pid)
+	}
+	return ""
+}
+
+// listeningInodes adds the inodes of the sockets in table listening on
+// port to inodes
+func listeningInodes(table string, port int, inodes map[string]bool) {
+	f, err := os.Open(table)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	want := fmt.Sprintf(":%04X", port)
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != tcpListen || !strings.HasSuffix(fields[1], want) {
+			continue
+		}
+		inodes[fields[9]] = true
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package listen
+
+// portOwner cannot find the process holding a port on this system
+func portOwner(addr string) string {
+	return ""
+}
@@ -0,0 +1,75 @@
+// Package logging holds slog handlers shared by the server. Routine
+// successes on high-frequency paths, such as a display checking in, are
+// logged at debug level; the sampling handler thins them out so that debug
+// logging can be left on in production without flooding the log.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// samplingHandler passes on one in every records below slog.LevelInfo with
+// the same message, and every record at info level or above
+type samplingHandler struct {
+	next    slog.Handler
+	every   uint64
+	counter *counter
+}
+
+// counter counts records by message. It is shared by every handler derived
+// from one sampling handler, so loggers with different attributes sample
+// together.
+type counter struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+// NewSamplingHandler wraps next so that only the first of every `every`
+// debug records with the same message is logged. Records at info level and
+// above are never sampled. An every below 2 returns next unchanged.
+func NewSamplingHandler(next slog.Handler, every int) slog.Handler {
+	if every < 2 {
+		return next
+	}
+	return &samplingHandler{
+		next:    next,
+		every:   uint64(every),
+		counter: &counter{counts: make(map[string]uint64)},
+	}
+}
+
+// Enabled reports whether the wrapped handler handles level
+func (h *samplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle drops all but one in every routine record with the same message
+func (h *samplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelInfo && !h.counter.sample(r.Message, h.every) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns a sampling handler wrapping next.WithAttrs
+func (h *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &samplingHandler{next: h.next.WithAttrs(attrs), every: h.every, counter: h.counter}
+}
+
+// WithGroup returns a sampling handler wrapping next.WithGroup
+func (h *samplingHandler) WithGroup(name string) slog.Handler {
+	return &samplingHandler{next: h.next.WithGroup(name), every: h.every, counter: h.counter}
+}
+
+// sample counts a record with message msg and reports whether it is the
+// first of its run of every
+func (c *counter) sample(msg string, every uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := c.counts[msg]
+	c.counts[msg] = (n + 1) % every
+	return n == 0
+}
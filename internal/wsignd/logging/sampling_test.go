@@ -0,0 +1,38 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSamplingHandler(t *testing.T) {
+	var buf bytes.Buffer
+	next := slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	logger := slog.New(NewSamplingHandler(next, 10))
+
+	for i := 0; i < 25; i++ {
+		logger.Debug("display seen", "i", i)
+		// Derived loggers share the count
+		logger.With("component", "hub").Debug("frame sent")
+		logger.Info("display state changed")
+	}
+
+	count := func(msg string) int {
+		return strings.Count(buf.String(), "msg=\""+msg+"\"")
+	}
+	// The 1st, 11th and 21st of each routine message get through
+	assert.Equal(t, 3, count("display seen"))
+	assert.Equal(t, 3, count("frame sent"))
+	assert.Equal(t, 25, count("display state changed"))
+	assert.Contains(t, buf.String(), "i=10")
+	assert.NotContains(t, buf.String(), "i=1\n")
+
+	t.Run("every below two does not sample", func(t *testing.T) {
+		assert.Same(t, next, NewSamplingHandler(next, 1))
+		assert.Same(t, next, NewSamplingHandler(next, 0))
+	})
+}
@@ -0,0 +1,99 @@
+// Package http serves the maintenance mode API
+package http
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/httpapi"
+	"github.com/wrale/wrale-signage/internal/wsignd/maintenance"
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+)
+
+// Path is where administrators start, end and inspect maintenance
+const Path = "/api/v1alpha1/admin/maintenance"
+
+// Handler serves maintenance requests
+type Handler struct {
+	service *maintenance.Service
+	logger  *slog.Logger
+}
+
+// NewHandler creates a maintenance handler
+func NewHandler(service *maintenance.Service, logger *slog.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// GetMaintenance reports whether the server is in maintenance
+func (h *Handler) GetMaintenance(w http.ResponseWriter, r *http.Request) {
+	httpapi.WriteJSON(w, http.StatusOK, toAPIStatus(h.service.Current(), 0))
+}
+
+// SetMaintenance starts maintenance, or ends it when the request is not
+// enabled. Starting while in maintenance replaces the notice.
+func (h *Handler) SetMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req v1alpha1.MaintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpapi.Error(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if !req.Enabled {
+		h.EndMaintenance(w, r)
+		return
+	}
+
+	state, notified, err := h.service.Start(r.Context(), maintenance.State{
+		ContentURL: req.ContentURL,
+		Message:    req.Message,
+		Until:      req.Until,
+		StartedBy:  operator.Name(r.Context()),
+	})
+	if err != nil {
+		h.logger.Error("failed to start maintenance",
+			"error", err,
+			"url", req.ContentURL,
+		)
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, toAPIStatus(state, notified))
+}
+
+// EndMaintenance ends maintenance and sends connected displays their
+// assigned content. Ending when not in maintenance succeeds.
+func (h *Handler) EndMaintenance(w http.ResponseWriter, r *http.Request) {
+	notified, err := h.service.End(r.Context(), operator.Name(r.Context()))
+	if err != nil {
+		h.logger.Error("failed to end maintenance", "error", err)
+		httpapi.WriteError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, toAPIStatus(nil, notified))
+}
+
+// toAPIStatus describes state, which is nil outside maintenance
+func toAPIStatus(state *maintenance.State, notified int) *v1alpha1.MaintenanceStatus {
+	status := &v1alpha1.MaintenanceStatus{
+		TypeMeta:         v1alpha1.TypeMeta{Kind: "MaintenanceStatus", APIVersion: "v1alpha1"},
+		DisplaysNotified: notified,
+	}
+	if state == nil {
+		return status
+	}
+	startedAt := state.StartedAt.In(time.UTC)
+	status.Enabled = true
+	status.ContentURL = state.ContentURL
+	status.Message = state.Message
+	status.Until = state.Until
+	status.StartedAt = &startedAt
+	status.StartedBy = state.StartedBy
+	return status
+}
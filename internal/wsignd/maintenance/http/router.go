@@ -0,0 +1,25 @@
+package http
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+)
+
+// NewRouter mounts the maintenance routes, which require the admin scope;
+// a nil guard leaves them open
+func NewRouter(h *Handler, guard operator.Guard) chi.Router {
+	r := chi.NewRouter()
+
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.RequestID)
+	r.Use(guard.Require(operator.ScopeAdmin))
+
+	r.Get("/", h.GetMaintenance)
+	r.Post("/", h.SetMaintenance)
+	r.Delete("/", h.EndMaintenance)
+
+	return r
+}
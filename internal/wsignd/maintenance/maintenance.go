@@ -0,0 +1,77 @@
+// Package maintenance implements maintenance mode, during which every
+// display is shown one notice instead of its assigned content. The mode is
+// stored so that it survives restarts and is shared by every replica.
+package maintenance
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+)
+
+// AssignmentName names the assignment displays are given while in
+// maintenance
+const AssignmentName = "maintenance"
+
+// State describes maintenance in progress
+type State struct {
+	// ContentURL is the notice displays are shown
+	ContentURL string
+	// Message describes the maintenance
+	Message string
+	// Until, when set, is when maintenance ends by itself
+	Until *time.Time
+	// StartedAt is when maintenance started
+	StartedAt time.Time
+	// StartedBy names the operator who started maintenance, if known
+	StartedBy string
+}
+
+// Expired reports whether maintenance has reached its end time at now
+func (s *State) Expired(now time.Time) bool {
+	return s.Until != nil && !now.Before(*s.Until)
+}
+
+// equal reports whether s and o describe the same maintenance; either may
+// be nil
+func (s *State) equal(o *State) bool {
+	if s == nil || o == nil {
+		return s == o
+	}
+	if (s.Until == nil) != (o.Until == nil) || (s.Until != nil && !s.Until.Equal(*o.Until)) {
+		return false
+	}
+	return s.ContentURL == o.ContentURL && s.Message == o.Message &&
+		s.StartedAt.Equal(o.StartedAt) && s.StartedBy == o.StartedBy
+}
+
+// Repository stores the maintenance state. There is at most one.
+type Repository interface {
+	// Get returns the stored state, or a not found error when the server
+	// is not in maintenance
+	Get(ctx context.Context) (*State, error)
+	// Save stores s, replacing any state already stored
+	Save(ctx context.Context, s *State) error
+	// Delete removes the stored state, reporting whether there was one
+	Delete(ctx context.Context) (bool, error)
+	// DeleteExpired removes the stored state if it has expired at now,
+	// reporting whether it did
+	DeleteExpired(ctx context.Context, now time.Time) (bool, error)
+}
+
+// Broadcaster pushes content to the displays connected to this server
+type Broadcaster interface {
+	// BroadcastContent sends each connected display the content resolved
+	// for it now, returning how many displays were sent it
+	BroadcastContent(ctx context.Context) int
+}
+
+// ContentResolver finds the content assigned to a display
+type ContentResolver interface {
+	// ForDisplay returns the assignment in effect for the display with id
+	// at location with properties, or nil when none selects it
+	ForDisplay(ctx context.Context, id uuid.UUID, location v1alpha1.DisplayLocation, properties map[string]string) (*v1alpha1.ContentAssignment, error)
+}
@@ -0,0 +1,70 @@
+// Package memory implements maintenance persistence in process memory for
+// demo mode and tests
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/maintenance"
+)
+
+// Repository implements maintenance.Repository in memory
+type Repository struct {
+	mu    sync.RWMutex
+	state *maintenance.State
+}
+
+// NewRepository creates an in-memory maintenance repository holding no
+// maintenance
+func NewRepository() maintenance.Repository {
+	return &Repository{}
+}
+
+// Get returns the stored state
+func (r *Repository) Get(ctx context.Context) (*maintenance.State, error) {
+	const op = "MaintenanceRepository.Get"
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.state == nil {
+		return nil, werrors.NewError("NOT_FOUND", "resource not found", op, werrors.ErrNotFound)
+	}
+	state := *r.state
+	return &state, nil
+}
+
+// Save stores s, replacing any state already stored
+func (r *Repository) Save(ctx context.Context, s *maintenance.State) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state := *s
+	r.state = &state
+	return nil
+}
+
+// Delete removes the stored state
+func (r *Repository) Delete(ctx context.Context) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := r.state != nil
+	r.state = nil
+	return deleted, nil
+}
+
+// DeleteExpired removes the stored state if it has expired at now
+func (r *Repository) DeleteExpired(ctx context.Context, now time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.state == nil || !r.state.Expired(now) {
+		return false, nil
+	}
+	r.state = nil
+	return true, nil
+}
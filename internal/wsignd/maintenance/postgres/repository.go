@@ -0,0 +1,101 @@
+// Package postgres implements the maintenance repository using PostgreSQL
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/database"
+	"github.com/wrale/wrale-signage/internal/wsignd/maintenance"
+)
+
+// Repository implements maintenance.Repository using PostgreSQL. The state
+// is the single row of maintenance_mode, present only during maintenance.
+type Repository struct {
+	db *sql.DB
+}
+
+// NewRepository creates a new PostgreSQL maintenance repository
+func NewRepository(db *sql.DB) maintenance.Repository {
+	return &Repository{db: db}
+}
+
+// Get returns the stored state
+func (r *Repository) Get(ctx context.Context) (*maintenance.State, error) {
+	const op = "MaintenanceRepository.Get"
+
+	var (
+		s     maintenance.State
+		until sql.NullTime
+	)
+	err := r.db.QueryRowContext(ctx, `
+		SELECT content_url, message, until, started_at, started_by
+		FROM maintenance_mode
+	`).Scan(&s.ContentURL, &s.Message, &until, &s.StartedAt, &s.StartedBy)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+	if until.Valid {
+		s.Until = &until.Time
+	}
+
+	return &s, nil
+}
+
+// Save stores s, replacing any state already stored
+func (r *Repository) Save(ctx context.Context, s *maintenance.State) error {
+	const op = "MaintenanceRepository.Save"
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO maintenance_mode (singleton, content_url, message, until, started_at, started_by)
+		VALUES (TRUE, $1, $2, $3, $4, $5)
+		ON CONFLICT (singleton) DO UPDATE SET
+			content_url = EXCLUDED.content_url,
+			message = EXCLUDED.message,
+			until = EXCLUDED.until,
+			started_at = EXCLUDED.started_at,
+			started_by = EXCLUDED.started_by
+	`, s.ContentURL, s.Message, s.Until, s.StartedAt, s.StartedBy)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+
+	return nil
+}
+
+// Delete removes the stored state
+func (r *Repository) Delete(ctx context.Context) (bool, error) {
+	const op = "MaintenanceRepository.Delete"
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM maintenance_mode`)
+	if err != nil {
+		return false, database.MapError(err, op)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, database.MapError(err, op)
+	}
+
+	return n > 0, nil
+}
+
+// DeleteExpired removes the stored state if it has expired at now. The
+// check is made in the statement so that maintenance started again
+// elsewhere in the meantime is left alone.
+func (r *Repository) DeleteExpired(ctx context.Context, now time.Time) (bool, error) {
+	const op = "MaintenanceRepository.DeleteExpired"
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM maintenance_mode WHERE until IS NOT NULL AND until <= $1
+	`, now)
+	if err != nil {
+		return false, database.MapError(err, op)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, database.MapError(err, op)
+	}
+
+	return n > 0, nil
+}
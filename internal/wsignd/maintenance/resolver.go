@@ -0,0 +1,40 @@
+package maintenance
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+)
+
+// Resolver gives every display the maintenance notice while the server is
+// in maintenance and the content next resolves otherwise
+type Resolver struct {
+	service *Service
+	next    ContentResolver
+}
+
+// NewResolver creates a resolver that overrides next during maintenance
+func NewResolver(service *Service, next ContentResolver) *Resolver {
+	return &Resolver{service: service, next: next}
+}
+
+// ForDisplay returns the maintenance notice as an assignment while the
+// server is in maintenance, whatever the display's assignments, and
+// otherwise what next resolves. The notice expires when the maintenance is
+// due to end.
+func (r *Resolver) ForDisplay(ctx context.Context, id uuid.UUID, location v1alpha1.DisplayLocation, properties map[string]string) (*v1alpha1.ContentAssignment, error) {
+	if current := r.service.Current(); current != nil {
+		return &v1alpha1.ContentAssignment{
+			TypeMeta:   v1alpha1.TypeMeta{Kind: "ContentAssignment", APIVersion: "v1alpha1"},
+			ObjectMeta: v1alpha1.ObjectMeta{Name: AssignmentName, CreatedAt: current.StartedAt},
+			DisplaySelector: v1alpha1.DisplaySelector{
+				SiteID: location.SiteID,
+			},
+			ContentURL: current.ContentURL,
+			ValidUntil: current.Until,
+		}, nil
+	}
+	return r.next.ForDisplay(ctx, id, location, properties)
+}
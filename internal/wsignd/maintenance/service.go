@@ -0,0 +1,209 @@
+package maintenance
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// Option configures a maintenance service
+type Option func(*Service)
+
+// WithClock sets the clock maintenance end times are checked against
+func WithClock(now func() time.Time) Option {
+	return func(s *Service) {
+		s.now = now
+	}
+}
+
+// Service starts and ends maintenance mode. It keeps the stored state in
+// memory so that resolving a display's content does not read the database;
+// Refresh picks up changes made on other replicas.
+type Service struct {
+	repo        Repository
+	broadcaster Broadcaster
+	logger      *slog.Logger
+	now         func() time.Time
+
+	mu      sync.RWMutex
+	current *State
+}
+
+// NewService creates a maintenance service backed by repo. Call Load before
+// the service is used so that maintenance started before a restart holds.
+func NewService(repo Repository, logger *slog.Logger, opts ...Option) *Service {
+	s := &Service{repo: repo, logger: logger, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// SetBroadcaster sets where content is pushed when maintenance starts or
+// ends. Without one, displays pick the change up when they next read their
+// content. It must be called before the service is used.
+func (s *Service) SetBroadcaster(b Broadcaster) {
+	s.broadcaster = b
+}
+
+// Load reads the stored state without notifying displays, which read their
+// content when they connect
+func (s *Service) Load(ctx context.Context) error {
+	const op = "MaintenanceService.Load"
+
+	stored, err := s.stored(ctx)
+	if err != nil {
+		return werrors.NewError("LOAD_FAILED", "Failed to load maintenance mode", op, err)
+	}
+	s.mu.Lock()
+	s.current = stored
+	s.mu.Unlock()
+	return nil
+}
+
+// Current returns the maintenance in progress, or nil when the server is
+// not in maintenance or the maintenance has reached its end time
+func (s *Service) Current() *State {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.current == nil || s.current.Expired(s.now()) {
+		return nil
+	}
+	state := *s.current
+	return &state
+}
+
+// Notice returns what displays are told about the maintenance in
+// progress, or nil when there is none
+func (s *Service) Notice() *v1alpha1.MaintenanceNotice {
+	current := s.Current()
+	if current == nil {
+		return nil
+	}
+	return &v1alpha1.MaintenanceNotice{Message: current.Message, Until: current.Until}
+}
+
+// Start puts the server in maintenance, or replaces the maintenance in
+// progress, and sends the notice to every connected display. It returns
+// the stored state and how many displays were sent the notice.
+func (s *Service) Start(ctx context.Context, state State) (*State, int, error) {
+	const op = "MaintenanceService.Start"
+
+	u, err := url.Parse(state.ContentURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, 0, werrors.NewError("INVALID_INPUT", "content URL must be an absolute http(s) URL", op, werrors.ErrInvalidInput)
+	}
+	now := s.now()
+	if state.Expired(now) {
+		return nil, 0, werrors.NewError("INVALID_INPUT", "until must be in the future", op, werrors.ErrInvalidInput)
+	}
+
+	state.StartedAt = now
+	if err := s.repo.Save(ctx, &state); err != nil {
+		return nil, 0, werrors.NewError("SAVE_FAILED", "Failed to start maintenance mode", op, err)
+	}
+	s.mu.Lock()
+	s.current = &state
+	s.mu.Unlock()
+
+	notified := s.broadcast(ctx)
+	s.logger.Info("maintenance started",
+		"audit", true,
+		"url", state.ContentURL,
+		"until", state.Until,
+		"operator", state.StartedBy,
+		"displaysNotified", notified,
+	)
+	result := state
+	return &result, notified, nil
+}
+
+// End takes the server out of maintenance and sends every connected
+// display its assigned content again. Ending when the server is not in
+// maintenance changes nothing. It returns how many displays were sent
+// their content.
+func (s *Service) End(ctx context.Context, operatorName string) (int, error) {
+	const op = "MaintenanceService.End"
+
+	deleted, err := s.repo.Delete(ctx)
+	if err != nil {
+		return 0, werrors.NewError("DELETE_FAILED", "Failed to end maintenance mode", op, err)
+	}
+	s.mu.Lock()
+	active := s.current != nil
+	s.current = nil
+	s.mu.Unlock()
+	if !deleted && !active {
+		return 0, nil
+	}
+
+	notified := s.broadcast(ctx)
+	s.logger.Info("maintenance ended",
+		"audit", true,
+		"operator", operatorName,
+		"displaysNotified", notified,
+	)
+	return notified, nil
+}
+
+// Refresh ends maintenance that has reached its end time and picks up
+// maintenance started or ended on other replicas, sending the displays
+// connected here their content when it changed. It is meant to be run
+// periodically.
+func (s *Service) Refresh(ctx context.Context) error {
+	const op = "MaintenanceService.Refresh"
+
+	expired, err := s.repo.DeleteExpired(ctx, s.now())
+	if err != nil {
+		return werrors.NewError("DELETE_FAILED", "Failed to end expired maintenance", op, err)
+	}
+	stored, err := s.stored(ctx)
+	if err != nil {
+		return werrors.NewError("LOAD_FAILED", "Failed to load maintenance mode", op, err)
+	}
+
+	s.mu.Lock()
+	changed := !stored.equal(s.current)
+	s.current = stored
+	s.mu.Unlock()
+	if !changed {
+		return nil
+	}
+
+	notified := s.broadcast(ctx)
+	switch {
+	case expired:
+		s.logger.Info("maintenance reached its end time", "displaysNotified", notified)
+	case stored == nil:
+		s.logger.Info("maintenance ended on another server", "displaysNotified", notified)
+	default:
+		s.logger.Info("maintenance changed on another server",
+			"url", stored.ContentURL,
+			"displaysNotified", notified,
+		)
+	}
+	return nil
+}
+
+// stored returns the stored state, or nil when there is none
+func (s *Service) stored(ctx context.Context) (*State, error) {
+	stored, err := s.repo.Get(ctx)
+	if werrors.IsNotFound(err) {
+		return nil, nil
+	}
+	return stored, err
+}
+
+// broadcast pushes displays their content as it resolves now
+func (s *Service) broadcast(ctx context.Context) int {
+	if s.broadcaster == nil {
+		return 0
+	}
+	return s.broadcaster.BroadcastContent(ctx)
+}
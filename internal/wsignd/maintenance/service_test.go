@@ -0,0 +1,166 @@
+package maintenance_test
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/clock"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/maintenance"
+	"github.com/wrale/wrale-signage/internal/wsignd/maintenance/memory"
+)
+
+const assignedURL = "https://example.com/welcome"
+
+// assigned resolves every display to the same assignment
+type assigned struct{}
+
+func (assigned) ForDisplay(ctx context.Context, id uuid.UUID, location v1alpha1.DisplayLocation, properties map[string]string) (*v1alpha1.ContentAssignment, error) {
+	return &v1alpha1.ContentAssignment{
+		ObjectMeta: v1alpha1.ObjectMeta{Name: "lobby-welcome"},
+		ContentURL: assignedURL,
+	}, nil
+}
+
+// recorder resolves a display's content whenever it is asked to broadcast,
+// as the display handler does, and keeps the URLs it would have sent
+type recorder struct {
+	resolver *maintenance.Resolver
+	sent     []string
+}
+
+func (r *recorder) BroadcastContent(ctx context.Context) int {
+	a, err := r.resolver.ForDisplay(ctx, uuid.New(), v1alpha1.DisplayLocation{SiteID: "hq"}, nil)
+	if err != nil || a == nil {
+		return 0
+	}
+	r.sent = append(r.sent, a.ContentURL)
+	return 1
+}
+
+// newServer builds the maintenance service of one server over repo
+func newServer(t *testing.T, repo maintenance.Repository, now *clock.Fake) (*maintenance.Service, *recorder) {
+	t.Helper()
+	service := maintenance.NewService(repo, slog.New(slog.NewTextHandler(os.Stdout, nil)), maintenance.WithClock(now.Now))
+	r := &recorder{resolver: maintenance.NewResolver(service, assigned{})}
+	service.SetBroadcaster(r)
+	require.NoError(t, service.Load(context.Background()))
+	return service, r
+}
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	now := clock.NewFake(time.Date(2024, 6, 1, 2, 0, 0, 0, time.UTC))
+	notice := "https://example.com/back-shortly"
+
+	t.Run("maintenance overrides assignments until it ends", func(t *testing.T) {
+		service, r := newServer(t, memory.NewRepository(), now)
+
+		notified, err := service.End(ctx, "alice")
+		require.NoError(t, err)
+		assert.Zero(t, notified, "ending outside maintenance notifies nobody")
+
+		state, notified, err := service.Start(ctx, maintenance.State{ContentURL: notice, Message: "Network upgrade", StartedBy: "alice"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, notified)
+		assert.Equal(t, now.Now(), state.StartedAt)
+
+		a, err := r.resolver.ForDisplay(ctx, uuid.New(), v1alpha1.DisplayLocation{SiteID: "hq"}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, maintenance.AssignmentName, a.Name)
+		assert.Equal(t, notice, a.ContentURL)
+		assert.Equal(t, &v1alpha1.MaintenanceNotice{Message: "Network upgrade"}, service.Notice())
+
+		notified, err = service.End(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, 1, notified)
+		assert.Nil(t, service.Current())
+		assert.Nil(t, service.Notice())
+
+		// Displays are sent the notice once maintenance is on and their
+		// content once it is off, never the other way round
+		assert.Equal(t, []string{notice, assignedURL}, r.sent)
+	})
+
+	t.Run("maintenance survives a restart", func(t *testing.T) {
+		repo := memory.NewRepository()
+		service, _ := newServer(t, repo, now)
+		until := now.Now().Add(time.Hour)
+		_, _, err := service.Start(ctx, maintenance.State{ContentURL: notice, Until: &until})
+		require.NoError(t, err)
+
+		restarted, r := newServer(t, repo, now)
+		require.NotNil(t, restarted.Current())
+		assert.Equal(t, notice, restarted.Current().ContentURL)
+		assert.Equal(t, until, *restarted.Current().Until)
+		a, err := r.resolver.ForDisplay(ctx, uuid.New(), v1alpha1.DisplayLocation{SiteID: "hq"}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, notice, a.ContentURL)
+		assert.Equal(t, &until, a.ValidUntil)
+		assert.Empty(t, r.sent, "loading notifies nobody")
+	})
+
+	t.Run("maintenance ends at its end time", func(t *testing.T) {
+		now := clock.NewFake(now.Now())
+		repo := memory.NewRepository()
+		service, r := newServer(t, repo, now)
+		until := now.Now().Add(time.Hour)
+		_, _, err := service.Start(ctx, maintenance.State{ContentURL: notice, Until: &until})
+		require.NoError(t, err)
+
+		require.NoError(t, service.Refresh(ctx))
+		assert.Equal(t, []string{notice}, r.sent, "nothing changed before the end time")
+
+		now.Advance(time.Hour)
+		assert.Nil(t, service.Current(), "expired before the refresh")
+		require.NoError(t, service.Refresh(ctx))
+		assert.Equal(t, []string{notice, assignedURL}, r.sent)
+		_, err = repo.Get(ctx)
+		assert.True(t, werrors.IsNotFound(err), "the stored state is removed")
+
+		require.NoError(t, service.Refresh(ctx))
+		assert.Len(t, r.sent, 2, "displays are sent their content once")
+	})
+
+	t.Run("changes on other servers are picked up", func(t *testing.T) {
+		repo := memory.NewRepository()
+		here, _ := newServer(t, repo, now)
+		there, r := newServer(t, repo, now)
+
+		_, _, err := here.Start(ctx, maintenance.State{ContentURL: notice})
+		require.NoError(t, err)
+		assert.Nil(t, there.Current(), "not until the next refresh")
+		require.NoError(t, there.Refresh(ctx))
+		require.NotNil(t, there.Current())
+
+		_, err = here.End(ctx, "alice")
+		require.NoError(t, err)
+		require.NoError(t, there.Refresh(ctx))
+		assert.Nil(t, there.Current())
+		assert.Equal(t, []string{notice, assignedURL}, r.sent)
+	})
+
+	t.Run("invalid requests are rejected", func(t *testing.T) {
+		service, r := newServer(t, memory.NewRepository(), now)
+		past := now.Now()
+		for _, state := range []maintenance.State{
+			{},
+			{ContentURL: "/back-shortly"},
+			{ContentURL: "ftp://example.com/back-shortly"},
+			{ContentURL: notice, Until: &past},
+		} {
+			_, _, err := service.Start(ctx, state)
+			assert.True(t, werrors.IsInvalidInput(err), state)
+		}
+		assert.Nil(t, service.Current())
+		assert.Empty(t, r.sent)
+	})
+}
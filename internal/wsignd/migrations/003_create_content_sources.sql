@@ -0,0 +1,22 @@
+-- Migration: 003
+-- Description: Create content sources table
+
+CREATE TABLE content_sources (
+    id              UUID PRIMARY KEY,
+    name            TEXT NOT NULL UNIQUE,
+    url             TEXT NOT NULL,
+    type            TEXT NOT NULL,
+    properties      JSONB NOT NULL DEFAULT '{}'::jsonb,
+    version         INTEGER NOT NULL DEFAULT 1,
+    last_validated  TIMESTAMP WITH TIME ZONE,
+    is_healthy      BOOLEAN NOT NULL DEFAULT FALSE,
+    status_report   JSONB,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Keep updated_at current on every change
+CREATE TRIGGER update_content_sources_updated_at
+    BEFORE UPDATE ON content_sources
+    FOR EACH ROW
+    EXECUTE PROCEDURE update_updated_at_column();
@@ -0,0 +1,16 @@
+-- Migration: 004
+-- Description: Create device codes table for display activation
+
+CREATE TABLE device_codes (
+    id              UUID PRIMARY KEY,
+    device_code     TEXT NOT NULL UNIQUE,
+    user_code       TEXT NOT NULL UNIQUE,
+    expires_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    poll_interval   INTEGER NOT NULL,
+    activated       BOOLEAN NOT NULL DEFAULT FALSE,
+    display_id      UUID REFERENCES displays(id) ON DELETE CASCADE,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Expired codes are removed periodically
+CREATE INDEX device_codes_expires_at_idx ON device_codes (expires_at);
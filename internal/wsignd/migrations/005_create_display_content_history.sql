@@ -0,0 +1,14 @@
+-- Migration: 005
+-- Description: Create display content history table
+
+CREATE TABLE display_content_history (
+    id              BIGSERIAL PRIMARY KEY,
+    display_id      UUID NOT NULL REFERENCES displays(id) ON DELETE CASCADE,
+    changed_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    from_url        TEXT NOT NULL DEFAULT '',
+    to_url          TEXT NOT NULL,
+    trigger         TEXT NOT NULL CHECK (trigger IN ('sequence', 'reload', 'assignment-change'))
+);
+
+-- History is always read and trimmed newest first per display
+CREATE INDEX display_content_history_display_idx ON display_content_history (display_id, id DESC);
@@ -0,0 +1,18 @@
+-- Migration: 006
+-- Description: Create display tokens table
+
+CREATE TABLE display_tokens (
+    id              UUID PRIMARY KEY,
+    display_id      UUID NOT NULL REFERENCES displays(id) ON DELETE CASCADE,
+    key_id          TEXT NOT NULL,
+    issued_at       TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at      TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Key usage is reported per key for unexpired tokens
+CREATE INDEX display_tokens_key_expires_idx ON display_tokens (key_id, expires_at);
+CREATE INDEX display_tokens_expires_at_idx ON display_tokens (expires_at);
+
+-- A device code is exchanged for a display token once. Polls after that
+-- are refused, so a leaked device code cannot mint further tokens.
+ALTER TABLE device_codes ADD COLUMN redeemed BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,5 @@
+-- Migration: 007
+-- Description: Index content sources by type
+
+-- Content sources are listed by type
+CREATE INDEX IF NOT EXISTS content_sources_type_idx ON content_sources (type);
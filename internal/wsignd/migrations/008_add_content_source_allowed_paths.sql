@@ -0,0 +1,5 @@
+-- Migration: 008
+-- Description: Add allowed assignment paths to content sources
+
+ALTER TABLE content_sources
+    ADD COLUMN allowed_paths TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,26 @@
+-- Migration: 009
+-- Description: Create content assignments table
+
+CREATE TABLE content_assignments (
+    id              UUID PRIMARY KEY,
+    name            TEXT NOT NULL UNIQUE,
+    source          TEXT NOT NULL DEFAULT '',
+    content_url     TEXT NOT NULL,
+    site_id         TEXT NOT NULL,
+    zone            TEXT NOT NULL DEFAULT '',
+    position        TEXT NOT NULL DEFAULT '',
+    valid_from      TIMESTAMP WITH TIME ZONE,
+    valid_until     TIMESTAMP WITH TIME ZONE,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Assignments are looked up by the source they came from and by location
+CREATE INDEX content_assignments_source_idx ON content_assignments (source);
+CREATE INDEX content_assignments_location_idx ON content_assignments (site_id, zone, position);
+
+-- Keep updated_at current on every change
+CREATE TRIGGER update_content_assignments_updated_at
+    BEFORE UPDATE ON content_assignments
+    FOR EACH ROW
+    EXECUTE PROCEDURE update_updated_at_column();
@@ -0,0 +1,8 @@
+-- Migration: 010
+-- Description: Add tags to content sources
+
+ALTER TABLE content_sources
+    ADD COLUMN tags JSONB NOT NULL DEFAULT '[]'::jsonb;
+
+-- Tag filters use containment (tags @> '["emergency"]')
+CREATE INDEX IF NOT EXISTS content_sources_tags_idx ON content_sources USING GIN (tags);
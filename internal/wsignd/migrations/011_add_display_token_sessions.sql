@@ -0,0 +1,11 @@
+-- Migration: 011
+-- Description: Group display tokens into sessions
+
+ALTER TABLE display_tokens ADD COLUMN session_id UUID;
+
+-- Tokens issued before sessions existed each form their own session
+UPDATE display_tokens SET session_id = id WHERE session_id IS NULL;
+
+ALTER TABLE display_tokens ALTER COLUMN session_id SET NOT NULL;
+
+CREATE INDEX display_tokens_display_session_idx ON display_tokens (display_id, session_id);
@@ -0,0 +1,21 @@
+-- Migration: 012
+-- Description: Create operators and their API tokens
+
+CREATE TABLE operators (
+    id              UUID PRIMARY KEY,
+    name            TEXT NOT NULL UNIQUE,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Only a SHA-256 hash of each token's secret is stored
+CREATE TABLE api_tokens (
+    id              UUID PRIMARY KEY,
+    operator_id     UUID NOT NULL REFERENCES operators(id) ON DELETE CASCADE,
+    name            TEXT NOT NULL DEFAULT '',
+    secret_hash     TEXT NOT NULL,
+    scopes          TEXT[] NOT NULL,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at      TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX api_tokens_operator_id_idx ON api_tokens (operator_id);
@@ -0,0 +1,8 @@
+-- Migration: 013
+-- Description: Track the last content error reported by each display
+
+-- Set from CONTENT_ERROR events and cleared by the next CONTENT_LOADED
+ALTER TABLE displays ADD COLUMN last_error_code TEXT;
+ALTER TABLE displays ADD COLUMN last_error_message TEXT;
+ALTER TABLE displays ADD COLUMN last_error_url TEXT;
+ALTER TABLE displays ADD COLUMN last_error_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,8 @@
+-- Migration: 014
+-- Description: Let selectors match display properties
+
+ALTER TABLE content_assignments
+    ADD COLUMN match_properties JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+-- Property filters use containment (properties @> '{"orientation": "portrait"}')
+CREATE INDEX IF NOT EXISTS displays_properties_idx ON displays USING GIN (properties);
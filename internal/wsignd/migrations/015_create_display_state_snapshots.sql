@@ -0,0 +1,13 @@
+-- Migration: 015
+-- Description: Create display state snapshots table
+
+-- One row per site per snapshot, holding only the states that had displays
+CREATE TABLE display_state_snapshots (
+    taken_at    TIMESTAMP WITH TIME ZONE NOT NULL,
+    site_id     TEXT NOT NULL,
+    counts      JSONB NOT NULL DEFAULT '{}'::jsonb,
+    PRIMARY KEY (site_id, taken_at)
+);
+
+-- Pruning and unfiltered history queries scan by time
+CREATE INDEX display_state_snapshots_taken_at_idx ON display_state_snapshots (taken_at);
@@ -0,0 +1,22 @@
+-- Migration: 016
+-- Description: Create display location proposals table
+
+CREATE TABLE display_location_proposals (
+    id                  UUID PRIMARY KEY,
+    display_id          UUID NOT NULL REFERENCES displays(id) ON DELETE CASCADE,
+    site_id             TEXT NOT NULL,
+    zone                TEXT NOT NULL,
+    position            TEXT NOT NULL,
+    previous_site_id    TEXT NOT NULL,
+    previous_zone       TEXT NOT NULL,
+    previous_position   TEXT NOT NULL,
+    note                TEXT NOT NULL DEFAULT '',
+    state               TEXT NOT NULL,
+    created_at          TIMESTAMP WITH TIME ZONE NOT NULL,
+    decided_at          TIMESTAMP WITH TIME ZONE,
+    decided_by          TEXT NOT NULL DEFAULT ''
+);
+
+-- Operators list proposals by state, and expiry looks for old pending ones
+CREATE INDEX display_location_proposals_state_idx ON display_location_proposals (state, created_at);
+CREATE INDEX display_location_proposals_display_idx ON display_location_proposals (display_id);
@@ -0,0 +1,23 @@
+-- Migration: 017
+-- Description: Create pending control messages table
+
+-- Control messages queued for displays that were not connected. seq keeps
+-- queue order; delivered_at is set by the conditional update that claims a
+-- message, so only one server ever delivers it.
+CREATE TABLE pending_messages (
+    id              UUID PRIMARY KEY,
+    seq             BIGSERIAL NOT NULL UNIQUE,
+    display_id      UUID NOT NULL REFERENCES displays(id) ON DELETE CASCADE,
+    message         JSONB NOT NULL,
+    state           TEXT NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    last_error      TEXT NOT NULL DEFAULT '',
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    delivered_at    TIMESTAMP WITH TIME ZONE
+);
+
+-- Delivery reads a display's queue in order; expiry and the delivery sweep
+-- look across displays by state
+CREATE INDEX pending_messages_display_idx ON pending_messages (display_id, seq);
+CREATE INDEX pending_messages_state_idx ON pending_messages (state, expires_at);
@@ -0,0 +1,6 @@
+-- Migration: 018
+-- Description: Store the device settings pushed to each display
+
+-- Orientation, brightness, volume and screen schedule, as JSON. Fields that
+-- are absent leave the device's own setting alone.
+ALTER TABLE displays ADD COLUMN settings JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,11 @@
+-- Migration: 019
+-- Description: Index displays by state and last check-in for the reaper and state counts
+
+-- Serves state filters and per-state counts, and replaces the index on
+-- state alone, which it covers
+CREATE INDEX displays_state_last_seen_idx ON displays (state, last_seen);
+DROP INDEX IF EXISTS displays_state_idx;
+
+-- The offline reaper only ever looks for active displays that have gone
+-- quiet, which are a small part of the table
+CREATE INDEX displays_active_last_seen_idx ON displays (last_seen) WHERE state = 'ACTIVE';
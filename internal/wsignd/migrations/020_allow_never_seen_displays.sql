@@ -0,0 +1,10 @@
+-- Migration: 020
+-- Description: Record displays that have never contacted the server with a NULL last_seen
+
+-- Displays were given their creation time as last_seen, which made one
+-- installed tomorrow look like one that went quiet today
+ALTER TABLE displays ALTER COLUMN last_seen DROP NOT NULL;
+
+-- A display still waiting for activation has not checked in yet; it gets
+-- a last_seen when it first connects
+UPDATE displays SET last_seen = NULL WHERE state = 'UNREGISTERED';
@@ -0,0 +1,12 @@
+-- Migration: 021
+-- Description: Index content events in read order for the event query API
+
+-- Events are paged through by (timestamp, id); the index on timestamp
+-- alone is covered by this one
+CREATE INDEX content_events_timestamp_id_idx ON content_events (timestamp, id);
+DROP INDEX IF EXISTS content_events_timestamp_idx;
+
+-- Most queries are for one URL, as are the URL metrics, which only need
+-- the leading columns
+CREATE INDEX content_events_url_timestamp_id_idx ON content_events (url, timestamp, id);
+DROP INDEX IF EXISTS content_events_url_idx;
@@ -0,0 +1,5 @@
+-- Migration: 022
+-- Description: Let device codes be bound to the site they may be activated into
+
+-- NULL leaves a code unbound
+ALTER TABLE device_codes ADD COLUMN allowed_site TEXT;
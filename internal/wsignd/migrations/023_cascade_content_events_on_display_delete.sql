@@ -0,0 +1,9 @@
+-- Migration: 023
+-- Description: Remove a display's content events along with the display
+
+-- Every other table keyed by display already cascades; without this, a
+-- display that ever reported content could not be deleted
+ALTER TABLE content_events DROP CONSTRAINT content_events_display_id_fkey;
+ALTER TABLE content_events
+    ADD CONSTRAINT content_events_display_id_fkey
+    FOREIGN KEY (display_id) REFERENCES displays(id) ON DELETE CASCADE;
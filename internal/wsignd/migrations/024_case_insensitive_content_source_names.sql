@@ -0,0 +1,28 @@
+-- Migration: 024
+-- Description: Make content source names unique regardless of case
+
+-- Sources whose names differ only in case cannot be merged automatically;
+-- stop and name them so an operator can rename or remove the extras
+DO $$
+DECLARE
+    duplicates TEXT;
+BEGIN
+    SELECT string_agg(names, '; ') INTO duplicates
+    FROM (
+        SELECT string_agg(name, ', ' ORDER BY name) AS names
+        FROM content_sources
+        GROUP BY lower(name)
+        HAVING count(*) > 1
+    ) groups;
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'content source names differ only in case: %', duplicates
+            USING HINT = 'Rename or delete all but one source in each group, then start the server again';
+    END IF;
+END
+$$;
+
+-- The service looks sources up by their lower-case name
+UPDATE content_sources SET name = lower(name) WHERE name <> lower(name);
+UPDATE content_assignments SET source = lower(source) WHERE source <> lower(source);
+
+CREATE UNIQUE INDEX content_sources_name_lower_idx ON content_sources (lower(name));
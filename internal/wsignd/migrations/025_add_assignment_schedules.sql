@@ -0,0 +1,6 @@
+-- Migration: 025
+-- Description: Add recurring daypart schedules to content assignments
+
+-- NULL means the assignment applies all day, every day, within its
+-- validity period
+ALTER TABLE content_assignments ADD COLUMN schedule JSONB;
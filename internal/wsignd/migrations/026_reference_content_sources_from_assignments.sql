@@ -0,0 +1,20 @@
+-- Migration: 026
+-- Description: Make content assignments reference the source they came from
+
+-- Assignments made without a source stop using an empty name, so that the
+-- foreign key below can skip them
+ALTER TABLE content_assignments ALTER COLUMN source DROP NOT NULL;
+ALTER TABLE content_assignments ALTER COLUMN source DROP DEFAULT;
+UPDATE content_assignments SET source = NULL WHERE source = '';
+
+-- Sources removed before references were tracked can no longer be named;
+-- their assignments keep showing the content URL they were given
+UPDATE content_assignments a SET source = NULL
+WHERE source IS NOT NULL
+    AND NOT EXISTS (SELECT 1 FROM content_sources s WHERE s.name = a.source);
+
+-- Removing a source is refused while assignments refer to it. The
+-- repository checks first and reports which; this backs that check up.
+ALTER TABLE content_assignments
+    ADD CONSTRAINT content_assignments_source_fkey
+    FOREIGN KEY (source) REFERENCES content_sources (name) ON DELETE RESTRICT;
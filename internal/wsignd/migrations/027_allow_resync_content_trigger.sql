@@ -0,0 +1,7 @@
+-- Migration: 027
+-- Description: Record content pushed again by an operator's resync
+
+ALTER TABLE display_content_history DROP CONSTRAINT display_content_history_trigger_check;
+ALTER TABLE display_content_history
+    ADD CONSTRAINT display_content_history_trigger_check
+    CHECK (trigger IN ('sequence', 'reload', 'assignment-change', 'resync'));
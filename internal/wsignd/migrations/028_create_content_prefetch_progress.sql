@@ -0,0 +1,15 @@
+-- Migration: 028
+-- Description: Keep the latest prefetch progress each display reported per URL
+
+-- One row per display and URL, replaced only by progress reported at the
+-- same time or later so that late events cannot move it backwards
+CREATE TABLE content_prefetch_progress (
+    display_id UUID NOT NULL REFERENCES displays(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    bytes_downloaded BIGINT NOT NULL,
+    bytes_total BIGINT NOT NULL,
+    reported_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (display_id, url)
+);
+
+CREATE INDEX content_prefetch_progress_url_idx ON content_prefetch_progress (url);
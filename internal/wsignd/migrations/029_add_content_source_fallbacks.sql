@@ -0,0 +1,5 @@
+-- Migration: 029
+-- Description: Add ordered fallback sources to content sources
+
+ALTER TABLE content_sources
+    ADD COLUMN fallbacks TEXT[] NOT NULL DEFAULT '{}';
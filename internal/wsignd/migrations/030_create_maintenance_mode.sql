@@ -0,0 +1,17 @@
+-- Migration: 030
+-- Description: Persist maintenance mode and record the content it pushes
+
+-- At most one row, present while the server is in maintenance mode
+CREATE TABLE maintenance_mode (
+    singleton BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (singleton),
+    content_url TEXT NOT NULL,
+    message TEXT NOT NULL DEFAULT '',
+    until TIMESTAMP WITH TIME ZONE,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    started_by TEXT NOT NULL DEFAULT ''
+);
+
+ALTER TABLE display_content_history DROP CONSTRAINT display_content_history_trigger_check;
+ALTER TABLE display_content_history
+    ADD CONSTRAINT display_content_history_trigger_check
+    CHECK (trigger IN ('sequence', 'reload', 'assignment-change', 'resync', 'maintenance'));
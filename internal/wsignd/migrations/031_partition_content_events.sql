@@ -0,0 +1,93 @@
+-- Migration: 031
+-- Description: Partition content events by month
+
+-- Events are kept in monthly range partitions on their timestamp, so that
+-- retention drops whole months rather than deleting row by row and queries
+-- over a window only read the partitions covering it. The existing table
+-- becomes the partition for every event before the first month, as is, so
+-- its events are not copied; retention drops it once its newest events
+-- expire. Only events stamped in the first month or later, from displays
+-- with clocks ahead, are moved into the partitions covering them.
+--
+-- The primary key of a partitioned table must include the partition key.
+-- The migration builds the unique index that needs on the existing table,
+-- which takes a while on a large one and blocks event reports meanwhile.
+-- To avoid that, build it beforehand without blocking writes:
+--
+--   CREATE UNIQUE INDEX CONCURRENTLY content_events_legacy_id_timestamp_idx
+--       ON content_events (id, timestamp)
+
+ALTER TABLE content_events RENAME TO content_events_legacy;
+ALTER INDEX content_events_pkey RENAME TO content_events_legacy_pkey;
+ALTER INDEX content_events_display_id_idx RENAME TO content_events_legacy_display_id_idx;
+ALTER INDEX content_events_type_idx RENAME TO content_events_legacy_type_idx;
+ALTER INDEX content_events_cleanup_idx RENAME TO content_events_legacy_cleanup_idx;
+ALTER INDEX content_events_timestamp_id_idx RENAME TO content_events_legacy_timestamp_id_idx;
+ALTER INDEX content_events_url_timestamp_id_idx RENAME TO content_events_legacy_url_timestamp_id_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS content_events_legacy_id_timestamp_idx
+    ON content_events_legacy (id, timestamp);
+-- Only an index backing a constraint can stand in for the parent's key
+ALTER TABLE content_events_legacy
+    ADD CONSTRAINT content_events_legacy_id_timestamp_key
+    UNIQUE USING INDEX content_events_legacy_id_timestamp_idx;
+
+CREATE TABLE content_events (
+    id          UUID NOT NULL,
+    display_id  UUID NOT NULL,
+    type        TEXT NOT NULL,
+    url         TEXT NOT NULL,
+    timestamp   TIMESTAMP WITH TIME ZONE NOT NULL,
+    error       JSONB,
+    metrics     JSONB,
+    context     JSONB,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (id, timestamp)
+) PARTITION BY RANGE (timestamp);
+
+-- The same indexes as before; attaching the existing table adopts its
+-- matching indexes and foreign key rather than building new ones
+CREATE INDEX content_events_display_id_idx ON content_events (display_id);
+CREATE INDEX content_events_type_idx ON content_events (type);
+CREATE INDEX content_events_timestamp_id_idx ON content_events (timestamp, id);
+CREATE INDEX content_events_url_timestamp_id_idx ON content_events (url, timestamp, id);
+ALTER TABLE content_events
+    ADD CONSTRAINT content_events_display_id_fkey
+    FOREIGN KEY (display_id) REFERENCES displays(id) ON DELETE CASCADE;
+
+-- Months start at midnight UTC. The server creates the partitions for
+-- later months ahead of time; events no partition covers, such as those
+-- from displays with badly wrong clocks, land in the default partition.
+--
+-- Attaching the existing table fails if it holds any event from the first
+-- month on, so those are moved to the new partitions first. A validated
+-- check constraint then proves the table fits its range, so the attach
+-- does not scan it.
+DO $$
+DECLARE
+    first_month TIMESTAMP := date_trunc('month', now() AT TIME ZONE 'UTC') + INTERVAL '1 month';
+BEGIN
+    EXECUTE format(
+        'CREATE TABLE %I PARTITION OF content_events FOR VALUES FROM (%L) TO (%L)',
+        'content_events_p' || to_char(first_month, 'YYYYMM'),
+        first_month AT TIME ZONE 'UTC',
+        (first_month + INTERVAL '1 month') AT TIME ZONE 'UTC');
+    CREATE TABLE content_events_default PARTITION OF content_events DEFAULT;
+
+    WITH moved AS (
+        DELETE FROM content_events_legacy
+        WHERE timestamp >= first_month AT TIME ZONE 'UTC'
+        RETURNING id, display_id, type, url, timestamp, error, metrics, context, created_at
+    )
+    INSERT INTO content_events (id, display_id, type, url, timestamp, error, metrics, context, created_at)
+    SELECT id, display_id, type, url, timestamp, error, metrics, context, created_at FROM moved;
+
+    EXECUTE format(
+        'ALTER TABLE content_events_legacy ADD CONSTRAINT content_events_legacy_timestamp_check CHECK (timestamp < %L) NOT VALID',
+        first_month AT TIME ZONE 'UTC');
+    ALTER TABLE content_events_legacy VALIDATE CONSTRAINT content_events_legacy_timestamp_check;
+    EXECUTE format(
+        'ALTER TABLE content_events ATTACH PARTITION content_events_legacy FOR VALUES FROM (MINVALUE) TO (%L)',
+        first_month AT TIME ZONE 'UTC');
+    -- The partition bound now enforces the same
+    ALTER TABLE content_events_legacy DROP CONSTRAINT content_events_legacy_timestamp_check;
+END $$;
@@ -0,0 +1,12 @@
+-- Migration: 032
+-- Description: Let content assignments name a single display
+
+-- Set when an assignment targets one display rather than a location; the
+-- location columns are then empty. There is deliberately no foreign key:
+-- an assignment outlives its display and is reported as orphaned, so
+-- operators notice and remove it rather than content silently vanishing.
+ALTER TABLE content_assignments ADD COLUMN display_id UUID;
+
+CREATE INDEX content_assignments_display_id_idx
+    ON content_assignments (display_id)
+    WHERE display_id IS NOT NULL;
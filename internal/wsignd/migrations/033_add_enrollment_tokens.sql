@@ -0,0 +1,15 @@
+-- Migration: 033
+-- Description: Keep enrollment tokens for provisioned displays with device codes
+
+-- Enrollment tokens are single use secrets minted with a provisioning file.
+-- They are presented instead of polled with, so they have no user code and
+-- name their display from the start.
+ALTER TABLE device_codes
+    ADD COLUMN kind TEXT NOT NULL DEFAULT 'device_code'
+        CHECK (kind IN ('device_code', 'enrollment'));
+
+ALTER TABLE device_codes ALTER COLUMN user_code DROP NOT NULL;
+
+ALTER TABLE device_codes
+    ADD CONSTRAINT device_codes_user_code_kind_check
+        CHECK ((kind = 'device_code') = (user_code IS NOT NULL));
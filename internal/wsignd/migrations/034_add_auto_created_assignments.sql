@@ -0,0 +1,7 @@
+-- Migration: 034
+-- Description: Mark content assignments created by auto-assignment rules
+
+-- Set on the assignments the server creates when a display is activated
+-- and matches an auto-assignment rule. Any assignment an operator made
+-- outranks them, and at most one exists per display.
+ALTER TABLE content_assignments ADD COLUMN auto_created BOOLEAN NOT NULL DEFAULT FALSE;
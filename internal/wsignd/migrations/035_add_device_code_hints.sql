@@ -0,0 +1,11 @@
+-- Migration: 035
+-- Description: Keep the location and name a display suggests with its device code
+
+-- Pre-staged displays send where they are meant to be installed when they
+-- request their codes. The values only pre-fill activation; NULL means no
+-- hint was given.
+ALTER TABLE device_codes
+    ADD COLUMN hint_site_id TEXT,
+    ADD COLUMN hint_zone TEXT,
+    ADD COLUMN hint_position TEXT,
+    ADD COLUMN hint_name TEXT;
@@ -0,0 +1,4 @@
+package migrations
+
+// LockKey exposes the advisory lock key to tests
+const LockKey = lockKey
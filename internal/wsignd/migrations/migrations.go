@@ -5,6 +5,7 @@ import (
 	"context"
 	"database/sql"
 	"embed"
+	"errors"
 	"fmt"
 	"log"
 	"regexp"
@@ -19,8 +20,10 @@ var migrationFiles embed.FS
 
 var (
 	migrationFilePattern = regexp.MustCompile(`^(\d{3})_(.+)\.sql$`)
-	functionPattern      = regexp.MustCompile(`(?si)CREATE(?:\s+OR\s+REPLACE)?\s+FUNCTION.*?LANGUAGE`)
-	commentPattern       = regexp.MustCompile(`(?m)^--.*$|/\*(?s).*?\*/`)
+	// functionPattern matches function definitions and anonymous DO blocks,
+	// whose bodies contain semicolons that do not end the statement
+	functionPattern = regexp.MustCompile(`(?si)CREATE(?:\s+OR\s+REPLACE)?\s+FUNCTION.*?LANGUAGE|\bDO\s+\$\$.*?\$\$`)
+	commentPattern  = regexp.MustCompile(`(?m)^--.*$|/\*(?s).*?\*/`)
 )
 
 // Migration represents a single database migration
@@ -31,14 +34,34 @@ type Migration struct {
 	Down        string
 }
 
+// DefaultLockTimeout is how long ApplyMigrations waits for another
+// instance to finish migrating when no timeout is given
+const DefaultLockTimeout = 5 * time.Minute
+
+// lockKey identifies the advisory lock held while migrating. Every instance
+// sharing a database must use the same key.
+const lockKey int64 = 0x7773696E6D6967 // "wsinmig"
+
+// lockPollInterval is how often a waiting instance retries the lock
+const lockPollInterval = 500 * time.Millisecond
+
+// unlockTimeout bounds releasing the migration lock
+const unlockTimeout = 10 * time.Second
+
 // Manager handles executing database migrations
 type Manager struct {
-	db *sql.DB
+	db          *sql.DB
+	lockTimeout time.Duration
 }
 
-// NewManager creates a new migration manager
-func NewManager(db *sql.DB) *Manager {
-	return &Manager{db: db}
+// NewManager creates a new migration manager. lockTimeout bounds how long
+// ApplyMigrations waits while another instance holds the migration lock;
+// zero uses DefaultLockTimeout.
+func NewManager(db *sql.DB, lockTimeout time.Duration) *Manager {
+	if lockTimeout <= 0 {
+		lockTimeout = DefaultLockTimeout
+	}
+	return &Manager{db: db, lockTimeout: lockTimeout}
 }
 
 // LoadMigrations reads all SQL migration files
@@ -93,17 +116,30 @@ func (m *Manager) LoadMigrations() ([]Migration, error) {
 	return migrations, nil
 }
 
-// ApplyMigrations runs any pending migrations
+// ApplyMigrations runs any pending migrations. Instances sharing a database
+// take turns through a Postgres advisory lock, so only one of them applies
+// migrations while the others wait. An instance that cannot get the lock
+// within the lock timeout carries on if every migration is already applied
+// and fails otherwise.
 func (m *Manager) ApplyMigrations(ctx context.Context) error {
-	if err := m.ensureMigrationTable(ctx); err != nil {
-		return fmt.Errorf("error creating migration table: %w", err)
-	}
-
 	migrations, err := m.LoadMigrations()
 	if err != nil {
 		return fmt.Errorf("error loading migrations: %w", err)
 	}
 
+	unlock, err := m.lock(ctx)
+	if errors.Is(err, errLockTimeout) {
+		return m.verifyApplied(ctx, migrations)
+	}
+	if err != nil {
+		return fmt.Errorf("error acquiring migration lock: %w", err)
+	}
+	defer unlock()
+
+	if err := m.ensureMigrationTable(ctx); err != nil {
+		return fmt.Errorf("error creating migration table: %w", err)
+	}
+
 	applied, err := m.getAppliedMigrations(ctx)
 	if err != nil {
 		return fmt.Errorf("error getting applied migrations: %w", err)
@@ -125,6 +161,132 @@ func (m *Manager) ApplyMigrations(ctx context.Context) error {
 	return nil
 }
 
+// Status describes whether a migration has been applied
+type Status struct {
+	Version     int        `json:"version"`
+	Description string     `json:"description"`
+	AppliedAt   *time.Time `json:"appliedAt,omitempty"`
+	// Unknown is set for migrations recorded in the database but not
+	// shipped with this binary, as after a downgrade
+	Unknown bool `json:"unknown,omitempty"`
+}
+
+// Status lists every migration with when it was applied, without applying
+// anything. Migrations the database records but this binary does not know
+// are listed last.
+func (m *Manager) Status(ctx context.Context) ([]Status, error) {
+	migrations, err := m.LoadMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("error loading migrations: %w", err)
+	}
+
+	// A database that was never migrated has no tracking table
+	var exists bool
+	if err := m.db.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("error checking migration table: %w", err)
+	}
+	applied := make(map[int]time.Time)
+	if exists {
+		if applied, err = m.getAppliedMigrations(ctx); err != nil {
+			return nil, fmt.Errorf("error getting applied migrations: %w", err)
+		}
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	for _, migration := range migrations {
+		status := Status{Version: migration.Version, Description: migration.Description}
+		if at, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &at
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+
+	var unknown []int
+	for version := range applied {
+		unknown = append(unknown, version)
+	}
+	sort.Ints(unknown)
+	for _, version := range unknown {
+		at := applied[version]
+		statuses = append(statuses, Status{Version: version, AppliedAt: &at, Unknown: true})
+	}
+	return statuses, nil
+}
+
+// errLockTimeout reports that another instance held the migration lock for
+// longer than the lock timeout
+var errLockTimeout = errors.New("timed out waiting for migration lock")
+
+// lock takes the migration advisory lock, waiting up to the lock timeout.
+// Advisory locks belong to a session, so the lock is held on a dedicated
+// connection that the returned function releases.
+func (m *Manager) lock(ctx context.Context) (func(), error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(m.lockTimeout)
+	for waiting := false; ; waiting = true {
+		var locked bool
+		if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, lockKey).Scan(&locked); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			conn.Close()
+			return nil, errLockTimeout
+		}
+		if !waiting {
+			log.Printf("Waiting up to %s for another instance to finish migrating", m.lockTimeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			conn.Close()
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	return func() {
+		// A fresh context so that the lock is released even when ctx
+		// has been cancelled
+		ctx, cancel := context.WithTimeout(context.Background(), unlockTimeout)
+		defer cancel()
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, lockKey); err != nil {
+			log.Printf("Error releasing migration lock: %v", err)
+		}
+		conn.Close()
+	}, nil
+}
+
+// verifyApplied checks that every migration has been applied, for an
+// instance that gave up waiting for the migration lock
+func (m *Manager) verifyApplied(ctx context.Context, migrations []Migration) error {
+	applied, err := m.getAppliedMigrations(ctx)
+	if err != nil {
+		return fmt.Errorf("%w after %s and applied migrations could not be checked: %v", errLockTimeout, m.lockTimeout, err)
+	}
+
+	var pending []int
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration.Version)
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w after %s with migrations %v still pending", errLockTimeout, m.lockTimeout, pending)
+	}
+
+	log.Printf("Migration lock still held after %s but all migrations are applied, continuing", m.lockTimeout)
+	return nil
+}
+
 // ensureMigrationTable creates the migration tracking table if needed
 func (m *Manager) ensureMigrationTable(ctx context.Context) error {
 	query := `
@@ -233,6 +395,12 @@ func (m *Manager) applyMigration(ctx context.Context, migration Migration) error
 		}
 	}()
 
+	// Migrations may rewrite large tables, so the pool's statement timeout
+	// does not apply to them
+	if _, err := tx.ExecContext(ctx, `SET LOCAL statement_timeout = 0`); err != nil {
+		return err
+	}
+
 	// Split and execute statements
 	statements := m.splitStatements(migration.Up)
 	for i, stmt := range statements {
@@ -242,13 +410,41 @@ func (m *Manager) applyMigration(ctx context.Context, migration Migration) error
 		}
 	}
 
-	// Record the migration
-	if _, err := tx.ExecContext(ctx, `
+	// Record the migration. Should the version already be recorded, the
+	// statements above were applied elsewhere first and this transaction
+	// is rolled back rather than applying them twice.
+	result, err := tx.ExecContext(ctx, `
 		INSERT INTO schema_migrations (version, description)
 		VALUES ($1, $2)
-	`, migration.Version, migration.Description); err != nil {
+		ON CONFLICT (version) DO NOTHING
+	`, migration.Version, migration.Description)
+	if err != nil {
 		return err
 	}
+	recorded, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if recorded == 0 {
+		return m.verifyRecorded(ctx, migration)
+	}
 
 	return tx.Commit()
 }
+
+// verifyRecorded checks that an already recorded version is the same
+// migration, not a different one that happens to share its number
+func (m *Manager) verifyRecorded(ctx context.Context, migration Migration) error {
+	var description string
+	if err := m.db.QueryRowContext(ctx, `
+		SELECT description FROM schema_migrations WHERE version = $1
+	`, migration.Version).Scan(&description); err != nil {
+		return fmt.Errorf("error checking recorded migration: %w", err)
+	}
+	if description != migration.Description {
+		return fmt.Errorf("version %d is recorded as %q, not %q", migration.Version, description, migration.Description)
+	}
+
+	log.Printf("Migration %d was applied by another instance", migration.Version)
+	return nil
+}
@@ -0,0 +1,104 @@
+package migrations_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/migrations"
+	"github.com/wrale/wrale-signage/internal/wsignd/testutil"
+)
+
+func TestConcurrentApplyMigrations(t *testing.T) {
+	db, cleanup := testutil.CreateTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	all, err := migrations.NewManager(db, 0).LoadMigrations()
+	require.NoError(t, err)
+
+	// Two replicas starting at the same time
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = migrations.NewManager(db, time.Minute).ApplyMigrations(ctx)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+
+	var recorded, versions int
+	require.NoError(t, db.QueryRowContext(ctx,
+		`SELECT COUNT(*), COUNT(DISTINCT version) FROM schema_migrations`).Scan(&recorded, &versions))
+	assert.Equal(t, len(all), recorded)
+	assert.Equal(t, len(all), versions)
+}
+
+func TestApplyMigrationsLockTimeout(t *testing.T) {
+	db, cleanup := testutil.CreateTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	// Another instance holds the lock for the whole test
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrations.LockKey)
+	require.NoError(t, err)
+
+	manager := migrations.NewManager(db, time.Second)
+
+	// Nothing has been applied, so waiting out the lock is an error
+	err = manager.ApplyMigrations(ctx)
+	assert.ErrorContains(t, err, "timed out waiting for migration lock")
+
+	// Once the other instance has applied everything, carrying on is safe
+	_, err = conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrations.LockKey)
+	require.NoError(t, err)
+	require.NoError(t, migrations.NewManager(db, 0).ApplyMigrations(ctx))
+	_, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrations.LockKey)
+	require.NoError(t, err)
+
+	assert.NoError(t, manager.ApplyMigrations(ctx))
+}
+
+func TestStatus(t *testing.T) {
+	db, cleanup := testutil.CreateTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	manager := migrations.NewManager(db, 0)
+
+	// An empty database has every migration pending
+	statuses, err := manager.Status(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, statuses)
+	for _, s := range statuses {
+		assert.Nil(t, s.AppliedAt, "migration %d", s.Version)
+	}
+
+	require.NoError(t, manager.ApplyMigrations(ctx))
+	_, err = db.ExecContext(ctx, `INSERT INTO schema_migrations (version, description) VALUES (999, 'from a newer release')`)
+	require.NoError(t, err)
+
+	statuses, err = manager.Status(ctx)
+	require.NoError(t, err)
+	last := statuses[len(statuses)-1]
+	assert.Equal(t, 999, last.Version)
+	assert.True(t, last.Unknown)
+	for _, s := range statuses[:len(statuses)-1] {
+		assert.NotNil(t, s.AppliedAt, "migration %d", s.Version)
+		assert.False(t, s.Unknown)
+	}
+}
@@ -0,0 +1,43 @@
+package operator
+
+import (
+	"context"
+	"net/http"
+)
+
+type contextKey int
+
+const principalKey contextKey = iota
+
+// WithPrincipal returns a context carrying the authenticated operator
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// PrincipalFromContext returns the authenticated operator, if any
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey).(*Principal)
+	return p, ok
+}
+
+// Name returns the name of the operator in ctx for audit logs, or
+// "anonymous" when the request was not authenticated
+func Name(ctx context.Context) string {
+	if p, ok := PrincipalFromContext(ctx); ok {
+		return p.OperatorName
+	}
+	return "anonymous"
+}
+
+// Guard builds middleware that admits only requests authorised for a scope
+type Guard func(scope Scope) func(http.Handler) http.Handler
+
+// Require returns the guard's middleware for scope. A nil guard admits
+// every request, which keeps routers usable without authentication in
+// tests.
+func (g Guard) Require(scope Scope) func(http.Handler) http.Handler {
+	if g == nil {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return g(scope)
+}
@@ -0,0 +1,111 @@
+// Package memory implements operator persistence in process memory for
+// demo mode and tests
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+)
+
+// Repository implements operator.Repository in memory
+type Repository struct {
+	mu        sync.RWMutex
+	operators map[uuid.UUID]operator.Operator
+	tokens    map[uuid.UUID]operator.APIToken
+}
+
+// NewRepository creates an empty in-memory operator repository
+func NewRepository() operator.Repository {
+	return &Repository{
+		operators: make(map[uuid.UUID]operator.Operator),
+		tokens:    make(map[uuid.UUID]operator.APIToken),
+	}
+}
+
+// CreateOperator stores a new operator
+func (r *Repository) CreateOperator(ctx context.Context, o *operator.Operator) error {
+	const op = "OperatorRepository.CreateOperator"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.operators {
+		if existing.Name == o.Name {
+			return werrors.NewError("CONFLICT", "resource already exists", op, werrors.ErrConflict)
+		}
+	}
+	o.ID = uuid.New()
+	o.CreatedAt = time.Now()
+	r.operators[o.ID] = *o
+	return nil
+}
+
+// GetOperator retrieves an operator by ID
+func (r *Repository) GetOperator(ctx context.Context, id uuid.UUID) (*operator.Operator, error) {
+	const op = "OperatorRepository.GetOperator"
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	o, ok := r.operators[id]
+	if !ok {
+		return nil, werrors.NewError("NOT_FOUND", "resource not found", op, werrors.ErrNotFound)
+	}
+	return &o, nil
+}
+
+// GetOperatorByName retrieves an operator by name
+func (r *Repository) GetOperatorByName(ctx context.Context, name string) (*operator.Operator, error) {
+	const op = "OperatorRepository.GetOperatorByName"
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, o := range r.operators {
+		if o.Name == name {
+			return &o, nil
+		}
+	}
+	return nil, werrors.NewError("NOT_FOUND", "resource not found", op, werrors.ErrNotFound)
+}
+
+// CreateToken stores a new API token
+func (r *Repository) CreateToken(ctx context.Context, t *operator.APIToken) error {
+	const op = "OperatorRepository.CreateToken"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.operators[t.OperatorID]; !ok {
+		return werrors.NewError("NOT_FOUND", "resource not found", op, werrors.ErrNotFound)
+	}
+	if _, exists := r.tokens[t.ID]; exists {
+		return werrors.NewError("CONFLICT", "resource already exists", op, werrors.ErrConflict)
+	}
+	t.CreatedAt = time.Now()
+	stored := *t
+	stored.Scopes = append([]operator.Scope(nil), t.Scopes...)
+	r.tokens[t.ID] = stored
+	return nil
+}
+
+// GetToken retrieves an API token by ID
+func (r *Repository) GetToken(ctx context.Context, id uuid.UUID) (*operator.APIToken, error) {
+	const op = "OperatorRepository.GetToken"
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.tokens[id]
+	if !ok {
+		return nil, werrors.NewError("NOT_FOUND", "resource not found", op, werrors.ErrNotFound)
+	}
+	t.Scopes = append([]operator.Scope(nil), t.Scopes...)
+	return &t, nil
+}
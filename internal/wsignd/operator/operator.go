@@ -0,0 +1,143 @@
+// Package operator manages the people and tools that administer the
+// server. Operators authenticate with API tokens carrying scopes, which are
+// kept apart from the access tokens displays use.
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidToken indicates a malformed, unknown, expired or wrongly signed
+// API token
+var ErrInvalidToken = errors.New("invalid API token")
+
+// Scope names a group of operations an API token may perform
+type Scope string
+
+const (
+	// ScopeDisplaysRead allows viewing displays and their state
+	ScopeDisplaysRead Scope = "displays:read"
+	// ScopeDisplaysWrite allows registering, changing and controlling displays
+	ScopeDisplaysWrite Scope = "displays:write"
+	// ScopeDisplaysApprove allows approving activated displays where the
+	// approval workflow is enabled
+	ScopeDisplaysApprove Scope = "displays:approve"
+	// ScopeContentRead allows viewing content sources and assignments
+	ScopeContentRead Scope = "content:read"
+	// ScopeContentWrite allows changing content sources and assignments
+	ScopeContentWrite Scope = "content:write"
+	// ScopeAdmin allows everything, including session management
+	ScopeAdmin Scope = "admin"
+)
+
+// AllScopes lists every scope a token may be given
+var AllScopes = []Scope{ScopeDisplaysRead, ScopeDisplaysWrite, ScopeDisplaysApprove, ScopeContentRead, ScopeContentWrite, ScopeAdmin}
+
+// ParseScopes parses a comma separated scope list such as
+// "displays:read,content:write"
+func ParseScopes(s string) ([]Scope, error) {
+	var scopes []Scope
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		scope := Scope(part)
+		known := false
+		for _, k := range AllScopes {
+			if scope == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown scope %q", part)
+		}
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+	return scopes, nil
+}
+
+// Operator is a person or tool allowed to administer the server
+type Operator struct {
+	// ID uniquely identifies the operator
+	ID uuid.UUID
+	// Name is the operator's unique name, such as an email address
+	Name string
+	// CreatedAt is when the operator was added
+	CreatedAt time.Time
+}
+
+// APIToken is an operator credential. Only a hash of its secret is stored.
+type APIToken struct {
+	// ID identifies the token and is part of the token string
+	ID uuid.UUID
+	// OperatorID is the operator the token acts for
+	OperatorID uuid.UUID
+	// Name describes what the token is for
+	Name string
+	// SecretHash is the hex SHA-256 of the token's secret
+	SecretHash string
+	// Scopes are the operations the token allows
+	Scopes []Scope
+	// CreatedAt is when the token was created
+	CreatedAt time.Time
+	// ExpiresAt is when the token stops working; nil tokens do not expire
+	ExpiresAt *time.Time
+}
+
+// Principal is an authenticated operator and the token it presented
+type Principal struct {
+	// OperatorID identifies the operator
+	OperatorID uuid.UUID
+	// OperatorName is the operator's name, recorded in audit logs
+	OperatorName string
+	// TokenID identifies the token used
+	TokenID uuid.UUID
+	// Scopes are the scopes of the token used
+	Scopes []Scope
+}
+
+// HasScope reports whether the principal may perform operations in scope.
+// The admin scope allows everything.
+func (p *Principal) HasScope(scope Scope) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Repository defines persistence for operators and their tokens
+type Repository interface {
+	// CreateOperator stores a new operator, setting its ID and CreatedAt
+	CreateOperator(ctx context.Context, o *Operator) error
+	// GetOperator retrieves an operator by ID
+	GetOperator(ctx context.Context, id uuid.UUID) (*Operator, error)
+	// GetOperatorByName retrieves an operator by name
+	GetOperatorByName(ctx context.Context, name string) (*Operator, error)
+	// CreateToken stores a new API token, setting its CreatedAt
+	CreateToken(ctx context.Context, t *APIToken) error
+	// GetToken retrieves an API token by ID
+	GetToken(ctx context.Context, id uuid.UUID) (*APIToken, error)
+}
+
+// Service defines operator and API token operations
+type Service interface {
+	// CreateToken issues an API token for the named operator, adding the
+	// operator if it does not exist yet. A zero ttl creates a token that
+	// does not expire. The token string is only available from this call.
+	CreateToken(ctx context.Context, operatorName, tokenName string, scopes []Scope, ttl time.Duration) (string, *APIToken, error)
+	// Authenticate checks an API token string and returns who it belongs to
+	Authenticate(ctx context.Context, raw string) (*Principal, error)
+}
@@ -0,0 +1,134 @@
+// Package postgres implements the operator repository using PostgreSQL
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/database"
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+)
+
+// Repository implements operator.Repository using PostgreSQL
+type Repository struct {
+	db *sql.DB
+}
+
+// NewRepository creates a new PostgreSQL operator repository
+func NewRepository(db *sql.DB) operator.Repository {
+	return &Repository{db: db}
+}
+
+// CreateOperator stores a new operator
+func (r *Repository) CreateOperator(ctx context.Context, o *operator.Operator) error {
+	const op = "OperatorRepository.CreateOperator"
+
+	o.ID = uuid.New()
+	o.CreatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO operators (id, name, created_at) VALUES ($1, $2, $3)
+	`, o.ID, o.Name, o.CreatedAt)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+
+	return nil
+}
+
+// GetOperator retrieves an operator by ID
+func (r *Repository) GetOperator(ctx context.Context, id uuid.UUID) (*operator.Operator, error) {
+	const op = "OperatorRepository.GetOperator"
+
+	var o operator.Operator
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, name, created_at FROM operators WHERE id = $1
+	`, id).Scan(&o.ID, &o.Name, &o.CreatedAt)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	return &o, nil
+}
+
+// GetOperatorByName retrieves an operator by name
+func (r *Repository) GetOperatorByName(ctx context.Context, name string) (*operator.Operator, error) {
+	const op = "OperatorRepository.GetOperatorByName"
+
+	var o operator.Operator
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, name, created_at FROM operators WHERE name = $1
+	`, name).Scan(&o.ID, &o.Name, &o.CreatedAt)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	return &o, nil
+}
+
+// CreateToken stores a new API token
+func (r *Repository) CreateToken(ctx context.Context, t *operator.APIToken) error {
+	const op = "OperatorRepository.CreateToken"
+
+	t.CreatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO api_tokens (
+			id, operator_id, name, secret_hash, scopes, created_at, expires_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`,
+		t.ID,
+		t.OperatorID,
+		t.Name,
+		t.SecretHash,
+		pq.Array(scopeStrings(t.Scopes)),
+		t.CreatedAt,
+		t.ExpiresAt,
+	)
+	if err != nil {
+		return database.MapError(err, op)
+	}
+
+	return nil
+}
+
+// GetToken retrieves an API token by ID
+func (r *Repository) GetToken(ctx context.Context, id uuid.UUID) (*operator.APIToken, error) {
+	const op = "OperatorRepository.GetToken"
+
+	var (
+		t      operator.APIToken
+		scopes []string
+	)
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, operator_id, name, secret_hash, scopes, created_at, expires_at
+		FROM api_tokens
+		WHERE id = $1
+	`, id).Scan(
+		&t.ID,
+		&t.OperatorID,
+		&t.Name,
+		&t.SecretHash,
+		pq.Array(&scopes),
+		&t.CreatedAt,
+		&t.ExpiresAt,
+	)
+	if err != nil {
+		return nil, database.MapError(err, op)
+	}
+
+	for _, s := range scopes {
+		t.Scopes = append(t.Scopes, operator.Scope(s))
+	}
+	return &t, nil
+}
+
+func scopeStrings(scopes []operator.Scope) []string {
+	out := make([]string, len(scopes))
+	for i, s := range scopes {
+		out[i] = string(s)
+	}
+	return out
+}
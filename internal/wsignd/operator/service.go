@@ -0,0 +1,169 @@
+package operator
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/clock"
+	"github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// TokenPrefix starts every operator API token, which tells them apart from
+// display JWTs at a glance
+const TokenPrefix = "wsop_"
+
+// secretBytes is the amount of randomness in a token secret
+const secretBytes = 32
+
+// service implements Service on top of a Repository
+type service struct {
+	repo  Repository
+	clock clock.Clock
+}
+
+// Option configures an operator service
+type Option func(*service)
+
+// WithClock sets the clock API tokens are issued and checked against
+func WithClock(c clock.Clock) Option {
+	return func(s *service) {
+		s.clock = c
+	}
+}
+
+// NewService creates an operator service. It reads the system clock unless
+// given another with WithClock.
+func NewService(repo Repository, opts ...Option) Service {
+	s := &service{repo: repo, clock: clock.Real()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// IsAPIToken reports whether raw has the shape of an operator API token
+func IsAPIToken(raw string) bool {
+	return strings.HasPrefix(raw, TokenPrefix)
+}
+
+// CreateToken issues an API token, adding the operator when needed.
+func (s *service) CreateToken(ctx context.Context, operatorName, tokenName string, scopes []Scope, ttl time.Duration) (string, *APIToken, error) {
+	const op = "OperatorService.CreateToken"
+
+	if operatorName == "" {
+		return "", nil, errors.NewError("INVALID_INPUT", "operator name is required", op, errors.ErrInvalidInput)
+	}
+	if len(scopes) == 0 {
+		return "", nil, errors.NewError("INVALID_INPUT", "at least one scope is required", op, errors.ErrInvalidInput)
+	}
+
+	operator, err := s.repo.GetOperatorByName(ctx, operatorName)
+	if errors.IsNotFound(err) {
+		operator = &Operator{Name: operatorName}
+		err = s.repo.CreateOperator(ctx, operator)
+	}
+	if err != nil {
+		return "", nil, errors.NewError("OPERATOR_FAILED", "Failed to find or create operator", op, err)
+	}
+
+	secret := make([]byte, secretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, errors.NewError("GENERATE_FAILED", "Failed to generate token secret", op, err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+
+	token := &APIToken{
+		ID:         uuid.New(),
+		OperatorID: operator.ID,
+		Name:       tokenName,
+		SecretHash: hashSecret(encoded),
+		Scopes:     scopes,
+	}
+	if ttl > 0 {
+		expires := s.clock.Now().Add(ttl)
+		token.ExpiresAt = &expires
+	}
+
+	if err := s.repo.CreateToken(ctx, token); err != nil {
+		return "", nil, errors.NewError("SAVE_FAILED", "Failed to save token", op, err)
+	}
+
+	raw := TokenPrefix + hex.EncodeToString(token.ID[:]) + "_" + encoded
+	return raw, token, nil
+}
+
+// Authenticate checks an API token's secret and expiry.
+func (s *service) Authenticate(ctx context.Context, raw string) (*Principal, error) {
+	const op = "OperatorService.Authenticate"
+
+	invalid := func(reason string) error {
+		return errors.NewError("INVALID_TOKEN", reason, op, fmt.Errorf("%w: %w", errors.ErrUnauthorized, ErrInvalidToken))
+	}
+
+	id, secret, ok := parseToken(raw)
+	if !ok {
+		return nil, invalid("malformed API token")
+	}
+
+	token, err := s.repo.GetToken(ctx, id)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, invalid("unknown API token")
+		}
+		return nil, errors.NewError("LOOKUP_FAILED", "Failed to look up token", op, err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(token.SecretHash)) != 1 {
+		return nil, invalid("unknown API token")
+	}
+	if token.ExpiresAt != nil && !s.clock.Now().Before(*token.ExpiresAt) {
+		return nil, invalid("API token has expired")
+	}
+
+	operator, err := s.repo.GetOperator(ctx, token.OperatorID)
+	if err != nil {
+		return nil, errors.NewError("LOOKUP_FAILED", "Failed to look up operator", op, err)
+	}
+
+	return &Principal{
+		OperatorID:   operator.ID,
+		OperatorName: operator.Name,
+		TokenID:      token.ID,
+		Scopes:       token.Scopes,
+	}, nil
+}
+
+// parseToken splits a token string into its ID and secret
+func parseToken(raw string) (uuid.UUID, string, bool) {
+	rest, ok := strings.CutPrefix(raw, TokenPrefix)
+	if !ok {
+		return uuid.Nil, "", false
+	}
+	idHex, secret, ok := strings.Cut(rest, "_")
+	if !ok || secret == "" {
+		return uuid.Nil, "", false
+	}
+	b, err := hex.DecodeString(idHex)
+	if err != nil {
+		return uuid.Nil, "", false
+	}
+	id, err := uuid.FromBytes(b)
+	if err != nil {
+		return uuid.Nil, "", false
+	}
+	return id, secret, true
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,101 @@
+package operator_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/clock"
+	"github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+	"github.com/wrale/wrale-signage/internal/wsignd/operator/memory"
+)
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewRepository()
+	now := clock.NewFake(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC))
+	svc := operator.NewService(repo, operator.WithClock(now))
+
+	t.Run("issued tokens authenticate as their operator", func(t *testing.T) {
+		raw, token, err := svc.CreateToken(ctx, "alice", "laptop", []operator.Scope{operator.ScopeDisplaysRead}, 0)
+		require.NoError(t, err)
+		assert.True(t, operator.IsAPIToken(raw))
+		assert.NotContains(t, token.SecretHash, strings.TrimPrefix(raw, operator.TokenPrefix))
+		assert.Nil(t, token.ExpiresAt)
+
+		p, err := svc.Authenticate(ctx, raw)
+		require.NoError(t, err)
+		assert.Equal(t, "alice", p.OperatorName)
+		assert.Equal(t, token.ID, p.TokenID)
+		assert.True(t, p.HasScope(operator.ScopeDisplaysRead))
+		assert.False(t, p.HasScope(operator.ScopeDisplaysWrite))
+	})
+
+	t.Run("tokens for the same operator share it", func(t *testing.T) {
+		_, first, err := svc.CreateToken(ctx, "bob", "", []operator.Scope{operator.ScopeContentRead}, 0)
+		require.NoError(t, err)
+		_, second, err := svc.CreateToken(ctx, "bob", "", []operator.Scope{operator.ScopeAdmin}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, first.OperatorID, second.OperatorID)
+	})
+
+	t.Run("rejects bad tokens", func(t *testing.T) {
+		raw, _, err := svc.CreateToken(ctx, "carol", "", []operator.Scope{operator.ScopeAdmin}, 0)
+		require.NoError(t, err)
+
+		for name, bad := range map[string]string{
+			"empty":        "",
+			"not prefixed": "abc",
+			"malformed":    operator.TokenPrefix + "nothex_secret",
+			"wrong secret": raw[:strings.LastIndex(raw, "_")] + "_guess",
+		} {
+			_, err := svc.Authenticate(ctx, bad)
+			assert.True(t, errors.IsUnauthorized(err), name)
+		}
+	})
+
+	t.Run("rejects expired tokens", func(t *testing.T) {
+		raw, token, err := svc.CreateToken(ctx, "dave", "", []operator.Scope{operator.ScopeAdmin}, time.Hour)
+		require.NoError(t, err)
+		require.NotNil(t, token.ExpiresAt)
+		assert.Equal(t, now.Now().Add(time.Hour), *token.ExpiresAt)
+
+		now.Advance(time.Hour - time.Nanosecond)
+		_, err = svc.Authenticate(ctx, raw)
+		assert.NoError(t, err, "valid until the instant it expires")
+
+		now.Advance(time.Nanosecond)
+		_, err = svc.Authenticate(ctx, raw)
+		assert.True(t, errors.IsUnauthorized(err))
+	})
+
+	t.Run("requires an operator and scopes", func(t *testing.T) {
+		_, _, err := svc.CreateToken(ctx, "", "", []operator.Scope{operator.ScopeAdmin}, 0)
+		assert.True(t, errors.IsInvalidInput(err))
+		_, _, err = svc.CreateToken(ctx, "erin", "", nil, 0)
+		assert.True(t, errors.IsInvalidInput(err))
+	})
+}
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := operator.ParseScopes("displays:read, content:write")
+	require.NoError(t, err)
+	assert.Equal(t, []operator.Scope{operator.ScopeDisplaysRead, operator.ScopeContentWrite}, scopes)
+
+	_, err = operator.ParseScopes("displays:delete")
+	assert.Error(t, err)
+	_, err = operator.ParseScopes(" , ")
+	assert.Error(t, err)
+}
+
+func TestHasScope(t *testing.T) {
+	admin := &operator.Principal{Scopes: []operator.Scope{operator.ScopeAdmin}}
+	for _, s := range operator.AllScopes {
+		assert.True(t, admin.HasScope(s), s)
+	}
+}
@@ -0,0 +1,211 @@
+// Package overload sheds load when the server has more work in flight than
+// it can serve. Requests are grouped into classes, each with its own cap on
+// concurrent work; a request that cannot get a slot within a short wait is
+// rejected with 503 rather than queued indefinitely.
+package overload
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+)
+
+// ErrorCode is returned in the error body of shed requests
+const ErrorCode = "server_busy"
+
+// Class groups requests that share an in-flight cap
+type Class string
+
+// Request classes
+const (
+	// ClassCheap covers short requests such as heartbeats and event reports
+	ClassCheap Class = "cheap"
+	// ClassExpensive covers requests that aggregate stored data, such as
+	// metrics, and are given a much smaller cap
+	ClassExpensive Class = "expensive"
+	// ClassWebSocket covers WebSocket upgrades. The slot is held for the
+	// lifetime of the connection, so requests of this class never queue.
+	ClassWebSocket Class = "websocket"
+	// ClassExempt bypasses shedding entirely
+	ClassExempt Class = "exempt"
+)
+
+// Classifier assigns a request to a class
+type Classifier func(r *http.Request) Class
+
+// Stats is a snapshot of one class's counters
+type Stats struct {
+	Capacity int    `json:"capacity"`
+	InFlight int64  `json:"inFlight"`
+	Waiting  int64  `json:"waiting"`
+	Shed     uint64 `json:"shed"`
+}
+
+// limiter is a counting semaphore with a bounded wait
+type limiter struct {
+	slots   chan struct{}
+	maxWait time.Duration
+	// retryAfter is how long shed requests are told to wait, at least a
+	// second since Retry-After counts whole seconds
+	retryAfter time.Duration
+	inFlight   atomic.Int64
+	waiting    atomic.Int64
+	shed       atomic.Uint64
+}
+
+func newLimiter(capacity int, maxWait, retryAfter time.Duration) *limiter {
+	return &limiter{
+		slots:      make(chan struct{}, capacity),
+		maxWait:    maxWait,
+		retryAfter: retryAfter,
+	}
+}
+
+// acquire takes a slot, waiting at most maxWait for one to free up. Waiting
+// ends early when the request is cancelled or shutdown is closed. It reports
+// false, and counts the request as shed, when no slot was taken.
+func (l *limiter) acquire(ctx context.Context, shutdown <-chan struct{}) bool {
+	select {
+	case l.slots <- struct{}{}:
+		l.inFlight.Add(1)
+		return true
+	default:
+	}
+
+	if l.maxWait > 0 {
+		timer := time.NewTimer(l.maxWait)
+		defer timer.Stop()
+
+		l.waiting.Add(1)
+		defer l.waiting.Add(-1)
+
+		select {
+		case l.slots <- struct{}{}:
+			l.inFlight.Add(1)
+			return true
+		case <-timer.C:
+		case <-ctx.Done():
+		case <-shutdown:
+		}
+	}
+
+	l.shed.Add(1)
+	return false
+}
+
+func (l *limiter) release() {
+	l.inFlight.Add(-1)
+	<-l.slots
+}
+
+func (l *limiter) stats() Stats {
+	return Stats{
+		Capacity: cap(l.slots),
+		InFlight: l.inFlight.Load(),
+		Waiting:  l.waiting.Load(),
+		Shed:     l.shed.Load(),
+	}
+}
+
+// Shedder caps in-flight requests per class
+type Shedder struct {
+	classify Classifier
+	limiters map[Class]*limiter
+	shutdown <-chan struct{}
+	logger   *slog.Logger
+}
+
+// New creates a shedder from the server's overload settings. Requests the
+// classifier leaves unknown are treated as cheap. Once ctx is done, requests
+// waiting for a slot are shed at once instead of holding up shutdown.
+//
+// Shed requests are told to retry once the queue has had a chance to drain,
+// except WebSocket upgrades: a connection slot only frees up when a display
+// disconnects, so refused displays are told to wait WebSocketRetryAfter and
+// are free to try another server meanwhile.
+func New(ctx context.Context, cfg config.OverloadConfig, classify Classifier, logger *slog.Logger) *Shedder {
+	return &Shedder{
+		classify: classify,
+		shutdown: ctx.Done(),
+		limiters: map[Class]*limiter{
+			ClassCheap:     newLimiter(cfg.MaxInFlight, cfg.MaxQueueWait, cfg.MaxQueueWait),
+			ClassExpensive: newLimiter(cfg.MaxExpensiveInFlight, cfg.MaxQueueWait, cfg.MaxQueueWait),
+			ClassWebSocket: newLimiter(cfg.MaxWebSockets, 0, cfg.WebSocketRetryAfter),
+		},
+		logger: logger,
+	}
+}
+
+// Middleware sheds requests whose class is saturated
+func (s *Shedder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class := s.classify(r)
+		if class == ClassExempt {
+			next.ServeHTTP(w, r)
+			return
+		}
+		l, ok := s.limiters[class]
+		if !ok {
+			class, l = ClassCheap, s.limiters[ClassCheap]
+		}
+
+		if !l.acquire(r.Context(), s.shutdown) {
+			s.logger.Warn("shedding request",
+				"class", class,
+				"method", r.Method,
+				"path", r.URL.Path,
+			)
+			s.reject(w, l)
+			return
+		}
+		defer l.release()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// reject writes a 503 asking the client to come back after the limiter's
+// retry delay
+func (s *Shedder) reject(w http.ResponseWriter, l *limiter) {
+	retryAfter := int(math.Ceil(l.retryAfter.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	w.Header().Set("Retry-After", fmt.Sprint(retryAfter))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if err := json.NewEncoder(w).Encode(v1alpha1.Error{
+		Code:    ErrorCode,
+		Message: "server is busy, retry later",
+	}); err != nil {
+		s.logger.Error("failed to write overload response", "error", err)
+	}
+}
+
+// Stats returns the current counters of every class
+func (s *Shedder) Stats() map[Class]Stats {
+	out := make(map[Class]Stats, len(s.limiters))
+	for class, l := range s.limiters {
+		out[class] = l.stats()
+	}
+	return out
+}
+
+// StatsHandler serves the counters as JSON for metrics scrapers
+func (s *Shedder) StatsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(s.Stats()); err != nil {
+			s.logger.Error("failed to write overload stats", "error", err)
+		}
+	}
+}
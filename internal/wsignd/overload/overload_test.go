@@ -0,0 +1,221 @@
+package overload
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+)
+
+func classifyAll(class Class) Classifier {
+	return func(*http.Request) Class { return class }
+}
+
+func TestShedder_BoundsLatencyUnderLoad(t *testing.T) {
+	const (
+		capacity = 4
+		work     = 20 * time.Millisecond
+		maxWait  = 10 * time.Millisecond
+	)
+	s := New(context.Background(), config.OverloadConfig{
+		MaxInFlight:          capacity,
+		MaxExpensiveInFlight: 1,
+		MaxWebSockets:        1,
+		MaxQueueWait:         maxWait,
+	}, classifyAll(ClassCheap), slog.Default())
+
+	var running, peak atomic.Int64
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(work)
+		running.Add(-1)
+		w.WriteHeader(http.StatusOK)
+	})
+	server := httptest.NewServer(s.Middleware(handler))
+	defer server.Close()
+
+	// Ten times the cap, all at once
+	const requests = capacity * 10
+	var (
+		wg      sync.WaitGroup
+		ok      atomic.Int64
+		shed    atomic.Int64
+		slowest atomic.Int64
+	)
+	start := make(chan struct{})
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			began := time.Now()
+			resp, err := http.Get(server.URL)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer resp.Body.Close()
+			elapsed := int64(time.Since(began))
+			for {
+				s := slowest.Load()
+				if elapsed <= s || slowest.CompareAndSwap(s, elapsed) {
+					break
+				}
+			}
+
+			switch resp.StatusCode {
+			case http.StatusOK:
+				ok.Add(1)
+			case http.StatusServiceUnavailable:
+				shed.Add(1)
+				assert.Equal(t, "1", resp.Header.Get("Retry-After"))
+				var body v1alpha1.Error
+				assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.Equal(t, ErrorCode, body.Code)
+			default:
+				t.Errorf("unexpected status %d", resp.StatusCode)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	assert.LessOrEqual(t, peak.Load(), int64(capacity), "in-flight cap exceeded")
+	assert.GreaterOrEqual(t, ok.Load(), int64(capacity))
+	assert.Positive(t, shed.Load(), "expected requests to be shed")
+	assert.Equal(t, int64(requests), ok.Load()+shed.Load())
+
+	// No request waits much longer than one queue wait plus its own work;
+	// the bound is generous to absorb scheduler noise
+	assert.Less(t, time.Duration(slowest.Load()), 10*(work+maxWait))
+
+	stats := s.Stats()[ClassCheap]
+	assert.Equal(t, capacity, stats.Capacity)
+	assert.Zero(t, stats.InFlight)
+	assert.Equal(t, uint64(shed.Load()), stats.Shed)
+}
+
+func TestShedder_CapsWebSockets(t *testing.T) {
+	s := New(context.Background(), config.OverloadConfig{
+		MaxInFlight:          10,
+		MaxExpensiveInFlight: 10,
+		MaxWebSockets:        1,
+		MaxQueueWait:         time.Second,
+		WebSocketRetryAfter:  30 * time.Second,
+	}, classifyAll(ClassWebSocket), slog.Default())
+
+	release := make(chan struct{})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ws", nil))
+	}()
+	require.Eventually(t, func() bool {
+		return s.Stats()[ClassWebSocket].InFlight == 1
+	}, time.Second, time.Millisecond)
+
+	// Connections never queue, so the second upgrade is refused at once
+	// even though the configured queue wait is long
+	began := time.Now()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ws", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Less(t, time.Since(began), 500*time.Millisecond)
+	assert.Equal(t, "30", rec.Header().Get("Retry-After"), "the display waits for a connection to close")
+
+	close(release)
+	<-done
+	assert.Equal(t, Stats{Capacity: 1, Shed: 1}, s.Stats()[ClassWebSocket])
+}
+
+func TestShedder_ExemptRequestsBypassCaps(t *testing.T) {
+	s := New(context.Background(), config.OverloadConfig{
+		MaxInFlight:          1,
+		MaxExpensiveInFlight: 1,
+		MaxWebSockets:        1,
+	}, classifyAll(ClassExempt), slog.Default())
+
+	rec := httptest.NewRecorder()
+	s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	for _, stats := range s.Stats() {
+		assert.Zero(t, stats.InFlight)
+		assert.Zero(t, stats.Shed)
+	}
+}
+
+func TestShedder_ShutdownReleasesWaiters(t *testing.T) {
+	ctx, beginShutdown := context.WithCancel(context.Background())
+	defer beginShutdown()
+	s := New(ctx, config.OverloadConfig{
+		MaxInFlight:          1,
+		MaxExpensiveInFlight: 1,
+		MaxWebSockets:        1,
+		MaxQueueWait:         time.Minute,
+	}, classifyAll(ClassCheap), slog.Default())
+
+	release := make(chan struct{})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+
+	// Hold the only slot so the next request parks in the queue
+	held := make(chan struct{})
+	go func() {
+		defer close(held)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	require.Eventually(t, func() bool {
+		return s.Stats()[ClassCheap].InFlight == 1
+	}, time.Second, time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	parked := make(chan struct{})
+	go func() {
+		defer close(parked)
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	require.Eventually(t, func() bool {
+		return s.Stats()[ClassCheap].Waiting == 1
+	}, time.Second, time.Millisecond)
+
+	beginShutdown()
+	select {
+	case <-parked:
+	case <-time.After(time.Second):
+		t.Fatal("waiting request was not released by shutdown")
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	var body v1alpha1.Error
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, ErrorCode, body.Code)
+
+	close(release)
+	<-held
+	assert.Equal(t, Stats{Capacity: 1, Shed: 1}, s.Stats()[ClassCheap])
+}
@@ -0,0 +1,102 @@
+// Package properties enforces the limits on the free-form properties of
+// displays and content sources. Properties are stored as JSON alongside
+// their owner and read with every lookup of it, so an oversized value slows
+// every scan that touches the row.
+package properties
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+// maxQuotedKey caps how much of a key is repeated in a violation, since the
+// key itself may be the oversized part
+const maxQuotedKey = 63
+
+// Violation is a way a set of properties exceeds a limit
+type Violation struct {
+	// Key is the property over a limit, or empty when the properties
+	// together are
+	Key string
+	// Message names the size found and the limit
+	Message string
+}
+
+// Violations lists every way props exceeds limits: first the number of
+// properties, then keys and values in key order, then the total size
+func Violations(limits config.PropertyLimits, props map[string]string) []Violation {
+	var out []Violation
+	if limits.MaxCount > 0 && len(props) > limits.MaxCount {
+		out = append(out, Violation{
+			Message: fmt.Sprintf("has %d properties, more than the limit of %d", len(props), limits.MaxCount),
+		})
+	}
+
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if limits.MaxKeyLength > 0 && len(k) > limits.MaxKeyLength {
+			out = append(out, Violation{
+				Key:     k,
+				Message: fmt.Sprintf("key is %d bytes, more than the limit of %d", len(k), limits.MaxKeyLength),
+			})
+		}
+		if v := props[k]; limits.MaxValueLength > 0 && len(v) > limits.MaxValueLength {
+			out = append(out, Violation{
+				Key:     k,
+				Message: fmt.Sprintf("value is %d bytes, more than the limit of %d", len(v), limits.MaxValueLength),
+			})
+		}
+	}
+
+	if limits.MaxTotalSize > 0 {
+		if size := Size(props); size > limits.MaxTotalSize {
+			out = append(out, Violation{
+				Message: fmt.Sprintf("total %d bytes as JSON, more than the limit of %d", size, limits.MaxTotalSize),
+			})
+		}
+	}
+	return out
+}
+
+// Size returns the number of bytes props take encoded as a JSON object, as
+// they are stored
+func Size(props map[string]string) int {
+	if len(props) == 0 {
+		return len("{}")
+	}
+	encoded, err := json.Marshal(props)
+	if err != nil {
+		return 0
+	}
+	return len(encoded)
+}
+
+// Validate records each violation of limits by props on verr. Violations
+// of a single property are reported against field[key], and the others
+// against field.
+func Validate(verr *werrors.ValidationError, limits config.PropertyLimits, field string, props map[string]string) {
+	for _, v := range Violations(limits, props) {
+		name := field
+		if v.Key != "" {
+			name = fmt.Sprintf("%s[%s]", field, QuoteKey(v.Key))
+		}
+		verr.Invalid(name, v.Message)
+	}
+}
+
+// QuoteKey returns key for use in a message, shortened when it is too long
+// to repeat in full
+func QuoteKey(key string) string {
+	if len(key) > maxQuotedKey {
+		return key[:maxQuotedKey] + "..."
+	}
+	return key
+}
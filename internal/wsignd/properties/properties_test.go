@@ -0,0 +1,102 @@
+package properties_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/properties"
+)
+
+// numbered returns n properties with one-byte values
+func numbered(n int) map[string]string {
+	props := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		props[fmt.Sprintf("k%d", i)] = "v"
+	}
+	return props
+}
+
+func TestViolations(t *testing.T) {
+	limits := config.DefaultPropertyLimits()
+
+	tests := []struct {
+		name  string
+		props map[string]string
+		want  []properties.Violation
+	}{
+		{name: "none", props: nil},
+		{name: "count at the limit", props: numbered(64)},
+		{
+			name:  "count over the limit",
+			props: numbered(65),
+			want:  []properties.Violation{{Message: "has 65 properties, more than the limit of 64"}},
+		},
+		{name: "key at the limit", props: map[string]string{strings.Repeat("k", 63): "v"}},
+		{
+			name:  "key over the limit",
+			props: map[string]string{strings.Repeat("k", 64): "v"},
+			want: []properties.Violation{{
+				Key:     strings.Repeat("k", 64),
+				Message: "key is 64 bytes, more than the limit of 63",
+			}},
+		},
+		{name: "value at the limit", props: map[string]string{"note": strings.Repeat("v", 1024)}},
+		{
+			name:  "value over the limit",
+			props: map[string]string{"note": strings.Repeat("v", 1025)},
+			want:  []properties.Violation{{Key: "note", Message: "value is 1025 bytes, more than the limit of 1024"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, properties.Violations(limits, tt.props))
+		})
+	}
+}
+
+func TestViolationsTotalSize(t *testing.T) {
+	// Each value is within its own limit; only the total is over
+	limits := config.PropertyLimits{MaxValueLength: 1024, MaxTotalSize: 2048}
+	props := map[string]string{"a": strings.Repeat("v", 1000), "b": strings.Repeat("v", 1000), "c": ""}
+	// {"a":"...","b":"...","c":""} is 2000 bytes of values and 22 of JSON
+	require.Equal(t, 2022, properties.Size(props))
+
+	props["c"] = strings.Repeat("v", 26)
+	require.Equal(t, 2048, properties.Size(props))
+	assert.Empty(t, properties.Violations(limits, props), "exactly at the limit")
+
+	props["c"] += "v"
+	assert.Equal(t, []properties.Violation{{Message: "total 2049 bytes as JSON, more than the limit of 2048"}},
+		properties.Violations(limits, props))
+
+	assert.Equal(t, 2, properties.Size(nil))
+}
+
+func TestViolationsUnlimited(t *testing.T) {
+	huge := map[string]string{strings.Repeat("k", 100): strings.Repeat("v", 100000)}
+	assert.Empty(t, properties.Violations(config.PropertyLimits{}, huge))
+}
+
+func TestValidate(t *testing.T) {
+	verr := &werrors.ValidationError{}
+	long := strings.Repeat("x", 100)
+	properties.Validate(verr, config.DefaultPropertyLimits(), "spec.properties", map[string]string{
+		long:   "v",
+		"blob": strings.Repeat("v", 2*1024*1024),
+	})
+
+	require.Len(t, verr.Fields, 3)
+	assert.Equal(t, "spec.properties[blob]", verr.Fields[0].Field)
+	assert.Equal(t, v1alpha1.FieldInvalid, verr.Fields[0].Code)
+	assert.Equal(t, "value is 2097152 bytes, more than the limit of 1024", verr.Fields[0].Message)
+	assert.Equal(t, "spec.properties["+long[:63]+"...]", verr.Fields[1].Field, "long keys are shortened")
+	assert.Equal(t, "spec.properties", verr.Fields[2].Field)
+	assert.Contains(t, verr.Fields[2].Message, "more than the limit of 16384")
+}
@@ -0,0 +1,252 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/clock"
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+	"github.com/wrale/wrale-signage/internal/wsignd/health"
+)
+
+// BreakerState is the state of a Breaker's circuit
+type BreakerState string
+
+// Circuit states
+const (
+	// BreakerClosed passes every call to the store
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen fails every call fast without reaching the store
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a single probe call through to decide whether
+	// the store has recovered
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// BreakerStats is a snapshot of a Breaker's state and counters
+type BreakerStats struct {
+	State BreakerState `json:"state"`
+	// Opened counts how often the circuit has opened
+	Opened uint64 `json:"opened"`
+	// FailedOpen counts requests allowed without a limit check because the
+	// store could not be reached
+	FailedOpen uint64 `json:"failedOpen"`
+	// FailedClosed counts requests denied for the same reason
+	FailedClosed uint64 `json:"failedClosed"`
+}
+
+// Breaker guards a limiter backed by a shared store. Rate limiting is not
+// worth stalling requests for, so once the store keeps failing the circuit
+// opens and calls fail fast for a cool-down period instead of each waiting
+// on the store's timeout. While the store is unavailable requests are
+// allowed, except for limit types configured to fail closed, which are
+// denied. Errors from the store are never returned to callers.
+type Breaker struct {
+	next       Service
+	cfg        config.BreakerConfig
+	failClosed map[string]bool
+	logger     *slog.Logger
+	clock      clock.Clock
+
+	mu          sync.Mutex
+	state       BreakerState
+	openedAt    time.Time
+	probing     bool
+	consecutive int
+	windowStart time.Time
+	calls       int
+	failures    int
+	stats       BreakerStats
+}
+
+// NewBreaker wraps next in a circuit breaker. It reads the system clock
+// unless given another with WithClock.
+func NewBreaker(next Service, cfg config.BreakerConfig, logger *slog.Logger, opts ...Option) *Breaker {
+	failClosed := make(map[string]bool, len(cfg.FailClosed))
+	for _, limitType := range cfg.FailClosed {
+		failClosed[limitType] = true
+	}
+	return &Breaker{
+		next:       next,
+		cfg:        cfg,
+		failClosed: failClosed,
+		logger:     logger,
+		clock:      newOptions(opts).clock,
+		state:      BreakerClosed,
+	}
+}
+
+func (b *Breaker) RegisterLimit(limitType string, limit Limit) {
+	b.next.RegisterLimit(limitType, limit)
+}
+
+func (b *Breaker) GetLimit(limitType string) Limit {
+	return b.next.GetLimit(limitType)
+}
+
+func (b *Breaker) Allow(ctx context.Context, key LimitKey) (*LimitStatus, error) {
+	if !b.acquire() {
+		return b.fallback(key)
+	}
+
+	status, err := b.next.Allow(ctx, key)
+	if err != nil && !errors.Is(err, ErrLimitExceeded) {
+		b.record(err)
+		return b.fallback(key)
+	}
+	b.record(nil)
+	return status, err
+}
+
+// Peek reports the state of key's bucket from the limiter behind the
+// breaker. It is only for diagnostics, so it neither waits on an open
+// circuit nor counts towards closing it.
+func (b *Breaker) Peek(ctx context.Context, key LimitKey) (*LimitStatus, error) {
+	inspector, ok := b.next.(Inspector)
+	if !ok {
+		return nil, ErrPeekUnsupported
+	}
+	if b.Stats().State == BreakerOpen {
+		return nil, fmt.Errorf("rate limit store circuit is open")
+	}
+	return inspector.Peek(ctx, key)
+}
+
+// acquire reports whether a call may go to the store
+func (b *Breaker) acquire() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && b.clock.Now().Sub(b.openedAt) >= b.cfg.Cooldown {
+		b.state = BreakerHalfOpen
+		b.probing = false
+		b.logger.Info("rate limit store circuit half-open, probing")
+	}
+
+	switch b.state {
+	case BreakerClosed:
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return false
+}
+
+// record updates the circuit with the outcome of a call to the store
+func (b *Breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	switch b.state {
+	case BreakerHalfOpen:
+		b.probing = false
+		if err != nil {
+			b.openLocked(now, err)
+			return
+		}
+		b.state = BreakerClosed
+		b.consecutive = 0
+		b.calls, b.failures = 0, 0
+		b.windowStart = now
+		b.logger.Info("rate limit store recovered, circuit closed")
+		return
+	case BreakerOpen:
+		// A call admitted before the circuit opened
+		return
+	}
+
+	if now.Sub(b.windowStart) >= b.cfg.Window {
+		b.windowStart = now
+		b.calls, b.failures = 0, 0
+	}
+	b.calls++
+	if err == nil {
+		b.consecutive = 0
+		return
+	}
+	b.consecutive++
+	b.failures++
+
+	tooMany := b.cfg.Failures > 0 && b.consecutive >= b.cfg.Failures
+	tooOften := b.cfg.ErrorPercent > 0 && b.calls >= b.cfg.MinCalls &&
+		b.failures*100 >= b.cfg.ErrorPercent*b.calls
+	if tooMany || tooOften {
+		b.openLocked(now, err)
+	}
+}
+
+// openLocked opens the circuit
+func (b *Breaker) openLocked(now time.Time, err error) {
+	b.state = BreakerOpen
+	b.openedAt = now
+	b.stats.Opened++
+	b.logger.Warn("rate limit store failing, circuit open",
+		"error", err,
+		"cooldown", b.cfg.Cooldown,
+		"failClosed", b.cfg.FailClosed,
+	)
+}
+
+// fallback answers a call the store could not: limit types configured to
+// fail closed are denied until the circuit may close again, and all others
+// are allowed
+func (b *Breaker) fallback(key LimitKey) (*LimitStatus, error) {
+	limit := b.next.GetLimit(key.Type)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.failClosed[key.Type] {
+		b.stats.FailedOpen++
+		return &LimitStatus{Limit: limit, Remaining: math.MaxInt32}, nil
+	}
+	b.stats.FailedClosed++
+	retryAfter := b.cfg.Cooldown - b.clock.Now().Sub(b.openedAt)
+	if retryAfter < time.Second {
+		retryAfter = time.Second
+	}
+	return &LimitStatus{Limit: limit, RetryAfter: retryAfter}, ErrLimitExceeded
+}
+
+// Stats returns the circuit state and counters
+func (b *Breaker) Stats() BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := b.stats
+	stats.State = b.state
+	return stats
+}
+
+// Ready is a readiness check reporting the server degraded while the
+// circuit is not closed. The server still serves requests then, so it is
+// never reported unready.
+func (b *Breaker) Ready(ctx context.Context) error {
+	if state := b.Stats().State; state != BreakerClosed {
+		return health.Degraded(fmt.Errorf("rate limit store circuit %s", state))
+	}
+	return nil
+}
+
+// StatsHandler serves the circuit state and counters as JSON for metrics
+// scrapers
+func (b *Breaker) StatsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(b.Stats()); err != nil {
+			b.logger.Error("failed to write rate limit stats", "error", err)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/clock"
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+	"github.com/wrale/wrale-signage/internal/wsignd/health"
+)
+
+// flakyStore is a limiter whose store fails on demand
+type flakyStore struct {
+	Service
+	err   error
+	calls int
+}
+
+func (s *flakyStore) Allow(ctx context.Context, key LimitKey) (*LimitStatus, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.Service.Allow(ctx, key)
+}
+
+func TestBreaker(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	now := clock.NewFake(time.Now())
+
+	newBreaker := func(cfg config.BreakerConfig) (*Breaker, *flakyStore) {
+		store := &flakyStore{Service: NewMemoryService(DefaultLimits(), WithClock(now))}
+		return NewBreaker(store, cfg, logger, WithClock(now)), store
+	}
+	apiKey := LimitKey{Type: LimitTypeDisplayAPI, Key: "10.0.0.1"}
+	codeKey := LimitKey{Type: LimitTypeDeviceCode, Key: "10.0.0.1"}
+	redisDown := errors.New("dial tcp 10.0.0.9:6379: i/o timeout")
+
+	t.Run("consecutive failures open the circuit", func(t *testing.T) {
+		b, store := newBreaker(config.BreakerConfig{
+			Failures:   3,
+			Cooldown:   30 * time.Second,
+			FailClosed: []string{LimitTypeDeviceCode},
+		})
+
+		store.err = redisDown
+		for i := 0; i < 3; i++ {
+			_, err := b.Allow(ctx, apiKey)
+			require.NoError(t, err, "store errors fail open")
+		}
+		assert.Equal(t, BreakerOpen, b.Stats().State)
+		assert.Equal(t, 3, store.calls)
+		assert.ErrorContains(t, b.Ready(ctx), "circuit open")
+
+		// Open: calls fail fast without reaching the store
+		_, err := b.Allow(ctx, apiKey)
+		assert.NoError(t, err)
+		status, err := b.Allow(ctx, codeKey)
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+		assert.Equal(t, 30*time.Second, status.RetryAfter)
+		assert.Equal(t, 3, store.calls)
+
+		stats := b.Stats()
+		assert.Equal(t, uint64(1), stats.Opened)
+		assert.Equal(t, uint64(4), stats.FailedOpen)
+		assert.Equal(t, uint64(1), stats.FailedClosed)
+
+		// Still open until the full cool-down has passed
+		now.Advance(30*time.Second - time.Nanosecond)
+		_, err = b.Allow(ctx, apiKey)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, store.calls)
+		assert.Equal(t, BreakerOpen, b.Stats().State)
+
+		// Half-open: a failed probe reopens the circuit
+		now.Advance(time.Nanosecond)
+		_, err = b.Allow(ctx, apiKey)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, store.calls)
+		assert.Equal(t, BreakerOpen, b.Stats().State)
+		assert.Equal(t, uint64(2), b.Stats().Opened)
+
+		// Half-open: a successful probe closes it
+		now.Advance(30 * time.Second)
+		store.err = nil
+		_, err = b.Allow(ctx, codeKey)
+		assert.NoError(t, err)
+		assert.Equal(t, BreakerClosed, b.Stats().State)
+		assert.NoError(t, b.Ready(ctx))
+
+		_, err = b.Allow(ctx, codeKey)
+		assert.NoError(t, err)
+		assert.Equal(t, 6, store.calls)
+	})
+
+	t.Run("only one probe at a time", func(t *testing.T) {
+		b, store := newBreaker(config.BreakerConfig{Failures: 1, Cooldown: time.Second})
+		store.err = redisDown
+		_, _ = b.Allow(ctx, apiKey)
+		now.Advance(time.Second)
+
+		require.True(t, b.acquire())
+		assert.Equal(t, BreakerHalfOpen, b.Stats().State)
+		assert.False(t, b.acquire(), "a second call must not reach the store while probing")
+	})
+
+	t.Run("error rate opens the circuit", func(t *testing.T) {
+		b, store := newBreaker(config.BreakerConfig{
+			ErrorPercent: 50,
+			MinCalls:     4,
+			Window:       10 * time.Second,
+			Cooldown:     time.Minute,
+		})
+
+		// Alternating failures never reach a consecutive count
+		for i := 0; i < 3; i++ {
+			store.err = nil
+			if i%2 == 1 {
+				store.err = redisDown
+			}
+			_, _ = b.Allow(ctx, apiKey)
+		}
+		assert.Equal(t, BreakerClosed, b.Stats().State, "below the minimum number of calls")
+
+		store.err = redisDown
+		_, _ = b.Allow(ctx, apiKey)
+		assert.Equal(t, BreakerOpen, b.Stats().State)
+	})
+
+	t.Run("the error rate window rolls over", func(t *testing.T) {
+		b, store := newBreaker(config.BreakerConfig{
+			ErrorPercent: 50,
+			MinCalls:     2,
+			Window:       10 * time.Second,
+		})
+
+		store.err = redisDown
+		_, _ = b.Allow(ctx, apiKey)
+		now.Advance(10 * time.Second)
+		store.err = nil
+		_, _ = b.Allow(ctx, apiKey)
+		_, _ = b.Allow(ctx, apiKey)
+		assert.Equal(t, BreakerClosed, b.Stats().State)
+	})
+
+	t.Run("rate limiting is not a store failure", func(t *testing.T) {
+		b, _ := newBreaker(config.BreakerConfig{Failures: 1})
+		b.RegisterLimit("tight", Limit{Rate: 1, Period: time.Minute, BurstSize: 1})
+
+		key := LimitKey{Type: "tight", Key: "10.0.0.1"}
+		_, err := b.Allow(ctx, key)
+		require.NoError(t, err)
+		_, err = b.Allow(ctx, key)
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+		assert.Equal(t, BreakerClosed, b.Stats().State)
+	})
+}
+
+func TestBreakerReadiness(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	store := &flakyStore{Service: NewMemoryService(DefaultLimits()), err: errors.New("connection refused")}
+	b := NewBreaker(store, config.BreakerConfig{Failures: 1, Cooldown: time.Minute}, logger)
+	_, _ = b.Allow(context.Background(), LimitKey{Type: LimitTypeDisplayAPI, Key: "10.0.0.1"})
+
+	checks := health.New(logger)
+	checks.Add("ratelimit", b.Ready)
+	report := checks.Run(context.Background())
+	assert.Equal(t, "degraded", report.Status)
+	assert.Equal(t, "degraded: rate limit store circuit open", report.Checks["ratelimit"])
+}
@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/clock"
+)
+
+// bucket is a token bucket for a single key
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// memoryService is a token bucket limiter held in process memory. Limits are
+// per instance, so a clustered deployment allows up to N times the limit.
+type memoryService struct {
+	mu      sync.Mutex
+	limits  map[string]Limit
+	buckets map[LimitKey]*bucket
+	clock   clock.Clock
+}
+
+// NewMemoryService creates an in-memory limiter with the given limits. It
+// reads the system clock unless given another with WithClock.
+func NewMemoryService(limits map[string]Limit, opts ...Option) Service {
+	s := &memoryService{
+		limits:  make(map[string]Limit),
+		buckets: make(map[LimitKey]*bucket),
+		clock:   newOptions(opts).clock,
+	}
+	for name, limit := range limits {
+		s.limits[name] = limit
+	}
+	return s
+}
+
+func (s *memoryService) RegisterLimit(limitType string, limit Limit) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limits[limitType] = limit
+}
+
+func (s *memoryService) GetLimit(limitType string) Limit {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.limitLocked(limitType)
+}
+
+// limitLocked returns the registered limit for a type or its default
+func (s *memoryService) limitLocked(limitType string) Limit {
+	if limit, ok := s.limits[limitType]; ok {
+		return limit
+	}
+	return DefaultLimits()[limitType]
+}
+
+func (s *memoryService) Allow(ctx context.Context, key LimitKey) (*LimitStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limit := s.limitLocked(key.Type)
+	if limit.Rate <= 0 || limit.Period <= 0 {
+		return &LimitStatus{Limit: limit, Remaining: math.MaxInt32}, nil
+	}
+
+	capacity := float64(limit.BurstSize)
+	if capacity < 1 {
+		capacity = 1
+	}
+	perToken := limit.Period / time.Duration(limit.Rate)
+	now := s.clock.Now()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		s.buckets[key] = b
+		s.pruneLocked(now)
+	}
+
+	// Refill based on elapsed time
+	elapsed := now.Sub(b.last)
+	b.tokens = math.Min(capacity, b.tokens+float64(elapsed)/float64(perToken))
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) * float64(perToken))
+		return &LimitStatus{Limit: limit, RetryAfter: wait}, ErrLimitExceeded
+	}
+
+	b.tokens--
+	return &LimitStatus{Limit: limit, Remaining: int(b.tokens)}, nil
+}
+
+// Peek returns the state of key's bucket, refilled to now, without taking
+// a token from it
+func (s *memoryService) Peek(ctx context.Context, key LimitKey) (*LimitStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limit := s.limitLocked(key.Type)
+	if limit.Rate <= 0 || limit.Period <= 0 {
+		return &LimitStatus{Limit: limit, Remaining: math.MaxInt32}, nil
+	}
+
+	capacity := float64(limit.BurstSize)
+	if capacity < 1 {
+		capacity = 1
+	}
+	perToken := limit.Period / time.Duration(limit.Rate)
+
+	tokens := capacity
+	if b, ok := s.buckets[key]; ok {
+		elapsed := s.clock.Now().Sub(b.last)
+		tokens = math.Min(capacity, b.tokens+float64(elapsed)/float64(perToken))
+	}
+	status := &LimitStatus{Limit: limit, Remaining: int(tokens)}
+	if tokens < 1 {
+		status.RetryAfter = time.Duration((1 - tokens) * float64(perToken))
+	}
+	return status, nil
+}
+
+// pruneLocked drops buckets that have been idle long enough to be full again
+func (s *memoryService) pruneLocked(now time.Time) {
+	// Only prune occasionally to keep Allow cheap
+	if len(s.buckets)%1024 != 0 {
+		return
+	}
+	for key, b := range s.buckets {
+		limit := s.limitLocked(key.Type)
+		if now.Sub(b.last) > limit.Period {
+			delete(s.buckets, key)
+		}
+	}
+}
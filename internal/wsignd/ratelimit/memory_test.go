@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/clock"
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+)
+
+func TestMemoryService_Allow(t *testing.T) {
+	ctx := context.Background()
+	now := clock.NewFake(time.Now())
+
+	svc := NewMemoryService(map[string]Limit{
+		"test": {Rate: 60, Period: time.Minute, BurstSize: 2},
+	}, WithClock(now))
+
+	key := LimitKey{Type: "test", Key: "10.0.0.1"}
+
+	// Burst is allowed immediately
+	_, err := svc.Allow(ctx, key)
+	require.NoError(t, err)
+	_, err = svc.Allow(ctx, key)
+	require.NoError(t, err)
+
+	// Third request exceeds the burst
+	status, err := svc.Allow(ctx, key)
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	assert.Equal(t, time.Second, status.RetryAfter)
+
+	// Other keys have their own bucket
+	_, err = svc.Allow(ctx, LimitKey{Type: "test", Key: "10.0.0.2"})
+	assert.NoError(t, err)
+
+	// Tokens refill at the steady rate
+	now.Advance(time.Second - time.Nanosecond)
+	_, err = svc.Allow(ctx, key)
+	assert.ErrorIs(t, err, ErrLimitExceeded, "the token is not back until the full interval has passed")
+	now.Advance(time.Nanosecond)
+	_, err = svc.Allow(ctx, key)
+	assert.NoError(t, err)
+
+	// Unknown limit types are not limited
+	_, err = svc.Allow(ctx, LimitKey{Type: "unknown", Key: "10.0.0.1"})
+	assert.NoError(t, err)
+}
+
+func TestMemoryService_DefaultMessageLimits(t *testing.T) {
+	ctx := context.Background()
+
+	// A limiter built without any limits still bounds socket messages
+	svc := NewMemoryService(nil, WithClock(clock.NewFake(time.Now())))
+
+	for _, limitType := range []string{LimitTypeWSMessageIn, LimitTypeWSMessageOut, LimitTypeWSConnection} {
+		limit := svc.GetLimit(limitType)
+		assert.Equal(t, DefaultLimits()[limitType], limit, limitType)
+		assert.Positive(t, limit.Rate, limitType)
+	}
+
+	key := LimitKey{Type: LimitTypeWSMessageIn, Key: "display-1"}
+	burst := svc.GetLimit(LimitTypeWSMessageIn).BurstSize
+	for i := 0; i < burst; i++ {
+		_, err := svc.Allow(ctx, key)
+		require.NoError(t, err)
+	}
+	_, err := svc.Allow(ctx, key)
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+}
+
+func TestRegisterConfiguredLimits(t *testing.T) {
+	svc := NewMemoryService(DefaultLimits())
+
+	RegisterConfiguredLimits(svc, config.RateLimitConfig{
+		WSMessagesInPerMinute: 10,
+	})
+
+	// Configured rates replace the default; the burst never exceeds the rate
+	assert.Equal(t, Limit{Rate: 10, Period: time.Minute, BurstSize: 10}, svc.GetLimit(LimitTypeWSMessageIn))
+	// Unset rates keep their defaults
+	assert.Equal(t, DefaultLimits()[LimitTypeWSMessageOut], svc.GetLimit(LimitTypeWSMessageOut))
+	assert.Equal(t, DefaultLimits()[LimitTypeWSConnection], svc.GetLimit(LimitTypeWSConnection))
+}
+
+func TestRegisterConfiguredProfiles(t *testing.T) {
+	svc := NewMemoryService(DefaultLimits())
+
+	RegisterConfiguredLimits(svc, config.RateLimitConfig{
+		Profiles: map[string]config.RateLimitProfile{
+			LimitTypeDisplayStats: {Rate: 5, Period: time.Minute, Burst: 2},
+			"reports":             {Rate: 10, Period: time.Hour},
+		},
+	})
+
+	assert.Equal(t, Limit{Rate: 5, Period: time.Minute, BurstSize: 2}, svc.GetLimit(LimitTypeDisplayStats))
+	// Profiles without a burst allow their whole rate at once
+	assert.Equal(t, Limit{Rate: 10, Period: time.Hour, BurstSize: 10}, svc.GetLimit("reports"))
+	assert.Equal(t, DefaultLimits()[LimitTypeDisplayAPI], svc.GetLimit(LimitTypeDisplayAPI))
+}
+
+func TestMemoryService_Peek(t *testing.T) {
+	ctx := context.Background()
+	now := clock.NewFake(time.Now())
+	svc := NewMemoryService(map[string]Limit{
+		"test": {Rate: 60, Period: time.Minute, BurstSize: 2},
+	}, WithClock(now))
+	inspector, ok := svc.(Inspector)
+	require.True(t, ok)
+	key := LimitKey{Type: "test", Key: "10.0.0.1"}
+
+	// A key never seen has its full burst
+	status, err := inspector.Peek(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, 2, status.Remaining)
+
+	for i := 0; i < 2; i++ {
+		_, err = svc.Allow(ctx, key)
+		require.NoError(t, err)
+	}
+	status, err = inspector.Peek(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, 0, status.Remaining)
+	assert.Equal(t, time.Second, status.RetryAfter)
+
+	// Peeking does not take a token, so a refill is still allowed
+	now.Advance(time.Second)
+	_, err = inspector.Peek(ctx, key)
+	require.NoError(t, err)
+	_, err = svc.Allow(ctx, key)
+	assert.NoError(t, err)
+}
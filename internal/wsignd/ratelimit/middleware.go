@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+)
+
+// CommonRateLimiters builds the rate limiting middleware routers apply to
+// their route groups. A group is counted against the limit of the same name
+// unless configuration binds it to another profile, so operators can tune
+// groups independently or make several share one budget.
+type CommonRateLimiters struct {
+	limiter Service
+	routes  map[string]string
+	logger  *slog.Logger
+
+	// mu guards bound, which records the profile of every group a router
+	// has asked for
+	mu    sync.Mutex
+	bound map[string]string
+}
+
+// NewCommonRateLimiters creates limiters counting against limiter. routes
+// binds route groups to profiles of another name and may be nil.
+func NewCommonRateLimiters(limiter Service, routes map[string]string, logger *slog.Logger) *CommonRateLimiters {
+	return &CommonRateLimiters{
+		limiter: limiter,
+		routes:  routes,
+		logger:  logger,
+		bound:   make(map[string]string),
+	}
+}
+
+// NamedLimiter returns middleware limiting the route group name per remote
+// address. Requests over the limit are rejected with 429.
+func (c *CommonRateLimiters) NamedLimiter(name string) func(http.Handler) http.Handler {
+	profile := name
+	if p, ok := c.routes[name]; ok {
+		profile = p
+	}
+
+	c.mu.Lock()
+	c.bound[name] = profile
+	c.mu.Unlock()
+
+	return middleware(c.limiter, profile, c.logger)
+}
+
+// DeviceCode limits the unauthenticated device activation flow
+func (c *CommonRateLimiters) DeviceCode() func(http.Handler) http.Handler {
+	return c.NamedLimiter(LimitTypeDeviceCode)
+}
+
+// WSConnection limits WebSocket connection attempts
+func (c *CommonRateLimiters) WSConnection() func(http.Handler) http.Handler {
+	return c.NamedLimiter(LimitTypeWSConnection)
+}
+
+// Validate checks the bindings once every router has been built. Each
+// mounted group must resolve to a registered limit, and each configured
+// binding must name a group that was mounted; both are configuration
+// mistakes that would otherwise leave routes silently unlimited.
+func (c *CommonRateLimiters) Validate() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var problems []string
+	for group, profile := range c.bound {
+		if limit := c.limiter.GetLimit(profile); limit.Rate <= 0 || limit.Period <= 0 {
+			problems = append(problems, fmt.Sprintf("route group %q is bound to unregistered rate limit profile %q", group, profile))
+		}
+	}
+	for group := range c.routes {
+		if _, ok := c.bound[group]; !ok {
+			problems = append(problems, fmt.Sprintf("rate limit binding names unknown route group %q", group))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("invalid rate limit configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// middleware counts requests against limitType per remote address and
+// rejects requests over the limit with 429
+func middleware(limiter Service, limitType string, logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := LimitKey{Type: limitType, Key: clientAddr(r)}
+
+			status, err := limiter.Allow(r.Context(), key)
+			if err != nil {
+				logger.Warn("rate limit exceeded",
+					"limitType", limitType,
+					"remoteAddr", key.Key,
+					"path", r.URL.Path,
+				)
+				retryAfter := int(math.Ceil(status.RetryAfter.Seconds()))
+				if retryAfter < 1 {
+					retryAfter = 1
+				}
+				w.Header().Set("Retry-After", fmt.Sprint(retryAfter))
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusTooManyRequests)
+				_ = json.NewEncoder(w).Encode(v1alpha1.Error{
+					Code:    "RATE_LIMITED",
+					Message: "rate limit exceeded",
+				})
+				return
+			}
+
+			if status != nil && status.Limit.Rate > 0 {
+				w.Header().Set("X-RateLimit-Limit", fmt.Sprint(status.Limit.Rate))
+				w.Header().Set("X-RateLimit-Remaining", fmt.Sprint(status.Remaining))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientAddr returns the host part of the request's remote address
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommonRateLimiters(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	limits := map[string]Limit{
+		"cheap":     {Rate: 60, Period: time.Minute, BurstSize: 5},
+		"expensive": {Rate: 60, Period: time.Minute, BurstSize: 2},
+	}
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	newRouter := func(routes map[string]string) (http.Handler, *CommonRateLimiters) {
+		limiters := NewCommonRateLimiters(NewMemoryService(limits), routes, logger)
+		r := chi.NewRouter()
+		r.With(limiters.NamedLimiter("cheap")).Get("/health", ok)
+		r.With(limiters.NamedLimiter("expensive")).Get("/search", ok)
+		return r, limiters
+	}
+	// allowed counts the requests to path that get through before the
+	// first 429
+	allowed := func(router http.Handler, path string) int {
+		for n := 0; n < 100; n++ {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			if rec.Code == http.StatusTooManyRequests {
+				assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+				return n
+			}
+			require.Equal(t, http.StatusOK, rec.Code)
+		}
+		return -1
+	}
+
+	t.Run("groups enforce their own limits", func(t *testing.T) {
+		router, limiters := newRouter(nil)
+		require.NoError(t, limiters.Validate())
+
+		assert.Equal(t, 2, allowed(router, "/search"))
+		// Exhausting one group leaves the other untouched
+		assert.Equal(t, 5, allowed(router, "/health"))
+	})
+
+	t.Run("bound groups share a profile", func(t *testing.T) {
+		router, limiters := newRouter(map[string]string{"cheap": "expensive"})
+		require.NoError(t, limiters.Validate())
+
+		assert.Equal(t, 2, allowed(router, "/health"))
+		assert.Equal(t, 0, allowed(router, "/search"))
+	})
+
+	t.Run("unregistered profiles fail validation", func(t *testing.T) {
+		_, limiters := newRouter(map[string]string{"cheap": "missing"})
+		err := limiters.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `"missing"`)
+	})
+
+	t.Run("bindings for unknown groups fail validation", func(t *testing.T) {
+		_, limiters := newRouter(map[string]string{"reports": "cheap"})
+		err := limiters.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `"reports"`)
+	})
+}
@@ -0,0 +1,156 @@
+// Package ratelimit provides request rate limiting for the server
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/clock"
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+)
+
+// ErrLimitExceeded indicates a request exceeded its rate limit
+var ErrLimitExceeded = errors.New("rate limit exceeded")
+
+// Limit type names used by the server's route groups
+const (
+	// LimitTypeDeviceCode limits the unauthenticated device activation flow
+	LimitTypeDeviceCode = "device_code"
+	// LimitTypeWSConnection limits WebSocket connection attempts per client
+	LimitTypeWSConnection = "ws_connection"
+	// LimitTypeWSMessageIn limits messages a display sends over its socket
+	LimitTypeWSMessageIn = "ws_message_in"
+	// LimitTypeWSMessageOut limits control messages sent to a display
+	LimitTypeWSMessageOut = "ws_message_out"
+	// LimitTypeDisplayAPI limits display management requests
+	LimitTypeDisplayAPI = "display_api"
+	// LimitTypeDisplayStats limits display state history queries, which
+	// aggregate stored snapshots
+	LimitTypeDisplayStats = "display_stats"
+	// LimitTypeContentProbe limits how often content previews fetch a
+	// content source's origin, counted per source
+	LimitTypeContentProbe = "content_probe"
+)
+
+// Limit defines how many requests are allowed per period
+type Limit struct {
+	// Rate is the number of requests allowed per Period
+	Rate int
+	// Period is the window over which Rate applies
+	Period time.Duration
+	// BurstSize is the number of requests allowed at once before the
+	// steady rate applies
+	BurstSize int
+}
+
+// LimitKey identifies the bucket a request is counted against
+type LimitKey struct {
+	// Type is the name of the limit being applied
+	Type string
+	// Key identifies the caller, such as a token or remote address
+	Key string
+}
+
+// LimitStatus describes the state of a bucket after a request
+type LimitStatus struct {
+	// Limit is the configured limit for the bucket
+	Limit Limit
+	// Remaining is the number of requests still allowed right now
+	Remaining int
+	// RetryAfter is how long to wait before the next request is allowed
+	RetryAfter time.Duration
+}
+
+// Service checks requests against configured limits
+type Service interface {
+	// Allow records a request and returns ErrLimitExceeded if it is over
+	// the limit for its key. Limit types without a registered limit use
+	// their entry in DefaultLimits; unknown limit types are always allowed.
+	Allow(ctx context.Context, key LimitKey) (*LimitStatus, error)
+	// RegisterLimit sets the limit for a limit type
+	RegisterLimit(limitType string, limit Limit)
+	// GetLimit returns the limit applied to a limit type, falling back to
+	// its default. The zero Limit means the type is not limited.
+	GetLimit(limitType string) Limit
+}
+
+// Inspector is implemented by limiters that can report the state of a
+// bucket without counting a request against it
+type Inspector interface {
+	// Peek returns the state of key's bucket as it stands. A key with no
+	// bucket yet reports its full burst.
+	Peek(ctx context.Context, key LimitKey) (*LimitStatus, error)
+}
+
+// ErrPeekUnsupported is returned by Peek when the limiter behind it cannot
+// report bucket state
+var ErrPeekUnsupported = errors.New("limiter cannot report bucket state")
+
+// Option configures a limiter
+type Option func(*options)
+
+// options are the settings shared by the limiters
+type options struct {
+	clock clock.Clock
+}
+
+// WithClock sets the clock buckets refill and circuits cool down against
+func WithClock(c clock.Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// newOptions applies opts over the defaults, which read the system clock
+func newOptions(opts []Option) options {
+	o := options{clock: clock.Real()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// DefaultLimits returns the limits applied when none are configured
+func DefaultLimits() map[string]Limit {
+	return map[string]Limit{
+		LimitTypeDeviceCode:   {Rate: 60, Period: time.Minute, BurstSize: 20},
+		LimitTypeWSConnection: {Rate: 10, Period: time.Minute, BurstSize: 5},
+		LimitTypeWSMessageIn:  {Rate: 120, Period: time.Minute, BurstSize: 20},
+		LimitTypeWSMessageOut: {Rate: 600, Period: time.Minute, BurstSize: 100},
+		LimitTypeDisplayAPI:   {Rate: 600, Period: time.Minute, BurstSize: 100},
+		LimitTypeDisplayStats: {Rate: 60, Period: time.Minute, BurstSize: 10},
+		LimitTypeContentProbe: {Rate: 6, Period: time.Minute, BurstSize: 3},
+	}
+}
+
+// RegisterConfiguredLimits applies the limits set in configuration. Rates
+// left at zero keep their defaults, and configured profiles replace the
+// limit of the same name.
+func RegisterConfiguredLimits(s Service, cfg config.RateLimitConfig) {
+	for name, p := range cfg.Profiles {
+		burst := p.Burst
+		if burst == 0 {
+			burst = p.Rate
+		}
+		s.RegisterLimit(name, Limit{Rate: p.Rate, Period: p.Period, BurstSize: burst})
+	}
+
+	perMinute := map[string]int{
+		LimitTypeWSConnection: cfg.WSConnectionsPerMinute,
+		LimitTypeWSMessageIn:  cfg.WSMessagesInPerMinute,
+		LimitTypeWSMessageOut: cfg.WSMessagesOutPerMinute,
+	}
+	for limitType, rate := range perMinute {
+		if rate <= 0 {
+			continue
+		}
+		limit := s.GetLimit(limitType)
+		limit.Rate = rate
+		limit.Period = time.Minute
+		if limit.BurstSize > rate {
+			limit.BurstSize = rate
+		}
+		s.RegisterLimit(limitType, limit)
+	}
+}
@@ -0,0 +1,134 @@
+// Package scheduler runs periodic background jobs for the server
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Job is a unit of periodic background work
+type Job func(ctx context.Context) error
+
+// entry is a job registered with the scheduler
+type entry struct {
+	name     string
+	interval time.Duration
+	fn       Job
+}
+
+// Scheduler runs registered jobs at fixed intervals until it is stopped. A
+// single scheduler is shared by all background work in the server so that
+// job lifecycle is managed in one place.
+type Scheduler struct {
+	logger *slog.Logger
+	// timeout bounds each run of a job; zero leaves runs unbounded
+	timeout time.Duration
+
+	mu      sync.Mutex
+	jobs    []entry
+	cancel  context.CancelFunc
+	running bool
+	wg      sync.WaitGroup
+}
+
+// Option configures a Scheduler
+type Option func(*Scheduler)
+
+// WithJobTimeout bounds each run of every job: its context is done once d
+// has passed, so a job stuck on a dead connection gives up instead of
+// blocking its later runs. Zero or less leaves runs unbounded.
+func WithJobTimeout(d time.Duration) Option {
+	return func(s *Scheduler) {
+		s.timeout = d
+	}
+}
+
+// New creates a scheduler with no registered jobs
+func New(logger *slog.Logger, opts ...Option) *Scheduler {
+	s := &Scheduler{logger: logger}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Every registers fn to run once per interval. Jobs must be registered before
+// Start is called; intervals of zero or less disable the job.
+func (s *Scheduler) Every(name string, interval time.Duration, fn Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if interval <= 0 {
+		s.logger.Info("scheduled job disabled", "job", name)
+		return
+	}
+	s.jobs = append(s.jobs, entry{name: name, interval: interval, fn: fn})
+}
+
+// Start launches all registered jobs. It returns immediately; jobs run until
+// ctx is cancelled or Stop is called.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return
+	}
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.running = true
+
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.run(ctx, job)
+	}
+}
+
+// Stop cancels all jobs and waits for any in-progress runs to finish
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.cancel()
+	s.running = false
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// run executes a single job on its interval until ctx is done
+func (s *Scheduler) run(ctx context.Context, job entry) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(job.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			start := time.Now()
+			if err := s.runOnce(ctx, job); err != nil {
+				s.logger.Error("scheduled job failed",
+					"job", job.name,
+					"error", err,
+					"duration", time.Since(start),
+				)
+			}
+		}
+	}
+}
+
+// runOnce runs a job once, within the job timeout if there is one
+func (s *Scheduler) runOnce(ctx context.Context, job entry) error {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	return job.fn(ctx)
+}
@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler_RunsJobsUntilStopped(t *testing.T) {
+	s := New(slog.Default())
+
+	var runs atomic.Int32
+	s.Every("count", 10*time.Millisecond, func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	})
+	s.Every("disabled", 0, func(ctx context.Context) error {
+		t.Error("disabled job should not run")
+		return nil
+	})
+
+	s.Start(context.Background())
+	assert.Eventually(t, func() bool { return runs.Load() >= 2 }, time.Second, 5*time.Millisecond)
+	s.Stop()
+
+	stopped := runs.Load()
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, stopped, runs.Load())
+}
+
+func TestScheduler_JobTimeout(t *testing.T) {
+	s := New(slog.Default(), WithJobTimeout(20*time.Millisecond))
+
+	errs := make(chan error, 1)
+	s.Every("stuck", 10*time.Millisecond, func(ctx context.Context) error {
+		_, hasDeadline := ctx.Deadline()
+		assert.True(t, hasDeadline)
+		<-ctx.Done()
+		select {
+		case errs <- ctx.Err():
+		default:
+		}
+		return ctx.Err()
+	})
+
+	s.Start(context.Background())
+	defer s.Stop()
+	select {
+	case err := <-errs:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(time.Second):
+		t.Fatal("stuck job was not cut off at its timeout")
+	}
+}
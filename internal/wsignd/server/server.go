@@ -0,0 +1,354 @@
+// Package server assembles the wsignd HTTP handler from its services and
+// the stores they are built on. The wsignd command serves it; the
+// integration tests drive it through httptest.
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/wrale/wrale-signage/api/types/v1alpha1"
+	"github.com/wrale/wrale-signage/internal/version"
+	"github.com/wrale/wrale-signage/internal/wsignd/admin"
+	"github.com/wrale/wrale-signage/internal/wsignd/assignment"
+	assignmenthttp "github.com/wrale/wrale-signage/internal/wsignd/assignment/http"
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
+	authhttp "github.com/wrale/wrale-signage/internal/wsignd/auth/http"
+	"github.com/wrale/wrale-signage/internal/wsignd/compress"
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	"github.com/wrale/wrale-signage/internal/wsignd/content/failover"
+	contenthttp "github.com/wrale/wrale-signage/internal/wsignd/content/http"
+	"github.com/wrale/wrale-signage/internal/wsignd/content/notify"
+	"github.com/wrale/wrale-signage/internal/wsignd/discovery"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/activation"
+	displayhttp "github.com/wrale/wrale-signage/internal/wsignd/display/http"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/outbox"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/proposal"
+	proposalhttp "github.com/wrale/wrale-signage/internal/wsignd/display/proposal/http"
+	"github.com/wrale/wrale-signage/internal/wsignd/drain"
+	"github.com/wrale/wrale-signage/internal/wsignd/health"
+	"github.com/wrale/wrale-signage/internal/wsignd/maintenance"
+	maintenancehttp "github.com/wrale/wrale-signage/internal/wsignd/maintenance/http"
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+	"github.com/wrale/wrale-signage/internal/wsignd/overload"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+	"github.com/wrale/wrale-signage/internal/wsignd/scheduler"
+)
+
+// App is what New builds: the handler to serve and the parts that shutdown
+// drains
+type App struct {
+	Handler  http.Handler
+	Displays *displayhttp.Handler
+	Requests *drain.Tracker
+}
+
+// New creates and configures the HTTP router with all application routes,
+// building the services on stores
+func New(ctx context.Context, cfg *config.Config, stores *Stores, keys *auth.KeyRing, logger *slog.Logger, sched *scheduler.Scheduler) (*App, error) {
+	r := chi.NewRouter()
+
+	// Count every request, shed or not, so shutdown knows what it waits for
+	requests := drain.NewTracker(logger)
+	r.Use(requests.Middleware)
+
+	// Display access tokens are signed with the primary key
+	tokenService := auth.NewService(keys, stores.Tokens, cfg.Auth.TokenExpiry)
+	sched.Every("token-cleanup", cfg.Auth.TokenExpiry, tokenService.CleanupExpired)
+
+	// Management routes require an operator API token with the right scope
+	guard := authhttp.OperatorAuth(operator.NewService(stores.Operators), tokenService, logger)
+
+	// Shed load before any handler work is done
+	shedder := overload.New(ctx, cfg.Server.Overload, classifyRequest, logger)
+	r.Use(shedder.Middleware)
+	// JSON lists and event exports are compressed for clients that accept
+	// it; control sockets and stored content pass through
+	r.Use(compress.Middleware(cfg.Server.Compression))
+	r.With(guard.Require(operator.ScopeAdmin)).Get(overloadStatsPath, shedder.StatsHandler())
+	r.With(guard.Require(operator.ScopeAdmin)).Get(drainStatsPath, requests.StatsHandler())
+	if stores.Queries != nil {
+		r.With(guard.Require(operator.ScopeAdmin)).Get(queryStatsPath, stores.Queries.StatsHandler())
+	}
+
+	// Set up display service dependencies
+	// Recent lifecycle events are kept for debug snapshots
+	events := display.NewEventLog(display.DefaultEventLogSize, &noopEventPublisher{}) // TODO: Implement real event publisher
+	liveness := display.Liveness{
+		OfflineAfter: cfg.Display.OfflineAfter,
+		GracePeriod:  cfg.Display.OfflineGracePeriod,
+	}
+	service := display.NewService(stores.Displays, events, cfg.Display.ContentHistorySize, liveness, display.ApprovalPolicy{Sites: cfg.Display.ApprovalSites}, display.Capacity{
+		MaxPerSite: cfg.Display.MaxPerSite,
+		Sites:      cfg.Display.SiteLimits,
+		MaxPerZone: cfg.Display.MaxPerZone,
+		Zones:      cfg.Display.ZoneLimits,
+	}, logger, display.WithPropertyLimits(cfg.Properties))
+	// Check often enough that displays are marked offline close to the end
+	// of their grace period
+	sched.Every("offline-reaper", cfg.Display.OfflineAfter/4, service.ReapOffline)
+	// Per-site state counts build up the history capacity planning reads
+	sched.Every("state-snapshots", cfg.Display.StateSnapshotInterval, func(ctx context.Context) error {
+		return service.SnapshotStates(ctx, cfg.Display.StateSnapshotRetention)
+	})
+
+	// Device activation codes expire quickly, so sweep them regularly
+	activationService := activation.NewService(stores.Activation, cfg.Auth.DeviceCodeExpiry, activation.SitePolicy{
+		Strict: cfg.Display.ActivationSitePolicy == config.ActivationSitesStrict,
+	}, activation.WithEnrollmentExpiry(cfg.Auth.EnrollmentTokenExpiry))
+	sched.Every("device-code-cleanup", cfg.Auth.DeviceCodeExpiry, activationService.CleanupExpired)
+
+	// Location proposals nobody decided on expire; checking hourly is
+	// plenty for an age measured in days
+	proposalService := proposal.NewService(stores.Proposals, service, cfg.Display.LocationProposalMaxAge)
+	sched.Every("location-proposal-expiry", time.Hour, proposalService.ExpireStale)
+	// The breaker only trips for limiters backed by a shared store; the
+	// in-memory limiter never fails
+	limits := stores.RateLimits
+	if limits == nil {
+		limits = ratelimit.NewMemoryService(ratelimit.DefaultLimits())
+	}
+	limiter := ratelimit.NewBreaker(limits, cfg.RateLimit.Breaker, logger)
+	ratelimit.RegisterConfiguredLimits(limiter, cfg.RateLimit)
+	r.With(guard.Require(operator.ScopeAdmin)).Get(rateLimitStatsPath, limiter.StatsHandler())
+
+	// Support reads the configuration the server actually runs with
+	r.With(guard.Require(operator.ScopeAdmin)).Get(admin.ConfigPath, admin.ConfigHandler(admin.EffectiveConfig(cfg, version.Version)))
+	limiters := ratelimit.NewCommonRateLimiters(limiter, cfg.RateLimit.Routes, logger)
+
+	// The display handler owns the control sockets other services push
+	// messages through
+	displayHandler := displayhttp.NewHandlerWithHubConfig(service, activationService, tokenService, logger, displayhttp.HubConfig{
+		SendQueueSize:          cfg.Display.SendQueueSize,
+		MaxConsecutiveDrops:    cfg.Display.MaxConsecutiveDrops,
+		Limiter:                limiter,
+		ReadBufferSize:         cfg.Server.WebSocket.ReadBufferSize,
+		WriteBufferSize:        cfg.Server.WebSocket.WriteBufferSize,
+		MaxMessageSize:         cfg.Server.WebSocket.MaxMessageSize,
+		MaxOutboundMessageSize: cfg.Server.WebSocket.MaxOutboundMessageSize,
+		ReconnectSpread:        cfg.Server.Shutdown.ReconnectSpread,
+	})
+
+	// Displays are told how often to check in, so the fleet's load can be
+	// tuned from here
+	polling, err := display.NewPolling(pollingPolicy(cfg.Display), liveness)
+	if err != nil {
+		return nil, fmt.Errorf("invalid polling policy: %w", err)
+	}
+	displayHandler.SetPolling(polling)
+
+	// Targeted messages for displays that are not connected are optionally
+	// kept until the display connects here or on another server
+	var sender notify.Sender = displayHandler
+	var pending display.PendingCounter
+	if cfg.Display.OutboxTTL > 0 {
+		outboxService := outbox.NewService(stores.Outbox, displayHandler, cfg.Display.OutboxTTL, logger)
+		displayHandler.SetOutbox(outboxService)
+		sender, pending = outboxService, outboxService
+		sched.Every("outbox-delivery", cfg.Display.OutboxDeliveryInterval, displayHandler.DeliverQueued)
+		sched.Every("outbox-expiry", time.Minute, outboxService.ExpireStale)
+	}
+	displayHandler.SetEnricher(display.NewEnricher(stores.Displays, displayHandler, pending))
+
+	// Set up content service dependencies; updates can reload the displays
+	// assigned the content, as can scheduled assignments opening or closing
+	timezones, err := scheduleTimezones(cfg.Display)
+	if err != nil {
+		return nil, err
+	}
+	assignmentService := assignment.NewService(stores.Assignments,
+		assignment.WithTimezones(timezones), assignment.WithDisplays(service))
+	displayHandler.SetTimezones(display.Timezones{Default: timezones.Default, Sites: timezones.Sites})
+	monitor := content.NewHealthMonitor(stores.Metrics)
+	contentService := content.NewService(
+		stores.Content,
+		content.NewHTTPValidator(cfg.Content.ValidationTimeout),
+		stores.Events,
+		stores.Metrics,
+		monitor,
+		notify.New(assignmentService, service, sender, logger),
+		content.WithPropertyLimits(cfg.Properties),
+	)
+	// Displays are assigned content by convention as they are activated
+	if len(cfg.Display.AutoAssign) > 0 {
+		displayHandler.SetAutoAssigner(assignment.NewAutoAssigner(assignmentService, contentService, autoAssignRules(cfg.Display)))
+	}
+	// Displays resolving their content are moved to a fallback source while
+	// the assigned one is unhealthy
+	resolver := failover.New(assignmentService, contentService, monitor, logger)
+	// Maintenance overrides every display's content until it ends, here or
+	// on another server
+	maintenanceService := maintenance.NewService(stores.Maintenance, logger)
+	maintenanceService.SetBroadcaster(displayHandler)
+	if err := maintenanceService.Load(ctx); err != nil {
+		return nil, err
+	}
+	displayHandler.SetContentResolver(maintenance.NewResolver(maintenanceService, resolver))
+	displayHandler.SetMaintenanceNotices(maintenanceService)
+	// Support reads a display's state from every service in one request
+	displayHandler.SetDebugService(displayhttp.NewDebugService(displayHandler, events, assignmentService, displayhttp.DefaultDebugTimeout))
+	sched.Every("maintenance-refresh", cfg.Display.ScheduleCheckInterval, maintenanceService.Refresh)
+	sched.Every("content-validation", cfg.Content.ValidationInterval, contentService.RefreshValidations)
+	// Old events are pruned, archived first when configured, and the
+	// partitions for the coming month created ahead of time
+	var retentionOpts []content.RetentionOption
+	if cfg.Content.EventArchive {
+		retentionOpts = append(retentionOpts, content.WithEventArchive(
+			content.NewEventArchive(filepath.Join(cfg.Content.StoragePath, "event-archive"))))
+	}
+	retention := content.NewRetention(stores.EventRetention, cfg.Content.EventRetention,
+		cfg.Content.EventPruneBatchSize, logger, retentionOpts...)
+	sched.Every("content-event-retention", cfg.Content.EventRetentionInterval, retention.Run)
+	r.With(guard.Require(operator.ScopeAdmin)).Get(admin.RetentionPath, admin.RetentionHandler(retention))
+	transitions := notify.NewTransitions(assignmentService, service, sender, timezones, cfg.Display.ScheduleJitter, logger)
+	sched.Every("schedule-transitions", cfg.Display.ScheduleCheckInterval, transitions.Run)
+
+	// Probes; the server is not ready while the display hub is stalled, and
+	// degraded while rate limits cannot be checked
+	checks := health.New(logger)
+	checks.Add("hub", displayHandler.HubReady)
+	checks.Add("ratelimit", limiter.Ready)
+	r.Get(health.LivePath, health.LiveHandler())
+	r.Get(health.ReadyPath, checks.ReadyHandler())
+
+	// Clients read the discovery document to adapt to this server
+	r.Get(discovery.Path, discovery.Handler(discovery.Document(version.Version)))
+	r.Get(discovery.VersionPath, discovery.VersionHandler(discovery.Version(version.Version, version.Commit, version.BuildDate)))
+
+	// Create and mount content handlers; displays authenticate event reports
+	contentHandler := contenthttp.NewHandler(contentService, logger)
+	contentHandler.SetDisplayTimezones(displayHandler)
+	contentHandler.SetPreviewer(contenthttp.NewPreviewer(service, assignmentService, limiter))
+	r.Mount("/api/v1alpha1/content", contenthttp.NewRouter(contentHandler, authhttp.RequireDisplayToken(tokenService, logger), guard))
+
+	// Administrators put every display in maintenance
+	maintenanceHandler := maintenancehttp.NewHandler(maintenanceService, logger)
+	r.Mount(maintenancehttp.Path, maintenancehttp.NewRouter(maintenanceHandler, guard))
+
+	// Create and mount assignment handlers
+	assignmentHandler := assignmenthttp.NewHandler(assignmentService, logger)
+	r.Mount("/api/v1alpha1/assignments", assignmenthttp.NewRouter(assignmentHandler, guard))
+
+	// Displays propose corrections to their own location, operators review
+	// them
+	proposalHandler := proposalhttp.NewHandler(proposalService, logger)
+	r.Mount("/api/v1alpha1/displays/{id}/location-proposals", proposalhttp.NewDisplayRouter(proposalHandler, authhttp.RequireDisplayToken(tokenService, logger)))
+	r.Mount("/api/v1alpha1/location-proposals", proposalhttp.NewRouter(proposalHandler, guard))
+
+	// Mount display handlers
+	r.Mount("/", displayhttp.NewRouterWithLimiters(displayHandler, limiters, guard))
+
+	// Every route group must resolve to a limit before serving
+	if err := limiters.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &App{Handler: r, Displays: displayHandler, Requests: requests}, nil
+}
+
+// Debug paths serving counters for metrics scrapers
+const (
+	overloadStatsPath  = "/debug/overload"
+	rateLimitStatsPath = "/debug/ratelimit"
+	drainStatsPath     = "/debug/requests"
+	queryStatsPath     = "/debug/queries"
+)
+
+// classifyRequest sorts requests into overload classes. Metrics and health
+// aggregate stored events, so they get the smaller expensive cap; WebSocket
+// upgrades hold their slot for as long as the display stays connected.
+// Probes are never shed, so an overloaded server is not also reported dead,
+// and neither is the version, which support asks for when things go wrong.
+func classifyRequest(r *http.Request) overload.Class {
+	switch {
+	case r.URL.Path == overloadStatsPath,
+		r.URL.Path == rateLimitStatsPath,
+		r.URL.Path == drainStatsPath,
+		r.URL.Path == queryStatsPath,
+		r.URL.Path == discovery.VersionPath,
+		r.URL.Path == health.LivePath,
+		r.URL.Path == health.ReadyPath:
+		return overload.ClassExempt
+	case strings.EqualFold(r.Header.Get("Upgrade"), "websocket"):
+		return overload.ClassWebSocket
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1alpha1/content/events",
+		r.URL.Path == "/api/v1alpha1/content/metrics",
+		r.URL.Path == "/api/v1alpha1/content/health",
+		r.URL.Path == "/api/v1alpha1/content/prefetch-status",
+		strings.HasPrefix(r.URL.Path, "/api/v1alpha1/content/metrics/"),
+		strings.HasPrefix(r.URL.Path, "/api/v1alpha1/content/health/"):
+		return overload.ClassExpensive
+	default:
+		return overload.ClassCheap
+	}
+}
+
+// pollingPolicy builds the display polling policy from the configuration
+func pollingPolicy(cfg config.DisplayConfig) display.PollingPolicy {
+	policy := display.PollingPolicy{
+		Default: display.PollingHints{
+			HeartbeatInterval:   cfg.HeartbeatInterval,
+			ContentPollInterval: cfg.ContentPollInterval,
+		},
+	}
+	if len(cfg.PollingSites) > 0 {
+		policy.Sites = make(map[string]display.PollingHints, len(cfg.PollingSites))
+		for site, intervals := range cfg.PollingSites {
+			policy.Sites[site] = display.PollingHints{
+				HeartbeatInterval:   intervals.Heartbeat,
+				ContentPollInterval: intervals.ContentPoll,
+			}
+		}
+	}
+	return policy
+}
+
+// scheduleTimezones loads the zones assignment schedules are read in
+func scheduleTimezones(cfg config.DisplayConfig) (assignment.Timezones, error) {
+	var tz assignment.Timezones
+	var err error
+	if tz.Default, err = time.LoadLocation(cfg.Timezone); err != nil {
+		return tz, fmt.Errorf("invalid display time zone: %w", err)
+	}
+	if len(cfg.SiteTimezones) > 0 {
+		tz.Sites = make(map[string]*time.Location, len(cfg.SiteTimezones))
+		for site, name := range cfg.SiteTimezones {
+			if tz.Sites[site], err = time.LoadLocation(name); err != nil {
+				return tz, fmt.Errorf("invalid time zone for site %s: %w", site, err)
+			}
+		}
+	}
+	return tz, nil
+}
+
+// autoAssignRules converts the configured auto-assignment rules
+func autoAssignRules(cfg config.DisplayConfig) []assignment.Rule {
+	rules := make([]assignment.Rule, 0, len(cfg.AutoAssign))
+	for _, r := range cfg.AutoAssign {
+		rule := assignment.Rule{
+			Match:  v1alpha1.DisplaySelector{SiteID: r.SiteID, Zone: r.Zone},
+			Source: r.Source,
+		}
+		if r.Property != "" {
+			rule.Match.MatchProperties = map[string]string{r.Property: r.Value}
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// noopEventPublisher is a temporary implementation of display.EventPublisher
+type noopEventPublisher struct{}
+
+func (p *noopEventPublisher) Publish(ctx context.Context, event display.Event) error {
+	return nil
+}
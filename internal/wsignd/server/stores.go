@@ -0,0 +1,113 @@
+package server
+
+import (
+	"database/sql"
+
+	"github.com/wrale/wrale-signage/internal/wsignd/assignment"
+	assignmentmemory "github.com/wrale/wrale-signage/internal/wsignd/assignment/memory"
+	assignmentpostgres "github.com/wrale/wrale-signage/internal/wsignd/assignment/postgres"
+	"github.com/wrale/wrale-signage/internal/wsignd/auth"
+	authmemory "github.com/wrale/wrale-signage/internal/wsignd/auth/memory"
+	authpostgres "github.com/wrale/wrale-signage/internal/wsignd/auth/postgres"
+	"github.com/wrale/wrale-signage/internal/wsignd/config"
+	"github.com/wrale/wrale-signage/internal/wsignd/content"
+	contentmemory "github.com/wrale/wrale-signage/internal/wsignd/content/memory"
+	contentpostgres "github.com/wrale/wrale-signage/internal/wsignd/content/postgres"
+	"github.com/wrale/wrale-signage/internal/wsignd/database"
+	"github.com/wrale/wrale-signage/internal/wsignd/display"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/activation"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/memory"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/outbox"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/postgres"
+	"github.com/wrale/wrale-signage/internal/wsignd/display/proposal"
+	"github.com/wrale/wrale-signage/internal/wsignd/maintenance"
+	maintenancememory "github.com/wrale/wrale-signage/internal/wsignd/maintenance/memory"
+	maintenancepostgres "github.com/wrale/wrale-signage/internal/wsignd/maintenance/postgres"
+	"github.com/wrale/wrale-signage/internal/wsignd/operator"
+	operatormemory "github.com/wrale/wrale-signage/internal/wsignd/operator/memory"
+	operatorpostgres "github.com/wrale/wrale-signage/internal/wsignd/operator/postgres"
+	"github.com/wrale/wrale-signage/internal/wsignd/ratelimit"
+)
+
+// Stores holds the storage the services are built on
+type Stores struct {
+	Displays   display.Repository
+	Activation activation.Repository
+	Proposals  proposal.Repository
+	Outbox     outbox.Repository
+	Tokens     auth.Repository
+	Content    content.Repository
+	Events     content.EventStore
+	Metrics    content.MetricsAggregator
+	// EventRetention prunes old events and prepares their partitions
+	EventRetention content.EventRetentionStore
+
+	Assignments assignment.Repository
+	Operators   operator.Repository
+	Maintenance maintenance.Repository
+
+	// RateLimits counts requests against their limits. When nil an
+	// in-memory store with the default limits is used.
+	RateLimits ratelimit.Service
+
+	// Queries times the statements run on the database, and is nil when
+	// they are not timed
+	Queries *database.QueryTimer
+}
+
+// PostgresStores builds storage backed by the database. Reads that can
+// tolerate replication lag go to reader when it is not nil. Identical
+// display and content source lookups made at the same time share one query
+// unless coalescing is disabled.
+func PostgresStores(db, reader *sql.DB, cfg *config.Config) *Stores {
+	replica := database.WithReader(reader)
+	contentRepo := contentpostgres.NewRepository(db, replica)
+	displays := postgres.NewRepository(db, replica)
+	var sources content.Repository = contentRepo
+	if cfg.Database.CoalesceReads {
+		displays = display.NewCoalescingRepository(displays)
+		sources = content.NewCoalescingRepository(sources)
+	}
+	return &Stores{
+		Displays:   displays,
+		Activation: postgres.NewActivationRepository(db),
+		Proposals:  postgres.NewProposalRepository(db),
+		Outbox:     postgres.NewOutboxRepository(db),
+		Tokens:     authpostgres.NewRepository(db),
+		Content:    sources,
+		Events:     contentRepo,
+		Metrics:    contentpostgres.NewMetricsAggregator(contentRepo, cfg.Content.MetricsWindow),
+
+		EventRetention: contentRepo,
+
+		Assignments: assignmentpostgres.NewRepository(db, replica),
+		Operators:   operatorpostgres.NewRepository(db),
+		Maintenance: maintenancepostgres.NewRepository(db),
+	}
+}
+
+// MemoryStores builds in-memory storage, as used in demo mode
+func MemoryStores(cfg *config.Config) *Stores {
+	contentRepo := contentmemory.NewRepository()
+	displayRepo := memory.NewRepository()
+	contentRepo.TrackDisplayErrors(displayRepo.(display.LastErrorStore))
+	assignmentRepo := assignmentmemory.NewRepository()
+	contentRepo.TrackReferences(assignmentRepo)
+	assignmentRepo.RequireSources(contentRepo)
+	return &Stores{
+		Displays:   displayRepo,
+		Activation: memory.NewActivationRepository(),
+		Proposals:  memory.NewProposalRepository(),
+		Outbox:     memory.NewOutboxRepository(),
+		Tokens:     authmemory.NewRepository(),
+		Content:    contentRepo,
+		Events:     contentRepo,
+		Metrics:    contentmemory.NewMetricsAggregator(contentRepo, cfg.Content.MetricsWindow),
+
+		EventRetention: contentRepo,
+
+		Assignments: assignmentRepo,
+		Operators:   operatormemory.NewRepository(),
+		Maintenance: maintenancememory.NewRepository(),
+	}
+}
@@ -0,0 +1,114 @@
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// ErrRecorded is what every statement run on a recording database fails
+// with
+var ErrRecorded = errors.New("statement recorded, not run")
+
+// QueryLog holds the statements run on a recording database
+type QueryLog struct {
+	mu      sync.Mutex
+	queries []string
+}
+
+// Queries returns the statements run so far, in order
+func (l *QueryLog) Queries() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.queries...)
+}
+
+// Reset forgets the statements run so far
+func (l *QueryLog) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.queries = nil
+}
+
+func (l *QueryLog) record(query string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.queries = append(l.queries, query)
+	return ErrRecorded
+}
+
+var (
+	registerRecording sync.Once
+	recordingLogs     sync.Map
+	recordingSeq      atomic.Int64
+)
+
+// RecordingDB returns a database that needs no server: it logs every
+// statement and fails it with ErrRecorded. It shows which connection a
+// repository sends its queries to.
+func RecordingDB(t testing.TB) (*sql.DB, *QueryLog) {
+	t.Helper()
+
+	registerRecording.Do(func() {
+		sql.Register("recording", recordingDriver{})
+	})
+	name := fmt.Sprintf("recording-%d", recordingSeq.Add(1))
+	log := &QueryLog{}
+	recordingLogs.Store(name, log)
+
+	db, err := sql.Open("recording", name)
+	if err != nil {
+		t.Fatalf("opening recording database: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		recordingLogs.Delete(name)
+	})
+	return db, log
+}
+
+type recordingDriver struct{}
+
+func (recordingDriver) Open(name string) (driver.Conn, error) {
+	log, ok := recordingLogs.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown recording database %q", name)
+	}
+	return &recordingConn{log: log.(*QueryLog)}, nil
+}
+
+// recordingConn records statements instead of running them. Transactions
+// begin and end without recording anything.
+type recordingConn struct {
+	log *QueryLog
+}
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, c.log.record(query)
+}
+
+func (c *recordingConn) Close() error { return nil }
+
+func (c *recordingConn) Begin() (driver.Tx, error) { return recordingTx{}, nil }
+
+func (c *recordingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return recordingTx{}, nil
+}
+
+func (c *recordingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return nil, c.log.record(query)
+}
+
+func (c *recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return nil, c.log.record(query)
+}
+
+type recordingTx struct{}
+
+func (recordingTx) Commit() error   { return nil }
+func (recordingTx) Rollback() error { return nil }
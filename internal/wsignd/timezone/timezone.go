@@ -0,0 +1,79 @@
+// Package timezone loads the IANA time zones operators give for sites and
+// displays, and reads the time zone an API request asks its timestamps to
+// be given in.
+package timezone
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+)
+
+const (
+	// Param is the query parameter asking for timestamps in a time zone
+	Param = "tz"
+	// Local asks for each display's timestamps in the display's own zone
+	Local = "local"
+)
+
+// locations caches loaded time zones, which are otherwise read from disk
+// on every lookup
+var locations sync.Map
+
+// Load returns the named IANA time zone, such as Europe/Berlin. The empty
+// name and "Local" are refused: they name UTC and the zone of whichever
+// machine reads them rather than a place.
+func Load(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	if name == "" || strings.EqualFold(name, "local") {
+		return nil, fmt.Errorf("%q is not an IANA time zone name such as Europe/Berlin", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// Request is the time zone a request asked for
+type Request struct {
+	// Location is the zone asked for by name, nil when Local is set
+	Location *time.Location
+	// Local asks for each display's own zone
+	Local bool
+}
+
+// FromRequest reads ?tz=local or ?tz=<IANA name> from r. It returns nil
+// when the parameter is absent, so timestamps are left as stored.
+func FromRequest(r *http.Request) (*Request, error) {
+	const op = "timezone.FromRequest"
+
+	name := r.URL.Query().Get(Param)
+	switch {
+	case name == "":
+		return nil, nil
+	case name == Local:
+		return &Request{Local: true}, nil
+	}
+	loc, err := Load(name)
+	if err != nil {
+		return nil, werrors.NewError("INVALID_INPUT", fmt.Sprintf("%s must be %q or an IANA time zone name: %v", Param, Local, err), op, werrors.ErrInvalidInput)
+	}
+	return &Request{Location: loc}, nil
+}
+
+// In returns t in loc, leaving the zero time alone so that it still reads
+// as never
+func In(t time.Time, loc *time.Location) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.In(loc)
+}
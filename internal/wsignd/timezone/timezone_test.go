@@ -0,0 +1,54 @@
+package timezone_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	werrors "github.com/wrale/wrale-signage/internal/wsignd/errors"
+	"github.com/wrale/wrale-signage/internal/wsignd/timezone"
+)
+
+func TestLoad(t *testing.T) {
+	loc, err := timezone.Load("Europe/Berlin")
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/Berlin", loc.String())
+
+	for _, name := range []string{"", "Local", "local", "Europe/Berlinn"} {
+		_, err := timezone.Load(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	tz, err := timezone.FromRequest(httptest.NewRequest(http.MethodGet, "/displays", nil))
+	require.NoError(t, err)
+	assert.Nil(t, tz, "absent means unchanged")
+
+	tz, err = timezone.FromRequest(httptest.NewRequest(http.MethodGet, "/displays?tz=local", nil))
+	require.NoError(t, err)
+	assert.Equal(t, &timezone.Request{Local: true}, tz)
+
+	tz, err = timezone.FromRequest(httptest.NewRequest(http.MethodGet, "/displays?tz=America/New_York", nil))
+	require.NoError(t, err)
+	require.NotNil(t, tz.Location)
+	assert.Equal(t, "America/New_York", tz.Location.String())
+
+	_, err = timezone.FromRequest(httptest.NewRequest(http.MethodGet, "/displays?tz=Mars/Olympus", nil))
+	assert.True(t, werrors.IsInvalidInput(err))
+}
+
+func TestIn(t *testing.T) {
+	ny, err := timezone.Load("America/New_York")
+	require.NoError(t, err)
+
+	// Either side of the spring daylight saving change
+	assert.Equal(t, "2024-03-10T01:59:00-05:00", timezone.In(time.Date(2024, 3, 10, 6, 59, 0, 0, time.UTC), ny).Format(time.RFC3339))
+	assert.Equal(t, "2024-03-10T03:00:00-04:00", timezone.In(time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC), ny).Format(time.RFC3339))
+	assert.True(t, timezone.In(time.Time{}, ny).IsZero())
+	assert.Equal(t, time.UTC, timezone.In(time.Time{}, ny).Location())
+}
@@ -1,5 +1,17 @@
 import React, { useEffect, useRef, useState } from 'react';
-import { ContentSequence } from '../types';
+import {
+  CONTROL_API_VERSION,
+  ContentSequence,
+  ControlMessageType,
+  RECONNECT_RESET_MS,
+  RECONNECT_SPLAY_MS,
+  clampPollingHints,
+  reconnectDelay
+} from '../types';
+
+// Message types this controller acts on, declared to the server so it
+// never sends types the controller would not understand
+const CAPABILITIES: ControlMessageType[] = ['SEQUENCE_UPDATE', 'RELOAD', 'RATE_LIMITED', 'SETTINGS', 'ERROR', 'RECONNECT'];
 
 interface ContentControllerProps {
   displayId: string;
@@ -17,15 +29,40 @@ export const ContentController: React.FC<ContentControllerProps> = ({
   const ws = useRef<WebSocket | null>(null);
   const [currentUrl, setCurrentUrl] = useState<string>('');
   const [lastError, setLastError] = useState<string | null>(null);
+  const unknownMessages = useRef(0);
+  // Status reports are held back while the server is dropping them
+  const statusBlockedUntil = useRef(0);
+  const statusRetry = useRef<ReturnType<typeof setTimeout> | null>(null);
+  // Status is also reported as a heartbeat, at the interval the server
+  // hints. Content arrives over the socket, so content poll hints are not
+  // used here.
+  const heartbeat = useRef<ReturnType<typeof setInterval> | null>(null);
+  // Reconnects back off with full jitter, or wait as long as the server
+  // asked in a RECONNECT message before it closed the connection. Browsers
+  // hide the status of a refused upgrade, so a Retry-After cannot be read
+  // here; the backoff spreads those retries instead.
+  const reconnectTimer = useRef<ReturnType<typeof setTimeout> | null>(null);
+  const reconnectAttempt = useRef(0);
+  const reconnectHint = useRef<number | null>(null);
 
   useEffect(() => {
+    let stopped = false;
+
     const connect = () => {
       const fullURL = `${wsURL}?id=${displayId}`;
+      const connectedAt = Date.now();
+      reconnectHint.current = null;
       ws.current = new WebSocket(fullURL);
 
       ws.current.onmessage = (event) => {
         const message = JSON.parse(event.data);
-        
+
+        // Newer servers may send versions or types this client predates
+        if (message.apiVersion && message.apiVersion !== CONTROL_API_VERSION) {
+          unknownMessages.current++;
+          return;
+        }
+
         switch (message.type) {
           case 'SEQUENCE_UPDATE':
             if (message.sequence) {
@@ -35,12 +72,61 @@ export const ContentController: React.FC<ContentControllerProps> = ({
           case 'RELOAD':
             onReloadRequired();
             break;
+          case 'STATUS':
+            break;
+          case 'RATE_LIMITED':
+            if (message.rateLimit?.direction === 'inbound') {
+              // The last report may have been dropped, so send it again
+              // once the server accepts messages
+              const retryAfter = message.rateLimit.retryAfterSeconds * 1000;
+              statusBlockedUntil.current = Date.now() + retryAfter;
+              if (statusRetry.current) {
+                clearTimeout(statusRetry.current);
+              }
+              statusRetry.current = setTimeout(sendStatus, retryAfter);
+            }
+            // Outbound limits need nothing: held updates are delivered
+            // once the limit allows, with only the latest sequence kept
+            break;
+          case 'SETTINGS': {
+            const hints = message.polling ? clampPollingHints(message.polling) : {};
+            if (hints.heartbeatInterval) {
+              if (heartbeat.current) {
+                clearInterval(heartbeat.current);
+              }
+              heartbeat.current = setInterval(sendStatus, hints.heartbeatInterval * 1000);
+            }
+            break;
+          }
+          case 'RECONNECT':
+            // The server closes the connection next
+            if (message.reconnect) {
+              reconnectHint.current = message.reconnect.delayMs + Math.random() * message.reconnect.spreadMs;
+            }
+            break;
+          case 'ERROR':
+            // The server dropped a message this display sent
+            if (message.error) {
+              console.warn('control message rejected', message.error.code, message.error.message);
+              setLastError(message.error.message);
+            }
+            break;
+          default:
+            unknownMessages.current++;
+            console.debug('ignored unknown control message', message.type, unknownMessages.current);
         }
       };
 
       ws.current.onclose = () => {
-        // Reconnect after delay
-        setTimeout(connect, 5000);
+        if (stopped) {
+          return;
+        }
+        if (Date.now() - connectedAt >= RECONNECT_RESET_MS) {
+          reconnectAttempt.current = 0;
+        }
+        reconnectAttempt.current++;
+        const wait = reconnectHint.current ?? reconnectDelay(reconnectAttempt.current);
+        reconnectTimer.current = setTimeout(connect, wait);
       };
 
       ws.current.onerror = (error) => {
@@ -49,9 +135,19 @@ export const ContentController: React.FC<ContentControllerProps> = ({
       };
     };
 
-    connect();
+    reconnectTimer.current = setTimeout(connect, Math.random() * RECONNECT_SPLAY_MS);
 
     return () => {
+      stopped = true;
+      if (reconnectTimer.current) {
+        clearTimeout(reconnectTimer.current);
+      }
+      if (statusRetry.current) {
+        clearTimeout(statusRetry.current);
+      }
+      if (heartbeat.current) {
+        clearInterval(heartbeat.current);
+      }
       if (ws.current) {
         ws.current.close();
       }
@@ -59,14 +155,19 @@ export const ContentController: React.FC<ContentControllerProps> = ({
   }, [displayId, wsURL]);
 
   const sendStatus = () => {
+    if (Date.now() < statusBlockedUntil.current) {
+      return;
+    }
     if (ws.current?.readyState === WebSocket.OPEN) {
       ws.current.send(JSON.stringify({
+        apiVersion: CONTROL_API_VERSION,
         type: 'STATUS',
         timestamp: new Date().toISOString(),
         status: {
           currentUrl,
           lastError,
-          updatedAt: new Date().toISOString()
+          updatedAt: new Date().toISOString(),
+          capabilities: CAPABILITIES
         }
       }));
     }
@@ -6,6 +6,9 @@ export interface ContentItem {
   url: string;
   duration: ContentDuration;
   transition: ContentTransition;
+  // How often the item comes up relative to the others; the server fills in
+  // the default of 1, so it is only missing from older servers
+  weight?: number;
 }
 
 export interface ContentDuration {
@@ -22,16 +25,99 @@ export interface DisplayStatus {
   currentUrl: string;
   lastError?: string;
   updatedAt: string;
+  capabilities?: ControlMessageType[];
 }
 
 export type ControlMessageType = 
   | 'SEQUENCE_UPDATE'
   | 'RELOAD'
-  | 'STATUS';
+  | 'STATUS'
+  | 'RATE_LIMITED'
+  | 'SETTINGS'
+  | 'ERROR'
+  | 'RECONNECT';
+
+// Control protocol version this client speaks. Messages with another
+// version, or a type not listed above, are ignored.
+export const CONTROL_API_VERSION = 'v1alpha1';
 
 export interface ControlMessage {
+  apiVersion?: string;
   type: ControlMessageType;
   timestamp: string;
   sequence?: ContentSequence;
   status?: DisplayStatus;
+  rateLimit?: ControlRateLimit;
+  polling?: PollingHints;
+  error?: ControlError;
+  reconnect?: ControlReconnect;
+}
+
+// Sent with RECONNECT just before the server closes the connection. Wait
+// delayMs plus a random part of spreadMs before connecting again, so the
+// fleet does not all land on the first server that comes up.
+export interface ControlReconnect {
+  delayMs: number;
+  spreadMs: number;
+  reason?: string;
+}
+
+// Reconnect backoff: the wait after each failure is picked at random up to
+// a ceiling that doubles from the initial value to the maximum. The first
+// connection is delayed by a random part of the splay, so displays powered
+// on together do not connect together.
+export const RECONNECT_INITIAL_MS = 1000;
+export const RECONNECT_MAX_MS = 2 * 60 * 1000;
+export const RECONNECT_SPLAY_MS = 10 * 1000;
+// A connection that stays up this long resets the backoff
+export const RECONNECT_RESET_MS = 60 * 1000;
+
+// reconnectDelay returns the full jitter wait after attempt consecutive
+// failures
+export function reconnectDelay(attempt: number): number {
+  const ceiling = Math.min(RECONNECT_MAX_MS, RECONNECT_INITIAL_MS * 2 ** Math.max(0, attempt - 1));
+  return Math.random() * ceiling;
+}
+
+// Sent with ERROR when a message this display sent was dropped. For
+// MESSAGE_TOO_LARGE, limit is the largest message in bytes the server
+// accepts.
+export interface ControlError {
+  code: string;
+  message: string;
+  limit?: number;
 }
+
+// Sent with RATE_LIMITED. Outbound means updates for this display are held
+// and will still arrive; inbound means messages this display sent were
+// dropped and should be sent again after retryAfterSeconds.
+export interface ControlRateLimit {
+  direction: 'outbound' | 'inbound';
+  retryAfterSeconds: number;
+}
+
+// Sent with SETTINGS. Intervals are in seconds; an unset one means keep the
+// current interval.
+export interface PollingHints {
+  heartbeatInterval?: number;
+  contentPollInterval?: number;
+}
+
+// Bounds applied to polling hints, matching the server's, so a bad hint
+// cannot make the display flood the server or go quiet for days
+export const MIN_HEARTBEAT_INTERVAL = 15;
+export const MAX_HEARTBEAT_INTERVAL = 60 * 60;
+export const MIN_CONTENT_POLL_INTERVAL = 30;
+export const MAX_CONTENT_POLL_INTERVAL = 24 * 60 * 60;
+
+const clampHint = (value: number | undefined, min: number, max: number): number | undefined => {
+  if (!value || value <= 0) {
+    return undefined;
+  }
+  return Math.min(Math.max(value, min), max);
+};
+
+export const clampPollingHints = (hints: PollingHints): PollingHints => ({
+  heartbeatInterval: clampHint(hints.heartbeatInterval, MIN_HEARTBEAT_INTERVAL, MAX_HEARTBEAT_INTERVAL),
+  contentPollInterval: clampHint(hints.contentPollInterval, MIN_CONTENT_POLL_INTERVAL, MAX_CONTENT_POLL_INTERVAL),
+});